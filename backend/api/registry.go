@@ -0,0 +1,240 @@
+// Package api describes HTTP routes in a machine-readable form, generates an
+// OpenAPI 3 document from them and validates request bodies against their schemas.
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxBodyBytes caps request bodies read for validation
+const maxBodyBytes = 1 << 20
+
+// Param describes a path or query parameter
+type Param struct {
+	Name        string
+	In          string // "path" or "query"
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Route describes a single endpoint
+type Route struct {
+	Method       string
+	Path         string // mux-style path, e.g. /v0/admin/withdrawals/{id}
+	Summary      string
+	Tag          string
+	Admin        bool   // Requires an admin token
+	Permission   string // Admin permission required, if any
+	Crypto       bool   // Needs DFNS; answers 503 while crypto features are disabled
+	Params       []Param
+	Body         *Schema // Request body schema; nil for no body
+	BodyOptional bool    // Body may be omitted entirely
+	Response     *Schema // Success response schema, for documentation
+}
+
+// Registry collects routes for documentation and validation
+type Registry struct {
+	mu     sync.RWMutex
+	routes []Route
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register records the route and returns h wrapped with request body validation
+func (reg *Registry) Register(route Route, h http.Handler) http.Handler {
+	reg.mu.Lock()
+	reg.routes = append(reg.routes, route)
+	reg.mu.Unlock()
+
+	if route.Body == nil {
+		return h
+	}
+	return ValidateBody(route, h)
+}
+
+// Routes returns the registered routes sorted by path and method
+func (reg *Registry) Routes() []Route {
+	reg.mu.RLock()
+	routes := append([]Route(nil), reg.routes...)
+	reg.mu.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// ValidationErrorResponse is the structured 400 body returned for invalid requests
+type ValidationErrorResponse struct {
+	Error   string       `json:"error"`
+	Details []FieldError `json:"details"`
+}
+
+// ValidateBody rejects requests whose JSON body does not match route.Body.
+// The body is restored so the wrapped handler can decode it as usual.
+func ValidateBody(route Route, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+		if err != nil {
+			writeValidationError(w, "could not read request body", nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+
+		if len(bytes.TrimSpace(raw)) == 0 {
+			if route.BodyOptional {
+				h.ServeHTTP(w, r)
+				return
+			}
+			writeValidationError(w, "request body is required", nil)
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			writeValidationError(w, "request body is not valid JSON", nil)
+			return
+		}
+
+		if errs := route.Body.Validate(value); len(errs) > 0 {
+			writeValidationError(w, "request validation failed", errs)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeValidationError(w http.ResponseWriter, message string, details []FieldError) {
+	if details == nil {
+		details = []FieldError{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{Error: message, Details: details})
+}
+
+// OpenAPI builds an OpenAPI 3 document describing the registered routes
+func (reg *Registry) OpenAPI(title, version string) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	for _, route := range reg.Routes() {
+		op := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"tags":        []string{route.Tag},
+			"security":    []map[string][]string{{"bearerAuth": {}}},
+		}
+
+		var params []map[string]interface{}
+		for _, p := range route.Params {
+			schema := p.Schema
+			if schema == nil {
+				schema = String("")
+			}
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"required":    p.Required || p.In == "path",
+				"schema":      schema,
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if route.Body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": !route.BodyOptional,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": route.Body}},
+			}
+		}
+
+		success := map[string]interface{}{"description": "Success"}
+		if route.Response != nil {
+			success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": route.Response}}
+		}
+		responses := map[string]interface{}{
+			"200": success,
+			"401": map[string]interface{}{"description": "Unauthorized"},
+		}
+		if route.Body != nil {
+			responses["400"] = map[string]interface{}{
+				"description": "Validation error",
+				"content": map[string]interface{}{"application/json": map[string]interface{}{
+					"schema": map[string]string{"$ref": "#/components/schemas/ValidationError"},
+				}},
+			}
+		}
+		if route.Crypto {
+			responses["503"] = map[string]interface{}{"description": "Crypto features disabled: DFNS is not configured"}
+		}
+		if route.Permission != "" {
+			responses["403"] = map[string]interface{}{"description": "Requires the " + route.Permission + " admin permission"}
+		} else if route.Admin {
+			responses["403"] = map[string]interface{}{"description": "Admin access required"}
+		}
+		op["responses"] = responses
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+			"schemas": map[string]interface{}{
+				"ValidationError": Object(map[string]*Schema{
+					"error": String("Summary of the problem"),
+					"details": ArrayOf(Object(map[string]*Schema{
+						"field":   String("Offending field, dot-separated"),
+						"message": String("What is wrong with it"),
+					}), "Per-field errors"),
+				}, "error", "details"),
+			},
+		},
+	}
+}
+
+// ServeOpenAPI returns a handler that serves the OpenAPI document as JSON
+func (reg *Registry) ServeOpenAPI(title, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reg.OpenAPI(title, version))
+	}
+}
+
+// operationID derives a stable identifier such as postV0WalletWithdraw
+func operationID(route Route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool { return r == '/' || r == '-' }) {
+		part = strings.Trim(part, "{}")
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, route Route, body string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	called := false
+	h := NewRegistry().Register(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		// The wrapped handler must still be able to read the body
+		raw, _ := io.ReadAll(r.Body)
+		if string(raw) != body {
+			t.Errorf("handler body = %q, want %q", raw, body)
+		}
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(route.Method, route.Path, strings.NewReader(body)))
+	return rr, called
+}
+
+func TestValidateBodyAcceptsValidWithdrawal(t *testing.T) {
+	rr, called := serve(t, WalletWithdraw, `{"chainName":"base","tokenSymbol":"USDC","amount":12.5,"toAddress":"0xabc"}`)
+	if !called || rr.Code != http.StatusOK {
+		t.Fatalf("expected handler to run, got status %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestValidateBodyReturnsFieldErrors(t *testing.T) {
+	rr, called := serve(t, WalletWithdraw, `{"chainName":"base","tokenSymbol":7,"toAddress":42}`)
+	if called {
+		t.Fatal("handler should not run for an invalid body")
+	}
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rr.Code)
+	}
+
+	var resp ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	got := map[string]string{}
+	for _, fe := range resp.Details {
+		got[fe.Field] = fe.Message
+	}
+	want := map[string]string{
+		"amount":      "is required",
+		"tokenSymbol": "must be a string",
+		"toAddress":   "must be a string",
+	}
+	for field, msg := range want {
+		if got[field] != msg {
+			t.Errorf("details[%s] = %q, want %q (all: %v)", field, got[field], msg, resp.Details)
+		}
+	}
+
+	rr, _ = serve(t, WalletWithdraw, `{"amount":-1}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "must be greater than 0") {
+		t.Errorf("negative amount: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestValidateBodyOptionalAndRequired(t *testing.T) {
+	if _, called := serve(t, AdminApproveWithdrawal, ""); !called {
+		t.Error("approve should accept an empty body")
+	}
+	if rr, _ := serve(t, AdminRejectWithdrawal, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("reject with no body: status = %d, want 400", rr.Code)
+	}
+	if rr, _ := serve(t, AdminRejectWithdrawal, `{"reason":""}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("reject with empty reason: status = %d, want 400", rr.Code)
+	}
+	if rr, _ := serve(t, WalletPendingDepositBetting, `{"enabled":`); rr.Code != http.StatusBadRequest {
+		t.Errorf("malformed JSON: status = %d, want 400", rr.Code)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	reg := NewRegistry()
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	reg.Register(WalletWithdraw, noop)
+	reg.Register(AdminRejectWithdrawal, noop)
+
+	rr := httptest.NewRecorder()
+	reg.ServeOpenAPI(Title, Version)(rr, httptest.NewRequest("GET", "/v0/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("document is not JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+
+	withdraw := doc.Paths["/v0/wallet/withdraw"]["post"]
+	if withdraw == nil || withdraw["operationId"] != "postV0WalletWithdraw" {
+		t.Fatalf("missing withdraw operation: %v", doc.Paths)
+	}
+	if _, ok := withdraw["requestBody"]; !ok {
+		t.Error("withdraw operation should document its request body")
+	}
+	if responses, _ := withdraw["responses"].(map[string]interface{}); responses["503"] == nil {
+		t.Error("crypto operation should document a 503 response")
+	}
+
+	reject := doc.Paths["/v0/admin/withdrawals/{id}/reject"]["post"]
+	responses, _ := reject["responses"].(map[string]interface{})
+	if _, ok := responses["403"]; !ok {
+		t.Error("admin operation should document a 403 response")
+	}
+}
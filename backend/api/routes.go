@@ -0,0 +1,529 @@
+package api
+
+import "socialpredict/models"
+
+// Title and Version identify the generated OpenAPI document
+const (
+	Title   = "SocialPredict API"
+	Version = "v0"
+)
+
+const (
+	tagWallet       = "wallet"
+	tagTransactions = "transactions"
+	tagAdmin        = "admin-withdrawals"
+)
+
+var idParam = Param{Name: "id", In: "path", Description: "Record ID", Schema: Integer("")}
+
+var tokenIDParam = Param{Name: "tokenId", In: "path", Description: "Chain token ID", Schema: Integer("")}
+
+var txStatuses = []string{
+	models.TxStatusAwaitingConfirmation, models.TxStatusPending, models.TxStatusApproving, models.TxStatusUnrecorded,
+	models.TxStatusApproved, models.TxStatusCompleted, models.TxStatusFailed, models.TxStatusRejected, models.TxStatusOnHold,
+	models.TxStatusDust,
+}
+
+// Wallet routes
+var (
+	WalletDepositAddress = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/deposit/{chain}",
+		Summary: "Get or create the user's deposit address on a chain",
+		Tag:     tagWallet,
+		Crypto:  true,
+		Params:  []Param{{Name: "chain", In: "path", Description: "Chain name, e.g. base", Schema: String("")}},
+	}
+	WalletDepositAddresses = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/deposits",
+		Summary: "List the user's deposit addresses on all active chains",
+		Tag:     tagWallet,
+		Crypto:  true,
+	}
+	WalletList = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/wallets",
+		Summary: "List the user's deposit wallets with on-chain balances and uncredited deposits",
+		Tag:     tagWallet,
+		Crypto:  true,
+	}
+	WalletWithdraw = Route{
+		Method:  "POST",
+		Path:    "/v0/wallet/withdraw",
+		Summary: "Request a withdrawal to an external address",
+		Tag:     tagWallet,
+		Crypto:  true,
+		Body: Object(map[string]*Schema{
+			"chainName":   String("Chain to withdraw on; defaults to the preferred chain from wallet settings"),
+			"tokenSymbol": String("Token to receive, e.g. USDC; defaults to the preferred token"),
+			"amount":      Number("Amount in credits, up to 6 decimal places").Positive(),
+			"toAddress":   String("External wallet address; defaults to the chain's default saved address").WithMaxLength(128),
+
+			"twoFactorCode": String("TOTP code; needed at or above the 2FA withdrawal threshold").WithMaxLength(16),
+			"emailToken":    String("Emailed confirmation code, instead of twoFactorCode").WithMaxLength(16),
+
+			"beneficiary": Object(map[string]*Schema{
+				"type":     String("Who controls the destination").WithEnum(models.BeneficiarySelfCustody, models.BeneficiaryExchange),
+				"name":     String("Beneficiary's name").WithMinLength(1).WithMaxLength(140),
+				"exchange": String("Receiving exchange; needed for EXCHANGE").WithMaxLength(140),
+			}, "type", "name"),
+		}, "amount"),
+	}
+	WalletValidateWithdrawal = Route{
+		Method:  "POST",
+		Path:    "/v0/wallet/withdraw/validate",
+		Summary: "Check a withdrawal against minimums, balance and limits without submitting it",
+		Tag:     tagWallet,
+		Crypto:  true,
+		Body:    WalletWithdraw.Body,
+	}
+	WalletConfirmWithdrawal = Route{
+		Method:  "POST",
+		Path:    "/v0/wallet/withdrawals/confirm",
+		Summary: "Confirm a withdrawal with the token from its emailed link (no session needed)",
+		Tag:     tagWallet,
+		Crypto:  true,
+		Body: Object(map[string]*Schema{
+			"token": String("Token from the confirmation link").WithMinLength(1).WithMaxLength(256),
+		}, "token"),
+	}
+	WalletWithdrawals = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/withdrawals",
+		Summary: "List the user's withdrawal requests",
+		Tag:     tagWallet,
+	}
+	WalletTransactions = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/transactions",
+		Summary: "List the user's crypto transactions",
+		Tag:     tagTransactions,
+		Params: []Param{
+			{Name: "page", In: "query", Description: "Page number, starting at 1 (deprecated, use cursor)", Schema: Integer("")},
+			{Name: "pageSize", In: "query", Description: "Items per page, at most 50", Schema: Integer("")},
+			{Name: "cursor", In: "query", Description: "nextCursor from the previous page; takes precedence over page", Schema: String("")},
+			{Name: "type", In: "query", Schema: String("").WithEnum(models.TxTypeDeposit, models.TxTypeWithdrawal)},
+			{Name: "status", In: "query", Schema: String("").WithEnum(txStatuses...)},
+		},
+	}
+	WalletActivity = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/activity",
+		Summary: "List crypto transactions, market payouts and other balance activity in one feed",
+		Tag:     tagTransactions,
+		Params: []Param{
+			{Name: "pageSize", In: "query", Description: "Items per page, at most 50", Schema: Integer("")},
+			{Name: "cursor", In: "query", Description: "nextCursor from the previous page", Schema: String("")},
+		},
+	}
+	WalletChains = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/chains",
+		Summary: "List supported chains",
+		Tag:     tagWallet,
+	}
+	WalletTokens = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/tokens",
+		Summary: "List supported tokens",
+		Tag:     tagWallet,
+	}
+	WalletInfo = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/info",
+		Summary: "Get the user's wallet summary",
+		Tag:     tagWallet,
+	}
+	WalletBalance = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/balance",
+		Summary: "Get the user's balance broken down into available and locked credits",
+		Tag:     tagWallet,
+	}
+	WalletEmailConfirmation = Route{
+		Method:  "POST",
+		Path:    "/v0/wallet/withdrawal-email-confirmation",
+		Summary: "Turn emailed confirmation links for withdrawals on or off",
+		Tag:     tagWallet,
+		Body: Object(map[string]*Schema{
+			"enabled":       Boolean("Whether withdrawals wait for an emailed confirmation link"),
+			"twoFactorCode": String("TOTP code; needed to turn the setting off").WithMaxLength(16),
+			"emailToken":    String("Emailed SECURITY_SETTINGS code, instead of twoFactorCode").WithMaxLength(16),
+		}, "enabled"),
+	}
+	WalletPendingDepositBetting = Route{
+		Method:  "POST",
+		Path:    "/v0/wallet/pending-deposit-betting",
+		Summary: "Opt in or out of betting against pending deposits",
+		Tag:     tagWallet,
+		Body: Object(map[string]*Schema{
+			"enabled": Boolean("Whether unconfirmed deposits count towards betting"),
+		}, "enabled"),
+	}
+	WalletSettings = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/settings",
+		Summary: "Get the user's wallet defaults, saved addresses and notification preferences",
+		Tag:     tagWallet,
+	}
+	WalletUpdateSettings = Route{
+		Method:  "PATCH",
+		Path:    "/v0/wallet/settings",
+		Summary: "Change the user's wallet defaults; fields left out are kept",
+		Tag:     tagWallet,
+		Body: Object(map[string]*Schema{
+			"preferredChain": String("Chain the withdrawal form starts on; empty clears it").WithMaxLength(50),
+			"preferredToken": String("Token on the preferred chain; empty clears it").WithMaxLength(20),
+			"savedAddresses": ArrayOf(Object(map[string]*Schema{
+				"chainName": String("Chain the address is on").WithMinLength(1),
+				"address":   String("External wallet address").WithMinLength(1).WithMaxLength(128),
+				"label":     String("Name shown for the address").WithMaxLength(50),
+				"isDefault": Boolean("Use this address when a withdrawal on its chain gives none; one per chain"),
+			}, "chainName", "address"), "Replaces all saved addresses, at most 20"),
+			"notifyWithdrawalCompleted": Boolean("Notify when a withdrawal completes"),
+		}),
+	}
+	WalletTransfer = Route{
+		Method:  "POST",
+		Path:    "/v0/wallet/transfer",
+		Summary: "Send credits to another user",
+		Tag:     tagWallet,
+		Body: Object(map[string]*Schema{
+			"toUsername": String("Recipient's username").WithMinLength(1).WithMaxLength(30),
+			"amount":     Number("Amount in credits, up to 6 decimal places").Positive(),
+			"memo":       String("Optional note shown to the recipient").WithMaxLength(280),
+		}, "toUsername", "amount"),
+	}
+	WalletListTransfers = Route{
+		Method:  "GET",
+		Path:    "/v0/wallet/transfers",
+		Summary: "List the user's recent sent and received credit transfers",
+		Tag:     tagWallet,
+	}
+)
+
+// Admin withdrawal routes
+var (
+	AdminListWithdrawals = Route{
+		Method:     "GET",
+		Path:       "/v0/admin/withdrawals",
+		Summary:    "List withdrawal requests",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermWithdrawalsView,
+		Params: []Param{
+			{Name: "status", In: "query", Schema: String("").WithEnum(txStatuses...)},
+			{Name: "minRisk", In: "query", Description: "Only requests with at least this risk score", Schema: Integer("")},
+			{Name: "country", In: "query", Description: "Only requests from this ISO country code", Schema: String("")},
+			{Name: "newCountry", In: "query", Description: "Only requests from a country the user had not withdrawn from before", Schema: Boolean("")},
+			{Name: "sort", In: "query", Description: "Sort by risk score instead of date", Schema: String("").WithEnum("risk")},
+			{Name: "page", In: "query", Schema: Integer("")},
+			{Name: "limit", In: "query", Description: "Items per page, at most 100", Schema: Integer("")},
+		},
+	}
+	AdminWithdrawalStats = Route{
+		Method:     "GET",
+		Path:       "/v0/admin/withdrawals/stats",
+		Summary:    "Get withdrawal statistics",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermWithdrawalsView,
+	}
+	AdminTravelRuleExport = Route{
+		Method:     "GET",
+		Path:       "/v0/admin/withdrawals/travel-rule",
+		Summary:    "Export withdrawals with beneficiary attestations, or over the travel-rule threshold, for compliance",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermWithdrawalsView,
+		Params: []Param{
+			{Name: "from", In: "query", Description: "First day, YYYY-MM-DD; defaults to 30 days ago", Schema: String("")},
+			{Name: "to", In: "query", Description: "Last day, YYYY-MM-DD; defaults to today", Schema: String("")},
+			{Name: "format", In: "query", Schema: String("").WithEnum("json", "csv")},
+		},
+	}
+	AdminWithdrawalDetails = Route{
+		Method:     "GET",
+		Path:       "/v0/admin/withdrawals/{id}",
+		Summary:    "Get a withdrawal request with user context",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermWithdrawalsView,
+		Params:     []Param{idParam},
+	}
+	AdminApproveWithdrawal = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/withdrawals/{id}/approve",
+		Summary:    "Approve a withdrawal and start the transfer",
+		Tag:        tagAdmin,
+		Crypto:     true,
+		Admin:      true,
+		Permission: models.PermWithdrawalsApprove,
+		Params:     []Param{idParam},
+		Body: Object(map[string]*Schema{
+			"note":             String("Optional admin note").WithMaxLength(1000),
+			"amount":           Number("Approve this many credits instead, less than requested; the rest is refunded").Positive(),
+			"adjustmentReason": String("Why less is approved; needed with amount").WithMaxLength(1000),
+		}),
+		BodyOptional: true,
+	}
+	AdminRejectWithdrawal = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/withdrawals/{id}/reject",
+		Summary:    "Reject a withdrawal and refund the user",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermWithdrawalsApprove,
+		Params:     []Param{idParam},
+		Body: Object(map[string]*Schema{
+			"reason": String("Reason shown to the user").WithMinLength(1).WithMaxLength(1000),
+		}, "reason"),
+	}
+	AdminAddWithdrawalNote = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/withdrawals/{id}/notes",
+		Summary:    "Add a reviewer note to a withdrawal's note thread",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermWithdrawalsView,
+		Params:     []Param{idParam},
+		Body: Object(map[string]*Schema{
+			"note": String("Note for other reviewers").WithMinLength(1).WithMaxLength(2000),
+			"flag": Boolean("Flag the request for other reviewers"),
+		}, "note"),
+	}
+	AdminGetWithdrawalLimits = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/settings/withdrawal-limits",
+		Summary: "Get withdrawal limits",
+		Tag:     tagAdmin,
+		Admin:   true,
+	}
+	AdminUpdateWithdrawalLimits = Route{
+		Method:  "PUT",
+		Path:    "/v0/admin/settings/withdrawal-limits",
+		Summary: "Update withdrawal limits",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Body: Object(map[string]*Schema{
+			"minWithdrawal": Number("Minimum credits per withdrawal").Positive(),
+			"maxWithdrawal": Number("Maximum credits per single withdrawal").Positive(),
+			"dailyLimit":    Number("Maximum credits per user over a rolling 24 hours").Positive(),
+			"weeklyLimit":   Number("Maximum credits per user over 7 days; 0 for no limit, omit to keep").NonNegative(),
+			"monthlyLimit":  Number("Maximum credits per user over 30 days; 0 for no limit, omit to keep").NonNegative(),
+
+			"globalDailyLimit":   Number("Maximum credits across all users over a rolling 24 hours; 0 for no limit, omit to keep").NonNegative(),
+			"globalWeeklyLimit":  Number("Maximum credits across all users over 7 days; 0 for no limit, omit to keep").NonNegative(),
+			"globalMonthlyLimit": Number("Maximum credits across all users over 30 days; 0 for no limit, omit to keep").NonNegative(),
+		}, "minWithdrawal", "maxWithdrawal", "dailyLimit"),
+	}
+	AdminGetTransferSettings = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/settings/transfers",
+		Summary: "Get the user-to-user credit transfer settings",
+		Tag:     tagAdmin,
+		Admin:   true,
+	}
+	AdminUpdateTransferSettings = Route{
+		Method:  "PUT",
+		Path:    "/v0/admin/settings/transfers",
+		Summary: "Switch credit transfers on or off and set the daily limit",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Body: Object(map[string]*Schema{
+			"enabled":    Boolean("Whether users may send credits to each other"),
+			"dailyLimit": Number("Maximum credits a user may send over a rolling 24 hours; omit to keep").Positive(),
+		}, "enabled"),
+	}
+	AdminGetCreatorFeeSettings = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/settings/creator-fees",
+		Summary: "Get the creator fee rate and the platform's share of it",
+		Tag:     tagAdmin,
+		Admin:   true,
+	}
+	AdminUpdateCreatorFeeSettings = Route{
+		Method:  "PUT",
+		Path:    "/v0/admin/settings/creator-fees",
+		Summary: "Set the creator fee on trade volume and the platform's share of it",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Body: Object(map[string]*Schema{
+			"feeBps":           Integer("Fee charged on each buy for the market creator, in basis points of the amount; 0 switches fees off").NonNegative(),
+			"platformShareBps": Integer("Part of each creator fee the platform keeps, in basis points").NonNegative(),
+		}, "feeBps", "platformShareBps"),
+	}
+	AdminGetMakerCheckerSettings = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/settings/maker-checker",
+		Summary: "Get the maker-checker policy for admin money actions",
+		Tag:     tagAdmin,
+		Admin:   true,
+	}
+	AdminUpdateMakerCheckerSettings = Route{
+		Method:  "PUT",
+		Path:    "/v0/admin/settings/maker-checker",
+		Summary: "Set the maker-checker policy for admin money actions",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Body: Object(map[string]*Schema{
+			"blockOwnAccount": Boolean("Stop admins approving withdrawals, holds, corrections and bonuses for their own account"),
+			"secondApproval":  Boolean("Send every balance correction to a different admin, not only those over the threshold"),
+		}, "blockOwnAccount", "secondApproval"),
+	}
+	AdminListTokenWithdrawalRules = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/settings/token-withdrawal-rules",
+		Summary: "List per-token withdrawal minimums and maximums",
+		Tag:     tagAdmin,
+		Admin:   true,
+	}
+	AdminSetTokenWithdrawalRule = Route{
+		Method:  "PUT",
+		Path:    "/v0/admin/settings/token-withdrawal-rules/{chain}/{token}",
+		Summary: "Set the withdrawal minimum and maximum for a token on a chain",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Params: []Param{
+			{Name: "chain", In: "path", Description: "Chain name, e.g. ethereum", Schema: String("")},
+			{Name: "token", In: "path", Description: "Token symbol, e.g. USDC", Schema: String("")},
+		},
+		Body: Object(map[string]*Schema{
+			"minWithdrawal": Number("Minimum credits per withdrawal; 0 uses the platform minimum").NonNegative(),
+			"maxWithdrawal": Number("Maximum credits per withdrawal; 0 uses the platform maximum").NonNegative(),
+			"disabled":      Boolean("Switch off withdrawals of this token on this chain"),
+			"note":          String("Why the rule exists").WithMaxLength(500),
+		}),
+	}
+	AdminReleaseWithdrawal = Route{
+		Method:  "POST",
+		Path:    "/v0/admin/withdrawals/{id}/release",
+		Summary: "Release a withdrawal held by screening",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Params:  []Param{idParam},
+		Body: Object(map[string]*Schema{
+			"note": String("Optional admin note").WithMaxLength(1000),
+		}),
+		BodyOptional: true,
+	}
+)
+
+// Admin chain configuration routes
+var (
+	AdminListChains = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/chains",
+		Summary: "List supported chains, active or not",
+		Tag:     tagAdmin,
+		Admin:   true,
+	}
+	AdminCreateChain = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/chains",
+		Summary:    "Add a supported chain",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Body: Object(chainFields(map[string]*Schema{
+			"name": String("DFNS chain name: ethereum, ethereum-sepolia, tron or tron-nile").WithMinLength(1),
+		}), "name", "minConfirmations"),
+	}
+	AdminUpdateChain = Route{
+		Method:     "PUT",
+		Path:       "/v0/admin/chains/{id}",
+		Summary:    "Replace a supported chain's configuration",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam},
+		Body:       Object(chainFields(map[string]*Schema{}), "minConfirmations"),
+	}
+	AdminActivateChain = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/chains/{id}/activate",
+		Summary:    "Open a chain to deposits and withdrawals",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam},
+	}
+	AdminDeactivateChain = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/chains/{id}/deactivate",
+		Summary:    "Close a chain to deposits and withdrawals",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam},
+	}
+	AdminListChainTokens = Route{
+		Method:  "GET",
+		Path:    "/v0/admin/chains/{id}/tokens",
+		Summary: "List the tokens on a chain, active or not",
+		Tag:     tagAdmin,
+		Admin:   true,
+		Params:  []Param{idParam},
+	}
+	AdminAddChainToken = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/chains/{id}/tokens",
+		Summary:    "Enable a token contract on a chain",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam},
+		Body: Object(chainTokenFields(map[string]*Schema{
+			"symbol": String("Token symbol, 2 to 10 capital letters or digits").WithPattern(`^[A-Za-z0-9]{2,10}$`),
+			"name":   String("Token name, required for a symbol the platform has not seen before").WithMaxLength(100),
+		}), "symbol", "contractAddress", "decimals"),
+	}
+	AdminUpdateChainToken = Route{
+		Method:     "PUT",
+		Path:       "/v0/admin/chains/{id}/tokens/{tokenId}",
+		Summary:    "Replace a token's contract address, decimals and minimum deposit on a chain",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam, tokenIDParam},
+		Body:       Object(chainTokenFields(map[string]*Schema{}), "contractAddress", "decimals"),
+	}
+	AdminActivateChainToken = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/chains/{id}/tokens/{tokenId}/activate",
+		Summary:    "Open a token on a chain to deposits and withdrawals",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam, tokenIDParam},
+	}
+	AdminDeactivateChainToken = Route{
+		Method:     "POST",
+		Path:       "/v0/admin/chains/{id}/tokens/{tokenId}/deactivate",
+		Summary:    "Close a token on a chain to deposits and withdrawals",
+		Tag:        tagAdmin,
+		Admin:      true,
+		Permission: models.PermChainsManage,
+		Params:     []Param{idParam, tokenIDParam},
+	}
+)
+
+// chainFields adds the editable chain fields to properties
+func chainFields(properties map[string]*Schema) map[string]*Schema {
+	properties["displayName"] = String("Defaults to the chain's usual name").WithMaxLength(100)
+	properties["rpcUrl"] = String("http(s) RPC node URL")
+	properties["explorerUrl"] = String("Block explorer base URL, or a template with {kind} and {id}")
+	properties["minConfirmations"] = Integer("Confirmations before a deposit is credited, 1 to 1000").Positive()
+	properties["iconUrl"] = String("http(s) icon URL")
+	properties["graceCrediting"] = Boolean("Credit deposits before minConfirmations, withdrawable once confirmed")
+	return properties
+}
+
+// chainTokenFields adds the editable chain token fields to properties
+func chainTokenFields(properties map[string]*Schema) map[string]*Schema {
+	properties["contractAddress"] = String("Token contract address in the chain's format").WithMinLength(1)
+	properties["decimals"] = Integer("Token decimals, 0 to 36").NonNegative()
+	properties["minDeposit"] = Number("Smallest deposit credited, in credits; smaller ones are recorded DUST. 0 or omitted for no minimum").NonNegative()
+	return properties
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Schema is the subset of OpenAPI 3 / JSON Schema used to describe and
+// validate request bodies. It marshals directly into the OpenAPI document.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// FieldError describes one validation failure
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Object returns an object schema with the given properties and required fields
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// String returns a string schema
+func String(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+// Integer returns an integer schema
+func Integer(description string) *Schema {
+	return &Schema{Type: "integer", Description: description}
+}
+
+// Number returns a number schema
+func Number(description string) *Schema {
+	return &Schema{Type: "number", Description: description}
+}
+
+// Boolean returns a boolean schema
+func Boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+// ArrayOf returns an array schema
+func ArrayOf(items *Schema, description string) *Schema {
+	return &Schema{Type: "array", Items: items, Description: description}
+}
+
+// WithMinLength sets the minimum string length
+func (s *Schema) WithMinLength(n int) *Schema {
+	s.MinLength = &n
+	return s
+}
+
+// WithMaxLength sets the maximum string length
+func (s *Schema) WithMaxLength(n int) *Schema {
+	s.MaxLength = &n
+	return s
+}
+
+// WithPattern sets a regular expression the string must match
+func (s *Schema) WithPattern(pattern string) *Schema {
+	s.Pattern = pattern
+	return s
+}
+
+// WithEnum restricts a string to the given values
+func (s *Schema) WithEnum(values ...string) *Schema {
+	s.Enum = values
+	return s
+}
+
+// Positive requires a number greater than zero
+func (s *Schema) Positive() *Schema {
+	zero := 0.0
+	s.Minimum = &zero
+	s.ExclusiveMinimum = true
+	return s
+}
+
+// NonNegative requires a number of zero or more
+func (s *Schema) NonNegative() *Schema {
+	zero := 0.0
+	s.Minimum = &zero
+	return s
+}
+
+// Validate checks a decoded JSON value (decoded with UseNumber) against the schema
+func (s *Schema) Validate(value interface{}) []FieldError {
+	var errs []FieldError
+	s.validate("", value, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *[]FieldError) {
+	field := path
+	if field == "" {
+		field = "body"
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if v, present := obj[name]; !present || v == nil {
+				*errs = append(*errs, FieldError{Field: join(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, known := s.Properties[name]
+			if !known {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, FieldError{Field: join(path, name), Message: "is not allowed"})
+				}
+				continue
+			}
+			if obj[name] != nil {
+				prop.validate(join(path, name), obj[name], errs)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, errs)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			fail("has an invalid format")
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			fail("must be one of %v", s.Enum)
+		}
+	case "number", "integer":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be a %s", s.Type)
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				fail("must be an integer")
+				return
+			}
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("must be a number")
+			return
+		}
+		if s.Minimum != nil {
+			if s.ExclusiveMinimum && f <= *s.Minimum {
+				fail("must be greater than %v", *s.Minimum)
+			} else if !s.ExclusiveMinimum && f < *s.Minimum {
+				fail("must be at least %v", *s.Minimum)
+			}
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
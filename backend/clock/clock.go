@@ -0,0 +1,28 @@
+// Package clock provides an injectable time source so that time-based rules
+// (withdrawal limits, holds, expirations) can be tested deterministically.
+package clock
+
+import "time"
+
+// Clock reports the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is a Clock backed by the system time.
+type Real struct{}
+
+// Now returns the current system time in UTC.
+func (Real) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// New returns the system Clock.
+func New() Clock {
+	return Real{}
+}
+
+// Since returns the time elapsed on c since t.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
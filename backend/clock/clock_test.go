@@ -0,0 +1,36 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvanceAndSet(t *testing.T) {
+	start := time.Date(2026, 1, 15, 23, 30, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if got := fake.Now(); !got.Equal(start) {
+		t.Fatalf("expected %v, got %v", start, got)
+	}
+
+	fake.Advance(45 * time.Minute)
+	if got := fake.Now(); !got.Equal(start.Add(45 * time.Minute)) {
+		t.Fatalf("expected clock to advance, got %v", got)
+	}
+
+	if elapsed := Since(fake, start); elapsed != 45*time.Minute {
+		t.Fatalf("expected 45m since start, got %v", elapsed)
+	}
+
+	later := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	fake.Set(later)
+	if got := fake.Now(); !got.Equal(later) {
+		t.Fatalf("expected %v after Set, got %v", later, got)
+	}
+}
+
+func TestRealClockIsUTC(t *testing.T) {
+	if loc := New().Now().Location(); loc != time.UTC {
+		t.Fatalf("expected UTC, got %v", loc)
+	}
+}
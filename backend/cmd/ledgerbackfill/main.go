@@ -0,0 +1,59 @@
+// Command ledgerbackfill imports existing balances and completed crypto
+// transactions into the ledger as opening entries, then reports every user
+// whose ledger does not add up to their balance. It is safe to run again
+// after an interruption; users already backfilled are only verified.
+//
+//	go run ./cmd/ledgerbackfill [-dry-run]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"socialpredict/migration"
+	_ "socialpredict/migration/migrations"
+	"socialpredict/models"
+	"socialpredict/seed"
+	"socialpredict/services/ledger"
+	"socialpredict/util"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only verify balances against the ledger; write nothing")
+	flag.Parse()
+
+	if err := util.GetEnv(); err != nil {
+		log.Printf("env: warning loading environment: %v", err)
+	}
+
+	util.InitDB()
+	db := util.GetDB()
+
+	const MAX_ATTEMPTS = 20
+	if err := seed.EnsureDBReady(db, MAX_ATTEMPTS); err != nil {
+		log.Fatalf("database readiness check failed: %v", err)
+	}
+	if err := migration.MigrateDB(db); err != nil {
+		log.Printf("migration: warning: %v", err)
+	}
+
+	report, err := ledger.Backfill(db, *dryRun)
+	if err != nil {
+		log.Printf("ledger backfill stopped after %d users: %v", report.Users, err)
+	}
+
+	for _, d := range report.Discrepancies {
+		log.Printf("discrepancy: user %d (%s) balance %s, ledger %s, difference %s",
+			d.UserID, d.Username, models.FormatMicroCredits(d.Balance),
+			models.FormatMicroCredits(d.LedgerTotal), models.FormatMicroCredits(d.Difference))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	if err != nil || len(report.Discrepancies) > 0 {
+		os.Exit(1)
+	}
+}
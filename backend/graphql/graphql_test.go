@@ -0,0 +1,273 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+type testResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path"`
+	} `json:"errors"`
+}
+
+func post(t *testing.T, db *gorm.DB, token, query string, variables map[string]interface{}) (int, testResponse) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	Handler(db).ServeHTTP(w, req)
+
+	var resp testResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// seedMarket creates a market with three bets, the newest by alice
+func seedMarket(t *testing.T, db *gorm.DB) models.Market {
+	t.Helper()
+	for _, name := range []string{"creator", "alice", "bob"} {
+		user := modelstesting.GenerateUser(name, 1000)
+		db.Create(&user)
+	}
+	market := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&market)
+	bets := []models.Bet{
+		modelstesting.GenerateBet(50, "YES", "bob", uint(market.ID), time.Minute),
+		modelstesting.GenerateBet(20, "NO", "bob", uint(market.ID), 2*time.Minute),
+		modelstesting.GenerateBet(30, "YES", "alice", uint(market.ID), 3*time.Minute),
+	}
+	for i := range bets {
+		db.Create(&bets[i])
+	}
+	db.Create(&models.MarketComment{MarketID: market.ID, UserID: 2, Username: "alice", Body: "Rain looks likely", CreatedAt: time.Now()})
+	return market
+}
+
+const marketPageQuery = `
+query MarketPage($id: ID!, $trades: Int = 2) {
+  market(id: $id) {
+    ...Summary
+    recent: trades(limit: $trades) { username amount }
+    comments { username body }
+    creator { username email }
+    myPosition { yesSharesOwned }
+    positions { username }
+    priceHistory(interval: "1h") { outcome candles { close } }
+  }
+}
+
+fragment Summary on Market {
+  __typename
+  id
+  questionTitle
+  probability
+  numUsers
+}`
+
+func TestMarketPageInOneQuery(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	seedMarket(t, db)
+
+	status, resp := post(t, db, "", marketPageQuery, map[string]interface{}{"id": "1"})
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200; errors %+v", status, resp.Errors)
+	}
+
+	market, ok := resp.Data["market"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("market = %v; errors %+v", resp.Data["market"], resp.Errors)
+	}
+	if market["__typename"] != "Market" || market["id"] != "1" || market["questionTitle"] != "Test Market" {
+		t.Errorf("market = %+v", market)
+	}
+	if market["numUsers"] != float64(2) {
+		t.Errorf("numUsers = %v, want 2", market["numUsers"])
+	}
+	if p, ok := market["probability"].(float64); !ok || p <= 0 || p >= 1 {
+		t.Errorf("probability = %v, want a probability", market["probability"])
+	}
+
+	trades := market["recent"].([]interface{})
+	if len(trades) != 2 || trades[0].(map[string]interface{})["username"] != "alice" {
+		t.Errorf("recent trades = %+v, want the newest two, alice's first", trades)
+	}
+	if comments := market["comments"].([]interface{}); len(comments) != 1 || comments[0].(map[string]interface{})["body"] != "Rain looks likely" {
+		t.Errorf("comments = %+v, want alice's comment", comments)
+	}
+	if positions := market["positions"].([]interface{}); len(positions) != 2 {
+		t.Errorf("positions = %+v, want alice's and bob's", positions)
+	}
+	if history := market["priceHistory"].(map[string]interface{}); history["outcome"] != "YES" {
+		t.Errorf("priceHistory = %+v, want YES candles", history)
+	}
+
+	// Anonymous visitors get null for guarded fields, and an error for each
+	creator := market["creator"].(map[string]interface{})
+	if creator["username"] != "creator" || creator["email"] != nil {
+		t.Errorf("creator = %+v, want a username and no email", creator)
+	}
+	if market["myPosition"] != nil {
+		t.Errorf("myPosition = %v, want null when signed out", market["myPosition"])
+	}
+	paths := map[string]string{}
+	for _, e := range resp.Errors {
+		b, _ := json.Marshal(e.Path)
+		paths[string(b)] = e.Message
+	}
+	if paths[`["market","creator","email"]`] != errSignInRequired.Error() || paths[`["market","myPosition"]`] != errSignInRequired.Error() {
+		t.Errorf("errors = %+v, want sign-in errors for email and myPosition", resp.Errors)
+	}
+}
+
+func TestFieldLevelAuth(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	seedMarket(t, db)
+	token := modelstesting.GenerateValidJWT("alice")
+
+	_, resp := post(t, db, token, `{
+  me { username email activity(first: 5) { items { type } } positions { market { id } yesSharesOwned } }
+  market(id: 1) { myPosition { yesSharesOwned } }
+  user(username: "bob") { username email }
+}`, nil)
+
+	me := resp.Data["me"].(map[string]interface{})
+	if me["username"] != "alice" || !strings.HasPrefix(me["email"].(string), "alice_") {
+		t.Errorf("me = %+v, want alice with her email", me)
+	}
+	if items := me["activity"].(map[string]interface{})["items"].([]interface{}); len(items) == 0 {
+		t.Error("activity is empty, want alice's bet")
+	}
+	if positions := me["positions"].([]interface{}); len(positions) != 1 {
+		t.Errorf("positions = %+v, want one", positions)
+	}
+	if position := resp.Data["market"].(map[string]interface{})["myPosition"]; position == nil {
+		t.Error("myPosition is null, want alice's position")
+	}
+
+	bob := resp.Data["user"].(map[string]interface{})
+	if bob["username"] != "bob" || bob["email"] != nil {
+		t.Errorf("user = %+v, want bob without his email", bob)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != errNotPermitted.Error() {
+		t.Errorf("errors = %+v, want one for bob's email", resp.Errors)
+	}
+}
+
+func TestMarketsStayInTheirCommunity(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	seedMarket(t, db)
+	other := modelstesting.GenerateMarket(2, "creator")
+	other.TenantID = 7
+	db.Create(&other)
+
+	_, resp := post(t, db, "", `{ market(id: 2) { id } markets(status: ALL) { id } }`, nil)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	if resp.Data["market"] != nil {
+		t.Errorf("market = %v, want null for another community's market", resp.Data["market"])
+	}
+	markets := resp.Data["markets"].([]interface{})
+	if len(markets) != 1 || markets[0].(map[string]interface{})["id"] != "1" {
+		t.Errorf("markets = %+v, want only market 1", markets)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{"syntax", "{ market(id: 1) { id }", nil, "Expected Name, found <EOF>"},
+		{"unknown field", "{ market(id: 1) { price } }", nil, `Cannot query field "price" on type "Market"`},
+		{"missing argument", "{ market { id } }", nil, `argument "id" of type "ID!" is required`},
+		{"missing selection", "{ market(id: 1) }", nil, "must have a selection of subfields"},
+		{"mutation", "mutation { market(id: 1) { id } }", nil, `does not support operation type "mutation"`},
+		{"undefined variable", "{ market(id: $id) { id } }", nil, `Variable "$id" is not defined`},
+		{"missing variable", "query($id: ID!) { market(id: $id) { id } }", nil, "must be defined"},
+		{"bad variable", "query($n: Int) { markets(limit: $n) { id } }", map[string]interface{}{"n": "ten"}, "cannot use string as Int"},
+		{"fragment cycle", "{ market(id: 1) { ...A } } fragment A on Market { ...A }", nil, `Cannot spread fragment "A" within itself`},
+		{"complexity", "query($n: Int) { markets(limit: 100) { trades(limit: $n) { username } } }", map[string]interface{}{"n": 200}, "exceeds the limit of 5000"},
+		{"depth", "{ market(id: 1) { creator { positions { market { creator { positions { market { creator { positions { market { id } } } } } } } } } } }", nil, "nested more than 10 levels deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := post(t, db, "", tt.query, tt.variables)
+			if status != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want 422", status)
+			}
+			if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.want) {
+				t.Errorf("errors = %+v, want %q", resp.Errors, tt.want)
+			}
+		})
+	}
+
+	// Introspection is off; the schema is served at SchemaPath instead
+	if _, resp := post(t, db, "", "{ __schema { types { name } } }", nil); len(resp.Errors) != 1 || resp.Errors[0].Message != "introspection disabled" {
+		t.Errorf("introspection errors = %+v, want it disabled", resp.Errors)
+	}
+
+	// Out of range arguments fail only their field
+	status, resp := post(t, db, "", "{ markets(limit: 500) { id } }", nil)
+	if status != http.StatusOK || resp.Data != nil || len(resp.Errors) != 1 {
+		t.Errorf("limit 500 = %d %+v, want 200 with one error and no data", status, resp)
+	}
+}
+
+func TestGetAndSkip(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	seedMarket(t, db)
+
+	params := url.Values{
+		"query":     {`query($brief: Boolean!) { market(id: 1) { id questionTitle @skip(if: $brief) } }`},
+		"variables": {`{"brief": true}`},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/graphql?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	Handler(db).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"data":{"market":{"id":"1"}}}` {
+		t.Errorf("GET = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSchemaSDL(t *testing.T) {
+	w := httptest.NewRecorder()
+	SchemaHandler(w, httptest.NewRequest(http.MethodGet, SchemaPath, nil))
+	sdl := w.Body.String()
+	for _, want := range []string{
+		"schema {\n  query: Query\n}",
+		"  market(id: ID!): Market\n",
+		"  trades(limit: Int = 50): [Trade!]!\n",
+		"enum MarketStatus {\n  ACTIVE\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("schema lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package graphqlapi serves a read-only GraphQL view of markets, positions,
+// price history and wallet activity, so a page can fetch what it needs in
+// one request instead of several REST calls.
+//
+// The schema is written in schema.graphqls and the executor is generated
+// from it by gqlgen; run go generate ./graphql after changing it. The
+// resolvers live in schema.resolvers.go. Queries can use variables,
+// fragments and the @skip and @include directives; there are no mutations
+// or subscriptions, and introspection is off. The schema is served in SDL at
+// /graphql/schema.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"io"
+	"net/http"
+
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/tenants"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"gorm.io/gorm"
+)
+
+// SchemaPath is where the schema is served
+const SchemaPath = "/graphql/schema"
+
+// Limits on what one request can ask for
+const (
+	maxBodySize       = 64 << 10 // A POSTed request, query and variables together
+	maxDepth          = 10
+	maxComplexity     = 5000
+	unboundedListSize = 20 // Items assumed for a list without a limit when costing a query
+)
+
+//go:embed schema.graphqls
+var schemaSDL string
+
+// An operation over the complexity limit is rejected before it runs, like
+// one that fails validation, so it gets the same status
+func init() {
+	errcode.RegisterErrorType("COMPLEXITY_LIMIT_EXCEEDED", errcode.KindProtocol)
+}
+
+// Handler serves GET and POST /graphql. Anonymous visitors can read public
+// data; a bearer token adds the viewer's own fields. Impersonation tokens
+// work only with GET, as on the REST API.
+func Handler(db *gorm.DB) http.HandlerFunc {
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  &Resolver{db: db},
+		Complexity: complexity(),
+	}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(depthLimit(maxDepth))
+	srv.Use(extension.FixedComplexityLimit(maxComplexity))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+		var viewer *models.User
+		if r.Header.Get("Authorization") != "" {
+			user, httperr := middleware.ValidateTokenAndGetUser(r, db)
+			if httperr != nil {
+				w.Header().Set("Content-Type", "application/json")
+				transport.SendErrorf(w, httperr.StatusCode, "%s", httperr.Message)
+				return
+			}
+			viewer = user
+		}
+
+		ex := newExecution(db, tenants.FromRequest(r), viewer)
+		srv.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), executionKey{}, ex)))
+	}
+}
+
+// SchemaHandler serves the schema in the GraphQL schema definition language
+func SchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, schemaSDL)
+}
+
+// complexity costs what is selected under a list once per item: its limit or
+// first argument where it has one, otherwise unboundedListSize. Every other
+// field costs one.
+func complexity() ComplexityRoot {
+	var c ComplexityRoot
+	c.Query.Markets = func(child int, _ *MarketStatus, _ *string, limit *int) int { return listCost(child, limit) }
+	c.Market.Trades = func(child int, limit *int) int { return listCost(child, limit) }
+	c.Market.Comments = func(child int, limit *int) int { return listCost(child, limit) }
+	c.User.Activity = func(child int, first *int, _ *string) int { return listCost(child, first) }
+
+	unbounded := func(child int) int { return listCost(child, nil) }
+	c.Market.ProbabilityChanges = unbounded
+	c.Market.Positions = unbounded
+	c.User.Positions = unbounded
+	c.PriceHistory.Candles = unbounded
+	c.ActivityPage.Items = unbounded
+	return c
+}
+
+func listCost(child int, limit *int) int {
+	n := unboundedListSize
+	if limit != nil && *limit > 0 {
+		n = *limit
+	}
+	return 1 + child*n
+}
+
+// depthLimit rejects operations whose fields nest deeper than its value
+type depthLimit int
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit(0)
+
+func (depthLimit) ExtensionName() string { return "DepthLimit" }
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (d depthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil || depth(op.SelectionSet) <= int(d) {
+		return nil
+	}
+	err := gqlerror.Errorf("query is nested more than %d levels deep", int(d))
+	errcode.Set(err, errcode.ValidationFailed)
+	return err
+}
+
+// depth counts the levels of fields in a selection set, through fragments
+func depth(set ast.SelectionSet) int {
+	deepest := 0
+	for _, sel := range set {
+		var d int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d = 1 + depth(sel.SelectionSet)
+		case *ast.InlineFragment:
+			d = depth(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				d = depth(sel.Definition.SelectionSet)
+			}
+		}
+		deepest = max(deepest, d)
+	}
+	return deepest
+}
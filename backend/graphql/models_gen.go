@@ -0,0 +1,73 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphqlapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type Query struct {
+}
+
+// Which markets a list includes
+type MarketStatus string
+
+const (
+	MarketStatusActive   MarketStatus = "ACTIVE"
+	MarketStatusClosed   MarketStatus = "CLOSED"
+	MarketStatusResolved MarketStatus = "RESOLVED"
+	MarketStatusAll      MarketStatus = "ALL"
+)
+
+var AllMarketStatus = []MarketStatus{
+	MarketStatusActive,
+	MarketStatusClosed,
+	MarketStatusResolved,
+	MarketStatusAll,
+}
+
+func (e MarketStatus) IsValid() bool {
+	switch e {
+	case MarketStatusActive, MarketStatusClosed, MarketStatusResolved, MarketStatusAll:
+		return true
+	}
+	return false
+}
+
+func (e MarketStatus) String() string {
+	return string(e)
+}
+
+func (e *MarketStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MarketStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MarketStatus", str)
+	}
+	return nil
+}
+
+func (e MarketStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MarketStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MarketStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
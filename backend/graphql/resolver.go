@@ -0,0 +1,199 @@
+package graphqlapi
+
+//go:generate go tool gqlgen generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	marketmath "socialpredict/handlers/math/market"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+	"socialpredict/services/groups"
+
+	"gorm.io/gorm"
+)
+
+// Limits on list arguments
+const (
+	maxMarkets  = 100
+	maxTrades   = 200
+	maxComments = 200
+	maxActivity = 50
+)
+
+// Errors returned for fields the viewer may not read
+var (
+	errSignInRequired = errors.New("sign in to read this field")
+	errNotPermitted   = errors.New("only the user themselves or an admin can read this field")
+)
+
+// Resolver resolves the schema's fields. What each request loads is kept in
+// its execution, which Handler puts in the request's context.
+type Resolver struct {
+	db *gorm.DB
+}
+
+// execution is the state of one request: who is asking, in which community,
+// and what has been loaded so far. Fields resolve concurrently, so the caches
+// are guarded by mu.
+type execution struct {
+	db     *gorm.DB
+	tenant *models.Tenant
+	viewer *models.User // nil for anonymous visitors
+
+	mu        sync.Mutex
+	markets   map[int64]*models.Market // nil entries are markets the viewer cannot see
+	users     map[string]*models.User
+	bets      map[int64][]models.Bet
+	positions map[int64][]positionsmath.MarketPosition
+}
+
+type executionKey struct{}
+
+func newExecution(db *gorm.DB, tenant *models.Tenant, viewer *models.User) *execution {
+	return &execution{
+		db:        db,
+		tenant:    tenant,
+		viewer:    viewer,
+		markets:   make(map[int64]*models.Market),
+		users:     make(map[string]*models.User),
+		bets:      make(map[int64][]models.Bet),
+		positions: make(map[int64][]positionsmath.MarketPosition),
+	}
+}
+
+func executionFrom(ctx context.Context) *execution {
+	return ctx.Value(executionKey{}).(*execution)
+}
+
+// market returns a market the viewer can see in this community, or nil
+func (ex *execution) market(id int64) (*models.Market, error) {
+	ex.mu.Lock()
+	market, ok := ex.markets[id]
+	ex.mu.Unlock()
+	if ok {
+		return market, nil
+	}
+	var found models.Market
+	if err := ex.db.Scopes(models.InTenant(ex.tenant.ID)).First(&found, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ex.mu.Lock()
+			ex.markets[id] = nil
+			ex.mu.Unlock()
+			return nil, nil
+		}
+		log.Printf("graphql: loading market %d: %v", id, err)
+		return nil, errors.New("failed to load market")
+	}
+	return ex.remember(&found)
+}
+
+// remember caches a market the query found, if the viewer can see it
+func (ex *execution) remember(market *models.Market) (*models.Market, error) {
+	ok, err := groups.CanView(ex.db, market, ex.viewer)
+	if err != nil {
+		log.Printf("graphql: checking access to market %d: %v", market.ID, err)
+		return nil, errors.New("failed to load market")
+	}
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if !ok {
+		ex.markets[market.ID] = nil
+		return nil, nil
+	}
+	ex.markets[market.ID] = market
+	return market, nil
+}
+
+func (ex *execution) user(username string) (*models.User, error) {
+	ex.mu.Lock()
+	user, ok := ex.users[username]
+	ex.mu.Unlock()
+	if ok {
+		return user, nil
+	}
+	var found models.User
+	if err := ex.db.Where("username = ?", username).First(&found).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ex.mu.Lock()
+			ex.users[username] = nil
+			ex.mu.Unlock()
+			return nil, nil
+		}
+		log.Printf("graphql: loading user %s: %v", username, err)
+		return nil, errors.New("failed to load user")
+	}
+	ex.mu.Lock()
+	ex.users[username] = &found
+	ex.mu.Unlock()
+	return &found, nil
+}
+
+func (ex *execution) marketBets(market *models.Market) []models.Bet {
+	ex.mu.Lock()
+	bets, ok := ex.bets[market.ID]
+	ex.mu.Unlock()
+	if ok {
+		return bets
+	}
+	bets = tradingdata.GetBetsForMarket(ex.db, uint(market.ID))
+	ex.mu.Lock()
+	ex.bets[market.ID] = bets
+	ex.mu.Unlock()
+	return bets
+}
+
+func (ex *execution) marketPositions(market *models.Market) ([]positionsmath.MarketPosition, error) {
+	ex.mu.Lock()
+	positions, ok := ex.positions[market.ID]
+	ex.mu.Unlock()
+	if ok {
+		return positions, nil
+	}
+	positions, err := positionsmath.CalculateMarketPositions(ex.db, market)
+	if err != nil {
+		log.Printf("graphql: positions for market %d: %v", market.ID, err)
+		return nil, errors.New("failed to load positions")
+	}
+	ex.mu.Lock()
+	ex.positions[market.ID] = positions
+	ex.mu.Unlock()
+	return positions, nil
+}
+
+func (ex *execution) probabilities(market *models.Market) []wpam.ProbabilityChange {
+	return marketmath.ForMarket(market).Probabilities(market.CreatedAt, ex.marketBets(market), tradingdata.GetLiquidityForMarket(ex.db, market.ID)...)
+}
+
+// signedIn lets any signed-in viewer read the field
+func (ex *execution) signedIn() error {
+	if ex.viewer == nil {
+		return errSignInRequired
+	}
+	return nil
+}
+
+// selfOrAdmin lets a user read their own field, and admins read anyone's
+func (ex *execution) selfOrAdmin(user *models.User) error {
+	if ex.viewer == nil {
+		return errSignInRequired
+	}
+	if ex.viewer.ID != user.ID && ex.viewer.UserType != "ADMIN" {
+		return errNotPermitted
+	}
+	return nil
+}
+
+// limitArg reads a list limit, which must be between 1 and max
+func limitArg(n *int, name string, max int) (int, error) {
+	if n == nil || *n < 1 || *n > max {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, max)
+	}
+	return *n, nil
+}
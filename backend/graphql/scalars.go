@@ -0,0 +1,44 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalTime writes a time in RFC 3339, in UTC
+func MarshalTime(t time.Time) graphql.Marshaler {
+	return graphql.MarshalTime(t.UTC())
+}
+
+// UnmarshalTime reads an RFC 3339 time
+func UnmarshalTime(v interface{}) (time.Time, error) {
+	return graphql.UnmarshalTime(v)
+}
+
+// MarshalInt64ID writes an int64 key, such as a market's, as an ID
+func MarshalInt64ID(id int64) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(strconv.FormatInt(id, 10)))
+	})
+}
+
+// UnmarshalInt64ID reads an ID given as a string or an integer
+func UnmarshalInt64ID(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case json.Number:
+		return v.Int64()
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("%T is not an ID", v)
+	}
+}
@@ -0,0 +1,290 @@
+package graphqlapi
+
+// This file will be automatically regenerated based on the schema, any resolver
+// implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.87
+
+import (
+	"context"
+	"errors"
+	"log"
+	"socialpredict/clock"
+	betshandlers "socialpredict/handlers/bets"
+	marketshandlers "socialpredict/handlers/markets"
+	marketmath "socialpredict/handlers/math/market"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/models"
+	"socialpredict/services/activity"
+	"socialpredict/services/comments"
+	"socialpredict/services/pricehistory"
+	"socialpredict/services/stream"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NextCursor is the resolver for the nextCursor field.
+func (r *activityPageResolver) NextCursor(ctx context.Context, obj *activity.Page) (*string, error) {
+	if obj.NextCursor == "" {
+		return nil, nil
+	}
+	return &obj.NextCursor, nil
+}
+
+// Creator is the resolver for the creator field.
+func (r *marketResolver) Creator(ctx context.Context, obj *models.Market) (*models.User, error) {
+	return executionFrom(ctx).user(obj.CreatorUsername)
+}
+
+// Probability is the resolver for the probability field.
+func (r *marketResolver) Probability(ctx context.Context, obj *models.Market) (float64, error) {
+	changes := executionFrom(ctx).probabilities(obj)
+	return changes[len(changes)-1].Probability, nil
+}
+
+// ProbabilityChanges is the resolver for the probabilityChanges field.
+func (r *marketResolver) ProbabilityChanges(ctx context.Context, obj *models.Market) ([]wpam.ProbabilityChange, error) {
+	return executionFrom(ctx).probabilities(obj), nil
+}
+
+// NumUsers is the resolver for the numUsers field.
+func (r *marketResolver) NumUsers(ctx context.Context, obj *models.Market) (int, error) {
+	return models.GetNumMarketUsers(executionFrom(ctx).marketBets(obj)), nil
+}
+
+// TotalVolume is the resolver for the totalVolume field.
+func (r *marketResolver) TotalVolume(ctx context.Context, obj *models.Market) (int, error) {
+	return int(marketmath.GetMarketVolume(executionFrom(ctx).marketBets(obj))), nil
+}
+
+// Trades is the resolver for the trades field.
+func (r *marketResolver) Trades(ctx context.Context, obj *models.Market, limit *int) ([]betshandlers.BetDisplayInfo, error) {
+	n, err := limitArg(limit, "limit", maxTrades)
+	if err != nil {
+		return nil, err
+	}
+	trades := betshandlers.MarketBetsForDisplay(r.db, obj)
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].PlacedAt.After(trades[j].PlacedAt) })
+	if len(trades) > n {
+		trades = trades[:n]
+	}
+	return trades, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *marketResolver) Comments(ctx context.Context, obj *models.Market, limit *int) ([]models.MarketComment, error) {
+	n, err := limitArg(limit, "limit", maxComments)
+	if err != nil {
+		return nil, err
+	}
+	return comments.NewService(r.db, clock.New(), stream.Default).List(executionFrom(ctx).viewer, obj.ID, n)
+}
+
+// Positions is the resolver for the positions field.
+func (r *marketResolver) Positions(ctx context.Context, obj *models.Market) ([]positionsmath.MarketPosition, error) {
+	return executionFrom(ctx).marketPositions(obj)
+}
+
+// MyPosition is the resolver for the myPosition field.
+func (r *marketResolver) MyPosition(ctx context.Context, obj *models.Market) (*positionsmath.MarketPosition, error) {
+	ex := executionFrom(ctx)
+	if err := ex.signedIn(); err != nil {
+		return nil, err
+	}
+	positions, err := ex.marketPositions(obj)
+	if err != nil {
+		return nil, err
+	}
+	for i := range positions {
+		if positions[i].Username == ex.viewer.Username {
+			return &positions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// PriceHistory is the resolver for the priceHistory field.
+func (r *marketResolver) PriceHistory(ctx context.Context, obj *models.Market, outcome *string, interval *string, from *time.Time, to *time.Time) (*pricehistory.History, error) {
+	if interval == nil {
+		return nil, pricehistory.ErrInvalidInterval
+	}
+	step, err := marketshandlers.ParseCandleInterval(*interval)
+	if err != nil {
+		return nil, err
+	}
+	var wanted string
+	if outcome != nil {
+		wanted = strings.TrimSpace(*outcome)
+	}
+	var start time.Time
+	if from != nil {
+		start = *from
+	}
+	end := clock.New().Now()
+	if to != nil {
+		end = *to
+	}
+
+	history, err := pricehistory.Candles(r.db, obj.ID, wanted, step, start, end)
+	if err != nil {
+		switch {
+		case errors.Is(err, pricehistory.ErrInvalidOutcome),
+			errors.Is(err, pricehistory.ErrInvalidInterval),
+			errors.Is(err, pricehistory.ErrInvalidRange),
+			errors.Is(err, pricehistory.ErrTooManyCandles):
+			return nil, err
+		}
+		log.Printf("graphql: price history for market %d: %v", obj.ID, err)
+		return nil, errors.New("failed to load price history")
+	}
+	return &history, nil
+}
+
+// Market is the resolver for the market field.
+func (r *positionResolver) Market(ctx context.Context, obj *positionsmath.MarketPosition) (*models.Market, error) {
+	return executionFrom(ctx).market(int64(obj.MarketID))
+}
+
+// Market is the resolver for the market field.
+func (r *queryResolver) Market(ctx context.Context, id string) (*models.Market, error) {
+	marketID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid market ID")
+	}
+	return executionFrom(ctx).market(marketID)
+}
+
+// Markets is the resolver for the markets field.
+func (r *queryResolver) Markets(ctx context.Context, status *MarketStatus, search *string, limit *int) ([]models.Market, error) {
+	n, err := limitArg(limit, "limit", maxMarkets)
+	if err != nil {
+		return nil, err
+	}
+	filter := strings.ToLower(string(MarketStatusActive))
+	if status != nil {
+		filter = strings.ToLower(string(*status))
+	}
+
+	ex := executionFrom(ctx)
+	var found []models.Market
+	if search != nil && strings.TrimSpace(*search) != "" {
+		found, err = marketshandlers.FindMarkets(r.db, strings.TrimSpace(*search), filter, n, ex.tenant.ID)
+	} else {
+		statusFilter, _ := marketshandlers.StatusFilter(filter)
+		found, err = marketshandlers.ListMarketsByStatus(r.db, statusFilter, ex.tenant.ID)
+	}
+	if err != nil {
+		return nil, errors.New("failed to load markets")
+	}
+	if len(found) > n {
+		found = found[:n]
+	}
+
+	markets := make([]models.Market, 0, len(found))
+	for i := range found {
+		market, err := ex.remember(&found[i])
+		if err != nil {
+			return nil, err
+		}
+		if market != nil {
+			markets = append(markets, *market)
+		}
+	}
+	return markets, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
+	ex := executionFrom(ctx)
+	if err := ex.signedIn(); err != nil {
+		return nil, err
+	}
+	return ex.viewer, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, username string) (*models.User, error) {
+	return executionFrom(ctx).user(username)
+}
+
+// AccountBalance is the resolver for the accountBalance field.
+func (r *userResolver) AccountBalance(ctx context.Context, obj *models.User) (int, error) {
+	return int(obj.WholeCredits()), nil
+}
+
+// Email is the resolver for the email field.
+func (r *userResolver) Email(ctx context.Context, obj *models.User) (*string, error) {
+	if err := executionFrom(ctx).selfOrAdmin(obj); err != nil {
+		return nil, err
+	}
+	return &obj.Email, nil
+}
+
+// Positions is the resolver for the positions field.
+func (r *userResolver) Positions(ctx context.Context, obj *models.User) ([]positionsmath.MarketPosition, error) {
+	all, err := positionsmath.CalculateAllUserMarketPositions_WPAM_DBPM(r.db, obj.Username)
+	if err != nil {
+		log.Printf("graphql: positions for %s: %v", obj.Username, err)
+		return nil, errors.New("failed to load positions")
+	}
+	ex := executionFrom(ctx)
+	positions := []positionsmath.MarketPosition{}
+	for i := range all {
+		market, err := ex.market(int64(all[i].MarketID))
+		if err != nil {
+			return nil, err
+		}
+		if market != nil {
+			positions = append(positions, all[i])
+		}
+	}
+	return positions, nil
+}
+
+// Activity is the resolver for the activity field.
+func (r *userResolver) Activity(ctx context.Context, obj *models.User, first *int, after *string) (*activity.Page, error) {
+	n, err := limitArg(first, "first", maxActivity)
+	if err != nil {
+		return nil, err
+	}
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+
+	ex := executionFrom(ctx)
+	includePrivate := ex.viewer != nil && (ex.viewer.ID == obj.ID || ex.viewer.UserType == "ADMIN")
+	page, err := activity.ProfileFeed(r.db, obj, includePrivate, cursor, n)
+	if err != nil {
+		if errors.Is(err, activity.ErrInvalidCursor) {
+			return nil, errors.New("invalid cursor")
+		}
+		log.Printf("graphql: activity for %s: %v", obj.Username, err)
+		return nil, errors.New("failed to load activity")
+	}
+	return page, nil
+}
+
+// ActivityPage returns ActivityPageResolver implementation.
+func (r *Resolver) ActivityPage() ActivityPageResolver { return &activityPageResolver{r} }
+
+// Market returns MarketResolver implementation.
+func (r *Resolver) Market() MarketResolver { return &marketResolver{r} }
+
+// Position returns PositionResolver implementation.
+func (r *Resolver) Position() PositionResolver { return &positionResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type activityPageResolver struct{ *Resolver }
+type marketResolver struct{ *Resolver }
+type positionResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
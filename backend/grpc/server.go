@@ -0,0 +1,244 @@
+// Package grpcapi serves the internal wallet API over gRPC so internal
+// services can credit users, read balances and submit withdrawals without
+// going through the HTTP handlers and cookie auth.
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"socialpredict/clock"
+	"socialpredict/grpc/walletpb"
+	wallethandlers "socialpredict/handlers/wallet"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+	"socialpredict/services/limits"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/screening"
+	"socialpredict/services/settings"
+	"socialpredict/services/travelrule"
+	"socialpredict/services/twofactor"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// auditActor identifies gRPC callers in the audit log
+const auditActor = "grpc"
+
+// Server implements walletpb.WalletServiceServer
+type Server struct {
+	walletpb.UnimplementedWalletServiceServer
+	db           *gorm.DB
+	screener     screening.Screener
+	secondFactor *twofactor.Service
+	travelRule   *travelrule.Policy
+	clock        clock.Clock
+}
+
+// NewServer creates the wallet gRPC service. Callers cannot give a second
+// factor or a beneficiary attestation, so withdrawals that need either are
+// refused; a nil secondFactor or travelRule requires neither.
+func NewServer(db *gorm.DB, screener screening.Screener, secondFactor *twofactor.Service, travelRule *travelrule.Policy, c clock.Clock) *Server {
+	return &Server{db: db, screener: screener, secondFactor: secondFactor, travelRule: travelRule, clock: c}
+}
+
+// maxReferenceLength matches the size of the ledger's external reference column
+const maxReferenceLength = 255
+
+// CreditUser adds credits to a user's balance and records a ledger entry.
+// A credit repeating an earlier reference returns the original entry
+// instead of crediting the user again.
+func (s *Server) CreditUser(ctx context.Context, req *walletpb.CreditUserRequest) (*walletpb.CreditUserResponse, error) {
+	if req.GetAmountMicro() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount_micro must be positive")
+	}
+	if strings.TrimSpace(req.GetReason()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+	reference := strings.TrimSpace(req.GetReference())
+	if len(reference) > maxReferenceLength {
+		return nil, status.Errorf(codes.InvalidArgument, "reference must be at most %d characters", maxReferenceLength)
+	}
+
+	if resp, err := s.replayCredit(req, reference); err != nil || resp != nil {
+		return resp, err
+	}
+
+	var resp walletpb.CreditUserResponse
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, req.GetUsername())
+		if err != nil {
+			return err
+		}
+		description := req.GetReason()
+		if reference != "" {
+			description = fmt.Sprintf("%s (ref %s)", description, reference)
+		}
+		entry, err := ledger.Apply(tx, user, ledger.Posting{
+			Type:              models.LedgerTypeInternalCredit,
+			Amount:            req.GetAmountMicro(),
+			ReferenceType:     auditActor,
+			Description:       description,
+			ExternalReference: reference,
+		})
+		if err != nil {
+			return err
+		}
+		resp.LedgerEntryId = uint64(entry.ID)
+		resp.BalanceMicro = entry.BalanceAfter
+		return audit.Record(tx, models.AuditLog{
+			Actor:      auditActor,
+			Action:     "INTERNAL_CREDIT",
+			TargetType: "ledger_entry",
+			TargetID:   entry.ID,
+			Details: fmt.Sprintf("user=%s amount=%s reason=%q reference=%q",
+				user.Username, models.FormatMicroCredits(entry.Amount), req.GetReason(), reference),
+		})
+	})
+	if err != nil {
+		// A concurrent credit with the same reference may have won the race
+		if replayed, replayErr := s.replayCredit(req, reference); replayErr != nil || replayed != nil {
+			return replayed, replayErr
+		}
+		return nil, toStatus(err)
+	}
+	return &resp, nil
+}
+
+// replayCredit returns the response for an earlier credit made with
+// reference, or nil if the reference has not been used. A reference reused
+// for a different user or amount is refused.
+func (s *Server) replayCredit(req *walletpb.CreditUserRequest, reference string) (*walletpb.CreditUserResponse, error) {
+	if reference == "" {
+		return nil, nil
+	}
+	var entry models.LedgerEntry
+	err := s.db.Where("external_reference = ?", reference).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	user, err := findUser(s.db, req.GetUsername())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if entry.UserID != user.ID || entry.Amount != req.GetAmountMicro() {
+		return nil, status.Error(codes.AlreadyExists, "reference was already used for a different credit")
+	}
+	return &walletpb.CreditUserResponse{LedgerEntryId: uint64(entry.ID), BalanceMicro: entry.BalanceAfter}, nil
+}
+
+// GetBalance returns a user's current balance
+func (s *Server) GetBalance(ctx context.Context, req *walletpb.GetBalanceRequest) (*walletpb.GetBalanceResponse, error) {
+	user, err := findUser(s.db, req.GetUsername())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	provisionalWhole, _ := models.MicroToWholeCredits(user.ProvisionalBalance)
+	return &walletpb.GetBalanceResponse{
+		BalanceMicro:       user.BalanceMicroCredits(),
+		ProvisionalCredits: provisionalWhole,
+		ProvisionalMicro:   user.ProvisionalBalance,
+	}, nil
+}
+
+// SubmitWithdrawal debits the user and queues a withdrawal for admin review.
+// Withdrawals that need a second factor must be made through the app.
+func (s *Server) SubmitWithdrawal(ctx context.Context, req *walletpb.SubmitWithdrawalRequest) (*walletpb.SubmitWithdrawalResponse, error) {
+	user, err := findUser(s.db, req.GetUsername())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if s.secondFactor.RequiredForWithdrawal(req.GetAmountMicro()) {
+		return nil, status.Error(codes.FailedPrecondition, "withdrawals of this size need a second factor and must be made in the app")
+	}
+
+	// Internal callers hold the API token; email confirmation and device holds
+	// guard browser sessions. Without an attestation, withdrawals at or above
+	// the travel-rule threshold are refused.
+	withdrawalReq, err := wallethandlers.InitiateWithdrawalCore(ctx, s.db, s.clock, s.screener, user,
+		req.GetChainName(), req.GetTokenSymbol(), req.GetToAddress(), req.GetAmountMicro(),
+		wallethandlers.WithdrawalOptions{TravelRule: s.travelRule})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &walletpb.SubmitWithdrawalResponse{
+		RequestId: uint64(withdrawalReq.ID),
+		Status:    withdrawalReq.Status,
+		RiskScore: int32(withdrawalReq.RiskScore),
+	}, nil
+}
+
+func findUser(db *gorm.DB, username string) (*models.User, error) {
+	if username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// toStatus maps service errors onto gRPC status codes
+func toStatus(err error) error {
+	var inputErr *wallethandlers.WithdrawalInputError
+	var limitErr *limits.LimitError
+	var restrictedErr *restrictions.RestrictedError
+	switch {
+	case status.Code(err) != codes.Unknown:
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.As(err, &inputErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &limitErr), errors.As(err, &restrictedErr), errors.Is(err, settings.ErrWithdrawalsFrozen):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		log.Printf("gRPC: internal error: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// TokenAuthInterceptor rejects calls that do not carry "authorization: Bearer <token>"
+func TokenAuthInterceptor(token string) grpc.UnaryServerInterceptor {
+	expected := []byte("Bearer " + token)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), expected) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ListenAndServe serves the wallet API on addr, authenticating callers with token
+func ListenAndServe(addr, token string, srv *Server) error {
+	if token == "" {
+		return errors.New("GRPC_API_TOKEN must be set to serve the gRPC API")
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(TokenAuthInterceptor(token)))
+	walletpb.RegisterWalletServiceServer(grpcServer, srv)
+
+	log.Printf("gRPC wallet API listening on %s", addr)
+	return grpcServer.Serve(lis)
+}
@@ -0,0 +1,166 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/grpc/walletpb"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/screening"
+	"socialpredict/services/travelrule"
+	"socialpredict/services/twofactor"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, srv *Server) walletpb.WalletServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(TokenAuthInterceptor("secret")))
+	walletpb.RegisterWalletServiceServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return walletpb.NewWalletServiceClient(conn)
+}
+
+func authed() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+}
+
+func TestCreditUserAndGetBalance(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("grpcuser", 100)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	client := newTestClient(t, NewServer(db, screening.NewStaticList(nil), nil, nil, clock.New()))
+
+	resp, err := client.CreditUser(authed(), &walletpb.CreditUserRequest{
+		Username: "grpcuser", AmountMicro: 1_500_000, Reason: "promo", Reference: "campaign-7",
+	})
+	if err != nil {
+		t.Fatalf("CreditUser: %v", err)
+	}
+	if resp.GetBalanceMicro() != models.CreditsToMicro(100)+1_500_000 {
+		t.Fatalf("unexpected balance %d", resp.GetBalanceMicro())
+	}
+
+	balance, err := client.GetBalance(authed(), &walletpb.GetBalanceRequest{Username: "grpcuser"})
+	if err != nil || balance.GetBalanceMicro() != resp.GetBalanceMicro() {
+		t.Fatalf("GetBalance = %v, %v", balance, err)
+	}
+
+	// The provisional allowance is reported in micro-credits
+	db.Model(&user).Update("provisional_balance", 2_750_000)
+	balance, err = client.GetBalance(authed(), &walletpb.GetBalanceRequest{Username: "grpcuser"})
+	if err != nil || balance.GetProvisionalMicro() != 2_750_000 || balance.GetProvisionalCredits() != 2 {
+		t.Fatalf("GetBalance provisional = %v, %v", balance, err)
+	}
+
+	if _, err := client.GetBalance(authed(), &walletpb.GetBalanceRequest{Username: "nobody"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestCreditUserReplaysReference(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("grpcuser", 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	client := newTestClient(t, NewServer(db, screening.NewStaticList(nil), nil, nil, clock.New()))
+
+	credit := &walletpb.CreditUserRequest{Username: "grpcuser", AmountMicro: 2_000_000, Reason: "promo", Reference: "payout-42"}
+	first, err := client.CreditUser(authed(), credit)
+	if err != nil {
+		t.Fatalf("CreditUser: %v", err)
+	}
+	again, err := client.CreditUser(authed(), credit)
+	if err != nil {
+		t.Fatalf("replayed CreditUser: %v", err)
+	}
+	if again.GetLedgerEntryId() != first.GetLedgerEntryId() || again.GetBalanceMicro() != 2_000_000 {
+		t.Fatalf("replay = %v, want the original entry %v", again, first)
+	}
+	db.First(&user, user.ID)
+	if user.AccountBalance != 2_000_000 {
+		t.Fatalf("balance = %d, want the credit applied once", user.AccountBalance)
+	}
+
+	credit.AmountMicro = 3_000_000
+	if _, err := client.CreditUser(authed(), credit); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists for a reused reference, got %v", err)
+	}
+}
+
+func TestRejectsMissingTokenAndInvalidInput(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("grpcuser", 100)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	client := newTestClient(t, NewServer(db, screening.NewStaticList(nil), nil, nil, clock.New()))
+
+	if _, err := client.GetBalance(context.Background(), &walletpb.GetBalanceRequest{Username: "grpcuser"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if _, err := client.CreditUser(authed(), &walletpb.CreditUserRequest{Username: "grpcuser", AmountMicro: 1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for missing reason, got %v", err)
+	}
+	if _, err := client.SubmitWithdrawal(authed(), &walletpb.SubmitWithdrawalRequest{
+		Username: "grpcuser", ChainName: "base", TokenSymbol: "USDC", AmountMicro: models.CreditsToMicro(500),
+		ToAddress: "0x1111111111111111111111111111111111111111",
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for insufficient balance, got %v", err)
+	}
+}
+
+func TestWithdrawalsNeedingAttestationOrSecondFactorAreRefused(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("grpcuser", 5000)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	secondFactor := twofactor.NewService(db, nil, twofactor.Config{WithdrawalThresholdMicro: models.CreditsToMicro(1000)}, clock.New())
+	travelRule := travelrule.NewPolicy(travelrule.Config{ThresholdMicro: models.CreditsToMicro(100)})
+	client := newTestClient(t, NewServer(db, screening.NewStaticList(nil), secondFactor, travelRule, clock.New()))
+
+	submit := func(credits int64) error {
+		_, err := client.SubmitWithdrawal(authed(), &walletpb.SubmitWithdrawalRequest{
+			Username: "grpcuser", ChainName: "ethereum", TokenSymbol: "USDC", AmountMicro: models.CreditsToMicro(credits),
+			ToAddress: "0x1111111111111111111111111111111111111111",
+		})
+		return err
+	}
+	if err := submit(2000); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition above the 2FA threshold, got %v", err)
+	}
+	if err := submit(500); status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != travelrule.ErrRequired.Error() {
+		t.Fatalf("expected InvalidArgument for a missing attestation, got %v", err)
+	}
+	if err := submit(50); err != nil {
+		t.Fatalf("SubmitWithdrawal below both thresholds: %v", err)
+	}
+
+	var stored models.User
+	db.First(&stored, user.ID)
+	if stored.BalanceMicroCredits() != models.CreditsToMicro(4950) {
+		t.Fatalf("balance = %d, want only the small withdrawal debited", stored.BalanceMicroCredits())
+	}
+}
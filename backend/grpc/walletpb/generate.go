@@ -0,0 +1,5 @@
+// Package walletpb contains the generated protobuf and gRPC code for the
+// internal wallet API. Regenerate with go generate after editing wallet.proto.
+package walletpb
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative grpc/walletpb/wallet.proto
@@ -0,0 +1,136 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/addresslabels"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AddressLabelRequest represents the request body for labeling an address
+type AddressLabelRequest struct {
+	ChainName string `json:"chainName"` // Omit to label the address on every chain
+	Address   string `json:"address"`
+	Kind      string `json:"kind"` // EXCHANGE, MIXER, CONTRACT or USER
+	Name      string `json:"name"`
+}
+
+// ListAddressLabelsHandler returns address labels, newest first; ?kind=
+// keeps one kind
+func ListAddressLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if _, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsView); httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	labels, err := addresslabels.List(db, r.URL.Query().Get("kind"))
+	if err != nil {
+		http.Error(w, "Failed to load address labels", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"labels": labels})
+}
+
+// SetAddressLabelHandler labels an address, replacing any label it had on
+// the same chain. The change is audited.
+func SetAddressLabelHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsApprove)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req AddressLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	label, err := addresslabels.Set(db, addresslabels.Input{
+		ChainName: req.ChainName,
+		Address:   req.Address,
+		Kind:      req.Kind,
+		Name:      req.Name,
+	}, models.AddressLabelManual, admin.Username)
+	if errors.Is(err, addresslabels.ErrInvalidLabel) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Admin: Address label change failed: %v", err)
+		http.Error(w, "Failed to label address", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Address %s labeled %s by %s", label.Address, label.Kind, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(label)
+}
+
+// DeleteAddressLabelHandler removes an address label. The change is audited.
+func DeleteAddressLabelHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsApprove)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid label ID", http.StatusBadRequest)
+		return
+	}
+
+	err := addresslabels.Delete(db, uint(id), admin.Username)
+	if errors.Is(err, addresslabels.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Admin: Address label deletion failed: %v", err)
+		http.Error(w, "Failed to delete address label", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Address label %d deleted by %s", id, admin.Username)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunAddressLabelerHandler runs the exchange heuristic and labeling API
+// lookups now instead of waiting for the background labeler
+func RunAddressLabelerHandler(svc *addresslabels.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsApprove)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		result, err := svc.Label(r.Context())
+		if err != nil {
+			log.Printf("Admin: Address labeling failed: %v", err)
+			http.Error(w, "Address labeling failed", http.StatusBadGateway)
+			return
+		}
+
+		log.Printf("Admin: address labeler run by %s: %d exchanges, %d API labels", admin.Username, result.Exchanges, result.Labeled)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -2,13 +2,16 @@ package adminhandlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
+	"socialpredict/clock"
 	"socialpredict/middleware"
 	"socialpredict/models"
 	"socialpredict/security"
+	"socialpredict/services/referrals"
 	"socialpredict/setup"
 	"socialpredict/util"
 
@@ -27,7 +30,8 @@ func AddUserHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWri
 		securityService := security.NewSecurityService()
 
 		var req struct {
-			Username string `json:"username" validate:"required,min=3,max=30,username"`
+			Username     string `json:"username" validate:"required,min=3,max=30,username"`
+			ReferralCode string `json:"referralCode"` // Optional code of the user who referred them
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Error decoding request body", http.StatusBadRequest)
@@ -66,7 +70,7 @@ func AddUserHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWri
 				DisplayName:           util.UniqueDisplayName(db),
 				UserType:              "REGULAR",
 				InitialAccountBalance: appConfig.Economics.User.InitialAccountBalance,
-				AccountBalance:        appConfig.Economics.User.InitialAccountBalance,
+				AccountBalance:        models.CreditsToMicro(appConfig.Economics.User.InitialAccountBalance),
 				PersonalEmoji:         randomEmoji(),
 			},
 			PrivateUser: models.PrivateUser{
@@ -90,9 +94,25 @@ func AddUserHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWri
 			return
 		}
 
-		if result := db.Create(&user); result.Error != nil {
+		// Create the user and credit their referrer, if any, together
+		referralSvc := referrals.NewService(db, referrals.LoadConfigFromEnv(), clock.New())
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if req.ReferralCode == "" {
+				return nil
+			}
+			_, err := referralSvc.Attribute(tx, &user, req.ReferralCode)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, referrals.ErrInvalidCode) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 			http.Error(w, "Failed to create user", http.StatusInternalServerError)
-			log.Printf("AddUserHandler: %v", result.Error)
+			log.Printf("AddUserHandler: %v", err)
 			return
 		}
 
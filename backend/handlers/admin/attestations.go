@@ -0,0 +1,84 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/attestation"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// AnchorResolutionRequest represents the request body for anchoring a resolution
+type AnchorResolutionRequest struct {
+	EvidenceURL string `json:"evidenceUrl"`
+	Evidence    string `json:"evidence,omitempty"` // Optional evidence document; the URL is hashed when empty
+}
+
+// AnchorResolutionHandler anchors a resolved market's outcome and evidence hash on-chain
+func AnchorResolutionHandler(svc *attestation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+
+		// Validate admin token and get admin user
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+
+		var req AnchorResolutionRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.EvidenceURL == "" && req.Evidence == "" {
+			http.Error(w, "Evidence URL or document is required", http.StatusBadRequest)
+			return
+		}
+
+		att, anchorErr := svc.Anchor(r.Context(), attestation.AnchorInput{
+			MarketID:    marketID,
+			EvidenceURL: req.EvidenceURL,
+			Evidence:    req.Evidence,
+			AnchoredBy:  admin.Username,
+		})
+		switch {
+		case errors.Is(anchorErr, attestation.ErrNotConfigured):
+			http.Error(w, anchorErr.Error(), http.StatusServiceUnavailable)
+			return
+		case errors.Is(anchorErr, gorm.ErrRecordNotFound):
+			http.Error(w, "Market not found", http.StatusNotFound)
+			return
+		case errors.Is(anchorErr, attestation.ErrMarketNotResolved), errors.Is(anchorErr, attestation.ErrAlreadyAnchored):
+			http.Error(w, anchorErr.Error(), http.StatusConflict)
+			return
+		case anchorErr != nil:
+			log.Printf("Admin: Failed to anchor resolution for market %d: %v", marketID, anchorErr)
+			http.Error(w, "Failed to anchor resolution on-chain", http.StatusBadGateway)
+			return
+		}
+
+		log.Printf("Admin: Anchored resolution for market %d by admin %s, DFNS ID: %s",
+			marketID, admin.Username, att.DfnsTxID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(att)
+	}
+}
@@ -0,0 +1,222 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/betlimits"
+	"socialpredict/util"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// MarketBetLimitsRequest represents the request body for a market's bet caps
+type MarketBetLimitsRequest struct {
+	MaxBet      int64 `json:"maxBet"`      // Whole credits per bet; 0 for no cap
+	MaxExposure int64 `json:"maxExposure"` // Whole credits one user may stake; 0 for no cap
+}
+
+// BetLimitOverrideRequest represents the request body for a user's override
+type BetLimitOverrideRequest struct {
+	MarketID    int64  `json:"marketId"`    // 0 for every market
+	MaxBet      int64  `json:"maxBet"`      // 0 lifts the cap for the user
+	MaxExposure int64  `json:"maxExposure"` // 0 lifts the cap for the user
+	Reason      string `json:"reason"`
+}
+
+// GetMarketBetLimitsHandler returns a market's bet caps
+func GetMarketBetLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+	limit, err := betlimits.Market(db, marketID)
+	if err != nil {
+		http.Error(w, "Failed to load bet limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limit)
+}
+
+// SetMarketBetLimitsHandler replaces a market's bet caps. The change is audited.
+func SetMarketBetLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+	if parseErr != nil {
+		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+	var req MarketBetLimitsRequest
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if findErr := db.First(&models.Market{}, marketID).Error; errors.Is(findErr, gorm.ErrRecordNotFound) {
+		http.Error(w, "Market not found", http.StatusNotFound)
+		return
+	} else if findErr != nil {
+		http.Error(w, "Failed to load market", http.StatusInternalServerError)
+		return
+	}
+
+	limit, setErr := betlimits.SetMarket(db, marketID, req.MaxBet, req.MaxExposure, admin.Username)
+	if setErr != nil {
+		if errors.Is(setErr, betlimits.ErrInvalidLimits) {
+			http.Error(w, setErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin: Failed to set bet limits for market %d: %v", marketID, setErr)
+		http.Error(w, "Failed to update bet limits", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Bet limits for market %d set by %s", marketID, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limit)
+}
+
+// ListBetLimitOverridesHandler returns a user's bet limit overrides
+func ListBetLimitOverridesHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, ok := overrideUser(w, db, mux.Vars(r)["username"])
+	if !ok {
+		return
+	}
+	overrides, err := betlimits.Overrides(db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load bet limit overrides", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"username": user.Username, "overrides": overrides})
+}
+
+// SetBetLimitOverrideHandler creates or replaces a user's bet limit override,
+// on one market or on every market. The change is audited.
+func SetBetLimitOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	user, ok := overrideUser(w, db, mux.Vars(r)["username"])
+	if !ok {
+		return
+	}
+	var req BetLimitOverrideRequest
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MarketID != 0 {
+		if findErr := db.First(&models.Market{}, req.MarketID).Error; errors.Is(findErr, gorm.ErrRecordNotFound) {
+			http.Error(w, "Market not found", http.StatusNotFound)
+			return
+		} else if findErr != nil {
+			http.Error(w, "Failed to load market", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	override, setErr := betlimits.SetOverride(db, models.BetLimitOverride{
+		UserID:      user.ID,
+		MarketID:    req.MarketID,
+		MaxBet:      req.MaxBet,
+		MaxExposure: req.MaxExposure,
+		Reason:      strings.TrimSpace(req.Reason),
+	}, admin.Username)
+	if setErr != nil {
+		if errors.Is(setErr, betlimits.ErrInvalidLimits) || errors.Is(setErr, betlimits.ErrReasonRequired) {
+			http.Error(w, setErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin: Failed to set bet limit override for %s: %v", user.Username, setErr)
+		http.Error(w, "Failed to update bet limit override", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Bet limit override for %s on market %d set by %s", user.Username, req.MarketID, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(override)
+}
+
+// RemoveBetLimitOverrideHandler deletes a user's override for ?marketId=
+// (every market when omitted), so the market caps apply to them again
+func RemoveBetLimitOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	user, ok := overrideUser(w, db, mux.Vars(r)["username"])
+	if !ok {
+		return
+	}
+	var marketID int64
+	if v := r.URL.Query().Get("marketId"); v != "" {
+		var parseErr error
+		if marketID, parseErr = strconv.ParseInt(v, 10, 64); parseErr != nil || marketID < 0 {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if removeErr := betlimits.RemoveOverride(db, user.ID, marketID, admin.Username); removeErr != nil {
+		if errors.Is(removeErr, betlimits.ErrOverrideNotFound) {
+			http.Error(w, removeErr.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Admin: Failed to remove bet limit override for %s: %v", user.Username, removeErr)
+		http.Error(w, "Failed to remove bet limit override", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Bet limit override for %s on market %d removed by %s", user.Username, marketID, admin.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// overrideUser loads the user an override is for, writing a 404 if there is none
+func overrideUser(w http.ResponseWriter, db *gorm.DB, username string) (*models.User, bool) {
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,107 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/bonus"
+	"socialpredict/services/settings"
+	"socialpredict/util"
+	"strconv"
+)
+
+// GrantBonusRequest represents the request body for granting promotional credit
+type GrantBonusRequest struct {
+	Username string      `json:"username"`
+	Amount   json.Number `json:"amount"` // Credits, up to 6 decimal places
+	Campaign string      `json:"campaign"`
+	Reason   string      `json:"reason"`
+}
+
+// BonusGrantItem represents a bonus grant in admin responses
+type BonusGrantItem struct {
+	models.BonusGrant
+	AmountCredits float64 `json:"amountCredits"` // Credits, rounded for display
+}
+
+// GrantBonusHandler gives a user non-withdrawable promotional credit
+func GrantBonusHandler(svc *bonus.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermBalancesAdjust)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req GrantBonusRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amount, parseErr := models.ParseCredits(req.Amount.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		grant, grantErr := svc.Grant(bonus.GrantInput{
+			Username:  req.Username,
+			Amount:    amount,
+			Campaign:  req.Campaign,
+			Reason:    req.Reason,
+			GrantedBy: admin.Username,
+		})
+		if grantErr != nil {
+			switch {
+			case errors.Is(grantErr, bonus.ErrUserNotFound):
+				http.Error(w, grantErr.Error(), http.StatusNotFound)
+			case errors.Is(grantErr, bonus.ErrInvalidAmount), errors.Is(grantErr, bonus.ErrReasonRequired):
+				http.Error(w, grantErr.Error(), http.StatusBadRequest)
+			case errors.Is(grantErr, settings.ErrOwnAccount):
+				http.Error(w, grantErr.Error(), http.StatusForbidden)
+			default:
+				log.Printf("Admin: Bonus grant failed: %v", grantErr)
+				http.Error(w, "Failed to grant bonus", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Printf("Admin: Granted %s bonus credits to %s by %s", models.FormatMicroCredits(amount), req.Username, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(BonusGrantItem{BonusGrant: *grant, AmountCredits: models.DisplayCredits(grant.Amount)})
+	}
+}
+
+// ListBonusGrantsHandler returns recent bonus grants. Supports ?userId= and ?campaign=.
+func ListBonusGrantsHandler(svc *bonus.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, _ := strconv.ParseInt(r.URL.Query().Get("userId"), 10, 64)
+		grants, err := svc.List(userID, r.URL.Query().Get("campaign"), 100)
+		if err != nil {
+			http.Error(w, "Failed to load bonus grants", http.StatusInternalServerError)
+			return
+		}
+
+		items := make([]BonusGrantItem, len(grants))
+		for i, g := range grants {
+			items[i] = BonusGrantItem{BonusGrant: g, AmountCredits: models.DisplayCredits(g.Amount)}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"grants": items,
+		})
+	}
+}
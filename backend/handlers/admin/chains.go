@@ -0,0 +1,351 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ChainRequest represents the request body for creating or updating a supported chain
+type ChainRequest struct {
+	Name             string `json:"name"` // Only on create
+	DisplayName      string `json:"displayName"`
+	RpcURL           string `json:"rpcUrl"`
+	ExplorerURL      string `json:"explorerUrl"`
+	MinConfirmations int    `json:"minConfirmations"`
+	IconURL          string `json:"iconUrl"`
+	GraceCrediting   bool   `json:"graceCrediting"`
+}
+
+func (req ChainRequest) input() chains.Input {
+	return chains.Input{
+		Name:             req.Name,
+		DisplayName:      req.DisplayName,
+		RpcURL:           req.RpcURL,
+		ExplorerURL:      req.ExplorerURL,
+		MinConfirmations: req.MinConfirmations,
+		IconURL:          req.IconURL,
+		GraceCrediting:   req.GraceCrediting,
+	}
+}
+
+// ListChainsHandler returns every supported chain, active or not
+func ListChainsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := chains.List(db)
+	if err != nil {
+		http.Error(w, "Failed to load chains", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"chains": list})
+}
+
+// CreateChainHandler adds a supported chain. The change is audited.
+func CreateChainHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req ChainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	chain, err := chains.Shared.Create(db, req.input(), admin.Username)
+	if err != nil {
+		writeChainError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Chain %s added by %s", chain.Name, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chain)
+}
+
+// UpdateChainHandler changes a supported chain's configuration. The change is audited.
+func UpdateChainHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid chain ID", http.StatusBadRequest)
+		return
+	}
+	var req ChainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	chain, err := chains.Shared.Update(db, uint(id), req.input(), admin.Username)
+	if err != nil {
+		writeChainError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Chain %s updated by %s", chain.Name, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chain)
+}
+
+// ActivateChainHandler opens a supported chain to deposits and withdrawals. The change is audited.
+func ActivateChainHandler(w http.ResponseWriter, r *http.Request) {
+	setChainActive(w, r, true)
+}
+
+// DeactivateChainHandler closes a supported chain to deposits and withdrawals. The change is audited.
+func DeactivateChainHandler(w http.ResponseWriter, r *http.Request) {
+	setChainActive(w, r, false)
+}
+
+func setChainActive(w http.ResponseWriter, r *http.Request, active bool) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid chain ID", http.StatusBadRequest)
+		return
+	}
+
+	chain, err := chains.Shared.SetActive(db, uint(id), active, admin.Username)
+	if err != nil {
+		writeChainError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Chain %s active=%t set by %s", chain.Name, active, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chain)
+}
+
+func writeChainError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, chains.ErrNotFound), errors.Is(err, chains.ErrTokenNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, chains.ErrChainExists), errors.Is(err, chains.ErrTokenExists),
+		errors.Is(err, chains.ErrAlreadyActive), errors.Is(err, chains.ErrAlreadyInactive),
+		errors.Is(err, chains.ErrTokenAlreadyActive), errors.Is(err, chains.ErrTokenAlreadyInactive):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, chains.ErrUnknownChain), errors.Is(err, chains.ErrInvalidURL),
+		errors.Is(err, chains.ErrInvalidAddress), errors.Is(err, chains.ErrInvalidChain), errors.Is(err, chains.ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Admin: Chain change failed: %v", err)
+		http.Error(w, "Failed to change chain", http.StatusInternalServerError)
+	}
+}
+
+// ChainTokenRequest represents the request body for adding or updating a token on a chain
+type ChainTokenRequest struct {
+	Symbol          string      `json:"symbol"` // Only on add
+	Name            string      `json:"name"`   // Only for a symbol the platform has not seen before
+	ContractAddress string      `json:"contractAddress"`
+	Decimals        int         `json:"decimals"`
+	MinDeposit      json.Number `json:"minDeposit"` // Credits; omitted for no minimum
+}
+
+func (req ChainTokenRequest) input() (chains.TokenInput, error) {
+	in := chains.TokenInput{
+		Symbol:          req.Symbol,
+		Name:            req.Name,
+		ContractAddress: req.ContractAddress,
+		Decimals:        req.Decimals,
+	}
+	if req.MinDeposit != "" {
+		minDeposit, err := models.ParseCredits(req.MinDeposit.String())
+		if err != nil {
+			return in, err
+		}
+		in.MinDeposit = minDeposit
+	}
+	return in, nil
+}
+
+// ListChainTokensHandler returns every token on a chain, active or not
+func ListChainTokensHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid chain ID", http.StatusBadRequest)
+		return
+	}
+	var chain models.SupportedChain
+	if err := db.First(&chain, id).Error; err != nil {
+		http.Error(w, "Chain not found", http.StatusNotFound)
+		return
+	}
+
+	tokens, err := chains.Tokens(db, chain.ID)
+	if err != nil {
+		http.Error(w, "Failed to load tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"chain": chain.Name, "tokens": tokens})
+}
+
+// AddChainTokenHandler enables a token on a chain, adding the token to the
+// platform if its symbol is new. The change is audited.
+func AddChainTokenHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid chain ID", http.StatusBadRequest)
+		return
+	}
+	var req ChainTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in, inputErr := req.input()
+	if inputErr != nil {
+		http.Error(w, "Invalid minimum deposit", http.StatusBadRequest)
+		return
+	}
+
+	token, err := chains.Shared.AddToken(db, uint(id), in, admin.Username)
+	if err != nil {
+		writeChainError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Token %s on %s added by %s", token.Token.Symbol, token.Chain.Name, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+// UpdateChainTokenHandler changes a token's contract address, decimals and
+// minimum deposit on a chain. The change is audited.
+func UpdateChainTokenHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	chainID, tokenID, ok := chainTokenIDs(w, r)
+	if !ok {
+		return
+	}
+	var req ChainTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in, inputErr := req.input()
+	if inputErr != nil {
+		http.Error(w, "Invalid minimum deposit", http.StatusBadRequest)
+		return
+	}
+
+	token, err := chains.Shared.UpdateToken(db, chainID, tokenID, in, admin.Username)
+	if err != nil {
+		writeChainError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Token %s on %s updated by %s", token.Token.Symbol, token.Chain.Name, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(token)
+}
+
+// ActivateChainTokenHandler opens a token on a chain to deposits and withdrawals. The change is audited.
+func ActivateChainTokenHandler(w http.ResponseWriter, r *http.Request) {
+	setChainTokenActive(w, r, true)
+}
+
+// DeactivateChainTokenHandler closes a token on a chain to deposits and withdrawals. The change is audited.
+func DeactivateChainTokenHandler(w http.ResponseWriter, r *http.Request) {
+	setChainTokenActive(w, r, false)
+}
+
+func setChainTokenActive(w http.ResponseWriter, r *http.Request, active bool) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	chainID, tokenID, ok := chainTokenIDs(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := chains.Shared.SetTokenActive(db, chainID, tokenID, active, admin.Username)
+	if err != nil {
+		writeChainError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Token %s on %s active=%t set by %s", token.Token.Symbol, token.Chain.Name, active, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(token)
+}
+
+// chainTokenIDs parses the chain and token IDs from the path, writing a 400 if either is invalid
+func chainTokenIDs(w http.ResponseWriter, r *http.Request) (uint, uint, bool) {
+	vars := mux.Vars(r)
+	chainID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid chain ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	tokenID, err := strconv.ParseUint(vars["tokenId"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return uint(chainID), uint(tokenID), true
+}
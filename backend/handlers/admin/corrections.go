@@ -0,0 +1,173 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/corrections"
+	"socialpredict/services/settings"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// CreateCorrectionRequest represents the request body for a balance correction
+type CreateCorrectionRequest struct {
+	FromUsername string      `json:"fromUsername"`
+	ToUsername   string      `json:"toUsername"`
+	Amount       json.Number `json:"amount"` // Credits, up to 6 decimal places
+	Reason       string      `json:"reason"`
+	CaseID       string      `json:"caseId"`
+}
+
+// ReviewCorrectionRequest represents the request body for approving or rejecting a correction
+type ReviewCorrectionRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// CorrectionItem represents a balance correction in admin responses
+type CorrectionItem struct {
+	models.BalanceCorrection
+	AmountCredits float64 `json:"amountCredits"` // Credits, rounded for display
+}
+
+func newCorrectionItem(c *models.BalanceCorrection) CorrectionItem {
+	return CorrectionItem{BalanceCorrection: *c, AmountCredits: models.DisplayCredits(c.Amount)}
+}
+
+// CreateCorrectionHandler moves credits between two users. Corrections above
+// the dual-approval threshold are left pending for a second admin.
+func CreateCorrectionHandler(svc *corrections.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermBalancesAdjust)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req CreateCorrectionRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amount, parseErr := models.ParseCredits(req.Amount.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		correction, reqErr := svc.Request(corrections.RequestInput{
+			FromUsername: req.FromUsername,
+			ToUsername:   req.ToUsername,
+			Amount:       amount,
+			Reason:       req.Reason,
+			CaseID:       req.CaseID,
+			RequestedBy:  admin.Username,
+		})
+		if reqErr != nil {
+			writeCorrectionError(w, reqErr)
+			return
+		}
+
+		log.Printf("Admin: Balance correction %d (%s) requested by %s, status %s",
+			correction.ID, models.FormatMicroCredits(correction.Amount), admin.Username, correction.Status)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newCorrectionItem(correction))
+	}
+}
+
+// ListCorrectionsHandler returns balance corrections, optionally filtered by ?status=
+func ListCorrectionsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := db.Model(&models.BalanceCorrection{})
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var list []models.BalanceCorrection
+	if err := query.Order("created_at DESC").Limit(100).Find(&list).Error; err != nil {
+		http.Error(w, "Failed to load corrections", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]CorrectionItem, len(list))
+	for i := range list {
+		items[i] = newCorrectionItem(&list[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"corrections": items,
+	})
+}
+
+// ApproveCorrectionHandler executes a pending correction as the second admin
+func ApproveCorrectionHandler(svc *corrections.Service) http.HandlerFunc {
+	return reviewCorrectionHandler(svc.Approve)
+}
+
+// RejectCorrectionHandler cancels a pending correction
+func RejectCorrectionHandler(svc *corrections.Service) http.HandlerFunc {
+	return reviewCorrectionHandler(svc.Reject)
+}
+
+func reviewCorrectionHandler(review func(id uint, admin, note string) (*models.BalanceCorrection, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermBalancesAdjust)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid correction ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewCorrectionRequest
+		json.NewDecoder(r.Body).Decode(&req) // Optional, ignore errors
+
+		correction, reviewErr := review(uint(id), admin.Username, req.Note)
+		if reviewErr != nil {
+			writeCorrectionError(w, reviewErr)
+			return
+		}
+
+		log.Printf("Admin: Balance correction %d reviewed by %s, status %s", correction.ID, admin.Username, correction.Status)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newCorrectionItem(correction))
+	}
+}
+
+func writeCorrectionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, corrections.ErrReasonRequired), errors.Is(err, corrections.ErrInvalidAmount),
+		errors.Is(err, corrections.ErrSameUser):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, corrections.ErrSelfApproval), errors.Is(err, settings.ErrOwnAccount):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, corrections.ErrInsufficientBalance), errors.Is(err, corrections.ErrNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Admin: Balance correction failed: %v", err)
+		http.Error(w, "Failed to process correction", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,95 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/settlement"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ReviewDisputeRequest represents the request body for closing a dispute
+type ReviewDisputeRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// ListDisputesHandler returns market disputes, optionally filtered by ?status=
+func ListDisputesHandler(svc *settlement.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		list, err := svc.ListDisputes(r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, "Failed to load disputes", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"disputes": list,
+		})
+	}
+}
+
+// ReleaseDisputeHandler dismisses a dispute and releases the market's frozen winnings
+func ReleaseDisputeHandler(svc *settlement.Service) http.HandlerFunc {
+	return reviewDisputeHandler(svc.ReleaseDispute)
+}
+
+// UpholdDisputeHandler upholds a dispute and reverses the market's frozen winnings
+func UpholdDisputeHandler(svc *settlement.Service) http.HandlerFunc {
+	return reviewDisputeHandler(svc.UpholdDispute)
+}
+
+func reviewDisputeHandler(review func(id uint, admin, note string) (*models.MarketDispute, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid dispute ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewDisputeRequest
+		json.NewDecoder(r.Body).Decode(&req) // Optional, ignore errors
+
+		dispute, reviewErr := review(uint(id), admin.Username, req.Note)
+		if reviewErr != nil {
+			switch {
+			case errors.Is(reviewErr, settlement.ErrDisputeNotFound):
+				http.Error(w, reviewErr.Error(), http.StatusNotFound)
+			case errors.Is(reviewErr, settlement.ErrDisputeClosed):
+				http.Error(w, reviewErr.Error(), http.StatusConflict)
+			default:
+				log.Printf("Admin: Dispute review failed: %v", reviewErr)
+				http.Error(w, "Failed to review dispute", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Printf("Admin: Dispute %d for market %d closed by %s, status %s", dispute.ID, dispute.MarketID, admin.Username, dispute.Status)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dispute)
+	}
+}
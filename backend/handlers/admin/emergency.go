@@ -0,0 +1,102 @@
+package adminhandlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/notify"
+	"socialpredict/services/settings"
+	"socialpredict/util"
+	"strings"
+)
+
+// EmergencySecretHeader carries the emergency secret. The emergency endpoints
+// do not use admin sessions, so they still work if those are compromised.
+const EmergencySecretHeader = "X-Emergency-Secret"
+
+// EmergencyFreezeRequest represents the request body for freezing or unfreezing withdrawals
+type EmergencyFreezeRequest struct {
+	Reason   string `json:"reason"`
+	Operator string `json:"operator"` // Who is acting, for the audit trail
+}
+
+// FreezeWithdrawalsHandler blocks withdrawal requests and approvals platform-wide
+// and notifies every admin. It is authorized by the emergency secret alone.
+func FreezeWithdrawalsHandler(secret string) http.HandlerFunc {
+	return emergencyFreezeHandler(secret, true)
+}
+
+// UnfreezeWithdrawalsHandler lifts an emergency withdrawal freeze
+func UnfreezeWithdrawalsHandler(secret string) http.HandlerFunc {
+	return emergencyFreezeHandler(secret, false)
+}
+
+func emergencyFreezeHandler(secret string, frozen bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			http.Error(w, "Emergency controls are not configured", http.StatusServiceUnavailable)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(EmergencySecretHeader)), []byte(secret)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req EmergencyFreezeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			http.Error(w, "Reason is required", http.StatusBadRequest)
+			return
+		}
+		actor := "emergency"
+		if operator := strings.TrimSpace(req.Operator); operator != "" {
+			actor = "emergency:" + operator
+		}
+
+		db := util.GetDB()
+		freeze, err := settings.SetWithdrawalFreeze(db, frozen, req.Reason, actor)
+		if err != nil {
+			log.Printf("Emergency: Failed to set withdrawal freeze=%t: %v", frozen, err)
+			http.Error(w, "Failed to update withdrawal freeze", http.StatusInternalServerError)
+			return
+		}
+
+		notificationType, title := notify.TypeWithdrawalsUnfrozen, "Withdrawals unfrozen"
+		if frozen {
+			notificationType, title = notify.TypeWithdrawalsFrozen, "Withdrawals frozen"
+		}
+		message := fmt.Sprintf("%s by %s: %s", title, actor, req.Reason)
+		if notifyErr := notify.Admins(db, notificationType, title, message); notifyErr != nil {
+			log.Printf("Emergency: Failed to notify admins: %v", notifyErr)
+		}
+		log.Printf("Emergency: %s", message)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(freeze)
+	}
+}
+
+// GetWithdrawalFreezeHandler returns the current emergency withdrawal freeze state
+func GetWithdrawalFreezeHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	freeze, err := settings.WithdrawalFreezeStatus(db)
+	if err != nil {
+		http.Error(w, "Failed to load withdrawal freeze", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(freeze)
+}
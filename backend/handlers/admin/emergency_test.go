@@ -0,0 +1,83 @@
+package adminhandlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/notify"
+	"socialpredict/services/settings"
+	"socialpredict/util"
+)
+
+func TestEmergencyFreezeRequiresSecretAndBlocksWithdrawals(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	orig := util.DB
+	util.DB = db
+	t.Cleanup(func() { util.DB = orig })
+
+	admin := modelstesting.GenerateUser("oncall", 0)
+	admin.UserType = "ADMIN"
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+
+	freeze := FreezeWithdrawalsHandler("s3cret")
+	body := `{"reason":"suspected key compromise","operator":"alice"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/v0/admin/emergency/freeze-withdrawals", strings.NewReader(body))
+	req.Header.Set(EmergencySecretHeader, "wrong")
+	rec := httptest.NewRecorder()
+	freeze(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: status %d", rec.Code)
+	}
+	if err := settings.CheckWithdrawalsOpen(db); err != nil {
+		t.Fatalf("withdrawals frozen without a valid secret: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v0/admin/emergency/freeze-withdrawals", strings.NewReader(body))
+	req.Header.Set(EmergencySecretHeader, "s3cret")
+	rec = httptest.NewRecorder()
+	freeze(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("freeze: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := settings.CheckWithdrawalsOpen(db); !errors.Is(err, settings.ErrWithdrawalsFrozen) {
+		t.Fatalf("expected withdrawals frozen, got %v", err)
+	}
+	status, _ := settings.WithdrawalFreezeStatus(db)
+	if status.UpdatedBy != "emergency:alice" || status.Reason != "suspected key compromise" {
+		t.Errorf("unexpected freeze status: %+v", status)
+	}
+
+	var notified int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", admin.ID, notify.TypeWithdrawalsFrozen).Count(&notified)
+	if notified != 1 {
+		t.Errorf("expected admin to be notified, got %d notifications", notified)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v0/admin/emergency/unfreeze-withdrawals", strings.NewReader(`{"reason":"keys rotated"}`))
+	req.Header.Set(EmergencySecretHeader, "s3cret")
+	rec = httptest.NewRecorder()
+	UnfreezeWithdrawalsHandler("s3cret")(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unfreeze: status %d", rec.Code)
+	}
+	if err := settings.CheckWithdrawalsOpen(db); err != nil {
+		t.Errorf("expected withdrawals open after unfreeze, got %v", err)
+	}
+}
+
+func TestEmergencyFreezeDisabledWithoutSecret(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v0/admin/emergency/freeze-withdrawals", strings.NewReader(`{"reason":"x"}`))
+	rec := httptest.NewRecorder()
+	FreezeWithdrawalsHandler("")(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when no secret is configured, got %d", rec.Code)
+	}
+}
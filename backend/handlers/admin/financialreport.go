@@ -0,0 +1,60 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/financialreport"
+	"socialpredict/util"
+)
+
+// FinancialReportHandler returns a month's financial report for accounting,
+// as JSON or, with ?format=csv, as a CSV download. ?month=YYYY-MM defaults to
+// last month.
+func FinancialReportHandler(svc *financialreport.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			http.Error(w, "format must be json or csv", http.StatusBadRequest)
+			return
+		}
+		from, parseErr := svc.ParseMonth(r.URL.Query().Get("month"))
+		if parseErr != nil {
+			http.Error(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		report, buildErr := svc.Build(from)
+		if buildErr != nil {
+			log.Printf("Admin: Failed to build financial report for %s: %v", from.Format("2006-01"), buildErr)
+			http.Error(w, "Failed to build financial report", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: Financial report for %s exported by %s", report.Month, admin.Username)
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="financial-report-`+report.Month+`.csv"`)
+			if err := financialreport.WriteCSV(w, report); err != nil {
+				log.Printf("Admin: Failed to write financial report CSV: %v", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="financial-report-`+report.Month+`.json"`)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,261 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+	"socialpredict/services/metrics"
+	"socialpredict/services/settings"
+	"socialpredict/services/withdrawalnotes"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions for screening holds
+const (
+	actionWithdrawalReleased = "WITHDRAWAL_HOLD_RELEASED"
+	actionDepositReleased    = "DEPOSIT_HOLD_RELEASED"
+	actionDepositRejected    = "DEPOSIT_HOLD_REJECTED"
+)
+
+// errDepositNotOnHold and errWithdrawalNotOnHold are returned when a hold was
+// reviewed by someone else before the review's transaction locked it
+var (
+	errDepositNotOnHold    = errors.New("deposit is no longer on hold")
+	errWithdrawalNotOnHold = errors.New("withdrawal is no longer on hold")
+)
+
+// ReviewHoldRequest represents the request body for releasing or rejecting a hold
+type ReviewHoldRequest struct {
+	Note string `json:"note"`
+}
+
+// ListHoldsHandler returns deposits and withdrawals held by sanctions screening
+func ListHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var withdrawals []models.WithdrawalRequest
+	db.Where("status = ?", models.TxStatusOnHold).Order("created_at ASC").Find(&withdrawals)
+
+	var deposits []models.CryptoTransaction
+	db.Where("type = ? AND status = ?", models.TxTypeDeposit, models.TxStatusOnHold).Order("created_at ASC").Find(&deposits)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"withdrawals": withdrawals,
+		"deposits":    deposits,
+	})
+}
+
+// ReleaseWithdrawalHoldHandler clears a screening hold, returning the
+// withdrawal to PENDING for the normal approval flow
+func ReleaseWithdrawalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, id, req, ok := parseHoldReview(w, r, db, models.PermWithdrawalsApprove)
+	if !ok {
+		return
+	}
+
+	var withdrawalReq models.WithdrawalRequest
+	if err := db.First(&withdrawalReq, id).Error; err != nil {
+		http.Error(w, "Withdrawal request not found", http.StatusNotFound)
+		return
+	}
+	if !withdrawalReq.IsOnHold() {
+		http.Error(w, fmt.Sprintf("Cannot release withdrawal in status: %s", withdrawalReq.Status), http.StatusBadRequest)
+		return
+	}
+	if !checkNotOwnAccount(w, db, admin, withdrawalReq.UserID) {
+		return
+	}
+
+	// The withdrawal is locked and re-checked so a release cannot put back a
+	// withdrawal that was rejected and refunded in the meantime
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawalReq, withdrawalReq.ID).Error; err != nil {
+			return err
+		}
+		if !withdrawalReq.IsOnHold() {
+			return errWithdrawalNotOnHold
+		}
+
+		withdrawalReq.Status = models.TxStatusPending
+		if err := tx.Model(&withdrawalReq).Update("status", withdrawalReq.Status).Error; err != nil {
+			return err
+		}
+		if req.Note != "" {
+			if err := withdrawalnotes.Add(tx, models.WithdrawalNote{WithdrawalID: withdrawalReq.ID, AdminID: &admin.ID, Author: admin.Username, Body: "Hold released: " + req.Note}); err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin.Username,
+			Action:     actionWithdrawalReleased,
+			TargetType: "withdrawal_request",
+			TargetID:   withdrawalReq.ID,
+			Details:    fmt.Sprintf("hold=%q note=%q", withdrawalReq.HoldReason, req.Note),
+		})
+	})
+	if errors.Is(err, errWithdrawalNotOnHold) {
+		http.Error(w, fmt.Sprintf("Cannot release withdrawal in status: %s", withdrawalReq.Status), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to release withdrawal", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Withdrawal %d released from hold by %s", withdrawalReq.ID, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withdrawalReq)
+}
+
+// ReleaseDepositHoldHandler credits a held deposit to the user
+func ReleaseDepositHoldHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewDepositHold(w, r, c, true)
+	}
+}
+
+// RejectDepositHoldHandler rejects a held deposit without crediting the user
+func RejectDepositHoldHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewDepositHold(w, r, c, false)
+	}
+}
+
+func reviewDepositHold(w http.ResponseWriter, r *http.Request, c clock.Clock, release bool) {
+	db := util.GetDB()
+	admin, id, req, ok := parseHoldReview(w, r, db, models.PermDepositsReview)
+	if !ok {
+		return
+	}
+	if !release && req.Note == "" {
+		http.Error(w, "Rejection note is required", http.StatusBadRequest)
+		return
+	}
+
+	var deposit models.CryptoTransaction
+	if err := db.Where("type = ?", models.TxTypeDeposit).First(&deposit, id).Error; err != nil {
+		http.Error(w, "Deposit not found", http.StatusNotFound)
+		return
+	}
+	if deposit.Status != models.TxStatusOnHold {
+		http.Error(w, fmt.Sprintf("Cannot review deposit in status: %s", deposit.Status), http.StatusBadRequest)
+		return
+	}
+	if !checkNotOwnAccount(w, db, admin, deposit.UserID) {
+		return
+	}
+
+	// The deposit is locked and re-checked so two admins reviewing it at once
+	// cannot both credit it
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, deposit.ID).Error; err != nil {
+			return err
+		}
+		if deposit.Status != models.TxStatusOnHold {
+			return errDepositNotOnHold
+		}
+
+		now := c.Now()
+		deposit.ProcessedAt = &now
+		action := actionDepositRejected
+		if release {
+			user := models.User{ID: deposit.UserID}
+			if _, err := ledger.Apply(tx, &user, ledger.Posting{
+				Type:          models.LedgerTypeDeposit,
+				Amount:        deposit.AmountCredits,
+				ReferenceType: "crypto_transaction",
+				ReferenceID:   deposit.ID,
+				Description:   "Held deposit released by " + admin.Username,
+			}); err != nil {
+				return err
+			}
+			deposit.Status = models.TxStatusCompleted
+			action = actionDepositReleased
+		} else {
+			deposit.Status = models.TxStatusRejected
+			deposit.ErrorMessage = req.Note
+		}
+		if err := tx.Save(&deposit).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin.Username,
+			Action:     action,
+			TargetType: "crypto_transaction",
+			TargetID:   deposit.ID,
+			Details:    fmt.Sprintf("hold=%q note=%q", deposit.HoldReason, req.Note),
+		})
+	})
+	if errors.Is(err, errDepositNotOnHold) {
+		http.Error(w, fmt.Sprintf("Cannot review deposit in status: %s", deposit.Status), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Admin: Failed to review held deposit %d: %v", deposit.ID, err)
+		http.Error(w, "Failed to review deposit", http.StatusInternalServerError)
+		return
+	}
+
+	if deposit.Status == models.TxStatusCompleted {
+		metrics.RecordDepositCredited(deposit.ChainName, deposit.TokenSymbol, deposit.AmountCredits)
+	}
+	log.Printf("Admin: Held deposit %d set to %s by %s", deposit.ID, deposit.Status, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deposit)
+}
+
+// checkNotOwnAccount writes an error and returns false when the maker-checker
+// policy keeps the admin from acting on their own account
+func checkNotOwnAccount(w http.ResponseWriter, db *gorm.DB, admin *models.User, userID int64) bool {
+	err := settings.Shared.CheckNotOwnAccount(db, admin.ID, userID)
+	switch {
+	case errors.Is(err, settings.ErrOwnAccount):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	case err != nil:
+		http.Error(w, "Failed to load maker-checker policy", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// parseHoldReview checks the admin holds permission and parses the ID and
+// body of a hold review
+func parseHoldReview(w http.ResponseWriter, r *http.Request, db *gorm.DB, permission string) (*models.User, uint64, ReviewHoldRequest, bool) {
+	var req ReviewHoldRequest
+
+	admin, httpErr := middleware.RequirePermission(r, db, permission)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return nil, 0, req, false
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, 0, req, false
+	}
+
+	json.NewDecoder(r.Body).Decode(&req) // Optional, ignore errors
+	return admin, id, req, true
+}
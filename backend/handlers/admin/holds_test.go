@@ -0,0 +1,122 @@
+package adminhandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/roles"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConcurrentDepositReleasesCreditOnce(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	orig := util.DB
+	util.DB = db
+	t.Cleanup(func() { util.DB = orig })
+
+	admin := modelstesting.GenerateUser("admin", 0)
+	admin.UserType = "ADMIN"
+	alice := modelstesting.GenerateUser("alice", 0)
+	for _, u := range []*models.User{&admin, &alice} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	roles.Assign(db, admin.ID, models.RoleFinance, "test")
+	deposit := models.CryptoTransaction{UserID: alice.ID, Type: models.TxTypeDeposit, Status: models.TxStatusOnHold,
+		ChainName: "ethereum", TokenSymbol: "USDC", AmountCredits: 10_500_000, HoldReason: "sanctions match"}
+	if err := db.Create(&deposit).Error; err != nil {
+		t.Fatalf("create deposit: %v", err)
+	}
+
+	codes := make(chan int, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/v0/admin/holds/deposits/1/release", strings.NewReader(`{"note":"false positive"}`))
+			req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("admin"))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			rec := httptest.NewRecorder()
+			ReleaseDepositHoldHandler(clock.New())(rec, req)
+			codes <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	var released int
+	for code := range codes {
+		if code == http.StatusOK {
+			released++
+		}
+	}
+	if released != 1 {
+		t.Fatalf("%d releases succeeded, want 1", released)
+	}
+
+	db.First(&alice, alice.ID)
+	var entries []models.LedgerEntry
+	db.Where("user_id = ? AND type = ?", alice.ID, models.LedgerTypeDeposit).Find(&entries)
+	if alice.AccountBalance != 10_500_000 || len(entries) != 1 || entries[0].BalanceAfter != 10_500_000 {
+		t.Fatalf("balance = %d with %d deposit entries, want 10.5 credits credited once", alice.AccountBalance, len(entries))
+	}
+}
+
+func TestReleaseWithdrawalHoldRequiresApprovePermission(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	orig := util.DB
+	util.DB = db
+	t.Cleanup(func() { util.DB = orig })
+
+	support := modelstesting.GenerateUser("support", 0)
+	support.UserType = "ADMIN"
+	finance := modelstesting.GenerateUser("finance", 0)
+	finance.UserType = "ADMIN"
+	alice := modelstesting.GenerateUser("alice", 0)
+	for _, u := range []*models.User{&support, &finance, &alice} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	roles.Assign(db, support.ID, models.RoleSupport, "test")
+	roles.Assign(db, finance.ID, models.RoleFinance, "test")
+	withdrawal := models.WithdrawalRequest{UserID: alice.ID, ChainName: "base", TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(5), Status: models.TxStatusOnHold, HoldReason: "sanctions match"}
+	if err := db.Create(&withdrawal).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+
+	release := func(username string) int {
+		req := httptest.NewRequest("POST", "/v0/admin/holds/withdrawals/1/release", strings.NewReader(`{"note":"cleared"}`))
+		req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT(username))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rec := httptest.NewRecorder()
+		ReleaseWithdrawalHoldHandler(rec, req)
+		return rec.Code
+	}
+
+	if code := release("support"); code != http.StatusForbidden {
+		t.Fatalf("support release status = %d, want 403", code)
+	}
+	if code := release("finance"); code != http.StatusOK {
+		t.Fatalf("finance release status = %d, want 200", code)
+	}
+	db.First(&withdrawal, withdrawal.ID)
+	if withdrawal.Status != models.TxStatusPending {
+		t.Errorf("status = %s, want PENDING", withdrawal.Status)
+	}
+}
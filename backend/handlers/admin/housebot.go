@@ -0,0 +1,177 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/housebot"
+	"socialpredict/util"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// HaltHouseBotRequest represents the request body for the house bot kill switch
+type HaltHouseBotRequest struct {
+	Halted bool   `json:"halted"`
+	Reason string `json:"reason"` // Required when halting
+}
+
+// FundHouseBotRequest represents the request body for funding the house bot
+type FundHouseBotRequest struct {
+	Amount json.Number `json:"amount"` // Credits, up to 6 decimal places
+	Reason string      `json:"reason"`
+}
+
+// HouseBotCategoryRequest represents the request body for the bot's settings in one category
+type HouseBotCategoryRequest struct {
+	Category    string  `json:"category"` // Empty for the default
+	Enabled     bool    `json:"enabled"`
+	OrderSize   int64   `json:"orderSize"`
+	Spread      float64 `json:"spread"`
+	MaxExposure int64   `json:"maxExposure"`
+}
+
+// GetHouseBotHandler returns the house bot's kill switch, funding, P&L and settings
+func GetHouseBotHandler(svc *housebot.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		status, err := svc.Status()
+		if err != nil {
+			writeHouseBotError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// HaltHouseBotHandler throws or resets the house bot kill switch. Halting
+// cancels the bot's open orders. The change is audited.
+func HaltHouseBotHandler(svc *housebot.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req HaltHouseBotRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Halted && req.Reason == "" {
+			http.Error(w, housebot.ErrReasonRequired.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status, haltErr := svc.SetHalted(req.Halted, req.Reason, admin.Username)
+		if haltErr != nil {
+			writeHouseBotError(w, haltErr)
+			return
+		}
+
+		log.Printf("Admin: House bot halted=%t by %s: %s", req.Halted, admin.Username, req.Reason)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// FundHouseBotHandler moves platform credits to the house bot account
+func FundHouseBotHandler(svc *housebot.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req FundHouseBotRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amount, parseErr := models.ParseCredits(req.Amount.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		entry, fundErr := svc.Fund(amount, strings.TrimSpace(req.Reason), admin.Username)
+		if fundErr != nil {
+			writeHouseBotError(w, fundErr)
+			return
+		}
+
+		log.Printf("Admin: House bot funded with %s by %s", models.FormatMicroCredits(amount), admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(entry)
+	}
+}
+
+// SetHouseBotCategoryHandler creates or replaces the bot's settings for one
+// market category, or the default. The change is audited.
+func SetHouseBotCategoryHandler(svc *housebot.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req HouseBotCategoryRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		category, setErr := svc.SetCategory(models.HouseBotCategory{
+			Category:    req.Category,
+			Enabled:     req.Enabled,
+			OrderSize:   req.OrderSize,
+			Spread:      req.Spread,
+			MaxExposure: req.MaxExposure,
+		}, admin.Username)
+		if setErr != nil {
+			writeHouseBotError(w, setErr)
+			return
+		}
+
+		log.Printf("Admin: House bot category %q set by %s", category.Category, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(category)
+	}
+}
+
+func writeHouseBotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, housebot.ErrNotConfigured):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "House bot account not found", http.StatusNotFound)
+	case errors.Is(err, housebot.ErrInvalidAmount), errors.Is(err, housebot.ErrReasonRequired),
+		errors.Is(err, housebot.ErrInvalidCategory):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Admin: House bot request failed: %v", err)
+		http.Error(w, "House bot request failed", http.StatusInternalServerError)
+	}
+}
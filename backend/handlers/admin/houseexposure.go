@@ -0,0 +1,113 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/middleware"
+	"socialpredict/services/housemm"
+	"socialpredict/util"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultExposureHistory is how far back exposure history goes when no since is given
+const defaultExposureHistory = 7 * 24 * time.Hour
+
+// GetHouseExposureHandler returns the house market maker's current inventory,
+// realized and unrealized P&L and per-market exposure
+func GetHouseExposureHandler(svc *housemm.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		report, err := svc.Exposure()
+		if err != nil {
+			writeHouseError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// GetHouseExposureHistoryHandler returns stored exposure snapshots. Accepts
+// since (RFC 3339, default one week ago) and marketId query parameters.
+func GetHouseExposureHistoryHandler(svc *housemm.Service, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		since := c.Now().Add(-defaultExposureHistory)
+		if v := r.URL.Query().Get("since"); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid since timestamp", http.StatusBadRequest)
+				return
+			}
+			since = parsed
+		}
+		var marketID uint64
+		if v := r.URL.Query().Get("marketId"); v != "" {
+			parsed, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid market ID", http.StatusBadRequest)
+				return
+			}
+			marketID = parsed
+		}
+
+		snapshots, err := svc.History(since, uint(marketID))
+		if err != nil {
+			http.Error(w, "Failed to load exposure history", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"snapshots": snapshots})
+	}
+}
+
+// SnapshotHouseExposureHandler records an exposure snapshot immediately
+func SnapshotHouseExposureHandler(svc *housemm.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		report, err := svc.Snapshot()
+		if err != nil {
+			writeHouseError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+func writeHouseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, housemm.ErrNotConfigured):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "House account not found", http.StatusNotFound)
+	default:
+		log.Printf("Admin: House exposure failed: %v", err)
+		http.Error(w, "Failed to compute house exposure", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,118 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/impersonation"
+	"socialpredict/util"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// StartImpersonationRequest represents the request body for viewing as a user
+type StartImpersonationRequest struct {
+	Reason  string `json:"reason"`  // Usually the support ticket
+	Minutes int    `json:"minutes"` // Optional; defaults to IMPERSONATION_TTL
+}
+
+// StartImpersonationResponse carries the read-only token for the session
+type StartImpersonationResponse struct {
+	Token   string                       `json:"token"`
+	Session *models.ImpersonationSession `json:"session"`
+}
+
+// StartImpersonationHandler opens a time-boxed, read-only session for viewing
+// a user's account as they see it. The session and every request made with
+// its token are audited.
+func StartImpersonationHandler(svc *impersonation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermUsersImpersonate)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req StartImpersonationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		username := mux.Vars(r)["username"]
+		session, err := svc.Start(admin, username, req.Reason, time.Duration(req.Minutes)*time.Minute)
+		if err != nil {
+			writeImpersonationError(w, err)
+			return
+		}
+		token, err := middleware.IssueImpersonationToken(session)
+		if err != nil {
+			log.Printf("Admin: Signing impersonation token for session %s failed: %v", session.ID, err)
+			http.Error(w, "Failed to issue impersonation token", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: %s is viewing as %s until %s: %s", admin.Username, username, session.ExpiresAt.Format(time.RFC3339), session.Reason)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(StartImpersonationResponse{Token: token, Session: session})
+	}
+}
+
+// EndImpersonationHandler ends an impersonation session early
+func EndImpersonationHandler(svc *impersonation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermUsersImpersonate)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		if err := svc.End(mux.Vars(r)["id"], admin.Username); err != nil {
+			writeImpersonationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListImpersonationsHandler returns the most recent impersonation sessions
+func ListImpersonationsHandler(svc *impersonation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if _, httpErr := middleware.RequirePermission(r, db, models.PermUsersImpersonate); httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		sessions, err := svc.List(100)
+		if err != nil {
+			http.Error(w, "Failed to load impersonation sessions", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessions)
+	}
+}
+
+func writeImpersonationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, impersonation.ErrUserNotFound), errors.Is(err, impersonation.ErrSessionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, impersonation.ErrReasonRequired), errors.Is(err, impersonation.ErrInvalidDuration):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, impersonation.ErrAdminTarget):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("Admin: Impersonation request failed: %v", err)
+		http.Error(w, "Impersonation request failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,144 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/moderation"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ModerationRequest represents the request body for a moderation action.
+// Reason is required for close, edit and delete; Message for warnings.
+type ModerationRequest struct {
+	Reason        string  `json:"reason,omitempty"`
+	Message       string  `json:"message,omitempty"`
+	QuestionTitle *string `json:"questionTitle,omitempty"`
+	Description   *string `json:"description,omitempty"`
+}
+
+// ModerationQueueHandler returns reported and wash-trading-flagged markets
+// waiting for review
+func ModerationQueueHandler(svc *moderation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		queue, err := svc.Queue()
+		if err != nil {
+			log.Printf("Admin: Failed to load moderation queue: %v", err)
+			http.Error(w, "Failed to load moderation queue", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"markets": queue,
+		})
+	}
+}
+
+// CloseReportedMarketHandler resolves a market N/A and refunds its bets
+func CloseReportedMarketHandler(svc *moderation.Service) http.HandlerFunc {
+	return moderationHandler(func(admin *models.User, marketID int64, req ModerationRequest) (interface{}, error) {
+		return svc.Close(admin.Username, marketID, req.Reason)
+	})
+}
+
+// EditReportedMarketHandler changes a market's title or description
+func EditReportedMarketHandler(svc *moderation.Service) http.HandlerFunc {
+	return moderationHandler(func(admin *models.User, marketID int64, req ModerationRequest) (interface{}, error) {
+		edit := moderation.MarketEdit{QuestionTitle: req.QuestionTitle, Description: req.Description}
+		return svc.Edit(admin.Username, marketID, edit, req.Reason)
+	})
+}
+
+// DeleteReportedMarketHandler removes a market that has no unsettled bets
+func DeleteReportedMarketHandler(svc *moderation.Service) http.HandlerFunc {
+	return moderationHandler(func(admin *models.User, marketID int64, req ModerationRequest) (interface{}, error) {
+		if err := svc.Delete(admin.Username, marketID, req.Reason); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"marketId": marketID, "deleted": true}, nil
+	})
+}
+
+// WarnMarketCreatorHandler sends a market's creator a moderation warning
+func WarnMarketCreatorHandler(svc *moderation.Service) http.HandlerFunc {
+	return moderationHandler(func(admin *models.User, marketID int64, req ModerationRequest) (interface{}, error) {
+		if err := svc.Warn(admin.Username, marketID, req.Message); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"marketId": marketID, "warned": true}, nil
+	})
+}
+
+// DismissMarketReportsHandler closes a market's open reports without action
+func DismissMarketReportsHandler(svc *moderation.Service) http.HandlerFunc {
+	return moderationHandler(func(admin *models.User, marketID int64, req ModerationRequest) (interface{}, error) {
+		dismissed, err := svc.Dismiss(admin.Username, marketID, req.Reason)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"marketId": marketID, "dismissed": dismissed}, nil
+	})
+}
+
+func moderationHandler(act func(admin *models.User, marketID int64, req ModerationRequest) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req ModerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		result, actErr := act(admin, marketID, req)
+		if actErr != nil {
+			switch {
+			case errors.Is(actErr, moderation.ErrMarketNotFound):
+				http.Error(w, actErr.Error(), http.StatusNotFound)
+			case errors.Is(actErr, moderation.ErrNoteRequired), errors.Is(actErr, moderation.ErrDetailsTooLong),
+				errors.Is(actErr, moderation.ErrNothingToEdit), errors.Is(actErr, moderation.ErrInvalidTitle),
+				errors.Is(actErr, moderation.ErrInvalidDesc), errors.Is(actErr, moderation.ErrMessageRequired):
+				http.Error(w, actErr.Error(), http.StatusBadRequest)
+			case errors.Is(actErr, moderation.ErrAlreadyResolved), errors.Is(actErr, moderation.ErrHasOpenBets),
+				errors.Is(actErr, moderation.ErrNoOpenReports):
+				http.Error(w, actErr.Error(), http.StatusConflict)
+			default:
+				log.Printf("Admin: Moderation of market %d failed: %v", marketID, actErr)
+				http.Error(w, "Failed to moderate market", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Printf("Admin: Market %d moderated by %s (%s %s)", marketID, admin.Username, r.Method, r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,192 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/conditional"
+	"socialpredict/services/oracle"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ConfigureOracleRequest represents the request body for a market's oracle
+type ConfigureOracleRequest struct {
+	Source     string `json:"source"`     // PRICE or SCORE
+	URL        string `json:"url"`        // JSON endpoint read once the market closes
+	Field      string `json:"field"`      // Dot-separated path to the value, e.g. "data.0.price"
+	Comparator string `json:"comparator"` // GT, GTE, LT, LTE or EQ, for PRICE
+	Target     string `json:"target"`     // Threshold for PRICE; the result meaning YES for SCORE
+}
+
+// ReviewOracleRequest represents the request body for approving or rejecting
+// a proposed outcome
+type ReviewOracleRequest struct {
+	Outcome string `json:"outcome,omitempty"` // Approve only: resolve to this instead of the proposal
+	Note    string `json:"note,omitempty"`
+}
+
+// ConfigureOracleHandler sets the oracle that resolves a market
+func ConfigureOracleHandler(svc *oracle.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req ConfigureOracleRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		cfg, configErr := svc.Configure(marketID, oracle.ConfigureInput{
+			Source:     req.Source,
+			URL:        req.URL,
+			Field:      req.Field,
+			Comparator: req.Comparator,
+			Target:     req.Target,
+		}, admin.Username)
+		if configErr != nil {
+			writeOracleError(w, configErr)
+			return
+		}
+
+		log.Printf("Admin: Oracle for market %d configured by %s", marketID, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cfg)
+	}
+}
+
+// GetOracleHandler returns a market's oracle
+func GetOracleHandler(svc *oracle.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		cfg, err := svc.Get(marketID)
+		if err != nil {
+			writeOracleError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cfg)
+	}
+}
+
+// ListOraclesHandler returns market oracles, optionally filtered by ?status=
+func ListOraclesHandler(svc *oracle.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		list, err := svc.List(r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, "Failed to load oracles", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"oracles": list,
+		})
+	}
+}
+
+// ApproveOracleHandler resolves a market to its oracle's proposed outcome, or
+// to the outcome given, without waiting for the review window to end
+func ApproveOracleHandler(svc *oracle.Service) http.HandlerFunc {
+	return reviewOracleHandler(func(id uint, admin string, req ReviewOracleRequest) (*models.MarketOracleConfig, error) {
+		return svc.Approve(id, admin, req.Outcome)
+	})
+}
+
+// RejectOracleHandler discards an oracle's proposed outcome so the market is
+// resolved by hand
+func RejectOracleHandler(svc *oracle.Service) http.HandlerFunc {
+	return reviewOracleHandler(func(id uint, admin string, req ReviewOracleRequest) (*models.MarketOracleConfig, error) {
+		return svc.Reject(id, admin, req.Note)
+	})
+}
+
+func reviewOracleHandler(review func(id uint, admin string, req ReviewOracleRequest) (*models.MarketOracleConfig, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid oracle ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewOracleRequest
+		json.NewDecoder(r.Body).Decode(&req) // Optional, ignore errors
+
+		cfg, reviewErr := review(uint(id), admin.Username, req)
+		if reviewErr != nil {
+			writeOracleError(w, reviewErr)
+			return
+		}
+
+		log.Printf("Admin: Oracle %d for market %d reviewed by %s, status %s", cfg.ID, cfg.MarketID, admin.Username, cfg.Status)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cfg)
+	}
+}
+
+func writeOracleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, oracle.ErrMarketNotFound), errors.Is(err, oracle.ErrOracleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, oracle.ErrInvalidSource), errors.Is(err, oracle.ErrInvalidURL),
+		errors.Is(err, oracle.ErrInvalidField), errors.Is(err, oracle.ErrInvalidCompare),
+		errors.Is(err, oracle.ErrInvalidTarget), errors.Is(err, oracle.ErrInvalidOutcome):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, oracle.ErrMarketResolved), errors.Is(err, oracle.ErrNotProposed),
+		errors.Is(err, oracle.ErrOracleInProgress), errors.Is(err, conditional.ErrConditionPending),
+		errors.Is(err, conditional.ErrConditionNotMet):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Admin: Oracle request failed: %v", err)
+		http.Error(w, "Failed to process oracle request", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package adminhandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/bonus"
+	"socialpredict/services/corrections"
+	"socialpredict/services/housebot"
+	"socialpredict/services/roles"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRestrictedAdminEndpointsRefuseSupportAdmins(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	orig := util.DB
+	util.DB = db
+	t.Cleanup(func() { util.DB = orig })
+
+	support := modelstesting.GenerateUser("support", 0)
+	support.UserType = "ADMIN"
+	if err := db.Create(&support).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	roles.Assign(db, support.ID, models.RoleSupport, "test")
+
+	clk := clock.New()
+	correctionSvc := corrections.NewService(db, corrections.Config{}, clk)
+	houseBot := housebot.NewService(db, housebot.Config{}, nil, clk)
+	handlers := map[string]http.HandlerFunc{
+		"release deposit hold":      ReleaseDepositHoldHandler(clk),
+		"reject deposit hold":       RejectDepositHoldHandler(clk),
+		"release withdrawal hold":   ReleaseWithdrawalHoldHandler,
+		"create correction":         CreateCorrectionHandler(correctionSvc),
+		"approve correction":        ApproveCorrectionHandler(correctionSvc),
+		"reject correction":         RejectCorrectionHandler(correctionSvc),
+		"update withdrawal limits":  UpdateWithdrawalLimitsHandler,
+		"set token withdrawal rule": SetTokenWithdrawalRuleHandler,
+		"update transfer settings":  UpdateTransferSettingsHandler,
+		"update creator fees":       UpdateCreatorFeeSettingsHandler,
+		"update maker-checker":      UpdateMakerCheckerSettingsHandler,
+		"grant bonus":               GrantBonusHandler(bonus.NewService(db, clk)),
+		"void market":               VoidMarketHandler(clk),
+		"set restriction":           SetRestrictionHandler(clk),
+		"lift restriction":          LiftRestrictionHandler,
+		"self-exclusion report":     SelfExclusionReportHandler(selfexclusion.NewService(db, clk)),
+		"set market bet limits":     SetMarketBetLimitsHandler,
+		"set bet limit override":    SetBetLimitOverrideHandler,
+		"remove bet limit override": RemoveBetLimitOverrideHandler,
+		"halt house bot":            HaltHouseBotHandler(houseBot),
+		"fund house bot":            FundHouseBotHandler(houseBot),
+		"set house bot category":    SetHouseBotCategoryHandler(houseBot),
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v0/admin/test", strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("support"))
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "marketId": "1"})
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
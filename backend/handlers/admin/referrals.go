@@ -0,0 +1,112 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/referrals"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ReferralReviewRequest represents the request body for clearing or blocking
+// a referral. Reason is required to block.
+type ReferralReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListReferralsHandler returns referrals for review, optionally filtered with
+// ?status=FLAGGED
+func ListReferralsHandler(svc *referrals.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		list, err := svc.List(r.URL.Query().Get("status"))
+		if err != nil {
+			if errors.Is(err, referrals.ErrInvalidStatus) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Admin: Failed to list referrals: %v", err)
+			http.Error(w, "Failed to list referrals", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"referrals": list,
+		})
+	}
+}
+
+// ClearReferralHandler marks a flagged referral as genuine so it earns rewards
+func ClearReferralHandler(svc *referrals.Service) http.HandlerFunc {
+	return referralReviewHandler(func(admin *models.User, id uint, req ReferralReviewRequest) (*models.Referral, error) {
+		return svc.Clear(id, admin.Username, req.Reason)
+	})
+}
+
+// BlockReferralHandler rules a referral fraudulent and takes back its rewards
+func BlockReferralHandler(svc *referrals.Service) http.HandlerFunc {
+	return referralReviewHandler(func(admin *models.User, id uint, req ReferralReviewRequest) (*models.Referral, error) {
+		return svc.Block(id, admin.Username, req.Reason)
+	})
+}
+
+func referralReviewHandler(act func(admin *models.User, id uint, req ReferralReviewRequest) (*models.Referral, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid referral ID", http.StatusBadRequest)
+			return
+		}
+		var req ReferralReviewRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		referral, actErr := act(admin, uint(id), req)
+		if actErr != nil {
+			switch {
+			case errors.Is(actErr, referrals.ErrReferralNotFound):
+				http.Error(w, actErr.Error(), http.StatusNotFound)
+			case errors.Is(actErr, referrals.ErrAlreadyBlocked):
+				http.Error(w, actErr.Error(), http.StatusConflict)
+			case errors.Is(actErr, referrals.ErrReasonRequired):
+				http.Error(w, actErr.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("Admin: Review of referral %d failed: %v", id, actErr)
+				http.Error(w, "Failed to review referral", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Printf("Admin: Referral %d set to %s by %s", id, referral.Status, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(referral)
+	}
+}
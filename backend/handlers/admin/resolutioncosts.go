@@ -0,0 +1,113 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/resolutioncost"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// RecordResolutionCostRequest represents the request body for attributing a resolution cost
+type RecordResolutionCostRequest struct {
+	Source      string      `json:"source"` // ORACLE, STAFF, FEE or OTHER
+	Amount      json.Number `json:"amount"` // Credits, up to 6 decimal places
+	Description string      `json:"description"`
+}
+
+// RecordResolutionCostHandler attributes an oracle or staff cost to a market
+func RecordResolutionCostHandler(svc *resolutioncost.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+
+		var req RecordResolutionCostRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amount, amountErr := models.ParseCredits(req.Amount.String())
+		if amountErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		cost, recordErr := svc.Record(resolutioncost.RecordInput{
+			MarketID:    marketID,
+			Source:      req.Source,
+			Amount:      amount,
+			Description: req.Description,
+			RecordedBy:  admin.Username,
+		})
+		if recordErr != nil {
+			writeResolutionCostError(w, recordErr)
+			return
+		}
+
+		log.Printf("Admin: %s recorded %s resolution cost of %s credits on market %d",
+			admin.Username, cost.Source, models.FormatMicroCredits(cost.Amount), marketID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(cost)
+	}
+}
+
+// GetMarketProfitabilityHandler reports a market's volume, fees, subsidy and resolution cost
+func GetMarketProfitabilityHandler(svc *resolutioncost.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+
+		report, err := svc.Profitability(marketID)
+		if err != nil {
+			writeResolutionCostError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+func writeResolutionCostError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, resolutioncost.ErrInvalidAmount), errors.Is(err, resolutioncost.ErrInvalidSource):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Market not found", http.StatusNotFound)
+	default:
+		log.Printf("Admin: Resolution cost operation failed: %v", err)
+		http.Error(w, "Failed to process resolution cost", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,117 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/restrictions"
+	"socialpredict/util"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SetRestrictionRequest represents the request body for restricting an account
+type SetRestrictionRequest struct {
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt"` // Omit to restrict until lifted
+}
+
+// ListRestrictionsHandler returns the restrictions in force on a user's account
+func ListRestrictionsHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, ok := overrideUser(w, db, mux.Vars(r)["username"])
+		if !ok {
+			return
+		}
+		active, err := restrictions.Active(db, user.ID, c.Now())
+		if err != nil {
+			http.Error(w, "Failed to load restrictions", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"username": user.Username, "restrictions": active})
+	}
+}
+
+// SetRestrictionHandler freezes withdrawals or trading, or blocks deposits,
+// on a user's account. The change is audited.
+func SetRestrictionHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermUsersRestrict)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		user, ok := overrideUser(w, db, mux.Vars(r)["username"])
+		if !ok {
+			return
+		}
+		var req SetRestrictionRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		kind := mux.Vars(r)["kind"]
+		restriction, setErr := restrictions.Set(db, user.ID, kind, strings.TrimSpace(req.Reason), req.ExpiresAt, admin.Username, c.Now())
+		if setErr != nil {
+			if errors.Is(setErr, restrictions.ErrUnknownKind) || errors.Is(setErr, restrictions.ErrReasonRequired) ||
+				errors.Is(setErr, restrictions.ErrInvalidExpiry) {
+				http.Error(w, setErr.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Admin: Failed to restrict %s for %s: %v", kind, user.Username, setErr)
+			http.Error(w, "Failed to update restriction", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: %s set on %s by %s", kind, user.Username, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(restriction)
+	}
+}
+
+// LiftRestrictionHandler removes a restriction from a user's account before
+// it expires. The change is audited.
+func LiftRestrictionHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermUsersRestrict)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	user, ok := overrideUser(w, db, mux.Vars(r)["username"])
+	if !ok {
+		return
+	}
+	kind := mux.Vars(r)["kind"]
+	if liftErr := restrictions.Lift(db, user.ID, kind, admin.Username); liftErr != nil {
+		if errors.Is(liftErr, restrictions.ErrNotFound) {
+			http.Error(w, liftErr.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Admin: Failed to lift %s for %s: %v", kind, user.Username, liftErr)
+		http.Error(w, "Failed to lift restriction", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: %s lifted on %s by %s", kind, user.Username, admin.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
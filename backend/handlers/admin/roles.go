@@ -0,0 +1,102 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/roles"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+// RoleItem is a role and the admins holding it
+type RoleItem struct {
+	models.Role
+	Members []string `json:"members"`
+}
+
+// SetUserRolesRequest represents the request body for setting an admin's roles
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// ListRolesHandler returns every admin role with its permissions and members
+func ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := roles.List(db)
+	if err != nil {
+		http.Error(w, "Failed to load roles", http.StatusInternalServerError)
+		return
+	}
+	var members []struct {
+		RoleID   uint
+		Username string
+	}
+	if err := db.Table("user_roles").
+		Select("user_roles.role_id, users.username").
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Order("users.username").Scan(&members).Error; err != nil {
+		http.Error(w, "Failed to load roles", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]RoleItem, len(list))
+	for i, role := range list {
+		items[i] = RoleItem{Role: role, Members: []string{}}
+		for _, m := range members {
+			if m.RoleID == role.ID {
+				items[i].Members = append(items[i].Members, m.Username)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"roles": items})
+}
+
+// SetUserRolesHandler replaces an admin's roles. The change is audited.
+func SetUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermRolesManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req SetUserRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	held, err := roles.Set(db, username, req.Roles, admin.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, roles.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, roles.ErrNotAdmin), errors.Is(err, roles.ErrUnknownRole):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, roles.ErrLastSuperAdmin):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Admin: Setting roles for %s failed: %v", username, err)
+			http.Error(w, "Failed to set roles", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Admin: Roles for %s set to %v by %s", username, req.Roles, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"username": username, "roles": held})
+}
@@ -0,0 +1,95 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/saga"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ListSagasHandler lists saga instances with their step history, newest first
+func ListSagasHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	query := db.Model(&models.SagaInstance{})
+	if name := r.URL.Query().Get("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if referenceID, err := strconv.ParseUint(r.URL.Query().Get("referenceId"), 10, 32); err == nil {
+		query = query.Where("reference_id = ?", referenceID)
+	}
+
+	var sagas []models.SagaInstance
+	if err := query.Preload("Steps", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Order("id DESC").Limit(limit).Find(&sagas).Error; err != nil {
+		http.Error(w, "Failed to load sagas", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"sagas": sagas})
+}
+
+// RetrySagaHandler resumes a saga stuck in FAILED or COMPENSATING
+func RetrySagaHandler(flows *saga.Coordinator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid saga ID", http.StatusBadRequest)
+			return
+		}
+
+		inst, retryErr := flows.Retry(uint(id))
+		switch {
+		case errors.Is(retryErr, gorm.ErrRecordNotFound):
+			http.Error(w, "Saga not found", http.StatusNotFound)
+			return
+		case errors.Is(retryErr, saga.ErrNotRetryable):
+			http.Error(w, retryErr.Error(), http.StatusConflict)
+			return
+		case inst == nil:
+			log.Printf("Admin: Retry of saga %d failed: %v", id, retryErr)
+			http.Error(w, "Failed to retry saga", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: Saga %d retried by %s, now %s", inst.ID, admin.Username, inst.Status)
+		resp := map[string]interface{}{"saga": inst}
+		if retryErr != nil {
+			resp["error"] = retryErr.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
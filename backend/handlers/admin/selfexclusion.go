@@ -0,0 +1,31 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/util"
+)
+
+// SelfExclusionReportHandler returns the accounts cooling off or
+// self-excluded now, and how many have a daily deposit limit
+func SelfExclusionReportHandler(svc *selfexclusion.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if _, httpErr := middleware.RequirePermission(r, db, models.PermUsersRestrict); httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		report, err := svc.Report()
+		if err != nil {
+			http.Error(w, "Failed to load self-exclusions", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
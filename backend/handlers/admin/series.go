@@ -0,0 +1,102 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/series"
+	"socialpredict/services/tenants"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// UpdateSeriesRequest represents the request body for pausing or resuming a series
+type UpdateSeriesRequest struct {
+	IsActive *bool `json:"isActive"`
+}
+
+// CreateSeriesHandler starts a recurring market series and opens its first market
+func CreateSeriesHandler(svc *series.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req series.CreateInput
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.TenantID = tenants.FromRequest(r).ID
+
+		created, market, createErr := svc.Create(admin, req)
+		if createErr != nil {
+			switch {
+			case errors.Is(createErr, series.ErrInvalidName), errors.Is(createErr, series.ErrInvalidTemplate),
+				errors.Is(createErr, series.ErrInvalidDescription), errors.Is(createErr, series.ErrInvalidProbability),
+				errors.Is(createErr, series.ErrInvalidSchedule), errors.Is(createErr, series.ErrNoUpcomingClose):
+				http.Error(w, createErr.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("Admin: Creating series failed: %v", createErr)
+				http.Error(w, "Failed to create series", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Printf("Admin: Series %d created by %s, first market %d", created.ID, admin.Username, market.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"series": created,
+			"market": market,
+		})
+	}
+}
+
+// UpdateSeriesHandler pauses or resumes a series
+func UpdateSeriesHandler(svc *series.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+		var req UpdateSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsActive == nil {
+			http.Error(w, "isActive is required", http.StatusBadRequest)
+			return
+		}
+
+		updated, err := svc.SetActive(uint(id), *req.IsActive)
+		if err != nil {
+			if errors.Is(err, series.ErrSeriesNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			log.Printf("Admin: Updating series %d failed: %v", id, err)
+			http.Error(w, "Failed to update series", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
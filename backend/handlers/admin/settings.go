@@ -0,0 +1,403 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/settings"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+// WithdrawalLimitsBody represents withdrawal limits in requests and responses,
+// in credits. The weekly, monthly and global limits may be omitted from an
+// update to keep their current values; 0 disables them.
+type WithdrawalLimitsBody struct {
+	MinWithdrawal json.Number `json:"minWithdrawal"`
+	MaxWithdrawal json.Number `json:"maxWithdrawal"`
+	DailyLimit    json.Number `json:"dailyLimit"`
+	WeeklyLimit   json.Number `json:"weeklyLimit,omitempty"`
+	MonthlyLimit  json.Number `json:"monthlyLimit,omitempty"`
+
+	GlobalDailyLimit   json.Number `json:"globalDailyLimit,omitempty"`
+	GlobalWeeklyLimit  json.Number `json:"globalWeeklyLimit,omitempty"`
+	GlobalMonthlyLimit json.Number `json:"globalMonthlyLimit,omitempty"`
+}
+
+func newWithdrawalLimitsBody(l settings.WithdrawalLimits) WithdrawalLimitsBody {
+	return WithdrawalLimitsBody{
+		MinWithdrawal: json.Number(models.FormatMicroCredits(l.Min)),
+		MaxWithdrawal: json.Number(models.FormatMicroCredits(l.Max)),
+		DailyLimit:    json.Number(models.FormatMicroCredits(l.Daily)),
+		WeeklyLimit:   json.Number(models.FormatMicroCredits(l.Weekly)),
+		MonthlyLimit:  json.Number(models.FormatMicroCredits(l.Monthly)),
+
+		GlobalDailyLimit:   json.Number(models.FormatMicroCredits(l.GlobalDaily)),
+		GlobalWeeklyLimit:  json.Number(models.FormatMicroCredits(l.GlobalWeekly)),
+		GlobalMonthlyLimit: json.Number(models.FormatMicroCredits(l.GlobalMonthly)),
+	}
+}
+
+// GetWithdrawalLimitsHandler returns the current withdrawal limits
+func GetWithdrawalLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limits, err := settings.Shared.WithdrawalLimits(db)
+	if err != nil {
+		http.Error(w, "Failed to load withdrawal limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWithdrawalLimitsBody(limits))
+}
+
+// UpdateWithdrawalLimitsHandler replaces the withdrawal limits. The change is audited.
+func UpdateWithdrawalLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermSettingsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req WithdrawalLimitsBody
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Omitted optional limits keep their current values
+	limits, loadErr := settings.Shared.WithdrawalLimits(db)
+	if loadErr != nil {
+		http.Error(w, "Failed to load withdrawal limits", http.StatusInternalServerError)
+		return
+	}
+	for _, field := range []struct {
+		value    json.Number
+		dest     *int64
+		optional bool
+	}{
+		{req.MinWithdrawal, &limits.Min, false},
+		{req.MaxWithdrawal, &limits.Max, false},
+		{req.DailyLimit, &limits.Daily, false},
+		{req.WeeklyLimit, &limits.Weekly, true},
+		{req.MonthlyLimit, &limits.Monthly, true},
+		{req.GlobalDailyLimit, &limits.GlobalDaily, true},
+		{req.GlobalWeeklyLimit, &limits.GlobalWeekly, true},
+		{req.GlobalMonthlyLimit, &limits.GlobalMonthly, true},
+	} {
+		if field.optional && field.value == "" {
+			continue
+		}
+		parsed, parseErr := models.ParseCredits(field.value.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+		*field.dest = parsed
+	}
+
+	if setErr := settings.Shared.SetWithdrawalLimits(db, limits, admin.Username); setErr != nil {
+		if errors.Is(setErr, settings.ErrInvalidLimits) {
+			http.Error(w, setErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin: Failed to update withdrawal limits: %v", setErr)
+		http.Error(w, "Failed to update withdrawal limits", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Withdrawal limits updated by %s", admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWithdrawalLimitsBody(limits))
+}
+
+// TokenWithdrawalRuleBody is a per-token withdrawal rule in requests, in credits.
+// A minimum or maximum of 0 falls back to the platform limit.
+type TokenWithdrawalRuleBody struct {
+	MinWithdrawal json.Number `json:"minWithdrawal"`
+	MaxWithdrawal json.Number `json:"maxWithdrawal"`
+	Disabled      bool        `json:"disabled"`
+	Note          string      `json:"note"`
+}
+
+// ListTokenWithdrawalRulesHandler returns the per-(chain, token) withdrawal rules
+func ListTokenWithdrawalRulesHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rules, err := settings.TokenWithdrawalRules(db)
+	if err != nil {
+		http.Error(w, "Failed to load token withdrawal rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rules": rules})
+}
+
+// SetTokenWithdrawalRuleHandler creates or replaces the withdrawal rule for a
+// token on a chain. The change is audited.
+func SetTokenWithdrawalRuleHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermSettingsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	vars := mux.Vars(r)
+	chainName, tokenSymbol := vars["chain"], vars["token"]
+	if _, tokenErr := chains.TokenOnChain(db, chainName, tokenSymbol); errors.Is(tokenErr, chains.ErrTokenNotFound) {
+		http.Error(w, "Unknown chain or token", http.StatusBadRequest)
+		return
+	} else if tokenErr != nil {
+		http.Error(w, "Failed to load chain tokens", http.StatusInternalServerError)
+		return
+	}
+
+	var req TokenWithdrawalRuleBody
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule := models.TokenWithdrawalRule{ChainName: chainName, TokenSymbol: tokenSymbol, Disabled: req.Disabled, Note: req.Note}
+	for _, field := range []struct {
+		value json.Number
+		dest  *int64
+	}{
+		{req.MinWithdrawal, &rule.MinWithdrawal},
+		{req.MaxWithdrawal, &rule.MaxWithdrawal},
+	} {
+		if field.value == "" {
+			continue
+		}
+		parsed, parseErr := models.ParseCredits(field.value.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+		*field.dest = parsed
+	}
+
+	saved, setErr := settings.SetTokenWithdrawalRule(db, rule, admin.Username)
+	if setErr != nil {
+		if errors.Is(setErr, settings.ErrInvalidTokenRule) {
+			http.Error(w, setErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin: Failed to update token withdrawal rule %s/%s: %v", chainName, tokenSymbol, setErr)
+		http.Error(w, "Failed to update token withdrawal rule", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Token withdrawal rule %s/%s updated by %s", chainName, tokenSymbol, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// TransferSettingsBody represents the credit transfer settings in requests and
+// responses, with the daily limit in credits
+type TransferSettingsBody struct {
+	Enabled    bool        `json:"enabled"`
+	DailyLimit json.Number `json:"dailyLimit"`
+}
+
+// GetTransferSettingsHandler returns the credit transfer settings
+func GetTransferSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfers, err := settings.Shared.Transfers(db)
+	if err != nil {
+		http.Error(w, "Failed to load transfer settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TransferSettingsBody{
+		Enabled:    transfers.Enabled,
+		DailyLimit: json.Number(models.FormatMicroCredits(transfers.DailyLimit)),
+	})
+}
+
+// UpdateTransferSettingsHandler switches credit transfers on or off and sets
+// the per-user daily limit. The change is audited.
+func UpdateTransferSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermSettingsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req TransferSettingsBody
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// An omitted daily limit keeps the current one
+	transfers, loadErr := settings.Shared.Transfers(db)
+	if loadErr != nil {
+		http.Error(w, "Failed to load transfer settings", http.StatusInternalServerError)
+		return
+	}
+	transfers.Enabled = req.Enabled
+	if req.DailyLimit != "" {
+		parsed, parseErr := models.ParseCredits(req.DailyLimit.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+		transfers.DailyLimit = parsed
+	}
+
+	if setErr := settings.Shared.SetTransfers(db, transfers, admin.Username); setErr != nil {
+		if errors.Is(setErr, settings.ErrInvalidTransferSettings) {
+			http.Error(w, setErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin: Failed to update transfer settings: %v", setErr)
+		http.Error(w, "Failed to update transfer settings", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Transfer settings updated by %s (enabled=%t)", admin.Username, transfers.Enabled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TransferSettingsBody{
+		Enabled:    transfers.Enabled,
+		DailyLimit: json.Number(models.FormatMicroCredits(transfers.DailyLimit)),
+	})
+}
+
+// CreatorFeeSettingsBody represents the creator fee settings in requests and
+// responses, in basis points
+type CreatorFeeSettingsBody struct {
+	FeeBps           int64 `json:"feeBps"`
+	PlatformShareBps int64 `json:"platformShareBps"`
+}
+
+// GetCreatorFeeSettingsHandler returns the creator fee settings
+func GetCreatorFeeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	fees, err := settings.Shared.CreatorFees(db)
+	if err != nil {
+		http.Error(w, "Failed to load creator fee settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CreatorFeeSettingsBody{FeeBps: fees.FeeBps, PlatformShareBps: fees.PlatformShareBps})
+}
+
+// UpdateCreatorFeeSettingsHandler sets the creator fee and how it is split
+// with the platform. New rates apply to trades from then on. The change is
+// audited.
+func UpdateCreatorFeeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermSettingsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req CreatorFeeSettingsBody
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	fees := settings.CreatorFees{FeeBps: req.FeeBps, PlatformShareBps: req.PlatformShareBps}
+
+	if setErr := settings.Shared.SetCreatorFees(db, fees, admin.Username); setErr != nil {
+		if errors.Is(setErr, settings.ErrInvalidCreatorFees) {
+			http.Error(w, setErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin: Failed to update creator fee settings: %v", setErr)
+		http.Error(w, "Failed to update creator fee settings", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Creator fee settings updated by %s (fee=%dbps platform=%dbps)", admin.Username, fees.FeeBps, fees.PlatformShareBps)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// MakerCheckerSettingsBody represents the maker-checker policy in requests and responses
+type MakerCheckerSettingsBody struct {
+	BlockOwnAccount bool `json:"blockOwnAccount"`
+	SecondApproval  bool `json:"secondApproval"`
+}
+
+// GetMakerCheckerSettingsHandler returns the maker-checker policy
+func GetMakerCheckerSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	policy, err := settings.Shared.MakerChecker(db)
+	if err != nil {
+		http.Error(w, "Failed to load maker-checker settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MakerCheckerSettingsBody{BlockOwnAccount: policy.BlockOwnAccount, SecondApproval: policy.SecondApproval})
+}
+
+// UpdateMakerCheckerSettingsHandler sets whether admins may act on their own
+// account and whether every balance correction needs a second admin. The
+// change is audited.
+func UpdateMakerCheckerSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermSettingsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req MakerCheckerSettingsBody
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy := settings.MakerChecker{BlockOwnAccount: req.BlockOwnAccount, SecondApproval: req.SecondApproval}
+
+	if setErr := settings.Shared.SetMakerChecker(db, policy, admin.Username); setErr != nil {
+		log.Printf("Admin: Failed to update maker-checker settings: %v", setErr)
+		http.Error(w, "Failed to update maker-checker settings", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Maker-checker settings updated by %s (blockOwnAccount=%t secondApproval=%t)",
+		admin.Username, policy.BlockOwnAccount, policy.SecondApproval)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
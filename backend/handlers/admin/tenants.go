@@ -0,0 +1,111 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/tenants"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ListTenantsHandler returns every tenant community, active or not
+func ListTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := tenants.List(db)
+	if err != nil {
+		http.Error(w, "Failed to load tenants", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tenants": list})
+}
+
+// CreateTenantHandler adds a branded community served on its own hostnames.
+// The change is audited.
+func CreateTenantHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermTenantsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	var req tenants.Input
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := tenants.Shared.Create(db, req, admin.Username)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Tenant %s added by %s", tenant.Slug, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tenant)
+}
+
+// UpdateTenantHandler replaces a tenant's hostnames, branding, fees and
+// chains, or switches it off. The change is audited.
+func UpdateTenantHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermTenantsManage)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid tenant ID", http.StatusBadRequest)
+		return
+	}
+	var req tenants.Input
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := tenants.Shared.Update(db, uint(id), req, admin.Username)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	log.Printf("Admin: Tenant %s updated by %s", tenant.Slug, admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenant)
+}
+
+func writeTenantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tenants.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, tenants.ErrSlugTaken), errors.Is(err, tenants.ErrHostnameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, tenants.ErrInvalidSlug), errors.Is(err, tenants.ErrInvalidName),
+		errors.Is(err, tenants.ErrInvalidHostname), errors.Is(err, tenants.ErrInvalidBranding),
+		errors.Is(err, tenants.ErrInvalidFee), errors.Is(err, tenants.ErrUnknownChain):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Admin: Tenant change failed: %v", err)
+		http.Error(w, "Failed to change tenant", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,65 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/travelrule"
+	"socialpredict/util"
+)
+
+// TravelRuleExportHandler exports withdrawals that carry a beneficiary
+// attestation or are over the travel-rule threshold, as JSON or, with
+// ?format=csv, as a CSV download. ?from= and ?to= are inclusive
+// YYYY-MM-DD days, defaulting to the last 30 days.
+func TravelRuleExportHandler(policy *travelrule.Policy, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsView)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			http.Error(w, "format must be json or csv", http.StatusBadRequest)
+			return
+		}
+		from, to, err := travelrule.ParseRange(query.Get("from"), query.Get("to"), c.Now().UTC())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		records, err := policy.Export(db, from, to)
+		if err != nil {
+			log.Printf("Admin: Failed to build travel-rule export: %v", err)
+			http.Error(w, "Failed to build travel-rule export", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: Travel-rule export of %d withdrawals (%s to %s) by %s", len(records), from.Format("2006-01-02"), to.Format("2006-01-02"), admin.Username)
+
+		filename := "travel-rule-" + from.Format("20060102") + "-" + to.Format("20060102")
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+			if err := travelrule.WriteCSV(w, records); err != nil {
+				log.Printf("Admin: Failed to write travel-rule CSV: %v", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"from":           from,
+			"to":             to,
+			"thresholdMicro": policy.Threshold(),
+			"withdrawals":    records,
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/treasury"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// treasuryTransferHistory is how many recent treasury transfers are listed
+const treasuryTransferHistory = 200
+
+// AddTreasuryWalletRequest represents the request body for registering a treasury wallet
+type AddTreasuryWalletRequest struct {
+	Name           string      `json:"name"`
+	Kind           string      `json:"kind"` // HOT or COLD
+	ChainName      string      `json:"chainName"`
+	Address        string      `json:"address"`
+	DfnsOrg        string      `json:"dfnsOrg,omitempty"`
+	DfnsWalletID   string      `json:"dfnsWalletId,omitempty"`
+	BalanceCeiling json.Number `json:"balanceCeiling,omitempty"` // Credits; hot wallets only
+}
+
+// UpdateTreasuryWalletRequest represents the request body for updating a treasury wallet
+type UpdateTreasuryWalletRequest struct {
+	BalanceCeiling json.Number `json:"balanceCeiling"` // Credits; 0 disables the alert
+	IsActive       bool        `json:"isActive"`
+}
+
+// MoveToColdRequest represents the request body for a cold-storage transfer
+type MoveToColdRequest struct {
+	FromWalletID uint        `json:"fromWalletId"`
+	ToWalletID   uint        `json:"toWalletId"`
+	TokenSymbol  string      `json:"tokenSymbol"`
+	Amount       json.Number `json:"amount"` // Credits
+	Note         string      `json:"note"`
+}
+
+// TreasuryWalletItem represents a treasury wallet in admin responses
+type TreasuryWalletItem struct {
+	models.TreasuryWallet
+	BalanceCeilingCredits float64 `json:"balanceCeilingCredits"`
+	LastBalanceCredits    float64 `json:"lastBalanceCredits"`
+	AboveCeiling          bool    `json:"aboveCeiling"`
+}
+
+func newTreasuryWalletItem(w *models.TreasuryWallet) TreasuryWalletItem {
+	return TreasuryWalletItem{
+		TreasuryWallet:        *w,
+		BalanceCeilingCredits: models.DisplayCredits(w.BalanceCeiling),
+		LastBalanceCredits:    models.DisplayCredits(w.LastBalance),
+		AboveCeiling:          w.BalanceCeiling > 0 && w.LastBalance > w.BalanceCeiling,
+	}
+}
+
+// TreasuryTransferItem represents a treasury transfer in admin responses
+type TreasuryTransferItem struct {
+	models.TreasuryTransfer
+	AmountCredits float64 `json:"amountCredits"`
+}
+
+// ListTreasuryWalletsHandler returns hot and cold treasury wallets with their last known balances
+func ListTreasuryWalletsHandler(svc *treasury.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		wallets, err := svc.Wallets()
+		if err != nil {
+			http.Error(w, "Failed to load treasury wallets", http.StatusInternalServerError)
+			return
+		}
+		items := make([]TreasuryWalletItem, len(wallets))
+		for i := range wallets {
+			items[i] = newTreasuryWalletItem(&wallets[i])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"wallets": items})
+	}
+}
+
+// AddTreasuryWalletHandler registers a hot or cold treasury wallet. The change is audited.
+func AddTreasuryWalletHandler(svc *treasury.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		var req AddTreasuryWalletRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var ceiling int64
+		if req.BalanceCeiling != "" {
+			parsed, parseErr := models.ParseCredits(req.BalanceCeiling.String())
+			if parseErr != nil {
+				http.Error(w, "Invalid balance ceiling", http.StatusBadRequest)
+				return
+			}
+			ceiling = parsed
+		}
+
+		wallet, addErr := svc.AddWallet(models.TreasuryWallet{
+			Name:           req.Name,
+			Kind:           req.Kind,
+			ChainName:      req.ChainName,
+			Address:        req.Address,
+			DfnsOrg:        req.DfnsOrg,
+			DfnsWalletID:   req.DfnsWalletID,
+			BalanceCeiling: ceiling,
+		}, admin.Username)
+		if addErr != nil {
+			writeTreasuryError(w, addErr)
+			return
+		}
+
+		log.Printf("Admin: %s treasury wallet %d added by %s", wallet.Kind, wallet.ID, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newTreasuryWalletItem(wallet))
+	}
+}
+
+// UpdateTreasuryWalletHandler changes a treasury wallet's ceiling and active flag. The change is audited.
+func UpdateTreasuryWalletHandler(svc *treasury.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+			return
+		}
+		var req UpdateTreasuryWalletRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		ceiling, ceilingErr := models.ParseCredits(req.BalanceCeiling.String())
+		if ceilingErr != nil {
+			http.Error(w, "Invalid balance ceiling", http.StatusBadRequest)
+			return
+		}
+
+		wallet, updateErr := svc.UpdateWallet(uint(id), ceiling, req.IsActive, admin.Username)
+		if updateErr != nil {
+			writeTreasuryError(w, updateErr)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newTreasuryWalletItem(wallet))
+	}
+}
+
+// ListTreasuryTransfersHandler returns recent treasury transfers
+func ListTreasuryTransfersHandler(svc *treasury.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		transfers, err := svc.Transfers(treasuryTransferHistory)
+		if err != nil {
+			http.Error(w, "Failed to load treasury transfers", http.StatusInternalServerError)
+			return
+		}
+		items := make([]TreasuryTransferItem, len(transfers))
+		for i, t := range transfers {
+			items[i] = TreasuryTransferItem{TreasuryTransfer: t, AmountCredits: models.DisplayCredits(t.Amount)}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"transfers": items})
+	}
+}
+
+// MoveToColdStorageHandler starts a transfer from a hot wallet to cold storage
+func MoveToColdStorageHandler(svc *treasury.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req MoveToColdRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amount, parseErr := models.ParseCredits(req.Amount.String())
+		if parseErr != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		transfer, moveErr := svc.MoveToCold(r.Context(), treasury.MoveInput{
+			FromWalletID: req.FromWalletID,
+			ToWalletID:   req.ToWalletID,
+			TokenSymbol:  req.TokenSymbol,
+			Amount:       amount,
+			Note:         req.Note,
+			InitiatedBy:  admin.Username,
+		})
+		if moveErr != nil {
+			writeTreasuryError(w, moveErr)
+			return
+		}
+
+		log.Printf("Admin: Cold storage transfer %d of %s %s initiated by %s",
+			transfer.ID, models.FormatMicroCredits(transfer.Amount), transfer.TokenSymbol, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(TreasuryTransferItem{TreasuryTransfer: *transfer, AmountCredits: models.DisplayCredits(transfer.Amount)})
+	}
+}
+
+func writeTreasuryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, treasury.ErrWalletNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, treasury.ErrInvalidWallet), errors.Is(err, treasury.ErrInvalidTransfer),
+		errors.Is(err, treasury.ErrInvalidAmount), errors.Is(err, treasury.ErrTokenUnavailable):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, treasury.ErrProviderUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, treasury.ErrTransferFailed):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		log.Printf("Admin: Treasury operation failed: %v", err)
+		http.Error(w, "Treasury operation failed", http.StatusInternalServerError)
+	}
+}
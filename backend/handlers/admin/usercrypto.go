@@ -0,0 +1,281 @@
+package adminhandlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/repository"
+	"socialpredict/services/addresslabels"
+	"socialpredict/services/screening"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Thresholds used when flagging a user's crypto activity for review
+const (
+	velocityWindow          = 24 * time.Hour
+	velocityWithdrawalCount = 5
+	rapidCycleWindow        = time.Hour
+)
+
+// Flag codes returned by the user crypto activity view
+const (
+	FlagHighVelocity     = "HIGH_VELOCITY"
+	FlagRapidCycle       = "DEPOSIT_THEN_WITHDRAW"
+	FlagMixerInteraction = "MIXER_INTERACTION"
+)
+
+// UserCryptoWallet represents one of the user's deposit wallets
+type UserCryptoWallet struct {
+	ID        uint      `json:"id"`
+	ChainName string    `json:"chainName"`
+	Address   string    `json:"address"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	DeactivationReason string     `json:"deactivationReason,omitempty"`
+	GraceUntil         *time.Time `json:"graceUntil,omitempty"` // Set on rotated-out wallets
+}
+
+// UserCryptoTransfer represents a deposit or withdrawal in the user view
+type UserCryptoTransfer struct {
+	ID          uint                 `json:"id"`
+	Status      string               `json:"status"`
+	ChainName   string               `json:"chainName"`
+	TokenSymbol string               `json:"tokenSymbol"`
+	Amount      float64              `json:"amount"`
+	AmountMicro int64                `json:"amountMicro"`
+	Address     string               `json:"address"` // Source for deposits, destination for withdrawals
+	Label       *models.AddressLabel `json:"addressLabel,omitempty"`
+	TxHash      string               `json:"txHash,omitempty"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	ProcessedAt *time.Time           `json:"processedAt,omitempty"`
+}
+
+// UserCryptoTotals summarizes a user's crypto flows in micro-credits
+type UserCryptoTotals struct {
+	DepositedMicro         int64   `json:"depositedMicro"`
+	WithdrawnMicro         int64   `json:"withdrawnMicro"`
+	PendingWithdrawalMicro int64   `json:"pendingWithdrawalMicro"`
+	NetFlowMicro           int64   `json:"netFlowMicro"`
+	NetFlow                float64 `json:"netFlow"`
+}
+
+// UserCryptoFlag describes a pattern worth a closer look by support staff
+type UserCryptoFlag struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// UserCryptoActivityResponse is the aggregated crypto view for a single user
+type UserCryptoActivityResponse struct {
+	UserID      int64                `json:"userId"`
+	Username    string               `json:"username"`
+	Balance     float64              `json:"balance"`
+	Wallets     []UserCryptoWallet   `json:"wallets"`
+	Deposits    []UserCryptoTransfer `json:"deposits"`
+	Withdrawals []UserCryptoTransfer `json:"withdrawals"`
+	Totals      UserCryptoTotals     `json:"totals"`
+	Flags       []UserCryptoFlag     `json:"flags"`
+}
+
+// GetUserCryptoActivityHandler returns wallets, deposits, withdrawals, totals and
+// risk flags for a single user so support staff can investigate in one call
+func GetUserCryptoActivityHandler(db *gorm.DB, repos repository.Repos, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Validate admin token
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		response, err := buildUserCryptoActivity(r.Context(), db, repos, userID, c.Now())
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// buildUserCryptoActivity loads and aggregates the crypto activity of a user
+func buildUserCryptoActivity(ctx context.Context, db *gorm.DB, repos repository.Repos, userID int64, now time.Time) (*UserCryptoActivityResponse, error) {
+	user, err := repos.Users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := repos.Wallets.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deposits, err := repos.Transactions.Page(ctx,
+		repository.TransactionFilter{UserID: userID, Type: models.TxTypeDeposit},
+		repository.TransactionPage{})
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := repos.Withdrawals.ListByUser(ctx, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(deposits)+len(withdrawals))
+	for _, dep := range deposits {
+		addresses = append(addresses, dep.FromAddress)
+	}
+	for _, wr := range withdrawals {
+		addresses = append(addresses, wr.ToAddress)
+	}
+	labels, err := addresslabels.Load(db, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &UserCryptoActivityResponse{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Balance:     models.DisplayCredits(user.BalanceMicroCredits()),
+		Wallets:     make([]UserCryptoWallet, len(wallets)),
+		Deposits:    make([]UserCryptoTransfer, len(deposits)),
+		Withdrawals: make([]UserCryptoTransfer, len(withdrawals)),
+	}
+
+	for i, wallet := range wallets {
+		response.Wallets[i] = UserCryptoWallet{
+			ID:        wallet.ID,
+			ChainName: wallet.ChainName,
+			Address:   wallet.Address,
+			IsActive:  wallet.IsActive,
+			CreatedAt: wallet.CreatedAt,
+
+			DeactivationReason: wallet.DeactivationReason,
+			GraceUntil:         wallet.GraceUntil,
+		}
+	}
+
+	for i, dep := range deposits {
+		response.Deposits[i] = UserCryptoTransfer{
+			ID:          dep.ID,
+			Status:      dep.Status,
+			ChainName:   dep.ChainName,
+			TokenSymbol: dep.TokenSymbol,
+			Amount:      models.DisplayCredits(dep.AmountCredits),
+			AmountMicro: dep.AmountCredits,
+			Address:     dep.FromAddress,
+			Label:       labels.Get(dep.ChainName, dep.FromAddress),
+			TxHash:      dep.TxHash,
+			CreatedAt:   dep.CreatedAt,
+			ProcessedAt: dep.ProcessedAt,
+		}
+		if dep.Status == models.TxStatusCompleted {
+			response.Totals.DepositedMicro += dep.AmountCredits
+		}
+	}
+
+	for i, wr := range withdrawals {
+		response.Withdrawals[i] = UserCryptoTransfer{
+			ID:          wr.ID,
+			Status:      wr.Status,
+			ChainName:   wr.ChainName,
+			TokenSymbol: wr.TokenSymbol,
+			Amount:      models.DisplayCredits(wr.Amount),
+			AmountMicro: wr.Amount,
+			Address:     wr.ToAddress,
+			Label:       labels.Get(wr.ChainName, wr.ToAddress),
+			CreatedAt:   wr.CreatedAt,
+			ProcessedAt: wr.ProcessedAt,
+		}
+		switch wr.Status {
+		case models.TxStatusCompleted:
+			response.Totals.WithdrawnMicro += wr.Amount
+		case models.TxStatusPending, models.TxStatusApproving, models.TxStatusUnrecorded, models.TxStatusApproved:
+			response.Totals.PendingWithdrawalMicro += wr.Amount
+		}
+	}
+
+	response.Totals.NetFlowMicro = response.Totals.DepositedMicro - response.Totals.WithdrawnMicro
+	response.Totals.NetFlow = models.DisplayCredits(response.Totals.NetFlowMicro)
+	response.Flags = flagUserCryptoActivity(deposits, withdrawals, labels, now)
+
+	return response, nil
+}
+
+// flagUserCryptoActivity applies simple heuristics to a user's deposit and withdrawal history
+func flagUserCryptoActivity(deposits []models.CryptoTransaction, withdrawals []models.WithdrawalRequest, labels *addresslabels.Labels, now time.Time) []UserCryptoFlag {
+	flags := []UserCryptoFlag{}
+
+	// Velocity: many withdrawal requests in a short window
+	recent := 0
+	for _, wr := range withdrawals {
+		if now.Sub(wr.CreatedAt) <= velocityWindow {
+			recent++
+		}
+	}
+	if recent >= velocityWithdrawalCount {
+		flags = append(flags, UserCryptoFlag{
+			Code:        FlagHighVelocity,
+			Description: strconv.Itoa(recent) + " withdrawal requests in the last 24 hours",
+		})
+	}
+
+	// Deposit immediately followed by a withdrawal
+rapid:
+	for _, wr := range withdrawals {
+		for _, dep := range deposits {
+			gap := wr.CreatedAt.Sub(dep.CreatedAt)
+			if gap >= 0 && gap <= rapidCycleWindow {
+				flags = append(flags, UserCryptoFlag{
+					Code:        FlagRapidCycle,
+					Description: "Withdrawal requested within an hour of a deposit",
+				})
+				break rapid
+			}
+		}
+	}
+
+	// Funds coming from or going to known mixers
+	for _, dep := range deposits {
+		if name, ok := mixerName(labels, dep.ChainName, dep.FromAddress); ok {
+			flags = append(flags, UserCryptoFlag{Code: FlagMixerInteraction, Description: "Deposit received from " + name})
+		}
+	}
+	for _, wr := range withdrawals {
+		if name, ok := mixerName(labels, wr.ChainName, wr.ToAddress); ok {
+			flags = append(flags, UserCryptoFlag{Code: FlagMixerInteraction, Description: "Withdrawal requested to " + name})
+		}
+	}
+
+	return flags
+}
+
+// mixerName names an address on the sanctioned mixer list or labeled as a mixer
+func mixerName(labels *addresslabels.Labels, chainName, address string) (string, bool) {
+	if name, ok := screening.KnownSanctioned[strings.ToLower(address)]; ok {
+		return name, true
+	}
+	if label := labels.Get(chainName, address); label != nil && label.Kind == models.AddressKindMixer {
+		if label.Name == "" {
+			return address, true
+		}
+		return label.Name, true
+	}
+	return "", false
+}
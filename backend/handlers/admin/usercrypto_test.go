@@ -0,0 +1,90 @@
+package adminhandlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/repository"
+)
+
+func TestBuildUserCryptoActivity_TotalsAndFlags(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Date(2026, 4, 2, 12, 0, 0, 0, time.UTC)
+
+	user := modelstesting.GenerateUser("investigated", 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	wallet := models.Wallet{UserID: user.ID, DfnsWalletID: "wa-1", ChainID: 1, ChainName: "ethereum", Address: "0x1111111111111111111111111111111111111111", IsActive: true}
+	if err := db.Create(&wallet).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+
+	deposit := models.CryptoTransaction{
+		UserID:        user.ID,
+		WalletID:      &wallet.ID,
+		Type:          models.TxTypeDeposit,
+		Status:        models.TxStatusCompleted,
+		ChainName:     "ethereum",
+		TokenSymbol:   "USDC",
+		AmountCredits: models.CreditsToMicro(500),
+		FromAddress:   "0x12D66f87A04A9E220743712cE6d9bB1B5616B8Fc",
+		TxHash:        "0xdep",
+	}
+	deposit.CreatedAt = now.Add(-3 * time.Hour)
+	if err := db.Create(&deposit).Error; err != nil {
+		t.Fatalf("create deposit: %v", err)
+	}
+
+	withdrawals := []models.WithdrawalRequest{
+		{UserID: user.ID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC", Amount: models.CreditsToMicro(100), ToAddress: "0xabc", Status: models.TxStatusCompleted},
+		{UserID: user.ID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC", Amount: models.CreditsToMicro(50), ToAddress: "0xabc", Status: models.TxStatusPending},
+	}
+	withdrawals[0].CreatedAt = now.Add(-150 * time.Minute) // 30 minutes after the deposit
+	withdrawals[1].CreatedAt = now.Add(-time.Hour)
+	for i := range withdrawals {
+		if err := db.Create(&withdrawals[i]).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+
+	activity, err := buildUserCryptoActivity(context.Background(), db, repository.NewGormRepos(db), user.ID, now)
+	if err != nil {
+		t.Fatalf("buildUserCryptoActivity: %v", err)
+	}
+
+	if len(activity.Wallets) != 1 || len(activity.Deposits) != 1 || len(activity.Withdrawals) != 2 {
+		t.Fatalf("unexpected counts: %d wallets, %d deposits, %d withdrawals",
+			len(activity.Wallets), len(activity.Deposits), len(activity.Withdrawals))
+	}
+	if activity.Totals.DepositedMicro != models.CreditsToMicro(500) ||
+		activity.Totals.WithdrawnMicro != models.CreditsToMicro(100) ||
+		activity.Totals.PendingWithdrawalMicro != models.CreditsToMicro(50) {
+		t.Fatalf("unexpected totals: %+v", activity.Totals)
+	}
+	if activity.Totals.NetFlow != 400 {
+		t.Fatalf("expected net flow 400, got %v", activity.Totals.NetFlow)
+	}
+
+	codes := map[string]bool{}
+	for _, flag := range activity.Flags {
+		codes[flag.Code] = true
+	}
+	if !codes[FlagRapidCycle] || !codes[FlagMixerInteraction] {
+		t.Fatalf("expected rapid-cycle and mixer flags, got %+v", activity.Flags)
+	}
+	if codes[FlagHighVelocity] {
+		t.Fatalf("did not expect a velocity flag for two withdrawals")
+	}
+}
+
+func TestBuildUserCryptoActivity_UnknownUser(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	if _, err := buildUserCryptoActivity(context.Background(), db, repository.NewGormRepos(db), 999, time.Now()); err == nil {
+		t.Fatal("expected error for unknown user")
+	}
+}
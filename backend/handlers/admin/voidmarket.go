@@ -0,0 +1,109 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/handlers/math/payout"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/conditional"
+	"socialpredict/services/notify"
+	"socialpredict/util"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ActionMarketVoided is the audit action for an admin voiding a market
+const ActionMarketVoided = "MARKET_VOIDED"
+
+// VoidMarketRequest represents the request body for voiding a market
+type VoidMarketRequest struct {
+	Reason string `json:"reason"`
+}
+
+// VoidMarketHandler voids a market that breaks the rules: it resolves N/A,
+// every bettor gets their stake back, creator fees are reversed and open
+// orders are cancelled
+func VoidMarketHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermMarketsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		marketID, parseErr := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req VoidMarketRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			http.Error(w, "A reason is required", http.StatusBadRequest)
+			return
+		}
+
+		var market models.Market
+		voidErr := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&market, marketID).Error; err != nil {
+				return err
+			}
+			if err := payout.Void(tx, &market, admin.Username, req.Reason, c.Now()); err != nil {
+				return err
+			}
+			var creator models.User
+			if err := tx.Where("username = ?", market.CreatorUsername).First(&creator).Error; err != nil {
+				return fmt.Errorf("creator: %w", err)
+			}
+			if err := notify.Send(tx, creator.ID, notify.TypeMarketVoided, "Market voided",
+				fmt.Sprintf("Your market #%d was voided by an admin and its bets were refunded. Reason: %s", market.ID, req.Reason)); err != nil {
+				return err
+			}
+			return audit.Record(tx, models.AuditLog{
+				Actor:      admin.Username,
+				Action:     ActionMarketVoided,
+				TargetType: "market",
+				TargetID:   uint(market.ID),
+				Details:    req.Reason,
+			})
+		})
+		if voidErr != nil {
+			switch {
+			case errors.Is(voidErr, gorm.ErrRecordNotFound):
+				http.Error(w, "Market not found", http.StatusNotFound)
+			case errors.Is(voidErr, payout.ErrAlreadyResolved):
+				http.Error(w, voidErr.Error(), http.StatusConflict)
+			default:
+				log.Printf("Admin: Voiding market %d failed: %v", marketID, voidErr)
+				http.Error(w, "Failed to void market", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Markets conditional on this one resolve N/A as well
+		if voided, err := conditional.Cascade(db, &market, c.Now()); err != nil {
+			log.Printf("Admin: Failed to resolve markets conditional on market %d: %v", market.ID, err)
+		} else if len(voided) > 0 {
+			log.Printf("Admin: Markets %v resolved N/A by their condition", voided)
+		}
+
+		log.Printf("Admin: Market %d voided by %s", market.ID, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(market)
+	}
+}
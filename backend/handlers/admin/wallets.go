@@ -0,0 +1,169 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/walletrotation"
+	"socialpredict/services/walletsync"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// RotateWalletRequest represents the request body for rotating a user wallet
+type RotateWalletRequest struct {
+	Reason string `json:"reason"` // e.g. "DFNS wallet archived", "key compromise"
+}
+
+// RotateWalletHandler deactivates a user's deposit wallet and provisions a
+// replacement on the same chain. Deposits to the old address are credited
+// until its grace period ends. The change is audited.
+func RotateWalletHandler(svc *walletrotation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, err := middleware.ValidateTokenAndGetUser(r, db)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if admin.UserType != "ADMIN" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+			return
+		}
+		var req RotateWalletRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		result, rotateErr := svc.Rotate(r.Context(), uint(id), req.Reason, admin.Username)
+		switch {
+		case rotateErr == nil:
+		case errors.Is(rotateErr, walletrotation.ErrWalletNotFound):
+			http.Error(w, rotateErr.Error(), http.StatusNotFound)
+			return
+		case errors.Is(rotateErr, walletrotation.ErrReasonRequired):
+			http.Error(w, rotateErr.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(rotateErr, walletrotation.ErrWalletInactive):
+			http.Error(w, rotateErr.Error(), http.StatusConflict)
+			return
+		case errors.Is(rotateErr, walletrotation.ErrProvisioningFailed):
+			http.Error(w, rotateErr.Error(), http.StatusBadGateway)
+			return
+		default:
+			log.Printf("Admin: Wallet rotation failed: %v", rotateErr)
+			http.Error(w, "Wallet rotation failed", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: wallet %d rotated to %d by %s", result.Old.ID, result.New.ID, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// ResolveWalletSyncIssueRequest represents the request body for resolving a wallet sync issue
+type ResolveWalletSyncIssueRequest struct {
+	Note string `json:"note"` // What was done, e.g. "archived in DFNS by ops"
+}
+
+// ListWalletSyncIssuesHandler returns the open mismatches between DFNS and
+// the local wallet records, or every issue with ?all=true
+func ListWalletSyncIssuesHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	issues, err := walletsync.Issues(db, r.URL.Query().Get("all") == "true")
+	if err != nil {
+		http.Error(w, "Failed to load wallet sync issues", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"issues": issues})
+}
+
+// RunWalletSyncHandler syncs wallet metadata from DFNS now instead of waiting
+// for the background sync
+func RunWalletSyncHandler(svc *walletsync.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		result, err := svc.Sync(r.Context())
+		if err != nil {
+			log.Printf("Admin: Wallet sync failed: %v", err)
+			http.Error(w, "Wallet sync failed", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: wallet sync run by %s: %d wallets, %d new issues", admin.Username, result.Wallets, result.Raised)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// ResolveWalletSyncIssueHandler marks a wallet sync issue reviewed. The same
+// mismatch is not raised again. The change is audited.
+func ResolveWalletSyncIssueHandler(svc *walletsync.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermChainsManage)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid issue ID", http.StatusBadRequest)
+			return
+		}
+		var req ResolveWalletSyncIssueRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		issue, err := svc.Resolve(uint(id), req.Note, admin.Username)
+		switch {
+		case err == nil:
+		case errors.Is(err, walletsync.ErrIssueNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, walletsync.ErrIssueResolved):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		default:
+			log.Printf("Admin: Resolving wallet sync issue %d failed: %v", id, err)
+			http.Error(w, "Failed to resolve wallet sync issue", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Admin: wallet sync issue %d resolved by %s", issue.ID, admin.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(issue)
+	}
+}
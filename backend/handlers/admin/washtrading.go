@@ -0,0 +1,80 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/washtrading"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetMarketIntegrityHandler rescans a market for wash trading and returns its
+// integrity score along with the flagged trades
+func GetMarketIntegrityHandler(detector *washtrading.Detector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		if err := middleware.ValidateAdminToken(r, db); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		marketID, err := strconv.ParseUint(mux.Vars(r)["marketId"], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+
+		var market models.Market
+		if err := db.First(&market, marketID).Error; err != nil {
+			http.Error(w, "Market not found", http.StatusNotFound)
+			return
+		}
+
+		report, err := detector.ScanMarket(uint(marketID))
+		if err != nil {
+			log.Printf("Admin: Wash trading scan of market %d failed: %v", marketID, err)
+			http.Error(w, "Failed to scan market", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// ListWashTradeFlagsHandler lists stored wash trade flags, newest first,
+// optionally filtered by username (either side) or market
+func ListWashTradeFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	if err := middleware.ValidateAdminToken(r, db); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	query := db.Model(&models.WashTradeFlag{})
+	if username := r.URL.Query().Get("username"); username != "" {
+		query = query.Where("username = ? OR counterparty = ?", username, username)
+	}
+	if marketID, err := strconv.ParseUint(r.URL.Query().Get("marketId"), 10, 32); err == nil {
+		query = query.Where("market_id = ?", marketID)
+	}
+
+	var flags []models.WashTradeFlag
+	if err := query.Order("detected_at DESC, id DESC").Limit(limit).Find(&flags).Error; err != nil {
+		http.Error(w, "Failed to load flags", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"flags": flags})
+}
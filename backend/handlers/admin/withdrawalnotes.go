@@ -0,0 +1,64 @@
+package adminhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/withdrawalnotes"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// WithdrawalNoteRequest represents the request body for adding a reviewer note
+type WithdrawalNoteRequest struct {
+	Note string `json:"note"`
+	Flag bool   `json:"flag,omitempty"` // Flag the request for other reviewers
+}
+
+// AddWithdrawalNoteHandler appends a reviewer note to a withdrawal request's
+// thread. Anyone who can view withdrawals can leave one; the note is audited.
+func AddWithdrawalNoteHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsView)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.StatusCode)
+		return
+	}
+
+	withdrawalID, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if parseErr != nil {
+		http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
+		return
+	}
+
+	var req WithdrawalNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	note, err := withdrawalnotes.Append(db, uint(withdrawalID), admin, req.Note, req.Flag)
+	switch {
+	case errors.Is(err, withdrawalnotes.ErrInvalidNote):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, withdrawalnotes.ErrNotFound):
+		http.Error(w, "Withdrawal request not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Admin: Failed to add note to withdrawal %d: %v", withdrawalID, err)
+		http.Error(w, "Failed to add note", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin: Note added to withdrawal %d by %s (flag=%t)", withdrawalID, admin.Username, req.Flag)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
@@ -2,17 +2,27 @@ package adminhandlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"socialpredict/clock"
 	"socialpredict/middleware"
 	"socialpredict/models"
-	"socialpredict/services/dfns"
-	"socialpredict/util"
+	"socialpredict/repository"
+	"socialpredict/services/addresslabels"
+	"socialpredict/services/explorer"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/saga"
+	"socialpredict/services/settings"
+	"socialpredict/services/withdrawalflow"
+	"socialpredict/services/withdrawalnotes"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 // WithdrawalRequestItem represents a withdrawal request in the admin list
@@ -22,100 +32,167 @@ type WithdrawalRequestItem struct {
 	Username    string     `json:"username"`
 	ChainName   string     `json:"chainName"`
 	TokenSymbol string     `json:"tokenSymbol"`
-	Amount      int64      `json:"amount"`
+	Amount      float64    `json:"amount"`      // Credits, rounded for display
+	AmountMicro int64      `json:"amountMicro"` // Exact amount in micro-credits
 	ToAddress   string     `json:"toAddress"`
 	Status      string     `json:"status"`
 	CreatedAt   time.Time  `json:"createdAt"`
 	ProcessedAt *time.Time `json:"processedAt,omitempty"`
-	AdminNote   string     `json:"adminNote,omitempty"`
+	Notes       int        `json:"notes"`   // Reviewer notes on the request
+	Flagged     bool       `json:"flagged"` // A note flags the request for review
+	RiskScore   int        `json:"riskScore"`
+	RiskReasons []string   `json:"riskReasons"`
+	Country     string     `json:"country,omitempty"`
+	NewCountry  bool       `json:"newCountry"`
+
+	OriginalAmount   float64 `json:"originalAmount,omitempty"` // Requested credits, when approved for less
+	AdjustmentReason string  `json:"adjustmentReason,omitempty"`
+
+	TxHash               string `json:"txHash,omitempty"`
+	ExplorerURL          string `json:"explorerUrl,omitempty"`          // Explorer link for the payout transaction, once sent
+	ToAddressExplorerURL string `json:"toAddressExplorerUrl,omitempty"` // Explorer link for the destination address
+
+	ToAddressLabel *models.AddressLabel `json:"toAddressLabel,omitempty"` // Who is behind the destination, if known
 }
 
-// ListWithdrawalRequestsHandler returns all withdrawal requests for admin review
-func ListWithdrawalRequestsHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
+// ListWithdrawalRequestsHandler returns all withdrawal requests for admin review.
+// Supports ?status=, ?minRisk= to filter by risk score and ?sort=risk to list the riskiest first.
+// ?country= filters by requesting country and ?newCountry=true keeps only
+// withdrawals from a country the user had not withdrawn from before.
+func ListWithdrawalRequestsHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsView); httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+		ctx := r.Context()
 
-	// Validate admin token
-	if err := middleware.ValidateAdminToken(r, db); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+		// Parse query params
+		pageStr := r.URL.Query().Get("page")
+		limitStr := r.URL.Query().Get("limit")
 
-	// Parse query params
-	status := r.URL.Query().Get("status")
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
+		page := 1
+		limit := 50
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
 
-	page := 1
-	limit := 50
-	if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-		page = p
-	}
-	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-		limit = l
-	}
-	offset := (page - 1) * limit
+		filter := repository.WithdrawalFilter{
+			Status:     r.URL.Query().Get("status"),
+			Country:    strings.ToUpper(r.URL.Query().Get("country")),
+			SortByRisk: r.URL.Query().Get("sort") == "risk",
+			Offset:     (page - 1) * limit,
+			Limit:      limit,
+		}
+		if minRisk, err := strconv.Atoi(r.URL.Query().Get("minRisk")); err == nil {
+			filter.MinRisk = &minRisk
+		}
+		if newCountry, err := strconv.ParseBool(r.URL.Query().Get("newCountry")); err == nil && newCountry {
+			filter.NewCountryOnly = true
+		}
 
-	// Build query
-	query := db.Model(&models.WithdrawalRequest{})
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
+		requests, total, err := repos.Withdrawals.List(ctx, filter)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawals", http.StatusInternalServerError)
+			return
+		}
 
-	var total int64
-	query.Count(&total)
-
-	var requests []models.WithdrawalRequest
-	query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&requests)
-
-	// Build response with user info
-	items := make([]WithdrawalRequestItem, len(requests))
-	for i, req := range requests {
-		// Get username
-		var user models.User
-		db.Select("username").First(&user, req.UserID)
-
-		items[i] = WithdrawalRequestItem{
-			ID:          req.ID,
-			UserID:      req.UserID,
-			Username:    user.Username,
-			ChainName:   req.ChainName,
-			TokenSymbol: req.TokenSymbol,
-			Amount:      req.Amount,
-			ToAddress:   req.ToAddress,
-			Status:      req.Status,
-			CreatedAt:   req.CreatedAt,
-			ProcessedAt: req.ProcessedAt,
-			AdminNote:   req.AdminNote,
+		// Payout tx hashes for explorer links, loaded in one query
+		txIDs := make([]uint, 0, len(requests))
+		for _, req := range requests {
+			if req.TransactionID != nil {
+				txIDs = append(txIDs, *req.TransactionID)
+			}
+		}
+		txHashes, err := repos.Transactions.HashesByID(ctx, txIDs)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawals", http.StatusInternalServerError)
+			return
+		}
+		links, _ := explorer.Load(db)
+		destinations := make([]string, len(requests))
+		for i, req := range requests {
+			destinations[i] = req.ToAddress
+		}
+		labels, _ := addresslabels.Load(db, destinations)
+		ids := make([]uint, len(requests))
+		for i, req := range requests {
+			ids[i] = req.ID
+		}
+		notes, err := withdrawalnotes.Summarize(db, ids)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawals", http.StatusInternalServerError)
+			return
 		}
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"withdrawals": items,
-		"total":       total,
-		"page":        page,
-		"limit":       limit,
-	})
+		items := make([]WithdrawalRequestItem, len(requests))
+		for i, req := range requests {
+			var txHash string
+			if req.TransactionID != nil {
+				txHash = txHashes[*req.TransactionID]
+			}
+
+			items[i] = WithdrawalRequestItem{
+				ID:          req.ID,
+				UserID:      req.UserID,
+				Username:    req.Username,
+				ChainName:   req.ChainName,
+				TokenSymbol: req.TokenSymbol,
+				Amount:      models.DisplayCredits(req.Amount),
+				AmountMicro: req.Amount,
+				ToAddress:   req.ToAddress,
+				Status:      req.Status,
+				CreatedAt:   req.CreatedAt,
+				ProcessedAt: req.ProcessedAt,
+				Notes:       notes[req.ID].Count,
+				Flagged:     notes[req.ID].Flagged,
+				RiskScore:   req.RiskScore,
+				RiskReasons: req.RiskReasonList(),
+				Country:     req.Country,
+				NewCountry:  req.NewCountry,
+
+				OriginalAmount:   models.DisplayCredits(req.OriginalAmount),
+				AdjustmentReason: req.AdjustmentReason,
+
+				TxHash:               txHash,
+				ExplorerURL:          links.Tx(req.ChainName, txHash),
+				ToAddressExplorerURL: links.Address(req.ChainName, req.ToAddress),
+
+				ToAddressLabel: labels.Get(req.ChainName, req.ToAddress),
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"withdrawals": items,
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+		})
+	}
 }
 
 // ApproveWithdrawalRequest represents the request body for approving a withdrawal
 type ApproveWithdrawalRequest struct {
 	Note string `json:"note,omitempty"` // Optional admin note
+
+	// Approve less than was requested, e.g. after a clawback. The difference
+	// is refunded to the user; AdjustmentReason is required with Amount.
+	Amount           json.Number `json:"amount,omitempty"` // Credits, up to 6 decimal places
+	AdjustmentReason string      `json:"adjustmentReason,omitempty"`
 }
 
-// ApproveWithdrawalHandler approves a withdrawal request and initiates the DFNS transfer
-func ApproveWithdrawalHandler(dfnsClient *dfns.Client) http.HandlerFunc {
+// ApproveWithdrawalHandler approves a withdrawal request by starting its
+// withdrawal saga, which initiates the DFNS transfer. With an amount the
+// request is approved for that much and the rest is refunded.
+func ApproveWithdrawalHandler(db *gorm.DB, repos repository.Repos, flows *saga.Coordinator, c clock.Clock) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		db := util.GetDB()
-
-		// Validate admin token and get admin user
-		admin, err := middleware.ValidateTokenAndGetUser(r, db)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		if admin.UserType != "ADMIN" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsApprove)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
 			return
 		}
 
@@ -133,8 +210,8 @@ func ApproveWithdrawalHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 		json.NewDecoder(r.Body).Decode(&req) // Optional, ignore errors
 
 		// Find the withdrawal request
-		var withdrawalReq models.WithdrawalRequest
-		if dbErr := db.First(&withdrawalReq, withdrawalID).Error; dbErr != nil {
+		withdrawalReq, repoErr := repos.Withdrawals.Get(r.Context(), uint(withdrawalID))
+		if repoErr != nil {
 			http.Error(w, "Withdrawal request not found", http.StatusNotFound)
 			return
 		}
@@ -145,96 +222,109 @@ func ApproveWithdrawalHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 			return
 		}
 
-		// Find the user's wallet for this chain
-		var wallet models.Wallet
-		if err := db.Where("user_id = ? AND chain_id = ? AND is_active = ?",
-			withdrawalReq.UserID, withdrawalReq.ChainID, true).First(&wallet).Error; err != nil {
-			http.Error(w, "User wallet not found for this chain", http.StatusBadRequest)
+		if !checkNotOwnAccount(w, db, admin, withdrawalReq.UserID) {
 			return
 		}
 
-		// Get chain info for token contract address
-		var chain models.SupportedChain
-		if err := db.Where("chain_id = ?", withdrawalReq.ChainID).First(&chain).Error; err != nil {
-			http.Error(w, "Chain configuration not found", http.StatusInternalServerError)
+		// Approvals would start transfers, so they stop during an emergency freeze
+		// and while the user's withdrawals are frozen
+		if freezeErr := settings.CheckWithdrawalsOpen(db); freezeErr != nil {
+			writeWithdrawalFlowError(w, freezeErr)
 			return
 		}
-
-		// Determine token contract address
-		var tokenContract string
-		switch withdrawalReq.TokenSymbol {
-		case "USDC":
-			tokenContract = chain.USDCAddress
-		case "USDT":
-			tokenContract = chain.USDTAddress
-		default:
-			http.Error(w, "Unsupported token", http.StatusBadRequest)
+		if freezeErr := restrictions.Check(db, withdrawalReq.UserID, models.RestrictionWithdrawalsFrozen, c.Now()); freezeErr != nil {
+			writeWithdrawalFlowError(w, freezeErr)
 			return
 		}
 
-		if tokenContract == "" {
-			http.Error(w, "Token not available on this chain", http.StatusBadRequest)
+		var flow *models.SagaInstance
+		var flowErr error
+		if req.Amount != "" {
+			approvedMicro, ok := parseAdjustment(w, db, withdrawalReq, req)
+			if !ok {
+				return
+			}
+			flow, flowErr = withdrawalflow.ApproveAdjusted(flows, withdrawalReq.ID, admin.ID, req.Note, approvedMicro, strings.TrimSpace(req.AdjustmentReason))
+		} else {
+			flow, flowErr = withdrawalflow.Approve(flows, withdrawalReq.ID, admin.ID, req.Note)
+		}
+		if flowErr != nil {
+			writeWithdrawalFlowError(w, flowErr)
 			return
 		}
 
-		// Convert credits to token amount
-		decimals := dfns.GetTokenDecimals(withdrawalReq.TokenSymbol)
-		tokenAmount := dfns.CreditsToTokenAmount(withdrawalReq.Amount, decimals)
-
-		// Initiate transfer via DFNS
-		transferReq := dfns.TransferRequest{
-			Kind:     dfns.TransferKindErc20,
-			To:       withdrawalReq.ToAddress,
-			Contract: tokenContract,
-			Amount:   tokenAmount,
+		if approved, repoErr := repos.Withdrawals.Get(r.Context(), withdrawalReq.ID); repoErr == nil {
+			withdrawalReq = approved
 		}
+		transactionID, _ := strconv.ParseUint(flow.Data[withdrawalflow.DataTransactionID], 10, 32)
 
-		dfnsTransfer, transferErr := dfnsClient.InitiateTransfer(wallet.DfnsWalletID, transferReq)
-		if transferErr != nil {
-			log.Printf("Admin: Failed to initiate DFNS transfer for withdrawal %d: %v", withdrawalReq.ID, transferErr)
-			http.Error(w, "Failed to initiate blockchain transfer", http.StatusInternalServerError)
-			return
+		log.Printf("Admin: Approved withdrawal %d by admin %s, DFNS transfer ID: %s",
+			withdrawalReq.ID, admin.Username, flow.Data[withdrawalflow.DataDfnsTransferID])
+
+		response := map[string]interface{}{
+			"message":        "Withdrawal approved and transfer initiated",
+			"withdrawalId":   withdrawalReq.ID,
+			"amount":         models.DisplayCredits(withdrawalReq.Amount),
+			"transactionId":  uint(transactionID),
+			"dfnsTransferId": flow.Data[withdrawalflow.DataDfnsTransferID],
+			"sagaId":         flow.ID,
+			"status":         withdrawalReq.Status,
 		}
-
-		// Create crypto transaction record
-		now := time.Now()
-		cryptoTx := models.CryptoTransaction{
-			UserID:        withdrawalReq.UserID,
-			WalletID:      &wallet.ID,
-			Type:          models.TxTypeWithdrawal,
-			Status:        models.TxStatusApproved,
-			ChainID:       withdrawalReq.ChainID,
-			ChainName:     withdrawalReq.ChainName,
-			TokenSymbol:   withdrawalReq.TokenSymbol,
-			TokenAddress:  tokenContract,
-			Amount:        tokenAmount,
-			AmountCredits: withdrawalReq.Amount,
-			ToAddress:     withdrawalReq.ToAddress,
-			DfnsTxID:      dfnsTransfer.ID,
+		if withdrawalReq.OriginalAmount > 0 {
+			response["originalAmount"] = models.DisplayCredits(withdrawalReq.OriginalAmount)
+			response["refundedAmount"] = models.DisplayCredits(withdrawalReq.OriginalAmount - withdrawalReq.Amount)
 		}
 
-		db.Create(&cryptoTx)
-
-		// Update withdrawal request
-		withdrawalReq.Status = models.TxStatusApproved
-		withdrawalReq.TransactionID = &cryptoTx.ID
-		withdrawalReq.AdminID = &admin.ID
-		withdrawalReq.AdminNote = req.Note
-		withdrawalReq.ProcessedAt = &now
-
-		db.Save(&withdrawalReq)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
 
-		log.Printf("Admin: Approved withdrawal %d by admin %s, DFNS transfer ID: %s",
-			withdrawalReq.ID, admin.Username, dfnsTransfer.ID)
+// parseAdjustment checks a reduced approval amount: positive, less than the
+// request and not below the token's minimum withdrawal, with a reason
+func parseAdjustment(w http.ResponseWriter, db *gorm.DB, withdrawalReq *models.WithdrawalRequest, req ApproveWithdrawalRequest) (int64, bool) {
+	approvedMicro, err := models.ParseCredits(req.Amount.String())
+	if err != nil || approvedMicro <= 0 || approvedMicro >= withdrawalReq.Amount {
+		http.Error(w, withdrawalflow.ErrInvalidAdjustment.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	if strings.TrimSpace(req.AdjustmentReason) == "" {
+		http.Error(w, "An adjustment reason is required when approving a reduced amount", http.StatusBadRequest)
+		return 0, false
+	}
+	if limits, err := settings.Shared.TokenWithdrawalLimits(db, withdrawalReq.ChainName, withdrawalReq.TokenSymbol); err == nil && approvedMicro < limits.Min {
+		http.Error(w, fmt.Sprintf("Approved amount is below the %s minimum of %s credits", withdrawalReq.TokenSymbol, models.FormatMicroCredits(limits.Min)), http.StatusBadRequest)
+		return 0, false
+	}
+	return approvedMicro, true
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message":       "Withdrawal approved and transfer initiated",
-			"withdrawalId":  withdrawalReq.ID,
-			"transactionId": cryptoTx.ID,
-			"dfnsTransferId": dfnsTransfer.ID,
-			"status":        withdrawalReq.Status,
-		})
+// writeWithdrawalFlowError maps withdrawal saga errors to HTTP responses
+func writeWithdrawalFlowError(w http.ResponseWriter, err error) {
+	var restrictedErr *restrictions.RestrictedError
+	switch {
+	case errors.Is(err, saga.ErrInDoubt):
+		// DFNS took the transfer; the request is TRANSFER_UNRECORDED until reconciled
+		http.Error(w, "Transfer initiated but not recorded; it will be reconciled", http.StatusAccepted)
+	case errors.Is(err, saga.ErrAlreadyActive):
+		http.Error(w, "Withdrawal is already being processed", http.StatusConflict)
+	case errors.Is(err, settings.ErrWithdrawalsFrozen):
+		http.Error(w, "Withdrawals are frozen", http.StatusServiceUnavailable)
+	case errors.As(err, &restrictedErr):
+		http.Error(w, "The user's withdrawals are frozen: "+restrictedErr.Restriction.Reason, http.StatusConflict)
+	case errors.Is(err, withdrawalflow.ErrNotApprovable), errors.Is(err, withdrawalflow.ErrInvalidAdjustment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, withdrawalflow.ErrWalletNotFound):
+		http.Error(w, "User wallet not found for this chain", http.StatusBadRequest)
+	case errors.Is(err, withdrawalflow.ErrTokenUnavailable):
+		http.Error(w, "Token not available on this chain", http.StatusBadRequest)
+	case errors.Is(err, withdrawalflow.ErrProviderUnavailable):
+		http.Error(w, "Wallet provider unavailable", http.StatusServiceUnavailable)
+	case errors.Is(err, withdrawalflow.ErrTransferFailed):
+		http.Error(w, "Failed to initiate blockchain transfer", http.StatusInternalServerError)
+	default:
+		log.Printf("Admin: Withdrawal saga failed: %v", err)
+		http.Error(w, "Failed to approve withdrawal", http.StatusInternalServerError)
 	}
 }
 
@@ -244,214 +334,212 @@ type RejectWithdrawalRequest struct {
 }
 
 // RejectWithdrawalHandler rejects a withdrawal request and refunds the user
-func RejectWithdrawalHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
-
-	// Validate admin token and get admin user
-	admin, err := middleware.ValidateTokenAndGetUser(r, db)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	if admin.UserType != "ADMIN" {
-		http.Error(w, "Admin access required", http.StatusForbidden)
-		return
-	}
-
-	// Get withdrawal ID from URL
-	vars := mux.Vars(r)
-	withdrawalIDStr := vars["id"]
-	withdrawalID, parseErr := strconv.ParseUint(withdrawalIDStr, 10, 32)
-	if parseErr != nil {
-		http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
-		return
-	}
+func RejectWithdrawalHandler(db *gorm.DB, repos repository.Repos, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsApprove)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
 
-	// Parse request body
-	var req RejectWithdrawalRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
+		// Get withdrawal ID from URL
+		vars := mux.Vars(r)
+		withdrawalIDStr := vars["id"]
+		withdrawalID, parseErr := strconv.ParseUint(withdrawalIDStr, 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
+			return
+		}
 
-	if req.Reason == "" {
-		http.Error(w, "Rejection reason is required", http.StatusBadRequest)
-		return
-	}
+		// Parse request body
+		var req RejectWithdrawalRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
 
-	// Find the withdrawal request
-	var withdrawalReq models.WithdrawalRequest
-	if err := db.First(&withdrawalReq, withdrawalID).Error; err != nil {
-		http.Error(w, "Withdrawal request not found", http.StatusNotFound)
-		return
-	}
+		if req.Reason == "" {
+			http.Error(w, "Rejection reason is required", http.StatusBadRequest)
+			return
+		}
 
-	// Check if can be rejected
-	if !withdrawalReq.CanBeRejected() {
-		http.Error(w, fmt.Sprintf("Cannot reject withdrawal in status: %s", withdrawalReq.Status), http.StatusBadRequest)
-		return
-	}
+		// Find the withdrawal request
+		withdrawalReq, err := repos.Withdrawals.Get(r.Context(), uint(withdrawalID))
+		if err != nil {
+			http.Error(w, "Withdrawal request not found", http.StatusNotFound)
+			return
+		}
 
-	// Start transaction for atomic refund
-	tx := db.Begin()
+		// Check if can be rejected
+		if !withdrawalReq.CanBeRejected() {
+			http.Error(w, fmt.Sprintf("Cannot reject withdrawal in status: %s", withdrawalReq.Status), http.StatusBadRequest)
+			return
+		}
 
-	// Refund the user's balance
-	var user models.User
-	if err := tx.First(&user, withdrawalReq.UserID).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "User not found", http.StatusInternalServerError)
-		return
-	}
+		// Refund and reject atomically; the repository re-checks the status
+		// under a lock so a racing approval or rejection cannot refund twice
+		rejected, user, txErr := repos.Withdrawals.Reject(r.Context(), withdrawalReq.ID, repository.Rejection{
+			AdminID: admin.ID, AdminName: admin.Username, Reason: req.Reason, At: c.Now(),
+		})
+		if errors.Is(txErr, repository.ErrWithdrawalStatusChanged) {
+			http.Error(w, fmt.Sprintf("Cannot reject withdrawal in status: %s", rejected.Status), http.StatusConflict)
+			return
+		}
+		if txErr != nil {
+			log.Printf("Admin: Failed to reject withdrawal %d: %v", withdrawalReq.ID, txErr)
+			http.Error(w, "Failed to reject withdrawal", http.StatusInternalServerError)
+			return
+		}
 
-	user.AccountBalance += withdrawalReq.Amount
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to refund user balance", http.StatusInternalServerError)
-		return
-	}
+		log.Printf("Admin: Rejected withdrawal %d by admin %s, reason: %s, refunded %s credits to user %s",
+			rejected.ID, admin.Username, req.Reason, models.FormatMicroCredits(rejected.Amount), user.Username)
 
-	// Update withdrawal request
-	now := time.Now()
-	withdrawalReq.Status = models.TxStatusRejected
-	withdrawalReq.AdminID = &admin.ID
-	withdrawalReq.AdminNote = req.Reason
-	withdrawalReq.ErrorMessage = req.Reason
-	withdrawalReq.ProcessedAt = &now
-
-	if err := tx.Save(&withdrawalReq).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to update withdrawal request", http.StatusInternalServerError)
-		return
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":        "Withdrawal rejected and credits refunded",
+			"withdrawalId":   rejected.ID,
+			"refundedAmount": models.DisplayCredits(rejected.Amount),
+			"status":         rejected.Status,
+		})
 	}
-
-	tx.Commit()
-
-	log.Printf("Admin: Rejected withdrawal %d by admin %s, reason: %s, refunded %d credits to user %s",
-		withdrawalReq.ID, admin.Username, req.Reason, withdrawalReq.Amount, user.Username)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":        "Withdrawal rejected and credits refunded",
-		"withdrawalId":   withdrawalReq.ID,
-		"refundedAmount": withdrawalReq.Amount,
-		"status":         withdrawalReq.Status,
-	})
 }
 
 // GetWithdrawalDetailsHandler returns details for a specific withdrawal request
-func GetWithdrawalDetailsHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
+func GetWithdrawalDetailsHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsView); httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+		ctx := r.Context()
 
-	// Validate admin token
-	if err := middleware.ValidateAdminToken(r, db); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+		// Get withdrawal ID from URL
+		vars := mux.Vars(r)
+		withdrawalIDStr := vars["id"]
+		withdrawalID, err := strconv.ParseUint(withdrawalIDStr, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
+			return
+		}
 
-	// Get withdrawal ID from URL
-	vars := mux.Vars(r)
-	withdrawalIDStr := vars["id"]
-	withdrawalID, err := strconv.ParseUint(withdrawalIDStr, 10, 32)
-	if err != nil {
-		http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
-		return
-	}
+		// Find the withdrawal request
+		withdrawalReq, err := repos.Withdrawals.Get(ctx, uint(withdrawalID))
+		if err != nil {
+			http.Error(w, "Withdrawal request not found", http.StatusNotFound)
+			return
+		}
 
-	// Find the withdrawal request
-	var withdrawalReq models.WithdrawalRequest
-	if err := db.First(&withdrawalReq, withdrawalID).Error; err != nil {
-		http.Error(w, "Withdrawal request not found", http.StatusNotFound)
-		return
-	}
+		// Get user info
+		user := &models.User{}
+		if found, err := repos.Users.Get(ctx, withdrawalReq.UserID); err == nil {
+			user = found
+		}
 
-	// Get user info
-	var user models.User
-	db.Select("username, account_balance").First(&user, withdrawalReq.UserID)
+		// Get associated transaction if exists
+		var cryptoTx *models.CryptoTransaction
+		if withdrawalReq.TransactionID != nil {
+			cryptoTx, _ = repos.Transactions.Get(ctx, *withdrawalReq.TransactionID)
+		}
 
-	// Get associated transaction if exists
-	var cryptoTx *models.CryptoTransaction
-	if withdrawalReq.TransactionID != nil {
-		var tx models.CryptoTransaction
-		if err := db.First(&tx, *withdrawalReq.TransactionID).Error; err == nil {
-			cryptoTx = &tx
+		notes, err := withdrawalnotes.List(db, withdrawalReq.ID)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawal notes", http.StatusInternalServerError)
+			return
 		}
-	}
 
-	response := map[string]interface{}{
-		"withdrawal": map[string]interface{}{
-			"id":          withdrawalReq.ID,
-			"userId":      withdrawalReq.UserID,
-			"username":    user.Username,
-			"chainName":   withdrawalReq.ChainName,
-			"tokenSymbol": withdrawalReq.TokenSymbol,
-			"amount":      withdrawalReq.Amount,
-			"toAddress":   withdrawalReq.ToAddress,
-			"status":      withdrawalReq.Status,
-			"createdAt":   withdrawalReq.CreatedAt,
-			"processedAt": withdrawalReq.ProcessedAt,
-			"adminNote":   withdrawalReq.AdminNote,
-			"error":       withdrawalReq.ErrorMessage,
-		},
-		"user": map[string]interface{}{
-			"currentBalance": user.AccountBalance,
-		},
-	}
+		links, _ := explorer.Load(db)
+		toLabel, _ := addresslabels.Lookup(db, withdrawalReq.ChainName, withdrawalReq.ToAddress)
+		response := map[string]interface{}{
+			"withdrawal": map[string]interface{}{
+				"id":          withdrawalReq.ID,
+				"userId":      withdrawalReq.UserID,
+				"username":    user.Username,
+				"chainName":   withdrawalReq.ChainName,
+				"tokenSymbol": withdrawalReq.TokenSymbol,
+				"amount":      models.DisplayCredits(withdrawalReq.Amount),
+				"amountMicro": withdrawalReq.Amount,
+				"toAddress":   withdrawalReq.ToAddress,
+
+				"originalAmount":      models.DisplayCredits(withdrawalReq.OriginalAmount),
+				"originalAmountMicro": withdrawalReq.OriginalAmount,
+				"adjustmentReason":    withdrawalReq.AdjustmentReason,
+
+				"status":      withdrawalReq.Status,
+				"createdAt":   withdrawalReq.CreatedAt,
+				"processedAt": withdrawalReq.ProcessedAt,
+				"error":       withdrawalReq.ErrorMessage,
+
+				"requestIp":  withdrawalReq.RequestIP,
+				"userAgent":  withdrawalReq.UserAgent,
+				"country":    withdrawalReq.Country,
+				"region":     withdrawalReq.Region,
+				"newCountry": withdrawalReq.NewCountry,
+
+				"beneficiaryType":       withdrawalReq.BeneficiaryType,
+				"beneficiaryName":       withdrawalReq.BeneficiaryName,
+				"beneficiaryExchange":   withdrawalReq.BeneficiaryExchange,
+				"beneficiaryAttestedAt": withdrawalReq.BeneficiaryAttestedAt,
+
+				"toAddressExplorerUrl": links.Address(withdrawalReq.ChainName, withdrawalReq.ToAddress),
+				"toAddressLabel":       toLabel,
+			},
+			"user": map[string]interface{}{
+				"currentBalance": models.DisplayCredits(user.BalanceMicroCredits()),
+			},
+			"notes": notes,
+		}
 
-	if cryptoTx != nil {
-		response["transaction"] = map[string]interface{}{
-			"id":       cryptoTx.ID,
-			"txHash":   cryptoTx.TxHash,
-			"dfnsTxId": cryptoTx.DfnsTxID,
-			"status":   cryptoTx.Status,
+		if cryptoTx != nil {
+			response["transaction"] = map[string]interface{}{
+				"id":          cryptoTx.ID,
+				"txHash":      cryptoTx.TxHash,
+				"dfnsTxId":    cryptoTx.DfnsTxID,
+				"status":      cryptoTx.Status,
+				"explorerUrl": links.Tx(cryptoTx.ChainName, cryptoTx.TxHash),
+			}
 		}
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
 }
 
 // GetWithdrawalStatsHandler returns withdrawal statistics for admin dashboard
-func GetWithdrawalStatsHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
+func GetWithdrawalStatsHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, httpErr := middleware.RequirePermission(r, db, models.PermWithdrawalsView); httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
 
-	// Validate admin token
-	if err := middleware.ValidateAdminToken(r, db); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+		totals, err := repos.Withdrawals.TotalsByStatus(r.Context())
+		if err != nil {
+			http.Error(w, "Failed to load withdrawal stats", http.StatusInternalServerError)
+			return
+		}
 
-	var pendingCount, approvedCount, completedCount, rejectedCount, failedCount int64
-	var totalPendingAmount, totalCompletedAmount int64
-
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusPending).Count(&pendingCount)
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusApproved).Count(&approvedCount)
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusCompleted).Count(&completedCount)
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusRejected).Count(&rejectedCount)
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusFailed).Count(&failedCount)
-
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusPending).
-		Select("COALESCE(SUM(amount), 0)").Scan(&totalPendingAmount)
-	db.Model(&models.WithdrawalRequest{}).Where("status = ?", models.TxStatusCompleted).
-		Select("COALESCE(SUM(amount), 0)").Scan(&totalCompletedAmount)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"pending": map[string]interface{}{
-			"count":  pendingCount,
-			"amount": totalPendingAmount,
-		},
-		"approved": map[string]interface{}{
-			"count": approvedCount,
-		},
-		"completed": map[string]interface{}{
-			"count":  completedCount,
-			"amount": totalCompletedAmount,
-		},
-		"rejected": map[string]interface{}{
-			"count": rejectedCount,
-		},
-		"failed": map[string]interface{}{
-			"count": failedCount,
-		},
-	})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pending": map[string]interface{}{
+				"count":  totals[models.TxStatusPending].Count,
+				"amount": models.DisplayCredits(totals[models.TxStatusPending].Amount),
+			},
+			"approved": map[string]interface{}{
+				"count": totals[models.TxStatusApproved].Count,
+			},
+			"unrecorded": map[string]interface{}{
+				"count": totals[models.TxStatusUnrecorded].Count,
+			},
+			"completed": map[string]interface{}{
+				"count":  totals[models.TxStatusCompleted].Count,
+				"amount": models.DisplayCredits(totals[models.TxStatusCompleted].Amount),
+			},
+			"rejected": map[string]interface{}{
+				"count": totals[models.TxStatusRejected].Count,
+			},
+			"failed": map[string]interface{}{
+				"count": totals[models.TxStatusFailed].Count,
+			},
+		})
+	}
 }
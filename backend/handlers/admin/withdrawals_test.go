@@ -0,0 +1,233 @@
+package adminhandlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/repository"
+	"socialpredict/services/roles"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+// stubWithdrawals serves fixed withdrawals and records the filter it was given
+type stubWithdrawals struct {
+	repository.WithdrawalRepo
+	requests []repository.WithdrawalListing
+	filter   repository.WithdrawalFilter
+}
+
+func (s *stubWithdrawals) List(_ context.Context, filter repository.WithdrawalFilter) ([]repository.WithdrawalListing, int64, error) {
+	s.filter = filter
+	return s.requests, int64(len(s.requests)), nil
+}
+
+type stubTransactions struct {
+	repository.TransactionRepo
+	hashes map[uint]string
+}
+
+func (s stubTransactions) HashesByID(context.Context, []uint) (map[uint]string, error) {
+	return s.hashes, nil
+}
+
+func TestListWithdrawalRequestsHandler_UsesRepos(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("admin", 0)
+	admin.UserType = "ADMIN"
+	db.Create(&admin)
+	roles.Assign(db, admin.ID, models.RoleSupport, "test")
+
+	txID := uint(9)
+	withdrawals := &stubWithdrawals{requests: []repository.WithdrawalListing{
+		{WithdrawalRequest: models.WithdrawalRequest{ID: 1, UserID: 7, ChainName: "base", TokenSymbol: "USDC", Amount: models.CreditsToMicro(25), Status: models.TxStatusCompleted, TransactionID: &txID}, Username: "alice"},
+		{WithdrawalRequest: models.WithdrawalRequest{ID: 2, UserID: 8, ChainName: "base", TokenSymbol: "USDC", Amount: models.CreditsToMicro(5), Status: models.TxStatusPending}},
+	}}
+	repos := repository.Repos{
+		Withdrawals:  withdrawals,
+		Transactions: stubTransactions{hashes: map[uint]string{txID: "0xpaid"}},
+	}
+
+	req := httptest.NewRequest("GET", "/v0/admin/withdrawals?status=PENDING&country=de&minRisk=40&sort=risk&page=2&limit=10", nil)
+	req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("admin"))
+	rec := httptest.NewRecorder()
+	ListWithdrawalRequestsHandler(db, repos)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	got := withdrawals.filter
+	if got.Status != "PENDING" || got.Country != "DE" || got.MinRisk == nil || *got.MinRisk != 40 ||
+		!got.SortByRisk || got.Offset != 10 || got.Limit != 10 {
+		t.Fatalf("filter = %+v", got)
+	}
+
+	var resp struct {
+		Withdrawals []WithdrawalRequestItem `json:"withdrawals"`
+		Total       int64                   `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 2 || len(resp.Withdrawals) != 2 {
+		t.Fatalf("response = %+v", resp)
+	}
+	// A deleted user leaves the username blank rather than failing the page
+	if resp.Withdrawals[0].Username != "alice" || resp.Withdrawals[0].TxHash != "0xpaid" || resp.Withdrawals[1].Username != "" {
+		t.Fatalf("items = %+v", resp.Withdrawals)
+	}
+}
+
+func TestGetWithdrawalStatsHandler_RepoError(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("admin", 0)
+	admin.UserType = "ADMIN"
+	db.Create(&admin)
+	roles.Assign(db, admin.ID, models.RoleSupport, "test")
+
+	req := httptest.NewRequest("GET", "/v0/admin/withdrawals/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("admin"))
+	rec := httptest.NewRecorder()
+	GetWithdrawalStatsHandler(db, repository.Repos{Withdrawals: failingWithdrawals{}})(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+}
+
+type failingWithdrawals struct {
+	repository.WithdrawalRepo
+}
+
+func (failingWithdrawals) TotalsByStatus(context.Context) (map[string]repository.StatusTotal, error) {
+	return nil, errors.New("database is down")
+}
+
+func TestRejectWithdrawalHandler_RequiresApprovePermission(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	orig := util.DB
+	util.DB = db
+	t.Cleanup(func() { util.DB = orig })
+
+	admin := modelstesting.GenerateUser("support", 0)
+	admin.UserType = "ADMIN"
+	db.Create(&admin)
+	roles.Assign(db, admin.ID, models.RoleSupport, "test")
+
+	reject := func() int {
+		req := httptest.NewRequest("POST", "/v0/admin/withdrawals/1/reject", strings.NewReader(`{"reason":"fraud"}`))
+		req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("support"))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rec := httptest.NewRecorder()
+		RejectWithdrawalHandler(db, repository.NewGormRepos(db), clock.New())(rec, req)
+		return rec.Code
+	}
+
+	if code := reject(); code != http.StatusForbidden {
+		t.Fatalf("support reject status = %d, want 403", code)
+	}
+	roles.Assign(db, admin.ID, models.RoleFinance, "test")
+	if code := reject(); code != http.StatusNotFound {
+		t.Fatalf("finance reject status = %d, want 404 for the missing withdrawal", code)
+	}
+}
+
+// stubReviewedWithdrawal serves one withdrawal for review and records how it
+// was rejected
+type stubReviewedWithdrawal struct {
+	repository.WithdrawalRepo
+	req       models.WithdrawalRequest
+	rejectErr error
+	rejection *repository.Rejection
+}
+
+func (s *stubReviewedWithdrawal) Get(_ context.Context, id uint) (*models.WithdrawalRequest, error) {
+	if id != s.req.ID {
+		return nil, errors.New("record not found")
+	}
+	req := s.req
+	return &req, nil
+}
+
+func (s *stubReviewedWithdrawal) Reject(_ context.Context, _ uint, rejection repository.Rejection) (*models.WithdrawalRequest, *models.User, error) {
+	s.rejection = &rejection
+	req := s.req
+	if s.rejectErr != nil {
+		return &req, nil, s.rejectErr
+	}
+	req.Status = models.TxStatusRejected
+	return &req, &models.User{ID: req.UserID, PublicUser: models.PublicUser{Username: "alice"}}, nil
+}
+
+func TestReviewWithdrawalHandlers_UseRepos(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("finance", 0)
+	admin.UserType = "ADMIN"
+	db.Create(&admin)
+	roles.Assign(db, admin.ID, models.RoleFinance, "test")
+
+	pending := models.WithdrawalRequest{ID: 1, UserID: 7, ChainName: "base", TokenSymbol: "USDC", Amount: models.CreditsToMicro(25), Status: models.TxStatusPending}
+	serve := func(h http.HandlerFunc, action, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v0/admin/withdrawals/"+id+"/"+action, strings.NewReader(`{"reason":"fraud"}`))
+		req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("finance"))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		withdrawals := &stubReviewedWithdrawal{req: pending}
+		rec := serve(RejectWithdrawalHandler(db, repository.Repos{Withdrawals: withdrawals}, clock.New()), "reject", "1")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+		if r := withdrawals.rejection; r == nil || r.AdminID != admin.ID || r.AdminName != "finance" || r.Reason != "fraud" {
+			t.Fatalf("rejection = %+v", withdrawals.rejection)
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp["status"] != models.TxStatusRejected || resp["refundedAmount"] != 25.0 {
+			t.Fatalf("response = %v", resp)
+		}
+	})
+
+	t.Run("reject after the status changed", func(t *testing.T) {
+		withdrawals := &stubReviewedWithdrawal{req: pending, rejectErr: repository.ErrWithdrawalStatusChanged}
+		rec := serve(RejectWithdrawalHandler(db, repository.Repos{Withdrawals: withdrawals}, clock.New()), "reject", "1")
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("status = %d, want 409", rec.Code)
+		}
+	})
+
+	t.Run("reject unknown withdrawal", func(t *testing.T) {
+		withdrawals := &stubReviewedWithdrawal{req: pending}
+		rec := serve(RejectWithdrawalHandler(db, repository.Repos{Withdrawals: withdrawals}, clock.New()), "reject", "2")
+		if rec.Code != http.StatusNotFound || withdrawals.rejection != nil {
+			t.Fatalf("status = %d, rejection = %+v", rec.Code, withdrawals.rejection)
+		}
+	})
+
+	t.Run("approve completed withdrawal", func(t *testing.T) {
+		completed := pending
+		completed.Status = models.TxStatusCompleted
+		withdrawals := &stubReviewedWithdrawal{req: completed}
+		rec := serve(ApproveWithdrawalHandler(db, repository.Repos{Withdrawals: withdrawals}, nil, clock.New()), "approve", "1")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", rec.Code)
+		}
+	})
+}
@@ -2,7 +2,11 @@ package betutils
 
 import (
 	"errors"
+	"log"
 	"socialpredict/models"
+	"socialpredict/services/groups"
+	"socialpredict/services/pricehistory"
+	"socialpredict/services/stream"
 	"time"
 
 	"gorm.io/gorm"
@@ -23,9 +27,51 @@ func CheckMarketStatus(db *gorm.DB, marketID uint) error {
 		return errors.New("cannot place a bet on a resolved market")
 	}
 
-	if time.Now().After(market.ResolutionDateTime) {
-		return errors.New("cannot place a bet on a closed market")
+	if market.ClosedAt != nil || time.Now().After(market.ResolutionDateTime) {
+		return ErrMarketClosed
 	}
 
 	return nil
 }
+
+// CheckMarketAccess returns groups.ErrNotMember if the market is group-only
+// and the user is not in its group
+func CheckMarketAccess(db *gorm.DB, marketID uint, userID int64) error {
+	var market models.Market
+	if err := db.Select("id", "visibility", "group_id").First(&market, marketID).Error; err != nil {
+		return errors.New("error fetching market")
+	}
+	return groups.CheckCanBet(db, &market, userID)
+}
+
+// ErrMarketClosed is returned for a bet on a market that has stopped taking bets
+var ErrMarketClosed = errors.New("cannot place a bet on a closed market")
+
+// LockOpenMarket claims the market's row for the transaction, failing with
+// ErrMarketClosed unless the market is still open. The close scheduler marks
+// a market closed with a conditional update on the same row, so a bet either
+// commits before its market closes or is rejected.
+func LockOpenMarket(tx *gorm.DB, marketID uint) error {
+	result := tx.Model(&models.Market{}).
+		Where("id = ? AND is_resolved = ? AND closed_at IS NULL AND resolution_date_time > ?", marketID, false, time.Now()).
+		UpdateColumn("updated_at", gorm.Expr("updated_at"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMarketClosed
+	}
+	return nil
+}
+
+// PublishTrade records the price point a bet or sale moved its market to and
+// streams the trade to the market's subscribers. It must only be called once
+// the transaction that placed the bet has committed, so nothing is published
+// for a trade that is rolled back.
+func PublishTrade(db *gorm.DB, bet models.Bet) {
+	points, err := pricehistory.Record(db, bet)
+	if err != nil {
+		log.Printf("PublishTrade: failed to record price point for bet %d: %v", bet.ID, err)
+	}
+	stream.Default.PublishTrade(bet, points)
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/models"
+	"socialpredict/services/tenants"
 	"socialpredict/setup"
 
 	"gorm.io/gorm"
@@ -25,7 +26,7 @@ func GetBetFees(db *gorm.DB, user *models.User, betRequest models.Bet) int64 {
 	MarketID := betRequest.MarketID
 
 	initialBetFee := getUserInitialBetFee(db, MarketID, user)
-	transactionFee := getTransactionFee(betRequest)
+	transactionFee := getTransactionFee(db, betRequest)
 
 	sumOfBetFees := initialBetFee + transactionFee
 
@@ -47,19 +48,30 @@ func getUserInitialBetFee(db *gorm.DB, marketID uint, user *models.User) int64 {
 	}
 
 	// This is the user's first bet on this market, apply the initial bet fee
-	return appConfig.Economics.Betting.BetFees.InitialBetFee
+	return marketFees(db, marketID).InitialBetFee
 }
 
-func getTransactionFee(betRequest models.Bet) int64 {
+func getTransactionFee(db *gorm.DB, betRequest models.Bet) int64 {
 
 	var transactionFee int64
 
+	fees := marketFees(db, betRequest.MarketID)
 	// if amount > 0, buying share, else selling share
 	if betRequest.Amount > 0 {
-		transactionFee = appConfig.Economics.Betting.BetFees.BuySharesFee
+		transactionFee = fees.BuySharesFee
 	} else {
-		transactionFee = appConfig.Economics.Betting.BetFees.SellSharesFee
+		transactionFee = fees.SellSharesFee
 	}
 
 	return transactionFee
 }
+
+// marketFees returns the fee schedule of the community the market belongs to,
+// or the platform's if the market's community cannot be loaded
+func marketFees(db *gorm.DB, marketID uint) setup.BetFees {
+	tenant, err := tenants.Shared.ForMarket(db, marketID)
+	if err != nil {
+		return appConfig.Economics.Betting.BetFees
+	}
+	return tenants.BetFees(tenant, appConfig.Economics.Betting.BetFees)
+}
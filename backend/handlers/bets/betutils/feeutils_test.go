@@ -61,17 +61,18 @@ func TestGetUserInitialBetFee(t *testing.T) {
 func TestGetTransactionFee(t *testing.T) {
 	// Mock the appConfig with test data
 	appConfig = setuptesting.MockEconomicConfig()
+	db := modelstesting.NewFakeDB(t)
 
 	// Test buy scenario
 	buyBet := models.Bet{Amount: 100}
-	transactionFee := getTransactionFee(buyBet)
+	transactionFee := getTransactionFee(db, buyBet)
 	if transactionFee != appConfig.Economics.Betting.BetFees.BuySharesFee {
 		t.Errorf("Expected buy transaction fee to be %d, got %d", appConfig.Economics.Betting.BetFees.BuySharesFee, transactionFee)
 	}
 
 	// Test sell scenario
 	sellBet := models.Bet{Amount: -100}
-	transactionFee = getTransactionFee(sellBet)
+	transactionFee = getTransactionFee(db, sellBet)
 	if transactionFee != appConfig.Economics.Betting.BetFees.SellSharesFee {
 		t.Errorf("Expected sell transaction fee to be %d, got %d", appConfig.Economics.Betting.BetFees.SellSharesFee, transactionFee)
 	}
@@ -26,6 +26,15 @@ func ValidateBuy(db *gorm.DB, bet *models.Bet) error {
 		return errors.New("Buy amount must be greater than or equal to 1")
 	}
 
+	// A categorical market's bets name one of its outcomes
+	if market.IsCategorical() {
+		var outcome models.MarketOutcome
+		if err := db.First(&outcome, "market_id = ? AND label = ?", market.ID, bet.Outcome).Error; err != nil {
+			return errors.New("bet outcome must be one of the market's outcomes")
+		}
+		return nil
+	}
+
 	// Validate bet outcome: it should be either 'YES' or 'NO'
 	if bet.Outcome != "YES" && bet.Outcome != "NO" {
 		return errors.New("bet outcome must be 'YES' or 'NO'")
@@ -48,6 +57,11 @@ func ValidateSale(db *gorm.DB, bet *models.Bet) error {
 		return errors.New("invalid or closed market")
 	}
 
+	// Positions in categorical markets are held to resolution
+	if market.IsCategorical() {
+		return errors.New("shares in categorical markets cannot be sold")
+	}
+
 	// Check for valid amount: it should be less than or equal to -1
 	if bet.Amount > -1 {
 		return errors.New("Sale amount must be greater than or equal to 1")
@@ -128,3 +128,28 @@ func TestValidateSale(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateBuyCategorical(t *testing.T) {
+
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("testuser", 0)
+	market := models.Market{
+		ID:          1,
+		OutcomeType: models.OutcomeTypeCategorical,
+	}
+	db.Create(&user)
+	db.Create(&market)
+	db.Create(&models.MarketOutcome{MarketID: 1, Label: "Alice", Position: 0})
+	db.Create(&models.MarketOutcome{MarketID: 1, Label: "Bob", Position: 1})
+
+	if err := ValidateBuy(db, &models.Bet{Username: "testuser", MarketID: 1, Amount: 10, Outcome: "Bob"}); err != nil {
+		t.Errorf("bet on a market outcome: %v", err)
+	}
+	if err := ValidateBuy(db, &models.Bet{Username: "testuser", MarketID: 1, Amount: 10, Outcome: "YES"}); err == nil {
+		t.Error("expected error for YES on a categorical market")
+	}
+	if err := ValidateSale(db, &models.Bet{Username: "testuser", MarketID: 1, Amount: -10, Outcome: "Bob"}); err == nil {
+		t.Error("expected error selling in a categorical market")
+	}
+}
@@ -0,0 +1,79 @@
+package buybetshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/tenants"
+	"socialpredict/setup"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+// APIBetRequest places a bet on the market in the URL
+type APIBetRequest struct {
+	Amount  int64  `json:"amount"`
+	Outcome string `json:"outcome"`
+}
+
+// APIPlaceBetHandler handles POST /v0/api/markets/{marketId}/bets for bots.
+// With an Idempotency-Key header a retried request returns the bet the first
+// one placed, with 200 and an Idempotent-Replayed header, instead of placing
+// it again.
+func APIPlaceBetHandler(loadEconConfig setup.EconConfigLoader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		marketID, err := strconv.ParseUint(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		if ok, err := tenants.MarketInTenant(db, uint(marketID), tenants.FromRequest(r)); err != nil || !ok {
+			http.Error(w, "Market not found", http.StatusNotFound)
+			return
+		}
+
+		var req APIBetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		betRequest := models.Bet{MarketID: uint(marketID), Amount: req.Amount, Outcome: req.Outcome}
+
+		var bet *models.Bet
+		replayed := false
+		if key, ok := r.Header[IdempotencyKeyHeader]; ok && len(key) > 0 {
+			bet, replayed, err = PlaceBetIdempotent(user, betRequest, key[0], db, loadEconConfig)
+		} else {
+			bet, err = PlaceBetCore(user, betRequest, db, loadEconConfig)
+		}
+		if err != nil {
+			if errors.Is(err, ErrIdempotencyKeyReused) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(bet)
+	}
+}
@@ -5,14 +5,26 @@ import (
 	"fmt"
 	"net/http"
 	betutils "socialpredict/handlers/bets/betutils"
+	"socialpredict/handlers/math/accuracy"
+	"socialpredict/handlers/tradingdata"
 	"socialpredict/middleware"
 	"socialpredict/models"
+	"socialpredict/services/betlimits"
+	"socialpredict/services/creatorfees"
+	"socialpredict/services/ledger"
+	"socialpredict/services/referrals"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/services/tenants"
 	"socialpredict/setup"
 	"socialpredict/util"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const referenceTypeBet = "bet"
+
 func PlaceBetHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		db := util.GetDB()
@@ -28,6 +40,10 @@ func PlaceBetHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWr
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if ok, err := tenants.MarketInTenant(db, betRequest.MarketID, tenants.FromRequest(r)); err != nil || !ok {
+			http.Error(w, "Market not found", http.StatusNotFound)
+			return
+		}
 
 		bet, err := PlaceBetCore(user, betRequest, db, loadEconConfig)
 		if err != nil {
@@ -43,16 +59,43 @@ func PlaceBetHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWr
 
 // PlaceBetCore handles the core logic of placing a bet.
 // It assumes user authentication and JSON decoding is already done.
+// The trade is published once the bet is committed, so db must not be a
+// transaction; callers placing the bet inside their own use PlaceBetInTx.
 func PlaceBetCore(user *models.User, betRequest models.Bet, db *gorm.DB, loadEconConfig setup.EconConfigLoader) (*models.Bet, error) {
+	bet, err := PlaceBetInTx(user, betRequest, db, loadEconConfig)
+	if err != nil {
+		return nil, err
+	}
+	betutils.PublishTrade(db, *bet)
+	return bet, nil
+}
+
+// PlaceBetInTx places a bet like PlaceBetCore but does not publish the trade.
+// The caller calls betutils.PublishTrade once its transaction has committed.
+func PlaceBetInTx(user *models.User, betRequest models.Bet, db *gorm.DB, loadEconConfig setup.EconConfigLoader) (*models.Bet, error) {
 	// Validate the request (check if market exists, if not closed/resolved, etc.)
 	if err := betutils.CheckMarketStatus(db, betRequest.MarketID); err != nil {
 		return nil, err
 	}
+	if err := betutils.CheckMarketAccess(db, betRequest.MarketID, user.ID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := restrictions.Check(db, user.ID, models.RestrictionTradingFrozen, now); err != nil {
+		return nil, err
+	}
+	if err := selfexclusion.Check(db, user.ID, now); err != nil {
+		return nil, err
+	}
 
 	sumOfBetFees := betutils.GetBetFees(db, user, betRequest)
+	creatorFee, err := creatorfees.Quote(db, betRequest.MarketID, user.Username, betRequest.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute creator fee: %w", err)
+	}
 
 	// Check if the user's balance after the bet would be lower than the allowed maximum debt
-	if err := checkUserBalance(user, betRequest, sumOfBetFees, loadEconConfig); err != nil {
+	if err := checkUserBalance(user, betRequest, sumOfBetFees, creatorFee.Fee, loadEconConfig); err != nil {
 		return nil, err
 	}
 
@@ -64,30 +107,81 @@ func PlaceBetCore(user *models.User, betRequest models.Bet, db *gorm.DB, loadEco
 		return nil, err
 	}
 
-	// Deduct bet amount and fee from user balance
-	totalCost := bet.Amount + sumOfBetFees
-	user.AccountBalance -= totalCost
+	// Record the probability the bet implies, for accuracy tracking
+	bet.Probability = impliedProbability(db, bet)
 
-	// Save updated user balance
-	if err := db.Save(user).Error; err != nil {
-		return nil, fmt.Errorf("failed to update user balance: %w", err)
-	}
+	// Bet amount and fees to deduct from the user's balance
+	totalCost := models.CreditsToMicro(bet.Amount+sumOfBetFees) + creatorFee.Fee
 
-	// Save the Bet
-	if err := db.Create(&bet).Error; err != nil {
-		return nil, fmt.Errorf("failed to create bet: %w", err)
+	// Save the balance and the bet only while the market is still open
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := betutils.LockOpenMarket(tx, bet.MarketID); err != nil {
+			return err
+		}
+		// The balance is checked again against the user's locked row, so
+		// concurrent bets cannot each spend the same credits
+		locked, err := ledger.LockUser(tx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+		if err := checkUserBalance(locked, betRequest, sumOfBetFees, creatorFee.Fee, loadEconConfig); err != nil {
+			return err
+		}
+		// Checked under the market lock, so two bets cannot each fit the caps alone
+		if err := betlimits.Check(tx, locked, int64(bet.MarketID), bet.Amount); err != nil {
+			return err
+		}
+		if err := tx.Create(&bet).Error; err != nil {
+			return fmt.Errorf("failed to create bet: %w", err)
+		}
+		marketID := int64(bet.MarketID)
+		if _, err := ledger.Apply(tx, user, ledger.Posting{
+			Type:          models.LedgerTypeBet,
+			Amount:        -totalCost,
+			ReferenceType: referenceTypeBet,
+			ReferenceID:   bet.ID,
+			MarketID:      &marketID,
+			Description:   fmt.Sprintf("Bet %d credits on %s in market %d", bet.Amount, bet.Outcome, bet.MarketID),
+			SpendBonus:    true,
+		}); err != nil {
+			return fmt.Errorf("failed to update user balance: %w", err)
+		}
+		if err := creatorfees.Accrue(tx, bet.MarketID, creatorFee, now); err != nil {
+			return err
+		}
+		return referrals.RewardBetFees(tx, user.ID, models.CreditsToMicro(sumOfBetFees), referrals.LoadConfigFromEnv(), now)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &bet, nil
 }
 
-func checkUserBalance(user *models.User, betRequest models.Bet, sumOfBetFees int64, loadEconConfig setup.EconConfigLoader) error {
+func checkUserBalance(user *models.User, betRequest models.Bet, sumOfBetFees, creatorFee int64, loadEconConfig setup.EconConfigLoader) error {
 	appConfig := loadEconConfig()
 	maximumDebtAllowed := appConfig.Economics.User.MaximumDebtAllowed
 
 	// Check if the user's balance after the bet would be lower than the allowed maximum debt
-	if user.AccountBalance-betRequest.Amount-sumOfBetFees < -maximumDebtAllowed {
+	// Provisional allowances from unconfirmed deposits count towards betting only
+	// The creator fee is in micro-credits, so the check is too
+	remaining := user.BettingBalance() - models.CreditsToMicro(betRequest.Amount+sumOfBetFees) - creatorFee
+	if remaining < -models.CreditsToMicro(maximumDebtAllowed) {
 		return fmt.Errorf("Insufficient balance")
 	}
 	return nil
 }
+
+// impliedProbability returns the market's probability for the bet's outcome
+// once the bet is placed, or zero if the market cannot be loaded
+func impliedProbability(db *gorm.DB, bet models.Bet) float64 {
+	var market models.Market
+	if err := db.First(&market, bet.MarketID).Error; err != nil {
+		return 0
+	}
+	var labels []string
+	for _, outcome := range tradingdata.GetOutcomesForMarket(db, market.ID) {
+		labels = append(labels, outcome.Label)
+	}
+	return accuracy.Implied(market, labels, tradingdata.GetBetsForMarket(db, bet.MarketID), tradingdata.GetLiquidityForMarket(db, market.ID), bet)
+}
@@ -1,11 +1,19 @@
 package buybetshandlers
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"socialpredict/clock"
 	"socialpredict/models"
 	"socialpredict/models/modelstesting"
+	"socialpredict/services/betlimits"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/selfexclusion"
 	"socialpredict/setup"
+
+	"gorm.io/gorm"
 )
 
 func TestCheckUserBalance_CustomConfig(t *testing.T) {
@@ -94,7 +102,7 @@ func TestCheckUserBalance_CustomConfig(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := checkUserBalance(user, tt.betRequest, tt.sumOfBetFees, loadEconConfig)
+			err := checkUserBalance(user, tt.betRequest, tt.sumOfBetFees, 0, loadEconConfig)
 			if (err != nil) != tt.expectsError {
 				t.Errorf("got error = %v, expected error = %v", err != nil, tt.expectsError)
 			}
@@ -131,8 +139,17 @@ func TestPlaceBetCore_BalanceAdjustment(t *testing.T) {
 	db.First(&updatedUser, "username = ?", "testuser")
 
 	expectedBalance := initialBalance - betRequest.Amount - modelstesting.GenerateEconomicConfig().Economics.Betting.BetFees.InitialBetFee
-	if updatedUser.AccountBalance != expectedBalance {
-		t.Fatalf("Expected balance %d, got %d", expectedBalance, updatedUser.AccountBalance)
+	if updatedUser.AccountBalance != models.CreditsToMicro(expectedBalance) {
+		t.Fatalf("Expected balance %d, got %d", expectedBalance, updatedUser.WholeCredits())
+	}
+
+	// The debit is recorded in the ledger against the bet
+	var entry models.LedgerEntry
+	if err := db.Where("user_id = ? AND type = ?", updatedUser.ID, models.LedgerTypeBet).First(&entry).Error; err != nil {
+		t.Fatalf("Expected a BET ledger entry: %v", err)
+	}
+	if entry.Amount != -models.CreditsToMicro(initialBalance-expectedBalance) || entry.ReferenceID != bet.ID || entry.BalanceAfter != updatedUser.AccountBalance {
+		t.Errorf("Unexpected ledger entry %+v", entry)
 	}
 
 	// Verify that the bet was created successfully
@@ -142,4 +159,150 @@ func TestPlaceBetCore_BalanceAdjustment(t *testing.T) {
 	if bet.Username != "testuser" {
 		t.Errorf("Expected bet username 'testuser', got %s", bet.Username)
 	}
+	if bet.Probability <= 0.5 || bet.Probability >= 1 {
+		t.Errorf("Expected the YES bet to record a probability above 0.5, got %v", bet.Probability)
+	}
+}
+
+func TestPlaceBetCore_EnforcesBetLimits(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("testuser", 1000)
+	market := modelstesting.GenerateMarket(1, "testuser")
+	db.Create(&user)
+	db.Create(&market)
+	if _, err := betlimits.SetMarket(db, 1, 0, 150, "admin"); err != nil {
+		t.Fatalf("SetMarket: %v", err)
+	}
+	loadEconConfig := func() *setup.EconomicConfig {
+		return modelstesting.GenerateEconomicConfig()
+	}
+
+	if _, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 100, Outcome: "YES"}, db, loadEconConfig); err != nil {
+		t.Fatalf("Expected first bet to be allowed, got %v", err)
+	}
+	_, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 51, Outcome: "NO"}, db, loadEconConfig)
+	var limitErr *betlimits.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Exposure != 100 {
+		t.Fatalf("Expected an exposure limit error with 100 staked, got %v", err)
+	}
+
+	var count int64
+	db.Model(&models.Bet{}).Where("username = ?", "testuser").Count(&count)
+	if count != 1 {
+		t.Errorf("Expected the refused bet not to be recorded, got %d bets", count)
+	}
+}
+
+func TestPlaceBetCore_RefusesFrozenTrading(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("testuser", 1000)
+	market := modelstesting.GenerateMarket(1, "testuser")
+	db.Create(&user)
+	db.Create(&market)
+	if _, err := restrictions.Set(db, user.ID, models.RestrictionTradingFrozen, "chargeback", nil, "admin", time.Now()); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	loadEconConfig := func() *setup.EconomicConfig {
+		return modelstesting.GenerateEconomicConfig()
+	}
+
+	_, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 10, Outcome: "YES"}, db, loadEconConfig)
+	var restrictedErr *restrictions.RestrictedError
+	if !errors.As(err, &restrictedErr) {
+		t.Fatalf("Expected a restriction error, got %v", err)
+	}
+
+	if err := restrictions.Lift(db, user.ID, models.RestrictionTradingFrozen, "admin"); err != nil {
+		t.Fatalf("Lift: %v", err)
+	}
+	if _, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 10, Outcome: "YES"}, db, loadEconConfig); err != nil {
+		t.Fatalf("Expected the bet to be allowed once lifted, got %v", err)
+	}
+}
+
+func TestPlaceBetCore_RefusesSelfExcludedUser(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("testuser", 1000)
+	market := modelstesting.GenerateMarket(1, "testuser")
+	db.Create(&user)
+	db.Create(&market)
+	svc := selfexclusion.NewService(db, clock.New())
+	if _, err := svc.Exclude(&user, models.ExclusionCoolingOff, 1); err != nil {
+		t.Fatalf("Exclude: %v", err)
+	}
+	loadEconConfig := func() *setup.EconomicConfig {
+		return modelstesting.GenerateEconomicConfig()
+	}
+
+	_, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 10, Outcome: "YES"}, db, loadEconConfig)
+	var excludedErr *selfexclusion.ExcludedError
+	if !errors.As(err, &excludedErr) {
+		t.Fatalf("Expected a self-exclusion error, got %v", err)
+	}
+}
+
+func TestPlaceBetCore_ChecksLockedBalance(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("testuser", 1000)
+	market := modelstesting.GenerateMarket(1, "testuser")
+	db.Create(&user)
+	db.Create(&market)
+
+	// Another bet has spent the credits since this copy of the user was loaded
+	if err := db.Model(&user).Update("account_balance", 0).Error; err != nil {
+		t.Fatalf("update balance: %v", err)
+	}
+
+	econ := modelstesting.GenerateEconomicConfig()
+	econ.Economics.User.MaximumDebtAllowed = 0
+	_, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 100, Outcome: "YES"}, db, func() *setup.EconomicConfig { return econ })
+	if err == nil {
+		t.Fatalf("expected the bet to be refused against the current balance")
+	}
+
+	var bets int64
+	db.Model(&models.Bet{}).Count(&bets)
+	var reloaded models.User
+	db.First(&reloaded, user.ID)
+	if bets != 0 || reloaded.AccountBalance != 0 {
+		t.Errorf("got %d bets and balance %d, want none placed", bets, reloaded.AccountBalance)
+	}
+}
+
+func TestPlaceBetInTx_PublishesNothingWhenRolledBack(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("testuser", 1000)
+	market := modelstesting.GenerateMarket(1, "testuser")
+	db.Create(&user)
+	db.Create(&market)
+
+	econ := func() *setup.EconomicConfig { return modelstesting.GenerateEconomicConfig() }
+	rollback := errors.New("rolled back")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := PlaceBetInTx(&user, models.Bet{MarketID: 1, Amount: 100, Outcome: "YES"}, tx, econ); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected the rollback error, got %v", err)
+	}
+	var points int64
+	db.Model(&models.MarketPricePoint{}).Count(&points)
+	if points != 0 {
+		t.Fatalf("%d price points recorded for a rolled back bet", points)
+	}
+
+	if _, err := PlaceBetCore(&user, models.Bet{MarketID: 1, Amount: 100, Outcome: "YES"}, db, econ); err != nil {
+		t.Fatalf("PlaceBetCore: %v", err)
+	}
+	db.Model(&models.MarketPricePoint{}).Count(&points)
+	if points == 0 {
+		t.Fatalf("no price point recorded for a committed bet")
+	}
 }
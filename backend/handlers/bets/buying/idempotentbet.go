@@ -0,0 +1,106 @@
+package buybetshandlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	betutils "socialpredict/handlers/bets/betutils"
+	"socialpredict/models"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKeyHeader carries the client's key for a bet request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrInvalidIdempotencyKey = fmt.Errorf("%s must be 1 to %d printable characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)
+	ErrIdempotencyKeyReused  = fmt.Errorf("%s was already used for a different bet", IdempotencyKeyHeader)
+)
+
+// requestHash fingerprints what a bet request asked for, so a key replayed
+// with different parameters is refused rather than answered with the wrong bet
+func requestHash(betRequest models.Bet) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s", betRequest.MarketID, betRequest.Amount, betRequest.Outcome)))
+	return hex.EncodeToString(sum[:])
+}
+
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// replay returns the bet an earlier request with the user's key placed, or
+// nil if the key has not been used
+func replay(db *gorm.DB, user *models.User, key, hash string) (*models.Bet, error) {
+	var record models.BetIdempotencyKey
+	err := db.Where("user_id = ? AND idempotency_key = ?", user.ID, key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	var bet models.Bet
+	if err := db.First(&bet, record.BetID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load bet %d for %s: %w", record.BetID, IdempotencyKeyHeader, err)
+	}
+	return &bet, nil
+}
+
+// PlaceBetIdempotent places a bet at most once per idempotency key. A key the
+// user already placed a bet with returns that bet, and replayed is true; the
+// user is not charged again. Failed bets do not use up their key.
+func PlaceBetIdempotent(user *models.User, betRequest models.Bet, key string, db *gorm.DB, loadEconConfig setup.EconConfigLoader) (bet *models.Bet, replayed bool, err error) {
+	key = strings.TrimSpace(key)
+	if !validIdempotencyKey(key) {
+		return nil, false, ErrInvalidIdempotencyKey
+	}
+	hash := requestHash(betRequest)
+
+	if existing, err := replay(db, user, key, hash); err != nil || existing != nil {
+		return existing, existing != nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Claiming the key first makes a concurrent retry wait on the unique
+		// index, then fail, instead of placing a second bet
+		record := models.BetIdempotencyKey{UserID: user.ID, Key: key, RequestHash: hash}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		placed, err := PlaceBetInTx(user, betRequest, tx, loadEconConfig)
+		if err != nil {
+			return err
+		}
+		bet = placed
+		return tx.Model(&record).Update("bet_id", placed.ID).Error
+	})
+	if err != nil {
+		// A concurrent request with the same key may have won the race
+		if existing, replayErr := replay(db, user, key, hash); replayErr == nil && existing != nil {
+			return existing, true, nil
+		} else if errors.Is(replayErr, ErrIdempotencyKeyReused) {
+			return nil, false, replayErr
+		}
+		return nil, false, err
+	}
+	betutils.PublishTrade(db, *bet)
+	return bet, false, nil
+}
@@ -0,0 +1,88 @@
+package buybetshandlers
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/setup"
+)
+
+func TestPlaceBetIdempotent_ReplayDoesNotChargeTwice(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("bot", 1000)
+	market := modelstesting.GenerateMarket(1, "bot")
+	db.Create(&user)
+	db.Create(&market)
+	loadEcon := func() *setup.EconomicConfig { return modelstesting.GenerateEconomicConfig() }
+
+	request := models.Bet{MarketID: 1, Amount: 100, Outcome: "YES"}
+	first, replayed, err := PlaceBetIdempotent(&user, request, "order-42", db, loadEcon)
+	if err != nil || replayed {
+		t.Fatalf("first request: replayed = %v, err = %v", replayed, err)
+	}
+
+	var afterFirst models.User
+	db.First(&afterFirst, user.ID)
+
+	again := afterFirst
+	second, replayed, err := PlaceBetIdempotent(&again, request, "order-42", db, loadEcon)
+	if err != nil || !replayed {
+		t.Fatalf("retry: replayed = %v, err = %v", replayed, err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("retry returned bet %d, want %d", second.ID, first.ID)
+	}
+
+	var afterRetry models.User
+	db.First(&afterRetry, user.ID)
+	if afterRetry.AccountBalance != afterFirst.AccountBalance {
+		t.Fatalf("retry charged the user: balance %d, want %d", afterRetry.AccountBalance, afterFirst.AccountBalance)
+	}
+	var bets int64
+	db.Model(&models.Bet{}).Count(&bets)
+	if bets != 1 {
+		t.Fatalf("expected 1 bet, got %d", bets)
+	}
+
+	// A different bet under the same key is refused
+	different := request
+	different.Amount = 50
+	if _, _, err := PlaceBetIdempotent(&again, different, "order-42", db, loadEcon); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("reused key: err = %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	// Keys are scoped per user
+	other := modelstesting.GenerateUser("otherbot", 1000)
+	db.Create(&other)
+	if _, replayed, err := PlaceBetIdempotent(&other, request, "order-42", db, loadEcon); err != nil || replayed {
+		t.Fatalf("other user's key: replayed = %v, err = %v", replayed, err)
+	}
+}
+
+func TestPlaceBetIdempotent_FailedBetReleasesKey(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("bot", 0)
+	market := modelstesting.GenerateMarket(1, "bot")
+	db.Create(&user)
+	db.Create(&market)
+	loadEcon := func() *setup.EconomicConfig { return modelstesting.GenerateEconomicConfig() }
+
+	if _, _, err := PlaceBetIdempotent(&user, models.Bet{MarketID: 1, Amount: 100000, Outcome: "YES"}, "k1", db, loadEcon); err == nil {
+		t.Fatal("expected the oversized bet to fail")
+	}
+	var keys int64
+	db.Model(&models.BetIdempotencyKey{}).Count(&keys)
+	if keys != 0 {
+		t.Fatalf("failed bet kept its key")
+	}
+
+	if _, _, err := PlaceBetIdempotent(&user, models.Bet{MarketID: 1, Amount: 10, Outcome: "YES"}, "k1", db, loadEcon); err != nil {
+		t.Fatalf("retry after failure: %v", err)
+	}
+
+	if _, _, err := PlaceBetIdempotent(&user, models.Bet{MarketID: 1, Amount: 10, Outcome: "YES"}, "bad\nkey", db, loadEcon); !errors.Is(err, ErrInvalidIdempotencyKey) {
+		t.Fatalf("invalid key: err = %v", err)
+	}
+}
@@ -2,9 +2,9 @@ package betshandlers
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
-	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/handlers/marketaccess"
+	marketmath "socialpredict/handlers/math/market"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/models"
 	"socialpredict/util"
@@ -43,32 +43,36 @@ func MarketBetsDisplayHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch bets for the market
 	bets := tradingdata.GetBetsForMarket(db, marketIDUint)
 
-	// feed in the time created
-	// note we are not using GetPublicResponseMarketByID because of circular import
-	var market models.Market
-	result := db.Where("ID = ?", marketIdStr).First(&market)
-	if result.Error != nil {
-		// Handle error, for example:
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			// Market not found
-		} else {
-			// Other error fetching market
-		}
-		return // Make sure to return or appropriately handle the error
+	// feed in the time created, hiding markets the viewer cannot see
+	market, ok := marketaccess.Viewable(w, r, db, int64(marketIDUint))
+	if !ok {
+		return
 	}
 
 	// Process bets and calculate market probability at the time of each bet
-	betsDisplayInfo := processBetsForDisplay(market.CreatedAt, bets, db)
+	betsDisplayInfo := processBetsForDisplay(market, bets, db)
 
 	// Respond with the bets display information
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(betsDisplayInfo)
 }
 
-func processBetsForDisplay(marketCreatedAtTime time.Time, bets []models.Bet, db *gorm.DB) []BetDisplayInfo {
+// MarketBetsForDisplay returns a market's bets, oldest first, each with the
+// market's probability when it was placed
+func MarketBetsForDisplay(db *gorm.DB, market *models.Market) []BetDisplayInfo {
+	return processBetsForDisplay(market, tradingdata.GetBetsForMarket(db, uint(market.ID)), db)
+}
+
+func processBetsForDisplay(market *models.Market, bets []models.Bet, db *gorm.DB) []BetDisplayInfo {
+
+	// Liquidity provided to the market changes its depth
+	var liquidity []models.LiquidityEvent
+	if len(bets) > 0 {
+		liquidity = tradingdata.GetLiquidityForMarket(db, int64(bets[0].MarketID))
+	}
 
 	// Calculate probabilities using the fetched bets
-	probabilityChanges := wpam.CalculateMarketProbabilitiesWPAM(marketCreatedAtTime, bets)
+	probabilityChanges := marketmath.ForMarket(market).Probabilities(market.CreatedAt, bets, liquidity...)
 
 	var betsDisplayInfo []BetDisplayInfo
 
@@ -0,0 +1,173 @@
+package sellbetshandlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	betutils "socialpredict/handlers/bets/betutils"
+	marketmath "socialpredict/handlers/math/market"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/pricehistory"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/stream"
+
+	"gorm.io/gorm"
+)
+
+const referenceTypeBet = "bet"
+
+var (
+	ErrInvalidShares = errors.New("shares to sell must be at least 1")
+	ErrTooManyShares = errors.New("cannot sell more shares than owned")
+	ErrSaleTooSmall  = errors.New("sale would pay less than one credit")
+)
+
+// ExitQuote prices a sale of shares back to the market maker before close.
+type ExitQuote struct {
+	MarketID          uint    `json:"marketId"`
+	Outcome           string  `json:"outcome"`
+	Shares            int64   `json:"shares"`
+	SharesOwned       int64   `json:"sharesOwned"`
+	SpotValue         int64   `json:"spotValue"`   // What the shares are worth at the current probability
+	Proceeds          int64   `json:"proceeds"`    // What the sale pays once price impact is taken off
+	PriceImpact       float64 `json:"priceImpact"` // Fraction of the spot value lost to price impact
+	ProbabilityBefore float64 `json:"probabilityBefore"`
+	ProbabilityAfter  float64 `json:"probabilityAfter"`
+}
+
+// QuoteExit prices selling shares of outcome from the user's position. A
+// shares value of zero quotes the whole position. The shares are valued at
+// the position's current worth and then sold into a constant-product pool
+// quoting the market's probability, as deep as the market's pool, so larger
+// exits pay proportionally less.
+func QuoteExit(db *gorm.DB, marketID uint, username, outcome string, shares int64) (ExitQuote, error) {
+	if err := betutils.CheckMarketStatus(db, marketID); err != nil {
+		return ExitQuote{}, err
+	}
+	if shares < 0 {
+		return ExitQuote{}, ErrInvalidShares
+	}
+
+	position, err := getUserNetPositionForMarket(db, strconv.FormatUint(uint64(marketID), 10), username)
+	if err != nil {
+		return ExitQuote{}, err
+	}
+	sharesOwned, err := getSharesOwnedForOutcome(position, outcome)
+	if err != nil {
+		return ExitQuote{}, err
+	}
+	if shares == 0 {
+		shares = sharesOwned
+	}
+	if shares > sharesOwned {
+		return ExitQuote{}, ErrTooManyShares
+	}
+	if position.Value <= 0 {
+		return ExitQuote{}, errors.New("position value is non-positive")
+	}
+
+	var market models.Market
+	if err := db.First(&market, marketID).Error; err != nil {
+		return ExitQuote{}, err
+	}
+	bets := tradingdata.GetBetsForMarket(db, marketID)
+	liquidity := tradingdata.GetLiquidityForMarket(db, int64(marketID))
+	maker := marketmath.ForMarket(&market)
+	probability := marketmath.CurrentProbability(&market, bets, liquidity...)
+
+	price := probability
+	if outcome == "NO" {
+		price = 1 - probability
+	}
+	if price <= 0 {
+		return ExitQuote{}, errors.New("outcome has no price")
+	}
+
+	spotValue := float64(shares) * float64(position.Value) / float64(sharesOwned)
+	cash := maker.SaleProceeds(probability, bets, liquidity, outcome, spotValue/price)
+	proceeds := int64(math.Floor(cash))
+	if proceeds < 1 {
+		return ExitQuote{}, ErrSaleTooSmall
+	}
+
+	sale := models.Bet{MarketID: marketID, Amount: -shares, Outcome: outcome, PlacedAt: time.Now()}
+	after := marketmath.ProjectProbability(&market, bets, sale, liquidity...)
+
+	return ExitQuote{
+		MarketID:          marketID,
+		Outcome:           outcome,
+		Shares:            shares,
+		SharesOwned:       sharesOwned,
+		SpotValue:         int64(math.Floor(spotValue)),
+		Proceeds:          proceeds,
+		PriceImpact:       1 - cash/spotValue,
+		ProbabilityBefore: probability,
+		ProbabilityAfter:  after,
+	}, nil
+}
+
+// ExitPosition sells shares of outcome from the user's position at the quoted
+// price. It records the sale as a negative bet carrying its proceeds and
+// credits the proceeds to the user through the ledger.
+func ExitPosition(db *gorm.DB, user *models.User, marketID uint, outcome string, shares int64) (*models.Bet, ExitQuote, error) {
+	var (
+		bet    models.Bet
+		quote  ExitQuote
+		points []models.MarketPricePoint
+	)
+	if err := restrictions.Check(db, user.ID, models.RestrictionTradingFrozen, time.Now()); err != nil {
+		return nil, ExitQuote{}, err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := betutils.LockOpenMarket(tx, marketID); err != nil {
+			return err
+		}
+		var err error
+		quote, err = QuoteExit(tx, marketID, user.Username, outcome, shares)
+		if err != nil {
+			return err
+		}
+
+		bet = models.Bet{
+			Username: user.Username,
+			MarketID: marketID,
+			Amount:   -quote.Shares,
+			PlacedAt: time.Now(),
+			Outcome:  outcome,
+			Proceeds: quote.Proceeds,
+		}
+		if err := betutils.ValidateSale(tx, &bet); err != nil {
+			return err
+		}
+		if err := tx.Create(&bet).Error; err != nil {
+			return err
+		}
+		if points, err = pricehistory.Record(tx, bet); err != nil {
+			return err
+		}
+
+		if err := tx.First(user, user.ID).Error; err != nil {
+			return err
+		}
+		mid := int64(marketID)
+		_, err = ledger.Apply(tx, user, ledger.Posting{
+			Type:          models.LedgerTypeMarketSale,
+			Amount:        models.CreditsToMicro(quote.Proceeds),
+			ReferenceType: referenceTypeBet,
+			ReferenceID:   bet.ID,
+			MarketID:      &mid,
+			Description:   fmt.Sprintf("Sold %d %s shares in market %d", quote.Shares, outcome, marketID),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, ExitQuote{}, err
+	}
+	stream.Default.PublishTrade(bet, points)
+	return &bet, quote, nil
+}
@@ -0,0 +1,122 @@
+package sellbetshandlers
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	marketmath "socialpredict/handlers/math/market"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestExitPositionSellsWithPriceImpact(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	alice := modelstesting.GenerateUser("alice", 0)
+	bob := modelstesting.GenerateUser("bob", 0)
+	db.Create(&alice)
+	db.Create(&bob)
+	market := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&market)
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(100, "YES", "alice", 1, 0),
+		modelstesting.GenerateBet(50, "NO", "bob", 1, 1),
+	} {
+		db.Create(&bet)
+	}
+
+	position, err := positionsmath.CalculateMarketPositionForUser_WPAM_DBPM(db, "1", "alice")
+	if err != nil {
+		t.Fatalf("position: %v", err)
+	}
+
+	quote, err := QuoteExit(db, 1, "alice", "YES", 0)
+	if err != nil {
+		t.Fatalf("QuoteExit: %v", err)
+	}
+	if quote.Shares != position.YesSharesOwned {
+		t.Fatalf("quote shares = %d, want the whole position %d", quote.Shares, position.YesSharesOwned)
+	}
+	if quote.Proceeds >= quote.SpotValue || quote.PriceImpact <= 0 {
+		t.Fatalf("quote = %+v, want proceeds below spot value", quote)
+	}
+	if quote.ProbabilityAfter >= quote.ProbabilityBefore {
+		t.Fatalf("selling YES moved probability from %v to %v", quote.ProbabilityBefore, quote.ProbabilityAfter)
+	}
+
+	half := position.YesSharesOwned / 2
+	sale, sold, err := ExitPosition(db, &alice, 1, "YES", half)
+	if err != nil {
+		t.Fatalf("ExitPosition: %v", err)
+	}
+	if sale.Amount != -half || sale.Proceeds != sold.Proceeds {
+		t.Fatalf("sale bet = %+v, want %d shares for %d credits", sale, half, sold.Proceeds)
+	}
+
+	var user models.User
+	db.First(&user, alice.ID)
+	if user.AccountBalance != models.CreditsToMicro(sold.Proceeds) {
+		t.Fatalf("balance = %d, want proceeds %d", user.AccountBalance, sold.Proceeds)
+	}
+	var entry models.LedgerEntry
+	if err := db.Where("user_id = ? AND type = ?", alice.ID, models.LedgerTypeMarketSale).First(&entry).Error; err != nil {
+		t.Fatalf("sale ledger entry: %v", err)
+	}
+	if entry.Amount != models.CreditsToMicro(sold.Proceeds) || entry.ReferenceID != sale.ID {
+		t.Fatalf("ledger entry = %+v", entry)
+	}
+
+	positions, err := positionsmath.CalculateMarketPositions_WPAM_DBPM(db, strconv.Itoa(1))
+	if err != nil {
+		t.Fatalf("positions: %v", err)
+	}
+	for _, p := range positions {
+		if p.Username == "alice" && p.TotalSpent != 100-sold.Proceeds {
+			t.Fatalf("alice total spent = %d, want %d", p.TotalSpent, 100-sold.Proceeds)
+		}
+	}
+}
+
+func TestExitPositionRejectsOversizedSale(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	alice := modelstesting.GenerateUser("alice", 0)
+	db.Create(&alice)
+	market := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&market)
+	bet := modelstesting.GenerateBet(20, "YES", "alice", 1, 0)
+	db.Create(&bet)
+
+	if _, _, err := ExitPosition(db, &alice, 1, "YES", 1000); !errors.Is(err, ErrTooManyShares) {
+		t.Fatalf("err = %v, want ErrTooManyShares", err)
+	}
+	var count int64
+	db.Model(&models.Bet{}).Where("amount < 0").Count(&count)
+	if count != 0 {
+		t.Fatalf("%d sale bets recorded after a rejected sale", count)
+	}
+}
+
+func TestQuoteExitUsesMarketMaker(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	alice := modelstesting.GenerateUser("alice", 0)
+	db.Create(&alice)
+	market := modelstesting.GenerateMarket(1, "creator")
+	market.MarketMaker = marketmath.MakerLMSR
+	market.LiquidityParameter = 100
+	db.Create(&market)
+	bet := modelstesting.GenerateBet(100, "YES", "alice", 1, 0)
+	db.Create(&bet)
+
+	quote, err := QuoteExit(db, 1, "alice", "YES", 0)
+	if err != nil {
+		t.Fatalf("QuoteExit: %v", err)
+	}
+	want := marketmath.LMSR{Liquidity: 100}.Probabilities(market.CreatedAt, []models.Bet{bet})[1].Probability
+	if quote.ProbabilityBefore != want {
+		t.Fatalf("probability before = %v, want LMSR's %v", quote.ProbabilityBefore, want)
+	}
+	if quote.Proceeds < 1 || quote.Proceeds > 100 || quote.ProbabilityAfter >= quote.ProbabilityBefore {
+		t.Fatalf("quote = %+v, want a sale for at most the 100 paid that lowers YES", quote)
+	}
+}
@@ -0,0 +1,67 @@
+package sellbetshandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+// ExitPositionRequest asks to sell shares of one outcome. Shares left at zero
+// sells the whole position; DryRun returns the quote without selling.
+type ExitPositionRequest struct {
+	Outcome string `json:"outcome"`
+	Shares  int64  `json:"shares"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+// ExitPositionResponse is the quote the sale was priced at and, unless it
+// was a dry run, the sale bet that was recorded.
+type ExitPositionResponse struct {
+	Quote ExitQuote   `json:"quote"`
+	Sale  *models.Bet `json:"sale,omitempty"`
+}
+
+// ExitPositionHandler handles POST /v0/markets/{marketId}/positions/sell
+func ExitPositionHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+	if httperr != nil {
+		http.Error(w, httperr.Error(), httperr.StatusCode)
+		return
+	}
+
+	marketID, err := strconv.ParseUint(mux.Vars(r)["marketId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ExitPositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var resp ExitPositionResponse
+	status := http.StatusCreated
+	if req.DryRun {
+		resp.Quote, err = QuoteExit(db, uint(marketID), user.Username, req.Outcome, req.Shares)
+		status = http.StatusOK
+	} else {
+		resp.Sale, resp.Quote, err = ExitPosition(db, user, uint(marketID), req.Outcome, req.Shares)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
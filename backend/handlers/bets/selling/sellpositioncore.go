@@ -5,8 +5,9 @@ import (
 	"fmt"
 	betutils "socialpredict/handlers/bets/betutils"
 	positionsmath "socialpredict/handlers/math/positions"
-	usershandlers "socialpredict/handlers/users"
 	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/restrictions"
 	"socialpredict/setup"
 	"strconv"
 	"time"
@@ -31,31 +32,55 @@ func (e ErrDustCapExceeded) IsBusinessRuleError() bool {
 }
 
 func ProcessSellRequest(db *gorm.DB, redeemRequest *models.Bet, user *models.User, cfg *setup.EconomicConfig) error {
+	_, _, err := SellPositionCore(db, redeemRequest, user, cfg)
+	return err
+}
+
+// SellPositionCore sells shares worth up to redeemRequest.Amount credits. It
+// returns the sale bet and the credits the shares sold for. The trade is
+// published once the sale is committed, so db must not be a transaction;
+// callers selling inside their own use SellPositionInTx.
+func SellPositionCore(db *gorm.DB, redeemRequest *models.Bet, user *models.User, cfg *setup.EconomicConfig) (*models.Bet, int64, error) {
+	bet, value, err := SellPositionInTx(db, redeemRequest, user, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	betutils.PublishTrade(db, *bet)
+	return bet, value, nil
+}
+
+// SellPositionInTx sells like SellPositionCore but does not publish the
+// trade. The caller calls betutils.PublishTrade once its transaction has
+// committed.
+func SellPositionInTx(db *gorm.DB, redeemRequest *models.Bet, user *models.User, cfg *setup.EconomicConfig) (*models.Bet, int64, error) {
 
 	if err := betutils.CheckMarketStatus(db, redeemRequest.MarketID); err != nil {
-		return err
+		return nil, 0, err
+	}
+	if err := restrictions.Check(db, user.ID, models.RestrictionTradingFrozen, time.Now()); err != nil {
+		return nil, 0, err
 	}
 
 	marketIDStr := strconv.FormatUint(uint64(redeemRequest.MarketID), 10)
 
 	userNetPosition, err := getUserNetPositionForMarket(db, marketIDStr, user.Username)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	sharesOwned, err := getSharesOwnedForOutcome(userNetPosition, redeemRequest.Outcome)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	sharesToSell, actualSaleValue, err := calculateSharesToSell(
 		userNetPosition, sharesOwned, redeemRequest.Amount, cfg)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	if sharesToSell == 0 {
-		return errors.New("not enough value to sell at least one share")
+		return nil, 0, errors.New("not enough value to sell at least one share")
 	}
 
 	bet := models.Bet{
@@ -64,21 +89,36 @@ func ProcessSellRequest(db *gorm.DB, redeemRequest *models.Bet, user *models.Use
 		Amount:   -sharesToSell, // negative share amount means sale
 		PlacedAt: time.Now(),
 		Outcome:  redeemRequest.Outcome,
+		Proceeds: actualSaleValue,
 	}
 
 	if err := betutils.ValidateSale(db, &bet); err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	if err := usershandlers.ApplyTransactionToUser(user.Username, actualSaleValue, db, usershandlers.TransactionSale); err != nil {
-		return err
-	}
-
-	if err := db.Create(&bet).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := betutils.LockOpenMarket(tx, bet.MarketID); err != nil {
+			return err
+		}
+		if err := tx.Create(&bet).Error; err != nil {
+			return err
+		}
+		marketID := int64(bet.MarketID)
+		_, err := ledger.Apply(tx, user, ledger.Posting{
+			Type:          models.LedgerTypeMarketSale,
+			Amount:        models.CreditsToMicro(actualSaleValue),
+			ReferenceType: referenceTypeBet,
+			ReferenceID:   bet.ID,
+			MarketID:      &marketID,
+			Description:   fmt.Sprintf("Sold %d %s shares in market %d", sharesToSell, bet.Outcome, bet.MarketID),
+		})
 		return err
+	})
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return nil
+	return &bet, actualSaleValue, nil
 }
 
 func getUserNetPositionForMarket(db *gorm.DB, marketIDStr string, username string) (positionsmath.UserMarketPosition, error) {
@@ -115,16 +155,21 @@ func calculateSharesToSell(userNetPosition positionsmath.UserMarketPosition, sha
 	if userNetPosition.Value <= 0 {
 		return 0, 0, errors.New("position value is non-positive")
 	}
+	var sharesToSell, actualSaleValue int64
 	valuePerShare := userNetPosition.Value / sharesOwned
-	if creditsToSell < valuePerShare {
-		return 0, 0, errors.New("requested credit amount is less than value of one share")
-	}
-	sharesToSell := creditsToSell / valuePerShare
-	if sharesToSell > sharesOwned {
-		sharesToSell = sharesOwned
+	if valuePerShare == 0 {
+		// Shares paying at most a credit each, as an LMSR market maker sells
+		// them, are priced at the position's exact value per share
+		sharesToSell = min(creditsToSell*sharesOwned/userNetPosition.Value, sharesOwned)
+		actualSaleValue = sharesToSell * userNetPosition.Value / sharesOwned
+	} else {
+		if creditsToSell < valuePerShare {
+			return 0, 0, errors.New("requested credit amount is less than value of one share")
+		}
+		sharesToSell = min(creditsToSell/valuePerShare, sharesOwned)
+		actualSaleValue = sharesToSell * valuePerShare
 	}
-	actualSaleValue := sharesToSell * valuePerShare
-	if sharesToSell == 0 {
+	if sharesToSell == 0 || actualSaleValue == 0 {
 		return 0, 0, errors.New("not enough value to sell at least one share")
 	}
 
@@ -71,7 +71,7 @@ func SellPositionHandler(loadEconConfig setup.EconConfigLoader) func(w http.Resp
 		}
 
 		// Deduct the bet and switching sides fee amount from the user's balance
-		user.AccountBalance -= redeemRequest.Amount
+		user.AddMicroCredits(-models.CreditsToMicro(redeemRequest.Amount))
 
 		// Update the user's balance in the database
 		if err := db.Save(&user).Error; err != nil {
@@ -0,0 +1,41 @@
+package healthhandlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"socialpredict/services/health"
+)
+
+// HealthHandler reports every dependency and answers 503 unless all are ok,
+// so monitoring alerts on a DFNS outage or a chain whose webhooks went quiet
+func HealthHandler(svc *health.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := svc.Check(r.Context())
+		status := http.StatusOK
+		if report.Status != health.StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	}
+}
+
+// ReadinessHandler answers 503 only when the instance cannot serve traffic,
+// i.e. the database is unreachable. The body carries the full report.
+func ReadinessHandler(svc *health.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := svc.Check(r.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report *health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,72 @@
+// Package marketaccess loads the market a per-market route is about, hiding
+// it from viewers who may not see it: markets of another tenant, and
+// group-only markets from non-members. Every such route answers a hidden
+// market the same way as one that does not exist.
+package marketaccess
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/groups"
+	"socialpredict/services/tenants"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned for a market that does not exist or that the
+// viewer may not see
+var ErrNotFound = errors.New("market not found")
+
+// Viewer returns the request's signed-in user, or nil for anonymous visitors
+// and tokens that do not validate
+func Viewer(r *http.Request, db *gorm.DB) *models.User {
+	if r.Header.Get("Authorization") == "" {
+		return nil
+	}
+	viewer, httperr := middleware.ValidateTokenAndGetUser(r, db)
+	if httperr != nil {
+		return nil
+	}
+	return viewer
+}
+
+// Load returns the market if the request's viewer can see it: only markets
+// of the request's tenant, and group-only markets only to their group's
+// members and admins
+func Load(r *http.Request, db *gorm.DB, marketID int64) (*models.Market, error) {
+	var market models.Market
+	if err := db.Scopes(models.InTenant(tenants.FromRequest(r).ID)).First(&market, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	ok, err := groups.CanView(db, &market, Viewer(r, db))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &market, nil
+}
+
+// Viewable loads the market like Load. If the viewer cannot see it, it
+// answers 404, or 500 if the market could not be loaded, and returns false.
+func Viewable(w http.ResponseWriter, r *http.Request, db *gorm.DB, marketID int64) (*models.Market, bool) {
+	market, err := Load(r, db, marketID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "Market not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Printf("Markets: loading market %d: %v", marketID, err)
+		http.Error(w, "Failed to load market", http.StatusInternalServerError)
+		return nil, false
+	}
+	return market, true
+}
@@ -0,0 +1,53 @@
+package marketaccess
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestLoadHidesGroupMarketsFromNonMembers(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	owner := modelstesting.GenerateUser("owner", 0)
+	outsider := modelstesting.GenerateUser("outsider", 0)
+	db.Create(&owner)
+	db.Create(&outsider)
+	group := models.Group{Name: "Office pool", OwnerID: owner.ID}
+	db.Create(&group)
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: owner.ID, Role: models.GroupRoleOwner})
+
+	market := modelstesting.GenerateMarket(1, "owner")
+	market.Visibility = models.MarketVisibilityGroup
+	market.GroupID = &group.ID
+	db.Create(&market)
+
+	request := func(username string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/v0/markets/1", nil)
+		if username != "" {
+			req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT(username))
+		}
+		return req
+	}
+
+	if got, err := Load(request("owner"), db, market.ID); err != nil || got.ID != market.ID {
+		t.Fatalf("member: %+v, %v", got, err)
+	}
+	for _, username := range []string{"outsider", ""} {
+		if _, err := Load(request(username), db, market.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("viewer %q: err = %v, want ErrNotFound", username, err)
+		}
+	}
+	if _, err := Load(request("owner"), db, 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown market: err = %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if _, ok := Viewable(w, request("outsider"), db, market.ID); ok || w.Code != http.StatusNotFound {
+		t.Errorf("Viewable for outsider = %v, status %d", ok, w.Code)
+	}
+}
@@ -13,16 +13,24 @@ type PublicResponseMarket struct {
 	QuestionTitle           string    `json:"questionTitle"`
 	Description             string    `json:"description"`
 	OutcomeType             string    `json:"outcomeType"`
+	Category                string    `json:"category,omitempty"`
 	ResolutionDateTime      time.Time `json:"resolutionDateTime"`
 	FinalResolutionDateTime time.Time `json:"finalResolutionDateTime"`
 	UTCOffset               int       `json:"utcOffset"`
 	IsResolved              bool      `json:"isResolved"`
 	ResolutionResult        string    `json:"resolutionResult"`
 	InitialProbability      float64   `json:"initialProbability"`
+	MarketMaker             string    `json:"marketMaker"`
+	LiquidityParameter      float64   `json:"liquidityParameter,omitempty"`
 	CreatorUsername         string    `json:"creatorUsername"`
 	CreatedAt               time.Time `json:"createdAt"`
 	YesLabel                string    `json:"yesLabel"`
 	NoLabel                 string    `json:"noLabel"`
+	ConditionMarketID       *int64    `json:"conditionMarketId,omitempty"`
+	ConditionOutcome        string    `json:"conditionOutcome,omitempty"`
+	Visibility              string    `json:"visibility"`
+	GroupID                 *uint     `json:"groupId,omitempty"`
+	TenantID                uint      `json:"tenantId,omitempty"`
 }
 
 // GetPublicResponseMarketByID retrieves a market by its ID using an existing database connection,
@@ -46,16 +54,24 @@ func GetPublicResponseMarketByID(db *gorm.DB, marketId string) (PublicResponseMa
 		QuestionTitle:           market.QuestionTitle,
 		Description:             market.Description,
 		OutcomeType:             market.OutcomeType,
+		Category:                market.Category,
 		ResolutionDateTime:      market.ResolutionDateTime,
 		FinalResolutionDateTime: market.FinalResolutionDateTime,
 		UTCOffset:               market.UTCOffset,
 		IsResolved:              market.IsResolved,
 		ResolutionResult:        market.ResolutionResult,
 		InitialProbability:      market.InitialProbability,
+		MarketMaker:             market.MarketMaker,
+		LiquidityParameter:      market.LiquidityParameter,
 		CreatorUsername:         market.CreatorUsername,
 		CreatedAt:               market.CreatedAt,
 		YesLabel:                market.YesLabel,
 		NoLabel:                 market.NoLabel,
+		ConditionMarketID:       market.ConditionMarketID,
+		ConditionOutcome:        market.ConditionOutcome,
+		Visibility:              market.Visibility,
+		GroupID:                 market.GroupID,
+		TenantID:                market.TenantID,
 	}
 
 	return responseMarket, nil
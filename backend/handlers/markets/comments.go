@@ -0,0 +1,97 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/handlers/marketaccess"
+	"socialpredict/middleware"
+	"socialpredict/services/comments"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AddCommentRequest represents the request body for commenting on a market
+type AddCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// AddMarketCommentHandler posts a comment on a market. Subscribers to the
+// market's stream receive it as a comment event.
+func AddMarketCommentHandler(svc *comments.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		if _, ok := marketaccess.Viewable(w, r, db, marketID); !ok {
+			return
+		}
+		var req AddCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		comment, err := svc.Add(user, marketID, req.Body)
+		if err != nil {
+			switch {
+			case errors.Is(err, comments.ErrMarketNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, comments.ErrInvalidBody):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("Markets: comment failed: %v", err)
+				http.Error(w, "Failed to post comment", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+	}
+}
+
+// MarketCommentsHandler returns a market's comments, newest first
+func MarketCommentsHandler(svc *comments.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		db := util.GetDB()
+		if _, ok := marketaccess.Viewable(w, r, db, marketID); !ok {
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 200 {
+			limit = 50
+		}
+		list, err := svc.List(marketaccess.Viewer(r, db), marketID, limit)
+		if err != nil {
+			if errors.Is(err, comments.ErrMarketNotFound) {
+				http.Error(w, "Market not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to load comments", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"marketId": marketID, "comments": list})
+	}
+}
@@ -7,18 +7,36 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"socialpredict/clock"
+	marketmath "socialpredict/handlers/math/market"
 	"socialpredict/logging"
 	"socialpredict/middleware"
 	"socialpredict/models"
 	"socialpredict/security"
+	"socialpredict/services/conditional"
+	"socialpredict/services/groups"
+	"socialpredict/services/ledger"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/tenants"
 	"socialpredict/setup"
 	"socialpredict/util"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 const maxQuestionTitleLength = 160
 
+const maxCategoryLength = 30
+
+// A categorical market has between minCategoricalOutcomes and
+// maxCategoricalOutcomes outcomes
+const (
+	minCategoricalOutcomes = 2
+	maxCategoricalOutcomes = 10
+)
+
 // validateMarketResolutionTime validates that the market resolution time meets business logic requirements
 func validateMarketResolutionTime(resolutionTime time.Time, config *setup.EconomicConfig) error {
 	now := time.Now()
@@ -66,6 +84,56 @@ func validateCustomLabels(yesLabel, noLabel string) error {
 	return nil
 }
 
+// normalizeCategory lower-cases and trims the market's optional category
+func normalizeCategory(category string) (string, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if len(category) > maxCategoryLength {
+		return "", fmt.Errorf("category must be at most %d characters", maxCategoryLength)
+	}
+	return category, nil
+}
+
+// validateOutcomes normalizes the market's outcome type and returns its
+// trimmed outcome labels; binary markets take none
+func validateOutcomes(market *models.Market, labels []string) ([]string, error) {
+	market.OutcomeType = strings.ToUpper(strings.TrimSpace(market.OutcomeType))
+	if market.OutcomeType == "" {
+		market.OutcomeType = models.OutcomeTypeBinary
+	}
+
+	switch market.OutcomeType {
+	case models.OutcomeTypeBinary:
+		if len(labels) > 0 {
+			return nil, errors.New("outcomes can only be given for categorical markets")
+		}
+		return nil, nil
+	case models.OutcomeTypeCategorical:
+	default:
+		return nil, errors.New("outcome type must be BINARY or CATEGORICAL")
+	}
+
+	if len(labels) < minCategoricalOutcomes || len(labels) > maxCategoricalOutcomes {
+		return nil, fmt.Errorf("categorical markets must have between %d and %d outcomes", minCategoricalOutcomes, maxCategoricalOutcomes)
+	}
+	seen := make(map[string]bool, len(labels))
+	trimmed := make([]string, len(labels))
+	for i, label := range labels {
+		label = strings.TrimSpace(label)
+		if len(label) < 1 || len(label) > 20 {
+			return nil, errors.New("outcome labels must be between 1 and 20 characters")
+		}
+		if label == "N/A" {
+			return nil, errors.New("N/A is reserved for markets resolved without an outcome")
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("duplicate outcome %q", label)
+		}
+		seen[label] = true
+		trimmed[i] = label
+	}
+	return trimmed, nil
+}
+
 func CreateMarketHandler(loadEconConfig setup.EconConfigLoader) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -84,17 +152,71 @@ func CreateMarketHandler(loadEconConfig setup.EconConfigLoader) func(http.Respon
 			return
 		}
 
-		var newMarket models.Market
+		var request struct {
+			models.Market
+			InitialLiquidity int64    `json:"initialLiquidity"` // Credits the creator escrows into the market's liquidity pool
+			Outcomes         []string `json:"outcomes"`         // Labels of a categorical market's outcomes
+		}
 
-		newMarket.CreatorUsername = user.Username
+		request.CreatorUsername = user.Username
 
-		err := json.NewDecoder(r.Body).Decode(&newMarket)
+		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
 			bodyBytes, _ := io.ReadAll(r.Body)
 			log.Printf("Error reading request body: %v, Body: %s", err, string(bodyBytes))
 			http.Error(w, "Error reading request body", http.StatusBadRequest)
 			return
 		}
+		newMarket := request.Market
+		tenant := tenants.FromRequest(r)
+		newMarket.TenantID = tenant.ID
+
+		if request.InitialLiquidity < 0 {
+			http.Error(w, "Initial liquidity cannot be negative", http.StatusBadRequest)
+			return
+		}
+
+		outcomeLabels, err := validateOutcomes(&newMarket, request.Outcomes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if newMarket.Category, err = normalizeCategory(newMarket.Category); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if newMarket.IsCategorical() && request.InitialLiquidity > 0 {
+			http.Error(w, "Initial liquidity is only supported on binary markets", http.StatusBadRequest)
+			return
+		}
+
+		// A conditional market names the market and outcome it depends on
+		if newMarket.IsConditional() {
+			newMarket.ConditionOutcome, err = conditional.Validate(db, *newMarket.ConditionMarketID, newMarket.ConditionOutcome)
+			if errors.Is(err, conditional.ErrConditionNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			newMarket.ConditionOutcome = ""
+		}
+
+		// Group-only markets can only be created in one of the creator's groups
+		if err = groups.ApplyVisibility(db, &newMarket, user.ID); err != nil {
+			switch {
+			case errors.Is(err, groups.ErrNotMember):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, groups.ErrInvalidVisibility), errors.Is(err, groups.ErrGroupRequired):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Error checking market group: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
 
 		// Validate and sanitize market input using security service
 		marketInput := security.MarketInput{
@@ -155,35 +277,64 @@ func CreateMarketHandler(loadEconConfig setup.EconConfigLoader) func(http.Respon
 			return
 		}
 
+		// The market maker is fixed for the life of the market
+		if err = marketmath.ValidateMarketMaker(&newMarket, appConfig.Economics.MarketCreation.DefaultLiquidityParameter); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Subtract any Market Creation Fees from Creator, up to maximum debt
-		marketCreateFee := appConfig.Economics.MarketIncentives.CreateMarketCost
+		marketCreateFee := tenants.CreateMarketCost(tenant, appConfig.Economics.MarketIncentives.CreateMarketCost)
 		maximumDebtAllowed := appConfig.Economics.User.MaximumDebtAllowed
 
 		// Maximum debt allowed check
-		if user.AccountBalance-marketCreateFee < -maximumDebtAllowed {
+		if user.AccountBalance-models.CreditsToMicro(marketCreateFee) < -models.CreditsToMicro(maximumDebtAllowed) {
 			http.Error(w, "Insufficient balance", http.StatusBadRequest)
 			return
 		}
 
-		// deduct fee
-		logging.LogAnyType(user.AccountBalance, "user.AccountBalance before")
-		// Deduct the bet and switching sides fee amount from the user's balance
-		user.AccountBalance -= marketCreateFee
-		logging.LogAnyType(user.AccountBalance, "user.AccountBalance after")
-
-		// Update the user's balance in the database
-		if err := db.Save(&user).Error; err != nil {
-			http.Error(w, "Error updating user balance: "+err.Error(), http.StatusInternalServerError)
+		// Liquidity cannot be borrowed or paid for with bonus credits
+		if request.InitialLiquidity > 0 && user.WithdrawableMicroCredits()-models.CreditsToMicro(marketCreateFee) < models.CreditsToMicro(request.InitialLiquidity) {
+			http.Error(w, "Insufficient balance for initial liquidity", http.StatusBadRequest)
 			return
 		}
 
-		// Create the market in the database
-		result := db.Create(&newMarket)
-		if result.Error != nil {
-			log.Printf("Error creating new market: %v", result.Error)
+		// Create the market in the database, charging its fee and seeding its liquidity pool
+		logging.LogAnyType(user.AccountBalance, "user.AccountBalance before")
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&newMarket).Error; err != nil {
+				return err
+			}
+			for i, label := range outcomeLabels {
+				if err := tx.Create(&models.MarketOutcome{MarketID: newMarket.ID, Label: label, Position: i}).Error; err != nil {
+					return err
+				}
+			}
+			if marketCreateFee > 0 {
+				if _, err := ledger.Apply(tx, user, ledger.Posting{
+					Type:          models.LedgerTypeMarketCreationFee,
+					Amount:        -models.CreditsToMicro(marketCreateFee),
+					ReferenceType: "market",
+					ReferenceID:   uint(newMarket.ID),
+					MarketID:      &newMarket.ID,
+					Description:   fmt.Sprintf("Fee for creating market %d", newMarket.ID),
+					SpendBonus:    true,
+				}); err != nil {
+					return fmt.Errorf("failed to charge market creation fee: %w", err)
+				}
+			}
+			if request.InitialLiquidity == 0 {
+				return nil
+			}
+			_, err := liquidity.NewService(db, clock.New()).AddInTx(tx, user.ID, newMarket.ID, models.CreditsToMicro(request.InitialLiquidity))
+			return err
+		})
+		if err != nil {
+			log.Printf("Error creating new market: %v", err)
 			http.Error(w, "Error creating new market", http.StatusInternalServerError)
 			return
 		}
+		logging.LogAnyType(user.AccountBalance, "user.AccountBalance after")
 
 		// Set the Content-Type header
 		w.Header().Set("Content-Type", "application/json")
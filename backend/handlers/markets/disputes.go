@@ -0,0 +1,71 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/settlement"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// OpenDisputeRequest represents the request body for disputing a resolution
+type OpenDisputeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// OpenDisputeHandler disputes a market's resolution, freezing its winnings
+// that are still inside their settlement delay
+func OpenDisputeHandler(svc *settlement.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req OpenDisputeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		dispute, err := svc.OpenDispute(marketID, user, req.Reason)
+		if err != nil {
+			writeDisputeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(dispute)
+	}
+}
+
+// writeDisputeError maps settlement errors to HTTP responses
+func writeDisputeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, settlement.ErrMarketNotFound), errors.Is(err, settlement.ErrDisputeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, settlement.ErrReasonRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, settlement.ErrNotEligible):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, settlement.ErrNothingHeld), errors.Is(err, settlement.ErrAlreadyDisputed),
+		errors.Is(err, settlement.ErrDisputeClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Markets: dispute request failed: %v", err)
+		http.Error(w, "Failed to process dispute", http.StatusInternalServerError)
+	}
+}
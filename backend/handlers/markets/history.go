@@ -0,0 +1,98 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/handlers/marketaccess"
+	"socialpredict/services/pricehistory"
+	"socialpredict/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ParseCandleInterval reads intervals such as 5m, 1h or 1d
+func ParseCandleInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, pricehistory.ErrInvalidInterval
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, pricehistory.ErrInvalidInterval
+	}
+	return d, nil
+}
+
+// MarketHistoryHandler serves GET /v0/markets/{marketId}/history with
+// probability candles and volume for one outcome. Query parameters:
+// interval (default 1h), outcome (categorical markets; defaults to YES or
+// the first outcome), and from and to as RFC 3339 times; to defaults to now
+// on c.
+func MarketHistoryHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		db := util.GetDB()
+		if _, ok := marketaccess.Viewable(w, r, db, marketID); !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		intervalStr := query.Get("interval")
+		if intervalStr == "" {
+			intervalStr = "1h"
+		}
+		interval, err := ParseCandleInterval(intervalStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var from, to time.Time
+		if s := query.Get("from"); s != "" {
+			if from, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, "Invalid from time", http.StatusBadRequest)
+				return
+			}
+		}
+		if s := query.Get("to"); s != "" {
+			if to, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, "Invalid to time", http.StatusBadRequest)
+				return
+			}
+		} else {
+			to = c.Now()
+		}
+
+		history, err := pricehistory.Candles(db, marketID, strings.TrimSpace(query.Get("outcome")), interval, from, to)
+		if err != nil {
+			switch {
+			case errors.Is(err, pricehistory.ErrMarketNotFound):
+				http.Error(w, "Market not found", http.StatusNotFound)
+			case errors.Is(err, pricehistory.ErrInvalidOutcome),
+				errors.Is(err, pricehistory.ErrInvalidInterval),
+				errors.Is(err, pricehistory.ErrInvalidRange),
+				errors.Is(err, pricehistory.ErrTooManyCandles):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to load price history", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		history.Interval = intervalStr
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
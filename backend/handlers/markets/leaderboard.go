@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"socialpredict/errors"
+	"socialpredict/handlers/marketaccess"
 	positionsmath "socialpredict/handlers/math/positions"
 	"socialpredict/util"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
@@ -15,12 +17,22 @@ func MarketLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	marketIdStr := vars["marketId"]
 
-	// Set content type header early to ensure it's always set
-	w.Header().Set("Content-Type", "application/json")
-
 	// Open up database to utilize connection pooling
 	db := util.GetDB()
 
+	marketID, err := strconv.ParseInt(marketIdStr, 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		errors.HandleHTTPError(w, err, http.StatusBadRequest, "Invalid request or data processing error.")
+		return
+	}
+	if _, ok := marketaccess.Viewable(w, r, db, marketID); !ok {
+		return
+	}
+
+	// Set content type header early to ensure it's always set
+	w.Header().Set("Content-Type", "application/json")
+
 	leaderboard, err := positionsmath.CalculateMarketLeaderboard(db, marketIdStr)
 	if errors.HandleHTTPError(w, err, http.StatusBadRequest, "Invalid request or data processing error.") {
 		return // Stop execution if there was an error.
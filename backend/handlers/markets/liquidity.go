@@ -0,0 +1,103 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/handlers/marketaccess"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/liquidity"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// LiquidityRequest adds or withdraws whole credits of market liquidity
+type LiquidityRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+// GetLiquidityHandler returns a market's liquidity pool and its providers
+func GetLiquidityHandler(pools *liquidity.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		if _, ok := marketaccess.Viewable(w, r, util.GetDB(), marketID); !ok {
+			return
+		}
+
+		pool, err := pools.Pool(marketID)
+		if err != nil {
+			writeLiquidityError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pool)
+	}
+}
+
+// AddLiquidityHandler escrows credits from the user into an open market's
+// liquidity pool
+func AddLiquidityHandler(pools *liquidity.Service) http.HandlerFunc {
+	return liquidityHandler(pools.Add)
+}
+
+// WithdrawLiquidityHandler returns part or all of the user's liquidity from
+// an open market
+func WithdrawLiquidityHandler(pools *liquidity.Service) http.HandlerFunc {
+	return liquidityHandler(pools.Remove)
+}
+
+func liquidityHandler(apply func(userID, marketID, amount int64) (*models.MarketLiquidity, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req LiquidityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		position, err := apply(user.ID, marketID, models.CreditsToMicro(req.Amount))
+		if err != nil {
+			writeLiquidityError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(position)
+	}
+}
+
+func writeLiquidityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, liquidity.ErrMarketNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, liquidity.ErrMarketClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, liquidity.ErrInvalidAmount), errors.Is(err, liquidity.ErrInsufficientBalance),
+		errors.Is(err, liquidity.ErrBonusNotAllowed), errors.Is(err, liquidity.ErrInsufficientStake),
+		errors.Is(err, liquidity.ErrCategoricalMarket):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Markets: liquidity request failed: %v", err)
+		http.Error(w, "Failed to update liquidity", http.StatusInternalServerError)
+	}
+}
@@ -6,10 +6,10 @@ import (
 	"net/http"
 	"socialpredict/handlers/marketpublicresponse"
 	marketmath "socialpredict/handlers/math/market"
-	"socialpredict/handlers/math/probabilities/wpam"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/handlers/users/publicuser"
 	"socialpredict/models"
+	"socialpredict/services/tenants"
 	"socialpredict/util"
 	"strconv"
 
@@ -23,7 +23,7 @@ type ListMarketsResponse struct {
 
 type MarketOverview struct {
 	Market          marketpublicresponse.PublicResponseMarket `json:"market"`
-	Creator         models.PublicUser                         `json:"creator"`
+	Creator         publicuser.PublicUserResponse             `json:"creator"`
 	LastProbability float64                                   `json:"lastProbability"`
 	NumUsers        int                                       `json:"numUsers"`
 	TotalVolume     int64                                     `json:"totalVolume"`
@@ -38,7 +38,7 @@ func ListMarketsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	db := util.GetDB()
-	markets, err := ListMarkets(db)
+	markets, err := ListMarkets(db, tenants.FromRequest(r).ID)
 	if err != nil {
 		http.Error(w, "Error fetching markets", http.StatusInternalServerError)
 		return
@@ -47,12 +47,12 @@ func ListMarketsHandler(w http.ResponseWriter, r *http.Request) {
 	var marketOverviews []MarketOverview
 	for _, market := range markets {
 		bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
-		probabilityChanges := wpam.CalculateMarketProbabilitiesWPAM(market.CreatedAt, bets)
+		probabilityChanges := marketmath.ForMarket(&market).Probabilities(market.CreatedAt, bets, tradingdata.GetLiquidityForMarket(db, market.ID)...)
 		numUsers := models.GetNumMarketUsers(bets)
 		marketVolume := marketmath.GetMarketVolume(bets)
 		lastProbability := probabilityChanges[len(probabilityChanges)-1].Probability
 
-		creatorInfo := publicuser.GetPublicUserInfo(db, market.CreatorUsername)
+		creatorInfo := publicuser.NewPublicUserResponse(publicuser.GetPublicUserInfo(db, market.CreatorUsername))
 
 		// return the PublicResponse type with information about the market
 		marketIDStr := strconv.FormatUint(uint64(market.ID), 10)
@@ -82,10 +82,10 @@ func ListMarketsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// ListMarkets fetches a random list of all markets from the database.
-func ListMarkets(db *gorm.DB) ([]models.Market, error) {
+// ListMarkets fetches a random list of a community's markets from the database.
+func ListMarkets(db *gorm.DB, tenantID uint) ([]models.Market, error) {
 	var markets []models.Market
-	result := db.Order("RANDOM()").Limit(100).Find(&markets) // Set a reasonable limit
+	result := db.Scopes(models.ListedMarkets, models.InTenant(tenantID)).Order("RANDOM()").Limit(100).Find(&markets) // Set a reasonable limit
 	if result.Error != nil {
 		log.Printf("Error fetching markets: %v", result.Error)
 		return nil, result.Error
@@ -6,10 +6,10 @@ import (
 	"net/http"
 	"socialpredict/handlers/marketpublicresponse"
 	marketmath "socialpredict/handlers/math/market"
-	"socialpredict/handlers/math/probabilities/wpam"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/handlers/users/publicuser"
 	"socialpredict/models"
+	"socialpredict/services/tenants"
 	"socialpredict/util"
 	"strconv"
 	"time"
@@ -37,7 +37,7 @@ func ListMarketsByStatusHandler(filterFunc MarketFilterFunc, statusName string)
 		}
 
 		db := util.GetDB()
-		markets, err := ListMarketsByStatus(db, filterFunc)
+		markets, err := ListMarketsByStatus(db, filterFunc, tenants.FromRequest(r).ID)
 		if err != nil {
 			log.Printf("Error fetching markets for status %s: %v", statusName, err)
 			http.Error(w, "Error fetching markets", http.StatusInternalServerError)
@@ -47,12 +47,12 @@ func ListMarketsByStatusHandler(filterFunc MarketFilterFunc, statusName string)
 		var marketOverviews []MarketOverview
 		for _, market := range markets {
 			bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
-			probabilityChanges := wpam.CalculateMarketProbabilitiesWPAM(market.CreatedAt, bets)
+			probabilityChanges := marketmath.ForMarket(&market).Probabilities(market.CreatedAt, bets, tradingdata.GetLiquidityForMarket(db, market.ID)...)
 			numUsers := models.GetNumMarketUsers(bets)
 			marketVolume := marketmath.GetMarketVolume(bets)
 			lastProbability := probabilityChanges[len(probabilityChanges)-1].Probability
 
-			creatorInfo := publicuser.GetPublicUserInfo(db, market.CreatorUsername)
+			creatorInfo := publicuser.NewPublicUserResponse(publicuser.GetPublicUserInfo(db, market.CreatorUsername))
 
 			// Return the PublicResponse type with information about the market
 			marketIDStr := strconv.FormatUint(uint64(market.ID), 10)
@@ -87,10 +87,10 @@ func ListMarketsByStatusHandler(filterFunc MarketFilterFunc, statusName string)
 	}
 }
 
-// ListMarketsByStatus fetches markets from the database using the provided filter function
-func ListMarketsByStatus(db *gorm.DB, filterFunc MarketFilterFunc) ([]models.Market, error) {
+// ListMarketsByStatus fetches a community's markets from the database using the provided filter function
+func ListMarketsByStatus(db *gorm.DB, filterFunc MarketFilterFunc, tenantID uint) ([]models.Market, error) {
 	var markets []models.Market
-	query := filterFunc(db).Order("created_at DESC").Limit(100) // Set a reasonable limit and order by most recent
+	query := filterFunc(db).Scopes(models.ListedMarkets, models.InTenant(tenantID)).Order("created_at DESC").Limit(100) // Set a reasonable limit and order by most recent
 	result := query.Find(&markets)
 	if result.Error != nil {
 		log.Printf("Error fetching filtered markets: %v", result.Error)
@@ -225,7 +225,7 @@ func TestListMarketsByStatus(t *testing.T) {
 	db.Create(&activeMarket)
 
 	// Test ListMarketsByStatus with ActiveMarketsFilter
-	markets, err := ListMarketsByStatus(db, ActiveMarketsFilter)
+	markets, err := ListMarketsByStatus(db, ActiveMarketsFilter, 0)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -237,12 +237,39 @@ func TestListMarketsByStatus(t *testing.T) {
 	}
 }
 
+func TestListMarketsByStatusKeepsTenantsApart(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	util.DB = db
+
+	testUser := modelstesting.GenerateUser("testuser", 1000)
+	db.Create(&testUser)
+
+	defaultMarket := modelstesting.GenerateMarket(1, "testuser")
+	clubMarket := modelstesting.GenerateMarket(2, "testuser")
+	clubMarket.TenantID = 7
+	db.Create(&defaultMarket)
+	db.Create(&clubMarket)
+
+	markets, err := ListMarketsByStatus(db, ActiveMarketsFilter, 7)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(markets) != 1 || markets[0].ID != clubMarket.ID {
+		t.Errorf("Expected only the club's market, got %d markets", len(markets))
+	}
+
+	markets, _ = ListMarketsByStatus(db, ActiveMarketsFilter, 0)
+	if len(markets) != 1 || markets[0].ID != defaultMarket.ID {
+		t.Errorf("Expected only the default community's market, got %d markets", len(markets))
+	}
+}
+
 func TestListMarketsByStatusWithEmptyResults(t *testing.T) {
 	db := modelstesting.NewFakeDB(t)
 	util.DB = db
 
 	// Test with no markets in database
-	markets, err := ListMarketsByStatus(db, ActiveMarketsFilter)
+	markets, err := ListMarketsByStatus(db, ActiveMarketsFilter, 0)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -0,0 +1,139 @@
+package marketshandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/comments"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/orders"
+	"socialpredict/services/stream"
+	"socialpredict/services/tenants"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// perMarketRoutes are the routes about market 1 that must hide it from
+// viewers who may not see it
+var perMarketRoutes = []struct{ method, path string }{
+	{"GET", "/v0/markets/1"},
+	{"GET", "/v0/marketprojection/1/10/YES/"},
+	{"GET", "/v0/markets/1/outcomes"},
+	{"GET", "/v0/markets/1/history?interval=1h"},
+	{"GET", "/v0/markets/1/stream"},
+	{"GET", "/v0/markets/1/comments"},
+	{"POST", "/v0/markets/1/comments"},
+	{"GET", "/v0/markets/leaderboard/1"},
+	{"GET", "/v0/markets/1/orders"},
+	{"GET", "/v0/markets/1/liquidity"},
+}
+
+func perMarketRouter(db *gorm.DB) *mux.Router {
+	commentSvc := comments.NewService(db, clock.New(), stream.NewHub(4))
+	router := mux.NewRouter()
+	router.HandleFunc("/v0/markets/{marketId}", MarketDetailsHandler).Methods("GET")
+	router.HandleFunc("/v0/marketprojection/{marketId}/{amount}/{outcome}/", ProjectNewProbabilityHandler).Methods("GET")
+	router.HandleFunc("/v0/markets/{marketId}/outcomes", MarketOutcomesHandler).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/history", MarketHistoryHandler(clock.New())).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/stream", MarketStreamHandler(stream.NewHub(4))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/comments", MarketCommentsHandler(commentSvc)).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/comments", AddMarketCommentHandler(commentSvc)).Methods("POST")
+	router.HandleFunc("/v0/markets/leaderboard/{marketId}", MarketLeaderboardHandler).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/orders", GetOrderBookHandler(orders.NewService(db, modelstesting.GenerateEconomicConfig, clock.New()))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/liquidity", GetLiquidityHandler(liquidity.NewService(db, clock.New()))).Methods("GET")
+	return router
+}
+
+func TestPerMarketRoutesHideGroupMarkets(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	util.DB = db
+	owner := modelstesting.GenerateUser("owner", 0)
+	outsider := modelstesting.GenerateUser("outsider", 0)
+	db.Create(&owner)
+	db.Create(&outsider)
+	db.Model(&outsider).Update("must_change_password", false)
+	group := models.Group{Name: "Office pool", OwnerID: owner.ID}
+	db.Create(&group)
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: owner.ID, Role: models.GroupRoleOwner})
+
+	market := modelstesting.GenerateMarket(1, "owner")
+	market.Visibility = models.MarketVisibilityGroup
+	market.GroupID = &group.ID
+	db.Create(&market)
+
+	router := perMarketRouter(db)
+	for _, route := range perMarketRoutes {
+		for _, username := range []string{"outsider", ""} {
+			if route.method == "POST" && username == "" {
+				continue // Posting needs a signed-in user before the market is looked at
+			}
+			req := httptest.NewRequest(route.method, route.path, strings.NewReader(`{"body":"hello"}`))
+			if username != "" {
+				req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT(username))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s %s as %q: status = %d, want 404", route.method, route.path, username, w.Code)
+			}
+		}
+	}
+
+	var count int64
+	db.Model(&models.MarketComment{}).Count(&count)
+	if count != 0 {
+		t.Errorf("outsider comments stored: %d", count)
+	}
+
+	req := httptest.NewRequest("GET", "/v0/markets/1", nil)
+	req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("owner"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("member details status = %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPerMarketRoutesHideOtherTenantsMarkets(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	util.DB = db
+	user := modelstesting.GenerateUser("alice", 0)
+	db.Create(&user)
+	db.Model(&user).Update("must_change_password", false)
+	store := tenants.NewStore(clock.New())
+	club, err := store.Create(db, tenants.Input{Slug: "club", Name: "Club", Hostnames: []string{"club.example"}, IsActive: true}, "admin")
+	if err != nil {
+		t.Fatalf("Create tenant: %v", err)
+	}
+
+	market := modelstesting.GenerateMarket(1, "alice")
+	market.TenantID = club.ID
+	db.Create(&market)
+	handler := tenants.Middleware(db, store, perMarketRouter(db))
+
+	for _, route := range perMarketRoutes {
+		req := httptest.NewRequest(route.method, "http://other.example"+route.path, strings.NewReader(`{"body":"hello"}`))
+		req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("alice"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s from another tenant: status = %d, want 404", route.method, route.path, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "http://club.example/v0/markets/1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("own tenant details status = %d: %s", w.Code, w.Body.String())
+	}
+}
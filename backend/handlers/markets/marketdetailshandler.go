@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"math"
 	"net/http"
+	"socialpredict/handlers/marketaccess"
 	"socialpredict/handlers/marketpublicresponse"
 	marketmath "socialpredict/handlers/math/market"
 	"socialpredict/handlers/math/probabilities/wpam"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/handlers/users/publicuser"
 	"socialpredict/models"
+	"socialpredict/services/attestation"
 	"socialpredict/util"
 	"strconv"
 
@@ -19,11 +21,12 @@ import (
 // MarketDetailResponse defines the structure for the market detail response
 type MarketDetailHandlerResponse struct {
 	Market             marketpublicresponse.PublicResponseMarket `json:"market"`
-	Creator            models.PublicUser                         `json:"creator"`
+	Creator            publicuser.PublicUserResponse             `json:"creator"`
 	ProbabilityChanges []wpam.ProbabilityChange                  `json:"probabilityChanges"`
 	NumUsers           int                                       `json:"numUsers"`
 	TotalVolume        int64                                     `json:"totalVolume"`
 	MarketDust         int64                                     `json:"marketDust"`
+	ResolutionProof    *attestation.Proof                        `json:"resolutionProof,omitempty"`
 }
 
 func MarketDetailsHandler(w http.ResponseWriter, r *http.Request) {
@@ -61,6 +64,11 @@ func MarketDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	// open up database to utilize connection pooling
 	db := util.GetDB()
 
+	// Group-only markets are hidden from everyone outside the group
+	if _, ok := marketaccess.Viewable(w, r, db, int64(marketIDUint)); !ok {
+		return
+	}
+
 	// Fetch all bets for the market
 	bets := tradingdata.GetBetsForMarket(db, marketIDUint)
 
@@ -72,7 +80,7 @@ func MarketDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Calculate probabilities using the fetched bets
-	probabilityChanges := wpam.CalculateMarketProbabilitiesWPAM(publicResponseMarket.CreatedAt, bets)
+	probabilityChanges := marketmath.New(publicResponseMarket.MarketMaker, publicResponseMarket.LiquidityParameter).Probabilities(publicResponseMarket.CreatedAt, bets, tradingdata.GetLiquidityForMarket(db, int64(marketIDUint))...)
 
 	// find the number of users on the market
 	numUsers := models.GetNumMarketUsers(bets)
@@ -88,7 +96,7 @@ func MarketDetailsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// get market creator
 	// Fetch the Creator's public information using utility function
-	publicCreator := publicuser.GetPublicUserInfo(db, publicResponseMarket.CreatorUsername)
+	publicCreator := publicuser.NewPublicUserResponse(publicuser.GetPublicUserInfo(db, publicResponseMarket.CreatorUsername))
 
 	// Manually construct the response
 	response := MarketDetailHandlerResponse{
@@ -100,6 +108,11 @@ func MarketDetailsHandler(w http.ResponseWriter, r *http.Request) {
 		MarketDust:         marketDust,
 	}
 
+	// Attach the on-chain resolution proof for anchored markets
+	if publicResponseMarket.IsResolved {
+		response.ResolutionProof = attestation.GetProof(db, publicResponseMarket.ID)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
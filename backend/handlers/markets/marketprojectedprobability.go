@@ -3,7 +3,8 @@ package marketshandlers
 import (
 	"encoding/json"
 	"net/http"
-	"socialpredict/handlers/marketpublicresponse"
+	"socialpredict/handlers/marketaccess"
+	marketmath "socialpredict/handlers/math/market"
 	"socialpredict/handlers/math/probabilities/wpam"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/models"
@@ -54,16 +55,16 @@ func ProjectNewProbabilityHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch all bets for the market
 	currentBets := tradingdata.GetBetsForMarket(db, marketIDUint)
 
-	// Fetch the market creation time using utility function
-	publicResponseMarket, err := marketpublicresponse.GetPublicResponseMarketByID(db, marketId)
-	if err != nil {
-		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+	// Fetch the market's creation time and market maker
+	market, ok := marketaccess.Viewable(w, r, db, int64(marketIDUint))
+	if !ok {
 		return
 	}
-	marketCreatedAt := publicResponseMarket.CreatedAt
 
 	// Project the new probability
-	projectedProbability := wpam.ProjectNewProbabilityWPAM(marketCreatedAt, currentBets, newBet)
+	projectedProbability := wpam.ProjectedProbability{
+		Probability: marketmath.ProjectProbability(market, currentBets, newBet, tradingdata.GetLiquidityForMarket(db, int64(marketIDUint))...),
+	}
 
 	// Set the content type to JSON and encode the response
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,165 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/handlers/marketaccess"
+	"socialpredict/middleware"
+	"socialpredict/services/betlimits"
+	"socialpredict/services/groups"
+	"socialpredict/services/orders"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// PlaceOrderRequest is a limit order. LimitPrice is the probability of
+// Outcome at which the order fills: at or below it for a buy, at or above it
+// for a sell.
+type PlaceOrderRequest struct {
+	Side       string  `json:"side"`    // BUY or SELL
+	Outcome    string  `json:"outcome"` // YES or NO
+	LimitPrice float64 `json:"limitPrice"`
+	Amount     int64   `json:"amount"` // Credits to bet, or for a sell, credits of shares to sell
+}
+
+// PlaceOrderHandler rests a limit order on a market. Orders whose limit is
+// already reached fill at once.
+func PlaceOrderHandler(book *orders.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req PlaceOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		order, err := book.Place(user.ID, marketID, orders.PlaceInput{
+			Side:       req.Side,
+			Outcome:    req.Outcome,
+			LimitPrice: req.LimitPrice,
+			Amount:     req.Amount,
+		})
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order)
+	}
+}
+
+// GetOrderBookHandler returns a market's open orders grouped by price
+func GetOrderBookHandler(book *orders.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		if _, ok := marketaccess.Viewable(w, r, util.GetDB(), marketID); !ok {
+			return
+		}
+
+		levels, err := book.Book(marketID)
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"marketId": marketID, "levels": levels})
+	}
+}
+
+// ListOrdersHandler returns the user's orders, optionally filtered by ?status=
+func ListOrdersHandler(book *orders.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 200 {
+			limit = 50
+		}
+		list, err := book.List(user.ID, r.URL.Query().Get("status"), limit)
+		if err != nil {
+			http.Error(w, "Failed to load orders", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"orders": list})
+	}
+}
+
+// CancelOrderHandler cancels one of the user's open orders
+func CancelOrderHandler(book *orders.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		order, err := book.Cancel(user.ID, uint(id))
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(order)
+	}
+}
+
+func writeOrderError(w http.ResponseWriter, err error) {
+	var limitErr *betlimits.LimitError
+	var restrictedErr *restrictions.RestrictedError
+	var excludedErr *selfexclusion.ExcludedError
+	switch {
+	case errors.Is(err, orders.ErrMarketNotFound), errors.Is(err, orders.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, orders.ErrMarketClosed), errors.Is(err, orders.ErrOrderNotOpen):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, groups.ErrNotMember), errors.As(err, &restrictedErr), errors.As(err, &excludedErr):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, orders.ErrInvalidSide), errors.Is(err, orders.ErrInvalidOutcome),
+		errors.Is(err, orders.ErrInvalidLimitPrice), errors.Is(err, orders.ErrInvalidAmount),
+		errors.Is(err, orders.ErrInsufficientBalance), errors.Is(err, orders.ErrTooManyOrders),
+		errors.Is(err, orders.ErrCategoricalMarket), errors.As(err, &limitErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Markets: order request failed: %v", err)
+		http.Error(w, "Failed to process order", http.StatusInternalServerError)
+	}
+}
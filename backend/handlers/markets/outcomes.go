@@ -0,0 +1,75 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"socialpredict/handlers/marketaccess"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// OutcomeInfo is one outcome of a categorical market with its current
+// probability and the credits bet on it
+type OutcomeInfo struct {
+	Label       string  `json:"label"`
+	Probability float64 `json:"probability"`
+	Volume      int64   `json:"volume"`
+}
+
+// MarketOutcomesResponse is a categorical market's outcomes and every
+// user's position in each
+type MarketOutcomesResponse struct {
+	MarketID  int64                               `json:"marketId"`
+	Pool      int64                               `json:"pool"` // Credits the winning outcome's shares split
+	Outcomes  []OutcomeInfo                       `json:"outcomes"`
+	Positions []positionsmath.CategoricalPosition `json:"positions"`
+}
+
+// MarketOutcomesHandler returns a categorical market's outcomes, their
+// probabilities and the positions held in them
+func MarketOutcomesHandler(w http.ResponseWriter, r *http.Request) {
+	marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+
+	db := util.GetDB()
+	market, ok := marketaccess.Viewable(w, r, db, marketID)
+	if !ok {
+		return
+	}
+	if !market.IsCategorical() {
+		http.Error(w, "Market is not categorical", http.StatusBadRequest)
+		return
+	}
+
+	var labels []string
+	for _, outcome := range tradingdata.GetOutcomesForMarket(db, market.ID) {
+		labels = append(labels, outcome.Label)
+	}
+	bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
+	changes := wpam.CalculateCategoricalProbabilitiesWPAM(market.CreatedAt, labels, bets)
+	positions, pool := positionsmath.CalculateCategoricalPositions(market.CreatedAt, labels, bets)
+
+	response := MarketOutcomesResponse{MarketID: market.ID, Pool: pool, Outcomes: []OutcomeInfo{}, Positions: positions}
+	volumes := make(map[string]int64)
+	for _, bet := range bets {
+		volumes[bet.Outcome] += bet.Amount
+	}
+	for i, label := range labels {
+		response.Outcomes = append(response.Outcomes, OutcomeInfo{
+			Label:       label,
+			Probability: changes[len(changes)-1].Probabilities[i],
+			Volume:      volumes[label],
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
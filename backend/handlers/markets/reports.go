@@ -0,0 +1,63 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/moderation"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ReportMarketRequest represents the request body for reporting a market
+type ReportMarketRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+}
+
+// ReportMarketHandler reports a market to the moderators
+func ReportMarketHandler(svc *moderation.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		var req ReportMarketRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		report, err := svc.Report(user, marketID, req.Reason, req.Details)
+		if err != nil {
+			switch {
+			case errors.Is(err, moderation.ErrMarketNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, moderation.ErrInvalidReason), errors.Is(err, moderation.ErrDetailsTooLong):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, moderation.ErrAlreadyReported):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				log.Printf("Markets: report failed: %v", err)
+				http.Error(w, "Failed to report market", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -5,105 +5,136 @@ import (
 	"errors"
 	"net/http"
 
+	"socialpredict/clock"
 	"socialpredict/handlers/math/payout"
 	"socialpredict/logging"
 	"socialpredict/middleware"
 	"socialpredict/models"
+	"socialpredict/services/conditional"
+	"socialpredict/services/resolutioncost"
+	"socialpredict/setup"
 	"socialpredict/util"
 	"strconv"
-	"time"
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 )
 
-func ResolveMarketHandler(w http.ResponseWriter, r *http.Request) {
+// ResolveMarketHandler resolves a market and pays it out, dated by c
+func ResolveMarketHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 
-	logging.LogMsg("Attempting to use ResolveMarketHandler.")
+		logging.LogMsg("Attempting to use ResolveMarketHandler.")
 
-	// Use database connection
-	db := util.GetDB()
+		// Use database connection
+		db := util.GetDB()
 
-	// Retrieve marketId from URL parameters
-	vars := mux.Vars(r)
-	marketIdStr := vars["marketId"]
+		// Retrieve marketId from URL parameters
+		vars := mux.Vars(r)
+		marketIdStr := vars["marketId"]
 
-	marketId, err := strconv.ParseUint(marketIdStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid market ID", http.StatusBadRequest)
-		return
-	}
+		marketId, err := strconv.ParseUint(marketIdStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
 
-	// Validate token and get user
-	user, httperr := middleware.ValidateTokenAndGetUser(r, db)
-	if httperr != nil {
-		http.Error(w, "Invalid token: "+httperr.Error(), http.StatusUnauthorized)
-		return
-	}
+		// Validate token and get user
+		user, httperr := middleware.ValidateTokenAndGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, "Invalid token: "+httperr.Error(), http.StatusUnauthorized)
+			return
+		}
 
-	// Parse request body for resolution outcome
-	var resolutionData struct {
-		Outcome string `json:"outcome"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&resolutionData); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+		// Parse request body for resolution outcome
+		var resolutionData struct {
+			Outcome string `json:"outcome"`
+			Reason  string `json:"reason,omitempty"` // Why the market is voided, for an N/A resolution
+		}
+		if err := json.NewDecoder(r.Body).Decode(&resolutionData); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	var market models.Market
-	result := db.First(&market, marketId)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			http.Error(w, "Market not found", http.StatusNotFound)
+		var market models.Market
+		result := db.First(&market, marketId)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				http.Error(w, "Market not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Error accessing database", http.StatusInternalServerError)
 			return
 		}
-		http.Error(w, "Error accessing database", http.StatusInternalServerError)
-		return
-	}
 
-	if &market == nil {
-		// handle nil market if necessary, this is just precautionary, as gorm.First should return found object or error
-		http.Error(w, "No market found with provided ID", http.StatusNotFound)
-		return
-	}
+		if &market == nil {
+			// handle nil market if necessary, this is just precautionary, as gorm.First should return found object or error
+			http.Error(w, "No market found with provided ID", http.StatusNotFound)
+			return
+		}
 
-	// Check if the logged-in user is the creator of the market
-	if market.CreatorUsername != user.Username {
-		http.Error(w, "User is not the creator of the market", http.StatusUnauthorized)
-		return
-	}
+		// Check if the logged-in user is the creator of the market
+		if market.CreatorUsername != user.Username {
+			http.Error(w, "User is not the creator of the market", http.StatusUnauthorized)
+			return
+		}
 
-	// Check if the market is already resolved
-	if market.IsResolved {
-		http.Error(w, "Market is already resolved", http.StatusBadRequest)
-		return
-	}
+		// Check if the market is already resolved
+		if market.IsResolved {
+			http.Error(w, "Market is already resolved", http.StatusBadRequest)
+			return
+		}
 
-	// Validate the resolution outcome
-	if resolutionData.Outcome != "YES" && resolutionData.Outcome != "NO" && resolutionData.Outcome != "N/A" {
-		http.Error(w, "Invalid resolution outcome", http.StatusBadRequest)
-		return
-	}
+		// Validate the resolution outcome; a categorical market resolves to one of its outcomes
+		if market.IsCategorical() {
+			var outcome models.MarketOutcome
+			if resolutionData.Outcome != "N/A" &&
+				db.First(&outcome, "market_id = ? AND label = ?", market.ID, resolutionData.Outcome).Error != nil {
+				http.Error(w, "Invalid resolution outcome", http.StatusBadRequest)
+				return
+			}
+		} else if resolutionData.Outcome != "YES" && resolutionData.Outcome != "NO" && resolutionData.Outcome != "N/A" {
+			http.Error(w, "Invalid resolution outcome", http.StatusBadRequest)
+			return
+		}
 
-	// Update the market with the resolution result
-	market.IsResolved = true
-	market.ResolutionResult = resolutionData.Outcome
-	market.FinalResolutionDateTime = time.Now()
+		// A conditional market cannot resolve YES or NO before its condition is met
+		if err := conditional.CheckResolution(db, &market, resolutionData.Outcome); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 
-	// Save the market changes first so payout calculation sees the resolved state
-	if err := db.Save(&market).Error; err != nil {
-		http.Error(w, "Error saving market resolution: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+		now := c.Now()
+		if resolutionData.Outcome == "N/A" {
+			// Voiding refunds every bettor and unwinds the market
+			err = payout.Void(db, &market, user.Username, resolutionData.Reason, now)
+		} else {
+			err = payout.Resolve(db, &market, resolutionData.Outcome, now)
+		}
+		if errors.Is(err, payout.ErrAlreadyResolved) {
+			http.Error(w, "Market is already resolved", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error resolving market: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	// Handle payouts (if applicable) - after market is saved as resolved
-	err = payout.DistributePayoutsWithRefund(&market, db)
-	if err != nil {
-		http.Error(w, "Error distributing payouts: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+		// Markets conditional on this one resolve N/A if their condition was not met
+		if voided, err := conditional.Cascade(db, &market, now); err != nil {
+			logging.LogMsg("Failed to resolve markets conditional on market " + marketIdStr + ": " + err.Error())
+		} else if len(voided) > 0 {
+			logging.LogAnyType(voided, "Markets resolved N/A by their condition")
+		}
+
+		// Book the configured flat resolution fee; a failure here does not undo the resolution
+		costs := resolutioncost.NewService(db, resolutioncost.LoadConfigFromEnv(), setup.EconomicsConfig, c)
+		if _, err := costs.ChargeResolutionFee(market.ID, user.Username); err != nil {
+			logging.LogMsg("Failed to record resolution fee for market " + marketIdStr + ": " + err.Error())
+		}
 
-	// Send a response back
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Market resolved successfully"})
+		// Send a response back
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Market resolved successfully"})
+	}
 }
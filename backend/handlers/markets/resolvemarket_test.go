@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"socialpredict/clock"
 	"socialpredict/models"
 	"socialpredict/models/modelstesting"
 	"socialpredict/util"
@@ -63,7 +64,7 @@ func TestResolveMarketHandler_NARefund(t *testing.T) {
 
 	// Set up router with URL vars
 	router := mux.NewRouter()
-	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler).Methods("POST")
+	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler(clock.New())).Methods("POST")
 	router.ServeHTTP(w, req)
 
 	// Check response
@@ -84,7 +85,7 @@ func TestResolveMarketHandler_NARefund(t *testing.T) {
 	// Verify bettor received refund
 	var updatedBettor models.User
 	db.Where("username = ?", "bettor").First(&updatedBettor)
-	if updatedBettor.AccountBalance != 100 {
+	if updatedBettor.AccountBalance != models.CreditsToMicro(100) {
 		t.Fatalf("Expected bettor balance 100 after refund, got %d", updatedBettor.AccountBalance)
 	}
 }
@@ -130,7 +131,7 @@ func TestResolveMarketHandler_YESWin(t *testing.T) {
 
 	// Set up router with URL vars
 	router := mux.NewRouter()
-	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler).Methods("POST")
+	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler(clock.New())).Methods("POST")
 	router.ServeHTTP(w, req)
 
 	// Check response
@@ -159,7 +160,7 @@ func TestResolveMarketHandler_YESWin(t *testing.T) {
 
 	// The total payouts should equal the market volume (200 total bet amount)
 	totalPayout := updatedWinner.AccountBalance + updatedLoser.AccountBalance
-	if totalPayout != 200 {
+	if totalPayout != models.CreditsToMicro(200) {
 		t.Fatalf("Expected total payout to be 200, got %d", totalPayout)
 	}
 }
@@ -205,7 +206,7 @@ func TestResolveMarketHandler_NOWin(t *testing.T) {
 
 	// Set up router with URL vars
 	router := mux.NewRouter()
-	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler).Methods("POST")
+	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler(clock.New())).Methods("POST")
 	router.ServeHTTP(w, req)
 
 	// Check response
@@ -234,7 +235,7 @@ func TestResolveMarketHandler_NOWin(t *testing.T) {
 
 	// The total payouts should equal the market volume (200 total bet amount)
 	totalPayout := updatedWinner.AccountBalance + updatedLoser.AccountBalance
-	if totalPayout != 200 {
+	if totalPayout != models.CreditsToMicro(200) {
 		t.Fatalf("Expected total payout to be 200, got %d", totalPayout)
 	}
 }
@@ -272,7 +273,7 @@ func TestResolveMarketHandler_UnauthorizedUser(t *testing.T) {
 
 	// Set up router with URL vars
 	router := mux.NewRouter()
-	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler).Methods("POST")
+	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler(clock.New())).Methods("POST")
 	router.ServeHTTP(w, req)
 
 	// Check response - should be unauthorized
@@ -319,7 +320,7 @@ func TestResolveMarketHandler_InvalidOutcome(t *testing.T) {
 
 	// Set up router with URL vars
 	router := mux.NewRouter()
-	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler).Methods("POST")
+	router.HandleFunc("/v0/market/{marketId}/resolve", ResolveMarketHandler(clock.New())).Methods("POST")
 	router.ServeHTTP(w, req)
 
 	// Check response - should be bad request
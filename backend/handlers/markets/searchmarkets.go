@@ -6,16 +6,17 @@ import (
 	"net/http"
 	"socialpredict/handlers/marketpublicresponse"
 	marketmath "socialpredict/handlers/math/market"
-	"socialpredict/handlers/math/probabilities/wpam"
 	"socialpredict/handlers/tradingdata"
 	"socialpredict/handlers/users/publicuser"
 	"socialpredict/models"
 	"socialpredict/security"
+	"socialpredict/services/tenants"
 	"socialpredict/util"
 	"strconv"
 	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SearchMarketsResponse defines the structure for search results
@@ -41,7 +42,10 @@ func SearchMarketsHandler(w http.ResponseWriter, r *http.Request) {
 	db := util.GetDB()
 
 	// Get and validate query parameters
-	query := r.URL.Query().Get("query")
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		query = r.URL.Query().Get("query")
+	}
 	status := r.URL.Query().Get("status")
 	limitStr := r.URL.Query().Get("limit")
 
@@ -78,7 +82,7 @@ func SearchMarketsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Perform the search
-	searchResponse, err := SearchMarkets(db, sanitizedQuery, status, limit)
+	searchResponse, err := SearchMarkets(db, sanitizedQuery, status, limit, tenants.FromRequest(r).ID)
 	if err != nil {
 		log.Printf("Error searching markets: %v", err)
 		http.Error(w, "Error searching markets", http.StatusInternalServerError)
@@ -92,33 +96,15 @@ func SearchMarketsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SearchMarkets performs the actual search logic with fallback
-func SearchMarkets(db *gorm.DB, query, status string, limit int) (*SearchMarketsResponse, error) {
+// SearchMarkets performs the actual search logic with fallback, over one community's markets
+func SearchMarkets(db *gorm.DB, query, status string, limit int, tenantID uint) (*SearchMarketsResponse, error) {
 	log.Printf("SearchMarkets: Searching for '%s' in status '%s'", query, status)
 
 	// Get the appropriate filter function for the primary search
-	var primaryFilter MarketFilterFunc
-	var statusName string
-
-	switch status {
-	case "active":
-		primaryFilter = ActiveMarketsFilter
-		statusName = "active"
-	case "closed":
-		primaryFilter = ClosedMarketsFilter
-		statusName = "closed"
-	case "resolved":
-		primaryFilter = ResolvedMarketsFilter
-		statusName = "resolved"
-	default:
-		primaryFilter = func(db *gorm.DB) *gorm.DB {
-			return db // No status filter for "all"
-		}
-		statusName = "all"
-	}
+	primaryFilter, statusName := StatusFilter(status)
 
 	// Search within the primary status
-	primaryResults, err := searchMarketsWithFilter(db, query, primaryFilter, limit)
+	primaryResults, err := searchMarketsWithFilter(db, query, primaryFilter, limit, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +133,7 @@ func SearchMarkets(db *gorm.DB, query, status string, limit int) (*SearchMarkets
 		allFilter := func(db *gorm.DB) *gorm.DB {
 			return db // No status filter
 		}
-		allResults, err := searchMarketsWithFilter(db, query, allFilter, limit*2) // Get more for filtering
+		allResults, err := searchMarketsWithFilter(db, query, allFilter, limit*2, tenantID) // Get more for filtering
 		if err != nil {
 			return nil, err
 		}
@@ -184,16 +170,60 @@ func SearchMarkets(db *gorm.DB, query, status string, limit int) (*SearchMarkets
 	return response, nil
 }
 
-// searchMarketsWithFilter performs the database search with the given filter
-func searchMarketsWithFilter(db *gorm.DB, searchQuery string, filterFunc MarketFilterFunc, limit int) ([]models.Market, error) {
+// StatusFilter returns the filter for a status of active, closed or
+// resolved, and its name. Any other status is "all", with no filter.
+func StatusFilter(status string) (MarketFilterFunc, string) {
+	switch status {
+	case "active":
+		return ActiveMarketsFilter, "active"
+	case "closed":
+		return ClosedMarketsFilter, "closed"
+	case "resolved":
+		return ResolvedMarketsFilter, "resolved"
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return db // No status filter for "all"
+	}, "all"
+}
+
+// FindMarkets searches a community's listed markets with one status, best
+// matches first, without the fallback to other statuses SearchMarkets adds
+func FindMarkets(db *gorm.DB, query, status string, limit int, tenantID uint) ([]models.Market, error) {
+	filter, _ := StatusFilter(status)
+	return searchMarketsWithFilter(db, query, filter, limit, tenantID)
+}
+
+// Full-text search tuning
+const (
+	// Titles at least this similar to the query match even when they share
+	// no word with it, so misspelt queries still find markets
+	searchTrigramThreshold = 0.3
+	// The boost for new markets halves once they are this many days old
+	searchRecencyDays = 30
+	// Largest recency boost, added to the text relevance of a brand new market
+	searchRecencyWeight = 0.1
+)
+
+// searchMarketsWithFilter performs the database search with the given filter.
+// On Postgres it uses the markets full-text index; other databases match
+// substrings of the title and description, newest first.
+func searchMarketsWithFilter(db *gorm.DB, searchQuery string, filterFunc MarketFilterFunc, limit int, tenantID uint) ([]models.Market, error) {
 	var markets []models.Market
 
+	if db.Dialector.Name() == "postgres" {
+		if err := fullTextSearchQuery(db, searchQuery, filterFunc, limit, tenantID).Find(&markets).Error; err != nil {
+			log.Printf("Error in searchMarketsWithFilter: %v", err)
+			return nil, err
+		}
+		return markets, nil
+	}
+
 	// Create the search query - search in both title and description
 	searchTerm := "%" + strings.ToLower(searchQuery) + "%"
 	log.Printf("searchMarketsWithFilter: searchTerm = '%s'", searchTerm)
 
 	// Build the query with filter
-	query := filterFunc(db).Where("LOWER(question_title) LIKE ? OR LOWER(description) LIKE ?", searchTerm, searchTerm).
+	query := filterFunc(db).Scopes(models.ListedMarkets, models.InTenant(tenantID)).Where("LOWER(question_title) LIKE ? OR LOWER(description) LIKE ?", searchTerm, searchTerm).
 		Order("created_at DESC").
 		Limit(limit)
 
@@ -216,6 +246,22 @@ func searchMarketsWithFilter(db *gorm.DB, searchQuery string, filterFunc MarketF
 	return markets, nil
 }
 
+// fullTextSearchQuery matches markets whose title or description shares
+// words with the query, or whose title is close to it by trigram similarity.
+// Results are ranked by text relevance, plus a boost for newer markets.
+func fullTextSearchQuery(db *gorm.DB, searchQuery string, filterFunc MarketFilterFunc, limit int, tenantID uint) *gorm.DB {
+	return filterFunc(db).Scopes(models.ListedMarkets, models.InTenant(tenantID)).
+		Where("search_vector @@ websearch_to_tsquery('english', ?) OR similarity(question_title, ?) > ?",
+			searchQuery, searchQuery, searchTrigramThreshold).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL: "ts_rank(search_vector, websearch_to_tsquery('english', ?)) + similarity(question_title, ?) + " +
+				"? / (1 + EXTRACT(EPOCH FROM (NOW() - created_at)) / 86400 / ?) DESC, created_at DESC",
+			Vars:               []interface{}{searchQuery, searchQuery, searchRecencyWeight, searchRecencyDays},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit)
+}
+
 // convertToMarketOverviews converts market models to MarketOverview structs
 func convertToMarketOverviews(db *gorm.DB, markets []models.Market) ([]MarketOverview, error) {
 	var marketOverviews []MarketOverview
@@ -223,12 +269,12 @@ func convertToMarketOverviews(db *gorm.DB, markets []models.Market) ([]MarketOve
 	for _, market := range markets {
 		// Get market data similar to listmarketsbystatus.go
 		bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
-		probabilityChanges := wpam.CalculateMarketProbabilitiesWPAM(market.CreatedAt, bets)
+		probabilityChanges := marketmath.ForMarket(&market).Probabilities(market.CreatedAt, bets, tradingdata.GetLiquidityForMarket(db, market.ID)...)
 		numUsers := models.GetNumMarketUsers(bets)
 		marketVolume := marketmath.GetMarketVolume(bets)
 		lastProbability := probabilityChanges[len(probabilityChanges)-1].Probability
 
-		creatorInfo := publicuser.GetPublicUserInfo(db, market.CreatorUsername)
+		creatorInfo := publicuser.NewPublicUserResponse(publicuser.GetPublicUserInfo(db, market.CreatorUsername))
 
 		// Get public response market
 		marketIDStr := strconv.FormatUint(uint64(market.ID), 10)
@@ -262,7 +262,7 @@ func TestSearchMarketsStatusFiltering(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := SearchMarkets(db, "Test", tt.status, 10)
+			result, err := SearchMarkets(db, "Test", tt.status, 10, 0)
 			assert.NoError(t, err, "Search failed for %s: %s", tt.name, tt.description)
 
 			// Extract IDs from primary results
@@ -0,0 +1,70 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/util"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestFullTextSearchQuery(t *testing.T) {
+	// Build the Postgres statement without connecting to a server
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run postgres: %v", err)
+	}
+
+	var markets []models.Market
+	stmt := fullTextSearchQuery(db, "bitcon price", ResolvedMarketsFilter, 10, 0).Find(&markets).Statement
+	sql := stmt.SQL.String()
+
+	for _, want := range []string{
+		"is_resolved = $1 AND (search_vector @@ websearch_to_tsquery('english', $2) OR similarity(question_title, $3) > $4) AND visibility = $5 AND tenant_id = $6",
+		"ORDER BY ts_rank(search_vector, websearch_to_tsquery('english', $7)) + similarity(question_title, $8)",
+		"created_at DESC LIMIT $11",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("search SQL is missing %q:\n%s", want, sql)
+		}
+	}
+	if len(stmt.Vars) != 11 || stmt.Vars[1] != "bitcon price" {
+		t.Errorf("unexpected vars %v", stmt.Vars)
+	}
+}
+
+func TestSearchMarketsHandlerAcceptsQ(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	util.DB = db
+
+	creator := modelstesting.GenerateUser("testuser", 0)
+	db.Create(&creator)
+	market := modelstesting.GenerateMarket(1, "testuser")
+	market.QuestionTitle = "Will Bitcoin reach $100k?"
+	db.Create(&market)
+
+	req := httptest.NewRequest(http.MethodGet, "/v0/markets/search?q=bitcoin", nil)
+	w := httptest.NewRecorder()
+	SearchMarketsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var response SearchMarketsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if response.Query != "bitcoin" || response.TotalCount != 1 {
+		t.Fatalf("response = %+v, want the Bitcoin market", response)
+	}
+}
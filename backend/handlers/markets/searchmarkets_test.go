@@ -255,7 +255,7 @@ func TestSearchMarketsFunction(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := SearchMarkets(db, tt.query, tt.status, tt.limit)
+			result, err := SearchMarkets(db, tt.query, tt.status, tt.limit, 0)
 			assert.NoError(t, err)
 			assert.NotNil(t, result)
 
@@ -466,7 +466,7 @@ func TestSearchMarketsCaseInsensitiveComprehensive(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := SearchMarkets(db, tt.searchQuery, "all", 10)
+			result, err := SearchMarkets(db, tt.searchQuery, "all", 10, 0)
 			assert.NoError(t, err, tt.description)
 			assert.Equal(t, tt.expectedCount, result.TotalCount,
 				"For query '%s': %s. Expected %d, got %d",
@@ -575,7 +575,7 @@ func TestSearchMarketsFallbackThreshold(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := SearchMarkets(db, tt.query, tt.status, 10)
+			result, err := SearchMarkets(db, tt.query, tt.status, 10, 0)
 			assert.NoError(t, err, tt.description)
 			assert.Equal(t, tt.expectedPrimary, result.PrimaryCount,
 				"Primary count mismatch for %s", tt.description)
@@ -651,7 +651,7 @@ func TestSearchMarketsEdgeCases(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := SearchMarkets(db, tt.query, "all", 10)
+			result, err := SearchMarkets(db, tt.query, "all", 10, 0)
 			if tt.query == "   " {
 				// Whitespace query should return an error or empty results
 				if err == nil {
@@ -0,0 +1,124 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/series"
+	"socialpredict/services/tenants"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ListSeriesHandler returns the community's active recurring market series
+func ListSeriesHandler(svc *series.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := svc.List(false)
+		if err != nil {
+			log.Printf("Markets: listing series failed: %v", err)
+			http.Error(w, "Failed to load series", http.StatusInternalServerError)
+			return
+		}
+		tenantID := tenants.FromRequest(r).ID
+		list = slices.DeleteFunc(list, func(s models.MarketSeries) bool { return s.TenantID != tenantID })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"series": list,
+		})
+	}
+}
+
+// GetSeriesHandler returns a series with its markets, newest first
+func GetSeriesHandler(svc *series.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+		detail, err := svc.Get(uint(id))
+		if err == nil && detail.TenantID != tenants.FromRequest(r).ID {
+			err = series.ErrSeriesNotFound
+		}
+		if err != nil {
+			writeSeriesError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detail)
+	}
+}
+
+// SubscribeSeriesHandler subscribes the user to a series' new markets
+func SubscribeSeriesHandler(svc *series.Service) http.HandlerFunc {
+	return subscriptionHandler(svc.Subscribe, true)
+}
+
+// UnsubscribeSeriesHandler unsubscribes the user from a series
+func UnsubscribeSeriesHandler(svc *series.Service) http.HandlerFunc {
+	return subscriptionHandler(svc.Unsubscribe, false)
+}
+
+func subscriptionHandler(change func(userID int64, id uint) error, subscribed bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+		if err := change(user.ID, uint(id)); err != nil {
+			writeSeriesError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"seriesId":   id,
+			"subscribed": subscribed,
+		})
+	}
+}
+
+// ListSeriesSubscriptionsHandler returns the series the user is subscribed to
+func ListSeriesSubscriptionsHandler(svc *series.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+		list, err := svc.Subscriptions(user.ID)
+		if err != nil {
+			log.Printf("Markets: listing series subscriptions failed: %v", err)
+			http.Error(w, "Failed to load subscriptions", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"series": list,
+		})
+	}
+}
+
+// writeSeriesError maps series errors to HTTP responses
+func writeSeriesError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, series.ErrSeriesNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Markets: series request failed: %v", err)
+		http.Error(w, "Failed to process series request", http.StatusInternalServerError)
+	}
+}
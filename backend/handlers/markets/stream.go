@@ -0,0 +1,75 @@
+package marketshandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"socialpredict/handlers/marketaccess"
+	"socialpredict/services/stream"
+	"socialpredict/util"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment line, so
+// proxies do not close it
+const streamHeartbeat = 25 * time.Second
+
+// MarketStreamHandler serves GET /v0/markets/{marketId}/stream, pushing the
+// market's trades, probability changes and comments as Server-Sent Events.
+// Clients that fall behind are disconnected and should reconnect.
+func MarketStreamHandler(hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		marketID, err := strconv.ParseInt(mux.Vars(r)["marketId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid market ID", http.StatusBadRequest)
+			return
+		}
+		if _, ok := marketaccess.Viewable(w, r, util.GetDB(), marketID); !ok {
+			return
+		}
+
+		sub, err := hub.Subscribe(marketID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer hub.Unsubscribe(sub)
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event.Data)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
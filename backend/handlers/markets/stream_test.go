@@ -0,0 +1,87 @@
+package marketshandlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/stream"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMarketStreamHandler(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	util.DB = db
+	market := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&market)
+
+	hub := stream.NewHub(8)
+	router := mux.NewRouter()
+	router.Handle("/v0/markets/{marketId}/stream", MarketStreamHandler(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v0/markets/99/stream")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown market status = %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/v0/markets/1/stream")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// Headers arrive once the handler has subscribed
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.Subscribers(1) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	hub.Publish(1, stream.EventComment, map[string]string{"text": "hello"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var got []string
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream ended early after %q", got)
+			}
+			got = append(got, line)
+			if strings.HasPrefix(line, "data: ") {
+				want := []string{"event: comment", `data: {"text":"hello"}`}
+				joined := strings.Join(got, "\n")
+				for _, w := range want {
+					if !strings.Contains(joined, w) {
+						t.Fatalf("stream %q is missing %q", joined, w)
+					}
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event received; got %q", got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package accuracy scores how well bettors forecast market outcomes.
+//
+// A bet's forecast is the market's probability for the bet's outcome just
+// after the bet was placed: the price the bettor was willing to move the
+// market to. It is recorded on the bet when it is placed (Bet.Probability);
+// bets from before that are replayed. Forecasts are scored with the Brier
+// score once the market resolves.
+package accuracy
+
+import (
+	"time"
+
+	marketmath "socialpredict/handlers/math/market"
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/models"
+)
+
+// Forecast is the probability a bet put on its outcome
+type Forecast struct {
+	BetID       uint
+	Username    string
+	Outcome     string
+	Probability float64
+	PlacedAt    time.Time
+}
+
+// probabilitiesAfter replays the market's bets and returns, for the n-th
+// bet, the market's probability for the bet's outcome just after it. The
+// bool is false for outcomes the market does not have.
+func probabilitiesAfter(market models.Market, labels []string, bets []models.Bet, liquidity []models.LiquidityEvent) func(n int, bet models.Bet) (float64, bool) {
+	if market.IsCategorical() {
+		changes := wpam.CalculateCategoricalProbabilitiesWPAM(market.CreatedAt, labels, bets)
+		index := make(map[string]int, len(labels))
+		for i, label := range labels {
+			index[label] = i
+		}
+		return func(n int, bet models.Bet) (float64, bool) {
+			i, ok := index[bet.Outcome]
+			if !ok {
+				return 0, false
+			}
+			return changes[n+1].Probabilities[i], true
+		}
+	}
+
+	changes := marketmath.ForMarket(&market).Probabilities(market.CreatedAt, bets, liquidity...)
+	return func(n int, bet models.Bet) (float64, bool) {
+		switch bet.Outcome {
+		case "YES":
+			return changes[n+1].Probability, true
+		case "NO":
+			return 1 - changes[n+1].Probability, true
+		}
+		return 0, false
+	}
+}
+
+// Implied returns the probability newBet puts on its outcome: the market's
+// probability for it once newBet is placed after bets. It is what gets
+// recorded on a bet at placement.
+func Implied(market models.Market, labels []string, bets []models.Bet, liquidity []models.LiquidityEvent, newBet models.Bet) float64 {
+	all := append(append([]models.Bet{}, bets...), newBet)
+	p, _ := probabilitiesAfter(market, labels, all, liquidity)(len(all)-1, newBet)
+	return p
+}
+
+// MarketForecasts returns a forecast for every purchase on the market. Bets
+// must be in the order they were placed; labels are the outcomes of a
+// categorical market and are ignored for binary markets. Sales are not
+// forecasts and are skipped.
+func MarketForecasts(market models.Market, labels []string, bets []models.Bet, liquidity []models.LiquidityEvent) []Forecast {
+	var after func(int, models.Bet) (float64, bool)
+
+	var forecasts []Forecast
+	for n, bet := range bets {
+		if bet.Amount <= 0 {
+			continue
+		}
+		p := bet.Probability
+		if p == 0 {
+			// Placed before probabilities were recorded
+			if after == nil {
+				after = probabilitiesAfter(market, labels, bets, liquidity)
+			}
+			var ok bool
+			if p, ok = after(n, bet); !ok {
+				continue
+			}
+		}
+		forecasts = append(forecasts, Forecast{
+			BetID:       bet.ID,
+			Username:    bet.Username,
+			Outcome:     bet.Outcome,
+			Probability: p,
+			PlacedAt:    bet.PlacedAt,
+		})
+	}
+	return forecasts
+}
+
+// Score returns the forecast's Brier score against the market's resolution:
+// 0 for certainty in what happened, 1 for certainty in what did not
+func (f Forecast) Score(resolution string) float64 {
+	d := f.Probability - outcome(f, resolution)
+	return d * d
+}
+
+func outcome(f Forecast, resolution string) float64 {
+	if f.Outcome == resolution {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package accuracy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestMarketForecastsBinary(t *testing.T) {
+	market := modelstesting.GenerateMarket(1, "creator")
+	market.CreatedAt = time.Now()
+	bets := []models.Bet{
+		modelstesting.GenerateBet(50, "YES", "alice", 1, time.Minute),
+		modelstesting.GenerateBet(-10, "YES", "alice", 1, 2*time.Minute),
+		modelstesting.GenerateBet(30, "NO", "bob", 1, 3*time.Minute),
+	}
+
+	forecasts := MarketForecasts(market, nil, bets, nil)
+	if len(forecasts) != 2 {
+		t.Fatalf("got %d forecasts, want 2 (the sale is skipped)", len(forecasts))
+	}
+	if forecasts[0].Username != "alice" || forecasts[0].Probability <= 0.5 {
+		t.Fatalf("alice's YES forecast = %+v, want above 0.5", forecasts[0])
+	}
+	if forecasts[1].Username != "bob" || forecasts[1].Outcome != "NO" {
+		t.Fatalf("second forecast = %+v, want bob's NO", forecasts[1])
+	}
+}
+
+func TestScore(t *testing.T) {
+	f := Forecast{Outcome: "YES", Probability: 0.8}
+	if got := f.Score("YES"); math.Abs(got-0.04) > 1e-9 {
+		t.Fatalf("score when right = %v, want 0.04", got)
+	}
+	if got := f.Score("NO"); math.Abs(got-0.64) > 1e-9 {
+		t.Fatalf("score when wrong = %v, want 0.64", got)
+	}
+}
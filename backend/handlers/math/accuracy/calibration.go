@@ -0,0 +1,134 @@
+package accuracy
+
+import (
+	"math"
+
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// CalibrationBuckets is how many equal-width probability ranges a
+// calibration curve is split into
+const CalibrationBuckets = 10
+
+// Scored is a forecast together with how its market resolved
+type Scored struct {
+	Forecast
+	MarketID   int64
+	Resolution string
+}
+
+// CalibrationBucket is one point on a calibration curve: of the forecasts
+// between Lower and Upper, how often the forecast outcome happened. A well
+// calibrated forecaster's Observed is close to their MeanForecast.
+type CalibrationBucket struct {
+	Lower        float64 `json:"lower"`
+	Upper        float64 `json:"upper"`
+	Forecasts    int     `json:"forecasts"`
+	MeanForecast float64 `json:"meanForecast"`
+	Observed     float64 `json:"observed"`
+}
+
+// Calibration buckets the forecasts by probability. Empty buckets are
+// included, with zero forecasts, so curves always have the same points.
+func Calibration(scored []Scored) []CalibrationBucket {
+	buckets := make([]CalibrationBucket, CalibrationBuckets)
+	width := 1.0 / CalibrationBuckets
+	for i := range buckets {
+		buckets[i].Lower = round(float64(i) * width)
+		buckets[i].Upper = round(float64(i+1) * width)
+	}
+
+	sums := make([]float64, CalibrationBuckets)
+	hits := make([]float64, CalibrationBuckets)
+	for _, s := range scored {
+		i := int(s.Probability * CalibrationBuckets)
+		if i >= CalibrationBuckets {
+			i = CalibrationBuckets - 1
+		}
+		buckets[i].Forecasts++
+		sums[i] += s.Probability
+		hits[i] += outcome(s.Forecast, s.Resolution)
+	}
+	for i := range buckets {
+		if n := float64(buckets[i].Forecasts); n > 0 {
+			buckets[i].MeanForecast = sums[i] / n
+			buckets[i].Observed = hits[i] / n
+		}
+	}
+	return buckets
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// Report is a user's forecast accuracy over the resolved markets they bet in
+type Report struct {
+	Username        string              `json:"username"`
+	BrierScore      *float64            `json:"brierScore"` // Nil until a market they bet in resolves
+	Forecasts       int                 `json:"forecasts"`
+	ResolvedMarkets int                 `json:"resolvedMarkets"`
+	Calibration     []CalibrationBucket `json:"calibration"`
+}
+
+// UserForecasts returns the user's scored forecasts in every market they
+// bet in that resolved to an outcome, ordered by market. Markets resolved
+// N/A are left out.
+func UserForecasts(db *gorm.DB, username string) ([]Scored, error) {
+	var marketIDs []int64
+	if err := db.Model(&models.Bet{}).Where("username = ? AND amount > 0", username).
+		Distinct().Pluck("market_id", &marketIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(marketIDs) == 0 {
+		return nil, nil
+	}
+
+	var markets []models.Market
+	if err := db.Where("id IN ? AND is_resolved = ? AND resolution_result <> ?", marketIDs, true, "N/A").
+		Order("id ASC").Find(&markets).Error; err != nil {
+		return nil, err
+	}
+
+	var scored []Scored
+	for _, market := range markets {
+		var labels []string
+		if market.IsCategorical() {
+			for _, o := range tradingdata.GetOutcomesForMarket(db, market.ID) {
+				labels = append(labels, o.Label)
+			}
+		}
+		bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
+		for _, f := range MarketForecasts(market, labels, bets, tradingdata.GetLiquidityForMarket(db, market.ID)) {
+			if f.Username == username {
+				scored = append(scored, Scored{Forecast: f, MarketID: market.ID, Resolution: market.ResolutionResult})
+			}
+		}
+	}
+	return scored, nil
+}
+
+// UserReport computes the user's Brier score and calibration curve
+func UserReport(db *gorm.DB, username string) (Report, error) {
+	scored, err := UserForecasts(db, username)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Username: username, Forecasts: len(scored), Calibration: Calibration(scored)}
+	markets := make(map[int64]bool)
+	var sum float64
+	for _, s := range scored {
+		sum += s.Score(s.Resolution)
+		markets[s.MarketID] = true
+	}
+	report.ResolvedMarkets = len(markets)
+	if len(scored) > 0 {
+		brier := sum / float64(len(scored))
+		report.BrierScore = &brier
+	}
+	return report, nil
+}
@@ -0,0 +1,76 @@
+package accuracy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestCalibration(t *testing.T) {
+	scored := []Scored{
+		{Forecast: Forecast{Outcome: "YES", Probability: 0.72}, Resolution: "YES"},
+		{Forecast: Forecast{Outcome: "YES", Probability: 0.78}, Resolution: "NO"},
+		{Forecast: Forecast{Outcome: "NO", Probability: 1}, Resolution: "NO"},
+	}
+
+	buckets := Calibration(scored)
+	if len(buckets) != CalibrationBuckets {
+		t.Fatalf("got %d buckets, want %d", len(buckets), CalibrationBuckets)
+	}
+	seventy := buckets[7]
+	if seventy.Lower != 0.7 || seventy.Forecasts != 2 || math.Abs(seventy.MeanForecast-0.75) > 1e-9 || seventy.Observed != 0.5 {
+		t.Fatalf("0.7-0.8 bucket = %+v", seventy)
+	}
+	if top := buckets[9]; top.Forecasts != 1 || top.Observed != 1 {
+		t.Fatalf("certain forecast landed in %+v, want the top bucket", top)
+	}
+}
+
+func TestUserReport(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	resolved := modelstesting.GenerateMarket(1, "creator")
+	resolved.IsResolved = true
+	resolved.ResolutionResult = "YES"
+	refunded := modelstesting.GenerateMarket(2, "creator")
+	refunded.IsResolved = true
+	refunded.ResolutionResult = "N/A"
+	open := modelstesting.GenerateMarket(3, "creator")
+	db.Create(&resolved)
+	db.Create(&refunded)
+	db.Create(&open)
+
+	recorded := modelstesting.GenerateBet(20, "YES", "alice", 1, time.Minute)
+	recorded.Probability = 0.9
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(20, "YES", "alice", 1, 0),
+		recorded,
+		modelstesting.GenerateBet(20, "NO", "bob", 1, 2*time.Minute),
+		modelstesting.GenerateBet(20, "YES", "alice", 2, 0),
+		modelstesting.GenerateBet(20, "YES", "alice", 3, 0),
+	} {
+		db.Create(&bet)
+	}
+
+	report, err := UserReport(db, "alice")
+	if err != nil {
+		t.Fatalf("UserReport: %v", err)
+	}
+	if report.Forecasts != 2 || report.ResolvedMarkets != 1 {
+		t.Fatalf("report = %+v, want two forecasts in the one market that resolved YES", report)
+	}
+	if report.BrierScore == nil || *report.BrierScore >= 0.25 {
+		t.Fatalf("brier = %v, want better than a coin flip", report.BrierScore)
+	}
+	if report.Calibration[9].Forecasts != 1 {
+		t.Fatalf("recorded 0.9 forecast not used: %+v", report.Calibration[9])
+	}
+
+	empty, err := UserReport(db, "carol")
+	if err != nil || empty.BrierScore != nil || len(empty.Calibration) != CalibrationBuckets {
+		t.Fatalf("report for user without bets = %+v, %v", empty, err)
+	}
+}
@@ -27,7 +27,7 @@ func TestComputeUserFinancials_NewUser_NoPositions(t *testing.T) {
 	}
 
 	// Compute financial snapshot
-	snapshot, err := ComputeUserFinancials(db, user.Username, user.AccountBalance, econ)
+	snapshot, err := ComputeUserFinancials(db, user.Username, user.WholeCredits(), econ)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -79,7 +79,7 @@ func TestComputeUserFinancials_NegativeBalance_Borrowing(t *testing.T) {
 	}
 
 	// Compute financial snapshot
-	snapshot, err := ComputeUserFinancials(db, user.Username, user.AccountBalance, econ)
+	snapshot, err := ComputeUserFinancials(db, user.Username, user.WholeCredits(), econ)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -145,7 +145,7 @@ func TestComputeUserFinancials_WithActivePositions(t *testing.T) {
 
 	// This test would need to be completed with actual market position data
 	// For now, let's verify the function can be called without error
-	_, err := ComputeUserFinancials(db, user.Username, user.AccountBalance, econ)
+	_, err := ComputeUserFinancials(db, user.Username, user.WholeCredits(), econ)
 	if err != nil {
 		t.Fatalf("Expected no error with active positions, got: %v", err)
 	}
@@ -184,7 +184,7 @@ func TestComputeUserFinancials_WithResolvedPositions(t *testing.T) {
 	}
 
 	// This test would need to be completed with actual resolved market position data
-	_, err := ComputeUserFinancials(db, user.Username, user.AccountBalance, econ)
+	_, err := ComputeUserFinancials(db, user.Username, user.WholeCredits(), econ)
 	if err != nil {
 		t.Fatalf("Expected no error with resolved positions, got: %v", err)
 	}
@@ -236,7 +236,7 @@ func TestComputeUserFinancials_MixedPositions(t *testing.T) {
 	}
 
 	// Test mixed positions scenario
-	snapshot, err := ComputeUserFinancials(db, user.Username, user.AccountBalance, econ)
+	snapshot, err := ComputeUserFinancials(db, user.Username, user.WholeCredits(), econ)
 	if err != nil {
 		t.Fatalf("Expected no error with mixed positions, got: %v", err)
 	}
@@ -0,0 +1,114 @@
+package financials_test
+
+import (
+	"testing"
+	"time"
+
+	buybetshandlers "socialpredict/handlers/bets/buying"
+	sellbetshandlers "socialpredict/handlers/bets/selling"
+	marketmath "socialpredict/handlers/math/market"
+	"socialpredict/handlers/math/outcomes/dbpm"
+	"socialpredict/handlers/math/payout"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestLMSRMarketTradesAndPaysOutThroughItsMarketMaker(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	econConfig, loadEcon := modelstesting.UseStandardTestEconomics(t)
+
+	for _, username := range []string{"alice", "bob"} {
+		user := modelstesting.GenerateUser(username, 100)
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create user %s: %v", username, err)
+		}
+	}
+	market := modelstesting.GenerateMarket(7101, "alice")
+	market.MarketMaker = marketmath.MakerLMSR
+	market.LiquidityParameter = 20
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+	maker := marketmath.ForMarket(&market)
+
+	loadUser := func(username string) *models.User {
+		var u models.User
+		if err := db.Where("username = ?", username).First(&u).Error; err != nil {
+			t.Fatalf("load user %s: %v", username, err)
+		}
+		return &u
+	}
+	placeBet := func(username string, amount int64, outcome string) *models.Bet {
+		bet, err := buybetshandlers.PlaceBetCore(loadUser(username), models.Bet{MarketID: uint(market.ID), Amount: amount, Outcome: outcome}, db, loadEcon)
+		if err != nil {
+			t.Fatalf("place bet for %s: %v", username, err)
+		}
+		return bet
+	}
+
+	// Placement records the probability LMSR moves the market to, not WPAM's
+	first := placeBet("alice", 20, "YES")
+	placeBet("bob", 10, "NO")
+	placeBet("alice", 10, "YES")
+	bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
+	lmsr := maker.Probabilities(market.CreatedAt, bets)
+	if first.Probability != lmsr[1].Probability {
+		t.Errorf("first bet probability = %v, want LMSR's %v", first.Probability, lmsr[1].Probability)
+	}
+	if wpamAfter := (marketmath.WPAM{}).Probabilities(market.CreatedAt, bets)[1].Probability; first.Probability == wpamAfter {
+		t.Fatalf("LMSR and WPAM agree on %v; the test cannot tell them apart", wpamAfter)
+	}
+
+	// Positions hold the shares LMSR sold each bet, not a DBPM split of the pool
+	positions, err := positionsmath.CalculateMarketPositions(db, &market)
+	if err != nil {
+		t.Fatalf("positions: %v", err)
+	}
+	shares := maker.Shares(bets, lmsr)
+	if want := shares[0] + shares[2]; positionFor(positions, "alice").YesSharesOwned != want {
+		t.Errorf("alice YES shares = %d, want %d", positionFor(positions, "alice").YesSharesOwned, want)
+	}
+	dbpmShares := dbpm.CalculateBetSharesDBPM(bets, lmsr)
+	if dbpmShares[0] == shares[0] {
+		t.Fatalf("LMSR and DBPM both give the first bet %d shares; the test cannot tell them apart", shares[0])
+	}
+
+	// Selling prices the position the market maker allocated
+	if _, _, err := sellbetshandlers.SellPositionCore(db, &models.Bet{MarketID: uint(market.ID), Amount: 5, Outcome: "YES"}, loadUser("alice"), econConfig); err != nil {
+		t.Fatalf("sell: %v", err)
+	}
+	bets = tradingdata.GetBetsForMarket(db, uint(market.ID))
+	if sale := bets[len(bets)-1]; sale.Amount >= 0 || sale.Proceeds <= 0 {
+		t.Fatalf("sale recorded as %+v", sale)
+	}
+
+	// Resolution pays out the LMSR positions, no more than the market took in
+	if err := payout.Resolve(db, &market, "YES", time.Now()); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	var paid int64
+	if err := db.Model(&models.LedgerEntry{}).Where("market_id = ? AND type = ?", market.ID, models.LedgerTypeMarketPayout).
+		Select("COALESCE(SUM(amount), 0)").Scan(&paid).Error; err != nil {
+		t.Fatalf("sum payouts: %v", err)
+	}
+	if want := models.CreditsToMicro(marketmath.GetMarketVolume(bets)); paid != want {
+		t.Errorf("paid out %d, want the market's volume %d", paid, want)
+	}
+	var bobPaid int64
+	db.Model(&models.LedgerEntry{}).Where("market_id = ? AND type = ? AND user_id = ?", market.ID, models.LedgerTypeMarketPayout, loadUser("bob").ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&bobPaid)
+	if bobPaid != 0 {
+		t.Errorf("bob, who bet NO, was paid %d", bobPaid)
+	}
+}
+
+func positionFor(positions []positionsmath.MarketPosition, username string) positionsmath.MarketPosition {
+	for _, pos := range positions {
+		if pos.Username == username {
+			return pos
+		}
+	}
+	return positionsmath.MarketPosition{}
+}
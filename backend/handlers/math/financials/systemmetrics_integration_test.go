@@ -2,6 +2,7 @@ package financials_test
 
 import (
 	"testing"
+	"time"
 
 	buybetshandlers "socialpredict/handlers/bets/buying"
 	financials "socialpredict/handlers/math/financials"
@@ -42,7 +43,7 @@ func TestComputeSystemMetrics_BalancedAfterFinalLockedBet(t *testing.T) {
 	if err := db.Where("username = ?", users[0].Username).First(&creator).Error; err != nil {
 		t.Fatalf("failed to load market creator: %v", err)
 	}
-	creator.AccountBalance -= creationFee
+	creator.AddMicroCredits(-models.CreditsToMicro(creationFee))
 	if err := db.Save(&creator).Error; err != nil {
 		t.Fatalf("failed to charge market creation fee: %v", err)
 	}
@@ -84,8 +85,8 @@ func TestComputeSystemMetrics_BalancedAfterFinalLockedBet(t *testing.T) {
 	var expectedUnusedDebt int64
 	for _, u := range dbUsers {
 		usedDebt := int64(0)
-		if u.AccountBalance < 0 {
-			usedDebt = -u.AccountBalance
+		if u.WholeCredits() < 0 {
+			usedDebt = -u.WholeCredits()
 		}
 		expectedUnusedDebt += maxDebt - usedDebt
 	}
@@ -203,7 +204,7 @@ func TestResolveMarket_DistributesAllBetVolume(t *testing.T) {
 		t.Fatalf("failed to mark market resolved: %v", err)
 	}
 
-	if err := payout.DistributePayoutsWithRefund(&market, db); err != nil {
+	if err := payout.DistributePayoutsWithRefund(&market, db, time.Now()); err != nil {
 		t.Fatalf("payout distribution failed: %v", err)
 	}
 
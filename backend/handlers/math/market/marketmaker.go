@@ -0,0 +1,228 @@
+package marketmath
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"socialpredict/handlers/math/outcomes/cpmm"
+	"socialpredict/handlers/math/outcomes/dbpm"
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/models"
+)
+
+// Market makers a binary market can be created with. WPAM, the original, sets
+// the probability to a weighted average of the bets and prices sales against a
+// constant-product pool. LMSR is Hanson's logarithmic market scoring rule; its
+// liquidity parameter sets how far each bet moves the price.
+const (
+	MakerWPAM = "WPAM"
+	MakerLMSR = "LMSR"
+)
+
+var (
+	ErrUnknownMarketMaker   = errors.New("market maker must be WPAM or LMSR")
+	ErrInvalidLiquidity     = errors.New("liquidity parameter must be positive")
+	ErrLMSRBinaryOnly       = errors.New("LMSR is only supported on binary markets")
+	ErrLiquidityWithoutLMSR = errors.New("a liquidity parameter can only be set for LMSR markets")
+)
+
+// lmsrPriceBound keeps LMSR probabilities off 0 and 1, where a sale
+// larger than the market maker can pay would otherwise push them
+const lmsrPriceBound = 0.0001
+
+// MarketMaker prices a binary market's YES and NO outcomes from its bets.
+// Bet placement, selling, positions, payouts, limit orders and price history
+// all go through it.
+type MarketMaker interface {
+	Name() string
+	// Probabilities returns the YES probability when the market was created
+	// and after each of its bets, in order. Liquidity events, ordered by time,
+	// deepen the market from when they happen without moving its probability.
+	Probabilities(createdAt time.Time, bets []models.Bet, liquidity ...models.LiquidityEvent) []wpam.ProbabilityChange
+	// SaleProceeds returns the credits paid for selling shares of outcome back
+	// to the market maker at probability, once bets and liquidity are in
+	SaleProceeds(probability float64, bets []models.Bet, liquidity []models.LiquidityEvent, outcome string, shares float64) float64
+	// Shares returns the shares of its outcome each bet holds, negative for
+	// a sale, given the probabilities Probabilities returned for the bets
+	Shares(bets []models.Bet, probabilities []wpam.ProbabilityChange, liquidity ...models.LiquidityEvent) []int64
+}
+
+// ForMarket returns the market maker the market was created with
+func ForMarket(market *models.Market) MarketMaker {
+	return New(market.MarketMaker, market.LiquidityParameter)
+}
+
+// New returns the named market maker; liquidity is only used by LMSR.
+// Markets from before market makers could be chosen have no name and use WPAM.
+func New(name string, liquidity float64) MarketMaker {
+	if name == MakerLMSR {
+		return LMSR{Liquidity: liquidity}
+	}
+	return WPAM{}
+}
+
+// CurrentProbability returns the market's YES probability after bets
+func CurrentProbability(market *models.Market, bets []models.Bet, liquidity ...models.LiquidityEvent) float64 {
+	return wpam.GetCurrentProbability(ForMarket(market).Probabilities(market.CreatedAt, bets, liquidity...))
+}
+
+// ProjectProbability returns the market's YES probability if newBet were
+// placed after bets
+func ProjectProbability(market *models.Market, bets []models.Bet, newBet models.Bet, liquidity ...models.LiquidityEvent) float64 {
+	all := append(append([]models.Bet{}, bets...), newBet)
+	return CurrentProbability(market, all, liquidity...)
+}
+
+// ValidateMarketMaker normalizes the market maker chosen for a new market,
+// defaulting to WPAM, and gives an LMSR market defaultLiquidity when its
+// creator did not choose a liquidity parameter
+func ValidateMarketMaker(market *models.Market, defaultLiquidity float64) error {
+	market.MarketMaker = strings.ToUpper(strings.TrimSpace(market.MarketMaker))
+	switch market.MarketMaker {
+	case "", MakerWPAM:
+		market.MarketMaker = MakerWPAM
+		if market.LiquidityParameter != 0 {
+			return ErrLiquidityWithoutLMSR
+		}
+		return nil
+	case MakerLMSR:
+	default:
+		return ErrUnknownMarketMaker
+	}
+
+	if market.IsCategorical() {
+		return ErrLMSRBinaryOnly
+	}
+	if market.LiquidityParameter == 0 {
+		market.LiquidityParameter = defaultLiquidity
+	}
+	if market.LiquidityParameter <= 0 || math.IsNaN(market.LiquidityParameter) || math.IsInf(market.LiquidityParameter, 0) {
+		return ErrInvalidLiquidity
+	}
+	return nil
+}
+
+// WPAM is the weighted probability averaging market maker
+type WPAM struct{}
+
+func (WPAM) Name() string { return MakerWPAM }
+
+func (WPAM) Probabilities(createdAt time.Time, bets []models.Bet, liquidity ...models.LiquidityEvent) []wpam.ProbabilityChange {
+	return wpam.CalculateMarketProbabilitiesWPAM(createdAt, bets, liquidity...)
+}
+
+// SaleProceeds prices the sale against a constant-product pool as deep as
+// the market's volume, subsidy and provided liquidity
+func (WPAM) SaleProceeds(probability float64, bets []models.Bet, liquidity []models.LiquidityEvent, outcome string, shares float64) float64 {
+	depth := float64(GetEndMarketVolume(bets))
+	for _, event := range liquidity {
+		depth += liquidityCredits(event)
+	}
+	cash, _ := cpmm.PoolAt(probability, depth).Sell(outcome, shares)
+	return cash
+}
+
+// Shares divides the market's pool between the bets with the
+// divergence-based payout model
+func (WPAM) Shares(bets []models.Bet, probabilities []wpam.ProbabilityChange, liquidity ...models.LiquidityEvent) []int64 {
+	return dbpm.CalculateBetSharesDBPM(bets, probabilities)
+}
+
+// LMSR is the logarithmic market scoring rule market maker. Its cost
+// function is C(y, n) = b·ln(e^(y/b) + e^(n/b)) over the YES and NO shares
+// sold, so a bet of a credits on YES leaves 1-p' = (1-p)·e^(-a/b), and a
+// sale of s YES shares leaves p' = p·e^(-s/b) / (p·e^(-s/b) + 1-p). The most
+// the market maker can lose is b·ln 2, so provided liquidity L raises b by
+// L/ln 2.
+type LMSR struct {
+	Liquidity float64 // b, in credits
+}
+
+func (LMSR) Name() string { return MakerLMSR }
+
+func (m LMSR) Probabilities(createdAt time.Time, bets []models.Bet, liquidity ...models.LiquidityEvent) []wpam.ProbabilityChange {
+	p := appConfig.Economics.MarketCreation.InitialMarketProbability
+	b := m.Liquidity
+	changes := []wpam.ProbabilityChange{{Probability: p, Timestamp: createdAt}}
+
+	next := 0
+	for _, bet := range bets {
+		for ; next < len(liquidity) && !liquidity[next].CreatedAt.After(bet.PlacedAt); next++ {
+			b += liquidityCredits(liquidity[next]) / math.Ln2
+		}
+		p = lmsrTrade(p, b, bet.Outcome, float64(bet.Amount))
+		changes = append(changes, wpam.ProbabilityChange{Probability: p, Timestamp: bet.PlacedAt})
+	}
+	return changes
+}
+
+// SaleProceeds returns C(q) - C(q - shares), which for YES is
+// -b·ln(1 - p + p·e^(-shares/b))
+func (m LMSR) SaleProceeds(probability float64, bets []models.Bet, liquidity []models.LiquidityEvent, outcome string, shares float64) float64 {
+	if shares <= 0 {
+		return 0
+	}
+	b := m.Liquidity
+	for _, event := range liquidity {
+		b += liquidityCredits(event) / math.Ln2
+	}
+	p := probability
+	if outcome == "NO" {
+		p = 1 - probability
+	}
+	return -b * math.Log(1-p+p*math.Exp(-shares/b))
+}
+
+// Shares returns the shares each bet bought from the market maker. A bet of
+// a credits on YES moving the probability from p to p' buys the share count
+// that raises the logit by (logit p' - logit p), which is a + b·ln(p'/p).
+// Sales already record the shares sold.
+func (m LMSR) Shares(bets []models.Bet, probabilities []wpam.ProbabilityChange, liquidity ...models.LiquidityEvent) []int64 {
+	shares := make([]int64, len(bets))
+	b := m.Liquidity
+	next := 0
+	for i, bet := range bets {
+		for ; next < len(liquidity) && !liquidity[next].CreatedAt.After(bet.PlacedAt); next++ {
+			b += liquidityCredits(liquidity[next]) / math.Ln2
+		}
+		if bet.Amount <= 0 || i+1 >= len(probabilities) {
+			shares[i] = bet.Amount
+			continue
+		}
+		before, after := probabilities[i].Probability, probabilities[i+1].Probability
+		if bet.Outcome == "NO" {
+			before, after = 1-before, 1-after
+		}
+		shares[i] = int64(math.Round(float64(bet.Amount) + b*math.Log(after/before)))
+	}
+	return shares
+}
+
+// lmsrTrade returns the YES probability after a trade on outcome. A
+// positive amount is a bet of that many credits; a negative amount is a sale
+// of that many shares, which lowers the odds of the outcome sold by e^(s/b).
+func lmsrTrade(p, b float64, outcome string, amount float64) float64 {
+	if amount < 0 {
+		fall := math.Exp(amount / b)
+		switch outcome {
+		case "YES":
+			p = p * fall / (p*fall + 1 - p)
+		case "NO":
+			p = p / (p + (1-p)*fall)
+		}
+	} else {
+		switch outcome {
+		case "YES":
+			p = 1 - (1-p)*math.Exp(-amount/b)
+		case "NO":
+			p = p * math.Exp(-amount/b)
+		}
+	}
+	return math.Min(math.Max(p, lmsrPriceBound), 1-lmsrPriceBound)
+}
+
+func liquidityCredits(event models.LiquidityEvent) float64 {
+	return float64(event.Amount) / float64(models.MicroCreditsPerCredit)
+}
@@ -0,0 +1,173 @@
+package marketmath
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/models"
+)
+
+func TestForMarketDefaultsToWPAM(t *testing.T) {
+	created := time.Now()
+	bets := []models.Bet{
+		{Amount: 20, Outcome: "YES", PlacedAt: created.Add(time.Minute)},
+		{Amount: 10, Outcome: "NO", PlacedAt: created.Add(2 * time.Minute)},
+	}
+	market := &models.Market{}
+	market.CreatedAt = created
+
+	if name := ForMarket(market).Name(); name != MakerWPAM {
+		t.Fatalf("market maker = %s, want WPAM", name)
+	}
+	want := wpam.GetCurrentProbability(wpam.CalculateMarketProbabilitiesWPAM(created, bets))
+	if got := CurrentProbability(market, bets); got != want {
+		t.Errorf("CurrentProbability = %v, want %v", got, want)
+	}
+}
+
+func TestLMSRProbabilities(t *testing.T) {
+	const b = 100.0
+	created := time.Now()
+	bets := []models.Bet{
+		{Amount: 100, Outcome: "YES", PlacedAt: created.Add(time.Minute)},
+		{Amount: 100, Outcome: "NO", PlacedAt: created.Add(2 * time.Minute)},
+	}
+	changes := LMSR{Liquidity: b}.Probabilities(created, bets)
+	if len(changes) != 3 || changes[0].Probability != 0.5 {
+		t.Fatalf("changes = %+v, want the opening probability and one per bet", changes)
+	}
+
+	// A bet costs the change in C = b·ln(e^(y/b) + e^(n/b)), which for YES
+	// shares is b·ln((1-p)/(1-p'))
+	p0, p1, p2 := changes[0].Probability, changes[1].Probability, changes[2].Probability
+	if cost := b * math.Log((1-p0)/(1-p1)); math.Abs(cost-100) > 1e-9 {
+		t.Errorf("YES bet cost %v, want 100", cost)
+	}
+	if cost := b * math.Log(p1/p2); math.Abs(cost-100) > 1e-9 {
+		t.Errorf("NO bet cost %v, want 100", cost)
+	}
+
+	// Deeper markets move less
+	deep := LMSR{Liquidity: 10 * b}.Probabilities(created, bets[:1])
+	if deep[1].Probability >= p1 {
+		t.Errorf("b=%v moved to %v, not less than b=%v's %v", 10*b, deep[1].Probability, b, p1)
+	}
+
+	// Liquidity provided before a bet deepens the market for it
+	provided := []models.LiquidityEvent{{Amount: models.CreditsToMicro(500), CreatedAt: created}}
+	withLiquidity := LMSR{Liquidity: b}.Probabilities(created, bets[:1], provided...)
+	if withLiquidity[1].Probability >= p1 {
+		t.Errorf("liquidity did not deepen the market: %v, want below %v", withLiquidity[1].Probability, p1)
+	}
+}
+
+func TestLMSRSaleReturnsPurchaseCost(t *testing.T) {
+	const b = 50.0
+	maker := LMSR{Liquidity: b}
+	for _, outcome := range []string{"YES", "NO"} {
+		bets := []models.Bet{{Amount: 40, Outcome: outcome}}
+		changes := maker.Probabilities(time.Now(), bets)
+		before, after := changes[0].Probability, changes[1].Probability
+
+		// The shares bought are the change in log-odds, times b
+		logOdds := func(p float64) float64 { return b * math.Log(p/(1-p)) }
+		shares := math.Abs(logOdds(after) - logOdds(before))
+
+		if cash := maker.SaleProceeds(after, bets, nil, outcome, shares); math.Abs(cash-40) > 1e-9 {
+			t.Errorf("selling back %s = %v credits, want the 40 paid", outcome, cash)
+		}
+	}
+	if cash := maker.SaleProceeds(0.5, nil, nil, "YES", 0); cash != 0 {
+		t.Errorf("selling no shares = %v, want 0", cash)
+	}
+}
+
+func TestLMSRSaleReturnsPurchasePrice(t *testing.T) {
+	const b = 100.0
+	maker := LMSR{Liquidity: b}
+	created := time.Now()
+
+	// Selling 50 YES shares at 0.5 leaves e^(-1/2) / (e^(-1/2) + 1)
+	sale := maker.Probabilities(created, []models.Bet{{Amount: -50, Outcome: "YES", PlacedAt: created}})
+	if want := 1 / (1 + math.Exp(0.5)); math.Abs(sale[1].Probability-want) > 1e-9 {
+		t.Errorf("selling 50 YES shares at 0.5 = %v, want %v", sale[1].Probability, want)
+	}
+
+	for _, outcome := range []string{"YES", "NO"} {
+		buy := models.Bet{Amount: 40, Outcome: outcome, PlacedAt: created.Add(time.Minute)}
+		changes := maker.Probabilities(created, []models.Bet{buy})
+		shares := maker.Shares([]models.Bet{buy}, changes)[0]
+
+		sell := models.Bet{Amount: -shares, Outcome: outcome, PlacedAt: created.Add(2 * time.Minute)}
+		roundTrip := maker.Probabilities(created, []models.Bet{buy, sell})
+		// Shares are whole, so the price comes back to within half a share's
+		// move, which is at most 1/(8b)
+		if got := roundTrip[2].Probability; math.Abs(got-changes[0].Probability) > 1/(8*b) {
+			t.Errorf("%s: buying %d shares and selling them left %v, want %v", outcome, shares, got, changes[0].Probability)
+		}
+	}
+}
+
+func TestValidateMarketMaker(t *testing.T) {
+	tests := []struct {
+		name          string
+		market        models.Market
+		wantErr       error
+		wantMaker     string
+		wantLiquidity float64
+	}{
+		{name: "defaults to WPAM", market: models.Market{}, wantMaker: MakerWPAM},
+		{name: "LMSR takes default liquidity", market: models.Market{MarketMaker: " lmsr "}, wantMaker: MakerLMSR, wantLiquidity: 100},
+		{name: "LMSR with liquidity", market: models.Market{MarketMaker: "LMSR", LiquidityParameter: 250}, wantMaker: MakerLMSR, wantLiquidity: 250},
+		{name: "negative liquidity", market: models.Market{MarketMaker: "LMSR", LiquidityParameter: -1}, wantErr: ErrInvalidLiquidity},
+		{name: "liquidity on WPAM", market: models.Market{LiquidityParameter: 10}, wantErr: ErrLiquidityWithoutLMSR},
+		{name: "categorical LMSR", market: models.Market{MarketMaker: "LMSR", OutcomeType: models.OutcomeTypeCategorical}, wantErr: ErrLMSRBinaryOnly},
+		{name: "unknown", market: models.Market{MarketMaker: "CPMM"}, wantErr: ErrUnknownMarketMaker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMarketMaker(&tt.market, 100)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.market.MarketMaker != tt.wantMaker || tt.market.LiquidityParameter != tt.wantLiquidity {
+				t.Errorf("market = %s b=%v, want %s b=%v", tt.market.MarketMaker, tt.market.LiquidityParameter, tt.wantMaker, tt.wantLiquidity)
+			}
+		})
+	}
+}
+
+func TestLMSRShares(t *testing.T) {
+	const b = 100.0
+	created := time.Now()
+	bets := []models.Bet{
+		{Amount: 100, Outcome: "YES", PlacedAt: created.Add(time.Minute)},
+		{Amount: 50, Outcome: "NO", PlacedAt: created.Add(2 * time.Minute)},
+		{Amount: -30, Outcome: "YES", PlacedAt: created.Add(3 * time.Minute)},
+	}
+	maker := LMSR{Liquidity: b}
+	shares := maker.Shares(bets, maker.Probabilities(created, bets))
+	if len(shares) != 3 {
+		t.Fatalf("shares = %v, want one per bet", shares)
+	}
+
+	// Buying y YES then n NO shares from an empty market costs
+	// C(y, 0) - C(0, 0) and then C(y, n) - C(y, 0)
+	cost := func(y, n float64) float64 { return b * math.Log(math.Exp(y/b)+math.Exp(n/b)) }
+	y, n := float64(shares[0]), float64(shares[1])
+	if paid := cost(y, 0) - cost(0, 0); math.Abs(paid-100) > 1 {
+		t.Errorf("%d YES shares cost %v, want 100", shares[0], paid)
+	}
+	if paid := cost(y, n) - cost(y, 0); math.Abs(paid-50) > 1 {
+		t.Errorf("%d NO shares cost %v, want 50", shares[1], paid)
+	}
+	if shares[2] != -30 {
+		t.Errorf("sale = %d shares, want the 30 it recorded", shares[2])
+	}
+}
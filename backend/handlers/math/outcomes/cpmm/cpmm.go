@@ -0,0 +1,52 @@
+// Package cpmm prices trades against a constant-product market maker.
+package cpmm
+
+import "math"
+
+// Pool is a constant-product pool of YES and NO shares. Trades keep
+// Yes*No constant, and the price of YES is No/(Yes+No).
+type Pool struct {
+	Yes float64
+	No  float64
+}
+
+// PoolAt returns a pool of the given depth quoting probability for YES.
+func PoolAt(probability, depth float64) Pool {
+	return Pool{Yes: (1 - probability) * depth, No: probability * depth}
+}
+
+// Price returns the pool's current price for outcome, between 0 and 1.
+func (p Pool) Price(outcome string) float64 {
+	total := p.Yes + p.No
+	if total <= 0 {
+		return 0
+	}
+	if outcome == "NO" {
+		return p.Yes / total
+	}
+	return p.No / total
+}
+
+// Sell returns the cash paid for selling shares of outcome back into the
+// pool, and the pool after the trade. The shares are added to their side of
+// the pool and cash is taken out of both sides so the product is unchanged,
+// so each further share sells for less than the last.
+func (p Pool) Sell(outcome string, shares float64) (float64, Pool) {
+	if shares <= 0 {
+		return 0, p
+	}
+	same, other := p.Yes, p.No
+	if outcome == "NO" {
+		same, other = p.No, p.Yes
+	}
+
+	// Solve (same+shares-cash)(other-cash) = same*other for the smaller root
+	b := same + shares + other
+	cash := (b - math.Sqrt(b*b-4*shares*other)) / 2
+
+	same, other = same+shares-cash, other-cash
+	if outcome == "NO" {
+		return cash, Pool{Yes: other, No: same}
+	}
+	return cash, Pool{Yes: same, No: other}
+}
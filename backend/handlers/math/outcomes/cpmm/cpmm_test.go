@@ -0,0 +1,44 @@
+package cpmm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPoolAtQuotesProbability(t *testing.T) {
+	pool := PoolAt(0.7, 100)
+	if got := pool.Price("YES"); math.Abs(got-0.7) > 1e-9 {
+		t.Fatalf("YES price = %v, want 0.7", got)
+	}
+	if got := pool.Price("NO"); math.Abs(got-0.3) > 1e-9 {
+		t.Fatalf("NO price = %v, want 0.3", got)
+	}
+}
+
+func TestSellHasPriceImpact(t *testing.T) {
+	pool := PoolAt(0.5, 100)
+
+	small, _ := pool.Sell("YES", 1)
+	if math.Abs(small-0.5) > 0.01 {
+		t.Fatalf("small sale paid %v, want about the spot price 0.5", small)
+	}
+
+	cash, after := pool.Sell("YES", 50)
+	if cash >= 25 {
+		t.Fatalf("large sale paid %v, want less than 50 shares at spot", cash)
+	}
+	if after.Price("YES") >= 0.5 {
+		t.Fatalf("YES price after sale = %v, want below 0.5", after.Price("YES"))
+	}
+	if got, want := after.Yes*after.No, pool.Yes*pool.No; math.Abs(got-want) > 1e-6 {
+		t.Fatalf("product after sale = %v, want %v", got, want)
+	}
+}
+
+func TestSellNoMirrorsYes(t *testing.T) {
+	yesCash, _ := PoolAt(0.3, 100).Sell("YES", 20)
+	noCash, _ := PoolAt(0.7, 100).Sell("NO", 20)
+	if math.Abs(yesCash-noCash) > 1e-9 {
+		t.Fatalf("YES sale paid %v but mirrored NO sale paid %v", yesCash, noCash)
+	}
+}
@@ -3,7 +3,6 @@ package dbpm
 import (
 	"log"
 	"math"
-	marketmath "socialpredict/handlers/math/market"
 	"socialpredict/handlers/math/probabilities/wpam"
 	"socialpredict/models"
 	"socialpredict/setup"
@@ -44,14 +43,14 @@ func DivideUpMarketPoolSharesDBPM(bets []models.Bet, probabilityChanges []wpam.P
 
 	// Get the total share pool as a float for precision
 	// Do not include the initial market subsidization in volume until market hits final resolution
-	totalSharePool := float64(marketmath.GetMarketVolume(bets))
+	totalSharePool := float64(marketVolume(bets))
 
 	// Initial condition, shares set to zero
 	yesShares := int64(0)
 	noShares := int64(0)
 
 	// Check case where there is only one bet
-	if marketmath.GetMarketVolume(bets) == 1 {
+	if marketVolume(bets) == 1 {
 		yesShares, noShares = singleCreditYesNoAllocator(bets)
 	} else {
 		// Calculate YES and NO pools using floating-point arithmetic
@@ -148,7 +147,7 @@ func calculateExcess(bets []models.Bet, scaledPayouts []int64) int64 {
 	for _, payout := range scaledPayouts {
 		sumScaledPayouts += payout
 	}
-	availablePool := marketmath.GetMarketVolume(bets)
+	availablePool := marketVolume(bets)
 	return sumScaledPayouts - availablePool
 }
 
@@ -210,6 +209,16 @@ func adjustForNegativeExcess(scaledPayouts []int64, excess int64) []int64 {
 	return scaledPayouts
 }
 
+// CalculateBetSharesDBPM runs the DBPM pipeline, returning the shares each
+// bet holds once the market's pool is divided up at its current probability
+func CalculateBetSharesDBPM(bets []models.Bet, probabilityChanges []wpam.ProbabilityChange) []int64 {
+	yesShares, noShares := DivideUpMarketPoolSharesDBPM(bets, probabilityChanges)
+	coursePayouts := CalculateCoursePayoutsDBPM(bets, probabilityChanges)
+	yesFactor, noFactor := CalculateNormalizationFactorsDBPM(yesShares, noShares, coursePayouts)
+	scaledPayouts := CalculateScaledPayoutsDBPM(bets, coursePayouts, yesFactor, noFactor)
+	return AdjustPayouts(bets, scaledPayouts)
+}
+
 // AdjustPayouts reconciles the additional or lacking funds from the betting pool by adjusting the payouts to past bets
 func AdjustPayouts(bets []models.Bet, scaledPayouts []int64) []int64 {
 	excess := calculateExcess(bets, scaledPayouts)
@@ -301,3 +310,13 @@ func singleCreditYesNoAllocator(bets []models.Bet) (yesShares int64, noShares in
 	// If equal or ambiguous, assign to neither (fallback)
 	return 0, 0
 }
+
+// marketVolume sums the bets' amounts like marketmath.GetMarketVolume, which
+// dbpm cannot import since marketmath's WPAM market maker allocates shares here
+func marketVolume(bets []models.Bet) int64 {
+	var total int64
+	for _, bet := range bets {
+		total += bet.Amount
+	}
+	return total
+}
@@ -0,0 +1,83 @@
+package payout
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+
+	"gorm.io/gorm"
+)
+
+// allocateCategoricalPayouts splits a categorical market's pool among the
+// holders of the winning outcome's shares. The last winner takes the
+// rounding, so the whole pool is paid out.
+func allocateCategoricalPayouts(market *models.Market, db *gorm.DB, now time.Time) error {
+	var labels []string
+	for _, outcome := range tradingdata.GetOutcomesForMarket(db, market.ID) {
+		labels = append(labels, outcome.Label)
+	}
+	if !containsLabel(labels, market.ResolutionResult) {
+		return fmt.Errorf("unsupported resolution result: %q", market.ResolutionResult)
+	}
+
+	bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
+	positions, pool := positionsmath.CalculateCategoricalPositions(market.CreatedAt, labels, bets)
+
+	betIDs := make(map[string][]string)
+	for _, bet := range bets {
+		if bet.Outcome == market.ResolutionResult {
+			betIDs[bet.Username] = append(betIDs[bet.Username], "#"+strconv.FormatUint(uint64(bet.ID), 10))
+		}
+	}
+
+	var winners []positionsmath.CategoricalPosition
+	var winningShares float64
+	for _, pos := range positions {
+		if pos.Outcome == market.ResolutionResult {
+			winners = append(winners, pos)
+			winningShares += pos.Shares
+		}
+	}
+
+	total := models.CreditsToMicro(pool)
+	var paid int64
+	for i, pos := range winners {
+		amount := int64(math.Floor(float64(total) * pos.Shares / winningShares))
+		if i == len(winners)-1 {
+			amount = total - paid
+		}
+		paid += amount
+		if amount <= 0 {
+			continue
+		}
+		if err := credit(db, pos.Username, ledger.Posting{
+			Type:          models.LedgerTypeMarketPayout,
+			Amount:        amount,
+			ReferenceType: referenceTypeMarket,
+			ReferenceID:   uint(market.ID),
+			MarketID:      &market.ID,
+			Description: fmt.Sprintf("Market #%d resolved %s; bets %s",
+				market.ID, market.ResolutionResult, strings.Join(betIDs[pos.Username], ", ")),
+		}, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -3,24 +3,55 @@ package payout
 import (
 	"errors"
 	"fmt"
+	"math"
 	positionsmath "socialpredict/handlers/math/positions"
-	usersHandlers "socialpredict/handlers/users"
 	"socialpredict/models"
+	"socialpredict/services/creatorfees"
+	"socialpredict/services/ledger"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/settlement"
 	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-func DistributePayoutsWithRefund(market *models.Market, db *gorm.DB) error {
+// Ledger reference types for settlement entries
+const (
+	referenceTypeMarket = "market"
+	referenceTypeBet    = "bet"
+)
+
+// DistributePayoutsWithRefund settles a resolved market. Each payout or
+// refund is booked as a ledger entry referencing the market, in a single
+// transaction so a market is never left half paid. now dates the settlement.
+func DistributePayoutsWithRefund(market *models.Market, db *gorm.DB, now time.Time) error {
 	if market == nil {
 		return errors.New("market is nil")
 	}
 
+	if market.IsCategorical() && market.ResolutionResult != "N/A" {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := allocateCategoricalPayouts(market, tx, now); err != nil {
+				return err
+			}
+			return creatorfees.Settle(tx, market, now)
+		})
+	}
+
 	switch market.ResolutionResult {
 	case "N/A":
-		return refundAllBets(market, db)
+		return db.Transaction(func(tx *gorm.DB) error {
+			return voidMarket(market, tx, now)
+		})
 	case "YES", "NO":
-		return calculateAndAllocateProportionalPayouts(market, db)
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := calculateAndAllocateProportionalPayouts(market, tx, now); err != nil {
+				return err
+			}
+			return creatorfees.Settle(tx, market, now)
+		})
 	case "PROB":
 		return fmt.Errorf("probabilistic resolution is not yet supported")
 	default:
@@ -28,41 +59,91 @@ func DistributePayoutsWithRefund(market *models.Market, db *gorm.DB) error {
 	}
 }
 
-func calculateAndAllocateProportionalPayouts(market *models.Market, db *gorm.DB) error {
-	// Step 1: Convert market ID formats
-	marketIDStr := strconv.FormatInt(market.ID, 10)
-
-	// Step 2: Calculate market positions with resolved valuation
-	displayPositions, err := positionsmath.CalculateMarketPositions_WPAM_DBPM(db, marketIDStr)
+func calculateAndAllocateProportionalPayouts(market *models.Market, db *gorm.DB, now time.Time) error {
+	// Step 1: Calculate market positions with resolved valuation, priced by the
+	// market's own market maker
+	displayPositions, err := positionsmath.CalculateMarketPositions(db, market)
 	if err != nil {
 		return err
 	}
 
-	// Step 3: Pay out each user their resolved valuation
-	for _, pos := range displayPositions {
-		if pos.Value > 0 {
-			if err := usersHandlers.ApplyTransactionToUser(pos.Username, pos.Value, db, usersHandlers.TransactionWin); err != nil {
-				return err
-			}
-		}
+	// Step 2: Collect each user's bets, so the payout entry can name them
+	var bets []models.Bet
+	if err := db.Where("market_id = ?", market.ID).Order("id").Find(&bets).Error; err != nil {
+		return err
+	}
+	betIDs := make(map[string][]string)
+	for _, bet := range bets {
+		betIDs[bet.Username] = append(betIDs[bet.Username], "#"+strconv.FormatUint(uint64(bet.ID), 10))
 	}
 
-	return nil
-}
-
-func refundAllBets(market *models.Market, db *gorm.DB) error {
-	// Retrieve all bets associated with the market
-	var bets []models.Bet
-	if err := db.Where("market_id = ?", market.ID).Find(&bets).Error; err != nil {
+	// Step 3: Settle liquidity providers, whose P&L comes out of or goes to the winners
+	var winnersPool int64
+	for _, pos := range displayPositions {
+		winnersPool += models.CreditsToMicro(max(pos.Value, 0))
+	}
+	providersTook, err := liquidity.Settle(db, market, winnersPool, now)
+	if err != nil {
 		return err
 	}
+	payouts := scalePayouts(displayPositions, winnersPool, winnersPool-providersTook)
 
-	// Refund each bet to the user
-	for _, bet := range bets {
-		if err := usersHandlers.ApplyTransactionToUser(bet.Username, bet.Amount, db, usersHandlers.TransactionRefund); err != nil {
+	// Step 4: Pay out each user their resolved valuation
+	for i, pos := range displayPositions {
+		if payouts[i] <= 0 {
+			continue
+		}
+		if err := credit(db, pos.Username, ledger.Posting{
+			Type:          models.LedgerTypeMarketPayout,
+			Amount:        payouts[i],
+			ReferenceType: referenceTypeMarket,
+			ReferenceID:   uint(market.ID),
+			MarketID:      &market.ID,
+			Description: fmt.Sprintf("Market #%d resolved %s; bets %s",
+				market.ID, market.ResolutionResult, strings.Join(betIDs[pos.Username], ", ")),
+		}, now); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// scalePayouts returns each position's payout in micro-credits, scaled so
+// they sum to total instead of pool. The last winner takes the rounding.
+func scalePayouts(positions []positionsmath.MarketPosition, pool, total int64) []int64 {
+	payouts := make([]int64, len(positions))
+	last := -1
+	var paid int64
+	for i, pos := range positions {
+		if pos.Value <= 0 {
+			continue
+		}
+		payouts[i] = models.CreditsToMicro(pos.Value)
+		if pool != total {
+			payouts[i] = int64(math.Round(float64(payouts[i]) * float64(total) / float64(pool)))
+		}
+		paid += payouts[i]
+		last = i
+	}
+	if last >= 0 {
+		payouts[last] += total - paid
+	}
+	return payouts
+}
+
+// credit applies a settlement posting to the named user. Winnings are held
+// for the settlement delay, counted from now, before they can be withdrawn.
+func credit(db *gorm.DB, username string, p ledger.Posting, now time.Time) error {
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return fmt.Errorf("user lookup failed: %w", err)
+	}
+	if _, err := ledger.Apply(db, &user, p); err != nil {
+		return err
+	}
+	if p.Type != models.LedgerTypeMarketPayout {
+		return nil
+	}
+	return settlement.Hold(db, &user, *p.MarketID, p.Amount, settlement.LoadConfigFromEnv(), now)
+}
@@ -2,6 +2,7 @@ package payout
 
 import (
 	"testing"
+	"time"
 
 	"socialpredict/models"
 	modelstesting "socialpredict/models/modelstesting"
@@ -19,7 +20,7 @@ func TestDistributePayoutsWithRefund_NARefund(t *testing.T) {
 	bet := modelstesting.GenerateBet(50, "YES", "refundbot", uint(market.ID), 0)
 	db.Create(&bet)
 
-	err := DistributePayoutsWithRefund(&market, db)
+	err := DistributePayoutsWithRefund(&market, db, time.Now())
 	if err != nil {
 		t.Fatalf("expected no error for N/A refund, got: %v", err)
 	}
@@ -31,7 +32,7 @@ func TestDistributePayoutsWithRefund_NARefund(t *testing.T) {
 	}
 
 	expectedBalance := int64(50) // Should get the bet amount back
-	if updatedUser.AccountBalance != expectedBalance {
+	if updatedUser.AccountBalance != models.CreditsToMicro(expectedBalance) {
 		t.Errorf("refundbot balance = %d, want %d", updatedUser.AccountBalance, expectedBalance)
 	}
 }
@@ -42,7 +43,7 @@ func TestDistributePayoutsWithRefund_UnknownResolution(t *testing.T) {
 	market.ResolutionResult = "MAYBE" // Invalid
 	db.Create(&market)
 
-	err := DistributePayoutsWithRefund(&market, db)
+	err := DistributePayoutsWithRefund(&market, db, time.Now())
 	if err == nil {
 		t.Fatal("expected error for unknown resolution result")
 	}
@@ -62,7 +63,7 @@ func TestCalculateAndAllocateProportionalPayouts_NoWinningShares(t *testing.T) {
 	bet := modelstesting.GenerateBet(100, "NO", "loserbot", uint(market.ID), 0)
 	db.Create(&bet)
 
-	err := calculateAndAllocateProportionalPayouts(&market, db)
+	err := calculateAndAllocateProportionalPayouts(&market, db, time.Now())
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -73,7 +74,7 @@ func TestCalculateAndAllocateProportionalPayouts_NoWinningShares(t *testing.T) {
 	}
 
 	expectedBalance := int64(0)
-	if u.AccountBalance != expectedBalance {
+	if u.AccountBalance != models.CreditsToMicro(expectedBalance) {
 		t.Errorf("loserbot balance = %d, want %d", u.AccountBalance, expectedBalance)
 	}
 }
@@ -91,7 +92,7 @@ func TestCalculateAndAllocateProportionalPayouts_SuccessfulPayout(t *testing.T)
 	bet := modelstesting.GenerateBet(100, "YES", "winnerbot", uint(market.ID), 0)
 	db.Create(&bet)
 
-	err := calculateAndAllocateProportionalPayouts(&market, db)
+	err := calculateAndAllocateProportionalPayouts(&market, db, time.Now())
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -103,7 +104,202 @@ func TestCalculateAndAllocateProportionalPayouts_SuccessfulPayout(t *testing.T)
 
 	// At resolution YES, winner gets full payout back from total volume
 	expectedBalance := int64(100)
-	if u.AccountBalance != expectedBalance {
+	if u.AccountBalance != models.CreditsToMicro(expectedBalance) {
 		t.Errorf("winnerbot balance = %d, want %d", u.AccountBalance, expectedBalance)
 	}
 }
+
+func TestDistributePayoutsWritesLedgerEntries(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(7, "creator")
+	market.ResolutionResult = "N/A"
+	db.Create(&market)
+
+	user := modelstesting.GenerateUser("refundbot", 0)
+	db.Create(&user)
+	first := modelstesting.GenerateBet(50, "YES", "refundbot", uint(market.ID), 0)
+	second := modelstesting.GenerateBet(20, "NO", "refundbot", uint(market.ID), 0)
+	db.Create(&first)
+	db.Create(&second)
+
+	if err := DistributePayoutsWithRefund(&market, db, time.Now()); err != nil {
+		t.Fatalf("DistributePayoutsWithRefund: %v", err)
+	}
+
+	var entries []models.LedgerEntry
+	db.Where("market_id = ?", market.ID).Order("id").Find(&entries)
+	if len(entries) != 2 {
+		t.Fatalf("got %d ledger entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Type != models.LedgerTypeMarketRefund || e.ReferenceType != referenceTypeBet ||
+		e.ReferenceID != first.ID || e.Amount != models.CreditsToMicro(50) {
+		t.Errorf("first entry = %+v", e)
+	}
+	if entries[1].BalanceAfter != models.CreditsToMicro(70) {
+		t.Errorf("balance after refunds = %d", entries[1].BalanceAfter)
+	}
+}
+
+func TestResolutionSettlesLiquidityAgainstWinners(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(8, "creator")
+	market.ResolutionResult = "YES"
+	market.IsResolved = true
+	db.Create(&market)
+
+	for _, name := range []string{"provider", "winner", "loser"} {
+		user := modelstesting.GenerateUser(name, 0)
+		db.Create(&user)
+	}
+	var provider models.User
+	db.First(&provider, "username = ?", "provider")
+
+	// Liquidity added at an even market, before traders push it toward YES
+	stake := models.CreditsToMicro(100)
+	added := time.Now().Add(-time.Hour)
+	db.Create(&models.MarketLiquidity{MarketID: market.ID, UserID: provider.ID, Username: "provider", Stake: stake, Probability: 0.5})
+	db.Create(&models.LiquidityEvent{MarketID: market.ID, UserID: provider.ID, Amount: stake, Probability: 0.5, CreatedAt: added})
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(100, "YES", "winner", uint(market.ID), 0),
+		modelstesting.GenerateBet(20, "NO", "loser", uint(market.ID), 0),
+	} {
+		db.Create(&bet)
+	}
+
+	if err := DistributePayoutsWithRefund(&market, db, time.Now()); err != nil {
+		t.Fatalf("DistributePayoutsWithRefund: %v", err)
+	}
+
+	var returned, paid int64
+	db.Model(&models.LedgerEntry{}).Where("type = ?", models.LedgerTypeLiquidityReturn).Select("COALESCE(SUM(amount), 0)").Scan(&returned)
+	db.Model(&models.LedgerEntry{}).Where("type = ?", models.LedgerTypeMarketPayout).Select("COALESCE(SUM(amount), 0)").Scan(&paid)
+	if returned <= 0 || returned >= stake {
+		t.Errorf("provider got %d back, want a loss on a stake of %d", returned, stake)
+	}
+	if total := returned + paid; total != stake+models.CreditsToMicro(120) {
+		t.Errorf("settled %d, want stake plus bets %d", total, stake+models.CreditsToMicro(120))
+	}
+
+	var position models.MarketLiquidity
+	db.First(&position, "market_id = ?", market.ID)
+	if position.SettledAt == nil || position.Payout != returned {
+		t.Errorf("position = %+v", position)
+	}
+}
+
+func TestCategoricalResolutionPaysWinningOutcome(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(9, "creator")
+	market.OutcomeType = models.OutcomeTypeCategorical
+	market.ResolutionResult = "Alice"
+	market.IsResolved = true
+	db.Create(&market)
+	for i, label := range []string{"Alice", "Bob", "Carol"} {
+		db.Create(&models.MarketOutcome{MarketID: market.ID, Label: label, Position: i})
+	}
+
+	for _, name := range []string{"early", "late", "loser"} {
+		user := modelstesting.GenerateUser(name, 0)
+		db.Create(&user)
+	}
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(30, "Alice", "early", uint(market.ID), time.Second),
+		modelstesting.GenerateBet(20, "Bob", "loser", uint(market.ID), 2*time.Second),
+		modelstesting.GenerateBet(30, "Alice", "late", uint(market.ID), 3*time.Second),
+	} {
+		db.Create(&bet)
+	}
+
+	if err := DistributePayoutsWithRefund(&market, db, time.Now()); err != nil {
+		t.Fatalf("DistributePayoutsWithRefund: %v", err)
+	}
+
+	balances := make(map[string]int64)
+	for _, name := range []string{"early", "late", "loser"} {
+		var u models.User
+		db.First(&u, "username = ?", name)
+		balances[name] = u.WholeCredits()
+	}
+	if balances["loser"] != 0 {
+		t.Errorf("loser balance = %d, want 0", balances["loser"])
+	}
+	var paid int64
+	db.Model(&models.LedgerEntry{}).Where("type = ?", models.LedgerTypeMarketPayout).Select("COALESCE(SUM(amount), 0)").Scan(&paid)
+	if paid != models.CreditsToMicro(80) {
+		t.Errorf("winners got %d, want the whole pool of %d", paid, models.CreditsToMicro(80))
+	}
+	if balances["early"] <= balances["late"] {
+		t.Errorf("early bettor got %d, late bettor %d; want the cheaper shares to pay more", balances["early"], balances["late"])
+	}
+}
+
+func TestCategoricalResolutionRejectsUnknownOutcome(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(10, "creator")
+	market.OutcomeType = models.OutcomeTypeCategorical
+	market.ResolutionResult = "YES"
+	db.Create(&market)
+	db.Create(&models.MarketOutcome{MarketID: market.ID, Label: "Alice"})
+
+	if err := DistributePayoutsWithRefund(&market, db, time.Now()); err == nil {
+		t.Fatal("expected error for an outcome the market does not have")
+	}
+}
+
+func TestDistributePayoutsHoldsWinnings(t *testing.T) {
+	t.Setenv("SETTLEMENT_DELAY_HOURS", "12")
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(11, "creator")
+	market.ResolutionResult = "YES"
+	market.IsResolved = true
+	db.Create(&market)
+
+	user := modelstesting.GenerateUser("winnerbot", 0)
+	db.Create(&user)
+	bet := modelstesting.GenerateBet(100, "YES", "winnerbot", uint(market.ID), 0)
+	db.Create(&bet)
+
+	if err := DistributePayoutsWithRefund(&market, db, time.Now()); err != nil {
+		t.Fatalf("DistributePayoutsWithRefund: %v", err)
+	}
+
+	var u models.User
+	db.First(&u, user.ID)
+	if u.PendingBalance != models.CreditsToMicro(100) || u.WithdrawableMicroCredits() != 0 {
+		t.Errorf("pending %d, withdrawable %d; want the winnings held", u.PendingBalance, u.WithdrawableMicroCredits())
+	}
+	var hold models.PayoutHold
+	if err := db.First(&hold, "user_id = ?", user.ID).Error; err != nil {
+		t.Fatalf("no hold: %v", err)
+	}
+	if delay := hold.ReleaseAt.Sub(hold.CreatedAt); delay < 11*time.Hour || delay > 13*time.Hour {
+		t.Errorf("hold released after %v, want 12h", delay)
+	}
+}
+
+func TestDistributePayoutsHoldsWinningsFromResolutionTime(t *testing.T) {
+	t.Setenv("SETTLEMENT_DELAY_HOURS", "24")
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(9, "creator")
+	market.ResolutionResult = "YES"
+	market.IsResolved = true
+	db.Create(&market)
+
+	user := modelstesting.GenerateUser("heldbot", 0)
+	db.Create(&user)
+	bet := modelstesting.GenerateBet(100, "YES", "heldbot", uint(market.ID), 0)
+	db.Create(&bet)
+
+	now := time.Date(2026, 5, 8, 12, 0, 0, 0, time.UTC)
+	if err := DistributePayoutsWithRefund(&market, db, now); err != nil {
+		t.Fatalf("DistributePayoutsWithRefund: %v", err)
+	}
+
+	var hold models.PayoutHold
+	if err := db.Where("user_id = ? AND market_id = ?", user.ID, market.ID).First(&hold).Error; err != nil {
+		t.Fatalf("load payout hold: %v", err)
+	}
+	if want := now.Add(24 * time.Hour); !hold.ReleaseAt.Equal(want) {
+		t.Errorf("release at = %v, want %v", hold.ReleaseAt, want)
+	}
+}
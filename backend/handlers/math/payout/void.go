@@ -0,0 +1,202 @@
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/creatorfees"
+	"socialpredict/services/ledger"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+// ErrAlreadyResolved is returned when resolving or voiding a market that has
+// resolved
+var ErrAlreadyResolved = errors.New("market is already resolved")
+
+// Void resolves an open market N/A on behalf of actor, a resolver or admin,
+// and unwinds it: see voidMarket.
+func Void(db *gorm.DB, market *models.Market, actor, reason string, now time.Time) error {
+	if market.IsResolved {
+		return ErrAlreadyResolved
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		reason = strings.TrimSpace(reason)
+		if err := markResolved(tx, market, "N/A", now, map[string]interface{}{
+			"voided_at":   now,
+			"voided_by":   actor,
+			"void_reason": reason,
+		}); err != nil {
+			return err
+		}
+		market.VoidedAt = &now
+		market.VoidedBy = actor
+		market.VoidReason = reason
+		return DistributePayoutsWithRefund(market, tx, now)
+	})
+}
+
+// Resolve resolves an open market to outcome and pays it out in one
+// transaction
+func Resolve(db *gorm.DB, market *models.Market, outcome string, now time.Time) error {
+	if market.IsResolved {
+		return ErrAlreadyResolved
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := markResolved(tx, market, outcome, now, nil); err != nil {
+			return err
+		}
+		return DistributePayoutsWithRefund(market, tx, now)
+	})
+}
+
+// markResolved flips the market to resolved only if it is still open, so two
+// resolutions or voids racing each other cannot both pay it out. extra holds
+// further columns to set with it.
+func markResolved(tx *gorm.DB, market *models.Market, outcome string, now time.Time, extra map[string]interface{}) error {
+	updates := map[string]interface{}{
+		"is_resolved":                true,
+		"resolution_result":          outcome,
+		"final_resolution_date_time": now,
+	}
+	for column, value := range extra {
+		updates[column] = value
+	}
+	result := tx.Model(&models.Market{}).
+		Where("id = ? AND is_resolved = ? AND voided_at IS NULL", market.ID, false).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlreadyResolved
+	}
+	market.IsResolved = true
+	market.ResolutionResult = outcome
+	market.FinalResolutionDateTime = now
+	return nil
+}
+
+// voidMarket unwinds a market resolved N/A as if it had never traded: every
+// bettor gets back their stake, liquidity providers their liquidity, creator
+// fees still accruing are forfeited and any already paid are taken back, and
+// its open limit orders are cancelled.
+func voidMarket(market *models.Market, tx *gorm.DB, now time.Time) error {
+	if err := refundAllBets(market, tx, now); err != nil {
+		return err
+	}
+	if err := liquidity.Refund(tx, market, now); err != nil {
+		return err
+	}
+	if err := creatorfees.Forfeit(tx, market, now); err != nil {
+		return err
+	}
+	if err := reverseCreatorFees(market, tx); err != nil {
+		return err
+	}
+	if err := cancelOpenOrders(market, tx, now); err != nil {
+		return err
+	}
+	if market.VoidedAt == nil {
+		market.VoidedAt = &now
+		return tx.Model(&models.Market{}).Where("id = ?", market.ID).Update("voided_at", now).Error
+	}
+	return nil
+}
+
+// refundAllBets refunds each bettor's stake: what they paid for shares less
+// what they got back selling them. Refunds are booked against their
+// purchases, oldest first. Bettors who sold for more than they paid keep the
+// difference rather than being charged.
+func refundAllBets(market *models.Market, db *gorm.DB, now time.Time) error {
+	var bets []models.Bet
+	if err := db.Where("market_id = ?", market.ID).Order("id").Find(&bets).Error; err != nil {
+		return err
+	}
+
+	stakes := make(map[string]int64)
+	for _, bet := range bets {
+		stakes[bet.Username] += bet.Spend()
+	}
+
+	for _, bet := range bets {
+		amount := min(bet.Amount, stakes[bet.Username])
+		if amount <= 0 {
+			continue
+		}
+		stakes[bet.Username] -= amount
+		if err := credit(db, bet.Username, ledger.Posting{
+			Type:          models.LedgerTypeMarketRefund,
+			Amount:        models.CreditsToMicro(amount),
+			ReferenceType: referenceTypeBet,
+			ReferenceID:   bet.ID,
+			MarketID:      &market.ID,
+			Description:   fmt.Sprintf("Market #%d resolved N/A; refund of bet #%d", market.ID, bet.ID),
+		}, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reverseCreatorFees takes back the creator fees booked on the market
+func reverseCreatorFees(market *models.Market, db *gorm.DB) error {
+	var earned []struct {
+		UserID int64
+		Total  int64
+	}
+	if err := db.Model(&models.LedgerEntry{}).Select("user_id, SUM(amount) AS total").
+		Where("market_id = ? AND type IN ?", market.ID, []string{models.LedgerTypeCreatorFee, models.LedgerTypeCreatorFeeReversal}).
+		Group("user_id").Scan(&earned).Error; err != nil {
+		return err
+	}
+	for _, e := range earned {
+		if e.Total <= 0 {
+			continue
+		}
+		var user models.User
+		if err := db.First(&user, e.UserID).Error; err != nil {
+			return fmt.Errorf("user lookup failed: %w", err)
+		}
+		if _, err := ledger.Apply(db, &user, ledger.Posting{
+			Type:          models.LedgerTypeCreatorFeeReversal,
+			Amount:        -e.Total,
+			ReferenceType: referenceTypeMarket,
+			ReferenceID:   uint(market.ID),
+			MarketID:      &market.ID,
+			Description:   fmt.Sprintf("Market #%d resolved N/A; creator fees reversed", market.ID),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelOpenOrders cancels the market's resting limit orders and tells their
+// users. Orders hold no funds, so there is nothing to return.
+func cancelOpenOrders(market *models.Market, db *gorm.DB, now time.Time) error {
+	var orders []models.MarketOrder
+	if err := db.Where("market_id = ? AND status = ?", market.ID, models.OrderStatusOpen).Order("id").Find(&orders).Error; err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	if err := db.Model(&models.MarketOrder{}).Where("market_id = ? AND status = ?", market.ID, models.OrderStatusOpen).
+		Updates(map[string]interface{}{"status": models.OrderStatusCancelled, "cancel_reason": "Market voided", "closed_at": now}).Error; err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if err := notify.Send(db, order.UserID, notify.TypeOrderCancelled, "Order cancelled",
+			fmt.Sprintf("Your limit order #%d on market #%d was cancelled because the market was voided.", order.ID, market.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package payout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/ledger"
+)
+
+func TestVoidUnwindsMarket(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	creator := modelstesting.GenerateUser("creator", 0)
+	trader := modelstesting.GenerateUser("trader", 0)
+	flipper := modelstesting.GenerateUser("flipper", 0)
+	db.Create(&creator)
+	db.Create(&trader)
+	db.Create(&flipper)
+	market := modelstesting.GenerateMarket(9, "creator")
+	db.Create(&market)
+
+	// trader bought 100 and sold some shares back for 30, so staked 70;
+	// flipper sold for more than they paid and is not charged
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(100, "YES", "trader", 9, 0),
+		{Username: "trader", MarketID: 9, Amount: -40, Proceeds: 30, Outcome: "YES"},
+		modelstesting.GenerateBet(10, "NO", "flipper", 9, 0),
+		{Username: "flipper", MarketID: 9, Amount: -10, Proceeds: 15, Outcome: "NO"},
+	} {
+		db.Create(&bet)
+	}
+	order := models.MarketOrder{MarketID: 9, Status: models.OrderStatusOpen, UserID: trader.ID, Username: "trader",
+		Side: models.OrderSideBuy, Outcome: "YES", LimitPrice: 0.4, Amount: 20}
+	db.Create(&order)
+	if _, err := ledger.Apply(db, &creator, ledger.Posting{Type: models.LedgerTypeCreatorFee,
+		Amount: models.CreditsToMicro(2), MarketID: &market.ID}); err != nil {
+		t.Fatalf("creator fee: %v", err)
+	}
+
+	now := time.Date(2026, 5, 8, 12, 0, 0, 0, time.UTC)
+	if err := Void(db, &market, "admin", "duplicate market", now); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if err := Void(db, &market, "admin", "again", now); !errors.Is(err, ErrAlreadyResolved) {
+		t.Fatalf("voiding twice: err = %v", err)
+	}
+
+	var stored models.Market
+	db.First(&stored, market.ID)
+	if !stored.IsVoid() || stored.ResolutionResult != "N/A" || stored.VoidedBy != "admin" || stored.VoidReason != "duplicate market" {
+		t.Fatalf("market = %+v", stored)
+	}
+
+	balances := map[string]int64{"trader": 70, "flipper": 0, "creator": 0}
+	for username, want := range balances {
+		var user models.User
+		db.Where("username = ?", username).First(&user)
+		if user.AccountBalance != models.CreditsToMicro(want) {
+			t.Errorf("%s balance = %d, want %d", username, user.AccountBalance, want)
+		}
+	}
+
+	db.First(&order, order.ID)
+	if order.Status != models.OrderStatusCancelled || order.ClosedAt == nil {
+		t.Errorf("order = %+v", order)
+	}
+}
+
+func TestVoidRacingResolutionPaysOnce(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	for _, username := range []string{"creator", "trader"} {
+		user := modelstesting.GenerateUser(username, 0)
+		db.Create(&user)
+	}
+	market := modelstesting.GenerateMarket(9, "creator")
+	db.Create(&market)
+	bet := modelstesting.GenerateBet(100, "YES", "trader", 9, 0)
+	db.Create(&bet)
+
+	// Both load the market while it is still open
+	var resolving, voiding, voidingAgain models.Market
+	db.First(&resolving, market.ID)
+	db.First(&voiding, market.ID)
+	db.First(&voidingAgain, market.ID)
+
+	now := time.Date(2026, 5, 8, 12, 0, 0, 0, time.UTC)
+	if err := Void(db, &voiding, "admin", "duplicate market", now); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if err := Void(db, &voidingAgain, "admin", "again", now); !errors.Is(err, ErrAlreadyResolved) {
+		t.Fatalf("second void: err = %v, want ErrAlreadyResolved", err)
+	}
+	if err := Resolve(db, &resolving, "YES", now); !errors.Is(err, ErrAlreadyResolved) {
+		t.Fatalf("resolve after void: err = %v, want ErrAlreadyResolved", err)
+	}
+
+	var trader models.User
+	db.Where("username = ?", "trader").First(&trader)
+	if trader.AccountBalance != models.CreditsToMicro(100) {
+		t.Errorf("trader balance = %d, want the 100 credit stake refunded once", trader.AccountBalance)
+	}
+	var stored models.Market
+	db.First(&stored, market.ID)
+	if stored.ResolutionResult != "N/A" || stored.VoidReason != "duplicate market" {
+		t.Errorf("market = %+v, want the first void kept", stored)
+	}
+}
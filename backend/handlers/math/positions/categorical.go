@@ -0,0 +1,67 @@
+package positionsmath
+
+import (
+	"sort"
+	"time"
+
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/models"
+)
+
+// CategoricalPosition is a user's holding in one outcome of a categorical
+// market. Each bet buys shares at the average of the outcome's probability
+// before and after it, so earlier and less likely picks buy more shares.
+type CategoricalPosition struct {
+	Username   string  `json:"username"`
+	Outcome    string  `json:"outcome"`
+	Shares     float64 `json:"shares"`
+	TotalSpent int64   `json:"totalSpent"` // Credits bet on the outcome
+	Value      float64 `json:"value"`      // Credits paid out if the outcome wins
+}
+
+// CalculateCategoricalPositions returns every user's position per outcome,
+// ordered by username then outcome, and the market's pool: the credits bet
+// across all outcomes, which the winning outcome's shares split at
+// resolution.
+func CalculateCategoricalPositions(marketCreatedAtTime time.Time, labels []string, bets []models.Bet) ([]CategoricalPosition, int64) {
+	changes := wpam.CalculateCategoricalProbabilitiesWPAM(marketCreatedAtTime, labels, bets)
+	index := make(map[string]int, len(labels))
+	for i, label := range labels {
+		index[label] = i
+	}
+
+	type key struct{ username, outcome string }
+	holdings := make(map[key]*CategoricalPosition)
+	outcomeShares := make([]float64, len(labels))
+	var pool int64
+	for n, bet := range bets {
+		i, ok := index[bet.Outcome]
+		if !ok || bet.Amount <= 0 {
+			continue
+		}
+		price := (changes[n].Probabilities[i] + changes[n+1].Probabilities[i]) / 2
+		shares := float64(bet.Amount) / price
+
+		k := key{bet.Username, bet.Outcome}
+		if holdings[k] == nil {
+			holdings[k] = &CategoricalPosition{Username: bet.Username, Outcome: bet.Outcome}
+		}
+		holdings[k].Shares += shares
+		holdings[k].TotalSpent += bet.Amount
+		outcomeShares[i] += shares
+		pool += bet.Amount
+	}
+
+	positions := make([]CategoricalPosition, 0, len(holdings))
+	for _, p := range holdings {
+		p.Value = float64(pool) * p.Shares / outcomeShares[index[p.Outcome]]
+		positions = append(positions, *p)
+	}
+	sort.Slice(positions, func(a, b int) bool {
+		if positions[a].Username != positions[b].Username {
+			return positions[a].Username < positions[b].Username
+		}
+		return index[positions[a].Outcome] < index[positions[b].Outcome]
+	})
+	return positions, pool
+}
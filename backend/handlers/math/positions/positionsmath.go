@@ -2,7 +2,6 @@ package positionsmath
 
 import (
 	"errors"
-	"socialpredict/handlers/marketpublicresponse"
 	marketmath "socialpredict/handlers/math/market"
 	"socialpredict/handlers/math/outcomes/dbpm"
 	"socialpredict/handlers/math/probabilities/wpam"
@@ -52,36 +51,31 @@ func CalculateMarketPositions_WPAM_DBPM(db *gorm.DB, marketIdStr string) ([]Mark
 	}
 	marketIDUint := uint(marketIDUint64)
 
-	// Assuming a function to fetch the market creation time
-	publicResponseMarket, err := marketpublicresponse.GetPublicResponseMarketByID(db, marketIdStr)
-	if spErrors.ErrorLogger(err, "Can't convert marketIdStr to publicResponseMarket.") {
+	var market models.Market
+	if err := db.First(&market, marketIDUint).Error; spErrors.ErrorLogger(err, "Can't load market for marketIdStr.") {
 		return nil, err
 	}
 
+	return CalculateMarketPositions(db, &market)
+}
+
+// CalculateMarketPositions summarizes every bettor's position in the market,
+// with shares allocated by the market's own market maker
+func CalculateMarketPositions(db *gorm.DB, market *models.Market) ([]MarketPosition, error) {
+	marketIDUint := uint(market.ID)
+
 	// Fetch bets for the market
 	var allBetsOnMarket []models.Bet
 	allBetsOnMarket = tradingdata.GetBetsForMarket(db, marketIDUint)
+	liquidity := tradingdata.GetLiquidityForMarket(db, market.ID)
 
 	// Get a timeline of probability changes for the market
-	allProbabilityChangesOnMarket := wpam.CalculateMarketProbabilitiesWPAM(publicResponseMarket.CreatedAt, allBetsOnMarket)
-
-	// Calculate the distribution of YES and NO shares based on DBPM
-	S_YES, S_NO := dbpm.DivideUpMarketPoolSharesDBPM(allBetsOnMarket, allProbabilityChangesOnMarket)
-
-	// Calculate course payout pools
-	coursePayouts := dbpm.CalculateCoursePayoutsDBPM(allBetsOnMarket, allProbabilityChangesOnMarket)
-
-	// Calculate normalization factors
-	F_YES, F_NO := dbpm.CalculateNormalizationFactorsDBPM(S_YES, S_NO, coursePayouts)
-
-	// Calculate scaled payouts
-	scaledPayouts := dbpm.CalculateScaledPayoutsDBPM(allBetsOnMarket, coursePayouts, F_YES, F_NO)
-
-	// Adjust payouts to align with the available betting pool using modularized functions
-	finalPayouts := dbpm.AdjustPayouts(allBetsOnMarket, scaledPayouts)
+	maker := marketmath.ForMarket(market)
+	allProbabilityChangesOnMarket := maker.Probabilities(market.CreatedAt, allBetsOnMarket, liquidity...)
 
-	// Aggregate user payouts into market positions
-	aggreatedPositions := dbpm.AggregateUserPayoutsDBPM(allBetsOnMarket, finalPayouts)
+	// Allocate each bet its shares, then sum them per user
+	betShares := maker.Shares(allBetsOnMarket, allProbabilityChangesOnMarket, liquidity...)
+	aggreatedPositions := dbpm.AggregateUserPayoutsDBPM(allBetsOnMarket, betShares)
 
 	// enforce all users are betting on either one side or the other, or net zero
 	netPositions := dbpm.NetAggregateMarketPositions(aggreatedPositions)
@@ -110,8 +104,8 @@ func CalculateMarketPositions_WPAM_DBPM(db *gorm.DB, marketIdStr string) ([]Mark
 		userPositionMap,
 		currentProbability,
 		totalVolume,
-		publicResponseMarket.IsResolved,
-		publicResponseMarket.ResolutionResult,
+		market.IsResolved,
+		market.ResolutionResult,
 	)
 	if err != nil {
 		return nil, err
@@ -125,9 +119,9 @@ func CalculateMarketPositions_WPAM_DBPM(db *gorm.DB, marketIdStr string) ([]Mark
 
 	for _, bet := range allBetsOnMarket {
 		totals := userBetTotals[bet.Username]
-		totals.TotalSpent += bet.Amount
-		if !publicResponseMarket.IsResolved {
-			totals.TotalSpentInPlay += bet.Amount
+		totals.TotalSpent += bet.Spend()
+		if !market.IsResolved {
+			totals.TotalSpentInPlay += bet.Spend()
 		}
 		userBetTotals[bet.Username] = totals
 	}
@@ -149,8 +143,8 @@ func CalculateMarketPositions_WPAM_DBPM(db *gorm.DB, marketIdStr string) ([]Mark
 			Value:            val.RoundedValue,
 			TotalSpent:       betTotals.TotalSpent,
 			TotalSpentInPlay: betTotals.TotalSpentInPlay,
-			IsResolved:       publicResponseMarket.IsResolved,
-			ResolutionResult: publicResponseMarket.ResolutionResult,
+			IsResolved:       market.IsResolved,
+			ResolutionResult: market.ResolutionResult,
 		})
 		seenUsers[p.Username] = true
 	}
@@ -168,8 +162,8 @@ func CalculateMarketPositions_WPAM_DBPM(db *gorm.DB, marketIdStr string) ([]Mark
 			Value:            0,
 			TotalSpent:       totals.TotalSpent,
 			TotalSpentInPlay: totals.TotalSpentInPlay,
-			IsResolved:       publicResponseMarket.IsResolved,
-			ResolutionResult: publicResponseMarket.ResolutionResult,
+			IsResolved:       market.IsResolved,
+			ResolutionResult: market.ResolutionResult,
 		})
 	}
 
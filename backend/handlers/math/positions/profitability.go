@@ -37,13 +37,13 @@ func ErrorLogger(err error, errMsg string) bool {
 }
 
 // CalculateUserSpend calculates the total amount a user has spent on a market
-// by summing all positive amounts (purchases) and subtracting negative amounts (sales)
+// by summing its purchases and subtracting the proceeds of its sales
 func CalculateUserSpend(bets []models.Bet, username string) int64 {
 	var totalSpend int64 = 0
 
 	for _, bet := range bets {
 		if bet.Username == username {
-			totalSpend += bet.Amount // Amount can be positive (buy) or negative (sell)
+			totalSpend += bet.Spend()
 		}
 	}
 
@@ -175,12 +175,23 @@ type GlobalUserProfitability struct {
 	Rank              int       `json:"rank"`
 }
 
-// CalculateGlobalLeaderboard calculates profitability rankings for all users across all markets
-func CalculateGlobalLeaderboard(db *gorm.DB) ([]GlobalUserProfitability, error) {
+// CalculateGlobalLeaderboard calculates profitability rankings for all users across one community's markets
+func CalculateGlobalLeaderboard(db *gorm.DB, tenantID uint) ([]GlobalUserProfitability, error) {
 	if db == nil {
 		return nil, errors.New("Failed to fetch users from database: database connection is nil")
 	}
 
+	// Only the community's markets count
+	var tenantMarketIDs []uint
+	if err := db.Model(&models.Market{}).Scopes(models.InTenant(tenantID)).Pluck("id", &tenantMarketIDs).Error; err != nil {
+		ErrorLogger(err, "Failed to fetch markets from database.")
+		return nil, err
+	}
+	inTenant := make(map[uint]bool, len(tenantMarketIDs))
+	for _, id := range tenantMarketIDs {
+		inTenant[id] = true
+	}
+
 	// Get all users who have made bets
 	var users []models.User
 	if err := db.Find(&users).Error; err != nil {
@@ -217,7 +228,8 @@ func CalculateGlobalLeaderboard(db *gorm.DB) ([]GlobalUserProfitability, error)
 
 		// Get all bets for this user to find earliest bet time
 		var userBets []models.Bet
-		if err := db.Where("username = ?", user.Username).Order("placed_at ASC").Find(&userBets).Error; err != nil {
+		if err := db.Where("username = ? AND market_id IN (?)", user.Username,
+			db.Model(&models.Market{}).Select("id").Scopes(models.InTenant(tenantID))).Order("placed_at ASC").Find(&userBets).Error; err != nil {
 			ErrorLogger(err, "Failed to fetch bets for user "+user.Username)
 			continue
 		}
@@ -229,6 +241,9 @@ func CalculateGlobalLeaderboard(db *gorm.DB) ([]GlobalUserProfitability, error)
 
 		// Aggregate profits from all markets
 		for _, position := range userPositions {
+			if !inTenant[position.MarketID] {
+				continue
+			}
 			// Calculate profit for this market: currentValue - totalSpent
 			marketProfit := position.Value - position.TotalSpent
 
@@ -103,7 +103,7 @@ func TestGlobalLeaderboardDataStructure(t *testing.T) {
 
 func TestCalculateGlobalLeaderboard_NilDB(t *testing.T) {
 	// Test error handling for nil database
-	leaderboard, err := CalculateGlobalLeaderboard(nil)
+	leaderboard, err := CalculateGlobalLeaderboard(nil, 0)
 	assert.Error(t, err)
 	assert.Empty(t, leaderboard)
 	assert.Contains(t, err.Error(), "Failed to fetch users from database")
@@ -0,0 +1,54 @@
+package wpam
+
+import (
+	"socialpredict/models"
+	"time"
+)
+
+// CategoricalProbabilityChange holds a categorical market's probabilities,
+// one per outcome in display order, after a bet
+type CategoricalProbabilityChange struct {
+	Probabilities []float64 `json:"probabilities"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// CalculateCategoricalProbabilitiesWPAM extends WPAM to N outcomes. The
+// initial subsidization is spread evenly across the outcomes, so each starts
+// at 1/N, and an outcome's probability is its share of the subsidization and
+// bets combined. Bets on unknown outcomes are ignored.
+func CalculateCategoricalProbabilitiesWPAM(marketCreatedAtTime time.Time, labels []string, bets []models.Bet) []CategoricalProbabilityChange {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(labels))
+	for i, label := range labels {
+		index[label] = i
+	}
+
+	subsidy := float64(appConfig.Economics.MarketCreation.InitialMarketSubsidization)
+	totals := make([]float64, len(labels))
+	var volume float64
+	snapshot := func() []float64 {
+		probabilities := make([]float64, len(labels))
+		for i := range labels {
+			if subsidy+volume <= 0 {
+				probabilities[i] = 1 / float64(len(labels))
+				continue
+			}
+			probabilities[i] = (subsidy/float64(len(labels)) + totals[i]) / (subsidy + volume)
+		}
+		return probabilities
+	}
+
+	changes := []CategoricalProbabilityChange{{Probabilities: snapshot(), Timestamp: marketCreatedAtTime}}
+	for _, bet := range bets {
+		if i, ok := index[bet.Outcome]; ok {
+			totals[i] += float64(bet.Amount)
+			volume += float64(bet.Amount)
+		}
+		changes = append(changes, CategoricalProbabilityChange{Probabilities: snapshot(), Timestamp: bet.PlacedAt})
+	}
+
+	return changes
+}
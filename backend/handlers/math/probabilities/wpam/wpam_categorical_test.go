@@ -0,0 +1,48 @@
+package wpam
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+)
+
+func TestCalculateCategoricalProbabilitiesWPAM(t *testing.T) {
+	now := time.Now()
+	labels := []string{"Alice", "Bob", "Carol", "Dave", "Eve"}
+	bets := []models.Bet{
+		{Outcome: "Alice", Amount: 30, PlacedAt: now.Add(time.Minute)},
+		{Outcome: "Bob", Amount: 10, PlacedAt: now.Add(2 * time.Minute)},
+		{Outcome: "Mallory", Amount: 100, PlacedAt: now.Add(3 * time.Minute)},
+	}
+
+	changes := CalculateCategoricalProbabilitiesWPAM(now, labels, bets)
+	if len(changes) != len(bets)+1 {
+		t.Fatalf("got %d changes, want %d", len(changes), len(bets)+1)
+	}
+	for _, p := range changes[0].Probabilities {
+		if math.Abs(p-0.2) > 1e-9 {
+			t.Errorf("initial probabilities = %v, want 0.2 each", changes[0].Probabilities)
+			break
+		}
+	}
+
+	for n, change := range changes {
+		var sum float64
+		for _, p := range change.Probabilities {
+			sum += p
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("change %d sums to %f", n, sum)
+		}
+	}
+
+	final := changes[len(changes)-1].Probabilities
+	if !(final[0] > final[1] && final[1] > final[2]) {
+		t.Errorf("final probabilities = %v, want Alice ahead of Bob ahead of the rest", final)
+	}
+	if changes[3].Probabilities[0] != changes[2].Probabilities[0] {
+		t.Error("a bet on an unknown outcome moved the market")
+	}
+}
@@ -29,7 +29,9 @@ func init() {
 }
 
 // CalculateMarketProbabilitiesWPAM calculates and returns the probability changes based on bets.
-func CalculateMarketProbabilitiesWPAM(marketCreatedAtTime time.Time, bets []models.Bet) []ProbabilityChange {
+// Liquidity events, ordered by time, deepen the market from when they happen
+// without moving its probability; they do not add probability changes.
+func CalculateMarketProbabilitiesWPAM(marketCreatedAtTime time.Time, bets []models.Bet, liquidity ...models.LiquidityEvent) []ProbabilityChange {
 	var probabilityChanges []ProbabilityChange
 
 	// Initial state using values from appConfig
@@ -38,28 +40,45 @@ func CalculateMarketProbabilitiesWPAM(marketCreatedAtTime time.Time, bets []mode
 	totalYes := appConfig.Economics.MarketCreation.InitialMarketYes
 	totalNo := appConfig.Economics.MarketCreation.InitialMarketNo
 
+	// Provided liquidity adds to the subsidization, each amount weighted
+	// toward the probability at which it was added
+	weight := float64(I_initial)
+	anchored := P_initial * float64(I_initial)
+	probability := func() float64 {
+		return (anchored + float64(totalYes)) / (weight + float64(totalYes) + float64(totalNo))
+	}
+	applyLiquidity := func(event models.LiquidityEvent) {
+		amount := float64(event.Amount) / float64(models.MicroCreditsPerCredit)
+		anchored += probability() * amount
+		weight += amount
+	}
+
 	probabilityChanges = append(probabilityChanges, ProbabilityChange{Probability: P_initial, Timestamp: marketCreatedAtTime})
 
 	// Calculate probabilities after each bet
+	next := 0
 	for _, bet := range bets {
+		for ; next < len(liquidity) && !liquidity[next].CreatedAt.After(bet.PlacedAt); next++ {
+			applyLiquidity(liquidity[next])
+		}
+
 		if bet.Outcome == "YES" {
 			totalYes += bet.Amount
 		} else if bet.Outcome == "NO" {
 			totalNo += bet.Amount
 		}
 
-		newProbability := (P_initial*float64(I_initial) + float64(totalYes)) / (float64(I_initial) + float64(totalYes) + float64(totalNo))
-		probabilityChanges = append(probabilityChanges, ProbabilityChange{Probability: newProbability, Timestamp: bet.PlacedAt})
+		probabilityChanges = append(probabilityChanges, ProbabilityChange{Probability: probability(), Timestamp: bet.PlacedAt})
 	}
 
 	return probabilityChanges
 }
 
-func ProjectNewProbabilityWPAM(marketCreatedAtTime time.Time, currentBets []models.Bet, newBet models.Bet) ProjectedProbability {
+func ProjectNewProbabilityWPAM(marketCreatedAtTime time.Time, currentBets []models.Bet, newBet models.Bet, liquidity ...models.LiquidityEvent) ProjectedProbability {
 
 	updatedBets := append(currentBets, newBet)
 
-	probabilityChanges := CalculateMarketProbabilitiesWPAM(marketCreatedAtTime, updatedBets)
+	probabilityChanges := CalculateMarketProbabilitiesWPAM(marketCreatedAtTime, updatedBets, liquidity...)
 
 	finalProbability := probabilityChanges[len(probabilityChanges)-1].Probability
 
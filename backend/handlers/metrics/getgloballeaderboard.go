@@ -4,13 +4,14 @@ import (
 	"encoding/json"
 	"net/http"
 	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/services/tenants"
 	"socialpredict/util"
 )
 
 func GetGlobalLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 	db := util.GetDB()
 
-	leaderboard, err := positionsmath.CalculateGlobalLeaderboard(db)
+	leaderboard, err := positionsmath.CalculateGlobalLeaderboard(db, tenants.FromRequest(r).ID)
 	if err != nil {
 		http.Error(w, "failed to compute global leaderboard: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,54 @@
+package metricshandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/leaderboard"
+	"socialpredict/services/tenants"
+)
+
+// LeaderboardHandler serves GET /v0/leaderboard?period=&sort=&page=&limit=
+// from the newest leaderboard snapshot of the request's community. Period is
+// all-time (the default), monthly or weekly; sort is profit (the default),
+// roi or brier.
+func LeaderboardHandler(svc *leaderboard.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		period := models.LeaderboardAllTime
+		if p := query.Get("period"); p != "" {
+			period = strings.ToUpper(strings.ReplaceAll(p, "-", "_"))
+		}
+		sortBy := leaderboard.SortProfit
+		if s := query.Get("sort"); s != "" {
+			sortBy = strings.ToLower(s)
+		}
+
+		page := 1
+		limit := 50
+		if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+			page = p
+		}
+		if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+
+		result, err := svc.Latest(tenants.FromRequest(r).ID, period, sortBy, page, limit)
+		if err != nil {
+			if errors.Is(err, leaderboard.ErrInvalidPeriod) || errors.Is(err, leaderboard.ErrInvalidSort) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to load leaderboard", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
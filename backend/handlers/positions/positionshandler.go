@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"socialpredict/errors"
+	"socialpredict/handlers/marketaccess"
 	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/models"
 	"socialpredict/util"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
@@ -17,7 +20,12 @@ func MarketDBPMPositionsHandler(w http.ResponseWriter, r *http.Request) {
 	// open up database to utilize connection pooling
 	db := util.GetDB()
 
-	marketDBPMPositions, err := positionsmath.CalculateMarketPositions_WPAM_DBPM(db, marketIdStr)
+	market, ok := viewableMarket(w, r, marketIdStr)
+	if !ok {
+		return
+	}
+
+	marketDBPMPositions, err := positionsmath.CalculateMarketPositions(db, market)
 	if errors.HandleHTTPError(w, err, http.StatusBadRequest, "Invalid request or data processing error.") {
 		return // Stop execution if there was an error.
 	}
@@ -35,6 +43,10 @@ func MarketDBPMUserPositionsHandler(w http.ResponseWriter, r *http.Request) {
 	// open up database to utilize connection pooling
 	db := util.GetDB()
 
+	if _, ok := viewableMarket(w, r, marketIdStr); !ok {
+		return
+	}
+
 	marketDBPMPositions, err := positionsmath.CalculateMarketPositionForUser_WPAM_DBPM(db, marketIdStr, userNameStr)
 	if errors.HandleHTTPError(w, err, http.StatusBadRequest, "Invalid request or data processing error.") {
 		return // Stop execution if there was an error.
@@ -44,3 +56,13 @@ func MarketDBPMUserPositionsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(marketDBPMPositions)
 }
+
+// viewableMarket loads the market in the URL if the viewer can see it
+func viewableMarket(w http.ResponseWriter, r *http.Request, marketIdStr string) (*models.Market, bool) {
+	marketID, err := strconv.ParseInt(marketIdStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+		return nil, false
+	}
+	return marketaccess.Viewable(w, r, util.GetDB(), marketID)
+}
@@ -3,6 +3,7 @@ package setup
 import (
 	"encoding/json"
 	"net/http"
+	"socialpredict/services/tenants"
 	"socialpredict/setup"
 )
 
@@ -15,7 +16,7 @@ func GetSetupHandler(loadEconomicsConfig func() (*setup.EconomicConfig, error))
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(appConfig.Economics)
+		err = json.NewEncoder(w).Encode(tenants.Economics(tenants.FromRequest(r), appConfig.Economics))
 		if err != nil {
 			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		}
@@ -26,8 +27,19 @@ type frontendChartsResponse struct {
 	SigFigs int `json:"sigFigs"`
 }
 
+// frontendTenantResponse is the branding of the community the frontend is
+// served for
+type frontendTenantResponse struct {
+	Slug         string `json:"slug"`
+	Name         string `json:"name"`
+	LogoURL      string `json:"logoUrl"`
+	PrimaryColor string `json:"primaryColor"`
+	CreditName   string `json:"creditName"`
+}
+
 type frontendConfigResponse struct {
 	Charts frontendChartsResponse `json:"charts"`
+	Tenant frontendTenantResponse `json:"tenant"`
 }
 
 func GetFrontendSetupHandler(loadEconomicsConfig func() (*setup.EconomicConfig, error)) func(w http.ResponseWriter, r *http.Request) {
@@ -38,10 +50,22 @@ func GetFrontendSetupHandler(loadEconomicsConfig func() (*setup.EconomicConfig,
 			return
 		}
 
+		tenant := tenants.FromRequest(r)
+		creditName := tenant.CreditName
+		if creditName == "" {
+			creditName = "credits"
+		}
 		response := frontendConfigResponse{
 			Charts: frontendChartsResponse{
 				SigFigs: setup.ChartSigFigs(),
 			},
+			Tenant: frontendTenantResponse{
+				Slug:         tenant.Slug,
+				Name:         tenant.Name,
+				LogoURL:      tenant.LogoURL,
+				PrimaryColor: tenant.PrimaryColor,
+				CreditName:   creditName,
+			},
 		}
 
 		w.Header().Set("Content-Type", "application/json")
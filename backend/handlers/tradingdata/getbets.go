@@ -28,3 +28,33 @@ func GetBetsForMarket(db *gorm.DB, marketID uint) []models.Bet {
 
 	return bets
 }
+
+// GetLiquidityForMarket returns the market's liquidity events in the order
+// they happened, for pricing alongside its bets
+func GetLiquidityForMarket(db *gorm.DB, marketID int64) []models.LiquidityEvent {
+	var events []models.LiquidityEvent
+
+	if err := db.
+		Where("market_id = ?", marketID).
+		Order("created_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil
+	}
+
+	return events
+}
+
+// GetOutcomesForMarket returns a categorical market's outcomes in display
+// order. Binary markets have none.
+func GetOutcomesForMarket(db *gorm.DB, marketID int64) []models.MarketOutcome {
+	var outcomes []models.MarketOutcome
+
+	if err := db.
+		Where("market_id = ?", marketID).
+		Order("position ASC").
+		Find(&outcomes).Error; err != nil {
+		return nil
+	}
+
+	return outcomes
+}
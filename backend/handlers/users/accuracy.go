@@ -0,0 +1,39 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/handlers/math/accuracy"
+	"socialpredict/models"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetUserAccuracyHandler returns a user's forecast accuracy: the Brier score
+// of their bets in resolved markets and their calibration curve.
+// Endpoint: GET /v0/users/{username}/accuracy
+func GetUserAccuracyHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+
+	var user models.User
+	if err := db.Where("username = ?", mux.Vars(r)["username"]).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := accuracy.UserReport(db, user.Username)
+	if err != nil {
+		http.Error(w, "Failed to compute accuracy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,62 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/activity"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetUserActivityHandler returns one feed of a user's bets, market payouts,
+// deposits and withdrawals, newest first.
+// Endpoint: GET /v0/users/{username}/activity
+// Anyone may view bets and payouts. Deposits, withdrawals and the rest of
+// the ledger are included only for the user themselves or an admin.
+func GetUserActivityHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+
+	var user models.User
+	if err := db.Where("username = ?", mux.Vars(r)["username"]).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	includePrivate := false
+	if r.Header.Get("Authorization") != "" {
+		viewer, httperr := middleware.ValidateTokenAndGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+		includePrivate = viewer.ID == user.ID || viewer.UserType == "ADMIN"
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+
+	page, err := activity.ProfileFeed(db, &user, includePrivate, r.URL.Query().Get("cursor"), pageSize)
+	if err != nil {
+		if errors.Is(err, activity.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to load activity", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
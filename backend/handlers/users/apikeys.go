@@ -0,0 +1,152 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/apikeys"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateAPIKeyRequest issues an API key. Scopes default to read only and the
+// rate limit, in requests per minute, to apikeys.DefaultRateLimit.
+type CreateAPIKeyRequest struct {
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes,omitempty"`
+	RateLimitPerMinute int      `json:"rateLimitPerMinute,omitempty"`
+}
+
+// APIKeyResponse includes the key itself, which is not shown again
+type APIKeyResponse struct {
+	APIKey *models.APIKey `json:"apiKey"`
+	Key    string         `json:"key"`
+}
+
+// ListAPIKeysHandler returns the authenticated user's API keys
+func ListAPIKeysHandler(keys *apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		list, err := keys.List(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to load API keys", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"apiKeys": list,
+			"scopes":  apikeys.Scopes,
+		})
+	}
+}
+
+// CreateAPIKeyHandler issues an API key for the authenticated user
+func CreateAPIKeyHandler(keys *apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		apiKey, key, err := keys.Issue(user.ID, req.Name, req.Scopes, req.RateLimitPerMinute)
+		if err != nil {
+			switch {
+			case errors.Is(err, apikeys.ErrInvalidName), errors.Is(err, apikeys.ErrInvalidScope),
+				errors.Is(err, apikeys.ErrInvalidRateLimit), errors.Is(err, apikeys.ErrTooManyKeys):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("API keys: failed to issue key for %s: %v", user.Username, err)
+				http.Error(w, "Failed to issue API key", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(APIKeyResponse{APIKey: apiKey, Key: key})
+	}
+}
+
+// RotateAPIKeyHandler replaces one of the authenticated user's API keys
+func RotateAPIKeyHandler(keys *apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+			return
+		}
+
+		apiKey, key, err := keys.Rotate(user.ID, uint(id))
+		if err != nil {
+			writeAPIKeyError(w, user, err, "rotate")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(APIKeyResponse{APIKey: apiKey, Key: key})
+	}
+}
+
+// RevokeAPIKeyHandler revokes one of the authenticated user's API keys
+func RevokeAPIKeyHandler(keys *apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := keys.Revoke(user.ID, uint(id)); err != nil {
+			writeAPIKeyError(w, user, err, "revoke")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeAPIKeyError(w http.ResponseWriter, user *models.User, err error, action string) {
+	switch {
+	case errors.Is(err, apikeys.ErrNotFound):
+		http.Error(w, "API key not found", http.StatusNotFound)
+	case errors.Is(err, apikeys.ErrRevoked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("API keys: failed to %s key for %s: %v", action, user.Username, err)
+		http.Error(w, "Failed to "+action+" API key", http.StatusInternalServerError)
+	}
+}
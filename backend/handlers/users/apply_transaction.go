@@ -3,6 +3,7 @@ package usershandlers
 import (
 	"fmt"
 	"socialpredict/models"
+	"socialpredict/services/ledger"
 
 	"gorm.io/gorm"
 )
@@ -16,6 +17,7 @@ const (
 )
 
 // ApplyTransactionToUser credits the user's balance for a specific transaction type (WIN, REFUND, etc.)
+// and records it in the ledger
 func ApplyTransactionToUser(username string, amount int64, db *gorm.DB, transactionType string) error {
 	var user models.User
 
@@ -23,16 +25,28 @@ func ApplyTransactionToUser(username string, amount int64, db *gorm.DB, transact
 		return fmt.Errorf("user lookup failed: %w", err)
 	}
 
+	posting := ledger.Posting{
+		Amount:        models.CreditsToMicro(amount),
+		ReferenceType: ledger.ReferenceTypeUser,
+		ReferenceID:   uint(user.ID),
+		Description:   fmt.Sprintf("%s of %d credits", transactionType, amount),
+	}
 	switch transactionType {
-	case TransactionWin, TransactionRefund, TransactionSale:
-		user.AccountBalance += amount
+	case TransactionWin:
+		posting.Type = models.LedgerTypeMarketPayout
+	case TransactionRefund:
+		posting.Type = models.LedgerTypeMarketRefund
+	case TransactionSale:
+		posting.Type = models.LedgerTypeMarketSale
 	case TransactionBuy, TransactionFee:
-		user.AccountBalance -= amount
+		posting.Type = models.LedgerTypeBet
+		posting.Amount = -posting.Amount
+		posting.SpendBonus = true
 	default:
 		return fmt.Errorf("unknown transaction type: %s", transactionType)
 	}
 
-	if err := db.Save(&user).Error; err != nil {
+	if _, err := ledger.Apply(db, &user, posting); err != nil {
 		return fmt.Errorf("failed to update user balance: %w", err)
 	}
 
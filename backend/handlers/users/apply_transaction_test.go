@@ -47,8 +47,8 @@ func TestApplyTransactionToUser(t *testing.T) {
 		if err != nil {
 			t.Errorf("unexpected error for type %s: %v", tc.txType, err)
 		}
-		if updated.AccountBalance != tc.expectBalance {
-			t.Errorf("after %s, expected balance %d, got %d", tc.txType, tc.expectBalance, updated.AccountBalance)
+		if updated.AccountBalance != models.CreditsToMicro(tc.expectBalance) {
+			t.Errorf("after %s, expected balance %d, got %d", tc.txType, tc.expectBalance, updated.WholeCredits())
 		}
 	}
 }
@@ -0,0 +1,32 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/creatorfees"
+	"socialpredict/util"
+)
+
+// GetCreatorEarningsHandler returns the creator fees the authenticated user
+// has earned, and is still accruing, on the markets they created.
+// Endpoint: GET /v0/creator/earnings
+func GetCreatorEarningsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+	if httperr != nil {
+		http.Error(w, httperr.Error(), httperr.StatusCode)
+		return
+	}
+
+	earnings, err := creatorfees.CreatorEarnings(db, user.ID)
+	if err != nil {
+		log.Printf("Creator earnings: failed to load for user %d: %v", user.ID, err)
+		http.Error(w, "Failed to load creator earnings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(earnings)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"socialpredict/handlers/users/publicuser"
+	"socialpredict/models"
 	"socialpredict/setup"
 	"socialpredict/util"
 
@@ -59,7 +60,8 @@ func calculateUserCredit(db *gorm.DB, username string, maximumdebt int64) int64
 
 	userPublicInfo := publicuser.GetPublicUserInfo(db, username)
 
-	userCredit := maximumdebt + userPublicInfo.AccountBalance
+	accountBalance, _ := models.MicroToWholeCredits(userPublicInfo.AccountBalance)
+	userCredit := maximumdebt + accountBalance
 
 	return int64(userCredit)
 }
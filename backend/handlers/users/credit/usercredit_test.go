@@ -31,7 +31,7 @@ func TestCalculateUserCredit(t *testing.T) {
 				Username:       tc.username,
 				DisplayName:    tc.displayName,
 				UserType:       "REGULAR",
-				AccountBalance: tc.accountBalance,
+				AccountBalance: models.CreditsToMicro(tc.accountBalance),
 			},
 			PrivateUser: models.PrivateUser{
 				Email:    tc.username + "@example.com",
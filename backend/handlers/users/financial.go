@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"socialpredict/handlers/math/financials"
 	"socialpredict/handlers/users/publicuser"
+	"socialpredict/models"
 	"socialpredict/setup"
 	"socialpredict/util"
 
@@ -43,7 +44,8 @@ func GetUserFinancialHandlerWithDB(db *gorm.DB, econConfigLoader func() (*setup.
 		}
 
 		// Compute comprehensive financial snapshot
-		snapshot, err := financials.ComputeUserFinancials(db, username, userPublicInfo.AccountBalance, econ)
+		accountBalance, _ := models.MicroToWholeCredits(userPublicInfo.AccountBalance)
+		snapshot, err := financials.ComputeUserFinancials(db, username, accountBalance, econ)
 		if err != nil {
 			log.Printf("Error generating user financial snapshot: %v", err)
 			http.Error(w, "Unable to generate financial snapshot", http.StatusInternalServerError)
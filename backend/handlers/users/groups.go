@@ -0,0 +1,233 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/groups"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// JoinGroupRequest carries the token from an invite link
+type JoinGroupRequest struct {
+	Token string `json:"token"`
+}
+
+// groupHandler authenticates the user, parses the {id} group ID and hands
+// both to fn
+func groupHandler(fn func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+		fn(w, r, user, uint(id))
+	}
+}
+
+// CreateGroupHandler creates a group owned by the authenticated user
+func CreateGroupHandler(svc *groups.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req groups.CreateInput
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		group, err := svc.Create(user, req)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(group)
+	}
+}
+
+// ListGroupsHandler returns the groups the authenticated user belongs to
+func ListGroupsHandler(svc *groups.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		list, err := svc.ListMine(user.ID)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"groups": list,
+		})
+	}
+}
+
+// GetGroupHandler returns a group and its members to one of its members
+func GetGroupHandler(svc *groups.Service) http.HandlerFunc {
+	return groupHandler(func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint) {
+		detail, err := svc.Get(user.ID, groupID)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detail)
+	})
+}
+
+// ListGroupMarketsHandler returns a group's markets to one of its members
+func ListGroupMarketsHandler(svc *groups.Service) http.HandlerFunc {
+	return groupHandler(func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint) {
+		markets, err := svc.Markets(user.ID, groupID)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"markets": markets,
+		})
+	})
+}
+
+// RemoveGroupMemberHandler removes a member from a group, or lets a member leave
+func RemoveGroupMemberHandler(svc *groups.Service) http.HandlerFunc {
+	return groupHandler(func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint) {
+		memberID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		if err := svc.RemoveMember(user.ID, groupID, memberID); err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// CreateGroupInviteHandler creates an invite link for a group the user owns
+func CreateGroupInviteHandler(svc *groups.Service) http.HandlerFunc {
+	return groupHandler(func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint) {
+		var req groups.InviteInput
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		invite, err := svc.CreateInvite(user.ID, groupID, req)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(invite)
+	})
+}
+
+// ListGroupInvitesHandler returns a group's invites to its owner
+func ListGroupInvitesHandler(svc *groups.Service) http.HandlerFunc {
+	return groupHandler(func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint) {
+		invites, err := svc.ListInvites(user.ID, groupID)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"invites": invites,
+		})
+	})
+}
+
+// RevokeGroupInviteHandler stops one of a group's invite links from working
+func RevokeGroupInviteHandler(svc *groups.Service) http.HandlerFunc {
+	return groupHandler(func(w http.ResponseWriter, r *http.Request, user *models.User, groupID uint) {
+		inviteID, err := strconv.ParseUint(mux.Vars(r)["inviteId"], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+			return
+		}
+		if err := svc.RevokeInvite(user.ID, groupID, uint(inviteID)); err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// JoinGroupHandler adds the authenticated user to the group of an invite link
+func JoinGroupHandler(svc *groups.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req JoinGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+			http.Error(w, "token is required", http.StatusBadRequest)
+			return
+		}
+
+		group, err := svc.Join(user.ID, req.Token)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(group)
+	}
+}
+
+// writeGroupError maps group errors to HTTP responses
+func writeGroupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, groups.ErrGroupNotFound), errors.Is(err, groups.ErrMemberNotFound),
+		errors.Is(err, groups.ErrInviteNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, groups.ErrNotOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, groups.ErrInviteExpired), errors.Is(err, groups.ErrInviteUsedUp):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, groups.ErrInvalidName), errors.Is(err, groups.ErrInvalidDescription),
+		errors.Is(err, groups.ErrInvalidExpiry), errors.Is(err, groups.ErrInvalidMaxUses),
+		errors.Is(err, groups.ErrInvalidInvite), errors.Is(err, groups.ErrOwnerCannotLeave):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Groups: request failed: %v", err)
+		http.Error(w, "Failed to process group request", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,33 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/notify"
+	"socialpredict/util"
+)
+
+// notificationsLimit caps how many notifications are returned
+const notificationsLimit = 50
+
+// GetNotificationsHandler returns the authenticated user's most recent notifications
+func GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+	if httperr != nil {
+		http.Error(w, httperr.Error(), httperr.StatusCode)
+		return
+	}
+
+	notifications, err := notify.List(db, user.ID, notificationsLimit)
+	if err != nil {
+		http.Error(w, "Failed to load notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+	})
+}
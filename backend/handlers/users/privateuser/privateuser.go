@@ -13,17 +13,17 @@ type CombinedUserResponse struct {
 	// Private fields
 	models.PrivateUser
 	// Public fields
-	Username              string `json:"username"`
-	DisplayName           string `json:"displayname"`
-	UserType              string `json:"usertype"`
-	InitialAccountBalance int64  `json:"initialAccountBalance"`
-	AccountBalance        int64  `json:"accountBalance"`
-	PersonalEmoji         string `json:"personalEmoji,omitempty"`
-	Description           string `json:"description,omitempty"`
-	PersonalLink1         string `json:"personalink1,omitempty"`
-	PersonalLink2         string `json:"personalink2,omitempty"`
-	PersonalLink3         string `json:"personalink3,omitempty"`
-	PersonalLink4         string `json:"personalink4,omitempty"`
+	Username              string  `json:"username"`
+	DisplayName           string  `json:"displayname"`
+	UserType              string  `json:"usertype"`
+	InitialAccountBalance int64   `json:"initialAccountBalance"`
+	AccountBalance        float64 `json:"accountBalance"`
+	PersonalEmoji         string  `json:"personalEmoji,omitempty"`
+	Description           string  `json:"description,omitempty"`
+	PersonalLink1         string  `json:"personalink1,omitempty"`
+	PersonalLink2         string  `json:"personalink2,omitempty"`
+	PersonalLink3         string  `json:"personalink3,omitempty"`
+	PersonalLink4         string  `json:"personalink4,omitempty"`
 }
 
 func GetPrivateProfileUserResponse(w http.ResponseWriter, r *http.Request) {
@@ -50,7 +50,7 @@ func GetPrivateProfileUserResponse(w http.ResponseWriter, r *http.Request) {
 		DisplayName:           publicInfo.DisplayName,
 		UserType:              publicInfo.UserType,
 		InitialAccountBalance: publicInfo.InitialAccountBalance,
-		AccountBalance:        publicInfo.AccountBalance,
+		AccountBalance:        models.DisplayCredits(publicInfo.AccountBalance),
 		PersonalEmoji:         publicInfo.PersonalEmoji,
 		Description:           publicInfo.Description,
 		PersonalLink1:         publicInfo.PersonalLink1,
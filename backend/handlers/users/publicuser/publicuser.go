@@ -17,12 +17,24 @@ func GetPublicUserResponse(w http.ResponseWriter, r *http.Request) {
 
 	db := util.GetDB()
 
-	response := GetPublicUserInfo(db, username)
+	response := NewPublicUserResponse(GetPublicUserInfo(db, username))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// PublicUserResponse is the public user as served by the API, with the
+// micro-credit balance rounded to credits for display.
+type PublicUserResponse struct {
+	models.PublicUser
+	AccountBalance float64 `json:"accountBalance"`
+}
+
+// NewPublicUserResponse wraps user for an API response.
+func NewPublicUserResponse(user models.PublicUser) PublicUserResponse {
+	return PublicUserResponse{PublicUser: user, AccountBalance: models.DisplayCredits(user.AccountBalance)}
+}
+
 // Function to get the users public info From the Database
 func GetPublicUserInfo(db *gorm.DB, username string) models.PublicUser {
 	var user models.User
@@ -0,0 +1,34 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/referrals"
+	"socialpredict/util"
+)
+
+// GetReferralsHandler returns the authenticated user's referral code, the
+// users they referred and the rewards earned from them
+// Endpoint: GET /v0/referrals
+func GetReferralsHandler(svc *referrals.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		stats, err := svc.Stats(user.ID)
+		if err != nil {
+			log.Printf("Referrals: failed to load stats for user %d: %v", user.ID, err)
+			http.Error(w, "Failed to load referrals", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
@@ -0,0 +1,128 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/util"
+)
+
+// ExclusionRequest represents the request body for taking a break
+type ExclusionRequest struct {
+	Days int `json:"days"`
+}
+
+// DepositLimitRequest represents the request body for setting a daily deposit limit
+type DepositLimitRequest struct {
+	DailyLimit json.Number `json:"dailyLimit"` // Credits; 0 removes the limit
+}
+
+// ResponsibleGamblingHandler returns the user's cooling-off or self-exclusion
+// and daily deposit limit
+func ResponsibleGamblingHandler(svc *selfexclusion.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		status, err := svc.Status(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to load responsible gambling settings", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// CoolingOffHandler keeps the user from betting and depositing for a short
+// break. It cannot be ended early.
+func CoolingOffHandler(svc *selfexclusion.Service) http.HandlerFunc {
+	return excludeHandler(svc, models.ExclusionCoolingOff)
+}
+
+// SelfExclusionHandler keeps the user from betting and depositing for months
+// or years. It cannot be ended early.
+func SelfExclusionHandler(svc *selfexclusion.Service) http.HandlerFunc {
+	return excludeHandler(svc, models.ExclusionSelfExclusion)
+}
+
+func excludeHandler(svc *selfexclusion.Service, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req ExclusionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		exclusion, err := svc.Exclude(user, kind, req.Days)
+		if err != nil {
+			if errors.Is(err, selfexclusion.ErrInvalidPeriod) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Users: %s for %s failed: %v", kind, user.Username, err)
+			http.Error(w, "Failed to apply exclusion", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Users: %s started %s until %s", user.Username, kind, exclusion.EndsAt.Format("2006-01-02"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(exclusion)
+	}
+}
+
+// SetDepositLimitHandler sets the user's daily deposit limit. Lowering it
+// applies at once; raising or removing it applies after 24 hours.
+func SetDepositLimitHandler(svc *selfexclusion.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req DepositLimitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		limit, err := models.ParseCredits(req.DailyLimit.String())
+		if err != nil {
+			http.Error(w, "Invalid deposit limit", http.StatusBadRequest)
+			return
+		}
+
+		status, err := svc.SetDepositLimit(user, limit)
+		if err != nil {
+			if errors.Is(err, selfexclusion.ErrInvalidLimit) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Users: Setting deposit limit for %s failed: %v", user.Username, err)
+			http.Error(w, "Failed to set deposit limit", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
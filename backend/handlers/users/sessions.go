@@ -0,0 +1,60 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/devices"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+)
+
+// ListSessionsHandler returns the user's active sessions and the devices they
+// were opened on. The session making the request is marked current.
+func ListSessionsHandler(deviceGuard *devices.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		sessions, err := deviceGuard.ListSessions(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sessions": sessions,
+			"current":  middleware.SessionIDFromRequest(r),
+		})
+	}
+}
+
+// RevokeSessionHandler logs one of the user's sessions out
+func RevokeSessionHandler(deviceGuard *devices.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		if err := deviceGuard.Revoke(user.ID, mux.Vars(r)["id"]); err != nil {
+			if errors.Is(err, devices.ErrSessionNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
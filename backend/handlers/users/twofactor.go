@@ -0,0 +1,159 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/twofactor"
+	"socialpredict/util"
+	"time"
+)
+
+// TwoFactorCodeRequest carries a TOTP code from the user's authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// EmailTokenRequest asks for a confirmation code by email
+type EmailTokenRequest struct {
+	Purpose string `json:"purpose"` // Defaults to WITHDRAWAL
+}
+
+// GetTwoFactorStatusHandler reports whether the user has TOTP enabled and
+// from what withdrawal amount a second factor is needed
+func GetTwoFactorStatusHandler(tf *twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		enabled, err := tf.Enabled(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to load two-factor status", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled":             enabled,
+			"withdrawalThreshold": models.DisplayCredits(tf.WithdrawalThreshold()),
+		})
+	}
+}
+
+// EnrollTwoFactorHandler starts TOTP enrollment and returns the secret to add
+// to an authenticator app. It is not shown again.
+func EnrollTwoFactorHandler(tf *twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		enrollment, err := tf.Enroll(user)
+		if err != nil {
+			if errors.Is(err, twofactor.ErrAlreadyEnabled) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			log.Printf("2FA: failed to enroll user %d: %v", user.ID, err)
+			http.Error(w, "Failed to start two-factor enrollment", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(enrollment)
+	}
+}
+
+// ActivateTwoFactorHandler finishes enrollment with a code from the app
+func ActivateTwoFactorHandler(tf *twofactor.Service) http.HandlerFunc {
+	return twoFactorCodeHandler(tf.Activate, "Two-factor authentication enabled")
+}
+
+// DisableTwoFactorHandler turns TOTP off after checking a current code
+func DisableTwoFactorHandler(tf *twofactor.Service) http.HandlerFunc {
+	return twoFactorCodeHandler(tf.Disable, "Two-factor authentication disabled")
+}
+
+func twoFactorCodeHandler(apply func(userID int64, code string) error, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req TwoFactorCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := apply(user.ID, req.Code); err != nil {
+			switch {
+			case errors.Is(err, twofactor.ErrInvalidCode):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, twofactor.ErrNotEnrolled), errors.Is(err, twofactor.ErrAlreadyEnabled):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				log.Printf("2FA: failed to update user %d: %v", user.ID, err)
+				http.Error(w, "Failed to update two-factor authentication", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": message})
+	}
+}
+
+// SendEmailTokenHandler emails the user a one-time confirmation code
+func SendEmailTokenHandler(tf *twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req EmailTokenRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Purpose == "" {
+			req.Purpose = models.EmailTokenPurposeWithdrawal
+		}
+
+		expiresAt, err := tf.SendEmailToken(user, req.Purpose)
+		if err != nil {
+			if errors.Is(err, twofactor.ErrUnknownPurpose) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("2FA: failed to send email token to user %d: %v", user.ID, err)
+			http.Error(w, "Failed to send confirmation code", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":   "Confirmation code sent to your email address",
+			"expiresAt": expiresAt.Format(time.RFC3339),
+		})
+	}
+}
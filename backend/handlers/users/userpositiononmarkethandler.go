@@ -3,9 +3,11 @@ package usershandlers
 import (
 	"encoding/json"
 	"net/http"
+	"socialpredict/handlers/marketaccess"
 	positionsmath "socialpredict/handlers/math/positions"
 	"socialpredict/middleware"
 	"socialpredict/util"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
@@ -22,6 +24,15 @@ func UserMarketPositionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	marketID, err := strconv.ParseInt(marketId, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid market ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := marketaccess.Viewable(w, r, db, marketID); !ok {
+		return
+	}
+
 	userPosition, err := positionsmath.CalculateMarketPositionForUser_WPAM_DBPM(db, marketId, user.Username)
 	if err != nil {
 		http.Error(w, "Error calculating user market position: "+err.Error(), http.StatusInternalServerError)
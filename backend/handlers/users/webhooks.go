@@ -0,0 +1,212 @@
+package usershandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/userhooks"
+	"socialpredict/util"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateWebhookRequest registers a webhook. MinChange is in credits.
+type CreateWebhookRequest struct {
+	URL         string      `json:"url"`
+	Events      []string    `json:"events,omitempty"` // Defaults to every supported event
+	MinChange   json.Number `json:"minChange,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// CreateWebhookResponse includes the signing secret, which is not shown again
+type CreateWebhookResponse struct {
+	Webhook *models.UserWebhook `json:"webhook"`
+	Secret  string              `json:"secret"`
+}
+
+// ListWebhooksHandler returns the authenticated user's webhooks
+func ListWebhooksHandler(hooks *userhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		list, err := hooks.List(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to load webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"webhooks":        list,
+			"supportedEvents": userhooks.SupportedEvents,
+		})
+	}
+}
+
+// CreateWebhookHandler registers a webhook for the authenticated user
+func CreateWebhookHandler(hooks *userhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req CreateWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var minChange int64
+		if req.MinChange != "" {
+			parsed, err := models.ParseCredits(req.MinChange.String())
+			if err != nil || parsed < 0 {
+				http.Error(w, "Invalid minChange", http.StatusBadRequest)
+				return
+			}
+			minChange = parsed
+		}
+
+		hook, secret, err := hooks.Register(user, req.URL, req.Events, minChange, req.Description)
+		if err != nil {
+			switch {
+			case errors.Is(err, userhooks.ErrInvalidURL), errors.Is(err, userhooks.ErrInvalidEvent), errors.Is(err, userhooks.ErrTooManyWebhooks):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("Webhooks: failed to register webhook for %s: %v", user.Username, err)
+				http.Error(w, "Failed to register webhook", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(CreateWebhookResponse{Webhook: hook, Secret: secret})
+	}
+}
+
+// DeleteWebhookHandler removes one of the authenticated user's webhooks
+func DeleteWebhookHandler(hooks *userhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := hooks.Delete(user.ID, uint(id)); err != nil {
+			if errors.Is(err, userhooks.ErrNotFound) {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListWebhookDeliveriesHandler returns the delivery log of one of the
+// authenticated user's webhooks, newest first. Takes optional status and
+// limit query parameters.
+func ListWebhookDeliveriesHandler(hooks *userhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		id, parseErr := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+		limit := 50
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		deliveries, err := hooks.Deliveries(user.ID, uint(id), r.URL.Query().Get("status"), limit)
+		if err != nil {
+			switch {
+			case errors.Is(err, userhooks.ErrNotFound):
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+			case errors.Is(err, userhooks.ErrInvalidStatus):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to load deliveries", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"deliveries": deliveries})
+	}
+}
+
+// RetryWebhookDeliveryHandler queues a delivery of one of the authenticated
+// user's webhooks to be sent again
+func RetryWebhookDeliveryHandler(hooks *userhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		vars := mux.Vars(r)
+		id, parseErr := strconv.ParseUint(vars["id"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+		deliveryID, parseErr := strconv.ParseUint(vars["deliveryId"], 10, 32)
+		if parseErr != nil {
+			http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+			return
+		}
+
+		delivery, err := hooks.Retry(user.ID, uint(id), uint(deliveryID))
+		if err != nil {
+			switch {
+			case errors.Is(err, userhooks.ErrNotFound):
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+			case errors.Is(err, userhooks.ErrNoDelivery):
+				http.Error(w, "Delivery not found", http.StatusNotFound)
+			default:
+				log.Printf("Webhooks: failed to retry delivery %d for %s: %v", deliveryID, user.Username, err)
+				http.Error(w, "Failed to retry delivery", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(delivery)
+	}
+}
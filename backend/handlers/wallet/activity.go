@@ -0,0 +1,40 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/services/activity"
+	"socialpredict/util"
+	"strconv"
+)
+
+// GetActivityHandler returns one feed of the user's crypto transactions and
+// market settlements, transfers and other ledger activity, newest first
+func GetActivityHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+	if httperr != nil {
+		http.Error(w, httperr.Error(), httperr.StatusCode)
+		return
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+
+	page, err := activity.Feed(db, user, r.URL.Query().Get("cursor"), pageSize)
+	if err != nil {
+		if errors.Is(err, activity.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to load activity", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
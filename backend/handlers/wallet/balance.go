@@ -0,0 +1,164 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// withdrawalLockedStatuses are the withdrawal request statuses whose amount
+// has been debited from the balance but not yet sent, or refunded
+var withdrawalLockedStatuses = []string{models.TxStatusAwaitingConfirmation, models.TxStatusPending, models.TxStatusOnHold, models.TxStatusApproving, models.TxStatusUnrecorded, models.TxStatusApproved}
+
+// BalanceResponse breaks the user's credits down into what can be spent now
+// and what is tied up. Total is Available plus both locked amounts. Each
+// amount is given in credits, rounded for display, and exactly in micro-credits.
+type BalanceResponse struct {
+	Total                  float64    `json:"total"`
+	TotalMicro             int64      `json:"totalMicro"`
+	Available              float64    `json:"available"` // Account balance; negative when the user is in debt
+	AvailableMicro         int64      `json:"availableMicro"`
+	LockedWithdrawals      float64    `json:"lockedWithdrawals"` // Requested withdrawals not yet sent or refunded
+	LockedWithdrawalsMicro int64      `json:"lockedWithdrawalsMicro"`
+	LockedPositions        float64    `json:"lockedPositions"` // Paid for positions in unresolved markets, fees included, less their sales
+	LockedPositionsMicro   int64      `json:"lockedPositionsMicro"`
+	PositionsValue         float64    `json:"positionsValue"` // Current market value of those positions
+	PositionsValueMicro    int64      `json:"positionsValueMicro"`
+	Provisional            float64    `json:"provisional"` // Betting-only allowance from unconfirmed deposits; not part of Total
+	ProvisionalMicro       int64      `json:"provisionalMicro"`
+	PendingWithdrawals     int64      `json:"pendingWithdrawals"` // Number of withdrawal requests counted in LockedWithdrawals
+	Bonus                  float64    `json:"bonus"`              // Unwagered promotional credit within Available; cannot be withdrawn
+	BonusMicro             int64      `json:"bonusMicro"`
+	PendingWinnings        float64    `json:"pendingWinnings"` // Market winnings within Available still inside their settlement delay
+	PendingWinningsMicro   int64      `json:"pendingWinningsMicro"`
+	NextRelease            *time.Time `json:"nextRelease,omitempty"` // When the next undisputed winnings are released
+	SettledWinnings        float64    `json:"settledWinnings"`       // Market winnings already released, less any reversed
+	SettledWinningsMicro   int64      `json:"settledWinningsMicro"`
+	Unconfirmed            float64    `json:"unconfirmed"` // Grace-credited deposits within Available still awaiting confirmations
+	UnconfirmedMicro       int64      `json:"unconfirmedMicro"`
+	Withdrawable           float64    `json:"withdrawable"` // Available less Bonus, PendingWinnings and Unconfirmed
+	WithdrawableMicro      int64      `json:"withdrawableMicro"`
+}
+
+// GetBalanceHandler returns the user's balance broken down into available and
+// locked credits
+func GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+	if httperr != nil {
+		http.Error(w, httperr.Error(), httperr.StatusCode)
+		return
+	}
+
+	response, err := computeBalance(db, user)
+	if err != nil {
+		http.Error(w, "Failed to compute balance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// computeBalance builds the balance breakdown for user. The account balance
+// is the running balance the ledger reconciles against; withdrawals and bets
+// have already been debited from it, so their ledger entries are added back
+// as locked credits.
+func computeBalance(db *gorm.DB, user *models.User) (*BalanceResponse, error) {
+	var pendingWithdrawals int64
+	if err := db.Model(&models.WithdrawalRequest{}).
+		Where("user_id = ? AND status IN ?", user.ID, withdrawalLockedStatuses).
+		Count(&pendingWithdrawals).Error; err != nil {
+		return nil, err
+	}
+	// Each request's debit less any part already refunded
+	var lockedWithdrawals int64
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(-SUM(ledger_entries.amount), 0)").
+		Joins("JOIN withdrawal_requests ON withdrawal_requests.id = ledger_entries.reference_id").
+		Where("ledger_entries.user_id = ? AND ledger_entries.reference_type = ? AND withdrawal_requests.status IN ?",
+			user.ID, ledger.ReferenceTypeWithdrawalRequest, withdrawalLockedStatuses).
+		Scan(&lockedWithdrawals).Error; err != nil {
+		return nil, err
+	}
+
+	var spentInPlay int64
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(-SUM(ledger_entries.amount), 0)").
+		Joins("JOIN markets ON markets.id = ledger_entries.market_id").
+		Where("ledger_entries.user_id = ? AND ledger_entries.type IN ? AND markets.is_resolved = ?",
+			user.ID, []string{models.LedgerTypeBet, models.LedgerTypeMarketSale}, false).
+		Scan(&spentInPlay).Error; err != nil {
+		return nil, err
+	}
+	// Sales can return more than was paid; nothing is locked then
+	lockedPositions := max(spentInPlay, 0)
+
+	userPositions, err := positionsmath.CalculateAllUserMarketPositions_WPAM_DBPM(db, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	var valueInPlay int64
+	for _, pos := range userPositions {
+		if !pos.IsResolved {
+			valueInPlay += pos.Value
+		}
+	}
+	positionsValue := models.CreditsToMicro(valueInPlay)
+	provisional := user.ProvisionalBalance
+
+	// Winnings are held for the settlement delay before they can be withdrawn
+	var winnings int64
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user_id = ? AND type IN ?", user.ID, []string{models.LedgerTypeMarketPayout, models.LedgerTypePayoutReversal}).
+		Scan(&winnings).Error; err != nil {
+		return nil, err
+	}
+	var nextHold models.PayoutHold
+	var nextRelease *time.Time
+	if err := db.Where("user_id = ? AND status = ?", user.ID, models.PayoutHoldHeld).
+		Order("release_at").Limit(1).Find(&nextHold).Error; err != nil {
+		return nil, err
+	}
+	if nextHold.ID != 0 {
+		nextRelease = &nextHold.ReleaseAt
+	}
+
+	available := user.BalanceMicroCredits()
+	total := available + lockedWithdrawals + lockedPositions
+
+	return &BalanceResponse{
+		Total:                  models.DisplayCredits(total),
+		TotalMicro:             total,
+		Available:              models.DisplayCredits(available),
+		AvailableMicro:         available,
+		LockedWithdrawals:      models.DisplayCredits(lockedWithdrawals),
+		LockedWithdrawalsMicro: lockedWithdrawals,
+		LockedPositions:        models.DisplayCredits(lockedPositions),
+		LockedPositionsMicro:   lockedPositions,
+		PositionsValue:         models.DisplayCredits(positionsValue),
+		PositionsValueMicro:    positionsValue,
+		Provisional:            models.DisplayCredits(provisional),
+		ProvisionalMicro:       provisional,
+		PendingWithdrawals:     pendingWithdrawals,
+		Bonus:                  models.DisplayCredits(user.BonusBalance),
+		BonusMicro:             user.BonusBalance,
+		PendingWinnings:        models.DisplayCredits(user.PendingBalance),
+		PendingWinningsMicro:   user.PendingBalance,
+		NextRelease:            nextRelease,
+		SettledWinnings:        models.DisplayCredits(winnings - user.PendingBalance),
+		SettledWinningsMicro:   winnings - user.PendingBalance,
+		Unconfirmed:            models.DisplayCredits(user.UnconfirmedBalance),
+		UnconfirmedMicro:       user.UnconfirmedBalance,
+		Withdrawable:           models.DisplayCredits(user.WithdrawableMicroCredits()),
+		WithdrawableMicro:      user.WithdrawableMicroCredits(),
+	}, nil
+}
@@ -0,0 +1,102 @@
+package wallethandlers
+
+import (
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/ledger"
+)
+
+func TestComputeBalanceBreakdown(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("alice", 100)
+	user.AddMicroCredits(500_000)
+	user.ProvisionalBalance = models.CreditsToMicro(20)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Pending and held withdrawals are locked, less any part refunded;
+	// completed and rejected ones are not
+	for _, w := range []struct {
+		status   string
+		amount   int64
+		refunded int64
+	}{
+		{models.TxStatusPending, 12 * models.MicroCreditsPerCredit, 2 * models.MicroCreditsPerCredit},
+		{models.TxStatusOnHold, 5 * models.MicroCreditsPerCredit, 0},
+		{models.TxStatusCompleted, 50 * models.MicroCreditsPerCredit, 0},
+		{models.TxStatusRejected, 7 * models.MicroCreditsPerCredit, 7 * models.MicroCreditsPerCredit},
+	} {
+		req := models.WithdrawalRequest{UserID: user.ID, ChainID: 8453, ChainName: "base", TokenSymbol: "USDC",
+			Amount: w.amount - w.refunded, ToAddress: "0x1111111111111111111111111111111111111111", Status: w.status}
+		if err := db.Create(&req).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+		entries := []models.LedgerEntry{{UserID: user.ID, Type: models.LedgerTypeWithdrawal, Amount: -w.amount,
+			ReferenceType: ledger.ReferenceTypeWithdrawalRequest, ReferenceID: req.ID}}
+		if w.refunded > 0 {
+			entries = append(entries, models.LedgerEntry{UserID: user.ID, Type: models.LedgerTypeWithdrawalRefund, Amount: w.refunded,
+				ReferenceType: ledger.ReferenceTypeWithdrawalRequest, ReferenceID: req.ID})
+		}
+		if err := db.Create(&entries).Error; err != nil {
+			t.Fatalf("create ledger entries: %v", err)
+		}
+	}
+
+	// Bets in an open market are locked, fees included and less sales; bets
+	// in a resolved market are not
+	open := modelstesting.GenerateMarket(1, "creator")
+	resolved := modelstesting.GenerateMarket(2, "creator")
+	resolved.IsResolved = true
+	resolved.ResolutionResult = "YES"
+	for _, m := range []*models.Market{&open, &resolved} {
+		if err := db.Create(m).Error; err != nil {
+			t.Fatalf("create market: %v", err)
+		}
+	}
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(30, "YES", "alice", 1, 0),
+		modelstesting.GenerateBet(40, "NO", "alice", 2, 0),
+	} {
+		if err := db.Create(&bet).Error; err != nil {
+			t.Fatalf("create bet: %v", err)
+		}
+		marketID := int64(bet.MarketID)
+		if err := db.Create(&models.LedgerEntry{UserID: user.ID, Type: models.LedgerTypeBet, Amount: -models.CreditsToMicro(bet.Amount + 1),
+			ReferenceType: "bet", ReferenceID: bet.ID, MarketID: &marketID}).Error; err != nil {
+			t.Fatalf("create ledger entry: %v", err)
+		}
+	}
+	openID := open.ID
+	if err := db.Create(&models.LedgerEntry{UserID: user.ID, Type: models.LedgerTypeMarketSale, Amount: 6 * models.MicroCreditsPerCredit,
+		MarketID: &openID}).Error; err != nil {
+		t.Fatalf("create ledger entry: %v", err)
+	}
+
+	got, err := computeBalance(db, &user)
+	if err != nil {
+		t.Fatalf("computeBalance: %v", err)
+	}
+
+	if got.AvailableMicro != 100_500_000 {
+		t.Errorf("available = %d, want 100500000", got.AvailableMicro)
+	}
+	if got.LockedWithdrawalsMicro != 15*models.MicroCreditsPerCredit || got.PendingWithdrawals != 2 {
+		t.Errorf("locked withdrawals = %d over %d requests, want 15000000 over 2", got.LockedWithdrawalsMicro, got.PendingWithdrawals)
+	}
+	if got.LockedPositionsMicro != 25*models.MicroCreditsPerCredit {
+		t.Errorf("locked positions = %d, want 25000000", got.LockedPositionsMicro)
+	}
+	if want := got.AvailableMicro + got.LockedWithdrawalsMicro + got.LockedPositionsMicro; got.TotalMicro != want {
+		t.Errorf("total = %d, want %d", got.TotalMicro, want)
+	}
+	if got.Total != 140.5 || got.Provisional != 20 || got.ProvisionalMicro != 20*models.MicroCreditsPerCredit {
+		t.Errorf("total = %v, provisional = %v (%d micro)", got.Total, got.Provisional, got.ProvisionalMicro)
+	}
+	if got.PositionsValueMicro != models.CreditsToMicro(int64(got.PositionsValue)) || got.PositionsValueMicro <= 0 {
+		t.Errorf("positions value = %v (%d micro)", got.PositionsValue, got.PositionsValueMicro)
+	}
+}
@@ -3,7 +3,11 @@ package wallethandlers
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
 	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/settings"
+	"socialpredict/services/tenants"
 	"socialpredict/util"
 )
 
@@ -38,6 +42,12 @@ func GetSupportedChainsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only the chains the community offers
+	tenant := tenants.FromRequest(r)
+	chains = slices.DeleteFunc(chains, func(chain models.SupportedChain) bool {
+		return !tenants.ChainEnabled(tenant, chain.Name)
+	})
+
 	response := make([]ChainResponse, len(chains))
 	for i, chain := range chains {
 		response[i] = ChainResponse{
@@ -101,23 +111,23 @@ func GetTokensForChainHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build list of available tokens for this chain
-	tokens := []TokenResponse{}
-
-	if chain.USDCAddress != "" {
-		tokens = append(tokens, TokenResponse{
-			Symbol:   "USDC",
-			Name:     "USD Coin",
-			Decimals: 6,
-			IsActive: true,
-		})
+	chainTokens, err := chains.Tokens(db, chain.ID)
+	if err != nil {
+		http.Error(w, "Failed to load tokens", http.StatusInternalServerError)
+		return
 	}
 
-	if chain.USDTAddress != "" {
+	// Build list of available tokens for this chain
+	tokens := []TokenResponse{}
+	for _, token := range chainTokens {
+		if !token.IsActive {
+			continue
+		}
 		tokens = append(tokens, TokenResponse{
-			Symbol:   "USDT",
-			Name:     "Tether USD",
-			Decimals: 6,
+			Symbol:   token.Token.Symbol,
+			Name:     token.Token.Name,
+			Decimals: token.Decimals,
+			IconURL:  token.Token.IconURL,
 			IsActive: true,
 		})
 	}
@@ -129,27 +139,71 @@ func GetTokensForChainHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetWalletInfoHandler returns the wallet status and configuration info
-func GetWalletInfoHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
+// TokenLimitsItem is the withdrawal minimum and maximum for a token on a chain
+// where they differ from the platform defaults, in credits
+type TokenLimitsItem struct {
+	ChainName          string  `json:"chainName"`
+	TokenSymbol        string  `json:"tokenSymbol"`
+	MinWithdrawal      float64 `json:"minWithdrawal"`
+	MaxWithdrawal      float64 `json:"maxWithdrawal"`
+	WithdrawalsEnabled bool    `json:"withdrawalsEnabled"`
+}
 
-	// Count active chains and tokens
-	var chainCount, tokenCount int64
-	db.Model(&models.SupportedChain{}).Where("is_active = ?", true).Count(&chainCount)
-	db.Model(&models.SupportedToken{}).Where("is_active = ?", true).Count(&tokenCount)
-
-	response := map[string]interface{}{
-		"status":          "active",
-		"supportedChains": chainCount,
-		"supportedTokens": tokenCount,
-		"limits": map[string]int64{
-			"minWithdrawal":  MinWithdrawalAmount,
-			"maxWithdrawal":  MaxWithdrawalAmount,
-			"dailyLimit":     DailyWithdrawalLimit,
-		},
-		"creditRatio": "1:1", // 1 token = 1 credit
-	}
+// GetWalletInfoHandler returns the wallet status and configuration info. The
+// status is "disabled" when DFNS is not configured and the crypto routes are off.
+func GetWalletInfoHandler(cryptoEnabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+
+		// Count active chains and tokens
+		var chainCount, tokenCount int64
+		db.Model(&models.SupportedChain{}).Where("is_active = ?", true).Count(&chainCount)
+		db.Model(&models.SupportedToken{}).Where("is_active = ?", true).Count(&tokenCount)
+
+		limits, err := settings.Shared.WithdrawalLimits(db)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawal limits", http.StatusInternalServerError)
+			return
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+		// Per-token rules override the platform minimum and maximum
+		rules, err := settings.TokenWithdrawalRules(db)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawal limits", http.StatusInternalServerError)
+			return
+		}
+		tokenLimits := make([]TokenLimitsItem, 0, len(rules))
+		for _, rule := range rules {
+			effective := settings.ApplyTokenRule(limits, rule)
+			tokenLimits = append(tokenLimits, TokenLimitsItem{
+				ChainName:          rule.ChainName,
+				TokenSymbol:        rule.TokenSymbol,
+				MinWithdrawal:      models.DisplayCredits(effective.Min),
+				MaxWithdrawal:      models.DisplayCredits(effective.Max),
+				WithdrawalsEnabled: !rule.Disabled,
+			})
+		}
+
+		status := "active"
+		if !cryptoEnabled {
+			status = "disabled"
+		}
+		response := map[string]interface{}{
+			"status":          status,
+			"supportedChains": chainCount,
+			"supportedTokens": tokenCount,
+			"limits": map[string]float64{
+				"minWithdrawal":  models.DisplayCredits(limits.Min),
+				"maxWithdrawal":  models.DisplayCredits(limits.Max),
+				"dailyLimit":     models.DisplayCredits(limits.Daily),
+				"weeklyLimit":    models.DisplayCredits(limits.Weekly),
+				"monthlyLimit":   models.DisplayCredits(limits.Monthly),
+			},
+			"tokenLimits": tokenLimits,
+			"creditRatio": "1:1", // 1 token = 1 credit
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
 }
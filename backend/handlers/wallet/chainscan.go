@@ -0,0 +1,21 @@
+package wallethandlers
+
+import (
+	"socialpredict/clock"
+	"socialpredict/logger"
+	"socialpredict/services/chainscan"
+	"socialpredict/services/dfns"
+	"socialpredict/services/screening"
+
+	"gorm.io/gorm"
+)
+
+// ScannedDepositCrediter credits deposits found by the chain scanner through
+// the webhook deposit path, so screening and tx hash deduplication apply. The
+// scanner read the transfer from the chain itself, so no receipt check is made.
+func ScannedDepositCrediter(db *gorm.DB, screener screening.Screener, c clock.Clock) chainscan.Crediter {
+	return func(org string, data *dfns.TransferEventData, raw []byte) error {
+		log := logger.Structured.With("source", "chain_scan", "dfns_org", org, "tx_hash", data.TxHash)
+		return processInboundTransfer(log, db, c, org, screener, nil, data, true, raw)
+	}
+}
@@ -0,0 +1,14 @@
+package wallethandlers
+
+import "net/http"
+
+// RequireCrypto answers 503 instead of calling h when crypto features are
+// disabled because DFNS is not configured
+func RequireCrypto(enabled bool, h http.HandlerFunc) http.HandlerFunc {
+	if enabled {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "crypto features disabled", http.StatusServiceUnavailable)
+	}
+}
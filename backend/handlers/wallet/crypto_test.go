@@ -0,0 +1,24 @@
+package wallethandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireCryptoAnswers503WhenDisabled(t *testing.T) {
+	called := false
+	h := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+	rr := httptest.NewRecorder()
+	RequireCrypto(false, h)(rr, httptest.NewRequest("GET", "/v0/wallet/deposits", nil))
+	if rr.Code != http.StatusServiceUnavailable || called {
+		t.Fatalf("disabled: status = %d, handler called = %v; want 503 without calling the handler", rr.Code, called)
+	}
+
+	rr = httptest.NewRecorder()
+	RequireCrypto(true, h)(rr, httptest.NewRequest("GET", "/v0/wallet/deposits", nil))
+	if rr.Code != http.StatusOK || !called {
+		t.Fatalf("enabled: status = %d, handler called = %v", rr.Code, called)
+	}
+}
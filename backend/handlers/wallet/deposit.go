@@ -1,14 +1,17 @@
 package wallethandlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"socialpredict/logger"
 	"socialpredict/middleware"
 	"socialpredict/models"
+	"socialpredict/repository"
+	"socialpredict/services/chains"
 	"socialpredict/services/dfns"
-	"socialpredict/util"
+	"socialpredict/services/tenants"
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
@@ -28,9 +31,8 @@ type AllDepositAddressesResponse struct {
 }
 
 // GetDepositAddressHandler returns the user's deposit address for a specific chain
-func GetDepositAddressHandler(dfnsClient *dfns.Client) http.HandlerFunc {
+func GetDepositAddressHandler(db *gorm.DB, wallets repository.WalletRepo, dfnsOrgs *dfns.Orgs) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		db := util.GetDB()
 		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
 		if httperr != nil {
 			http.Error(w, httperr.Error(), httperr.StatusCode)
@@ -41,24 +43,32 @@ func GetDepositAddressHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 		chainName := vars["chain"]
 
 		// Validate chain name
-		if !dfns.IsValidChainName(chainName) {
+		active, err := chains.Shared.IsActive(db, chainName)
+		if err != nil {
+			http.Error(w, "Failed to load supported chains", http.StatusInternalServerError)
+			return
+		}
+		if !active || !tenants.ChainEnabled(tenants.FromRequest(r), chainName) {
 			http.Error(w, "Invalid chain name", http.StatusBadRequest)
 			return
 		}
 
 		// Find existing wallet for this user and chain
-		var wallet models.Wallet
-		result := db.Where("user_id = ? AND chain_name = ? AND is_active = ?", user.ID, chainName, true).First(&wallet)
-
-		if result.Error != nil {
+		wallet, err := wallets.ActiveForChain(r.Context(), user.ID, chainName)
+		if err != nil {
+			// An impersonating admin sees the account as it is, without creating a wallet
+			if middleware.ImpersonatorFromRequest(r) != "" {
+				http.Error(w, "No deposit address on this chain yet", http.StatusNotFound)
+				return
+			}
 			// Wallet doesn't exist, create one via DFNS
-			newWallet, err := createWalletForUser(user, chainName, dfnsClient, db)
+			log := logger.FromContext(r.Context()).With("user_id", user.ID, "chain", chainName)
+			wallet, err = createWalletForUser(r.Context(), user, chainName, dfnsOrgs, db)
 			if err != nil {
-				log.Printf("Failed to create wallet for user %s on chain %s: %v", user.Username, chainName, err)
+				log.Error("failed to create deposit wallet", "error", err)
 				http.Error(w, "Failed to create deposit address", http.StatusInternalServerError)
 				return
 			}
-			wallet = *newWallet
 		}
 
 		// Get chain info for display name
@@ -81,9 +91,8 @@ func GetDepositAddressHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 }
 
 // GetAllDepositAddressesHandler returns deposit addresses for all supported chains
-func GetAllDepositAddressesHandler(dfnsClient *dfns.Client) http.HandlerFunc {
+func GetAllDepositAddressesHandler(db *gorm.DB, wallets repository.WalletRepo, dfnsOrgs *dfns.Orgs) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		db := util.GetDB()
 		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
 		if httperr != nil {
 			http.Error(w, httperr.Error(), httperr.StatusCode)
@@ -92,23 +101,28 @@ func GetAllDepositAddressesHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 
 		// Get all active supported chains
 		var chains []models.SupportedChain
-		db.Where("is_active = ?", true).Find(&chains)
+		db.WithContext(r.Context()).Where("is_active = ?", true).Find(&chains)
 
 		addresses := make([]DepositAddressResponse, 0, len(chains))
 
+		tenant := tenants.FromRequest(r)
 		for _, chain := range chains {
+			if !tenants.ChainEnabled(tenant, chain.Name) {
+				continue
+			}
 			// Find or create wallet for each chain
-			var wallet models.Wallet
-			result := db.Where("user_id = ? AND chain_name = ? AND is_active = ?", user.ID, chain.Name, true).First(&wallet)
-
-			if result.Error != nil {
+			wallet, err := wallets.ActiveForChain(r.Context(), user.ID, chain.Name)
+			if err != nil {
+				if middleware.ImpersonatorFromRequest(r) != "" {
+					continue
+				}
 				// Create wallet if it doesn't exist
-				newWallet, err := createWalletForUser(user, chain.Name, dfnsClient, db)
+				log := logger.FromContext(r.Context()).With("user_id", user.ID, "chain", chain.Name)
+				wallet, err = createWalletForUser(r.Context(), user, chain.Name, dfnsOrgs, db)
 				if err != nil {
-					log.Printf("Failed to create wallet for user %s on chain %s: %v", user.Username, chain.Name, err)
+					log.Error("failed to create deposit wallet", "error", err)
 					continue // Skip this chain but continue with others
 				}
-				wallet = *newWallet
 			}
 
 			addresses = append(addresses, DepositAddressResponse{
@@ -128,8 +142,9 @@ func GetAllDepositAddressesHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 	}
 }
 
-// createWalletForUser creates a new MPC wallet for a user on a specific chain
-func createWalletForUser(user *models.User, chainName string, dfnsClient *dfns.Client, db *gorm.DB) (*models.Wallet, error) {
+// createWalletForUser creates a new MPC wallet for a user on a specific chain,
+// failing over to a secondary DFNS org when the primary is unavailable
+func createWalletForUser(ctx context.Context, user *models.User, chainName string, dfnsOrgs *dfns.Orgs, db *gorm.DB) (*models.Wallet, error) {
 	// Get DFNS network name for the chain
 	network := dfns.GetDFNSNetwork(chainName)
 	if network == "" {
@@ -149,7 +164,7 @@ func createWalletForUser(user *models.User, chainName string, dfnsClient *dfns.C
 		ExternalID: fmt.Sprintf("%d", user.ID),
 	}
 
-	dfnsWallet, err := dfnsClient.CreateWallet(createReq)
+	dfnsWallet, org, err := dfnsOrgs.CreateWallet(ctx, createReq)
 	if err != nil {
 		return nil, fmt.Errorf("DFNS wallet creation failed: %w", err)
 	}
@@ -158,6 +173,7 @@ func createWalletForUser(user *models.User, chainName string, dfnsClient *dfns.C
 	wallet := &models.Wallet{
 		UserID:       user.ID,
 		DfnsWalletID: dfnsWallet.ID,
+		DfnsOrg:      org,
 		ChainID:      chainInfo.ChainID,
 		ChainName:    chainName,
 		Address:      dfnsWallet.Address,
@@ -165,10 +181,17 @@ func createWalletForUser(user *models.User, chainName string, dfnsClient *dfns.C
 	}
 
 	if err := db.Create(wallet).Error; err != nil {
+		// A concurrent request may have saved the user's wallet for this chain
+		// first; the unique index allows one active wallet, so use that one
+		var existing models.Wallet
+		if db.Where("user_id = ? AND chain_id = ? AND is_active = ?", user.ID, chainInfo.ChainID, true).First(&existing).Error == nil {
+			logger.FromContext(ctx).Warn("deposit wallet created concurrently, discarding the new one", "user_id", user.ID, "chain", chainName, "dfns_wallet_id", dfnsWallet.ID)
+			return &existing, nil
+		}
 		return nil, fmt.Errorf("failed to save wallet: %w", err)
 	}
 
-	log.Printf("Created wallet for user %s on chain %s: %s", user.Username, chainName, wallet.Address)
+	logger.FromContext(ctx).Info("created deposit wallet", "user_id", user.ID, "chain", chainName, "dfns_org", org, "dfns_wallet_id", wallet.DfnsWalletID, "address", wallet.Address)
 
 	return wallet, nil
 }
@@ -0,0 +1,293 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/metrics"
+	"socialpredict/services/notify"
+	"socialpredict/services/receipts"
+	"socialpredict/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PendingDepositBettingRequest represents the request body for opting in or out
+// of betting against pending deposits
+type PendingDepositBettingRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PendingDepositBettingResponse reports the user's setting and current allowance
+type PendingDepositBettingResponse struct {
+	Enabled                 bool    `json:"enabled"`
+	ProvisionalBalance      float64 `json:"provisionalBalance"` // Credits, usable for betting only
+	ProvisionalBalanceMicro int64   `json:"provisionalBalanceMicro"`
+}
+
+// SetPendingDepositBettingHandler lets a user opt into betting against
+// unconfirmed deposits. The allowance never counts towards withdrawals.
+func SetPendingDepositBettingHandler(w http.ResponseWriter, r *http.Request) {
+	db := util.GetDB()
+	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+	if httperr != nil {
+		http.Error(w, httperr.Error(), httperr.StatusCode)
+		return
+	}
+
+	var req PendingDepositBettingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Model(user).Update("bet_against_pending_deposits", req.Enabled).Error; err != nil {
+		http.Error(w, "Failed to update setting", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PendingDepositBettingResponse{
+		Enabled:                 req.Enabled,
+		ProvisionalBalance:      models.DisplayCredits(user.ProvisionalBalance),
+		ProvisionalBalanceMicro: user.ProvisionalBalance,
+	})
+}
+
+// recordPendingDeposit stores an unconfirmed deposit. On a chain with grace
+// crediting the deposit is credited at once but kept out of the withdrawable
+// balance; otherwise, if the user opted in, it grants a provisional betting
+// allowance of its value.
+func recordPendingDeposit(log *slog.Logger, db *gorm.DB, tx *models.CryptoTransaction) error {
+	return db.Transaction(func(dbTx *gorm.DB) error {
+		var chain models.SupportedChain
+		if err := dbTx.Where("chain_id = ?", tx.ChainID).Limit(1).Find(&chain).Error; err != nil {
+			return err
+		}
+		tx.GraceCredited = chain.GraceCrediting
+		if err := dbTx.Create(tx).Error; err != nil {
+			return err
+		}
+		if tx.GraceCredited {
+			return graceCredit(log, dbTx, tx)
+		}
+
+		var user models.User
+		if err := dbTx.First(&user, tx.UserID).Error; err != nil {
+			return err
+		}
+		allowance := tx.AmountCredits
+		if !user.BetAgainstPendingDeposits || allowance <= 0 {
+			return nil
+		}
+
+		if err := dbTx.Create(&models.ProvisionalCredit{
+			UserID:              user.ID,
+			CryptoTransactionID: tx.ID,
+			Amount:              allowance,
+			Status:              models.ProvisionalStatusActive,
+		}).Error; err != nil {
+			return err
+		}
+		log.Info("granted provisional credits", "user_id", user.ID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(allowance))
+		return dbTx.Model(&user).Update("provisional_balance", gorm.Expr("provisional_balance + ?", allowance)).Error
+	})
+}
+
+// confirmPendingDeposit credits a pending deposit and converts its provisional
+// allowance. A deposit that needs on-chain verification and fails it, or one
+// to a user whose deposits have since been blocked, is held for review
+// instead, and its allowance reversed.
+func confirmPendingDeposit(log *slog.Logger, db *gorm.DB, c clock.Clock, verifier *receipts.Service, tx *models.CryptoTransaction) error {
+	if reason := depositBlocked(log, db, tx.UserID, c.Now()); reason != "" {
+		return holdPendingDeposit(log, db, c, tx, reason)
+	}
+	if reason := verifyDeposit(log, db, verifier, tx); reason != "" {
+		return holdPendingDeposit(log, db, c, tx, reason)
+	}
+	credited := false
+	err := db.Transaction(func(dbTx *gorm.DB) error {
+		now := c.Now()
+		claimed, err := claimPendingDeposit(dbTx, tx, map[string]interface{}{
+			"status":       models.TxStatusCompleted,
+			"processed_at": now,
+		})
+		if err != nil || !claimed {
+			return err
+		}
+		tx.Status = models.TxStatusCompleted
+		tx.ProcessedAt = &now
+		credited = true
+
+		user, err := ledger.LockUser(dbTx, tx.UserID)
+		if err != nil {
+			return err
+		}
+		// A grace-credited deposit is already in the balance and only
+		// becomes withdrawable
+		if tx.GraceCredited {
+			log.Info("grace-credited deposit confirmed", "user_id", user.ID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(tx.AmountCredits))
+			return dbTx.Model(user).Update("unconfirmed_balance", max(user.UnconfirmedBalance-tx.AmountCredits, 0)).Error
+		}
+		released, err := settleProvisionalCredit(dbTx, tx.ID, models.ProvisionalStatusConverted, now)
+		if err != nil {
+			return err
+		}
+		if _, err := ledger.Apply(dbTx, user, depositPosting(tx)); err != nil {
+			return err
+		}
+		if err := dbTx.Model(user).Update("provisional_balance", gorm.Expr("provisional_balance - ?", released)).Error; err != nil {
+			return err
+		}
+
+		log.Info("pending deposit confirmed", "user_id", user.ID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(tx.AmountCredits))
+		return nil
+	})
+	if err == nil && credited {
+		metrics.RecordDepositCredited(tx.ChainName, tx.TokenSymbol, tx.AmountCredits)
+	}
+	return err
+}
+
+// failPendingDeposit marks a pending deposit failed, as when it is reorged out,
+// and reverses its provisional allowance or grace credit. Bets already placed
+// with either stay, so the user may end up with a lower (possibly negative)
+// balance.
+func failPendingDeposit(log *slog.Logger, db *gorm.DB, c clock.Clock, tx *models.CryptoTransaction) error {
+	return db.Transaction(func(dbTx *gorm.DB) error {
+		now := c.Now()
+		claimed, err := claimPendingDeposit(dbTx, tx, map[string]interface{}{
+			"status":        models.TxStatusFailed,
+			"error_message": "Deposit failed to confirm",
+			"processed_at":  now,
+		})
+		if err != nil || !claimed {
+			return err
+		}
+		tx.Status = models.TxStatusFailed
+		tx.ErrorMessage = "Deposit failed to confirm"
+		tx.ProcessedAt = &now
+		return reverseProvisionalCredit(log, dbTx, tx, now)
+	})
+}
+
+// holdPendingDeposit puts a pending deposit ON_HOLD for an admin to release
+// or reject, reversing its provisional allowance or grace credit
+func holdPendingDeposit(log *slog.Logger, db *gorm.DB, c clock.Clock, tx *models.CryptoTransaction, reason string) error {
+	return db.Transaction(func(dbTx *gorm.DB) error {
+		now := c.Now()
+		claimed, err := claimPendingDeposit(dbTx, tx, map[string]interface{}{
+			"status":       models.TxStatusOnHold,
+			"hold_reason":  reason,
+			"processed_at": now,
+		})
+		if err != nil || !claimed {
+			return err
+		}
+		tx.Status = models.TxStatusOnHold
+		tx.HoldReason = reason
+		tx.ProcessedAt = &now
+		log.Info("pending deposit held for review", "user_id", tx.UserID, "tx_id", tx.ID, "reason", reason)
+		return reverseProvisionalCredit(log, dbTx, tx, now)
+	})
+}
+
+// claimPendingDeposit moves a deposit out of PENDING with updates. It reports
+// false, leaving the deposit alone, when a confirmation, failure or hold
+// seen from another event or the chain scanner got there first.
+func claimPendingDeposit(dbTx *gorm.DB, tx *models.CryptoTransaction, updates map[string]interface{}) (bool, error) {
+	result := dbTx.Model(&models.CryptoTransaction{}).
+		Where("id = ? AND status = ?", tx.ID, models.TxStatusPending).
+		Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	return true, nil
+}
+
+// reverseProvisionalCredit takes back the allowance or grace credit granted
+// for a pending deposit, as of now
+func reverseProvisionalCredit(log *slog.Logger, dbTx *gorm.DB, tx *models.CryptoTransaction, now time.Time) error {
+	if tx.GraceCredited {
+		return reverseGraceCredit(log, dbTx, tx)
+	}
+	released, err := settleProvisionalCredit(dbTx, tx.ID, models.ProvisionalStatusReversed, now)
+	if err != nil || released == 0 {
+		return err
+	}
+	log.Info("reversed provisional credits", "user_id", tx.UserID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(released))
+	return dbTx.Model(&models.User{}).Where("id = ?", tx.UserID).
+		Update("provisional_balance", gorm.Expr("provisional_balance - ?", released)).Error
+}
+
+// settleProvisionalCredit closes the active allowance for a deposit at now and
+// returns its amount
+func settleProvisionalCredit(db *gorm.DB, cryptoTxID uint, status string, now time.Time) (int64, error) {
+	var credit models.ProvisionalCredit
+	err := db.Where("crypto_transaction_id = ? AND status = ?", cryptoTxID, models.ProvisionalStatusActive).First(&credit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	credit.Status = status
+	credit.ResolvedAt = &now
+	if err := db.Save(&credit).Error; err != nil {
+		return 0, err
+	}
+	return credit.Amount, nil
+}
+
+// graceCredit credits a pending deposit through the ledger and keeps it out
+// of the withdrawable balance until it confirms
+func graceCredit(log *slog.Logger, dbTx *gorm.DB, tx *models.CryptoTransaction) error {
+	user, err := ledger.LockUser(dbTx, tx.UserID)
+	if err != nil {
+		return err
+	}
+	if _, err := ledger.Apply(dbTx, user, ledger.Posting{
+		Type:          models.LedgerTypeGraceDeposit,
+		Amount:        tx.AmountCredits,
+		ReferenceType: ledger.ReferenceTypeCryptoTransaction,
+		ReferenceID:   tx.ID,
+		Description:   fmt.Sprintf("Deposit of %s %s on %s, withdrawable once confirmed", models.FormatMicroCredits(tx.AmountCredits), tx.TokenSymbol, tx.ChainName),
+	}); err != nil {
+		return err
+	}
+	log.Info("grace-credited pending deposit", "user_id", user.ID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(tx.AmountCredits))
+	return dbTx.Model(user).Update("unconfirmed_balance", user.UnconfirmedBalance+tx.AmountCredits).Error
+}
+
+// reverseGraceCredit takes a grace-credited deposit back out of the balance
+// through the ledger and tells the user
+func reverseGraceCredit(log *slog.Logger, dbTx *gorm.DB, tx *models.CryptoTransaction) error {
+	user, err := ledger.LockUser(dbTx, tx.UserID)
+	if err != nil {
+		return err
+	}
+	if _, err := ledger.Apply(dbTx, user, ledger.Posting{
+		Type:          models.LedgerTypeGraceDepositReversal,
+		Amount:        -tx.AmountCredits,
+		ReferenceType: ledger.ReferenceTypeCryptoTransaction,
+		ReferenceID:   tx.ID,
+		Description:   fmt.Sprintf("Deposit of %s %s on %s reversed before it confirmed", models.FormatMicroCredits(tx.AmountCredits), tx.TokenSymbol, tx.ChainName),
+	}); err != nil {
+		return err
+	}
+	if err := dbTx.Model(user).Update("unconfirmed_balance", max(user.UnconfirmedBalance-tx.AmountCredits, 0)).Error; err != nil {
+		return err
+	}
+	log.Info("reversed grace-credited deposit", "user_id", user.ID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(tx.AmountCredits))
+	return notify.Send(dbTx, user.ID, notify.TypeDepositReversed, "Deposit reversed",
+		fmt.Sprintf("Your deposit of %s %s on %s did not confirm and its credits were taken back.",
+			models.FormatMicroCredits(tx.AmountCredits), tx.TokenSymbol, tx.ChainName))
+}
@@ -0,0 +1,346 @@
+package wallethandlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/logger"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/receipts"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/screening"
+	"socialpredict/services/selfexclusion"
+
+	"gorm.io/gorm"
+)
+
+func setupPendingDeposit(t *testing.T, optIn bool) (*gorm.DB, models.User, *dfns.TransferEventData) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+
+	user := modelstesting.GenerateUser("depositor", 0)
+	user.BetAgainstPendingDeposits = optIn
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	// Supported chains and their tokens are seeded by migrations
+	usdc, err := chains.TokenOnChain(db, "base", "USDC")
+	if err != nil {
+		t.Fatalf("load seeded token: %v", err)
+	}
+	wallet := models.Wallet{UserID: user.ID, DfnsWalletID: "wa-dep", ChainID: 8453, ChainName: "base", Address: "0xwallet", IsActive: true}
+	if err := db.Create(&wallet).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+
+	data := &dfns.TransferEventData{
+		ID:        "xfer-1",
+		WalletID:  "wa-dep",
+		Direction: "Inbound",
+		Amount:    "25500000", // 25.5 USDC
+		From:      "0x1111111111111111111111111111111111111111",
+		Contract:  usdc.ContractAddress,
+		TxHash:    "0xpending",
+	}
+	return db, user, data
+}
+
+func TestPendingDepositGrantsAllowanceAndConverts(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	screener := screening.NewStaticList(nil)
+
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, false, nil)
+
+	db.First(&user, user.ID)
+	if user.AccountBalance != 0 || user.ProvisionalBalance != 25500000 || user.BettingBalance() != 25500000 {
+		t.Fatalf("expected 25.5 provisional credits only, got balance %d provisional %d", user.AccountBalance, user.ProvisionalBalance)
+	}
+
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 25500000 || user.ProvisionalBalance != 0 {
+		t.Fatalf("expected deposit credited and allowance converted, got %d micro, provisional %d", user.BalanceMicroCredits(), user.ProvisionalBalance)
+	}
+	var credit models.ProvisionalCredit
+	db.First(&credit)
+	if credit.Status != models.ProvisionalStatusConverted {
+		t.Fatalf("expected converted allowance, got %s", credit.Status)
+	}
+}
+
+func TestFailedPendingDepositReversesAllowance(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, false, nil)
+
+	// The user bets 20 of the provisional allowance
+	db.Model(&user).Update("account_balance", -20)
+
+	var tx models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&tx)
+	if err := failPendingDeposit(logger.Structured, db, clk, &tx); err != nil {
+		t.Fatalf("failPendingDeposit: %v", err)
+	}
+
+	db.First(&user, user.ID)
+	if user.ProvisionalBalance != 0 || user.AccountBalance != -20 {
+		t.Fatalf("expected allowance reversed with bets kept, got balance %d provisional %d", user.AccountBalance, user.ProvisionalBalance)
+	}
+	db.First(&tx, tx.ID)
+	if tx.Status != models.TxStatusFailed {
+		t.Fatalf("expected failed deposit, got %s", tx.Status)
+	}
+}
+
+func TestGraceCreditedDepositIsWithdrawableOnceConfirmed(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	db.Model(&models.SupportedChain{}).Where("name = ?", "base").Update("grace_crediting", true)
+	screener := screening.NewStaticList(nil)
+
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, false, nil)
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 25500000 || user.UnconfirmedBalance != 25500000 || user.WithdrawableMicroCredits() != 0 || user.ProvisionalBalance != 0 {
+		t.Fatalf("expected credited but unwithdrawable deposit, got %d micro, unconfirmed %d, provisional %d",
+			user.BalanceMicroCredits(), user.UnconfirmedBalance, user.ProvisionalBalance)
+	}
+
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 25500000 || user.UnconfirmedBalance != 0 || user.WithdrawableMicroCredits() != 25500000 {
+		t.Fatalf("expected deposit credited once and withdrawable, got %d micro, unconfirmed %d", user.BalanceMicroCredits(), user.UnconfirmedBalance)
+	}
+	var entries int64
+	db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type = ?", user.ID, models.LedgerTypeGraceDeposit).Count(&entries)
+	if entries != 1 {
+		t.Fatalf("expected one grace deposit ledger entry, got %d", entries)
+	}
+}
+
+func TestReorgedGraceCreditedDepositIsReversed(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, false)
+	clk := clock.New()
+	db.Model(&models.SupportedChain{}).Where("name = ?", "base").Update("grace_crediting", true)
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, false, nil)
+
+	var tx models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&tx)
+	if err := failPendingDeposit(logger.Structured, db, clk, &tx); err != nil {
+		t.Fatalf("failPendingDeposit: %v", err)
+	}
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 0 || user.UnconfirmedBalance != 0 {
+		t.Fatalf("expected grace credit reversed, got %d micro, unconfirmed %d", user.BalanceMicroCredits(), user.UnconfirmedBalance)
+	}
+	var reversal models.LedgerEntry
+	if err := db.Where("user_id = ? AND type = ?", user.ID, models.LedgerTypeGraceDepositReversal).First(&reversal).Error; err != nil || reversal.Amount != -25500000 {
+		t.Fatalf("expected reversal ledger entry, got %+v, %v", reversal, err)
+	}
+}
+
+func TestPendingDepositWithoutOptInGrantsNothing(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, false)
+	clk := clock.New()
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, false, nil)
+
+	db.First(&user, user.ID)
+	if user.ProvisionalBalance != 0 || user.AccountBalance != 0 {
+		t.Fatalf("expected no credit before confirmation, got balance %d provisional %d", user.AccountBalance, user.ProvisionalBalance)
+	}
+}
+
+func TestDepositToRetiredWalletHeldAfterGracePeriod(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, false)
+	clk := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	db.Model(&models.Wallet{}).Where("dfns_wallet_id = ?", data.WalletID).
+		Updates(map[string]interface{}{"is_active": false, "grace_until": clk.Now().Add(time.Hour)})
+
+	// Within the grace period the deposit is credited as usual
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, true, nil)
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 25500000 {
+		t.Fatalf("balance after grace-period deposit = %d, want 25500000", user.BalanceMicroCredits())
+	}
+
+	clk.Advance(2 * time.Hour)
+	data.ID, data.TxHash = "xfer-2", "0xlate"
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, true, nil)
+
+	var tx models.CryptoTransaction
+	if err := db.Where("tx_hash = ?", data.TxHash).First(&tx).Error; err != nil {
+		t.Fatalf("deposit not recorded: %v", err)
+	}
+	db.First(&user, user.ID)
+	if tx.Status != models.TxStatusOnHold || user.BalanceMicroCredits() != 25500000 {
+		t.Errorf("deposit status %s, balance %d; want ON_HOLD and nothing more credited", tx.Status, user.BalanceMicroCredits())
+	}
+}
+
+type rejectingVerifier struct{}
+
+func (rejectingVerifier) Verify(context.Context, *models.SupportedChain, receipts.Expected) error {
+	return receipts.ErrMismatch
+}
+
+func TestPendingDepositFailingVerificationIsHeld(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	verifier := receipts.NewService(rejectingVerifier{}, receipts.Config{ThresholdMicro: models.CreditsToMicro(10), Timeout: time.Second})
+
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), verifier, data, false, nil)
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), verifier, data, true, nil)
+
+	var tx models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&tx)
+	db.First(&user, user.ID)
+	if tx.Status != models.TxStatusOnHold || user.BalanceMicroCredits() != 0 || user.ProvisionalBalance != 0 {
+		t.Errorf("deposit %s, balance %d, provisional %d; want held with nothing credited",
+			tx.Status, user.BalanceMicroCredits(), user.ProvisionalBalance)
+	}
+}
+
+func TestDepositBelowMinimumRecordedAsDust(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	db.Model(&models.ChainToken{}).Where("contract_address = ?", data.Contract).Update("min_deposit", 50_000_000)
+
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, false, nil)
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, true, nil)
+
+	var txs []models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).Find(&txs)
+	if len(txs) != 1 || txs[0].Status != models.TxStatusDust {
+		t.Fatalf("deposits recorded = %+v, want one DUST", txs)
+	}
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 0 || user.ProvisionalBalance != 0 {
+		t.Errorf("balance %d provisional %d, want nothing credited", user.BalanceMicroCredits(), user.ProvisionalBalance)
+	}
+
+	// At or above the minimum the deposit is credited as usual
+	data.ID, data.TxHash, data.Amount = "xfer-2", "0xlarger", "50000000"
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, true, nil)
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 50_000_000 {
+		t.Errorf("balance = %d, want 50000000", user.BalanceMicroCredits())
+	}
+}
+
+func TestDepositsToBlockedAccountAreHeld(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	screener := screening.NewStaticList(nil)
+
+	// Blocked after the deposit was first seen: confirming holds it
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, false, nil)
+	if _, err := restrictions.Set(db, user.ID, models.RestrictionDepositsBlocked, "source of funds review", nil, "admin", clk.Now()); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+
+	var tx models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&tx)
+	db.First(&user, user.ID)
+	if tx.Status != models.TxStatusOnHold || tx.HoldReason != "Deposits blocked: source of funds review" ||
+		user.BalanceMicroCredits() != 0 || user.ProvisionalBalance != 0 {
+		t.Fatalf("deposit %s (%q), balance %d, provisional %d; want held with nothing credited",
+			tx.Status, tx.HoldReason, user.BalanceMicroCredits(), user.ProvisionalBalance)
+	}
+
+	// A new deposit is held straight away
+	data.ID, data.TxHash = "xfer-2", "0xblocked"
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+	db.Where("tx_hash = ?", data.TxHash).First(&tx)
+	db.First(&user, user.ID)
+	if tx.Status != models.TxStatusOnHold || user.BalanceMicroCredits() != 0 {
+		t.Errorf("second deposit %s, balance %d; want held", tx.Status, user.BalanceMicroCredits())
+	}
+}
+
+func TestDepositsPastSelfImposedLimitsAreHeld(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, false)
+	clk := clock.New()
+	screener := screening.NewStaticList(nil)
+	svc := selfexclusion.NewService(db, clk)
+
+	// 25.5 fits a 30 credit daily limit; a second one does not
+	if _, err := svc.SetDepositLimit(&user, models.CreditsToMicro(30)); err != nil {
+		t.Fatalf("SetDepositLimit: %v", err)
+	}
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+	data.ID, data.TxHash = "xfer-2", "0xover"
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+
+	var tx models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&tx)
+	db.First(&user, user.ID)
+	if tx.Status != models.TxStatusOnHold || tx.HoldReason != "Over the daily deposit limit of 30 credits" ||
+		user.BalanceMicroCredits() != 25_500_000 {
+		t.Fatalf("second deposit %s (%q), balance %d; want it held and only the first credited",
+			tx.Status, tx.HoldReason, user.BalanceMicroCredits())
+	}
+
+	// While cooling off every deposit is held
+	if _, err := svc.SetDepositLimit(&user, 0); err != nil {
+		t.Fatalf("SetDepositLimit: %v", err)
+	}
+	if _, err := svc.Exclude(&user, models.ExclusionCoolingOff, 3); err != nil {
+		t.Fatalf("Exclude: %v", err)
+	}
+	data.ID, data.TxHash, data.Amount = "xfer-3", "0xbreak", "1000000"
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screener, nil, data, true, nil)
+	var held models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&held)
+	if held.Status != models.TxStatusOnHold || !strings.HasPrefix(held.HoldReason, "Deposit during cooling-off until ") {
+		t.Errorf("deposit while cooling off %s (%q), want held", held.Status, held.HoldReason)
+	}
+}
+
+func TestPendingDepositConfirmedTwiceIsCreditedOnce(t *testing.T) {
+	db, user, data := setupPendingDeposit(t, true)
+	clk := clock.New()
+	processInboundTransfer(logger.Structured, db, clk, dfns.PrimaryOrg, screening.NewStaticList(nil), nil, data, false, nil)
+
+	// The confirmed event, transfer.completed and the chain scanner each load
+	// the deposit while it is still PENDING
+	var first, second, third models.CryptoTransaction
+	db.Where("tx_hash = ?", data.TxHash).First(&first)
+	db.Where("tx_hash = ?", data.TxHash).First(&second)
+	db.Where("tx_hash = ?", data.TxHash).First(&third)
+
+	if err := confirmPendingDeposit(logger.Structured, db, clk, nil, &first); err != nil {
+		t.Fatalf("first confirm: %v", err)
+	}
+	if err := confirmPendingDeposit(logger.Structured, db, clk, nil, &second); err != nil {
+		t.Fatalf("second confirm: %v", err)
+	}
+	if err := failPendingDeposit(logger.Structured, db, clk, &third); err != nil {
+		t.Fatalf("late fail: %v", err)
+	}
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != 25500000 || user.ProvisionalBalance != 0 {
+		t.Fatalf("balance %d micro, provisional %d; want the deposit credited once", user.BalanceMicroCredits(), user.ProvisionalBalance)
+	}
+	var entries int64
+	db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type = ?", user.ID, models.LedgerTypeDeposit).Count(&entries)
+	if entries != 1 {
+		t.Errorf("deposit ledger entries = %d, want 1", entries)
+	}
+	var tx models.CryptoTransaction
+	db.First(&tx, first.ID)
+	if tx.Status != models.TxStatusCompleted {
+		t.Errorf("deposit status = %s, want COMPLETED", tx.Status)
+	}
+}
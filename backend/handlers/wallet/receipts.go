@@ -0,0 +1,47 @@
+package wallethandlers
+
+import (
+	"context"
+	"log/slog"
+	"socialpredict/models"
+	"socialpredict/services/receipts"
+
+	"gorm.io/gorm"
+)
+
+// verifyDeposit checks a deposit against its on-chain receipt when it is large
+// enough to need it. It returns a hold reason if the deposit could not be
+// verified, or "" if it may be credited.
+func verifyDeposit(log *slog.Logger, db *gorm.DB, verifier *receipts.Service, tx *models.CryptoTransaction) string {
+	if !verifier.Required(tx.AmountCredits) {
+		return ""
+	}
+
+	var chain models.SupportedChain
+	if err := db.Where("chain_id = ?", tx.ChainID).First(&chain).Error; err != nil {
+		log.Warn("chain not found for deposit verification", "chain_id", tx.ChainID)
+		return "On-chain verification unavailable: chain not configured"
+	}
+	// The recipient must be our wallet, not whatever the payload claims
+	to := tx.ToAddress
+	if tx.WalletID != nil {
+		var wallet models.Wallet
+		if err := db.First(&wallet, *tx.WalletID).Error; err == nil {
+			to = wallet.Address
+		}
+	}
+
+	// Webhook processing does not depend on the DFNS request staying open
+	err := verifier.Check(context.Background(), &chain, receipts.Expected{
+		TxHash:   tx.TxHash,
+		Contract: tx.TokenAddress,
+		To:       to,
+		Amount:   tx.Amount,
+	}, tx.AmountCredits)
+	if err != nil {
+		log.Warn("deposit failed on-chain verification", "tx_hash", tx.TxHash, "error", err)
+		return "On-chain verification failed: " + err.Error()
+	}
+	log.Info("deposit verified on chain", "tx_hash", tx.TxHash)
+	return ""
+}
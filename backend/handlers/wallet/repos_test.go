@@ -0,0 +1,163 @@
+package wallethandlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/repository"
+	"socialpredict/services/screening"
+	"socialpredict/services/transfers"
+	"socialpredict/services/walletsettings"
+)
+
+// stubWalletSettings serves fixed wallet settings and records updates
+type stubWalletSettings struct {
+	repository.WalletRepo
+	settings    walletsettings.Settings
+	updateErr   error
+	defaultsErr error
+	patched     *walletsettings.Patch
+}
+
+func (s *stubWalletSettings) Settings(context.Context, int64) (*walletsettings.Settings, error) {
+	return &s.settings, nil
+}
+
+func (s *stubWalletSettings) UpdateSettings(_ context.Context, _ int64, patch walletsettings.Patch) (*walletsettings.Settings, error) {
+	s.patched = &patch
+	if s.updateErr != nil {
+		return nil, s.updateErr
+	}
+	if patch.PreferredChain != nil {
+		s.settings.PreferredChain = *patch.PreferredChain
+	}
+	return &s.settings, nil
+}
+
+func (s *stubWalletSettings) WithdrawalDefaults(context.Context, int64, *string, *string, *string) error {
+	return s.defaultsErr
+}
+
+// stubTransfers answers every transfer with err, or records it
+type stubTransfers struct {
+	err  error
+	sent *models.CreditTransfer
+}
+
+func (s *stubTransfers) Send(fromUserID int64, toUsername string, amount int64, memo string) (*models.CreditTransfer, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.sent = &models.CreditTransfer{ID: 3, FromUserID: fromUserID, Amount: amount, Memo: memo}
+	return s.sent, nil
+}
+
+func (s *stubTransfers) List(int64, int) ([]transfers.TransferView, error) {
+	return nil, s.err
+}
+
+func authedRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT("alice"))
+	return req
+}
+
+func TestWalletSettingsHandlers_UseRepos(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	modelstesting.CreateUser(t, db, "alice", 100)
+
+	wallets := &stubWalletSettings{settings: walletsettings.Settings{PreferredChain: "base", PreferredToken: "USDC"}}
+	repos := repository.Repos{Wallets: wallets}
+
+	rec := httptest.NewRecorder()
+	GetWalletSettingsHandler(db, repos)(rec, authedRequest("GET", "/v0/wallet/settings", ""))
+	var got walletsettings.Settings
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if rec.Code != http.StatusOK || got.PreferredChain != "base" || got.PreferredToken != "USDC" {
+		t.Fatalf("get = %d %+v", rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	UpdateWalletSettingsHandler(db, repos)(rec, authedRequest("PUT", "/v0/wallet/settings", `{"preferredChain":"ethereum"}`))
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if rec.Code != http.StatusOK || got.PreferredChain != "ethereum" {
+		t.Fatalf("update = %d %+v", rec.Code, got)
+	}
+
+	wallets.updateErr = walletsettings.ErrInvalidChain
+	rec = httptest.NewRecorder()
+	UpdateWalletSettingsHandler(db, repos)(rec, authedRequest("PUT", "/v0/wallet/settings", `{"preferredChain":"nowhere"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid chain status = %d, want 400", rec.Code)
+	}
+}
+
+func TestTransferCreditsHandler_MapsServiceErrors(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	alice := modelstesting.CreateUser(t, db, "alice", 100)
+
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{transfers.ErrRecipientNotFound, http.StatusNotFound},
+		{transfers.ErrInsufficientBalance, http.StatusBadRequest},
+		{transfers.ErrDailyLimitExceeded, http.StatusTooManyRequests},
+		{transfers.ErrDisabled, http.StatusServiceUnavailable},
+		{errors.New("database is down"), http.StatusInternalServerError},
+	} {
+		rec := httptest.NewRecorder()
+		TransferCreditsHandler(db, &stubTransfers{err: tt.err})(rec, authedRequest("POST", "/v0/wallet/transfer", `{"toUsername":"bob","amount":"5"}`))
+		if rec.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+
+	svc := &stubTransfers{}
+	rec := httptest.NewRecorder()
+	TransferCreditsHandler(db, svc)(rec, authedRequest("POST", "/v0/wallet/transfer", `{"toUsername":"bob","amount":"5.5","memo":"lunch"}`))
+	if rec.Code != http.StatusOK || svc.sent == nil || svc.sent.FromUserID != alice.ID || svc.sent.Amount != models.CreditsToMicro(5)+models.MicroCreditsPerCredit/2 {
+		t.Fatalf("send = %d %+v", rec.Code, svc.sent)
+	}
+
+	rec = httptest.NewRecorder()
+	ListTransfersHandler(db, &stubTransfers{err: errors.New("database is down")})(rec, authedRequest("GET", "/v0/wallet/transfers", ""))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("list status = %d, want 500", rec.Code)
+	}
+}
+
+func TestWithdrawalHandlers_FailWhenDefaultsCannotLoad(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+	db := modelstesting.NewFakeDB(t)
+	modelstesting.CreateUser(t, db, "alice", 100)
+	repos := repository.Repos{Wallets: &stubWalletSettings{defaultsErr: errors.New("database is down")}}
+
+	body := `{"amount":"10"}`
+	rec := httptest.NewRecorder()
+	InitiateWithdrawalHandler(db, repos, nil, screening.NewStaticList(nil), nil, nil, nil, nil, nil, clock.New())(rec, authedRequest("POST", "/v0/wallet/withdraw", body))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("initiate status = %d, want 500", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	ValidateWithdrawalHandler(db, repos, nil, nil, clock.New())(rec, authedRequest("POST", "/v0/wallet/withdraw/validate", body))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("validate status = %d, want 500", rec.Code)
+	}
+
+	var requests int64
+	db.Model(&models.WithdrawalRequest{}).Count(&requests)
+	if requests != 0 {
+		t.Fatalf("withdrawal requests = %d, want 0", requests)
+	}
+}
@@ -0,0 +1,109 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/util"
+
+	"gorm.io/gorm"
+)
+
+// sandboxDepositSource is the sender reported for simulated deposits
+const sandboxDepositSource = "sandbox-faucet"
+
+// SimulateDepositRequest represents the request body for a sandbox deposit
+type SimulateDepositRequest struct {
+	ChainName   string      `json:"chainName"`
+	TokenSymbol string      `json:"tokenSymbol"`
+	Amount      json.Number `json:"amount"` // Credits
+}
+
+// SimulateDepositHandler sends a fake deposit to the user's deposit address on
+// a chain. Only registered in DFNS sandbox mode; the deposit arrives through
+// the normal webhook path, unconfirmed first and confirmed after a delay.
+func SimulateDepositHandler(sandbox *dfns.Sandbox) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req SimulateDepositRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amountMicro, err := models.ParseCredits(req.Amount.String())
+		if err != nil || amountMicro <= 0 {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		var wallet models.Wallet
+		if err := db.Where("user_id = ? AND chain_name = ? AND is_active = ?", user.ID, req.ChainName, true).First(&wallet).Error; err != nil {
+			http.Error(w, "No deposit address on this chain; request one first", http.StatusNotFound)
+			return
+		}
+		token, err := chains.ActiveToken(db, wallet.ChainName, req.TokenSymbol)
+		if errors.Is(err, chains.ErrTokenNotFound) {
+			http.Error(w, "Token not available on this chain", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load chain tokens", http.StatusInternalServerError)
+			return
+		}
+
+		amount := dfns.MicroCreditsToTokenAmount(amountMicro, token.Decimals)
+		transfer, err := sandbox.SimulateDeposit(wallet.DfnsWalletID, req.TokenSymbol, token.ContractAddress, amount, sandboxDepositSource)
+		if errors.Is(err, dfns.ErrSandboxWalletNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(transfer)
+	}
+}
+
+// RestoreSandboxWallets re-registers the deposit and treasury hot wallets the
+// database holds with the sandbox, which forgets them on restart
+func RestoreSandboxWallets(db *gorm.DB, sandbox *dfns.Sandbox) error {
+	var wallets []models.Wallet
+	if err := db.Where("dfns_wallet_id <> ''").Find(&wallets).Error; err != nil {
+		return err
+	}
+	for _, wallet := range wallets {
+		sandbox.RestoreWallet(dfns.WalletResponse{
+			ID:      wallet.DfnsWalletID,
+			Network: dfns.GetDFNSNetwork(wallet.ChainName),
+			Address: wallet.Address,
+		})
+	}
+
+	var hot []models.TreasuryWallet
+	if err := db.Where("kind = ? AND dfns_wallet_id <> ''", models.TreasuryWalletHot).Find(&hot).Error; err != nil {
+		return err
+	}
+	for _, wallet := range hot {
+		sandbox.RestoreWallet(dfns.WalletResponse{
+			ID:      wallet.DfnsWalletID,
+			Network: dfns.GetDFNSNetwork(wallet.ChainName),
+			Address: wallet.Address,
+			Name:    wallet.Name,
+		})
+	}
+	return nil
+}
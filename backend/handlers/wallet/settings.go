@@ -0,0 +1,69 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/logger"
+	"socialpredict/middleware"
+	"socialpredict/repository"
+	"socialpredict/services/walletsettings"
+
+	"gorm.io/gorm"
+)
+
+// GetWalletSettingsHandler returns the user's wallet defaults, saved
+// addresses and notification preferences, for prefilling the withdrawal form
+func GetWalletSettingsHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		settings, err := repos.Wallets.Settings(r.Context(), user.ID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("failed to load wallet settings", "user_id", user.ID, "error", err)
+			http.Error(w, "Failed to load wallet settings", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settings)
+	}
+}
+
+// UpdateWalletSettingsHandler changes the fields of the user's wallet
+// settings that the body includes
+func UpdateWalletSettingsHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var patch walletsettings.Patch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		settings, err := repos.Wallets.UpdateSettings(r.Context(), user.ID, patch)
+		if err != nil {
+			switch {
+			case errors.Is(err, walletsettings.ErrInvalidChain), errors.Is(err, walletsettings.ErrInvalidToken),
+				errors.Is(err, walletsettings.ErrInvalidAddress), errors.Is(err, walletsettings.ErrTooMany):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				logger.FromContext(r.Context()).Error("failed to update wallet settings", "user_id", user.ID, "error", err)
+				http.Error(w, "Failed to update wallet settings", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settings)
+	}
+}
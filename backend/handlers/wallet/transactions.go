@@ -1,149 +1,225 @@
 package wallethandlers
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"socialpredict/middleware"
 	"socialpredict/models"
-	"socialpredict/util"
+	"socialpredict/repository"
+	"socialpredict/services/explorer"
 	"strconv"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// errInvalidCursor is returned for a cursor that was not produced by this API
+var errInvalidCursor = errors.New("invalid cursor")
+
 // TransactionListResponse represents a paginated list of transactions
 type TransactionListResponse struct {
 	Transactions []TransactionItem `json:"transactions"`
 	Total        int64             `json:"total"`
 	Page         int               `json:"page"`
 	PageSize     int               `json:"pageSize"`
+	NextCursor   string            `json:"nextCursor,omitempty"` // Pass as ?cursor= to fetch the next page; empty on the last page
 }
 
-// TransactionItem represents a single transaction in the list
-type TransactionItem struct {
-	ID            uint       `json:"id"`
-	Type          string     `json:"type"`
-	Status        string     `json:"status"`
-	ChainName     string     `json:"chainName"`
-	TokenSymbol   string     `json:"tokenSymbol"`
-	Amount        int64      `json:"amount"`
-	TxHash        string     `json:"txHash,omitempty"`
-	FromAddress   string     `json:"fromAddress,omitempty"`
-	ToAddress     string     `json:"toAddress,omitempty"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	ProcessedAt   *time.Time `json:"processedAt,omitempty"`
+// transactionCursor is the keyset position of the last transaction on a page
+type transactionCursor struct {
+	CreatedAt time.Time
+	ID        uint
 }
 
-// GetTransactionHistoryHandler returns the user's crypto transaction history
-func GetTransactionHistoryHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
-	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
-	if httperr != nil {
-		http.Error(w, httperr.Error(), httperr.StatusCode)
-		return
-	}
+// encode renders the cursor as an opaque URL-safe string
+func (c transactionCursor) encode() string {
+	raw := fmt.Sprintf("%s|%d", c.CreatedAt.UTC().Format(time.RFC3339Nano), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
 
-	// Parse pagination params
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
+// decodeTransactionCursor parses a cursor produced by encode
+func decodeTransactionCursor(s string) (transactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return transactionCursor{}, errInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return transactionCursor{}, errInvalidCursor
 	}
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
-	if pageSize < 1 || pageSize > 50 {
-		pageSize = 20
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return transactionCursor{}, errInvalidCursor
 	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return transactionCursor{}, errInvalidCursor
+	}
+	return transactionCursor{CreatedAt: createdAt, ID: uint(id)}, nil
+}
 
-	// Optional filters
-	txType := r.URL.Query().Get("type")   // DEPOSIT, WITHDRAWAL
-	status := r.URL.Query().Get("status") // PENDING, COMPLETED, etc.
+// TransactionItem represents a single transaction in the list
+type TransactionItem struct {
+	ID          uint       `json:"id"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	ChainName   string     `json:"chainName"`
+	TokenSymbol string     `json:"tokenSymbol"`
+	Amount      float64    `json:"amount"`      // Credits, rounded for display
+	AmountMicro int64      `json:"amountMicro"` // Exact amount in micro-credits
+	TxHash      string     `json:"txHash,omitempty"`
+	FromAddress string     `json:"fromAddress,omitempty"`
+	ToAddress   string     `json:"toAddress,omitempty"`
+	ExplorerURL string     `json:"explorerUrl,omitempty"` // Block explorer link for the transaction, where known
+	CreatedAt   time.Time  `json:"createdAt"`
+	ProcessedAt *time.Time `json:"processedAt,omitempty"`
+}
 
-	// Build query
-	query := db.Model(&models.CryptoTransaction{}).Where("user_id = ?", user.ID)
+// GetTransactionHistoryHandler returns the user's crypto transaction history
+func GetTransactionHistoryHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
 
-	if txType != "" {
-		query = query.Where("type = ?", txType)
-	}
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
+		// Parse pagination params. A cursor takes precedence over page; page is
+		// kept for older clients.
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		if pageSize < 1 || pageSize > 50 {
+			pageSize = 20
+		}
 
-	var total int64
-	query.Count(&total)
-
-	var transactions []models.CryptoTransaction
-	offset := (page - 1) * pageSize
-	query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&transactions)
-
-	// Map to response items
-	items := make([]TransactionItem, len(transactions))
-	for i, tx := range transactions {
-		items[i] = TransactionItem{
-			ID:          tx.ID,
-			Type:        tx.Type,
-			Status:      tx.Status,
-			ChainName:   tx.ChainName,
-			TokenSymbol: tx.TokenSymbol,
-			Amount:      tx.AmountCredits,
-			TxHash:      tx.TxHash,
-			FromAddress: tx.FromAddress,
-			ToAddress:   tx.ToAddress,
-			CreatedAt:   tx.CreatedAt,
-			ProcessedAt: tx.ProcessedAt,
+		var cursor *transactionCursor
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			decoded, err := decodeTransactionCursor(c)
+			if err != nil {
+				http.Error(w, "Invalid cursor", http.StatusBadRequest)
+				return
+			}
+			cursor = &decoded
 		}
-	}
 
-	response := TransactionListResponse{
-		Transactions: items,
-		Total:        total,
-		Page:         page,
-		PageSize:     pageSize,
-	}
+		// Optional filters: type is DEPOSIT or WITHDRAWAL, status PENDING, COMPLETED, etc.
+		filter := repository.TransactionFilter{
+			UserID: user.ID,
+			Type:   r.URL.Query().Get("type"),
+			Status: r.URL.Query().Get("status"),
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
+		total, err := repos.Transactions.Count(r.Context(), filter)
+		if err != nil {
+			http.Error(w, "Failed to load transactions", http.StatusInternalServerError)
+			return
+		}
 
-// GetTransactionByIDHandler returns a specific transaction by ID
-func GetTransactionByIDHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
-	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
-	if httperr != nil {
-		http.Error(w, httperr.Error(), httperr.StatusCode)
-		return
-	}
+		transactions, nextCursor, err := pageTransactions(r.Context(), repos.Transactions, filter, cursor, page, pageSize)
+		if err != nil {
+			http.Error(w, "Failed to load transactions", http.StatusInternalServerError)
+			return
+		}
 
-	// Get transaction ID from URL
-	txIDStr := r.URL.Query().Get("id")
-	if txIDStr == "" {
-		http.Error(w, "Transaction ID required", http.StatusBadRequest)
-		return
-	}
+		// Map to response items; explorer links are best effort
+		links, _ := explorer.Load(db)
+		items := make([]TransactionItem, len(transactions))
+		for i, tx := range transactions {
+			items[i] = newTransactionItem(tx, links)
+		}
 
-	txID, err := strconv.ParseUint(txIDStr, 10, 32)
-	if err != nil {
-		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
-		return
-	}
+		response := TransactionListResponse{
+			Transactions: items,
+			Total:        total,
+			Page:         page,
+			PageSize:     pageSize,
+			NextCursor:   nextCursor,
+		}
 
-	var tx models.CryptoTransaction
-	if err := db.Where("id = ? AND user_id = ?", txID, user.ID).First(&tx).Error; err != nil {
-		http.Error(w, "Transaction not found", http.StatusNotFound)
-		return
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
 	}
+}
 
-	response := TransactionItem{
+// newTransactionItem maps a transaction to its response item
+func newTransactionItem(tx models.CryptoTransaction, links *explorer.Links) TransactionItem {
+	return TransactionItem{
 		ID:          tx.ID,
 		Type:        tx.Type,
 		Status:      tx.Status,
 		ChainName:   tx.ChainName,
 		TokenSymbol: tx.TokenSymbol,
-		Amount:      tx.AmountCredits,
+		Amount:      models.DisplayCredits(tx.AmountCredits),
+		AmountMicro: tx.AmountCredits,
 		TxHash:      tx.TxHash,
 		FromAddress: tx.FromAddress,
 		ToAddress:   tx.ToAddress,
+		ExplorerURL: links.Tx(tx.ChainName, tx.TxHash),
 		CreatedAt:   tx.CreatedAt,
 		ProcessedAt: tx.ProcessedAt,
 	}
+}
+
+// pageTransactions fetches one page newest first. With a cursor it seeks past
+// the cursor position (keyset pagination), otherwise it falls back to offset.
+// One extra row is read to tell whether a next page exists.
+func pageTransactions(ctx context.Context, txs repository.TransactionRepo, filter repository.TransactionFilter, cursor *transactionCursor, page, pageSize int) ([]models.CryptoTransaction, string, error) {
+	selection := repository.TransactionPage{Offset: (page - 1) * pageSize, Limit: pageSize + 1}
+	if cursor != nil {
+		selection = repository.TransactionPage{AfterCreatedAt: cursor.CreatedAt, AfterID: cursor.ID, Limit: pageSize + 1}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	transactions, err := txs.Page(ctx, filter, selection)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(transactions) <= pageSize {
+		return transactions, "", nil
+	}
+
+	transactions = transactions[:pageSize]
+	last := transactions[pageSize-1]
+	return transactions, transactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode(), nil
+}
+
+// GetTransactionByIDHandler returns a specific transaction by ID
+func GetTransactionByIDHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		// Get transaction ID from URL
+		txIDStr := r.URL.Query().Get("id")
+		if txIDStr == "" {
+			http.Error(w, "Transaction ID required", http.StatusBadRequest)
+			return
+		}
+
+		txID, err := strconv.ParseUint(txIDStr, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := repos.Transactions.GetForUser(r.Context(), user.ID, uint(txID))
+		if err != nil {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+
+		links, _ := explorer.Load(db)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newTransactionItem(*tx, links))
+	}
 }
@@ -0,0 +1,84 @@
+package wallethandlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/repository"
+)
+
+func TestPageTransactionsKeysetCursor(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Five transactions for user 1, two sharing a timestamp, and one for user 2
+	for i, offset := range []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		tx := models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+			ChainName: "base", TokenSymbol: "USDC", TxHash: "0x" + string(rune('a'+i))}
+		tx.CreatedAt = base.Add(offset)
+		if err := db.Create(&tx).Error; err != nil {
+			t.Fatalf("create transaction: %v", err)
+		}
+	}
+	other := models.CryptoTransaction{UserID: 2, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+		ChainName: "base", TokenSymbol: "USDC", TxHash: "0xother"}
+	other.CreatedAt = base.Add(30 * time.Second)
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatalf("create transaction: %v", err)
+	}
+
+	repo := repository.NewGormTransactionRepo(db)
+	filter := repository.TransactionFilter{UserID: 1}
+	ctx := context.Background()
+
+	var seen []uint
+	var cursor *transactionCursor
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor pagination did not terminate")
+		}
+		txs, next, err := pageTransactions(ctx, repo, filter, cursor, 1, 2)
+		if err != nil {
+			t.Fatalf("page: %v", err)
+		}
+		for _, tx := range txs {
+			seen = append(seen, tx.ID)
+		}
+		if next == "" {
+			break
+		}
+		decoded, err := decodeTransactionCursor(next)
+		if err != nil {
+			t.Fatalf("decode cursor: %v", err)
+		}
+		cursor = &decoded
+	}
+
+	// Newest first, ties broken by ID, no duplicates or gaps, other users excluded
+	want := []uint{5, 4, 3, 2, 1}
+	if len(seen) != len(want) {
+		t.Fatalf("got IDs %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("got IDs %v, want %v", seen, want)
+		}
+	}
+
+	// Offset pagination still works and hands out a cursor for the next page
+	txs, next, err := pageTransactions(ctx, repo, filter, nil, 2, 2)
+	if err != nil || len(txs) != 2 || txs[0].ID != 3 || next == "" {
+		t.Fatalf("offset page: %v %+v %q", err, txs, next)
+	}
+}
+
+func TestDecodeTransactionCursorRejectsGarbage(t *testing.T) {
+	for _, c := range []string{"not-base64!", "bm9waXBl", transactionCursor{CreatedAt: time.Now(), ID: 7}.encode()[:5]} {
+		if _, err := decodeTransactionCursor(c); err == nil {
+			t.Errorf("expected error for cursor %q", c)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/logger"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/transfers"
+
+	"gorm.io/gorm"
+)
+
+// TransferRequestBody represents the request body for sending credits to another user
+type TransferRequestBody struct {
+	ToUsername string      `json:"toUsername"`
+	Amount     json.Number `json:"amount"` // Credits, up to 6 decimal places
+	Memo       string      `json:"memo,omitempty"`
+}
+
+// CreditTransfers sends and lists credit transfers between users
+type CreditTransfers interface {
+	Send(fromUserID int64, toUsername string, amount int64, memo string) (*models.CreditTransfer, error)
+	List(userID int64, limit int) ([]transfers.TransferView, error)
+}
+
+// TransferCreditsHandler sends credits from the user to another user
+func TransferCreditsHandler(db *gorm.DB, svc CreditTransfers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req TransferRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ToUsername == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		amountMicro, err := models.ParseCredits(req.Amount.String())
+		if err != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		transfer, err := svc.Send(user.ID, req.ToUsername, amountMicro, req.Memo)
+		if err != nil {
+			var restrictedErr *restrictions.RestrictedError
+			switch {
+			case errors.As(err, &restrictedErr):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, transfers.ErrDisabled):
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			case errors.Is(err, transfers.ErrRecipientNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, transfers.ErrDailyLimitExceeded):
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			case errors.Is(err, transfers.ErrInvalidAmount), errors.Is(err, transfers.ErrSelfTransfer),
+				errors.Is(err, transfers.ErrInsufficientBalance), errors.Is(err, transfers.ErrMemoTooLong),
+				errors.Is(err, transfers.ErrBonusNotTransferable):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				logger.FromContext(r.Context()).Error("credit transfer failed", "user_id", user.ID, "error", err)
+				http.Error(w, "Failed to transfer credits", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transfers.TransferView{
+			ID:           transfer.ID,
+			Direction:    "sent",
+			Counterparty: req.ToUsername,
+			Amount:       models.DisplayCredits(transfer.Amount),
+			AmountMicro:  transfer.Amount,
+			Memo:         transfer.Memo,
+			CreatedAt:    transfer.CreatedAt,
+		})
+	}
+}
+
+// ListTransfersHandler returns the user's recent sent and received transfers
+func ListTransfersHandler(db *gorm.DB, svc CreditTransfers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		views, err := svc.List(user.ID, 50)
+		if err != nil {
+			http.Error(w, "Failed to load transfers", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"transfers": views})
+	}
+}
@@ -0,0 +1,218 @@
+package wallethandlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	wallethandlers "socialpredict/handlers/wallet"
+	"socialpredict/handlers/wallet/wallettesting"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/limits"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/settings"
+)
+
+const payoutAddress = "0x2222222222222222222222222222222222222222"
+
+func withdrawalStatus(h *wallettesting.Harness, id uint) string {
+	var withdrawalReq models.WithdrawalRequest
+	h.DB.First(&withdrawalReq, id)
+	return withdrawalReq.Status
+}
+
+// requestWithdrawal submits a withdrawal through the API and returns its ID
+func requestWithdrawal(t *testing.T, h *wallettesting.Harness, user models.User, credits int64, toAddress string) uint {
+	t.Helper()
+	rr := h.Do(t, "POST", "/v0/wallet/withdraw", user.Username, map[string]interface{}{
+		"chainName": "ethereum", "tokenSymbol": "USDC", "amount": credits, "toAddress": toAddress,
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("withdraw status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp wallethandlers.WithdrawalResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("withdraw response: %v", err)
+	}
+	return resp.RequestID
+}
+
+func approve(t *testing.T, h *wallettesting.Harness, admin models.User, id uint) {
+	t.Helper()
+	rr := h.Do(t, "POST", fmt.Sprintf("/v0/admin/withdrawals/%d/approve", id), admin.Username, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("approve status = %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDepositWebhookCreditsUser(t *testing.T) {
+	h := wallettesting.New(t)
+	user := h.CreateUser(t, "depositor", 0)
+	wallet := h.CreateWallet(t, user, "ethereum")
+
+	h.Deposit(t, wallet, "USDC", 40)
+
+	wallettesting.Eventually(t, "deposit credited", func() bool {
+		return h.Balance(t, user.ID) == models.CreditsToMicro(40)
+	})
+	var deposit models.CryptoTransaction
+	h.DB.Where("user_id = ? AND type = ?", user.ID, models.TxTypeDeposit).First(&deposit)
+	if deposit.Status != models.TxStatusCompleted || deposit.AmountCredits != models.CreditsToMicro(40) {
+		t.Fatalf("deposit = %s for %d micro", deposit.Status, deposit.AmountCredits)
+	}
+
+	rr := h.Do(t, "GET", "/v0/wallet/balance", user.Username, nil)
+	var balance wallethandlers.BalanceResponse
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &balance) != nil || balance.AvailableMicro != models.CreditsToMicro(40) {
+		t.Fatalf("balance = %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestApprovedWithdrawalCompletes(t *testing.T) {
+	h := wallettesting.New(t)
+	admin := h.CreateAdmin(t, "finance")
+	user := h.CreateUser(t, "alice", 200)
+	h.CreateWallet(t, user, "ethereum")
+
+	id := requestWithdrawal(t, h, user, 60, payoutAddress)
+	if balance := h.Balance(t, user.ID); balance != models.CreditsToMicro(140) {
+		t.Fatalf("balance after request = %d, want 60 credits reserved", balance)
+	}
+
+	approve(t, h, admin, id)
+	wallettesting.Eventually(t, "withdrawal completed", func() bool {
+		return withdrawalStatus(h, id) == models.TxStatusCompleted
+	})
+	if balance := h.Balance(t, user.ID); balance != models.CreditsToMicro(140) {
+		t.Fatalf("balance after completion = %d, want 140 credits", balance)
+	}
+}
+
+func TestFailedWithdrawalIsRefunded(t *testing.T) {
+	h := wallettesting.New(t)
+	admin := h.CreateAdmin(t, "finance")
+	user := h.CreateUser(t, "alice", 200)
+	h.CreateWallet(t, user, "ethereum")
+
+	// The sandbox fails transfers to addresses ending in "dead"
+	id := requestWithdrawal(t, h, user, 60, "0x000000000000000000000000000000000000dead")
+	approve(t, h, admin, id)
+
+	wallettesting.Eventually(t, "withdrawal failed", func() bool {
+		return withdrawalStatus(h, id) == models.TxStatusFailed
+	})
+	if balance := h.Balance(t, user.ID); balance != models.CreditsToMicro(200) {
+		t.Fatalf("balance after failure = %d, want the 60 credits refunded", balance)
+	}
+}
+
+func TestDailyWithdrawalLimit(t *testing.T) {
+	h := wallettesting.New(t)
+	user := h.CreateUser(t, "alice", 200)
+	err := settings.Shared.SetWithdrawalLimits(h.DB, settings.WithdrawalLimits{
+		Min: models.CreditsToMicro(50), Max: models.CreditsToMicro(100), Daily: models.CreditsToMicro(100),
+	}, "test")
+	if err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	// An earlier withdrawal today already uses 50 of the 100 credits
+	modelstesting.CreateWithdrawal(t, h.DB, user.ID, "ethereum", "USDC", payoutAddress, models.CreditsToMicro(50))
+
+	rr := h.Do(t, "POST", "/v0/wallet/withdraw", user.Username, map[string]interface{}{
+		"chainName": "ethereum", "tokenSymbol": "USDC", "amount": 60, "toAddress": payoutAddress,
+	})
+	var resp wallethandlers.WithdrawalLimitResponse
+	if rr.Code != http.StatusBadRequest || json.Unmarshal(rr.Body.Bytes(), &resp) != nil || resp.Window != limits.WindowDaily {
+		t.Fatalf("over the daily limit = %d: %s", rr.Code, rr.Body.String())
+	}
+
+	requestWithdrawal(t, h, user, 50, payoutAddress)
+	if balance := h.Balance(t, user.ID); balance != models.CreditsToMicro(150) {
+		t.Fatalf("balance = %d, want only the allowed withdrawal taken", balance)
+	}
+}
+
+func TestFrozenWithdrawalsAreRefused(t *testing.T) {
+	h := wallettesting.New(t)
+	admin := h.CreateAdmin(t, "finance")
+	user := h.CreateUser(t, "alice", 200)
+	h.CreateWallet(t, user, "ethereum")
+	id := requestWithdrawal(t, h, user, 60, payoutAddress)
+
+	if _, err := restrictions.Set(h.DB, user.ID, models.RestrictionWithdrawalsFrozen, "account takeover suspected", nil, admin.Username, time.Now()); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	rr := h.Do(t, "POST", "/v0/wallet/withdraw", user.Username, map[string]interface{}{
+		"chainName": "ethereum", "tokenSymbol": "USDC", "amount": 60, "toAddress": payoutAddress,
+	})
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("withdraw while frozen = %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = h.Do(t, "POST", fmt.Sprintf("/v0/admin/withdrawals/%d/approve", id), admin.Username, nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("approve while frozen = %d: %s", rr.Code, rr.Body.String())
+	}
+	if status := withdrawalStatus(h, id); status != models.TxStatusPending {
+		t.Fatalf("withdrawal status = %s, want it left pending", status)
+	}
+
+	// An expired freeze no longer applies
+	h.DB.Model(&models.UserRestriction{}).Where("user_id = ?", user.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	approve(t, h, admin, id)
+}
+
+// deliver posts a signed DFNS webhook and returns the response status
+func deliver(t *testing.T, h *wallettesting.Harness, event dfns.WebhookEvent) int {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("encode event: %v", err)
+	}
+	req := httptest.NewRequest("POST", wallettesting.WebhookPath, bytes.NewReader(payload))
+	req.Header.Set("X-DFNS-Signature", dfns.SignWebhookPayload(payload, wallettesting.WebhookSecret))
+	rr := httptest.NewRecorder()
+	h.Server.Config.Handler.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestFailedWebhookIsRedelivered(t *testing.T) {
+	h := wallettesting.New(t)
+	user := h.CreateUser(t, "depositor", 0)
+	wallet := h.CreateWallet(t, user, "ethereum")
+	token, err := chains.TokenOnChain(h.DB, wallet.ChainName, "USDC")
+	if err != nil {
+		t.Fatalf("load USDC: %v", err)
+	}
+	data, _ := json.Marshal(dfns.TransferEventData{
+		ID: "xfer-retry", WalletID: wallet.DfnsWalletID, Status: dfns.TransferStatusConfirmed, TxHash: "0xretry",
+		Direction: "Inbound", Symbol: "USDC", Amount: "25000000", Contract: token.ContractAddress,
+		From: "0x1111111111111111111111111111111111111111", To: wallet.Address, Decimals: 6,
+	})
+	event := dfns.WebhookEvent{ID: "evt-retry", Kind: dfns.EventTransferConfirmed, Data: data, Timestamp: time.Now().UTC().Format(time.RFC3339)}
+
+	// The deposit cannot be recorded while its table is gone
+	if err := h.DB.Migrator().DropTable(&models.CryptoTransaction{}); err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+	if status := deliver(t, h, event); status != http.StatusInternalServerError {
+		t.Fatalf("failed delivery = %d, want 500", status)
+	}
+	if err := h.DB.AutoMigrate(&models.CryptoTransaction{}); err != nil {
+		t.Fatalf("restore table: %v", err)
+	}
+
+	if status := deliver(t, h, event); status != http.StatusOK {
+		t.Fatalf("redelivery = %d, want 200", status)
+	}
+	if balance := h.Balance(t, user.ID); balance != models.CreditsToMicro(25) {
+		t.Fatalf("balance = %d, want the deposit credited on redelivery", balance)
+	}
+}
@@ -0,0 +1,143 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"socialpredict/clock"
+	"socialpredict/logger"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/repository"
+	"socialpredict/services/walletbalances"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// uncreditedDepositStatuses are the deposit statuses recorded against a wallet
+// but not yet added to the user's balance
+var uncreditedDepositStatuses = []string{models.TxStatusPending, models.TxStatusOnHold}
+
+// WalletTokenBalance is a deposit wallet's on-chain balance of one token
+type WalletTokenBalance struct {
+	TokenSymbol     string    `json:"tokenSymbol"`
+	ContractAddress string    `json:"contractAddress"`
+	Balance         float64   `json:"balance"` // Credits, rounded for display
+	BalanceMicro    int64     `json:"balanceMicro"`
+	FetchedAt       time.Time `json:"fetchedAt"`
+}
+
+// WalletPendingDeposits sums the deposits of one token that reached a wallet
+// but are not yet credited: awaiting confirmations or held for review
+type WalletPendingDeposits struct {
+	TokenSymbol string  `json:"tokenSymbol"`
+	Count       int64   `json:"count"`
+	Amount      float64 `json:"amount"` // Credits, rounded for display
+	AmountMicro int64   `json:"amountMicro"`
+}
+
+// WalletListItem is one of the user's deposit wallets
+type WalletListItem struct {
+	ID              uint                    `json:"id"`
+	ChainID         int64                   `json:"chainId"`
+	ChainName       string                  `json:"chainName"`
+	DisplayName     string                  `json:"displayName"`
+	Address         string                  `json:"address"`
+	IsActive        bool                    `json:"isActive"`
+	AcceptsDeposits bool                    `json:"acceptsDeposits"` // False for a retired address past its grace period
+	GraceUntil      *time.Time              `json:"graceUntil,omitempty"`
+	CreatedAt       time.Time               `json:"createdAt"`
+	Balances        []WalletTokenBalance    `json:"balances"`
+	Pending         []WalletPendingDeposits `json:"pending"`
+}
+
+// GetWalletsHandler lists the user's deposit wallets on every chain, retired
+// ones included, with their on-chain token balances and the deposits that
+// reached them but are not yet credited
+func GetWalletsHandler(db *gorm.DB, wallets repository.WalletRepo, balances *walletbalances.Service, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		userWallets, err := wallets.ListByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "Failed to load wallets", http.StatusInternalServerError)
+			return
+		}
+
+		now := c.Now()
+		items := make([]WalletListItem, 0, len(userWallets))
+		for i := range userWallets {
+			wallet := &userWallets[i]
+			item, err := walletListItem(r, db, balances, wallet, now)
+			if err != nil {
+				logger.FromContext(r.Context()).Error("failed to load wallet balances", "wallet_id", wallet.ID, "error", err)
+				http.Error(w, "Failed to load wallet balances", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, *item)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"wallets": items})
+	}
+}
+
+func walletListItem(r *http.Request, db *gorm.DB, balances *walletbalances.Service, wallet *models.Wallet, now time.Time) (*WalletListItem, error) {
+	displayName := wallet.ChainName
+	if info, ok := models.ChainInfo[wallet.ChainName]; ok {
+		displayName = info.DisplayName
+	}
+	item := &WalletListItem{
+		ID:              wallet.ID,
+		ChainID:         wallet.ChainID,
+		ChainName:       wallet.ChainName,
+		DisplayName:     displayName,
+		Address:         wallet.Address,
+		IsActive:        wallet.IsActive,
+		AcceptsDeposits: wallet.AcceptsDeposits(now),
+		GraceUntil:      wallet.GraceUntil,
+		CreatedAt:       wallet.CreatedAt,
+		Balances:        []WalletTokenBalance{},
+		Pending:         []WalletPendingDeposits{},
+	}
+
+	snapshots, err := balances.Balances(r.Context(), wallet)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		item.Balances = append(item.Balances, WalletTokenBalance{
+			TokenSymbol:     snapshot.TokenSymbol,
+			ContractAddress: snapshot.ContractAddress,
+			Balance:         models.DisplayCredits(snapshot.Balance),
+			BalanceMicro:    snapshot.Balance,
+			FetchedAt:       snapshot.FetchedAt,
+		})
+	}
+
+	var pending []struct {
+		TokenSymbol string
+		Count       int64
+		Sum         int64
+	}
+	if err := db.Model(&models.CryptoTransaction{}).
+		Select("token_symbol, COUNT(*) AS count, COALESCE(SUM(amount_credits), 0) AS sum").
+		Where("wallet_id = ? AND type = ? AND status IN ?", wallet.ID, models.TxTypeDeposit, uncreditedDepositStatuses).
+		Group("token_symbol").Order("token_symbol").
+		Scan(&pending).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range pending {
+		item.Pending = append(item.Pending, WalletPendingDeposits{
+			TokenSymbol: p.TokenSymbol,
+			Count:       p.Count,
+			Amount:      models.DisplayCredits(p.Sum),
+			AmountMicro: p.Sum,
+		})
+	}
+	return item, nil
+}
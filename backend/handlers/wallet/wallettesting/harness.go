@@ -0,0 +1,200 @@
+// Package wallettesting runs the wallet and withdrawal handlers against an
+// in-memory database and the DFNS sandbox, so integration tests can follow
+// credits from a deposit webhook through to a settled or refunded withdrawal.
+package wallettesting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"socialpredict/api"
+	"socialpredict/clock"
+	adminhandlers "socialpredict/handlers/admin"
+	wallethandlers "socialpredict/handlers/wallet"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/repository"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/replay"
+	"socialpredict/services/roles"
+	"socialpredict/services/saga"
+	"socialpredict/services/screening"
+	"socialpredict/services/settings"
+	"socialpredict/services/withdrawalflow"
+	"socialpredict/util"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	// WebhookSecret signs sandbox webhooks and is checked by the webhook handler
+	WebhookSecret = "wallettesting-webhook-secret"
+	// ConfirmDelay is how long sandbox transfers and deposits take to settle
+	ConfirmDelay = 20 * time.Millisecond
+	// WebhookPath is where the sandbox delivers its webhooks
+	WebhookPath = "/v0/webhook/dfns"
+)
+
+// Harness is a running wallet backend. Sandbox webhooks arrive over HTTP on
+// Server and are handled asynchronously, so tests wait for their effects
+// with Eventually.
+type Harness struct {
+	DB      *gorm.DB
+	Sandbox *dfns.Sandbox
+	Orgs    *dfns.Orgs
+	Flows   *saga.Coordinator
+	Server  *httptest.Server
+}
+
+// New starts a harness whose database, server and shared caches are torn
+// down when the test ends. It replaces util.DB, so tests using it must not
+// run in parallel.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	t.Setenv("JWT_SIGNING_KEY", "test-secret-key-for-testing")
+
+	db := modelstesting.NewFakeDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// The in-memory database lives on one connection, which webhook
+	// deliveries and the test take turns on
+	sqlDB.SetMaxOpenConns(1)
+
+	orig := util.DB
+	util.DB = db
+	t.Cleanup(func() { util.DB = orig })
+	t.Cleanup(chains.Shared.Invalidate)
+	t.Cleanup(settings.Shared.Invalidate)
+
+	router := mux.NewRouter()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	sandbox := dfns.NewSandbox(dfns.SandboxConfig{
+		WebhookURL:    server.URL + WebhookPath,
+		WebhookSecret: WebhookSecret,
+		ConfirmDelay:  ConfirmDelay,
+	})
+	orgs := sandbox.Orgs()
+	clk := clock.New()
+	flows := saga.NewCoordinator(db, clk)
+	withdrawalflow.Register(flows, orgs, clk)
+	guard := replay.NewGuard(db, replay.Config{Tolerance: time.Minute}, clk)
+	screener := screening.NewStaticList(nil)
+	repos := repository.NewGormRepos(db)
+
+	webhook := wallethandlers.DFNSWebhookHandler(db, repos, orgs, screener, flows, guard, nil, clk)
+	router.HandleFunc(WebhookPath, webhook).Methods("POST")
+	router.HandleFunc(WebhookPath+"/{org}", webhook).Methods("POST")
+
+	registry := api.NewRegistry()
+	documented := func(route api.Route, h http.HandlerFunc) {
+		router.Handle(route.Path, registry.Register(route, h)).Methods(route.Method)
+	}
+	documented(api.WalletBalance, wallethandlers.GetBalanceHandler)
+	documented(api.WalletWithdraw, wallethandlers.InitiateWithdrawalHandler(db, repos, orgs, screener, nil, nil, nil, nil, nil, clk))
+	documented(api.AdminApproveWithdrawal, adminhandlers.ApproveWithdrawalHandler(db, repos, flows, clk))
+
+	return &Harness{DB: db, Sandbox: sandbox, Orgs: orgs, Flows: flows, Server: server}
+}
+
+// CreateUser stores a user who can call the API, holding the given whole credits
+func (h *Harness) CreateUser(t testing.TB, username string, credits int64) models.User {
+	t.Helper()
+	return modelstesting.CreateUser(t, h.DB, username, credits)
+}
+
+// CreateAdmin stores an admin with the finance role, who may approve withdrawals
+func (h *Harness) CreateAdmin(t testing.TB, username string) models.User {
+	t.Helper()
+	admin := h.CreateUser(t, username, 0)
+	if err := h.DB.Model(&admin).Update("user_type", "ADMIN").Error; err != nil {
+		t.Fatalf("promote %s: %v", username, err)
+	}
+	admin.UserType = "ADMIN"
+	if err := roles.Assign(h.DB, admin.ID, models.RoleFinance, "wallettesting"); err != nil {
+		t.Fatalf("assign finance role: %v", err)
+	}
+	return admin
+}
+
+// CreateWallet opens a sandbox wallet for the user on chainName and stores it
+func (h *Harness) CreateWallet(t testing.TB, user models.User, chainName string) models.Wallet {
+	t.Helper()
+	created, err := h.Orgs.Primary().CreateWallet(context.Background(), dfns.CreateWalletRequest{
+		Network: dfns.GetDFNSNetwork(chainName),
+		Name:    user.Username + "-" + chainName,
+	})
+	if err != nil {
+		t.Fatalf("create sandbox wallet: %v", err)
+	}
+	return modelstesting.CreateWallet(t, h.DB, user.ID, chainName, created.ID, created.Address)
+}
+
+// Deposit sends whole tokens to the wallet from outside. DFNS reports the
+// transfer as inbound now and confirmed after ConfirmDelay.
+func (h *Harness) Deposit(t testing.TB, wallet models.Wallet, tokenSymbol string, tokens int64) {
+	t.Helper()
+	token, err := chains.TokenOnChain(h.DB, wallet.ChainName, tokenSymbol)
+	if err != nil {
+		t.Fatalf("load %s on %s: %v", tokenSymbol, wallet.ChainName, err)
+	}
+	amount := new(big.Int).Mul(big.NewInt(tokens), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(token.Decimals)), nil))
+	if _, err := h.Sandbox.SimulateDeposit(wallet.DfnsWalletID, tokenSymbol, token.ContractAddress, amount.String(),
+		"0x1111111111111111111111111111111111111111"); err != nil {
+		t.Fatalf("simulate deposit: %v", err)
+	}
+}
+
+// Do sends an API request as username, encoding body as JSON when it is not nil
+func (h *Harness) Do(t testing.TB, method, path, username string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var raw []byte
+	if body != nil {
+		var err error
+		if raw, err = json.Marshal(body); err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	if username != "" {
+		req.Header.Set("Authorization", "Bearer "+modelstesting.GenerateValidJWT(username))
+	}
+	rr := httptest.NewRecorder()
+	h.Server.Config.Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// Balance returns the user's current balance in micro-credits
+func (h *Harness) Balance(t testing.TB, userID int64) int64 {
+	t.Helper()
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		t.Fatalf("load user %d: %v", userID, err)
+	}
+	return user.BalanceMicroCredits()
+}
+
+// Eventually waits up to a second for cond, which usually depends on a
+// sandbox webhook, and fails the test with msg if it never holds
+func Eventually(t testing.TB, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting: %s", msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,128 +1,323 @@
 package wallethandlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"socialpredict/clock"
+	"socialpredict/logger"
 	"socialpredict/models"
+	"socialpredict/repository"
+	"socialpredict/services/chains"
 	"socialpredict/services/dfns"
-	"socialpredict/util"
+	"socialpredict/services/health"
+	"socialpredict/services/ledger"
+	"socialpredict/services/metrics"
+	"socialpredict/services/receipts"
+	"socialpredict/services/replay"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/saga"
+	"socialpredict/services/screening"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/services/treasury"
+	"socialpredict/services/withdrawalflow"
+	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// DFNSWebhookHandler handles incoming webhooks from DFNS
-func DFNSWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	// Read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Webhook: Failed to read request body: %v", err)
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
+// DFNSWebhookHandler handles incoming webhooks from DFNS. Each org posts to its
+// own path (/v0/webhook/dfns/{org}) and is verified with that org's secret; the
+// bare path is the primary org. An org without a secret gets 503. Events
+// outside the guard's timestamp tolerance are rejected, and each event ID is
+// processed at most once per org. Large deposits are checked against the chain
+// by verifier before being credited. Deposits and transfers are recorded at
+// c's current time.
+func DFNSWebhookHandler(db *gorm.DB, repos repository.Repos, dfnsOrgs *dfns.Orgs, screener screening.Screener, flows *saga.Coordinator, guard *replay.Guard, verifier *receipts.Service, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org := mux.Vars(r)["org"]
+		if org == "" {
+			org = dfns.PrimaryOrg
+		}
+		// The webhook's own request ID is kept separate from the trace ID,
+		// which follows the withdrawal that started the transfer
+		log := logger.Structured.With("request_id", logger.TraceID(r.Context()), "dfns_org", org)
+
+		webhookSecret, ok := dfnsOrgs.WebhookSecret(org)
+		if !ok {
+			log.Warn("webhook from unknown DFNS org")
+			http.Error(w, "Unknown webhook", http.StatusNotFound)
+			return
+		}
+		// Without a secret nothing could be verified, so nothing is accepted
+		if webhookSecret == "" {
+			log.Error("webhook secret is not configured for DFNS org")
+			metrics.WebhookEvents.WithLabelValues("unknown", metrics.ResultRejected).Inc()
+			http.Error(w, "Webhook is not configured", http.StatusServiceUnavailable)
+			return
+		}
 
-	// Verify webhook signature
-	signature := r.Header.Get("X-DFNS-Signature")
-	webhookSecret := os.Getenv("DFNS_WEBHOOK_SECRET")
+		// Read request body
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error("failed to read webhook body", "error", err)
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
 
-	if webhookSecret != "" && !dfns.VerifyWebhookSignature(body, signature, webhookSecret) {
-		log.Printf("Webhook: Invalid signature")
-		http.Error(w, "Invalid signature", http.StatusUnauthorized)
-		return
-	}
+		// Verify webhook signature
+		signature := r.Header.Get("X-DFNS-Signature")
+		if !dfns.VerifyWebhookSignature(body, signature, webhookSecret) {
+			log.Warn("invalid webhook signature")
+			metrics.WebhookEvents.WithLabelValues("unknown", metrics.ResultRejected).Inc()
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
 
-	// Parse webhook event
-	event, err := dfns.ParseWebhookEvent(body)
-	if err != nil {
-		log.Printf("Webhook: Failed to parse event: %v", err)
-		http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
-		return
-	}
+		// Parse webhook event
+		event, err := dfns.ParseWebhookEvent(body)
+		if err != nil {
+			log.Warn("failed to parse webhook event", "error", err)
+			metrics.WebhookEvents.WithLabelValues("unknown", metrics.ResultRejected).Inc()
+			http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+			return
+		}
 
-	log.Printf("Webhook: Received event type: %s, ID: %s", event.Kind, event.ID)
+		if event.ID == "" {
+			log.Warn("webhook event has no ID")
+			metrics.WebhookEvents.WithLabelValues("unknown", metrics.ResultRejected).Inc()
+			http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+			return
+		}
+		log = log.With("event_id", event.ID, "event_kind", event.Kind)
+
+		// Reject stale or future-dated deliveries; the replay cache only
+		// remembers event IDs for this window
+		if err := dfns.VerifyWebhookTimestamp(event, r.Header.Get(dfns.TimestampHeader), guard.Now(), guard.Tolerance()); err != nil {
+			log.Warn("webhook timestamp rejected", "timestamp", event.Timestamp, "header", r.Header.Get(dfns.TimestampHeader))
+			metrics.WebhookEvents.WithLabelValues(event.Kind, metrics.ResultRejected).Inc()
+			http.Error(w, "Invalid webhook timestamp", http.StatusUnauthorized)
+			return
+		}
 
-	// Handle different event types
-	switch event.Kind {
-	case dfns.EventTransferInbound, dfns.EventTransferConfirmed:
-		handleInboundTransfer(event, body)
-	case dfns.EventTransferCompleted:
-		handleTransferCompleted(event)
-	case dfns.EventTransferFailed:
-		handleTransferFailed(event)
-	default:
-		log.Printf("Webhook: Unhandled event type: %s", event.Kind)
-	}
+		source := "dfns:" + org
+		claimed, err := guard.Claim(source, event.ID)
+		if err != nil {
+			log.Error("failed to record webhook receipt", "error", err)
+			http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
+			return
+		}
+		if !claimed {
+			// Acknowledge so DFNS stops retrying, but do nothing
+			log.Warn("duplicate webhook event ignored")
+			metrics.WebhookEvents.WithLabelValues(event.Kind, metrics.ResultDuplicate).Inc()
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		log.Info("webhook received")
+
+		// Handle different event types
+		var handleErr error
+		result := metrics.ResultProcessed
+		switch event.Kind {
+		case dfns.EventTransferInbound, dfns.EventTransferConfirmed:
+			handleErr = handleInboundTransfer(log, db, c, org, screener, verifier, event, body)
+		case dfns.EventTransferCompleted:
+			handleErr = handleTransferCompleted(r.Context(), log, db, repos, c, flows, verifier, event)
+		case dfns.EventTransferFailed:
+			handleErr = handleTransferFailed(r.Context(), log, db, repos, c, flows, event)
+		default:
+			log.Info("webhook event not handled")
+			result = metrics.ResultIgnored
+		}
+		if handleErr != nil {
+			log.Error("failed to process webhook event", "error", handleErr)
+			result = metrics.ResultFailed
+			// Let a retry of this event through
+			if err := guard.Release(source, event.ID); err != nil {
+				log.Error("failed to release webhook receipt", "error", err)
+			}
+		} else {
+			recordWebhookHeartbeat(log, db, c, event)
+		}
+		metrics.WebhookEvents.WithLabelValues(event.Kind, result).Inc()
 
-	w.WriteHeader(http.StatusOK)
+		// A failure is reported so DFNS delivers the event again
+		if handleErr != nil {
+			http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
 }
 
 // handleInboundTransfer processes an inbound (deposit) transfer
-func handleInboundTransfer(event *dfns.WebhookEvent, rawPayload []byte) {
+func handleInboundTransfer(log *slog.Logger, db *gorm.DB, c clock.Clock, org string, screener screening.Screener, verifier *receipts.Service, event *dfns.WebhookEvent, rawPayload []byte) error {
 	data, err := dfns.ParseTransferEventData(event.Data)
 	if err != nil {
-		log.Printf("Webhook: Failed to parse transfer event data: %v", err)
-		return
+		return fmt.Errorf("failed to parse transfer event data: %w", err)
 	}
 
+	confirmed := event.Kind == dfns.EventTransferConfirmed || strings.EqualFold(data.Status, dfns.TransferStatusConfirmed)
+	return processInboundTransfer(transferLogger(log, data), db, c, org, screener, verifier, data, confirmed, rawPayload)
+}
+
+// processInboundTransfer records a deposit. Confirmed deposits are credited
+// immediately; unconfirmed ones are recorded PENDING until confirmation, with a
+// provisional betting allowance for users who opted in. Events that need no
+// action return nil; an error means the deposit could not be recorded.
+func processInboundTransfer(log *slog.Logger, db *gorm.DB, c clock.Clock, org string, screener screening.Screener, verifier *receipts.Service, data *dfns.TransferEventData, confirmed bool, rawPayload []byte) error {
 	// Only process inbound transfers
 	if data.Direction != "Inbound" {
-		log.Printf("Webhook: Skipping non-inbound transfer: %s", data.Direction)
-		return
+		log.Info("skipping non-inbound transfer", "direction", data.Direction)
+		return nil
 	}
 
-	db := util.GetDB()
-
 	// Find the wallet that received the deposit
 	var wallet models.Wallet
 	if err := db.Where("dfns_wallet_id = ?", data.WalletID).First(&wallet).Error; err != nil {
-		log.Printf("Webhook: Wallet not found for DFNS wallet ID: %s", data.WalletID)
-		return
+		log.Warn("wallet not found for deposit")
+		return nil
 	}
 
-	// Check if we've already processed this transaction (idempotency)
-	var existingTx models.CryptoTransaction
-	if db.Where("tx_hash = ?", data.TxHash).First(&existingTx).Error == nil {
-		log.Printf("Webhook: Transaction already processed: %s", data.TxHash)
-		return
+	// An org may only credit deposits to wallets it holds
+	if wallet.DfnsOrg != org {
+		log.Warn("deposit wallet belongs to another DFNS org, ignoring", "wallet_org", wallet.DfnsOrg)
+		return nil
 	}
 
-	// Determine token symbol from contract address
-	tokenSymbol := getTokenSymbolFromContract(data.Contract, wallet.ChainID, db)
-	if tokenSymbol == "" {
-		log.Printf("Webhook: Unknown token contract: %s on chain %d", data.Contract, wallet.ChainID)
-		return
+	// Check if we've already processed this transfer (idempotency). A
+	// pending deposit is completed by its confirmation event.
+	transfer := models.CryptoTransaction{ChainID: wallet.ChainID, TxHash: data.TxHash, Type: models.TxTypeDeposit, ToAddress: wallet.Address, LogIndex: data.LogIndex}
+	var existingTx models.CryptoTransaction
+	if db.Scopes(models.SameTransfer(&transfer)).First(&existingTx).Error == nil {
+		if confirmed && existingTx.Type == models.TxTypeDeposit && existingTx.Status == models.TxStatusPending {
+			if err := confirmPendingDeposit(log, db, c, verifier, &existingTx); err != nil {
+				return fmt.Errorf("failed to confirm pending deposit %d: %w", existingTx.ID, err)
+			}
+			return nil
+		}
+		log.Info("deposit already processed")
+		return nil
 	}
 
-	// Convert amount to credits (1:1 for stablecoins)
-	decimals := dfns.GetTokenDecimals(tokenSymbol)
-	amountCredits := dfns.ConvertToCredits(data.Amount, decimals)
+	// Determine the token from the contract address
+	token := getTokenFromContract(data.Contract, wallet.ChainID, db)
+	if token == nil {
+		log.Warn("unknown token contract", "contract", data.Contract, "chain_id", wallet.ChainID)
+		return nil
+	}
+	tokenSymbol := token.Token.Symbol
 
-	if amountCredits <= 0 {
-		log.Printf("Webhook: Zero or negative amount after conversion: %s -> %d", data.Amount, amountCredits)
-		return
+	// Convert amount to micro-credits (1:1 for 6-decimal stablecoins, no truncation)
+	amountMicro, err := dfns.ConvertToMicroCredits(data.Amount, token.Decimals)
+	if err != nil {
+		log.Warn("deposit amount cannot be credited", "amount", data.Amount, "error", err)
+		return nil
 	}
 
-	// Create transaction record and credit user atomically
-	now := time.Now()
+	now := c.Now()
 	tx := models.CryptoTransaction{
 		UserID:        wallet.UserID,
 		WalletID:      &wallet.ID,
 		Type:          models.TxTypeDeposit,
-		Status:        models.TxStatusCompleted,
 		ChainID:       wallet.ChainID,
 		ChainName:     wallet.ChainName,
 		TokenSymbol:   tokenSymbol,
 		TokenAddress:  data.Contract,
 		Amount:        data.Amount,
-		AmountCredits: amountCredits,
+		AmountCredits: amountMicro,
 		TxHash:        data.TxHash,
 		FromAddress:   data.From,
-		ToAddress:     data.To,
+		ToAddress:     transfer.ToAddress, // The wallet's own address, so webhook and chain scan records match
+		LogIndex:      transfer.LogIndex,
 		DfnsTxID:      data.ID,
 		WebhookData:   string(rawPayload),
-		ProcessedAt:   &now,
+	}
+
+	// Spam transfers below the token's minimum are recorded once, so repeats
+	// are ignored, but never screened or credited
+	if amountMicro < token.MinDeposit {
+		tx.Status, tx.ProcessedAt = models.TxStatusDust, &now
+		if err := db.Create(&tx).Error; err != nil {
+			if depositRecorded(db, &tx) {
+				log.Info("deposit already processed")
+				return nil
+			}
+			return fmt.Errorf("failed to record dust deposit: %w", err)
+		}
+		metrics.DustDeposits.WithLabelValues(tx.ChainName, tx.TokenSymbol).Inc()
+		log.Info("dust deposit recorded, not credited", "user_id", wallet.UserID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(amountMicro))
+		return nil
+	}
+
+	// Screen the source address; flagged deposits are recorded ON_HOLD and
+	// only credited once an admin releases them
+	status, holdReason := models.TxStatusCompleted, ""
+	if !confirmed {
+		status = models.TxStatusPending
+	}
+	// A rotated-out address credits during its grace period, then holds
+	if !wallet.IsActive {
+		if wallet.AcceptsDeposits(now) {
+			log.Info("deposit to retired wallet within grace period", "wallet_id", wallet.ID)
+		} else {
+			log.Warn("deposit to retired wallet after grace period, holding", "wallet_id", wallet.ID)
+			status, holdReason = models.TxStatusOnHold, "Deposit to a retired wallet after its grace period"
+		}
+	}
+	if result, screenErr := screener.Screen(wallet.ChainName, data.From); screenErr != nil {
+		log.Warn("screening failed, holding deposit", "from", data.From, "error", screenErr)
+		status, holdReason = models.TxStatusOnHold, "Screening unavailable"
+	} else if result.Flagged {
+		log.Warn("deposit source flagged by screening", "from", data.From, "provider", result.Provider, "reason", result.Reason)
+		status, holdReason = models.TxStatusOnHold, result.Reason
+	}
+	if reason := depositBlocked(log, db, wallet.UserID, now); reason != "" {
+		status, holdReason = models.TxStatusOnHold, reason
+	} else if reason := depositOverLimit(log, db, wallet.UserID, amountMicro, now); reason != "" {
+		status, holdReason = models.TxStatusOnHold, reason
+	}
+
+	// Create transaction record and credit user atomically
+	tx.Status, tx.HoldReason = status, holdReason
+	if status == models.TxStatusCompleted {
+		if reason := verifyDeposit(log, db, verifier, &tx); reason != "" {
+			status, tx.Status, tx.HoldReason = models.TxStatusOnHold, models.TxStatusOnHold, reason
+		}
+	}
+	if status != models.TxStatusPending {
+		tx.ProcessedAt = &now
+	}
+
+	switch status {
+	case models.TxStatusOnHold:
+		if err := db.Create(&tx).Error; err != nil {
+			if depositRecorded(db, &tx) {
+				log.Info("deposit already processed")
+				return nil
+			}
+			return fmt.Errorf("failed to create held transaction record: %w", err)
+		}
+		log.Info("deposit held for review", "user_id", wallet.UserID, "tx_id", tx.ID)
+		return nil
+	case models.TxStatusPending:
+		if err := recordPendingDeposit(log, db, &tx); err != nil {
+			if depositRecorded(db, &tx) {
+				log.Info("deposit already processed")
+				return nil
+			}
+			return fmt.Errorf("failed to record pending deposit: %w", err)
+		}
+		log.Info("pending deposit recorded", "user_id", wallet.UserID, "tx_id", tx.ID)
+		return nil
 	}
 
 	// Use database transaction to atomically credit user
@@ -131,56 +326,146 @@ func handleInboundTransfer(event *dfns.WebhookEvent, rawPayload []byte) {
 	// Create transaction record
 	if err := dbTx.Create(&tx).Error; err != nil {
 		dbTx.Rollback()
-		log.Printf("Webhook: Failed to create transaction record: %v", err)
-		return
+		if depositRecorded(db, &tx) {
+			log.Info("deposit already processed")
+			return nil
+		}
+		return fmt.Errorf("failed to create transaction record: %w", err)
 	}
 
-	// Credit user's account balance
-	var user models.User
-	if err := dbTx.First(&user, wallet.UserID).Error; err != nil {
+	// Credit user's account balance through the ledger, which locks the row
+	// so a concurrent withdrawal cannot overwrite the credit
+	user := &models.User{ID: wallet.UserID}
+	if _, err := ledger.Apply(dbTx, user, depositPosting(&tx)); err != nil {
 		dbTx.Rollback()
-		log.Printf("Webhook: Failed to find user: %v", err)
-		return
+		return fmt.Errorf("failed to credit user balance: %w", err)
 	}
 
-	user.AccountBalance += amountCredits
-	if err := dbTx.Save(&user).Error; err != nil {
-		dbTx.Rollback()
-		log.Printf("Webhook: Failed to credit user balance: %v", err)
-		return
+	if err := dbTx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit deposit: %w", err)
+	}
+	metrics.RecordDepositCredited(tx.ChainName, tx.TokenSymbol, amountMicro)
+	log.Info("deposit credited", "user_id", wallet.UserID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(amountMicro))
+	return nil
+}
+
+// depositPosting is the ledger entry crediting a deposit to its user
+func depositPosting(tx *models.CryptoTransaction) ledger.Posting {
+	return ledger.Posting{
+		Type:          models.LedgerTypeDeposit,
+		Amount:        tx.AmountCredits,
+		ReferenceType: ledger.ReferenceTypeCryptoTransaction,
+		ReferenceID:   tx.ID,
+		Description:   fmt.Sprintf("Deposit of %s %s on %s", models.FormatMicroCredits(tx.AmountCredits), tx.TokenSymbol, tx.ChainName),
+	}
+}
+
+// depositBlocked returns why the user's deposits are held rather than
+// credited, or "" if they are not: an admin blocked them, or the user is
+// cooling off or self-excluded. Deposits are held when the restrictions
+// cannot be checked, as when screening is unavailable.
+func depositBlocked(log *slog.Logger, db *gorm.DB, userID int64, now time.Time) string {
+	err := restrictions.Check(db, userID, models.RestrictionDepositsBlocked, now)
+	if err == nil {
+		err = selfexclusion.Check(db, userID, now)
 	}
+	if err == nil {
+		return ""
+	}
+	var restrictedErr *restrictions.RestrictedError
+	var excludedErr *selfexclusion.ExcludedError
+	switch {
+	case errors.As(err, &restrictedErr):
+		log.Warn("deposits blocked on account, holding deposit", "user_id", userID)
+		return "Deposits blocked: " + restrictedErr.Restriction.Reason
+	case errors.As(err, &excludedErr):
+		log.Warn("user is self-excluded, holding deposit", "user_id", userID, "kind", excludedErr.Exclusion.Kind)
+		return "Deposit during " + strings.ToLower(strings.ReplaceAll(excludedErr.Exclusion.Kind, "_", "-")) +
+			" until " + excludedErr.Exclusion.EndsAt.UTC().Format(time.RFC3339)
+	}
+	log.Warn("account restrictions unavailable, holding deposit", "user_id", userID, "error", err)
+	return "Account restrictions unavailable"
+}
 
-	dbTx.Commit()
-	log.Printf("Webhook: Deposit credited - User %s, Amount %d credits, TxHash %s",
-		user.Username, amountCredits, data.TxHash)
+// depositOverLimit returns why a deposit of amountMicro is held for going
+// past the daily deposit limit the user set themselves, or "" if it is
+// within it. It is checked once, when the deposit is first seen, so a
+// pending deposit counts towards the limit as it confirms.
+func depositOverLimit(log *slog.Logger, db *gorm.DB, userID, amountMicro int64, now time.Time) string {
+	over, limit, err := selfexclusion.DepositOverLimit(db, userID, amountMicro, now)
+	if err != nil {
+		log.Warn("deposit limit unavailable, holding deposit", "user_id", userID, "error", err)
+		return "Deposit limit unavailable"
+	}
+	if !over {
+		return ""
+	}
+	log.Warn("deposit over the user's daily limit, holding", "user_id", userID, "limit", models.FormatMicroCredits(limit))
+	return "Over the daily deposit limit of " + models.FormatMicroCredits(limit) + " credits"
+}
+
+// depositRecorded reports whether another transaction with tx's DFNS transfer
+// ID or on-chain transfer exists. It explains a failed insert: a concurrent
+// webhook or chain scan recorded the deposit first and the unique indexes
+// rejected this copy.
+func depositRecorded(db *gorm.DB, tx *models.CryptoTransaction) bool {
+	query := db.Model(&models.CryptoTransaction{}).Scopes(models.SameTransfer(tx))
+	if tx.DfnsTxID != "" {
+		query = query.Or("dfns_tx_id = ?", tx.DfnsTxID)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
 }
 
 // handleTransferCompleted processes a completed outbound transfer
-func handleTransferCompleted(event *dfns.WebhookEvent) {
+func handleTransferCompleted(ctx context.Context, log *slog.Logger, db *gorm.DB, repos repository.Repos, c clock.Clock, flows *saga.Coordinator, verifier *receipts.Service, event *dfns.WebhookEvent) error {
 	data, err := dfns.ParseTransferEventData(event.Data)
 	if err != nil {
-		log.Printf("Webhook: Failed to parse transfer completed event: %v", err)
-		return
+		return fmt.Errorf("failed to parse transfer completed event: %w", err)
 	}
+	log = transferLogger(log, data)
 
-	db := util.GetDB()
+	// Treasury sweeps to cold storage are not user transactions
+	if found, err := treasury.CompleteTransfer(db, data.ID, data.TxHash, c.Now()); found {
+		if err != nil {
+			return fmt.Errorf("failed to complete treasury transfer %s: %w", data.ID, err)
+		}
+		return nil
+	}
 
 	// Find the transaction by DFNS ID
-	var tx models.CryptoTransaction
-	if err := db.Where("dfns_tx_id = ?", data.ID).First(&tx).Error; err != nil {
-		log.Printf("Webhook: Transaction not found for DFNS ID: %s", data.ID)
-		return
+	transferTx, err := repos.Transactions.GetByDfnsID(ctx, data.ID)
+	if err != nil {
+		return transferNotFound(log, db, data.ID)
+	}
+	tx := *transferTx
+
+	// Completing a pending deposit credits the user
+	if tx.Type == models.TxTypeDeposit && tx.Status == models.TxStatusPending {
+		if err := confirmPendingDeposit(log, db, c, verifier, &tx); err != nil {
+			return fmt.Errorf("failed to confirm pending deposit %d: %w", tx.ID, err)
+		}
+		return nil
+	}
+
+	// Withdrawals approved through the saga finish there
+	if withdrawalReq, ok := waitingWithdrawal(db, flows, &tx); ok {
+		if _, err := flows.Advance(withdrawalflow.Name, withdrawalReq.ID, map[string]string{withdrawalflow.DataTxHash: data.TxHash}); err != nil {
+			return fmt.Errorf("failed to advance withdrawal %d: %w", withdrawalReq.ID, err)
+		}
+		log.Info("withdrawal transfer completed", "withdrawal_id", withdrawalReq.ID, "tx_id", tx.ID, "tx_hash", data.TxHash)
+		return nil
 	}
 
 	// Update transaction status
-	now := time.Now()
+	now := c.Now()
 	tx.Status = models.TxStatusCompleted
 	tx.TxHash = data.TxHash
 	tx.ProcessedAt = &now
 
 	if err := db.Save(&tx).Error; err != nil {
-		log.Printf("Webhook: Failed to update transaction: %v", err)
-		return
+		return fmt.Errorf("failed to update transaction: %w", err)
 	}
 
 	// Update associated withdrawal request
@@ -193,93 +478,173 @@ func handleTransferCompleted(event *dfns.WebhookEvent) {
 		}
 	}
 
-	log.Printf("Webhook: Transfer completed - TxID %d, TxHash %s", tx.ID, data.TxHash)
+	log.Info("transfer completed", "tx_id", tx.ID, "tx_hash", data.TxHash)
+	return nil
 }
 
 // handleTransferFailed processes a failed transfer
-func handleTransferFailed(event *dfns.WebhookEvent) {
+func handleTransferFailed(ctx context.Context, log *slog.Logger, db *gorm.DB, repos repository.Repos, c clock.Clock, flows *saga.Coordinator, event *dfns.WebhookEvent) error {
 	data, err := dfns.ParseTransferEventData(event.Data)
 	if err != nil {
-		log.Printf("Webhook: Failed to parse transfer failed event: %v", err)
-		return
+		return fmt.Errorf("failed to parse transfer failed event: %w", err)
 	}
+	log = transferLogger(log, data)
 
-	db := util.GetDB()
+	if found, err := treasury.FailTransfer(db, data.ID, "Transfer failed on blockchain", c.Now()); found {
+		if err != nil {
+			return fmt.Errorf("failed to record failed treasury transfer %s: %w", data.ID, err)
+		}
+		return nil
+	}
 
 	// Find the transaction by DFNS ID
-	var tx models.CryptoTransaction
-	if err := db.Where("dfns_tx_id = ?", data.ID).First(&tx).Error; err != nil {
-		log.Printf("Webhook: Transaction not found for DFNS ID: %s", data.ID)
-		return
+	transferTx, err := repos.Transactions.GetByDfnsID(ctx, data.ID)
+	if err != nil {
+		return transferNotFound(log, db, data.ID)
 	}
+	tx := *transferTx
 
-	// Update transaction status
-	now := time.Now()
-	tx.Status = models.TxStatusFailed
-	tx.ProcessedAt = &now
-	tx.ErrorMessage = "Transfer failed"
+	// A pending deposit that fails never gets credited; reverse any provisional allowance
+	if tx.Type == models.TxTypeDeposit && tx.Status == models.TxStatusPending {
+		if err := failPendingDeposit(log, db, c, &tx); err != nil {
+			return fmt.Errorf("failed to reverse pending deposit %d: %w", tx.ID, err)
+		}
+		return nil
+	}
 
-	if err := db.Save(&tx).Error; err != nil {
-		log.Printf("Webhook: Failed to update transaction: %v", err)
-		return
+	// Withdrawals approved through the saga are refunded by its compensation
+	if withdrawalReq, ok := waitingWithdrawal(db, flows, &tx); ok {
+		if _, err := flows.Fail(withdrawalflow.Name, withdrawalReq.ID, "Transfer failed on blockchain"); err != nil {
+			return fmt.Errorf("failed to compensate withdrawal %d: %w", withdrawalReq.ID, err)
+		}
+		log.Info("withdrawal transfer failed", "withdrawal_id", withdrawalReq.ID, "tx_id", tx.ID)
+		return nil
 	}
 
-	// If this was a withdrawal, refund the user
-	if tx.Type == models.TxTypeWithdrawal {
-		var user models.User
-		if err := db.First(&user, tx.UserID).Error; err == nil {
-			user.AccountBalance += tx.AmountCredits
-			db.Save(&user)
-			log.Printf("Webhook: Refunded %d credits to user %s due to failed withdrawal", tx.AmountCredits, user.Username)
+	// Mark the transaction failed and refund a withdrawal atomically. The
+	// transaction and user rows are locked, and the status re-checked, so a
+	// repeated webhook cannot refund twice or lose a concurrent balance change.
+	now := c.Now()
+	err = db.Transaction(func(dbTx *gorm.DB) error {
+		if err := dbTx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tx, tx.ID).Error; err != nil {
+			return err
+		}
+		if tx.Status == models.TxStatusFailed {
+			return nil
+		}
+		tx.Status = models.TxStatusFailed
+		tx.ProcessedAt = &now
+		tx.ErrorMessage = "Transfer failed"
+		if err := dbTx.Save(&tx).Error; err != nil {
+			return fmt.Errorf("failed to update transaction: %w", err)
+		}
+
+		// If this was a withdrawal, refund the user
+		if tx.Type != models.TxTypeWithdrawal {
+			return nil
+		}
+		_, err := ledger.Apply(dbTx, &models.User{ID: tx.UserID}, ledger.Posting{
+			Type:          models.LedgerTypeWithdrawalRefund,
+			Amount:        tx.AmountCredits,
+			ReferenceType: ledger.ReferenceTypeCryptoTransaction,
+			ReferenceID:   tx.ID,
+			Description:   "Refund of failed withdrawal",
+		})
+		switch {
+		case err == nil:
+			log.Info("refunded failed withdrawal", "user_id", tx.UserID, "tx_id", tx.ID, "credits", models.FormatMicroCredits(tx.AmountCredits))
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return fmt.Errorf("failed to refund user balance: %w", err)
 		}
 
 		// Update withdrawal request
 		var withdrawalReq models.WithdrawalRequest
-		if err := db.Where("transaction_id = ?", tx.ID).First(&withdrawalReq).Error; err == nil {
+		if err := dbTx.Where("transaction_id = ?", tx.ID).First(&withdrawalReq).Error; err == nil {
 			withdrawalReq.Status = models.TxStatusFailed
 			withdrawalReq.ProcessedAt = &now
 			withdrawalReq.ErrorMessage = "Transfer failed on blockchain"
-			db.Save(&withdrawalReq)
+			if err := dbTx.Save(&withdrawalReq).Error; err != nil {
+				return fmt.Errorf("failed to update withdrawal request: %w", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Webhook: Transfer failed - TxID %d, DFNS ID %s", tx.ID, data.ID)
+	log.Info("transfer failed", "tx_id", tx.ID)
+	return nil
 }
 
-// getTokenSymbolFromContract determines the token symbol from the contract address
-func getTokenSymbolFromContract(contract string, chainID int64, db *gorm.DB) string {
-	var chain models.SupportedChain
-	if err := db.Where("chain_id = ?", chainID).First(&chain).Error; err != nil {
-		return ""
+// recordWebhookHeartbeat notes a processed event against its chain for the
+// webhook freshness health check
+func recordWebhookHeartbeat(log *slog.Logger, db *gorm.DB, c clock.Clock, event *dfns.WebhookEvent) {
+	var data struct {
+		Network string `json:"network"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return
+	}
+	chainName := dfns.GetChainNameFromNetwork(data.Network)
+	if chainName == "" {
+		return
 	}
+	if err := health.RecordWebhook(db, chainName, event.Kind, event.ID, c.Now()); err != nil {
+		log.Warn("failed to record webhook heartbeat", "chain", chainName, "error", err)
+	}
+}
+
+// transferLogger tags log with the DFNS transfer and, for transfers we
+// initiated, the trace ID we sent DFNS as the external ID
+func transferLogger(log *slog.Logger, data *dfns.TransferEventData) *slog.Logger {
+	log = log.With("dfns_transfer_id", data.ID)
+	if data.ExternalID != "" {
+		log = log.With("trace_id", data.ExternalID)
+	}
+	return log
+}
 
-	// Compare contract addresses (case-insensitive)
-	if equalAddresses(contract, chain.USDCAddress) {
-		return "USDC"
+// getTokenFromContract finds the token at a contract address on the chain, or
+// returns nil for a contract not on it
+func getTokenFromContract(contract string, chainID int64, db *gorm.DB) *models.ChainToken {
+	var chain models.SupportedChain
+	if err := db.Where("chain_id = ?", chainID).First(&chain).Error; err != nil {
+		return nil
 	}
-	if equalAddresses(contract, chain.USDTAddress) {
-		return "USDT"
+	token, err := chains.TokenByContract(db, &chain, contract)
+	if err != nil {
+		return nil
 	}
+	return token
+}
 
-	return ""
+// transferNotFound handles a transfer event with no transaction. A withdrawal
+// whose transfer started but is not yet recorded fails the event, so DFNS
+// redelivers it after the reconciler has recorded the transfer.
+func transferNotFound(log *slog.Logger, db *gorm.DB, transferID string) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := db.Where("transfer_id = ? AND status = ?", transferID, models.TxStatusUnrecorded).First(&withdrawalReq).Error; err == nil {
+		return fmt.Errorf("withdrawal %d transfer %s is not recorded yet", withdrawalReq.ID, transferID)
+	}
+	log.Warn("transaction not found for DFNS transfer")
+	return nil
 }
 
-// equalAddresses compares two EVM addresses case-insensitively
-func equalAddresses(a, b string) bool {
-	if len(a) != len(b) {
-		return false
+// waitingWithdrawal returns the withdrawal request for tx when its saga is
+// waiting on the transfer. Withdrawals approved before sagas existed have no
+// instance and are handled inline.
+func waitingWithdrawal(db *gorm.DB, flows *saga.Coordinator, tx *models.CryptoTransaction) (*models.WithdrawalRequest, bool) {
+	if flows == nil || tx.Type != models.TxTypeWithdrawal {
+		return nil, false
 	}
-	for i := 0; i < len(a); i++ {
-		ca, cb := a[i], b[i]
-		if ca >= 'A' && ca <= 'Z' {
-			ca += 32
-		}
-		if cb >= 'A' && cb <= 'Z' {
-			cb += 32
-		}
-		if ca != cb {
-			return false
-		}
+	var withdrawalReq models.WithdrawalRequest
+	if err := db.Where("transaction_id = ?", tx.ID).First(&withdrawalReq).Error; err != nil {
+		return nil, false
+	}
+	inst, err := flows.Find(withdrawalflow.Name, withdrawalReq.ID)
+	if err != nil || inst.Status != models.SagaStatusWaiting {
+		return nil, false
 	}
-	return true
+	return &withdrawalReq, true
 }
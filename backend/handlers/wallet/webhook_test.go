@@ -0,0 +1,37 @@
+package wallethandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/repository"
+	"socialpredict/services/dfns"
+	"socialpredict/services/screening"
+)
+
+func TestWebhookRejectedWithoutSecret(t *testing.T) {
+	unsigned := `{"id":"evt-1","kind":"wallet.blockchainevent.detected","data":{}}`
+	for _, tc := range []struct {
+		name   string
+		secret string
+		want   int
+	}{
+		{"secret not configured", "", http.StatusServiceUnavailable},
+		{"unsigned delivery", "webhook-secret", http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			orgs := dfns.NewOrgs([]dfns.Config{{Name: dfns.PrimaryOrg, WebhookSecret: tc.secret}})
+			handler := DFNSWebhookHandler(nil, repository.Repos{}, orgs, screening.NewStaticList(nil), nil, nil, nil, clock.New())
+
+			req := httptest.NewRequest("POST", "/v0/webhook/dfns", strings.NewReader(unsigned))
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
@@ -1,30 +1,51 @@
 package wallethandlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"socialpredict/clock"
+	"socialpredict/logger"
 	"socialpredict/middleware"
 	"socialpredict/models"
+	"socialpredict/repository"
+	"socialpredict/security"
+	"socialpredict/services/chains"
+	"socialpredict/services/devices"
 	"socialpredict/services/dfns"
-	"socialpredict/util"
+	"socialpredict/services/geoip"
+	"socialpredict/services/ledger"
+	"socialpredict/services/limits"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/risk"
+	"socialpredict/services/screening"
+	"socialpredict/services/settings"
+	"socialpredict/services/travelrule"
+	"socialpredict/services/twofactor"
+	"socialpredict/services/withdrawalconfirm"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// WithdrawalLimits defines the withdrawal limits
-const (
-	MinWithdrawalAmount   = 10    // Minimum credits per withdrawal
-	MaxWithdrawalAmount   = 10000 // Maximum credits per single withdrawal
-	DailyWithdrawalLimit  = 50000 // Maximum credits per day
-)
-
 // WithdrawalRequestBody represents the request body for initiating a withdrawal
 type WithdrawalRequestBody struct {
-	ChainName   string `json:"chainName"`
-	TokenSymbol string `json:"tokenSymbol"`
-	Amount      int64  `json:"amount"`    // Amount in credits
-	ToAddress   string `json:"toAddress"` // External wallet address
+	ChainName   string      `json:"chainName"`
+	TokenSymbol string      `json:"tokenSymbol"`
+	Amount      json.Number `json:"amount"`    // Amount in credits, up to 6 decimal places
+	ToAddress   string      `json:"toAddress"` // External wallet address
+
+	// Second factor, needed at or above the 2FA withdrawal threshold: a TOTP
+	// code, or a code emailed via POST /v0/2fa/email-token
+	TwoFactorCode string `json:"twoFactorCode,omitempty"`
+	EmailToken    string `json:"emailToken,omitempty"`
+
+	// Who receives the funds, needed at or above the travel-rule threshold
+	Beneficiary *travelrule.Beneficiary `json:"beneficiary,omitempty"`
 }
 
 // WithdrawalResponse represents the response for a withdrawal request
@@ -33,16 +54,48 @@ type WithdrawalResponse struct {
 	Status      string    `json:"status"`
 	ChainName   string    `json:"chainName"`
 	TokenSymbol string    `json:"tokenSymbol"`
-	Amount      int64     `json:"amount"`
+	Amount      float64   `json:"amount"`      // Credits, rounded for display
+	AmountMicro int64     `json:"amountMicro"` // Exact amount in micro-credits
 	ToAddress   string    `json:"toAddress"`
 	CreatedAt   time.Time `json:"createdAt"`
 	Message     string    `json:"message,omitempty"`
 }
 
-// InitiateWithdrawalHandler processes a withdrawal request
-func InitiateWithdrawalHandler(dfnsClient *dfns.Client) http.HandlerFunc {
+// WithdrawalOptions adjusts how InitiateWithdrawalCore records a request
+type WithdrawalOptions struct {
+	AwaitConfirmation bool       // Wait for the user to confirm by email before admin review
+	DeviceID          *uint      // Device the request came from, where known
+	HoldUntil         *time.Time // Hold the request as from a new device until then
+	Origin            WithdrawalOrigin
+	Beneficiary       *travelrule.Beneficiary // Beneficiary attestation sent with the request, if any
+	TravelRule        *travelrule.Policy      // Requires a Beneficiary at or above its threshold; nil requires none
+}
+
+// WithdrawalOrigin is where a withdrawal was requested from
+type WithdrawalOrigin struct {
+	IP        string
+	UserAgent string
+	Location  geoip.Location
+}
+
+// WithdrawalInputError reports an invalid withdrawal request
+type WithdrawalInputError struct {
+	Message string
+}
+
+func (e *WithdrawalInputError) Error() string {
+	return e.Message
+}
+
+// InitiateWithdrawalHandler processes a withdrawal request. Withdrawals at or
+// above the 2FA threshold need a second factor; a nil secondFactor requires none.
+// Users who turned on email confirmation are sent a link by confirmations.
+// Requests from a recently seen device are held by deviceGuard; a nil
+// deviceGuard holds none. The requesting IP is located by locator, if set.
+// Withdrawals at or above travelRule's threshold need a beneficiary
+// attestation; a nil travelRule requires none. Time-based rules read c.
+func InitiateWithdrawalHandler(db *gorm.DB, repos repository.Repos, dfnsOrgs *dfns.Orgs, screener screening.Screener, secondFactor *twofactor.Service, confirmations *withdrawalconfirm.Service, deviceGuard *devices.Service, locator geoip.Locator, travelRule *travelrule.Policy, c clock.Clock) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		db := util.GetDB()
 		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
 		if httperr != nil {
 			http.Error(w, httperr.Error(), httperr.StatusCode)
@@ -55,175 +108,553 @@ func InitiateWithdrawalHandler(dfnsClient *dfns.Client) http.HandlerFunc {
 			return
 		}
 
-		// Validate chain name
-		if !dfns.IsValidChainName(req.ChainName) {
-			http.Error(w, "Invalid chain name", http.StatusBadRequest)
+		amountMicro, err := models.ParseCredits(req.Amount.String())
+		if err != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
 			return
 		}
-
-		// Validate token symbol
-		if !dfns.IsValidTokenSymbol(req.TokenSymbol) {
-			http.Error(w, "Invalid token symbol. Supported: USDC, USDT", http.StatusBadRequest)
+		// Anything left out comes from the user's wallet settings
+		if err := repos.Wallets.WithdrawalDefaults(r.Context(), user.ID, &req.ChainName, &req.TokenSymbol, &req.ToAddress); err != nil {
+			logger.FromContext(r.Context()).Error("failed to load wallet settings", "error", err)
+			http.Error(w, "Failed to process withdrawal", http.StatusInternalServerError)
 			return
 		}
 
-		// Validate destination address format based on chain type
-		if !dfns.IsValidAddress(req.ToAddress, req.ChainName) {
-			http.Error(w, "Invalid destination address for this chain", http.StatusBadRequest)
+		// Validate before checking the second factor, so a code is not used up
+		// on a withdrawal that would be refused anyway
+		var withdrawalReq *models.WithdrawalRequest
+		_, err = ValidateWithdrawal(db, c, user, req.ChainName, req.TokenSymbol, req.ToAddress, amountMicro)
+		if err == nil {
+			if _, err = travelRule.Check(amountMicro, req.Beneficiary); err != nil {
+				err = &WithdrawalInputError{Message: err.Error()}
+			}
+		}
+		if err == nil && secondFactor.RequiredForWithdrawal(amountMicro) {
+			if httperr := middleware.RequireSecondFactor(secondFactor, user, models.EmailTokenPurposeWithdrawal, req.TwoFactorCode, req.EmailToken); httperr != nil {
+				http.Error(w, httperr.Error(), httperr.StatusCode)
+				return
+			}
+		}
+		if err == nil {
+			opts := WithdrawalOptions{
+				AwaitConfirmation: user.ConfirmWithdrawalsByEmail && confirmations != nil,
+				Origin:            requestOrigin(r, locator),
+				Beneficiary:       req.Beneficiary,
+				TravelRule:        travelRule,
+			}
+			if opts.DeviceID, opts.HoldUntil, err = deviceHold(deviceGuard, r); err != nil {
+				logger.FromContext(r.Context()).Error("failed to check withdrawal device", "error", err)
+				http.Error(w, "Failed to process withdrawal", http.StatusInternalServerError)
+				return
+			}
+			withdrawalReq, err = InitiateWithdrawalCore(r.Context(), db, c, screener, user, req.ChainName, req.TokenSymbol, req.ToAddress, amountMicro, opts)
+		}
+		if err != nil {
+			var inputErr *WithdrawalInputError
+			var limitErr *limits.LimitError
+			var restrictedErr *restrictions.RestrictedError
+			if errors.As(err, &limitErr) {
+				writeWithdrawalLimitError(w, limitErr)
+				return
+			}
+			if errors.As(err, &restrictedErr) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if errors.Is(err, settings.ErrWithdrawalsFrozen) {
+				http.Error(w, "Withdrawals are temporarily unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if errors.As(err, &inputErr) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Validate minimum withdrawal
-		if req.Amount < MinWithdrawalAmount {
-			http.Error(w, "Minimum withdrawal is 10 credits", http.StatusBadRequest)
-			return
+		message := "Withdrawal request submitted. It will be processed after admin approval."
+		if withdrawalReq.IsOnHold() {
+			message = "Withdrawal request submitted and is under compliance review."
+		}
+		if withdrawalReq.HoldReason == models.HoldReasonNewDevice && withdrawalReq.HoldUntil != nil {
+			message = fmt.Sprintf("Withdrawal request submitted from a new device. It is held until %s before admin review.",
+				withdrawalReq.HoldUntil.UTC().Format(time.RFC3339))
+		}
+		if withdrawalReq.Status == models.TxStatusAwaitingConfirmation {
+			message = "Withdrawal request submitted. Open the link we emailed you to confirm it."
+			if err := confirmations.SendLink(user, withdrawalReq); err != nil {
+				logger.FromContext(r.Context()).Error("failed to send withdrawal confirmation email", "withdrawal_id", withdrawalReq.ID, "error", err)
+				message = "Withdrawal request submitted, but the confirmation email could not be sent. It will expire and be refunded."
+			}
 		}
 
-		// Validate maximum single withdrawal
-		if req.Amount > MaxWithdrawalAmount {
-			http.Error(w, "Maximum single withdrawal is 10,000 credits", http.StatusBadRequest)
-			return
+		response := WithdrawalResponse{
+			RequestID:   withdrawalReq.ID,
+			Status:      withdrawalReq.Status,
+			ChainName:   withdrawalReq.ChainName,
+			TokenSymbol: withdrawalReq.TokenSymbol,
+			Amount:      models.DisplayCredits(withdrawalReq.Amount),
+			AmountMicro: withdrawalReq.Amount,
+			ToAddress:   withdrawalReq.ToAddress,
+			CreatedAt:   withdrawalReq.CreatedAt,
+			Message:     message,
 		}
 
-		// Check user has sufficient balance
-		if user.AccountBalance < req.Amount {
-			http.Error(w, "Insufficient balance", http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ValidateWithdrawalResponse reports whether a withdrawal would be accepted and
+// the minimum and maximum that apply to its chain and token, in credits
+type ValidateWithdrawalResponse struct {
+	Valid         bool                     `json:"valid"`
+	Error         string                   `json:"error,omitempty"`
+	MinWithdrawal float64                  `json:"minWithdrawal"`
+	MaxWithdrawal float64                  `json:"maxWithdrawal"`
+	LimitError    *WithdrawalLimitResponse `json:"limitError,omitempty"`
+
+	SecondFactorRequired bool `json:"secondFactorRequired"` // Submitting needs twoFactorCode or emailToken
+	BeneficiaryRequired  bool `json:"beneficiaryRequired"`  // Submitting needs beneficiary
+}
+
+// ValidateWithdrawalHandler runs the withdrawal checks for a request body
+// without submitting it, so the withdrawal form can show problems up front.
+// A beneficiary is only checked when one is sent.
+func ValidateWithdrawalHandler(db *gorm.DB, repos repository.Repos, secondFactor *twofactor.Service, travelRule *travelrule.Policy, c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
 			return
 		}
 
-		// Check daily withdrawal limit
-		if err := checkDailyWithdrawalLimit(db, user.ID, req.Amount); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var req WithdrawalRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-
-		// Get chain info
-		chainInfo, ok := models.ChainInfo[req.ChainName]
-		if !ok {
-			http.Error(w, "Chain configuration not found", http.StatusInternalServerError)
+		amountMicro, err := models.ParseCredits(req.Amount.String())
+		if err != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
 			return
 		}
-
-		// Use transaction to debit balance and create request atomically
-		tx := db.Begin()
-
-		// Debit user balance immediately
-		user.AccountBalance -= req.Amount
-		if err := tx.Save(user).Error; err != nil {
-			tx.Rollback()
+		// Anything left out comes from the user's wallet settings
+		if err := repos.Wallets.WithdrawalDefaults(r.Context(), user.ID, &req.ChainName, &req.TokenSymbol, &req.ToAddress); err != nil {
+			logger.FromContext(r.Context()).Error("failed to load wallet settings", "error", err)
 			http.Error(w, "Failed to process withdrawal", http.StatusInternalServerError)
 			return
 		}
 
-		// Create withdrawal request in PENDING state (awaiting admin approval)
-		withdrawalReq := models.WithdrawalRequest{
-			UserID:      user.ID,
-			ChainID:     chainInfo.ChainID,
-			ChainName:   req.ChainName,
-			TokenSymbol: req.TokenSymbol,
-			Amount:      req.Amount,
-			ToAddress:   req.ToAddress,
-			Status:      models.TxStatusPending,
+		withdrawalLimits, err := ValidateWithdrawal(db, c, user, req.ChainName, req.TokenSymbol, req.ToAddress, amountMicro)
+		if err == nil && req.Beneficiary != nil {
+			if _, checkErr := travelRule.Check(amountMicro, req.Beneficiary); checkErr != nil {
+				err = &WithdrawalInputError{Message: checkErr.Error()}
+			}
 		}
+		resp := ValidateWithdrawalResponse{
+			Valid:         err == nil,
+			MinWithdrawal: models.DisplayCredits(withdrawalLimits.Min),
+			MaxWithdrawal: models.DisplayCredits(withdrawalLimits.Max),
 
-		if err := tx.Create(&withdrawalReq).Error; err != nil {
-			tx.Rollback()
-			http.Error(w, "Failed to create withdrawal request", http.StatusInternalServerError)
-			return
+			SecondFactorRequired: secondFactor.RequiredForWithdrawal(amountMicro),
+			BeneficiaryRequired:  travelRule.Required(amountMicro),
 		}
-
-		tx.Commit()
-
-		response := WithdrawalResponse{
-			RequestID:   withdrawalReq.ID,
-			Status:      withdrawalReq.Status,
-			ChainName:   req.ChainName,
-			TokenSymbol: req.TokenSymbol,
-			Amount:      req.Amount,
-			ToAddress:   req.ToAddress,
-			CreatedAt:   withdrawalReq.CreatedAt,
-			Message:     "Withdrawal request submitted. It will be processed after admin approval.",
+		if err != nil {
+			var inputErr *WithdrawalInputError
+			var limitErr *limits.LimitError
+			var restrictedErr *restrictions.RestrictedError
+			switch {
+			case errors.As(err, &limitErr):
+				resp.Error = err.Error()
+				resp.LimitError = newWithdrawalLimitResponse(limitErr)
+			case errors.As(err, &inputErr), errors.As(err, &restrictedErr):
+				resp.Error = err.Error()
+			case errors.Is(err, settings.ErrWithdrawalsFrozen):
+				resp.Error = "Withdrawals are temporarily unavailable"
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(resp)
 	}
 }
 
-// GetUserWithdrawalsHandler returns the user's withdrawal requests
-func GetUserWithdrawalsHandler(w http.ResponseWriter, r *http.Request) {
-	db := util.GetDB()
-	user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
-	if httperr != nil {
-		http.Error(w, httperr.Error(), httperr.StatusCode)
-		return
-	}
-
-	var requests []models.WithdrawalRequest
-	db.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(50).Find(&requests)
-
-	type WithdrawalListItem struct {
-		ID          uint       `json:"id"`
-		ChainName   string     `json:"chainName"`
-		TokenSymbol string     `json:"tokenSymbol"`
-		Amount      int64      `json:"amount"`
-		ToAddress   string     `json:"toAddress"`
-		Status      string     `json:"status"`
-		CreatedAt   time.Time  `json:"createdAt"`
-		ProcessedAt *time.Time `json:"processedAt,omitempty"`
-	}
-
-	items := make([]WithdrawalListItem, len(requests))
-	for i, req := range requests {
-		items[i] = WithdrawalListItem{
-			ID:          req.ID,
-			ChainName:   req.ChainName,
-			TokenSymbol: req.TokenSymbol,
-			Amount:      req.Amount,
-			ToAddress:   req.ToAddress,
-			Status:      req.Status,
-			CreatedAt:   req.CreatedAt,
-			ProcessedAt: req.ProcessedAt,
+// ValidateWithdrawal checks a withdrawal against the chain and token, the
+// per-token minimum and maximum, the user's balance and the rolling limits,
+// without changing anything, as of c's current time. It returns the limits
+// that applied.
+// Validation failures are returned as *WithdrawalInputError or *limits.LimitError,
+// settings.ErrWithdrawalsFrozen while withdrawals are frozen, and
+// *restrictions.RestrictedError while the user's withdrawals are.
+func ValidateWithdrawal(db *gorm.DB, c clock.Clock, user *models.User, chainName, tokenSymbol, toAddress string, amountMicro int64) (settings.WithdrawalLimits, error) {
+	// Nothing gets through during an emergency freeze
+	if err := settings.CheckWithdrawalsOpen(db); err != nil {
+		return settings.WithdrawalLimits{}, err
+	}
+	if err := restrictions.Check(db, user.ID, models.RestrictionWithdrawalsFrozen, c.Now()); err != nil {
+		return settings.WithdrawalLimits{}, err
+	}
+
+	// Validate chain name
+	active, err := chains.Shared.IsActive(db, chainName)
+	if err != nil {
+		return settings.WithdrawalLimits{}, errors.New("Failed to load supported chains")
+	}
+	if !active {
+		return settings.WithdrawalLimits{}, &WithdrawalInputError{Message: "Invalid chain name"}
+	}
+
+	// Validate token symbol
+	if _, err := chains.Shared.Token(db, chainName, tokenSymbol); errors.Is(err, chains.ErrTokenNotFound) {
+		return settings.WithdrawalLimits{}, &WithdrawalInputError{Message: fmt.Sprintf("Token %s is not supported on %s", tokenSymbol, chainName)}
+	} else if err != nil {
+		return settings.WithdrawalLimits{}, errors.New("Failed to load supported tokens")
+	}
+
+	// Validate destination address format based on chain type
+	if !dfns.IsValidAddress(toAddress, chainName) {
+		return settings.WithdrawalLimits{}, &WithdrawalInputError{Message: "Invalid destination address for this chain"}
+	}
+
+	// Limits are admin-editable platform settings, with per-token overrides
+	withdrawalLimits, err := settings.Shared.TokenWithdrawalLimits(db, chainName, tokenSymbol)
+	if errors.Is(err, settings.ErrWithdrawalsDisabled) {
+		return withdrawalLimits, &WithdrawalInputError{Message: fmt.Sprintf("%s withdrawals on %s are currently disabled", tokenSymbol, chainName)}
+	}
+	if err != nil {
+		return withdrawalLimits, errors.New("Failed to load withdrawal limits")
+	}
+
+	// Validate minimum withdrawal
+	if amountMicro < withdrawalLimits.Min {
+		return withdrawalLimits, &WithdrawalInputError{Message: fmt.Sprintf("Minimum %s withdrawal on %s is %s credits", tokenSymbol, chainName, models.FormatMicroCredits(withdrawalLimits.Min))}
+	}
+
+	// Validate maximum single withdrawal
+	if amountMicro > withdrawalLimits.Max {
+		return withdrawalLimits, &WithdrawalInputError{Message: fmt.Sprintf("Maximum single %s withdrawal on %s is %s credits", tokenSymbol, chainName, models.FormatMicroCredits(withdrawalLimits.Max))}
+	}
+
+	// Check user has sufficient balance
+	if user.BalanceMicroCredits() < amountMicro {
+		return withdrawalLimits, &WithdrawalInputError{Message: "Insufficient balance"}
+	}
+
+	// Bonus credit, unreleased winnings and unconfirmed deposits stay on the platform
+	limitsService := limits.NewService(db, c)
+	if err := limitsService.CheckWithdrawable(user, amountMicro); err != nil {
+		return withdrawalLimits, &WithdrawalInputError{Message: err.Error()}
+	}
+
+	// Check daily, 7-day and 30-day withdrawal limits
+	if err := limitsService.Check(user.ID, amountMicro, withdrawalLimits); err != nil {
+		return withdrawalLimits, err
+	}
+	return withdrawalLimits, nil
+}
+
+// InitiateWithdrawalCore validates a withdrawal, debits the user's balance and
+// records the request for admin review, or with opts.AwaitConfirmation set, for
+// the user to confirm by email first. With opts.HoldUntil set the request is
+// held as coming from a new device until then. opts.Beneficiary is checked
+// against opts.TravelRule and recorded as the user's attestation. It assumes
+// the user is authenticated.
+// Validation failures are returned as *WithdrawalInputError or *limits.LimitError,
+// settings.ErrWithdrawalsFrozen while withdrawals are frozen, and
+// *restrictions.RestrictedError while the user's withdrawals are. The request
+// keeps ctx's trace ID (or a new one) so its DFNS transfer and webhooks can be
+// traced back to it.
+func InitiateWithdrawalCore(ctx context.Context, db *gorm.DB, c clock.Clock, screener screening.Screener, user *models.User, chainName, tokenSymbol, toAddress string, amountMicro int64, opts WithdrawalOptions) (*models.WithdrawalRequest, error) {
+	withdrawalLimits, err := ValidateWithdrawal(db, c, user, chainName, tokenSymbol, toAddress, amountMicro)
+	if err != nil {
+		return nil, err
+	}
+	beneficiary, err := opts.TravelRule.Check(amountMicro, opts.Beneficiary)
+	if err != nil {
+		return nil, &WithdrawalInputError{Message: err.Error()}
+	}
+
+	// Get chain info
+	chainInfo, ok := models.ChainInfo[chainName]
+	if !ok {
+		return nil, errors.New("Chain configuration not found")
+	}
+
+	traceID := logger.TraceID(ctx)
+	if traceID == "" {
+		traceID = logger.NewTraceID()
+	}
+	log := logger.WithTrace(traceID).With("user_id", user.ID)
+
+	// Screen the destination before funds leave the balance; flagged
+	// requests are held for manual review
+	status, holdReason := models.TxStatusPending, ""
+	if result, screenErr := screener.Screen(chainName, toAddress); screenErr != nil {
+		log.Warn("screening failed, holding withdrawal", "to_address", toAddress, "error", screenErr)
+		status, holdReason = models.TxStatusOnHold, "Screening unavailable"
+	} else if result.Flagged {
+		log.Warn("withdrawal destination flagged by screening", "to_address", toAddress, "provider", result.Provider, "reason", result.Reason)
+		status, holdReason = models.TxStatusOnHold, result.Reason
+	} else if opts.HoldUntil != nil {
+		status, holdReason = models.TxStatusOnHold, models.HoldReasonNewDevice
+	}
+	var holdUntil *time.Time
+	if holdReason == models.HoldReasonNewDevice {
+		holdUntil = opts.HoldUntil
+	}
+
+	// The screening result is kept in HoldReason, so confirming moves the
+	// request to ON_HOLD rather than PENDING if it was flagged
+	if opts.AwaitConfirmation {
+		status = models.TxStatusAwaitingConfirmation
+	}
+
+	// Use transaction to debit balance and create request atomically
+	tx := db.Begin()
+
+	// Debit user balance immediately. The balance is re-read under a row lock
+	// so a concurrent withdrawal or deposit cannot act on the same balance;
+	// the checks made before the lock are repeated against it.
+	locked, err := ledger.LockUser(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("Failed to process withdrawal")
+	}
+	// A freeze applied since validation stops the request too
+	if err := settings.CheckWithdrawalsOpen(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := restrictions.Check(tx, locked.ID, models.RestrictionWithdrawalsFrozen, c.Now()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if locked.BalanceMicroCredits() < amountMicro {
+		tx.Rollback()
+		return nil, &WithdrawalInputError{Message: "Insufficient balance"}
+	}
+	limitsService := limits.NewService(tx, c)
+	if err := limitsService.CheckWithdrawable(locked, amountMicro); err != nil {
+		tx.Rollback()
+		return nil, &WithdrawalInputError{Message: err.Error()}
+	}
+	// Requests recorded since validation count against the rolling limits too
+	if err := limitsService.Check(locked.ID, amountMicro, withdrawalLimits); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// Create withdrawal request in PENDING (or ON_HOLD) state, awaiting admin
+	// review, or AWAITING_USER_CONFIRMATION
+	withdrawalReq := models.WithdrawalRequest{
+		UserID:      user.ID,
+		ChainID:     chainInfo.ChainID,
+		ChainName:   chainName,
+		TokenSymbol: tokenSymbol,
+		Amount:      amountMicro,
+		ToAddress:   toAddress,
+		Status:      status,
+		HoldReason:  holdReason,
+		HoldUntil:   holdUntil,
+		DeviceID:    opts.DeviceID,
+		TraceID:     traceID,
+		RequestIP:   opts.Origin.IP,
+		UserAgent:   opts.Origin.UserAgent,
+		Country:     opts.Origin.Location.Country,
+		Region:      opts.Origin.Location.Region,
+	}
+	beneficiary.Apply(&withdrawalReq, c.Now())
+	risk.NewScorer(tx, c).Apply(&withdrawalReq)
+
+	withdrawals := repository.NewGormWithdrawalRepo(tx)
+	newCountry, err := isNewWithdrawalCountry(ctx, withdrawals, user.ID, withdrawalReq.Country)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("Failed to process withdrawal")
+	}
+	withdrawalReq.NewCountry = newCountry
+
+	if err := withdrawals.Create(ctx, &withdrawalReq); err != nil {
+		tx.Rollback()
+		return nil, errors.New("Failed to create withdrawal request")
+	}
+	if _, err := ledger.Apply(tx, locked, ledger.Posting{
+		Type:          models.LedgerTypeWithdrawal,
+		Amount:        -amountMicro,
+		ReferenceType: ledger.ReferenceTypeWithdrawalRequest,
+		ReferenceID:   withdrawalReq.ID,
+		Description:   fmt.Sprintf("Withdrawal of %s %s on %s", models.FormatMicroCredits(amountMicro), tokenSymbol, chainName),
+	}); err != nil {
+		tx.Rollback()
+		return nil, errors.New("Failed to process withdrawal")
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Error("failed to commit withdrawal request", "error", err)
+		return nil, errors.New("Failed to process withdrawal")
+	}
+	*user = *locked
+
+	log.Info("withdrawal requested", "withdrawal_id", withdrawalReq.ID, "status", withdrawalReq.Status,
+		"country", withdrawalReq.Country, "new_country", withdrawalReq.NewCountry, "chain", chainName, "token", tokenSymbol, "credits", models.FormatMicroCredits(amountMicro), "risk_score", withdrawalReq.RiskScore)
+	return &withdrawalReq, nil
+}
+
+// isNewWithdrawalCountry reports whether country differs from that of all the
+// user's earlier withdrawals. A first withdrawal, or one whose earlier
+// withdrawals have no known country, has nothing to compare with and is not new.
+func isNewWithdrawalCountry(ctx context.Context, withdrawals repository.WithdrawalRepo, userID int64, country string) (bool, error) {
+	if country == "" {
+		return false, nil
+	}
+	seen, err := withdrawals.Countries(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range seen {
+		if c == country {
+			return false, nil
 		}
 	}
+	return len(seen) > 0, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"withdrawals": items,
-	})
+// requestOrigin captures the IP, user agent and coarse location of r.
+// A failed lookup is logged and leaves the location empty.
+func requestOrigin(r *http.Request, locator geoip.Locator) WithdrawalOrigin {
+	ip := strings.TrimSpace(security.ClientIP(r))
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	origin := WithdrawalOrigin{IP: ip, UserAgent: r.UserAgent()}
+	if locator != nil {
+		loc, err := locator.Locate(ip, r.Header)
+		if err != nil {
+			logger.FromContext(r.Context()).Warn("failed to locate withdrawal IP", "ip", ip, "error", err)
+		}
+		origin.Location = loc
+	}
+	return origin
 }
 
-// checkDailyWithdrawalLimit checks if the user has exceeded daily withdrawal limits
-func checkDailyWithdrawalLimit(db *gorm.DB, userID int64, amount int64) error {
-	today := time.Now().Truncate(24 * time.Hour)
+// deviceHold returns the device of the request's session and, if it was first
+// seen recently, when its withdrawals may proceed. Tokens without a session
+// are not held.
+func deviceHold(deviceGuard *devices.Service, r *http.Request) (*uint, *time.Time, error) {
+	sessionID := middleware.SessionIDFromRequest(r)
+	if deviceGuard == nil || sessionID == "" {
+		return nil, nil, nil
+	}
+	session, err := deviceGuard.Active(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	holdUntil, err := deviceGuard.WithdrawalHold(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &session.DeviceID, holdUntil, nil
+}
 
-	var dailyTotal int64
-	db.Model(&models.WithdrawalRequest{}).
-		Where("user_id = ? AND created_at >= ? AND status != ?", userID, today, models.TxStatusRejected).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&dailyTotal)
+// GetUserWithdrawalsHandler returns the user's withdrawal requests
+func GetUserWithdrawalsHandler(db *gorm.DB, repos repository.Repos) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		requests, err := repos.Withdrawals.ListByUser(r.Context(), user.ID, 50)
+		if err != nil {
+			http.Error(w, "Failed to load withdrawals", http.StatusInternalServerError)
+			return
+		}
+
+		type WithdrawalListItem struct {
+			ID          uint       `json:"id"`
+			ChainName   string     `json:"chainName"`
+			TokenSymbol string     `json:"tokenSymbol"`
+			Amount      float64    `json:"amount"`
+			AmountMicro int64      `json:"amountMicro"`
+			ToAddress   string     `json:"toAddress"`
+			Status      string     `json:"status"`
+			CreatedAt   time.Time  `json:"createdAt"`
+			ProcessedAt *time.Time `json:"processedAt,omitempty"`
+
+			OriginalAmount   float64 `json:"originalAmount,omitempty"` // Requested credits, when approved for less
+			AdjustmentReason string  `json:"adjustmentReason,omitempty"`
+		}
 
-	if dailyTotal+amount > DailyWithdrawalLimit {
-		return &WithdrawalLimitError{
-			Message:    "Daily withdrawal limit exceeded",
-			DailyLimit: DailyWithdrawalLimit,
-			Used:       dailyTotal,
-			Requested:  amount,
+		items := make([]WithdrawalListItem, len(requests))
+		for i, req := range requests {
+			items[i] = WithdrawalListItem{
+				ID:          req.ID,
+				ChainName:   req.ChainName,
+				TokenSymbol: req.TokenSymbol,
+				Amount:      models.DisplayCredits(req.Amount),
+				AmountMicro: req.Amount,
+				ToAddress:   req.ToAddress,
+				Status:      req.Status,
+				CreatedAt:   req.CreatedAt,
+				ProcessedAt: req.ProcessedAt,
+
+				OriginalAmount:   models.DisplayCredits(req.OriginalAmount),
+				AdjustmentReason: req.AdjustmentReason,
+			}
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"withdrawals": items,
+		})
 	}
+}
 
-	return nil
+// WindowUsageItem is one window's usage in a limit error response, in credits
+type WindowUsageItem struct {
+	Window    string  `json:"window"`
+	Scope     string  `json:"scope"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Remaining float64 `json:"remaining"`
 }
 
-// WithdrawalLimitError represents a withdrawal limit error
-type WithdrawalLimitError struct {
-	Message    string
-	DailyLimit int64
-	Used       int64
-	Requested  int64
+// WithdrawalLimitResponse is the 400 body returned when a withdrawal exceeds a limit
+type WithdrawalLimitResponse struct {
+	Error     string            `json:"error"`
+	Window    string            `json:"window"`
+	Scope     string            `json:"scope"`
+	Requested float64           `json:"requested"`
+	Usage     []WindowUsageItem `json:"usage"`
 }
 
-func (e *WithdrawalLimitError) Error() string {
-	return e.Message
+func newWithdrawalLimitResponse(limitErr *limits.LimitError) *WithdrawalLimitResponse {
+	resp := &WithdrawalLimitResponse{
+		Error:     limitErr.Error(),
+		Window:    limitErr.Window,
+		Scope:     limitErr.Scope,
+		Requested: models.DisplayCredits(limitErr.Requested),
+		Usage:     make([]WindowUsageItem, 0, len(limitErr.Usage)),
+	}
+	for _, u := range limitErr.Usage {
+		resp.Usage = append(resp.Usage, WindowUsageItem{
+			Window:    u.Window,
+			Scope:     u.Scope,
+			Limit:     models.DisplayCredits(u.Limit),
+			Used:      models.DisplayCredits(u.Used),
+			Remaining: models.DisplayCredits(u.Remaining),
+		})
+	}
+	return resp
+}
+
+func writeWithdrawalLimitError(w http.ResponseWriter, limitErr *limits.LimitError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(newWithdrawalLimitResponse(limitErr))
 }
@@ -0,0 +1,97 @@
+package wallethandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"socialpredict/logger"
+	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/services/twofactor"
+	"socialpredict/services/withdrawalconfirm"
+	"socialpredict/util"
+)
+
+// EmailConfirmationSettingRequest turns withdrawal email confirmation on or
+// off. Turning it off needs a second factor, so a hijacked session cannot
+// simply remove the protection.
+type EmailConfirmationSettingRequest struct {
+	Enabled       bool   `json:"enabled"`
+	TwoFactorCode string `json:"twoFactorCode,omitempty"`
+	EmailToken    string `json:"emailToken,omitempty"` // Emailed code for purpose SECURITY_SETTINGS
+}
+
+// ConfirmWithdrawalRequest carries the token from an emailed confirmation link
+type ConfirmWithdrawalRequest struct {
+	Token string `json:"token"`
+}
+
+// SetWithdrawalEmailConfirmationHandler lets a user require an emailed
+// confirmation link for each of their withdrawals
+func SetWithdrawalEmailConfirmationHandler(secondFactor *twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := util.GetDB()
+		user, httperr := middleware.ValidateUserAndEnforcePasswordChangeGetUser(r, db)
+		if httperr != nil {
+			http.Error(w, httperr.Error(), httperr.StatusCode)
+			return
+		}
+
+		var req EmailConfirmationSettingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if user.ConfirmWithdrawalsByEmail && !req.Enabled {
+			if httperr := middleware.RequireSecondFactor(secondFactor, user, models.EmailTokenPurposeSecuritySettings, req.TwoFactorCode, req.EmailToken); httperr != nil {
+				http.Error(w, httperr.Error(), httperr.StatusCode)
+				return
+			}
+		}
+
+		if err := db.Model(user).Update("confirm_withdrawals_by_email", req.Enabled).Error; err != nil {
+			http.Error(w, "Failed to update setting", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"enabled": req.Enabled})
+	}
+}
+
+// ConfirmWithdrawalHandler confirms a withdrawal from the token in its emailed
+// link, passing it on to admin review. It needs no session: holding the
+// token proves access to the user's email.
+func ConfirmWithdrawalHandler(confirmations *withdrawalconfirm.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ConfirmWithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		withdrawalReq, err := confirmations.Confirm(req.Token)
+		if err != nil {
+			switch {
+			case errors.Is(err, withdrawalconfirm.ErrInvalidToken), errors.Is(err, withdrawalconfirm.ErrRequestNotFound):
+				http.Error(w, withdrawalconfirm.ErrInvalidToken.Error(), http.StatusBadRequest)
+			case errors.Is(err, withdrawalconfirm.ErrExpired):
+				http.Error(w, err.Error(), http.StatusGone)
+			case errors.Is(err, withdrawalconfirm.ErrNotAwaiting):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				logger.FromContext(r.Context()).Error("failed to confirm withdrawal", "error", err)
+				http.Error(w, "Failed to confirm withdrawal", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Withdrawal confirmed. It will be processed after admin approval.",
+			"withdrawalId": withdrawalReq.ID,
+			"status":       withdrawalReq.Status,
+		})
+	}
+}
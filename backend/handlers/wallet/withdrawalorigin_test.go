@@ -0,0 +1,53 @@
+package wallethandlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/repository"
+	"socialpredict/services/geoip"
+)
+
+func TestRequestOriginStripsPortAndLocates(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v0/wallet/withdraw", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("CF-IPCountry", "pt")
+
+	origin := requestOrigin(r, geoip.HeaderLocator{CountryHeader: "CF-IPCountry"})
+	if origin.IP != "203.0.113.7" || origin.UserAgent != "test-agent" || origin.Location.Country != "PT" {
+		t.Errorf("origin = %+v", origin)
+	}
+	if origin := requestOrigin(r, nil); origin.Location.Country != "" {
+		t.Errorf("origin without locator = %+v", origin)
+	}
+}
+
+func TestIsNewWithdrawalCountry(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 100)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Nothing to compare a first withdrawal with
+	if isNew, err := isNewWithdrawalCountry(context.Background(), repository.NewGormWithdrawalRepo(db), user.ID, "US"); err != nil || isNew {
+		t.Fatalf("first withdrawal = %v, %v", isNew, err)
+	}
+
+	req := models.WithdrawalRequest{UserID: user.ID, ChainID: 8453, ChainName: "base", TokenSymbol: "USDC",
+		Amount: models.MicroCreditsPerCredit, ToAddress: "0x1111111111111111111111111111111111111111",
+		Status: models.TxStatusCompleted, Country: "US"}
+	if err := db.Create(&req).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+
+	for country, want := range map[string]bool{"US": false, "NG": true, "": false} {
+		if isNew, err := isNewWithdrawalCountry(context.Background(), repository.NewGormWithdrawalRepo(db), user.ID, country); err != nil || isNew != want {
+			t.Errorf("isNewWithdrawalCountry(%q) = %v, %v; want %v", country, isNew, err, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package wallethandlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/limits"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/screening"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+func TestConcurrentWithdrawalsCannotOverdraw(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// The in-memory database lives on one connection, so the two requests'
+	// transactions take turns on it as they would on a locked row
+	sqlDB.SetMaxOpenConns(1)
+
+	user := modelstesting.GenerateUser("alice", 100)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Both requests were validated against the same 100 credit balance
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		stale := user
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = InitiateWithdrawalCore(context.Background(), db, clock.New(), screening.NewStaticList(nil), &stale,
+				"ethereum", "USDC", "0x1111111111111111111111111111111111111111", models.CreditsToMicro(60), WithdrawalOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	var succeeded int
+	for _, err := range errs {
+		var inputErr *WithdrawalInputError
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &inputErr) && inputErr.Message == "Insufficient balance":
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d withdrawals succeeded, want 1", succeeded)
+	}
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != models.CreditsToMicro(40) {
+		t.Fatalf("balance = %d micro, want 40 credits", user.BalanceMicroCredits())
+	}
+	var requests int64
+	db.Model(&models.WithdrawalRequest{}).Where("user_id = ?", user.ID).Count(&requests)
+	if requests != 1 {
+		t.Fatalf("withdrawal requests = %d, want 1", requests)
+	}
+}
+
+func TestRollingLimitsAreRecheckedUnderLock(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t.Cleanup(settings.Shared.Invalidate)
+	err := settings.Shared.SetWithdrawalLimits(db, settings.WithdrawalLimits{
+		Min: models.CreditsToMicro(10), Max: models.CreditsToMicro(100), Daily: models.CreditsToMicro(100),
+	}, "test")
+	if err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	user := modelstesting.CreateUser(t, db, "alice", 200)
+
+	// Another request is recorded after this one is validated but before it
+	// takes the balance lock
+	raced := false
+	db.Callback().Query().Before("gorm:query").Register("test:concurrent_request", func(tx *gorm.DB) {
+		if _, locking := tx.Statement.Clauses["FOR"]; locking && !raced {
+			raced = true
+			tx.Session(&gorm.Session{NewDB: true}).Create(&models.WithdrawalRequest{UserID: user.ID, ChainID: 1, ChainName: "ethereum",
+				TokenSymbol: "USDC", Amount: models.CreditsToMicro(50), ToAddress: "0x1111111111111111111111111111111111111111", Status: models.TxStatusPending})
+		}
+	})
+
+	_, err = InitiateWithdrawalCore(context.Background(), db, clock.New(), screening.NewStaticList(nil), &user,
+		"ethereum", "USDC", "0x1111111111111111111111111111111111111111", models.CreditsToMicro(60), WithdrawalOptions{})
+	var limitErr *limits.LimitError
+	if !raced || !errors.As(err, &limitErr) || limitErr.Window != limits.WindowDaily {
+		t.Fatalf("err = %v, want the daily limit enforced under the lock", err)
+	}
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != models.CreditsToMicro(200) {
+		t.Fatalf("balance = %d micro, want nothing debited", user.BalanceMicroCredits())
+	}
+}
+
+func TestFreezesAreRecheckedUnderLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		freeze  func(db *gorm.DB, userID int64) error
+		wantErr func(error) bool
+	}{
+		{
+			name: "platform freeze",
+			freeze: func(db *gorm.DB, _ int64) error {
+				_, err := settings.SetWithdrawalFreeze(db, true, "incident", "admin")
+				return err
+			},
+			wantErr: func(err error) bool { return errors.Is(err, settings.ErrWithdrawalsFrozen) },
+		},
+		{
+			name: "user restriction",
+			freeze: func(db *gorm.DB, userID int64) error {
+				_, err := restrictions.Set(db, userID, models.RestrictionWithdrawalsFrozen, "review", nil, "admin", time.Now())
+				return err
+			},
+			wantErr: func(err error) bool {
+				var restrictedErr *restrictions.RestrictedError
+				return errors.As(err, &restrictedErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := modelstesting.NewFakeDB(t)
+			user := modelstesting.CreateUser(t, db, "alice", 200)
+
+			// The freeze lands after this request is validated but before it
+			// takes the balance lock
+			raced := false
+			db.Callback().Query().Before("gorm:query").Register("test:concurrent_freeze", func(tx *gorm.DB) {
+				if _, locking := tx.Statement.Clauses["FOR"]; locking && !raced {
+					raced = true
+					if err := tt.freeze(tx.Session(&gorm.Session{NewDB: true}), user.ID); err != nil {
+						t.Errorf("freeze: %v", err)
+					}
+				}
+			})
+
+			_, err := InitiateWithdrawalCore(context.Background(), db, clock.New(), screening.NewStaticList(nil), &user,
+				"ethereum", "USDC", "0x1111111111111111111111111111111111111111", models.CreditsToMicro(60), WithdrawalOptions{})
+			if !raced || !tt.wantErr(err) {
+				t.Fatalf("err = %v, want the freeze enforced under the lock", err)
+			}
+			db.First(&user, user.ID)
+			if user.BalanceMicroCredits() != models.CreditsToMicro(200) {
+				t.Fatalf("balance = %d micro, want nothing debited", user.BalanceMicroCredits())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// RequestIDHeader carries the trace ID on requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// maxTraceIDLength bounds inbound request IDs; longer values are replaced
+const maxTraceIDLength = 64
+
+type traceIDKey struct{}
+
+// Structured is the JSON logger used for traced wallet and DFNS events.
+// Set LOG_FORMAT=text for human-readable output.
+var Structured = newStructured()
+
+func newStructured() *slog.Logger {
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "text") {
+		return slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+}
+
+// NewTraceID returns a random 16-byte hex trace ID
+func NewTraceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithTraceID returns a copy of ctx carrying id
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceID returns the trace ID stored in ctx, or "" if there is none
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the structured logger tagged with ctx's trace ID
+func FromContext(ctx context.Context) *slog.Logger {
+	return WithTrace(TraceID(ctx))
+}
+
+// WithTrace returns the structured logger tagged with id. It is used where
+// the trace ID was persisted, e.g. on a withdrawal or in a DFNS webhook.
+func WithTrace(id string) *slog.Logger {
+	if id == "" {
+		return Structured
+	}
+	return Structured.With("trace_id", id)
+}
+
+// RequestIDMiddleware gives every request a trace ID, reusing a well-formed
+// X-Request-ID from the caller, and echoes it in the response header
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validTraceID(id) {
+			id = NewTraceID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithTraceID(r.Context(), id)))
+	})
+}
+
+// validTraceID accepts short IDs of letters, digits, '-' and '_' so a caller
+// cannot inject arbitrary text into logs or the DFNS external ID
+func validTraceID(id string) bool {
+	if id == "" || len(id) > maxTraceIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
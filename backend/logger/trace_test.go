@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		inbound string
+		reuse   bool
+	}{
+		{"reuses well-formed ID", "req-123_abc", true},
+		{"generates when missing", "", false},
+		{"replaces malformed ID", "bad id\nwith newline", false},
+		{"replaces oversized ID", string(bytes.Repeat([]byte("a"), maxTraceIDLength+1)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = TraceID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header.Set(RequestIDHeader, tt.inbound)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if seen == "" {
+				t.Fatal("expected a trace ID in the request context")
+			}
+			if got := rec.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("response header %q, context %q", got, seen)
+			}
+			if tt.reuse != (seen == tt.inbound) {
+				t.Errorf("trace ID %q, inbound %q, reuse %v", seen, tt.inbound, tt.reuse)
+			}
+		})
+	}
+}
+
+func TestFromContextTagsTraceID(t *testing.T) {
+	var buf bytes.Buffer
+	orig := Structured
+	Structured = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { Structured = orig })
+
+	FromContext(WithTraceID(context.Background(), "trace-1")).Info("hello")
+	FromContext(context.Background()).Info("untraced")
+
+	dec := json.NewDecoder(&buf)
+	var traced, untraced map[string]interface{}
+	if err := dec.Decode(&traced); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := dec.Decode(&untraced); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if traced["trace_id"] != "trace-1" {
+		t.Errorf("trace_id = %v, want trace-1", traced["trace_id"])
+	}
+	if _, ok := untraced["trace_id"]; ok {
+		t.Errorf("untraced entry has trace_id %v", untraced["trace_id"])
+	}
+}
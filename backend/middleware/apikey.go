@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"socialpredict/models"
+	"socialpredict/services/apikeys"
+	"strconv"
+	"strings"
+)
+
+type apiKeyContextKey struct{}
+
+// apiKeyAuth is what a request authenticated by API key carries in its context
+type apiKeyAuth struct {
+	key  *models.APIKey
+	user *models.User
+}
+
+// APIKeyFromRequest returns the API key the request was authenticated with,
+// or nil if it was not
+func APIKeyFromRequest(r *http.Request) *models.APIKey {
+	if auth, ok := r.Context().Value(apiKeyContextKey{}).(apiKeyAuth); ok {
+		return auth.key
+	}
+	return nil
+}
+
+func apiKeyUser(r *http.Request) *models.User {
+	if auth, ok := r.Context().Value(apiKeyContextKey{}).(apiKeyAuth); ok {
+		return auth.user
+	}
+	return nil
+}
+
+// APIKeyMiddleware lets the routes it wraps be called with an API key that
+// grants scope. Requests bearing a key are authenticated and rate limited
+// per key; handlers then see the key's user through ValidateTokenAndGetUser.
+// Requests without a key pass through untouched. Routes that are not
+// wrapped reject API keys.
+func APIKeyMiddleware(keys *apikeys.Service, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !apikeys.IsAPIKey(token) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, user, err := keys.Authenticate(token)
+			if err != nil {
+				if errors.Is(err, apikeys.ErrInvalidKey) || errors.Is(err, apikeys.ErrRevoked) {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				log.Printf("Failed to authenticate API key: %v", err)
+				http.Error(w, "Failed to authenticate API key", http.StatusInternalServerError)
+				return
+			}
+			if !key.HasScope(scope) {
+				http.Error(w, "API key does not have the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.RateLimitPerMinute))
+			if ok, retryAfter := keys.Allow(key); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "API key rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, apiKeyAuth{key: key, user: user})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"net/http"
+	"socialpredict/clock"
 	"socialpredict/models"
+	"socialpredict/services/apikeys"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -35,12 +37,25 @@ func ValidateUserAndEnforcePasswordChangeGetUser(r *http.Request, db *gorm.DB) (
 
 // ValidateTokenAndGetUser checks that the user is who they claim to be, and returns their information for use
 func ValidateTokenAndGetUser(r *http.Request, db *gorm.DB) (*models.User, *HTTPError) {
+	return ValidateTokenAndGetUserAt(r, db, clock.New())
+}
+
+// ValidateTokenAndGetUserAt is ValidateTokenAndGetUser with impersonation
+// sessions checked for expiry as of c's current time
+func ValidateTokenAndGetUserAt(r *http.Request, db *gorm.DB, c clock.Clock) (*models.User, *HTTPError) {
+	if user := apiKeyUser(r); user != nil {
+		return user, nil
+	}
+
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		return nil, &HTTPError{StatusCode: http.StatusUnauthorized, Message: "Authorization header is required"}
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if apikeys.IsAPIKey(tokenString) {
+		return nil, &HTTPError{StatusCode: http.StatusForbidden, Message: "API keys are not accepted on this endpoint"}
+	}
 	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return getJWTKey(), nil
 	})
@@ -49,6 +64,12 @@ func ValidateTokenAndGetUser(r *http.Request, db *gorm.DB) (*models.User, *HTTPE
 	}
 
 	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
+		if claims.ImpersonatedBy != "" {
+			return impersonatedUser(r, db, c, claims)
+		}
+		if httpErr := checkSession(db, claims); httpErr != nil {
+			return nil, httpErr
+		}
 		var user models.User
 		result := db.Where("username = ?", claims.Username).First(&user)
 		if result.Error != nil {
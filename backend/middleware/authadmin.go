@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"socialpredict/models"
+	"socialpredict/services/roles"
 
 	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
@@ -28,6 +29,9 @@ func ValidateAdminToken(r *http.Request, db *gorm.DB) error {
 	}
 
 	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
+		if httpErr := checkSession(db, claims); httpErr != nil {
+			return httpErr
+		}
 		var user models.User
 		result := db.Where("username = ?", claims.Username).First(&user)
 		if result.Error != nil {
@@ -42,3 +46,23 @@ func ValidateAdminToken(r *http.Request, db *gorm.DB) error {
 
 	return errors.New("invalid token")
 }
+
+// RequirePermission checks that the authenticated user is an admin holding a
+// role that grants permission, and returns them
+func RequirePermission(r *http.Request, db *gorm.DB, permission string) (*models.User, *HTTPError) {
+	user, httpErr := ValidateTokenAndGetUser(r, db)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if user.UserType != "ADMIN" {
+		return nil, &HTTPError{StatusCode: http.StatusForbidden, Message: "Admin access required"}
+	}
+	allowed, err := roles.HasPermission(db, user.ID, permission)
+	if err != nil {
+		return nil, &HTTPError{StatusCode: http.StatusInternalServerError, Message: "Failed to check permissions"}
+	}
+	if !allowed {
+		return nil, &HTTPError{StatusCode: http.StatusForbidden, Message: fmt.Sprintf("The %s permission is required", permission)}
+	}
+	return user, nil
+}
@@ -3,9 +3,12 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"socialpredict/services/devices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
 )
 
 type HTTPError struct {
@@ -30,3 +33,38 @@ func extractTokenFromHeader(r *http.Request) (string, error) {
 func parseToken(tokenString string, keyFunc jwt.Keyfunc) (*jwt.Token, error) {
 	return jwt.ParseWithClaims(tokenString, &UserClaims{}, keyFunc)
 }
+
+// checkSession rejects a token whose session has been revoked. Tokens issued
+// before sessions were tracked carry no session ID and are let through.
+// Impersonation tokens are rejected; only ValidateTokenAndGetUser takes them.
+func checkSession(db *gorm.DB, claims *UserClaims) *HTTPError {
+	if claims.ImpersonatedBy != "" {
+		return &HTTPError{StatusCode: http.StatusForbidden, Message: "Impersonation tokens are not accepted on this endpoint"}
+	}
+	if claims.Id == "" {
+		return nil
+	}
+	if _, err := devices.ActiveSession(db, claims.Id, time.Now()); err != nil {
+		return &HTTPError{StatusCode: http.StatusUnauthorized, Message: "Session has expired or been revoked"}
+	}
+	return nil
+}
+
+// SessionIDFromRequest returns the session ID of the request's token, or ""
+// if it has none. Call it only after the token has been validated.
+func SessionIDFromRequest(r *http.Request) string {
+	tokenString, err := extractTokenFromHeader(r)
+	if err != nil {
+		return ""
+	}
+	token, err := parseToken(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return getJWTKey(), nil
+	})
+	if err != nil {
+		return ""
+	}
+	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
+		return claims.Id
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/impersonation"
+	"testing"
+	"time"
+)
+
+func TestImpersonationTokenIsReadOnlyAndAudited(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("support", 0)
+	admin.UserType = "ADMIN"
+	user := modelstesting.GenerateUser("alice", 0)
+	for _, u := range []*models.User{&admin, &user} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	svc := impersonation.NewService(db, impersonation.Config{TTL: time.Minute, MaxTTL: time.Hour}, clock.New())
+	session, err := svc.Start(&admin, "alice", "ticket 42", 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	token, err := IssueImpersonationToken(session)
+	if err != nil {
+		t.Fatalf("IssueImpersonationToken: %v", err)
+	}
+
+	request := func(method, path string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	req := request("GET", "/v0/wallet/transactions")
+	got, httpErr := ValidateTokenAndGetUser(req, db)
+	if httpErr != nil || got.Username != "alice" {
+		t.Fatalf("GET as alice = %+v, %v", got, httpErr)
+	}
+	if by := ImpersonatorFromRequest(req); by != "support" {
+		t.Errorf("ImpersonatorFromRequest = %q, want support", by)
+	}
+	var views int64
+	db.Model(&models.AuditLog{}).Where("action = ? AND actor = ? AND details LIKE ?", "USER_IMPERSONATION_VIEW", "support", "%GET /v0/wallet/transactions").Count(&views)
+	if views != 1 {
+		t.Errorf("audited %d views, want 1", views)
+	}
+
+	if _, httpErr := ValidateTokenAndGetUser(request("POST", "/v0/bet"), db); httpErr == nil || httpErr.StatusCode != http.StatusForbidden {
+		t.Errorf("POST with impersonation token = %+v, want 403", httpErr)
+	}
+	if err := ValidateAdminToken(request("GET", "/v0/admin/users"), db); err == nil {
+		t.Error("admin route accepted an impersonation token")
+	}
+
+	if err := svc.End(session.ID, "support"); err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	if _, httpErr := ValidateTokenAndGetUser(request("GET", "/v0/wallet"), db); httpErr == nil || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET after the session ended = %+v, want 401", httpErr)
+	}
+}
+
+func TestImpersonationTokenExpiresOnTheClock(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("support", 0)
+	admin.UserType = "ADMIN"
+	user := modelstesting.GenerateUser("alice", 0)
+	for _, u := range []*models.User{&admin, &user} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	c := clock.NewFake(time.Now())
+	svc := impersonation.NewService(db, impersonation.Config{TTL: time.Minute, MaxTTL: time.Hour}, c)
+	session, err := svc.Start(&admin, "alice", "ticket 42", 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	token, err := IssueImpersonationToken(session)
+	if err != nil {
+		t.Fatalf("IssueImpersonationToken: %v", err)
+	}
+	request := func() *http.Request {
+		req := httptest.NewRequest("GET", "/v0/wallet", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	if got, httpErr := ValidateTokenAndGetUserAt(request(), db, c); httpErr != nil || got.Username != "alice" {
+		t.Fatalf("GET within the session = %+v, %v", got, httpErr)
+	}
+	c.Advance(30 * time.Second)
+	if _, httpErr := ValidateTokenAndGetUserAt(request(), db, c); httpErr != nil {
+		t.Fatalf("GET before expiry = %v", httpErr)
+	}
+	c.Advance(time.Minute)
+	if _, httpErr := ValidateTokenAndGetUserAt(request(), db, c); httpErr == nil || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET after the session expired = %+v, want 401", httpErr)
+	}
+}
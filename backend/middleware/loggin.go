@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"socialpredict/clock"
 	"socialpredict/models"
 	"socialpredict/security"
+	"socialpredict/services/devices"
 	"socialpredict/util"
 	"time"
 
@@ -21,7 +23,8 @@ func getJWTKey() []byte {
 
 // UserClaims represents the expected structure of the JWT claims
 type UserClaims struct {
-	Username string `json:"username"`
+	Username       string `json:"username"`
+	ImpersonatedBy string `json:"impersonatedBy,omitempty"` // Set on read-only impersonation tokens
 	jwt.StandardClaims
 }
 
@@ -82,11 +85,21 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record the session and the device it was opened on
+	expiresAt := time.Now().Add(24 * time.Hour)
+	session, deviceKey, err := devices.NewService(db, devices.LoadConfigFromEnv(), clock.New()).
+		RecordLogin(user.ID, r.Header.Get(devices.DeviceHeader), r.UserAgent(), security.ClientIP(r), expiresAt)
+	if err != nil {
+		http.Error(w, "Error creating session", http.StatusInternalServerError)
+		return
+	}
+
 	// Create UserClaim
 	claims := &UserClaims{
 		Username: user.Username,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			Id:        session.ID,
+			ExpiresAt: expiresAt.Unix(),
 		},
 	}
 
@@ -109,6 +122,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		"username":           user.Username,
 		"usertype":           user.UserType,
 		"mustChangePassword": user.MustChangePassword,
+		"deviceId":           deviceKey, // Send back as X-Device-ID on later logins
 	}
 	json.NewEncoder(w).Encode(responseData)
 }
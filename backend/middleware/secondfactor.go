@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"socialpredict/models"
+	"socialpredict/services/twofactor"
+)
+
+// Request headers carrying a second factor, for sensitive endpoints whose
+// request body has no field for one
+const (
+	TwoFactorCodeHeader = "X-2FA-Code"
+	EmailTokenHeader    = "X-Email-Token"
+)
+
+// SecondFactorVerifier checks a TOTP code or emailed code for a purpose.
+// *twofactor.Service satisfies it.
+type SecondFactorVerifier interface {
+	Verify(user *models.User, purpose, code, emailToken string) error
+}
+
+// SecondFactorFromRequest reads a TOTP code and emailed code from the request headers
+func SecondFactorFromRequest(r *http.Request) (code, emailToken string) {
+	return r.Header.Get(TwoFactorCodeHeader), r.Header.Get(EmailTokenHeader)
+}
+
+// RequireSecondFactor checks code or emailToken for purpose and returns the
+// HTTP error to send if neither is valid
+func RequireSecondFactor(v SecondFactorVerifier, user *models.User, purpose, code, emailToken string) *HTTPError {
+	err := v.Verify(user, purpose, code, emailToken)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, twofactor.ErrRequired), errors.Is(err, twofactor.ErrInvalidCode),
+		errors.Is(err, twofactor.ErrInvalidToken), errors.Is(err, twofactor.ErrNotEnrolled):
+		// 403 rather than 401, so clients do not treat it as an expired session
+		return &HTTPError{StatusCode: http.StatusForbidden, Message: err.Error()}
+	default:
+		log.Printf("Failed to verify second factor for user %d: %v", user.ID, err)
+		return &HTTPError{StatusCode: http.StatusInternalServerError, Message: "Failed to verify second factor"}
+	}
+}
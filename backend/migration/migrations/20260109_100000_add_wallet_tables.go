@@ -18,7 +18,7 @@ func init() {
 		}
 
 		// Migrate the SupportedChain model
-		if err := db.AutoMigrate(&models.SupportedChain{}); err != nil {
+		if err := db.AutoMigrate(&legacySupportedChain{}); err != nil {
 			return err
 		}
 
@@ -38,7 +38,7 @@ func init() {
 		}
 
 		// Seed default supported chains
-		chains := []models.SupportedChain{
+		chains := []legacySupportedChain{
 			{
 				ChainID:          1,
 				Name:             "ethereum",
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateMicroCreditAmounts rescales user balances and existing crypto
+// amounts from whole credits to micro-credits.
+func MigrateMicroCreditAmounts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.User{}).
+			Where("1 = 1").
+			UpdateColumn("account_balance", gorm.Expr("account_balance * ?", models.MicroCreditsPerCredit)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.WithdrawalRequest{}).
+			Where("1 = 1").
+			Update("amount", gorm.Expr("amount * ?", models.MicroCreditsPerCredit)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CryptoTransaction{}).
+			Where("1 = 1").
+			Update("amount_credits", gorm.Expr("amount_credits * ?", models.MicroCreditsPerCredit)).Error
+	})
+}
+
+func init() {
+	err := migration.Register("20260201090000", MigrateMicroCreditAmounts)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260201090000: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260210090000", func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&models.Wallet{}); err != nil {
+			return err
+		}
+		// Wallets created before multi-org support all live in the primary org
+		return db.Model(&models.Wallet{}).
+			Where("dfns_org IS NULL OR dfns_org = ''").
+			Update("dfns_org", "primary").Error
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260210090000: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260212090000", func(db *gorm.DB) error {
+		return db.AutoMigrate(&models.WithdrawalRequest{})
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260212090000: %v", err)
+	}
+}
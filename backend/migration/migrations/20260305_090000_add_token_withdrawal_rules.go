@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260305090000", func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&models.TokenWithdrawalRule{}); err != nil {
+			return err
+		}
+
+		// Mainnet gas makes small stablecoin withdrawals uneconomic
+		rules := []models.TokenWithdrawalRule{
+			{ChainName: "ethereum", TokenSymbol: "USDC", MinWithdrawal: models.CreditsToMicro(50), Note: "Mainnet gas", UpdatedBy: "migration"},
+			{ChainName: "ethereum", TokenSymbol: "USDT", MinWithdrawal: models.CreditsToMicro(50), Note: "Mainnet gas", UpdatedBy: "migration"},
+		}
+		for _, rule := range rules {
+			if err := db.Where("chain_name = ? AND token_symbol = ?", rule.ChainName, rule.TokenSymbol).FirstOrCreate(&rule).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260305090000: %v", err)
+	}
+}
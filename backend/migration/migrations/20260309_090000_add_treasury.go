@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260309090000", func(db *gorm.DB) error {
+		return db.AutoMigrate(&models.TreasuryWallet{}, &models.TreasuryTransfer{})
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260309090000: %v", err)
+	}
+}
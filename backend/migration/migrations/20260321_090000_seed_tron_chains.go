@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"errors"
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260321090000", func(db *gorm.DB) error {
+		// TRON chains ship inactive; enabling one needs a DFNS org that holds
+		// TRON wallets and TRX in the treasury hot wallet for energy
+		chains := []legacySupportedChain{
+			{
+				ChainID:          728126428,
+				Name:             "tron",
+				DisplayName:      "TRON",
+				ExplorerURL:      "https://tronscan.org",
+				USDCAddress:      "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8",
+				USDTAddress:      "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t",
+				MinConfirmations: 19, // Blocks until solidified
+			},
+			{
+				ChainID:          3448148188,
+				Name:             "tron-nile",
+				DisplayName:      "TRON Nile",
+				ExplorerURL:      "https://nile.tronscan.org",
+				USDTAddress:      "TXYZopYRdj2D9XRtbG411XZZ3kM5VkAeBf",
+				MinConfirmations: 19,
+			},
+		}
+
+		for _, chain := range chains {
+			var existing legacySupportedChain
+			err := db.Where("chain_id = ?", chain.ChainID).First(&existing).Error
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err := db.Create(&chain).Error; err != nil {
+				return err
+			}
+			// is_active defaults to true, so a false value is only kept by an update
+			if err := db.Model(&chain).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260321090000: %v", err)
+	}
+}
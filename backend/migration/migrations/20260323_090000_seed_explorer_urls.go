@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260323090000", func(db *gorm.DB) error {
+		// Explorer base URLs for the seeded EVM chains; ones an admin already set are kept
+		explorers := map[int64]string{
+			1:     "https://etherscan.io",
+			137:   "https://polygonscan.com",
+			8453:  "https://basescan.org",
+			42161: "https://arbiscan.io",
+		}
+		for chainID, url := range explorers {
+			if err := db.Model(&models.SupportedChain{}).
+				Where("chain_id = ? AND (explorer_url IS NULL OR explorer_url = '')", chainID).
+				Update("explorer_url", url).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260323090000: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+
+	"gorm.io/gorm"
+)
+
+// marketSearchStatements add the full-text search column to markets, keep
+// it current with a trigger, backfill it and index it, along with a trigram
+// index on titles for typo-tolerant matching. Titles weigh more than
+// descriptions in the ranking.
+var marketSearchStatements = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`ALTER TABLE markets ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`CREATE OR REPLACE FUNCTION markets_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector :=
+		setweight(to_tsvector('english', coalesce(NEW.question_title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(NEW.description, '')), 'B');
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS markets_search_vector_trigger ON markets`,
+	`CREATE TRIGGER markets_search_vector_trigger
+	BEFORE INSERT OR UPDATE OF question_title, description ON markets
+	FOR EACH ROW EXECUTE PROCEDURE markets_search_vector_update()`,
+	`UPDATE markets SET search_vector =
+		setweight(to_tsvector('english', coalesce(question_title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B')`,
+	`CREATE INDEX IF NOT EXISTS idx_markets_search_vector ON markets USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_markets_question_title_trgm ON markets USING GIN (question_title gin_trgm_ops)`,
+}
+
+// MigrateMarketSearch sets up Postgres full-text search over markets. Other
+// databases have no tsvector or triggers in this form and keep searching
+// with LIKE, so the migration does nothing there.
+func MigrateMarketSearch(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range marketSearchStatements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func init() {
+	err := migration.Register("20260426090000", MigrateMarketSearch)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260426090000: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260514090000", func(db *gorm.DB) error {
+		return db.AutoMigrate(&models.Market{}, &models.Group{}, &models.GroupMember{}, &models.GroupInvite{})
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260514090000: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+
+	"gorm.io/gorm"
+)
+
+// withdrawalListIndexStatements index the admin withdrawal list's orderings:
+// newest first, optionally within one status, and riskiest first
+var withdrawalListIndexStatements = []string{
+	`CREATE INDEX IF NOT EXISTS idx_withdrawal_requests_created_at ON withdrawal_requests (created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_withdrawal_requests_status_created_at ON withdrawal_requests (status, created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_withdrawal_requests_risk_created_at ON withdrawal_requests (risk_score, created_at)`,
+}
+
+// MigrateWithdrawalListIndexes adds the withdrawal list indexes
+func MigrateWithdrawalListIndexes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range withdrawalListIndexStatements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func init() {
+	err := migration.Register("20260520090000", MigrateWithdrawalListIndexes)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260520090000: %v", err)
+	}
+}
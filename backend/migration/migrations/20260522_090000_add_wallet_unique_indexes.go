@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+
+	"gorm.io/gorm"
+)
+
+// walletUniqueIndex is a partial unique index enforcing an idempotency rule
+// the application otherwise only checks before inserting. Soft-deleted rows
+// are left out so a deleted record does not block its replacement.
+type walletUniqueIndex struct {
+	name    string
+	table   string
+	columns string
+	where   string
+}
+
+var walletUniqueIndexes = []walletUniqueIndex{
+	// One active deposit wallet per user and chain; rotated-out wallets are kept inactive
+	{"idx_wallets_user_chain_active", "wallets", "user_id, chain_id", "is_active AND deleted_at IS NULL"},
+	// A DFNS transfer is recorded once
+	{"idx_crypto_transactions_dfns_tx_id", "crypto_transactions", "dfns_tx_id", "dfns_tx_id <> '' AND deleted_at IS NULL"},
+	// An on-chain transfer is recorded once; a batched transaction's transfers
+	// to different addresses are each recorded
+	{"idx_crypto_transactions_chain_tx_transfer", "crypto_transactions", "chain_id, tx_hash, type, to_address", "tx_hash <> '' AND deleted_at IS NULL"},
+}
+
+// MigrateWalletUniqueIndexes adds the unique indexes. Existing duplicates
+// fail the migration rather than being removed, since deciding which of two
+// wallets or deposits is genuine needs a person.
+func MigrateWalletUniqueIndexes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return createUniqueIndexes(tx, walletUniqueIndexes)
+	})
+}
+
+// createUniqueIndexes creates each index, refusing when the table already
+// holds rows the index would reject
+func createUniqueIndexes(tx *gorm.DB, indexes []walletUniqueIndex) error {
+	for _, idx := range indexes {
+		var duplicates int64
+		err := tx.Raw(fmt.Sprintf(
+			`SELECT COUNT(*) FROM (SELECT 1 FROM %s WHERE %s GROUP BY %s HAVING COUNT(*) > 1) AS duplicates`,
+			idx.table, idx.where, idx.columns)).Scan(&duplicates).Error
+		if err != nil {
+			return err
+		}
+		if duplicates > 0 {
+			return fmt.Errorf("%s has %d duplicated (%s) values; resolve them before adding %s", idx.table, duplicates, idx.columns, idx.name)
+		}
+		if err := tx.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s) WHERE %s`,
+			idx.name, idx.table, idx.columns, idx.where)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	err := migration.Register("20260522090000", MigrateWalletUniqueIndexes)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260522090000: %v", err)
+	}
+}
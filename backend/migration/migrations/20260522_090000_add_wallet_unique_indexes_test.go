@@ -0,0 +1,83 @@
+package migrations_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"socialpredict/migration/migrations"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestWalletUniqueIndexes_EnforceIdempotency(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	active := models.Wallet{UserID: 1, DfnsWalletID: "wa-1", ChainID: 1, ChainName: "ethereum", Address: "0x1", IsActive: true}
+	if err := db.Create(&active).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	duplicate := models.Wallet{UserID: 1, DfnsWalletID: "wa-2", ChainID: 1, ChainName: "ethereum", Address: "0x2", IsActive: true}
+	if err := db.Create(&duplicate).Error; err == nil {
+		t.Fatal("second active wallet on the same chain was accepted")
+	}
+	// A retired wallet does not count, so rotation can add a new active one
+	if err := db.Model(&active).Update("is_active", false).Error; err != nil {
+		t.Fatalf("retire wallet: %v", err)
+	}
+	second := models.Wallet{UserID: 1, DfnsWalletID: "wa-3", ChainID: 1, ChainName: "ethereum", Address: "0x3", IsActive: true}
+	if err := db.Create(&second).Error; err != nil {
+		t.Fatalf("create wallet after rotation: %v", err)
+	}
+
+	deposit := models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xabc", ToAddress: "0x3", DfnsTxID: "xfer-1"}
+	if err := db.Create(&deposit).Error; err != nil {
+		t.Fatalf("create deposit: %v", err)
+	}
+	for _, dup := range []models.CryptoTransaction{
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xabc", ToAddress: "0x3", DfnsTxID: "xfer-2"},
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xdef", ToAddress: "0x3", DfnsTxID: "xfer-1"},
+	} {
+		if err := db.Create(&dup).Error; err == nil {
+			t.Fatalf("duplicate transaction %s/%s was accepted", dup.TxHash, dup.DfnsTxID)
+		}
+	}
+	// The same hash on another chain, other transfers batched into the same
+	// transaction, and withdrawals not yet broadcast, are fine
+	for _, ok := range []models.CryptoTransaction{
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 8453, TxHash: "0xabc", ToAddress: "0x3"},
+		{UserID: 2, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xabc", ToAddress: "0x4"},
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xabc", ToAddress: "0x3", LogIndex: 1},
+		{UserID: 1, Type: models.TxTypeWithdrawal, Status: models.TxStatusPending, ChainID: 1},
+		{UserID: 1, Type: models.TxTypeWithdrawal, Status: models.TxStatusPending, ChainID: 1},
+	} {
+		if err := db.Create(&ok).Error; err != nil {
+			t.Fatalf("create transaction: %v", err)
+		}
+	}
+
+	// A soft-deleted deposit no longer blocks its hash
+	db.Delete(&deposit)
+	again := models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xabc", ToAddress: "0x3", DfnsTxID: "xfer-1"}
+	if err := db.Create(&again).Error; err != nil {
+		t.Fatalf("recreate deleted deposit: %v", err)
+	}
+}
+
+func TestWalletUniqueIndexes_RefuseExistingDuplicates(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	if err := db.Exec(`DROP INDEX idx_wallets_user_chain_active`).Error; err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	for i, address := range []string{"0x1", "0x2"} {
+		wallet := models.Wallet{UserID: 1, DfnsWalletID: fmt.Sprintf("wa-%d", i), ChainID: 1, ChainName: "ethereum", Address: address, IsActive: true}
+		if err := db.Create(&wallet).Error; err != nil {
+			t.Fatalf("create wallet: %v", err)
+		}
+	}
+
+	err := migrations.MigrateWalletUniqueIndexes(db)
+	if err == nil || !strings.Contains(err.Error(), "idx_wallets_user_chain_active") {
+		t.Fatalf("err = %v, want duplicates reported", err)
+	}
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260524090000", func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&models.WithdrawalRequest{}); err != nil {
+			return err
+		}
+		// The withdrawal saga gained a reserve step in front of the transfer,
+		// so unfinished instances move one step on to stay on the same step
+		return db.Model(&models.SagaInstance{}).
+			Where("name = ? AND status IN ?", "withdrawal",
+				[]string{models.SagaStatusWaiting, models.SagaStatusFailed, models.SagaStatusCompensating}).
+			Update("current_step", gorm.Expr("current_step + 1")).Error
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260524090000: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func init() {
+	err := migration.Register("20260526090000", func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&models.Role{}, &models.UserRole{}); err != nil {
+			return err
+		}
+		builtIn := models.BuiltInRoles()
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&builtIn).Error; err != nil {
+			return err
+		}
+
+		// Existing admins keep every permission they had
+		var superadmin models.Role
+		if err := db.Where("name = ?", models.RoleSuperAdmin).First(&superadmin).Error; err != nil {
+			return err
+		}
+		var adminIDs []int64
+		if err := db.Model(&models.User{}).Where("user_type = ?", "ADMIN").Pluck("id", &adminIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range adminIDs {
+			assignment := models.UserRole{UserID: id, RoleID: superadmin.ID, GrantedBy: "migration"}
+			if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&assignment).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260526090000: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// legacySupportedChain is supported_chains as it was before token contracts
+// moved to chain_tokens. Earlier migrations create and seed it.
+type legacySupportedChain struct {
+	gorm.Model
+	ChainID          int64  `gorm:"unique;not null"`
+	Name             string `gorm:"not null"`
+	DisplayName      string `gorm:"not null"`
+	RpcURL           string
+	ExplorerURL      string
+	USDCAddress      string
+	USDTAddress      string
+	MinConfirmations int  `gorm:"default:12"`
+	IsActive         bool `gorm:"default:true"`
+	IconURL          string
+}
+
+func (legacySupportedChain) TableName() string {
+	return "supported_chains"
+}
+
+func init() {
+	err := migration.Register("20260528090000", func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&models.ChainToken{}); err != nil {
+			return err
+		}
+		if !db.Migrator().HasColumn(&legacySupportedChain{}, "usdc_address") {
+			return nil
+		}
+
+		var chains []legacySupportedChain
+		if err := db.Find(&chains).Error; err != nil {
+			return err
+		}
+		var tokens []models.SupportedToken
+		if err := db.Find(&tokens).Error; err != nil {
+			return err
+		}
+		tokenIDs := make(map[string]uint, len(tokens))
+		for _, token := range tokens {
+			tokenIDs[token.Symbol] = token.ID
+		}
+
+		for _, chain := range chains {
+			for symbol, contract := range map[string]string{"USDC": chain.USDCAddress, "USDT": chain.USDTAddress} {
+				if contract == "" || tokenIDs[symbol] == 0 {
+					continue
+				}
+				token := models.ChainToken{
+					SupportedChainID: chain.ID,
+					SupportedTokenID: tokenIDs[symbol],
+					ContractAddress:  contract,
+					Decimals:         6,
+					IsActive:         true,
+				}
+				if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&token).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, column := range []string{"usdc_address", "usdt_address"} {
+			if err := db.Migrator().DropColumn(&legacySupportedChain{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260528090000: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260530090000", func(db *gorm.DB) error {
+		// Existing tokens start with no minimum deposit
+		return db.AutoMigrate(&models.ChainToken{})
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260530090000: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"log"
+	"time"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateWithdrawalNotes replaces the single admin note on withdrawal
+// requests with a thread of notes. An existing note becomes the first note of
+// its request's thread, by the admin who processed it where one is recorded,
+// dated when the request was processed.
+func MigrateWithdrawalNotes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.WithdrawalNote{}); err != nil {
+			return err
+		}
+		if !tx.Migrator().HasColumn(&models.WithdrawalRequest{}, "admin_note") {
+			return nil
+		}
+
+		var rows []struct {
+			ID          uint
+			AdminID     *int64
+			AdminNote   string
+			Username    string
+			ProcessedAt *time.Time
+			UpdatedAt   time.Time
+		}
+		if err := tx.Table("withdrawal_requests").
+			Select("withdrawal_requests.id, withdrawal_requests.admin_id, withdrawal_requests.admin_note, COALESCE(users.username, '') AS username, " +
+				"withdrawal_requests.processed_at, withdrawal_requests.updated_at").
+			Joins("LEFT JOIN users ON users.id = withdrawal_requests.admin_id").
+			Where("withdrawal_requests.admin_note <> ''").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			author := row.Username
+			if author == "" {
+				author = "admin"
+			}
+			notedAt := row.UpdatedAt
+			if row.ProcessedAt != nil {
+				notedAt = *row.ProcessedAt
+			}
+			note := models.WithdrawalNote{WithdrawalID: row.ID, AdminID: row.AdminID, Author: author, Body: row.AdminNote, CreatedAt: notedAt}
+			if err := tx.Create(&note).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&models.WithdrawalRequest{}, "admin_note")
+	})
+}
+
+func init() {
+	err := migration.Register("20260609090000", MigrateWithdrawalNotes)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260609090000: %v", err)
+	}
+}
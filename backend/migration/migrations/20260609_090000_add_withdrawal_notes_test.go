@@ -0,0 +1,44 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"socialpredict/migration/migrations"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestMigrateWithdrawalNotes_MovesAdminNotes(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	admin := modelstesting.GenerateUser("reviewer", 0)
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	// Simulate the old schema's single note column
+	if err := db.Exec("ALTER TABLE `withdrawal_requests` ADD COLUMN `admin_note` text").Error; err != nil {
+		t.Fatalf("add admin_note: %v", err)
+	}
+	noted := models.WithdrawalRequest{UserID: 99, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC", Amount: 1,
+		ToAddress: "0xabc", Status: models.TxStatusRejected, AdminID: &admin.ID}
+	plain := noted
+	for _, wr := range []*models.WithdrawalRequest{&noted, &plain} {
+		if err := db.Create(wr).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+	db.Exec("UPDATE withdrawal_requests SET admin_note = ? WHERE id = ?", "Address on a watch list", noted.ID)
+
+	if err := migrations.MigrateWithdrawalNotes(db); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	if db.Migrator().HasColumn(&models.WithdrawalRequest{}, "admin_note") {
+		t.Fatalf("expected admin_note to be dropped")
+	}
+	var notes []models.WithdrawalNote
+	db.Find(&notes)
+	if len(notes) != 1 || notes[0].WithdrawalID != noted.ID || notes[0].Author != "reviewer" || notes[0].Body != "Address on a watch list" {
+		t.Fatalf("notes = %+v", notes)
+	}
+}
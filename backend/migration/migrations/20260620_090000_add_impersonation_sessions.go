@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"errors"
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260620090000", func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&models.ImpersonationSession{}); err != nil {
+			return err
+		}
+
+		// Support staff handle the tickets impersonation is for
+		var support models.Role
+		err := db.Where("name = ?", models.RoleSupport).First(&support).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if support.Grants(models.PermUsersImpersonate) {
+			return nil
+		}
+		support.Permissions = append(support.Permissions, models.PermUsersImpersonate)
+		return db.Save(&support).Error
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260620090000: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260623090000", func(db *gorm.DB) error {
+		// Existing markets, series and leaderboards belong to the default community, tenant 0
+		return db.AutoMigrate(&models.Tenant{}, &models.Market{}, &models.MarketSeries{}, &models.LeaderboardSnapshot{})
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260623090000: %v", err)
+	}
+}
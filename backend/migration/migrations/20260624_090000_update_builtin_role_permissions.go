@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	err := migration.Register("20260624090000", func(db *gorm.DB) error {
+		// Finance admins review held deposits and balance corrections, which
+		// now need their own permissions
+		for _, role := range models.BuiltInRoles() {
+			if err := db.Model(&models.Role{}).Where("name = ?", role.Name).
+				Updates(models.Role{Description: role.Description, Permissions: role.Permissions}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260624090000: %v", err)
+	}
+}
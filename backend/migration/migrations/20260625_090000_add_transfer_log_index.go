@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// An on-chain transfer is recorded once; a transaction's transfers are told
+// apart by their log index, so two transfers to the same address in one
+// transaction are each recorded
+var transferLogIndex = walletUniqueIndex{"idx_crypto_transactions_chain_tx_log", "crypto_transactions", "chain_id, tx_hash, type, to_address, log_index", "tx_hash <> '' AND deleted_at IS NULL"}
+
+// MigrateTransferLogIndex adds the log index column and replaces the
+// recorded-transfer unique index with one keyed on it. Existing records get
+// log index 0.
+func MigrateTransferLogIndex(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.CryptoTransaction{}); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := createUniqueIndexes(tx, []walletUniqueIndex{transferLogIndex}); err != nil {
+			return err
+		}
+		return tx.Exec(`DROP INDEX IF EXISTS idx_crypto_transactions_chain_tx_transfer`).Error
+	})
+}
+
+func init() {
+	err := migration.Register("20260625090000", MigrateTransferLogIndex)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260625090000: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package migrations_test
+
+import (
+	"strings"
+	"testing"
+
+	"socialpredict/migration/migrations"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestTransferLogIndex_RefuseExistingDuplicates(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	if err := db.Exec(`DROP INDEX idx_crypto_transactions_chain_tx_log`).Error; err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		tx := models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 1, TxHash: "0xabc", ToAddress: "0x1"}
+		if err := db.Create(&tx).Error; err != nil {
+			t.Fatalf("create deposit: %v", err)
+		}
+	}
+
+	err := migrations.MigrateTransferLogIndex(db)
+	if err == nil || !strings.Contains(err.Error(), "idx_crypto_transactions_chain_tx_log") {
+		t.Fatalf("err = %v, want duplicates reported", err)
+	}
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"log"
+
+	"socialpredict/logger"
+	"socialpredict/migration"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateMicroCreditProvisionalBalance rescales provisional betting
+// allowances from whole credits to micro-credits, like the other balances.
+func MigrateMicroCreditProvisionalBalance(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.User{}).
+			Where("provisional_balance <> 0").
+			UpdateColumn("provisional_balance", gorm.Expr("provisional_balance * ?", models.MicroCreditsPerCredit)).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.ProvisionalCredit{}).
+			Where("1 = 1").
+			UpdateColumn("amount", gorm.Expr("amount * ?", models.MicroCreditsPerCredit)).Error
+	})
+}
+
+func init() {
+	err := migration.Register("20260626090000", MigrateMicroCreditProvisionalBalance)
+	if err != nil {
+		logger.LogError("migrations", "init", err)
+		log.Fatalf("Failed to register migration 20260626090000: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"socialpredict/migration/migrations"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestMicroCreditProvisionalBalance(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 0)
+	db.Create(&user)
+	db.Model(&user).Update("provisional_balance", 25)
+	db.Create(&models.ProvisionalCredit{UserID: user.ID, CryptoTransactionID: 1, Amount: 25, Status: models.ProvisionalStatusActive})
+
+	if err := migrations.MigrateMicroCreditProvisionalBalance(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	db.First(&user, user.ID)
+	var credit models.ProvisionalCredit
+	db.First(&credit)
+	if user.ProvisionalBalance != models.CreditsToMicro(25) || credit.Amount != models.CreditsToMicro(25) {
+		t.Fatalf("provisional balance %d, allowance %d; want 25 credits in micro-credits", user.ProvisionalBalance, credit.Amount)
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Address label kinds
+const (
+	AddressKindExchange = "EXCHANGE" // Exchange hot or deposit wallet
+	AddressKindMixer    = "MIXER"    // Mixing service
+	AddressKindContract = "CONTRACT" // Smart contract
+	AddressKindUser     = "USER"     // Known individual
+)
+
+// Address label sources
+const (
+	AddressLabelManual    = "MANUAL"    // Set by an admin
+	AddressLabelAPI       = "API"       // Returned by the labeling API
+	AddressLabelHeuristic = "HEURISTIC" // Inferred from deposit patterns
+)
+
+// AddressLabel says who is behind an on-chain address, shown next to deposit
+// sources and withdrawal destinations in admin views and used in withdrawal
+// risk scoring. A label with no chain applies on every chain.
+type AddressLabel struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	ChainName string    `json:"chainName" gorm:"uniqueIndex:idx_address_label;not null;default:''"`
+	Address   string    `json:"address" gorm:"uniqueIndex:idx_address_label;not null"` // Lowercased for EVM addresses
+	Kind      string    `json:"kind" gorm:"index;not null"`
+	Name      string    `json:"name"`
+	Source    string    `json:"source" gorm:"not null"`
+	CreatedBy string    `json:"createdBy,omitempty"` // Admin username for manual labels
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for AddressLabel
+func (AddressLabel) TableName() string {
+	return "address_labels"
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API key scopes
+const (
+	APIKeyScopeRead  = "read"  // Read markets, bets, positions and price history
+	APIKeyScopeTrade = "trade" // Place and sell bets; implies read
+)
+
+// APIKey lets a program act as a user without a login session. Only a hash
+// of the key is stored; the key itself is shown once, when it is issued.
+type APIKey struct {
+	gorm.Model
+	ID                 uint       `json:"id" gorm:"primary_key"`
+	UserID             int64      `json:"userId" gorm:"index;not null"`
+	Name               string     `json:"name" gorm:"not null"`
+	Prefix             string     `json:"prefix" gorm:"not null"` // Start of the key, to tell keys apart
+	KeyHash            string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes             string     `json:"scopes" gorm:"not null"` // Comma-separated
+	RateLimitPerMinute int        `json:"rateLimitPerMinute" gorm:"not null"`
+	LastUsedAt         *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt          *time.Time `json:"revokedAt,omitempty"`
+	RotatedFromID      *uint      `json:"rotatedFromId,omitempty"` // The key this one replaced
+}
+
+// TableName specifies the table name for APIKey
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if s == scope || (s == APIKeyScopeTrade && scope == APIKeyScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRevoked reports whether the key has been revoked or rotated out
+func (k APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attestation status constants
+const (
+	AttestationStatusBroadcast = "BROADCAST"
+	AttestationStatusFailed    = "FAILED"
+)
+
+// ResolutionAttestation anchors a market's resolution outcome and evidence hash on-chain
+type ResolutionAttestation struct {
+	gorm.Model
+	ID             uint      `json:"id" gorm:"primary_key"`
+	MarketID       int64     `json:"marketId" gorm:"uniqueIndex;not null"`
+	Outcome        string    `json:"outcome" gorm:"not null"`
+	ResolvedAt     time.Time `json:"resolvedAt"`
+	EvidenceURL    string    `json:"evidenceUrl"`
+	EvidenceHash   string    `json:"evidenceHash" gorm:"not null"`   // sha256 of the evidence document, hex
+	CommitmentHash string    `json:"commitmentHash" gorm:"not null"` // sha256 of the anchored payload, hex
+	ChainName      string    `json:"chainName" gorm:"not null"`
+	DfnsWalletID   string    `json:"dfnsWalletId"`
+	DfnsTxID       string    `json:"dfnsTxId" gorm:"index"`
+	TxHash         string    `json:"txHash"`
+	Status         string    `json:"status" gorm:"not null"`
+	ErrorMessage   string    `json:"errorMessage"`
+	AnchoredBy     string    `json:"anchoredBy"` // Admin username
+}
+
+// TableName specifies the table name for ResolutionAttestation
+func (ResolutionAttestation) TableName() string {
+	return "resolution_attestations"
+}
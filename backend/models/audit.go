@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// AuditLog records an administrative action for later review
+type AuditLog struct {
+	gorm.Model
+	ID         uint   `json:"id" gorm:"primary_key"`
+	Actor      string `json:"actor" gorm:"index;not null"` // Username of the admin who acted
+	Action     string `json:"action" gorm:"index;not null"`
+	TargetType string `json:"targetType" gorm:"index:idx_audit_target"`
+	TargetID   uint   `json:"targetId" gorm:"index:idx_audit_target"`
+	CaseID     string `json:"caseId,omitempty" gorm:"index"`
+	Details    string `json:"details"`
+}
+
+// TableName specifies the table name for AuditLog
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
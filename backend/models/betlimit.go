@@ -0,0 +1,38 @@
+package models
+
+import "gorm.io/gorm"
+
+// MarketBetLimit caps betting on one market, so a thin market cannot take on
+// more risk than its liquidity supports. A zero cap is not enforced.
+type MarketBetLimit struct {
+	gorm.Model
+	ID          uint   `json:"id" gorm:"primary_key"`
+	MarketID    int64  `json:"marketId" gorm:"uniqueIndex;not null"`
+	MaxBet      int64  `json:"maxBet"`      // Whole credits per bet
+	MaxExposure int64  `json:"maxExposure"` // Whole credits one user may have staked on the market
+	UpdatedBy   string `json:"updatedBy"`
+}
+
+// TableName specifies the table name for MarketBetLimit
+func (MarketBetLimit) TableName() string {
+	return "market_bet_limits"
+}
+
+// BetLimitOverride replaces the market caps for one user, on one market or on
+// every market when MarketID is 0, e.g. to let a known large trader bet more.
+// A zero cap lifts that cap for the user.
+type BetLimitOverride struct {
+	gorm.Model
+	ID          uint   `json:"id" gorm:"primary_key"`
+	UserID      int64  `json:"userId" gorm:"uniqueIndex:idx_bet_limit_override;not null"`
+	MarketID    int64  `json:"marketId" gorm:"uniqueIndex:idx_bet_limit_override;not null;default:0"`
+	MaxBet      int64  `json:"maxBet"`      // Whole credits per bet
+	MaxExposure int64  `json:"maxExposure"` // Whole credits staked on one market
+	Reason      string `json:"reason"`
+	UpdatedBy   string `json:"updatedBy"`
+}
+
+// TableName specifies the table name for BetLimitOverride
+func (BetLimitOverride) TableName() string {
+	return "bet_limit_overrides"
+}
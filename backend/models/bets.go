@@ -8,19 +8,31 @@ import (
 
 type Bet struct {
 	gorm.Model
-	Action   string    `json:"action"`
-	ID       uint      `json:"id" gorm:"primary_key"`
-	Username string    `json:"username"`
-	User     User      `gorm:"foreignKey:Username;references:Username"`
-	MarketID uint      `json:"marketId"`
-	Market   Market    `gorm:"foreignKey:ID;references:MarketID"`
-	Amount   int64     `json:"amount"`
-	PlacedAt time.Time `json:"placedAt"`
-	Outcome  string    `json:"outcome,omitempty"`
+	Action      string    `json:"action"`
+	ID          uint      `json:"id" gorm:"primary_key"`
+	Username    string    `json:"username"`
+	User        User      `gorm:"foreignKey:Username;references:Username"`
+	MarketID    uint      `json:"marketId"`
+	Market      Market    `gorm:"foreignKey:ID;references:MarketID"`
+	Amount      int64     `json:"amount"`
+	PlacedAt    time.Time `json:"placedAt"`
+	Outcome     string    `json:"outcome,omitempty"`
+	Proceeds    int64     `json:"proceeds,omitempty"`    // Credits paid out for a sale; zero for purchases
+	Probability float64   `json:"probability,omitempty"` // Market probability of Outcome just after a purchase; zero for sales and older bets
 }
 
 type Bets []Bet
 
+// Spend returns what the bet cost its user in credits: the amount for a
+// purchase, and minus the proceeds for a sale. Sales made before proceeds
+// were recorded fall back to their share amount.
+func (b Bet) Spend() int64 {
+	if b.Amount < 0 && b.Proceeds > 0 {
+		return -b.Proceeds
+	}
+	return b.Amount
+}
+
 // getMarketUsers returns the number of unique users for a given market
 func GetNumMarketUsers(bets []Bet) int {
 	userMap := make(map[string]bool)
@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// BonusGrant is promotional credit, such as a sign-up bonus, given to a user.
+// It can be bet with but not withdrawn; see User.BonusBalance.
+type BonusGrant struct {
+	gorm.Model
+	ID        uint   `json:"id" gorm:"primary_key"`
+	UserID    int64  `json:"userId" gorm:"index;not null"`
+	Amount    int64  `json:"amount" gorm:"not null"` // Micro-credits
+	Campaign  string `json:"campaign" gorm:"index"`  // e.g. SIGNUP, so grants can be totalled per promotion
+	Reason    string `json:"reason"`
+	GrantedBy string `json:"grantedBy" gorm:"not null"` // Admin username
+}
+
+// TableName specifies the table name for BonusGrant
+func (BonusGrant) TableName() string {
+	return "bonus_grants"
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ChainScanCursor records the last block the chain scanner has processed for
+// a chain, so scanning resumes where it stopped after a restart
+type ChainScanCursor struct {
+	ChainName string    `json:"chainName" gorm:"primaryKey"`
+	LastBlock int64     `json:"lastBlock" gorm:"not null"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// MarketComment is a user's comment on a market
+type MarketComment struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	MarketID  int64     `json:"marketId" gorm:"index:idx_market_comment_time,priority:1;not null"`
+	UserID    int64     `json:"-" gorm:"not null"`
+	Username  string    `json:"username" gorm:"not null"`
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index:idx_market_comment_time,priority:2;not null"`
+}
+
+// TableName specifies the table name for MarketComment
+func (MarketComment) TableName() string {
+	return "market_comments"
+}
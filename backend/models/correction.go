@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Balance correction status constants
+const (
+	CorrectionStatusPendingApproval = "PENDING_APPROVAL"
+	CorrectionStatusCompleted       = "COMPLETED"
+	CorrectionStatusRejected        = "REJECTED"
+)
+
+// BalanceCorrection is an admin-initiated transfer of credits between two
+// users, used to resolve disputes such as a mistaken internal transfer
+type BalanceCorrection struct {
+	gorm.Model
+	ID          uint       `json:"id" gorm:"primary_key"`
+	FromUserID  int64      `json:"fromUserId" gorm:"index;not null"`
+	ToUserID    int64      `json:"toUserId" gorm:"index;not null"`
+	Amount      int64      `json:"amount" gorm:"not null"` // Micro-credits
+	Reason      string     `json:"reason" gorm:"not null"`
+	CaseID      string     `json:"caseId" gorm:"index"` // Support case reference
+	Status      string     `json:"status" gorm:"index;not null"`
+	RequestedBy string     `json:"requestedBy" gorm:"not null"` // Admin username
+	ApprovedBy  string     `json:"approvedBy"`                  // Second admin, for corrections above the threshold
+	ReviewNote  string     `json:"reviewNote"`
+	ProcessedAt *time.Time `json:"processedAt"`
+}
+
+// TableName specifies the table name for BalanceCorrection
+func (BalanceCorrection) TableName() string {
+	return "balance_corrections"
+}
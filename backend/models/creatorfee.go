@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Creator fee accrual statuses
+const (
+	CreatorFeeStatusAccruing  = "ACCRUING"  // Market still trading; fees build up
+	CreatorFeeStatusSettled   = "SETTLED"   // Paid to the creator when the market resolved
+	CreatorFeeStatusForfeited = "FORFEITED" // Market voided; the creator gets nothing
+)
+
+// MarketCreatorFee accumulates the creator fees charged on a market's trades
+// until the market resolves. Amounts are in micro-credits.
+type MarketCreatorFee struct {
+	gorm.Model
+	ID             uint       `json:"id" gorm:"primary_key"`
+	MarketID       int64      `json:"marketId" gorm:"uniqueIndex;not null"`
+	CreatorID      int64      `json:"creatorId" gorm:"index;not null"`
+	Volume         int64      `json:"volume"`         // Trade volume the fees were charged on
+	CreatorAmount  int64      `json:"creatorAmount"`  // Creator's share of the fees
+	PlatformAmount int64      `json:"platformAmount"` // Platform's share of the fees
+	Status         string     `json:"status" gorm:"index;not null"`
+	SettledAt      *time.Time `json:"settledAt,omitempty"`
+}
+
+// TableName specifies the table name for MarketCreatorFee
+func (MarketCreatorFee) TableName() string {
+	return "market_creator_fees"
+}
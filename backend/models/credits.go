@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MicroCreditsPerCredit is the precision used for crypto-backed amounts.
+// Stablecoins have 6 decimals, so one micro-credit maps to one base unit of
+// USDC/USDT and deposits never lose value to integer truncation.
+const MicroCreditsPerCredit int64 = 1_000_000
+
+// ErrInvalidCreditAmount is returned when a credit amount cannot be parsed.
+var ErrInvalidCreditAmount = errors.New("invalid credit amount")
+
+// CreditsToMicro converts whole credits to micro-credits.
+func CreditsToMicro(credits int64) int64 {
+	return credits * MicroCreditsPerCredit
+}
+
+// MicroToWholeCredits splits micro-credits into whole credits and the
+// non-negative remainder, flooring towards negative infinity so that
+// negative balances (allowed up to the maximum debt) stay consistent.
+func MicroToWholeCredits(micro int64) (whole int64, remainder int64) {
+	whole = micro / MicroCreditsPerCredit
+	remainder = micro % MicroCreditsPerCredit
+	if remainder < 0 {
+		whole--
+		remainder += MicroCreditsPerCredit
+	}
+	return whole, remainder
+}
+
+// DisplayCredits rounds micro-credits to two decimal places for API responses.
+func DisplayCredits(micro int64) float64 {
+	return math.Round(float64(micro)/float64(MicroCreditsPerCredit)*100) / 100
+}
+
+// FormatMicroCredits renders micro-credits as a decimal string with trailing
+// zeros trimmed, e.g. 10750000 -> "10.75".
+func FormatMicroCredits(micro int64) string {
+	sign := ""
+	if micro < 0 {
+		sign = "-"
+		micro = -micro
+	}
+	whole := micro / MicroCreditsPerCredit
+	frac := micro % MicroCreditsPerCredit
+	if frac == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	fracStr := strings.TrimRight(fmt.Sprintf("%06d", frac), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, fracStr)
+}
+
+// ParseCredits parses a decimal credit amount such as "10.75" into
+// micro-credits. At most six decimal places are accepted.
+func ParseCredits(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidCreditAmount
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	wholeStr, fracStr, hasFrac := strings.Cut(s, ".")
+	if wholeStr == "" || (hasFrac && fracStr == "") || len(fracStr) > 6 {
+		return 0, ErrInvalidCreditAmount
+	}
+	for _, r := range wholeStr + fracStr {
+		if r < '0' || r > '9' {
+			return 0, ErrInvalidCreditAmount
+		}
+	}
+	if len(wholeStr) > 12 {
+		return 0, ErrInvalidCreditAmount
+	}
+
+	whole, err := strconv.ParseInt(wholeStr, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCreditAmount
+	}
+	var frac int64
+	if fracStr != "" {
+		frac, err = strconv.ParseInt(fracStr+strings.Repeat("0", 6-len(fracStr)), 10, 64)
+		if err != nil {
+			return 0, ErrInvalidCreditAmount
+		}
+	}
+
+	micro := whole*MicroCreditsPerCredit + frac
+	if negative {
+		micro = -micro
+	}
+	return micro, nil
+}
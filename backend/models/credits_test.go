@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestParseCredits(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "10", want: 10_000_000},
+		{in: "10.75", want: 10_750_000},
+		{in: "0.000001", want: 1},
+		{in: "007.5", want: 7_500_000},
+		{in: "-2.5", want: -2_500_000},
+		{in: "1.0000001", wantErr: true},
+		{in: "1.", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCredits(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseCredits(%q): expected error, got %d", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseCredits(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatAndDisplayMicroCredits(t *testing.T) {
+	if got := FormatMicroCredits(10_750_000); got != "10.75" {
+		t.Errorf("expected 10.75, got %s", got)
+	}
+	if got := FormatMicroCredits(-1_000_001); got != "-1.000001" {
+		t.Errorf("expected -1.000001, got %s", got)
+	}
+	if got := DisplayCredits(10_756_000); got != 10.76 {
+		t.Errorf("expected 10.76, got %v", got)
+	}
+}
+
+func TestUserBalanceIsMicroCredits(t *testing.T) {
+	u := User{PublicUser: PublicUser{AccountBalance: CreditsToMicro(5)}}
+
+	u.AddMicroCredits(10_750_000)
+	if u.AccountBalance != 15_750_000 || u.WholeCredits() != 15 {
+		t.Fatalf("after deposit got %d micro, %d whole", u.AccountBalance, u.WholeCredits())
+	}
+
+	u.AddMicroCredits(-16_000_000)
+	if u.BalanceMicroCredits() != -250_000 {
+		t.Fatalf("expected -250000 micro, got %d", u.BalanceMicroCredits())
+	}
+	if u.WholeCredits() != -1 {
+		t.Fatalf("negative balances should floor, got %d whole", u.WholeCredits())
+	}
+}
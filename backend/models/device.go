@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// HoldReasonNewDevice is the HoldReason of a withdrawal held because it was
+// requested from a recently seen device. Such holds lift by themselves at
+// WithdrawalRequest.HoldUntil.
+const HoldReasonNewDevice = "New device"
+
+// UserDevice is a browser or app a user has logged in from, identified by a
+// random ID the client keeps and sends as X-Device-ID
+type UserDevice struct {
+	ID          uint       `json:"id" gorm:"primary_key"`
+	UserID      int64      `json:"userId" gorm:"uniqueIndex:idx_user_device;not null"`
+	DeviceKey   string     `json:"-" gorm:"uniqueIndex:idx_user_device;not null"`
+	UserAgent   string     `json:"userAgent"`
+	LastIP      string     `json:"lastIp"`
+	FirstSeenAt time.Time  `json:"firstSeenAt" gorm:"not null"`
+	LastSeenAt  time.Time  `json:"lastSeenAt" gorm:"not null"`
+	TrustedAt   *time.Time `json:"trustedAt,omitempty"` // Set by an admin to skip the new-device withdrawal hold
+	TrustedBy   string     `json:"trustedBy,omitempty"`
+}
+
+// UserSession is one login. Its ID is the jti claim of the session's token,
+// so revoking the session invalidates the token.
+type UserSession struct {
+	ID        string     `json:"id" gorm:"primary_key"`
+	UserID    int64      `json:"userId" gorm:"index;not null"`
+	DeviceID  uint       `json:"deviceId" gorm:"index;not null"`
+	UserAgent string     `json:"userAgent"`
+	IP        string     `json:"ip"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"not null"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
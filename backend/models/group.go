@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Market visibility levels
+const (
+	MarketVisibilityPublic   = "PUBLIC"   // Listed, and open to everyone
+	MarketVisibilityUnlisted = "UNLISTED" // Open to anyone with the link, but left out of lists and search
+	MarketVisibilityGroup    = "GROUP"    // Only members of the market's group can see it or bet
+)
+
+// Group member roles
+const (
+	GroupRoleOwner  = "OWNER" // Manages members and invites
+	GroupRoleMember = "MEMBER"
+)
+
+// Group is a private set of users who can see and bet in its group-only markets
+type Group struct {
+	gorm.Model
+	ID          uint   `json:"id" gorm:"primary_key"`
+	Name        string `json:"name" gorm:"not null"`
+	Description string `json:"description"`
+	OwnerID     int64  `json:"ownerId" gorm:"index;not null"`
+}
+
+// TableName specifies the table name for Group
+func (Group) TableName() string {
+	return "groups"
+}
+
+// GroupMember is a user's membership of a group
+type GroupMember struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	GroupID   uint      `json:"groupId" gorm:"uniqueIndex:idx_group_member;not null"`
+	UserID    int64     `json:"userId" gorm:"uniqueIndex:idx_group_member;index;not null"`
+	Role      string    `json:"role" gorm:"not null"`
+	CreatedAt time.Time `json:"joinedAt"`
+}
+
+// TableName specifies the table name for GroupMember
+func (GroupMember) TableName() string {
+	return "group_members"
+}
+
+// GroupInvite is a link that adds whoever opens it to a group until it
+// expires, runs out of uses or is revoked. Only the hash of its token is kept.
+type GroupInvite struct {
+	gorm.Model
+	ID        uint       `json:"id" gorm:"primary_key"`
+	GroupID   uint       `json:"groupId" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	CreatedBy int64      `json:"createdBy" gorm:"not null"`
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"not null"`
+	MaxUses   int        `json:"maxUses"` // Zero allows any number
+	Uses      int        `json:"uses"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// TableName specifies the table name for GroupInvite
+func (GroupInvite) TableName() string {
+	return "group_invites"
+}
+
+// ListedMarkets limits a market query to markets that appear in lists and search
+func ListedMarkets(db *gorm.DB) *gorm.DB {
+	return db.Where("visibility = ?", MarketVisibilityPublic)
+}
@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// HouseBotCategory configures the house liquidity bot for new markets in one
+// category. The row with an empty Category applies to markets in categories
+// without their own row, and to markets with no category.
+type HouseBotCategory struct {
+	gorm.Model
+	ID          uint    `json:"id" gorm:"primary_key"`
+	Category    string  `json:"category" gorm:"uniqueIndex;not null;default:''"`
+	Enabled     bool    `json:"enabled"`
+	OrderSize   int64   `json:"orderSize"`   // Whole credits the bot bids on each of YES and NO
+	Spread      float64 `json:"spread"`      // How far below the market price each bid rests
+	MaxExposure int64   `json:"maxExposure"` // Whole credits the bot may stake on one market
+	UpdatedBy   string  `json:"updatedBy"`
+}
+
+// TableName specifies the table name for HouseBotCategory
+func (HouseBotCategory) TableName() string {
+	return "house_bot_categories"
+}
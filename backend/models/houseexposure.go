@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HouseExposureSnapshot records the house market maker's position in one
+// market at a point in time, so exposure can be charted historically
+type HouseExposureSnapshot struct {
+	gorm.Model
+	ID            uint      `json:"id" gorm:"primary_key"`
+	SnapshotAt    time.Time `json:"snapshotAt" gorm:"index;not null"`
+	Username      string    `json:"username" gorm:"not null"`
+	MarketID      uint      `json:"marketId" gorm:"index;not null"`
+	YesShares     int64     `json:"yesShares"`
+	NoShares      int64     `json:"noShares"`
+	Value         int64     `json:"value"` // Current valuation, or payout once resolved
+	Cost          int64     `json:"cost"`  // Net amount spent in the market
+	RealizedPnL   int64     `json:"realizedPnl"`
+	UnrealizedPnL int64     `json:"unrealizedPnl"`
+	Resolved      bool      `json:"resolved"`
+}
+
+// TableName specifies the table name for HouseExposureSnapshot
+func (HouseExposureSnapshot) TableName() string {
+	return "house_exposure_snapshots"
+}
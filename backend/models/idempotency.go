@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// BetIdempotencyKey remembers the bet a client's Idempotency-Key placed, so
+// a retried request returns that bet instead of placing another. Keys are
+// scoped to the user who sent them.
+type BetIdempotencyKey struct {
+	ID          uint      `json:"-" gorm:"primary_key"`
+	UserID      int64     `json:"userId" gorm:"uniqueIndex:idx_bet_idempotency_key,priority:1;not null"`
+	Key         string    `json:"key" gorm:"column:idempotency_key;uniqueIndex:idx_bet_idempotency_key,priority:2;size:255;not null"`
+	RequestHash string    `json:"-" gorm:"not null"` // Tells a retry from a different request reusing the key
+	BetID       uint      `json:"betId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
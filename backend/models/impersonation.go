@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ImpersonationSession lets a support admin view the platform as a user does.
+// Its ID is the jti claim of the read-only token issued for it, so ending the
+// session invalidates the token.
+type ImpersonationSession struct {
+	ID            string     `json:"id" gorm:"primary_key"`
+	AdminID       int64      `json:"adminId" gorm:"index;not null"`
+	AdminUsername string     `json:"adminUsername" gorm:"not null"`
+	UserID        int64      `json:"userId" gorm:"index;not null"`
+	Username      string     `json:"username" gorm:"not null"`
+	Reason        string     `json:"reason" gorm:"not null"` // Usually a support ticket
+	CreatedAt     time.Time  `json:"createdAt"`
+	ExpiresAt     time.Time  `json:"expiresAt" gorm:"not null"`
+	EndedAt       *time.Time `json:"endedAt,omitempty"`
+	EndedBy       string     `json:"endedBy,omitempty"`
+}
+
+// TableName specifies the table name for ImpersonationSession
+func (ImpersonationSession) TableName() string {
+	return "impersonation_sessions"
+}
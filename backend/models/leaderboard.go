@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Leaderboard periods
+const (
+	LeaderboardAllTime = "ALL_TIME"
+	LeaderboardMonthly = "MONTHLY" // Markets resolved since the start of the calendar month, UTC
+	LeaderboardWeekly  = "WEEKLY"  // Markets resolved since Monday, UTC
+)
+
+// LeaderboardSnapshot is a user's standing on one leaderboard as computed by
+// the scheduled leaderboard job. Each run writes a full set of rows sharing
+// its ComputedAt; the newest set is served.
+type LeaderboardSnapshot struct {
+	ID              uint      `json:"-" gorm:"primary_key"`
+	Period          string    `json:"period" gorm:"index:idx_leaderboard_snapshot,priority:1;not null"`
+	ComputedAt      time.Time `json:"computedAt" gorm:"index:idx_leaderboard_snapshot,priority:2;not null"`
+	PeriodStart     time.Time `json:"periodStart"`              // Zero for the all-time board
+	TenantID        uint      `json:"-" gorm:"index;default:0"` // Community whose markets the standing covers
+	Username        string    `json:"username" gorm:"not null"`
+	RealizedProfit  int64     `json:"realizedProfit"`       // Credits won less credits spent in resolved markets
+	AmountWagered   int64     `json:"amountWagered"`        // Credits bet in those markets
+	ROI             float64   `json:"roi"`                  // RealizedProfit over AmountWagered
+	BrierScore      *float64  `json:"brierScore,omitempty"` // Mean Brier score of the user's bets; lower is better
+	Forecasts       int       `json:"forecasts"`            // Bets scored into BrierScore
+	ResolvedMarkets int       `json:"resolvedMarkets"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "gorm.io/gorm"
+
+// Ledger entry type constants
+const (
+	LedgerTypeCorrection     = "CORRECTION"
+	LedgerTypeInternalCredit = "INTERNAL_CREDIT" // Credit made by an internal service over gRPC
+	LedgerTypeDeposit        = "DEPOSIT"         // Crypto deposit credited to the user
+
+	LedgerTypeWithdrawal       = "WITHDRAWAL"        // Credits debited when a crypto withdrawal is requested
+	LedgerTypeWithdrawalRefund = "WITHDRAWAL_REFUND" // Withdrawal credits returned after it was rejected, failed, expired or reduced
+
+	LedgerTypeBet               = "BET"                 // Stake and fees paid for a bet
+	LedgerTypeMarketCreationFee = "MARKET_CREATION_FEE" // Fee paid to create a market
+
+	LedgerTypeOpeningBalance     = "OPENING_BALANCE"     // Balance carried over from before the ledger, written by the backfill
+	LedgerTypeHistoricalDeposit  = "HISTORICAL_DEPOSIT"  // Completed crypto deposit imported by the backfill
+	LedgerTypeHistoricalWithdraw = "HISTORICAL_WITHDRAW" // Completed crypto withdrawal imported by the backfill
+
+	LedgerTypeResolutionCost         = "RESOLUTION_COST"          // Platform expense for resolving a market
+	LedgerTypeResolutionCostRecovery = "RESOLUTION_COST_RECOVERY" // Part of a resolution cost covered by the market's fees
+
+	LedgerTypeTransferOut = "TRANSFER_OUT" // Credits sent to another user
+	LedgerTypeTransferIn  = "TRANSFER_IN"  // Credits received from another user
+
+	LedgerTypeBonusGrant = "BONUS_GRANT" // Promotional credit granted by an admin; not withdrawable until wagered
+
+	LedgerTypeMarketPayout = "MARKET_PAYOUT" // Winning position paid out when a market resolves
+	LedgerTypeMarketRefund = "MARKET_REFUND" // Stake refunded when a market is voided (resolves N/A)
+	LedgerTypeMarketSale   = "MARKET_SALE"   // Proceeds of shares sold back to the market before close
+
+	LedgerTypePayoutReversal = "PAYOUT_REVERSAL" // Held winnings taken back after a dispute was upheld
+
+	LedgerTypeGraceDeposit         = "GRACE_DEPOSIT"          // Deposit credited before its confirmations; not withdrawable until confirmed
+	LedgerTypeGraceDepositReversal = "GRACE_DEPOSIT_REVERSAL" // Grace-credited deposit taken back after it failed or was reorged out
+
+	LedgerTypeCreatorFee         = "CREATOR_FEE"          // Creator's share of a market's trading fees
+	LedgerTypeCreatorFeeReversal = "CREATOR_FEE_REVERSAL" // Creator fees taken back when their market is voided
+	LedgerTypePlatformFeeShare   = "PLATFORM_FEE_SHARE"   // Platform's share of a market's creator fees
+
+	LedgerTypeReferralReward         = "REFERRAL_REWARD"          // Referrer's share of a referred user's trading fees
+	LedgerTypeReferralRewardReversal = "REFERRAL_REWARD_REVERSAL" // Referral rewards taken back after an admin blocked the referral
+
+	LedgerTypeLiquidityAdd    = "LIQUIDITY_ADD"    // Credits escrowed into a market's liquidity pool
+	LedgerTypeLiquidityRemove = "LIQUIDITY_REMOVE" // Liquidity withdrawn from an open market
+	LedgerTypeLiquidityReturn = "LIQUIDITY_RETURN" // Liquidity returned, with its P&L, when a market resolves
+
+	LedgerTypeHouseBotFunding = "HOUSE_BOT_FUNDING" // Platform credits allocated to the house liquidity bot
+)
+
+// PlatformUserID is the UserID of ledger entries booked against the platform
+// itself rather than a user. BalanceAfter is not tracked for these entries.
+const PlatformUserID int64 = 0
+
+// LedgerEntry records a single change to a user's balance. Amounts are in
+// micro-credits; debits are negative.
+type LedgerEntry struct {
+	gorm.Model
+	ID            uint   `json:"id" gorm:"primary_key"`
+	UserID        int64  `json:"userId" gorm:"index;not null"`
+	Type          string `json:"type" gorm:"index;not null"`
+	Amount        int64  `json:"amount" gorm:"not null"`       // Signed micro-credits
+	BalanceAfter  int64  `json:"balanceAfter" gorm:"not null"` // User balance in micro-credits after this entry
+	ReferenceType string `json:"referenceType" gorm:"index:idx_ledger_reference"`
+	ReferenceID   uint   `json:"referenceId" gorm:"index:idx_ledger_reference"`
+	CaseID        string `json:"caseId,omitempty" gorm:"index"`   // Support case the entry relates to
+	MarketID      *int64 `json:"marketId,omitempty" gorm:"index"` // Market the entry settles, for payouts and refunds
+	// ExternalReference is the caller's reference for an internal credit. It
+	// is unique, so a replayed credit finds its entry instead of paying again.
+	ExternalReference *string `json:"externalReference,omitempty" gorm:"uniqueIndex;size:255"`
+	Description       string  `json:"description"`
+}
+
+// TableName specifies the table name for LedgerEntry
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MarketLiquidity is one user's stake in a market's liquidity pool. The
+// stake deepens the market like the initial subsidization does, anchored at
+// Probability, and is settled when the market resolves.
+type MarketLiquidity struct {
+	gorm.Model
+	ID          uint       `json:"id" gorm:"primary_key"`
+	MarketID    int64      `json:"marketId" gorm:"uniqueIndex:idx_market_liquidity_provider;not null"`
+	UserID      int64      `json:"userId" gorm:"uniqueIndex:idx_market_liquidity_provider;not null"`
+	Username    string     `json:"username" gorm:"not null"`
+	Stake       int64      `json:"stake" gorm:"not null"`       // Micro-credits currently provided
+	Probability float64    `json:"probability" gorm:"not null"` // Stake-weighted YES probability at which the stake was added
+	Payout      int64      `json:"payout"`                      // Micro-credits returned at settlement
+	SettledAt   *time.Time `json:"settledAt,omitempty"`
+}
+
+// TableName specifies the table name for MarketLiquidity
+func (MarketLiquidity) TableName() string {
+	return "market_liquidity"
+}
+
+// LiquidityEvent is liquidity added to or withdrawn from a market. Events
+// are replayed with the market's bets to price it.
+type LiquidityEvent struct {
+	ID          uint      `json:"id" gorm:"primary_key"`
+	MarketID    int64     `json:"marketId" gorm:"index;not null"`
+	UserID      int64     `json:"userId" gorm:"not null"`
+	Amount      int64     `json:"amount" gorm:"not null"`      // Signed micro-credits; withdrawals are negative
+	Probability float64   `json:"probability" gorm:"not null"` // Market probability when the event happened
+	CreatedAt   time.Time `json:"createdAt" gorm:"index"`
+}
@@ -6,20 +6,81 @@ import (
 	"gorm.io/gorm"
 )
 
+// Market outcome types. A binary market resolves YES or NO; a categorical
+// market resolves to one of its MarketOutcome labels.
+const (
+	OutcomeTypeBinary      = "BINARY"
+	OutcomeTypeCategorical = "CATEGORICAL"
+)
+
 type Market struct {
 	gorm.Model
-	ID                      int64     `json:"id" gorm:"primary_key"`
-	QuestionTitle           string    `json:"questionTitle" gorm:"not null"`
-	Description             string    `json:"description" gorm:"not null"`
-	OutcomeType             string    `json:"outcomeType" gorm:"not null"`
-	ResolutionDateTime      time.Time `json:"resolutionDateTime" gorm:"not null"`
-	FinalResolutionDateTime time.Time `json:"finalResolutionDateTime"`
-	UTCOffset               int       `json:"utcOffset"`
-	IsResolved              bool      `json:"isResolved"`
-	ResolutionResult        string    `json:"resolutionResult"`
-	InitialProbability      float64   `json:"initialProbability" gorm:"not null"`
-	YesLabel                string    `json:"yesLabel" gorm:"default:YES"`
-	NoLabel                 string    `json:"noLabel" gorm:"default:NO"`
-	CreatorUsername         string    `json:"creatorUsername" gorm:"not null"`
-	Creator                 User      `gorm:"foreignKey:CreatorUsername;references:Username"`
+	ID                      int64      `json:"id" gorm:"primary_key"`
+	QuestionTitle           string     `json:"questionTitle" gorm:"not null"`
+	Description             string     `json:"description" gorm:"not null"`
+	OutcomeType             string     `json:"outcomeType" gorm:"not null"`
+	Category                string     `json:"category,omitempty" gorm:"index"` // Topic the creator filed the market under, lower case; optional
+	ResolutionDateTime      time.Time  `json:"resolutionDateTime" gorm:"not null"`
+	FinalResolutionDateTime time.Time  `json:"finalResolutionDateTime"`
+	UTCOffset               int        `json:"utcOffset"`
+	IsResolved              bool       `json:"isResolved"`
+	ResolutionResult        string     `json:"resolutionResult"`
+	InitialProbability      float64    `json:"initialProbability" gorm:"not null"`
+	MarketMaker             string     `json:"marketMaker" gorm:"not null;default:WPAM"` // Mechanism pricing the market, chosen at creation; see handlers/math/market
+	LiquidityParameter      float64    `json:"liquidityParameter,omitempty"`             // LMSR liquidity b in credits: higher moves the price less per bet
+	YesLabel                string     `json:"yesLabel" gorm:"default:YES"`
+	NoLabel                 string     `json:"noLabel" gorm:"default:NO"`
+	ConditionMarketID       *int64     `json:"conditionMarketId,omitempty" gorm:"index"`        // Market this one is conditional on
+	ConditionOutcome        string     `json:"conditionOutcome,omitempty"`                      // Outcome the condition market must resolve to, or this market resolves N/A
+	Visibility              string     `json:"visibility" gorm:"index;not null;default:PUBLIC"` // PUBLIC, UNLISTED or GROUP
+	GroupID                 *uint      `json:"groupId,omitempty" gorm:"index"`                  // Group that can see a GROUP market
+	TenantID                uint       `json:"tenantId,omitempty" gorm:"index;default:0"`       // Community the market belongs to; 0 for the default
+	SeriesID                *uint      `json:"seriesId,omitempty" gorm:"index"`                 // Recurring series this market is an instance of
+	SeriesIndex             int        `json:"seriesIndex,omitempty"`                           // Position in the series, from 1
+	ClosedAt                *time.Time `json:"closedAt,omitempty" gorm:"index"`                 // Set by the close scheduler once the market stops taking bets
+	ResolveRemindedAt       *time.Time `json:"-"`                                               // Last reminder sent to the creator to resolve
+	OverdueEscalatedAt      *time.Time `json:"overdueEscalatedAt,omitempty"`                    // When admins were told the market is overdue for resolution
+	VoidedAt                *time.Time `json:"voidedAt,omitempty"`                              // Set when the market resolved N/A and was unwound
+	VoidedBy                string     `json:"voidedBy,omitempty"`                              // Resolver or admin who voided the market; empty when voided automatically
+	VoidReason              string     `json:"voidReason,omitempty"`                            // Why the market was voided
+	CreatorUsername         string     `json:"creatorUsername" gorm:"not null"`
+	Creator                 User       `gorm:"foreignKey:CreatorUsername;references:Username"`
+}
+
+// IsConditional reports whether the market depends on another market's outcome
+func (m *Market) IsConditional() bool {
+	return m.ConditionMarketID != nil
+}
+
+// Market lifecycle states; see Market.Status
+const (
+	MarketStatusOpen     = "OPEN"
+	MarketStatusClosed   = "CLOSED"
+	MarketStatusResolved = "RESOLVED"
+	MarketStatusVoid     = "VOID"
+)
+
+// Status returns where the market is in its lifecycle. A market past its
+// close time counts as closed even before the scheduler has marked it.
+func (m *Market) Status(now time.Time) string {
+	switch {
+	case m.IsVoid():
+		return MarketStatusVoid
+	case m.IsResolved:
+		return MarketStatusResolved
+	case m.ClosedAt != nil || !now.Before(m.ResolutionDateTime):
+		return MarketStatusClosed
+	default:
+		return MarketStatusOpen
+	}
+}
+
+// IsVoid reports whether the market was voided, refunding its bettors
+func (m *Market) IsVoid() bool {
+	return m.VoidedAt != nil
+}
+
+// IsCategorical reports whether the market has more than two outcomes
+func (m *Market) IsCategorical() bool {
+	return m.OutcomeType == OutcomeTypeCategorical
 }
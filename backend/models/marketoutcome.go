@@ -0,0 +1,11 @@
+package models
+
+// MarketOutcome is one of a categorical market's possible outcomes. Bets on
+// a categorical market name the outcome's label, and the market resolves to
+// one of them or N/A.
+type MarketOutcome struct {
+	ID       uint   `json:"id" gorm:"primary_key"`
+	MarketID int64  `json:"marketId" gorm:"uniqueIndex:idx_market_outcome_label;not null"`
+	Label    string `json:"label" gorm:"uniqueIndex:idx_market_outcome_label;not null"`
+	Position int    `json:"position" gorm:"not null"` // Display order, from 0
+}
@@ -13,7 +13,7 @@ import (
 )
 
 // NewFakeDB returns a sqlite db running in memory as a gorm.DB
-func NewFakeDB(t *testing.T) *gorm.DB {
+func NewFakeDB(t testing.TB) *gorm.DB {
 	t.Helper()
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
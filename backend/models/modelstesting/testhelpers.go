@@ -69,7 +69,7 @@ func GenerateUser(username string, startingBalance int64) models.User {
 			DisplayName:           fmt.Sprintf("%s_display_%s", username, uniqueId),
 			UserType:              "regular",
 			InitialAccountBalance: startingBalance,
-			AccountBalance:        startingBalance,
+			AccountBalance:        models.CreditsToMicro(startingBalance),
 		},
 		PrivateUser: models.PrivateUser{
 			Email:    fmt.Sprintf("%s_%s@example.com", username, uniqueId),
@@ -97,6 +97,7 @@ func GenerateEconomicConfig() *setup.EconomicConfig {
 				InitialMarketSubsidization: 10,
 				InitialMarketYes:           0,
 				InitialMarketNo:            0,
+				DefaultLiquidityParameter:  100,
 			},
 			MarketIncentives: setup.MarketIncentives{
 				CreateMarketCost: 10,
@@ -0,0 +1,62 @@
+package modelstesting
+
+import (
+	"testing"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateUser stores a regular user holding the given whole credits, with the
+// forced password change already done so the user can call the API
+func CreateUser(t testing.TB, db *gorm.DB, username string, credits int64) models.User {
+	t.Helper()
+	user := GenerateUser(username, credits)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	// must_change_password defaults to true, so gorm skips the zero value on create
+	if err := db.Model(&user).Update("must_change_password", false).Error; err != nil {
+		t.Fatalf("clear password change for %s: %v", username, err)
+	}
+	user.MustChangePassword = false
+	return user
+}
+
+// CreateWallet stores an active deposit wallet for the user on chainName,
+// held by the primary DFNS org
+func CreateWallet(t testing.TB, db *gorm.DB, userID int64, chainName, dfnsWalletID, address string) models.Wallet {
+	t.Helper()
+	wallet := models.Wallet{
+		UserID:       userID,
+		DfnsWalletID: dfnsWalletID,
+		ChainID:      models.GetChainID(chainName),
+		ChainName:    chainName,
+		Address:      address,
+		IsActive:     true,
+	}
+	if err := db.Create(&wallet).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	return wallet
+}
+
+// CreateWithdrawal stores a pending withdrawal request. The user's balance is
+// left alone, so tests that need the amount reserved must debit it themselves.
+func CreateWithdrawal(t testing.TB, db *gorm.DB, userID int64, chainName, tokenSymbol, toAddress string, amountMicro int64) models.WithdrawalRequest {
+	t.Helper()
+	withdrawalReq := models.WithdrawalRequest{
+		UserID:      userID,
+		ChainID:     models.GetChainID(chainName),
+		ChainName:   chainName,
+		TokenSymbol: tokenSymbol,
+		Amount:      amountMicro,
+		ToAddress:   toAddress,
+		Status:      models.TxStatusPending,
+	}
+	if err := db.Create(&withdrawalReq).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+	return withdrawalReq
+}
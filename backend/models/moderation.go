@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Market report reasons
+const (
+	ReportReasonSpam       = "SPAM"
+	ReportReasonOffensive  = "OFFENSIVE"
+	ReportReasonMisleading = "MISLEADING" // Ambiguous or unresolvable question, or misleading terms
+	ReportReasonDuplicate  = "DUPLICATE"
+	ReportReasonOther      = "OTHER"
+)
+
+// Market report status constants
+const (
+	ReportStatusOpen      = "OPEN"
+	ReportStatusActioned  = "ACTIONED"  // A moderator acted on the market
+	ReportStatusDismissed = "DISMISSED" // A moderator found nothing wrong
+)
+
+// MarketReport is a user's complaint about a market, queued for moderators.
+// A user can report a market once.
+type MarketReport struct {
+	gorm.Model
+	ID         uint       `json:"id" gorm:"primary_key"`
+	MarketID   int64      `json:"marketId" gorm:"uniqueIndex:idx_market_report_reporter,priority:1;not null"`
+	ReporterID int64      `json:"reporterId" gorm:"uniqueIndex:idx_market_report_reporter,priority:2;not null"`
+	Reason     string     `json:"reason" gorm:"not null"`
+	Details    string     `json:"details,omitempty" gorm:"type:text"`
+	Status     string     `json:"status" gorm:"index;not null"`
+	ReviewedBy string     `json:"reviewedBy,omitempty"` // Username of the moderator who closed the report
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+}
+
+// TableName specifies the table name for MarketReport
+func (MarketReport) TableName() string {
+	return "market_reports"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification is an in-app message to a user
+type Notification struct {
+	gorm.Model
+	ID      uint       `json:"id" gorm:"primary_key"`
+	UserID  int64      `json:"userId" gorm:"index;not null"`
+	Type    string     `json:"type" gorm:"not null"`
+	Title   string     `json:"title" gorm:"not null"`
+	Message string     `json:"message"`
+	ReadAt  *time.Time `json:"readAt"`
+}
+
+// TableName specifies the table name for Notification
+func (Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Oracle data sources
+const (
+	OracleSourcePrice = "PRICE" // A number, such as a price, compared with a threshold
+	OracleSourceScore = "SCORE" // A result, such as a match winner, matched against a target or outcome label
+)
+
+// Oracle comparators for PRICE sources
+const (
+	OracleCompareGT  = "GT"
+	OracleCompareGTE = "GTE"
+	OracleCompareLT  = "LT"
+	OracleCompareLTE = "LTE"
+	OracleCompareEQ  = "EQ"
+)
+
+// Oracle statuses
+const (
+	OracleStatusPending   = "PENDING"   // Waiting for the market to close
+	OracleStatusProposed  = "PROPOSED"  // Outcome observed and awaiting the end of the review window
+	OracleStatusResolved  = "RESOLVED"  // Market resolved to the proposed outcome
+	OracleStatusRejected  = "REJECTED"  // An admin rejected the proposal; the market is resolved by hand
+	OracleStatusFailed    = "FAILED"    // The source could not be read; the market is resolved by hand
+	OracleStatusCancelled = "CANCELLED" // The market was resolved some other way
+)
+
+// MarketOracleConfig resolves a market from an external JSON feed. Once the
+// market closes the feed is read and the outcome proposed; admins can
+// approve, override or reject it until ReviewEndsAt, after which it is
+// paid out.
+type MarketOracleConfig struct {
+	gorm.Model
+	ID              uint       `json:"id" gorm:"primary_key"`
+	MarketID        int64      `json:"marketId" gorm:"uniqueIndex;not null"`
+	Source          string     `json:"source" gorm:"not null"`
+	URL             string     `json:"url" gorm:"not null"`
+	Field           string     `json:"field" gorm:"not null"` // Dot-separated path to the value in the response, e.g. "data.0.price"
+	Comparator      string     `json:"comparator"`
+	Target          string     `json:"target"` // Threshold for PRICE; the result meaning YES for SCORE on a binary market
+	Status          string     `json:"status" gorm:"index;not null"`
+	ObservedValue   string     `json:"observedValue,omitempty"`
+	ProposedOutcome string     `json:"proposedOutcome,omitempty"`
+	Attempts        int        `json:"attempts"`
+	LastError       string     `json:"lastError,omitempty"`
+	CheckedAt       *time.Time `json:"checkedAt,omitempty"`
+	ReviewEndsAt    *time.Time `json:"reviewEndsAt,omitempty"`
+	ConfiguredBy    string     `json:"configuredBy"`
+	ReviewedBy      string     `json:"reviewedBy,omitempty"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Order sides
+const (
+	OrderSideBuy  = "BUY"
+	OrderSideSell = "SELL"
+)
+
+// Order statuses
+const (
+	OrderStatusOpen      = "OPEN"
+	OrderStatusFilled    = "FILLED"
+	OrderStatusCancelled = "CANCELLED" // By the user, or because a fill failed
+	OrderStatusExpired   = "EXPIRED"   // The market closed first
+)
+
+// MarketOrder is a limit order resting on a market. It fills as bets placed
+// for its user once the market's probability of Outcome reaches LimitPrice:
+// at or below it for a buy, at or above it for a sell.
+type MarketOrder struct {
+	gorm.Model
+	ID           uint       `json:"id" gorm:"primary_key"`
+	MarketID     int64      `json:"marketId" gorm:"index:idx_market_order_book;not null"`
+	Status       string     `json:"status" gorm:"index:idx_market_order_book;not null"`
+	UserID       int64      `json:"userId" gorm:"index;not null"`
+	Username     string     `json:"username" gorm:"not null"`
+	Side         string     `json:"side" gorm:"not null"`
+	Outcome      string     `json:"outcome" gorm:"not null"`
+	LimitPrice   float64    `json:"limitPrice" gorm:"not null"` // Probability of Outcome
+	Amount       int64      `json:"amount" gorm:"not null"`     // Credits to bet, or for a sell, credits of shares to sell
+	Filled       int64      `json:"filled"`                     // Credits bet or shares sold for so far
+	CancelReason string     `json:"cancelReason,omitempty"`
+	ClosedAt     *time.Time `json:"closedAt,omitempty"`
+}
+
+// Remaining returns the credits of the order still to fill
+func (o *MarketOrder) Remaining() int64 {
+	return max(o.Amount-o.Filled, 0)
+}
+
+// OrderFill is part of an order filled by one bet
+type OrderFill struct {
+	ID          uint      `json:"id" gorm:"primary_key"`
+	OrderID     uint      `json:"orderId" gorm:"index;not null"`
+	BetID       uint      `json:"betId" gorm:"not null"`
+	Amount      int64     `json:"amount" gorm:"not null"`      // Credits
+	Probability float64   `json:"probability" gorm:"not null"` // Market YES probability after the fill
+	CreatedAt   time.Time `json:"createdAt"`
+}
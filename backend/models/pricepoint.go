@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// MarketPricePoint is a market's probability for one outcome just after a
+// trade, recorded so price history can be charted without replaying bets.
+// Binary markets record the YES probability; categorical markets record a
+// point for every outcome on each trade.
+type MarketPricePoint struct {
+	ID          uint      `json:"-" gorm:"primary_key"`
+	MarketID    int64     `json:"marketId" gorm:"uniqueIndex:idx_price_point_trade,priority:1;index:idx_price_point_time,priority:1;not null"`
+	BetID       uint      `json:"betId" gorm:"uniqueIndex:idx_price_point_trade,priority:2;not null"`
+	Outcome     string    `json:"outcome" gorm:"uniqueIndex:idx_price_point_trade,priority:3;index:idx_price_point_time,priority:2;not null"`
+	Probability float64   `json:"probability"`
+	Volume      int64     `json:"volume"`                                                           // Credits traded, counted on the outcome the bet traded
+	RecordedAt  time.Time `json:"recordedAt" gorm:"index:idx_price_point_time,priority:3;not null"` // When the bet was placed
+}
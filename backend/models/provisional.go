@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Provisional credit status constants
+const (
+	ProvisionalStatusActive    = "ACTIVE"    // Deposit unconfirmed, allowance usable for betting
+	ProvisionalStatusConverted = "CONVERTED" // Deposit confirmed and credited in full
+	ProvisionalStatusReversed  = "REVERSED"  // Deposit failed, allowance withdrawn
+)
+
+// ProvisionalCredit is a betting-only allowance backed by an unconfirmed
+// deposit. Active allowances are summed in User.ProvisionalBalance.
+type ProvisionalCredit struct {
+	gorm.Model
+	ID                  uint       `json:"id" gorm:"primary_key"`
+	UserID              int64      `json:"userId" gorm:"index;not null"`
+	CryptoTransactionID uint       `json:"cryptoTransactionId" gorm:"uniqueIndex;not null"`
+	Amount              int64      `json:"amount" gorm:"not null"` // Micro-credits
+	Status              string     `json:"status" gorm:"index;not null"`
+	ResolvedAt          *time.Time `json:"resolvedAt"`
+}
+
+// TableName specifies the table name for ProvisionalCredit
+func (ProvisionalCredit) TableName() string {
+	return "provisional_credits"
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Referral statuses
+const (
+	ReferralStatusActive  = "ACTIVE"  // Earns rewards until RewardsUntil or the cap
+	ReferralStatusFlagged = "FLAGGED" // Looks like a self-referral; earns nothing until an admin clears it
+	ReferralStatusBlocked = "BLOCKED" // Ruled fraudulent by an admin; its rewards were taken back
+)
+
+// ReferralCode is the code a user shares to refer new users
+type ReferralCode struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	UserID    int64     `json:"userId" gorm:"uniqueIndex;not null"`
+	Code      string    `json:"code" gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for ReferralCode
+func (ReferralCode) TableName() string {
+	return "referral_codes"
+}
+
+// Referral links a new user to the user who referred them. The referrer
+// earns a share of the referee's trading fees for a limited time, up to a cap.
+type Referral struct {
+	gorm.Model
+	ID           uint       `json:"id" gorm:"primary_key"`
+	ReferrerID   int64      `json:"referrerId" gorm:"index;not null"`
+	RefereeID    int64      `json:"refereeId" gorm:"uniqueIndex;not null"`
+	Code         string     `json:"code" gorm:"not null"`
+	Status       string     `json:"status" gorm:"index;not null"`
+	FlagReason   string     `json:"flagReason,omitempty"`
+	RewardsUntil time.Time  `json:"rewardsUntil" gorm:"not null"`
+	Rewarded     int64      `json:"rewarded"` // Micro-credits paid to the referrer so far
+	ReviewedBy   string     `json:"reviewedBy,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewedAt,omitempty"` // Set once an admin clears or blocks the referral
+}
+
+// TableName specifies the table name for Referral
+func (Referral) TableName() string {
+	return "referrals"
+}
@@ -0,0 +1,29 @@
+package models
+
+import "gorm.io/gorm"
+
+// Resolution cost source constants
+const (
+	ResolutionCostSourceOracle = "ORACLE" // Paid data or oracle feed
+	ResolutionCostSourceStaff  = "STAFF"  // Staff time spent researching the outcome
+	ResolutionCostSourceFee    = "FEE"    // Flat resolution fee charged automatically on resolution
+	ResolutionCostSourceOther  = "OTHER"
+)
+
+// ResolutionCost attributes the cost of resolving a market to that market.
+// Amounts are in micro-credits.
+type ResolutionCost struct {
+	gorm.Model
+	ID          uint   `json:"id" gorm:"primary_key"`
+	MarketID    int64  `json:"marketId" gorm:"index;not null"`
+	Source      string `json:"source" gorm:"not null"`
+	Amount      int64  `json:"amount" gorm:"not null"`
+	Recovered   int64  `json:"recovered" gorm:"not null;default:0"` // Portion covered by the market's fee pool
+	Description string `json:"description"`
+	RecordedBy  string `json:"recordedBy" gorm:"not null"`
+}
+
+// TableName specifies the table name for ResolutionCost
+func (ResolutionCost) TableName() string {
+	return "resolution_costs"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User restriction flags
+const (
+	RestrictionWithdrawalsFrozen = "withdrawals_frozen" // No new withdrawals, and pending ones are not approved
+	RestrictionTradingFrozen     = "trading_frozen"     // No bets, sales or limit orders
+	RestrictionDepositsBlocked   = "deposits_blocked"   // Deposits are held for review instead of credited
+)
+
+// RestrictionKinds lists every user restriction flag
+var RestrictionKinds = []string{RestrictionWithdrawalsFrozen, RestrictionTradingFrozen, RestrictionDepositsBlocked}
+
+// UserRestriction is a restriction an admin placed on one user's account,
+// in force until ExpiresAt or, when that is nil, until lifted
+type UserRestriction struct {
+	gorm.Model
+	ID        uint       `json:"id" gorm:"primary_key"`
+	UserID    int64      `json:"userId" gorm:"uniqueIndex:idx_user_restriction;not null"`
+	Kind      string     `json:"kind" gorm:"uniqueIndex:idx_user_restriction;not null"`
+	Reason    string     `json:"reason" gorm:"not null"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	UpdatedBy string     `json:"updatedBy"`
+}
+
+// TableName specifies the table name for UserRestriction
+func (UserRestriction) TableName() string {
+	return "user_restrictions"
+}
+
+// ActiveAt reports whether the restriction is in force at now
+func (r UserRestriction) ActiveAt(now time.Time) bool {
+	return r.ExpiresAt == nil || now.Before(*r.ExpiresAt)
+}
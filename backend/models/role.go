@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// Admin permissions. Endpoints guarded by a permission are open only to
+// admins holding a role that grants it; other admin endpoints are open to
+// every admin.
+const (
+	PermWithdrawalsView    = "withdrawals.view"    // List and inspect withdrawals
+	PermWithdrawalsApprove = "withdrawals.approve" // Approve and reject withdrawals
+	PermDepositsReview     = "deposits.review"     // Release and reject held deposits
+	PermBalancesAdjust     = "balances.adjust"     // Correct balances and grant bonus credits
+	PermSettingsManage     = "settings.manage"     // Change limits, fees and the maker-checker policy
+	PermMarketsManage      = "markets.manage"      // Void markets, cap bets and run the house bot
+	PermUsersRestrict      = "users.restrict"      // Restrict accounts and see self-exclusions
+	PermChainsManage       = "chains.manage"       // Change chain and treasury wallet configuration
+	PermRolesManage        = "roles.manage"        // Assign admin roles
+	PermUsersImpersonate   = "users.impersonate"   // View a user's account as they see it, read-only
+	PermTenantsManage      = "tenants.manage"      // Add and configure branded communities
+	PermAll                = "*"                   // Every permission
+)
+
+// Built-in admin roles
+const (
+	RoleSuperAdmin = "superadmin"
+	RoleFinance    = "finance"
+	RoleSupport    = "support"
+)
+
+// Role is a named set of admin permissions
+type Role struct {
+	ID          uint      `json:"id" gorm:"primary_key"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions" gorm:"serializer:json;not null"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for Role
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Grants reports whether the role includes permission
+func (r Role) Grants(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission || p == PermAll {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltInRoles returns the roles every platform starts with
+func BuiltInRoles() []Role {
+	return []Role{
+		{Name: RoleSuperAdmin, Description: "Every admin permission", Permissions: []string{PermAll}},
+		{Name: RoleFinance, Description: "Reviews and approves withdrawals, held deposits and balance corrections",
+			Permissions: []string{PermWithdrawalsView, PermWithdrawalsApprove, PermDepositsReview, PermBalancesAdjust}},
+		{Name: RoleSupport, Description: "Looks into withdrawals for users", Permissions: []string{PermWithdrawalsView, PermUsersImpersonate}},
+	}
+}
+
+// UserRole assigns a role to an admin
+type UserRole struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	UserID    int64     `json:"userId" gorm:"uniqueIndex:idx_user_roles_user_role;not null"`
+	RoleID    uint      `json:"roleId" gorm:"uniqueIndex:idx_user_roles_user_role;index;not null"`
+	GrantedBy string    `json:"grantedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	Role      Role      `json:"role" gorm:"foreignKey:RoleID"`
+}
+
+// TableName specifies the table name for UserRole
+func (UserRole) TableName() string {
+	return "user_roles"
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Saga status constants
+const (
+	SagaStatusRunning      = "RUNNING"      // Executing steps
+	SagaStatusWaiting      = "WAITING"      // Waiting for an external event to finish the current step
+	SagaStatusCompleted    = "COMPLETED"    // All steps done
+	SagaStatusCompensating = "COMPENSATING" // Undoing completed steps; stuck here if an undo failed
+	SagaStatusCompensated  = "COMPENSATED"  // A step failed and completed steps were undone
+	SagaStatusFailed       = "FAILED"       // A step failed and could not be compensated; needs a retry
+)
+
+// Saga step status constants
+const (
+	SagaStepDone        = "DONE"
+	SagaStepFailed      = "FAILED"
+	SagaStepCompensated = "COMPENSATED"
+)
+
+// SagaInstance is one run of a multi-step money flow, such as a withdrawal
+// from approval to completion. Step state is kept so partial failures can be
+// compensated or retried.
+type SagaInstance struct {
+	gorm.Model
+	ID          uint              `json:"id" gorm:"primary_key"`
+	Name        string            `json:"name" gorm:"index:idx_saga_reference;not null"`
+	ReferenceID uint              `json:"referenceId" gorm:"index:idx_saga_reference;not null"`
+	Status      string            `json:"status" gorm:"index;not null"`
+	CurrentStep int               `json:"currentStep"` // Index of the next step to run, or the step being waited on
+	LastError   string            `json:"lastError,omitempty"`
+	Data        map[string]string `json:"data" gorm:"serializer:json"`
+	FinishedAt  *time.Time        `json:"finishedAt"`
+	Steps       []SagaStepLog     `json:"steps" gorm:"foreignKey:SagaID"`
+}
+
+// TableName specifies the table name for SagaInstance
+func (SagaInstance) TableName() string {
+	return "saga_instances"
+}
+
+// SagaStepLog records the outcome of a saga step
+type SagaStepLog struct {
+	gorm.Model
+	ID     uint   `json:"id" gorm:"primary_key"`
+	SagaID uint   `json:"sagaId" gorm:"index;not null"`
+	Step   int    `json:"step"`
+	Name   string `json:"name" gorm:"not null"`
+	Status string `json:"status" gorm:"not null"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TableName specifies the table name for SagaStepLog
+func (SagaStepLog) TableName() string {
+	return "saga_step_logs"
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Self-exclusion kinds
+const (
+	ExclusionCoolingOff    = "COOLING_OFF"    // A short break of days or weeks
+	ExclusionSelfExclusion = "SELF_EXCLUSION" // Months or years away
+)
+
+// SelfExclusion is a period a user chose to be kept from betting and
+// depositing. It cannot be ended early.
+type SelfExclusion struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	UserID    int64     `json:"userId" gorm:"index;not null"`
+	Kind      string    `json:"kind" gorm:"not null"`
+	StartsAt  time.Time `json:"startsAt" gorm:"not null"`
+	EndsAt    time.Time `json:"endsAt" gorm:"index;not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for SelfExclusion
+func (SelfExclusion) TableName() string {
+	return "self_exclusions"
+}
+
+// DepositLimit is the daily deposit limit a user set on themselves. Lowering
+// it applies at once; raising or removing it waits until PendingFrom.
+type DepositLimit struct {
+	UserID       int64      `json:"userId" gorm:"primary_key"`
+	DailyLimit   int64      `json:"dailyLimit"`             // Micro-credits a rolling 24 hours; 0 for none
+	PendingLimit *int64     `json:"pendingLimit,omitempty"` // The raised limit, in micro-credits, once PendingFrom passes
+	PendingFrom  *time.Time `json:"pendingFrom,omitempty"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// TableName specifies the table name for DepositLimit
+func (DepositLimit) TableName() string {
+	return "deposit_limits"
+}
+
+// EffectiveAt returns the limit in force at now, taking in a raised limit
+// whose delay has passed
+func (l DepositLimit) EffectiveAt(now time.Time) int64 {
+	if l.PendingLimit != nil && l.PendingFrom != nil && !now.Before(*l.PendingFrom) {
+		return *l.PendingLimit
+	}
+	return l.DailyLimit
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MarketSeries is a recurring market, such as a weekly "Will BTC close above
+// X?". Its generator keeps one instance open: when an instance closes, the
+// next is created from the series' settings, closing at the next time on its
+// schedule.
+type MarketSeries struct {
+	gorm.Model
+	ID                 uint       `json:"id" gorm:"primary_key"`
+	Name               string     `json:"name" gorm:"not null"`
+	QuestionTemplate   string     `json:"questionTemplate" gorm:"not null"` // "{date}" is replaced with the instance's close date
+	Description        string     `json:"description"`
+	InitialProbability float64    `json:"initialProbability" gorm:"not null"`
+	YesLabel           string     `json:"yesLabel" gorm:"default:YES"`
+	NoLabel            string     `json:"noLabel" gorm:"default:NO"`
+	Schedule           string     `json:"schedule" gorm:"not null"` // Cron expression for instance close times, in UTC
+	CreatorUsername    string     `json:"creatorUsername" gorm:"not null"`
+	TenantID           uint       `json:"tenantId,omitempty" gorm:"index;default:0"` // Community its markets open in
+	IsActive           bool       `json:"isActive" gorm:"index;not null"`
+	Instances          int        `json:"instances"` // Instances created so far
+	LastMarketID       *int64     `json:"lastMarketId,omitempty"`
+	LastGeneratedAt    *time.Time `json:"lastGeneratedAt,omitempty"`
+	LastError          string     `json:"lastError,omitempty"`
+}
+
+// TableName specifies the table name for MarketSeries
+func (MarketSeries) TableName() string {
+	return "market_series"
+}
+
+// MarketSeriesSubscription asks for a notification whenever a series opens
+// a new instance
+type MarketSeriesSubscription struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	SeriesID  uint      `json:"seriesId" gorm:"uniqueIndex:idx_series_subscriber;not null"`
+	UserID    int64     `json:"userId" gorm:"uniqueIndex:idx_series_subscriber;index;not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for MarketSeriesSubscription
+func (MarketSeriesSubscription) TableName() string {
+	return "market_series_subscriptions"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "gorm.io/gorm"
+
+// Platform setting keys
+const (
+	SettingWithdrawalMin     = "withdrawal.min"           // Micro-credits
+	SettingWithdrawalMax     = "withdrawal.max"           // Micro-credits
+	SettingWithdrawalDaily   = "withdrawal.daily_limit"   // Micro-credits
+	SettingWithdrawalWeekly  = "withdrawal.weekly_limit"  // Micro-credits over 7 days; 0 for no limit
+	SettingWithdrawalMonthly = "withdrawal.monthly_limit" // Micro-credits over 30 days; 0 for no limit
+
+	SettingGlobalWithdrawalDaily   = "withdrawal.global_daily_limit"   // Platform-wide micro-credits per day; 0 for no limit
+	SettingGlobalWithdrawalWeekly  = "withdrawal.global_weekly_limit"  // Platform-wide micro-credits over 7 days; 0 for no limit
+	SettingGlobalWithdrawalMonthly = "withdrawal.global_monthly_limit" // Platform-wide micro-credits over 30 days; 0 for no limit
+
+	SettingWithdrawalsFrozen      = "withdrawal.frozen"        // "true" while an emergency freeze is active
+	SettingWithdrawalFreezeReason = "withdrawal.freeze_reason" // Why the freeze was applied or lifted
+
+	SettingTransfersEnabled   = "transfer.enabled"     // "false" to switch off user-to-user transfers
+	SettingTransferDailyLimit = "transfer.daily_limit" // Micro-credits a user may send over a rolling 24 hours
+
+	SettingCreatorFeeBps         = "creator_fee.bps"            // Fee on each trade, in basis points of its volume; 0 for none
+	SettingCreatorFeePlatformBps = "creator_fee.platform_share" // Platform's share of each creator fee, in basis points
+
+	SettingMakerCheckerOwnAccount     = "maker_checker.block_own_account" // "false" to let admins approve actions on their own account
+	SettingMakerCheckerSecondApproval = "maker_checker.second_approval"   // "true" to send every balance correction to a second admin
+
+	SettingHouseBotHalted     = "house_bot.halted"      // "true" while the house liquidity bot is switched off
+	SettingHouseBotHaltReason = "house_bot.halt_reason" // Why the bot was halted or resumed
+)
+
+// PlatformSetting is a runtime-editable platform setting stored as a string
+type PlatformSetting struct {
+	gorm.Model
+	ID        uint   `json:"id" gorm:"primary_key"`
+	Key       string `json:"key" gorm:"uniqueIndex;not null"`
+	Value     string `json:"value" gorm:"not null"`
+	UpdatedBy string `json:"updatedBy"`
+}
+
+// TableName specifies the table name for PlatformSetting
+func (PlatformSetting) TableName() string {
+	return "platform_settings"
+}
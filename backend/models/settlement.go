@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payout hold statuses
+const (
+	PayoutHoldHeld     = "HELD"     // Waiting out the settlement delay
+	PayoutHoldDisputed = "DISPUTED" // Frozen by an open dispute on the market
+	PayoutHoldReleased = "RELEASED" // Withdrawable
+	PayoutHoldReversed = "REVERSED" // Taken back after the dispute was upheld
+)
+
+// PayoutHold is market winnings kept out of the withdrawable balance until
+// ReleaseAt; see User.PendingBalance
+type PayoutHold struct {
+	gorm.Model
+	ID         uint       `json:"id" gorm:"primary_key"`
+	UserID     int64      `json:"userId" gorm:"index;not null"`
+	MarketID   int64      `json:"marketId" gorm:"index;not null"`
+	Amount     int64      `json:"amount" gorm:"not null"` // Micro-credits
+	Status     string     `json:"status" gorm:"index;not null"`
+	ReleaseAt  time.Time  `json:"releaseAt" gorm:"index;not null"`
+	ReleasedAt *time.Time `json:"releasedAt,omitempty"`
+}
+
+// Market dispute statuses
+const (
+	DisputeStatusOpen     = "OPEN"
+	DisputeStatusReleased = "RELEASED" // Dismissed; the held winnings were released
+	DisputeStatusUpheld   = "UPHELD"   // The held winnings were reversed
+)
+
+// MarketDispute challenges a market's resolution while its winnings are
+// still held, freezing them until an admin decides
+type MarketDispute struct {
+	gorm.Model
+	ID         uint       `json:"id" gorm:"primary_key"`
+	MarketID   int64      `json:"marketId" gorm:"index;not null"`
+	OpenedBy   string     `json:"openedBy" gorm:"not null"`
+	Reason     string     `json:"reason" gorm:"not null"`
+	Status     string     `json:"status" gorm:"index;not null"`
+	ReviewedBy string     `json:"reviewedBy,omitempty"`
+	ReviewNote string     `json:"reviewNote,omitempty"`
+	ClosedAt   *time.Time `json:"closedAt,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "gorm.io/gorm"
+
+// Tenant is a branded community hosted on this deployment and selected by the
+// request's hostname. Its markets, and the leaderboards built from them, are
+// kept apart from other communities'; users, wallets and the treasury are
+// shared. Requests to any other hostname are the default community, tenant 0.
+type Tenant struct {
+	gorm.Model
+	ID               uint     `json:"id" gorm:"primary_key"`
+	Slug             string   `json:"slug" gorm:"uniqueIndex;not null"`
+	Name             string   `json:"name" gorm:"not null"`
+	Hostnames        []string `json:"hostnames" gorm:"serializer:json;not null"` // Lower case, without port
+	LogoURL          string   `json:"logoUrl"`
+	PrimaryColor     string   `json:"primaryColor"`                         // CSS hex colour, e.g. #1f6feb
+	CreditName       string   `json:"creditName"`                           // What the community calls credits; empty for "credits"
+	InitialBetFee    *int64   `json:"initialBetFee,omitempty"`              // Whole credits; nil for the platform's fee
+	BuySharesFee     *int64   `json:"buySharesFee,omitempty"`               // Whole credits; nil for the platform's fee
+	SellSharesFee    *int64   `json:"sellSharesFee,omitempty"`              // Whole credits; nil for the platform's fee
+	CreateMarketCost *int64   `json:"createMarketCost,omitempty"`           // Whole credits; nil for the platform's cost
+	EnabledChains    []string `json:"enabledChains" gorm:"serializer:json"` // Chains offered for deposits; empty for every active chain
+	IsActive         bool     `json:"isActive" gorm:"index;not null"`
+}
+
+// TableName specifies the table name for Tenant
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// InTenant scopes a market query to one community's markets
+func InTenant(tenantID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+// TokenWithdrawalRule overrides the platform withdrawal limits for one token on
+// one chain, e.g. a higher minimum on Ethereum mainnet where gas makes small
+// transfers uneconomic
+type TokenWithdrawalRule struct {
+	gorm.Model
+	ID            uint   `json:"id" gorm:"primary_key"`
+	ChainName     string `json:"chainName" gorm:"uniqueIndex:idx_token_withdrawal_rule;not null"`
+	TokenSymbol   string `json:"tokenSymbol" gorm:"uniqueIndex:idx_token_withdrawal_rule;not null"`
+	MinWithdrawal int64  `json:"minWithdrawal"` // Micro-credits; 0 uses the platform minimum
+	MaxWithdrawal int64  `json:"maxWithdrawal"` // Micro-credits; 0 uses the platform maximum
+	Disabled      bool   `json:"disabled"`      // Withdrawals of this token on this chain are switched off
+	Note          string `json:"note,omitempty"`
+	UpdatedBy     string `json:"updatedBy"`
+}
+
+// TableName specifies the table name for TokenWithdrawalRule
+func (TokenWithdrawalRule) TableName() string {
+	return "token_withdrawal_rules"
+}
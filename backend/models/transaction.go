@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,8 +20,28 @@ const (
 	TxStatusCompleted = "COMPLETED"
 	TxStatusFailed    = "FAILED"
 	TxStatusRejected  = "REJECTED"
+	TxStatusOnHold    = "ON_HOLD"   // Flagged by sanctions screening, awaiting manual review
+	TxStatusCancelled = "CANCELLED" // Withdrawn by the user before processing; refunded
+	TxStatusExpired   = "EXPIRED"   // Not processed in time; refunded
+	TxStatusApproving = "APPROVING" // Approved by an admin; the transfer is being started
+	TxStatusDust      = "DUST"      // Deposit below the token's minimum; recorded but not credited
+
+	TxStatusUnrecorded = "TRANSFER_UNRECORDED" // Transfer started with DFNS but not recorded; awaiting reconciliation
+
+	TxStatusAwaitingConfirmation = "AWAITING_USER_CONFIRMATION" // Withdrawal waiting for the user to open the emailed confirmation link
+)
+
+// Beneficiary types a withdrawal can be attested to
+const (
+	BeneficiarySelfCustody = "SELF_CUSTODY" // A wallet the user controls
+	BeneficiaryExchange    = "EXCHANGE"     // An account at another exchange
 )
 
+// WithdrawalCommittedStatuses are the withdrawal request statuses whose amount
+// has left, or is still leaving, the user's balance. Rejected, failed,
+// cancelled and expired requests were refunded and do not count towards limits.
+var WithdrawalCommittedStatuses = []string{TxStatusAwaitingConfirmation, TxStatusPending, TxStatusOnHold, TxStatusApproving, TxStatusUnrecorded, TxStatusApproved, TxStatusCompleted}
+
 // CryptoTransaction tracks all deposits and withdrawals
 type CryptoTransaction struct {
 	gorm.Model
@@ -28,24 +49,27 @@ type CryptoTransaction struct {
 	UserID        int64      `json:"userId" gorm:"index;not null"`
 	WalletID      *uint      `json:"walletId" gorm:"index"`
 	Type          string     `json:"type" gorm:"not null"`         // DEPOSIT or WITHDRAWAL
-	Status        string     `json:"status" gorm:"index;not null"` // PENDING, APPROVED, COMPLETED, FAILED, REJECTED
+	Status        string     `json:"status" gorm:"index;not null"` // PENDING, APPROVED, COMPLETED, FAILED, REJECTED, ON_HOLD
 	ChainID       int64      `json:"chainId"`
 	ChainName     string     `json:"chainName"`
 	TokenSymbol   string     `json:"tokenSymbol"`   // USDC, USDT
 	TokenAddress  string     `json:"tokenAddress"`  // Contract address
 	Amount        string     `json:"amount"`        // Raw amount in token decimals (string for precision)
-	AmountCredits int64      `json:"amountCredits"` // Converted to platform credits (1:1 for stablecoins)
+	AmountCredits int64      `json:"amountCredits"` // Converted to platform micro-credits (1 token = 1,000,000 for stablecoins)
 	TxHash        string     `json:"txHash" gorm:"index"`
 	FromAddress   string     `json:"fromAddress"`
 	ToAddress     string     `json:"toAddress"`
+	LogIndex      int64      `json:"logIndex" gorm:"not null;default:0"`
 	DfnsTxID      string     `json:"dfnsTxId"` // DFNS transaction/request ID
 	Confirmations int        `json:"confirmations" gorm:"default:0"`
 	RequiredConf  int        `json:"requiredConf"`
-	Fee           string     `json:"fee"`                        // Network fee
+	Fee           string     `json:"fee"`                          // Network fee
 	PlatformFee   int64      `json:"platformFee" gorm:"default:0"` // Platform fee in credits
 	ErrorMessage  string     `json:"errorMessage"`
 	WebhookData   string     `json:"webhookData" gorm:"type:text"` // Store raw webhook data
+	HoldReason    string     `json:"holdReason,omitempty"`         // Why the transaction was put ON_HOLD
 	ProcessedAt   *time.Time `json:"processedAt"`
+	GraceCredited bool       `json:"graceCredited,omitempty"` // A PENDING deposit already credited, see SupportedChain.GraceCrediting
 }
 
 // WithdrawalRequest tracks user withdrawal requests before admin approval
@@ -56,14 +80,50 @@ type WithdrawalRequest struct {
 	ChainID       int64      `json:"chainId" gorm:"not null"`
 	ChainName     string     `json:"chainName" gorm:"not null"`
 	TokenSymbol   string     `json:"tokenSymbol" gorm:"not null"`
-	Amount        int64      `json:"amount" gorm:"not null"` // Amount in credits
+	Amount        int64      `json:"amount" gorm:"not null"` // Amount in micro-credits
 	ToAddress     string     `json:"toAddress" gorm:"not null"`
-	Status        string     `json:"status" gorm:"index;not null"` // PENDING, APPROVED, COMPLETED, REJECTED, FAILED
+	Status        string     `json:"status" gorm:"index;not null"` // PENDING, APPROVED, COMPLETED, REJECTED, FAILED, ON_HOLD
 	TransactionID *uint      `json:"transactionId"`                // Link to CryptoTransaction when processed
 	ErrorMessage  string     `json:"errorMessage"`
-	AdminID       *int64     `json:"adminId"`                     // Admin who approved/rejected
-	AdminNote     string     `json:"adminNote"`                   // Note from admin
+	AdminID       *int64     `json:"adminId"` // Admin who approved/rejected
 	ProcessedAt   *time.Time `json:"processedAt"`
+	RiskScore     int        `json:"riskScore" gorm:"index;default:0"` // 0-100, assigned when the request is created
+	RiskReasons   string     `json:"riskReasons"`                      // Comma-separated risk reason codes
+	HoldReason    string     `json:"holdReason,omitempty"`             // Why the request was put ON_HOLD
+	TraceID       string     `json:"traceId,omitempty" gorm:"index"`   // Request trace ID, sent to DFNS as the transfer's external ID
+	DeviceID      *uint      `json:"deviceId,omitempty" gorm:"index"`  // UserDevice the request came from, where known
+	HoldUntil     *time.Time `json:"holdUntil,omitempty" gorm:"index"` // When a new-device hold lifts by itself
+
+	// Where the request came from, for fraud review
+	RequestIP  string `json:"requestIp,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	Country    string `json:"country,omitempty" gorm:"index"`        // ISO 3166-1 alpha-2, empty when unknown
+	Region     string `json:"region,omitempty"`                      // Coarse region, where the locator provides one
+	NewCountry bool   `json:"newCountry" gorm:"index;default:false"` // First withdrawal from Country after others from elsewhere
+
+	// Beneficiary the user attested to, required at or above the travel-rule
+	// threshold and kept for compliance exports
+	BeneficiaryType       string     `json:"beneficiaryType,omitempty" gorm:"index"` // SELF_CUSTODY or EXCHANGE, empty when not attested
+	BeneficiaryName       string     `json:"beneficiaryName,omitempty"`
+	BeneficiaryExchange   string     `json:"beneficiaryExchange,omitempty"` // Receiving exchange, for EXCHANGE beneficiaries
+	BeneficiaryAttestedAt *time.Time `json:"beneficiaryAttestedAt,omitempty"`
+
+	// Set when an admin approved less than was requested. Amount is then the
+	// approved amount and the difference was refunded to the user.
+	OriginalAmount   int64  `json:"originalAmount,omitempty"` // Requested amount in micro-credits
+	AdjustmentReason string `json:"adjustmentReason,omitempty"`
+
+	// DFNS transfer of an approval that started but could not be recorded,
+	// for the reconciler and the transfer's webhooks to find it by
+	TransferID string `json:"transferId,omitempty" gorm:"index"`
+}
+
+// RiskReasonList returns the risk reason codes as a slice
+func (wr *WithdrawalRequest) RiskReasonList() []string {
+	if wr.RiskReasons == "" {
+		return []string{}
+	}
+	return strings.Split(wr.RiskReasons, ",")
 }
 
 // TableName specifies the table name for CryptoTransaction
@@ -71,6 +131,16 @@ func (CryptoTransaction) TableName() string {
 	return "crypto_transactions"
 }
 
+// SameTransfer scopes a crypto transaction query to the records of the same
+// on-chain transfer as ct: its chain, hash, type, receiving address and log
+// index. One transaction can batch several transfers, even to the same
+// address, so the hash alone does not identify a transfer.
+func SameTransfer(ct *CryptoTransaction) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("chain_id = ? AND tx_hash = ? AND type = ? AND to_address = ? AND log_index = ?", ct.ChainID, ct.TxHash, ct.Type, ct.ToAddress, ct.LogIndex)
+	}
+}
+
 // TableName specifies the table name for WithdrawalRequest
 func (WithdrawalRequest) TableName() string {
 	return "withdrawal_requests"
@@ -114,5 +184,10 @@ func (wr *WithdrawalRequest) CanBeApproved() bool {
 
 // CanBeRejected returns true if the withdrawal can be rejected
 func (wr *WithdrawalRequest) CanBeRejected() bool {
-	return wr.Status == TxStatusPending
+	return wr.Status == TxStatusPending || wr.Status == TxStatusOnHold
+}
+
+// IsOnHold returns true if the withdrawal is held for screening review
+func (wr *WithdrawalRequest) IsOnHold() bool {
+	return wr.Status == TxStatusOnHold
 }
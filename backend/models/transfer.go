@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// CreditTransfer is credits sent from one user to another, such as a tip or
+// a community prize. Both sides are recorded in the ledger.
+type CreditTransfer struct {
+	gorm.Model
+	ID         uint   `json:"id" gorm:"primary_key"`
+	FromUserID int64  `json:"fromUserId" gorm:"index;not null"`
+	ToUserID   int64  `json:"toUserId" gorm:"index;not null"`
+	Amount     int64  `json:"amount" gorm:"not null"` // Micro-credits
+	Memo       string `json:"memo,omitempty"`
+}
+
+// TableName specifies the table name for CreditTransfer
+func (CreditTransfer) TableName() string {
+	return "credit_transfers"
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Treasury wallet kinds
+const (
+	TreasuryWalletHot  = "HOT"  // DFNS wallet that pays out withdrawals
+	TreasuryWalletCold = "COLD" // Cold storage; only receives sweeps from hot wallets
+)
+
+// Treasury transfer status constants
+const (
+	TreasuryTransferPending   = "PENDING"   // Recorded, not yet accepted by DFNS
+	TreasuryTransferSubmitted = "SUBMITTED" // Accepted by DFNS, awaiting the chain
+	TreasuryTransferCompleted = "COMPLETED"
+	TreasuryTransferFailed    = "FAILED"
+)
+
+// TreasuryWallet is a platform-owned wallet on one chain. Hot wallets are
+// DFNS wallets used for withdrawals; cold wallets only need an address.
+type TreasuryWallet struct {
+	gorm.Model
+	ID             uint       `json:"id" gorm:"primary_key"`
+	Name           string     `json:"name" gorm:"not null"`
+	Kind           string     `json:"kind" gorm:"index;not null"` // HOT or COLD
+	ChainID        int64      `json:"chainId" gorm:"not null"`
+	ChainName      string     `json:"chainName" gorm:"index;not null"`
+	Address        string     `json:"address" gorm:"not null"`
+	DfnsOrg        string     `json:"dfnsOrg,omitempty"`      // Hot wallets only
+	DfnsWalletID   string     `json:"dfnsWalletId,omitempty"` // Hot wallets only
+	BalanceCeiling int64      `json:"balanceCeiling"`         // Micro-credits; hot balances above this raise an alert; 0 for none
+	LastBalance    int64      `json:"lastBalance"`            // Micro-credits of stablecoins at the last check
+	LastCheckedAt  *time.Time `json:"lastCheckedAt,omitempty"`
+	LastAlertAt    *time.Time `json:"lastAlertAt,omitempty"`
+	IsActive       bool       `json:"isActive" gorm:"default:true"`
+	CreatedBy      string     `json:"createdBy"`
+}
+
+// TableName specifies the table name for TreasuryWallet
+func (TreasuryWallet) TableName() string {
+	return "treasury_wallets"
+}
+
+// TreasuryTransfer records a movement of funds between treasury wallets
+type TreasuryTransfer struct {
+	gorm.Model
+	ID             uint       `json:"id" gorm:"primary_key"`
+	FromWalletID   uint       `json:"fromWalletId" gorm:"index;not null"`
+	ToWalletID     uint       `json:"toWalletId" gorm:"index;not null"`
+	ChainName      string     `json:"chainName" gorm:"not null"`
+	TokenSymbol    string     `json:"tokenSymbol" gorm:"not null"`
+	TokenAddress   string     `json:"tokenAddress"`
+	Amount         int64      `json:"amount" gorm:"not null"` // Micro-credits
+	TokenAmount    string     `json:"tokenAmount"`            // Raw amount in token decimals
+	FromAddress    string     `json:"fromAddress"`
+	ToAddress      string     `json:"toAddress"`
+	Status         string     `json:"status" gorm:"index;not null"`
+	DfnsTransferID string     `json:"dfnsTransferId" gorm:"index"`
+	TxHash         string     `json:"txHash"`
+	InitiatedBy    string     `json:"initiatedBy" gorm:"not null"`
+	Note           string     `json:"note"`
+	ErrorMessage   string     `json:"errorMessage,omitempty"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+}
+
+// TableName specifies the table name for TreasuryTransfer
+func (TreasuryTransfer) TableName() string {
+	return "treasury_transfers"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Email token purposes
+const (
+	EmailTokenPurposeWithdrawal       = "WITHDRAWAL"
+	EmailTokenPurposeSecuritySettings = "SECURITY_SETTINGS" // Weakening an account protection
+)
+
+// UserTwoFactor holds a user's TOTP secret. The secret is stored on
+// enrollment and only protects anything once a code has been confirmed and
+// Enabled is set.
+type UserTwoFactor struct {
+	ID           uint       `json:"id" gorm:"primary_key"`
+	UserID       int64      `json:"userId" gorm:"uniqueIndex;not null"`
+	Secret       string     `json:"-" gorm:"not null"` // Base32 TOTP secret
+	Enabled      bool       `json:"enabled" gorm:"default:false"`
+	EnabledAt    *time.Time `json:"enabledAt,omitempty"`
+	LastUsedStep int64      `json:"-"` // TOTP time step of the last accepted code, so a code cannot be reused
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// EmailToken is a one-time code emailed to a user to confirm a sensitive
+// action. Only a hash of the code is stored.
+type EmailToken struct {
+	ID        uint       `json:"id" gorm:"primary_key"`
+	UserID    int64      `json:"userId" gorm:"index:idx_email_token_user_purpose;not null"`
+	Purpose   string     `json:"purpose" gorm:"index:idx_email_token_user_purpose;not null"`
+	TokenHash string     `json:"-" gorm:"not null"`
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"not null"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -11,6 +11,22 @@ type User struct {
 	PublicUser
 	PrivateUser
 	MustChangePassword bool `json:"mustChangePassword" gorm:"default:true"`
+	// BetAgainstPendingDeposits opts the user into betting with unconfirmed deposits
+	BetAgainstPendingDeposits bool `json:"betAgainstPendingDeposits" gorm:"default:false"`
+	// ProvisionalBalance is the betting-only allowance, in micro-credits, from unconfirmed deposits
+	ProvisionalBalance int64 `json:"provisionalBalance" gorm:"default:0"`
+	// ConfirmWithdrawalsByEmail holds each withdrawal until the user opens a link emailed to them
+	ConfirmWithdrawalsByEmail bool `json:"confirmWithdrawalsByEmail" gorm:"default:false"`
+	// BonusBalance is promotional credit, in micro-credits, included in the
+	// balance but not yet wagered. It cannot be withdrawn.
+	BonusBalance int64 `json:"bonusBalance" gorm:"default:0"`
+	// PendingBalance is market winnings, in micro-credits, still inside their
+	// settlement delay. They can be bet but not withdrawn until released.
+	PendingBalance int64 `json:"pendingBalance" gorm:"default:0"`
+	// UnconfirmedBalance is deposits, in micro-credits, credited before they
+	// reached their chain's confirmations. They can be bet but not withdrawn
+	// until confirmed, and are taken back if the deposit fails.
+	UnconfirmedBalance int64 `json:"unconfirmedBalance" gorm:"default:0"`
 }
 
 type PublicUser struct {
@@ -18,7 +34,7 @@ type PublicUser struct {
 	DisplayName           string `json:"displayname" gorm:"unique;not null"`
 	UserType              string `json:"usertype" gorm:"not null"`
 	InitialAccountBalance int64  `json:"initialAccountBalance"`
-	AccountBalance        int64  `json:"accountBalance"`
+	AccountBalance        int64  `json:"accountBalance"` // Micro-credits
 	PersonalEmoji         string `json:"personalEmoji,omitempty"`
 	Description           string `json:"description,omitempty"`
 	PersonalLink1         string `json:"personalink1,omitempty"`
@@ -33,6 +49,41 @@ type PrivateUser struct {
 	Password string `json:"password,omitempty" gorm:"not null"`
 }
 
+// BalanceMicroCredits returns the user's full balance in micro-credits.
+func (u *User) BalanceMicroCredits() int64 {
+	return u.AccountBalance
+}
+
+// WholeCredits returns the balance rounded down to whole credits, for the
+// betting and market maths that work in whole credits.
+func (u *User) WholeCredits() int64 {
+	whole, _ := MicroToWholeCredits(u.AccountBalance)
+	return whole
+}
+
+// BettingBalance returns the micro-credits available for betting, including
+// any provisional allowance from unconfirmed deposits.
+func (u *User) BettingBalance() int64 {
+	return u.AccountBalance + u.ProvisionalBalance
+}
+
+// AddMicroCredits adjusts the balance by delta micro-credits.
+func (u *User) AddMicroCredits(delta int64) {
+	u.AccountBalance += delta
+}
+
+// WithdrawableMicroCredits returns the balance less any unwagered bonus,
+// unreleased winnings and unconfirmed deposits, in micro-credits
+func (u *User) WithdrawableMicroCredits() int64 {
+	return max(u.BalanceMicroCredits()-u.BonusBalance-u.PendingBalance-u.UnconfirmedBalance, 0)
+}
+
+// SpendBonus uses up to spent micro-credits of the bonus balance. Bonus credit
+// is spent first, so once wagered its winnings are ordinary credits.
+func (u *User) SpendBonus(spent int64) {
+	u.BonusBalance = max(u.BonusBalance-max(spent, 0), 0)
+}
+
 // HashPassword hashes given password
 func (u *User) HashPassword(password string) error {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User webhook event types
+const (
+	WebhookEventBalanceChanged    = "balance.changed"
+	WebhookEventBetFilled         = "bet.filled"                // A bet or sale of the user's was placed
+	WebhookEventMarketResolved    = "market.resolved"           // A market the user bet in resolved
+	WebhookEventDepositCredited   = "deposit.credited"          // A crypto deposit was credited to the user
+	WebhookEventWithdrawalChanged = "withdrawal.status_changed" // One of the user's withdrawals changed status
+)
+
+// Webhook delivery status constants
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryFailed    = "FAILED" // Gave up after the maximum number of attempts
+)
+
+// UserWebhook is an outbound URL a user registered to receive signed events
+// about their own account
+type UserWebhook struct {
+	gorm.Model
+	ID          uint   `json:"id" gorm:"primary_key"`
+	UserID      int64  `json:"userId" gorm:"index;not null"`
+	URL         string `json:"url" gorm:"not null"`
+	Secret      string `json:"-" gorm:"not null"` // HMAC key for payload signatures
+	Events      string `json:"events" gorm:"not null"`
+	MinChange   int64  `json:"minChange"`   // Micro-credits; smaller balance changes are not reported
+	LastBalance int64  `json:"lastBalance"` // Micro-credit balance at the last balance event
+	Description string `json:"description,omitempty"`
+	IsActive    bool   `json:"isActive" gorm:"default:true"`
+}
+
+// TableName specifies the table name for UserWebhook
+func (UserWebhook) TableName() string {
+	return "user_webhooks"
+}
+
+// UserWebhookDelivery is one event queued for, or sent to, a user webhook
+type UserWebhookDelivery struct {
+	gorm.Model
+	ID            uint       `json:"id" gorm:"primary_key"`
+	WebhookID     uint       `json:"webhookId" gorm:"index;uniqueIndex:idx_webhook_delivery_reference,priority:1;not null"`
+	UserID        int64      `json:"userId" gorm:"index;not null"`
+	Event         string     `json:"event" gorm:"not null"`
+	ReferenceKey  *string    `json:"-" gorm:"uniqueIndex:idx_webhook_delivery_reference,priority:2"` // What the event is about, so a scan never queues it twice for a webhook
+	Payload       string     `json:"payload" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"index;not null"`
+	Attempts      int        `json:"attempts"`
+	ResponseCode  int        `json:"responseCode,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty" gorm:"index"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// TableName specifies the table name for UserWebhookDelivery
+func (UserWebhookDelivery) TableName() string {
+	return "user_webhook_deliveries"
+}
+
+// UserWebhookCursor is how far the event scan has read one source of account
+// events, such as bets or withdrawals
+type UserWebhookCursor struct {
+	Source    string    `gorm:"primaryKey"`
+	Position  time.Time `gorm:"not null"`
+	CreatedAt time.Time // When scanning the source began
+}
+
+// TableName specifies the table name for UserWebhookCursor
+func (UserWebhookCursor) TableName() string {
+	return "user_webhook_cursors"
+}
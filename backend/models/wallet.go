@@ -12,10 +12,30 @@ type Wallet struct {
 	ID           uint   `json:"id" gorm:"primary_key"`
 	UserID       int64  `json:"userId" gorm:"index;not null"`
 	DfnsWalletID string `json:"dfnsWalletId" gorm:"unique;not null"` // DFNS wallet identifier
+	DfnsOrg      string `json:"dfnsOrg" gorm:"default:primary"`      // DFNS organization that holds the wallet
 	ChainID      int64  `json:"chainId" gorm:"not null"`             // Chain ID (1=ETH mainnet, 11155111=Sepolia, 728126428=TRON, etc.)
 	ChainName    string `json:"chainName" gorm:"not null"`           // Human readable: "ethereum", "ethereum-sepolia", "tron", "tron-nile"
 	Address      string `json:"address" gorm:"index;not null"`       // Wallet address on this chain
 	IsActive     bool   `json:"isActive" gorm:"default:true"`
+
+	// Set when the wallet is rotated out. Deposits to a retired address are
+	// still credited until GraceUntil, then held for review.
+	DeactivatedAt      *time.Time `json:"deactivatedAt,omitempty"`
+	DeactivationReason string     `json:"deactivationReason,omitempty"`
+	GraceUntil         *time.Time `json:"graceUntil,omitempty"`
+	ReplacedByID       *uint      `json:"replacedById,omitempty"`
+
+	// DFNS's view of the wallet, as of the last wallet sync
+	DfnsName     string     `json:"dfnsName,omitempty"`
+	DfnsStatus   string     `json:"dfnsStatus,omitempty"` // "Active" or "Archived"
+	DfnsTags     []string   `json:"dfnsTags,omitempty" gorm:"serializer:json"`
+	DfnsSyncedAt *time.Time `json:"dfnsSyncedAt,omitempty"`
+}
+
+// AcceptsDeposits reports whether deposits to the wallet are credited at now.
+// Wallets deactivated without a grace period keep crediting, as before.
+func (w *Wallet) AcceptsDeposits(now time.Time) bool {
+	return w.IsActive || w.GraceUntil == nil || now.Before(*w.GraceUntil)
 }
 
 // SupportedChain represents a blockchain that the platform supports
@@ -27,11 +47,13 @@ type SupportedChain struct {
 	DisplayName      string `json:"displayName" gorm:"not null"` // "Ethereum Mainnet"
 	RpcURL           string `json:"rpcUrl"`
 	ExplorerURL      string `json:"explorerUrl"`
-	USDCAddress      string `json:"usdcAddress"`                   // USDC contract address on this chain
-	USDTAddress      string `json:"usdtAddress"`                   // USDT contract address on this chain
 	MinConfirmations int    `json:"minConfirmations" gorm:"default:12"`
 	IsActive         bool   `json:"isActive" gorm:"default:true"`
 	IconURL          string `json:"iconUrl"`
+	// GraceCrediting credits deposits as soon as they are seen, before
+	// MinConfirmations, keeping them out of the withdrawable balance until
+	// they confirm. Meant for chains with long confirmation times.
+	GraceCrediting bool `json:"graceCrediting" gorm:"default:false"`
 }
 
 // SupportedToken represents a token that can be deposited/withdrawn
@@ -45,6 +67,21 @@ type SupportedToken struct {
 	IconURL  string `json:"iconUrl"`
 }
 
+// ChainToken is a token accepted on a chain, at that chain's contract address
+type ChainToken struct {
+	ID               uint           `json:"id" gorm:"primary_key"`
+	SupportedChainID uint           `json:"supportedChainId" gorm:"uniqueIndex:idx_chain_tokens_chain_token;not null"`
+	SupportedTokenID uint           `json:"supportedTokenId" gorm:"uniqueIndex:idx_chain_tokens_chain_token;index;not null"`
+	ContractAddress  string         `json:"contractAddress" gorm:"not null"`
+	Decimals         int            `json:"decimals" gorm:"not null"` // Of this contract, which can differ between chains
+	MinDeposit       int64          `json:"minDeposit" gorm:"not null;default:0"` // Micro-credits; smaller deposits are recorded DUST and not credited
+	IsActive         bool           `json:"isActive" gorm:"not null"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	UpdatedAt        time.Time      `json:"updatedAt"`
+	Chain            SupportedChain `json:"-" gorm:"foreignKey:SupportedChainID"`
+	Token            SupportedToken `json:"token" gorm:"foreignKey:SupportedTokenID"`
+}
+
 // ChainInfo maps chain names to their IDs and DFNS network names
 var ChainInfo = map[string]struct {
 	ChainID     int64
@@ -108,6 +145,11 @@ func (SupportedToken) TableName() string {
 	return "supported_tokens"
 }
 
+// TableName specifies the table name for ChainToken
+func (ChainToken) TableName() string {
+	return "chain_tokens"
+}
+
 // BeforeCreate hook to set creation timestamp
 func (w *Wallet) BeforeCreate(tx *gorm.DB) error {
 	if w.CreatedAt.IsZero() {
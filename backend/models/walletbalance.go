@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// WalletBalanceSnapshot is a deposit wallet's on-chain balance of one token as
+// DFNS last reported it. Snapshots are refreshed when a user lists their
+// wallets and are older than the refresh interval.
+type WalletBalanceSnapshot struct {
+	ID              uint      `json:"-" gorm:"primary_key"`
+	WalletID        uint      `json:"-" gorm:"uniqueIndex:idx_wallet_balance_snapshot;not null"`
+	TokenSymbol     string    `json:"tokenSymbol" gorm:"uniqueIndex:idx_wallet_balance_snapshot;not null"`
+	ContractAddress string    `json:"contractAddress"`
+	Balance         int64     `json:"balance"` // Micro-credits
+	FetchedAt       time.Time `json:"fetchedAt" gorm:"not null"`
+}
+
+// TableName specifies the table name for WalletBalanceSnapshot
+func (WalletBalanceSnapshot) TableName() string {
+	return "wallet_balance_snapshots"
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// WalletSettings are a user's defaults for the wallet: the chain and token
+// the withdrawal form starts on and which wallet notifications they receive.
+// Users without a row have no defaults and every notification on.
+type WalletSettings struct {
+	ID             uint   `json:"-" gorm:"primary_key"`
+	UserID         int64  `json:"-" gorm:"uniqueIndex;not null"`
+	PreferredChain string `json:"preferredChain"`
+	PreferredToken string `json:"preferredToken"` // Only set together with PreferredChain
+
+	// Notification preferences, stored as opt-outs so a missing row means on
+	MuteWithdrawalCompleted bool `json:"muteWithdrawalCompleted"`
+
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for WalletSettings
+func (WalletSettings) TableName() string {
+	return "wallet_settings"
+}
+
+// SavedAddress is a withdrawal destination the user saved under a label. The
+// chain's default address fills in withdrawals that give none.
+type SavedAddress struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	UserID    int64     `json:"-" gorm:"uniqueIndex:idx_saved_address;not null"`
+	ChainName string    `json:"chainName" gorm:"uniqueIndex:idx_saved_address;not null"`
+	Address   string    `json:"address" gorm:"uniqueIndex:idx_saved_address;not null"`
+	Label     string    `json:"label"`
+	IsDefault bool      `json:"isDefault" gorm:"default:false"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for SavedAddress
+func (SavedAddress) TableName() string {
+	return "saved_addresses"
+}
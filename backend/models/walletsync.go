@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Wallet sync issue kinds
+const (
+	WalletSyncOrphaned = "ORPHANED" // DFNS wallet with no local record
+	WalletSyncMissing  = "MISSING"  // Local wallet that DFNS does not list
+	WalletSyncArchived = "ARCHIVED" // Active local wallet that DFNS has archived
+)
+
+// WalletSyncIssue is a mismatch between DFNS and the local wallet records
+// found by the wallet sync, waiting for admin review. There is one issue per
+// kind and DFNS wallet; a resolved issue is not raised again.
+type WalletSyncIssue struct {
+	ID           uint       `json:"id" gorm:"primary_key"`
+	Kind         string     `json:"kind" gorm:"uniqueIndex:idx_wallet_sync_issue;not null"`
+	DfnsOrg      string     `json:"dfnsOrg" gorm:"uniqueIndex:idx_wallet_sync_issue;not null"`
+	DfnsWalletID string     `json:"dfnsWalletId" gorm:"uniqueIndex:idx_wallet_sync_issue;not null"`
+	WalletID     *uint      `json:"walletId,omitempty"`         // Local user wallet, if any
+	TreasuryID   *uint      `json:"treasuryWalletId,omitempty"` // Local treasury wallet, if any
+	Network      string     `json:"network,omitempty"`
+	Address      string     `json:"address,omitempty"`
+	Details      string     `json:"details"`
+	DetectedAt   time.Time  `json:"detectedAt" gorm:"not null"`
+	LastSeenAt   time.Time  `json:"lastSeenAt" gorm:"not null"`
+	ResolvedAt   *time.Time `json:"resolvedAt,omitempty" gorm:"index"`
+	ResolvedBy   string     `json:"resolvedBy,omitempty"` // Admin username, or "sync" when the mismatch cleared
+	Note         string     `json:"note,omitempty"`
+}
+
+// TableName specifies the table name for WalletSyncIssue
+func (WalletSyncIssue) TableName() string {
+	return "wallet_sync_issues"
+}
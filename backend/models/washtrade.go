@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Wash trading pattern constants
+const (
+	WashPatternSelfRoundTrip  = "SELF_ROUND_TRIP" // A user buys and sells, or buys both outcomes, in quick succession
+	WashPatternLinkedOpposite = "LINKED_OPPOSITE" // Accounts sharing an external address take opposite sides
+)
+
+// WashTradeFlag records a pair of bets that look like a user or linked accounts
+// trading against themselves. The pair is unique so rescans are idempotent.
+type WashTradeFlag struct {
+	gorm.Model
+	ID           uint      `json:"id" gorm:"primary_key"`
+	MarketID     uint      `json:"marketId" gorm:"index;not null"`
+	Username     string    `json:"username" gorm:"index;not null"`
+	Counterparty string    `json:"counterparty" gorm:"not null"` // Same as Username for self round trips
+	Pattern      string    `json:"pattern" gorm:"not null"`
+	BetID        uint      `json:"betId" gorm:"uniqueIndex:idx_wash_trade_pair;not null"`
+	CounterBetID uint      `json:"counterBetId" gorm:"uniqueIndex:idx_wash_trade_pair;not null"`
+	Volume       int64     `json:"volume" gorm:"not null"` // Combined absolute amount of both bets
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// TableName specifies the table name for WashTradeFlag
+func (WashTradeFlag) TableName() string {
+	return "wash_trade_flags"
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// WebhookHeartbeat records the last DFNS webhook processed for a chain, so
+// health checks can spot a chain whose webhooks have silently stopped
+type WebhookHeartbeat struct {
+	ChainName     string    `json:"chainName" gorm:"primaryKey"`
+	LastEventAt   time.Time `json:"lastEventAt" gorm:"not null"`
+	LastEventKind string    `json:"lastEventKind"`
+	LastEventID   string    `json:"lastEventId"`
+}
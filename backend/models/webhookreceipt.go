@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// WebhookReceipt records an inbound webhook event so a replayed delivery of
+// the same event is recognised and ignored
+type WebhookReceipt struct {
+	ID         uint      `json:"id" gorm:"primary_key"`
+	Source     string    `json:"source" gorm:"uniqueIndex:idx_webhook_receipt_event;not null"` // e.g. "dfns:primary"
+	EventID    string    `json:"eventId" gorm:"uniqueIndex:idx_webhook_receipt_event;not null"`
+	ReceivedAt time.Time `json:"receivedAt" gorm:"index;not null"`
+}
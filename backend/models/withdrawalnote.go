@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// WithdrawalNote is a reviewer's note on a withdrawal request. Notes are
+// internal to admins and kept as a thread, oldest first; approval, rejection
+// and hold-release notes are added to it too.
+type WithdrawalNote struct {
+	ID           uint      `json:"id" gorm:"primary_key"`
+	WithdrawalID uint      `json:"withdrawalId" gorm:"index;not null"`
+	AdminID      *int64    `json:"adminId,omitempty"` // Nil for notes the system adds
+	Author       string    `json:"author" gorm:"not null"`
+	Body         string    `json:"body" gorm:"type:text;not null"`
+	Flag         bool      `json:"flag" gorm:"default:false"` // Asks other reviewers to look at the request before acting on it
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for WithdrawalNote
+func (WithdrawalNote) TableName() string {
+	return "withdrawal_notes"
+}
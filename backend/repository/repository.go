@@ -0,0 +1,137 @@
+// Package repository holds the data access used by the wallet and withdrawal
+// handlers. Handlers depend on the interfaces here rather than on a global
+// database handle, so they can be unit tested against stubs, and every query
+// runs under the request's context so it is cancelled with the request.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/walletsettings"
+
+	"gorm.io/gorm"
+)
+
+// ErrWithdrawalStatusChanged is returned when a withdrawal left a rejectable
+// status while the rejection waited for its lock
+var ErrWithdrawalStatusChanged = errors.New("withdrawal status changed")
+
+// WithdrawalFilter narrows the admin withdrawal listing
+type WithdrawalFilter struct {
+	Status         string
+	MinRisk        *int
+	Country        string // Upper-case ISO country code
+	NewCountryOnly bool   // Only withdrawals from a country the user had not withdrawn from before
+	SortByRisk     bool   // Riskiest first instead of newest first
+	Offset         int
+	Limit          int
+}
+
+// StatusTotal is the number and micro-credit sum of withdrawals in one status
+type StatusTotal struct {
+	Count  int64
+	Amount int64
+}
+
+// WithdrawalListing is a withdrawal request with its user's username
+type WithdrawalListing struct {
+	models.WithdrawalRequest
+	Username string
+}
+
+// Rejection is an admin's refusal of a withdrawal
+type Rejection struct {
+	AdminID   int64
+	AdminName string
+	Reason    string
+	At        time.Time
+}
+
+// WithdrawalRepo reads and records withdrawal requests
+type WithdrawalRepo interface {
+	Get(ctx context.Context, id uint) (*models.WithdrawalRequest, error)
+	Create(ctx context.Context, req *models.WithdrawalRequest) error
+	// Countries returns the distinct known countries the user has withdrawn from
+	Countries(ctx context.Context, userID int64) ([]string, error)
+	// Reject refunds a withdrawal to its user and marks it rejected, in one
+	// transaction under locks on the request and the user. It returns
+	// ErrWithdrawalStatusChanged, with the request as found, if the request
+	// can no longer be rejected.
+	Reject(ctx context.Context, id uint, rejection Rejection) (*models.WithdrawalRequest, *models.User, error)
+	// List returns one page of withdrawals matching filter, with usernames
+	// joined in, and the total number of matches
+	List(ctx context.Context, filter WithdrawalFilter) ([]WithdrawalListing, int64, error)
+	// ListByUser returns a user's withdrawals newest first; limit 0 returns all of them
+	ListByUser(ctx context.Context, userID int64, limit int) ([]models.WithdrawalRequest, error)
+	// TotalsByStatus returns the count and sum of withdrawals in each status
+	TotalsByStatus(ctx context.Context) (map[string]StatusTotal, error)
+}
+
+// TransactionFilter narrows a user's crypto transaction history
+type TransactionFilter struct {
+	UserID int64
+	Type   string // DEPOSIT or WITHDRAWAL; empty for both
+	Status string
+}
+
+// TransactionPage selects a page of transactions, newest first. When
+// AfterID is set the page starts after that (CreatedAt, ID) position,
+// otherwise Offset rows are skipped. A zero Limit returns every row.
+type TransactionPage struct {
+	AfterCreatedAt time.Time
+	AfterID        uint
+	Offset         int
+	Limit          int
+}
+
+// TransactionRepo reads crypto transactions
+type TransactionRepo interface {
+	Get(ctx context.Context, id uint) (*models.CryptoTransaction, error)
+	// GetByDfnsID returns the transaction DFNS knows by dfnsID
+	GetByDfnsID(ctx context.Context, dfnsID string) (*models.CryptoTransaction, error)
+	// GetForUser returns the transaction only if it belongs to userID
+	GetForUser(ctx context.Context, userID int64, id uint) (*models.CryptoTransaction, error)
+	Count(ctx context.Context, filter TransactionFilter) (int64, error)
+	Page(ctx context.Context, filter TransactionFilter, page TransactionPage) ([]models.CryptoTransaction, error)
+	// HashesByID returns the on-chain hash of each transaction in ids
+	HashesByID(ctx context.Context, ids []uint) (map[uint]string, error)
+}
+
+// WalletRepo reads users' deposit wallets and keeps their wallet settings
+type WalletRepo interface {
+	// ActiveForChain returns the user's active wallet on chainName
+	ActiveForChain(ctx context.Context, userID int64, chainName string) (*models.Wallet, error)
+	// ListByUser returns all of a user's wallets, oldest first, retired ones included
+	ListByUser(ctx context.Context, userID int64) ([]models.Wallet, error)
+	Settings(ctx context.Context, userID int64) (*walletsettings.Settings, error)
+	UpdateSettings(ctx context.Context, userID int64, patch walletsettings.Patch) (*walletsettings.Settings, error)
+	// WithdrawalDefaults fills in a withdrawal's missing chain, token and
+	// destination from the user's settings
+	WithdrawalDefaults(ctx context.Context, userID int64, chainName, tokenSymbol, toAddress *string) error
+}
+
+// UserRepo reads users
+type UserRepo interface {
+	Get(ctx context.Context, id int64) (*models.User, error)
+}
+
+// Repos bundles the repositories a handler may need
+type Repos struct {
+	Withdrawals  WithdrawalRepo
+	Transactions TransactionRepo
+	Wallets      WalletRepo
+	Users        UserRepo
+}
+
+// NewGormRepos returns GORM-backed repositories over db
+func NewGormRepos(db *gorm.DB) Repos {
+	return Repos{
+		Withdrawals:  NewGormWithdrawalRepo(db),
+		Transactions: NewGormTransactionRepo(db),
+		Wallets:      NewGormWalletRepo(db),
+		Users:        NewGormUserRepo(db),
+	}
+}
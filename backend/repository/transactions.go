@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+type GormTransactionRepo struct {
+	db *gorm.DB
+}
+
+func NewGormTransactionRepo(db *gorm.DB) *GormTransactionRepo {
+	return &GormTransactionRepo{db: db}
+}
+
+func (r *GormTransactionRepo) Get(ctx context.Context, id uint) (*models.CryptoTransaction, error) {
+	var tx models.CryptoTransaction
+	if err := r.db.WithContext(ctx).First(&tx, id).Error; err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *GormTransactionRepo) GetByDfnsID(ctx context.Context, dfnsID string) (*models.CryptoTransaction, error) {
+	var tx models.CryptoTransaction
+	if err := r.db.WithContext(ctx).Where("dfns_tx_id = ?", dfnsID).First(&tx).Error; err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *GormTransactionRepo) GetForUser(ctx context.Context, userID int64, id uint) (*models.CryptoTransaction, error) {
+	var tx models.CryptoTransaction
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&tx).Error; err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *GormTransactionRepo) filtered(ctx context.Context, filter TransactionFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.CryptoTransaction{}).Where("user_id = ?", filter.UserID)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	return query
+}
+
+func (r *GormTransactionRepo) Count(ctx context.Context, filter TransactionFilter) (int64, error) {
+	var total int64
+	err := r.filtered(ctx, filter).Count(&total).Error
+	return total, err
+}
+
+func (r *GormTransactionRepo) Page(ctx context.Context, filter TransactionFilter, page TransactionPage) ([]models.CryptoTransaction, error) {
+	query := r.filtered(ctx, filter)
+	if page.AfterID != 0 {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", page.AfterCreatedAt, page.AfterCreatedAt, page.AfterID)
+	} else {
+		query = query.Offset(page.Offset)
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+	var transactions []models.CryptoTransaction
+	if err := query.Order("created_at DESC, id DESC").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *GormTransactionRepo) HashesByID(ctx context.Context, ids []uint) (map[uint]string, error) {
+	hashes := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return hashes, nil
+	}
+	var txs []models.CryptoTransaction
+	if err := r.db.WithContext(ctx).Select("id, tx_hash").Where("id IN ?", ids).Find(&txs).Error; err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		hashes[tx.ID] = tx.TxHash
+	}
+	return hashes, nil
+}
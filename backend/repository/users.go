@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+type GormUserRepo struct {
+	db *gorm.DB
+}
+
+func NewGormUserRepo(db *gorm.DB) *GormUserRepo {
+	return &GormUserRepo{db: db}
+}
+
+func (r *GormUserRepo) Get(ctx context.Context, id int64) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
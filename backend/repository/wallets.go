@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"socialpredict/models"
+	"socialpredict/services/walletsettings"
+
+	"gorm.io/gorm"
+)
+
+type GormWalletRepo struct {
+	db *gorm.DB
+}
+
+func NewGormWalletRepo(db *gorm.DB) *GormWalletRepo {
+	return &GormWalletRepo{db: db}
+}
+
+func (r *GormWalletRepo) ActiveForChain(ctx context.Context, userID int64, chainName string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.WithContext(ctx).Where("user_id = ? AND chain_name = ? AND is_active = ?", userID, chainName, true).First(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *GormWalletRepo) ListByUser(ctx context.Context, userID int64) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+func (r *GormWalletRepo) Settings(ctx context.Context, userID int64) (*walletsettings.Settings, error) {
+	return walletsettings.Get(r.db.WithContext(ctx), userID)
+}
+
+func (r *GormWalletRepo) UpdateSettings(ctx context.Context, userID int64, patch walletsettings.Patch) (*walletsettings.Settings, error) {
+	return walletsettings.Update(r.db.WithContext(ctx), userID, patch)
+}
+
+func (r *GormWalletRepo) WithdrawalDefaults(ctx context.Context, userID int64, chainName, tokenSymbol, toAddress *string) error {
+	return walletsettings.Defaults(r.db.WithContext(ctx), userID, chainName, tokenSymbol, toAddress)
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/withdrawalnotes"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormWithdrawalRepo struct {
+	db *gorm.DB
+}
+
+func NewGormWithdrawalRepo(db *gorm.DB) *GormWithdrawalRepo {
+	return &GormWithdrawalRepo{db: db}
+}
+
+func (r *GormWithdrawalRepo) Get(ctx context.Context, id uint) (*models.WithdrawalRequest, error) {
+	var req models.WithdrawalRequest
+	if err := r.db.WithContext(ctx).First(&req, id).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *GormWithdrawalRepo) Create(ctx context.Context, req *models.WithdrawalRequest) error {
+	return r.db.WithContext(ctx).Create(req).Error
+}
+
+func (r *GormWithdrawalRepo) Countries(ctx context.Context, userID int64) ([]string, error) {
+	var countries []string
+	err := r.db.WithContext(ctx).Model(&models.WithdrawalRequest{}).
+		Where("user_id = ? AND country <> ''", userID).
+		Distinct().Pluck("country", &countries).Error
+	return countries, err
+}
+
+func (r *GormWithdrawalRepo) Reject(ctx context.Context, id uint, rejection Rejection) (*models.WithdrawalRequest, *models.User, error) {
+	var req models.WithdrawalRequest
+	var user *models.User
+	// The request is locked and re-checked so two admins (or an approval)
+	// racing on it cannot refund it twice, and the user is locked so the
+	// refund is not lost to a concurrent balance change
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&req, id).Error; err != nil {
+			return err
+		}
+		if !req.CanBeRejected() {
+			return ErrWithdrawalStatusChanged
+		}
+
+		var err error
+		if user, err = ledger.LockUser(tx, req.UserID); err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+		if _, err := ledger.Apply(tx, user, ledger.Posting{
+			Type:          models.LedgerTypeWithdrawalRefund,
+			Amount:        req.Amount,
+			ReferenceType: ledger.ReferenceTypeWithdrawalRequest,
+			ReferenceID:   req.ID,
+			Description:   "Refund of rejected withdrawal: " + rejection.Reason,
+		}); err != nil {
+			return fmt.Errorf("failed to refund user balance: %w", err)
+		}
+
+		req.Status = models.TxStatusRejected
+		req.AdminID = &rejection.AdminID
+		req.ErrorMessage = rejection.Reason
+		req.ProcessedAt = &rejection.At
+		if err := tx.Save(&req).Error; err != nil {
+			return err
+		}
+		return withdrawalnotes.Add(tx, models.WithdrawalNote{WithdrawalID: req.ID, AdminID: &rejection.AdminID, Author: rejection.AdminName, Body: "Rejected: " + rejection.Reason})
+	})
+	if err != nil {
+		return &req, nil, err
+	}
+	return &req, user, nil
+}
+
+func (r *GormWithdrawalRepo) List(ctx context.Context, filter WithdrawalFilter) ([]WithdrawalListing, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.WithdrawalRequest{})
+	if filter.Status != "" {
+		query = query.Where("withdrawal_requests.status = ?", filter.Status)
+	}
+	if filter.MinRisk != nil {
+		query = query.Where("withdrawal_requests.risk_score >= ?", *filter.MinRisk)
+	}
+	if filter.Country != "" {
+		query = query.Where("withdrawal_requests.country = ?", filter.Country)
+	}
+	if filter.NewCountryOnly {
+		query = query.Where("withdrawal_requests.new_country = ?", true)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "withdrawal_requests.created_at DESC"
+	if filter.SortByRisk {
+		order = "withdrawal_requests.risk_score DESC, withdrawal_requests.created_at DESC"
+	}
+	// Usernames come from the same query rather than a lookup per row
+	var listings []WithdrawalListing
+	err := query.Select("withdrawal_requests.*, users.username AS username").
+		Joins("LEFT JOIN users ON users.id = withdrawal_requests.user_id").
+		Order(order).Offset(filter.Offset).Limit(filter.Limit).
+		Scan(&listings).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return listings, total, nil
+}
+
+func (r *GormWithdrawalRepo) ListByUser(ctx context.Context, userID int64, limit int) ([]models.WithdrawalRequest, error) {
+	query := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var requests []models.WithdrawalRequest
+	if err := query.Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *GormWithdrawalRepo) TotalsByStatus(ctx context.Context) (map[string]StatusTotal, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+		Amount int64
+	}
+	err := r.db.WithContext(ctx).Model(&models.WithdrawalRequest{}).
+		Select("status, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]StatusTotal, len(rows))
+	for _, row := range rows {
+		totals[row.Status] = StatusTotal{Count: row.Count, Amount: row.Amount}
+	}
+	return totals, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func TestWithdrawalListJoinsUsernames(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	alice := modelstesting.GenerateUser("alice", 0)
+	bob := modelstesting.GenerateUser("bob", 0)
+	db.Create(&alice)
+	db.Create(&bob)
+
+	base := time.Date(2026, 5, 20, 9, 0, 0, 0, time.UTC)
+	for i, w := range []struct {
+		userID int64
+		status string
+		risk   int
+	}{
+		{alice.ID, models.TxStatusPending, 10},
+		{bob.ID, models.TxStatusPending, 80},
+		{alice.ID, models.TxStatusCompleted, 90},
+		{999, models.TxStatusPending, 0}, // User since removed
+	} {
+		req := models.WithdrawalRequest{UserID: w.userID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+			Amount: models.CreditsToMicro(10), ToAddress: "0xabc", Status: w.status, RiskScore: w.risk}
+		req.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := db.Create(&req).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+
+	repo := NewGormWithdrawalRepo(db)
+	listings, total, err := repo.List(context.Background(), WithdrawalFilter{Status: models.TxStatusPending, Limit: 10})
+	if err != nil || total != 3 || len(listings) != 3 {
+		t.Fatalf("list = %d of %d, %v", len(listings), total, err)
+	}
+	// Newest first
+	if listings[0].Username != "" || listings[1].Username != "bob" || listings[2].Username != "alice" {
+		t.Fatalf("usernames = %q, %q, %q", listings[0].Username, listings[1].Username, listings[2].Username)
+	}
+	if listings[1].ID == 0 || listings[1].UserID != bob.ID || listings[1].Amount != models.CreditsToMicro(10) {
+		t.Fatalf("listing = %+v", listings[1].WithdrawalRequest)
+	}
+
+	listings, _, err = repo.List(context.Background(), WithdrawalFilter{SortByRisk: true, Limit: 2})
+	if err != nil || len(listings) != 2 || listings[0].RiskScore != 90 || listings[1].Username != "bob" {
+		t.Fatalf("riskiest = %+v, %v", listings, err)
+	}
+}
+
+// seedWithdrawals creates users and n withdrawal requests spread across them
+func seedWithdrawals(b *testing.B, n int) *gorm.DB {
+	b.Helper()
+	db := modelstesting.NewFakeDB(b)
+	const users = 1000
+	for i := 0; i < users; i++ {
+		user := modelstesting.GenerateUser(fmt.Sprintf("user%d", i), 0)
+		if err := db.Create(&user).Error; err != nil {
+			b.Fatalf("create user: %v", err)
+		}
+	}
+	statuses := []string{models.TxStatusPending, models.TxStatusCompleted, models.TxStatusRejected, models.TxStatusFailed}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := make([]models.WithdrawalRequest, 0, 1000)
+	for i := 0; i < n; i++ {
+		req := models.WithdrawalRequest{UserID: int64(i%users + 1), ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+			Amount: models.CreditsToMicro(10), ToAddress: "0xabc", Status: statuses[i%len(statuses)], RiskScore: i % 100}
+		req.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		batch = append(batch, req)
+		if len(batch) == cap(batch) || i == n-1 {
+			if err := db.CreateInBatches(batch, 500).Error; err != nil {
+				b.Fatalf("create withdrawals: %v", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	return db
+}
+
+// BenchmarkWithdrawalList compares a page of the admin list with usernames
+// joined in against looking each username up separately, over 100k requests:
+//
+//	go test ./repository -run '^$' -bench WithdrawalList
+func BenchmarkWithdrawalList(b *testing.B) {
+	db := seedWithdrawals(b, 100_000)
+	ctx := context.Background()
+	filter := WithdrawalFilter{Status: models.TxStatusPending, Limit: 100}
+
+	b.Run("Joined", func(b *testing.B) {
+		repo := NewGormWithdrawalRepo(db)
+		for i := 0; i < b.N; i++ {
+			if _, _, err := repo.List(ctx, filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("PerRowLookup", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var requests []models.WithdrawalRequest
+			var total int64
+			query := db.Model(&models.WithdrawalRequest{}).Where("status = ?", filter.Status)
+			query.Session(&gorm.Session{}).Count(&total)
+			query.Order("created_at DESC").Limit(filter.Limit).Find(&requests)
+			for _, req := range requests {
+				var user models.User
+				db.Select("username").First(&user, req.UserID)
+			}
+		}
+	})
+}
+
+func TestWithdrawalRejectRefundsOnce(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	alice := modelstesting.CreateUser(t, db, "alice", 75)
+	repo := NewGormWithdrawalRepo(db)
+	ctx := context.Background()
+
+	req := models.WithdrawalRequest{UserID: alice.ID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(25), ToAddress: "0xabc", Status: models.TxStatusPending, Country: "US"}
+	if err := repo.Create(ctx, &req); err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+	if countries, err := repo.Countries(ctx, alice.ID); err != nil || len(countries) != 1 || countries[0] != "US" {
+		t.Fatalf("countries = %v, %v", countries, err)
+	}
+
+	at := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	rejection := Rejection{AdminID: 42, AdminName: "finance", Reason: "fraud", At: at}
+	rejected, user, err := repo.Reject(ctx, req.ID, rejection)
+	if err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if rejected.Status != models.TxStatusRejected || *rejected.AdminID != 42 || !rejected.ProcessedAt.Equal(at) {
+		t.Fatalf("rejected = %+v", rejected)
+	}
+	if user.BalanceMicroCredits() != models.CreditsToMicro(100) {
+		t.Fatalf("balance = %d micro, want 100 credits", user.BalanceMicroCredits())
+	}
+	var notes int64
+	db.Model(&models.WithdrawalNote{}).Where("withdrawal_id = ?", req.ID).Count(&notes)
+	if notes != 1 {
+		t.Fatalf("notes = %d, want 1", notes)
+	}
+
+	if _, _, err := repo.Reject(ctx, req.ID, rejection); !errors.Is(err, ErrWithdrawalStatusChanged) {
+		t.Fatalf("second reject err = %v, want ErrWithdrawalStatusChanged", err)
+	}
+	db.First(&alice, alice.ID)
+	if alice.BalanceMicroCredits() != models.CreditsToMicro(100) {
+		t.Fatalf("balance after second reject = %d micro, want 100 credits", alice.BalanceMicroCredits())
+	}
+}
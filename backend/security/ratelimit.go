@@ -88,7 +88,7 @@ func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get client IP
-			ip := getClientIP(r)
+			ip := ClientIP(r)
 
 			// Check rate limit
 			if !limiter.GetLimiter(ip).Allow() {
@@ -106,7 +106,7 @@ func LoginRateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Hand
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get client IP
-			ip := getClientIP(r)
+			ip := ClientIP(r)
 
 			// Check rate limit
 			if !limiter.GetLimiter(ip).Allow() {
@@ -119,8 +119,8 @@ func LoginRateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Hand
 	}
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
+// ClientIP extracts the client IP address from the request
+func ClientIP(r *http.Request) string {
 	// Check for forwarded IP first (if behind proxy)
 	forwarded := r.Header.Get("X-Forwarded-For")
 	if forwarded != "" {
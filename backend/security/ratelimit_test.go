@@ -174,9 +174,9 @@ func TestGetClientIP(t *testing.T) {
 				req.Header.Set("X-Real-IP", tt.realIP)
 			}
 
-			result := getClientIP(req)
+			result := ClientIP(req)
 			if result != tt.expectedPrefix {
-				t.Errorf("getClientIP() = %v, want %v", result, tt.expectedPrefix)
+				t.Errorf("ClientIP() = %v, want %v", result, tt.expectedPrefix)
 			}
 		})
 	}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"socialpredict/models"
+	"socialpredict/services/roles"
 	"socialpredict/setup"
 	"time"
 
@@ -46,7 +47,7 @@ func SeedUsers(db *gorm.DB) {
 					DisplayName:           "Administrator",
 					UserType:              "ADMIN",
 					InitialAccountBalance: config.Economics.User.InitialAccountBalance,
-					AccountBalance:        config.Economics.User.InitialAccountBalance,
+					AccountBalance:        models.CreditsToMicro(config.Economics.User.InitialAccountBalance),
 					PersonalEmoji:         "NONE",
 					Description:           "Administrator",
 				},
@@ -60,7 +61,9 @@ func SeedUsers(db *gorm.DB) {
 			adminUser.HashPassword(adminPassword)
 
 			db.Create(&adminUser)
-
+			if err := roles.Assign(db, adminUser.ID, models.RoleSuperAdmin, "seed"); err != nil {
+				log.Printf("Failed to make the admin user a superadmin: %v", err)
+			}
 		}
 	}
 
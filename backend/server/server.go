@@ -1,9 +1,14 @@
 package server
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"socialpredict/api"
+	"socialpredict/clock"
+	graphqlapi "socialpredict/graphql"
+	grpcapi "socialpredict/grpc"
 	"socialpredict/handlers"
 	adminhandlers "socialpredict/handlers/admin"
 	betshandlers "socialpredict/handlers/bets"
@@ -11,6 +16,7 @@ import (
 	sellbetshandlers "socialpredict/handlers/bets/selling"
 	"socialpredict/handlers/cms/homepage"
 	cmshomehttp "socialpredict/handlers/cms/homepage/http"
+	healthhandlers "socialpredict/handlers/health"
 	marketshandlers "socialpredict/handlers/markets"
 	metricshandlers "socialpredict/handlers/metrics"
 	positions "socialpredict/handlers/positions"
@@ -21,13 +27,65 @@ import (
 	privateuser "socialpredict/handlers/users/privateuser"
 	"socialpredict/handlers/users/publicuser"
 	wallethandlers "socialpredict/handlers/wallet"
+	"socialpredict/logger"
 	"socialpredict/middleware"
+	"socialpredict/models"
+	"socialpredict/repository"
 	"socialpredict/security"
+	"socialpredict/services/addresslabels"
+	"socialpredict/services/apikeys"
+	"socialpredict/services/attestation"
+	"socialpredict/services/bonus"
+	"socialpredict/services/chainscan"
+	"socialpredict/services/comments"
+	"socialpredict/services/corrections"
+	"socialpredict/services/devices"
 	"socialpredict/services/dfns"
+	"socialpredict/services/evmrpc"
+	"socialpredict/services/financialreport"
+	"socialpredict/services/geoip"
+	"socialpredict/services/groups"
+	"socialpredict/services/health"
+	"socialpredict/services/housebot"
+	"socialpredict/services/housemm"
+	"socialpredict/services/impersonation"
+	"socialpredict/services/leaderboard"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/mailer"
+	"socialpredict/services/marketclose"
+	"socialpredict/services/metrics"
+	"socialpredict/services/moderation"
+	"socialpredict/services/oracle"
+	"socialpredict/services/orders"
+	"socialpredict/services/receipts"
+	"socialpredict/services/referrals"
+	"socialpredict/services/replay"
+	"socialpredict/services/resolutioncost"
+	"socialpredict/services/saga"
+	"socialpredict/services/screening"
+	"socialpredict/services/secrets"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/services/series"
+	"socialpredict/services/settings"
+	"socialpredict/services/settlement"
+	"socialpredict/services/stream"
+	"socialpredict/services/tenants"
+	"socialpredict/services/transfers"
+	"socialpredict/services/travelrule"
+	"socialpredict/services/treasury"
+	"socialpredict/services/twofactor"
+	"socialpredict/services/userhooks"
+	"socialpredict/services/walletbalances"
+	"socialpredict/services/walletrotation"
+	"socialpredict/services/walletsync"
+	"socialpredict/services/washtrading"
+	"socialpredict/services/withdrawalconfirm"
+	"socialpredict/services/withdrawalflow"
 	"socialpredict/setup"
 	"socialpredict/util"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -79,7 +137,7 @@ func buildCORSFromEnv() *cors.Cors {
 	}
 	origins := getListEnv("CORS_ALLOW_ORIGINS", "*")
 	methods := getListEnv("CORS_ALLOW_METHODS", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
-	headers := getListEnv("CORS_ALLOW_HEADERS", "Content-Type,Authorization")
+	headers := getListEnv("CORS_ALLOW_HEADERS", "Content-Type,Authorization,X-Device-ID,Idempotency-Key")
 	expose := getListEnv("CORS_EXPOSE_HEADERS", "")
 	allowCreds := getBoolEnv("CORS_ALLOW_CREDENTIALS", false)
 	maxAge := getIntEnv("CORS_MAX_AGE", 600)
@@ -111,6 +169,12 @@ func Start() {
 	securityMiddleware := securityService.SecurityMiddleware()
 	loginSecurityMiddleware := securityService.LoginSecurityMiddleware()
 
+	// API keys let programs call the routes wrapped in readScope, and with
+	// the trade scope also those wrapped in tradeScope
+	apiKeys := apikeys.NewService(util.GetDB(), clock.New())
+	readScope := middleware.APIKeyMiddleware(apiKeys, models.APIKeyScopeRead)
+	tradeScope := middleware.APIKeyMiddleware(apiKeys, models.APIKeyScopeTrade)
+
 	router.HandleFunc("/v0/home", handlers.HomeHandler).Methods("GET")
 	router.Handle("/v0/login", loginSecurityMiddleware(http.HandlerFunc(middleware.LoginHandler))).Methods("POST")
 
@@ -122,25 +186,37 @@ func Start() {
 	router.Handle("/v0/global/leaderboard", securityMiddleware(http.HandlerFunc(metricshandlers.GetGlobalLeaderboardHandler))).Methods("GET")
 
 	// markets display, market information
-	router.Handle("/v0/markets", securityMiddleware(http.HandlerFunc(marketshandlers.ListMarketsHandler))).Methods("GET")
-	router.Handle("/v0/markets/search", securityMiddleware(http.HandlerFunc(marketshandlers.SearchMarketsHandler))).Methods("GET")
-	router.Handle("/v0/markets/active", securityMiddleware(http.HandlerFunc(marketshandlers.ListActiveMarketsHandler))).Methods("GET")
-	router.Handle("/v0/markets/closed", securityMiddleware(http.HandlerFunc(marketshandlers.ListClosedMarketsHandler))).Methods("GET")
-	router.Handle("/v0/markets/resolved", securityMiddleware(http.HandlerFunc(marketshandlers.ListResolvedMarketsHandler))).Methods("GET")
-	router.Handle("/v0/markets/{marketId}", securityMiddleware(http.HandlerFunc(marketshandlers.MarketDetailsHandler))).Methods("GET")
-	router.Handle("/v0/marketprojection/{marketId}/{amount}/{outcome}/", securityMiddleware(http.HandlerFunc(marketshandlers.ProjectNewProbabilityHandler))).Methods("GET")
+	router.Handle("/v0/markets", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.ListMarketsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/search", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.SearchMarketsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/active", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.ListActiveMarketsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/closed", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.ListClosedMarketsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/resolved", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.ListResolvedMarketsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.MarketDetailsHandler)))).Methods("GET")
+	router.Handle("/v0/marketprojection/{marketId}/{amount}/{outcome}/", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.ProjectNewProbabilityHandler)))).Methods("GET")
 
 	// handle market positions, get trades
-	router.Handle("/v0/markets/bets/{marketId}", securityMiddleware(http.HandlerFunc(betshandlers.MarketBetsDisplayHandler))).Methods("GET")
-	router.Handle("/v0/markets/positions/{marketId}", securityMiddleware(http.HandlerFunc(positions.MarketDBPMPositionsHandler))).Methods("GET")
-	router.Handle("/v0/markets/positions/{marketId}/{username}", securityMiddleware(http.HandlerFunc(positions.MarketDBPMUserPositionsHandler))).Methods("GET")
-	router.Handle("/v0/markets/leaderboard/{marketId}", securityMiddleware(http.HandlerFunc(marketshandlers.MarketLeaderboardHandler))).Methods("GET")
+	router.Handle("/v0/markets/bets/{marketId}", securityMiddleware(readScope(http.HandlerFunc(betshandlers.MarketBetsDisplayHandler)))).Methods("GET")
+	router.Handle("/v0/markets/positions/{marketId}", securityMiddleware(readScope(http.HandlerFunc(positions.MarketDBPMPositionsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/positions/{marketId}/{username}", securityMiddleware(readScope(http.HandlerFunc(positions.MarketDBPMUserPositionsHandler)))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/outcomes", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.MarketOutcomesHandler)))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/history", securityMiddleware(readScope(marketshandlers.MarketHistoryHandler(clock.New())))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/stream", securityMiddleware(http.HandlerFunc(marketshandlers.MarketStreamHandler(stream.Default)))).Methods("GET")
+	commentSvc := comments.NewService(util.GetDB(), clock.New(), stream.Default)
+	router.Handle("/v0/markets/{marketId}/comments", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.MarketCommentsHandler(commentSvc))))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/comments", securityMiddleware(http.HandlerFunc(marketshandlers.AddMarketCommentHandler(commentSvc)))).Methods("POST")
+	router.Handle("/v0/markets/leaderboard/{marketId}", securityMiddleware(readScope(http.HandlerFunc(marketshandlers.MarketLeaderboardHandler)))).Methods("GET")
+
+	// read-only GraphQL over markets, positions, price history and activity
+	router.Handle("/graphql", securityMiddleware(readScope(graphqlapi.Handler(util.GetDB())))).Methods("GET", "POST")
+	router.Handle(graphqlapi.SchemaPath, securityMiddleware(http.HandlerFunc(graphqlapi.SchemaHandler))).Methods("GET")
 
 	// handle public user stuff
 	router.Handle("/v0/userinfo/{username}", securityMiddleware(http.HandlerFunc(publicuser.GetPublicUserResponse))).Methods("GET")
 	router.Handle("/v0/usercredit/{username}", securityMiddleware(http.HandlerFunc(usercredit.GetUserCreditHandler))).Methods("GET")
 	router.Handle("/v0/portfolio/{username}", securityMiddleware(http.HandlerFunc(publicuser.GetPortfolio))).Methods("GET")
 	router.Handle("/v0/users/{username}/financial", securityMiddleware(http.HandlerFunc(usershandlers.GetUserFinancialHandler))).Methods("GET")
+	router.Handle("/v0/users/{username}/activity", securityMiddleware(http.HandlerFunc(usershandlers.GetUserActivityHandler))).Methods("GET")
+	router.Handle("/v0/users/{username}/accuracy", securityMiddleware(http.HandlerFunc(usershandlers.GetUserAccuracyHandler))).Methods("GET")
 
 	// handle private user stuff, display sensitive profile information to customize
 	router.Handle("/v0/privateprofile", securityMiddleware(http.HandlerFunc(privateuser.GetPrivateProfileUserResponse))).Methods("GET")
@@ -153,10 +229,14 @@ func Start() {
 	router.Handle("/v0/profilechange/links", securityMiddleware(http.HandlerFunc(usershandlers.ChangePersonalLinks))).Methods("POST")
 
 	// handle private user actions such as resolve a market, make a bet, create a market, change profile
-	router.Handle("/v0/resolve/{marketId}", securityMiddleware(http.HandlerFunc(marketshandlers.ResolveMarketHandler))).Methods("POST")
-	router.Handle("/v0/bet", securityMiddleware(http.HandlerFunc(buybetshandlers.PlaceBetHandler(setup.EconomicsConfig)))).Methods("POST")
-	router.Handle("/v0/userposition/{marketId}", securityMiddleware(http.HandlerFunc(usershandlers.UserMarketPositionHandler))).Methods("GET")
-	router.Handle("/v0/sell", securityMiddleware(http.HandlerFunc(sellbetshandlers.SellPositionHandler(setup.EconomicsConfig)))).Methods("POST")
+	router.Handle("/v0/resolve/{marketId}", securityMiddleware(marketshandlers.ResolveMarketHandler(clock.New()))).Methods("POST")
+	router.Handle("/v0/bet", securityMiddleware(tradeScope(http.HandlerFunc(buybetshandlers.PlaceBetHandler(setup.EconomicsConfig))))).Methods("POST")
+	router.Handle("/v0/notifications", securityMiddleware(http.HandlerFunc(usershandlers.GetNotificationsHandler))).Methods("GET")
+	router.Handle("/v0/creator/earnings", securityMiddleware(http.HandlerFunc(usershandlers.GetCreatorEarningsHandler))).Methods("GET")
+	router.Handle("/v0/userposition/{marketId}", securityMiddleware(readScope(http.HandlerFunc(usershandlers.UserMarketPositionHandler)))).Methods("GET")
+	router.Handle("/v0/sell", securityMiddleware(tradeScope(http.HandlerFunc(sellbetshandlers.SellPositionHandler(setup.EconomicsConfig))))).Methods("POST")
+	router.Handle("/v0/markets/{marketId}/positions/sell", securityMiddleware(tradeScope(http.HandlerFunc(sellbetshandlers.ExitPositionHandler)))).Methods("POST")
+	router.Handle("/v0/api/markets/{marketId}/bets", securityMiddleware(tradeScope(http.HandlerFunc(buybetshandlers.APIPlaceBetHandler(setup.EconomicsConfig))))).Methods("POST")
 	router.Handle("/v0/create", securityMiddleware(http.HandlerFunc(marketshandlers.CreateMarketHandler(setup.EconomicsConfig)))).Methods("POST")
 
 	// admin stuff - apply security middleware
@@ -172,43 +252,495 @@ func Start() {
 	router.HandleFunc("/v0/content/home", homepageHandler.PublicGet).Methods("GET")
 	router.Handle("/v0/admin/content/home", securityMiddleware(http.HandlerFunc(homepageHandler.AdminUpdate))).Methods("PUT")
 
-	// Initialize DFNS client
-	dfnsConfig := dfns.LoadConfigFromEnv()
-	var dfnsClient *dfns.Client
-	if dfnsConfig.IsConfigured() {
-		var err error
-		dfnsClient, err = dfns.NewClient(dfnsConfig)
+	// Initialize DFNS clients, one per configured organization, or the
+	// in-memory sandbox when DFNS_MODE=sandbox
+	var dfnsOrgs *dfns.Orgs
+	var dfnsSandbox *dfns.Sandbox
+	if dfns.SandboxEnabled() {
+		dfnsSandbox = dfns.NewSandbox(dfns.LoadSandboxConfigFromEnv())
+		if err := wallethandlers.RestoreSandboxWallets(db, dfnsSandbox); err != nil {
+			log.Printf("Warning: Failed to restore DFNS sandbox wallets: %v", err)
+		}
+		dfnsOrgs = dfnsSandbox.Orgs()
+		log.Printf("Warning: DFNS sandbox mode - wallets and transfers are simulated")
+	} else {
+		// Signing keys may come from a secrets manager instead of the environment or disk
+		dfnsConfigs := dfns.LoadOrgConfigsFromEnv()
+		secretStore, err := secrets.NewFromEnv(context.Background())
 		if err != nil {
-			log.Printf("Warning: Failed to initialize DFNS client: %v", err)
-		} else {
-			log.Printf("DFNS client initialized successfully")
+			log.Printf("Warning: Failed to initialize secrets backend: %v", err)
+		}
+		if err := dfns.LoadPrivateKeySecrets(context.Background(), dfnsConfigs, secretStore); err != nil {
+			log.Printf("Warning: %v", err)
 		}
+		dfnsOrgs = dfns.NewOrgs(dfnsConfigs)
+	}
+	// Without a configured DFNS org the wallet, deposit and withdrawal routes
+	// answer 503 and the rest of the market runs as usual
+	cryptoEnabled := dfnsOrgs.Configured()
+	if cryptoEnabled {
+		log.Printf("DFNS clients initialized for orgs: %s", strings.Join(dfnsOrgs.Names(), ", "))
 	} else {
-		log.Printf("Warning: DFNS not configured - wallet features will be limited")
+		log.Printf("Warning: DFNS not configured - crypto features disabled")
+	}
+
+	// Resolution attestations are broadcast from a platform DFNS wallet in the primary org
+	var broadcaster attestation.Broadcaster
+	if primary := dfnsOrgs.Primary(); primary != nil {
+		broadcaster = primary
+	}
+	attestationSvc := attestation.NewService(db, broadcaster, attestation.LoadConfigFromEnv(), clock.New())
+	screener := screening.NewFromEnv()
+	correctionsSvc := corrections.NewService(db, corrections.LoadConfigFromEnv(), clock.New())
+	resolutionCostSvc := resolutioncost.NewService(db, resolutioncost.LoadConfigFromEnv(), setup.EconomicsConfig, clock.New())
+
+	// Market liquidity pools
+	liquiditySvc := liquidity.NewService(db, clock.New())
+	router.Handle("/v0/markets/{marketId}/liquidity", securityMiddleware(http.HandlerFunc(marketshandlers.GetLiquidityHandler(liquiditySvc)))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/liquidity", securityMiddleware(http.HandlerFunc(marketshandlers.AddLiquidityHandler(liquiditySvc)))).Methods("POST")
+	router.Handle("/v0/markets/{marketId}/liquidity/withdraw", securityMiddleware(http.HandlerFunc(marketshandlers.WithdrawLiquidityHandler(liquiditySvc)))).Methods("POST")
+
+	// Limit orders, matched when placed and after bets move a market
+	orderBook := orders.NewService(db, setup.EconomicsConfig, clock.New())
+	orderInterval := 15 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("ORDER_MATCH_INTERVAL")); err == nil && d > 0 {
+		orderInterval = d
+	}
+	go orderBook.Run(orderInterval)
+	router.Handle("/v0/markets/{marketId}/orders", securityMiddleware(http.HandlerFunc(marketshandlers.GetOrderBookHandler(orderBook)))).Methods("GET")
+	router.Handle("/v0/markets/{marketId}/orders", securityMiddleware(http.HandlerFunc(marketshandlers.PlaceOrderHandler(orderBook)))).Methods("POST")
+	router.Handle("/v0/orders", securityMiddleware(http.HandlerFunc(marketshandlers.ListOrdersHandler(orderBook)))).Methods("GET")
+	router.Handle("/v0/orders/{id}", securityMiddleware(http.HandlerFunc(marketshandlers.CancelOrderHandler(orderBook)))).Methods("DELETE")
+
+	// Recurring market series open their next market when the last one closes
+	seriesSvc := series.NewService(db, setup.EconomicsConfig, clock.New())
+	seriesInterval := time.Minute
+	if d, err := time.ParseDuration(os.Getenv("SERIES_GENERATE_INTERVAL")); err == nil && d > 0 {
+		seriesInterval = d
+	}
+	go seriesSvc.Run(seriesInterval)
+	router.Handle("/v0/series", securityMiddleware(http.HandlerFunc(marketshandlers.ListSeriesHandler(seriesSvc)))).Methods("GET")
+	router.Handle("/v0/series/subscriptions", securityMiddleware(http.HandlerFunc(marketshandlers.ListSeriesSubscriptionsHandler(seriesSvc)))).Methods("GET")
+	router.Handle("/v0/series/{id}", securityMiddleware(http.HandlerFunc(marketshandlers.GetSeriesHandler(seriesSvc)))).Methods("GET")
+	router.Handle("/v0/series/{id}/subscribe", securityMiddleware(http.HandlerFunc(marketshandlers.SubscribeSeriesHandler(seriesSvc)))).Methods("POST")
+	router.Handle("/v0/series/{id}/subscribe", securityMiddleware(http.HandlerFunc(marketshandlers.UnsubscribeSeriesHandler(seriesSvc)))).Methods("DELETE")
+
+	// Referral codes and the rewards earned from referred users
+	referralSvc := referrals.NewService(db, referrals.LoadConfigFromEnv(), clock.New())
+	router.Handle("/v0/referrals", securityMiddleware(http.HandlerFunc(usershandlers.GetReferralsHandler(referralSvc)))).Methods("GET")
+
+	// Private groups and their invite links
+	groupsSvc := groups.NewService(db, groups.LoadConfigFromEnv(), clock.New())
+	router.Handle("/v0/groups", securityMiddleware(http.HandlerFunc(usershandlers.CreateGroupHandler(groupsSvc)))).Methods("POST")
+	router.Handle("/v0/groups", securityMiddleware(http.HandlerFunc(usershandlers.ListGroupsHandler(groupsSvc)))).Methods("GET")
+	router.Handle("/v0/groups/join", securityMiddleware(http.HandlerFunc(usershandlers.JoinGroupHandler(groupsSvc)))).Methods("POST")
+	router.Handle("/v0/groups/{id}", securityMiddleware(http.HandlerFunc(usershandlers.GetGroupHandler(groupsSvc)))).Methods("GET")
+	router.Handle("/v0/groups/{id}/markets", securityMiddleware(http.HandlerFunc(usershandlers.ListGroupMarketsHandler(groupsSvc)))).Methods("GET")
+	router.Handle("/v0/groups/{id}/members/{userId}", securityMiddleware(http.HandlerFunc(usershandlers.RemoveGroupMemberHandler(groupsSvc)))).Methods("DELETE")
+	router.Handle("/v0/groups/{id}/invites", securityMiddleware(http.HandlerFunc(usershandlers.CreateGroupInviteHandler(groupsSvc)))).Methods("POST")
+	router.Handle("/v0/groups/{id}/invites", securityMiddleware(http.HandlerFunc(usershandlers.ListGroupInvitesHandler(groupsSvc)))).Methods("GET")
+	router.Handle("/v0/groups/{id}/invites/{inviteId}", securityMiddleware(http.HandlerFunc(usershandlers.RevokeGroupInviteHandler(groupsSvc)))).Methods("DELETE")
+
+	// Market oracles, checked once markets close and resolved after admin review
+	oracleSvc := oracle.NewService(db, oracle.LoadConfigFromEnv(), clock.New())
+	oracleInterval := time.Minute
+	if d, err := time.ParseDuration(os.Getenv("ORACLE_CHECK_INTERVAL")); err == nil && d > 0 {
+		oracleInterval = d
+	}
+	go oracleSvc.Run(oracleInterval)
+
+	// Market winnings are held for the settlement delay, and can be disputed meanwhile
+	settlementSvc := settlement.NewService(db, clock.New())
+	go settlementSvc.Run(5 * time.Minute)
+	router.Handle("/v0/markets/{marketId}/disputes", securityMiddleware(http.HandlerFunc(marketshandlers.OpenDisputeHandler(settlementSvc)))).Methods("POST")
+
+	// Users can report markets to the moderators
+	moderationSvc := moderation.NewService(db, clock.New())
+	router.Handle("/v0/markets/{marketId}/report", securityMiddleware(http.HandlerFunc(marketshandlers.ReportMarketHandler(moderationSvc)))).Methods("POST")
+
+	// Leaderboards are computed on a schedule and served from the newest snapshot
+	leaderboardSvc := leaderboard.NewService(db, clock.New())
+	leaderboardInterval := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("LEADERBOARD_SNAPSHOT_INTERVAL")); err == nil && d > 0 {
+		leaderboardInterval = d
+	}
+	go leaderboardSvc.Run(leaderboardInterval)
+	router.Handle("/v0/leaderboard", securityMiddleware(http.HandlerFunc(metricshandlers.LeaderboardHandler(leaderboardSvc)))).Methods("GET")
+
+	// Markets close to bets at their close time; unresolved ones are chased with the creator, then admins
+	closeScheduler := marketclose.NewScheduler(db, marketclose.LoadConfigFromEnv(), clock.New())
+	closeInterval := time.Minute
+	if d, err := time.ParseDuration(os.Getenv("MARKET_CLOSE_INTERVAL")); err == nil && d > 0 {
+		closeInterval = d
+	}
+	go closeScheduler.Run(closeInterval)
+
+	// Wash trading detection rescans recently active markets in the background
+	washDetector := washtrading.NewDetector(db, clock.New())
+	washInterval := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("WASH_TRADING_SCAN_INTERVAL")); err == nil && d > 0 {
+		washInterval = d
+	}
+	go washDetector.Run(washInterval)
+
+	// House market maker exposure is snapshotted hourly when a house account is configured
+	houseSvc := housemm.NewService(db, housemm.LoadConfigFromEnv(), clock.New())
+	if houseSvc.Enabled() {
+		go houseSvc.Run(time.Hour)
+	}
+
+	// The house liquidity bot bids on new markets when a bot account is configured
+	houseBot := housebot.NewService(db, housebot.LoadConfigFromEnv(), orderBook, clock.New())
+	if houseBot.Enabled() {
+		botInterval := time.Minute
+		if d, err := time.ParseDuration(os.Getenv("HOUSE_BOT_INTERVAL")); err == nil && d > 0 {
+			botInterval = d
+		}
+		go houseBot.Run(botInterval)
+	}
+
+	// Withdrawals run as sagas from approval through transfer completion
+	flows := saga.NewCoordinator(db, clock.New())
+	withdrawalflow.Register(flows, dfnsOrgs, clock.New())
+
+	// Transfers started at approval but not recorded are retried every few minutes
+	go withdrawalflow.NewReconciler(db, flows).Run(5 * time.Minute)
+
+	// Treasury hot wallets are checked against their balance ceilings in the background
+	treasurySvc := treasury.NewService(db, treasury.OrgProviders(dfnsOrgs), treasury.LoadConfigFromEnv(), clock.New())
+	treasuryInterval := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("TREASURY_CHECK_INTERVAL")); err == nil && d > 0 {
+		treasuryInterval = d
+	}
+	go treasurySvc.Run(treasuryInterval)
+
+	// User webhooks for balance changes, fills, resolutions, deposits and
+	// withdrawals, scanned and delivered in the background
+	userHooks := userhooks.NewService(db, userhooks.LoadConfigFromEnv(), clock.New())
+	hookInterval := 30 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("USER_WEBHOOKS_INTERVAL")); err == nil && d > 0 {
+		hookInterval = d
+	}
+	go userHooks.Run(hookInterval)
+
+	router.Handle("/v0/webhooks", securityMiddleware(http.HandlerFunc(usershandlers.ListWebhooksHandler(userHooks)))).Methods("GET")
+	router.Handle("/v0/webhooks", securityMiddleware(http.HandlerFunc(usershandlers.CreateWebhookHandler(userHooks)))).Methods("POST")
+	router.Handle("/v0/webhooks/{id}", securityMiddleware(http.HandlerFunc(usershandlers.DeleteWebhookHandler(userHooks)))).Methods("DELETE")
+	router.Handle("/v0/webhooks/{id}/deliveries", securityMiddleware(http.HandlerFunc(usershandlers.ListWebhookDeliveriesHandler(userHooks)))).Methods("GET")
+	router.Handle("/v0/webhooks/{id}/deliveries/{deliveryId}/retry", securityMiddleware(http.HandlerFunc(usershandlers.RetryWebhookDeliveryHandler(userHooks)))).Methods("POST")
+
+	// API key management takes a login session, never an API key
+	router.Handle("/v0/apikeys", securityMiddleware(http.HandlerFunc(usershandlers.ListAPIKeysHandler(apiKeys)))).Methods("GET")
+	router.Handle("/v0/apikeys", securityMiddleware(http.HandlerFunc(usershandlers.CreateAPIKeyHandler(apiKeys)))).Methods("POST")
+	router.Handle("/v0/apikeys/{id}/rotate", securityMiddleware(http.HandlerFunc(usershandlers.RotateAPIKeyHandler(apiKeys)))).Methods("POST")
+	router.Handle("/v0/apikeys/{id}", securityMiddleware(http.HandlerFunc(usershandlers.RevokeAPIKeyHandler(apiKeys)))).Methods("DELETE")
+
+	// Two-factor authentication for withdrawals and other sensitive actions
+	secondFactor := twofactor.NewService(db, mailer.FromEnv(), twofactor.LoadConfigFromEnv(), clock.New())
+	router.Handle("/v0/2fa", securityMiddleware(http.HandlerFunc(usershandlers.GetTwoFactorStatusHandler(secondFactor)))).Methods("GET")
+	router.Handle("/v0/2fa/enroll", securityMiddleware(http.HandlerFunc(usershandlers.EnrollTwoFactorHandler(secondFactor)))).Methods("POST")
+	router.Handle("/v0/2fa/activate", securityMiddleware(http.HandlerFunc(usershandlers.ActivateTwoFactorHandler(secondFactor)))).Methods("POST")
+	router.Handle("/v0/2fa/disable", securityMiddleware(http.HandlerFunc(usershandlers.DisableTwoFactorHandler(secondFactor)))).Methods("POST")
+	router.Handle("/v0/2fa/email-token", securityMiddleware(http.HandlerFunc(usershandlers.SendEmailTokenHandler(secondFactor)))).Methods("POST")
+
+	// Optional emailed confirmation links for withdrawals; unconfirmed requests
+	// are expired and refunded every minute
+	withdrawalConfirmations := withdrawalconfirm.NewService(db, mailer.FromEnv(), withdrawalconfirm.LoadConfigFromEnv(), clock.New())
+	go withdrawalConfirmations.Run(time.Minute)
+
+	// Login sessions and devices; withdrawals from a new device are held for a while
+	deviceGuard := devices.NewService(db, devices.LoadConfigFromEnv(), clock.New())
+	go deviceGuard.Run(time.Minute)
+	router.Handle("/v0/sessions", securityMiddleware(http.HandlerFunc(usershandlers.ListSessionsHandler(deviceGuard)))).Methods("GET")
+	router.Handle("/v0/sessions/{id}", securityMiddleware(http.HandlerFunc(usershandlers.RevokeSessionHandler(deviceGuard)))).Methods("DELETE")
+
+	// Withdrawals over the travel-rule threshold need a beneficiary attestation
+	travelRule := travelrule.NewPolicy(travelrule.LoadConfigFromEnv())
+
+	// Internal gRPC wallet API, enabled by GRPC_ADDR
+	if grpcAddr := os.Getenv("GRPC_ADDR"); grpcAddr != "" {
+		go func() {
+			if err := grpcapi.ListenAndServe(grpcAddr, os.Getenv("GRPC_API_TOKEN"), grpcapi.NewServer(db, screener, secondFactor, travelRule, clock.New())); err != nil {
+				log.Printf("Warning: gRPC wallet API stopped: %v", err)
+			}
+		}()
+	}
+
+	// Wallet and admin withdrawal routes are described in the api package, which
+	// validates their request bodies and generates the OpenAPI document
+	apiRegistry := api.NewRegistry()
+	documented := func(route api.Route, h http.HandlerFunc) {
+		if route.Crypto {
+			h = wallethandlers.RequireCrypto(cryptoEnabled, h)
+		}
+		router.Handle(route.Path, securityMiddleware(apiRegistry.Register(route, h))).Methods(route.Method)
 	}
+	router.HandleFunc("/v0/openapi.json", apiRegistry.ServeOpenAPI(api.Title, api.Version)).Methods("GET")
+
+	// Data access for the wallet and withdrawal handlers
+	repos := repository.NewGormRepos(db)
 
 	// Wallet routes - user facing
-	router.Handle("/v0/wallet/deposit/{chain}", securityMiddleware(http.HandlerFunc(wallethandlers.GetDepositAddressHandler(dfnsClient)))).Methods("GET")
-	router.Handle("/v0/wallet/deposits", securityMiddleware(http.HandlerFunc(wallethandlers.GetAllDepositAddressesHandler(dfnsClient)))).Methods("GET")
-	router.Handle("/v0/wallet/withdraw", securityMiddleware(http.HandlerFunc(wallethandlers.InitiateWithdrawalHandler(dfnsClient)))).Methods("POST")
-	router.Handle("/v0/wallet/withdrawals", securityMiddleware(http.HandlerFunc(wallethandlers.GetUserWithdrawalsHandler))).Methods("GET")
-	router.Handle("/v0/wallet/transactions", securityMiddleware(http.HandlerFunc(wallethandlers.GetTransactionHistoryHandler))).Methods("GET")
-	router.Handle("/v0/wallet/chains", securityMiddleware(http.HandlerFunc(wallethandlers.GetSupportedChainsHandler))).Methods("GET")
-	router.Handle("/v0/wallet/tokens", securityMiddleware(http.HandlerFunc(wallethandlers.GetSupportedTokensHandler))).Methods("GET")
-	router.Handle("/v0/wallet/info", securityMiddleware(http.HandlerFunc(wallethandlers.GetWalletInfoHandler))).Methods("GET")
-
-	// DFNS webhook endpoint (no auth - uses signature verification)
-	router.HandleFunc("/v0/webhook/dfns", wallethandlers.DFNSWebhookHandler).Methods("POST")
+	documented(api.WalletDepositAddress, wallethandlers.GetDepositAddressHandler(db, repos.Wallets, dfnsOrgs))
+	documented(api.WalletDepositAddresses, wallethandlers.GetAllDepositAddressesHandler(db, repos.Wallets, dfnsOrgs))
+	walletBalances := walletbalances.NewService(db, walletbalances.OrgReaders(dfnsOrgs), walletbalances.LoadConfigFromEnv(), clock.New())
+	documented(api.WalletList, wallethandlers.GetWalletsHandler(db, repos.Wallets, walletBalances, clock.New()))
+	documented(api.WalletWithdraw, wallethandlers.InitiateWithdrawalHandler(db, repos, dfnsOrgs, screener, secondFactor, withdrawalConfirmations, deviceGuard, geoip.NewFromEnv(), travelRule, clock.New()))
+	documented(api.WalletConfirmWithdrawal, wallethandlers.ConfirmWithdrawalHandler(withdrawalConfirmations))
+	documented(api.WalletEmailConfirmation, wallethandlers.SetWithdrawalEmailConfirmationHandler(secondFactor))
+	documented(api.WalletValidateWithdrawal, wallethandlers.ValidateWithdrawalHandler(db, repos, secondFactor, travelRule, clock.New()))
+	documented(api.WalletWithdrawals, wallethandlers.GetUserWithdrawalsHandler(db, repos))
+	documented(api.WalletTransactions, wallethandlers.GetTransactionHistoryHandler(db, repos))
+	documented(api.WalletActivity, wallethandlers.GetActivityHandler)
+	documented(api.WalletChains, wallethandlers.GetSupportedChainsHandler)
+	documented(api.WalletTokens, wallethandlers.GetSupportedTokensHandler)
+	documented(api.WalletInfo, wallethandlers.GetWalletInfoHandler(cryptoEnabled))
+	documented(api.WalletBalance, wallethandlers.GetBalanceHandler)
+	documented(api.WalletPendingDepositBetting, wallethandlers.SetPendingDepositBettingHandler)
+	documented(api.WalletSettings, wallethandlers.GetWalletSettingsHandler(db, repos))
+	documented(api.WalletUpdateSettings, wallethandlers.UpdateWalletSettingsHandler(db, repos))
+
+	// User-to-user credit transfers, switched off or capped from the admin settings
+	transferSvc := transfers.NewService(db, settings.Shared, clock.New())
+	documented(api.WalletTransfer, wallethandlers.TransferCreditsHandler(db, transferSvc))
+	documented(api.WalletListTransfers, wallethandlers.ListTransfersHandler(db, transferSvc))
+
+	// Simulated deposits, sandbox mode only
+	if dfnsSandbox != nil {
+		router.Handle("/v0/sandbox/deposits", securityMiddleware(http.HandlerFunc(wallethandlers.SimulateDepositHandler(dfnsSandbox)))).Methods("POST")
+	}
+
+	// DFNS webhook endpoint (no auth - uses signature verification, plus a
+	// timestamp window and replay cache whose expired entries are pruned hourly)
+	webhookGuard := replay.NewGuard(db, replay.LoadConfigFromEnv(), clock.New())
+	go webhookGuard.Run(time.Hour)
+	// Large deposits are checked against the chain's RPC; sandbox deposits never reach a chain
+	var receiptVerifier *receipts.Service
+	if dfnsSandbox == nil {
+		receiptVerifier = receipts.NewService(receipts.NewRPCVerifier(), receipts.LoadConfigFromEnv())
+	}
+	// Optional chain scanning credits deposits when DFNS webhooks are delayed
+	chainScanner := chainscan.NewService(db, evmrpc.NewClient(), wallethandlers.ScannedDepositCrediter(db, screener, clock.New()), chainscan.LoadConfigFromEnv(), clock.New())
+	if chainScanner.Enabled() && dfnsSandbox == nil {
+		scanInterval := time.Minute
+		if d, err := time.ParseDuration(os.Getenv("CHAIN_SCANNER_INTERVAL")); err == nil && d > 0 {
+			scanInterval = d
+		}
+		go chainScanner.Run(scanInterval)
+	}
+	dfnsWebhook := wallethandlers.RequireCrypto(cryptoEnabled, wallethandlers.DFNSWebhookHandler(db, repos, dfnsOrgs, screener, flows, webhookGuard, receiptVerifier, clock.New()))
+	router.HandleFunc("/v0/webhook/dfns", dfnsWebhook).Methods("POST")
+	router.HandleFunc("/v0/webhook/dfns/{org}", dfnsWebhook).Methods("POST")
 
 	// Admin withdrawal management routes
-	router.Handle("/v0/admin/withdrawals", securityMiddleware(http.HandlerFunc(adminhandlers.ListWithdrawalRequestsHandler))).Methods("GET")
-	router.Handle("/v0/admin/withdrawals/stats", securityMiddleware(http.HandlerFunc(adminhandlers.GetWithdrawalStatsHandler))).Methods("GET")
-	router.Handle("/v0/admin/withdrawals/{id}", securityMiddleware(http.HandlerFunc(adminhandlers.GetWithdrawalDetailsHandler))).Methods("GET")
-	router.Handle("/v0/admin/withdrawals/{id}/approve", securityMiddleware(http.HandlerFunc(adminhandlers.ApproveWithdrawalHandler(dfnsClient)))).Methods("POST")
-	router.Handle("/v0/admin/withdrawals/{id}/reject", securityMiddleware(http.HandlerFunc(adminhandlers.RejectWithdrawalHandler))).Methods("POST")
+	documented(api.AdminListWithdrawals, adminhandlers.ListWithdrawalRequestsHandler(db, repos))
+	documented(api.AdminWithdrawalStats, adminhandlers.GetWithdrawalStatsHandler(db, repos))
+	documented(api.AdminTravelRuleExport, adminhandlers.TravelRuleExportHandler(travelRule, clock.New()))
+	documented(api.AdminWithdrawalDetails, adminhandlers.GetWithdrawalDetailsHandler(db, repos))
+	documented(api.AdminApproveWithdrawal, adminhandlers.ApproveWithdrawalHandler(db, repos, flows, clock.New()))
+	documented(api.AdminRejectWithdrawal, adminhandlers.RejectWithdrawalHandler(db, repos, clock.New()))
+	documented(api.AdminAddWithdrawalNote, adminhandlers.AddWithdrawalNoteHandler)
+	documented(api.AdminReleaseWithdrawal, adminhandlers.ReleaseWithdrawalHoldHandler)
+	documented(api.AdminGetWithdrawalLimits, adminhandlers.GetWithdrawalLimitsHandler)
+	documented(api.AdminUpdateWithdrawalLimits, adminhandlers.UpdateWithdrawalLimitsHandler)
+	documented(api.AdminGetTransferSettings, adminhandlers.GetTransferSettingsHandler)
+	documented(api.AdminUpdateTransferSettings, adminhandlers.UpdateTransferSettingsHandler)
+	documented(api.AdminGetCreatorFeeSettings, adminhandlers.GetCreatorFeeSettingsHandler)
+	documented(api.AdminUpdateCreatorFeeSettings, adminhandlers.UpdateCreatorFeeSettingsHandler)
+	documented(api.AdminGetMakerCheckerSettings, adminhandlers.GetMakerCheckerSettingsHandler)
+	documented(api.AdminUpdateMakerCheckerSettings, adminhandlers.UpdateMakerCheckerSettingsHandler)
+	documented(api.AdminListTokenWithdrawalRules, adminhandlers.ListTokenWithdrawalRulesHandler)
+	documented(api.AdminSetTokenWithdrawalRule, adminhandlers.SetTokenWithdrawalRuleHandler)
+
+	// Admin saga routes
+	router.Handle("/v0/admin/sagas", securityMiddleware(http.HandlerFunc(adminhandlers.ListSagasHandler))).Methods("GET")
+	router.Handle("/v0/admin/sagas/{id}/retry", securityMiddleware(http.HandlerFunc(adminhandlers.RetrySagaHandler(flows)))).Methods("POST")
+
+	// Admin chain configuration routes
+	documented(api.AdminListChains, adminhandlers.ListChainsHandler)
+	documented(api.AdminCreateChain, adminhandlers.CreateChainHandler)
+	documented(api.AdminUpdateChain, adminhandlers.UpdateChainHandler)
+	documented(api.AdminActivateChain, adminhandlers.ActivateChainHandler)
+	documented(api.AdminDeactivateChain, adminhandlers.DeactivateChainHandler)
+	documented(api.AdminListChainTokens, adminhandlers.ListChainTokensHandler)
+	documented(api.AdminAddChainToken, adminhandlers.AddChainTokenHandler)
+	documented(api.AdminUpdateChainToken, adminhandlers.UpdateChainTokenHandler)
+	documented(api.AdminActivateChainToken, adminhandlers.ActivateChainTokenHandler)
+	documented(api.AdminDeactivateChainToken, adminhandlers.DeactivateChainTokenHandler)
+
+	// Admin role routes
+	router.Handle("/v0/admin/roles", securityMiddleware(http.HandlerFunc(adminhandlers.ListRolesHandler))).Methods("GET")
+	router.Handle("/v0/admin/users/{username}/roles", securityMiddleware(http.HandlerFunc(adminhandlers.SetUserRolesHandler))).Methods("PUT")
+
+	// Admin sanctions screening hold review routes
+	router.Handle("/v0/admin/holds", securityMiddleware(http.HandlerFunc(adminhandlers.ListHoldsHandler))).Methods("GET")
+	router.Handle("/v0/admin/deposits/{id}/release", securityMiddleware(adminhandlers.ReleaseDepositHoldHandler(clock.New()))).Methods("POST")
+	router.Handle("/v0/admin/deposits/{id}/reject", securityMiddleware(adminhandlers.RejectDepositHoldHandler(clock.New()))).Methods("POST")
+
+	// Admin resolution attestation routes
+	router.Handle("/v0/admin/markets/{marketId}/attest", securityMiddleware(http.HandlerFunc(adminhandlers.AnchorResolutionHandler(attestationSvc)))).Methods("POST")
+
+	// Admin resolution cost and market profitability routes
+	router.Handle("/v0/admin/markets/{marketId}/resolution-costs", securityMiddleware(http.HandlerFunc(adminhandlers.RecordResolutionCostHandler(resolutionCostSvc)))).Methods("POST")
+	router.Handle("/v0/admin/markets/{marketId}/profitability", securityMiddleware(http.HandlerFunc(adminhandlers.GetMarketProfitabilityHandler(resolutionCostSvc)))).Methods("GET")
+
+	// Admin market oracle routes
+	router.Handle("/v0/admin/oracles", securityMiddleware(http.HandlerFunc(adminhandlers.ListOraclesHandler(oracleSvc)))).Methods("GET")
+	router.Handle("/v0/admin/oracles/{id}/approve", securityMiddleware(http.HandlerFunc(adminhandlers.ApproveOracleHandler(oracleSvc)))).Methods("POST")
+	router.Handle("/v0/admin/oracles/{id}/reject", securityMiddleware(http.HandlerFunc(adminhandlers.RejectOracleHandler(oracleSvc)))).Methods("POST")
+	router.Handle("/v0/admin/markets/{marketId}/oracle", securityMiddleware(http.HandlerFunc(adminhandlers.GetOracleHandler(oracleSvc)))).Methods("GET")
+	router.Handle("/v0/admin/markets/{marketId}/oracle", securityMiddleware(http.HandlerFunc(adminhandlers.ConfigureOracleHandler(oracleSvc)))).Methods("PUT")
+
+	// Admin market dispute routes
+	router.Handle("/v0/admin/disputes", securityMiddleware(http.HandlerFunc(adminhandlers.ListDisputesHandler(settlementSvc)))).Methods("GET")
+	router.Handle("/v0/admin/disputes/{id}/release", securityMiddleware(http.HandlerFunc(adminhandlers.ReleaseDisputeHandler(settlementSvc)))).Methods("POST")
+	router.Handle("/v0/admin/disputes/{id}/uphold", securityMiddleware(http.HandlerFunc(adminhandlers.UpholdDisputeHandler(settlementSvc)))).Methods("POST")
+
+	// Admin recurring market series routes
+	router.Handle("/v0/admin/series", securityMiddleware(http.HandlerFunc(adminhandlers.CreateSeriesHandler(seriesSvc)))).Methods("POST")
+	router.Handle("/v0/admin/series/{id}", securityMiddleware(http.HandlerFunc(adminhandlers.UpdateSeriesHandler(seriesSvc)))).Methods("PUT")
+
+	// Admin market void route, for markets that break the rules
+	router.Handle("/v0/admin/markets/{marketId}/void", securityMiddleware(adminhandlers.VoidMarketHandler(clock.New()))).Methods("POST")
+
+	// Admin bet limit routes, capping bet size and stake per market with per-user overrides
+	router.Handle("/v0/admin/markets/{marketId}/bet-limits", securityMiddleware(http.HandlerFunc(adminhandlers.GetMarketBetLimitsHandler))).Methods("GET")
+	router.Handle("/v0/admin/markets/{marketId}/bet-limits", securityMiddleware(http.HandlerFunc(adminhandlers.SetMarketBetLimitsHandler))).Methods("PUT")
+	router.Handle("/v0/admin/users/{username}/bet-limits", securityMiddleware(http.HandlerFunc(adminhandlers.ListBetLimitOverridesHandler))).Methods("GET")
+	router.Handle("/v0/admin/users/{username}/bet-limits", securityMiddleware(http.HandlerFunc(adminhandlers.SetBetLimitOverrideHandler))).Methods("PUT")
+	router.Handle("/v0/admin/users/{username}/bet-limits", securityMiddleware(http.HandlerFunc(adminhandlers.RemoveBetLimitOverrideHandler))).Methods("DELETE")
+
+	// Per-user withdrawal, trading and deposit restrictions
+	router.Handle("/v0/admin/users/{username}/restrictions", securityMiddleware(adminhandlers.ListRestrictionsHandler(clock.New()))).Methods("GET")
+	router.Handle("/v0/admin/users/{username}/restrictions/{kind}", securityMiddleware(adminhandlers.SetRestrictionHandler(clock.New()))).Methods("PUT")
+	router.Handle("/v0/admin/users/{username}/restrictions/{kind}", securityMiddleware(http.HandlerFunc(adminhandlers.LiftRestrictionHandler))).Methods("DELETE")
+
+	// Cooling-off, self-exclusion and daily deposit limits users set on themselves
+	selfExclusions := selfexclusion.NewService(db, clock.New())
+	router.Handle("/v0/responsible-gambling", securityMiddleware(http.HandlerFunc(usershandlers.ResponsibleGamblingHandler(selfExclusions)))).Methods("GET")
+	router.Handle("/v0/responsible-gambling/cooling-off", securityMiddleware(http.HandlerFunc(usershandlers.CoolingOffHandler(selfExclusions)))).Methods("POST")
+	router.Handle("/v0/responsible-gambling/self-exclusion", securityMiddleware(http.HandlerFunc(usershandlers.SelfExclusionHandler(selfExclusions)))).Methods("POST")
+	router.Handle("/v0/responsible-gambling/deposit-limit", securityMiddleware(http.HandlerFunc(usershandlers.SetDepositLimitHandler(selfExclusions)))).Methods("PUT")
+	router.Handle("/v0/admin/self-exclusions", securityMiddleware(http.HandlerFunc(adminhandlers.SelfExclusionReportHandler(selfExclusions)))).Methods("GET")
+
+	// Branded communities served on their own hostnames
+	router.Handle("/v0/admin/tenants", securityMiddleware(http.HandlerFunc(adminhandlers.ListTenantsHandler))).Methods("GET")
+	router.Handle("/v0/admin/tenants", securityMiddleware(http.HandlerFunc(adminhandlers.CreateTenantHandler))).Methods("POST")
+	router.Handle("/v0/admin/tenants/{id}", securityMiddleware(http.HandlerFunc(adminhandlers.UpdateTenantHandler))).Methods("PUT")
+
+	// Admin market integrity routes
+	router.Handle("/v0/admin/markets/{marketId}/integrity", securityMiddleware(http.HandlerFunc(adminhandlers.GetMarketIntegrityHandler(washDetector)))).Methods("GET")
+	router.Handle("/v0/admin/wash-trading", securityMiddleware(http.HandlerFunc(adminhandlers.ListWashTradeFlagsHandler))).Methods("GET")
+
+	// Admin monthly financial report export
+	financialReportSvc := financialreport.NewService(db, setup.EconomicsConfig, clock.New())
+	router.Handle("/v0/admin/reports/financial", securityMiddleware(http.HandlerFunc(adminhandlers.FinancialReportHandler(financialReportSvc)))).Methods("GET")
+
+	// Admin referral fraud review routes
+	router.Handle("/v0/admin/referrals", securityMiddleware(http.HandlerFunc(adminhandlers.ListReferralsHandler(referralSvc)))).Methods("GET")
+	router.Handle("/v0/admin/referrals/{id}/clear", securityMiddleware(http.HandlerFunc(adminhandlers.ClearReferralHandler(referralSvc)))).Methods("POST")
+	router.Handle("/v0/admin/referrals/{id}/block", securityMiddleware(http.HandlerFunc(adminhandlers.BlockReferralHandler(referralSvc)))).Methods("POST")
+
+	// Admin market moderation routes
+	router.Handle("/v0/admin/moderation/queue", securityMiddleware(http.HandlerFunc(adminhandlers.ModerationQueueHandler(moderationSvc)))).Methods("GET")
+	router.Handle("/v0/admin/moderation/markets/{marketId}", securityMiddleware(http.HandlerFunc(adminhandlers.EditReportedMarketHandler(moderationSvc)))).Methods("PUT")
+	router.Handle("/v0/admin/moderation/markets/{marketId}", securityMiddleware(http.HandlerFunc(adminhandlers.DeleteReportedMarketHandler(moderationSvc)))).Methods("DELETE")
+	router.Handle("/v0/admin/moderation/markets/{marketId}/close", securityMiddleware(http.HandlerFunc(adminhandlers.CloseReportedMarketHandler(moderationSvc)))).Methods("POST")
+	router.Handle("/v0/admin/moderation/markets/{marketId}/warn", securityMiddleware(http.HandlerFunc(adminhandlers.WarnMarketCreatorHandler(moderationSvc)))).Methods("POST")
+	router.Handle("/v0/admin/moderation/markets/{marketId}/dismiss", securityMiddleware(http.HandlerFunc(adminhandlers.DismissMarketReportsHandler(moderationSvc)))).Methods("POST")
+
+	// Admin house market maker exposure routes
+	router.Handle("/v0/admin/house/exposure", securityMiddleware(http.HandlerFunc(adminhandlers.GetHouseExposureHandler(houseSvc)))).Methods("GET")
+	router.Handle("/v0/admin/house/exposure/history", securityMiddleware(adminhandlers.GetHouseExposureHistoryHandler(houseSvc, clock.New()))).Methods("GET")
+	router.Handle("/v0/admin/house/exposure/snapshots", securityMiddleware(http.HandlerFunc(adminhandlers.SnapshotHouseExposureHandler(houseSvc)))).Methods("POST")
+
+	// Admin house liquidity bot routes
+	router.Handle("/v0/admin/house/bot", securityMiddleware(http.HandlerFunc(adminhandlers.GetHouseBotHandler(houseBot)))).Methods("GET")
+	router.Handle("/v0/admin/house/bot/halt", securityMiddleware(http.HandlerFunc(adminhandlers.HaltHouseBotHandler(houseBot)))).Methods("POST")
+	router.Handle("/v0/admin/house/bot/fund", securityMiddleware(http.HandlerFunc(adminhandlers.FundHouseBotHandler(houseBot)))).Methods("POST")
+	router.Handle("/v0/admin/house/bot/categories", securityMiddleware(http.HandlerFunc(adminhandlers.SetHouseBotCategoryHandler(houseBot)))).Methods("PUT")
+
+	// Admin user investigation routes
+	router.Handle("/v0/admin/users/{id}/crypto", securityMiddleware(adminhandlers.GetUserCryptoActivityHandler(db, repos, clock.New()))).Methods("GET")
+	router.Handle("/v0/admin/users/{id}/devices", securityMiddleware(http.HandlerFunc(adminhandlers.ListUserDevicesHandler(deviceGuard)))).Methods("GET")
+	router.Handle("/v0/admin/devices/{id}/trust", securityMiddleware(http.HandlerFunc(adminhandlers.TrustDeviceHandler(deviceGuard)))).Methods("POST")
+
+	// Support view-as-user: read-only, time-boxed and audited
+	impersonations := impersonation.NewService(db, impersonation.LoadConfigFromEnv(), clock.New())
+	router.Handle("/v0/admin/users/{username}/impersonate", securityMiddleware(http.HandlerFunc(adminhandlers.StartImpersonationHandler(impersonations)))).Methods("POST")
+	router.Handle("/v0/admin/impersonations", securityMiddleware(http.HandlerFunc(adminhandlers.ListImpersonationsHandler(impersonations)))).Methods("GET")
+	router.Handle("/v0/admin/impersonations/{id}", securityMiddleware(http.HandlerFunc(adminhandlers.EndImpersonationHandler(impersonations)))).Methods("DELETE")
+
+	// User deposit wallet rotation; the old address credits deposits during a grace period
+	rotationSvc := walletrotation.NewService(db, dfnsOrgs, walletrotation.LoadConfigFromEnv(), clock.New())
+	router.Handle("/v0/admin/wallets/{id}/rotate", securityMiddleware(wallethandlers.RequireCrypto(cryptoEnabled, adminhandlers.RotateWalletHandler(rotationSvc)))).Methods("POST")
+
+	// Wallet names, tags and status are synced from DFNS in the background;
+	// mismatches with the local records wait for admin review
+	walletSync := walletsync.NewService(db, dfnsOrgs.Names(), walletsync.OrgListers(dfnsOrgs), walletsync.LoadConfigFromEnv(), clock.New())
+	walletSyncInterval := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("WALLET_SYNC_INTERVAL")); err == nil && d > 0 {
+		walletSyncInterval = d
+	}
+	go walletSync.Run(walletSyncInterval)
+	router.Handle("/v0/admin/wallet-sync/issues", securityMiddleware(http.HandlerFunc(adminhandlers.ListWalletSyncIssuesHandler))).Methods("GET")
+	router.Handle("/v0/admin/wallet-sync/issues/{id}/resolve", securityMiddleware(http.HandlerFunc(adminhandlers.ResolveWalletSyncIssueHandler(walletSync)))).Methods("POST")
+	router.Handle("/v0/admin/wallet-sync/run", securityMiddleware(http.HandlerFunc(adminhandlers.RunWalletSyncHandler(walletSync)))).Methods("POST")
+
+	// Address labels shown on deposits and withdrawals and used in risk
+	// scoring; exchanges are detected and a labeling API consulted in the background
+	addressLabeler := addresslabels.NewService(db, addresslabels.NewProviderFromEnv(), addresslabels.LoadConfigFromEnv(), clock.New())
+	labelInterval := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("ADDRESS_LABELS_INTERVAL")); err == nil && d > 0 {
+		labelInterval = d
+	}
+	go addressLabeler.Run(labelInterval)
+	router.Handle("/v0/admin/address-labels", securityMiddleware(http.HandlerFunc(adminhandlers.ListAddressLabelsHandler))).Methods("GET")
+	router.Handle("/v0/admin/address-labels", securityMiddleware(http.HandlerFunc(adminhandlers.SetAddressLabelHandler))).Methods("PUT")
+	router.Handle("/v0/admin/address-labels/run", securityMiddleware(http.HandlerFunc(adminhandlers.RunAddressLabelerHandler(addressLabeler)))).Methods("POST")
+	router.Handle("/v0/admin/address-labels/{id}", securityMiddleware(http.HandlerFunc(adminhandlers.DeleteAddressLabelHandler))).Methods("DELETE")
+
+	// Admin promotional credit routes; bonus credit cannot be withdrawn until wagered
+	bonusSvc := bonus.NewService(db, clock.New())
+	router.Handle("/v0/admin/bonuses", securityMiddleware(http.HandlerFunc(adminhandlers.ListBonusGrantsHandler(bonusSvc)))).Methods("GET")
+	router.Handle("/v0/admin/bonuses", securityMiddleware(http.HandlerFunc(adminhandlers.GrantBonusHandler(bonusSvc)))).Methods("POST")
+
+	// Admin balance correction routes
+	router.Handle("/v0/admin/corrections", securityMiddleware(http.HandlerFunc(adminhandlers.ListCorrectionsHandler))).Methods("GET")
+	router.Handle("/v0/admin/corrections", securityMiddleware(http.HandlerFunc(adminhandlers.CreateCorrectionHandler(correctionsSvc)))).Methods("POST")
+	router.Handle("/v0/admin/corrections/{id}/approve", securityMiddleware(http.HandlerFunc(adminhandlers.ApproveCorrectionHandler(correctionsSvc)))).Methods("POST")
+	router.Handle("/v0/admin/corrections/{id}/reject", securityMiddleware(http.HandlerFunc(adminhandlers.RejectCorrectionHandler(correctionsSvc)))).Methods("POST")
+
+	// Emergency withdrawal freeze, authorized by EMERGENCY_SECRET rather than an admin session
+	emergencySecret := os.Getenv("EMERGENCY_SECRET")
+	router.Handle("/v0/admin/emergency/freeze-withdrawals", securityMiddleware(http.HandlerFunc(adminhandlers.FreezeWithdrawalsHandler(emergencySecret)))).Methods("POST")
+	router.Handle("/v0/admin/emergency/unfreeze-withdrawals", securityMiddleware(http.HandlerFunc(adminhandlers.UnfreezeWithdrawalsHandler(emergencySecret)))).Methods("POST")
+	router.Handle("/v0/admin/emergency/withdrawals", securityMiddleware(http.HandlerFunc(adminhandlers.GetWithdrawalFreezeHandler))).Methods("GET")
+
+	// Admin treasury routes
+	router.Handle("/v0/admin/treasury/wallets", securityMiddleware(http.HandlerFunc(adminhandlers.ListTreasuryWalletsHandler(treasurySvc)))).Methods("GET")
+	router.Handle("/v0/admin/treasury/wallets", securityMiddleware(http.HandlerFunc(adminhandlers.AddTreasuryWalletHandler(treasurySvc)))).Methods("POST")
+	router.Handle("/v0/admin/treasury/wallets/{id}", securityMiddleware(http.HandlerFunc(adminhandlers.UpdateTreasuryWalletHandler(treasurySvc)))).Methods("PUT")
+	router.Handle("/v0/admin/treasury/transfers", securityMiddleware(http.HandlerFunc(adminhandlers.ListTreasuryTransfersHandler(treasurySvc)))).Methods("GET")
+	router.Handle("/v0/admin/treasury/cold-storage", securityMiddleware(http.HandlerFunc(adminhandlers.MoveToColdStorageHandler(treasurySvc)))).Methods("POST")
+
+	// Health checks: /health fails on any problem for monitoring, /readyz only
+	// when the database is unreachable
+	healthSvc := health.NewService(db, health.OrgPingers(dfnsOrgs), health.LoadConfigFromEnv(), clock.New())
+	router.Handle("/health", healthhandlers.HealthHandler(healthSvc)).Methods("GET")
+	router.Handle("/readyz", healthhandlers.ReadinessHandler(healthSvc)).Methods("GET")
+
+	// Prometheus metrics, readable with METRICS_TOKEN as a bearer token
+	if err := metrics.RegisterWalletCollector(db); err != nil {
+		log.Printf("Warning: Failed to register wallet metrics: %v", err)
+	}
+	router.Handle("/metrics", metrics.Handler(os.Getenv("METRICS_TOKEN"))).Methods("GET")
+
+	// Select the community by hostname, then tag every request with a trace
+	// ID for structured logs
+	handler := logger.RequestIDMiddleware(tenants.Middleware(db, tenants.Shared, router))
 
 	// Apply CORS middleware if enabled
-	handler := http.Handler(router)
 	if c != nil {
 		handler = c.Handler(handler)
 	}
@@ -0,0 +1,344 @@
+// Package activity merges a user's records into one chronological feed:
+// crypto deposits and withdrawals, ledger entries such as market payouts,
+// refunds, transfers and bonuses, and, on profile feeds, market bets.
+package activity
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/explorer"
+
+	"gorm.io/gorm"
+)
+
+// Item sources
+const (
+	SourceCryptoTransaction = "crypto_transaction"
+	SourceLedger            = "ledger"
+	SourceBet               = "bet"
+)
+
+// Types of bet items
+const (
+	TypeBuy  = "BUY"
+	TypeSell = "SELL"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by Feed
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// hiddenLedgerTypes duplicate crypto transactions or carry balances over from
+// before the ledger, so they are left out of the feed
+var hiddenLedgerTypes = []string{
+	models.LedgerTypeOpeningBalance,
+	models.LedgerTypeHistoricalDeposit,
+	models.LedgerTypeHistoricalWithdraw,
+}
+
+// settlementLedgerTypes are the ledger entries shown on a public profile;
+// the rest of a user's ledger is private
+var settlementLedgerTypes = []string{
+	models.LedgerTypeMarketPayout,
+	models.LedgerTypeMarketRefund,
+}
+
+// Item is one entry in the feed. AmountMicro is signed: credits into the
+// balance are positive, debits negative. Sales record the shares sold but
+// not the credits received, so their amount is zero.
+type Item struct {
+	Source        string    `json:"source"`
+	ID            uint      `json:"id"`
+	Type          string    `json:"type"` // DEPOSIT, WITHDRAWAL or a ledger entry type
+	Amount        float64   `json:"amount"`
+	AmountMicro   int64     `json:"amountMicro"`
+	Description   string    `json:"description,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	MarketID      *int64    `json:"marketId,omitempty"`
+	MarketTitle   string    `json:"marketTitle,omitempty"`
+	Outcome       string    `json:"outcome,omitempty"`
+	Shares        int64     `json:"shares,omitempty"`
+	ReferenceType string    `json:"referenceType,omitempty"`
+	ReferenceID   uint      `json:"referenceId,omitempty"`
+	ChainName     string    `json:"chainName,omitempty"`
+	TokenSymbol   string    `json:"tokenSymbol,omitempty"`
+	TxHash        string    `json:"txHash,omitempty"`
+	ExplorerURL   string    `json:"explorerUrl,omitempty"`
+	OccurredAt    time.Time `json:"occurredAt"`
+}
+
+// Page is one page of the feed
+type Page struct {
+	Items      []Item `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"` // Pass back to fetch the next page; empty on the last page
+}
+
+// cursor is the position of the last item on a page. Items are ordered by
+// time, then source rank, then ID, all descending.
+type cursor struct {
+	At   time.Time
+	Rank int
+	ID   uint
+}
+
+func (c cursor) encode() string {
+	raw := fmt.Sprintf("%s|%d|%d", c.At.UTC().Format(time.RFC3339Nano), c.Rank, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	rank, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{At: at, Rank: rank, ID: uint(id)}, nil
+}
+
+// source loads up to limit items of one kind that come after the cursor
+type source struct {
+	rank int
+	load func(db *gorm.DB, user *models.User, after func(*gorm.DB) *gorm.DB, limit int) ([]Item, error)
+}
+
+// Sources are ranked so items at the same instant have a stable order
+var (
+	walletSources = []source{
+		{rank: 1, load: loadCryptoTransactions},
+		{rank: 2, load: loadLedgerEntries},
+	}
+	profileSources = []source{
+		{rank: 1, load: loadCryptoTransactions},
+		{rank: 2, load: loadLedgerEntries},
+		{rank: 3, load: loadBets},
+	}
+	publicProfileSources = []source{
+		{rank: 2, load: loadSettlements},
+		{rank: 3, load: loadBets},
+	}
+)
+
+// seek restricts a source's query to items after c, given the source's rank
+func seek(c *cursor, rank int) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		switch {
+		case c == nil:
+			return query
+		case rank < c.Rank:
+			return query.Where("created_at <= ?", c.At)
+		case rank == c.Rank:
+			return query.Where("created_at < ? OR (created_at = ? AND id < ?)", c.At, c.At, c.ID)
+		default:
+			return query.Where("created_at < ?", c.At)
+		}
+	}
+}
+
+// Feed returns up to limit of the user's wallet items, newest first, after
+// the position encoded in cursorStr (or from the start if it is empty)
+func Feed(db *gorm.DB, user *models.User, cursorStr string, limit int) (*Page, error) {
+	return feed(db, user, walletSources, cursorStr, limit)
+}
+
+// ProfileFeed returns up to limit of the user's bets and wallet items,
+// newest first, after the position encoded in cursorStr. Unless
+// includePrivate is set, only bets and market settlements are included.
+func ProfileFeed(db *gorm.DB, user *models.User, includePrivate bool, cursorStr string, limit int) (*Page, error) {
+	if includePrivate {
+		return feed(db, user, profileSources, cursorStr, limit)
+	}
+	return feed(db, user, publicProfileSources, cursorStr, limit)
+}
+
+func feed(db *gorm.DB, user *models.User, sources []source, cursorStr string, limit int) (*Page, error) {
+	c, err := decodeCursor(cursorStr)
+	if err != nil {
+		return nil, err
+	}
+
+	type ranked struct {
+		Item
+		rank int
+	}
+	var merged []ranked
+	for _, src := range sources {
+		// Each source reads one extra row so a next page can be detected
+		items, err := src.load(db, user, seek(c, src.rank), limit+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			merged = append(merged, ranked{Item: item, rank: src.rank})
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if !a.OccurredAt.Equal(b.OccurredAt) {
+			return a.OccurredAt.After(b.OccurredAt)
+		}
+		if a.rank != b.rank {
+			return a.rank > b.rank
+		}
+		return a.ID > b.ID
+	})
+
+	page := &Page{Items: []Item{}}
+	for i, r := range merged {
+		if i == limit {
+			last := merged[limit-1]
+			page.NextCursor = cursor{At: last.OccurredAt, Rank: last.rank, ID: last.ID}.encode()
+			break
+		}
+		page.Items = append(page.Items, r.Item)
+	}
+	if err := addMarketTitles(db, page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// addMarketTitles fills in the title of each item's market
+func addMarketTitles(db *gorm.DB, items []Item) error {
+	var ids []int64
+	for _, item := range items {
+		if item.MarketID != nil {
+			ids = append(ids, *item.MarketID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var markets []models.Market
+	if err := db.Select("id", "question_title").Where("id IN ?", ids).Find(&markets).Error; err != nil {
+		return err
+	}
+	titles := make(map[int64]string, len(markets))
+	for _, m := range markets {
+		titles[m.ID] = m.QuestionTitle
+	}
+	for i := range items {
+		if items[i].MarketID != nil {
+			items[i].MarketTitle = titles[*items[i].MarketID]
+		}
+	}
+	return nil
+}
+
+func loadCryptoTransactions(db *gorm.DB, user *models.User, after func(*gorm.DB) *gorm.DB, limit int) ([]Item, error) {
+	var txs []models.CryptoTransaction
+	query := db.Model(&models.CryptoTransaction{}).Where("user_id = ?", user.ID)
+	if err := after(query).Order("created_at DESC, id DESC").Limit(limit).Find(&txs).Error; err != nil {
+		return nil, err
+	}
+
+	links, _ := explorer.Load(db) // Best effort
+	items := make([]Item, len(txs))
+	for i, tx := range txs {
+		amount := tx.AmountCredits
+		if tx.Type == models.TxTypeWithdrawal {
+			amount = -amount
+		}
+		items[i] = Item{
+			Source:      SourceCryptoTransaction,
+			ID:          tx.ID,
+			Type:        tx.Type,
+			Amount:      models.DisplayCredits(amount),
+			AmountMicro: amount,
+			Status:      tx.Status,
+			ChainName:   tx.ChainName,
+			TokenSymbol: tx.TokenSymbol,
+			TxHash:      tx.TxHash,
+			ExplorerURL: links.Tx(tx.ChainName, tx.TxHash),
+			OccurredAt:  tx.CreatedAt,
+		}
+	}
+	return items, nil
+}
+
+func loadLedgerEntries(db *gorm.DB, user *models.User, after func(*gorm.DB) *gorm.DB, limit int) ([]Item, error) {
+	query := db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type NOT IN ?", user.ID, hiddenLedgerTypes)
+	return ledgerItems(after(query), limit)
+}
+
+func loadSettlements(db *gorm.DB, user *models.User, after func(*gorm.DB) *gorm.DB, limit int) ([]Item, error) {
+	query := db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type IN ?", user.ID, settlementLedgerTypes)
+	return ledgerItems(after(query), limit)
+}
+
+func ledgerItems(query *gorm.DB, limit int) ([]Item, error) {
+	var entries []models.LedgerEntry
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, len(entries))
+	for i, e := range entries {
+		items[i] = Item{
+			Source:        SourceLedger,
+			ID:            e.ID,
+			Type:          e.Type,
+			Amount:        models.DisplayCredits(e.Amount),
+			AmountMicro:   e.Amount,
+			Description:   e.Description,
+			MarketID:      e.MarketID,
+			ReferenceType: e.ReferenceType,
+			ReferenceID:   e.ReferenceID,
+			OccurredAt:    e.CreatedAt,
+		}
+	}
+	return items, nil
+}
+
+func loadBets(db *gorm.DB, user *models.User, after func(*gorm.DB) *gorm.DB, limit int) ([]Item, error) {
+	var bets []models.Bet
+	query := db.Model(&models.Bet{}).Where("username = ?", user.Username)
+	if err := after(query).Order("created_at DESC, id DESC").Limit(limit).Find(&bets).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, len(bets))
+	for i, bet := range bets {
+		marketID := int64(bet.MarketID)
+		item := Item{
+			Source:     SourceBet,
+			ID:         bet.ID,
+			Type:       TypeBuy,
+			MarketID:   &marketID,
+			Outcome:    bet.Outcome,
+			OccurredAt: bet.CreatedAt,
+		}
+		// Bet amounts are whole credits spent, or shares sold when negative
+		if bet.Amount < 0 {
+			item.Type, item.Shares = TypeSell, -bet.Amount
+		} else {
+			item.AmountMicro = -models.CreditsToMicro(bet.Amount)
+			item.Amount = models.DisplayCredits(item.AmountMicro)
+		}
+		items[i] = item
+	}
+	return items, nil
+}
@@ -0,0 +1,141 @@
+package activity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestFeedMergesSourcesAcrossPages(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	base := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+	deposit := models.CryptoTransaction{UserID: user.ID, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+		ChainName: "base", TokenSymbol: "USDC", AmountCredits: models.CreditsToMicro(100)}
+	deposit.CreatedAt = base
+	withdrawal := models.CryptoTransaction{UserID: user.ID, Type: models.TxTypeWithdrawal, Status: models.TxStatusCompleted,
+		ChainName: "base", TokenSymbol: "USDC", AmountCredits: models.CreditsToMicro(30)}
+	withdrawal.CreatedAt = base.Add(2 * time.Hour)
+	for _, tx := range []*models.CryptoTransaction{&deposit, &withdrawal} {
+		if err := db.Create(tx).Error; err != nil {
+			t.Fatalf("create transaction: %v", err)
+		}
+	}
+
+	marketID := int64(3)
+	for i, e := range []models.LedgerEntry{
+		{UserID: user.ID, Type: models.LedgerTypeMarketPayout, Amount: models.CreditsToMicro(40), MarketID: &marketID},
+		{UserID: user.ID, Type: models.LedgerTypeTransferOut, Amount: -models.CreditsToMicro(5)},
+		{UserID: user.ID, Type: models.LedgerTypeHistoricalDeposit, Amount: models.CreditsToMicro(100)},
+	} {
+		// The payout lands at the same instant as the withdrawal
+		e.CreatedAt = base.Add(time.Duration(2+i) * time.Hour)
+		if err := db.Create(&e).Error; err != nil {
+			t.Fatalf("create ledger entry: %v", err)
+		}
+	}
+
+	var got []Item
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("feed did not end")
+		}
+		page, err := Feed(db, &user, cursor, 2)
+		if err != nil {
+			t.Fatalf("Feed: %v", err)
+		}
+		got = append(got, page.Items...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	want := []string{models.LedgerTypeTransferOut, models.LedgerTypeMarketPayout, models.TxTypeWithdrawal, models.TxTypeDeposit}
+	if len(got) != len(want) {
+		t.Fatalf("got %d items %+v, want %v", len(got), got, want)
+	}
+	for i, item := range got {
+		if item.Type != want[i] {
+			t.Errorf("item %d = %s, want %s", i, item.Type, want[i])
+		}
+	}
+	if got[2].AmountMicro != -models.CreditsToMicro(30) || got[1].MarketID == nil || *got[1].MarketID != marketID {
+		t.Errorf("items = %+v", got)
+	}
+
+	if _, err := Feed(db, &user, "not-a-cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor = %v", err)
+	}
+}
+
+func TestProfileFeedHidesPrivateItemsFromOthers(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("bob", 0)
+	db.Create(&user)
+	market := modelstesting.GenerateMarket(4, "creator")
+	db.Create(&market)
+
+	base := time.Date(2026, 4, 8, 9, 0, 0, 0, time.UTC)
+	deposit := models.CryptoTransaction{UserID: user.ID, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+		ChainName: "base", TokenSymbol: "USDC", AmountCredits: models.CreditsToMicro(100)}
+	deposit.CreatedAt = base
+	db.Create(&deposit)
+
+	buy := modelstesting.GenerateBet(25, "YES", "bob", uint(market.ID), 0)
+	buy.CreatedAt = base.Add(time.Hour)
+	sale := modelstesting.GenerateBet(-10, "YES", "bob", uint(market.ID), 0)
+	sale.CreatedAt = base.Add(2 * time.Hour)
+	db.Create(&buy)
+	db.Create(&sale)
+
+	marketID := market.ID
+	for i, e := range []models.LedgerEntry{
+		{UserID: user.ID, Type: models.LedgerTypeMarketPayout, Amount: models.CreditsToMicro(40), MarketID: &marketID},
+		{UserID: user.ID, Type: models.LedgerTypeTransferIn, Amount: models.CreditsToMicro(5)},
+	} {
+		e.CreatedAt = base.Add(time.Duration(3+i) * time.Hour)
+		db.Create(&e)
+	}
+
+	types := func(page *Page) []string {
+		var out []string
+		for _, item := range page.Items {
+			out = append(out, item.Type)
+		}
+		return out
+	}
+
+	public, err := ProfileFeed(db, &user, false, "", 10)
+	if err != nil {
+		t.Fatalf("ProfileFeed: %v", err)
+	}
+	want := []string{models.LedgerTypeMarketPayout, TypeSell, TypeBuy}
+	if got := types(public); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("public feed = %v, want %v", got, want)
+	}
+	if sold := public.Items[1]; sold.Shares != 10 || sold.AmountMicro != 0 || sold.MarketTitle != market.QuestionTitle {
+		t.Errorf("sale item = %+v", sold)
+	}
+	if bought := public.Items[2]; bought.AmountMicro != -models.CreditsToMicro(25) || bought.Outcome != "YES" {
+		t.Errorf("buy item = %+v", bought)
+	}
+
+	private, err := ProfileFeed(db, &user, true, "", 10)
+	if err != nil {
+		t.Fatalf("ProfileFeed: %v", err)
+	}
+	want = []string{models.LedgerTypeTransferIn, models.LedgerTypeMarketPayout, TypeSell, TypeBuy, models.TxTypeDeposit}
+	if got := types(private); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("private feed = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package addresslabels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+const (
+	hotWallet = "0x28C6c06298d514Db089934071355E5743bf21d60"
+	mixer     = "0xd90e2f925DA726b50C4Ed8D0Fb90Ad053324F31b"
+)
+
+type fakeProvider map[string]Found
+
+func (f fakeProvider) Lookup(_ context.Context, chainName, address string) (*Found, error) {
+	if found, ok := f[address]; ok {
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func TestSetAndLookupPreferChainLabels(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	if _, err := Set(db, Input{Address: hotWallet, Kind: "exchange", Name: "Binance 14"}, models.AddressLabelManual, "root"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := Set(db, Input{ChainName: "ethereum", Address: hotWallet, Kind: models.AddressKindContract, Name: "Router"}, models.AddressLabelManual, "root"); err != nil {
+		t.Fatalf("set chain label: %v", err)
+	}
+	if _, err := Set(db, Input{ChainName: "ethereum", Address: "not-an-address", Kind: models.AddressKindUser}, models.AddressLabelManual, "root"); !errors.Is(err, ErrInvalidLabel) {
+		t.Fatalf("invalid address err = %v", err)
+	}
+
+	labels, err := Load(db, []string{hotWallet})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := labels.Get("ethereum", hotWallet); got == nil || got.Kind != models.AddressKindContract {
+		t.Fatalf("ethereum label = %+v; want the chain's own label", got)
+	}
+	if got := labels.Get("ethereum-sepolia", hotWallet); got == nil || got.Kind != models.AddressKindExchange || got.Address != Normalize(hotWallet) {
+		t.Fatalf("sepolia label = %+v; want the every-chain label", got)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ?", ActionLabelSet).Count(&audits)
+	if audits != 2 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
+
+func TestLabelDetectsExchangesAndAsksProvider(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		dep := models.CryptoTransaction{UserID: int64(i + 1), Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+			ChainName: "ethereum", TokenSymbol: "USDC", FromAddress: hotWallet, TxHash: fmt.Sprintf("0xdep%d", i)}
+		if err := db.Create(&dep).Error; err != nil {
+			t.Fatalf("create deposit: %v", err)
+		}
+	}
+	wr := models.WithdrawalRequest{UserID: 1, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC", Amount: 1, ToAddress: mixer, Status: models.TxStatusPending}
+	if err := db.Create(&wr).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+
+	svc := NewService(db, fakeProvider{Normalize(mixer): {Kind: models.AddressKindMixer, Name: "Tornado Cash"}},
+		Config{ExchangeMinUsers: 3}, clock.NewFake(now))
+	result, err := svc.Label(context.Background())
+	if err != nil || result.Exchanges != 1 || result.Labeled != 1 {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+	if label, _ := Lookup(db, "ethereum", hotWallet); label == nil || label.Kind != models.AddressKindExchange || label.Source != models.AddressLabelHeuristic {
+		t.Fatalf("hot wallet label = %+v", label)
+	}
+	if label, _ := Lookup(db, "ethereum", mixer); label == nil || label.Kind != models.AddressKindMixer || label.Source != models.AddressLabelAPI {
+		t.Fatalf("mixer label = %+v", label)
+	}
+
+	// Labeled addresses are left alone on the next pass
+	if result, err := svc.Label(context.Background()); err != nil || result.Exchanges != 0 || result.LookedUp != 0 {
+		t.Fatalf("second pass = %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,298 @@
+package addresslabels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	labelerActor          = "labeler"
+	defaultExchangeUsers  = 5
+	defaultLookbackWindow = 24 * time.Hour // Addresses the first pass asks the API about
+	lookupBatch           = 100            // Most addresses sent to the API per pass
+)
+
+// Found is a label returned by a labeling API
+type Found struct {
+	Kind string
+	Name string
+}
+
+// Provider looks addresses up in a labeling API. It returns nil when the
+// address is unknown.
+type Provider interface {
+	Lookup(ctx context.Context, chainName, address string) (*Found, error)
+}
+
+// HTTPProvider queries a labeling API: GET {baseURL}/{chain}/{address} with
+// an X-API-Key header, answering {"kind":"exchange","name":"..."} or 404
+type HTTPProvider struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewHTTPProvider creates an HTTP labeling provider
+func NewHTTPProvider(baseURL, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Lookup implements Provider
+func (p *HTTPProvider) Lookup(ctx context.Context, chainName, address string) (*Found, error) {
+	endpoint := p.baseURL + "/" + url.PathEscape(chainName) + "/" + url.PathEscape(address)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create labeling request: %w", err)
+	}
+	req.Header.Set("X-API-Key", p.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("labeling request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("labeling API returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse labeling response: %w", err)
+	}
+	kind := strings.ToUpper(body.Kind)
+	if !kinds[kind] {
+		return nil, nil
+	}
+	return &Found{Kind: kind, Name: body.Name}, nil
+}
+
+// NewProviderFromEnv returns the HTTP provider when ADDRESS_LABELS_API_URL is
+// set, with ADDRESS_LABELS_API_KEY, or nil
+func NewProviderFromEnv() Provider {
+	apiURL := os.Getenv("ADDRESS_LABELS_API_URL")
+	if apiURL == "" {
+		return nil
+	}
+	return NewHTTPProvider(apiURL, os.Getenv("ADDRESS_LABELS_API_KEY"))
+}
+
+// Config holds labeler settings
+type Config struct {
+	// Deposit sources that funded this many distinct users are labeled as
+	// exchanges: exchange hot wallets pay out to many customers
+	ExchangeMinUsers int
+}
+
+// LoadConfigFromEnv reads ADDRESS_LABELS_EXCHANGE_MIN_USERS
+func LoadConfigFromEnv() Config {
+	config := Config{ExchangeMinUsers: defaultExchangeUsers}
+	if n, err := strconv.Atoi(os.Getenv("ADDRESS_LABELS_EXCHANGE_MIN_USERS")); err == nil && n > 1 {
+		config.ExchangeMinUsers = n
+	}
+	return config
+}
+
+// Result summarizes a labeling pass
+type Result struct {
+	Exchanges int `json:"exchanges"` // Deposit sources labeled as exchanges by the heuristic
+	LookedUp  int `json:"lookedUp"`  // Addresses sent to the labeling API
+	Labeled   int `json:"labeled"`   // Addresses the labeling API knew
+}
+
+// Service labels addresses seen in deposits and withdrawals
+type Service struct {
+	db       *gorm.DB
+	provider Provider // nil when no labeling API is configured
+	config   Config
+	clock    clock.Clock
+	since    time.Time // Addresses seen after this are sent to the API on the next pass
+}
+
+// NewService creates a labeler
+func NewService(db *gorm.DB, provider Provider, config Config, c clock.Clock) *Service {
+	return &Service{db: db, provider: provider, config: config, clock: c, since: c.Now().Add(-defaultLookbackWindow)}
+}
+
+// Run labels addresses every interval until the process exits
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if result, err := s.Label(context.Background()); err != nil {
+			log.Printf("AddressLabels: Labeling failed: %v", err)
+		} else if result.Exchanges > 0 || result.Labeled > 0 {
+			log.Printf("AddressLabels: %d exchanges detected, %d addresses labeled by the API", result.Exchanges, result.Labeled)
+		}
+	}
+}
+
+// Label runs the exchange heuristic over all deposits, then asks the labeling
+// API about unlabeled addresses seen since the last pass. Existing labels,
+// manual ones especially, are never replaced.
+func (s *Service) Label(ctx context.Context) (*Result, error) {
+	result := &Result{}
+	now := s.clock.Now()
+
+	exchanges, err := s.exchangeSources()
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range exchanges {
+		if _, err := Set(s.db, Input{
+			ChainName: source.chainName,
+			Address:   source.address,
+			Kind:      models.AddressKindExchange,
+			Name:      fmt.Sprintf("Funded %d users", source.users),
+		}, models.AddressLabelHeuristic, labelerActor); errors.Is(err, ErrInvalidLabel) {
+			continue // An address recorded in a form the chain does not accept
+		} else if err != nil {
+			return nil, err
+		}
+		result.Exchanges++
+	}
+
+	if s.provider == nil {
+		return result, nil
+	}
+	seen, err := s.seenSince(s.since)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range seen {
+		result.LookedUp++
+		found, err := s.provider.Lookup(ctx, addr.chainName, addr.address)
+		if err != nil {
+			// Try again next pass rather than skip addresses after an outage
+			return result, err
+		}
+		if found == nil {
+			continue
+		}
+		if _, err := Set(s.db, Input{ChainName: addr.chainName, Address: addr.address, Kind: found.Kind, Name: found.Name},
+			models.AddressLabelAPI, labelerActor); err != nil && !errors.Is(err, ErrInvalidLabel) {
+			return nil, err
+		}
+		result.Labeled++
+	}
+	// A full batch leaves addresses behind; the next pass picks them up
+	if len(seen) < lookupBatch {
+		s.since = now
+	}
+	return result, nil
+}
+
+type chainAddress struct {
+	chainName string
+	address   string
+	users     int
+}
+
+// exchangeSources returns unlabeled deposit sources that funded at least
+// ExchangeMinUsers distinct users
+func (s *Service) exchangeSources() ([]chainAddress, error) {
+	var rows []struct {
+		ChainName   string
+		FromAddress string
+		UserID      int64
+	}
+	if err := s.db.Model(&models.CryptoTransaction{}).Distinct("chain_name", "from_address", "user_id").
+		Where("type = ? AND from_address <> ''", models.TxTypeDeposit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	users := map[chainAddress]map[int64]bool{}
+	addresses := []string{}
+	for _, row := range rows {
+		key := chainAddress{chainName: row.ChainName, address: Normalize(row.FromAddress)}
+		if users[key] == nil {
+			users[key] = map[int64]bool{}
+			addresses = append(addresses, key.address)
+		}
+		users[key][row.UserID] = true
+	}
+	labels, err := Load(s.db, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []chainAddress{}
+	for key, funded := range users {
+		if len(funded) >= s.config.ExchangeMinUsers && labels.Get(key.chainName, key.address) == nil {
+			key.users = len(funded)
+			sources = append(sources, key)
+		}
+	}
+	return sources, nil
+}
+
+// seenSince returns unlabeled deposit sources and withdrawal destinations
+// first recorded after since, at most lookupBatch of them
+func (s *Service) seenSince(since time.Time) ([]chainAddress, error) {
+	var deposits, withdrawals []struct {
+		ChainName string
+		Address   string
+	}
+	if err := s.db.Model(&models.CryptoTransaction{}).Distinct("chain_name", "from_address AS address").
+		Where("type = ? AND from_address <> '' AND created_at > ?", models.TxTypeDeposit, since).
+		Scan(&deposits).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.WithdrawalRequest{}).Distinct("chain_name", "to_address AS address").
+		Where("created_at > ?", since).
+		Scan(&withdrawals).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := []chainAddress{}
+	seen := map[chainAddress]bool{}
+	addresses := []string{}
+	for _, row := range append(deposits, withdrawals...) {
+		key := chainAddress{chainName: row.ChainName, address: Normalize(row.Address)}
+		if !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, key)
+			addresses = append(addresses, key.address)
+		}
+	}
+	labels, err := Load(s.db, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	unlabeled := []chainAddress{}
+	for _, key := range candidates {
+		if labels.Get(key.chainName, key.address) == nil {
+			unlabeled = append(unlabeled, key)
+		}
+		if len(unlabeled) == lookupBatch {
+			break
+		}
+	}
+	return unlabeled, nil
+}
@@ -0,0 +1,207 @@
+// Package addresslabels records who is behind on-chain addresses: exchanges,
+// mixers, contracts and known users. Labels are set by admins, fetched from a
+// labeling API, or inferred from deposit patterns, and are shown next to
+// deposit sources and withdrawal destinations and used in risk scoring.
+package addresslabels
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions for label changes
+const (
+	ActionLabelSet     = "ADDRESS_LABEL_SET"
+	ActionLabelDeleted = "ADDRESS_LABEL_DELETED"
+)
+
+const (
+	auditTargetLabel = "address_label"
+	maxNameLength    = 100
+)
+
+var (
+	ErrNotFound     = errors.New("address label not found")
+	ErrInvalidLabel = errors.New("a label needs an address valid for its chain, a kind of EXCHANGE, MIXER, CONTRACT or USER and a name of at most 100 characters")
+)
+
+var kinds = map[string]bool{
+	models.AddressKindExchange: true,
+	models.AddressKindMixer:    true,
+	models.AddressKindContract: true,
+	models.AddressKindUser:     true,
+}
+
+// Input is the admin-editable part of a label
+type Input struct {
+	ChainName string // Empty for every chain
+	Address   string
+	Kind      string
+	Name      string
+}
+
+// Normalize returns the form addresses are stored and matched in: EVM
+// addresses are lowercased, TRON's case-sensitive base58 is kept
+func Normalize(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(strings.ToLower(address), "0x") {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+func (in *Input) validate() error {
+	in.ChainName = strings.TrimSpace(in.ChainName)
+	in.Address = Normalize(in.Address)
+	in.Kind = strings.ToUpper(strings.TrimSpace(in.Kind))
+	in.Name = strings.TrimSpace(in.Name)
+	if !kinds[in.Kind] || len(in.Name) > maxNameLength || in.Address == "" {
+		return ErrInvalidLabel
+	}
+	if in.ChainName != "" && !dfns.IsValidAddress(in.Address, in.ChainName) {
+		return ErrInvalidLabel
+	}
+	if in.ChainName == "" && (len(in.Address) > 128 || strings.ContainsAny(in.Address, " \t\n/")) {
+		return ErrInvalidLabel
+	}
+	return nil
+}
+
+// Set labels an address, replacing any label it had on the same chain, and
+// audits the change
+func Set(db *gorm.DB, in Input, source, actor string) (*models.AddressLabel, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	label := models.AddressLabel{
+		ChainName: in.ChainName,
+		Address:   in.Address,
+		Kind:      in.Kind,
+		Name:      in.Name,
+		Source:    source,
+	}
+	if source == models.AddressLabelManual {
+		label.CreatedBy = actor
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_name"}, {Name: "address"}},
+			DoUpdates: clause.AssignmentColumns([]string{"kind", "name", "source", "created_by", "updated_at"}),
+		}).Create(&label).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("chain_name = ? AND address = ?", label.ChainName, label.Address).First(&label).Error; err != nil {
+			return err
+		}
+		return record(tx, actor, ActionLabelSet, label.ID, fmt.Sprintf("%s on %s: %s %q (%s)", label.Address, chainOrAll(label.ChainName), label.Kind, label.Name, label.Source))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// Delete removes a label and audits the change
+func Delete(db *gorm.DB, id uint, actor string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var label models.AddressLabel
+		if err := tx.First(&label, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&label).Error; err != nil {
+			return err
+		}
+		return record(tx, actor, ActionLabelDeleted, label.ID, fmt.Sprintf("%s on %s: %s %q", label.Address, chainOrAll(label.ChainName), label.Kind, label.Name))
+	})
+}
+
+// List returns labels, newest first, optionally of one kind
+func List(db *gorm.DB, kind string) ([]models.AddressLabel, error) {
+	labels := []models.AddressLabel{}
+	query := db.Order("id DESC")
+	if kind != "" {
+		query = query.Where("kind = ?", strings.ToUpper(kind))
+	}
+	err := query.Find(&labels).Error
+	return labels, err
+}
+
+// Labels looks up the labels of a set of addresses loaded in one query
+type Labels struct {
+	byKey map[string]models.AddressLabel
+}
+
+// Load reads the labels of addresses on any chain
+func Load(db *gorm.DB, addresses []string) (*Labels, error) {
+	l := &Labels{byKey: map[string]models.AddressLabel{}}
+	normalized := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address != "" {
+			normalized = append(normalized, Normalize(address))
+		}
+	}
+	if len(normalized) == 0 {
+		return l, nil
+	}
+	var labels []models.AddressLabel
+	if err := db.Where("address IN ?", normalized).Find(&labels).Error; err != nil {
+		return nil, err
+	}
+	for _, label := range labels {
+		l.byKey[label.ChainName+"/"+label.Address] = label
+	}
+	return l, nil
+}
+
+// Get returns the label of an address on a chain, preferring a label for that
+// chain over one for every chain, or nil if it has none
+func (l *Labels) Get(chainName, address string) *models.AddressLabel {
+	if l == nil || address == "" {
+		return nil
+	}
+	address = Normalize(address)
+	if label, ok := l.byKey[chainName+"/"+address]; ok {
+		return &label
+	}
+	if label, ok := l.byKey["/"+address]; ok {
+		return &label
+	}
+	return nil
+}
+
+// Lookup returns the label of a single address on a chain, or nil
+func Lookup(db *gorm.DB, chainName, address string) (*models.AddressLabel, error) {
+	labels, err := Load(db, []string{address})
+	if err != nil {
+		return nil, err
+	}
+	return labels.Get(chainName, address), nil
+}
+
+func chainOrAll(chainName string) string {
+	if chainName == "" {
+		return "all chains"
+	}
+	return chainName
+}
+
+func record(tx *gorm.DB, actor, action string, id uint, details string) error {
+	return audit.Record(tx, models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: auditTargetLabel,
+		TargetID:   id,
+		Details:    details,
+	})
+}
@@ -0,0 +1,301 @@
+// Package apikeys issues and checks API keys, which let bots and research
+// scripts call the API as a user without a login session. Each key carries
+// its own scopes and request rate limit.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// KeyPrefix starts every API key, so keys can be told apart from session
+// tokens and spotted by secret scanners
+const KeyPrefix = "sp_"
+
+// Per-key rate limits, in requests per minute
+const (
+	DefaultRateLimit = 60
+	MaxRateLimit     = 600
+)
+
+const (
+	maxKeysPerUser         = 10
+	maxNameLength          = 64
+	displayPrefixLength    = len(KeyPrefix) + 8
+	lastUsedUpdateInterval = time.Minute
+)
+
+var (
+	ErrInvalidName      = fmt.Errorf("name is required and must be at most %d characters", maxNameLength)
+	ErrInvalidScope     = errors.New("scopes must be read or trade")
+	ErrInvalidRateLimit = fmt.Errorf("rate limit must be between 1 and %d requests per minute", MaxRateLimit)
+	ErrTooManyKeys      = fmt.Errorf("at most %d active API keys per user", maxKeysPerUser)
+	ErrNotFound         = errors.New("API key not found")
+	ErrInvalidKey       = errors.New("invalid API key")
+	ErrRevoked          = errors.New("API key has been revoked")
+)
+
+// Scopes lists the scopes a key can be granted
+var Scopes = []string{models.APIKeyScopeRead, models.APIKeyScopeTrade}
+
+// Service manages API keys and enforces their rate limits
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+
+	mu       sync.Mutex
+	limiters map[uint]*rate.Limiter
+}
+
+// NewService returns an API key service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c, limiters: make(map[uint]*rate.Limiter)}
+}
+
+// IsAPIKey reports whether token looks like an API key rather than a session token
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, KeyPrefix)
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return KeyPrefix + hex.EncodeToString(b), nil
+}
+
+// normalizeScopes validates scopes and returns them deduplicated, in the
+// order of Scopes. No scopes means read only.
+func normalizeScopes(scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return models.APIKeyScopeRead, nil
+	}
+	requested := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		valid := false
+		for _, known := range Scopes {
+			valid = valid || s == known
+		}
+		if !valid {
+			return "", ErrInvalidScope
+		}
+		requested[s] = true
+	}
+	var result []string
+	for _, known := range Scopes {
+		if requested[known] {
+			result = append(result, known)
+		}
+	}
+	return strings.Join(result, ","), nil
+}
+
+// create saves a new key built from template and returns it with the
+// plaintext key
+func create(tx *gorm.DB, template models.APIKey) (*models.APIKey, string, error) {
+	key, err := newKey()
+	if err != nil {
+		return nil, "", err
+	}
+	template.Prefix = key[:displayPrefixLength]
+	template.KeyHash = hash(key)
+	if err := tx.Create(&template).Error; err != nil {
+		return nil, "", err
+	}
+	return &template, key, nil
+}
+
+// Issue creates an API key for the user and returns it with the key itself,
+// which is only shown this once. A zero rate limit means DefaultRateLimit.
+func (s *Service) Issue(userID int64, name string, scopes []string, rateLimit int) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, "", ErrInvalidName
+	}
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if rateLimit == 0 {
+		rateLimit = DefaultRateLimit
+	}
+	if rateLimit < 1 || rateLimit > MaxRateLimit {
+		return nil, "", ErrInvalidRateLimit
+	}
+
+	var apiKey *models.APIKey
+	var key string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxKeysPerUser {
+			return ErrTooManyKeys
+		}
+		var err error
+		apiKey, key, err = create(tx, models.APIKey{
+			UserID:             userID,
+			Name:               name,
+			Scopes:             normalized,
+			RateLimitPerMinute: rateLimit,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return apiKey, key, nil
+}
+
+// List returns the user's keys, newest first, including revoked ones
+func (s *Service) List(userID int64) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// find loads one of the user's active keys
+func find(tx *gorm.DB, userID int64, id uint) (models.APIKey, error) {
+	var apiKey models.APIKey
+	err := tx.Where("id = ? AND user_id = ?", id, userID).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiKey, ErrNotFound
+	}
+	if err != nil {
+		return apiKey, err
+	}
+	if apiKey.IsRevoked() {
+		return apiKey, ErrRevoked
+	}
+	return apiKey, nil
+}
+
+// Rotate replaces one of the user's keys with a new key that has the same
+// name, scopes and rate limit. The old key stops working at once.
+func (s *Service) Rotate(userID int64, id uint) (*models.APIKey, string, error) {
+	var apiKey *models.APIKey
+	var key string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		old, err := find(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		now := s.clock.Now()
+		if err := tx.Model(&old).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		apiKey, key, err = create(tx, models.APIKey{
+			UserID:             userID,
+			Name:               old.Name,
+			Scopes:             old.Scopes,
+			RateLimitPerMinute: old.RateLimitPerMinute,
+			RotatedFromID:      &old.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	s.forget(id)
+	return apiKey, key, nil
+}
+
+// Revoke stops one of the user's keys from working
+func (s *Service) Revoke(userID int64, id uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		apiKey, err := find(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&apiKey).Update("revoked_at", s.clock.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.forget(id)
+	return nil
+}
+
+// Authenticate returns the active key matching key and the user it belongs to
+func (s *Service) Authenticate(key string) (*models.APIKey, *models.User, error) {
+	if !IsAPIKey(key) {
+		return nil, nil, ErrInvalidKey
+	}
+	var apiKey models.APIKey
+	err := s.db.Where("key_hash = ?", hash(key)).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if apiKey.IsRevoked() {
+		return nil, nil, ErrRevoked
+	}
+
+	var user models.User
+	if err := s.db.First(&user, apiKey.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidKey
+		}
+		return nil, nil, err
+	}
+
+	// Recording every request would mean a write per read; a minute's
+	// precision is enough to tell which keys are in use
+	now := s.clock.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedUpdateInterval {
+		if err := s.db.Model(&apiKey).Update("last_used_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return &apiKey, &user, nil
+}
+
+// Allow takes one request from the key's rate limit. When the limit is used
+// up it returns false and how long until the next request is allowed.
+func (s *Service) Allow(apiKey *models.APIKey) (bool, time.Duration) {
+	s.mu.Lock()
+	limiter, ok := s.limiters[apiKey.ID]
+	if !ok || limiter.Burst() != apiKey.RateLimitPerMinute {
+		perMinute := apiKey.RateLimitPerMinute
+		limiter = rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+		s.limiters[apiKey.ID] = limiter
+	}
+	s.mu.Unlock()
+
+	now := s.clock.Now()
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// forget drops a revoked key's rate limiter
+func (s *Service) forget(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.limiters, id)
+}
@@ -0,0 +1,172 @@
+package apikeys
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func createUser(t *testing.T, db *gorm.DB, username string) *models.User {
+	t.Helper()
+	user := modelstesting.GenerateUser(username, 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	svc := NewService(db, clock.NewFake(time.Date(2026, 4, 30, 12, 0, 0, 0, time.UTC)))
+	user := createUser(t, db, "bot")
+
+	apiKey, key, err := svc.Issue(user.ID, "research", nil, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) || !strings.HasPrefix(key, apiKey.Prefix) {
+		t.Fatalf("key %q does not start with %q", key, apiKey.Prefix)
+	}
+	if apiKey.KeyHash == key || strings.Contains(apiKey.KeyHash, key) {
+		t.Fatal("key stored in plaintext")
+	}
+	if apiKey.Scopes != models.APIKeyScopeRead || apiKey.RateLimitPerMinute != DefaultRateLimit {
+		t.Fatalf("defaults = %q, %d", apiKey.Scopes, apiKey.RateLimitPerMinute)
+	}
+
+	found, owner, err := svc.Authenticate(key)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if found.ID != apiKey.ID || owner.Username != "bot" {
+		t.Fatalf("authenticated key %d for %s", found.ID, owner.Username)
+	}
+	var stored models.APIKey
+	db.First(&stored, apiKey.ID)
+	if stored.LastUsedAt == nil {
+		t.Fatal("last use not recorded")
+	}
+
+	if _, _, err := svc.Authenticate(key + "0"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("wrong key: err = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestIssueValidation(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	svc := NewService(db, clock.New())
+	user := createUser(t, db, "bot")
+
+	if _, _, err := svc.Issue(user.ID, " ", nil, 0); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("blank name: err = %v", err)
+	}
+	if _, _, err := svc.Issue(user.ID, "k", []string{"admin"}, 0); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("unknown scope: err = %v", err)
+	}
+	if _, _, err := svc.Issue(user.ID, "k", nil, MaxRateLimit+1); !errors.Is(err, ErrInvalidRateLimit) {
+		t.Errorf("rate limit: err = %v", err)
+	}
+
+	apiKey, _, err := svc.Issue(user.ID, "k", []string{"TRADE", "read", "trade"}, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if apiKey.Scopes != "read,trade" {
+		t.Errorf("scopes = %q, want read,trade", apiKey.Scopes)
+	}
+
+	for i := 1; i < maxKeysPerUser; i++ {
+		if _, _, err := svc.Issue(user.ID, "k", nil, 0); err != nil {
+			t.Fatalf("Issue %d: %v", i, err)
+		}
+	}
+	if _, _, err := svc.Issue(user.ID, "k", nil, 0); !errors.Is(err, ErrTooManyKeys) {
+		t.Errorf("over limit: err = %v, want ErrTooManyKeys", err)
+	}
+}
+
+func TestRotateAndRevoke(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	svc := NewService(db, clock.New())
+	user := createUser(t, db, "bot")
+	other := createUser(t, db, "other")
+
+	apiKey, oldKey, err := svc.Issue(user.ID, "trader", []string{models.APIKeyScopeTrade}, 30)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, _, err := svc.Rotate(other.ID, apiKey.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("rotate another user's key: err = %v, want ErrNotFound", err)
+	}
+
+	rotated, newKey, err := svc.Rotate(user.ID, apiKey.ID)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if rotated.Scopes != apiKey.Scopes || rotated.RateLimitPerMinute != 30 || rotated.RotatedFromID == nil || *rotated.RotatedFromID != apiKey.ID {
+		t.Fatalf("rotated key = %+v", rotated)
+	}
+	if _, _, err := svc.Authenticate(oldKey); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("old key: err = %v, want ErrRevoked", err)
+	}
+	if _, _, err := svc.Authenticate(newKey); err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	if _, _, err := svc.Rotate(user.ID, apiKey.ID); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("rotate twice: err = %v, want ErrRevoked", err)
+	}
+
+	if err := svc.Revoke(user.ID, rotated.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, _, err := svc.Authenticate(newKey); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("revoked key: err = %v, want ErrRevoked", err)
+	}
+
+	keys, err := svc.List(user.ID)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("List = %d keys, %v", len(keys), err)
+	}
+}
+
+func TestAllowEnforcesPerKeyRateLimit(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 4, 30, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	user := createUser(t, db, "bot")
+
+	limited, _, err := svc.Issue(user.ID, "slow", nil, 2)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	other, _, err := svc.Issue(user.ID, "fast", nil, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := svc.Allow(limited); !ok {
+			t.Fatalf("request %d refused", i)
+		}
+	}
+	ok, retryAfter := svc.Allow(limited)
+	if ok || retryAfter <= 0 || retryAfter > 30*time.Second {
+		t.Fatalf("third request: ok = %v, retry after %v", ok, retryAfter)
+	}
+	if ok, _ := svc.Allow(other); !ok {
+		t.Fatal("other key limited by the first")
+	}
+
+	fake.Advance(30 * time.Second)
+	if ok, _ := svc.Allow(limited); !ok {
+		t.Fatal("request refused after the limit refilled")
+	}
+}
@@ -0,0 +1,185 @@
+// Package attestation anchors market resolutions on-chain so users can verify
+// outcomes independently of the platform database.
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+)
+
+// payloadVersion prefixes every anchored commitment
+const payloadVersion = "socialpredict-resolution-v1"
+
+var (
+	ErrNotConfigured     = errors.New("resolution attestation is not configured")
+	ErrMarketNotResolved = errors.New("market is not resolved")
+	ErrAlreadyAnchored   = errors.New("market resolution is already anchored")
+)
+
+// Broadcaster sends a transaction from a DFNS wallet. *dfns.Client satisfies it.
+type Broadcaster interface {
+	BroadcastTransaction(ctx context.Context, walletID string, req dfns.BroadcastTransactionRequest) (*dfns.TransferResponse, error)
+}
+
+// Config holds the attestation wallet settings
+type Config struct {
+	WalletID      string // DFNS wallet that pays for the anchor transaction
+	ChainName     string // Chain the wallet lives on, e.g. "base"
+	AnchorAddress string // Recipient of the zero-value anchor transaction
+}
+
+// LoadConfigFromEnv loads attestation configuration from environment variables
+func LoadConfigFromEnv() Config {
+	return Config{
+		WalletID:      os.Getenv("ATTESTATION_DFNS_WALLET_ID"),
+		ChainName:     os.Getenv("ATTESTATION_CHAIN"),
+		AnchorAddress: os.Getenv("ATTESTATION_ANCHOR_ADDRESS"),
+	}
+}
+
+// IsConfigured returns true if a wallet and anchor address are set
+func (c Config) IsConfigured() bool {
+	return c.WalletID != "" && c.ChainName != "" && dfns.IsValidEVMAddress(c.AnchorAddress)
+}
+
+// Service anchors resolution commitments through DFNS
+type Service struct {
+	db          *gorm.DB
+	broadcaster Broadcaster
+	config      Config
+	clock       clock.Clock
+}
+
+// NewService creates an attestation service
+func NewService(db *gorm.DB, broadcaster Broadcaster, config Config, c clock.Clock) *Service {
+	return &Service{db: db, broadcaster: broadcaster, config: config, clock: c}
+}
+
+// AnchorInput describes the evidence backing a resolution
+type AnchorInput struct {
+	MarketID    int64
+	EvidenceURL string
+	Evidence    string // Evidence document; the URL is hashed when empty
+	AnchoredBy  string
+}
+
+// HashEvidence returns the hex sha256 of the evidence document
+func HashEvidence(evidence string) string {
+	sum := sha256.Sum256([]byte(evidence))
+	return hex.EncodeToString(sum[:])
+}
+
+// Commitment returns the canonical payload anchored for a resolution
+func Commitment(marketID int64, outcome string, resolvedAtUnix int64, evidenceHash string) string {
+	return fmt.Sprintf("%s|%d|%s|%d|%s", payloadVersion, marketID, outcome, resolvedAtUnix, evidenceHash)
+}
+
+// Anchor records the commitment for a resolved market and broadcasts it on-chain
+func (s *Service) Anchor(ctx context.Context, in AnchorInput) (*models.ResolutionAttestation, error) {
+	if s.broadcaster == nil || !s.config.IsConfigured() {
+		return nil, ErrNotConfigured
+	}
+
+	var market models.Market
+	if err := s.db.First(&market, in.MarketID).Error; err != nil {
+		return nil, err
+	}
+	if !market.IsResolved {
+		return nil, ErrMarketNotResolved
+	}
+
+	var existing models.ResolutionAttestation
+	err := s.db.Where("market_id = ? AND status <> ?", market.ID, models.AttestationStatusFailed).First(&existing).Error
+	if err == nil {
+		return nil, ErrAlreadyAnchored
+	}
+
+	evidence := in.Evidence
+	if evidence == "" {
+		evidence = in.EvidenceURL
+	}
+	evidenceHash := HashEvidence(evidence)
+	commitment := Commitment(market.ID, market.ResolutionResult, market.FinalResolutionDateTime.Unix(), evidenceHash)
+	commitmentSum := sha256.Sum256([]byte(commitment))
+
+	att := models.ResolutionAttestation{
+		MarketID:       market.ID,
+		Outcome:        market.ResolutionResult,
+		ResolvedAt:     market.FinalResolutionDateTime,
+		EvidenceURL:    in.EvidenceURL,
+		EvidenceHash:   evidenceHash,
+		CommitmentHash: hex.EncodeToString(commitmentSum[:]),
+		ChainName:      s.config.ChainName,
+		DfnsWalletID:   s.config.WalletID,
+		AnchoredBy:     in.AnchoredBy,
+	}
+
+	// Once sent, the broadcast should not be abandoned because the caller left
+	resp, broadcastErr := s.broadcaster.BroadcastTransaction(context.WithoutCancel(ctx), s.config.WalletID, dfns.BroadcastTransactionRequest{
+		Kind:  dfns.BroadcastKindEvm,
+		To:    s.config.AnchorAddress,
+		Value: "0",
+		Data:  "0x" + hex.EncodeToString([]byte(commitment)),
+	})
+	if broadcastErr != nil {
+		att.Status = models.AttestationStatusFailed
+		att.ErrorMessage = broadcastErr.Error()
+	} else {
+		att.Status = models.AttestationStatusBroadcast
+		att.DfnsTxID = resp.ID
+		att.TxHash = resp.TxHash
+	}
+	att.CreatedAt = s.clock.Now()
+
+	// A failed earlier attempt is replaced by the new one
+	if err := s.db.Unscoped().Where("market_id = ?", market.ID).Delete(&models.ResolutionAttestation{}).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(&att).Error; err != nil {
+		return nil, err
+	}
+
+	if broadcastErr != nil {
+		return &att, fmt.Errorf("failed to broadcast attestation: %w", broadcastErr)
+	}
+	return &att, nil
+}
+
+// Proof is the public verification data for an anchored resolution
+type Proof struct {
+	Outcome        string `json:"outcome"`
+	EvidenceURL    string `json:"evidenceUrl,omitempty"`
+	EvidenceHash   string `json:"evidenceHash"`
+	Commitment     string `json:"commitment"`
+	CommitmentHash string `json:"commitmentHash"`
+	ChainName      string `json:"chainName"`
+	TxHash         string `json:"txHash,omitempty"`
+	Status         string `json:"status"`
+}
+
+// GetProof returns the anchored proof for a market, or nil if none exists
+func GetProof(db *gorm.DB, marketID int64) *Proof {
+	var att models.ResolutionAttestation
+	if err := db.Where("market_id = ? AND status = ?", marketID, models.AttestationStatusBroadcast).First(&att).Error; err != nil {
+		return nil
+	}
+	return &Proof{
+		Outcome:        att.Outcome,
+		EvidenceURL:    att.EvidenceURL,
+		EvidenceHash:   att.EvidenceHash,
+		Commitment:     Commitment(att.MarketID, att.Outcome, att.ResolvedAt.Unix(), att.EvidenceHash),
+		CommitmentHash: att.CommitmentHash,
+		ChainName:      att.ChainName,
+		TxHash:         att.TxHash,
+		Status:         att.Status,
+	}
+}
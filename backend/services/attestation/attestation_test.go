@@ -0,0 +1,112 @@
+package attestation
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/dfns"
+)
+
+type fakeBroadcaster struct {
+	calls []dfns.BroadcastTransactionRequest
+	err   error
+}
+
+func (f *fakeBroadcaster) BroadcastTransaction(_ context.Context, walletID string, req dfns.BroadcastTransactionRequest) (*dfns.TransferResponse, error) {
+	f.calls = append(f.calls, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &dfns.TransferResponse{ID: "tx-123", WalletID: walletID, TxHash: "0xanchor"}, nil
+}
+
+var testConfig = Config{
+	WalletID:      "wa-attest",
+	ChainName:     "base",
+	AnchorAddress: "0x000000000000000000000000000000000000dEaD",
+}
+
+func TestAnchorBroadcastsCommitmentAndExposesProof(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(7, "creator")
+	market.IsResolved = true
+	market.ResolutionResult = "YES"
+	market.FinalResolutionDateTime = time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+
+	broadcaster := &fakeBroadcaster{}
+	svc := NewService(db, broadcaster, testConfig, clock.NewFake(time.Now()))
+
+	att, err := svc.Anchor(context.Background(), AnchorInput{MarketID: 7, EvidenceURL: "https://example.com/result", AnchoredBy: "admin"})
+	if err != nil {
+		t.Fatalf("Anchor: %v", err)
+	}
+	if att.Status != models.AttestationStatusBroadcast || att.TxHash != "0xanchor" {
+		t.Fatalf("unexpected attestation: %+v", att)
+	}
+
+	if len(broadcaster.calls) != 1 {
+		t.Fatalf("expected one broadcast, got %d", len(broadcaster.calls))
+	}
+	call := broadcaster.calls[0]
+	data, _ := hex.DecodeString(strings.TrimPrefix(call.Data, "0x"))
+	expected := Commitment(7, "YES", market.FinalResolutionDateTime.Unix(), HashEvidence("https://example.com/result"))
+	if call.Kind != dfns.BroadcastKindEvm || string(data) != expected {
+		t.Fatalf("unexpected broadcast payload %q", string(data))
+	}
+
+	proof := GetProof(db, 7)
+	if proof == nil || proof.Commitment != expected || proof.TxHash != "0xanchor" {
+		t.Fatalf("unexpected proof: %+v", proof)
+	}
+
+	if _, err := svc.Anchor(context.Background(), AnchorInput{MarketID: 7, EvidenceURL: "https://example.com/other"}); !errors.Is(err, ErrAlreadyAnchored) {
+		t.Fatalf("expected ErrAlreadyAnchored, got %v", err)
+	}
+}
+
+func TestAnchorRejectsUnresolvedAndUnconfigured(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(8, "creator")
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+
+	svc := NewService(db, &fakeBroadcaster{}, testConfig, clock.New())
+	if _, err := svc.Anchor(context.Background(), AnchorInput{MarketID: 8, EvidenceURL: "x"}); !errors.Is(err, ErrMarketNotResolved) {
+		t.Fatalf("expected ErrMarketNotResolved, got %v", err)
+	}
+
+	unconfigured := NewService(db, nil, testConfig, clock.New())
+	if _, err := unconfigured.Anchor(context.Background(), AnchorInput{MarketID: 8, EvidenceURL: "x"}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestFailedBroadcastIsRecordedWithoutProof(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(9, "creator")
+	market.IsResolved = true
+	market.ResolutionResult = "NO"
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+
+	svc := NewService(db, &fakeBroadcaster{err: errors.New("dfns down")}, testConfig, clock.New())
+	att, err := svc.Anchor(context.Background(), AnchorInput{MarketID: 9, EvidenceURL: "x"})
+	if err == nil || att == nil || att.Status != models.AttestationStatusFailed {
+		t.Fatalf("expected failed attestation, got %+v, %v", att, err)
+	}
+	if GetProof(db, 9) != nil {
+		t.Fatal("failed attestations should not produce a proof")
+	}
+}
@@ -0,0 +1,13 @@
+// Package audit records administrative actions.
+package audit
+
+import (
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// Record writes an audit log entry
+func Record(db *gorm.DB, entry models.AuditLog) error {
+	return db.Create(&entry).Error
+}
@@ -0,0 +1,217 @@
+// Package betlimits caps how much can be bet on a market, per bet and per
+// user, to limit the treasury's risk on thin markets. Admins set the caps for
+// each market and can override them for individual users.
+package betlimits
+
+import (
+	"errors"
+	"fmt"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions
+const (
+	ActionMarketLimitsUpdated = "MARKET_BET_LIMITS_UPDATED"
+	ActionOverrideUpdated     = "BET_LIMIT_OVERRIDE_UPDATED"
+	ActionOverrideRemoved     = "BET_LIMIT_OVERRIDE_REMOVED"
+)
+
+// LimitError kinds
+const (
+	KindBet      = "bet"
+	KindExposure = "exposure"
+)
+
+var (
+	ErrInvalidLimits    = errors.New("bet limits must be 0 or positive")
+	ErrReasonRequired   = errors.New("a reason is required for a bet limit override")
+	ErrOverrideNotFound = errors.New("bet limit override not found")
+)
+
+// Caps are the limits on one user's bets on one market, in whole credits.
+// A zero cap is not enforced.
+type Caps struct {
+	MaxBet      int64 `json:"maxBet"`
+	MaxExposure int64 `json:"maxExposure"`
+	Override    bool  `json:"override"` // The caps come from an override for the user
+}
+
+// LimitError is returned when a bet would go over a cap
+type LimitError struct {
+	Kind      string // KindBet or KindExposure
+	Limit     int64
+	Exposure  int64 // Credits the user already has staked on the market
+	Requested int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Kind == KindBet {
+		return fmt.Sprintf("Maximum bet on this market is %d credits", e.Limit)
+	}
+	return fmt.Sprintf("Bet would take your stake on this market to %d credits, over the %d credit limit",
+		e.Exposure+e.Requested, e.Limit)
+}
+
+// For returns the caps on the user's bets on the market. An override for the
+// user on this market replaces the market's caps, as does one for every
+// market when there is none for this market.
+func For(db *gorm.DB, userID, marketID int64) (Caps, error) {
+	var override models.BetLimitOverride
+	if err := db.Where("user_id = ? AND market_id IN ?", userID, []int64{marketID, 0}).
+		Order("market_id DESC").Limit(1).Find(&override).Error; err != nil {
+		return Caps{}, err
+	}
+	if override.ID != 0 {
+		return Caps{MaxBet: override.MaxBet, MaxExposure: override.MaxExposure, Override: true}, nil
+	}
+
+	limit, err := Market(db, marketID)
+	if err != nil {
+		return Caps{}, err
+	}
+	return Caps{MaxBet: limit.MaxBet, MaxExposure: limit.MaxExposure}, nil
+}
+
+// Exposure returns the credits the user has staked on the market: what their
+// bets cost less what their sales returned
+func Exposure(db *gorm.DB, username string, marketID int64) (int64, error) {
+	var staked int64
+	err := db.Model(&models.Bet{}).Where("username = ? AND market_id = ?", username, marketID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&staked).Error
+	return max(staked, 0), err
+}
+
+// Check returns a *LimitError if a bet of amount credits by the user would go
+// over the market's caps
+func Check(db *gorm.DB, user *models.User, marketID, amount int64) error {
+	caps, err := For(db, user.ID, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to load bet limits: %w", err)
+	}
+	if caps.MaxBet > 0 && amount > caps.MaxBet {
+		return &LimitError{Kind: KindBet, Limit: caps.MaxBet, Requested: amount}
+	}
+	if caps.MaxExposure == 0 {
+		return nil
+	}
+	exposure, err := Exposure(db, user.Username, marketID)
+	if err != nil {
+		return fmt.Errorf("failed to total stake: %w", err)
+	}
+	if exposure+amount > caps.MaxExposure {
+		return &LimitError{Kind: KindExposure, Limit: caps.MaxExposure, Exposure: exposure, Requested: amount}
+	}
+	return nil
+}
+
+// Market returns the market's caps, zero when none are set
+func Market(db *gorm.DB, marketID int64) (models.MarketBetLimit, error) {
+	limit := models.MarketBetLimit{MarketID: marketID}
+	err := db.Where("market_id = ?", marketID).Limit(1).Find(&limit).Error
+	return limit, err
+}
+
+// SetMarket replaces the market's caps and audits the change
+func SetMarket(db *gorm.DB, marketID, maxBet, maxExposure int64, actor string) (*models.MarketBetLimit, error) {
+	if maxBet < 0 || maxExposure < 0 {
+		return nil, ErrInvalidLimits
+	}
+	limit := models.MarketBetLimit{MarketID: marketID, MaxBet: maxBet, MaxExposure: maxExposure, UpdatedBy: actor}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "market_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"max_bet", "max_exposure", "updated_by", "updated_at"}),
+		}).Create(&limit).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("market_id = ?", marketID).First(&limit).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionMarketLimitsUpdated,
+			TargetType: "market",
+			TargetID:   uint(marketID),
+			Details:    fmt.Sprintf("maxBet=%d maxExposure=%d", maxBet, maxExposure),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// Overrides lists the user's overrides, the one for every market first
+func Overrides(db *gorm.DB, userID int64) ([]models.BetLimitOverride, error) {
+	overrides := []models.BetLimitOverride{}
+	err := db.Where("user_id = ?", userID).Order("market_id").Find(&overrides).Error
+	return overrides, err
+}
+
+// SetOverride creates or replaces the user's override for override.MarketID
+// and audits the change
+func SetOverride(db *gorm.DB, override models.BetLimitOverride, actor string) (*models.BetLimitOverride, error) {
+	if override.MaxBet < 0 || override.MaxExposure < 0 || override.MarketID < 0 {
+		return nil, ErrInvalidLimits
+	}
+	if override.Reason == "" {
+		return nil, ErrReasonRequired
+	}
+	override.UpdatedBy = actor
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "market_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"max_bet", "max_exposure", "reason", "updated_by", "updated_at"}),
+		}).Create(&override).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND market_id = ?", override.UserID, override.MarketID).First(&override).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionOverrideUpdated,
+			TargetType: "user",
+			TargetID:   uint(override.UserID),
+			Details: fmt.Sprintf("market=%s maxBet=%d maxExposure=%d reason=%s",
+				overrideScope(override.MarketID), override.MaxBet, override.MaxExposure, override.Reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// RemoveOverride deletes the user's override for marketID (0 for every
+// market) and audits it, so the market caps apply again
+func RemoveOverride(db *gorm.DB, userID, marketID int64, actor string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("user_id = ? AND market_id = ?", userID, marketID).Delete(&models.BetLimitOverride{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOverrideNotFound
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionOverrideRemoved,
+			TargetType: "user",
+			TargetID:   uint(userID),
+			Details:    "market=" + overrideScope(marketID),
+		})
+	})
+}
+
+func overrideScope(marketID int64) string {
+	if marketID == 0 {
+		return "all"
+	}
+	return fmt.Sprint(marketID)
+}
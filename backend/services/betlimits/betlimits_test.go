@@ -0,0 +1,106 @@
+package betlimits
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestCheckBoundaries(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 1000)
+	db.Create(&user)
+
+	if _, err := SetMarket(db, 1, 100, 250, "admin"); err != nil {
+		t.Fatalf("SetMarket: %v", err)
+	}
+
+	if err := Check(db, &user, 1, 100); err != nil {
+		t.Fatalf("bet at the cap should be allowed, got %v", err)
+	}
+	var limitErr *LimitError
+	if err := Check(db, &user, 1, 101); !errors.As(err, &limitErr) || limitErr.Kind != KindBet {
+		t.Fatalf("expected a bet limit error, got %v", err)
+	}
+
+	// 100 bought, 20 sold back: 80 staked
+	db.Create(&models.Bet{Username: "alice", MarketID: 1, Amount: 100, Outcome: "YES"})
+	db.Create(&models.Bet{Username: "alice", MarketID: 1, Amount: 100, Outcome: "NO"})
+	db.Create(&models.Bet{Username: "alice", MarketID: 1, Amount: -20, Outcome: "NO"})
+
+	if err := Check(db, &user, 1, 70); err != nil {
+		t.Fatalf("bet taking stake to the cap should be allowed, got %v", err)
+	}
+	err := Check(db, &user, 1, 71)
+	if !errors.As(err, &limitErr) || limitErr.Kind != KindExposure || limitErr.Exposure != 180 {
+		t.Fatalf("expected an exposure limit error with 180 staked, got %v", err)
+	}
+
+	// Other markets are not capped
+	if err := Check(db, &user, 2, 1000); err != nil {
+		t.Fatalf("uncapped market should allow any bet, got %v", err)
+	}
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("whale", 10000)
+	db.Create(&user)
+
+	SetMarket(db, 1, 100, 0, "admin")
+	SetMarket(db, 2, 100, 0, "admin")
+
+	if _, err := SetOverride(db, models.BetLimitOverride{UserID: user.ID, MaxBet: 500, Reason: "market maker"}, "admin"); err != nil {
+		t.Fatalf("SetOverride all markets: %v", err)
+	}
+	if _, err := SetOverride(db, models.BetLimitOverride{UserID: user.ID, MarketID: 2, Reason: "no cap here"}, "admin"); err != nil {
+		t.Fatalf("SetOverride market 2: %v", err)
+	}
+
+	caps, _ := For(db, user.ID, 1)
+	if !caps.Override || caps.MaxBet != 500 {
+		t.Errorf("market 1 should use the all-markets override, got %+v", caps)
+	}
+	caps, _ = For(db, user.ID, 2)
+	if !caps.Override || caps.MaxBet != 0 {
+		t.Errorf("market 2 should use its own override, got %+v", caps)
+	}
+	if err := Check(db, &user, 2, 5000); err != nil {
+		t.Errorf("zero override should lift the cap, got %v", err)
+	}
+
+	other := modelstesting.GenerateUser("bob", 1000)
+	db.Create(&other)
+	if caps, _ := For(db, other.ID, 1); caps.Override || caps.MaxBet != 100 {
+		t.Errorf("other users should get the market caps, got %+v", caps)
+	}
+
+	if err := RemoveOverride(db, user.ID, 0, "admin"); err != nil {
+		t.Fatalf("RemoveOverride: %v", err)
+	}
+	if caps, _ := For(db, user.ID, 1); caps.Override || caps.MaxBet != 100 {
+		t.Errorf("market caps should apply after removal, got %+v", caps)
+	}
+	if err := RemoveOverride(db, user.ID, 0, "admin"); !errors.Is(err, ErrOverrideNotFound) {
+		t.Errorf("expected ErrOverrideNotFound, got %v", err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action IN ?", []string{ActionOverrideUpdated, ActionOverrideRemoved}).Count(&audits)
+	if audits != 3 {
+		t.Errorf("expected 3 override audit entries, got %d", audits)
+	}
+}
+
+func TestSetOverrideRequiresReason(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	if _, err := SetOverride(db, models.BetLimitOverride{UserID: 1, MaxBet: 10}, "admin"); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("expected ErrReasonRequired, got %v", err)
+	}
+	if _, err := SetMarket(db, 1, -1, 0, "admin"); !errors.Is(err, ErrInvalidLimits) {
+		t.Errorf("expected ErrInvalidLimits, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package bonus grants promotional credit. Granted credit can be bet with but
+// not withdrawn or transferred until it has been wagered; see
+// models.User.BonusBalance.
+package bonus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+	"socialpredict/services/notify"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+// ActionGranted is the audit action for a bonus grant
+const ActionGranted = "BONUS_GRANTED"
+
+const referenceType = "bonus_grant"
+
+var (
+	ErrInvalidAmount  = errors.New("amount must be positive")
+	ErrReasonRequired = errors.New("reason is required")
+	ErrUserNotFound   = errors.New("user not found")
+)
+
+// Service grants and lists promotional credit
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService creates a bonus service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+// GrantInput describes a bonus to grant
+type GrantInput struct {
+	Username  string
+	Amount    int64  // Micro-credits
+	Campaign  string // Upper-cased, e.g. SIGNUP
+	Reason    string
+	GrantedBy string // Admin username
+}
+
+// Grant credits the user with promotional credit, records it in the ledger
+// and as bonus balance, and notifies the user. The maker-checker policy can
+// stop admins granting to themselves.
+func (s *Service) Grant(in GrantInput) (*models.BonusGrant, error) {
+	if in.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if strings.TrimSpace(in.Reason) == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var grant models.BonusGrant
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("username = ?", in.Username).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		if user.Username == in.GrantedBy {
+			policy, err := settings.Shared.MakerChecker(tx)
+			if err != nil {
+				return err
+			}
+			if policy.BlockOwnAccount {
+				return settings.ErrOwnAccount
+			}
+		}
+
+		grant = models.BonusGrant{
+			UserID:    user.ID,
+			Amount:    in.Amount,
+			Campaign:  strings.ToUpper(strings.TrimSpace(in.Campaign)),
+			Reason:    in.Reason,
+			GrantedBy: in.GrantedBy,
+		}
+		grant.CreatedAt = s.clock.Now()
+		if err := tx.Create(&grant).Error; err != nil {
+			return err
+		}
+
+		if _, err := ledger.Apply(tx, &user, ledger.Posting{
+			Type:          models.LedgerTypeBonusGrant,
+			Amount:        in.Amount,
+			ReferenceType: referenceType,
+			ReferenceID:   grant.ID,
+			Description:   fmt.Sprintf("Bonus #%d: %s", grant.ID, in.Reason),
+		}); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("bonus_balance", gorm.Expr("bonus_balance + ?", in.Amount)).Error; err != nil {
+			return err
+		}
+
+		if err := audit.Record(tx, models.AuditLog{
+			Actor:      in.GrantedBy,
+			Action:     ActionGranted,
+			TargetType: referenceType,
+			TargetID:   grant.ID,
+			Details: fmt.Sprintf("user=%d amount=%s campaign=%q reason=%q",
+				user.ID, models.FormatMicroCredits(in.Amount), grant.Campaign, in.Reason),
+		}); err != nil {
+			return err
+		}
+		return notify.Send(tx, user.ID, notify.TypeBonusGranted, "Bonus credits",
+			fmt.Sprintf("You received %s bonus credits. Bonus credits can be used for betting; winnings from them can be withdrawn.",
+				models.FormatMicroCredits(in.Amount)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// List returns the most recent grants, optionally for one user or campaign
+func (s *Service) List(userID int64, campaign string, limit int) ([]models.BonusGrant, error) {
+	query := s.db.Model(&models.BonusGrant{})
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if campaign != "" {
+		query = query.Where("campaign = ?", strings.ToUpper(campaign))
+	}
+	var grants []models.BonusGrant
+	err := query.Order("created_at DESC").Limit(limit).Find(&grants).Error
+	return grants, err
+}
@@ -0,0 +1,65 @@
+package bonus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/settings"
+	"socialpredict/services/transfers"
+)
+
+func TestGrantIsSpendableButNotWithdrawable(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 4, 4, 9, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+
+	alice := modelstesting.GenerateUser("alice", 20)
+	bob := modelstesting.GenerateUser("bob", 0)
+	for _, u := range []*models.User{&alice, &bob} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	if _, err := svc.Grant(GrantInput{Username: "alice", Amount: models.CreditsToMicro(50), Reason: ""}); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("grant without reason = %v", err)
+	}
+	if _, err := svc.Grant(GrantInput{Username: "nobody", Amount: 1, Reason: "x"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("grant to unknown user = %v", err)
+	}
+
+	grant, err := svc.Grant(GrantInput{Username: "alice", Amount: models.CreditsToMicro(50), Campaign: "signup", Reason: "Welcome", GrantedBy: "admin"})
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	if grant.Campaign != "SIGNUP" {
+		t.Errorf("campaign = %q", grant.Campaign)
+	}
+
+	var got models.User
+	db.First(&got, alice.ID)
+	if got.AccountBalance != models.CreditsToMicro(70) || got.BonusBalance != models.CreditsToMicro(50) || got.WithdrawableMicroCredits() != models.CreditsToMicro(20) {
+		t.Errorf("user after grant = balance %d bonus %d", got.AccountBalance, got.BonusBalance)
+	}
+
+	var entry models.LedgerEntry
+	if err := db.Where("type = ? AND reference_id = ?", models.LedgerTypeBonusGrant, grant.ID).First(&entry).Error; err != nil ||
+		entry.BalanceAfter != got.BalanceMicroCredits() {
+		t.Errorf("ledger entry = %+v, %v", entry, err)
+	}
+
+	// Bonus credit cannot be passed on to another user either
+	sender := transfers.NewService(db, settings.NewStore(fake), fake)
+	if _, err := sender.Send(alice.ID, "bob", models.CreditsToMicro(30), ""); !errors.Is(err, transfers.ErrBonusNotTransferable) {
+		t.Errorf("transfer of bonus = %v", err)
+	}
+
+	grants, err := svc.List(alice.ID, "SIGNUP", 10)
+	if err != nil || len(grants) != 1 {
+		t.Errorf("List = %+v, %v", grants, err)
+	}
+}
@@ -0,0 +1,293 @@
+// Package chains lets admins add, change and switch off supported chains and
+// the tokens accepted on each, and tells the deposit and withdrawal
+// validators which are open. Only chains DFNS can sign for, those in
+// models.ChainInfo, can be added. Active chains and tokens are cached
+// briefly; every change drops the cache.
+package chains
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions for chain changes
+const (
+	ActionCreated     = "SUPPORTED_CHAIN_CREATED"
+	ActionUpdated     = "SUPPORTED_CHAIN_UPDATED"
+	ActionActivated   = "SUPPORTED_CHAIN_ACTIVATED"
+	ActionDeactivated = "SUPPORTED_CHAIN_DEACTIVATED"
+)
+
+const (
+	targetType          = "supported_chain"
+	cacheTTL            = 30 * time.Second
+	maxDisplayName      = 100
+	maxMinConfirmations = 1000
+)
+
+var (
+	ErrUnknownChain    = errors.New("chain must be a DFNS network the platform knows: ethereum, ethereum-sepolia, tron or tron-nile")
+	ErrChainExists     = errors.New("chain already exists")
+	ErrNotFound        = errors.New("chain not found")
+	ErrInvalidURL      = errors.New("RPC, explorer and icon URLs must be http or https")
+	ErrInvalidAddress  = errors.New("token contract address is not valid for this chain")
+	ErrInvalidChain    = fmt.Errorf("display name must be at most %d characters and min confirmations between 1 and %d", maxDisplayName, maxMinConfirmations)
+	ErrAlreadyInactive = errors.New("chain is already inactive")
+	ErrAlreadyActive   = errors.New("chain is already active")
+)
+
+// Input is the admin-editable part of a supported chain
+type Input struct {
+	Name             string // Fixed once the chain exists
+	DisplayName      string // Defaults to the ChainInfo display name
+	RpcURL           string
+	ExplorerURL      string // Base URL or {kind}/{id} template, see services/explorer
+	MinConfirmations int
+	IconURL          string
+	GraceCrediting   bool // Credit deposits before MinConfirmations, see models.SupportedChain
+}
+
+// validate checks the input for the named chain and fills in defaults
+func (in *Input) validate(name string) error {
+	in.DisplayName = strings.TrimSpace(in.DisplayName)
+	if in.DisplayName == "" {
+		in.DisplayName = models.ChainInfo[name].DisplayName
+	}
+	if len(in.DisplayName) > maxDisplayName || in.MinConfirmations < 1 || in.MinConfirmations > maxMinConfirmations {
+		return ErrInvalidChain
+	}
+	for _, u := range []string{in.RpcURL, in.ExplorerURL, in.IconURL} {
+		if !validURL(u) {
+			return ErrInvalidURL
+		}
+	}
+	return nil
+}
+
+// validURL accepts an empty URL or an absolute http(s) one
+func validURL(raw string) bool {
+	if raw == "" {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Store caches the active supported chains and their active tokens
+type Store struct {
+	clock    clock.Clock
+	mu       sync.RWMutex
+	cached   *snapshot
+	loadedAt time.Time
+}
+
+type snapshot struct {
+	chains map[string]bool
+	tokens map[string]models.ChainToken // By chain name and symbol, see tokenKey
+}
+
+// Shared is the process-wide chain store
+var Shared = NewStore(clock.New())
+
+// NewStore creates an empty chain store
+func NewStore(c clock.Clock) *Store {
+	return &Store{clock: c}
+}
+
+// Invalidate drops the cached chains so the next check goes to the database
+func (s *Store) Invalidate() {
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+}
+
+// IsActive reports whether deposits and withdrawals are open on a chain: it
+// must be a DFNS network the platform knows and an active supported chain
+func (s *Store) IsActive(db *gorm.DB, name string) (bool, error) {
+	if !dfns.IsValidChainName(name) {
+		return false, nil
+	}
+	snap, err := s.load(db)
+	if err != nil {
+		return false, err
+	}
+	return snap.chains[name], nil
+}
+
+func (s *Store) load(db *gorm.DB) (*snapshot, error) {
+	s.mu.RLock()
+	snap, loadedAt := s.cached, s.loadedAt
+	s.mu.RUnlock()
+	if snap != nil && clock.Since(s.clock, loadedAt) < cacheTTL {
+		return snap, nil
+	}
+
+	var names []string
+	if err := db.Model(&models.SupportedChain{}).Where("is_active = ?", true).Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+	var tokens []models.ChainToken
+	if err := db.Preload("Chain").Preload("Token").Where("is_active = ?", true).Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	snap = &snapshot{chains: make(map[string]bool, len(names)), tokens: make(map[string]models.ChainToken, len(tokens))}
+	for _, name := range names {
+		snap.chains[name] = true
+	}
+	for _, token := range tokens {
+		if snap.chains[token.Chain.Name] {
+			snap.tokens[tokenKey(token.Chain.Name, token.Token.Symbol)] = token
+		}
+	}
+
+	s.mu.Lock()
+	s.cached, s.loadedAt = snap, s.clock.Now()
+	s.mu.Unlock()
+	return snap, nil
+}
+
+// List returns every supported chain, active or not
+func List(db *gorm.DB) ([]models.SupportedChain, error) {
+	chains := []models.SupportedChain{}
+	err := db.Order("name").Find(&chains).Error
+	return chains, err
+}
+
+// Create adds an active chain and audits it
+func (s *Store) Create(db *gorm.DB, in Input, actor string) (*models.SupportedChain, error) {
+	name := strings.ToLower(strings.TrimSpace(in.Name))
+	info, known := models.ChainInfo[name]
+	if !known || info.DfnsNetwork == "" || !dfns.IsValidChainName(name) {
+		return nil, ErrUnknownChain
+	}
+	if err := in.validate(name); err != nil {
+		return nil, err
+	}
+
+	chain := &models.SupportedChain{
+		ChainID:          info.ChainID,
+		Name:             name,
+		DisplayName:      in.DisplayName,
+		RpcURL:           in.RpcURL,
+		ExplorerURL:      in.ExplorerURL,
+		MinConfirmations: in.MinConfirmations,
+		IsActive:         true,
+		IconURL:          in.IconURL,
+		GraceCrediting:   in.GraceCrediting,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Unscoped().Model(&models.SupportedChain{}).
+			Where("name = ? OR chain_id = ?", name, info.ChainID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrChainExists
+		}
+		if err := tx.Create(chain).Error; err != nil {
+			return err
+		}
+		return record(tx, actor, ActionCreated, chain, fmt.Sprintf("chain %s (%d) network %s", name, info.ChainID, info.DfnsNetwork))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return chain, nil
+}
+
+// Update replaces a chain's RPC URL, explorer, confirmations, grace crediting,
+// display name and icon, and audits the change. The name cannot change.
+func (s *Store) Update(db *gorm.DB, id uint, in Input, actor string) (*models.SupportedChain, error) {
+	var chain models.SupportedChain
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := find(tx, id, &chain); err != nil {
+			return err
+		}
+		if in.Name != "" && strings.ToLower(strings.TrimSpace(in.Name)) != chain.Name {
+			return ErrUnknownChain
+		}
+		if err := in.validate(chain.Name); err != nil {
+			return err
+		}
+		previous := chain
+		err := tx.Model(&chain).Updates(map[string]interface{}{
+			"display_name":      in.DisplayName,
+			"rpc_url":           in.RpcURL,
+			"explorer_url":      in.ExplorerURL,
+			"min_confirmations": in.MinConfirmations,
+			"icon_url":          in.IconURL,
+			"grace_crediting":   in.GraceCrediting,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return record(tx, actor, ActionUpdated, &chain, fmt.Sprintf("chain %s rpc %q->%q explorer %q->%q confirmations %d->%d grace %t->%t",
+			chain.Name, previous.RpcURL, chain.RpcURL, previous.ExplorerURL, chain.ExplorerURL,
+			previous.MinConfirmations, chain.MinConfirmations, previous.GraceCrediting, chain.GraceCrediting))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return &chain, nil
+}
+
+// SetActive opens or closes a chain to deposits and withdrawals and audits
+// the change. Existing wallets and transactions on it are kept.
+func (s *Store) SetActive(db *gorm.DB, id uint, active bool, actor string) (*models.SupportedChain, error) {
+	var chain models.SupportedChain
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := find(tx, id, &chain); err != nil {
+			return err
+		}
+		if chain.IsActive == active {
+			if active {
+				return ErrAlreadyActive
+			}
+			return ErrAlreadyInactive
+		}
+		if err := tx.Model(&chain).Update("is_active", active).Error; err != nil {
+			return err
+		}
+		action := ActionDeactivated
+		if active {
+			action = ActionActivated
+		}
+		return record(tx, actor, action, &chain, "chain "+chain.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return &chain, nil
+}
+
+func find(tx *gorm.DB, id uint, chain *models.SupportedChain) error {
+	err := tx.First(chain, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func record(tx *gorm.DB, actor, action string, chain *models.SupportedChain, details string) error {
+	return audit.Record(tx, models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   chain.ID,
+		Details:    details,
+	})
+}
@@ -0,0 +1,79 @@
+package chains
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestAdminChainChangesReachTheValidators(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t.Cleanup(Shared.Invalidate)
+	store := Shared
+
+	// Sepolia is a DFNS network but not seeded; polygon is seeded but not a DFNS network
+	if ok, err := store.IsActive(db, "ethereum-sepolia"); err != nil || ok {
+		t.Fatalf("sepolia active before it was added: %v, %v", ok, err)
+	}
+	if ok, _ := store.IsActive(db, "polygon"); ok {
+		t.Fatalf("polygon active without a DFNS network")
+	}
+	if _, err := store.Create(db, Input{Name: "polygon", MinConfirmations: 12}, "root"); !errors.Is(err, ErrUnknownChain) {
+		t.Fatalf("polygon err = %v", err)
+	}
+
+	in := Input{Name: "ethereum-sepolia", RpcURL: "ftp://node", MinConfirmations: 3}
+	if _, err := store.Create(db, in, "root"); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("bad RPC err = %v", err)
+	}
+	in.RpcURL = "https://sepolia.example.com"
+	in.MinConfirmations = 0
+	if _, err := store.Create(db, in, "root"); !errors.Is(err, ErrInvalidChain) {
+		t.Fatalf("no confirmations err = %v", err)
+	}
+
+	in.MinConfirmations = 3
+	chain, err := store.Create(db, in, "root")
+	if err != nil || chain.ChainID != 11155111 || chain.DisplayName != "Ethereum Sepolia" || !chain.IsActive {
+		t.Fatalf("create = %+v, %v", chain, err)
+	}
+	if ok, _ := store.IsActive(db, "ethereum-sepolia"); !ok {
+		t.Fatalf("sepolia not active after it was added")
+	}
+	if _, err := store.Create(db, in, "root"); !errors.Is(err, ErrChainExists) {
+		t.Fatalf("duplicate err = %v", err)
+	}
+
+	in.Name = ""
+	in.RpcURL = "https://rpc.sepolia.example.com"
+	in.MinConfirmations = 6
+	updated, err := store.Update(db, chain.ID, in, "root")
+	if err != nil || updated.RpcURL != in.RpcURL || updated.MinConfirmations != 6 {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	in.Name = "tron"
+	if _, err := store.Update(db, chain.ID, in, "root"); !errors.Is(err, ErrUnknownChain) {
+		t.Fatalf("rename err = %v", err)
+	}
+
+	if _, err := store.SetActive(db, chain.ID, false, "root"); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	if ok, _ := store.IsActive(db, "ethereum-sepolia"); ok {
+		t.Fatalf("sepolia still active after it was deactivated")
+	}
+	if _, err := store.SetActive(db, chain.ID, false, "root"); !errors.Is(err, ErrAlreadyInactive) {
+		t.Fatalf("deactivate again err = %v", err)
+	}
+	if _, err := store.SetActive(db, 999, true, "root"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing chain err = %v", err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ?", targetType, chain.ID).Count(&audits)
+	if audits != 3 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
@@ -0,0 +1,286 @@
+package chains
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions for token changes
+const (
+	ActionTokenAdded       = "CHAIN_TOKEN_ADDED"
+	ActionTokenUpdated     = "CHAIN_TOKEN_UPDATED"
+	ActionTokenActivated   = "CHAIN_TOKEN_ACTIVATED"
+	ActionTokenDeactivated = "CHAIN_TOKEN_DEACTIVATED"
+)
+
+const (
+	tokenTargetType = "chain_token"
+	maxDecimals     = 36
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+
+var (
+	ErrTokenNotFound        = errors.New("token not available on this chain")
+	ErrTokenExists          = errors.New("token or contract already on this chain")
+	ErrTokenAlreadyActive   = errors.New("token is already active on this chain")
+	ErrTokenAlreadyInactive = errors.New("token is already inactive on this chain")
+	ErrInvalidToken         = fmt.Errorf("symbol must be 2 to 10 letters or digits, a new token needs a name, decimals run from 0 to %d and the minimum deposit cannot be negative", maxDecimals)
+)
+
+// TokenInput is the admin-editable part of a token on a chain
+type TokenInput struct {
+	Symbol          string // Fixed once the token is on the chain
+	Name            string // Only used for a symbol the platform has not seen before
+	ContractAddress string
+	Decimals        int
+	MinDeposit      int64 // Micro-credits; 0 for no minimum
+}
+
+func tokenKey(chainName, symbol string) string {
+	return chainName + "/" + symbol
+}
+
+// Token returns an active token on an active chain, for validating requests
+func (s *Store) Token(db *gorm.DB, chainName, symbol string) (*models.ChainToken, error) {
+	snap, err := s.load(db)
+	if err != nil {
+		return nil, err
+	}
+	token, ok := snap.tokens[tokenKey(chainName, symbol)]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &token, nil
+}
+
+// Tokens returns every token on a chain, active or not, by symbol
+func Tokens(db *gorm.DB, chainID uint) ([]models.ChainToken, error) {
+	tokens := []models.ChainToken{}
+	err := db.Preload("Token").
+		Joins("JOIN supported_tokens ON supported_tokens.id = chain_tokens.supported_token_id").
+		Where("chain_tokens.supported_chain_id = ?", chainID).
+		Order("supported_tokens.symbol").Find(&tokens).Error
+	return tokens, err
+}
+
+// ActiveToken reads an active token on a chain from the database, bypassing
+// the cache, for moving funds
+func ActiveToken(db *gorm.DB, chainName, symbol string) (*models.ChainToken, error) {
+	return tokenOnChain(db.Where("chain_tokens.is_active = ?", true), chainName, symbol)
+}
+
+// TokenOnChain finds a token on a chain, active or not
+func TokenOnChain(db *gorm.DB, chainName, symbol string) (*models.ChainToken, error) {
+	return tokenOnChain(db, chainName, symbol)
+}
+
+func tokenOnChain(db *gorm.DB, chainName, symbol string) (*models.ChainToken, error) {
+	var token models.ChainToken
+	err := db.Preload("Chain").Preload("Token").
+		Joins("JOIN supported_chains ON supported_chains.id = chain_tokens.supported_chain_id").
+		Joins("JOIN supported_tokens ON supported_tokens.id = chain_tokens.supported_token_id").
+		Where("supported_chains.name = ? AND supported_tokens.symbol = ?", chainName, symbol).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TokenByContract finds the token at a contract address on a chain. Inactive
+// tokens are included: funds sent to a deposit address have arrived whether
+// or not the token is still offered.
+func TokenByContract(db *gorm.DB, chain *models.SupportedChain, contract string) (*models.ChainToken, error) {
+	tokens, err := Tokens(db, chain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tokens {
+		// Base58 TRON contracts are compared by their decoded bytes, EVM ones case-insensitively
+		if dfns.SameAddress(chain.Name, contract, tokens[i].ContractAddress) {
+			tokens[i].Chain = *chain
+			return &tokens[i], nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+// AddToken enables a token on a chain and audits it. A symbol the platform has
+// not seen before is added to the supported tokens.
+func (s *Store) AddToken(db *gorm.DB, chainID uint, in TokenInput, actor string) (*models.ChainToken, error) {
+	in.Symbol = strings.ToUpper(strings.TrimSpace(in.Symbol))
+	in.Name = strings.TrimSpace(in.Name)
+	if !symbolPattern.MatchString(in.Symbol) {
+		return nil, ErrInvalidToken
+	}
+
+	var token models.ChainToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var chain models.SupportedChain
+		if err := find(tx, chainID, &chain); err != nil {
+			return err
+		}
+		if err := in.validate(&chain); err != nil {
+			return err
+		}
+		existing, err := Tokens(tx, chain.ID)
+		if err != nil {
+			return err
+		}
+		for _, t := range existing {
+			if t.Token.Symbol == in.Symbol || dfns.SameAddress(chain.Name, t.ContractAddress, in.ContractAddress) {
+				return ErrTokenExists
+			}
+		}
+
+		var supported models.SupportedToken
+		err = tx.Where("symbol = ?", in.Symbol).First(&supported).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if in.Name == "" {
+				return ErrInvalidToken
+			}
+			supported = models.SupportedToken{Symbol: in.Symbol, Name: in.Name, Decimals: in.Decimals, IsActive: true}
+			err = tx.Create(&supported).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		token = models.ChainToken{
+			SupportedChainID: chain.ID,
+			SupportedTokenID: supported.ID,
+			ContractAddress:  in.ContractAddress,
+			Decimals:         in.Decimals,
+			MinDeposit:       in.MinDeposit,
+			IsActive:         true,
+			Chain:            chain,
+			Token:            supported,
+		}
+		if err := tx.Omit("Chain", "Token").Create(&token).Error; err != nil {
+			return err
+		}
+		return recordToken(tx, actor, ActionTokenAdded, &token,
+			fmt.Sprintf("%s on %s at %s, %d decimals, min deposit %s", in.Symbol, chain.Name, in.ContractAddress, in.Decimals, models.FormatMicroCredits(in.MinDeposit)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return &token, nil
+}
+
+// UpdateToken changes a token's contract address, decimals and minimum deposit
+// on a chain and audits the change
+func (s *Store) UpdateToken(db *gorm.DB, chainID, id uint, in TokenInput, actor string) (*models.ChainToken, error) {
+	var token models.ChainToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := findToken(tx, chainID, id, &token); err != nil {
+			return err
+		}
+		if in.Symbol != "" && strings.ToUpper(strings.TrimSpace(in.Symbol)) != token.Token.Symbol {
+			return ErrInvalidToken
+		}
+		if err := in.validate(&token.Chain); err != nil {
+			return err
+		}
+		others, err := Tokens(tx, chainID)
+		if err != nil {
+			return err
+		}
+		for _, t := range others {
+			if t.ID != token.ID && dfns.SameAddress(token.Chain.Name, t.ContractAddress, in.ContractAddress) {
+				return ErrTokenExists
+			}
+		}
+
+		previous := token
+		if err := tx.Model(&token).Omit("Chain", "Token").Updates(map[string]interface{}{
+			"contract_address": in.ContractAddress,
+			"decimals":         in.Decimals,
+			"min_deposit":      in.MinDeposit,
+		}).Error; err != nil {
+			return err
+		}
+		return recordToken(tx, actor, ActionTokenUpdated, &token, fmt.Sprintf("%s on %s contract %s->%s decimals %d->%d min deposit %s->%s",
+			token.Token.Symbol, token.Chain.Name, previous.ContractAddress, token.ContractAddress, previous.Decimals, token.Decimals,
+			models.FormatMicroCredits(previous.MinDeposit), models.FormatMicroCredits(token.MinDeposit)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return &token, nil
+}
+
+// SetTokenActive opens or closes a token on a chain to deposits and
+// withdrawals and audits the change
+func (s *Store) SetTokenActive(db *gorm.DB, chainID, id uint, active bool, actor string) (*models.ChainToken, error) {
+	var token models.ChainToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := findToken(tx, chainID, id, &token); err != nil {
+			return err
+		}
+		if token.IsActive == active {
+			if active {
+				return ErrTokenAlreadyActive
+			}
+			return ErrTokenAlreadyInactive
+		}
+		if err := tx.Model(&token).Omit("Chain", "Token").Update("is_active", active).Error; err != nil {
+			return err
+		}
+		action := ActionTokenDeactivated
+		if active {
+			action = ActionTokenActivated
+		}
+		return recordToken(tx, actor, action, &token, token.Token.Symbol+" on "+token.Chain.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return &token, nil
+}
+
+// validate checks the contract address suits the chain and the decimals and
+// minimum deposit are sane
+func (in *TokenInput) validate(chain *models.SupportedChain) error {
+	in.ContractAddress = strings.TrimSpace(in.ContractAddress)
+	if !dfns.IsValidAddress(in.ContractAddress, chain.Name) {
+		return ErrInvalidAddress
+	}
+	if in.Decimals < 0 || in.Decimals > maxDecimals || in.MinDeposit < 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func findToken(tx *gorm.DB, chainID, id uint, token *models.ChainToken) error {
+	err := tx.Preload("Chain").Preload("Token").Where("supported_chain_id = ?", chainID).First(token, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTokenNotFound
+	}
+	return err
+}
+
+func recordToken(tx *gorm.DB, actor, action string, token *models.ChainToken, details string) error {
+	return audit.Record(tx, models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: tokenTargetType,
+		TargetID:   token.ID,
+		Details:    details,
+	})
+}
@@ -0,0 +1,94 @@
+package chains
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+const daiContract = "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+
+func TestAdminCanEnableANewStablecoin(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t.Cleanup(Shared.Invalidate)
+	store := Shared
+
+	var ethereum models.SupportedChain
+	if err := db.Where("name = ?", "ethereum").First(&ethereum).Error; err != nil {
+		t.Fatalf("load seeded chain: %v", err)
+	}
+	usdc, err := store.Token(db, "ethereum", "USDC")
+	if err != nil || usdc.Decimals != 6 {
+		t.Fatalf("seeded USDC = %+v, %v", usdc, err)
+	}
+	if _, err := store.Token(db, "ethereum", "DAI"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("DAI before it was added err = %v", err)
+	}
+
+	in := TokenInput{Symbol: "dai", ContractAddress: daiContract, Decimals: 18}
+	if _, err := store.AddToken(db, ethereum.ID, in, "root"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("new symbol without a name err = %v", err)
+	}
+	in.Name = "Dai Stablecoin"
+	in.ContractAddress = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	if _, err := store.AddToken(db, ethereum.ID, in, "root"); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("TRON address on ethereum err = %v", err)
+	}
+	in.ContractAddress = strings.ToLower(usdc.ContractAddress)
+	if _, err := store.AddToken(db, ethereum.ID, in, "root"); !errors.Is(err, ErrTokenExists) {
+		t.Fatalf("USDC contract reused err = %v", err)
+	}
+
+	in.ContractAddress = daiContract
+	dai, err := store.AddToken(db, ethereum.ID, in, "root")
+	if err != nil || dai.Token.Symbol != "DAI" || dai.Decimals != 18 || !dai.IsActive {
+		t.Fatalf("add = %+v, %v", dai, err)
+	}
+	if got, err := store.Token(db, "ethereum", "DAI"); err != nil || got.ContractAddress != daiContract {
+		t.Fatalf("DAI not offered after it was added: %+v, %v", got, err)
+	}
+	if _, err := store.AddToken(db, ethereum.ID, in, "root"); !errors.Is(err, ErrTokenExists) {
+		t.Fatalf("duplicate err = %v", err)
+	}
+
+	in.MinDeposit = -1
+	if _, err := store.UpdateToken(db, ethereum.ID, dai.ID, in, "root"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("negative minimum deposit err = %v", err)
+	}
+	in.MinDeposit = 1_000_000
+	if updated, err := store.UpdateToken(db, ethereum.ID, dai.ID, in, "root"); err != nil || updated.MinDeposit != 1_000_000 {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	in.Decimals = 40
+	if _, err := store.UpdateToken(db, ethereum.ID, dai.ID, in, "root"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("bad decimals err = %v", err)
+	}
+	if _, err := store.UpdateToken(db, 999, dai.ID, in, "root"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("token on another chain err = %v", err)
+	}
+
+	if _, err := store.SetTokenActive(db, ethereum.ID, dai.ID, false, "root"); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	if _, err := store.Token(db, "ethereum", "DAI"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("DAI still offered after it was deactivated: %v", err)
+	}
+	if _, err := store.SetTokenActive(db, ethereum.ID, dai.ID, false, "root"); !errors.Is(err, ErrTokenAlreadyInactive) {
+		t.Fatalf("deactivate again err = %v", err)
+	}
+
+	// Deposits to a token that is no longer offered are still recognised
+	found, err := TokenByContract(db, &ethereum, strings.ToLower(daiContract))
+	if err != nil || found.ID != dai.ID || found.Decimals != 18 {
+		t.Fatalf("by contract = %+v, %v", found, err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ?", tokenTargetType, dai.ID).Count(&audits)
+	if audits != 3 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
@@ -0,0 +1,257 @@
+// Package chainscan detects deposits by reading ERC20 Transfer logs straight
+// from each chain's RPC node (SupportedChain.RpcURL). It is a redundancy layer
+// for when DFNS webhooks are delayed: deposits are handed to the same
+// crediting path as webhooks and deduplicated against them by tx hash.
+package chainscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/evmrpc"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBatchBlocks  = 2000
+	defaultRPCTimeout   = 30 * time.Second
+	defaultAddressChunk = 100 // Recipient topics per eth_getLogs call
+)
+
+// LogSource reads blocks and logs from an RPC node. *evmrpc.Client satisfies it.
+type LogSource interface {
+	BlockNumber(ctx context.Context, url string) (uint64, error)
+	GetLogs(ctx context.Context, url string, filter evmrpc.LogFilter) ([]evmrpc.Log, error)
+}
+
+// Crediter records a confirmed inbound transfer to a wallet held by org, as a
+// DFNS webhook would. raw is stored with the deposit for auditing.
+type Crediter func(org string, data *dfns.TransferEventData, raw []byte) error
+
+// Config holds chain scanner settings
+type Config struct {
+	Enabled     bool
+	BatchBlocks uint64        // Most blocks read per chain per scan
+	RPCTimeout  time.Duration // How long one chain's scan may take
+}
+
+// LoadConfigFromEnv reads CHAIN_SCANNER_ENABLED, CHAIN_SCANNER_BATCH_BLOCKS and CHAIN_SCANNER_RPC_TIMEOUT
+func LoadConfigFromEnv() Config {
+	config := Config{
+		Enabled:     os.Getenv("CHAIN_SCANNER_ENABLED") == "true",
+		BatchBlocks: defaultBatchBlocks,
+		RPCTimeout:  defaultRPCTimeout,
+	}
+	if n, err := strconv.ParseUint(os.Getenv("CHAIN_SCANNER_BATCH_BLOCKS"), 10, 64); err == nil && n > 0 {
+		config.BatchBlocks = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("CHAIN_SCANNER_RPC_TIMEOUT")); err == nil && d > 0 {
+		config.RPCTimeout = d
+	}
+	return config
+}
+
+// Service scans chains for deposits
+type Service struct {
+	db     *gorm.DB
+	source LogSource
+	credit Crediter
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a chain scanner
+func NewService(db *gorm.DB, source LogSource, credit Crediter, config Config, c clock.Clock) *Service {
+	return &Service{db: db, source: source, credit: credit, config: config, clock: c}
+}
+
+// Enabled reports whether CHAIN_SCANNER_ENABLED is set
+func (s *Service) Enabled() bool {
+	return s.config.Enabled
+}
+
+// ScanAll scans every active chain that has an RPC endpoint and returns how
+// many deposits were handed over for crediting
+func (s *Service) ScanAll(ctx context.Context) (int, error) {
+	var chains []models.SupportedChain
+	if err := s.db.Where("is_active = ? AND rpc_url <> ''", true).Order("name").Find(&chains).Error; err != nil {
+		return 0, err
+	}
+	total := 0
+	var errs []error
+	for i := range chains {
+		n, err := s.ScanChain(ctx, &chains[i])
+		total += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", chains[i].Name, err))
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// ScanChain reads the next batch of blocks that have enough confirmations.
+// The first scan of a chain only records the current block as its starting
+// point. The cursor is not moved past a block whose deposits failed, so they
+// are retried on the next scan.
+func (s *Service) ScanChain(ctx context.Context, chain *models.SupportedChain) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.RPCTimeout)
+	defer cancel()
+
+	tokens, err := chains.Tokens(s.db, chain.ID)
+	if err != nil {
+		return 0, err
+	}
+	contracts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if dfns.IsValidEVMAddress(token.ContractAddress) {
+			contracts = append(contracts, token.ContractAddress)
+		}
+	}
+	if len(contracts) == 0 {
+		return 0, nil
+	}
+
+	head, err := s.source.BlockNumber(ctx, chain.RpcURL)
+	if err != nil {
+		return 0, err
+	}
+	confirmations := uint64(0)
+	if chain.MinConfirmations > 0 {
+		confirmations = uint64(chain.MinConfirmations)
+	}
+	if head < confirmations {
+		return 0, nil
+	}
+	safe := head - confirmations
+
+	var cursor models.ChainScanCursor
+	err = s.db.Where("chain_name = ?", chain.Name).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, s.db.Create(&models.ChainScanCursor{ChainName: chain.Name, LastBlock: int64(safe), UpdatedAt: s.clock.Now()}).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+	from := uint64(cursor.LastBlock) + 1
+	if from > safe {
+		return 0, nil
+	}
+	to := min(safe, from+s.config.BatchBlocks-1)
+
+	var wallets []models.Wallet
+	if err := s.db.Where("chain_id = ?", chain.ChainID).Find(&wallets).Error; err != nil {
+		return 0, err
+	}
+	byAddress := make(map[string]*models.Wallet, len(wallets))
+	topics := make([]string, 0, len(wallets))
+	for i := range wallets {
+		if !dfns.IsValidEVMAddress(wallets[i].Address) {
+			continue
+		}
+		byAddress[strings.ToLower(wallets[i].Address)] = &wallets[i]
+		topics = append(topics, evmrpc.AddressTopic(wallets[i].Address))
+	}
+
+	credited := 0
+	for start := 0; start < len(topics); start += defaultAddressChunk {
+		chunk := topics[start:min(start+defaultAddressChunk, len(topics))]
+		logs, err := s.source.GetLogs(ctx, chain.RpcURL, evmrpc.LogFilter{
+			FromBlock: evmrpc.Quantity(from),
+			ToBlock:   evmrpc.Quantity(to),
+			Address:   contracts,
+			Topics:    []interface{}{evmrpc.TransferTopic, nil, chunk},
+		})
+		if err != nil {
+			return credited, err
+		}
+		for _, l := range logs {
+			done, err := s.handleLog(byAddress, l)
+			if err != nil {
+				return credited, fmt.Errorf("deposit %s: %w", l.TransactionHash, err)
+			}
+			if done {
+				credited++
+			}
+		}
+	}
+
+	return credited, s.db.Model(&models.ChainScanCursor{}).Where("chain_name = ?", chain.Name).
+		Updates(map[string]interface{}{"last_block": int64(to), "updated_at": s.clock.Now()}).Error
+}
+
+// handleLog credits one Transfer log unless a webhook already recorded it. A
+// deposit still pending its DFNS confirmation is passed on, since the scanner
+// only sees blocks with enough confirmations.
+func (s *Service) handleLog(byAddress map[string]*models.Wallet, l evmrpc.Log) (bool, error) {
+	if len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], evmrpc.TransferTopic) {
+		return false, nil
+	}
+	wallet, ok := byAddress[strings.ToLower(evmrpc.TopicAddress(l.Topics[2]))]
+	if !ok {
+		return false, nil
+	}
+	amount, ok := evmrpc.ParseAmount(l.Data)
+	if !ok || amount.Sign() <= 0 {
+		return false, nil
+	}
+	logIndex, err := evmrpc.ParseQuantity(l.LogIndex)
+	if err != nil {
+		return false, fmt.Errorf("log index %q: %w", l.LogIndex, err)
+	}
+
+	transfer := models.CryptoTransaction{ChainID: wallet.ChainID, TxHash: l.TransactionHash, Type: models.TxTypeDeposit, ToAddress: wallet.Address, LogIndex: int64(logIndex)}
+	var existing models.CryptoTransaction
+	if err := s.db.Scopes(models.SameTransfer(&transfer)).First(&existing).Error; err == nil {
+		if existing.Status != models.TxStatusPending {
+			return false, nil
+		}
+	}
+
+	raw, err := json.Marshal(l)
+	if err != nil {
+		return false, err
+	}
+	data := &dfns.TransferEventData{
+		WalletID:  wallet.DfnsWalletID,
+		Status:    dfns.TransferStatusConfirmed,
+		TxHash:    l.TransactionHash,
+		Direction: "Inbound",
+		Kind:      "Erc20",
+		Amount:    amount.String(),
+		From:      evmrpc.TopicAddress(l.Topics[1]),
+		To:        wallet.Address,
+		Contract:  l.Address,
+		LogIndex:  int64(logIndex),
+	}
+	if err := s.credit(wallet.DfnsOrg, data, raw); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Run scans every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.ScanAll(context.Background())
+		if err != nil {
+			log.Printf("ChainScan: Scan failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("ChainScan: Passed %d deposits for crediting", n)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package chainscan
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/evmrpc"
+
+	"gorm.io/gorm"
+)
+
+const depositAddress = "0x2222222222222222222222222222222222222222"
+
+type fakeSource struct {
+	head    uint64
+	logs    []evmrpc.Log
+	filters []evmrpc.LogFilter
+}
+
+func (f *fakeSource) BlockNumber(context.Context, string) (uint64, error) {
+	return f.head, nil
+}
+
+func (f *fakeSource) GetLogs(_ context.Context, _ string, filter evmrpc.LogFilter) ([]evmrpc.Log, error) {
+	f.filters = append(f.filters, filter)
+	return f.logs, nil
+}
+
+func setupScanner(t *testing.T) (*gorm.DB, *models.SupportedChain, *fakeSource, *[]*dfns.TransferEventData, *Service) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	// Supported chains are seeded by migrations
+	var chain models.SupportedChain
+	if err := db.Where("chain_id = ?", 8453).First(&chain).Error; err != nil {
+		t.Fatalf("load seeded chain: %v", err)
+	}
+	chain.RpcURL, chain.MinConfirmations = "http://rpc.invalid", 10
+	db.Save(&chain)
+
+	wallet := models.Wallet{UserID: 1, DfnsWalletID: "wa-1", DfnsOrg: dfns.PrimaryOrg, ChainID: 8453, ChainName: "base", Address: depositAddress, IsActive: true}
+	if err := db.Create(&wallet).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+
+	source := &fakeSource{head: 1000}
+	var credited []*dfns.TransferEventData
+	credit := func(org string, data *dfns.TransferEventData, _ []byte) error {
+		credited = append(credited, data)
+		return nil
+	}
+	svc := NewService(db, source, credit, Config{Enabled: true, BatchBlocks: 100, RPCTimeout: time.Second}, clock.NewFake(time.Now()))
+	return db, &chain, source, &credited, svc
+}
+
+func usdcContract(t *testing.T, db *gorm.DB, chain *models.SupportedChain) string {
+	t.Helper()
+	token, err := chains.TokenOnChain(db, chain.Name, "USDC")
+	if err != nil {
+		t.Fatalf("load seeded USDC contract: %v", err)
+	}
+	return token.ContractAddress
+}
+
+func transferLog(contract, txHash string) evmrpc.Log {
+	return evmrpc.Log{
+		Address:         contract,
+		Topics:          []string{evmrpc.TransferTopic, evmrpc.AddressTopic("0x1111111111111111111111111111111111111111"), evmrpc.AddressTopic(depositAddress)},
+		Data:            "0x" + "00000000000000000000000000000000000000000000000000000000017d7840", // 25 USDC
+		TransactionHash: txHash,
+		LogIndex:        "0x0",
+	}
+}
+
+func TestScanChainCreditsConfirmedTransfers(t *testing.T) {
+	db, chain, source, credited, svc := setupScanner(t)
+
+	// The first scan only records where to start
+	if n, err := svc.ScanChain(context.Background(), chain); err != nil || n != 0 {
+		t.Fatalf("first scan = %d, %v", n, err)
+	}
+	var cursor models.ChainScanCursor
+	db.First(&cursor, "chain_name = ?", "base")
+	if cursor.LastBlock != 990 {
+		t.Fatalf("cursor = %d, want head minus confirmations (990)", cursor.LastBlock)
+	}
+
+	source.head = 1500
+	source.logs = []evmrpc.Log{transferLog(usdcContract(t, db, chain), "0xnew"), transferLog(usdcContract(t, db, chain), "0xdone")}
+	db.Create(&models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 8453, TxHash: "0xdone", ToAddress: depositAddress})
+
+	n, err := svc.ScanChain(context.Background(), chain)
+	if err != nil || n != 1 {
+		t.Fatalf("scan = %d, %v; want 1 deposit", n, err)
+	}
+	got := (*credited)[0]
+	if got.TxHash != "0xnew" || got.WalletID != "wa-1" || got.Amount != "25000000" || got.Status != dfns.TransferStatusConfirmed {
+		t.Errorf("credited %+v", got)
+	}
+	if f := source.filters[0]; f.FromBlock != evmrpc.Quantity(991) || f.ToBlock != evmrpc.Quantity(1090) {
+		t.Errorf("filter range %s-%s, want one batch from 991", f.FromBlock, f.ToBlock)
+	}
+	db.First(&cursor, "chain_name = ?", "base")
+	if cursor.LastBlock != 1090 {
+		t.Errorf("cursor = %d, want 1090", cursor.LastBlock)
+	}
+}
+
+func TestScanChainConfirmsPendingWebhookDeposit(t *testing.T) {
+	db, chain, source, credited, svc := setupScanner(t)
+	db.Create(&models.ChainScanCursor{ChainName: "base", LastBlock: 900})
+	db.Create(&models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusPending, ChainID: 8453, TxHash: "0xpending", ToAddress: depositAddress})
+	source.logs = []evmrpc.Log{transferLog(usdcContract(t, db, chain), "0xpending")}
+
+	if n, err := svc.ScanChain(context.Background(), chain); err != nil || n != 1 || (*credited)[0].TxHash != "0xpending" {
+		t.Errorf("scan = %d, %v; want the pending deposit passed on", n, err)
+	}
+}
+
+func TestScanChainCreditsBatchedTransfer(t *testing.T) {
+	db, chain, source, credited, svc := setupScanner(t)
+	db.Create(&models.ChainScanCursor{ChainName: "base", LastBlock: 900})
+	// Another transfer batched into the same transaction was already recorded
+	db.Create(&models.CryptoTransaction{UserID: 2, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 8453, TxHash: "0xbatch", ToAddress: "0x3333333333333333333333333333333333333333"})
+	source.logs = []evmrpc.Log{transferLog(usdcContract(t, db, chain), "0xbatch")}
+
+	if n, err := svc.ScanChain(context.Background(), chain); err != nil || n != 1 || (*credited)[0].TxHash != "0xbatch" {
+		t.Errorf("scan = %d, %v; want the batched transfer credited", n, err)
+	}
+}
+
+func TestScanChainCreditsSecondTransferToSameAddress(t *testing.T) {
+	db, chain, source, credited, svc := setupScanner(t)
+	db.Create(&models.ChainScanCursor{ChainName: "base", LastBlock: 900})
+	// The transaction's first transfer to the wallet was already recorded
+	db.Create(&models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainID: 8453, TxHash: "0xtwice", ToAddress: depositAddress})
+	second := transferLog(usdcContract(t, db, chain), "0xtwice")
+	second.LogIndex = "0x3"
+	source.logs = []evmrpc.Log{transferLog(usdcContract(t, db, chain), "0xtwice"), second}
+
+	n, err := svc.ScanChain(context.Background(), chain)
+	if err != nil || n != 1 {
+		t.Fatalf("scan = %d, %v; want only the second transfer credited", n, err)
+	}
+	if got := (*credited)[0]; got.TxHash != "0xtwice" || got.LogIndex != 3 {
+		t.Errorf("credited %+v, want log index 3", got)
+	}
+}
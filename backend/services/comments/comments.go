@@ -0,0 +1,92 @@
+// Package comments stores users' comments on markets and pushes each new
+// comment to the market's stream subscribers.
+package comments
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/groups"
+	"socialpredict/services/stream"
+
+	"gorm.io/gorm"
+)
+
+const maxBodyLength = 2000
+
+var (
+	ErrMarketNotFound = errors.New("market not found")
+	ErrInvalidBody    = fmt.Errorf("comment must be 1 to %d characters", maxBodyLength)
+)
+
+// Service adds and lists market comments
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+	hub   *stream.Hub
+}
+
+// NewService returns a comment service publishing to hub
+func NewService(db *gorm.DB, c clock.Clock, hub *stream.Hub) *Service {
+	return &Service{db: db, clock: c, hub: hub}
+}
+
+// Add posts a comment by user on a market and publishes it as a comment event
+func (s *Service) Add(user *models.User, marketID int64, body string) (*models.MarketComment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxBodyLength {
+		return nil, ErrInvalidBody
+	}
+	if err := s.checkCanView(user, marketID); err != nil {
+		return nil, err
+	}
+
+	comment := models.MarketComment{
+		MarketID:  marketID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Body:      body,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.db.Create(&comment).Error; err != nil {
+		return nil, err
+	}
+	s.hub.Publish(marketID, stream.EventComment, comment)
+	return &comment, nil
+}
+
+// List returns up to limit of a market's comments, newest first. viewer is
+// nil for anonymous visitors.
+func (s *Service) List(viewer *models.User, marketID int64, limit int) ([]models.MarketComment, error) {
+	if err := s.checkCanView(viewer, marketID); err != nil {
+		return nil, err
+	}
+	comments := []models.MarketComment{}
+	err := s.db.Where("market_id = ?", marketID).
+		Order("created_at DESC, id DESC").Limit(limit).Find(&comments).Error
+	return comments, err
+}
+
+// checkCanView returns ErrMarketNotFound if the market does not exist or is
+// group-only and user is not in its group
+func (s *Service) checkCanView(user *models.User, marketID int64) error {
+	var market models.Market
+	if err := s.db.Select("id", "visibility", "group_id").First(&market, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMarketNotFound
+		}
+		return err
+	}
+	ok, err := groups.CanView(s.db, &market, user)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMarketNotFound
+	}
+	return nil
+}
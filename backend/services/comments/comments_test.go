@@ -0,0 +1,95 @@
+package comments
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/stream"
+)
+
+func TestAddStoresAndPublishesComment(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 0)
+	market := modelstesting.GenerateMarket(1, "alice")
+	db.Create(&user)
+	db.Create(&market)
+
+	hub := stream.NewHub(4)
+	sub, err := hub.Subscribe(market.ID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	now := time.Date(2026, 4, 29, 12, 0, 0, 0, time.UTC)
+	svc := NewService(db, clock.NewFake(now), hub)
+
+	comment, err := svc.Add(&user, market.ID, "  Rain looks likely  ")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if comment.Body != "Rain looks likely" || comment.Username != "alice" || !comment.CreatedAt.Equal(now) {
+		t.Errorf("comment = %+v", comment)
+	}
+
+	event := <-sub.Events()
+	if event.Type != stream.EventComment || event.Data.(models.MarketComment).ID != comment.ID {
+		t.Fatalf("event = %+v, want the comment", event)
+	}
+
+	list, err := svc.List(&user, market.ID, 10)
+	if err != nil || len(list) != 1 || list[0].ID != comment.ID {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+}
+
+func TestAddValidates(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 0)
+	market := modelstesting.GenerateMarket(1, "alice")
+	db.Create(&user)
+	db.Create(&market)
+	svc := NewService(db, clock.New(), stream.NewHub(4))
+
+	if _, err := svc.Add(&user, market.ID, "   "); !errors.Is(err, ErrInvalidBody) {
+		t.Errorf("blank comment: err = %v", err)
+	}
+	if _, err := svc.Add(&user, market.ID, strings.Repeat("a", maxBodyLength+1)); !errors.Is(err, ErrInvalidBody) {
+		t.Errorf("long comment: err = %v", err)
+	}
+	if _, err := svc.Add(&user, 99, "hello"); !errors.Is(err, ErrMarketNotFound) {
+		t.Errorf("unknown market: err = %v", err)
+	}
+}
+
+func TestGroupMarketCommentsNeedMembership(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	owner := modelstesting.GenerateUser("owner", 0)
+	outsider := modelstesting.GenerateUser("outsider", 0)
+	db.Create(&owner)
+	db.Create(&outsider)
+	group := models.Group{Name: "Office pool", OwnerID: owner.ID}
+	db.Create(&group)
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: owner.ID, Role: models.GroupRoleOwner})
+	market := modelstesting.GenerateMarket(1, "owner")
+	market.Visibility = models.MarketVisibilityGroup
+	market.GroupID = &group.ID
+	db.Create(&market)
+	svc := NewService(db, clock.New(), stream.NewHub(4))
+
+	if _, err := svc.Add(&outsider, market.ID, "hello"); !errors.Is(err, ErrMarketNotFound) {
+		t.Errorf("outsider Add: err = %v", err)
+	}
+	if _, err := svc.List(&outsider, market.ID, 10); !errors.Is(err, ErrMarketNotFound) {
+		t.Errorf("outsider List: err = %v", err)
+	}
+	if _, err := svc.List(nil, market.ID, 10); !errors.Is(err, ErrMarketNotFound) {
+		t.Errorf("anonymous List: err = %v", err)
+	}
+	if _, err := svc.Add(&owner, market.ID, "hello"); err != nil {
+		t.Errorf("member Add: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package conditional links markets that only make sense if another market
+// resolves a certain way, such as "If X happens, will Y?". A conditional
+// market cannot resolve YES or NO until its condition market has resolved
+// to the condition outcome. If the condition market resolves any other way,
+// the conditional market resolves N/A and every bet in it is refunded
+// through the ledger, as are the bets of markets conditional on it in turn.
+package conditional
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"socialpredict/handlers/math/payout"
+	"socialpredict/models"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrConditionNotFound = errors.New("condition market not found")
+	ErrConditionResolved = errors.New("condition market is already resolved")
+	ErrInvalidCondition  = errors.New("condition outcome is not an outcome of the condition market")
+	ErrConditionPending  = errors.New("condition market has not resolved yet")
+	ErrConditionNotMet   = errors.New("condition market did not resolve to the condition outcome")
+)
+
+// Validate checks that a new market can be conditional on the market
+// conditionMarketID resolving to outcome, and returns the outcome in the
+// form the condition market's resolution will take. A binary condition
+// defaults to YES.
+func Validate(db *gorm.DB, conditionMarketID int64, outcome string) (string, error) {
+	var condition models.Market
+	if err := db.First(&condition, conditionMarketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrConditionNotFound
+		}
+		return "", err
+	}
+	if condition.IsResolved {
+		return "", ErrConditionResolved
+	}
+
+	outcome = strings.TrimSpace(outcome)
+	if !condition.IsCategorical() {
+		outcome = strings.ToUpper(outcome)
+		if outcome == "" {
+			outcome = "YES"
+		}
+		if outcome != "YES" && outcome != "NO" {
+			return "", ErrInvalidCondition
+		}
+		return outcome, nil
+	}
+
+	var label models.MarketOutcome
+	if err := db.First(&label, "market_id = ? AND label = ?", condition.ID, outcome).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrInvalidCondition
+		}
+		return "", err
+	}
+	return outcome, nil
+}
+
+// CheckResolution reports whether market may resolve to outcome. N/A is
+// always allowed; any other outcome waits until the condition is met.
+func CheckResolution(db *gorm.DB, market *models.Market, outcome string) error {
+	if !market.IsConditional() || outcome == "N/A" {
+		return nil
+	}
+	var condition models.Market
+	if err := db.First(&condition, *market.ConditionMarketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrConditionNotFound
+		}
+		return err
+	}
+	if !condition.IsResolved {
+		return ErrConditionPending
+	}
+	if condition.ResolutionResult != market.ConditionOutcome {
+		return ErrConditionNotMet
+	}
+	return nil
+}
+
+// Cascade resolves N/A every open market conditional on the resolved
+// market whose condition was not met, refunding their bets, and then the
+// markets conditional on those. It returns the IDs of the markets it
+// resolved.
+func Cascade(db *gorm.DB, resolved *models.Market, now time.Time) ([]int64, error) {
+	var dependents []models.Market
+	if err := db.Where("condition_market_id = ? AND is_resolved = ?", resolved.ID, false).
+		Order("id").Find(&dependents).Error; err != nil {
+		return nil, err
+	}
+
+	var voided []int64
+	for i := range dependents {
+		market := &dependents[i]
+		if market.ConditionOutcome == resolved.ResolutionResult {
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return void(tx, market, resolved, now)
+		}); err != nil {
+			return voided, fmt.Errorf("market %d: %w", market.ID, err)
+		}
+		voided = append(voided, market.ID)
+
+		further, err := Cascade(db, market, now)
+		voided = append(voided, further...)
+		if err != nil {
+			return voided, err
+		}
+	}
+	return voided, nil
+}
+
+// void resolves market N/A because its condition market resolved otherwise
+func void(tx *gorm.DB, market, condition *models.Market, now time.Time) error {
+	reason := fmt.Sprintf("Condition not met: market #%d resolved %s", condition.ID, condition.ResolutionResult)
+	if err := payout.Void(tx, market, "", reason, now); err != nil {
+		return err
+	}
+
+	var creator models.User
+	if err := tx.Where("username = ?", market.CreatorUsername).First(&creator).Error; err != nil {
+		return fmt.Errorf("creator: %w", err)
+	}
+	return notify.Send(tx, creator.ID, notify.TypeMarketVoided, "Market resolved N/A",
+		fmt.Sprintf("Your market #%d resolved N/A and its bets were refunded because market #%d resolved %s, not %s.",
+			market.ID, condition.ID, condition.ResolutionResult, market.ConditionOutcome))
+}
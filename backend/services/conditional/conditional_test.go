@@ -0,0 +1,129 @@
+package conditional
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func conditionalMarket(t *testing.T, db *gorm.DB, id, parent int64, outcome string) models.Market {
+	t.Helper()
+	market := modelstesting.GenerateMarket(id, "creator")
+	market.ConditionMarketID = &parent
+	market.ConditionOutcome = outcome
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market %d: %v", id, err)
+	}
+	return market
+}
+
+func TestValidate(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	parent := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&parent)
+
+	if outcome, err := Validate(db, 1, ""); err != nil || outcome != "YES" {
+		t.Errorf("Validate default = %q, %v; want YES", outcome, err)
+	}
+	if outcome, err := Validate(db, 1, " no "); err != nil || outcome != "NO" {
+		t.Errorf("Validate no = %q, %v; want NO", outcome, err)
+	}
+	if _, err := Validate(db, 1, "MAYBE"); !errors.Is(err, ErrInvalidCondition) {
+		t.Errorf("Validate MAYBE = %v, want ErrInvalidCondition", err)
+	}
+	if _, err := Validate(db, 99, "YES"); !errors.Is(err, ErrConditionNotFound) {
+		t.Errorf("Validate missing market = %v, want ErrConditionNotFound", err)
+	}
+
+	db.Model(&parent).Update("is_resolved", true)
+	if _, err := Validate(db, 1, "YES"); !errors.Is(err, ErrConditionResolved) {
+		t.Errorf("Validate resolved market = %v, want ErrConditionResolved", err)
+	}
+}
+
+func TestCheckResolution(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	parent := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&parent)
+	child := conditionalMarket(t, db, 2, 1, "YES")
+
+	if err := CheckResolution(db, &child, "YES"); !errors.Is(err, ErrConditionPending) {
+		t.Errorf("before the condition resolves = %v, want ErrConditionPending", err)
+	}
+	if err := CheckResolution(db, &child, "N/A"); err != nil {
+		t.Errorf("N/A before the condition resolves = %v", err)
+	}
+
+	db.Model(&parent).Updates(map[string]interface{}{"is_resolved": true, "resolution_result": "NO"})
+	if err := CheckResolution(db, &child, "NO"); !errors.Is(err, ErrConditionNotMet) {
+		t.Errorf("condition not met = %v, want ErrConditionNotMet", err)
+	}
+
+	db.Model(&parent).Update("resolution_result", "YES")
+	if err := CheckResolution(db, &child, "NO"); err != nil {
+		t.Errorf("condition met = %v", err)
+	}
+}
+
+func TestCascadeRefundsMarketsWhoseConditionFailed(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	creator := modelstesting.GenerateUser("creator", 0)
+	bettor := modelstesting.GenerateUser("bettor", 0)
+	db.Create(&creator)
+	db.Create(&bettor)
+
+	parent := modelstesting.GenerateMarket(1, "creator")
+	parent.IsResolved = true
+	parent.ResolutionResult = "NO"
+	db.Create(&parent)
+	child := conditionalMarket(t, db, 2, 1, "YES")
+	grandchild := conditionalMarket(t, db, 3, 2, "YES")
+	sibling := conditionalMarket(t, db, 4, 1, "NO")
+
+	for _, bet := range []models.Bet{
+		modelstesting.GenerateBet(40, "YES", "bettor", uint(child.ID), 0),
+		modelstesting.GenerateBet(15, "NO", "bettor", uint(grandchild.ID), 0),
+		modelstesting.GenerateBet(25, "YES", "bettor", uint(sibling.ID), 0),
+	} {
+		db.Create(&bet)
+	}
+
+	voided, err := Cascade(db, &parent, time.Now())
+	if err != nil {
+		t.Fatalf("Cascade: %v", err)
+	}
+	if len(voided) != 2 || voided[0] != child.ID || voided[1] != grandchild.ID {
+		t.Fatalf("voided = %v, want [%d %d]", voided, child.ID, grandchild.ID)
+	}
+
+	for _, id := range voided {
+		var market models.Market
+		db.First(&market, id)
+		if !market.IsResolved || market.ResolutionResult != "N/A" {
+			t.Errorf("market %d = resolved %v %q, want N/A", id, market.IsResolved, market.ResolutionResult)
+		}
+	}
+	var open models.Market
+	db.First(&open, sibling.ID)
+	if open.IsResolved {
+		t.Error("market whose condition was met was resolved")
+	}
+
+	var refunded int64
+	db.Model(&models.LedgerEntry{}).Where("type = ?", models.LedgerTypeMarketRefund).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded)
+	if refunded != models.CreditsToMicro(55) {
+		t.Errorf("refunded %d, want %d", refunded, models.CreditsToMicro(55))
+	}
+
+	var notified int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", creator.ID, "MARKET_VOIDED").Count(&notified)
+	if notified != 2 {
+		t.Errorf("creator got %d notifications, want 2", notified)
+	}
+}
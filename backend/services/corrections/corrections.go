@@ -0,0 +1,270 @@
+// Package corrections moves credits between users to resolve disputes. Large
+// corrections, or every correction under the maker-checker policy, need a
+// second admin to approve them before funds move.
+package corrections
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+	"socialpredict/services/notify"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultDualApprovalThreshold is the correction size, in whole credits, above
+// which a second admin must approve
+const defaultDualApprovalThreshold = 1000
+
+// Audit actions
+const (
+	ActionRequested = "BALANCE_CORRECTION_REQUESTED"
+	ActionCompleted = "BALANCE_CORRECTION_COMPLETED"
+	ActionRejected  = "BALANCE_CORRECTION_REJECTED"
+)
+
+const referenceType = "balance_correction"
+
+var (
+	ErrReasonRequired      = errors.New("reason is required")
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrSameUser            = errors.New("source and destination users must differ")
+	ErrInsufficientBalance = errors.New("source user has insufficient balance")
+	ErrNotPending          = errors.New("correction is not pending approval")
+	ErrSelfApproval        = errors.New("a correction cannot be approved by the admin who requested it")
+)
+
+// Config holds correction settings
+type Config struct {
+	DualApprovalThreshold int64 // Micro-credits; larger corrections need a second admin
+}
+
+// LoadConfigFromEnv reads CORRECTION_DUAL_APPROVAL_THRESHOLD (whole credits)
+func LoadConfigFromEnv() Config {
+	threshold := models.CreditsToMicro(defaultDualApprovalThreshold)
+	if v := os.Getenv("CORRECTION_DUAL_APPROVAL_THRESHOLD"); v != "" {
+		if parsed, err := models.ParseCredits(v); err == nil {
+			threshold = parsed
+		}
+	}
+	return Config{DualApprovalThreshold: threshold}
+}
+
+// Service creates, approves and rejects balance corrections
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a corrections service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c}
+}
+
+// RequestInput describes a correction to make
+type RequestInput struct {
+	FromUsername string
+	ToUsername   string
+	Amount       int64 // Micro-credits
+	Reason       string
+	CaseID       string
+	RequestedBy  string // Admin username
+}
+
+// Request records a correction. Corrections at or below the dual-approval
+// threshold are executed immediately unless the maker-checker policy asks for
+// a second admin on all of them; larger ones always wait for a second admin.
+func (s *Service) Request(in RequestInput) (*models.BalanceCorrection, error) {
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if in.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if in.FromUsername == in.ToUsername {
+		return nil, ErrSameUser
+	}
+	policy, err := settings.Shared.MakerChecker(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if policy.BlockOwnAccount && (in.RequestedBy == in.FromUsername || in.RequestedBy == in.ToUsername) {
+		return nil, settings.ErrOwnAccount
+	}
+
+	var correction models.BalanceCorrection
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var from, to models.User
+		if err := tx.Where("username = ?", in.FromUsername).First(&from).Error; err != nil {
+			return fmt.Errorf("source user: %w", err)
+		}
+		if err := tx.Where("username = ?", in.ToUsername).First(&to).Error; err != nil {
+			return fmt.Errorf("destination user: %w", err)
+		}
+
+		correction = models.BalanceCorrection{
+			FromUserID:  from.ID,
+			ToUserID:    to.ID,
+			Amount:      in.Amount,
+			Reason:      in.Reason,
+			CaseID:      in.CaseID,
+			Status:      models.CorrectionStatusPendingApproval,
+			RequestedBy: in.RequestedBy,
+		}
+		if err := tx.Create(&correction).Error; err != nil {
+			return err
+		}
+		if err := s.audit(tx, in.RequestedBy, ActionRequested, &correction); err != nil {
+			return err
+		}
+
+		if policy.SecondApproval || correction.Amount > s.config.DualApprovalThreshold {
+			return nil
+		}
+		locked, lockedTo, err := ledger.LockUsers(tx, from.ID, to.ID)
+		if err != nil {
+			return err
+		}
+		return s.execute(tx, &correction, locked, lockedTo, in.RequestedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &correction, nil
+}
+
+// Approve executes a pending correction. The approver must differ from the
+// requester and, under the maker-checker policy, from both users involved.
+func (s *Service) Approve(id uint, approver, note string) (*models.BalanceCorrection, error) {
+	policy, err := settings.Shared.MakerChecker(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var correction models.BalanceCorrection
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Locked so that two admins approving at once cannot both execute it
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&correction, id).Error; err != nil {
+			return err
+		}
+		if correction.Status != models.CorrectionStatusPendingApproval {
+			return ErrNotPending
+		}
+		if correction.RequestedBy == approver {
+			return ErrSelfApproval
+		}
+
+		from, to, err := ledger.LockUsers(tx, correction.FromUserID, correction.ToUserID)
+		if err != nil {
+			return fmt.Errorf("users: %w", err)
+		}
+		if policy.BlockOwnAccount && (approver == from.Username || approver == to.Username) {
+			return settings.ErrOwnAccount
+		}
+
+		correction.ReviewNote = note
+		return s.execute(tx, &correction, from, to, approver)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &correction, nil
+}
+
+// Reject cancels a pending correction without moving funds
+func (s *Service) Reject(id uint, reviewer, note string) (*models.BalanceCorrection, error) {
+	var correction models.BalanceCorrection
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&correction, id).Error; err != nil {
+			return err
+		}
+		if correction.Status != models.CorrectionStatusPendingApproval {
+			return ErrNotPending
+		}
+
+		now := s.clock.Now()
+		correction.Status = models.CorrectionStatusRejected
+		correction.ApprovedBy = reviewer
+		correction.ReviewNote = note
+		correction.ProcessedAt = &now
+		if err := tx.Save(&correction).Error; err != nil {
+			return err
+		}
+		return s.audit(tx, reviewer, ActionRejected, &correction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &correction, nil
+}
+
+// execute moves the funds, writes both ledger entries and notifies both users.
+// Both users must be locked with ledger.LockUsers.
+func (s *Service) execute(tx *gorm.DB, correction *models.BalanceCorrection, from, to *models.User, approver string) error {
+	if from.BalanceMicroCredits() < correction.Amount {
+		return ErrInsufficientBalance
+	}
+
+	description := fmt.Sprintf("Balance correction #%d: %s", correction.ID, correction.Reason)
+	if _, err := ledger.Apply(tx, from, ledger.Posting{
+		Type:          models.LedgerTypeCorrection,
+		Amount:        -correction.Amount,
+		ReferenceType: referenceType,
+		ReferenceID:   correction.ID,
+		CaseID:        correction.CaseID,
+		Description:   description,
+	}); err != nil {
+		return err
+	}
+	if _, err := ledger.Apply(tx, to, ledger.Posting{
+		Type:          models.LedgerTypeCorrection,
+		Amount:        correction.Amount,
+		ReferenceType: referenceType,
+		ReferenceID:   correction.ID,
+		CaseID:        correction.CaseID,
+		Description:   description,
+	}); err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	correction.Status = models.CorrectionStatusCompleted
+	correction.ApprovedBy = approver
+	correction.ProcessedAt = &now
+	if err := tx.Save(correction).Error; err != nil {
+		return err
+	}
+	if err := s.audit(tx, approver, ActionCompleted, correction); err != nil {
+		return err
+	}
+
+	amount := models.FormatMicroCredits(correction.Amount)
+	if err := notify.Send(tx, from.ID, notify.TypeBalanceCorrection, "Balance correction",
+		fmt.Sprintf("%s credits were moved from your account to %s. Reason: %s", amount, to.Username, correction.Reason)); err != nil {
+		return err
+	}
+	return notify.Send(tx, to.ID, notify.TypeBalanceCorrection, "Balance correction",
+		fmt.Sprintf("%s credits were moved to your account from %s. Reason: %s", amount, from.Username, correction.Reason))
+}
+
+func (s *Service) audit(tx *gorm.DB, actor, action string, correction *models.BalanceCorrection) error {
+	return audit.Record(tx, models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: referenceType,
+		TargetID:   correction.ID,
+		CaseID:     correction.CaseID,
+		Details: fmt.Sprintf("from=%d to=%d amount=%s reason=%q",
+			correction.FromUserID, correction.ToUserID, models.FormatMicroCredits(correction.Amount), correction.Reason),
+	})
+}
@@ -0,0 +1,220 @@
+package corrections
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+func setupUsers(t *testing.T, db *gorm.DB) (models.User, models.User) {
+	t.Helper()
+	from := modelstesting.GenerateUser("sender", 5000)
+	to := modelstesting.GenerateUser("receiver", 0)
+	for _, u := range []*models.User{&from, &to} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	return from, to
+}
+
+func TestSmallCorrectionExecutesImmediately(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	from, to := setupUsers(t, db)
+	svc := NewService(db, Config{DualApprovalThreshold: models.CreditsToMicro(1000)}, clock.NewFake(time.Now()))
+
+	correction, err := svc.Request(RequestInput{
+		FromUsername: "sender", ToUsername: "receiver",
+		Amount: models.CreditsToMicro(250), Reason: "mistaken transfer", CaseID: "CASE-1", RequestedBy: "admin1",
+	})
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if correction.Status != models.CorrectionStatusCompleted {
+		t.Fatalf("expected completed, got %s", correction.Status)
+	}
+
+	db.First(&from, from.ID)
+	db.First(&to, to.ID)
+	if from.AccountBalance != models.CreditsToMicro(4750) || to.AccountBalance != models.CreditsToMicro(250) {
+		t.Fatalf("unexpected balances: %d, %d", from.AccountBalance, to.AccountBalance)
+	}
+
+	var entries []models.LedgerEntry
+	db.Where("case_id = ?", "CASE-1").Order("amount").Find(&entries)
+	if len(entries) != 2 || entries[0].Amount != -models.CreditsToMicro(250) || entries[1].ReferenceID != correction.ID {
+		t.Fatalf("unexpected ledger entries: %+v", entries)
+	}
+
+	var notifications, audits int64
+	db.Model(&models.Notification{}).Count(&notifications)
+	db.Model(&models.AuditLog{}).Where("case_id = ?", "CASE-1").Count(&audits)
+	if notifications != 2 || audits != 2 {
+		t.Fatalf("expected 2 notifications and 2 audit entries, got %d and %d", notifications, audits)
+	}
+}
+
+func TestLargeCorrectionNeedsSecondAdmin(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	from, _ := setupUsers(t, db)
+	svc := NewService(db, Config{DualApprovalThreshold: models.CreditsToMicro(1000)}, clock.New())
+
+	correction, err := svc.Request(RequestInput{
+		FromUsername: "sender", ToUsername: "receiver",
+		Amount: models.CreditsToMicro(2000), Reason: "dispute", RequestedBy: "admin1",
+	})
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if correction.Status != models.CorrectionStatusPendingApproval {
+		t.Fatalf("expected pending approval, got %s", correction.Status)
+	}
+	db.First(&from, from.ID)
+	if from.AccountBalance != models.CreditsToMicro(5000) {
+		t.Fatalf("funds moved before approval: %d", from.AccountBalance)
+	}
+
+	if _, err := svc.Approve(correction.ID, "admin1", ""); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected ErrSelfApproval, got %v", err)
+	}
+	approved, err := svc.Approve(correction.ID, "admin2", "verified")
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if approved.Status != models.CorrectionStatusCompleted || approved.ApprovedBy != "admin2" {
+		t.Fatalf("unexpected correction: %+v", approved)
+	}
+	if _, err := svc.Reject(correction.ID, "admin2", ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("expected ErrNotPending, got %v", err)
+	}
+}
+
+func TestCorrectionValidation(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	setupUsers(t, db)
+	svc := NewService(db, Config{DualApprovalThreshold: models.CreditsToMicro(100000)}, clock.New())
+
+	cases := []struct {
+		in   RequestInput
+		want error
+	}{
+		{RequestInput{FromUsername: "sender", ToUsername: "receiver", Amount: 1, Reason: " "}, ErrReasonRequired},
+		{RequestInput{FromUsername: "sender", ToUsername: "receiver", Amount: 0, Reason: "x"}, ErrInvalidAmount},
+		{RequestInput{FromUsername: "sender", ToUsername: "sender", Amount: 1, Reason: "x"}, ErrSameUser},
+		{RequestInput{FromUsername: "sender", ToUsername: "receiver", Amount: models.CreditsToMicro(6000), Reason: "x"}, ErrInsufficientBalance},
+		{RequestInput{FromUsername: "ghost", ToUsername: "receiver", Amount: 1, Reason: "x"}, gorm.ErrRecordNotFound},
+	}
+	for _, tc := range cases {
+		if _, err := svc.Request(tc.in); !errors.Is(err, tc.want) {
+			t.Errorf("Request(%+v) = %v, want %v", tc.in, err, tc.want)
+		}
+	}
+
+	var count int64
+	db.Model(&models.BalanceCorrection{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("failed corrections should not be persisted, found %d", count)
+	}
+}
+
+func TestMakerCheckerPolicy(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	setupUsers(t, db)
+	t.Cleanup(settings.Shared.Invalidate)
+	svc := NewService(db, Config{DualApprovalThreshold: models.CreditsToMicro(1000)}, clock.New())
+
+	// Admins cannot move credits into or out of their own account
+	if _, err := svc.Request(RequestInput{
+		FromUsername: "sender", ToUsername: "receiver",
+		Amount: models.CreditsToMicro(10), Reason: "refund myself", RequestedBy: "receiver",
+	}); !errors.Is(err, settings.ErrOwnAccount) {
+		t.Fatalf("own-account request: got %v, want ErrOwnAccount", err)
+	}
+
+	// With second approval on, even a small correction waits for another admin
+	if err := settings.Shared.SetMakerChecker(db, settings.MakerChecker{BlockOwnAccount: true, SecondApproval: true}, "admin1"); err != nil {
+		t.Fatalf("set policy: %v", err)
+	}
+	correction, err := svc.Request(RequestInput{
+		FromUsername: "sender", ToUsername: "receiver",
+		Amount: models.CreditsToMicro(10), Reason: "dispute", RequestedBy: "admin1",
+	})
+	if err != nil || correction.Status != models.CorrectionStatusPendingApproval {
+		t.Fatalf("Request: %+v, %v; want pending approval", correction, err)
+	}
+	if _, err := svc.Approve(correction.ID, "sender", ""); !errors.Is(err, settings.ErrOwnAccount) {
+		t.Fatalf("approval by a party: got %v, want ErrOwnAccount", err)
+	}
+	if approved, err := svc.Approve(correction.ID, "admin2", ""); err != nil || approved.Status != models.CorrectionStatusCompleted {
+		t.Fatalf("Approve: %+v, %v", approved, err)
+	}
+}
+
+func TestConcurrentReviewsExecuteOnce(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	from, to := setupUsers(t, db)
+	svc := NewService(db, Config{DualApprovalThreshold: models.CreditsToMicro(1000)}, clock.New())
+
+	correction, err := svc.Request(RequestInput{
+		FromUsername: "sender", ToUsername: "receiver",
+		Amount: models.CreditsToMicro(2000), Reason: "dispute", RequestedBy: "admin1",
+	})
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+
+	// Two approvals and a rejection race; only the first review may stand
+	reviews := []func() error{
+		func() error { _, err := svc.Approve(correction.ID, "admin2", ""); return err },
+		func() error { _, err := svc.Approve(correction.ID, "admin3", ""); return err },
+		func() error { _, err := svc.Reject(correction.ID, "admin4", ""); return err },
+	}
+	errs := make(chan error, len(reviews))
+	var wg sync.WaitGroup
+	for _, review := range reviews {
+		wg.Add(1)
+		go func(review func() error) {
+			defer wg.Done()
+			errs <- review()
+		}(review)
+	}
+	wg.Wait()
+	close(errs)
+
+	var succeeded int
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrNotPending):
+			t.Errorf("review: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d reviews succeeded, want 1", succeeded)
+	}
+
+	var entries int64
+	db.Model(&models.LedgerEntry{}).Where("reference_id = ?", correction.ID).Count(&entries)
+	db.First(&from, from.ID)
+	db.First(&to, to.ID)
+	if from.AccountBalance+to.AccountBalance != models.CreditsToMicro(5000) || (entries != 0 && entries != 2) {
+		t.Errorf("balances %d + %d, %d ledger entries; want funds moved at most once", from.AccountBalance, to.AccountBalance, entries)
+	}
+	if entries == 2 && to.AccountBalance != models.CreditsToMicro(2000) {
+		t.Errorf("receiver has %d, want 2000", to.AccountBalance)
+	}
+}
@@ -0,0 +1,188 @@
+// Package creatorfees charges traders a fee on each buy for the market's
+// creator. Fees accumulate per market and are paid to the creator, less the
+// platform's share, when the market resolves. A voided market's fees are
+// forfeited to the platform. Rates come from the platform settings.
+package creatorfees
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const referenceType = "market"
+
+// Charge is the creator fee on one trade, in micro-credits
+type Charge struct {
+	Volume   int64
+	Fee      int64 // Charged to the trader
+	Platform int64 // Part of Fee the platform keeps
+}
+
+// Quote returns the creator fee on a buy of amount credits by username.
+// Creators pay no fee on their own markets.
+func Quote(db *gorm.DB, marketID uint, username string, amount int64) (Charge, error) {
+	if amount <= 0 {
+		return Charge{}, nil
+	}
+	rates, err := settings.Shared.CreatorFees(db)
+	if err != nil {
+		return Charge{}, err
+	}
+	if rates.FeeBps == 0 {
+		return Charge{}, nil
+	}
+	var market models.Market
+	if err := db.Select("id", "creator_username").First(&market, marketID).Error; err != nil {
+		return Charge{}, err
+	}
+	if market.CreatorUsername == username {
+		return Charge{}, nil
+	}
+	volume := models.CreditsToMicro(amount)
+	fee, platform := rates.Split(volume)
+	return Charge{Volume: volume, Fee: fee, Platform: platform}, nil
+}
+
+// Accrue adds a trade's fee, made at now, to the market's running total
+func Accrue(tx *gorm.DB, marketID uint, charge Charge, now time.Time) error {
+	if charge.Fee <= 0 {
+		return nil
+	}
+	var market models.Market
+	if err := tx.Select("id", "creator_username").First(&market, marketID).Error; err != nil {
+		return err
+	}
+	var creator models.User
+	if err := tx.Select("id").Where("username = ?", market.CreatorUsername).First(&creator).Error; err != nil {
+		return fmt.Errorf("creator lookup failed: %w", err)
+	}
+	accrual := models.MarketCreatorFee{
+		MarketID:       market.ID,
+		CreatorID:      creator.ID,
+		Volume:         charge.Volume,
+		CreatorAmount:  charge.Fee - charge.Platform,
+		PlatformAmount: charge.Platform,
+		Status:         models.CreatorFeeStatusAccruing,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "market_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"volume":          gorm.Expr("market_creator_fees.volume + ?", accrual.Volume),
+			"creator_amount":  gorm.Expr("market_creator_fees.creator_amount + ?", accrual.CreatorAmount),
+			"platform_amount": gorm.Expr("market_creator_fees.platform_amount + ?", accrual.PlatformAmount),
+			"updated_at":      now,
+		}),
+	}).Create(&accrual).Error
+}
+
+// accruing returns the market's unsettled fees, or nil if there are none
+func accruing(tx *gorm.DB, marketID int64) (*models.MarketCreatorFee, error) {
+	var accrual models.MarketCreatorFee
+	err := tx.Where("market_id = ? AND status = ?", marketID, models.CreatorFeeStatusAccruing).First(&accrual).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &accrual, nil
+}
+
+// Settle pays a resolved market's fees to its creator and books the
+// platform's share. Callers run it in the resolution transaction.
+func Settle(tx *gorm.DB, market *models.Market, now time.Time) error {
+	accrual, err := accruing(tx, market.ID)
+	if err != nil || accrual == nil {
+		return err
+	}
+	if accrual.CreatorAmount > 0 {
+		var creator models.User
+		if err := tx.First(&creator, accrual.CreatorID).Error; err != nil {
+			return fmt.Errorf("creator lookup failed: %w", err)
+		}
+		if _, err := ledger.Apply(tx, &creator, ledger.Posting{
+			Type:          models.LedgerTypeCreatorFee,
+			Amount:        accrual.CreatorAmount,
+			ReferenceType: referenceType,
+			ReferenceID:   uint(market.ID),
+			MarketID:      &market.ID,
+			Description:   fmt.Sprintf("Creator fees on market #%d", market.ID),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := bookPlatform(tx, market.ID, accrual.PlatformAmount, "Platform share of creator fees on market #%d"); err != nil {
+		return err
+	}
+	return finish(tx, accrual, models.CreatorFeeStatusSettled, now)
+}
+
+// Forfeit closes a voided market's fees without paying the creator; the
+// platform keeps all of them
+func Forfeit(tx *gorm.DB, market *models.Market, now time.Time) error {
+	accrual, err := accruing(tx, market.ID)
+	if err != nil || accrual == nil {
+		return err
+	}
+	if err := bookPlatform(tx, market.ID, accrual.CreatorAmount+accrual.PlatformAmount, "Creator fees forfeited on voided market #%d"); err != nil {
+		return err
+	}
+	return finish(tx, accrual, models.CreatorFeeStatusForfeited, now)
+}
+
+// bookPlatform records fees the platform keeps in its ledger
+func bookPlatform(tx *gorm.DB, marketID int64, amount int64, description string) error {
+	if amount <= 0 {
+		return nil
+	}
+	_, err := ledger.RecordPlatform(tx, ledger.Posting{
+		Type:          models.LedgerTypePlatformFeeShare,
+		Amount:        amount,
+		ReferenceType: referenceType,
+		ReferenceID:   uint(marketID),
+		MarketID:      &marketID,
+		Description:   fmt.Sprintf(description, marketID),
+	})
+	return err
+}
+
+func finish(tx *gorm.DB, accrual *models.MarketCreatorFee, status string, now time.Time) error {
+	return tx.Model(accrual).Updates(map[string]interface{}{"status": status, "settled_at": now}).Error
+}
+
+// Earnings sums up a creator's fees. Amounts are in micro-credits.
+type Earnings struct {
+	Pending   int64                     `json:"pending"`   // Accruing on markets not yet resolved
+	Earned    int64                     `json:"earned"`    // Paid out on resolved markets
+	Forfeited int64                     `json:"forfeited"` // Lost on voided markets
+	Volume    int64                     `json:"volume"`    // Fee-paying volume across all markets
+	Markets   []models.MarketCreatorFee `json:"markets"`
+}
+
+// CreatorEarnings returns a creator's fees, with each market newest first
+func CreatorEarnings(db *gorm.DB, creatorID int64) (*Earnings, error) {
+	earnings := &Earnings{Markets: []models.MarketCreatorFee{}}
+	if err := db.Where("creator_id = ?", creatorID).Order("id DESC").Find(&earnings.Markets).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range earnings.Markets {
+		earnings.Volume += m.Volume
+		switch m.Status {
+		case models.CreatorFeeStatusAccruing:
+			earnings.Pending += m.CreatorAmount
+		case models.CreatorFeeStatusSettled:
+			earnings.Earned += m.CreatorAmount
+		case models.CreatorFeeStatusForfeited:
+			earnings.Forfeited += m.CreatorAmount
+		}
+	}
+	return earnings, nil
+}
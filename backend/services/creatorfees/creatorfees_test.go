@@ -0,0 +1,79 @@
+package creatorfees
+
+import (
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/settings"
+)
+
+func TestCreatorFeesAccrueAndSettle(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	creator := modelstesting.GenerateUser("creator", 0)
+	trader := modelstesting.GenerateUser("trader", 1000)
+	db.Create(&creator)
+	db.Create(&trader)
+	settled := modelstesting.GenerateMarket(1, "creator")
+	voided := modelstesting.GenerateMarket(2, "creator")
+	db.Create(&settled)
+	db.Create(&voided)
+
+	// Fees are off until an admin sets them
+	if charge, err := Quote(db, 1, "trader", 100); err != nil || charge.Fee != 0 {
+		t.Fatalf("default quote = %+v, %v", charge, err)
+	}
+
+	// 2% fee, of which the platform keeps a quarter
+	if err := settings.Shared.SetCreatorFees(db, settings.CreatorFees{FeeBps: 200, PlatformShareBps: 2500}, "admin"); err != nil {
+		t.Fatalf("set fees: %v", err)
+	}
+	t.Cleanup(settings.Shared.Invalidate)
+
+	if charge, _ := Quote(db, 1, "creator", 100); charge.Fee != 0 {
+		t.Fatalf("creator charged %d on their own market", charge.Fee)
+	}
+	charge, err := Quote(db, 1, "trader", 100)
+	if err != nil || charge.Fee != models.CreditsToMicro(2) || charge.Platform != 500_000 {
+		t.Fatalf("quote = %+v, %v", charge, err)
+	}
+	for _, marketID := range []uint{1, 1, 2} {
+		if err := Accrue(db, marketID, charge, time.Now()); err != nil {
+			t.Fatalf("accrue: %v", err)
+		}
+	}
+
+	earnings, err := CreatorEarnings(db, creator.ID)
+	if err != nil || earnings.Pending != 4_500_000 || earnings.Volume != models.CreditsToMicro(300) {
+		t.Fatalf("pending earnings = %+v, %v", earnings, err)
+	}
+
+	now := time.Date(2026, 5, 16, 9, 0, 0, 0, time.UTC)
+	if err := Settle(db, &settled, now); err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	// Settling again pays nothing more
+	if err := Settle(db, &settled, now); err != nil {
+		t.Fatalf("settle again: %v", err)
+	}
+	if err := Forfeit(db, &voided, now); err != nil {
+		t.Fatalf("forfeit: %v", err)
+	}
+
+	db.First(&creator, creator.ID)
+	if creator.BalanceMicroCredits() != 3_000_000 {
+		t.Fatalf("creator balance = %d micro, want 3 credits", creator.BalanceMicroCredits())
+	}
+	var platform int64
+	db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type = ?", models.PlatformUserID, models.LedgerTypePlatformFeeShare).
+		Select("SUM(amount)").Scan(&platform)
+	if platform != 3_000_000 {
+		t.Fatalf("platform share = %d, want 1 credit on settlement plus 2 forfeited", platform)
+	}
+
+	earnings, _ = CreatorEarnings(db, creator.ID)
+	if earnings.Pending != 0 || earnings.Earned != 3_000_000 || earnings.Forfeited != 1_500_000 {
+		t.Fatalf("earnings = %+v", earnings)
+	}
+}
@@ -0,0 +1,288 @@
+// Package devices tracks the devices and sessions users log in from. A
+// withdrawal requested from a device first seen recently is held for a while
+// before it can be approved, so a stolen password cannot be cashed out at
+// once. Admins can trust a device to lift its holds.
+package devices
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+)
+
+// DeviceHeader is the request header carrying the client's device key
+const DeviceHeader = "X-Device-ID"
+
+const (
+	defaultNewDeviceHold = 24 * time.Hour
+	actionDeviceTrusted  = "USER_DEVICE_TRUSTED"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found or no longer active")
+	ErrDeviceNotFound  = errors.New("device not found")
+)
+
+// deviceKeyPattern accepts client-generated keys such as UUIDs; anything else
+// is replaced with a server-issued key
+var deviceKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+// Config holds device settings
+type Config struct {
+	NewDeviceHold time.Duration // How long withdrawals from a new device are held; 0 disables the hold
+}
+
+// LoadConfigFromEnv reads NEW_DEVICE_WITHDRAWAL_HOLD
+func LoadConfigFromEnv() Config {
+	config := Config{NewDeviceHold: defaultNewDeviceHold}
+	if d, err := time.ParseDuration(os.Getenv("NEW_DEVICE_WITHDRAWAL_HOLD")); err == nil && d >= 0 {
+		config.NewDeviceHold = d
+	}
+	return config
+}
+
+// Service manages devices, sessions and new-device withdrawal holds
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a device service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c}
+}
+
+// RecordLogin records a login from deviceKey and opens a session lasting
+// until expiresAt. An empty or malformed key is replaced with a new one,
+// which is returned for the client to keep.
+func (s *Service) RecordLogin(userID int64, deviceKey, userAgent, ip string, expiresAt time.Time) (*models.UserSession, string, error) {
+	if !deviceKeyPattern.MatchString(deviceKey) {
+		key, err := randomHex(16)
+		if err != nil {
+			return nil, "", err
+		}
+		deviceKey = key
+	}
+	sessionID, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.clock.Now()
+	session := &models.UserSession{
+		ID:        sessionID,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var device models.UserDevice
+		err := tx.Where("user_id = ? AND device_key = ?", userID, deviceKey).First(&device).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			device = models.UserDevice{UserID: userID, DeviceKey: deviceKey, UserAgent: userAgent, LastIP: ip, FirstSeenAt: now, LastSeenAt: now}
+			if err := tx.Create(&device).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := tx.Model(&device).Updates(map[string]interface{}{"user_agent": userAgent, "last_ip": ip, "last_seen_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		session.DeviceID = device.ID
+		return tx.Create(session).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, deviceKey, nil
+}
+
+// Active returns the session with id if it has not expired or been revoked
+func (s *Service) Active(id string) (*models.UserSession, error) {
+	return ActiveSession(s.db, id, s.clock.Now())
+}
+
+// ActiveSession returns the session with id if it is active at now
+func ActiveSession(db *gorm.DB, id string, now time.Time) (*models.UserSession, error) {
+	var session models.UserSession
+	err := db.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, now).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SessionView is an active session with the device it was opened on
+type SessionView struct {
+	models.UserSession
+	Device models.UserDevice `json:"device"`
+}
+
+// ListSessions returns the user's active sessions, newest first
+func (s *Service) ListSessions(userID int64) ([]SessionView, error) {
+	var sessions []models.UserSession
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, s.clock.Now()).
+		Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	deviceIDs := make([]uint, 0, len(sessions))
+	for _, session := range sessions {
+		deviceIDs = append(deviceIDs, session.DeviceID)
+	}
+	var devices []models.UserDevice
+	if err := s.db.Where("id IN ?", deviceIDs).Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.UserDevice, len(devices))
+	for _, d := range devices {
+		byID[d.ID] = d
+	}
+
+	views := make([]SessionView, len(sessions))
+	for i, session := range sessions {
+		views[i] = SessionView{UserSession: session, Device: byID[session.DeviceID]}
+	}
+	return views, nil
+}
+
+// Revoke ends one of the user's sessions, invalidating its token
+func (s *Service) Revoke(userID int64, sessionID string) error {
+	result := s.db.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", s.clock.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// ListDevices returns the devices a user has logged in from
+func (s *Service) ListDevices(userID int64) ([]models.UserDevice, error) {
+	var devices []models.UserDevice
+	err := s.db.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error
+	return devices, err
+}
+
+// WithdrawalHold returns when a withdrawal requested in session may proceed,
+// or nil if its device is old enough or trusted
+func (s *Service) WithdrawalHold(session *models.UserSession) (*time.Time, error) {
+	if s.config.NewDeviceHold <= 0 {
+		return nil, nil
+	}
+	var device models.UserDevice
+	if err := s.db.First(&device, session.DeviceID).Error; err != nil {
+		return nil, err
+	}
+	if device.TrustedAt != nil {
+		return nil, nil
+	}
+	until := device.FirstSeenAt.Add(s.config.NewDeviceHold)
+	if !s.clock.Now().Before(until) {
+		return nil, nil
+	}
+	return &until, nil
+}
+
+// Trust exempts a device from the new-device hold and releases its held
+// withdrawals. It returns how many were released.
+func (s *Service) Trust(deviceID uint, actor, note string) (int64, error) {
+	var released int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var device models.UserDevice
+		if err := tx.First(&device, deviceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeviceNotFound
+			}
+			return err
+		}
+		now := s.clock.Now()
+		if err := tx.Model(&device).Updates(map[string]interface{}{"trusted_at": now, "trusted_by": actor}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		released, err = releaseHolds(tx, "device_id = ?", deviceID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     actionDeviceTrusted,
+			TargetType: "user_device",
+			TargetID:   device.ID,
+			Details:    fmt.Sprintf("user=%d released=%d note=%q", device.UserID, released, note),
+		})
+	})
+	return released, err
+}
+
+// ReleaseExpiredHolds returns withdrawals whose new-device hold has run out
+// to PENDING, and returns how many were released
+func (s *Service) ReleaseExpiredHolds() (int64, error) {
+	return releaseHolds(s.db, "hold_until <= ?", s.clock.Now())
+}
+
+// releaseHolds lifts the new-device hold on the withdrawals matching the condition.
+// Held requests go back to PENDING; requests still awaiting email
+// confirmation lose the hold, so confirming sends them straight to review.
+func releaseHolds(tx *gorm.DB, condition string, args ...interface{}) (int64, error) {
+	held := tx.Model(&models.WithdrawalRequest{}).Where(condition, args...).
+		Where("hold_reason = ? AND status = ?", models.HoldReasonNewDevice, models.TxStatusOnHold).
+		Updates(map[string]interface{}{"status": models.TxStatusPending, "hold_reason": "", "hold_until": nil})
+	if held.Error != nil {
+		return 0, held.Error
+	}
+	awaiting := tx.Model(&models.WithdrawalRequest{}).Where(condition, args...).
+		Where("hold_reason = ? AND status = ?", models.HoldReasonNewDevice, models.TxStatusAwaitingConfirmation).
+		Updates(map[string]interface{}{"hold_reason": "", "hold_until": nil})
+	if awaiting.Error != nil {
+		return 0, awaiting.Error
+	}
+	return held.RowsAffected + awaiting.RowsAffected, nil
+}
+
+// Run releases expired new-device holds every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.ReleaseExpiredHolds()
+		if err != nil {
+			log.Printf("Devices: Releasing holds failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Devices: Released %d withdrawals from new-device hold", n)
+		}
+	}
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
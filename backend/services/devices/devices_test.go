@@ -0,0 +1,158 @@
+package devices
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func setup(t *testing.T) (*gorm.DB, *clock.Fake, *Service, *models.User) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 29, 9, 0, 0, 0, time.UTC))
+	svc := NewService(db, Config{NewDeviceHold: 24 * time.Hour}, fake)
+
+	user := modelstesting.GenerateUser("alice", 100)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, fake, svc, &user
+}
+
+func login(t *testing.T, svc *Service, fake *clock.Fake, userID int64, deviceKey string) (*models.UserSession, string) {
+	t.Helper()
+	session, key, err := svc.RecordLogin(userID, deviceKey, "test-agent", "203.0.113.7", fake.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("RecordLogin: %v", err)
+	}
+	return session, key
+}
+
+func heldWithdrawal(t *testing.T, db *gorm.DB, userID int64, deviceID uint, until time.Time) *models.WithdrawalRequest {
+	t.Helper()
+	req := models.WithdrawalRequest{UserID: userID, ChainID: 8453, ChainName: "base", TokenSymbol: "USDC",
+		Amount: 10 * models.MicroCreditsPerCredit, ToAddress: "0x1111111111111111111111111111111111111111",
+		Status: models.TxStatusOnHold, HoldReason: models.HoldReasonNewDevice, HoldUntil: &until, DeviceID: &deviceID}
+	if err := db.Create(&req).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+	return &req
+}
+
+func TestRecordLoginReusesKnownDevice(t *testing.T) {
+	db, fake, svc, user := setup(t)
+
+	first, key := login(t, svc, fake, user.ID, "bad key")
+	if key == "bad key" || !deviceKeyPattern.MatchString(key) {
+		t.Fatalf("malformed key was not replaced: %q", key)
+	}
+
+	fake.Advance(time.Hour)
+	second, again := login(t, svc, fake, user.ID, key)
+	if again != key || second.DeviceID != first.DeviceID || second.ID == first.ID {
+		t.Errorf("second login = %+v key %q, want same device as %+v", second, again, first)
+	}
+
+	var count int64
+	db.Model(&models.UserDevice{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("devices = %d, want 1", count)
+	}
+}
+
+func TestWithdrawalHoldOnlyForNewDevices(t *testing.T) {
+	_, fake, svc, user := setup(t)
+	session, key := login(t, svc, fake, user.ID, "")
+
+	until, err := svc.WithdrawalHold(session)
+	if err != nil || until == nil || !until.Equal(fake.Now().Add(24*time.Hour)) {
+		t.Fatalf("hold on new device = %v, %v", until, err)
+	}
+
+	fake.Advance(25 * time.Hour)
+	later, _ := login(t, svc, fake, user.ID, key)
+	if until, err := svc.WithdrawalHold(later); err != nil || until != nil {
+		t.Errorf("hold on known device = %v, %v", until, err)
+	}
+
+	off := NewService(svc.db, Config{}, fake)
+	fresh, _ := login(t, off, fake, user.ID, "")
+	if until, _ := off.WithdrawalHold(fresh); until != nil {
+		t.Errorf("hold with hold disabled = %v", until)
+	}
+}
+
+func TestTrustReleasesHeldWithdrawals(t *testing.T) {
+	db, fake, svc, user := setup(t)
+	session, _ := login(t, svc, fake, user.ID, "")
+	held := heldWithdrawal(t, db, user.ID, session.DeviceID, fake.Now().Add(24*time.Hour))
+
+	if _, err := svc.Trust(999, "admin", ""); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("trust unknown device = %v", err)
+	}
+	released, err := svc.Trust(session.DeviceID, "admin", "called the user")
+	if err != nil || released != 1 {
+		t.Fatalf("Trust = %d, %v", released, err)
+	}
+
+	var got models.WithdrawalRequest
+	db.First(&got, held.ID)
+	if got.Status != models.TxStatusPending || got.HoldReason != "" || got.HoldUntil != nil {
+		t.Errorf("released withdrawal = %+v", got)
+	}
+	if until, _ := svc.WithdrawalHold(session); until != nil {
+		t.Errorf("trusted device still held until %v", until)
+	}
+
+	var entry models.AuditLog
+	if err := db.Where("action = ?", actionDeviceTrusted).First(&entry).Error; err != nil || entry.Actor != "admin" {
+		t.Errorf("audit entry = %+v, %v", entry, err)
+	}
+}
+
+func TestReleaseExpiredHolds(t *testing.T) {
+	db, fake, svc, user := setup(t)
+	session, _ := login(t, svc, fake, user.ID, "")
+	held := heldWithdrawal(t, db, user.ID, session.DeviceID, fake.Now().Add(24*time.Hour))
+
+	if n, err := svc.ReleaseExpiredHolds(); err != nil || n != 0 {
+		t.Fatalf("release before expiry = %d, %v", n, err)
+	}
+	fake.Advance(24 * time.Hour)
+	if n, err := svc.ReleaseExpiredHolds(); err != nil || n != 1 {
+		t.Fatalf("release after expiry = %d, %v", n, err)
+	}
+
+	var got models.WithdrawalRequest
+	db.First(&got, held.ID)
+	if got.Status != models.TxStatusPending {
+		t.Errorf("status = %s, want PENDING", got.Status)
+	}
+}
+
+func TestRevokeEndsSession(t *testing.T) {
+	_, fake, svc, user := setup(t)
+	session, _ := login(t, svc, fake, user.ID, "")
+
+	if sessions, _ := svc.ListSessions(user.ID); len(sessions) != 1 || sessions[0].Device.ID != session.DeviceID {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	if err := svc.Revoke(user.ID+1, session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoke other user's session = %v", err)
+	}
+	if err := svc.Revoke(user.ID, session.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := svc.Active(session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoked session active: %v", err)
+	}
+	if sessions, _ := svc.ListSessions(user.ID); len(sessions) != 0 {
+		t.Errorf("sessions after revoke = %+v", sessions)
+	}
+}
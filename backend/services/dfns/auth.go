@@ -0,0 +1,122 @@
+package dfns
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshSkew refreshes a delegated token this long before it expires,
+// so a request is never sent with a token that lapses in flight
+const tokenRefreshSkew = time.Minute
+
+// ErrNoAuthToken is returned when no bearer token can be sent to DFNS
+var ErrNoAuthToken = errors.New("no DFNS auth token configured")
+
+// tokenSource supplies the bearer token sent with each DFNS request. With a
+// delegated username it logs in on that user's behalf with the service
+// account and caches the user token until shortly before it expires;
+// otherwise it serves the service account token.
+type tokenSource struct {
+	serviceToken string
+	username     string
+	login        func(ctx context.Context, username string) (string, error)
+	now          func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // Zero when the token carries no expiry
+}
+
+// Delegated reports whether requests run as a delegated end user
+func (s *tokenSource) Delegated() bool {
+	return s.username != ""
+}
+
+// Token returns the current bearer token, logging in again if the delegated
+// token is missing or about to expire
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	if !s.Delegated() {
+		if s.serviceToken == "" {
+			return "", ErrNoAuthToken
+		}
+		return s.serviceToken, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && (s.expires.IsZero() || s.now().Add(tokenRefreshSkew).Before(s.expires)) {
+		return s.token, nil
+	}
+	token, err := s.login(ctx, s.username)
+	if err != nil {
+		return "", fmt.Errorf("delegated login failed: %w", err)
+	}
+	s.token = token
+	s.expires = tokenExpiry(token)
+	return token, nil
+}
+
+// Invalidate drops a delegated token DFNS rejected so the next call logs in again
+func (s *tokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it; DFNS does
+// that. It returns the zero time when the token has no readable expiry.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// DelegatedLoginRequest asks DFNS for an end user's auth token
+type DelegatedLoginRequest struct {
+	Username string `json:"username"`
+}
+
+// DelegatedLoginResponse carries the end user's auth token
+type DelegatedLoginResponse struct {
+	Token string `json:"token"`
+}
+
+// delegatedLogin obtains an auth token for username with the service
+// account token. DFNS requires the call to be signed, so it needs a private key.
+func (c *Client) delegatedLogin(ctx context.Context, username string) (string, error) {
+	if !c.config.CanSign() {
+		return "", errors.New("delegated login requires a signing key")
+	}
+	respBody, err := c.send(ctx, "POST", "/auth/login/delegated", DelegatedLoginRequest{Username: username}, c.config.ServiceAccountToken, true)
+	if err != nil {
+		return "", err
+	}
+	var resp DelegatedLoginResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.Token == "" {
+		return "", errors.New("delegated login returned no token")
+	}
+	return resp.Token, nil
+}
@@ -0,0 +1,85 @@
+package dfns
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testJWT returns an unsigned token whose exp claim is exp
+func testJWT(name string, exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, name, exp.Unix())))
+	return "e30." + payload + ".sig"
+}
+
+func TestTokenOnlyClientSendsBearerToken(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(Config{Name: PrimaryOrg, BaseURL: srv.URL, ServiceAccountToken: "sa-token"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if client.dfnsClient != nil {
+		t.Fatal("a client without a private key should not sign requests")
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if got != "Bearer sa-token" {
+		t.Fatalf("Authorization = %q", got)
+	}
+}
+
+func TestDelegatedTokenIsRefreshedBeforeExpiryAndOnRejection(t *testing.T) {
+	now := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	logins := 0
+	valid := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			http.Error(w, "token expired", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer srv.Close()
+
+	client := &Client{config: Config{BaseURL: srv.URL}, httpClient: http.DefaultClient}
+	client.tokens = &tokenSource{
+		username: "ops@example.com",
+		now:      func() time.Time { return now },
+		login: func(_ context.Context, username string) (string, error) {
+			logins++
+			valid = testJWT(fmt.Sprintf("%s-%d", username, logins), now.Add(10*time.Minute))
+			return valid, nil
+		},
+	}
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil || logins != 1 {
+		t.Fatalf("first call: err %v, logins %d", err, logins)
+	}
+	if err := client.Ping(ctx); err != nil || logins != 1 {
+		t.Fatalf("cached token: err %v, logins %d; want the token reused", err, logins)
+	}
+
+	// Within the refresh skew of expiry the token is replaced up front
+	now = now.Add(9*time.Minute + 30*time.Second)
+	if err := client.Ping(ctx); err != nil || logins != 2 {
+		t.Fatalf("near expiry: err %v, logins %d; want a fresh login", err, logins)
+	}
+
+	// A token DFNS revokes early is replaced and the call retried once
+	valid = "revoked-elsewhere"
+	if err := client.Ping(ctx); err != nil || logins != 3 {
+		t.Fatalf("rejected token: err %v, logins %d; want a retry with a new token", err, logins)
+	}
+}
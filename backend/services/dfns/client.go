@@ -2,11 +2,16 @@ package dfns
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"time"
+
+	"socialpredict/logger"
+	"socialpredict/services/metrics"
 
 	"github.com/dfns/dfns-sdk-go/credentials"
 	api "github.com/dfns/dfns-sdk-go/dfnsapiclient"
@@ -15,26 +20,37 @@ import (
 // Client is the DFNS API client
 type Client struct {
 	config     Config
-	httpClient *http.Client
-	dfnsClient *http.Client
+	httpClient *http.Client // Sends bearer-token requests as they are
+	dfnsClient *http.Client // Signs user actions with the service account key
+	tokens     *tokenSource
 }
 
-// NewClient creates a new DFNS client
+// NewClient creates a new DFNS client. Without a credential and private key
+// it runs in token-only mode, authenticating with the bearer token alone.
 func NewClient(config Config) (*Client, error) {
 	client := &Client{
-		config: config,
+		config:     config,
+		httpClient: &http.Client{},
+	}
+	client.tokens = &tokenSource{
+		serviceToken: config.ServiceAccountToken,
+		username:     config.DelegatedUsername,
+		login:        client.delegatedLogin,
+		now:          time.Now,
 	}
 
-	// Load private key content
-	privateKey := config.PrivateKey
-	if privateKey == "" && config.PrivateKeyPath != "" {
-		keyData, err := os.ReadFile(config.PrivateKeyPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read private key file: %w", err)
-		}
-		privateKey = string(keyData)
+	if !config.CanSign() {
+		logger.Structured.Warn("DFNS client in token-only mode, signed calls will be rejected", "dfns_org", config.Name)
+		return client, nil
 	}
 
+	// Load the private key now so a bad key fails at startup, not on the first transfer
+	privateKey, keyType, err := loadPrivateKey(config)
+	if err != nil {
+		return nil, err
+	}
+	logger.Structured.Info("DFNS signing key loaded", "dfns_org", config.Name, "key_type", keyType)
+
 	// Create the DFNS signer (no error returned)
 	signer := credentials.NewAsymmetricKeySigner(&credentials.AsymmetricKeySignerConfig{
 		PrivateKey: privateKey,
@@ -57,13 +73,44 @@ func NewClient(config Config) (*Client, error) {
 
 	// Create the DFNS HTTP client (handles signing automatically)
 	client.dfnsClient = api.CreateDfnsAPIClient(apiOptions)
-	client.httpClient = &http.Client{}
 
 	return client, nil
 }
 
-// doRequest performs an authenticated request to the DFNS API
-func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error) {
+// doRequest performs an authenticated request to the DFNS API. The call is
+// abandoned when ctx is done or the configured per-call timeout elapses.
+// A delegated token DFNS rejects as expired is refreshed and the call
+// retried once.
+func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	if timeout := c.config.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Delegated users cannot have their actions signed by the service
+	// account, so their requests only carry the bearer token
+	signed := c.dfnsClient != nil && !c.tokens.Delegated()
+	for attempt := 0; ; attempt++ {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := c.send(ctx, method, path, body, token, signed)
+		var apiErr APIError
+		if attempt == 0 && c.tokens.Delegated() && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			c.tokens.Invalidate(token)
+			continue
+		}
+		return respBody, err
+	}
+}
+
+// send makes one call to the DFNS API with the bearer token, through the
+// signing client when signed is set
+func (c *Client) send(ctx context.Context, method, path string, body interface{}, token string, signed bool) ([]byte, error) {
+	log := logger.FromContext(ctx)
+
 	var bodyBytes []byte
 	var err error
 
@@ -76,19 +123,31 @@ func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error
 
 	url := c.config.BaseURL + path
 
-	req, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	// Use the DFNS client which handles signing automatically
-	resp, err := c.dfnsClient.Do(req)
+	httpClient := c.httpClient
+	if signed {
+		// The DFNS client signs user actions automatically
+		httpClient = c.dfnsClient
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	elapsed := time.Since(start)
+	log = log.With("method", method, "endpoint", metrics.EndpointLabel(path))
 	if err != nil {
+		metrics.ObserveDFNSRequest(method, path, 0, elapsed)
+		log.Error("DFNS request failed", "duration_ms", elapsed.Milliseconds(), "error", err)
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	metrics.ObserveDFNSRequest(method, path, resp.StatusCode, elapsed)
+	log = log.With("status", resp.StatusCode, "duration_ms", elapsed.Milliseconds())
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -96,9 +155,11 @@ func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		log.Warn("DFNS request rejected")
+		return nil, APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode), Details: string(respBody)}
 	}
 
+	log.Debug("DFNS request completed")
 	return respBody, nil
 }
 
@@ -2,17 +2,26 @@ package dfns
 
 import (
 	"os"
+	"time"
 )
 
+// DefaultTimeout bounds each DFNS API call when no timeout is configured
+const DefaultTimeout = 30 * time.Second
+
 // Config holds DFNS configuration
 type Config struct {
-	BaseURL             string // https://api.dfns.io or https://api.dfns.ninja (testnet)
-	OrgID               string // Organization ID from DFNS dashboard
-	ServiceAccountToken string // Service account authentication token
-	CredentialID        string // Credential ID for signing (from DFNS dashboard)
-	PrivateKey          string // Private key PEM content (for signing)
-	PrivateKeyPath      string // Path to service account private key file (for signing)
-	WebhookSecret       string // Secret for webhook signature verification
+	Name                string            // Organization name used for routing, e.g. "primary" or "backup"
+	BaseURL             string            // https://api.dfns.io or https://api.dfns.ninja (testnet)
+	OrgID               string            // Organization ID from DFNS dashboard
+	ServiceAccountToken string            // Service account authentication token
+	CredentialID        string            // Credential ID for signing (from DFNS dashboard)
+	PrivateKey          string            // Private key PEM content (for signing)
+	PrivateKeyPath      string            // Path to service account private key file (for signing)
+	PrivateKeySecret    string            // Secrets manager name of the private key PEM (for signing)
+	WebhookSecret       string            // Secret for webhook signature verification
+	DelegatedUsername   string            // End user to act as via delegated login; empty uses the service account token
+	FeeSponsors         map[string]string // Fee sponsor ID by DFNS network, attached to transfers on that network
+	Timeout             time.Duration     // Per-call timeout; 0 disables it
 }
 
 // LoadConfigFromEnv loads DFNS configuration from environment variables
@@ -24,7 +33,11 @@ func LoadConfigFromEnv() Config {
 		CredentialID:        os.Getenv("DFNS_CREDENTIAL_ID"),
 		PrivateKey:          os.Getenv("DFNS_PRIVATE_KEY"),
 		PrivateKeyPath:      os.Getenv("DFNS_PRIVATE_KEY_PATH"),
+		PrivateKeySecret:    os.Getenv("DFNS_PRIVATE_KEY_SECRET"),
 		WebhookSecret:       os.Getenv("DFNS_WEBHOOK_SECRET"),
+		DelegatedUsername:   os.Getenv("DFNS_DELEGATED_USERNAME"),
+		FeeSponsors:         parseFeeSponsors(os.Getenv("DFNS_FEE_SPONSORS")),
+		Timeout:             getDurationOrDefault("DFNS_TIMEOUT", DefaultTimeout),
 	}
 }
 
@@ -33,6 +46,13 @@ func (c Config) IsConfigured() bool {
 	return c.ServiceAccountToken != ""
 }
 
+// CanSign reports whether a credential and private key are configured for
+// signing user actions. Without them the client runs in token-only mode and
+// calls that DFNS requires to be signed are rejected.
+func (c Config) CanSign() bool {
+	return c.CredentialID != "" && (c.PrivateKey != "" || c.PrivateKeyPath != "" || c.PrivateKeySecret != "")
+}
+
 // getEnvOrDefault returns the environment variable value or a default
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -40,3 +60,12 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDurationOrDefault parses a duration such as "10s" from the environment,
+// falling back to defaultValue when unset or invalid
+func getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d >= 0 {
+		return d
+	}
+	return defaultValue
+}
@@ -0,0 +1,104 @@
+package dfns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fee sponsor statuses
+const (
+	FeeSponsorActive      = "Active"
+	FeeSponsorDeactivated = "Deactivated"
+)
+
+// FeeSponsor is a DFNS resource that pays network fees for transfers from
+// other wallets on its network out of its own wallet's native balance, so
+// user wallets need no gas funding
+type FeeSponsor struct {
+	ID          string `json:"id"`
+	WalletID    string `json:"walletId"` // Wallet whose native balance pays the fees
+	Network     string `json:"network"`
+	Status      string `json:"status"`
+	DateCreated string `json:"dateCreated"`
+}
+
+// FeeSponsorListResponse represents a page of fee sponsors
+type FeeSponsorListResponse struct {
+	Items      []FeeSponsor `json:"items"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+// CreateFeeSponsorRequest makes a wallet the fee sponsor for its network
+type CreateFeeSponsorRequest struct {
+	WalletID string `json:"walletId"`
+}
+
+// CreateFeeSponsor makes walletID a fee sponsor for its network. Its ID goes
+// in the org's DFNS_FEE_SPONSORS to be attached to transfers.
+func (c *Client) CreateFeeSponsor(ctx context.Context, walletID string) (*FeeSponsor, error) {
+	return c.feeSponsorRequest(ctx, "POST", "/fee-sponsors", CreateFeeSponsorRequest{WalletID: walletID}, "create fee sponsor")
+}
+
+// GetFeeSponsor retrieves a fee sponsor by its ID
+func (c *Client) GetFeeSponsor(ctx context.Context, sponsorID string) (*FeeSponsor, error) {
+	return c.feeSponsorRequest(ctx, "GET", "/fee-sponsors/"+sponsorID, nil, "get fee sponsor")
+}
+
+// ActivateFeeSponsor resumes paying fees from a deactivated sponsor
+func (c *Client) ActivateFeeSponsor(ctx context.Context, sponsorID string) (*FeeSponsor, error) {
+	return c.feeSponsorRequest(ctx, "PUT", "/fee-sponsors/"+sponsorID+"/activate", nil, "activate fee sponsor")
+}
+
+// DeactivateFeeSponsor stops a sponsor paying fees; transfers naming it are rejected
+func (c *Client) DeactivateFeeSponsor(ctx context.Context, sponsorID string) (*FeeSponsor, error) {
+	return c.feeSponsorRequest(ctx, "PUT", "/fee-sponsors/"+sponsorID+"/deactivate", nil, "deactivate fee sponsor")
+}
+
+// ListFeeSponsors lists the org's fee sponsors
+func (c *Client) ListFeeSponsors(ctx context.Context) (*FeeSponsorListResponse, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/fee-sponsors", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fee sponsors: %w", err)
+	}
+
+	var list FeeSponsorListResponse
+	if err := json.Unmarshal(respBody, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse fee sponsor list response: %w", err)
+	}
+	return &list, nil
+}
+
+func (c *Client) feeSponsorRequest(ctx context.Context, method, path string, body interface{}, action string) (*FeeSponsor, error) {
+	respBody, err := c.doRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	var sponsor FeeSponsor
+	if err := json.Unmarshal(respBody, &sponsor); err != nil {
+		return nil, fmt.Errorf("failed to parse fee sponsor response: %w", err)
+	}
+	return &sponsor, nil
+}
+
+// FeeSponsorFor returns the fee sponsor configured for a DFNS network, or ""
+func (c *Client) FeeSponsorFor(network string) string {
+	return c.config.FeeSponsors[network]
+}
+
+// parseFeeSponsors reads "Network=sponsorID" pairs separated by commas, e.g.
+// "EthereumMainnet=fs-1,Tron=fs-2". Malformed pairs are skipped.
+func parseFeeSponsors(value string) map[string]string {
+	sponsors := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		network, id, ok := strings.Cut(pair, "=")
+		network, id = strings.TrimSpace(network), strings.TrimSpace(id)
+		if !ok || network == "" || id == "" {
+			continue
+		}
+		sponsors[network] = id
+	}
+	return sponsors
+}
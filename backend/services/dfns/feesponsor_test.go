@@ -0,0 +1,46 @@
+package dfns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransfersCarryConfiguredFeeSponsor(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fee-sponsors" {
+			w.Write([]byte(`{"id":"fs-new","walletId":"wa-gas","network":"EthereumMainnet","status":"Active"}`))
+			return
+		}
+		got = map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"xfer-1","status":"Pending"}`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(srv.URL)
+	client.config.FeeSponsors = parseFeeSponsors(" EthereumMainnet = fs-eth ,broken, Tron=")
+	ctx := context.Background()
+
+	if _, err := client.InitiateTransfer(ctx, "wa-user", NewTokenTransfer("ethereum", "0xto", "0xusdc", "1000000", "trace-1")); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if got["feeSponsorId"] != "fs-eth" || got["network"] != nil {
+		t.Fatalf("ethereum transfer body = %v; want the sponsor attached and no network field", got)
+	}
+
+	if _, err := client.InitiateTransfer(ctx, "wa-user", NewTokenTransfer("tron", "Tto", "Tusdt", "1000000", "trace-2")); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if _, ok := got["feeSponsorId"]; ok {
+		t.Fatalf("tron transfer body = %v; want no sponsor where none is configured", got)
+	}
+
+	sponsor, err := client.CreateFeeSponsor(ctx, "wa-gas")
+	if err != nil || sponsor.ID != "fs-new" || sponsor.Status != FeeSponsorActive {
+		t.Fatalf("create sponsor = %+v, %v", sponsor, err)
+	}
+}
@@ -0,0 +1,112 @@
+package dfns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Signing key types DFNS accepts for service account credentials
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeECDSA   = "ecdsa-p256"
+	KeyTypeRSA     = "rsa"
+)
+
+// ErrUnsupportedKey is returned for a signing key DFNS cannot verify
+var ErrUnsupportedKey = errors.New("unsupported DFNS signing key")
+
+// SecretStore reads a named secret. secrets.Store satisfies it.
+type SecretStore interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// LoadPrivateKeySecrets fills in PrivateKey from the secrets store for every
+// config that names a PrivateKeySecret and has no key of its own, so the key
+// is held in memory only. Configs whose secret cannot be read are left without
+// a key and fail to initialize; the first error is returned.
+func LoadPrivateKeySecrets(ctx context.Context, configs []Config, store SecretStore) error {
+	var firstErr error
+	for i := range configs {
+		config := &configs[i]
+		if config.PrivateKeySecret == "" || config.PrivateKey != "" || config.PrivateKeyPath != "" {
+			continue
+		}
+		if store == nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("DFNS org %s: private key secret set but no secrets backend configured", config.Name)
+			}
+			continue
+		}
+		key, err := store.Get(ctx, config.PrivateKeySecret)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("DFNS org %s: failed to load private key secret: %w", config.Name, err)
+			}
+			continue
+		}
+		config.PrivateKey = key
+	}
+	return firstErr
+}
+
+// loadPrivateKey reads the configured PEM private key, from PrivateKey or
+// else PrivateKeyPath, and checks that it parses as a key DFNS accepts:
+// ED25519 or ECDSA P-256 in PKCS8, ECDSA in SEC1 ("EC PRIVATE KEY") or RSA
+// in PKCS8 or PKCS1. The key type is selected from the parsed key, and the
+// signer picks its algorithm the same way. It returns the PEM and the type.
+func loadPrivateKey(config Config) (string, string, error) {
+	privateKey := config.PrivateKey
+	if privateKey == "" && config.PrivateKeyPath != "" {
+		keyData, err := os.ReadFile(config.PrivateKeyPath)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read private key file: %w", err)
+		}
+		privateKey = string(keyData)
+	}
+	if privateKey == "" && config.PrivateKeySecret != "" {
+		return "", "", fmt.Errorf("private key secret %s was not loaded", config.PrivateKeySecret)
+	}
+
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return "", "", errors.New("private key is not PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return "", "", fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return privateKey, KeyTypeEd25519, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return "", "", fmt.Errorf("%w: ECDSA curve %s, want P-256", ErrUnsupportedKey, k.Curve.Params().Name)
+		}
+		return privateKey, KeyTypeECDSA, nil
+	case *rsa.PrivateKey:
+		return privateKey, KeyTypeRSA, nil
+	default:
+		return "", "", fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
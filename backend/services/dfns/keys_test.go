@@ -0,0 +1,86 @@
+package dfns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func pkcs8PEM(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestLoadPrivateKeySelectsTypeFromKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	sec1, _ := x509.MarshalECPrivateKey(p256)
+
+	tests := []struct {
+		name string
+		pem  string
+		want string
+		err  error
+	}{
+		{"ed25519 pkcs8", pkcs8PEM(t, edKey), KeyTypeEd25519, nil},
+		{"p256 pkcs8", pkcs8PEM(t, p256), KeyTypeECDSA, nil},
+		{"p256 sec1", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})), KeyTypeECDSA, nil},
+		{"rsa pkcs1", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})), KeyTypeRSA, nil},
+		{"p384 pkcs8", pkcs8PEM(t, p384), "", ErrUnsupportedKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, keyType, err := loadPrivateKey(Config{PrivateKey: tt.pem})
+			if !errors.Is(err, tt.err) || keyType != tt.want {
+				t.Fatalf("key type %q, err %v; want %q, %v", keyType, err, tt.want, tt.err)
+			}
+		})
+	}
+
+	if _, err := NewClient(Config{BaseURL: "https://api.dfns.test", ServiceAccountToken: "t", CredentialID: "cr-1", PrivateKey: "not a key"}); err == nil {
+		t.Fatal("NewClient should reject an unreadable signing key")
+	}
+}
+
+type fakeSecrets map[string]string
+
+func (f fakeSecrets) Get(_ context.Context, name string) (string, error) {
+	if value, ok := f[name]; ok {
+		return value, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestLoadPrivateKeySecrets(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keyPEM := pkcs8PEM(t, edKey)
+	configs := []Config{
+		{Name: PrimaryOrg, PrivateKeySecret: "dfns/primary"},
+		{Name: "backup", PrivateKeySecret: "dfns/backup"},
+		{Name: "local", PrivateKey: "inline", PrivateKeySecret: "dfns/local"},
+	}
+
+	err := LoadPrivateKeySecrets(context.Background(), configs, fakeSecrets{"dfns/primary": keyPEM})
+	if err == nil {
+		t.Fatal("a missing secret should be reported")
+	}
+	if configs[0].PrivateKey != keyPEM || configs[1].PrivateKey != "" || configs[2].PrivateKey != "inline" {
+		t.Fatalf("configs = %+v", configs)
+	}
+	if _, _, err := loadPrivateKey(configs[1]); err == nil {
+		t.Fatal("a config whose secret was not loaded should not load a key")
+	}
+}
@@ -0,0 +1,166 @@
+package dfns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"socialpredict/logger"
+)
+
+// PrimaryOrg is the name of the organization configured by the unprefixed DFNS_* variables
+const PrimaryOrg = "primary"
+
+// ErrNoOrgConfigured is returned when no DFNS organization has a working client
+var ErrNoOrgConfigured = errors.New("no DFNS organization configured")
+
+// LoadOrgConfigsFromEnv loads the primary configuration plus any additional
+// organizations listed in DFNS_ORGS (comma separated). Each additional org
+// reads DFNS_<NAME>_API_URL, DFNS_<NAME>_ORG_ID, DFNS_<NAME>_SERVICE_ACCOUNT_TOKEN,
+// DFNS_<NAME>_CREDENTIAL_ID, DFNS_<NAME>_PRIVATE_KEY, DFNS_<NAME>_PRIVATE_KEY_PATH,
+// DFNS_<NAME>_PRIVATE_KEY_SECRET, DFNS_<NAME>_WEBHOOK_SECRET,
+// DFNS_<NAME>_DELEGATED_USERNAME, DFNS_<NAME>_FEE_SPONSORS and
+// DFNS_<NAME>_TIMEOUT (defaulting to DFNS_TIMEOUT).
+func LoadOrgConfigsFromEnv() []Config {
+	primary := LoadConfigFromEnv()
+	primary.Name = PrimaryOrg
+	configs := []Config{primary}
+
+	for _, name := range strings.Split(os.Getenv("DFNS_ORGS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == PrimaryOrg {
+			continue
+		}
+		prefix := "DFNS_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		configs = append(configs, Config{
+			Name:                name,
+			BaseURL:             getEnvOrDefault(prefix+"API_URL", "https://api.dfns.io"),
+			OrgID:               os.Getenv(prefix + "ORG_ID"),
+			ServiceAccountToken: os.Getenv(prefix + "SERVICE_ACCOUNT_TOKEN"),
+			CredentialID:        os.Getenv(prefix + "CREDENTIAL_ID"),
+			PrivateKey:          os.Getenv(prefix + "PRIVATE_KEY"),
+			PrivateKeyPath:      os.Getenv(prefix + "PRIVATE_KEY_PATH"),
+			PrivateKeySecret:    os.Getenv(prefix + "PRIVATE_KEY_SECRET"),
+			WebhookSecret:       os.Getenv(prefix + "WEBHOOK_SECRET"),
+			DelegatedUsername:   os.Getenv(prefix + "DELEGATED_USERNAME"),
+			FeeSponsors:         parseFeeSponsors(os.Getenv(prefix + "FEE_SPONSORS")),
+			Timeout:             getDurationOrDefault(prefix+"TIMEOUT", primary.Timeout),
+		})
+	}
+
+	return configs
+}
+
+// Orgs routes DFNS calls to the organization that owns a wallet and fails
+// over wallet creation to secondary organizations
+type Orgs struct {
+	clients map[string]*Client
+	configs map[string]Config
+	order   []string // Failover order, primary first
+}
+
+// NewOrgs creates clients for every configured organization. Organizations
+// that are not configured or fail to initialize are skipped with a warning.
+func NewOrgs(configs []Config) *Orgs {
+	orgs := &Orgs{
+		clients: map[string]*Client{},
+		configs: map[string]Config{},
+	}
+
+	for _, config := range configs {
+		orgs.configs[config.Name] = config
+		if !config.IsConfigured() {
+			continue
+		}
+		client, err := NewClient(config)
+		if err != nil {
+			logger.Structured.Warn("failed to initialize DFNS client", "dfns_org", config.Name, "error", err)
+			continue
+		}
+		orgs.Add(config.Name, client)
+	}
+
+	return orgs
+}
+
+// Add registers a client for an organization, appending it to the failover order
+func (o *Orgs) Add(name string, client *Client) {
+	if _, exists := o.clients[name]; !exists {
+		o.order = append(o.order, name)
+	}
+	o.clients[name] = client
+}
+
+// Names returns the organizations with an initialized client, in failover order
+func (o *Orgs) Names() []string {
+	return append([]string(nil), o.order...)
+}
+
+// Configured reports whether at least one organization passed
+// Config.IsConfigured and has a client
+func (o *Orgs) Configured() bool {
+	return o != nil && len(o.order) > 0
+}
+
+// Client returns the client for an organization, or nil if unavailable.
+// An empty name selects the primary organization.
+func (o *Orgs) Client(name string) *Client {
+	if o == nil {
+		return nil
+	}
+	if name == "" {
+		name = PrimaryOrg
+	}
+	return o.clients[name]
+}
+
+// Primary returns the client for the primary organization, or nil
+func (o *Orgs) Primary() *Client {
+	return o.Client(PrimaryOrg)
+}
+
+// WebhookSecret returns the webhook secret for an organization and whether the org is known
+func (o *Orgs) WebhookSecret(name string) (string, bool) {
+	if o == nil {
+		return "", false
+	}
+	config, ok := o.configs[name]
+	return config.WebhookSecret, ok
+}
+
+// CreateWallet creates a wallet in the first available organization, failing
+// over to the next one when an organization is unreachable or returns a
+// server error. It returns the wallet and the organization that created it.
+// Once ctx is done no further organizations are tried.
+func (o *Orgs) CreateWallet(ctx context.Context, req CreateWalletRequest) (*WalletResponse, string, error) {
+	if o == nil || len(o.order) == 0 {
+		return nil, "", ErrNoOrgConfigured
+	}
+
+	var lastErr error
+	for _, name := range o.order {
+		wallet, err := o.clients[name].CreateWallet(ctx, req)
+		if err == nil {
+			return wallet, name, nil
+		}
+		lastErr = fmt.Errorf("org %s: %w", name, err)
+		if ctx.Err() != nil || !IsRetryable(err) {
+			return nil, "", lastErr
+		}
+		logger.FromContext(ctx).Warn("DFNS wallet creation failed, trying next org", "dfns_org", name, "error", err)
+	}
+
+	return nil, "", lastErr
+}
+
+// IsRetryable reports whether a DFNS error is worth retrying elsewhere:
+// transport failures and 5xx/429 responses are, client errors are not.
+func IsRetryable(err error) bool {
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == 429
+	}
+	return err != nil
+}
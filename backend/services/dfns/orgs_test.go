@@ -0,0 +1,138 @@
+package dfns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client that talks to url without request signing
+func newTestClient(url string) *Client {
+	return &Client{config: Config{BaseURL: url}, httpClient: http.DefaultClient, tokens: &tokenSource{serviceToken: "test-token"}}
+}
+
+func TestLoadOrgConfigsFromEnv(t *testing.T) {
+	t.Setenv("DFNS_SERVICE_ACCOUNT_TOKEN", "primary-token")
+	t.Setenv("DFNS_WEBHOOK_SECRET", "primary-secret")
+	t.Setenv("DFNS_ORGS", " backup ,primary,")
+	t.Setenv("DFNS_BACKUP_SERVICE_ACCOUNT_TOKEN", "backup-token")
+	t.Setenv("DFNS_BACKUP_WEBHOOK_SECRET", "backup-secret")
+
+	configs := LoadOrgConfigsFromEnv()
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 configs, got %d", len(configs))
+	}
+	if configs[0].Name != PrimaryOrg || configs[0].ServiceAccountToken != "primary-token" {
+		t.Fatalf("unexpected primary config: %+v", configs[0])
+	}
+	if configs[1].Name != "backup" || configs[1].WebhookSecret != "backup-secret" {
+		t.Fatalf("unexpected backup config: %+v", configs[1])
+	}
+
+	orgs := &Orgs{configs: map[string]Config{}}
+	for _, c := range configs {
+		orgs.configs[c.Name] = c
+	}
+	if secret, ok := orgs.WebhookSecret("backup"); !ok || secret != "backup-secret" {
+		t.Fatalf("unexpected backup secret %q", secret)
+	}
+	if _, ok := orgs.WebhookSecret("unknown"); ok {
+		t.Fatal("unknown org should not have a webhook secret")
+	}
+}
+
+func TestCreateWalletFailsOverOnServerError(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"wa-backup","address":"0xabc"}`))
+	}))
+	defer backup.Close()
+
+	orgs := &Orgs{clients: map[string]*Client{}, configs: map[string]Config{}}
+	orgs.Add(PrimaryOrg, newTestClient(primary.URL))
+	orgs.Add("backup", newTestClient(backup.URL))
+
+	wallet, org, err := orgs.CreateWallet(context.Background(), CreateWalletRequest{Network: "Ethereum"})
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if org != "backup" || wallet.ID != "wa-backup" {
+		t.Fatalf("expected backup wallet, got %s from %s", wallet.ID, org)
+	}
+}
+
+func TestCreateWalletDoesNotFailOverOnClientError(t *testing.T) {
+	calls := 0
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad network", http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	orgs := &Orgs{clients: map[string]*Client{}, configs: map[string]Config{}}
+	orgs.Add(PrimaryOrg, newTestClient(rejecting.URL))
+	orgs.Add("backup", newTestClient(rejecting.URL))
+
+	_, _, err := orgs.CreateWallet(context.Background(), CreateWalletRequest{Network: "Nope"})
+	var apiErr APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 APIError, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+
+	if _, _, err := (&Orgs{}).CreateWallet(context.Background(), CreateWalletRequest{}); !errors.Is(err, ErrNoOrgConfigured) {
+		t.Fatalf("expected ErrNoOrgConfigured, got %v", err)
+	}
+}
+
+func TestRequestTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	client := newTestClient(slow.URL)
+	client.config.Timeout = 50 * time.Millisecond
+	if err := client.Ping(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestCreateWalletStopsFailoverWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var backupCalled bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupCalled = true
+		w.Write([]byte(`{"id":"wa-backup","address":"0xabc"}`))
+	}))
+	defer backup.Close()
+
+	orgs := &Orgs{clients: map[string]*Client{}, configs: map[string]Config{}}
+	orgs.Add(PrimaryOrg, newTestClient(primary.URL))
+	orgs.Add("backup", newTestClient(backup.URL))
+
+	if _, _, err := orgs.CreateWallet(ctx, CreateWalletRequest{Network: "Ethereum"}); err == nil {
+		t.Fatal("expected an error once the context is cancelled")
+	}
+	if backupCalled {
+		t.Fatal("backup org should not be tried after cancellation")
+	}
+}
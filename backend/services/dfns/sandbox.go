@@ -0,0 +1,470 @@
+package dfns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"socialpredict/logger"
+)
+
+// DFNS_MODE values
+const (
+	ModeLive    = "live"
+	ModeSandbox = "sandbox"
+)
+
+// sandboxBaseURL is the base URL sandbox clients send requests to; they never leave the process
+const sandboxBaseURL = "http://dfns.sandbox"
+
+// SandboxFailSuffix makes a sandbox transfer fail when the destination
+// address ends with it, to exercise the failed-withdrawal path
+const SandboxFailSuffix = "dead"
+
+// ErrSandboxWalletNotFound is returned when simulating a deposit to an unknown wallet
+var ErrSandboxWalletNotFound = errors.New("sandbox wallet not found")
+
+// SandboxEnabled reports whether DFNS_MODE selects the sandbox
+func SandboxEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("DFNS_MODE")), ModeSandbox)
+}
+
+// SandboxConfig holds sandbox settings
+type SandboxConfig struct {
+	WebhookURL    string        // Where simulated webhooks are delivered
+	WebhookSecret string        // Signs simulated webhooks
+	ConfirmDelay  time.Duration // Delay before a transfer completes or a deposit confirms
+}
+
+// LoadSandboxConfigFromEnv reads DFNS_SANDBOX_WEBHOOK_URL, DFNS_WEBHOOK_SECRET
+// and DFNS_SANDBOX_CONFIRM_DELAY
+func LoadSandboxConfigFromEnv() SandboxConfig {
+	return SandboxConfig{
+		WebhookURL:    getEnvOrDefault("DFNS_SANDBOX_WEBHOOK_URL", "http://localhost:8080/v0/webhook/dfns"),
+		WebhookSecret: getEnvOrDefault("DFNS_WEBHOOK_SECRET", "sandbox-webhook-secret"),
+		ConfirmDelay:  getDurationOrDefault("DFNS_SANDBOX_CONFIRM_DELAY", 5*time.Second),
+	}
+}
+
+// Sandbox is an in-memory stand-in for the DFNS API for local development.
+// Wallets get deterministic IDs and addresses derived from their name, so
+// they survive restarts; transfers complete (or fail, see SandboxFailSuffix)
+// after ConfirmDelay and are reported through signed webhooks, just like DFNS.
+type Sandbox struct {
+	config SandboxConfig
+
+	mu        sync.Mutex
+	wallets   map[string]*sandboxWallet
+	transfers map[string]*TransferEventData
+	run       string // Distinguishes IDs from previous runs, which the database may still hold
+	seq       int
+
+	deliver func(payload []byte) // Sends a webhook; replaced in tests
+	after   func(d time.Duration, f func())
+}
+
+type sandboxWallet struct {
+	WalletResponse
+	assets map[string]*sandboxAsset // By lower-cased contract address
+}
+
+type sandboxAsset struct {
+	symbol  string
+	balance *big.Int // Base units
+}
+
+// NewSandbox creates an empty sandbox
+func NewSandbox(config SandboxConfig) *Sandbox {
+	s := &Sandbox{
+		config:    config,
+		wallets:   map[string]*sandboxWallet{},
+		transfers: map[string]*TransferEventData{},
+		run:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		after:     func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+	}
+	s.deliver = s.post
+	return s
+}
+
+// Orgs returns a single primary organization served by the sandbox
+func (s *Sandbox) Orgs() *Orgs {
+	config := Config{Name: PrimaryOrg, BaseURL: sandboxBaseURL, WebhookSecret: s.config.WebhookSecret, Timeout: DefaultTimeout}
+	client := &Client{config: config, httpClient: &http.Client{Transport: s}, dfnsClient: &http.Client{Transport: s}, tokens: &tokenSource{serviceToken: "sandbox"}}
+	orgs := &Orgs{clients: map[string]*Client{}, configs: map[string]Config{PrimaryOrg: config}}
+	orgs.Add(PrimaryOrg, client)
+	return orgs
+}
+
+// RoundTrip serves client requests in-process
+func (s *Sandbox) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// ServeHTTP implements the subset of the DFNS wallet API the backend uses
+func (s *Sandbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "wallets" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		var req CreateWalletRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Network == "" {
+			http.Error(w, "network is required", http.StatusBadRequest)
+			return
+		}
+		writeSandboxJSON(w, s.createWallet(req))
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeSandboxJSON(w, s.listWallets(r.URL.Query().Get("network")))
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		if wallet, ok := s.wallet(parts[1]); ok {
+			writeSandboxJSON(w, wallet.WalletResponse)
+			return
+		}
+		http.NotFound(w, r)
+	case len(parts) == 3 && parts[2] == "assets" && r.Method == http.MethodGet:
+		if assets, ok := s.assets(parts[1]); ok {
+			writeSandboxJSON(w, assets)
+			return
+		}
+		http.NotFound(w, r)
+	case len(parts) == 3 && parts[2] == "transfers" && r.Method == http.MethodPost:
+		var req TransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.To == "" || req.Amount == "" {
+			http.Error(w, "to and amount are required", http.StatusBadRequest)
+			return
+		}
+		transfer, err := s.transfer(parts[1], req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeSandboxJSON(w, transfer)
+	case len(parts) == 3 && parts[2] == "transfers" && r.Method == http.MethodGet:
+		writeSandboxJSON(w, s.listTransfers(parts[1]))
+	case len(parts) == 4 && parts[2] == "transfers" && r.Method == http.MethodGet:
+		if transfer, ok := s.getTransfer(parts[1], parts[3]); ok {
+			writeSandboxJSON(w, transfer)
+			return
+		}
+		http.NotFound(w, r)
+	case len(parts) == 3 && parts[2] == "transactions" && r.Method == http.MethodPost:
+		if _, ok := s.wallet(parts[1]); !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		id := s.nextID("tx")
+		s.mu.Unlock()
+		writeSandboxJSON(w, TransferResponse{ID: id, WalletID: parts[1], Status: "Broadcasted", TxHash: sandboxTxHash(id), DateCreated: sandboxNow()})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// SimulateDeposit credits a sandbox wallet with amount (in token base units)
+// and reports it the way DFNS does: an unconfirmed inbound webhook now, and
+// a confirmed one after ConfirmDelay
+func (s *Sandbox) SimulateDeposit(walletID, symbol, contract, amount, from string) (*TransferResponse, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	s.mu.Lock()
+	wallet, found := s.wallets[walletID]
+	if !found {
+		s.mu.Unlock()
+		return nil, ErrSandboxWalletNotFound
+	}
+	asset := wallet.asset(symbol, contract)
+	asset.balance.Add(asset.balance, value)
+	id := s.nextID("xfer")
+	data := &TransferEventData{
+		ID:          id,
+		WalletID:    walletID,
+		Network:     wallet.Network,
+		Status:      "Executing",
+		TxHash:      sandboxTxHash(id),
+		Direction:   "Inbound",
+		Kind:        TokenTransferKind(GetChainNameFromNetwork(wallet.Network)),
+		Symbol:      symbol,
+		Amount:      amount,
+		From:        from,
+		To:          wallet.Address,
+		Contract:    contract,
+		Decimals:    GetTokenDecimals(symbol),
+		DateCreated: sandboxNow(),
+	}
+	s.transfers[id] = data
+	inbound := *data
+	s.mu.Unlock()
+
+	go func() {
+		s.emit(EventTransferInbound, &inbound)
+		s.after(s.config.ConfirmDelay, func() {
+			s.mu.Lock()
+			data.Status = TransferStatusConfirmed
+			confirmed := *data
+			s.mu.Unlock()
+			s.emit(EventTransferConfirmed, &confirmed)
+		})
+	}()
+	return &TransferResponse{ID: id, WalletID: walletID, Network: data.Network, Status: inbound.Status, TxHash: data.TxHash, DateCreated: data.DateCreated}, nil
+}
+
+// createWallet returns the wallet for req's name and network, creating it on first use
+func (s *Sandbox) createWallet(req CreateWalletRequest) WalletResponse {
+	seed := sha256.Sum256([]byte(req.Network + "|" + req.Name + "|" + req.ExternalID))
+	id := "wa-sandbox-" + hex.EncodeToString(seed[:8])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wallet, ok := s.wallets[id]; ok {
+		return wallet.WalletResponse
+	}
+	wallet := &sandboxWallet{
+		WalletResponse: WalletResponse{
+			ID:          id,
+			Network:     req.Network,
+			Address:     sandboxAddress(req.Network, seed),
+			Name:        req.Name,
+			Status:      "Active",
+			DateCreated: sandboxNow(),
+			ExternalID:  req.ExternalID,
+		},
+		assets: map[string]*sandboxAsset{},
+	}
+	s.wallets[id] = wallet
+	return wallet.WalletResponse
+}
+
+// RestoreWallet re-registers a wallet created in an earlier run; the sandbox
+// keeps no state across restarts but the database still references it
+func (s *Sandbox) RestoreWallet(wallet WalletResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.wallets[wallet.ID]; ok {
+		return
+	}
+	if wallet.Status == "" {
+		wallet.Status = "Active"
+	}
+	s.wallets[wallet.ID] = &sandboxWallet{WalletResponse: wallet, assets: map[string]*sandboxAsset{}}
+}
+
+func (s *Sandbox) wallet(id string) (*sandboxWallet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wallet, ok := s.wallets[id]
+	return wallet, ok
+}
+
+func (s *Sandbox) listWallets(network string) WalletListResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := WalletListResponse{Items: []WalletResponse{}}
+	for _, wallet := range s.wallets {
+		if network == "" || wallet.Network == network {
+			list.Items = append(list.Items, wallet.WalletResponse)
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].ID < list.Items[j].ID })
+	return list
+}
+
+func (s *Sandbox) assets(walletID string) (WalletBalanceResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wallet, ok := s.wallets[walletID]
+	if !ok {
+		return WalletBalanceResponse{}, false
+	}
+	resp := WalletBalanceResponse{Items: []WalletAsset{}}
+	for contract, asset := range wallet.assets {
+		resp.Items = append(resp.Items, WalletAsset{
+			Symbol:   asset.symbol,
+			Name:     asset.symbol,
+			Balance:  asset.balance.String(),
+			Decimals: GetTokenDecimals(asset.symbol),
+			Contract: contract,
+		})
+	}
+	sort.Slice(resp.Items, func(i, j int) bool { return resp.Items[i].Symbol < resp.Items[j].Symbol })
+	return resp, true
+}
+
+// transfer accepts an outbound transfer and settles it after ConfirmDelay.
+// Balances are not enforced, so hot wallets need no funding.
+func (s *Sandbox) transfer(walletID string, req TransferRequest) (*TransferResponse, error) {
+	s.mu.Lock()
+	wallet, ok := s.wallets[walletID]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrSandboxWalletNotFound
+	}
+	id := s.nextID("xfer")
+	asset := wallet.asset("", req.Contract)
+	data := &TransferEventData{
+		ID:          id,
+		WalletID:    walletID,
+		Network:     wallet.Network,
+		Status:      "Pending",
+		Direction:   "Outbound",
+		Kind:        req.Kind,
+		Symbol:      asset.symbol,
+		Amount:      req.Amount,
+		From:        wallet.Address,
+		To:          req.To,
+		Contract:    req.Contract,
+		DateCreated: sandboxNow(),
+		ExternalID:  req.ExternalID,
+	}
+	s.transfers[id] = data
+	// Built now, because the transfer may settle before this returns
+	resp := &TransferResponse{ID: id, WalletID: walletID, Network: data.Network, Status: data.Status, DateCreated: data.DateCreated}
+	s.mu.Unlock()
+
+	fails := strings.HasSuffix(strings.ToLower(req.To), SandboxFailSuffix)
+	s.after(s.config.ConfirmDelay, func() {
+		kind := EventTransferCompleted
+		s.mu.Lock()
+		if fails {
+			kind, data.Status = EventTransferFailed, "Failed"
+		} else {
+			data.Status, data.TxHash = TransferStatusConfirmed, sandboxTxHash(id)
+			if value, ok := new(big.Int).SetString(req.Amount, 10); ok {
+				asset.balance.Sub(asset.balance, value)
+			}
+		}
+		settled := *data
+		s.mu.Unlock()
+		s.emit(kind, &settled)
+	})
+	return resp, nil
+}
+
+func (s *Sandbox) listTransfers(walletID string) TransferListResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := TransferListResponse{Items: []TransferResponse{}}
+	for _, data := range s.transfers {
+		if data.WalletID == walletID {
+			list.Items = append(list.Items, transferResponse(data))
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].ID < list.Items[j].ID })
+	return list
+}
+
+func (s *Sandbox) getTransfer(walletID, transferID string) (TransferResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.transfers[transferID]
+	if !ok || data.WalletID != walletID {
+		return TransferResponse{}, false
+	}
+	return transferResponse(data), true
+}
+
+// emit delivers a webhook event for a transfer
+func (s *Sandbox) emit(kind string, data *TransferEventData) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	id := s.nextID("evt")
+	s.mu.Unlock()
+	payload, err := json.Marshal(WebhookEvent{ID: id, Kind: kind, Data: raw, Timestamp: sandboxNow(), OrgID: "sandbox"})
+	if err != nil {
+		return
+	}
+	s.deliver(payload)
+}
+
+// post sends a signed webhook to the configured URL
+func (s *Sandbox) post(payload []byte) {
+	req, err := http.NewRequest(http.MethodPost, s.config.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		logger.Structured.Error("sandbox webhook request failed", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-DFNS-Signature", SignWebhookPayload(payload, s.config.WebhookSecret))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		logger.Structured.Warn("sandbox webhook delivery failed", "url", s.config.WebhookURL, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Structured.Warn("sandbox webhook rejected", "url", s.config.WebhookURL, "status", resp.StatusCode)
+	}
+}
+
+// nextID returns a sequential ID; the caller holds s.mu
+func (s *Sandbox) nextID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s-sandbox-%s-%d", prefix, s.run, s.seq)
+}
+
+// asset returns the wallet's holding of a token, creating an empty one; the caller holds s.mu
+func (w *sandboxWallet) asset(symbol, contract string) *sandboxAsset {
+	key := strings.ToLower(contract)
+	asset, ok := w.assets[key]
+	if !ok {
+		asset = &sandboxAsset{symbol: symbol, balance: new(big.Int)}
+		w.assets[key] = asset
+	}
+	if asset.symbol == "" {
+		asset.symbol = symbol
+	}
+	return asset
+}
+
+func transferResponse(data *TransferEventData) TransferResponse {
+	return TransferResponse{ID: data.ID, WalletID: data.WalletID, Network: data.Network, Status: data.Status, TxHash: data.TxHash, DateCreated: data.DateCreated}
+}
+
+// sandboxAddress derives a well-formed address for network from seed
+func sandboxAddress(network string, seed [32]byte) string {
+	if strings.HasPrefix(network, "Tron") {
+		return encodeTronBase58(append([]byte{tronAddressPrefix}, seed[:20]...))
+	}
+	return "0x" + hex.EncodeToString(seed[:20])
+}
+
+func sandboxTxHash(id string) string {
+	sum := sha256.Sum256([]byte("sandbox-tx|" + id))
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+func sandboxNow() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func writeSandboxJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
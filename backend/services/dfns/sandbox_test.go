@@ -0,0 +1,140 @@
+package dfns
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestSandbox settles transfers immediately and collects webhook payloads
+func newTestSandbox(t *testing.T) (*Sandbox, *Client, chan *WebhookEvent) {
+	t.Helper()
+	sandbox := NewSandbox(SandboxConfig{WebhookSecret: "secret"})
+	events := make(chan *WebhookEvent, 10)
+	sandbox.after = func(_ time.Duration, f func()) { f() }
+	sandbox.deliver = func(payload []byte) {
+		event, err := ParseWebhookEvent(payload)
+		if err != nil {
+			t.Errorf("ParseWebhookEvent: %v", err)
+			return
+		}
+		events <- event
+	}
+	return sandbox, sandbox.Orgs().Primary(), events
+}
+
+func nextEvent(t *testing.T, events chan *WebhookEvent) (*WebhookEvent, *TransferEventData) {
+	t.Helper()
+	select {
+	case event := <-events:
+		data, err := ParseTransferEventData(event.Data)
+		if err != nil {
+			t.Fatalf("ParseTransferEventData: %v", err)
+		}
+		return event, data
+	case <-time.After(time.Second):
+		t.Fatal("no webhook delivered")
+		return nil, nil
+	}
+}
+
+func TestSandboxWalletsAreDeterministic(t *testing.T) {
+	_, client, _ := newTestSandbox(t)
+	ctx := context.Background()
+
+	first, err := client.CreateWallet(ctx, CreateWalletRequest{Network: "EthereumSepolia", Name: "user-1-ethereum-sepolia", ExternalID: "1"})
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	again, err := client.CreateWallet(ctx, CreateWalletRequest{Network: "EthereumSepolia", Name: "user-1-ethereum-sepolia", ExternalID: "1"})
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if first.ID != again.ID || first.Address != again.Address {
+		t.Errorf("wallets differ: %+v vs %+v", first, again)
+	}
+	if !IsValidEVMAddress(first.Address) {
+		t.Errorf("invalid EVM address %q", first.Address)
+	}
+
+	tron, err := client.CreateWallet(ctx, CreateWalletRequest{Network: "TronNile", Name: "user-1-tron-nile"})
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if !IsValidTronAddress(tron.Address) {
+		t.Errorf("invalid TRON address %q", tron.Address)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}
+
+func TestSandboxDepositAndTransfer(t *testing.T) {
+	sandbox, client, events := newTestSandbox(t)
+	ctx := context.Background()
+	wallet, err := client.CreateWallet(ctx, CreateWalletRequest{Network: "EthereumSepolia", Name: "hot"})
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	const contract = "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"
+
+	if _, err := sandbox.SimulateDeposit(wallet.ID, "USDC", contract, "5000000", "sender"); err != nil {
+		t.Fatalf("SimulateDeposit: %v", err)
+	}
+	inbound, data := nextEvent(t, events)
+	if inbound.Kind != EventTransferInbound || data.Direction != "Inbound" || data.Status == TransferStatusConfirmed {
+		t.Errorf("first deposit event = %s %+v, want unconfirmed inbound", inbound.Kind, data)
+	}
+	confirmed, data := nextEvent(t, events)
+	if confirmed.Kind != EventTransferConfirmed || data.Status != TransferStatusConfirmed || data.Amount != "5000000" {
+		t.Errorf("second deposit event = %s %+v, want confirmed", confirmed.Kind, data)
+	}
+
+	transfer, err := client.InitiateTransfer(ctx, wallet.ID, TransferRequest{
+		Kind: TransferKindErc20, To: "0x2222222222222222222222222222222222222222", Contract: contract, Amount: "2000000", ExternalID: "trace-1",
+	})
+	if err != nil {
+		t.Fatalf("InitiateTransfer: %v", err)
+	}
+	completed, data := nextEvent(t, events)
+	if completed.Kind != EventTransferCompleted || data.ID != transfer.ID || data.ExternalID != "trace-1" || data.TxHash == "" {
+		t.Errorf("transfer event = %s %+v", completed.Kind, data)
+	}
+
+	assets, err := client.GetWalletBalance(ctx, wallet.ID)
+	if err != nil {
+		t.Fatalf("GetWalletBalance: %v", err)
+	}
+	if len(assets.Items) != 1 || assets.Items[0].Symbol != "USDC" || assets.Items[0].Balance != "3000000" {
+		t.Errorf("assets = %+v, want 3 USDC", assets.Items)
+	}
+}
+
+func TestSandboxTransferFailsForDeadAddress(t *testing.T) {
+	_, client, events := newTestSandbox(t)
+	ctx := context.Background()
+	wallet, err := client.CreateWallet(ctx, CreateWalletRequest{Network: "EthereumSepolia", Name: "user-2"})
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	if _, err := client.InitiateTransfer(ctx, wallet.ID, TransferRequest{
+		Kind: TransferKindErc20, To: "0x000000000000000000000000000000000000dEaD", Amount: "1",
+	}); err != nil {
+		t.Fatalf("InitiateTransfer: %v", err)
+	}
+	if event, _ := nextEvent(t, events); event.Kind != EventTransferFailed {
+		t.Errorf("event kind = %s, want %s", event.Kind, EventTransferFailed)
+	}
+
+	if _, err := client.InitiateTransfer(ctx, "wa-unknown", TransferRequest{To: "0x1", Amount: "1"}); err == nil {
+		t.Error("expected an error for an unknown wallet")
+	}
+}
+
+func TestSandboxWebhookIsSigned(t *testing.T) {
+	payload := []byte(`{"id":"evt-1"}`)
+	if !VerifyWebhookSignature(payload, SignWebhookPayload(payload, "secret"), "secret") {
+		t.Error("signed payload failed verification")
+	}
+}
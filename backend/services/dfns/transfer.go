@@ -1,22 +1,31 @@
 package dfns
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+
+	"socialpredict/logger"
 )
 
 // TransferKind represents the type of transfer
 const (
 	TransferKindErc20  = "Erc20"
+	TransferKindTrc20  = "Trc20"
 	TransferKindNative = "Native"
 )
 
 // TransferRequest represents a request to transfer assets from a wallet
 type TransferRequest struct {
-	Kind     string `json:"kind"`              // "Erc20" for token transfers, "Native" for ETH
-	To       string `json:"to"`                // Destination address
-	Contract string `json:"contract,omitempty"` // Token contract address (for Erc20)
-	Amount   string `json:"amount"`            // Amount in smallest unit (wei/base units)
+	Kind       string `json:"kind"`                 // "Erc20"/"Trc20" for token transfers, "Native" for ETH
+	To         string `json:"to"`                   // Destination address
+	Contract   string `json:"contract,omitempty"`   // Token contract address (for Erc20/Trc20)
+	Amount     string `json:"amount"`               // Amount in smallest unit (wei/base units)
+	FeeLimit   string `json:"feeLimit,omitempty"`   // Trc20 only: most sun to burn for energy
+	ExternalID string `json:"externalId,omitempty"` // Our trace ID, echoed back in webhooks
+
+	FeeSponsorID string `json:"feeSponsorId,omitempty"` // Sponsor paying the network fee instead of the wallet
+	Network      string `json:"-"`                      // Sending wallet's DFNS network; selects the configured fee sponsor
 }
 
 // TransferResponse represents a transfer initiated via DFNS
@@ -35,11 +44,24 @@ type TransferListResponse struct {
 	NextCursor string             `json:"nextCursor,omitempty"`
 }
 
-// InitiateTransfer starts a transfer from a wallet
-func (c *Client) InitiateTransfer(walletID string, req TransferRequest) (*TransferResponse, error) {
+// InitiateTransfer starts a transfer from a wallet. The request's ExternalID,
+// if set, is logged as the trace ID when ctx carries none. A request without
+// a fee sponsor gets the one configured for its Network, so the wallet needs
+// no native balance for gas.
+func (c *Client) InitiateTransfer(ctx context.Context, walletID string, req TransferRequest) (*TransferResponse, error) {
 	path := fmt.Sprintf("/wallets/%s/transfers", walletID)
+	if req.ExternalID != "" && logger.TraceID(ctx) == "" {
+		ctx = logger.WithTraceID(ctx, req.ExternalID)
+	}
+	if req.FeeSponsorID == "" {
+		req.FeeSponsorID = c.FeeSponsorFor(req.Network)
+	}
+	log := logger.FromContext(ctx).With("dfns_wallet_id", walletID)
+	if req.FeeSponsorID != "" {
+		log = log.With("dfns_fee_sponsor_id", req.FeeSponsorID)
+	}
 
-	respBody, err := c.doRequest("POST", path, req)
+	respBody, err := c.doRequest(ctx, "POST", path, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initiate transfer: %w", err)
 	}
@@ -49,14 +71,15 @@ func (c *Client) InitiateTransfer(walletID string, req TransferRequest) (*Transf
 		return nil, fmt.Errorf("failed to parse transfer response: %w", err)
 	}
 
+	log.Info("DFNS transfer initiated", "dfns_transfer_id", transfer.ID, "status", transfer.Status)
 	return &transfer, nil
 }
 
 // GetTransfer retrieves a transfer by its ID
-func (c *Client) GetTransfer(walletID, transferID string) (*TransferResponse, error) {
+func (c *Client) GetTransfer(ctx context.Context, walletID, transferID string) (*TransferResponse, error) {
 	path := fmt.Sprintf("/wallets/%s/transfers/%s", walletID, transferID)
 
-	respBody, err := c.doRequest("GET", path, nil)
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get transfer: %w", err)
 	}
@@ -70,10 +93,10 @@ func (c *Client) GetTransfer(walletID, transferID string) (*TransferResponse, er
 }
 
 // ListTransfers lists all transfers for a wallet
-func (c *Client) ListTransfers(walletID string) (*TransferListResponse, error) {
+func (c *Client) ListTransfers(ctx context.Context, walletID string) (*TransferListResponse, error) {
 	path := fmt.Sprintf("/wallets/%s/transfers", walletID)
 
-	respBody, err := c.doRequest("GET", path, nil)
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list transfers: %w", err)
 	}
@@ -86,16 +109,27 @@ func (c *Client) ListTransfers(walletID string) (*TransferListResponse, error) {
 	return &list, nil
 }
 
-// BroadcastTransactionRequest represents a request to broadcast a signed transaction
+// Broadcast kinds
+const (
+	BroadcastKindTransaction = "Transaction"
+	BroadcastKindEvm         = "Evm"
+)
+
+// BroadcastTransactionRequest represents a request to broadcast a transaction.
+// Either Transaction (serialized) or To/Value/Data (for kind "Evm") is set.
 type BroadcastTransactionRequest struct {
-	Transaction string `json:"transaction"` // Signed transaction data
+	Kind        string `json:"kind,omitempty"`
+	Transaction string `json:"transaction,omitempty"` // Signed transaction data
+	To          string `json:"to,omitempty"`          // Destination address (Evm)
+	Value       string `json:"value,omitempty"`       // Native value in wei (Evm)
+	Data        string `json:"data,omitempty"`        // Hex-encoded calldata (Evm)
 }
 
 // BroadcastTransaction broadcasts a pre-signed transaction
-func (c *Client) BroadcastTransaction(walletID string, req BroadcastTransactionRequest) (*TransferResponse, error) {
+func (c *Client) BroadcastTransaction(ctx context.Context, walletID string, req BroadcastTransactionRequest) (*TransferResponse, error) {
 	path := fmt.Sprintf("/wallets/%s/transactions", walletID)
 
-	respBody, err := c.doRequest("POST", path, req)
+	respBody, err := c.doRequest(ctx, "POST", path, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to broadcast transaction: %w", err)
 	}
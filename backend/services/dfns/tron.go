@@ -0,0 +1,148 @@
+package dfns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// defaultTronFeeLimit caps the TRX (in sun) a TRC20 transfer may burn for
+// energy: 100 TRX, enough for a USDT transfer to a fresh address
+const defaultTronFeeLimit = "100000000"
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// tronAddressPrefix is the first byte of every TRON mainnet and testnet address
+const tronAddressPrefix = 0x41
+
+// TokenTransferKind returns the DFNS transfer kind for a token transfer on chainName
+func TokenTransferKind(chainName string) string {
+	if IsTronChain(chainName) {
+		return TransferKindTrc20
+	}
+	return TransferKindErc20
+}
+
+// TronFeeLimit returns DFNS_TRON_FEE_LIMIT, the most sun a TRC20 transfer may burn
+func TronFeeLimit() string {
+	if v := os.Getenv("DFNS_TRON_FEE_LIMIT"); v != "" {
+		if n, ok := new(big.Int).SetString(v, 10); ok && n.Sign() > 0 {
+			return n.String()
+		}
+	}
+	return defaultTronFeeLimit
+}
+
+// NewTokenTransfer builds a stablecoin transfer request of the right kind for
+// chainName, naming its network so the client can attach a fee sponsor. TRC20
+// transfers carry a fee limit, since the sending wallet burns TRX for energy
+// when it has none staked.
+func NewTokenTransfer(chainName, to, contract, amount, externalID string) TransferRequest {
+	req := TransferRequest{
+		Kind:       TokenTransferKind(chainName),
+		To:         to,
+		Contract:   contract,
+		Amount:     amount,
+		ExternalID: externalID,
+		Network:    GetDFNSNetwork(chainName),
+	}
+	if req.Kind == TransferKindTrc20 {
+		req.FeeLimit = TronFeeLimit()
+	}
+	return req
+}
+
+// NormalizeTronAddress returns the base58check form of a TRON address given
+// in base58 or hex (41-prefixed, or 0x-prefixed without the 41 byte). ok is
+// false if address is neither.
+func NormalizeTronAddress(address string) (string, bool) {
+	if IsValidTronAddress(address) {
+		if _, ok := decodeTronBase58(address); ok {
+			return address, true
+		}
+		return "", false
+	}
+
+	raw := strings.TrimPrefix(strings.TrimPrefix(address, "0x"), "0X")
+	if len(raw) == 40 {
+		raw = "41" + raw
+	}
+	payload, err := hex.DecodeString(raw)
+	if err != nil || len(payload) != 21 || payload[0] != tronAddressPrefix {
+		return "", false
+	}
+	return encodeTronBase58(payload), true
+}
+
+// SameAddress reports whether a and b are the same address on chainName.
+// EVM addresses compare case-insensitively; TRON addresses compare by their
+// decoded bytes, since base58 is case-sensitive and DFNS may report hex.
+func SameAddress(chainName, a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if IsTronChain(chainName) {
+		na, okA := NormalizeTronAddress(a)
+		nb, okB := NormalizeTronAddress(b)
+		return okA && okB && na == nb
+	}
+	return strings.EqualFold(a, b)
+}
+
+// TronTxID formats a transaction hash the way TRON tools expect: lowercase
+// hex without a 0x prefix
+func TronTxID(txHash string) string {
+	return strings.ToLower(strings.TrimPrefix(txHash, "0x"))
+}
+
+func encodeTronBase58(payload []byte) string {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	full := append(append([]byte{}, payload...), second[:4]...)
+
+	n := new(big.Int).SetBytes(full)
+	mod, base := new(big.Int), big.NewInt(58)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range full {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// decodeTronBase58 decodes a base58check TRON address and verifies its checksum
+func decodeTronBase58(address string) ([]byte, bool) {
+	n := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range address {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, false
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	full := n.Bytes()
+	if len(full) != 25 || full[0] != tronAddressPrefix {
+		return nil, false
+	}
+	payload, checksum := full[:21], full[21:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return nil, false
+	}
+	return payload, true
+}
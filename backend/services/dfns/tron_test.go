@@ -0,0 +1,51 @@
+package dfns
+
+import "testing"
+
+const usdtTron = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+
+func TestNormalizeTronAddress(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{usdtTron, usdtTron, true},
+		{"41a614f803b6fd780986a42c78ec9c7f77e6ded13c", usdtTron, true},
+		{"0xa614f803b6fd780986a42c78ec9c7f77e6ded13c", usdtTron, true},
+		{"TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6T", "", false}, // Bad checksum
+		{"0x1234", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeTronAddress(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeTronAddress(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSameAddress(t *testing.T) {
+	if !SameAddress("tron", usdtTron, "41A614F803B6FD780986A42C78EC9C7F77E6DED13C") {
+		t.Error("base58 and hex forms of a TRON address should match")
+	}
+	if SameAddress("tron", usdtTron, "tr7nhqjekqxgtci8q8zy4pl8otszgjlj6t") {
+		t.Error("TRON addresses are case-sensitive")
+	}
+	if !SameAddress("ethereum", "0xdAC17F958D2ee523a2206206994597C13D831ec7", "0xdac17f958d2ee523a2206206994597c13d831ec7") {
+		t.Error("EVM addresses compare case-insensitively")
+	}
+	if SameAddress("base", "", "") {
+		t.Error("empty addresses never match")
+	}
+}
+
+func TestNewTokenTransfer(t *testing.T) {
+	tron := NewTokenTransfer("tron-nile", usdtTron, usdtTron, "1000000", "trace")
+	if tron.Kind != TransferKindTrc20 || tron.FeeLimit != defaultTronFeeLimit {
+		t.Errorf("TRON transfer = %+v, want Trc20 with a fee limit", tron)
+	}
+	evm := NewTokenTransfer("base", "0x1", "0x2", "1", "")
+	if evm.Kind != TransferKindErc20 || evm.FeeLimit != "" {
+		t.Errorf("EVM transfer = %+v, want Erc20 without a fee limit", evm)
+	}
+}
@@ -1,6 +1,8 @@
 package dfns
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"regexp"
 	"strings"
@@ -17,12 +19,6 @@ var ValidChains = map[string]bool{
 	"tron-nile":        true,
 }
 
-// ValidTokens contains the valid token symbols
-var ValidTokens = map[string]bool{
-	"USDC": true,
-	"USDT": true,
-}
-
 // IsValidEVMAddress validates an EVM address format
 func IsValidEVMAddress(address string) bool {
 	return evmAddressRegex.MatchString(address)
@@ -56,35 +52,67 @@ func IsTestnet(chainName string) bool {
 	return strings.Contains(chainName, "sepolia") || strings.Contains(chainName, "nile")
 }
 
-// IsValidTokenSymbol validates a token symbol
-func IsValidTokenSymbol(symbol string) bool {
-	return ValidTokens[symbol]
-}
+// microCreditDecimals is the number of decimals in one platform credit
+const microCreditDecimals = 6
 
-// ConvertToCredits converts a raw token amount to credits
-// For USDC/USDT (6 decimals): 1,000,000 raw units = 1 credit
-func ConvertToCredits(rawAmount string, decimals int) int64 {
-	amount := new(big.Int)
-	amount.SetString(rawAmount, 10)
+// Errors returned by ConvertToMicroCredits
+var (
+	ErrInvalidTokenAmount     = errors.New("token amount is not a number of micro-credits")
+	ErrTokenAmountNotPositive = errors.New("token amount is less than one micro-credit")
+)
 
-	// Create divisor: 10^decimals
-	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+// ConvertToMicroCredits converts a raw token amount to platform micro-credits
+// For USDC/USDT (6 decimals): 1 raw unit = 1 micro-credit, so nothing is lost.
+// Tokens with more than 6 decimals are truncated below one micro-credit.
+// Amounts that are not integers or overflow an int64 return
+// ErrInvalidTokenAmount, and those under one micro-credit
+// ErrTokenAmountNotPositive.
+func ConvertToMicroCredits(rawAmount string, decimals int) (int64, error) {
+	amount, ok := new(big.Int).SetString(rawAmount, 10)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTokenAmount, rawAmount)
+	}
 
-	// Divide to get credits (integer division, truncates fractional credits)
-	credits := new(big.Int).Div(amount, divisor)
+	shift := decimals - microCreditDecimals
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil)
+	if shift >= 0 {
+		amount.Div(amount, scale)
+	} else {
+		amount.Mul(amount, scale)
+	}
 
-	return credits.Int64()
+	if !amount.IsInt64() {
+		return 0, fmt.Errorf("%w: %s overflows", ErrInvalidTokenAmount, rawAmount)
+	}
+	if amount.Sign() <= 0 {
+		return 0, ErrTokenAmountNotPositive
+	}
+	return amount.Int64(), nil
 }
 
-// CreditsToTokenAmount converts credits to raw token amount
-// For USDC/USDT (6 decimals): 1 credit = 1,000,000 raw units
-func CreditsToTokenAmount(credits int64, decimals int) string {
-	amount := big.NewInt(credits)
-	multiplier := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
-	amount.Mul(amount, multiplier)
+// MicroCreditsToTokenAmount converts platform micro-credits to a raw token amount
+// For USDC/USDT (6 decimals): 1 micro-credit = 1 raw unit
+func MicroCreditsToTokenAmount(microCredits int64, decimals int) string {
+	amount := big.NewInt(microCredits)
+
+	shift := decimals - microCreditDecimals
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil)
+	if shift >= 0 {
+		amount.Mul(amount, scale)
+	} else {
+		amount.Div(amount, scale)
+	}
+
 	return amount.String()
 }
 
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
 // GetTokenDecimals returns the decimals for a token symbol
 func GetTokenDecimals(symbol string) int {
 	// USDC and USDT both have 6 decimals
@@ -125,6 +153,16 @@ func GetDFNSNetwork(chainName string) string {
 	return ChainNameToNetwork[chainName]
 }
 
+// GetChainNameFromNetwork returns our chain name for a DFNS network name, or ""
+func GetChainNameFromNetwork(network string) string {
+	for chainName, n := range ChainNameToNetwork {
+		if n == network {
+			return chainName
+		}
+	}
+	return ""
+}
+
 // GetChainIDFromNetwork returns the chain ID for a DFNS network name
 func GetChainIDFromNetwork(network string) int64 {
 	return NetworkToChainID[network]
@@ -0,0 +1,72 @@
+package dfns
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConvertToMicroCreditsKeepsFractions(t *testing.T) {
+	tests := []struct {
+		raw      string
+		decimals int
+		want     int64
+		wantErr  error
+	}{
+		{raw: "10750000", decimals: 6, want: 10_750_000},                        // 10.75 USDC
+		{raw: "1", decimals: 6, want: 1},                                        // smallest USDC unit
+		{raw: "1500000000000000000", decimals: 18, want: 1_500_000},             // 1.5 of an 18-decimal token
+		{raw: "999999999999", decimals: 18, wantErr: ErrTokenAmountNotPositive}, // below one micro-credit
+		{raw: "0", decimals: 6, wantErr: ErrTokenAmountNotPositive},
+		{raw: "-5000000", decimals: 6, wantErr: ErrTokenAmountNotPositive},
+		{raw: "9223372036854775808", decimals: 6, wantErr: ErrInvalidTokenAmount}, // one more than an int64 holds
+		{raw: "not-a-number", decimals: 6, wantErr: ErrInvalidTokenAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := ConvertToMicroCredits(tt.raw, tt.decimals)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("ConvertToMicroCredits(%s, %d) = %d, %v, want %d, %v", tt.raw, tt.decimals, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMicroCreditsToTokenAmount(t *testing.T) {
+	if got := MicroCreditsToTokenAmount(10_750_000, 6); got != "10750000" {
+		t.Errorf("expected 10750000, got %s", got)
+	}
+	if got := MicroCreditsToTokenAmount(1_500_000, 18); got != "1500000000000000000" {
+		t.Errorf("expected 1500000000000000000, got %s", got)
+	}
+}
+
+func TestVerifyWebhookTimestamp(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	tolerance := 5 * time.Minute
+	fresh := now.Add(-time.Minute).Format(time.RFC3339)
+	stale := now.Add(-10 * time.Minute).Format(time.RFC3339)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		header    string
+		wantErr   bool
+	}{
+		{"fresh, no header", fresh, "", false},
+		{"fresh unix header", fresh, strconv.FormatInt(now.Unix(), 10), false},
+		{"missing timestamp", "", "", true},
+		{"stale timestamp", stale, "", true},
+		{"future timestamp", now.Add(10 * time.Minute).Format(time.RFC3339), "", true},
+		{"stale header", fresh, strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), true},
+		{"garbled header", fresh, "yesterday", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookTimestamp(&WebhookEvent{Timestamp: tt.timestamp}, tt.header, now, tolerance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyWebhookTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package dfns
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 )
 
 // CreateWalletRequest represents a request to create a new wallet
@@ -14,13 +16,14 @@ type CreateWalletRequest struct {
 
 // WalletResponse represents a wallet from the DFNS API
 type WalletResponse struct {
-	ID          string `json:"id"`
-	Network     string `json:"network"`
-	Address     string `json:"address"`
-	Name        string `json:"name,omitempty"`
-	Status      string `json:"status"`
-	DateCreated string `json:"dateCreated"`
-	ExternalID  string `json:"externalId,omitempty"`
+	ID          string   `json:"id"`
+	Network     string   `json:"network"`
+	Address     string   `json:"address"`
+	Name        string   `json:"name,omitempty"`
+	Status      string   `json:"status"` // "Active" or "Archived"
+	DateCreated string   `json:"dateCreated"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
 }
 
 // WalletListResponse represents a list of wallets
@@ -30,10 +33,10 @@ type WalletListResponse struct {
 }
 
 // CreateWallet creates a new MPC wallet on a specific network
-func (c *Client) CreateWallet(req CreateWalletRequest) (*WalletResponse, error) {
+func (c *Client) CreateWallet(ctx context.Context, req CreateWalletRequest) (*WalletResponse, error) {
 	path := "/wallets"
 
-	respBody, err := c.doRequest("POST", path, req)
+	respBody, err := c.doRequest(ctx, "POST", path, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create wallet: %w", err)
 	}
@@ -47,10 +50,10 @@ func (c *Client) CreateWallet(req CreateWalletRequest) (*WalletResponse, error)
 }
 
 // GetWallet retrieves a wallet by its ID
-func (c *Client) GetWallet(walletID string) (*WalletResponse, error) {
+func (c *Client) GetWallet(ctx context.Context, walletID string) (*WalletResponse, error) {
 	path := fmt.Sprintf("/wallets/%s", walletID)
 
-	respBody, err := c.doRequest("GET", path, nil)
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
@@ -64,13 +67,13 @@ func (c *Client) GetWallet(walletID string) (*WalletResponse, error) {
 }
 
 // ListWallets lists all wallets, optionally filtered by network
-func (c *Client) ListWallets(network string) (*WalletListResponse, error) {
+func (c *Client) ListWallets(ctx context.Context, network string) (*WalletListResponse, error) {
 	path := "/wallets"
 	if network != "" {
 		path = fmt.Sprintf("/wallets?network=%s", network)
 	}
 
-	respBody, err := c.doRequest("GET", path, nil)
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list wallets: %w", err)
 	}
@@ -83,11 +86,46 @@ func (c *Client) ListWallets(network string) (*WalletListResponse, error) {
 	return &list, nil
 }
 
+// ListAllWallets lists every wallet in the organization, following the
+// pagination cursor across pages
+func (c *Client) ListAllWallets(ctx context.Context) ([]WalletResponse, error) {
+	var wallets []WalletResponse
+	cursor := ""
+	for {
+		path := "/wallets"
+		if cursor != "" {
+			path = "/wallets?paginationToken=" + url.QueryEscape(cursor)
+		}
+		respBody, err := c.doRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list wallets: %w", err)
+		}
+		var page WalletListResponse
+		if err := json.Unmarshal(respBody, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse wallet list response: %w", err)
+		}
+		wallets = append(wallets, page.Items...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return wallets, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// Ping makes the cheapest authenticated request, listing a single wallet, to
+// check that DFNS is reachable and accepts our credentials
+func (c *Client) Ping(ctx context.Context) error {
+	if _, err := c.doRequest(ctx, "GET", "/wallets?limit=1", nil); err != nil {
+		return fmt.Errorf("DFNS ping failed: %w", err)
+	}
+	return nil
+}
+
 // GetWalletBalance retrieves the balance of a specific asset in a wallet
-func (c *Client) GetWalletBalance(walletID string) (*WalletBalanceResponse, error) {
+func (c *Client) GetWalletBalance(ctx context.Context, walletID string) (*WalletBalanceResponse, error) {
 	path := fmt.Sprintf("/wallets/%s/assets", walletID)
 
-	respBody, err := c.doRequest("GET", path, nil)
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get wallet balance: %w", err)
 	}
@@ -5,7 +5,10 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 )
 
 // Webhook event types
@@ -20,6 +23,9 @@ const (
 	EventTransferConfirmed      = "wallet.transfer.confirmed"
 )
 
+// TransferStatusConfirmed is the transfer status DFNS reports once a transfer is final
+const TransferStatusConfirmed = "Confirmed"
+
 // WebhookEvent represents a webhook event from DFNS
 type WebhookEvent struct {
 	ID        string          `json:"id"`
@@ -45,7 +51,9 @@ type TransferEventData struct {
 	Contract    string `json:"contract,omitempty"`
 	Decimals    int    `json:"decimals,omitempty"`
 	BlockNumber int64  `json:"blockNumber,omitempty"`
+	LogIndex    int64  `json:"logIndex,omitempty"` // Position of the transfer's log within its transaction
 	DateCreated string `json:"dateCreated,omitempty"`
+	ExternalID  string `json:"externalId,omitempty"` // Trace ID we set when initiating the transfer
 }
 
 // WalletEventData represents the data for wallet webhook events
@@ -63,11 +71,57 @@ func VerifyWebhookSignature(payload []byte, signature, secret string) bool {
 		return false
 	}
 
+	expectedMAC := SignWebhookPayload(payload, secret)
+
+	return hmac.Equal([]byte(signature), []byte(expectedMAC))
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 signature DFNS sends in X-DFNS-Signature
+func SignWebhookPayload(payload []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
-	expectedMAC := hex.EncodeToString(mac.Sum(nil))
+	return hex.EncodeToString(mac.Sum(nil))
+}
 
-	return hmac.Equal([]byte(signature), []byte(expectedMAC))
+// TimestampHeader carries a webhook's delivery time, in Unix seconds or RFC 3339
+const TimestampHeader = "X-DFNS-Timestamp"
+
+// ErrWebhookTimestamp is returned for a webhook whose timestamp is missing or
+// outside the tolerance window
+var ErrWebhookTimestamp = errors.New("webhook timestamp missing or outside the tolerance window")
+
+// VerifyWebhookTimestamp checks that the event's signed timestamp, and the
+// timestamp header when sent, are within tolerance of now. Together with a
+// replay cache covering the same window, this stops captured payloads from
+// being replayed.
+func VerifyWebhookTimestamp(event *WebhookEvent, header string, now time.Time, tolerance time.Duration) error {
+	signed, ok := parseWebhookTime(event.Timestamp)
+	if !ok || !withinTolerance(signed, now, tolerance) {
+		return ErrWebhookTimestamp
+	}
+	if header != "" {
+		sent, ok := parseWebhookTime(header)
+		if !ok || !withinTolerance(sent, now, tolerance) {
+			return ErrWebhookTimestamp
+		}
+	}
+	return nil
+}
+
+func parseWebhookTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), true
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+func withinTolerance(t, now time.Time, tolerance time.Duration) bool {
+	d := now.Sub(t)
+	return d <= tolerance && d >= -tolerance
 }
 
 // ParseWebhookEvent parses the raw webhook payload into a WebhookEvent
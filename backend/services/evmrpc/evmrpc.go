@@ -0,0 +1,158 @@
+// Package evmrpc is a minimal Ethereum JSON-RPC client for the few calls the
+// backend makes directly against a chain's RPC node (SupportedChain.RpcURL).
+package evmrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TransferTopic is keccak256("Transfer(address,address,uint256)"), the first
+// topic of every ERC20 Transfer log
+const TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// Client calls JSON-RPC methods on an RPC node
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient creates a JSON-RPC client
+func NewClient() *Client {
+	return &Client{httpClient: &http.Client{}}
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Log is an event log emitted by a transaction
+type Log struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+}
+
+// Receipt is a transaction receipt
+type Receipt struct {
+	Status string `json:"status"` // "0x1" on success
+	Logs   []Log  `json:"logs"`
+}
+
+// LogFilter selects logs for eth_getLogs. Topics entries are either nil (any),
+// a string, or a []string of alternatives.
+type LogFilter struct {
+	FromBlock string        `json:"fromBlock"`
+	ToBlock   string        `json:"toBlock"`
+	Address   []string      `json:"address,omitempty"`
+	Topics    []interface{} `json:"topics,omitempty"`
+}
+
+// Call invokes method on the node at url and decodes its result into out
+func (c *Client) Call(ctx context.Context, url, method string, params []interface{}, out interface{}) error {
+	body, err := json.Marshal(request{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create RPC request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("RPC request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("RPC endpoint returned status %d", resp.StatusCode)
+	}
+
+	var rpcResp response
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("failed to parse RPC response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	if err := json.Unmarshal(rpcResp.Result, out); err != nil {
+		return fmt.Errorf("failed to parse RPC result: %w", err)
+	}
+	return nil
+}
+
+// TransactionReceipt returns the receipt for txHash, or nil if the node has none
+func (c *Client) TransactionReceipt(ctx context.Context, url, txHash string) (*Receipt, error) {
+	var receipt *Receipt
+	if err := c.Call(ctx, url, "eth_getTransactionReceipt", []interface{}{txHash}, &receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// BlockNumber returns the latest block number
+func (c *Client) BlockNumber(ctx context.Context, url string) (uint64, error) {
+	var hex string
+	if err := c.Call(ctx, url, "eth_blockNumber", nil, &hex); err != nil {
+		return 0, err
+	}
+	return ParseQuantity(hex)
+}
+
+// GetLogs returns the logs matching filter
+func (c *Client) GetLogs(ctx context.Context, url string, filter LogFilter) ([]Log, error) {
+	var logs []Log
+	if err := c.Call(ctx, url, "eth_getLogs", []interface{}{filter}, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// Quantity encodes n as a JSON-RPC hex quantity
+func Quantity(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+// ParseQuantity decodes a JSON-RPC hex quantity
+func ParseQuantity(hex string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(hex, "0x"), 16, 64)
+}
+
+// ParseAmount decodes a 32-byte hex log data word as an integer
+func ParseAmount(data string) (*big.Int, bool) {
+	return new(big.Int).SetString(strings.TrimPrefix(data, "0x"), 16)
+}
+
+// AddressTopic pads an address to a 32-byte indexed topic
+func AddressTopic(address string) string {
+	return "0x000000000000000000000000" + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// TopicAddress extracts the address from a 32-byte indexed topic
+func TopicAddress(topic string) string {
+	topic = strings.TrimPrefix(topic, "0x")
+	if len(topic) < 40 {
+		return ""
+	}
+	return "0x" + topic[len(topic)-40:]
+}
@@ -0,0 +1,81 @@
+// Package explorer builds block-explorer links for transactions and addresses
+// from each chain's SupportedChain.ExplorerURL, so clients need no mapping of
+// their own.
+//
+// ExplorerURL is either a base URL, to which the usual paths are appended
+// (/tx/<hash> and /address/<address>, or Tronscan's /#/transaction/ and
+// /#/address/ on TRON), or a template containing {kind} and {id}, where kind
+// is "tx" or "address".
+package explorer
+
+import (
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+)
+
+// Link kinds used in templates
+const (
+	KindTx      = "tx"
+	KindAddress = "address"
+)
+
+// Links builds explorer links for a set of chains
+type Links struct {
+	bases map[string]string // Chain name to ExplorerURL
+}
+
+// New builds links for chains
+func New(chains []models.SupportedChain) *Links {
+	l := &Links{bases: make(map[string]string, len(chains))}
+	for _, chain := range chains {
+		l.bases[chain.Name] = strings.TrimRight(chain.ExplorerURL, "/")
+	}
+	return l
+}
+
+// Load builds links for every supported chain, active or not, since old
+// transactions may be on a chain that has since been disabled
+func Load(db *gorm.DB) (*Links, error) {
+	var chains []models.SupportedChain
+	if err := db.Find(&chains).Error; err != nil {
+		return nil, err
+	}
+	return New(chains), nil
+}
+
+// Tx links a transaction, or returns "" if the chain has no explorer or the hash is empty
+func (l *Links) Tx(chainName, txHash string) string {
+	if dfns.IsTronChain(chainName) {
+		txHash = dfns.TronTxID(txHash)
+	}
+	return l.build(chainName, KindTx, txHash)
+}
+
+// Address links an address, or returns "" if the chain has no explorer or the address is empty
+func (l *Links) Address(chainName, address string) string {
+	return l.build(chainName, KindAddress, address)
+}
+
+func (l *Links) build(chainName, kind, id string) string {
+	if l == nil || id == "" {
+		return ""
+	}
+	base := l.bases[chainName]
+	if base == "" {
+		return ""
+	}
+	if strings.Contains(base, "{id}") {
+		return strings.NewReplacer("{kind}", kind, "{id}", id).Replace(base)
+	}
+	if dfns.IsTronChain(chainName) {
+		if kind == KindTx {
+			return base + "/#/transaction/" + id
+		}
+		return base + "/#/address/" + id
+	}
+	return base + "/" + kind + "/" + id
+}
@@ -0,0 +1,53 @@
+package explorer
+
+import (
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestLinks(t *testing.T) {
+	links := New([]models.SupportedChain{
+		{Name: "ethereum", ExplorerURL: "https://etherscan.io/"},
+		{Name: "tron", ExplorerURL: "https://tronscan.org"},
+		{Name: "custom", ExplorerURL: "https://explorer.example/{kind}/{id}?ref=sp"},
+		{Name: "polygon"},
+	})
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"evm tx", links.Tx("ethereum", "0xabc"), "https://etherscan.io/tx/0xabc"},
+		{"evm address", links.Address("ethereum", "0x1111"), "https://etherscan.io/address/0x1111"},
+		{"tron tx", links.Tx("tron", "0xABC"), "https://tronscan.org/#/transaction/abc"},
+		{"tron address", links.Address("tron", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"), "https://tronscan.org/#/address/TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"},
+		{"template", links.Tx("custom", "0xabc"), "https://explorer.example/tx/0xabc?ref=sp"},
+		{"no explorer", links.Tx("polygon", "0xabc"), ""},
+		{"unknown chain", links.Tx("solana", "0xabc"), ""},
+		{"empty hash", links.Tx("ethereum", ""), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	var none *Links
+	if none.Tx("ethereum", "0xabc") != "" {
+		t.Error("nil Links should build no links")
+	}
+}
+
+func TestLoadUsesSeededExplorers(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	links, err := Load(db)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := links.Tx("base", "0xabc"); got != "https://basescan.org/tx/0xabc" {
+		t.Errorf("base tx link = %q", got)
+	}
+}
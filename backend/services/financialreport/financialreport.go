@@ -0,0 +1,194 @@
+// Package financialreport builds the monthly financial report for accounting:
+// crypto deposits and withdrawals, platform and creator fees, promotional
+// credit issued and the net change in the treasury. Fee and credit figures
+// are aggregated from the ledger; bet fees, which are not booked there, are
+// derived from the month's bets and the current fee schedule.
+package financialreport
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidMonth = errors.New("month must be YYYY-MM and not in the future")
+
+// Line is one figure in the report. Amount is in micro-credits.
+type Line struct {
+	Count  int64 `json:"count"`
+	Amount int64 `json:"amount"`
+}
+
+// LedgerTotal is the month's ledger entries of one type
+type LedgerTotal struct {
+	Type   string `json:"type"`
+	Count  int64  `json:"count"`
+	Amount int64  `json:"amount"` // Signed micro-credits
+}
+
+// Report is a month's financial summary. Amounts are in micro-credits.
+type Report struct {
+	Month string    `json:"month"` // YYYY-MM
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"` // Exclusive
+
+	Deposits    Line `json:"deposits"`    // Completed crypto deposits
+	Withdrawals Line `json:"withdrawals"` // Completed crypto withdrawals
+
+	BetFees          Line  `json:"betFees"`          // Initial, buy and sell fees on the month's bets
+	PlatformFeeShare Line  `json:"platformFeeShare"` // Platform's share of creator fees
+	PlatformFees     int64 `json:"platformFees"`     // Bet fees plus the platform's share of creator fees
+	CreatorFees      Line  `json:"creatorFees"`      // Paid to market creators, net of reversals
+
+	PromotionalCredits Line `json:"promotionalCredits"` // Bonus grants
+	ReferralRewards    Line `json:"referralRewards"`    // Net of reversals
+	ResolutionCosts    Line `json:"resolutionCosts"`    // Net of recoveries; negative is a cost
+
+	NetTreasuryChange int64 `json:"netTreasuryChange"` // Deposits less withdrawals
+
+	Ledger []LedgerTotal `json:"ledger"` // Every ledger entry type booked in the month
+}
+
+// Service builds financial reports
+type Service struct {
+	db             *gorm.DB
+	loadEconConfig setup.EconConfigLoader
+	clock          clock.Clock
+}
+
+// NewService creates a financial report service
+func NewService(db *gorm.DB, loadEconConfig setup.EconConfigLoader, c clock.Clock) *Service {
+	return &Service{db: db, loadEconConfig: loadEconConfig, clock: c}
+}
+
+// ParseMonth returns the start of a YYYY-MM month in UTC. An empty month is
+// last month.
+func (s *Service) ParseMonth(month string) (time.Time, error) {
+	now := s.clock.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month == "" {
+		return thisMonth.AddDate(0, -1, 0), nil
+	}
+	from, err := time.Parse("2006-01", month)
+	if err != nil || from.After(thisMonth) {
+		return time.Time{}, ErrInvalidMonth
+	}
+	return from, nil
+}
+
+// Build reports on the month starting at from
+func (s *Service) Build(from time.Time) (*Report, error) {
+	to := from.AddDate(0, 1, 0)
+	report := &Report{Month: from.Format("2006-01"), From: from, To: to, Ledger: []LedgerTotal{}}
+
+	var err error
+	if report.Deposits, err = s.crypto(models.TxTypeDeposit, from, to); err != nil {
+		return nil, err
+	}
+	if report.Withdrawals, err = s.crypto(models.TxTypeWithdrawal, from, to); err != nil {
+		return nil, err
+	}
+	if report.BetFees, err = s.betFees(from, to); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Model(&models.LedgerEntry{}).
+		Select("type, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("type").Order("type").
+		Scan(&report.Ledger).Error
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]LedgerTotal, len(report.Ledger))
+	for _, t := range report.Ledger {
+		totals[t.Type] = t
+	}
+	sum := func(types ...string) Line {
+		var line Line
+		for _, t := range types {
+			line.Count += totals[t].Count
+			line.Amount += totals[t].Amount
+		}
+		return line
+	}
+	report.PlatformFeeShare = sum(models.LedgerTypePlatformFeeShare)
+	report.CreatorFees = sum(models.LedgerTypeCreatorFee, models.LedgerTypeCreatorFeeReversal)
+	report.PromotionalCredits = sum(models.LedgerTypeBonusGrant)
+	report.ReferralRewards = sum(models.LedgerTypeReferralReward, models.LedgerTypeReferralRewardReversal)
+	report.ResolutionCosts = sum(models.LedgerTypeResolutionCost, models.LedgerTypeResolutionCostRecovery)
+
+	report.PlatformFees = report.BetFees.Amount + report.PlatformFeeShare.Amount
+	report.NetTreasuryChange = report.Deposits.Amount - report.Withdrawals.Amount
+	return report, nil
+}
+
+// crypto totals the completed crypto transactions of a type processed in the month
+func (s *Service) crypto(txType string, from, to time.Time) (Line, error) {
+	var line Line
+	err := s.db.Model(&models.CryptoTransaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount_credits), 0) AS amount").
+		Where("type = ? AND status = ? AND COALESCE(processed_at, created_at) >= ? AND COALESCE(processed_at, created_at) < ?",
+			txType, models.TxStatusCompleted, from, to).
+		Scan(&line).Error
+	return line, err
+}
+
+// betFees derives the fees charged on the month's bets. A user's first bet
+// on a market pays the initial fee on top of the buy or sell fee.
+func (s *Service) betFees(from, to time.Time) (Line, error) {
+	var counts struct {
+		Buys  int64
+		Sells int64
+	}
+	err := s.db.Model(&models.Bet{}).
+		Select("COALESCE(SUM(CASE WHEN amount > 0 THEN 1 ELSE 0 END), 0) AS buys, COALESCE(SUM(CASE WHEN amount < 0 THEN 1 ELSE 0 END), 0) AS sells").
+		Where("placed_at >= ? AND placed_at < ?", from, to).
+		Scan(&counts).Error
+	if err != nil {
+		return Line{}, err
+	}
+	var firsts int64
+	err = s.db.Table("(?) AS firsts",
+		s.db.Model(&models.Bet{}).Select("MIN(placed_at) AS first_at").Group("username, market_id"),
+	).Where("first_at >= ? AND first_at < ?", from, to).Count(&firsts).Error
+	if err != nil {
+		return Line{}, err
+	}
+
+	fees := s.loadEconConfig().Economics.Betting.BetFees
+	total := firsts*fees.InitialBetFee + counts.Buys*fees.BuySharesFee + counts.Sells*fees.SellSharesFee
+	return Line{Count: counts.Buys + counts.Sells, Amount: models.CreditsToMicro(total)}, nil
+}
+
+// WriteCSV writes the report as month,metric,count,amount_micro,amount_credits rows
+func WriteCSV(w io.Writer, report *Report) error {
+	out := csv.NewWriter(w)
+	row := func(metric string, count, amount int64) {
+		out.Write([]string{report.Month, metric, strconv.FormatInt(count, 10), strconv.FormatInt(amount, 10), models.FormatMicroCredits(amount)})
+	}
+	out.Write([]string{"month", "metric", "count", "amount_micro", "amount_credits"})
+	row("deposits", report.Deposits.Count, report.Deposits.Amount)
+	row("withdrawals", report.Withdrawals.Count, report.Withdrawals.Amount)
+	row("bet_fees", report.BetFees.Count, report.BetFees.Amount)
+	row("platform_fee_share", report.PlatformFeeShare.Count, report.PlatformFeeShare.Amount)
+	row("platform_fees", 0, report.PlatformFees)
+	row("creator_fees", report.CreatorFees.Count, report.CreatorFees.Amount)
+	row("promotional_credits", report.PromotionalCredits.Count, report.PromotionalCredits.Amount)
+	row("referral_rewards", report.ReferralRewards.Count, report.ReferralRewards.Amount)
+	row("resolution_costs", report.ResolutionCosts.Count, report.ResolutionCosts.Amount)
+	row("net_treasury_change", 0, report.NetTreasuryChange)
+	for _, t := range report.Ledger {
+		row("ledger:"+t.Type, t.Count, t.Amount)
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,104 @@
+package financialreport
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/setup"
+)
+
+func TestMonthlyReport(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	config := modelstesting.GenerateEconomicConfig()
+	config.Economics.Betting.BetFees = setup.BetFees{InitialBetFee: 1, BuySharesFee: 2, SellSharesFee: 3}
+	svc := NewService(db, func() *setup.EconomicConfig { return config }, clock.NewFake(time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC)))
+
+	from, err := svc.ParseMonth("")
+	if err != nil || from != time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("default month = %v, %v", from, err)
+	}
+	for _, bad := range []string{"2026-7", "2026-07", "May"} {
+		if _, err := svc.ParseMonth(bad); !errors.Is(err, ErrInvalidMonth) {
+			t.Fatalf("ParseMonth(%q) err = %v", bad, err)
+		}
+	}
+
+	may := func(day int) time.Time { return time.Date(2026, 5, day, 9, 0, 0, 0, time.UTC) }
+	june := time.Date(2026, 6, 2, 9, 0, 0, 0, time.UTC)
+
+	for _, tx := range []models.CryptoTransaction{
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, AmountCredits: models.CreditsToMicro(50), ProcessedAt: timePtr(may(3))},
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, AmountCredits: models.CreditsToMicro(25), ProcessedAt: timePtr(may(20))},
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusPending, AmountCredits: models.CreditsToMicro(999)},
+		{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, AmountCredits: models.CreditsToMicro(999), ProcessedAt: &june},
+		{UserID: 1, Type: models.TxTypeWithdrawal, Status: models.TxStatusCompleted, AmountCredits: models.CreditsToMicro(30), ProcessedAt: timePtr(may(25))},
+	} {
+		db.Create(&tx)
+	}
+
+	for _, entry := range []models.LedgerEntry{
+		{UserID: 1, Type: models.LedgerTypeBonusGrant, Amount: models.CreditsToMicro(10)},
+		{UserID: 1, Type: models.LedgerTypeCreatorFee, Amount: models.CreditsToMicro(4)},
+		{UserID: models.PlatformUserID, Type: models.LedgerTypePlatformFeeShare, Amount: models.CreditsToMicro(1)},
+	} {
+		entry.CreatedAt = may(15)
+		db.Create(&entry)
+	}
+	late := models.LedgerEntry{UserID: 1, Type: models.LedgerTypeBonusGrant, Amount: models.CreditsToMicro(10)}
+	late.CreatedAt = june
+	db.Create(&late)
+
+	// alice's first bet on market 1 was in April, so only bob's pays the initial fee in May
+	for _, bet := range []models.Bet{
+		{Username: "alice", MarketID: 1, Amount: 10, Outcome: "YES", PlacedAt: time.Date(2026, 4, 28, 9, 0, 0, 0, time.UTC)},
+		{Username: "alice", MarketID: 1, Amount: 10, Outcome: "YES", PlacedAt: may(2)},
+		{Username: "alice", MarketID: 1, Amount: -5, Outcome: "YES", PlacedAt: may(4)},
+		{Username: "bob", MarketID: 1, Amount: 10, Outcome: "NO", PlacedAt: may(6)},
+	} {
+		db.Create(&bet)
+	}
+
+	report, err := svc.Build(from)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if report.Month != "2026-05" {
+		t.Fatalf("month = %s", report.Month)
+	}
+	if report.Deposits != (Line{Count: 2, Amount: models.CreditsToMicro(75)}) || report.Withdrawals != (Line{Count: 1, Amount: models.CreditsToMicro(30)}) {
+		t.Fatalf("deposits = %+v, withdrawals = %+v", report.Deposits, report.Withdrawals)
+	}
+	if report.NetTreasuryChange != models.CreditsToMicro(45) {
+		t.Fatalf("net treasury change = %d", report.NetTreasuryChange)
+	}
+	// One initial fee, two buys and one sale
+	if report.BetFees != (Line{Count: 3, Amount: models.CreditsToMicro(1 + 2*2 + 3)}) {
+		t.Fatalf("bet fees = %+v", report.BetFees)
+	}
+	if report.PlatformFees != models.CreditsToMicro(9) || report.CreatorFees.Amount != models.CreditsToMicro(4) {
+		t.Fatalf("platform fees = %d, creator fees = %+v", report.PlatformFees, report.CreatorFees)
+	}
+	if report.PromotionalCredits != (Line{Count: 1, Amount: models.CreditsToMicro(10)}) {
+		t.Fatalf("promotional credits = %+v", report.PromotionalCredits)
+	}
+	if len(report.Ledger) != 3 {
+		t.Fatalf("ledger totals = %+v", report.Ledger)
+	}
+
+	var out bytes.Buffer
+	if err := WriteCSV(&out, report); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "month,metric,count,amount_micro,amount_credits\n") ||
+		!strings.Contains(out.String(), "2026-05,deposits,2,75000000,") {
+		t.Fatalf("csv = %s", out.String())
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
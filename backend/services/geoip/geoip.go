@@ -0,0 +1,123 @@
+// Package geoip resolves the coarse location (country and region) a request
+// comes from, for fraud review. It never needs to be precise.
+package geoip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Location is a coarse location. Country is an ISO 3166-1 alpha-2 code;
+// either field may be empty when unknown.
+type Location struct {
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+}
+
+// Locator resolves the location of a client IP. The request headers are
+// passed along for locators that trust a CDN's geolocation headers.
+type Locator interface {
+	Locate(ip string, header http.Header) (Location, error)
+}
+
+// HeaderLocator reads the location from headers set by a trusted proxy or CDN,
+// such as Cloudflare's CF-IPCountry
+type HeaderLocator struct {
+	CountryHeader string
+	RegionHeader  string // Optional
+}
+
+// Locate implements Locator
+func (h HeaderLocator) Locate(ip string, header http.Header) (Location, error) {
+	loc := Location{Country: normalizeCountry(header.Get(h.CountryHeader))}
+	if h.RegionHeader != "" {
+		loc.Region = strings.TrimSpace(header.Get(h.RegionHeader))
+	}
+	return loc, nil
+}
+
+// HTTPLocator queries an ipinfo-style API: GET {baseURL}/{ip} with an
+// Authorization bearer token, answering {"country":"US","region":"California"}
+type HTTPLocator struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewHTTPLocator creates an HTTP locator
+func NewHTTPLocator(baseURL, token string) *HTTPLocator {
+	return &HTTPLocator{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Locate implements Locator. Private and loopback addresses are not looked up.
+func (l *HTTPLocator) Locate(ip string, header http.Header) (Location, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsPrivate() || parsed.IsLoopback() || parsed.IsUnspecified() {
+		return Location{}, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, l.baseURL+"/"+url.PathEscape(ip), nil)
+	if err != nil {
+		return Location{}, fmt.Errorf("failed to create geolocation request: %w", err)
+	}
+	if l.token != "" {
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return Location{}, fmt.Errorf("geolocation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Location{}, fmt.Errorf("geolocation API returned status %d", resp.StatusCode)
+	}
+
+	var body Location
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Location{}, fmt.Errorf("failed to parse geolocation response: %w", err)
+	}
+	body.Country = normalizeCountry(body.Country)
+	return body, nil
+}
+
+// normalizeCountry upper-cases a two-letter country code and drops anything
+// else, including Cloudflare's XX (unknown) and T1 (Tor)
+func normalizeCountry(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code == "XX" || code == "T1" {
+		return ""
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return code
+}
+
+// NewFromEnv builds the configured locator. GEOIP_API_URL and GEOIP_API_TOKEN
+// enable the HTTP locator; otherwise the location is read from the
+// GEOIP_COUNTRY_HEADER (default CF-IPCountry) and GEOIP_REGION_HEADER headers.
+func NewFromEnv() Locator {
+	if apiURL := os.Getenv("GEOIP_API_URL"); apiURL != "" {
+		return NewHTTPLocator(apiURL, os.Getenv("GEOIP_API_TOKEN"))
+	}
+	countryHeader := os.Getenv("GEOIP_COUNTRY_HEADER")
+	if countryHeader == "" {
+		countryHeader = "CF-IPCountry"
+	}
+	return HeaderLocator{CountryHeader: countryHeader, RegionHeader: os.Getenv("GEOIP_REGION_HEADER")}
+}
@@ -0,0 +1,55 @@
+package geoip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderLocator(t *testing.T) {
+	l := HeaderLocator{CountryHeader: "CF-IPCountry", RegionHeader: "CF-Region"}
+	header := http.Header{}
+	header.Set("CF-IPCountry", "de")
+	header.Set("CF-Region", "Bavaria")
+
+	loc, err := l.Locate("203.0.113.7", header)
+	if err != nil || loc != (Location{Country: "DE", Region: "Bavaria"}) {
+		t.Fatalf("Locate = %+v, %v", loc, err)
+	}
+
+	header.Set("CF-IPCountry", "XX")
+	if loc, _ := l.Locate("203.0.113.7", header); loc.Country != "" {
+		t.Errorf("unknown country = %q, want empty", loc.Country)
+	}
+}
+
+func TestHTTPLocator(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/203.0.113.7" {
+			w.Write([]byte(`{"country":"fr","region":"Brittany","city":"Rennes"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	l := NewHTTPLocator(server.URL, "secret")
+	loc, err := l.Locate("203.0.113.7", nil)
+	if err != nil || loc != (Location{Country: "FR", Region: "Brittany"}) {
+		t.Fatalf("Locate = %+v, %v", loc, err)
+	}
+	if _, err := l.Locate("198.51.100.1", nil); err == nil {
+		t.Error("expected an error for a failed lookup")
+	}
+
+	calls = 0
+	if loc, err := l.Locate("10.0.0.1", nil); err != nil || loc != (Location{}) || calls != 0 {
+		t.Errorf("private address = %+v, %v after %d calls", loc, err, calls)
+	}
+}
@@ -0,0 +1,79 @@
+package groups
+
+import (
+	"strings"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// IsMember reports whether the user belongs to the group
+func IsMember(db *gorm.DB, groupID uint, userID int64) (bool, error) {
+	var count int64
+	err := db.Model(&models.GroupMember{}).Where("group_id = ? AND user_id = ?", groupID, userID).Count(&count).Error
+	return count > 0, err
+}
+
+// CanView reports whether a user may see a market. Public and unlisted
+// markets are open to everyone; group-only markets to the group's members
+// and admins. A nil user is an anonymous visitor.
+func CanView(db *gorm.DB, market *models.Market, user *models.User) (bool, error) {
+	if market.Visibility != models.MarketVisibilityGroup {
+		return true, nil
+	}
+	if user == nil || market.GroupID == nil {
+		return false, nil
+	}
+	if user.UserType == "ADMIN" {
+		return true, nil
+	}
+	return IsMember(db, *market.GroupID, user.ID)
+}
+
+// CheckCanBet returns ErrNotMember if the market is group-only and the user
+// is not in its group
+func CheckCanBet(db *gorm.DB, market *models.Market, userID int64) error {
+	if market.Visibility != models.MarketVisibilityGroup {
+		return nil
+	}
+	if market.GroupID == nil {
+		return ErrNotMember
+	}
+	ok, err := IsMember(db, *market.GroupID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// ApplyVisibility validates and normalises a new market's visibility. An
+// empty visibility means public. Group-only markets need a group the
+// creator belongs to; other markets have their group cleared.
+func ApplyVisibility(db *gorm.DB, market *models.Market, creatorID int64) error {
+	market.Visibility = strings.ToUpper(strings.TrimSpace(market.Visibility))
+	switch market.Visibility {
+	case "":
+		market.Visibility = models.MarketVisibilityPublic
+		market.GroupID = nil
+	case models.MarketVisibilityPublic, models.MarketVisibilityUnlisted:
+		market.GroupID = nil
+	case models.MarketVisibilityGroup:
+		if market.GroupID == nil {
+			return ErrGroupRequired
+		}
+		ok, err := IsMember(db, *market.GroupID, creatorID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotMember
+		}
+	default:
+		return ErrInvalidVisibility
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+// Package groups manages private groups and the markets only their members
+// can see. The owner of a group brings people in with invite links, which
+// expire, may be limited to a number of uses and can be revoked. Only a hash
+// of each link's token is stored, so a leaked database does not leak links.
+package groups
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultInviteURL  = "http://localhost/groups/join"
+	defaultInviteTTL  = 72 * time.Hour
+	maxInviteTTL      = 30 * 24 * time.Hour
+	maxNameLength     = 80
+	maxDescriptionLen = 1000
+)
+
+var (
+	ErrGroupNotFound      = errors.New("group not found")
+	ErrNotMember          = errors.New("not a member of this group")
+	ErrNotOwner           = errors.New("only the group owner can do this")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrOwnerCannotLeave   = errors.New("the group owner cannot leave the group")
+	ErrInviteNotFound     = errors.New("invite not found")
+	ErrInvalidInvite      = errors.New("invalid invite link")
+	ErrInviteExpired      = errors.New("invite link has expired")
+	ErrInviteUsedUp       = errors.New("invite link has no uses left")
+	ErrInvalidName        = fmt.Errorf("name must be 1 to %d characters", maxNameLength)
+	ErrInvalidDescription = fmt.Errorf("description must be at most %d characters", maxDescriptionLen)
+	ErrInvalidExpiry      = fmt.Errorf("invite expiry must be between 1 and %d hours", int(maxInviteTTL.Hours()))
+	ErrInvalidMaxUses     = errors.New("max uses cannot be negative")
+	ErrInvalidVisibility  = errors.New("visibility must be PUBLIC, UNLISTED or GROUP")
+	ErrGroupRequired      = errors.New("group-only markets need a groupId")
+)
+
+// Config holds group invite settings
+type Config struct {
+	InviteURL string // Page invite links open; the token is added as ?token=
+}
+
+// LoadConfigFromEnv reads GROUP_INVITE_URL
+func LoadConfigFromEnv() Config {
+	config := Config{InviteURL: defaultInviteURL}
+	if v := os.Getenv("GROUP_INVITE_URL"); v != "" {
+		config.InviteURL = v
+	}
+	return config
+}
+
+// Service manages groups, their members and their invites
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a group service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c}
+}
+
+// CreateInput describes a new group
+type CreateInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// InviteInput describes a new invite link. Zero ExpiresInHours means 72
+// hours; zero MaxUses means any number of uses.
+type InviteInput struct {
+	ExpiresInHours int `json:"expiresInHours"`
+	MaxUses        int `json:"maxUses"`
+}
+
+// Invite is a newly created invite with its link, which is only shown once
+type Invite struct {
+	models.GroupInvite
+	Token string `json:"token"`
+	Link  string `json:"link"`
+}
+
+// Member is a group member as shown to other members
+type Member struct {
+	UserID   int64     `json:"userId"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// Membership is one of the user's groups and their role in it
+type Membership struct {
+	models.Group
+	Role string `json:"role"`
+}
+
+// Detail is a group with its members
+type Detail struct {
+	models.Group
+	Members []Member `json:"members"`
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create makes a group with owner as its first member
+func (s *Service) Create(owner *models.User, in CreateInput) (*models.Group, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > maxNameLength {
+		return nil, ErrInvalidName
+	}
+	if len(in.Description) > maxDescriptionLen {
+		return nil, ErrInvalidDescription
+	}
+	group := models.Group{Name: in.Name, Description: in.Description, OwnerID: owner.ID}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.GroupMember{GroupID: group.ID, UserID: owner.ID, Role: models.GroupRoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ListMine returns the groups the user belongs to
+func (s *Service) ListMine(userID int64) ([]Membership, error) {
+	var list []Membership
+	err := s.db.Model(&models.Group{}).
+		Select("groups.*, group_members.role").
+		Joins("JOIN group_members ON group_members.group_id = groups.id").
+		Where("group_members.user_id = ?", userID).
+		Order("groups.id").
+		Scan(&list).Error
+	return list, err
+}
+
+func (s *Service) group(id uint) (*models.Group, error) {
+	var group models.Group
+	if err := s.db.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// memberGroup returns the group if the user belongs to it. Non-members get
+// ErrGroupNotFound so private groups cannot be probed for.
+func (s *Service) memberGroup(userID int64, id uint) (*models.Group, error) {
+	group, err := s.group(id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := IsMember(s.db, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// ownedGroup returns the group if the user owns it
+func (s *Service) ownedGroup(userID int64, id uint) (*models.Group, error) {
+	group, err := s.memberGroup(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if group.OwnerID != userID {
+		return nil, ErrNotOwner
+	}
+	return group, nil
+}
+
+// Get returns a group and its members to one of its members
+func (s *Service) Get(userID int64, id uint) (*Detail, error) {
+	group, err := s.memberGroup(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	detail := Detail{Group: *group}
+	err = s.db.Table("group_members").
+		Select("group_members.user_id, users.username, group_members.role, group_members.created_at AS joined_at").
+		Joins("JOIN users ON users.id = group_members.user_id").
+		Where("group_members.group_id = ?", id).
+		Order("group_members.id").
+		Scan(&detail.Members).Error
+	if err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// Markets returns a group's markets, newest first, to one of its members
+func (s *Service) Markets(userID int64, id uint) ([]models.Market, error) {
+	if _, err := s.memberGroup(userID, id); err != nil {
+		return nil, err
+	}
+	var markets []models.Market
+	err := s.db.Where("group_id = ? AND visibility = ?", id, models.MarketVisibilityGroup).Order("id DESC").Find(&markets).Error
+	return markets, err
+}
+
+// RemoveMember takes a user out of a group. The owner can remove anyone but
+// themselves; other members can only remove themselves.
+func (s *Service) RemoveMember(actorID int64, id uint, userID int64) error {
+	group, err := s.memberGroup(actorID, id)
+	if err != nil {
+		return err
+	}
+	if userID == group.OwnerID {
+		return ErrOwnerCannotLeave
+	}
+	if actorID != userID && actorID != group.OwnerID {
+		return ErrNotOwner
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("group_id = ? AND user_id = ?", id, userID).Delete(&models.GroupMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMemberNotFound
+		}
+		if actorID == userID {
+			return nil
+		}
+		return notify.Send(tx, userID, notify.TypeGroupRemoved, "Removed from "+group.Name,
+			fmt.Sprintf("You were removed from the group \"%s\" and can no longer see its markets.", group.Name))
+	})
+}
+
+// CreateInvite makes an invite link for a group the user owns
+func (s *Service) CreateInvite(actorID int64, id uint, in InviteInput) (*Invite, error) {
+	if _, err := s.ownedGroup(actorID, id); err != nil {
+		return nil, err
+	}
+	ttl := defaultInviteTTL
+	if in.ExpiresInHours != 0 {
+		ttl = time.Duration(in.ExpiresInHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > maxInviteTTL {
+		return nil, ErrInvalidExpiry
+	}
+	if in.MaxUses < 0 {
+		return nil, ErrInvalidMaxUses
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	invite := Invite{
+		GroupInvite: models.GroupInvite{
+			GroupID:   id,
+			TokenHash: hash(token),
+			CreatedBy: actorID,
+			ExpiresAt: s.clock.Now().Add(ttl),
+			MaxUses:   in.MaxUses,
+		},
+		Token: token,
+		Link:  s.config.InviteURL + "?token=" + url.QueryEscape(token),
+	}
+	if err := s.db.Create(&invite.GroupInvite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListInvites returns a group's invites, newest first, to its owner
+func (s *Service) ListInvites(actorID int64, id uint) ([]models.GroupInvite, error) {
+	if _, err := s.ownedGroup(actorID, id); err != nil {
+		return nil, err
+	}
+	var invites []models.GroupInvite
+	err := s.db.Where("group_id = ?", id).Order("id DESC").Find(&invites).Error
+	return invites, err
+}
+
+// RevokeInvite stops an invite link from working
+func (s *Service) RevokeInvite(actorID int64, id, inviteID uint) error {
+	if _, err := s.ownedGroup(actorID, id); err != nil {
+		return err
+	}
+	result := s.db.Model(&models.GroupInvite{}).
+		Where("id = ? AND group_id = ? AND revoked_at IS NULL", inviteID, id).
+		Update("revoked_at", s.clock.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+// Join adds the user to the group an invite link belongs to. Joining a
+// group the user is already in is a no-op and does not use up the link.
+func (s *Service) Join(userID int64, token string) (*models.Group, error) {
+	var group *models.Group
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var invite models.GroupInvite
+		if err := tx.Where("token_hash = ?", hash(strings.TrimSpace(token))).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidInvite
+			}
+			return err
+		}
+		if invite.RevokedAt != nil {
+			return ErrInvalidInvite
+		}
+		now := s.clock.Now()
+		if !now.Before(invite.ExpiresAt) {
+			return ErrInviteExpired
+		}
+
+		var found models.Group
+		if err := tx.First(&found, invite.GroupID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidInvite
+			}
+			return err
+		}
+		group = &found
+
+		ok, err := IsMember(tx, invite.GroupID, userID)
+		if err != nil || ok {
+			return err
+		}
+
+		// Take a use only if one is left, so concurrent joins cannot overrun the limit
+		result := tx.Model(&models.GroupInvite{}).
+			Where("id = ? AND (max_uses = 0 OR uses < max_uses)", invite.ID).
+			UpdateColumn("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInviteUsedUp
+		}
+		return tx.Create(&models.GroupMember{GroupID: invite.GroupID, UserID: userID, Role: models.GroupRoleMember}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
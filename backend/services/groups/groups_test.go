@@ -0,0 +1,157 @@
+package groups
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestInviteLinks(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	owner := modelstesting.GenerateUser("owner", 0)
+	alice := modelstesting.GenerateUser("alice", 0)
+	bob := modelstesting.GenerateUser("bob", 0)
+	db.Create(&owner)
+	db.Create(&alice)
+	db.Create(&bob)
+	fake := clock.NewFake(time.Date(2026, 5, 14, 9, 0, 0, 0, time.UTC))
+	svc := NewService(db, Config{InviteURL: "https://example.com/join"}, fake)
+
+	group, err := svc.Create(&owner, CreateInput{Name: "Book club"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := svc.CreateInvite(alice.ID, group.ID, InviteInput{}); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("non-member invite err = %v, want ErrGroupNotFound", err)
+	}
+
+	invite, err := svc.CreateInvite(owner.ID, group.ID, InviteInput{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+	if invite.Link != "https://example.com/join?token="+invite.Token || invite.TokenHash == invite.Token {
+		t.Fatalf("invite = %+v", invite)
+	}
+	if !invite.ExpiresAt.Equal(fake.Now().Add(72 * time.Hour)) {
+		t.Fatalf("expires at %v", invite.ExpiresAt)
+	}
+
+	if _, err := svc.Join(alice.ID, invite.Token); err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	// Joining again neither fails nor uses up the link
+	if _, err := svc.Join(alice.ID, invite.Token); err != nil {
+		t.Fatalf("join again: %v", err)
+	}
+	if _, err := svc.Join(bob.ID, invite.Token); !errors.Is(err, ErrInviteUsedUp) {
+		t.Fatalf("used up err = %v", err)
+	}
+	if _, err := svc.Join(bob.ID, "not-a-token"); !errors.Is(err, ErrInvalidInvite) {
+		t.Fatalf("bad token err = %v", err)
+	}
+
+	expiring, err := svc.CreateInvite(owner.ID, group.ID, InviteInput{ExpiresInHours: 1})
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+	fake.Advance(time.Hour)
+	if _, err := svc.Join(bob.ID, expiring.Token); !errors.Is(err, ErrInviteExpired) {
+		t.Fatalf("expired err = %v", err)
+	}
+
+	revoked, err := svc.CreateInvite(owner.ID, group.ID, InviteInput{})
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+	if err := svc.RevokeInvite(owner.ID, group.ID, revoked.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := svc.Join(bob.ID, revoked.Token); !errors.Is(err, ErrInvalidInvite) {
+		t.Fatalf("revoked err = %v", err)
+	}
+
+	detail, err := svc.Get(alice.ID, group.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(detail.Members) != 2 || detail.Members[1].Username != "alice" {
+		t.Fatalf("members = %+v", detail.Members)
+	}
+
+	if err := svc.RemoveMember(alice.ID, group.ID, owner.ID); !errors.Is(err, ErrOwnerCannotLeave) {
+		t.Fatalf("remove owner err = %v", err)
+	}
+	if err := svc.RemoveMember(owner.ID, group.ID, alice.ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := svc.Get(alice.ID, group.ID); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("removed member get err = %v", err)
+	}
+}
+
+func TestMarketVisibility(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	owner := modelstesting.GenerateUser("owner", 0)
+	outsider := modelstesting.GenerateUser("outsider", 0)
+	db.Create(&owner)
+	db.Create(&outsider)
+	svc := NewService(db, Config{}, clock.New())
+	group, err := svc.Create(&owner, CreateInput{Name: "Office pool"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	private := modelstesting.GenerateMarket(1, "owner")
+	private.Visibility = "group"
+	if err := ApplyVisibility(db, &private, owner.ID); !errors.Is(err, ErrGroupRequired) {
+		t.Fatalf("missing group err = %v", err)
+	}
+	private.GroupID = &group.ID
+	if err := ApplyVisibility(db, &private, outsider.ID); !errors.Is(err, ErrNotMember) {
+		t.Fatalf("outsider err = %v", err)
+	}
+	if err := ApplyVisibility(db, &private, owner.ID); err != nil || private.Visibility != models.MarketVisibilityGroup {
+		t.Fatalf("apply: %v, %q", err, private.Visibility)
+	}
+	db.Create(&private)
+
+	unlisted := modelstesting.GenerateMarket(2, "owner")
+	unlisted.Visibility = models.MarketVisibilityUnlisted
+	unlisted.GroupID = &group.ID
+	if err := ApplyVisibility(db, &unlisted, owner.ID); err != nil || unlisted.GroupID != nil {
+		t.Fatalf("unlisted: %v, %v", err, unlisted.GroupID)
+	}
+	db.Create(&unlisted)
+
+	public := modelstesting.GenerateMarket(3, "owner")
+	db.Create(&public)
+
+	var listed []models.Market
+	db.Scopes(models.ListedMarkets).Find(&listed)
+	if len(listed) != 1 || listed[0].ID != 3 {
+		t.Fatalf("listed = %+v", listed)
+	}
+
+	if ok, _ := CanView(db, &private, &outsider); ok {
+		t.Fatal("outsider can view group market")
+	}
+	if ok, _ := CanView(db, &private, nil); ok {
+		t.Fatal("anonymous visitor can view group market")
+	}
+	if ok, _ := CanView(db, &private, &owner); !ok {
+		t.Fatal("member cannot view group market")
+	}
+	if ok, _ := CanView(db, &unlisted, nil); !ok {
+		t.Fatal("anonymous visitor cannot view unlisted market")
+	}
+	if err := CheckCanBet(db, &private, outsider.ID); !errors.Is(err, ErrNotMember) {
+		t.Fatalf("outsider bet err = %v", err)
+	}
+	if err := CheckCanBet(db, &private, owner.ID); err != nil {
+		t.Fatalf("member bet err = %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+// Package health checks the backend's dependencies: the database, each DFNS
+// organization, and how recently each chain's DFNS webhooks were processed.
+package health
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Component and overall statuses
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // Works, but something needs attention
+	StatusDown     = "down"
+	StatusUnknown  = "unknown" // No data yet, e.g. a chain that has never had a webhook
+)
+
+const (
+	defaultWebhookStaleAfter = 6 * time.Hour
+	defaultDFNSTimeout       = 5 * time.Second
+	defaultDFNSCacheFor      = 30 * time.Second
+)
+
+// ErrTimeout is reported for a DFNS org that did not answer in time
+var ErrTimeout = errors.New("timed out")
+
+// Pinger checks that a DFNS org is reachable. *dfns.Client satisfies it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// OrgPingers returns a pinger for each configured DFNS organization
+func OrgPingers(orgs *dfns.Orgs) map[string]Pinger {
+	pingers := map[string]Pinger{}
+	if orgs == nil {
+		return pingers
+	}
+	for _, name := range orgs.Names() {
+		pingers[name] = orgs.Client(name)
+	}
+	return pingers
+}
+
+// Config holds health check settings
+type Config struct {
+	WebhookStaleAfter time.Duration // A chain with no webhook for this long is degraded
+	DFNSTimeout       time.Duration // How long to wait for a DFNS org to answer
+	DFNSCacheFor      time.Duration // How long DFNS results are reused, so probes don't hammer the API
+}
+
+// LoadConfigFromEnv reads HEALTH_WEBHOOK_STALE_AFTER, HEALTH_DFNS_TIMEOUT and HEALTH_DFNS_CACHE_FOR
+func LoadConfigFromEnv() Config {
+	config := Config{
+		WebhookStaleAfter: defaultWebhookStaleAfter,
+		DFNSTimeout:       defaultDFNSTimeout,
+		DFNSCacheFor:      defaultDFNSCacheFor,
+	}
+	if d, err := time.ParseDuration(os.Getenv("HEALTH_WEBHOOK_STALE_AFTER")); err == nil && d > 0 {
+		config.WebhookStaleAfter = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("HEALTH_DFNS_TIMEOUT")); err == nil && d > 0 {
+		config.DFNSTimeout = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("HEALTH_DFNS_CACHE_FOR")); err == nil && d >= 0 {
+		config.DFNSCacheFor = d
+	}
+	return config
+}
+
+// Component is the status of one dependency
+type Component struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DFNSOrg is the status of one DFNS organization
+type DFNSOrg struct {
+	Component
+	Org       string    `json:"org"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// ChainWebhooks reports webhook freshness for one chain
+type ChainWebhooks struct {
+	Component
+	Chain         string     `json:"chain"`
+	LastEventAt   *time.Time `json:"lastEventAt,omitempty"`
+	LastEventKind string     `json:"lastEventKind,omitempty"`
+	SecondsSince  *int64     `json:"secondsSinceLastEvent,omitempty"`
+}
+
+// Report is the result of a health check
+type Report struct {
+	Status    string          `json:"status"`
+	CheckedAt time.Time       `json:"checkedAt"`
+	Database  Component       `json:"database"`
+	DFNS      []DFNSOrg       `json:"dfns"`
+	Webhooks  []ChainWebhooks `json:"webhooks"`
+}
+
+// Ready reports whether the instance can serve traffic. Only the database is
+// required; DFNS or webhook problems degrade wallet features but not the app.
+func (r *Report) Ready() bool {
+	return r.Database.Status == StatusOK
+}
+
+// Service runs health checks
+type Service struct {
+	db      *gorm.DB
+	pingers map[string]Pinger
+	config  Config
+	clock   clock.Clock
+
+	mu         sync.Mutex
+	dfnsCache  []DFNSOrg
+	dfnsCached time.Time
+}
+
+// NewService creates a health check service
+func NewService(db *gorm.DB, pingers map[string]Pinger, config Config, c clock.Clock) *Service {
+	return &Service{db: db, pingers: pingers, config: config, clock: c}
+}
+
+// RecordWebhook notes that a webhook for chainName was processed at now
+func RecordWebhook(db *gorm.DB, chainName, kind, eventID string, now time.Time) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_event_at", "last_event_kind", "last_event_id"}),
+	}).Create(&models.WebhookHeartbeat{
+		ChainName:     chainName,
+		LastEventAt:   now,
+		LastEventKind: kind,
+		LastEventID:   eventID,
+	}).Error
+}
+
+// Check runs every health check. The overall status is down when the
+// database is unreachable, degraded when a DFNS org is down or a chain's
+// webhooks are stale, and ok otherwise.
+func (s *Service) Check(ctx context.Context) *Report {
+	now := s.clock.Now()
+	report := &Report{Status: StatusOK, CheckedAt: now, Database: s.checkDatabase(ctx)}
+	report.DFNS = s.checkDFNS(ctx, now)
+	if report.Database.Status == StatusOK {
+		report.Webhooks = s.checkWebhooks(now)
+	}
+
+	if report.Database.Status != StatusOK {
+		report.Status = StatusDown
+		return report
+	}
+	for _, org := range report.DFNS {
+		if org.Status != StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	for _, chain := range report.Webhooks {
+		if chain.Status == StatusDegraded {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (s *Service) checkDatabase(ctx context.Context) Component {
+	sqlDB, err := s.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		return Component{Status: StatusDown, Error: err.Error()}
+	}
+	return Component{Status: StatusOK}
+}
+
+// checkDFNS pings every org concurrently, reusing recent results. The results
+// are shared between callers, so one caller going away does not cut them short.
+func (s *Service) checkDFNS(ctx context.Context, now time.Time) []DFNSOrg {
+	ctx = context.WithoutCancel(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dfnsCache != nil && now.Sub(s.dfnsCached) < s.config.DFNSCacheFor {
+		return s.dfnsCache
+	}
+
+	names := make([]string, 0, len(s.pingers))
+	for name := range s.pingers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]DFNSOrg, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = DFNSOrg{Org: name, CheckedAt: now, Component: s.ping(ctx, s.pingers[name])}
+		}(i, name)
+	}
+	wg.Wait()
+
+	s.dfnsCache, s.dfnsCached = results, now
+	return results
+}
+
+func (s *Service) ping(ctx context.Context, p Pinger) Component {
+	ctx, cancel := context.WithTimeout(ctx, s.config.DFNSTimeout)
+	defer cancel()
+	if err := p.Ping(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return Component{Status: StatusDown, Error: ErrTimeout.Error()}
+		}
+		return Component{Status: StatusDown, Error: err.Error()}
+	}
+	return Component{Status: StatusOK}
+}
+
+// checkWebhooks reports time since the last webhook for each active chain
+func (s *Service) checkWebhooks(now time.Time) []ChainWebhooks {
+	var chains []models.SupportedChain
+	if err := s.db.Where("is_active = ?", true).Order("name").Find(&chains).Error; err != nil {
+		return []ChainWebhooks{{Component: Component{Status: StatusUnknown, Error: err.Error()}}}
+	}
+	var beats []models.WebhookHeartbeat
+	if err := s.db.Find(&beats).Error; err != nil {
+		return []ChainWebhooks{{Component: Component{Status: StatusUnknown, Error: err.Error()}}}
+	}
+	byChain := make(map[string]models.WebhookHeartbeat, len(beats))
+	for _, beat := range beats {
+		byChain[beat.ChainName] = beat
+	}
+
+	results := make([]ChainWebhooks, 0, len(chains))
+	for _, chain := range chains {
+		result := ChainWebhooks{Chain: chain.Name, Component: Component{Status: StatusUnknown}}
+		if beat, ok := byChain[chain.Name]; ok {
+			last := beat.LastEventAt
+			since := int64(now.Sub(last) / time.Second)
+			result.LastEventAt, result.LastEventKind, result.SecondsSince = &last, beat.LastEventKind, &since
+			result.Status = StatusOK
+			if now.Sub(last) > s.config.WebhookStaleAfter {
+				result.Status = StatusDegraded
+				result.Error = "no webhook processed within " + s.config.WebhookStaleAfter.String()
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,164 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+type fakePinger struct {
+	err   error
+	calls int
+}
+
+func (f *fakePinger) Ping(context.Context) error {
+	f.calls++
+	return f.err
+}
+
+func newTestService(t *testing.T, pingers map[string]Pinger) (*Service, *gorm.DB, *clock.Fake) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	// Only ethereum and tron are active
+	if err := db.Model(&models.SupportedChain{}).Where("1 = 1").Update("is_active", false).Error; err != nil {
+		t.Fatalf("deactivate chains: %v", err)
+	}
+	for _, chain := range []models.SupportedChain{
+		{ChainID: 1, Name: "ethereum", DisplayName: "Ethereum"},
+		{ChainID: 728126428, Name: "tron", DisplayName: "Tron"},
+	} {
+		if err := db.Where(models.SupportedChain{ChainID: chain.ChainID}).FirstOrCreate(&chain).Error; err != nil {
+			t.Fatalf("create chain: %v", err)
+		}
+		if err := db.Model(&chain).Update("is_active", true).Error; err != nil {
+			t.Fatalf("activate chain: %v", err)
+		}
+	}
+	fake := clock.NewFake(time.Date(2026, 3, 13, 12, 0, 0, 0, time.UTC))
+	config := Config{WebhookStaleAfter: time.Hour, DFNSTimeout: time.Second, DFNSCacheFor: 30 * time.Second}
+	return NewService(db, pingers, config, fake), db, fake
+}
+
+func webhooksByChain(report *Report) map[string]ChainWebhooks {
+	out := map[string]ChainWebhooks{}
+	for _, w := range report.Webhooks {
+		out[w.Chain] = w
+	}
+	return out
+}
+
+func TestCheckHealthy(t *testing.T) {
+	svc, db, fake := newTestService(t, map[string]Pinger{"primary": &fakePinger{}})
+	for _, chain := range []string{"ethereum", "tron"} {
+		if err := RecordWebhook(db, chain, "wallet.transfer.inbound", "evt-1", fake.Now()); err != nil {
+			t.Fatalf("RecordWebhook: %v", err)
+		}
+	}
+
+	report := svc.Check(context.Background())
+	if report.Status != StatusOK || !report.Ready() {
+		t.Fatalf("status = %s, ready = %v, want ok and ready", report.Status, report.Ready())
+	}
+	if len(report.DFNS) != 1 || report.DFNS[0].Status != StatusOK {
+		t.Errorf("dfns = %+v", report.DFNS)
+	}
+}
+
+func TestCheckFlagsStaleWebhooks(t *testing.T) {
+	svc, db, fake := newTestService(t, nil)
+	if err := RecordWebhook(db, "ethereum", "wallet.transfer.inbound", "evt-1", fake.Now()); err != nil {
+		t.Fatalf("RecordWebhook: %v", err)
+	}
+	fake.Advance(2 * time.Hour)
+	// A later event for the other chain keeps it fresh
+	if err := RecordWebhook(db, "tron", "wallet.transfer.completed", "evt-2", fake.Now()); err != nil {
+		t.Fatalf("RecordWebhook: %v", err)
+	}
+
+	report := svc.Check(context.Background())
+	if report.Status != StatusDegraded {
+		t.Fatalf("status = %s, want degraded", report.Status)
+	}
+	if !report.Ready() {
+		t.Error("stale webhooks should not make the instance unready")
+	}
+	chains := webhooksByChain(report)
+	if eth := chains["ethereum"]; eth.Status != StatusDegraded || eth.SecondsSince == nil || *eth.SecondsSince != 7200 {
+		t.Errorf("ethereum = %+v", eth)
+	}
+	if tron := chains["tron"]; tron.Status != StatusOK || tron.LastEventKind != "wallet.transfer.completed" {
+		t.Errorf("tron = %+v", tron)
+	}
+}
+
+func TestCheckUnknownWithoutWebhooks(t *testing.T) {
+	svc, _, _ := newTestService(t, nil)
+
+	report := svc.Check(context.Background())
+	if report.Status != StatusOK {
+		t.Errorf("status = %s, want ok for chains with no webhooks yet", report.Status)
+	}
+	for _, chain := range report.Webhooks {
+		if chain.Status != StatusUnknown {
+			t.Errorf("%s status = %s, want unknown", chain.Chain, chain.Status)
+		}
+	}
+}
+
+func TestCheckDFNSDownAndCached(t *testing.T) {
+	pinger := &fakePinger{err: errors.New("connection refused")}
+	svc, _, fake := newTestService(t, map[string]Pinger{"primary": pinger})
+
+	report := svc.Check(context.Background())
+	if report.Status != StatusDegraded || report.DFNS[0].Status != StatusDown {
+		t.Fatalf("status = %s, dfns = %+v", report.Status, report.DFNS)
+	}
+
+	svc.Check(context.Background())
+	if pinger.calls != 1 {
+		t.Errorf("calls = %d, want DFNS result reused within the cache window", pinger.calls)
+	}
+	fake.Advance(time.Minute)
+	svc.Check(context.Background())
+	if pinger.calls != 2 {
+		t.Errorf("calls = %d, want DFNS pinged again after the cache window", pinger.calls)
+	}
+}
+
+func TestCheckDatabaseDown(t *testing.T) {
+	svc, db, _ := newTestService(t, nil)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+	sqlDB.Close()
+
+	report := svc.Check(context.Background())
+	if report.Status != StatusDown || report.Ready() {
+		t.Errorf("status = %s, ready = %v, want down and not ready", report.Status, report.Ready())
+	}
+}
+
+type blockingPinger struct{}
+
+func (blockingPinger) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCheckDFNSTimeout(t *testing.T) {
+	svc, _, _ := newTestService(t, map[string]Pinger{"primary": blockingPinger{}})
+	svc.config.DFNSTimeout = 10 * time.Millisecond
+
+	report := svc.Check(context.Background())
+	if org := report.DFNS[0]; org.Status != StatusDown || org.Error != ErrTimeout.Error() {
+		t.Errorf("dfns = %+v, want down with timeout", org)
+	}
+}
@@ -0,0 +1,430 @@
+// Package housebot runs the house liquidity bot. A dedicated account, funded
+// with platform credits, rests buy orders on both sides of new markets so
+// early traders have something to trade against. What it bids is set per
+// market category and bounded per market. The bot halts itself once it has
+// lost a configured amount of its funding, and admins can halt it at any time.
+package housebot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/betlimits"
+	"socialpredict/services/housemm"
+	"socialpredict/services/ledger"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/orders"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions
+const (
+	ActionHalted          = "HOUSE_BOT_HALTED"
+	ActionResumed         = "HOUSE_BOT_RESUMED"
+	ActionFunded          = "HOUSE_BOT_FUNDED"
+	ActionCategoryUpdated = "HOUSE_BOT_CATEGORY_UPDATED"
+)
+
+// Actor is recorded as the actor when the bot halts itself
+const Actor = "house-bot"
+
+const (
+	defaultMaxLoss         = 1000 // Credits
+	defaultNewMarketWindow = 24 * time.Hour
+
+	// maxSpread keeps both bids below an even market's price
+	maxSpread = 0.5
+)
+
+var (
+	ErrNotConfigured   = errors.New("house bot account is not configured")
+	ErrInvalidAmount   = errors.New("amount must be positive")
+	ErrReasonRequired  = errors.New("a reason is required")
+	ErrInvalidCategory = errors.New("order size and max exposure must be 0 or positive, spread between 0 and 0.5, and an enabled category needs an order size")
+)
+
+// Config identifies the bot account and bounds its losses
+type Config struct {
+	Username        string
+	MaxLoss         int64         // Micro-credits of its funding the bot may lose before halting itself; 0 for no limit
+	NewMarketWindow time.Duration // Markets older than this are not seeded
+}
+
+// LoadConfigFromEnv reads HOUSE_BOT_USERNAME, HOUSE_BOT_MAX_LOSS (credits,
+// default 1000) and HOUSE_BOT_NEW_MARKET_WINDOW (default 24h)
+func LoadConfigFromEnv() Config {
+	config := Config{
+		Username:        os.Getenv("HOUSE_BOT_USERNAME"),
+		MaxLoss:         models.CreditsToMicro(defaultMaxLoss),
+		NewMarketWindow: defaultNewMarketWindow,
+	}
+	if v := os.Getenv("HOUSE_BOT_MAX_LOSS"); v != "" {
+		if parsed, err := models.ParseCredits(v); err == nil && parsed >= 0 {
+			config.MaxLoss = parsed
+		}
+	}
+	if d, err := time.ParseDuration(os.Getenv("HOUSE_BOT_NEW_MARKET_WINDOW")); err == nil && d > 0 {
+		config.NewMarketWindow = d
+	}
+	return config
+}
+
+// Status reports whether the bot is running and how its funding has fared.
+// Amounts are in micro-credits.
+type Status struct {
+	Username      string                    `json:"username"`
+	Halted        bool                      `json:"halted"`
+	Reason        string                    `json:"reason,omitempty"`
+	UpdatedBy     string                    `json:"updatedBy,omitempty"`
+	UpdatedAt     *time.Time                `json:"updatedAt,omitempty"`
+	Funded        int64                     `json:"funded"`        // Platform credits allocated to the bot
+	Balance       int64                     `json:"balance"`       // Bot's current balance
+	PositionValue int64                     `json:"positionValue"` // Value of its positions in unresolved markets
+	Loss          int64                     `json:"loss"`          // Funded less balance and position value; negative is a profit
+	MaxLoss       int64                     `json:"maxLoss"`
+	OpenOrders    int64                     `json:"openOrders"`
+	Categories    []models.HouseBotCategory `json:"categories"`
+}
+
+// Service runs the bot
+type Service struct {
+	db     *gorm.DB
+	config Config
+	book   *orders.Service
+	clock  clock.Clock
+}
+
+// NewService creates the house bot service. The bot places its orders through book.
+func NewService(db *gorm.DB, config Config, book *orders.Service, c clock.Clock) *Service {
+	return &Service{db: db, config: config, book: book, clock: c}
+}
+
+// Enabled reports whether a bot account is configured
+func (s *Service) Enabled() bool {
+	return s.config.Username != ""
+}
+
+func (s *Service) bot() (*models.User, error) {
+	if !s.Enabled() {
+		return nil, ErrNotConfigured
+	}
+	var bot models.User
+	if err := s.db.Where("username = ?", s.config.Username).First(&bot).Error; err != nil {
+		return nil, err
+	}
+	return &bot, nil
+}
+
+// Status returns the bot's kill switch, funding, P&L and category settings
+func (s *Service) Status() (*Status, error) {
+	bot, err := s.bot()
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Username: bot.Username, Balance: bot.BalanceMicroCredits(), MaxLoss: s.config.MaxLoss}
+	if err := s.readHalt(status); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type = ?", bot.ID, models.LedgerTypeHouseBotFunding).
+		Select("COALESCE(SUM(amount), 0)").Scan(&status.Funded).Error; err != nil {
+		return nil, err
+	}
+	report, err := housemm.NewService(s.db, housemm.Config{Username: bot.Username}, s.clock).Exposure()
+	if err != nil {
+		return nil, err
+	}
+	status.PositionValue = models.CreditsToMicro(report.GrossExposure)
+	status.Loss = status.Funded - status.Balance - status.PositionValue
+
+	if err := s.db.Model(&models.MarketOrder{}).Where("user_id = ? AND status = ?", bot.ID, models.OrderStatusOpen).
+		Count(&status.OpenOrders).Error; err != nil {
+		return nil, err
+	}
+	if status.Categories, err = s.Categories(); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// readHalt reads the kill switch straight from the database. Like the
+// withdrawal freeze it is never cached, so a halt applies on every instance at once.
+func (s *Service) readHalt(status *Status) error {
+	var rows []models.PlatformSetting
+	if err := s.db.Where("key IN ?", []string{models.SettingHouseBotHalted, models.SettingHouseBotHaltReason}).
+		Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		switch row.Key {
+		case models.SettingHouseBotHalted:
+			status.Halted, _ = strconv.ParseBool(row.Value)
+			status.UpdatedBy = row.UpdatedBy
+			updatedAt := row.UpdatedAt
+			status.UpdatedAt = &updatedAt
+		case models.SettingHouseBotHaltReason:
+			status.Reason = row.Value
+		}
+	}
+	return nil
+}
+
+// SetHalted throws or resets the kill switch and audits the change.
+// Halting cancels the bot's open orders.
+func (s *Service) SetHalted(halted bool, reason, actor string) (*Status, error) {
+	bot, err := s.bot()
+	if err != nil {
+		return nil, err
+	}
+	action := ActionResumed
+	if halted {
+		action = ActionHalted
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for key, value := range map[string]string{
+			models.SettingHouseBotHalted:     strconv.FormatBool(halted),
+			models.SettingHouseBotHaltReason: reason,
+		} {
+			setting := models.PlatformSetting{Key: key, Value: value, UpdatedBy: actor}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}).Create(&setting).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     action,
+			TargetType: "platform_settings",
+			Details:    reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if halted {
+		cancelled, err := s.book.CancelAll(bot.ID, "House bot halted: "+reason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cancel bot orders: %w", err)
+		}
+		log.Printf("House bot: halted by %s, %d orders cancelled: %s", actor, cancelled, reason)
+	}
+	return s.Status()
+}
+
+// Fund moves amount micro-credits from the platform to the bot account
+func (s *Service) Fund(amount int64, reason, actor string) (*models.LedgerEntry, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	bot, err := s.bot()
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *models.LedgerEntry
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		locked, err := ledger.LockUser(tx, bot.ID)
+		if err != nil {
+			return err
+		}
+		posting := ledger.Posting{
+			Type:          models.LedgerTypeHouseBotFunding,
+			Amount:        amount,
+			ReferenceType: "user",
+			ReferenceID:   uint(bot.ID),
+			Description:   reason,
+		}
+		if entry, err = ledger.Apply(tx, locked, posting); err != nil {
+			return err
+		}
+		posting.Amount = -amount
+		if _, err := ledger.RecordPlatform(tx, posting); err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionFunded,
+			TargetType: "user",
+			TargetID:   uint(bot.ID),
+			Details:    fmt.Sprintf("amount=%s reason=%s", models.FormatMicroCredits(amount), reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Categories lists the bot's per-category settings, the default first
+func (s *Service) Categories() ([]models.HouseBotCategory, error) {
+	categories := []models.HouseBotCategory{}
+	err := s.db.Order("category").Find(&categories).Error
+	return categories, err
+}
+
+// SetCategory creates or replaces the bot's settings for category.Category
+// ("" for the default) and audits the change
+func (s *Service) SetCategory(category models.HouseBotCategory, actor string) (*models.HouseBotCategory, error) {
+	category.Category = strings.ToLower(strings.TrimSpace(category.Category))
+	if category.OrderSize < 0 || category.MaxExposure < 0 || category.Spread < 0 || category.Spread >= maxSpread ||
+		(category.Enabled && category.OrderSize < 1) {
+		return nil, ErrInvalidCategory
+	}
+	category.UpdatedBy = actor
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "category"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled", "order_size", "spread", "max_exposure", "updated_by", "updated_at"}),
+		}).Create(&category).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("category = ?", category.Category).First(&category).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionCategoryUpdated,
+			TargetType: "house_bot_category",
+			TargetID:   category.ID,
+			Details: fmt.Sprintf("category=%q enabled=%t orderSize=%d spread=%g maxExposure=%d",
+				category.Category, category.Enabled, category.OrderSize, category.Spread, category.MaxExposure),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// settingsFor returns the enabled settings for the market's category, falling
+// back to the default, or nil if the bot should leave the market alone
+func settingsFor(categories []models.HouseBotCategory, market *models.Market) *models.HouseBotCategory {
+	var fallback *models.HouseBotCategory
+	for i := range categories {
+		switch categories[i].Category {
+		case market.Category:
+			if categories[i].Enabled {
+				return &categories[i]
+			}
+			return nil
+		case "":
+			fallback = &categories[i]
+		}
+	}
+	if fallback != nil && fallback.Enabled {
+		return fallback
+	}
+	return nil
+}
+
+// RunOnce checks the loss limit and then seeds new markets the bot has not
+// bid on yet. It returns the number of orders placed.
+func (s *Service) RunOnce() (int, error) {
+	status, err := s.Status()
+	if err != nil {
+		return 0, err
+	}
+	if status.Halted {
+		return 0, nil
+	}
+	if s.config.MaxLoss > 0 && status.Loss >= s.config.MaxLoss {
+		reason := fmt.Sprintf("loss of %s reached the %s limit", models.FormatMicroCredits(status.Loss), models.FormatMicroCredits(s.config.MaxLoss))
+		_, err := s.SetHalted(true, reason, Actor)
+		return 0, err
+	}
+
+	bot, err := s.bot()
+	if err != nil {
+		return 0, err
+	}
+	now := s.clock.Now()
+	var markets []models.Market
+	if err := s.db.Where("created_at >= ? AND resolution_date_time > ? AND is_resolved = ? AND closed_at IS NULL AND voided_at IS NULL",
+		now.Add(-s.config.NewMarketWindow), now, false).
+		Where("outcome_type = ? AND visibility = ?", models.OutcomeTypeBinary, "PUBLIC").
+		Where("id NOT IN (?)", s.db.Model(&models.MarketOrder{}).Select("market_id").Where("user_id = ?", bot.ID)).
+		Order("id").Find(&markets).Error; err != nil {
+		return 0, err
+	}
+
+	placed := 0
+	for i := range markets {
+		market := &markets[i]
+		settings := settingsFor(status.Categories, market)
+		if settings == nil || market.CreatorUsername == bot.Username {
+			continue
+		}
+		placed += s.seed(bot, market, settings)
+	}
+	return placed, nil
+}
+
+// seed rests a bid on each side of the market, settings.Spread below its
+// price and within settings.MaxExposure, and returns how many were placed
+func (s *Service) seed(bot *models.User, market *models.Market, settings *models.HouseBotCategory) int {
+	size := settings.OrderSize
+	if settings.MaxExposure > 0 {
+		staked, err := betlimits.Exposure(s.db, bot.Username, market.ID)
+		if err != nil {
+			log.Printf("House bot: exposure on market %d: %v", market.ID, err)
+			return 0
+		}
+		size = min(size, (settings.MaxExposure-staked)/2)
+	}
+	if size < 1 {
+		return 0
+	}
+
+	probability := liquidity.CurrentProbability(s.db, market)
+	placed := 0
+	for _, side := range []struct {
+		outcome string
+		price   float64
+	}{{"YES", probability}, {"NO", 1 - probability}} {
+		outcome := side.outcome
+		// Rounded down to the cent, allowing for float error in the subtraction
+		limit := math.Floor((side.price-settings.Spread)*100+1e-9) / 100
+		if limit < 0.01 {
+			continue
+		}
+		_, err := s.book.Place(bot.ID, market.ID, orders.PlaceInput{Side: models.OrderSideBuy, Outcome: outcome, LimitPrice: limit, Amount: size})
+		if err != nil {
+			log.Printf("House bot: bid on %s in market %d failed: %v", outcome, market.ID, err)
+			continue
+		}
+		placed++
+	}
+	return placed
+}
+
+// Run seeds new markets every interval. It never returns.
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.RunOnce()
+		if err != nil {
+			log.Printf("House bot: run failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("House bot: placed %d orders", n)
+		}
+	}
+}
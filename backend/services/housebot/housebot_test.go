@@ -0,0 +1,163 @@
+package housebot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/orders"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+func setupBot(t *testing.T) (*gorm.DB, *clock.Fake, *Service, *models.User) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	bot := modelstesting.GenerateUser("housebot", 0)
+	if err := db.Create(&bot).Error; err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+	config := Config{Username: "housebot", MaxLoss: models.CreditsToMicro(100), NewMarketWindow: 24 * time.Hour}
+	svc := NewService(db, config, orders.NewService(db, setup.EconomicsConfig, fake), fake)
+	return db, fake, svc, &bot
+}
+
+func market(t *testing.T, db *gorm.DB, id int64, category string, age time.Duration, now time.Time) {
+	t.Helper()
+	m := modelstesting.GenerateMarket(id, "creator")
+	m.Category = category
+	m.CreatedAt = now.Add(-age)
+	if err := db.Create(&m).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+}
+
+func botOrders(t *testing.T, db *gorm.DB, bot *models.User, status string) []models.MarketOrder {
+	t.Helper()
+	var list []models.MarketOrder
+	if err := db.Where("user_id = ? AND status = ?", bot.ID, status).Order("market_id, outcome DESC").Find(&list).Error; err != nil {
+		t.Fatalf("load orders: %v", err)
+	}
+	return list
+}
+
+func TestBotSeedsNewMarketsPerCategory(t *testing.T) {
+	db, fake, bot, user := setupBot(t)
+	if _, err := bot.Fund(models.CreditsToMicro(500), "initial allocation", "admin"); err != nil {
+		t.Fatalf("Fund: %v", err)
+	}
+	if _, err := bot.SetCategory(models.HouseBotCategory{Enabled: true, OrderSize: 50, Spread: 0.05, MaxExposure: 60}, "admin"); err != nil {
+		t.Fatalf("SetCategory default: %v", err)
+	}
+	if _, err := bot.SetCategory(models.HouseBotCategory{Category: " Sports "}, "admin"); err != nil {
+		t.Fatalf("SetCategory sports: %v", err)
+	}
+
+	market(t, db, 1, "", time.Hour, fake.Now())
+	market(t, db, 2, "sports", time.Hour, fake.Now())
+	market(t, db, 3, "politics", time.Hour, fake.Now())
+	market(t, db, 4, "", 48*time.Hour, fake.Now())
+
+	placed, err := bot.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if placed != 4 {
+		t.Fatalf("placed %d orders, want a bid on each side of markets 1 and 3", placed)
+	}
+	open := botOrders(t, db, user, models.OrderStatusOpen)
+	for _, o := range open {
+		if o.MarketID == 2 || o.MarketID == 4 {
+			t.Errorf("bid on market %d, which is disabled or too old", o.MarketID)
+		}
+		// Bounded by half the 60 credit exposure, 5 cents under the even price
+		if o.Side != models.OrderSideBuy || o.Amount != 30 || o.LimitPrice != 0.45 {
+			t.Errorf("order = %+v, want a 30 credit bid at 0.45", o)
+		}
+	}
+
+	if placed, _ := bot.RunOnce(); placed != 0 {
+		t.Errorf("second run placed %d orders, want markets seeded once", placed)
+	}
+}
+
+func TestKillSwitchCancelsOrders(t *testing.T) {
+	db, fake, bot, user := setupBot(t)
+	bot.Fund(models.CreditsToMicro(500), "initial allocation", "admin")
+	bot.SetCategory(models.HouseBotCategory{Enabled: true, OrderSize: 10, Spread: 0.1}, "admin")
+	market(t, db, 1, "", time.Hour, fake.Now())
+	bot.RunOnce()
+
+	status, err := bot.SetHalted(true, "bad prices", "admin")
+	if err != nil {
+		t.Fatalf("SetHalted: %v", err)
+	}
+	if !status.Halted || status.Reason != "bad prices" || status.OpenOrders != 0 {
+		t.Fatalf("status = %+v, want halted with no open orders", status)
+	}
+	if cancelled := botOrders(t, db, user, models.OrderStatusCancelled); len(cancelled) != 2 {
+		t.Fatalf("cancelled %d orders, want 2", len(cancelled))
+	}
+
+	market(t, db, 2, "", time.Hour, fake.Now())
+	if placed, _ := bot.RunOnce(); placed != 0 {
+		t.Errorf("halted bot placed %d orders", placed)
+	}
+	bot.SetHalted(false, "", "admin")
+	if placed, _ := bot.RunOnce(); placed != 2 {
+		t.Errorf("resumed bot placed %d orders, want 2 on the new market", placed)
+	}
+}
+
+func TestLossLimitHaltsBot(t *testing.T) {
+	db, fake, bot, user := setupBot(t)
+	bot.Fund(models.CreditsToMicro(500), "initial allocation", "admin")
+	bot.SetCategory(models.HouseBotCategory{Enabled: true, OrderSize: 10, Spread: 0.1}, "admin")
+	market(t, db, 1, "", time.Hour, fake.Now())
+	bot.RunOnce()
+
+	// 100 credits of the funding lost
+	db.Model(&models.User{}).Where("id = ?", user.ID).Update("account_balance", models.CreditsToMicro(400))
+
+	market(t, db, 2, "", time.Hour, fake.Now())
+	if placed, err := bot.RunOnce(); err != nil || placed != 0 {
+		t.Fatalf("RunOnce = %d, %v; want the bot to halt instead", placed, err)
+	}
+	status, _ := bot.Status()
+	if !status.Halted || status.UpdatedBy != Actor || status.Loss != models.CreditsToMicro(100) || status.OpenOrders != 0 {
+		t.Fatalf("status = %+v, want halted by the bot at a 100 credit loss", status)
+	}
+}
+
+func TestFundingComesFromThePlatform(t *testing.T) {
+	db, _, bot, _ := setupBot(t)
+	if _, err := bot.Fund(models.CreditsToMicro(5), "", "admin"); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("Fund without reason = %v, want ErrReasonRequired", err)
+	}
+	if _, err := bot.Fund(0, "nothing", "admin"); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Fund zero = %v, want ErrInvalidAmount", err)
+	}
+	if _, err := bot.Fund(models.CreditsToMicro(250), "allocation", "admin"); err != nil {
+		t.Fatalf("Fund: %v", err)
+	}
+
+	var platform int64
+	db.Model(&models.LedgerEntry{}).Where("user_id = ? AND type = ?", models.PlatformUserID, models.LedgerTypeHouseBotFunding).
+		Select("COALESCE(SUM(amount), 0)").Scan(&platform)
+	if platform != -models.CreditsToMicro(250) {
+		t.Errorf("platform ledger = %d, want -250 credits", platform)
+	}
+	status, _ := bot.Status()
+	if status.Funded != models.CreditsToMicro(250) || status.Balance != status.Funded || status.Loss != 0 {
+		t.Errorf("status = %+v, want 250 funded and no loss", status)
+	}
+
+	if _, err := NewService(db, Config{}, nil, clock.New()).Status(); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("unconfigured Status = %v, want ErrNotConfigured", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package housemm reports the inventory, P&L and per-market exposure of the
+// house market-making account and keeps historical snapshots of it.
+package housemm
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"socialpredict/clock"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotConfigured is returned when no house account has been designated
+var ErrNotConfigured = errors.New("house market maker account is not configured")
+
+// Config identifies the house market maker account
+type Config struct {
+	Username string
+}
+
+// LoadConfigFromEnv reads HOUSE_MM_USERNAME
+func LoadConfigFromEnv() Config {
+	return Config{Username: os.Getenv("HOUSE_MM_USERNAME")}
+}
+
+// MarketExposure is the house position in a single market
+type MarketExposure struct {
+	MarketID      uint  `json:"marketId"`
+	YesShares     int64 `json:"yesShares"`
+	NoShares      int64 `json:"noShares"`
+	NetShares     int64 `json:"netShares"` // YES minus NO; positive means long YES
+	Value         int64 `json:"value"`
+	Cost          int64 `json:"cost"`
+	RealizedPnL   int64 `json:"realizedPnl"`
+	UnrealizedPnL int64 `json:"unrealizedPnl"`
+	Resolved      bool  `json:"resolved"`
+}
+
+// Report summarises the house account across all markets
+type Report struct {
+	Username      string           `json:"username"`
+	Balance       int64            `json:"balance"`
+	GrossExposure int64            `json:"grossExposure"` // Value held in unresolved markets
+	RealizedPnL   int64            `json:"realizedPnl"`
+	UnrealizedPnL int64            `json:"unrealizedPnl"`
+	Markets       []MarketExposure `json:"markets"`
+	GeneratedAt   time.Time        `json:"generatedAt"`
+}
+
+// Service computes and snapshots house exposure
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a house market maker reporting service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c}
+}
+
+// Enabled reports whether a house account is configured
+func (s *Service) Enabled() bool {
+	return s.config.Username != ""
+}
+
+// Exposure computes the house account's current inventory and P&L
+func (s *Service) Exposure() (*Report, error) {
+	if !s.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	var house models.User
+	if err := s.db.Where("username = ?", s.config.Username).First(&house).Error; err != nil {
+		return nil, err
+	}
+
+	positions, err := positionsmath.CalculateAllUserMarketPositions_WPAM_DBPM(s.db, house.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Username:    house.Username,
+		Balance:     house.WholeCredits(),
+		Markets:     make([]MarketExposure, 0, len(positions)),
+		GeneratedAt: s.clock.Now(),
+	}
+	for _, pos := range positions {
+		exp := MarketExposure{
+			MarketID:  pos.MarketID,
+			YesShares: pos.YesSharesOwned,
+			NoShares:  pos.NoSharesOwned,
+			NetShares: pos.YesSharesOwned - pos.NoSharesOwned,
+			Value:     pos.Value,
+			Cost:      pos.TotalSpent,
+			Resolved:  pos.IsResolved,
+		}
+		if pos.IsResolved {
+			exp.RealizedPnL = pos.Value - pos.TotalSpent
+		} else {
+			exp.UnrealizedPnL = pos.Value - pos.TotalSpent
+			report.GrossExposure += pos.Value
+		}
+		report.RealizedPnL += exp.RealizedPnL
+		report.UnrealizedPnL += exp.UnrealizedPnL
+		report.Markets = append(report.Markets, exp)
+	}
+	sort.Slice(report.Markets, func(i, j int) bool { return report.Markets[i].MarketID < report.Markets[j].MarketID })
+	return report, nil
+}
+
+// Snapshot computes the current exposure and stores one row per market
+func (s *Service) Snapshot() (*Report, error) {
+	report, err := s.Exposure()
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Markets) == 0 {
+		return report, nil
+	}
+
+	rows := make([]models.HouseExposureSnapshot, len(report.Markets))
+	for i, m := range report.Markets {
+		rows[i] = models.HouseExposureSnapshot{
+			SnapshotAt:    report.GeneratedAt,
+			Username:      report.Username,
+			MarketID:      m.MarketID,
+			YesShares:     m.YesShares,
+			NoShares:      m.NoShares,
+			Value:         m.Value,
+			Cost:          m.Cost,
+			RealizedPnL:   m.RealizedPnL,
+			UnrealizedPnL: m.UnrealizedPnL,
+			Resolved:      m.Resolved,
+		}
+	}
+	if err := s.db.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// History returns stored snapshots since the given time, optionally for one market
+func (s *Service) History(since time.Time, marketID uint) ([]models.HouseExposureSnapshot, error) {
+	query := s.db.Where("snapshot_at >= ?", since)
+	if marketID != 0 {
+		query = query.Where("market_id = ?", marketID)
+	}
+	var rows []models.HouseExposureSnapshot
+	err := query.Order("snapshot_at ASC, market_id ASC").Find(&rows).Error
+	return rows, err
+}
+
+// Run takes a snapshot every interval. It never returns.
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.Snapshot(); err != nil {
+			log.Printf("House MM: snapshot failed: %v", err)
+		}
+	}
+}
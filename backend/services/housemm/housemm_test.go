@@ -0,0 +1,82 @@
+package housemm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models/modelstesting"
+)
+
+func TestExposureRequiresConfiguredAccount(t *testing.T) {
+	svc := NewService(modelstesting.NewFakeDB(t), Config{}, clock.New())
+	if _, err := svc.Exposure(); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestExposureAndSnapshots(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	_, _ = modelstesting.UseStandardTestEconomics(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewService(db, Config{Username: "house"}, clock.NewFake(now))
+
+	for _, name := range []string{"creator", "house", "trader"} {
+		user := modelstesting.GenerateUser(name, 1000)
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	open := modelstesting.GenerateMarket(1, "creator")
+	resolved := modelstesting.GenerateMarket(2, "creator")
+	resolved.IsResolved = true
+	resolved.ResolutionResult = "YES"
+	for _, m := range []interface{}{&open, &resolved} {
+		if err := db.Create(m).Error; err != nil {
+			t.Fatalf("create market: %v", err)
+		}
+	}
+	for _, bet := range []struct {
+		amount   int64
+		outcome  string
+		username string
+		market   uint
+	}{
+		{100, "YES", "house", 1},
+		{50, "NO", "trader", 1},
+		{40, "YES", "house", 2},
+		{60, "NO", "trader", 2},
+	} {
+		b := modelstesting.GenerateBet(bet.amount, bet.outcome, bet.username, bet.market, 0)
+		if err := db.Create(&b).Error; err != nil {
+			t.Fatalf("create bet: %v", err)
+		}
+	}
+
+	report, err := svc.Exposure()
+	if err != nil {
+		t.Fatalf("exposure: %v", err)
+	}
+	if len(report.Markets) != 2 || report.Markets[0].MarketID != 1 || report.Markets[1].MarketID != 2 {
+		t.Fatalf("unexpected markets: %+v", report.Markets)
+	}
+	openExp, resolvedExp := report.Markets[0], report.Markets[1]
+	if openExp.Resolved || openExp.YesShares == 0 || openExp.Cost != 100 || openExp.RealizedPnL != 0 {
+		t.Errorf("unexpected open market exposure: %+v", openExp)
+	}
+	if !resolvedExp.Resolved || resolvedExp.UnrealizedPnL != 0 || resolvedExp.RealizedPnL != resolvedExp.Value-40 {
+		t.Errorf("unexpected resolved market exposure: %+v", resolvedExp)
+	}
+	if report.GrossExposure != openExp.Value {
+		t.Errorf("gross exposure = %d, want %d", report.GrossExposure, openExp.Value)
+	}
+
+	if _, err := svc.Snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	history, err := svc.History(now.Add(-time.Minute), 2)
+	if err != nil || len(history) != 1 || history[0].RealizedPnL != resolvedExp.RealizedPnL {
+		t.Fatalf("unexpected history: %v %+v", err, history)
+	}
+}
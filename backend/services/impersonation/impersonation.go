@@ -0,0 +1,190 @@
+// Package impersonation lets support admins view the platform as a user sees
+// it, to chase tickets such as a deposit that did not show up. An admin opens
+// a time-boxed session and gets a token that authenticates as the user but
+// only for reads; the session and every request made with its token are
+// recorded in the audit log. Admins cannot be impersonated.
+package impersonation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTTL    = 15 * time.Minute
+	defaultMaxTTL = time.Hour
+
+	actionStarted = "USER_IMPERSONATION_STARTED"
+	actionViewed  = "USER_IMPERSONATION_VIEW"
+	actionEnded   = "USER_IMPERSONATION_ENDED"
+)
+
+var (
+	ErrReasonRequired  = errors.New("a reason is required to impersonate a user")
+	ErrInvalidDuration = errors.New("invalid impersonation duration")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrAdminTarget     = errors.New("admins cannot be impersonated")
+	ErrSessionNotFound = errors.New("impersonation session not found or no longer active")
+)
+
+// Config holds impersonation settings
+type Config struct {
+	TTL    time.Duration // How long a session lasts when the admin does not say
+	MaxTTL time.Duration // The longest session an admin may ask for
+}
+
+// LoadConfigFromEnv reads IMPERSONATION_TTL and IMPERSONATION_MAX_TTL
+func LoadConfigFromEnv() Config {
+	config := Config{TTL: defaultTTL, MaxTTL: defaultMaxTTL}
+	if d, err := time.ParseDuration(os.Getenv("IMPERSONATION_TTL")); err == nil && d > 0 {
+		config.TTL = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("IMPERSONATION_MAX_TTL")); err == nil && d > 0 {
+		config.MaxTTL = d
+	}
+	if config.TTL > config.MaxTTL {
+		config.TTL = config.MaxTTL
+	}
+	return config
+}
+
+// Service opens and ends impersonation sessions
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates an impersonation service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c}
+}
+
+// Start opens a read-only session for admin to view username's account,
+// lasting ttl, or the configured default when ttl is 0
+func (s *Service) Start(admin *models.User, username, reason string, ttl time.Duration) (*models.ImpersonationSession, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if ttl == 0 {
+		ttl = s.config.TTL
+	}
+	if ttl < 0 || ttl > s.config.MaxTTL {
+		return nil, fmt.Errorf("%w: must be at most %s", ErrInvalidDuration, s.config.MaxTTL)
+	}
+
+	var user models.User
+	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.UserType == "ADMIN" {
+		return nil, ErrAdminTarget
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	session := &models.ImpersonationSession{
+		ID:            id,
+		AdminID:       admin.ID,
+		AdminUsername: admin.Username,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Reason:        reason,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(ttl),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin.Username,
+			Action:     actionStarted,
+			TargetType: "user",
+			TargetID:   uint(user.ID),
+			Details:    fmt.Sprintf("session=%s expires=%s reason=%q", id, session.ExpiresAt.Format(time.RFC3339), reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// End closes a session early, invalidating its token
+func (s *Service) End(id, actor string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		session, err := ActiveSession(tx, id, s.clock.Now())
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(session).Updates(map[string]interface{}{"ended_at": s.clock.Now(), "ended_by": actor}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     actionEnded,
+			TargetType: "user",
+			TargetID:   uint(session.UserID),
+			Details:    fmt.Sprintf("session=%s", id),
+		})
+	})
+}
+
+// List returns the most recent sessions, newest first
+func (s *Service) List(limit int) ([]models.ImpersonationSession, error) {
+	var sessions []models.ImpersonationSession
+	err := s.db.Order("created_at DESC").Limit(limit).Find(&sessions).Error
+	return sessions, err
+}
+
+// ActiveSession returns the session with id if it has neither expired nor
+// been ended at now
+func ActiveSession(db *gorm.DB, id string, now time.Time) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	err := db.Where("id = ? AND ended_at IS NULL AND expires_at > ?", id, now).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RecordView audits one request made with a session's token
+func RecordView(db *gorm.DB, session *models.ImpersonationSession, method, path string) error {
+	return audit.Record(db, models.AuditLog{
+		Actor:      session.AdminUsername,
+		Action:     actionViewed,
+		TargetType: "user",
+		TargetID:   uint(session.UserID),
+		Details:    fmt.Sprintf("session=%s %s %s", session.ID, method, path),
+	})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
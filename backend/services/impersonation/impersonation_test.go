@@ -0,0 +1,75 @@
+package impersonation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestStartValidatesAndTimeBoxes(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	admin := modelstesting.GenerateUser("support", 0)
+	admin.UserType = "ADMIN"
+	other := modelstesting.GenerateUser("otheradmin", 0)
+	other.UserType = "ADMIN"
+	user := modelstesting.GenerateUser("alice", 0)
+	for _, u := range []*models.User{&admin, &other, &user} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	svc := NewService(db, Config{TTL: 15 * time.Minute, MaxTTL: time.Hour}, fake)
+
+	tests := []struct {
+		name     string
+		username string
+		reason   string
+		ttl      time.Duration
+		wantErr  error
+	}{
+		{name: "no reason", username: "alice", reason: " ", wantErr: ErrReasonRequired},
+		{name: "too long", username: "alice", reason: "ticket", ttl: 2 * time.Hour, wantErr: ErrInvalidDuration},
+		{name: "unknown user", username: "nobody", reason: "ticket", wantErr: ErrUserNotFound},
+		{name: "admin", username: "otheradmin", reason: "ticket", wantErr: ErrAdminTarget},
+	}
+	for _, tt := range tests {
+		if _, err := svc.Start(&admin, tt.username, tt.reason, tt.ttl); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	session, err := svc.Start(&admin, "alice", "deposit missing, ticket 42", 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if session.UserID != user.ID || !session.ExpiresAt.Equal(fake.Now().Add(15*time.Minute)) {
+		t.Fatalf("session = %+v, want alice for the default 15 minutes", session)
+	}
+	var started int64
+	db.Model(&models.AuditLog{}).Where("action = ? AND actor = ? AND target_id = ?", actionStarted, "support", user.ID).Count(&started)
+	if started != 1 {
+		t.Errorf("audited %d starts, want 1", started)
+	}
+
+	if _, err := ActiveSession(db, session.ID, fake.Now().Add(14*time.Minute)); err != nil {
+		t.Errorf("session inactive before expiry: %v", err)
+	}
+	if _, err := ActiveSession(db, session.ID, fake.Now().Add(15*time.Minute)); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expired session = %v, want ErrSessionNotFound", err)
+	}
+
+	if err := svc.End(session.ID, "support"); err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	if _, err := ActiveSession(db, session.ID, fake.Now()); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("ended session = %v, want ErrSessionNotFound", err)
+	}
+	if err := svc.End(session.ID, "support"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("ending twice = %v, want ErrSessionNotFound", err)
+	}
+}
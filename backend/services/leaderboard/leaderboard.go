@@ -0,0 +1,324 @@
+// Package leaderboard ranks users by how they did in resolved markets:
+// realized profit, return on what they wagered, and forecast accuracy as a
+// Brier score. Computing the boards means replaying every resolved market,
+// so a scheduled job stores them as snapshots and requests read the newest.
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/handlers/math/accuracy"
+	positionsmath "socialpredict/handlers/math/positions"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// Sort orders for a leaderboard page
+const (
+	SortProfit = "profit"
+	SortROI    = "roi"
+	SortBrier  = "brier"
+)
+
+const retention = 90 * 24 * time.Hour
+
+var (
+	ErrInvalidPeriod = errors.New("period must be all-time, monthly or weekly")
+	ErrInvalidSort   = errors.New("sort must be profit, roi or brier")
+)
+
+// Periods lists the leaderboards each snapshot computes
+var Periods = []string{models.LeaderboardAllTime, models.LeaderboardMonthly, models.LeaderboardWeekly}
+
+// Entry is a ranked row on a leaderboard page
+type Entry struct {
+	Rank int `json:"rank"`
+	models.LeaderboardSnapshot
+}
+
+// Page is one page of a leaderboard, ranked by Sort
+type Page struct {
+	Period      string     `json:"period"`
+	Sort        string     `json:"sort"`
+	PeriodStart *time.Time `json:"periodStart,omitempty"`
+	ComputedAt  *time.Time `json:"computedAt"` // Nil until the first snapshot
+	Entries     []Entry    `json:"entries"`
+	Total       int64      `json:"total"`
+	Page        int        `json:"page"`
+	Limit       int        `json:"limit"`
+}
+
+// Service computes and serves leaderboard snapshots
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService returns a leaderboard service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+// PeriodStart returns when the period that contains now began. The all-time
+// board has no start.
+func PeriodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case models.LeaderboardMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case models.LeaderboardWeekly:
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return time.Time{}
+}
+
+// standing accumulates a user's results over the markets in a period
+type standing struct {
+	profit    int64
+	wagered   int64
+	brierSum  float64
+	forecasts int
+	markets   int
+}
+
+// marketResult is what each user made and forecast in one resolved market
+type marketResult struct {
+	tenantID   uint
+	resolvedAt time.Time
+	profit     map[string]int64
+	wagered    map[string]int64
+	forecasts  []accuracy.Forecast
+	resolution string
+}
+
+// Snapshot computes every leaderboard of every community as of now and
+// stores them, pruning snapshots older than the retention window. It returns
+// the snapshot time.
+func (s *Service) Snapshot() (time.Time, error) {
+	now := s.clock.Now().UTC()
+
+	results, err := s.resolvedMarkets()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Each community ranks its users over its own markets
+	type key struct {
+		tenantID uint
+		username string
+	}
+	var rows []models.LeaderboardSnapshot
+	for _, period := range Periods {
+		start := PeriodStart(period, now)
+		standings := make(map[key]*standing)
+		get := func(tenantID uint, username string) *standing {
+			k := key{tenantID, username}
+			if standings[k] == nil {
+				standings[k] = &standing{}
+			}
+			return standings[k]
+		}
+
+		for _, result := range results {
+			if result.resolvedAt.Before(start) {
+				continue
+			}
+			for username, wagered := range result.wagered {
+				st := get(result.tenantID, username)
+				st.profit += result.profit[username]
+				st.wagered += wagered
+				st.markets++
+			}
+			for _, f := range result.forecasts {
+				st := get(result.tenantID, f.Username)
+				st.brierSum += f.Score(result.resolution)
+				st.forecasts++
+			}
+		}
+
+		for k, st := range standings {
+			row := models.LeaderboardSnapshot{
+				Period:          period,
+				ComputedAt:      now,
+				PeriodStart:     start,
+				TenantID:        k.tenantID,
+				Username:        k.username,
+				RealizedProfit:  st.profit,
+				AmountWagered:   st.wagered,
+				Forecasts:       st.forecasts,
+				ResolvedMarkets: st.markets,
+			}
+			if st.wagered > 0 {
+				row.ROI = float64(st.profit) / float64(st.wagered)
+			}
+			if st.forecasts > 0 {
+				brier := st.brierSum / float64(st.forecasts)
+				row.BrierScore = &brier
+			}
+			rows = append(rows, row)
+		}
+	}
+	// Stable insert order keeps ids, and so ties, deterministic
+	sort.Slice(rows, func(a, b int) bool {
+		if rows[a].Period != rows[b].Period {
+			return rows[a].Period < rows[b].Period
+		}
+		if rows[a].TenantID != rows[b].TenantID {
+			return rows[a].TenantID < rows[b].TenantID
+		}
+		return rows[a].Username < rows[b].Username
+	})
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(&rows, 500).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("computed_at < ?", now.Add(-retention)).Delete(&models.LeaderboardSnapshot{}).Error
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to store leaderboard snapshot: %w", err)
+	}
+	return now, nil
+}
+
+// resolvedMarkets replays every market that resolved to an outcome. Markets
+// resolved N/A refunded their bets and count towards no board.
+func (s *Service) resolvedMarkets() ([]marketResult, error) {
+	var markets []models.Market
+	if err := s.db.Where("is_resolved = ? AND resolution_result <> ?", true, "N/A").
+		Order("id ASC").Find(&markets).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]marketResult, 0, len(markets))
+	for _, market := range markets {
+		bets := tradingdata.GetBetsForMarket(s.db, uint(market.ID))
+		if len(bets) == 0 {
+			continue
+		}
+		result := marketResult{
+			tenantID:   market.TenantID,
+			resolvedAt: market.FinalResolutionDateTime,
+			profit:     make(map[string]int64),
+			wagered:    make(map[string]int64),
+			resolution: market.ResolutionResult,
+		}
+		for _, bet := range bets {
+			if bet.Amount > 0 {
+				result.wagered[bet.Username] += bet.Amount
+			}
+		}
+
+		var labels []string
+		if market.IsCategorical() {
+			for _, outcome := range tradingdata.GetOutcomesForMarket(s.db, market.ID) {
+				labels = append(labels, outcome.Label)
+			}
+			positions, _ := positionsmath.CalculateCategoricalPositions(market.CreatedAt, labels, bets)
+			payouts := make(map[string]float64)
+			for _, p := range positions {
+				if p.Outcome == market.ResolutionResult {
+					payouts[p.Username] += p.Value
+				}
+				result.profit[p.Username] -= p.TotalSpent
+			}
+			for username, value := range payouts {
+				result.profit[username] += int64(value)
+			}
+		} else {
+			positions, err := positionsmath.CalculateMarketPositions_WPAM_DBPM(s.db, strconv.FormatInt(market.ID, 10))
+			if err != nil {
+				return nil, fmt.Errorf("failed to value market %d: %w", market.ID, err)
+			}
+			for _, p := range positions {
+				result.profit[p.Username] += p.Value - p.TotalSpent
+			}
+		}
+
+		result.forecasts = accuracy.MarketForecasts(market, labels, bets, tradingdata.GetLiquidityForMarket(s.db, market.ID))
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Latest returns a page of the newest snapshot of the period's leaderboard
+// for a community, ranked by sortBy. Brier rankings leave out users with no
+// scored bets.
+func (s *Service) Latest(tenantID uint, period, sortBy string, page, limit int) (Page, error) {
+	valid := false
+	for _, p := range Periods {
+		valid = valid || p == period
+	}
+	if !valid {
+		return Page{}, ErrInvalidPeriod
+	}
+
+	order := "realized_profit DESC, username ASC"
+	switch sortBy {
+	case SortProfit:
+	case SortROI:
+		order = "roi DESC, realized_profit DESC, username ASC"
+	case SortBrier:
+		order = "brier_score ASC, forecasts DESC, username ASC"
+	default:
+		return Page{}, ErrInvalidSort
+	}
+
+	result := Page{Period: period, Sort: sortBy, Entries: []Entry{}, Page: page, Limit: limit}
+
+	var latest models.LeaderboardSnapshot
+	err := s.db.Where("period = ?", period).Order("computed_at DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return Page{}, err
+	}
+	result.ComputedAt = &latest.ComputedAt
+	if !latest.PeriodStart.IsZero() {
+		result.PeriodStart = &latest.PeriodStart
+	}
+
+	query := s.db.Model(&models.LeaderboardSnapshot{}).
+		Where("period = ? AND computed_at = ? AND tenant_id = ?", period, latest.ComputedAt, tenantID)
+	if sortBy == SortBrier {
+		query = query.Where("brier_score IS NOT NULL")
+	}
+	if err := query.Count(&result.Total).Error; err != nil {
+		return Page{}, err
+	}
+
+	var rows []models.LeaderboardSnapshot
+	offset := (page - 1) * limit
+	if err := query.Order(order).Offset(offset).Limit(limit).Find(&rows).Error; err != nil {
+		return Page{}, err
+	}
+	for i, row := range rows {
+		result.Entries = append(result.Entries, Entry{Rank: offset + i + 1, LeaderboardSnapshot: row})
+	}
+	return result, nil
+}
+
+// Run snapshots the leaderboards now and then every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Snapshot(); err != nil {
+			log.Printf("Leaderboard: snapshot failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,138 @@
+package leaderboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func resolvedMarket(t *testing.T, db *gorm.DB, id int64, result string, resolvedAt time.Time, bets ...models.Bet) {
+	t.Helper()
+	market := modelstesting.GenerateMarket(id, "creator")
+	market.IsResolved = true
+	market.ResolutionResult = result
+	market.FinalResolutionDateTime = resolvedAt
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+	for _, bet := range bets {
+		bet.MarketID = uint(id)
+		if err := db.Create(&bet).Error; err != nil {
+			t.Fatalf("create bet: %v", err)
+		}
+	}
+}
+
+func TestSnapshotRanksResolvedMarkets(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	clk := clock.NewFake(time.Now())
+	now := clk.Now()
+
+	resolvedMarket(t, db, 1, "YES", now,
+		modelstesting.GenerateBet(50, "YES", "alice", 0, 0),
+		modelstesting.GenerateBet(50, "NO", "bob", 0, time.Minute),
+	)
+	resolvedMarket(t, db, 2, "NO", now.AddDate(0, -2, 0),
+		modelstesting.GenerateBet(40, "NO", "carol", 0, 0),
+		modelstesting.GenerateBet(20, "YES", "bob", 0, time.Minute),
+	)
+	resolvedMarket(t, db, 3, "N/A", now,
+		modelstesting.GenerateBet(500, "YES", "dave", 0, 0),
+	)
+
+	svc := NewService(db, clk)
+	if _, err := svc.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	all, err := svc.Latest(0, models.LeaderboardAllTime, SortProfit, 1, 10)
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if all.Total != 3 {
+		t.Fatalf("all-time board has %d users, want alice, bob and carol", all.Total)
+	}
+	if all.Entries[0].Username != "alice" || all.Entries[0].Rank != 1 || all.Entries[0].RealizedProfit <= 0 {
+		t.Fatalf("top of all-time board = %+v, want alice in profit", all.Entries[0])
+	}
+	last := all.Entries[len(all.Entries)-1]
+	if last.Username != "bob" || last.RealizedProfit >= 0 || last.ResolvedMarkets != 2 || last.AmountWagered != 70 {
+		t.Fatalf("bottom of all-time board = %+v, want bob down over two markets", last)
+	}
+
+	weekly, err := svc.Latest(0, models.LeaderboardWeekly, SortProfit, 1, 10)
+	if err != nil {
+		t.Fatalf("Latest weekly: %v", err)
+	}
+	for _, entry := range weekly.Entries {
+		if entry.Username == "carol" {
+			t.Fatalf("weekly board includes carol's market from two months ago")
+		}
+	}
+
+	brier, err := svc.Latest(0, models.LeaderboardAllTime, SortBrier, 1, 1)
+	if err != nil {
+		t.Fatalf("Latest brier: %v", err)
+	}
+	if brier.Total != 3 || len(brier.Entries) != 1 || brier.Entries[0].Username == "bob" {
+		t.Fatalf("brier page = %+v, want a single entry that is not bob", brier)
+	}
+	if brier.Entries[0].BrierScore == nil || *brier.Entries[0].BrierScore >= 0.25 {
+		t.Fatalf("best brier score = %v, want better than a coin flip", brier.Entries[0].BrierScore)
+	}
+}
+
+func TestLatestServesNewestSnapshot(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	clk := clock.NewFake(time.Now())
+	svc := NewService(db, clk)
+
+	empty, err := svc.Latest(0, models.LeaderboardMonthly, SortROI, 1, 10)
+	if err != nil || empty.ComputedAt != nil || len(empty.Entries) != 0 {
+		t.Fatalf("before any snapshot got %+v, %v", empty, err)
+	}
+
+	if _, err := svc.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	resolvedMarket(t, db, 1, "YES", clk.Now(), modelstesting.GenerateBet(10, "YES", "alice", 0, 0))
+	clk.Advance(time.Hour)
+	second, err := svc.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	page, err := svc.Latest(0, models.LeaderboardMonthly, SortROI, 1, 10)
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if page.ComputedAt == nil || !page.ComputedAt.Equal(second) || page.Total != 1 {
+		t.Fatalf("page = %+v, want the second snapshot with alice", page)
+	}
+
+	if _, err := svc.Latest(0, "DAILY", SortProfit, 1, 10); !errors.Is(err, ErrInvalidPeriod) {
+		t.Fatalf("err = %v, want ErrInvalidPeriod", err)
+	}
+	if _, err := svc.Latest(0, models.LeaderboardWeekly, "volume", 1, 10); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("err = %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 3, 19, 15, 30, 0, 0, time.UTC) // Thursday
+	if got := PeriodStart(models.LeaderboardWeekly, now); !got.Equal(time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("week start = %v, want Monday 16 March", got)
+	}
+	if got := PeriodStart(models.LeaderboardMonthly, now); !got.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("month start = %v, want 1 March", got)
+	}
+	if got := PeriodStart(models.LeaderboardAllTime, now); !got.IsZero() {
+		t.Fatalf("all-time start = %v, want zero", got)
+	}
+}
@@ -0,0 +1,247 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// backfillBatchSize is how many users are loaded per query during a backfill
+const backfillBatchSize = 500
+
+// Reference types identifying what an entry was derived from
+const (
+	ReferenceTypeUser              = "user"
+	ReferenceTypeCryptoTransaction = "crypto_transaction"
+	ReferenceTypeWithdrawalRequest = "withdrawal_request"
+)
+
+// Discrepancy is a user whose ledger entries do not add up to their balance
+type Discrepancy struct {
+	UserID      int64  `json:"userId"`
+	Username    string `json:"username"`
+	Balance     int64  `json:"balance"`     // Current balance in micro-credits
+	LedgerTotal int64  `json:"ledgerTotal"` // Sum of the user's ledger entries in micro-credits
+	Difference  int64  `json:"difference"`  // Balance minus LedgerTotal
+}
+
+// BackfillReport summarises a backfill or verification run
+type BackfillReport struct {
+	Users         int           `json:"users"`
+	Backfilled    int           `json:"backfilled"` // Users given opening entries on this run
+	Skipped       int           `json:"skipped"`    // Users backfilled by an earlier run
+	Entries       int           `json:"entries"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Backfill gives every user without an opening balance entry one, together with
+// an entry for each completed crypto deposit and withdrawal. The opening entry
+// is whatever the user's balance is not explained by those transactions and
+// any existing ledger entries. Each user is locked while this is worked out,
+// so it is taken from one snapshot of the balance and the entries that no
+// balance change can slip between. From then on every balance change writes
+// its own entry, and verification catches any that did not. Users are
+// committed one at a time and skipped once done, so an interrupted run can
+// simply be started again. With dryRun set nothing is written and only the
+// verification is reported.
+func Backfill(db *gorm.DB, dryRun bool) (*BackfillReport, error) {
+	report := &BackfillReport{Discrepancies: []Discrepancy{}}
+
+	var lastID int64
+	for {
+		var users []models.User
+		if err := db.Where("id > ?", lastID).Order("id").Limit(backfillBatchSize).Find(&users).Error; err != nil {
+			return report, fmt.Errorf("failed to load users: %w", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		lastID = users[len(users)-1].ID
+
+		for i := range users {
+			user := &users[i]
+			report.Users++
+
+			done, err := hasOpeningBalance(db, user.ID)
+			if err != nil {
+				return report, err
+			}
+			if done {
+				report.Skipped++
+			} else if !dryRun {
+				var created int
+				if err := db.Transaction(func(tx *gorm.DB) error {
+					created, err = backfillUser(tx, user.ID)
+					return err
+				}); err != nil {
+					return report, fmt.Errorf("failed to backfill user %d: %w", user.ID, err)
+				}
+				if created > 0 {
+					report.Backfilled++
+					report.Entries += created
+				} else {
+					report.Skipped++
+				}
+			}
+
+			d, err := verifyUser(db, user.ID)
+			if err != nil {
+				return report, err
+			}
+			if d != nil {
+				report.Discrepancies = append(report.Discrepancies, *d)
+			}
+		}
+	}
+	return report, nil
+}
+
+func hasOpeningBalance(db *gorm.DB, userID int64) (bool, error) {
+	var count int64
+	if err := db.Model(&models.LedgerEntry{}).
+		Where("user_id = ? AND type = ?", userID, models.LedgerTypeOpeningBalance).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check opening balance for user %d: %w", userID, err)
+	}
+	return count > 0, nil
+}
+
+// backfillUser locks one user and writes their opening entry and historical
+// crypto entries, returning how many entries it created. A user backfilled
+// meanwhile by another run is left alone.
+func backfillUser(tx *gorm.DB, userID int64) (int, error) {
+	user, err := LockUser(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if done, err := hasOpeningBalance(tx, user.ID); err != nil || done {
+		return 0, err
+	}
+
+	var existing int64
+	if err := tx.Model(&models.LedgerEntry{}).Where("user_id = ?", user.ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&existing).Error; err != nil {
+		return 0, err
+	}
+
+	var txs []models.CryptoTransaction
+	if err := tx.Where("user_id = ? AND status = ?", user.ID, models.TxStatusCompleted).
+		Find(&txs).Error; err != nil {
+		return 0, err
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		ti, tj := settledAt(&txs[i]), settledAt(&txs[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return txs[i].ID < txs[j].ID
+	})
+
+	var history []models.LedgerEntry
+	var historyTotal int64
+	for _, ct := range txs {
+		var imported int64
+		if err := tx.Model(&models.LedgerEntry{}).
+			Where("reference_type = ? AND reference_id = ?", ReferenceTypeCryptoTransaction, ct.ID).
+			Count(&imported).Error; err != nil {
+			return 0, err
+		}
+		if imported > 0 {
+			continue
+		}
+
+		entry := models.LedgerEntry{
+			UserID:        user.ID,
+			ReferenceType: ReferenceTypeCryptoTransaction,
+			ReferenceID:   ct.ID,
+		}
+		entry.CreatedAt = settledAt(&ct)
+		switch ct.Type {
+		case models.TxTypeDeposit:
+			entry.Type = models.LedgerTypeHistoricalDeposit
+			entry.Amount = ct.AmountCredits
+			entry.Description = fmt.Sprintf("Deposit of %s %s on %s", models.FormatMicroCredits(ct.AmountCredits), ct.TokenSymbol, ct.ChainName)
+		case models.TxTypeWithdrawal:
+			entry.Type = models.LedgerTypeHistoricalWithdraw
+			entry.Amount = -ct.AmountCredits
+			entry.Description = fmt.Sprintf("Withdrawal of %s %s on %s", models.FormatMicroCredits(ct.AmountCredits), ct.TokenSymbol, ct.ChainName)
+		default:
+			continue
+		}
+		historyTotal += entry.Amount
+		history = append(history, entry)
+	}
+
+	// The opening balance predates everything it is derived from
+	opening := user.BalanceMicroCredits() - existing - historyTotal
+	openingEntry := models.LedgerEntry{
+		UserID:        user.ID,
+		Type:          models.LedgerTypeOpeningBalance,
+		Amount:        opening,
+		BalanceAfter:  opening,
+		ReferenceType: ReferenceTypeUser,
+		ReferenceID:   uint(user.ID),
+		Description:   "Opening balance imported by ledger backfill",
+	}
+	openingEntry.CreatedAt = user.CreatedAt
+	if len(history) > 0 && history[0].CreatedAt.Before(openingEntry.CreatedAt) {
+		openingEntry.CreatedAt = history[0].CreatedAt
+	}
+	if err := tx.Create(&openingEntry).Error; err != nil {
+		return 0, err
+	}
+
+	running := opening
+	for i := range history {
+		running += history[i].Amount
+		history[i].BalanceAfter = running
+		if err := tx.Create(&history[i]).Error; err != nil {
+			return 0, err
+		}
+	}
+	return 1 + len(history), nil
+}
+
+// verifyUser compares a user's current balance with the sum of their ledger
+// entries. The user is locked while both are read, so a balance change in
+// flight is not reported as a discrepancy.
+func verifyUser(db *gorm.DB, userID int64) (*Discrepancy, error) {
+	var (
+		user  *models.User
+		total int64
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = LockUser(tx, userID); err != nil {
+			return err
+		}
+		return tx.Model(&models.LedgerEntry{}).Where("user_id = ?", userID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to total ledger for user %d: %w", userID, err)
+	}
+	balance := user.BalanceMicroCredits()
+	if total == balance {
+		return nil, nil
+	}
+	return &Discrepancy{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Balance:     balance,
+		LedgerTotal: total,
+		Difference:  balance - total,
+	}, nil
+}
+
+// settledAt is when a crypto transaction moved the user's balance
+func settledAt(ct *models.CryptoTransaction) time.Time {
+	if ct.ProcessedAt != nil {
+		return *ct.ProcessedAt
+	}
+	return ct.CreatedAt
+}
@@ -0,0 +1,104 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestBackfillCreatesOpeningEntriesAndIsResumable(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+
+	alice := modelstesting.GenerateUser("alice", 100)
+	alice.AddMicroCredits(250_000)
+	bob := modelstesting.GenerateUser("bob", 40)
+	for _, u := range []*models.User{&alice, &bob} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, ct := range []models.CryptoTransaction{
+		{UserID: alice.ID, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, AmountCredits: 50_000_000, ProcessedAt: &day},
+		{UserID: alice.ID, Type: models.TxTypeWithdrawal, Status: models.TxStatusCompleted, AmountCredits: 20_000_000},
+		{UserID: alice.ID, Type: models.TxTypeDeposit, Status: models.TxStatusFailed, AmountCredits: 99_000_000},
+	} {
+		if err := db.Create(&ct).Error; err != nil {
+			t.Fatalf("create transaction: %v", err)
+		}
+	}
+	// An existing correction is kept and counted towards the opening balance
+	if err := db.Create(&models.LedgerEntry{UserID: bob.ID, Type: models.LedgerTypeCorrection, Amount: 5_000_000}).Error; err != nil {
+		t.Fatalf("create ledger entry: %v", err)
+	}
+
+	report, err := Backfill(db, false)
+	if err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	if report.Users != 2 || report.Backfilled != 2 || report.Entries != 4 {
+		t.Errorf("report = %+v, want 2 users, 2 backfilled, 4 entries", report)
+	}
+	if len(report.Discrepancies) != 0 {
+		t.Errorf("unexpected discrepancies: %+v", report.Discrepancies)
+	}
+
+	var opening models.LedgerEntry
+	db.Where("user_id = ? AND type = ?", alice.ID, models.LedgerTypeOpeningBalance).First(&opening)
+	if want := int64(100_250_000 - 50_000_000 + 20_000_000); opening.Amount != want {
+		t.Errorf("alice opening = %d, want %d", opening.Amount, want)
+	}
+	var last models.LedgerEntry
+	db.Where("user_id = ?", alice.ID).Order("id DESC").First(&last)
+	if last.BalanceAfter != 100_250_000 {
+		t.Errorf("alice final BalanceAfter = %d, want 100250000", last.BalanceAfter)
+	}
+	var bobOpening models.LedgerEntry
+	db.Where("user_id = ? AND type = ?", bob.ID, models.LedgerTypeOpeningBalance).First(&bobOpening)
+	if bobOpening.Amount != 35_000_000 {
+		t.Errorf("bob opening = %d, want 35000000", bobOpening.Amount)
+	}
+
+	// A second run writes nothing and reports balances that moved outside the
+	// ledger, but not those changed through it
+	if _, err := Apply(db, &alice, Posting{Type: models.LedgerTypeBet, Amount: -3_000_000}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if err := modelstesting.AdjustUserBalance(db, "bob", -10); err != nil {
+		t.Fatalf("adjust balance: %v", err)
+	}
+	report, err = Backfill(db, false)
+	if err != nil {
+		t.Fatalf("second Backfill: %v", err)
+	}
+	if report.Backfilled != 0 || report.Skipped != 2 || report.Entries != 0 {
+		t.Errorf("second report = %+v, want everything skipped", report)
+	}
+	if len(report.Discrepancies) != 1 || report.Discrepancies[0].Username != "bob" || report.Discrepancies[0].Difference != -10_000_000 {
+		t.Errorf("discrepancies = %+v, want bob at -10 credits", report.Discrepancies)
+	}
+}
+
+func TestBackfillDryRunWritesNothing(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("carol", 10)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	report, err := Backfill(db, true)
+	if err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	var count int64
+	db.Model(&models.LedgerEntry{}).Count(&count)
+	if count != 0 || report.Backfilled != 0 {
+		t.Errorf("dry run wrote %d entries, report %+v", count, report)
+	}
+	if len(report.Discrepancies) != 1 || report.Discrepancies[0].Difference != 10_000_000 {
+		t.Errorf("discrepancies = %+v, want carol unbacked by 10 credits", report.Discrepancies)
+	}
+}
@@ -0,0 +1,133 @@
+// Package ledger applies balance changes to users and records them as ledger entries.
+package ledger
+
+import (
+	"fmt"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Posting describes a balance change to record against a user
+type Posting struct {
+	Type          string
+	Amount        int64 // Signed micro-credits
+	ReferenceType string
+	ReferenceID   uint
+	CaseID        string
+	MarketID      *int64
+	Description   string
+	// SpendBonus makes a debit use up the user's unwagered bonus first, as
+	// bets and fees do
+	SpendBonus bool
+	// ExternalReference, when set, is stored on the entry under a unique index
+	ExternalReference string
+}
+
+// LockUser loads a user and locks its row (SELECT ... FOR UPDATE) until tx
+// ends, so a balance read through it cannot be overwritten by a concurrent
+// transaction working from the same starting balance. Callers must run it
+// inside a transaction and make their balance change from the returned user.
+func LockUser(tx *gorm.DB, userID int64) (*models.User, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LockUsers locks two users with LockUser, lower ID first so that
+// transactions locking the same pair cannot deadlock, and returns them in
+// the order given
+func LockUsers(tx *gorm.DB, firstID, secondID int64) (*models.User, *models.User, error) {
+	if firstID > secondID {
+		second, first, err := LockUsers(tx, secondID, firstID)
+		return first, second, err
+	}
+	first, err := LockUser(tx, firstID)
+	if err != nil {
+		return nil, nil, err
+	}
+	second, err := LockUser(tx, secondID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return first, second, nil
+}
+
+// SaveBalance writes only the user's balance and unwagered bonus columns.
+// They are written as they are, so the user must have been loaded with
+// LockUser in the same transaction.
+func SaveBalance(tx *gorm.DB, user *models.User) error {
+	return tx.Model(user).Updates(map[string]interface{}{
+		"account_balance": user.AccountBalance,
+		"bonus_balance":   user.BonusBalance,
+	}).Error
+}
+
+// Apply adjusts the user's balance by p.Amount, saves the user and writes the
+// matching ledger entry. It locks and re-reads the user's row first, so the
+// change lands on the current balance however stale the caller's copy is;
+// the copy's balance is then updated to match. Inside a transaction it runs
+// in a savepoint and holds the lock until the transaction ends.
+func Apply(tx *gorm.DB, user *models.User, p Posting) (*models.LedgerEntry, error) {
+	var entry models.LedgerEntry
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		locked, err := LockUser(tx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to lock user %d: %w", user.ID, err)
+		}
+		locked.AddMicroCredits(p.Amount)
+		if p.SpendBonus {
+			locked.SpendBonus(-p.Amount)
+		}
+		if err := SaveBalance(tx, locked); err != nil {
+			return fmt.Errorf("failed to update balance for user %d: %w", user.ID, err)
+		}
+
+		entry = models.LedgerEntry{
+			UserID:        user.ID,
+			Type:          p.Type,
+			Amount:        p.Amount,
+			BalanceAfter:  locked.BalanceMicroCredits(),
+			ReferenceType: p.ReferenceType,
+			ReferenceID:   p.ReferenceID,
+			CaseID:        p.CaseID,
+			MarketID:      p.MarketID,
+			Description:   p.Description,
+		}
+		if p.ExternalReference != "" {
+			entry.ExternalReference = &p.ExternalReference
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return fmt.Errorf("failed to write ledger entry for user %d: %w", user.ID, err)
+		}
+		user.AccountBalance, user.BonusBalance = locked.AccountBalance, locked.BonusBalance
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RecordPlatform writes a ledger entry against the platform account. No user
+// balance changes, so BalanceAfter is left at zero.
+func RecordPlatform(tx *gorm.DB, p Posting) (*models.LedgerEntry, error) {
+	entry := models.LedgerEntry{
+		UserID:        models.PlatformUserID,
+		Type:          p.Type,
+		Amount:        p.Amount,
+		ReferenceType: p.ReferenceType,
+		ReferenceID:   p.ReferenceID,
+		CaseID:        p.CaseID,
+		MarketID:      p.MarketID,
+		Description:   p.Description,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to write platform ledger entry: %w", err)
+	}
+	return &entry, nil
+}
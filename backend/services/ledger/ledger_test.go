@@ -0,0 +1,79 @@
+package ledger
+
+import (
+	"sync"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func TestLockedBalanceChangesAreNotLost(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	user := modelstesting.GenerateUser("alice", 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Twenty concurrent postings of half a credit each
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.Transaction(func(tx *gorm.DB) error {
+				locked, err := LockUser(tx, user.ID)
+				if err != nil {
+					return err
+				}
+				_, err = Apply(tx, locked, Posting{Type: models.LedgerTypeBonusGrant, Amount: 500_000})
+				return err
+			})
+			if err != nil {
+				t.Errorf("posting: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	db.First(&user, user.ID)
+	if user.BalanceMicroCredits() != models.CreditsToMicro(10) {
+		t.Fatalf("balance = %d micro, want 10 credits", user.BalanceMicroCredits())
+	}
+	var entries int64
+	db.Model(&models.LedgerEntry{}).Where("user_id = ?", user.ID).Count(&entries)
+	if entries != 20 {
+		t.Fatalf("ledger entries = %d, want 20", entries)
+	}
+}
+
+func TestApplyWorksFromTheCurrentBalance(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 10)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// A copy loaded before a deposit is credited must not overwrite it
+	var stale models.User
+	db.First(&stale, user.ID)
+	if _, err := Apply(db, &user, Posting{Type: models.LedgerTypeBonusGrant, Amount: models.CreditsToMicro(5)}); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if _, err := Apply(db, &stale, Posting{Type: models.LedgerTypeBonusGrant, Amount: models.CreditsToMicro(1)}); err != nil {
+		t.Fatalf("posting from stale copy: %v", err)
+	}
+
+	db.First(&user, user.ID)
+	if user.AccountBalance != models.CreditsToMicro(16) || stale.AccountBalance != models.CreditsToMicro(16) {
+		t.Fatalf("balance = %d, copy = %d; want 16 for both", user.AccountBalance, stale.AccountBalance)
+	}
+}
@@ -0,0 +1,193 @@
+// Package limits enforces withdrawal limits over rolling 24-hour, 7-day and
+// 30-day windows, per user and optionally across the whole platform.
+package limits
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+// Window names
+const (
+	WindowDaily   = "daily"
+	WindowWeekly  = "7d"
+	WindowMonthly = "30d"
+)
+
+// Limit scopes
+const (
+	ScopeUser     = "user"
+	ScopePlatform = "platform"
+)
+
+// WindowUsage is how much of one window's limit has been used. Amounts are in
+// micro-credits.
+type WindowUsage struct {
+	Window    string `json:"window"`
+	Scope     string `json:"scope"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+}
+
+// LimitError is returned when a withdrawal would exceed a limit. Usage covers
+// every enforced window, not only the one exceeded.
+type LimitError struct {
+	Window    string
+	Scope     string
+	Requested int64 // Micro-credits
+	Usage     []WindowUsage
+}
+
+func (e *LimitError) Error() string {
+	name := map[string]string{WindowDaily: "daily", WindowWeekly: "7-day", WindowMonthly: "30-day"}[e.Window]
+	if e.Scope == ScopePlatform {
+		return fmt.Sprintf("Platform-wide %s withdrawal limit reached", name)
+	}
+	return fmt.Sprintf("%s%s withdrawal limit exceeded", strings.ToUpper(name[:1]), name[1:])
+}
+
+// Exceeded returns the usage of the window that was exceeded
+func (e *LimitError) Exceeded() WindowUsage {
+	for _, u := range e.Usage {
+		if u.Window == e.Window && u.Scope == e.Scope {
+			return u
+		}
+	}
+	return WindowUsage{Window: e.Window, Scope: e.Scope}
+}
+
+// Service measures withdrawals against the configured limits
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService creates a limits service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+type window struct {
+	name  string
+	scope string
+	limit int64
+	since time.Time
+}
+
+// windows lists the enforced windows; zero limits are skipped
+func (s *Service) windows(l settings.WithdrawalLimits) []window {
+	// Windows roll with the request time rather than resetting at midnight,
+	// so a limit cannot be used twice either side of a day boundary
+	now := s.clock.Now()
+	today := now.Add(-24 * time.Hour)
+	week := now.Add(-7 * 24 * time.Hour)
+	month := now.Add(-30 * 24 * time.Hour)
+
+	all := []window{
+		{WindowDaily, ScopeUser, l.Daily, today},
+		{WindowWeekly, ScopeUser, l.Weekly, week},
+		{WindowMonthly, ScopeUser, l.Monthly, month},
+		{WindowDaily, ScopePlatform, l.GlobalDaily, today},
+		{WindowWeekly, ScopePlatform, l.GlobalWeekly, week},
+		{WindowMonthly, ScopePlatform, l.GlobalMonthly, month},
+	}
+	var enforced []window
+	for _, w := range all {
+		if w.limit > 0 {
+			enforced = append(enforced, w)
+		}
+	}
+	return enforced
+}
+
+// Usage reports the user's and platform's usage of every enforced window
+func (s *Service) Usage(userID int64, l settings.WithdrawalLimits) ([]WindowUsage, error) {
+	usage := []WindowUsage{}
+	for _, w := range s.windows(l) {
+		query := s.db.Model(&models.WithdrawalRequest{}).
+			Where("created_at > ? AND status IN ?", w.since, models.WithdrawalCommittedStatuses)
+		if w.scope == ScopeUser {
+			query = query.Where("user_id = ?", userID)
+		}
+		var used int64
+		if err := query.Select("COALESCE(SUM(amount), 0)").Scan(&used).Error; err != nil {
+			return nil, fmt.Errorf("failed to total %s %s withdrawals: %w", w.scope, w.name, err)
+		}
+		usage = append(usage, WindowUsage{
+			Window:    w.name,
+			Scope:     w.scope,
+			Limit:     w.limit,
+			Used:      used,
+			Remaining: max(w.limit-used, 0),
+		})
+	}
+	return usage, nil
+}
+
+// Check returns a *LimitError if withdrawing amount micro-credits would take
+// the user or the platform over any enforced window
+func (s *Service) Check(userID int64, amount int64, l settings.WithdrawalLimits) error {
+	usage, err := s.Usage(userID, l)
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		if u.Used+amount > u.Limit {
+			return &LimitError{Window: u.Window, Scope: u.Scope, Requested: amount, Usage: usage}
+		}
+	}
+	return nil
+}
+
+// WithdrawableError is returned when a withdrawal would take credits that
+// cannot leave the platform yet. Amounts are in micro-credits.
+type WithdrawableError struct {
+	Requested    int64
+	Withdrawable int64
+	Bonus        int64 // Promotional credit not yet wagered
+	Pending      int64 // Market winnings not yet released
+	Unconfirmed  int64 // Deposits not yet confirmed on chain
+}
+
+func (e *WithdrawableError) Error() string {
+	var held []string
+	if e.Bonus > 0 {
+		held = append(held, fmt.Sprintf("%s credits are promotional bonus that must be wagered first", models.FormatMicroCredits(e.Bonus)))
+	}
+	if e.Pending > 0 {
+		held = append(held, fmt.Sprintf("%s credits are winnings not yet released", models.FormatMicroCredits(e.Pending)))
+	}
+	if e.Unconfirmed > 0 {
+		held = append(held, fmt.Sprintf("%s credits are deposits not yet confirmed", models.FormatMicroCredits(e.Unconfirmed)))
+	}
+	message := fmt.Sprintf("Only %s credits can be withdrawn", models.FormatMicroCredits(e.Withdrawable))
+	if len(held) == 0 {
+		return message
+	}
+	return message + "; " + strings.Join(held, ", ")
+}
+
+// CheckWithdrawable returns a *WithdrawableError if withdrawing amount
+// micro-credits would dip into the user's bonus, pending winnings or
+// unconfirmed deposits. Only settled deposited and won credits can leave the
+// platform.
+func (s *Service) CheckWithdrawable(user *models.User, amount int64) error {
+	if withdrawable := user.WithdrawableMicroCredits(); amount > withdrawable {
+		return &WithdrawableError{
+			Requested:    amount,
+			Withdrawable: withdrawable,
+			Bonus:        user.BonusBalance,
+			Pending:      user.PendingBalance,
+			Unconfirmed:  user.UnconfirmedBalance,
+		}
+	}
+	return nil
+}
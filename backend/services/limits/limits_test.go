@@ -0,0 +1,226 @@
+package limits
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+func createUser(t *testing.T, db *gorm.DB, name string) models.User {
+	t.Helper()
+	user := modelstesting.GenerateUser(name, 100000)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func createWithdrawal(t *testing.T, db *gorm.DB, userID int64, credits int64, status string, at time.Time) {
+	t.Helper()
+	req := models.WithdrawalRequest{UserID: userID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(credits), ToAddress: "0xabc", Status: status}
+	req.CreatedAt = at
+	if err := db.Create(&req).Error; err != nil {
+		t.Fatalf("create withdrawal request: %v", err)
+	}
+}
+
+func TestDailyLimitIsRolling24Hours(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	user := createUser(t, db, "limituser")
+	l := settings.WithdrawalLimits{Daily: models.CreditsToMicro(50000)}
+
+	// Just before midnight, and a request from two days ago
+	createWithdrawal(t, db, user.ID, 40000, models.TxStatusPending, fake.Now())
+	createWithdrawal(t, db, user.ID, 40000, models.TxStatusCompleted, fake.Now().Add(-48*time.Hour))
+
+	// An hour later it is a new UTC day, but the earlier request still counts
+	fake.Advance(time.Hour)
+	if err := svc.Check(user.ID, models.CreditsToMicro(10000), l); err != nil {
+		t.Fatalf("expected 10000 to fit within the daily limit, got %v", err)
+	}
+	err := svc.Check(user.ID, models.CreditsToMicro(10000)+1, l)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected LimitError after midnight, got %v", err)
+	}
+	if got := limitErr.Exceeded(); got.Window != WindowDaily || got.Used != models.CreditsToMicro(40000) {
+		t.Fatalf("expected 40000 credits used in the last 24h, got %+v", got)
+	}
+
+	// Exactly 24 hours after the request it drops out of the window
+	fake.Advance(23 * time.Hour)
+	if err := svc.Check(user.ID, models.CreditsToMicro(50000), l); err != nil {
+		t.Fatalf("expected limit to free up after 24 hours, got %v", err)
+	}
+}
+
+func TestRefundedStatusesDoNotCount(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	user := createUser(t, db, "refunded")
+	l := settings.WithdrawalLimits{Daily: models.CreditsToMicro(1000)}
+
+	for _, status := range []string{models.TxStatusRejected, models.TxStatusFailed, models.TxStatusCancelled, models.TxStatusExpired} {
+		createWithdrawal(t, db, user.ID, 1000, status, fake.Now().Add(-time.Hour))
+	}
+	for _, status := range models.WithdrawalCommittedStatuses {
+		createWithdrawal(t, db, user.ID, 100, status, fake.Now().Add(-time.Hour))
+	}
+
+	usage, err := svc.Usage(user.ID, l)
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	want := models.CreditsToMicro(100 * int64(len(models.WithdrawalCommittedStatuses)))
+	if len(usage) != 1 || usage[0].Used != want {
+		t.Fatalf("usage = %+v, want %d micro-credits from the committed requests only", usage, want)
+	}
+}
+
+func TestRollingWindowsAndBreakdown(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	user := createUser(t, db, "roller")
+	l := settings.WithdrawalLimits{
+		Daily:   models.CreditsToMicro(1500),
+		Weekly:  models.CreditsToMicro(2000),
+		Monthly: models.CreditsToMicro(3000),
+	}
+
+	createWithdrawal(t, db, user.ID, 900, models.TxStatusCompleted, fake.Now().Add(-3*24*time.Hour))
+	createWithdrawal(t, db, user.ID, 900, models.TxStatusCompleted, fake.Now().Add(-10*24*time.Hour))
+	createWithdrawal(t, db, user.ID, 900, models.TxStatusCompleted, fake.Now().Add(-20*24*time.Hour))
+	createWithdrawal(t, db, user.ID, 5000, models.TxStatusRejected, fake.Now().Add(-time.Hour))
+	createWithdrawal(t, db, user.ID, 5000, models.TxStatusCompleted, fake.Now().Add(-31*24*time.Hour))
+
+	// 2700 used over 30 days leaves 300; 900 over 7 days leaves 1100; nothing today
+	if err := svc.Check(user.ID, models.CreditsToMicro(300), l); err != nil {
+		t.Fatalf("expected 300 to fit, got %v", err)
+	}
+	err := svc.Check(user.ID, models.CreditsToMicro(301), l)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Window != WindowMonthly || limitErr.Scope != ScopeUser {
+		t.Fatalf("expected 30-day user limit error, got %v", err)
+	}
+	if len(limitErr.Usage) != 3 {
+		t.Fatalf("expected a breakdown of 3 windows, got %+v", limitErr.Usage)
+	}
+	for _, u := range limitErr.Usage {
+		want := map[string]int64{WindowDaily: 0, WindowWeekly: 900, WindowMonthly: 2700}[u.Window]
+		if u.Used != models.CreditsToMicro(want) || u.Remaining != u.Limit-u.Used {
+			t.Errorf("%s usage = %+v, want %d used", u.Window, u, want)
+		}
+	}
+
+	// Without a monthly limit the 7-day window is the binding one
+	l.Monthly = 0
+	err = svc.Check(user.ID, models.CreditsToMicro(1101), l)
+	if !errors.As(err, &limitErr) || limitErr.Window != WindowWeekly {
+		t.Fatalf("expected 7-day limit error, got %v", err)
+	}
+}
+
+func TestGlobalLimitCountsAllUsers(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	alice := createUser(t, db, "alice")
+	bob := createUser(t, db, "bob")
+	l := settings.WithdrawalLimits{Daily: models.CreditsToMicro(1000), GlobalDaily: models.CreditsToMicro(1500)}
+
+	createWithdrawal(t, db, alice.ID, 1000, models.TxStatusPending, fake.Now().Add(-time.Hour))
+
+	if err := svc.Check(bob.ID, models.CreditsToMicro(500), l); err != nil {
+		t.Fatalf("expected 500 to fit under the platform cap, got %v", err)
+	}
+	err := svc.Check(bob.ID, models.CreditsToMicro(600), l)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Scope != ScopePlatform {
+		t.Fatalf("expected platform limit error, got %v", err)
+	}
+	if limitErr.Error() != "Platform-wide daily withdrawal limit reached" || limitErr.Exceeded().Used != models.CreditsToMicro(1000) {
+		t.Errorf("unexpected error %q / %+v", limitErr.Error(), limitErr.Exceeded())
+	}
+}
+
+func TestCheckWithdrawableExcludesBonus(t *testing.T) {
+	svc := NewService(nil, clock.NewFake(time.Now()))
+	user := models.User{BonusBalance: models.CreditsToMicro(30)}
+	user.AccountBalance = models.CreditsToMicro(100)
+
+	if err := svc.CheckWithdrawable(&user, models.CreditsToMicro(70)); err != nil {
+		t.Errorf("withdrawing deposited credits: %v", err)
+	}
+	var withdrawableErr *WithdrawableError
+	if err := svc.CheckWithdrawable(&user, models.CreditsToMicro(71)); !errors.As(err, &withdrawableErr) ||
+		withdrawableErr.Withdrawable != models.CreditsToMicro(70) {
+		t.Errorf("withdrawing into bonus = %v", err)
+	}
+
+	// Wagering spends the bonus first
+	user.AddMicroCredits(-models.CreditsToMicro(40))
+	user.SpendBonus(models.CreditsToMicro(40))
+	if user.BonusBalance != 0 || svc.CheckWithdrawable(&user, models.CreditsToMicro(60)) != nil {
+		t.Errorf("after wagering bonus = %d, withdrawable = %d", user.BonusBalance, user.WithdrawableMicroCredits())
+	}
+}
+
+func TestWithdrawableErrorBreaksDownHeldCredits(t *testing.T) {
+	svc := NewService(nil, clock.NewFake(time.Now()))
+	tests := []struct {
+		name string
+		user models.User
+		want string
+	}{
+		{
+			name: "bonus",
+			user: models.User{BonusBalance: models.CreditsToMicro(30)},
+			want: "Only 70 credits can be withdrawn; 30 credits are promotional bonus that must be wagered first",
+		},
+		{
+			name: "pending winnings",
+			user: models.User{PendingBalance: models.CreditsToMicro(30)},
+			want: "Only 70 credits can be withdrawn; 30 credits are winnings not yet released",
+		},
+		{
+			name: "unconfirmed deposits",
+			user: models.User{UnconfirmedBalance: models.CreditsToMicro(30)},
+			want: "Only 70 credits can be withdrawn; 30 credits are deposits not yet confirmed",
+		},
+		{
+			name: "all three",
+			user: models.User{BonusBalance: models.CreditsToMicro(10), PendingBalance: models.CreditsToMicro(15), UnconfirmedBalance: models.CreditsToMicro(5)},
+			want: "Only 70 credits can be withdrawn; 10 credits are promotional bonus that must be wagered first, " +
+				"15 credits are winnings not yet released, 5 credits are deposits not yet confirmed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.AccountBalance = models.CreditsToMicro(100)
+			if err := svc.CheckWithdrawable(&user, models.CreditsToMicro(70)); err != nil {
+				t.Fatalf("withdrawing 70: %v", err)
+			}
+			err := svc.CheckWithdrawable(&user, models.CreditsToMicro(71))
+			var withdrawableErr *WithdrawableError
+			if !errors.As(err, &withdrawableErr) {
+				t.Fatalf("withdrawing 71 = %v, want a *WithdrawableError", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("message = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
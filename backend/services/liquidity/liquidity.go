@@ -0,0 +1,340 @@
+// Package liquidity lets users provide liquidity to a market. Provided
+// credits are escrowed in the ledger and deepen the market's pricing like the
+// initial subsidization, so bets move its probability less.
+//
+// A stake added at probability p stands in for p of it on YES and the rest on
+// NO. Withdrawn while the market is open it is worth exactly the stake. At
+// resolution the winning side is settled at the final probability P: a YES
+// resolution returns stake*p/P and a NO resolution stake*(1-p)/(1-P). A
+// provider gains when the market ends less sure of the right outcome than it
+// was when they added liquidity, and loses when traders moved it the right
+// way. Gains are paid from, and losses paid to, the market's winners.
+package liquidity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"socialpredict/clock"
+	marketmath "socialpredict/handlers/math/market"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const referenceType = "market"
+
+var (
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrMarketNotFound      = errors.New("market not found")
+	ErrMarketClosed        = errors.New("market is closed")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrBonusNotAllowed     = errors.New("promotional bonus credits cannot be provided as liquidity")
+	ErrInsufficientStake   = errors.New("amount exceeds your liquidity in this market")
+	ErrCategoricalMarket   = errors.New("liquidity can only be provided to binary markets")
+)
+
+// Service adds and withdraws market liquidity
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService creates a liquidity service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+// Add escrows amount micro-credits from the user into the market's pool.
+// Bonus credits cannot be provided, since an open stake is withdrawn at face
+// value.
+func (s *Service) Add(userID, marketID int64, amount int64) (*models.MarketLiquidity, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	var position models.MarketLiquidity
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.add(tx, userID, marketID, amount, &position)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// AddInTx escrows liquidity as part of the caller's transaction, for seeding
+// a market as it is created
+func (s *Service) AddInTx(tx *gorm.DB, userID, marketID int64, amount int64) (*models.MarketLiquidity, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	var position models.MarketLiquidity
+	if err := s.add(tx, userID, marketID, amount, &position); err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+func (s *Service) add(tx *gorm.DB, userID, marketID int64, amount int64, position *models.MarketLiquidity) error {
+	market, err := s.openMarket(tx, marketID)
+	if err != nil {
+		return err
+	}
+	if market.IsCategorical() {
+		return ErrCategoricalMarket
+	}
+	// Locked so the balance checked is the one debited
+	user, err := ledger.LockUser(tx, userID)
+	if err != nil {
+		return fmt.Errorf("provider: %w", err)
+	}
+	if user.BalanceMicroCredits() < amount {
+		return ErrInsufficientBalance
+	}
+	if user.WithdrawableMicroCredits() < amount {
+		return ErrBonusNotAllowed
+	}
+
+	probability := CurrentProbability(tx, market)
+	if err := loadPosition(tx, market.ID, user, position); err != nil {
+		return err
+	}
+	// Keep the stake's YES share when topping up at a different probability
+	position.Probability = (position.Probability*float64(position.Stake) + probability*float64(amount)) / float64(position.Stake+amount)
+	position.Stake += amount
+	if err := tx.Save(position).Error; err != nil {
+		return err
+	}
+
+	if err := s.recordEvent(tx, market.ID, user.ID, amount, probability); err != nil {
+		return err
+	}
+	_, err = ledger.Apply(tx, user, ledger.Posting{
+		Type:          models.LedgerTypeLiquidityAdd,
+		Amount:        -amount,
+		ReferenceType: referenceType,
+		ReferenceID:   uint(market.ID),
+		MarketID:      &market.ID,
+		Description:   fmt.Sprintf("Liquidity added to market #%d", market.ID),
+	})
+	return err
+}
+
+// Remove returns amount micro-credits of the user's stake from an open market
+func (s *Service) Remove(userID, marketID int64, amount int64) (*models.MarketLiquidity, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	var position models.MarketLiquidity
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		market, err := s.openMarket(tx, marketID)
+		if err != nil {
+			return err
+		}
+		// The provider and position are locked so two withdrawals cannot both
+		// pass the stake check
+		user, err := ledger.LockUser(tx, userID)
+		if err != nil {
+			return fmt.Errorf("provider: %w", err)
+		}
+		if err := loadPosition(tx, market.ID, user, &position); err != nil {
+			return err
+		}
+		if position.Stake < amount {
+			return ErrInsufficientStake
+		}
+		position.Stake -= amount
+		if err := tx.Save(&position).Error; err != nil {
+			return err
+		}
+
+		if err := s.recordEvent(tx, market.ID, user.ID, -amount, CurrentProbability(tx, market)); err != nil {
+			return err
+		}
+		_, err = ledger.Apply(tx, user, ledger.Posting{
+			Type:          models.LedgerTypeLiquidityRemove,
+			Amount:        amount,
+			ReferenceType: referenceType,
+			ReferenceID:   uint(market.ID),
+			MarketID:      &market.ID,
+			Description:   fmt.Sprintf("Liquidity withdrawn from market #%d", market.ID),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// Pool is a market's liquidity and its providers
+type Pool struct {
+	MarketID   int64                    `json:"marketId"`
+	Total      float64                  `json:"total"`      // Credits, rounded for display
+	TotalMicro int64                    `json:"totalMicro"` // Exact total in micro-credits
+	Providers  []models.MarketLiquidity `json:"providers"`
+}
+
+// Pool returns the market's liquidity providers, largest stake first
+func (s *Service) Pool(marketID int64) (*Pool, error) {
+	if err := s.db.Select("id").First(&models.Market{}, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMarketNotFound
+		}
+		return nil, err
+	}
+	pool := &Pool{MarketID: marketID, Providers: []models.MarketLiquidity{}}
+	if err := s.db.Where("market_id = ? AND (stake > 0 OR payout > 0)", marketID).
+		Order("stake DESC, id").Find(&pool.Providers).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range pool.Providers {
+		pool.TotalMicro += p.Stake
+	}
+	pool.Total = models.DisplayCredits(pool.TotalMicro)
+	return pool, nil
+}
+
+// CurrentProbability prices the market from its bets and liquidity
+func CurrentProbability(db *gorm.DB, market *models.Market) float64 {
+	bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
+	events := tradingdata.GetLiquidityForMarket(db, market.ID)
+	return marketmath.CurrentProbability(market, bets, events...)
+}
+
+// Settle returns each provider's stake with its P&L once the market has
+// resolved YES or NO. winnersPool is what the winning bettors are due in
+// micro-credits; Settle returns how much of it the providers took, which is
+// negative when their losses go to the winners. With no winners, stakes are
+// returned as they are.
+func Settle(tx *gorm.DB, market *models.Market, winnersPool int64, now time.Time) (int64, error) {
+	positions, err := unsettled(tx, market.ID)
+	if err != nil || len(positions) == 0 {
+		return 0, err
+	}
+
+	final := CurrentProbability(tx, market)
+	returns := make([]int64, len(positions))
+	var gains, losses int64
+	for i, p := range positions {
+		returns[i] = p.Stake
+		if winnersPool <= 0 || final <= 0 || final >= 1 {
+			continue
+		}
+		switch market.ResolutionResult {
+		case "YES":
+			returns[i] = int64(math.Round(float64(p.Stake) * p.Probability / final))
+		case "NO":
+			returns[i] = int64(math.Round(float64(p.Stake) * (1 - p.Probability) / (1 - final)))
+		}
+		if returns[i] > p.Stake {
+			gains += returns[i] - p.Stake
+		} else {
+			losses += p.Stake - returns[i]
+		}
+	}
+
+	// Gains are limited to what the winners and losing providers can pay
+	if gains-losses > winnersPool {
+		for i, p := range positions {
+			if gain := returns[i] - p.Stake; gain > 0 {
+				returns[i] = p.Stake + gain*(winnersPool+losses)/gains
+			}
+		}
+	}
+
+	var net int64
+	for i := range positions {
+		net += returns[i] - positions[i].Stake
+		if err := pay(tx, market, &positions[i], returns[i], now,
+			fmt.Sprintf("Liquidity returned from market #%d, resolved %s", market.ID, market.ResolutionResult)); err != nil {
+			return 0, err
+		}
+	}
+	return net, nil
+}
+
+// Refund returns each provider's stake in full, for a market resolved N/A
+func Refund(tx *gorm.DB, market *models.Market, now time.Time) error {
+	positions, err := unsettled(tx, market.ID)
+	if err != nil {
+		return err
+	}
+	for i := range positions {
+		if err := pay(tx, market, &positions[i], positions[i].Stake, now,
+			fmt.Sprintf("Liquidity refunded from market #%d, resolved N/A", market.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unsettled(tx *gorm.DB, marketID int64) ([]models.MarketLiquidity, error) {
+	var positions []models.MarketLiquidity
+	err := tx.Where("market_id = ? AND stake > 0 AND settled_at IS NULL", marketID).
+		Order("id").Find(&positions).Error
+	return positions, err
+}
+
+func pay(tx *gorm.DB, market *models.Market, position *models.MarketLiquidity, amount int64, now time.Time, description string) error {
+	if err := tx.Model(position).Updates(map[string]interface{}{"payout": amount, "settled_at": now}).Error; err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return nil
+	}
+	var user models.User
+	if err := tx.First(&user, position.UserID).Error; err != nil {
+		return fmt.Errorf("provider: %w", err)
+	}
+	_, err := ledger.Apply(tx, &user, ledger.Posting{
+		Type:          models.LedgerTypeLiquidityReturn,
+		Amount:        amount,
+		ReferenceType: referenceType,
+		ReferenceID:   uint(market.ID),
+		MarketID:      &market.ID,
+		Description:   description,
+	})
+	return err
+}
+
+// openMarket loads a market that is still taking bets
+func (s *Service) openMarket(tx *gorm.DB, marketID int64) (*models.Market, error) {
+	var market models.Market
+	if err := tx.First(&market, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMarketNotFound
+		}
+		return nil, err
+	}
+	if market.IsResolved || !market.ResolutionDateTime.After(s.clock.Now()) {
+		return nil, ErrMarketClosed
+	}
+	return &market, nil
+}
+
+// loadPosition loads and locks the user's position in the market, or an empty
+// one if they have none
+func loadPosition(tx *gorm.DB, marketID int64, user *models.User, position *models.MarketLiquidity) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("market_id = ? AND user_id = ?", marketID, user.ID).First(position).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		*position = models.MarketLiquidity{MarketID: marketID, UserID: user.ID, Username: user.Username}
+		return nil
+	}
+	return err
+}
+
+func (s *Service) recordEvent(tx *gorm.DB, marketID, userID, amount int64, probability float64) error {
+	return tx.Create(&models.LiquidityEvent{
+		MarketID:    marketID,
+		UserID:      userID,
+		Amount:      amount,
+		Probability: probability,
+		CreatedAt:   s.clock.Now(),
+	}).Error
+}
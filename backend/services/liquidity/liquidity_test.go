@@ -0,0 +1,153 @@
+package liquidity
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func setup(t *testing.T) (*gorm.DB, *clock.Fake, *Service, *models.User) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	provider := modelstesting.GenerateUser("provider", 1000)
+	if err := db.Create(&provider).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, fake, NewService(db, fake), &provider
+}
+
+func createMarket(t *testing.T, db *gorm.DB, id int64) *models.Market {
+	t.Helper()
+	market := modelstesting.GenerateMarket(id, "provider")
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+	return &market
+}
+
+func TestLiquidityDeepensMarketWithoutMovingIt(t *testing.T) {
+	db, fake, svc, provider := setup(t)
+	deep := createMarket(t, db, 1)
+	shallow := createMarket(t, db, 2)
+
+	before := CurrentProbability(db, deep)
+	if _, err := svc.Add(provider.ID, deep.ID, models.CreditsToMicro(500)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if after := CurrentProbability(db, deep); math.Abs(after-before) > 1e-9 {
+		t.Errorf("probability moved from %f to %f", before, after)
+	}
+
+	var user models.User
+	db.First(&user, provider.ID)
+	if user.AccountBalance != models.CreditsToMicro(500) {
+		t.Errorf("balance after escrow = %d, want 500", user.AccountBalance)
+	}
+
+	for _, market := range []*models.Market{deep, shallow} {
+		bet := modelstesting.GenerateBet(50, "YES", "provider", uint(market.ID), 0)
+		bet.PlacedAt = fake.Now().Add(time.Minute)
+		db.Create(&bet)
+	}
+	moveDeep := CurrentProbability(db, deep) - before
+	moveShallow := CurrentProbability(db, shallow) - before
+	if moveDeep <= 0 || moveDeep >= moveShallow {
+		t.Errorf("bet moved the deep market %f and the shallow one %f", moveDeep, moveShallow)
+	}
+}
+
+func TestRemoveLiquidity(t *testing.T) {
+	db, fake, svc, provider := setup(t)
+	market := createMarket(t, db, 1)
+
+	svc.Add(provider.ID, market.ID, models.CreditsToMicro(300))
+	if _, err := svc.Remove(provider.ID, market.ID, models.CreditsToMicro(301)); !errors.Is(err, ErrInsufficientStake) {
+		t.Errorf("over-withdrawal = %v, want ErrInsufficientStake", err)
+	}
+	position, err := svc.Remove(provider.ID, market.ID, models.CreditsToMicro(300))
+	if err != nil || position.Stake != 0 {
+		t.Fatalf("Remove = %+v, %v", position, err)
+	}
+	var user models.User
+	db.First(&user, provider.ID)
+	if user.AccountBalance != models.CreditsToMicro(1000) {
+		t.Errorf("balance after withdrawal = %d, want 1000", user.AccountBalance)
+	}
+
+	fake.Advance(48 * time.Hour)
+	if _, err := svc.Add(provider.ID, market.ID, models.CreditsToMicro(10)); !errors.Is(err, ErrMarketClosed) {
+		t.Errorf("add to closed market = %v, want ErrMarketClosed", err)
+	}
+}
+
+func TestBonusCreditsCannotBeProvided(t *testing.T) {
+	db, _, svc, provider := setup(t)
+	market := createMarket(t, db, 1)
+	db.Model(provider).Update("bonus_balance", models.CreditsToMicro(900))
+
+	if _, err := svc.Add(provider.ID, market.ID, models.CreditsToMicro(200)); !errors.Is(err, ErrBonusNotAllowed) {
+		t.Errorf("Add = %v, want ErrBonusNotAllowed", err)
+	}
+	if _, err := svc.Add(provider.ID, market.ID, models.CreditsToMicro(100)); err != nil {
+		t.Errorf("Add within withdrawable balance: %v", err)
+	}
+}
+
+func TestConcurrentRemovesCannotOverdrawStake(t *testing.T) {
+	db, _, svc, provider := setup(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	market := createMarket(t, db, 1)
+	if _, err := svc.Add(provider.ID, market.ID, models.CreditsToMicro(500)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// Only one of two 300 credit withdrawals fits the 500 credit stake
+	errs := make(chan error, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Remove(provider.ID, market.ID, models.CreditsToMicro(300))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var removed, refused int
+	for err := range errs {
+		switch {
+		case err == nil:
+			removed++
+		case errors.Is(err, ErrInsufficientStake):
+			refused++
+		default:
+			t.Errorf("Remove: %v", err)
+		}
+	}
+	if removed != 1 || refused != 1 {
+		t.Errorf("removed %d, refused %d; want one of each", removed, refused)
+	}
+
+	var user models.User
+	db.First(&user, provider.ID)
+	var position models.MarketLiquidity
+	db.Where("market_id = ? AND user_id = ?", market.ID, provider.ID).First(&position)
+	if user.AccountBalance != models.CreditsToMicro(800) || position.Stake != models.CreditsToMicro(200) {
+		t.Errorf("balance %d, stake %d; want 800 and 200 credits", user.AccountBalance, position.Stake)
+	}
+}
@@ -0,0 +1,76 @@
+// Package mailer sends transactional email, such as withdrawal confirmation
+// codes, over SMTP.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Mailer sends a plain-text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends email through an SMTP relay
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer for the relay at host:port. auth may be nil.
+func NewSMTPMailer(host, port, from string, auth smtp.Auth) *SMTPMailer {
+	return &SMTPMailer{addr: net.JoinHostPort(host, port), from: from, auth: auth}
+}
+
+// Send implements Mailer
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid recipient or subject")
+	}
+	msg := "From: " + m.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+}
+
+// LogMailer is used when no SMTP relay is configured. It logs that an email
+// was dropped, without its body, which may hold a secret.
+type LogMailer struct{}
+
+// Send implements Mailer
+func (LogMailer) Send(to, subject, body string) error {
+	log.Printf("Mailer: SMTP_HOST not set, dropping email %q to %s", subject, to)
+	return nil
+}
+
+// FromEnv returns an SMTP mailer configured by SMTP_HOST, SMTP_PORT (default
+// 587), SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM, or a LogMailer if
+// SMTP_HOST is not set
+func FromEnv() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogMailer{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@" + host
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return NewSMTPMailer(host, port, from, auth)
+}
@@ -0,0 +1,222 @@
+// Package marketclose moves markets through their lifecycle on a schedule.
+// A market closes at its close time: it is marked CLOSED, stops taking bets
+// and its resting limit orders expire. Its creator is then reminded to
+// resolve it until they do, and admins are told once it has gone unresolved
+// for longer than the overdue period.
+package marketclose
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultReminderInterval = 24 * time.Hour
+	defaultOverdueAfter     = 72 * time.Hour
+	batchSize               = 200
+)
+
+// Config controls creator reminders and admin escalation
+type Config struct {
+	ReminderInterval time.Duration // How often the creator is reminded; zero sends only the closing notice
+	OverdueAfter     time.Duration // How long after closing admins are told; zero never escalates
+}
+
+// LoadConfigFromEnv reads MARKET_RESOLVE_REMINDER_INTERVAL and
+// MARKET_OVERDUE_AFTER, as Go durations such as "24h"
+func LoadConfigFromEnv() Config {
+	config := Config{ReminderInterval: defaultReminderInterval, OverdueAfter: defaultOverdueAfter}
+	if d, err := time.ParseDuration(os.Getenv("MARKET_RESOLVE_REMINDER_INTERVAL")); err == nil && d >= 0 {
+		config.ReminderInterval = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("MARKET_OVERDUE_AFTER")); err == nil && d >= 0 {
+		config.OverdueAfter = d
+	}
+	return config
+}
+
+// Scheduler closes markets and chases their resolution
+type Scheduler struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewScheduler creates a close scheduler
+func NewScheduler(db *gorm.DB, config Config, c clock.Clock) *Scheduler {
+	return &Scheduler{db: db, config: config, clock: c}
+}
+
+// Result counts what one pass did
+type Result struct {
+	Closed    int `json:"closed"`
+	Reminded  int `json:"reminded"`
+	Escalated int `json:"escalated"`
+}
+
+// Tick runs one pass: closing due markets, reminding creators and escalating
+// overdue markets
+func (s *Scheduler) Tick() (Result, error) {
+	var result Result
+	var err error
+	if result.Closed, err = s.CloseDue(); err != nil {
+		return result, fmt.Errorf("closing: %w", err)
+	}
+	if result.Reminded, err = s.Remind(); err != nil {
+		return result, fmt.Errorf("reminding: %w", err)
+	}
+	if result.Escalated, err = s.Escalate(); err != nil {
+		return result, fmt.Errorf("escalating: %w", err)
+	}
+	return result, nil
+}
+
+// CloseDue closes the open markets whose close time has passed
+func (s *Scheduler) CloseDue() (int, error) {
+	now := s.clock.Now()
+	var due []models.Market
+	if err := s.db.Where("is_resolved = ? AND closed_at IS NULL AND resolution_date_time <= ?", false, now).
+		Order("resolution_date_time").Limit(batchSize).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	closed := 0
+	for i := range due {
+		ok, err := s.close(&due[i], now)
+		if err != nil {
+			return closed, fmt.Errorf("market %d: %w", due[i].ID, err)
+		}
+		if ok {
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+// close marks one market closed, unless a resolution or another pass got to
+// it first. The update waits for any bet holding the market's row (see
+// betutils.LockOpenMarket), so no bet lands after it.
+func (s *Scheduler) close(market *models.Market, now time.Time) (bool, error) {
+	closed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Market{}).
+			Where("id = ? AND is_resolved = ? AND closed_at IS NULL", market.ID, false).
+			Updates(map[string]interface{}{"closed_at": now, "resolve_reminded_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		closed = true
+
+		if err := tx.Model(&models.MarketOrder{}).
+			Where("market_id = ? AND status = ?", market.ID, models.OrderStatusOpen).
+			Updates(map[string]interface{}{"status": models.OrderStatusExpired, "closed_at": now}).Error; err != nil {
+			return err
+		}
+
+		creator, err := creatorOf(tx, market)
+		if err != nil {
+			return err
+		}
+		return notify.Send(tx, creator.ID, notify.TypeMarketClosed, "Market closed",
+			fmt.Sprintf("Your market #%d \"%s\" has closed to new bets. Please resolve it.", market.ID, market.QuestionTitle))
+	})
+	return closed, err
+}
+
+// Remind reminds the creators of closed, unresolved markets to resolve them,
+// once every reminder interval. Markets an oracle is resolving are skipped.
+func (s *Scheduler) Remind() (int, error) {
+	if s.config.ReminderInterval <= 0 {
+		return 0, nil
+	}
+	now := s.clock.Now()
+	var due []models.Market
+	if err := s.db.Where("is_resolved = ? AND closed_at IS NOT NULL AND resolve_reminded_at <= ?", false, now.Add(-s.config.ReminderInterval)).
+		Where("id NOT IN (?)", s.db.Model(&models.MarketOracleConfig{}).Select("market_id").
+			Where("status IN ?", []string{models.OracleStatusPending, models.OracleStatusProposed})).
+		Order("id").Limit(batchSize).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	reminded := 0
+	for i := range due {
+		market := &due[i]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(market).UpdateColumn("resolve_reminded_at", now).Error; err != nil {
+				return err
+			}
+			creator, err := creatorOf(tx, market)
+			if err != nil {
+				return err
+			}
+			return notify.Send(tx, creator.ID, notify.TypeResolveReminder, "Market awaiting resolution",
+				fmt.Sprintf("Your market #%d \"%s\" closed %s ago and has not been resolved yet.",
+					market.ID, market.QuestionTitle, now.Sub(*market.ClosedAt).Round(time.Hour)))
+		})
+		if err != nil {
+			return reminded, fmt.Errorf("market %d: %w", market.ID, err)
+		}
+		reminded++
+	}
+	return reminded, nil
+}
+
+// Escalate tells admins about markets left unresolved for the overdue
+// period since closing. Each market is escalated once.
+func (s *Scheduler) Escalate() (int, error) {
+	if s.config.OverdueAfter <= 0 {
+		return 0, nil
+	}
+	now := s.clock.Now()
+	var overdue []models.Market
+	if err := s.db.Where("is_resolved = ? AND overdue_escalated_at IS NULL AND closed_at <= ?", false, now.Add(-s.config.OverdueAfter)).
+		Order("id").Limit(batchSize).Find(&overdue).Error; err != nil {
+		return 0, err
+	}
+	escalated := 0
+	for i := range overdue {
+		market := &overdue[i]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(market).UpdateColumn("overdue_escalated_at", now).Error; err != nil {
+				return err
+			}
+			return notify.Admins(tx, notify.TypeMarketOverdue, "Market overdue for resolution",
+				fmt.Sprintf("Market #%d \"%s\" by %s closed %s ago and is still unresolved.",
+					market.ID, market.QuestionTitle, market.CreatorUsername, now.Sub(*market.ClosedAt).Round(time.Hour)))
+		})
+		if err != nil {
+			return escalated, fmt.Errorf("market %d: %w", market.ID, err)
+		}
+		escalated++
+	}
+	return escalated, nil
+}
+
+func creatorOf(tx *gorm.DB, market *models.Market) (*models.User, error) {
+	var creator models.User
+	if err := tx.Where("username = ?", market.CreatorUsername).First(&creator).Error; err != nil {
+		return nil, fmt.Errorf("creator: %w", err)
+	}
+	return &creator, nil
+}
+
+// Run makes a pass every interval
+func (s *Scheduler) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		result, err := s.Tick()
+		if err != nil {
+			log.Printf("MarketClose: pass failed: %v", err)
+		}
+		if result.Closed > 0 || result.Reminded > 0 || result.Escalated > 0 {
+			log.Printf("MarketClose: closed %d, reminded %d, escalated %d", result.Closed, result.Reminded, result.Escalated)
+		}
+	}
+}
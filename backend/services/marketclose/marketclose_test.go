@@ -0,0 +1,79 @@
+package marketclose
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	betutils "socialpredict/handlers/bets/betutils"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/notify"
+)
+
+func TestSchedulerClosesRemindsAndEscalates(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	creator := modelstesting.GenerateUser("creator", 0)
+	admin := modelstesting.GenerateUser("admin", 0)
+	admin.UserType = "ADMIN"
+	db.Create(&creator)
+	db.Create(&admin)
+
+	now := time.Now()
+	fake := clock.NewFake(now)
+	due := modelstesting.GenerateMarket(1, "creator")
+	due.ResolutionDateTime = now.Add(-time.Minute)
+	open := modelstesting.GenerateMarket(2, "creator")
+	open.ResolutionDateTime = now.Add(30 * 24 * time.Hour)
+	db.Create(&due)
+	db.Create(&open)
+	order := models.MarketOrder{MarketID: 1, Status: models.OrderStatusOpen, UserID: creator.ID, Username: "creator",
+		Side: models.OrderSideBuy, Outcome: "YES", LimitPrice: 0.4, Amount: 20}
+	db.Create(&order)
+
+	s := NewScheduler(db, Config{ReminderInterval: 24 * time.Hour, OverdueAfter: 72 * time.Hour}, fake)
+	result, err := s.Tick()
+	if err != nil || result != (Result{Closed: 1}) {
+		t.Fatalf("first pass = %+v, %v", result, err)
+	}
+	db.First(&due, due.ID)
+	if due.ClosedAt == nil || due.Status(fake.Now()) != models.MarketStatusClosed {
+		t.Fatalf("market not closed: %+v", due)
+	}
+	db.First(&order, order.ID)
+	if order.Status != models.OrderStatusExpired {
+		t.Errorf("order status = %s", order.Status)
+	}
+
+	// Closed markets take no more bets
+	if err := betutils.LockOpenMarket(db, uint(due.ID)); !errors.Is(err, betutils.ErrMarketClosed) {
+		t.Fatalf("bet on closed market: err = %v", err)
+	}
+	if err := betutils.LockOpenMarket(db, uint(open.ID)); err != nil {
+		t.Fatalf("bet on open market: %v", err)
+	}
+
+	// A day later the creator is reminded; three days later admins are told
+	fake.Advance(25 * time.Hour)
+	if result, err := s.Tick(); err != nil || result != (Result{Reminded: 1}) {
+		t.Fatalf("second pass = %+v, %v", result, err)
+	}
+	fake.Advance(48 * time.Hour)
+	if result, err := s.Tick(); err != nil || result != (Result{Reminded: 1, Escalated: 1}) {
+		t.Fatalf("third pass = %+v, %v", result, err)
+	}
+	if result, err := s.Tick(); err != nil || result != (Result{}) {
+		t.Fatalf("repeat pass = %+v, %v", result, err)
+	}
+
+	counts := map[string]int64{}
+	for _, typ := range []string{notify.TypeMarketClosed, notify.TypeResolveReminder, notify.TypeMarketOverdue} {
+		var n int64
+		db.Model(&models.Notification{}).Where("type = ?", typ).Count(&n)
+		counts[typ] = n
+	}
+	if counts[notify.TypeMarketClosed] != 1 || counts[notify.TypeResolveReminder] != 2 || counts[notify.TypeMarketOverdue] != 1 {
+		t.Fatalf("notifications = %v", counts)
+	}
+}
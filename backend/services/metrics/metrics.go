@@ -0,0 +1,216 @@
+// Package metrics exposes Prometheus metrics for the wallet, webhook and DFNS
+// subsystems. Counters are updated where the events happen; withdrawal
+// figures are read from the database at scrape time.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "socialpredict"
+
+// Webhook event results
+const (
+	ResultProcessed = "processed"
+	ResultFailed    = "failed"
+	ResultRejected  = "rejected"  // Bad signature or payload
+	ResultIgnored   = "ignored"   // Event kind not handled
+	ResultDuplicate = "duplicate" // Event ID already processed, e.g. a replay
+)
+
+// Registry holds every socialpredict metric plus the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// WebhookEvents counts DFNS webhook events by kind and result
+	WebhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "events_total",
+		Help:      "DFNS webhook events received, by event kind and result.",
+	}, []string{"kind", "result"})
+
+	// DepositsCredited counts deposits credited to user balances
+	DepositsCredited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "wallet",
+		Name:      "deposits_credited_total",
+		Help:      "Deposits credited to user balances, by chain and token.",
+	}, []string{"chain", "token"})
+
+	// DepositCreditsCredited sums the credits of credited deposits
+	DepositCreditsCredited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "wallet",
+		Name:      "deposit_credits_total",
+		Help:      "Credits added to user balances by deposits, by chain and token.",
+	}, []string{"chain", "token"})
+
+	// DustDeposits counts deposits below the token's minimum, recorded but not credited
+	DustDeposits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "wallet",
+		Name:      "dust_deposits_total",
+		Help:      "Deposits below the token's minimum, recorded but not credited, by chain and token.",
+	}, []string{"chain", "token"})
+
+	// DFNSRequestDuration observes DFNS API latency
+	DFNSRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "dfns",
+		Name:      "request_duration_seconds",
+		Help:      "DFNS API request latency, by method, endpoint and status code.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"method", "endpoint", "code"})
+
+	// DFNSRequestErrors counts failed DFNS API requests
+	DFNSRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dfns",
+		Name:      "request_errors_total",
+		Help:      "DFNS API requests that failed in transport or returned a non-2xx status, by method and endpoint.",
+	}, []string{"method", "endpoint", "code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		WebhookEvents,
+		DepositsCredited,
+		DepositCreditsCredited,
+		DustDeposits,
+		DFNSRequestDuration,
+		DFNSRequestErrors,
+	)
+}
+
+// RecordDepositCredited counts a deposit credited to a user's balance
+func RecordDepositCredited(chain, token string, amountMicro int64) {
+	DepositsCredited.WithLabelValues(chain, token).Inc()
+	DepositCreditsCredited.WithLabelValues(chain, token).Add(models.DisplayCredits(amountMicro))
+}
+
+// ObserveDFNSRequest records one DFNS API call. A status of 0 means the
+// request failed before a response arrived.
+func ObserveDFNSRequest(method, path string, status int, elapsed time.Duration) {
+	endpoint := EndpointLabel(path)
+	code := "error"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	DFNSRequestDuration.WithLabelValues(method, endpoint, code).Observe(elapsed.Seconds())
+	if status < 200 || status >= 300 {
+		DFNSRequestErrors.WithLabelValues(method, endpoint, code).Inc()
+	}
+}
+
+// EndpointLabel replaces the identifiers in an API path with ":id" so each
+// endpoint is a single label value, e.g. /wallets/wa-123/transfers becomes
+// /wallets/:id/transfers
+func EndpointLabel(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment != "" && strings.IndexFunc(segment, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// walletCollector reads withdrawal counts and the approval queue from the
+// database on each scrape
+type walletCollector struct {
+	db           *gorm.DB
+	withdrawals  *prometheus.Desc
+	queueDepth   *prometheus.Desc
+	queueCredits *prometheus.Desc
+	scrapeErrors prometheus.Counter
+}
+
+// RegisterWalletCollector adds the withdrawal gauges, read from db at scrape time
+func RegisterWalletCollector(db *gorm.DB) error {
+	return Registry.Register(&walletCollector{
+		db: db,
+		withdrawals: prometheus.NewDesc(prometheus.BuildFQName(namespace, "wallet", "withdrawals"),
+			"Withdrawal requests by status.", []string{"status"}, nil),
+		queueDepth: prometheus.NewDesc(prometheus.BuildFQName(namespace, "wallet", "pending_withdrawals"),
+			"Withdrawal requests awaiting admin review (PENDING or ON_HOLD).", nil, nil),
+		queueCredits: prometheus.NewDesc(prometheus.BuildFQName(namespace, "wallet", "pending_withdrawal_credits"),
+			"Credits in withdrawal requests awaiting admin review.", nil, nil),
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "wallet",
+			Name:      "collector_errors_total",
+			Help:      "Database errors while collecting withdrawal metrics.",
+		}),
+	})
+}
+
+func (c *walletCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.withdrawals
+	ch <- c.queueDepth
+	ch <- c.queueCredits
+	c.scrapeErrors.Describe(ch)
+}
+
+func (c *walletCollector) Collect(ch chan<- prometheus.Metric) {
+	defer c.scrapeErrors.Collect(ch)
+
+	var byStatus []struct {
+		Status string
+		Count  int64
+	}
+	if err := c.db.Model(&models.WithdrawalRequest{}).Select("status, COUNT(*) AS count").
+		Group("status").Scan(&byStatus).Error; err != nil {
+		c.scrapeErrors.Inc()
+		return
+	}
+	for _, row := range byStatus {
+		ch <- prometheus.MustNewConstMetric(c.withdrawals, prometheus.GaugeValue, float64(row.Count), row.Status)
+	}
+
+	var queue struct {
+		Count  int64
+		Amount int64
+	}
+	if err := c.db.Model(&models.WithdrawalRequest{}).Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("status IN ?", []string{models.TxStatusPending, models.TxStatusOnHold}).Scan(&queue).Error; err != nil {
+		c.scrapeErrors.Inc()
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(queue.Count))
+	ch <- prometheus.MustNewConstMetric(c.queueCredits, prometheus.GaugeValue, models.DisplayCredits(queue.Amount))
+}
+
+// Handler serves the registry to callers presenting "Authorization: Bearer
+// <token>". With no token configured the endpoint is disabled.
+func Handler(token string) http.Handler {
+	metricsHandler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		metricsHandler.ServeHTTP(w, r)
+	})
+}
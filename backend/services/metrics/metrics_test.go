@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	cases := map[string]string{
+		"/wallets":                             "/wallets",
+		"/wallets/wa-5f3k2/transfers":          "/wallets/:id/transfers",
+		"/wallets/wa-5f3k2/transfers/xfr-9":    "/wallets/:id/transfers/:id",
+		"/wallets?network=EthereumMainnet":     "/wallets",
+		"/wallets/wa-5f3k2/transactions/tx-12": "/wallets/:id/transactions/:id",
+	}
+	for path, want := range cases {
+		if got := EndpointLabel(path); got != want {
+			t.Errorf("EndpointLabel(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestHandlerRequiresTokenAndReportsWithdrawals(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	for _, status := range []string{models.TxStatusPending, models.TxStatusPending, models.TxStatusOnHold, models.TxStatusCompleted} {
+		req := models.WithdrawalRequest{UserID: 1, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+			Amount: models.CreditsToMicro(100), ToAddress: "0xabc", Status: status}
+		if err := db.Create(&req).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+	if err := RegisterWalletCollector(db); err != nil {
+		t.Fatalf("register collector: %v", err)
+	}
+	ObserveDFNSRequest("POST", "/wallets/wa-1/transfers", 500, 120*time.Millisecond)
+	RecordDepositCredited("ethereum", "USDC", models.CreditsToMicro(25))
+
+	handler := Handler("metrics-token")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer metrics-token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`socialpredict_wallet_withdrawals{status="PENDING"} 2`,
+		`socialpredict_wallet_pending_withdrawals 3`,
+		`socialpredict_wallet_pending_withdrawal_credits 300`,
+		`socialpredict_dfns_request_errors_total{code="500",endpoint="/wallets/:id/transfers",method="POST"} 1`,
+		`socialpredict_wallet_deposit_credits_total{chain="ethereum",token="USDC"} 25`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestHandlerDisabledWithoutToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 with no token configured, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,476 @@
+// Package moderation handles user reports about markets and the actions
+// moderators take on them: closing a market with refunds, editing it,
+// deleting it, warning its creator or dismissing the reports. Every action
+// is recorded in the audit log, and the latest action on a market clears it
+// from the queue until it is reported or flagged again.
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/handlers/math/payout"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	ActionReportsDismissed = "MARKET_REPORTS_DISMISSED"
+	ActionMarketClosed     = "MARKET_CLOSED_BY_MODERATOR"
+	ActionMarketEdited     = "MARKET_EDITED_BY_MODERATOR"
+	ActionMarketDeleted    = "MARKET_DELETED_BY_MODERATOR"
+	ActionCreatorWarned    = "MARKET_CREATOR_WARNED"
+)
+
+// actions are the audit actions that count as reviewing a market
+var actions = []string{ActionReportsDismissed, ActionMarketClosed, ActionMarketEdited, ActionMarketDeleted, ActionCreatorWarned}
+
+const (
+	targetMarket     = "market"
+	targetUser       = "user"
+	maxDetailsLength = 1000
+	maxTitleLength   = 160
+	maxDescription   = 2000
+)
+
+var (
+	ErrMarketNotFound  = errors.New("market not found")
+	ErrInvalidReason   = errors.New("reason must be SPAM, OFFENSIVE, MISLEADING, DUPLICATE or OTHER")
+	ErrDetailsTooLong  = fmt.Errorf("details must be at most %d characters", maxDetailsLength)
+	ErrAlreadyReported = errors.New("you have already reported this market")
+	ErrNoteRequired    = errors.New("a reason for the action is required")
+	ErrAlreadyResolved = errors.New("market is already resolved")
+	ErrNothingToEdit   = errors.New("nothing to edit")
+	ErrInvalidTitle    = fmt.Errorf("question title must be 1 to %d characters", maxTitleLength)
+	ErrInvalidDesc     = fmt.Errorf("description must be at most %d characters", maxDescription)
+	ErrHasOpenBets     = errors.New("market has bets that have not been paid out or refunded; close it first")
+	ErrNoOpenReports   = errors.New("market has no open reports")
+	ErrMessageRequired = errors.New("a warning message is required")
+	ErrCreatorNotFound = errors.New("market creator not found")
+)
+
+// Reasons lists the reasons a market can be reported for
+var Reasons = []string{
+	models.ReportReasonSpam,
+	models.ReportReasonOffensive,
+	models.ReportReasonMisleading,
+	models.ReportReasonDuplicate,
+	models.ReportReasonOther,
+}
+
+// Service handles reports and moderation actions
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService returns a moderation service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+// QueueItem is a market waiting for a moderator
+type QueueItem struct {
+	MarketID        int64          `json:"marketId"`
+	QuestionTitle   string         `json:"questionTitle"`
+	CreatorUsername string         `json:"creatorUsername"`
+	IsResolved      bool           `json:"isResolved"`
+	OpenReports     int            `json:"openReports"`
+	Reasons         map[string]int `json:"reasons"`
+	LastReportedAt  *time.Time     `json:"lastReportedAt,omitempty"`
+	WashTradeFlags  int            `json:"washTradeFlags"`  // Flags detected since the market was last reviewed
+	CreatorWarnings int64          `json:"creatorWarnings"` // Warnings the creator has had for any market
+	LastReviewedAt  *time.Time     `json:"lastReviewedAt,omitempty"`
+}
+
+// MarketEdit changes a market's wording. Nil fields are left as they are.
+type MarketEdit struct {
+	QuestionTitle *string `json:"questionTitle,omitempty"`
+	Description   *string `json:"description,omitempty"`
+}
+
+func validReason(reason string) bool {
+	for _, r := range Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) market(tx *gorm.DB, marketID int64) (*models.Market, error) {
+	var market models.Market
+	if err := tx.First(&market, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMarketNotFound
+		}
+		return nil, err
+	}
+	return &market, nil
+}
+
+// Report files the user's report about a market
+func (s *Service) Report(user *models.User, marketID int64, reason, details string) (*models.MarketReport, error) {
+	reason = strings.ToUpper(strings.TrimSpace(reason))
+	if !validReason(reason) {
+		return nil, ErrInvalidReason
+	}
+	details = strings.TrimSpace(details)
+	if len(details) > maxDetailsLength {
+		return nil, ErrDetailsTooLong
+	}
+	if _, err := s.market(s.db, marketID); err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	if err := s.db.Model(&models.MarketReport{}).Where("market_id = ? AND reporter_id = ?", marketID, user.ID).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrAlreadyReported
+	}
+
+	report := models.MarketReport{
+		MarketID:   marketID,
+		ReporterID: user.ID,
+		Reason:     reason,
+		Details:    details,
+		Status:     models.ReportStatusOpen,
+	}
+	if err := s.db.Create(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// lastReviewed returns when a moderator last acted on the market
+func (s *Service) lastReviewed(marketID int64) (*time.Time, error) {
+	var entry models.AuditLog
+	err := s.db.Where("target_type = ? AND target_id = ? AND action IN ?", targetMarket, marketID, actions).
+		Order("created_at DESC").First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry.CreatedAt, nil
+}
+
+// Queue returns the markets with open reports or with wash trading flagged
+// since they were last reviewed, most reported first
+func (s *Service) Queue() ([]QueueItem, error) {
+	items := make(map[int64]*QueueItem)
+	item := func(marketID int64) *QueueItem {
+		if items[marketID] == nil {
+			items[marketID] = &QueueItem{MarketID: marketID, Reasons: make(map[string]int)}
+		}
+		return items[marketID]
+	}
+
+	var reports []models.MarketReport
+	if err := s.db.Where("status = ?", models.ReportStatusOpen).Order("id").Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	for _, report := range reports {
+		it := item(report.MarketID)
+		it.OpenReports++
+		it.Reasons[report.Reason]++
+		reportedAt := report.CreatedAt
+		it.LastReportedAt = &reportedAt
+	}
+
+	var flagged []int64
+	if err := s.db.Model(&models.WashTradeFlag{}).Distinct().Pluck("market_id", &flagged).Error; err != nil {
+		return nil, err
+	}
+	for _, marketID := range flagged {
+		reviewed, err := s.lastReviewed(marketID)
+		if err != nil {
+			return nil, err
+		}
+		query := s.db.Model(&models.WashTradeFlag{}).Where("market_id = ?", marketID)
+		if reviewed != nil {
+			query = query.Where("detected_at > ?", *reviewed)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			item(marketID).WashTradeFlags = int(count)
+		}
+	}
+
+	result := make([]QueueItem, 0, len(items))
+	for marketID, it := range items {
+		market, err := s.market(s.db, marketID)
+		if errors.Is(err, ErrMarketNotFound) {
+			continue // Deleted
+		}
+		if err != nil {
+			return nil, err
+		}
+		it.QuestionTitle = market.QuestionTitle
+		it.CreatorUsername = market.CreatorUsername
+		it.IsResolved = market.IsResolved
+		if it.LastReviewedAt, err = s.lastReviewed(marketID); err != nil {
+			return nil, err
+		}
+		var creator models.User
+		if err := s.db.Where("username = ?", market.CreatorUsername).First(&creator).Error; err == nil {
+			s.db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ? AND action = ?", targetUser, creator.ID, ActionCreatorWarned).
+				Count(&it.CreatorWarnings)
+		}
+		result = append(result, *it)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].OpenReports != result[b].OpenReports {
+			return result[a].OpenReports > result[b].OpenReports
+		}
+		if result[a].WashTradeFlags != result[b].WashTradeFlags {
+			return result[a].WashTradeFlags > result[b].WashTradeFlags
+		}
+		return result[a].MarketID < result[b].MarketID
+	})
+	return result, nil
+}
+
+// closeReports marks the market's open reports as reviewed
+func (s *Service) closeReports(tx *gorm.DB, marketID int64, status, admin string) (int64, error) {
+	now := s.clock.Now()
+	result := tx.Model(&models.MarketReport{}).Where("market_id = ? AND status = ?", marketID, models.ReportStatusOpen).
+		Updates(map[string]interface{}{"status": status, "reviewed_by": admin, "reviewed_at": now})
+	return result.RowsAffected, result.Error
+}
+
+func notifyCreator(tx *gorm.DB, market *models.Market, notificationType, title, message string) (*models.User, error) {
+	var creator models.User
+	if err := tx.Where("username = ?", market.CreatorUsername).First(&creator).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCreatorNotFound
+		}
+		return nil, err
+	}
+	return &creator, notify.Send(tx, creator.ID, notificationType, title, message)
+}
+
+func requireNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return "", ErrNoteRequired
+	}
+	if len(note) > maxDetailsLength {
+		return "", ErrDetailsTooLong
+	}
+	return note, nil
+}
+
+// Dismiss closes the market's open reports without acting on the market
+func (s *Service) Dismiss(admin string, marketID int64, note string) (int64, error) {
+	var dismissed int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.market(tx, marketID); err != nil {
+			return err
+		}
+		var err error
+		if dismissed, err = s.closeReports(tx, marketID, models.ReportStatusDismissed, admin); err != nil {
+			return err
+		}
+		if dismissed == 0 {
+			return ErrNoOpenReports
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionReportsDismissed,
+			TargetType: targetMarket,
+			TargetID:   uint(marketID),
+			Details:    fmt.Sprintf("reports=%d note=%q", dismissed, note),
+		})
+	})
+	return dismissed, err
+}
+
+// Close takes a market down by resolving it N/A, which refunds every bet
+func (s *Service) Close(admin string, marketID int64, note string) (*models.Market, error) {
+	note, err := requireNote(note)
+	if err != nil {
+		return nil, err
+	}
+	var market *models.Market
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if market, err = s.market(tx, marketID); err != nil {
+			return err
+		}
+		if market.IsResolved {
+			return ErrAlreadyResolved
+		}
+		if err := payout.Void(tx, market, admin, note, s.clock.Now()); err != nil {
+			return fmt.Errorf("failed to refund bets: %w", err)
+		}
+		reports, err := s.closeReports(tx, marketID, models.ReportStatusActioned, admin)
+		if err != nil {
+			return err
+		}
+		if _, err := notifyCreator(tx, market, notify.TypeMarketModerated, "Market closed by a moderator",
+			fmt.Sprintf("Your market #%d was closed by a moderator and its bets were refunded. Reason: %s", market.ID, note)); err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionMarketClosed,
+			TargetType: targetMarket,
+			TargetID:   uint(marketID),
+			Details:    fmt.Sprintf("reports=%d note=%q", reports, note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return market, nil
+}
+
+// Edit rewords a market. The audit log keeps the previous wording.
+func (s *Service) Edit(admin string, marketID int64, edit MarketEdit, note string) (*models.Market, error) {
+	note, err := requireNote(note)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(map[string]interface{})
+	if edit.QuestionTitle != nil {
+		title := strings.TrimSpace(*edit.QuestionTitle)
+		if title == "" || len(title) > maxTitleLength {
+			return nil, ErrInvalidTitle
+		}
+		updates["question_title"] = title
+	}
+	if edit.Description != nil {
+		if len(*edit.Description) > maxDescription {
+			return nil, ErrInvalidDesc
+		}
+		updates["description"] = *edit.Description
+	}
+	if len(updates) == 0 {
+		return nil, ErrNothingToEdit
+	}
+
+	var market *models.Market
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if market, err = s.market(tx, marketID); err != nil {
+			return err
+		}
+		before := fmt.Sprintf("title=%q description=%q", market.QuestionTitle, market.Description)
+		if err := tx.Model(market).Updates(updates).Error; err != nil {
+			return err
+		}
+		reports, err := s.closeReports(tx, marketID, models.ReportStatusActioned, admin)
+		if err != nil {
+			return err
+		}
+		if _, err := notifyCreator(tx, market, notify.TypeMarketModerated, "Market edited by a moderator",
+			fmt.Sprintf("A moderator edited your market #%d. Reason: %s", market.ID, note)); err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionMarketEdited,
+			TargetType: targetMarket,
+			TargetID:   uint(marketID),
+			Details:    fmt.Sprintf("reports=%d note=%q before: %s", reports, note, before),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return market, nil
+}
+
+// Delete removes a market from listings. Markets with unsettled bets must
+// be closed first, so nobody's stake disappears with them.
+func (s *Service) Delete(admin string, marketID int64, note string) error {
+	note, err := requireNote(note)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		market, err := s.market(tx, marketID)
+		if err != nil {
+			return err
+		}
+		if !market.IsResolved {
+			var bets int64
+			if err := tx.Model(&models.Bet{}).Where("market_id = ?", marketID).Count(&bets).Error; err != nil {
+				return err
+			}
+			if bets > 0 {
+				return ErrHasOpenBets
+			}
+		}
+		reports, err := s.closeReports(tx, marketID, models.ReportStatusActioned, admin)
+		if err != nil {
+			return err
+		}
+		if _, err := notifyCreator(tx, market, notify.TypeMarketModerated, "Market removed by a moderator",
+			fmt.Sprintf("Your market #%d was removed by a moderator. Reason: %s", market.ID, note)); err != nil {
+			return err
+		}
+		if err := tx.Delete(market).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionMarketDeleted,
+			TargetType: targetMarket,
+			TargetID:   uint(marketID),
+			Details:    fmt.Sprintf("reports=%d note=%q title=%q", reports, note, market.QuestionTitle),
+		})
+	})
+}
+
+// Warn sends the market's creator a moderation warning. It is recorded
+// against the creator as well as the market, so repeat offenders show up.
+func (s *Service) Warn(admin string, marketID int64, message string) error {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return ErrMessageRequired
+	}
+	if len(message) > maxDetailsLength {
+		return ErrDetailsTooLong
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		market, err := s.market(tx, marketID)
+		if err != nil {
+			return err
+		}
+		creator, err := notifyCreator(tx, market, notify.TypeModerationWarning, "Moderation warning",
+			fmt.Sprintf("A moderator warned you about your market #%d: %s", market.ID, message))
+		if err != nil {
+			return err
+		}
+		reports, err := s.closeReports(tx, marketID, models.ReportStatusActioned, admin)
+		if err != nil {
+			return err
+		}
+		details := fmt.Sprintf("market=%d reports=%d message=%q", marketID, reports, message)
+		if err := audit.Record(tx, models.AuditLog{
+			Actor: admin, Action: ActionCreatorWarned, TargetType: targetUser, TargetID: uint(creator.ID), Details: details,
+		}); err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor: admin, Action: ActionCreatorWarned, TargetType: targetMarket, TargetID: uint(marketID), Details: details,
+		})
+	})
+}
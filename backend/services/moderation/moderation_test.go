@@ -0,0 +1,152 @@
+package moderation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/notify"
+)
+
+func setup(t *testing.T) (*Service, *models.User, *models.User, models.Market) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	creator := modelstesting.GenerateUser("creator", 0)
+	reporter := modelstesting.GenerateUser("reporter", 1000)
+	db.Create(&creator)
+	db.Create(&reporter)
+	market := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&market)
+	return NewService(db, clock.New()), &creator, &reporter, market
+}
+
+func TestReportAndQueue(t *testing.T) {
+	svc, _, reporter, market := setup(t)
+
+	if _, err := svc.Report(reporter, market.ID, "boring", ""); !errors.Is(err, ErrInvalidReason) {
+		t.Fatalf("invalid reason: err = %v", err)
+	}
+	if _, err := svc.Report(reporter, 99, models.ReportReasonSpam, ""); !errors.Is(err, ErrMarketNotFound) {
+		t.Fatalf("unknown market: err = %v", err)
+	}
+	if _, err := svc.Report(reporter, market.ID, "spam", "advertising"); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if _, err := svc.Report(reporter, market.ID, models.ReportReasonOther, ""); !errors.Is(err, ErrAlreadyReported) {
+		t.Fatalf("second report: err = %v", err)
+	}
+	second := modelstesting.GenerateUser("second", 0)
+	svc.db.Create(&second)
+	if _, err := svc.Report(&second, market.ID, models.ReportReasonMisleading, ""); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+
+	// A wash-traded market joins the queue without reports
+	flagged := modelstesting.GenerateMarket(2, "creator")
+	svc.db.Create(&flagged)
+	svc.db.Create(&models.WashTradeFlag{MarketID: 2, Username: "reporter", Counterparty: "reporter",
+		Pattern: models.WashPatternSelfRoundTrip, BetID: 1, CounterBetID: 2, Volume: 20, DetectedAt: time.Now()})
+
+	queue, err := svc.Queue()
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	if len(queue) != 2 || queue[0].MarketID != market.ID || queue[0].OpenReports != 2 || queue[1].WashTradeFlags != 1 {
+		t.Fatalf("queue = %+v", queue)
+	}
+	if queue[0].Reasons[models.ReportReasonSpam] != 1 || queue[0].Reasons[models.ReportReasonMisleading] != 1 {
+		t.Fatalf("reasons = %v", queue[0].Reasons)
+	}
+
+	// Reviewing clears both from the queue
+	if n, err := svc.Dismiss("admin", market.ID, "fine"); err != nil || n != 2 {
+		t.Fatalf("dismiss: %d, %v", n, err)
+	}
+	if err := svc.Warn("admin", flagged.ID, "stop trading with yourself"); err != nil {
+		t.Fatalf("warn: %v", err)
+	}
+	queue, err = svc.Queue()
+	if err != nil || len(queue) != 0 {
+		t.Fatalf("queue after review = %+v, %v", queue, err)
+	}
+	if _, err := svc.Dismiss("admin", market.ID, ""); !errors.Is(err, ErrNoOpenReports) {
+		t.Fatalf("dismiss again: err = %v", err)
+	}
+}
+
+func TestCloseRefundsBets(t *testing.T) {
+	svc, creator, reporter, market := setup(t)
+	bet := modelstesting.GenerateBet(100, "YES", reporter.Username, uint(market.ID), 0)
+	svc.db.Create(&bet)
+	svc.db.Model(reporter).Update("account_balance", reporter.AccountBalance-models.CreditsToMicro(100))
+	if _, err := svc.Report(reporter, market.ID, models.ReportReasonOffensive, ""); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+
+	if err := svc.Delete("admin", market.ID, "offensive"); !errors.Is(err, ErrHasOpenBets) {
+		t.Fatalf("delete with bets: err = %v", err)
+	}
+	if _, err := svc.Close("admin", market.ID, ""); !errors.Is(err, ErrNoteRequired) {
+		t.Fatalf("close without reason: err = %v", err)
+	}
+	closed, err := svc.Close("admin", market.ID, "offensive")
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !closed.IsResolved || closed.ResolutionResult != "N/A" {
+		t.Fatalf("closed market = %+v", closed)
+	}
+	if _, err := svc.Close("admin", market.ID, "again"); !errors.Is(err, ErrAlreadyResolved) {
+		t.Fatalf("close twice: err = %v", err)
+	}
+
+	var refunded models.User
+	svc.db.First(&refunded, reporter.ID)
+	if refunded.AccountBalance != models.CreditsToMicro(1000) {
+		t.Fatalf("balance after refund = %d, want 1000", refunded.AccountBalance)
+	}
+	var report models.MarketReport
+	svc.db.First(&report)
+	if report.Status != models.ReportStatusActioned || report.ReviewedBy != "admin" {
+		t.Fatalf("report = %+v", report)
+	}
+	var notified int64
+	svc.db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", creator.ID, notify.TypeMarketModerated).Count(&notified)
+	if notified != 1 {
+		t.Fatalf("creator notifications = %d", notified)
+	}
+
+	// Refunded markets can be removed
+	if err := svc.Delete("admin", market.ID, "offensive"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var audits int64
+	svc.db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ?", "market", market.ID).Count(&audits)
+	if audits != 2 {
+		t.Fatalf("audit entries = %d, want 2", audits)
+	}
+}
+
+func TestEditKeepsPreviousWording(t *testing.T) {
+	svc, _, _, market := setup(t)
+	long := string(make([]byte, maxTitleLength+1))
+	if _, err := svc.Edit("admin", market.ID, MarketEdit{QuestionTitle: &long}, "typo"); !errors.Is(err, ErrInvalidTitle) {
+		t.Fatalf("long title: err = %v", err)
+	}
+	if _, err := svc.Edit("admin", market.ID, MarketEdit{}, "typo"); !errors.Is(err, ErrNothingToEdit) {
+		t.Fatalf("empty edit: err = %v", err)
+	}
+	title := "Will it rain tomorrow?"
+	edited, err := svc.Edit("admin", market.ID, MarketEdit{QuestionTitle: &title}, "clearer wording")
+	if err != nil || edited.QuestionTitle != title {
+		t.Fatalf("edit: %+v, %v", edited, err)
+	}
+	var entry models.AuditLog
+	svc.db.Where("action = ?", ActionMarketEdited).First(&entry)
+	if entry.Details == "" || entry.TargetID != uint(market.ID) {
+		t.Fatalf("audit entry = %+v", entry)
+	}
+}
@@ -0,0 +1,66 @@
+// Package notify delivers in-app notifications to users.
+package notify
+
+import (
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// Notification type constants
+const (
+	TypeBalanceCorrection   = "BALANCE_CORRECTION"
+	TypeWithdrawalCompleted = "WITHDRAWAL_COMPLETED"
+	TypeWithdrawalFailed    = "WITHDRAWAL_FAILED"
+	TypeWithdrawalAdjusted  = "WITHDRAWAL_ADJUSTED"
+	TypeDepositReversed     = "DEPOSIT_REVERSED"
+	TypeTreasuryAlert       = "TREASURY_ALERT"
+	TypeWithdrawalsFrozen   = "WITHDRAWALS_FROZEN"
+	TypeWithdrawalsUnfrozen = "WITHDRAWALS_UNFROZEN"
+	TypeCreditTransfer      = "CREDIT_TRANSFER"
+	TypeBonusGranted        = "BONUS_GRANTED"
+	TypeOrderFilled         = "ORDER_FILLED"
+	TypeOrderCancelled      = "ORDER_CANCELLED"
+	TypeMarketVoided        = "MARKET_VOIDED"
+	TypeOracleProposed      = "ORACLE_PROPOSED"
+	TypeDisputeOpened       = "DISPUTE_OPENED"
+	TypeDisputeClosed       = "DISPUTE_CLOSED"
+	TypeMarketModerated     = "MARKET_MODERATED"
+	TypeModerationWarning   = "MODERATION_WARNING"
+	TypeMarketClosed        = "MARKET_CLOSED"
+	TypeResolveReminder     = "RESOLVE_REMINDER"
+	TypeMarketOverdue       = "MARKET_OVERDUE"
+	TypeSeriesMarket        = "SERIES_MARKET_OPENED"
+	TypeGroupRemoved        = "GROUP_REMOVED"
+)
+
+// Send stores a notification for a user
+func Send(db *gorm.DB, userID int64, notificationType, title, message string) error {
+	return db.Create(&models.Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+	}).Error
+}
+
+// Admins stores a notification for every admin user
+func Admins(db *gorm.DB, notificationType, title, message string) error {
+	var adminIDs []int64
+	if err := db.Model(&models.User{}).Where("user_type = ?", "ADMIN").Pluck("id", &adminIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range adminIDs {
+		if err := Send(db, id, notificationType, title, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns a user's most recent notifications
+func List(db *gorm.DB, userID int64, limit int) ([]models.Notification, error) {
+	var notifications []models.Notification
+	err := db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
@@ -0,0 +1,555 @@
+// Package oracle resolves markets automatically from external data feeds,
+// such as a price API for crypto price markets or a sports scores API.
+//
+// An admin configures a market's oracle: a JSON endpoint, the path to a value
+// in its response and how that value maps to an outcome. Once the market
+// closes the feed is read and the outcome proposed. Admins can approve,
+// override or reject the proposal during the review window; when the window
+// ends unreviewed, the market resolves to the proposed outcome and pays out.
+package oracle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/handlers/math/payout"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/conditional"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	ActionConfigured = "MARKET_ORACLE_CONFIGURED"
+	ActionApproved   = "MARKET_ORACLE_APPROVED"
+	ActionRejected   = "MARKET_ORACLE_REJECTED"
+)
+
+const (
+	referenceType       = "market_oracle"
+	defaultReviewWindow = 24 * time.Hour
+	maxAttempts         = 10
+	fetchTimeout        = 10 * time.Second
+	maxResponseBytes    = 1 << 20
+)
+
+var (
+	ErrMarketNotFound   = errors.New("market not found")
+	ErrMarketResolved   = errors.New("market is already resolved")
+	ErrOracleNotFound   = errors.New("oracle not found")
+	ErrNotProposed      = errors.New("oracle has no outcome awaiting review")
+	ErrInvalidSource    = errors.New("source must be PRICE or SCORE")
+	ErrInvalidURL       = errors.New("url must be an http or https URL")
+	ErrInvalidField     = errors.New("field is required")
+	ErrInvalidCompare   = errors.New("comparator must be GT, GTE, LT, LTE or EQ")
+	ErrInvalidTarget    = errors.New("target is not valid for this source and market")
+	ErrInvalidOutcome   = errors.New("outcome is not an outcome of the market")
+	ErrOracleInProgress = errors.New("oracle has already proposed an outcome")
+)
+
+// Config controls the review window
+type Config struct {
+	ReviewWindow time.Duration // How long admins have to review a proposed outcome
+}
+
+// LoadConfigFromEnv reads ORACLE_REVIEW_WINDOW, a duration such as "24h"
+func LoadConfigFromEnv() Config {
+	config := Config{ReviewWindow: defaultReviewWindow}
+	if d, err := time.ParseDuration(os.Getenv("ORACLE_REVIEW_WINDOW")); err == nil && d >= 0 {
+		config.ReviewWindow = d
+	}
+	return config
+}
+
+// Service configures oracles and resolves their markets
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+	client *http.Client
+}
+
+// NewService creates an oracle service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c, client: &http.Client{Timeout: fetchTimeout}}
+}
+
+// ConfigureInput describes a market's data source
+type ConfigureInput struct {
+	Source     string
+	URL        string
+	Field      string
+	Comparator string
+	Target     string
+}
+
+// Configure sets the market's oracle, replacing any earlier configuration
+// that has not yet proposed an outcome
+func (s *Service) Configure(marketID int64, in ConfigureInput, admin string) (*models.MarketOracleConfig, error) {
+	in.Source = strings.ToUpper(strings.TrimSpace(in.Source))
+	in.Comparator = strings.ToUpper(strings.TrimSpace(in.Comparator))
+	in.Field = strings.TrimSpace(in.Field)
+	in.Target = strings.TrimSpace(in.Target)
+
+	var cfg models.MarketOracleConfig
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var market models.Market
+		if err := tx.First(&market, marketID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMarketNotFound
+			}
+			return err
+		}
+		if market.IsResolved {
+			return ErrMarketResolved
+		}
+		if err := validate(&market, &in); err != nil {
+			return err
+		}
+
+		err := tx.Where("market_id = ?", marketID).First(&cfg).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if cfg.Status == models.OracleStatusProposed {
+			return ErrOracleInProgress
+		}
+		cfg = models.MarketOracleConfig{
+			Model:        cfg.Model,
+			ID:           cfg.ID,
+			MarketID:     marketID,
+			Source:       in.Source,
+			URL:          in.URL,
+			Field:        in.Field,
+			Comparator:   in.Comparator,
+			Target:       in.Target,
+			Status:       models.OracleStatusPending,
+			ConfiguredBy: admin,
+		}
+		if err := tx.Save(&cfg).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionConfigured,
+			TargetType: referenceType,
+			TargetID:   cfg.ID,
+			Details:    fmt.Sprintf("market=%d source=%s url=%q field=%q comparator=%s target=%q", marketID, cfg.Source, cfg.URL, cfg.Field, cfg.Comparator, cfg.Target),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate checks a configuration against the market it resolves
+func validate(market *models.Market, in *ConfigureInput) error {
+	if u, err := url.Parse(in.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if in.Field == "" {
+		return ErrInvalidField
+	}
+
+	switch in.Source {
+	case models.OracleSourcePrice:
+		if market.IsCategorical() {
+			return ErrInvalidTarget
+		}
+		switch in.Comparator {
+		case models.OracleCompareGT, models.OracleCompareGTE, models.OracleCompareLT, models.OracleCompareLTE, models.OracleCompareEQ:
+		default:
+			return ErrInvalidCompare
+		}
+		if _, err := strconv.ParseFloat(in.Target, 64); err != nil {
+			return ErrInvalidTarget
+		}
+	case models.OracleSourceScore:
+		// A categorical market resolves to the reported result itself
+		in.Comparator = models.OracleCompareEQ
+		if market.IsCategorical() != (in.Target == "") {
+			return ErrInvalidTarget
+		}
+	default:
+		return ErrInvalidSource
+	}
+	return nil
+}
+
+// Get returns a market's oracle
+func (s *Service) Get(marketID int64) (*models.MarketOracleConfig, error) {
+	var cfg models.MarketOracleConfig
+	if err := s.db.Where("market_id = ?", marketID).First(&cfg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOracleNotFound
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// List returns oracles, optionally with the given status, soonest review first
+func (s *Service) List(status string) ([]models.MarketOracleConfig, error) {
+	query := s.db.Model(&models.MarketOracleConfig{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	list := []models.MarketOracleConfig{}
+	err := query.Order("review_ends_at IS NULL, review_ends_at, id").Limit(100).Find(&list).Error
+	return list, err
+}
+
+// Check reads the feed of every pending oracle whose market has closed and
+// proposes its outcome. Oracles whose market was resolved some other way are
+// cancelled. It returns how many outcomes were proposed.
+func (s *Service) Check() (int, error) {
+	var pending []models.MarketOracleConfig
+	if err := s.db.Where("status IN ?", []string{models.OracleStatusPending, models.OracleStatusProposed}).
+		Order("id").Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	now := s.clock.Now()
+	proposed := 0
+	for i := range pending {
+		cfg := &pending[i]
+		var market models.Market
+		if err := s.db.First(&market, cfg.MarketID).Error; err != nil {
+			continue
+		}
+		if market.IsResolved {
+			s.db.Model(cfg).Update("status", models.OracleStatusCancelled)
+			continue
+		}
+		if cfg.Status != models.OracleStatusPending || market.ResolutionDateTime.After(now) {
+			continue
+		}
+
+		if err := s.propose(cfg, &market, now); err != nil {
+			log.Printf("Oracle: market %d: %v", market.ID, err)
+			continue
+		}
+		proposed++
+	}
+	return proposed, nil
+}
+
+// propose reads the oracle's feed and records the outcome for review, or
+// the failure to read it
+func (s *Service) propose(cfg *models.MarketOracleConfig, market *models.Market, now time.Time) error {
+	value, err := s.observe(cfg)
+	var outcome string
+	if err == nil {
+		outcome, err = Evaluate(cfg, market, outcomeLabels(s.db, market), value)
+	}
+	if err != nil {
+		updates := map[string]interface{}{"attempts": cfg.Attempts + 1, "last_error": err.Error(), "checked_at": now}
+		if cfg.Attempts+1 >= maxAttempts {
+			updates["status"] = models.OracleStatusFailed
+		}
+		if updateErr := s.db.Model(cfg).Updates(updates).Error; updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	reviewEnds := now.Add(s.config.ReviewWindow)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(cfg).Updates(map[string]interface{}{
+			"status":           models.OracleStatusProposed,
+			"observed_value":   value,
+			"proposed_outcome": outcome,
+			"attempts":         cfg.Attempts + 1,
+			"last_error":       "",
+			"checked_at":       now,
+			"review_ends_at":   reviewEnds,
+		}).Error; err != nil {
+			return err
+		}
+		var creator models.User
+		if err := tx.Where("username = ?", market.CreatorUsername).First(&creator).Error; err != nil {
+			return fmt.Errorf("creator: %w", err)
+		}
+		return notify.Send(tx, creator.ID, notify.TypeOracleProposed, "Market outcome proposed",
+			fmt.Sprintf("The oracle for your market #%d reported %q and proposes %s. It resolves at %s unless an admin intervenes.",
+				market.ID, value, outcome, reviewEnds.UTC().Format(time.RFC3339)))
+	})
+}
+
+// observe fetches the oracle's URL and returns the value at its field
+func (s *Service) observe(cfg *models.MarketOracleConfig) (string, error) {
+	resp, err := s.client.Get(cfg.URL)
+	if err != nil {
+		return "", fmt.Errorf("fetch: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch: status %d", resp.StatusCode)
+	}
+
+	var body interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode: %w", err)
+	}
+	return lookup(body, cfg.Field)
+}
+
+// lookup walks a dot-separated path through decoded JSON; numeric segments
+// index arrays
+func lookup(node interface{}, path string) (string, error) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return "", fmt.Errorf("field %q not found", path)
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", fmt.Errorf("field %q not found", path)
+			}
+			node = v[i]
+		default:
+			return "", fmt.Errorf("field %q not found", path)
+		}
+	}
+
+	switch v := node.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("field %q is not a value", path)
+	}
+}
+
+// Evaluate maps an observed value to the market's outcome. labels are a
+// categorical market's outcome labels.
+func Evaluate(cfg *models.MarketOracleConfig, market *models.Market, labels []string, value string) (string, error) {
+	if cfg.Source == models.OracleSourceScore {
+		if market.IsCategorical() {
+			for _, label := range labels {
+				if strings.EqualFold(label, strings.TrimSpace(value)) {
+					return label, nil
+				}
+			}
+			return "", fmt.Errorf("result %q is not an outcome of the market", value)
+		}
+		return yesNo(strings.EqualFold(strings.TrimSpace(value), cfg.Target)), nil
+	}
+
+	observed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return "", fmt.Errorf("value %q is not a number", value)
+	}
+	target, err := strconv.ParseFloat(cfg.Target, 64)
+	if err != nil {
+		return "", ErrInvalidTarget
+	}
+	switch cfg.Comparator {
+	case models.OracleCompareGT:
+		return yesNo(observed > target), nil
+	case models.OracleCompareGTE:
+		return yesNo(observed >= target), nil
+	case models.OracleCompareLT:
+		return yesNo(observed < target), nil
+	case models.OracleCompareLTE:
+		return yesNo(observed <= target), nil
+	case models.OracleCompareEQ:
+		return yesNo(observed == target), nil
+	default:
+		return "", ErrInvalidCompare
+	}
+}
+
+func yesNo(yes bool) string {
+	if yes {
+		return "YES"
+	}
+	return "NO"
+}
+
+// Finalize resolves every market whose proposal has outlived its review
+// window and returns how many it resolved
+func (s *Service) Finalize() (int, error) {
+	var due []models.MarketOracleConfig
+	if err := s.db.Where("status = ? AND review_ends_at <= ?", models.OracleStatusProposed, s.clock.Now()).
+		Order("review_ends_at").Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	for i := range due {
+		if _, err := s.resolve(&due[i], due[i].ProposedOutcome, ""); err != nil {
+			log.Printf("Oracle: resolving market %d failed: %v", due[i].MarketID, err)
+			continue
+		}
+		resolved++
+	}
+	return resolved, nil
+}
+
+// Approve resolves the market at once, to the proposed outcome or to the
+// admin's override
+func (s *Service) Approve(id uint, admin, override string) (*models.MarketOracleConfig, error) {
+	var cfg models.MarketOracleConfig
+	if err := s.db.First(&cfg, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOracleNotFound
+		}
+		return nil, err
+	}
+	outcome := cfg.ProposedOutcome
+	if override = strings.TrimSpace(override); override != "" {
+		outcome = override
+	}
+	return s.resolve(&cfg, outcome, admin)
+}
+
+// Reject discards the proposal; the market is then resolved by hand
+func (s *Service) Reject(id uint, admin, note string) (*models.MarketOracleConfig, error) {
+	var cfg models.MarketOracleConfig
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&cfg, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOracleNotFound
+			}
+			return err
+		}
+		result := tx.Model(&cfg).Where("status = ?", models.OracleStatusProposed).
+			Updates(map[string]interface{}{"status": models.OracleStatusRejected, "reviewed_by": admin})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotProposed
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionRejected,
+			TargetType: referenceType,
+			TargetID:   cfg.ID,
+			Details:    fmt.Sprintf("market=%d proposed=%s observed=%q note=%q", cfg.MarketID, cfg.ProposedOutcome, cfg.ObservedValue, note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, s.db.First(&cfg, cfg.ID).Error
+}
+
+// resolve resolves the oracle's market to outcome and pays it out, then
+// resolves N/A any markets conditional on it that no longer can be met.
+// reviewer is empty when the review window ran out.
+func (s *Service) resolve(cfg *models.MarketOracleConfig, outcome, reviewer string) (*models.MarketOracleConfig, error) {
+	now := s.clock.Now()
+	var market models.Market
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&market, cfg.MarketID).Error; err != nil {
+			return ErrMarketNotFound
+		}
+		if market.IsResolved {
+			return ErrMarketResolved
+		}
+		if !isOutcome(&market, outcomeLabels(tx, &market), outcome) {
+			return ErrInvalidOutcome
+		}
+		if err := conditional.CheckResolution(tx, &market, outcome); err != nil {
+			return err
+		}
+
+		result := tx.Model(cfg).Where("status = ?", models.OracleStatusProposed).Updates(map[string]interface{}{
+			"status":           models.OracleStatusResolved,
+			"proposed_outcome": outcome,
+			"reviewed_by":      reviewer,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotProposed
+		}
+
+		if err := payout.Resolve(tx, &market, outcome, now); err != nil {
+			if errors.Is(err, payout.ErrAlreadyResolved) {
+				return ErrMarketResolved
+			}
+			return err
+		}
+		if reviewer == "" {
+			return nil
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      reviewer,
+			Action:     ActionApproved,
+			TargetType: referenceType,
+			TargetID:   cfg.ID,
+			Details:    fmt.Sprintf("market=%d proposed=%s resolved=%s observed=%q", market.ID, cfg.ProposedOutcome, outcome, cfg.ObservedValue),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conditional.Cascade(s.db, &market, now); err != nil {
+		log.Printf("Oracle: resolving markets conditional on market %d failed: %v", market.ID, err)
+	}
+	return cfg, s.db.First(cfg, cfg.ID).Error
+}
+
+func outcomeLabels(db *gorm.DB, market *models.Market) []string {
+	if !market.IsCategorical() {
+		return nil
+	}
+	var labels []string
+	db.Model(&models.MarketOutcome{}).Where("market_id = ?", market.ID).Order("position").Pluck("label", &labels)
+	return labels
+}
+
+func isOutcome(market *models.Market, labels []string, outcome string) bool {
+	if outcome == "N/A" {
+		return true
+	}
+	if !market.IsCategorical() {
+		return outcome == "YES" || outcome == "NO"
+	}
+	for _, label := range labels {
+		if label == outcome {
+			return true
+		}
+	}
+	return false
+}
+
+// Run checks feeds and finalizes reviewed proposals every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.Check(); err != nil {
+			log.Printf("Oracle: check failed: %v", err)
+		}
+		if _, err := s.Finalize(); err != nil {
+			log.Printf("Oracle: finalize failed: %v", err)
+		}
+	}
+}
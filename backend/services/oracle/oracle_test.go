@@ -0,0 +1,174 @@
+package oracle
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestEvaluate(t *testing.T) {
+	binary := &models.Market{OutcomeType: models.OutcomeTypeBinary}
+	categorical := &models.Market{OutcomeType: models.OutcomeTypeCategorical}
+	labels := []string{"Home", "Away", "Draw"}
+
+	tests := []struct {
+		name   string
+		cfg    models.MarketOracleConfig
+		market *models.Market
+		value  string
+		want   string
+	}{
+		{"price above threshold", models.MarketOracleConfig{Source: models.OracleSourcePrice, Comparator: models.OracleCompareGTE, Target: "65000"}, binary, "70000.5", "YES"},
+		{"price below threshold", models.MarketOracleConfig{Source: models.OracleSourcePrice, Comparator: models.OracleCompareGT, Target: "65000"}, binary, "65000", "NO"},
+		{"score matches target", models.MarketOracleConfig{Source: models.OracleSourceScore, Target: "home"}, binary, "Home", "YES"},
+		{"score names an outcome", models.MarketOracleConfig{Source: models.OracleSourceScore}, categorical, "draw", "Draw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Evaluate(&tt.cfg, tt.market, labels, tt.value)
+			if err != nil || got != tt.want {
+				t.Errorf("Evaluate = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Evaluate(&models.MarketOracleConfig{Source: models.OracleSourceScore}, categorical, labels, "Abandoned"); err == nil {
+		t.Error("expected error for a result that is not an outcome")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	body := map[string]interface{}{
+		"data": []interface{}{map[string]interface{}{"price": 42.5, "final": true}},
+	}
+	if got, err := lookup(body, "data.0.price"); err != nil || got != "42.5" {
+		t.Errorf("lookup price = %q, %v", got, err)
+	}
+	if got, err := lookup(body, "data.0.final"); err != nil || got != "true" {
+		t.Errorf("lookup final = %q, %v", got, err)
+	}
+	if _, err := lookup(body, "data.1.price"); err == nil {
+		t.Error("expected error for a missing index")
+	}
+}
+
+func TestOracleProposesAndResolvesAfterReview(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	svc := NewService(db, Config{ReviewWindow: time.Hour}, fake)
+
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"price":70123.4}]}`))
+	}))
+	defer feed.Close()
+
+	for _, name := range []string{"creator", "bettor"} {
+		user := modelstesting.GenerateUser(name, 0)
+		db.Create(&user)
+	}
+	market := modelstesting.GenerateMarket(1, "creator")
+	db.Create(&market)
+	bet := modelstesting.GenerateBet(50, "YES", "bettor", uint(market.ID), 0)
+	db.Create(&bet)
+
+	cfg, err := svc.Configure(market.ID, ConfigureInput{
+		Source: "price", URL: feed.URL, Field: "data.0.price", Comparator: "gte", Target: "65000",
+	}, "admin")
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	if n, _ := svc.Check(); n != 0 {
+		t.Fatalf("proposed %d outcomes before the market closed", n)
+	}
+
+	fake.Advance(25 * time.Hour)
+	if n, err := svc.Check(); err != nil || n != 1 {
+		t.Fatalf("Check = %d, %v; want 1 proposal", n, err)
+	}
+	cfg, _ = svc.Get(market.ID)
+	if cfg.Status != models.OracleStatusProposed || cfg.ProposedOutcome != "YES" || cfg.ObservedValue != "70123.4" {
+		t.Fatalf("oracle = %+v", cfg)
+	}
+	var notified int64
+	db.Model(&models.Notification{}).Where("type = ?", "ORACLE_PROPOSED").Count(&notified)
+	if notified != 1 {
+		t.Errorf("got %d proposal notifications, want 1", notified)
+	}
+
+	if n, _ := svc.Finalize(); n != 0 {
+		t.Fatal("resolved during the review window")
+	}
+
+	fake.Advance(time.Hour)
+	if n, err := svc.Finalize(); err != nil || n != 1 {
+		t.Fatalf("Finalize = %d, %v; want 1", n, err)
+	}
+	var resolved models.Market
+	db.First(&resolved, market.ID)
+	if !resolved.IsResolved || resolved.ResolutionResult != "YES" {
+		t.Errorf("market = resolved %v %q, want YES", resolved.IsResolved, resolved.ResolutionResult)
+	}
+	var paid int64
+	db.Model(&models.LedgerEntry{}).Where("type = ?", models.LedgerTypeMarketPayout).
+		Select("COALESCE(SUM(amount), 0)").Scan(&paid)
+	if paid != models.CreditsToMicro(50) {
+		t.Errorf("paid %d, want %d", paid, models.CreditsToMicro(50))
+	}
+}
+
+func TestOracleReview(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	svc := NewService(db, Config{ReviewWindow: time.Hour}, fake)
+
+	creator := modelstesting.GenerateUser("creator", 0)
+	db.Create(&creator)
+	for id := int64(1); id <= 2; id++ {
+		market := modelstesting.GenerateMarket(id, "creator")
+		db.Create(&market)
+		db.Create(&models.MarketOracleConfig{
+			MarketID: id, Source: models.OracleSourcePrice, URL: "https://example.com", Field: "price",
+			Comparator: models.OracleCompareGT, Target: "1", Status: models.OracleStatusProposed, ProposedOutcome: "YES",
+		})
+	}
+
+	// Approving with an override resolves at once
+	approved, err := svc.Approve(1, "admin", "NO")
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if approved.Status != models.OracleStatusResolved || approved.ProposedOutcome != "NO" || approved.ReviewedBy != "admin" {
+		t.Errorf("approved = %+v", approved)
+	}
+	var market models.Market
+	db.First(&market, 1)
+	if market.ResolutionResult != "NO" {
+		t.Errorf("market resolved %q, want the override NO", market.ResolutionResult)
+	}
+	if _, err := svc.Approve(1, "admin", ""); !errors.Is(err, ErrMarketResolved) {
+		t.Errorf("second approval = %v, want ErrMarketResolved", err)
+	}
+
+	// Rejecting leaves the market for the creator to resolve
+	rejected, err := svc.Reject(2, "admin", "feed was stale")
+	if err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+	if rejected.Status != models.OracleStatusRejected {
+		t.Errorf("rejected = %+v", rejected)
+	}
+	fake.Advance(2 * time.Hour)
+	svc.Finalize()
+	var open models.Market
+	db.First(&open, 2)
+	if open.IsResolved {
+		t.Error("rejected proposal resolved its market")
+	}
+}
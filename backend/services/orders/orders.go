@@ -0,0 +1,532 @@
+// Package orders runs limit orders alongside market-order betting. Orders
+// rest on a market until its price reaches their limit and are then filled
+// against the market maker as ordinary bets and sales. Buys fill only as far
+// as keeps the price within their limit, so they can fill in parts.
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"socialpredict/clock"
+	betutils "socialpredict/handlers/bets/betutils"
+	buybetshandlers "socialpredict/handlers/bets/buying"
+	sellbetshandlers "socialpredict/handlers/bets/selling"
+	marketmath "socialpredict/handlers/math/market"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+	"socialpredict/services/betlimits"
+	"socialpredict/services/groups"
+	"socialpredict/services/ledger"
+	"socialpredict/services/liquidity"
+	"socialpredict/services/notify"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/selfexclusion"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxOpenOrders is how many open orders a user may have on one market
+	MaxOpenOrders = 50
+
+	// maxFillsPerMatch bounds one matching pass, so a busy market cannot
+	// hold up the others
+	maxFillsPerMatch = 100
+
+	minLimitPrice = 0.01
+	maxLimitPrice = 0.99
+)
+
+var (
+	ErrInvalidSide         = errors.New("side must be BUY or SELL")
+	ErrInvalidOutcome      = errors.New("outcome must be YES or NO")
+	ErrInvalidLimitPrice   = fmt.Errorf("limit price must be between %.2f and %.2f", minLimitPrice, maxLimitPrice)
+	ErrInvalidAmount       = errors.New("amount must be at least 1 credit")
+	ErrMarketNotFound      = errors.New("market not found")
+	ErrMarketClosed        = errors.New("market is closed")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrTooManyOrders       = fmt.Errorf("at most %d open orders per market", MaxOpenOrders)
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderNotOpen        = errors.New("order is not open")
+	ErrCategoricalMarket   = errors.New("limit orders can only be placed on binary markets")
+
+	// errClosedDuringFill rolls back a fill whose order was cancelled meanwhile
+	errClosedDuringFill = errors.New("order closed during fill")
+)
+
+// Service places, cancels and matches limit orders
+type Service struct {
+	db    *gorm.DB
+	econ  setup.EconConfigLoader
+	clock clock.Clock
+	mu    sync.Mutex // Serializes matching, so an order is not filled twice
+}
+
+// NewService creates an order service
+func NewService(db *gorm.DB, econ setup.EconConfigLoader, c clock.Clock) *Service {
+	return &Service{db: db, econ: econ, clock: c}
+}
+
+// PlaceInput describes a limit order
+type PlaceInput struct {
+	Side       string
+	Outcome    string
+	LimitPrice float64
+	Amount     int64 // Whole credits
+}
+
+// Place rests a limit order on an open market and matches the market at
+// once, so an order whose limit is already reached fills immediately
+func (s *Service) Place(userID, marketID int64, in PlaceInput) (*models.MarketOrder, error) {
+	in.Side = strings.ToUpper(strings.TrimSpace(in.Side))
+	in.Outcome = strings.ToUpper(strings.TrimSpace(in.Outcome))
+	switch {
+	case in.Side != models.OrderSideBuy && in.Side != models.OrderSideSell:
+		return nil, ErrInvalidSide
+	case in.Outcome != "YES" && in.Outcome != "NO":
+		return nil, ErrInvalidOutcome
+	case in.LimitPrice < minLimitPrice || in.LimitPrice > maxLimitPrice:
+		return nil, ErrInvalidLimitPrice
+	case in.Amount < 1:
+		return nil, ErrInvalidAmount
+	}
+
+	var order models.MarketOrder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		market, err := s.openMarket(tx, marketID)
+		if err != nil {
+			return err
+		}
+		if market.IsCategorical() {
+			return ErrCategoricalMarket
+		}
+		if err := groups.CheckCanBet(tx, market, userID); err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("user: %w", err)
+		}
+		if err := restrictions.Check(tx, user.ID, models.RestrictionTradingFrozen, s.clock.Now()); err != nil {
+			return err
+		}
+		if in.Side == models.OrderSideBuy && user.BettingBalance() < models.CreditsToMicro(in.Amount) {
+			return ErrInsufficientBalance
+		}
+		// Fills are checked against the caps too; this refuses orders that could never fill
+		if in.Side == models.OrderSideBuy {
+			if err := betlimits.Check(tx, &user, market.ID, in.Amount); err != nil {
+				return err
+			}
+			if err := selfexclusion.Check(tx, user.ID, s.clock.Now()); err != nil {
+				return err
+			}
+		}
+		var open int64
+		if err := tx.Model(&models.MarketOrder{}).
+			Where("market_id = ? AND user_id = ? AND status = ?", market.ID, user.ID, models.OrderStatusOpen).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open >= MaxOpenOrders {
+			return ErrTooManyOrders
+		}
+
+		order = models.MarketOrder{
+			MarketID:   market.ID,
+			Status:     models.OrderStatusOpen,
+			UserID:     user.ID,
+			Username:   user.Username,
+			Side:       in.Side,
+			Outcome:    in.Outcome,
+			LimitPrice: in.LimitPrice,
+			Amount:     in.Amount,
+		}
+		order.CreatedAt = s.clock.Now()
+		return tx.Create(&order).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Match(marketID); err != nil {
+		log.Printf("Orders: matching market %d failed: %v", marketID, err)
+	}
+	if err := s.db.First(&order, order.ID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Cancel cancels one of the user's open orders
+func (s *Service) Cancel(userID int64, orderID uint) (*models.MarketOrder, error) {
+	var order models.MarketOrder
+	if err := s.db.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	if order.Status != models.OrderStatusOpen {
+		return nil, ErrOrderNotOpen
+	}
+	closed, err := s.close(s.db, &order, models.OrderStatusCancelled, "Cancelled by user")
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, ErrOrderNotOpen
+	}
+	return &order, nil
+}
+
+// CancelAll cancels every open order of the user for reason and returns how
+// many were cancelled. A fill already under way completes first.
+func (s *Service) CancelAll(userID int64, reason string) (int64, error) {
+	result := s.db.Model(&models.MarketOrder{}).
+		Where("user_id = ? AND status = ?", userID, models.OrderStatusOpen).
+		Updates(map[string]interface{}{"status": models.OrderStatusCancelled, "cancel_reason": reason, "closed_at": s.clock.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// List returns the user's orders, newest first, optionally only those with status
+func (s *Service) List(userID int64, status string, limit int) ([]models.MarketOrder, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", strings.ToUpper(status))
+	}
+	orders := []models.MarketOrder{}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+// BookLevel is the open orders on one side of a market at one price
+type BookLevel struct {
+	Side       string  `json:"side"`
+	Outcome    string  `json:"outcome"`
+	LimitPrice float64 `json:"limitPrice"`
+	Amount     int64   `json:"amount"` // Credits still to fill
+	Orders     int     `json:"orders"`
+}
+
+// Book returns a market's open orders grouped by side, outcome and price,
+// most aggressive price first
+func (s *Service) Book(marketID int64) ([]BookLevel, error) {
+	if err := s.db.Select("id").First(&models.Market{}, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMarketNotFound
+		}
+		return nil, err
+	}
+	var open []models.MarketOrder
+	if err := s.db.Where("market_id = ? AND status = ?", marketID, models.OrderStatusOpen).Find(&open).Error; err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		side, outcome string
+		price         float64
+	}
+	levels := map[key]*BookLevel{}
+	for _, o := range open {
+		k := key{o.Side, o.Outcome, o.LimitPrice}
+		if levels[k] == nil {
+			levels[k] = &BookLevel{Side: o.Side, Outcome: o.Outcome, LimitPrice: o.LimitPrice}
+		}
+		levels[k].Amount += o.Remaining()
+		levels[k].Orders++
+	}
+	book := make([]BookLevel, 0, len(levels))
+	for _, level := range levels {
+		book = append(book, *level)
+	}
+	sort.Slice(book, func(i, j int) bool {
+		a, b := book[i], book[j]
+		if a.Side != b.Side {
+			return a.Side < b.Side
+		}
+		if a.Outcome != b.Outcome {
+			return a.Outcome > b.Outcome
+		}
+		if a.Side == models.OrderSideBuy {
+			return a.LimitPrice > b.LimitPrice
+		}
+		return a.LimitPrice < b.LimitPrice
+	})
+	return book, nil
+}
+
+// Match fills the market's orders whose limits have been reached, best
+// price first and then oldest first, until none can fill. Orders on a
+// market that has closed are expired. It returns the number of fills.
+func (s *Service) Match(marketID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fills := 0
+	for fills < maxFillsPerMatch {
+		var market models.Market
+		if err := s.db.First(&market, marketID).Error; err != nil {
+			return fills, err
+		}
+		if market.IsResolved || !market.ResolutionDateTime.After(s.clock.Now()) {
+			return fills, s.expire(market.ID)
+		}
+
+		var open []models.MarketOrder
+		if err := s.db.Where("market_id = ? AND status = ?", market.ID, models.OrderStatusOpen).
+			Order("created_at, id").Find(&open).Error; err != nil {
+			return fills, err
+		}
+		order, amount := s.next(&market, open)
+		if order == nil {
+			return fills, nil
+		}
+
+		if err := s.fill(&market, order, amount); errors.Is(err, errClosedDuringFill) {
+			continue
+		} else if err != nil {
+			// A fill that cannot be placed, for example for lack of balance,
+			// cancels the order rather than blocking the rest of the book
+			log.Printf("Orders: filling order %d failed: %v", order.ID, err)
+			if err := s.cancelFailed(order, err); err != nil {
+				return fills, err
+			}
+			continue
+		}
+		fills++
+	}
+	return fills, nil
+}
+
+// next picks the order to fill and the credits to fill it with, or nil if
+// no order's limit has been reached
+func (s *Service) next(market *models.Market, open []models.MarketOrder) (*models.MarketOrder, int64) {
+	if len(open) == 0 {
+		return nil, 0
+	}
+	bets := tradingdata.GetBetsForMarket(s.db, uint(market.ID))
+	events := tradingdata.GetLiquidityForMarket(s.db, market.ID)
+	probability := marketmath.CurrentProbability(market, bets, events...)
+
+	var best *models.MarketOrder
+	var bestAmount int64
+	bestImprovement := 0.0
+	for i := range open {
+		o := &open[i]
+		price := outcomeProbability(o.Outcome, probability)
+		var improvement float64
+		var amount int64
+		if o.Side == models.OrderSideBuy {
+			improvement = o.LimitPrice - price
+			amount = s.buyable(market, bets, events, o)
+		} else {
+			improvement = price - o.LimitPrice
+			if improvement >= 0 {
+				amount = o.Remaining()
+			}
+		}
+		// Orders are oldest first, so ties keep time priority
+		if amount > 0 && (best == nil || improvement > bestImprovement) {
+			best, bestAmount, bestImprovement = o, amount, improvement
+		}
+	}
+	return best, bestAmount
+}
+
+// buyable returns the most credits of a buy order that can be bet without
+// moving the price of its outcome above the limit
+func (s *Service) buyable(market *models.Market, bets []models.Bet, events []models.LiquidityEvent, order *models.MarketOrder) int64 {
+	// Cap capacity so projections never write into the bets' backing array
+	bets = bets[:len(bets):len(bets)]
+	priceAfter := func(amount int64) float64 {
+		bet := models.Bet{MarketID: uint(market.ID), Amount: amount, Outcome: order.Outcome, PlacedAt: s.clock.Now()}
+		return outcomeProbability(order.Outcome, marketmath.ProjectProbability(market, bets, bet, events...))
+	}
+
+	low, high := int64(0), order.Remaining()
+	for low < high {
+		mid := (low + high + 1) / 2
+		if priceAfter(mid) <= order.LimitPrice {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// fill places the bet or sale for part of an order and records the fill. The
+// market and then the user are locked, in the order bets lock them, so the
+// fill works from the user's current balance. The trade is published only
+// once the fill has committed.
+func (s *Service) fill(market *models.Market, order *models.MarketOrder, amount int64) error {
+	var bet *models.Bet
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := betutils.LockOpenMarket(tx, uint(market.ID)); err != nil {
+			return err
+		}
+		user, err := ledger.LockUser(tx, order.UserID)
+		if err != nil {
+			return fmt.Errorf("user: %w", err)
+		}
+
+		filled := amount
+		if order.Side == models.OrderSideBuy {
+			placed, err := buybetshandlers.PlaceBetInTx(user, models.Bet{MarketID: uint(market.ID), Amount: amount, Outcome: order.Outcome}, tx, s.econ)
+			if err != nil {
+				return err
+			}
+			bet = placed
+		} else {
+			sold, value, err := sellbetshandlers.SellPositionInTx(tx, &models.Bet{MarketID: uint(market.ID), Amount: amount, Outcome: order.Outcome}, user, s.econ())
+			if err != nil {
+				return err
+			}
+			// A sale sells what it can up to the amount, so it closes the order
+			bet, filled = sold, order.Remaining()
+			amount = value
+		}
+
+		order.Filled += filled
+		updates := map[string]interface{}{"filled": order.Filled}
+		if order.Remaining() == 0 {
+			now := s.clock.Now()
+			order.Status, order.ClosedAt = models.OrderStatusFilled, &now
+			updates["status"], updates["closed_at"] = order.Status, now
+		}
+		result := tx.Model(&models.MarketOrder{}).
+			Where("id = ? AND status = ?", order.ID, models.OrderStatusOpen).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errClosedDuringFill
+		}
+
+		probability := liquidity.CurrentProbability(tx, market)
+		if err := tx.Create(&models.OrderFill{
+			OrderID:     order.ID,
+			BetID:       bet.ID,
+			Amount:      amount,
+			Probability: probability,
+			CreatedAt:   s.clock.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		verb := "bought"
+		if order.Side == models.OrderSideSell {
+			verb = "sold"
+		}
+		return notify.Send(tx, user.ID, notify.TypeOrderFilled, "Order filled",
+			fmt.Sprintf("Your limit order #%d %s %d credits of %s on market #%d at %.1f%%.",
+				order.ID, verb, amount, order.Outcome, market.ID, outcomeProbability(order.Outcome, probability)*100))
+	})
+	if err != nil {
+		return err
+	}
+	betutils.PublishTrade(s.db, *bet)
+	return nil
+}
+
+// cancelFailed cancels an order whose fill failed and tells its user why
+func (s *Service) cancelFailed(order *models.MarketOrder, cause error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		closed, err := s.close(tx, order, models.OrderStatusCancelled, cause.Error())
+		if err != nil || !closed {
+			return err
+		}
+		return notify.Send(tx, order.UserID, notify.TypeOrderCancelled, "Order cancelled",
+			fmt.Sprintf("Your limit order #%d on market #%d could not be filled and was cancelled: %s",
+				order.ID, order.MarketID, cause.Error()))
+	})
+}
+
+// close moves an open order to status, reporting whether it was still open
+func (s *Service) close(tx *gorm.DB, order *models.MarketOrder, status, reason string) (bool, error) {
+	now := s.clock.Now()
+	result := tx.Model(&models.MarketOrder{}).
+		Where("id = ? AND status = ?", order.ID, models.OrderStatusOpen).
+		Updates(map[string]interface{}{"status": status, "cancel_reason": reason, "closed_at": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	order.Status, order.CancelReason, order.ClosedAt = status, reason, &now
+	return true, nil
+}
+
+// expire closes the open orders of a market that has stopped taking bets
+func (s *Service) expire(marketID int64) error {
+	return s.db.Model(&models.MarketOrder{}).
+		Where("market_id = ? AND status = ?", marketID, models.OrderStatusOpen).
+		Updates(map[string]interface{}{"status": models.OrderStatusExpired, "closed_at": s.clock.Now()}).Error
+}
+
+// MatchAll matches every market with open orders
+func (s *Service) MatchAll() (int, error) {
+	var marketIDs []int64
+	if err := s.db.Model(&models.MarketOrder{}).Where("status = ?", models.OrderStatusOpen).
+		Distinct().Pluck("market_id", &marketIDs).Error; err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, id := range marketIDs {
+		n, err := s.Match(id)
+		total += n
+		if err != nil {
+			log.Printf("Orders: matching market %d failed: %v", id, err)
+		}
+	}
+	return total, nil
+}
+
+// Run matches all markets every interval, filling orders reached by bets
+// placed since the last pass
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.MatchAll()
+		if err != nil {
+			log.Printf("Orders: matching failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Orders: Filled %d orders", n)
+		}
+	}
+}
+
+// openMarket loads a market that is still taking bets
+func (s *Service) openMarket(tx *gorm.DB, marketID int64) (*models.Market, error) {
+	var market models.Market
+	if err := tx.First(&market, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMarketNotFound
+		}
+		return nil, err
+	}
+	if market.IsResolved || !market.ResolutionDateTime.After(s.clock.Now()) {
+		return nil, ErrMarketClosed
+	}
+	return &market, nil
+}
+
+// outcomeProbability converts the market's YES probability to the
+// probability of outcome
+func outcomeProbability(outcome string, yes float64) float64 {
+	if outcome == "NO" {
+		return 1 - yes
+	}
+	return yes
+}
@@ -0,0 +1,175 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/betlimits"
+	"socialpredict/services/liquidity"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+func setupBook(t *testing.T) (*gorm.DB, *clock.Fake, *Service, *models.Market) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	for _, name := range []string{"alice", "bob"} {
+		user := modelstesting.GenerateUser(name, 1000)
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	market := modelstesting.GenerateMarket(1, "alice")
+	market.CreatedAt = fake.Now().Add(-time.Hour)
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+	return db, fake, NewService(db, setup.EconomicsConfig, fake), &market
+}
+
+func user(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	var u models.User
+	if err := db.Where("username = ?", name).First(&u).Error; err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return &u
+}
+
+func bet(t *testing.T, db *gorm.DB, username string, marketID int64, amount int64, outcome string) {
+	t.Helper()
+	b := modelstesting.GenerateBet(amount, outcome, username, uint(marketID), 0)
+	if err := db.Create(&b).Error; err != nil {
+		t.Fatalf("create bet: %v", err)
+	}
+}
+
+func TestBuyOrderFillsUpToItsLimit(t *testing.T) {
+	db, _, book, market := setupBook(t)
+	alice := user(t, db, "alice")
+
+	order, err := book.Place(alice.ID, market.ID, PlaceInput{Side: "buy", Outcome: "YES", LimitPrice: 0.6, Amount: 100})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	// The order fills at once only as far as keeps YES at or below 60%
+	if order.Status != models.OrderStatusOpen || order.Filled < 1 || order.Filled >= 100 {
+		t.Fatalf("order after placing = %+v", order)
+	}
+	if p := liquidity.CurrentProbability(db, market); p > 0.6 {
+		t.Errorf("probability after partial fill = %f, above the limit", p)
+	}
+
+	// A NO bet pulls the price back down, letting more of the order fill
+	bet(t, db, "bob", market.ID, 20, "NO")
+	firstFill := order.Filled
+	if n, err := book.Match(market.ID); err != nil || n != 1 {
+		t.Fatalf("Match = %d, %v", n, err)
+	}
+	db.First(order, order.ID)
+	if order.Filled <= firstFill {
+		t.Errorf("filled %d after the price fell, want more than %d", order.Filled, firstFill)
+	}
+
+	var fills []models.OrderFill
+	db.Where("order_id = ?", order.ID).Find(&fills)
+	var betTotal int64
+	db.Model(&models.Bet{}).Where("username = ?", "alice").Select("SUM(amount)").Scan(&betTotal)
+	if len(fills) != 2 || betTotal != order.Filled {
+		t.Errorf("%d fills and %d credits of bets, want 2 fills totalling %d", len(fills), betTotal, order.Filled)
+	}
+	var notes int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", alice.ID, "ORDER_FILLED").Count(&notes)
+	if notes != 2 {
+		t.Errorf("%d fill notifications, want 2", notes)
+	}
+}
+
+func TestSellOrderWaitsForItsPrice(t *testing.T) {
+	db, _, book, market := setupBook(t)
+	alice := user(t, db, "alice")
+	bet(t, db, "bob", market.ID, 50, "NO")
+	bet(t, db, "alice", market.ID, 20, "YES")
+
+	order, err := book.Place(alice.ID, market.ID, PlaceInput{Side: "SELL", Outcome: "YES", LimitPrice: 0.5, Amount: 5})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	if order.Status != models.OrderStatusOpen || order.Filled != 0 {
+		t.Fatalf("order rested as %+v", order)
+	}
+
+	bet(t, db, "bob", market.ID, 60, "YES")
+	if _, err := book.Match(market.ID); err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	db.First(order, order.ID)
+	if order.Status != models.OrderStatusFilled {
+		t.Fatalf("order after price rose = %+v", order)
+	}
+	var sale models.Bet
+	if err := db.Where("username = ? AND amount < 0", "alice").First(&sale).Error; err != nil {
+		t.Errorf("no sale recorded: %v", err)
+	}
+}
+
+func TestCancelExpireAndFailedFills(t *testing.T) {
+	db, fake, book, market := setupBook(t)
+	alice, bob := user(t, db, "alice"), user(t, db, "bob")
+
+	resting, _ := book.Place(alice.ID, market.ID, PlaceInput{Side: "BUY", Outcome: "NO", LimitPrice: 0.2, Amount: 10})
+	if _, err := book.Cancel(bob.ID, resting.ID); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("cancel by another user = %v, want ErrOrderNotFound", err)
+	}
+	cancelled, err := book.Cancel(alice.ID, resting.ID)
+	if err != nil || cancelled.Status != models.OrderStatusCancelled {
+		t.Fatalf("Cancel = %+v, %v", cancelled, err)
+	}
+	if _, err := book.Cancel(alice.ID, resting.ID); !errors.Is(err, ErrOrderNotOpen) {
+		t.Errorf("second cancel = %v, want ErrOrderNotOpen", err)
+	}
+
+	// A sell with no shares to sell is cancelled when it triggers
+	failed, err := book.Place(bob.ID, market.ID, PlaceInput{Side: "SELL", Outcome: "NO", LimitPrice: 0.1, Amount: 10})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	if failed.Status != models.OrderStatusCancelled || failed.CancelReason == "" {
+		t.Errorf("unfillable sell = %+v", failed)
+	}
+
+	open, _ := book.Place(alice.ID, market.ID, PlaceInput{Side: "BUY", Outcome: "YES", LimitPrice: 0.1, Amount: 10})
+	fake.Advance(48 * time.Hour)
+	if _, err := book.Match(market.ID); err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	db.First(open, open.ID)
+	if open.Status != models.OrderStatusExpired {
+		t.Errorf("order on closed market = %s, want EXPIRED", open.Status)
+	}
+	if _, err := book.Place(alice.ID, market.ID, PlaceInput{Side: "BUY", Outcome: "YES", LimitPrice: 0.5, Amount: 1}); !errors.Is(err, ErrMarketClosed) {
+		t.Errorf("order on closed market = %v, want ErrMarketClosed", err)
+	}
+}
+
+func TestBuyOrderOverBetLimitIsRefused(t *testing.T) {
+	db, _, book, market := setupBook(t)
+	alice := user(t, db, "alice")
+	if _, err := betlimits.SetMarket(db, market.ID, 50, 0, "admin"); err != nil {
+		t.Fatalf("SetMarket: %v", err)
+	}
+
+	var limitErr *betlimits.LimitError
+	if _, err := book.Place(alice.ID, market.ID, PlaceInput{Side: "BUY", Outcome: "YES", LimitPrice: 0.6, Amount: 51}); !errors.As(err, &limitErr) {
+		t.Fatalf("order over the cap = %v, want a LimitError", err)
+	}
+	if _, err := book.Place(alice.ID, market.ID, PlaceInput{Side: "BUY", Outcome: "YES", LimitPrice: 0.6, Amount: 50}); err != nil {
+		t.Fatalf("order at the cap: %v", err)
+	}
+}
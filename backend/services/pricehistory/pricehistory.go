@@ -0,0 +1,248 @@
+// Package pricehistory records a market's probability after every trade and
+// aggregates the recorded points into candles for charting.
+//
+// Markets traded before points were recorded are backfilled from their bets
+// the first time their history is requested.
+package pricehistory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	marketmath "socialpredict/handlers/math/market"
+	"socialpredict/handlers/math/probabilities/wpam"
+	"socialpredict/handlers/tradingdata"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxCandles bounds how many candles one request can return
+const MaxCandles = 1000
+
+var (
+	ErrMarketNotFound  = errors.New("market not found")
+	ErrInvalidOutcome  = errors.New("outcome is not one of the market's outcomes")
+	ErrInvalidInterval = errors.New("interval must be at least one minute")
+	ErrInvalidRange    = errors.New("from must be before to")
+	ErrTooManyCandles  = fmt.Errorf("range and interval would return more than %d candles", MaxCandles)
+)
+
+// Candle is the probability range of one outcome over one interval
+type Candle struct {
+	Start  time.Time `json:"start"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume int64     `json:"volume"` // Credits traded on the outcome
+	Trades int       `json:"trades"`
+}
+
+// History is an outcome's candles over a range
+type History struct {
+	MarketID int64    `json:"marketId"`
+	Outcome  string   `json:"outcome"`
+	Interval string   `json:"interval"`
+	Candles  []Candle `json:"candles"`
+}
+
+// series returns the outcomes a market records points for, in order
+func series(db *gorm.DB, market models.Market) []string {
+	if !market.IsCategorical() {
+		return []string{"YES"}
+	}
+	var labels []string
+	for _, outcome := range tradingdata.GetOutcomesForMarket(db, market.ID) {
+		labels = append(labels, outcome.Label)
+	}
+	return labels
+}
+
+// points replays the market's bets and returns the price points for the
+// bets from index from on
+func points(db *gorm.DB, market models.Market, labels []string, bets []models.Bet, from int) []models.MarketPricePoint {
+	volume := func(bet models.Bet) int64 {
+		if bet.Amount < 0 {
+			if bet.Proceeds > 0 {
+				return bet.Proceeds
+			}
+			return -bet.Amount
+		}
+		return bet.Amount
+	}
+
+	var result []models.MarketPricePoint
+	if market.IsCategorical() {
+		changes := wpam.CalculateCategoricalProbabilitiesWPAM(market.CreatedAt, labels, bets)
+		for n := from; n < len(bets); n++ {
+			for i, label := range labels {
+				point := models.MarketPricePoint{
+					MarketID:    market.ID,
+					BetID:       bets[n].ID,
+					Outcome:     label,
+					Probability: changes[n+1].Probabilities[i],
+					RecordedAt:  bets[n].PlacedAt,
+				}
+				if bets[n].Outcome == label {
+					point.Volume = volume(bets[n])
+				}
+				result = append(result, point)
+			}
+		}
+		return result
+	}
+
+	changes := marketmath.ForMarket(&market).Probabilities(market.CreatedAt, bets, tradingdata.GetLiquidityForMarket(db, market.ID)...)
+	for n := from; n < len(bets); n++ {
+		result = append(result, models.MarketPricePoint{
+			MarketID:    market.ID,
+			BetID:       bets[n].ID,
+			Outcome:     "YES",
+			Probability: changes[n+1].Probability,
+			Volume:      volume(bets[n]),
+			RecordedAt:  bets[n].PlacedAt,
+		})
+	}
+	return result
+}
+
+// save inserts points, skipping any already recorded for the same trade
+func save(db *gorm.DB, pts []models.MarketPricePoint) error {
+	if len(pts) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&pts, 500).Error
+}
+
+// Record stores and returns the market's probabilities just after bet,
+// which must already be saved. Call it with the transaction the bet was
+// saved in.
+func Record(db *gorm.DB, bet models.Bet) ([]models.MarketPricePoint, error) {
+	var market models.Market
+	if err := db.First(&market, bet.MarketID).Error; err != nil {
+		return nil, err
+	}
+	bets := tradingdata.GetBetsForMarket(db, bet.MarketID)
+	for n := range bets {
+		if bets[n].ID == bet.ID {
+			pts := points(db, market, series(db, market), bets[:n+1], n)
+			return pts, save(db, pts)
+		}
+	}
+	return nil, fmt.Errorf("bet %d not found in market %d", bet.ID, bet.MarketID)
+}
+
+// backfill records points for a market's bets from before recording began
+func backfill(db *gorm.DB, market models.Market, labels []string) error {
+	var recorded int64
+	if err := db.Model(&models.MarketPricePoint{}).Where("market_id = ?", market.ID).Count(&recorded).Error; err != nil {
+		return err
+	}
+	bets := tradingdata.GetBetsForMarket(db, uint(market.ID))
+	if int(recorded) >= len(bets)*len(labels) {
+		return nil
+	}
+	return save(db, points(db, market, labels, bets, 0))
+}
+
+// opening returns the outcome's probability before any trade
+func opening(market models.Market, labels []string, outcome string) float64 {
+	if market.IsCategorical() {
+		return wpam.CalculateCategoricalProbabilitiesWPAM(market.CreatedAt, labels, nil)[0].Probabilities[indexOf(labels, outcome)]
+	}
+	return marketmath.ForMarket(&market).Probabilities(market.CreatedAt, nil)[0].Probability
+}
+
+func indexOf(labels []string, label string) int {
+	for i, l := range labels {
+		if l == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// Candles aggregates the outcome's price points into candles of the given
+// interval, covering from to to. A zero from defaults to the market's
+// creation; the caller supplies to, usually the current time. Intervals with no trades repeat the previous close. An empty
+// outcome means YES, or the first outcome of a categorical market. The
+// returned history's Interval is left for the caller to label.
+func Candles(db *gorm.DB, marketID int64, outcome string, interval time.Duration, from, to time.Time) (History, error) {
+	if interval < time.Minute {
+		return History{}, ErrInvalidInterval
+	}
+
+	var market models.Market
+	if err := db.First(&market, marketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return History{}, ErrMarketNotFound
+		}
+		return History{}, err
+	}
+	labels := series(db, market)
+	if outcome == "" && len(labels) > 0 {
+		outcome = labels[0]
+	}
+	if indexOf(labels, outcome) < 0 {
+		return History{}, ErrInvalidOutcome
+	}
+
+	if from.IsZero() {
+		from = market.CreatedAt
+	}
+	from = from.UTC().Truncate(interval)
+	if !from.Before(to) {
+		return History{}, ErrInvalidRange
+	}
+	count := int((to.Sub(from) + interval - 1) / interval)
+	if count > MaxCandles {
+		return History{}, ErrTooManyCandles
+	}
+
+	if err := backfill(db, market, labels); err != nil {
+		return History{}, fmt.Errorf("failed to backfill price history: %w", err)
+	}
+
+	// The close before the range opens the first candle
+	price := opening(market, labels, outcome)
+	var last models.MarketPricePoint
+	err := db.Where("market_id = ? AND outcome = ? AND recorded_at < ?", marketID, outcome, from).
+		Order("recorded_at DESC, bet_id DESC").First(&last).Error
+	if err == nil {
+		price = last.Probability
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return History{}, err
+	}
+
+	var pts []models.MarketPricePoint
+	if err := db.Where("market_id = ? AND outcome = ? AND recorded_at >= ? AND recorded_at < ?", marketID, outcome, from, to).
+		Order("recorded_at ASC, bet_id ASC").Find(&pts).Error; err != nil {
+		return History{}, err
+	}
+
+	candles := make([]Candle, count)
+	next := 0
+	for i := range candles {
+		start := from.Add(time.Duration(i) * interval)
+		end := start.Add(interval)
+		c := Candle{Start: start, Open: price, High: price, Low: price, Close: price}
+		for ; next < len(pts) && pts[next].RecordedAt.Before(end); next++ {
+			p := pts[next].Probability
+			if p > c.High {
+				c.High = p
+			}
+			if p < c.Low {
+				c.Low = p
+			}
+			c.Close = p
+			c.Volume += pts[next].Volume
+			c.Trades++
+		}
+		price = c.Close
+		candles[i] = c
+	}
+	return History{MarketID: marketID, Outcome: outcome, Candles: candles}, nil
+}
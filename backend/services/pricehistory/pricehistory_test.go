@@ -0,0 +1,121 @@
+package pricehistory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func createMarketWithBets(t *testing.T, db *gorm.DB, createdAt time.Time, bets ...models.Bet) []models.Bet {
+	t.Helper()
+	market := modelstesting.GenerateMarket(1, "creator")
+	market.CreatedAt = createdAt
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+	for i := range bets {
+		bets[i].MarketID = 1
+		if err := db.Create(&bets[i]).Error; err != nil {
+			t.Fatalf("create bet: %v", err)
+		}
+	}
+	return bets
+}
+
+func bet(amount int64, outcome string, at time.Time) models.Bet {
+	return models.Bet{Username: "alice", Amount: amount, Outcome: outcome, PlacedAt: at}
+}
+
+func TestCandlesBackfillAndCarryForward(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t0 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	createMarketWithBets(t, db, t0,
+		bet(50, "YES", t0.Add(10*time.Minute)),
+		bet(20, "NO", t0.Add(20*time.Minute)),
+		bet(30, "YES", t0.Add(150*time.Minute)),
+	)
+
+	history, err := Candles(db, 1, "", time.Hour, time.Time{}, t0.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("Candles: %v", err)
+	}
+	if history.Outcome != "YES" || len(history.Candles) != 3 {
+		t.Fatalf("history = %+v, want three YES candles", history)
+	}
+
+	first, quiet, last := history.Candles[0], history.Candles[1], history.Candles[2]
+	if first.Trades != 2 || first.Volume != 70 || first.High <= first.Open || first.Close >= first.High {
+		t.Fatalf("first candle = %+v, want a rise then a fall over two trades", first)
+	}
+	if quiet.Trades != 0 || quiet.Open != first.Close || quiet.High != first.Close || quiet.Close != first.Close {
+		t.Fatalf("quiet candle = %+v, want it flat at the previous close %v", quiet, first.Close)
+	}
+	if last.Trades != 1 || last.Open != first.Close || last.Close <= last.Open {
+		t.Fatalf("last candle = %+v, want a YES trade up from %v", last, first.Close)
+	}
+
+	var points int64
+	db.Model(&models.MarketPricePoint{}).Count(&points)
+	if points != 3 {
+		t.Fatalf("backfill stored %d points, want 3", points)
+	}
+
+	// A later range opens at the close before it
+	later, err := Candles(db, 1, "YES", time.Hour, t0.Add(time.Hour), t0.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Candles: %v", err)
+	}
+	if len(later.Candles) != 1 || later.Candles[0].Open != first.Close {
+		t.Fatalf("later candles = %+v, want one opening at %v", later.Candles, first.Close)
+	}
+}
+
+func TestRecordIsIdempotent(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t0 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	bets := createMarketWithBets(t, db, t0, bet(50, "YES", t0.Add(time.Minute)), bet(10, "NO", t0.Add(2*time.Minute)))
+
+	for i := 0; i < 2; i++ {
+		if _, err := Record(db, bets[0]); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	var points []models.MarketPricePoint
+	db.Find(&points)
+	if len(points) != 1 || points[0].BetID != bets[0].ID || points[0].Probability <= 0.5 || points[0].Volume != 50 {
+		t.Fatalf("points = %+v, want one point above 0.5 for the first bet", points)
+	}
+}
+
+func TestCandlesValidation(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t0 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	createMarketWithBets(t, db, t0)
+
+	cases := []struct {
+		name     string
+		marketID int64
+		outcome  string
+		interval time.Duration
+		to       time.Time
+		want     error
+	}{
+		{"unknown market", 99, "", time.Hour, t0.Add(time.Hour), ErrMarketNotFound},
+		{"unknown outcome", 1, "MAYBE", time.Hour, t0.Add(time.Hour), ErrInvalidOutcome},
+		{"interval too short", 1, "", time.Second, t0.Add(time.Hour), ErrInvalidInterval},
+		{"empty range", 1, "", time.Hour, t0, ErrInvalidRange},
+		{"too many candles", 1, "", time.Minute, t0.Add(30 * 24 * time.Hour), ErrTooManyCandles},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Candles(db, tc.marketID, tc.outcome, tc.interval, time.Time{}, tc.to); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package receipts checks large deposits against the chain before they are
+// credited. The transaction receipt is fetched from the chain's JSON-RPC
+// endpoint (SupportedChain.RpcURL) and must contain an ERC20 Transfer log
+// with the expected contract, recipient and amount.
+package receipts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/dfns"
+	"socialpredict/services/evmrpc"
+)
+
+const (
+	defaultThreshold = 1000 * models.MicroCreditsPerCredit
+	defaultTimeout   = 10 * time.Second
+)
+
+var (
+	ErrNoRPC            = errors.New("chain has no RPC endpoint configured")
+	ErrUnsupportedChain = errors.New("receipt verification is only supported on EVM chains")
+	ErrNotFound         = errors.New("transaction receipt not found")
+	ErrReverted         = errors.New("transaction reverted")
+	ErrMismatch         = errors.New("no Transfer log matches the deposit")
+)
+
+// Expected describes the transfer a deposit claims happened
+type Expected struct {
+	TxHash   string
+	Contract string // Token contract
+	To       string // Deposit wallet address
+	Amount   string // In the token's smallest unit
+}
+
+// Verifier checks a transfer against the chain
+type Verifier interface {
+	Verify(ctx context.Context, chain *models.SupportedChain, want Expected) error
+}
+
+// RPCVerifier reads receipts over Ethereum JSON-RPC
+type RPCVerifier struct {
+	client *evmrpc.Client
+}
+
+// NewRPCVerifier creates an RPC verifier
+func NewRPCVerifier() *RPCVerifier {
+	return &RPCVerifier{client: evmrpc.NewClient()}
+}
+
+// Verify implements Verifier
+func (v *RPCVerifier) Verify(ctx context.Context, chain *models.SupportedChain, want Expected) error {
+	if chain.RpcURL == "" {
+		return ErrNoRPC
+	}
+	if !dfns.IsValidEVMAddress(want.To) || !dfns.IsValidEVMAddress(want.Contract) {
+		return ErrUnsupportedChain
+	}
+	amount, ok := new(big.Int).SetString(want.Amount, 10)
+	if !ok {
+		return fmt.Errorf("%w: invalid amount %q", ErrMismatch, want.Amount)
+	}
+
+	r, err := v.client.TransactionReceipt(ctx, chain.RpcURL, want.TxHash)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return ErrNotFound
+	}
+	if r.Status != "0x1" {
+		return ErrReverted
+	}
+
+	for _, l := range r.Logs {
+		if len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], evmrpc.TransferTopic) || !strings.EqualFold(l.Address, want.Contract) {
+			continue
+		}
+		if !strings.EqualFold(evmrpc.TopicAddress(l.Topics[2]), want.To) {
+			continue
+		}
+		if value, ok := evmrpc.ParseAmount(l.Data); ok && value.Cmp(amount) == 0 {
+			return nil
+		}
+	}
+	return ErrMismatch
+}
+
+// Config holds receipt verification settings
+type Config struct {
+	ThresholdMicro int64         // Deposits of at least this many micro-credits are verified
+	Timeout        time.Duration // How long to wait for the RPC endpoint
+}
+
+// LoadConfigFromEnv reads DEPOSIT_VERIFY_THRESHOLD (credits; 0 verifies every
+// deposit) and DEPOSIT_VERIFY_TIMEOUT
+func LoadConfigFromEnv() Config {
+	config := Config{ThresholdMicro: defaultThreshold, Timeout: defaultTimeout}
+	if v := os.Getenv("DEPOSIT_VERIFY_THRESHOLD"); v != "" {
+		if micro, err := models.ParseCredits(v); err == nil && micro >= 0 {
+			config.ThresholdMicro = micro
+		}
+	}
+	if d, err := time.ParseDuration(os.Getenv("DEPOSIT_VERIFY_TIMEOUT")); err == nil && d > 0 {
+		config.Timeout = d
+	}
+	return config
+}
+
+// Service decides which deposits need verification and runs it
+type Service struct {
+	verifier Verifier
+	config   Config
+}
+
+// NewService creates a receipt verification service
+func NewService(verifier Verifier, config Config) *Service {
+	return &Service{verifier: verifier, config: config}
+}
+
+// Required reports whether a deposit of amountMicro must be verified
+func (s *Service) Required(amountMicro int64) bool {
+	return s != nil && amountMicro >= s.config.ThresholdMicro
+}
+
+// Check verifies a deposit of amountMicro when it is at or above the
+// threshold. A nil Service verifies nothing.
+func (s *Service) Check(ctx context.Context, chain *models.SupportedChain, want Expected, amountMicro int64) error {
+	if !s.Required(amountMicro) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	return s.verifier.Verify(ctx, chain, want)
+}
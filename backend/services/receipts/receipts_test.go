@@ -0,0 +1,93 @@
+package receipts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/services/evmrpc"
+)
+
+const (
+	usdc    = "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"
+	deposit = "0x2222222222222222222222222222222222222222"
+)
+
+// rpcServer answers eth_getTransactionReceipt with result
+func rpcServer(t *testing.T, result string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Method string }
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "eth_getTransactionReceipt" {
+			t.Errorf("unexpected RPC request %+v (%v)", req, err)
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func transferReceipt(status, contract, to, amountHex string) string {
+	return `{"status":"` + status + `","logs":[{"address":"` + contract + `","topics":["` + evmrpc.TransferTopic + `",` +
+		`"0x0000000000000000000000001111111111111111111111111111111111111111",` +
+		`"0x000000000000000000000000` + to[2:] + `"],"data":"` + amountHex + `"}]}`
+}
+
+func TestRPCVerifier(t *testing.T) {
+	want := Expected{TxHash: "0xabc", Contract: usdc, To: deposit, Amount: "5000000000"} // 5,000 USDC
+	amount := "0x000000000000000000000000000000000000000000000000000000012a05f200"
+
+	tests := []struct {
+		name    string
+		result  string
+		wantErr error
+	}{
+		{"matching transfer", transferReceipt("0x1", usdc, deposit, amount), nil},
+		{"receipt not found", "null", ErrNotFound},
+		{"reverted", transferReceipt("0x0", usdc, deposit, amount), ErrReverted},
+		{"wrong amount", transferReceipt("0x1", usdc, deposit, "0x01"), ErrMismatch},
+		{"wrong recipient", transferReceipt("0x1", usdc, "0x3333333333333333333333333333333333333333", amount), ErrMismatch},
+		{"wrong contract", transferReceipt("0x1", "0x4444444444444444444444444444444444444444", deposit, amount), ErrMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &models.SupportedChain{RpcURL: rpcServer(t, tt.result).URL}
+			err := NewRPCVerifier().Verify(context.Background(), chain, want)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := NewRPCVerifier().Verify(context.Background(), &models.SupportedChain{}, want); !errors.Is(err, ErrNoRPC) {
+		t.Errorf("Verify without RPC = %v, want ErrNoRPC", err)
+	}
+}
+
+type countingVerifier struct{ calls int }
+
+func (c *countingVerifier) Verify(context.Context, *models.SupportedChain, Expected) error {
+	c.calls++
+	return ErrMismatch
+}
+
+func TestServiceOnlyChecksAboveThreshold(t *testing.T) {
+	verifier := &countingVerifier{}
+	svc := NewService(verifier, Config{ThresholdMicro: models.CreditsToMicro(1000), Timeout: defaultTimeout})
+
+	if err := svc.Check(context.Background(), &models.SupportedChain{}, Expected{}, models.CreditsToMicro(999)); err != nil || verifier.calls != 0 {
+		t.Errorf("small deposit: err %v, %d calls; want no check", err, verifier.calls)
+	}
+	if err := svc.Check(context.Background(), &models.SupportedChain{}, Expected{}, models.CreditsToMicro(1000)); !errors.Is(err, ErrMismatch) {
+		t.Errorf("large deposit: err %v, want ErrMismatch", err)
+	}
+
+	var none *Service
+	if none.Required(models.CreditsToMicro(1_000_000)) {
+		t.Error("a nil service should not require verification")
+	}
+}
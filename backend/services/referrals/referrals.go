@@ -0,0 +1,400 @@
+// Package referrals runs the referral program. Each user has a code to share;
+// a new user signed up with it earns their referrer a share of the bet fees
+// they pay for a limited time, up to a cap per referral. Rewards are ordinary
+// credits booked in the ledger, so they can be withdrawn like any other.
+//
+// A referral whose two users have logged in from the same device or IP looks
+// like a self-referral. It is flagged and earns nothing until an admin clears
+// it; an admin can instead block it, which takes back what it earned.
+package referrals
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions for admin referral reviews
+const (
+	ActionCleared = "REFERRAL_CLEARED"
+	ActionBlocked = "REFERRAL_BLOCKED"
+)
+
+const (
+	referenceType     = "referral"
+	codeLength        = 8
+	codeAlphabet      = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // No 0/O or 1/I
+	defaultRewardBps  = 2000
+	defaultRewardDays = 90
+	defaultRewardCap  = 100 // Credits per referral
+)
+
+var (
+	ErrInvalidCode      = errors.New("invalid referral code")
+	ErrSelfReferral     = errors.New("you cannot use your own referral code")
+	ErrAlreadyReferred  = errors.New("user was already referred")
+	ErrReferralNotFound = errors.New("referral not found")
+	ErrAlreadyBlocked   = errors.New("referral is already blocked")
+	ErrInvalidStatus    = errors.New("status must be ACTIVE, FLAGGED or BLOCKED")
+	ErrReasonRequired   = errors.New("reason is required")
+)
+
+// Config holds referral reward rules
+type Config struct {
+	RewardBps int64         // Share of the referee's bet fees paid to the referrer, in basis points
+	Window    time.Duration // How long after signup the referee's fees earn rewards
+	Cap       int64         // Most micro-credits a single referral can earn
+}
+
+// LoadConfigFromEnv reads REFERRAL_REWARD_BPS, REFERRAL_REWARD_DAYS and
+// REFERRAL_REWARD_CAP (credits)
+func LoadConfigFromEnv() Config {
+	config := Config{
+		RewardBps: defaultRewardBps,
+		Window:    defaultRewardDays * 24 * time.Hour,
+		Cap:       models.CreditsToMicro(defaultRewardCap),
+	}
+	if v, err := strconv.ParseInt(os.Getenv("REFERRAL_REWARD_BPS"), 10, 64); err == nil && v >= 0 && v <= 10000 {
+		config.RewardBps = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("REFERRAL_REWARD_DAYS")); err == nil && v >= 0 {
+		config.Window = time.Duration(v) * 24 * time.Hour
+	}
+	if v := os.Getenv("REFERRAL_REWARD_CAP"); v != "" {
+		if parsed, err := models.ParseCredits(v); err == nil {
+			config.Cap = parsed
+		}
+	}
+	return config
+}
+
+// Service manages referral codes, attribution and admin review
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a referral service
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	return &Service{db: db, config: config, clock: c}
+}
+
+func newCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// Code returns the user's referral code, creating it on first use
+func (s *Service) Code(userID int64) (*models.ReferralCode, error) {
+	var code models.ReferralCode
+	err := s.db.Where("user_id = ?", userID).First(&code).Error
+	if err == nil {
+		return &code, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	value, err := newCode()
+	if err != nil {
+		return nil, err
+	}
+	code = models.ReferralCode{UserID: userID, Code: value, CreatedAt: s.clock.Now()}
+	if err := s.db.Create(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// Attribute records that referee signed up with code. Callers run it in the
+// transaction that creates the referee.
+func (s *Service) Attribute(tx *gorm.DB, referee *models.User, code string) (*models.Referral, error) {
+	var owner models.ReferralCode
+	if err := tx.Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&owner).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCode
+		}
+		return nil, err
+	}
+	if owner.UserID == referee.ID {
+		return nil, ErrSelfReferral
+	}
+	var count int64
+	if err := tx.Model(&models.Referral{}).Where("referee_id = ?", referee.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyReferred
+	}
+
+	referral := models.Referral{
+		ReferrerID:   owner.UserID,
+		RefereeID:    referee.ID,
+		Code:         owner.Code,
+		Status:       models.ReferralStatusActive,
+		RewardsUntil: s.clock.Now().Add(s.config.Window),
+	}
+	if reason, err := selfReferralSignal(tx, owner.UserID, referee.ID); err != nil {
+		return nil, err
+	} else if reason != "" {
+		referral.Status, referral.FlagReason = models.ReferralStatusFlagged, reason
+	}
+	if err := tx.Create(&referral).Error; err != nil {
+		return nil, err
+	}
+	return &referral, nil
+}
+
+// RewardBetFees pays the referrer of the user their share of fees
+// micro-credits the user just paid. Referrals are checked for self-referral
+// on every reward until an admin has reviewed them, since the users' devices
+// and IPs only show up once they log in. Callers run it in the bet's
+// transaction.
+func RewardBetFees(tx *gorm.DB, refereeID int64, fees int64, config Config, now time.Time) error {
+	if fees <= 0 || config.RewardBps <= 0 {
+		return nil
+	}
+	var referral models.Referral
+	err := tx.Where("referee_id = ? AND status = ? AND rewards_until > ? AND rewarded < ?",
+		refereeID, models.ReferralStatusActive, now, config.Cap).First(&referral).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if referral.ReviewedAt == nil {
+		reason, err := selfReferralSignal(tx, referral.ReferrerID, referral.RefereeID)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return tx.Model(&referral).Updates(map[string]interface{}{
+				"status": models.ReferralStatusFlagged, "flag_reason": reason,
+			}).Error
+		}
+	}
+
+	reward := min(fees*config.RewardBps/10000, config.Cap-referral.Rewarded)
+	if reward <= 0 {
+		return nil
+	}
+	// Only take the reward if the cap still allows it, so concurrent bets cannot overrun it
+	result := tx.Model(&models.Referral{}).
+		Where("id = ? AND rewarded + ? <= ?", referral.ID, reward, config.Cap).
+		UpdateColumn("rewarded", gorm.Expr("rewarded + ?", reward))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	var referrer models.User
+	if err := tx.First(&referrer, referral.ReferrerID).Error; err != nil {
+		return fmt.Errorf("referrer lookup failed: %w", err)
+	}
+	_, err = ledger.Apply(tx, &referrer, ledger.Posting{
+		Type:          models.LedgerTypeReferralReward,
+		Amount:        reward,
+		ReferenceType: referenceType,
+		ReferenceID:   referral.ID,
+		Description:   fmt.Sprintf("Referral reward for user #%d's bet fees", refereeID),
+	})
+	return err
+}
+
+// selfReferralSignal returns why two users look like the same person, or ""
+func selfReferralSignal(tx *gorm.DB, referrerID, refereeID int64) (string, error) {
+	var shared int64
+	if err := tx.Table("user_devices AS a").
+		Joins("JOIN user_devices AS b ON a.device_key = b.device_key").
+		Where("a.user_id = ? AND b.user_id = ?", referrerID, refereeID).
+		Count(&shared).Error; err != nil {
+		return "", err
+	}
+	if shared > 0 {
+		return "Referrer and referee logged in from the same device", nil
+	}
+	if err := tx.Table("user_sessions AS a").
+		Joins("JOIN user_sessions AS b ON a.ip = b.ip").
+		Where("a.user_id = ? AND b.user_id = ? AND a.ip <> ''", referrerID, refereeID).
+		Count(&shared).Error; err != nil {
+		return "", err
+	}
+	if shared > 0 {
+		return "Referrer and referee logged in from the same IP address", nil
+	}
+	return "", nil
+}
+
+// ReferredUser is one of a referrer's referrals
+type ReferredUser struct {
+	ID           uint      `json:"id"`
+	Username     string    `json:"username"`
+	Status       string    `json:"status"`
+	Rewarded     int64     `json:"rewarded"`
+	RewardsUntil time.Time `json:"rewardsUntil"`
+	JoinedAt     time.Time `json:"joinedAt"`
+}
+
+// Stats summarises a user's referrals. Amounts are in micro-credits.
+type Stats struct {
+	Code      string         `json:"code"`
+	Referred  int            `json:"referred"`  // Users signed up with the code
+	Earning   int            `json:"earning"`   // Referrals still earning rewards
+	Rewarded  int64          `json:"rewarded"`  // Total rewards paid
+	RewardBps int64          `json:"rewardBps"` // Current share of referees' bet fees
+	Cap       int64          `json:"cap"`       // Most a single referral can earn
+	Referrals []ReferredUser `json:"referrals"`
+}
+
+// Stats returns the user's code and their referrals, newest first
+func (s *Service) Stats(userID int64) (*Stats, error) {
+	code, err := s.Code(userID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{Code: code.Code, RewardBps: s.config.RewardBps, Cap: s.config.Cap, Referrals: []ReferredUser{}}
+	err = s.db.Table("referrals").
+		Select("referrals.id, users.username, referrals.status, referrals.rewarded, referrals.rewards_until, referrals.created_at AS joined_at").
+		Joins("JOIN users ON users.id = referrals.referee_id").
+		Where("referrals.referrer_id = ? AND referrals.deleted_at IS NULL", userID).
+		Order("referrals.id DESC").
+		Scan(&stats.Referrals).Error
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	for _, r := range stats.Referrals {
+		stats.Referred++
+		stats.Rewarded += r.Rewarded
+		if r.Status == models.ReferralStatusActive && now.Before(r.RewardsUntil) && r.Rewarded < s.config.Cap {
+			stats.Earning++
+		}
+	}
+	return stats, nil
+}
+
+// List returns referrals for admin review, newest first, optionally by status
+func (s *Service) List(status string) ([]models.Referral, error) {
+	query := s.db.Order("id DESC").Limit(500)
+	if status != "" {
+		status = strings.ToUpper(status)
+		switch status {
+		case models.ReferralStatusActive, models.ReferralStatusFlagged, models.ReferralStatusBlocked:
+		default:
+			return nil, ErrInvalidStatus
+		}
+		query = query.Where("status = ?", status)
+	}
+	var referrals []models.Referral
+	err := query.Find(&referrals).Error
+	return referrals, err
+}
+
+func (s *Service) review(tx *gorm.DB, id uint) (*models.Referral, error) {
+	var referral models.Referral
+	if err := tx.First(&referral, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReferralNotFound
+		}
+		return nil, err
+	}
+	if referral.Status == models.ReferralStatusBlocked {
+		return nil, ErrAlreadyBlocked
+	}
+	return &referral, nil
+}
+
+// Clear marks a referral as genuine, so it earns rewards and is no longer
+// checked for self-referral
+func (s *Service) Clear(id uint, admin, note string) (*models.Referral, error) {
+	var referral *models.Referral
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if referral, err = s.review(tx, id); err != nil {
+			return err
+		}
+		now := s.clock.Now()
+		referral.Status, referral.FlagReason = models.ReferralStatusActive, ""
+		referral.ReviewedBy, referral.ReviewedAt = admin, &now
+		if err := tx.Save(referral).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionCleared,
+			TargetType: referenceType,
+			TargetID:   referral.ID,
+			Details:    fmt.Sprintf("referrer=%d referee=%d note=%q", referral.ReferrerID, referral.RefereeID, note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// Block rules a referral fraudulent: it stops earning and the referrer is
+// charged back everything it paid them
+func (s *Service) Block(id uint, admin, reason string) (*models.Referral, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, ErrReasonRequired
+	}
+	var referral *models.Referral
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if referral, err = s.review(tx, id); err != nil {
+			return err
+		}
+		if referral.Rewarded > 0 {
+			var referrer models.User
+			if err := tx.First(&referrer, referral.ReferrerID).Error; err != nil {
+				return fmt.Errorf("referrer lookup failed: %w", err)
+			}
+			if _, err := ledger.Apply(tx, &referrer, ledger.Posting{
+				Type:          models.LedgerTypeReferralRewardReversal,
+				Amount:        -referral.Rewarded,
+				ReferenceType: referenceType,
+				ReferenceID:   referral.ID,
+				Description:   "Referral blocked: " + reason,
+			}); err != nil {
+				return err
+			}
+		}
+		now := s.clock.Now()
+		referral.Status, referral.FlagReason = models.ReferralStatusBlocked, reason
+		referral.ReviewedBy, referral.ReviewedAt = admin, &now
+		if err := tx.Save(referral).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     ActionBlocked,
+			TargetType: referenceType,
+			TargetID:   referral.ID,
+			Details: fmt.Sprintf("referrer=%d referee=%d reversed=%s reason=%q", referral.ReferrerID, referral.RefereeID,
+				models.FormatMicroCredits(referral.Rewarded), reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
@@ -0,0 +1,135 @@
+package referrals
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestReferralRewardsAreCappedAndExpire(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	referrer := modelstesting.GenerateUser("referrer", 0)
+	referee := modelstesting.GenerateUser("referee", 0)
+	db.Create(&referrer)
+	db.Create(&referee)
+	fake := clock.NewFake(time.Date(2026, 5, 18, 9, 0, 0, 0, time.UTC))
+	config := Config{RewardBps: 5000, Window: 90 * 24 * time.Hour, Cap: models.CreditsToMicro(3)}
+	svc := NewService(db, config, fake)
+
+	code, err := svc.Code(referrer.ID)
+	if err != nil || len(code.Code) != codeLength {
+		t.Fatalf("code = %+v, %v", code, err)
+	}
+	if again, _ := svc.Code(referrer.ID); again.Code != code.Code {
+		t.Fatalf("code changed from %s to %s", code.Code, again.Code)
+	}
+
+	if _, err := svc.Attribute(db, &referrer, code.Code); !errors.Is(err, ErrSelfReferral) {
+		t.Fatalf("self-referral err = %v", err)
+	}
+	if _, err := svc.Attribute(db, &referee, "NOPE"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("bad code err = %v", err)
+	}
+	referral, err := svc.Attribute(db, &referee, " "+code.Code+" ")
+	if err != nil || referral.Status != models.ReferralStatusActive {
+		t.Fatalf("attribute = %+v, %v", referral, err)
+	}
+
+	// Half of 4 credits of fees, then only what is left under the 3 credit cap
+	for i := 0; i < 2; i++ {
+		if err := RewardBetFees(db, referee.ID, models.CreditsToMicro(4), config, fake.Now()); err != nil {
+			t.Fatalf("reward: %v", err)
+		}
+	}
+	db.First(&referrer, referrer.ID)
+	if referrer.BalanceMicroCredits() != models.CreditsToMicro(3) {
+		t.Fatalf("referrer balance = %d, want the 3 credit cap", referrer.BalanceMicroCredits())
+	}
+
+	stats, err := svc.Stats(referrer.ID)
+	if err != nil || stats.Referred != 1 || stats.Earning != 0 || stats.Rewarded != models.CreditsToMicro(3) {
+		t.Fatalf("stats = %+v, %v", stats, err)
+	}
+	if len(stats.Referrals) != 1 || stats.Referrals[0].Username != "referee" {
+		t.Fatalf("referrals = %+v", stats.Referrals)
+	}
+
+	// Fees after the window earn nothing
+	config.Cap = models.CreditsToMicro(100)
+	fake.Advance(91 * 24 * time.Hour)
+	if err := RewardBetFees(db, referee.ID, models.CreditsToMicro(4), config, fake.Now()); err != nil {
+		t.Fatalf("late reward: %v", err)
+	}
+	db.First(&referrer, referrer.ID)
+	if referrer.BalanceMicroCredits() != models.CreditsToMicro(3) {
+		t.Fatalf("referrer rewarded after the window: %d", referrer.BalanceMicroCredits())
+	}
+}
+
+func TestSharedDeviceFlagsReferralUntilReviewed(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	referrer := modelstesting.GenerateUser("referrer", 0)
+	referee := modelstesting.GenerateUser("referee", 0)
+	db.Create(&referrer)
+	db.Create(&referee)
+	fake := clock.NewFake(time.Date(2026, 5, 18, 9, 0, 0, 0, time.UTC))
+	config := Config{RewardBps: 5000, Window: 24 * time.Hour, Cap: models.CreditsToMicro(100)}
+	svc := NewService(db, config, fake)
+
+	code, _ := svc.Code(referrer.ID)
+	referral, err := svc.Attribute(db, &referee, code.Code)
+	if err != nil {
+		t.Fatalf("attribute: %v", err)
+	}
+	if err := RewardBetFees(db, referee.ID, models.CreditsToMicro(2), config, fake.Now()); err != nil {
+		t.Fatalf("reward: %v", err)
+	}
+
+	// Both users then log in from the same browser
+	for _, id := range []int64{referrer.ID, referee.ID} {
+		db.Create(&models.UserDevice{UserID: id, DeviceKey: "shared", FirstSeenAt: fake.Now(), LastSeenAt: fake.Now()})
+	}
+	if err := RewardBetFees(db, referee.ID, models.CreditsToMicro(2), config, fake.Now()); err != nil {
+		t.Fatalf("reward: %v", err)
+	}
+	db.First(referral, referral.ID)
+	if referral.Status != models.ReferralStatusFlagged || referral.Rewarded != models.CreditsToMicro(1) {
+		t.Fatalf("referral = %+v", referral)
+	}
+	if flagged, _ := svc.List("flagged"); len(flagged) != 1 {
+		t.Fatalf("flagged = %+v", flagged)
+	}
+
+	// A cleared referral earns again despite the shared device
+	if _, err := svc.Clear(referral.ID, "admin", "siblings"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if err := RewardBetFees(db, referee.ID, models.CreditsToMicro(2), config, fake.Now()); err != nil {
+		t.Fatalf("reward: %v", err)
+	}
+
+	if _, err := svc.Block(referral.ID, "admin", ""); !errors.Is(err, ErrReasonRequired) {
+		t.Fatalf("block without reason err = %v", err)
+	}
+	blocked, err := svc.Block(referral.ID, "admin", "same person")
+	if err != nil || blocked.Status != models.ReferralStatusBlocked {
+		t.Fatalf("block = %+v, %v", blocked, err)
+	}
+	db.First(&referrer, referrer.ID)
+	if referrer.BalanceMicroCredits() != 0 {
+		t.Fatalf("referrer kept %d after block", referrer.BalanceMicroCredits())
+	}
+	if _, err := svc.Clear(referral.ID, "admin", ""); !errors.Is(err, ErrAlreadyBlocked) {
+		t.Fatalf("clear blocked err = %v", err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("target_type = ?", referenceType).Count(&audits)
+	if audits != 2 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
@@ -0,0 +1,92 @@
+// Package replay guards webhook endpoints against replayed deliveries. Each
+// event ID is claimed once per source; claims are kept for twice the
+// timestamp tolerance, beyond which the timestamp check rejects a replay.
+package replay
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultTolerance is how far a webhook timestamp may be from the current time
+const defaultTolerance = 5 * time.Minute
+
+// Config holds replay protection settings
+type Config struct {
+	Tolerance time.Duration // Accepted clock difference for webhook timestamps
+}
+
+// LoadConfigFromEnv reads DFNS_WEBHOOK_TOLERANCE
+func LoadConfigFromEnv() Config {
+	config := Config{Tolerance: defaultTolerance}
+	if d, err := time.ParseDuration(os.Getenv("DFNS_WEBHOOK_TOLERANCE")); err == nil && d > 0 {
+		config.Tolerance = d
+	}
+	return config
+}
+
+// Guard records webhook event IDs
+type Guard struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewGuard creates a replay guard
+func NewGuard(db *gorm.DB, config Config, c clock.Clock) *Guard {
+	return &Guard{db: db, config: config, clock: c}
+}
+
+// Tolerance returns the accepted clock difference for webhook timestamps
+func (g *Guard) Tolerance() time.Duration {
+	return g.config.Tolerance
+}
+
+// Now returns the guard's current time, for timestamp checks
+func (g *Guard) Now() time.Time {
+	return g.clock.Now()
+}
+
+// Claim records eventID for source. It returns false if the event was already
+// claimed, i.e. the delivery is a replay or a duplicate.
+func (g *Guard) Claim(source, eventID string) (bool, error) {
+	result := g.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WebhookReceipt{
+		Source:     source,
+		EventID:    eventID,
+		ReceivedAt: g.clock.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Release forgets a claim so a failed event can be delivered again
+func (g *Guard) Release(source, eventID string) error {
+	return g.db.Where("source = ? AND event_id = ?", source, eventID).Delete(&models.WebhookReceipt{}).Error
+}
+
+// Prune deletes claims older than twice the tolerance and returns how many
+func (g *Guard) Prune() (int64, error) {
+	cutoff := g.clock.Now().Add(-2 * g.config.Tolerance)
+	result := g.db.Where("received_at < ?", cutoff).Delete(&models.WebhookReceipt{})
+	return result.RowsAffected, result.Error
+}
+
+// Run prunes expired claims every interval
+func (g *Guard) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := g.Prune(); err != nil {
+			log.Printf("Replay: Failed to prune webhook receipts: %v", err)
+		}
+	}
+}
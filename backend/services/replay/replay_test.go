@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestClaimRejectsDuplicates(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	guard := NewGuard(db, Config{Tolerance: 5 * time.Minute}, clock.NewFake(time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)))
+
+	claimed, err := guard.Claim("dfns:primary", "evt-1")
+	if err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v; want true", claimed, err)
+	}
+	if claimed, err := guard.Claim("dfns:primary", "evt-1"); err != nil || claimed {
+		t.Errorf("replayed claim = %v, %v; want false", claimed, err)
+	}
+	if claimed, err := guard.Claim("dfns:secondary", "evt-1"); err != nil || !claimed {
+		t.Errorf("claim from another source = %v, %v; want true", claimed, err)
+	}
+
+	if err := guard.Release("dfns:primary", "evt-1"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if claimed, err := guard.Claim("dfns:primary", "evt-1"); err != nil || !claimed {
+		t.Errorf("claim after release = %v, %v; want true", claimed, err)
+	}
+}
+
+func TestPruneKeepsTwiceTheTolerance(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	clk := clock.NewFake(time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC))
+	guard := NewGuard(db, Config{Tolerance: 5 * time.Minute}, clk)
+
+	if _, err := guard.Claim("dfns:primary", "old"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(8 * time.Minute)
+	if _, err := guard.Claim("dfns:primary", "recent"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(3 * time.Minute)
+
+	pruned, err := guard.Prune()
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d receipts, want 1", pruned)
+	}
+	var left []models.WebhookReceipt
+	db.Find(&left)
+	if len(left) != 1 || left[0].EventID != "recent" {
+		t.Errorf("receipts left = %+v, want only recent", left)
+	}
+}
@@ -0,0 +1,241 @@
+// Package resolutioncost attributes oracle and staff costs of resolving a
+// market to that market, optionally recovers them from the fees the market
+// collected, and reports per-market profitability.
+package resolutioncost
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+// ActionRecorded is the audit action for a recorded resolution cost
+const ActionRecorded = "RESOLUTION_COST_RECORDED"
+
+const referenceType = "market"
+
+var (
+	ErrInvalidAmount = errors.New("amount must be positive")
+	ErrInvalidSource = errors.New("source must be ORACLE, STAFF, FEE or OTHER")
+)
+
+// Config holds resolution cost settings
+type Config struct {
+	ResolutionFee   int64 // Micro-credits charged to every market on resolution; 0 disables
+	RecoverFromFees bool  // Cover costs from the market's fee pool where possible
+}
+
+// LoadConfigFromEnv reads RESOLUTION_FEE (credits) and RESOLUTION_COST_RECOVER_FROM_FEES
+func LoadConfigFromEnv() Config {
+	var config Config
+	if v := os.Getenv("RESOLUTION_FEE"); v != "" {
+		if parsed, err := models.ParseCredits(v); err == nil {
+			config.ResolutionFee = parsed
+		}
+	}
+	config.RecoverFromFees, _ = strconv.ParseBool(os.Getenv("RESOLUTION_COST_RECOVER_FROM_FEES"))
+	return config
+}
+
+// Profitability summarises a market's economics. Amounts are in micro-credits.
+type Profitability struct {
+	MarketID        int64                   `json:"marketId"`
+	Volume          int64                   `json:"volume"`  // Total bought
+	Fees            int64                   `json:"fees"`    // Bet fees collected
+	Subsidy         int64                   `json:"subsidy"` // Initial market subsidization
+	ResolutionCost  int64                   `json:"resolutionCost"`
+	RecoveredCost   int64                   `json:"recoveredCost"`   // Resolution cost covered by fees
+	UnrecoveredCost int64                   `json:"unrecoveredCost"` // Resolution cost borne by the platform
+	Net             int64                   `json:"net"`             // Fees minus subsidy and resolution cost
+	Costs           []models.ResolutionCost `json:"costs"`
+}
+
+// Service records resolution costs and computes market profitability
+type Service struct {
+	db             *gorm.DB
+	config         Config
+	loadEconConfig setup.EconConfigLoader
+	clock          clock.Clock
+}
+
+// NewService creates a resolution cost service
+func NewService(db *gorm.DB, config Config, loadEconConfig setup.EconConfigLoader, c clock.Clock) *Service {
+	return &Service{db: db, config: config, loadEconConfig: loadEconConfig, clock: c}
+}
+
+// RecordInput describes a cost to attribute to a market
+type RecordInput struct {
+	MarketID    int64
+	Source      string
+	Amount      int64 // Micro-credits
+	Description string
+	RecordedBy  string
+}
+
+// Record attributes a cost to a market and books it in the platform ledger.
+// When recovery is enabled, as much as the market's unused fee pool allows is
+// booked as recovered.
+func (s *Service) Record(in RecordInput) (*models.ResolutionCost, error) {
+	if in.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	switch in.Source {
+	case models.ResolutionCostSourceOracle, models.ResolutionCostSourceStaff,
+		models.ResolutionCostSourceFee, models.ResolutionCostSourceOther:
+	default:
+		return nil, ErrInvalidSource
+	}
+
+	var cost models.ResolutionCost
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var market models.Market
+		if err := tx.First(&market, in.MarketID).Error; err != nil {
+			return err
+		}
+
+		cost = models.ResolutionCost{
+			MarketID:    market.ID,
+			Source:      in.Source,
+			Amount:      in.Amount,
+			Description: in.Description,
+			RecordedBy:  in.RecordedBy,
+		}
+		if s.config.RecoverFromFees {
+			available, err := s.unrecoveredFees(tx, market.ID)
+			if err != nil {
+				return err
+			}
+			cost.Recovered = min(in.Amount, max(available, 0))
+		}
+		if err := tx.Create(&cost).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("%s resolution cost for market %d", in.Source, market.ID)
+		if _, err := ledger.RecordPlatform(tx, ledger.Posting{
+			Type:          models.LedgerTypeResolutionCost,
+			Amount:        -cost.Amount,
+			ReferenceType: referenceType,
+			ReferenceID:   uint(market.ID),
+			Description:   description,
+		}); err != nil {
+			return err
+		}
+		if cost.Recovered > 0 {
+			if _, err := ledger.RecordPlatform(tx, ledger.Posting{
+				Type:          models.LedgerTypeResolutionCostRecovery,
+				Amount:        cost.Recovered,
+				ReferenceType: referenceType,
+				ReferenceID:   uint(market.ID),
+				Description:   description + " recovered from fee pool",
+			}); err != nil {
+				return err
+			}
+		}
+
+		return audit.Record(tx, models.AuditLog{
+			Actor:      in.RecordedBy,
+			Action:     ActionRecorded,
+			TargetType: referenceType,
+			TargetID:   uint(market.ID),
+			Details: fmt.Sprintf("source=%s amount=%s recovered=%s description=%q", cost.Source,
+				models.FormatMicroCredits(cost.Amount), models.FormatMicroCredits(cost.Recovered), cost.Description),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+// ChargeResolutionFee records the configured flat resolution fee, if any
+func (s *Service) ChargeResolutionFee(marketID int64, resolvedBy string) (*models.ResolutionCost, error) {
+	if s.config.ResolutionFee <= 0 {
+		return nil, nil
+	}
+	return s.Record(RecordInput{
+		MarketID:    marketID,
+		Source:      models.ResolutionCostSourceFee,
+		Amount:      s.config.ResolutionFee,
+		Description: "Resolution fee",
+		RecordedBy:  resolvedBy,
+	})
+}
+
+// Profitability reports volume, fees, subsidy and resolution cost for a market
+func (s *Service) Profitability(marketID int64) (*Profitability, error) {
+	var market models.Market
+	if err := s.db.First(&market, marketID).Error; err != nil {
+		return nil, err
+	}
+
+	report := &Profitability{
+		MarketID: market.ID,
+		Subsidy:  models.CreditsToMicro(s.loadEconConfig().Economics.MarketCreation.InitialMarketSubsidization),
+		Costs:    []models.ResolutionCost{},
+	}
+
+	var err error
+	if report.Volume, report.Fees, err = s.volumeAndFees(s.db, market.ID); err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("market_id = ?", market.ID).Order("created_at ASC, id ASC").Find(&report.Costs).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range report.Costs {
+		report.ResolutionCost += c.Amount
+		report.RecoveredCost += c.Recovered
+	}
+	report.UnrecoveredCost = report.ResolutionCost - report.RecoveredCost
+	report.Net = report.Fees - report.Subsidy - report.ResolutionCost
+	return report, nil
+}
+
+// unrecoveredFees is the part of the market's fee pool not yet used to recover costs
+func (s *Service) unrecoveredFees(tx *gorm.DB, marketID int64) (int64, error) {
+	_, fees, err := s.volumeAndFees(tx, marketID)
+	if err != nil {
+		return 0, err
+	}
+	var recovered int64
+	if err := tx.Model(&models.ResolutionCost{}).Where("market_id = ?", marketID).
+		Select("COALESCE(SUM(recovered), 0)").Scan(&recovered).Error; err != nil {
+		return 0, err
+	}
+	return fees - recovered, nil
+}
+
+// volumeAndFees derives bought volume and collected bet fees from the market's
+// bets and the current fee schedule, in micro-credits
+func (s *Service) volumeAndFees(tx *gorm.DB, marketID int64) (int64, int64, error) {
+	var bets []models.Bet
+	if err := tx.Where("market_id = ?", marketID).Order("placed_at ASC, id ASC").Find(&bets).Error; err != nil {
+		return 0, 0, err
+	}
+
+	betFees := s.loadEconConfig().Economics.Betting.BetFees
+	var volume, fees int64
+	seen := map[string]bool{}
+	for _, bet := range bets {
+		if !seen[bet.Username] {
+			seen[bet.Username] = true
+			fees += betFees.InitialBetFee
+		}
+		if bet.Amount > 0 {
+			volume += bet.Amount
+			fees += betFees.BuySharesFee
+		} else {
+			fees += betFees.SellSharesFee
+		}
+	}
+	return models.CreditsToMicro(volume), models.CreditsToMicro(fees), nil
+}
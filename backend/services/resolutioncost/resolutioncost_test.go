@@ -0,0 +1,94 @@
+package resolutioncost
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func setupMarket(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	market := modelstesting.GenerateMarket(1, "creator")
+	if err := db.Create(&market).Error; err != nil {
+		t.Fatalf("create market: %v", err)
+	}
+	// Two bettors pay the initial bet fee once each
+	for _, b := range []models.Bet{
+		modelstesting.GenerateBet(100, "YES", "alice", 1, 0),
+		modelstesting.GenerateBet(50, "NO", "bob", 1, 0),
+		modelstesting.GenerateBet(20, "YES", "alice", 1, 0),
+	} {
+		if err := db.Create(&b).Error; err != nil {
+			t.Fatalf("create bet: %v", err)
+		}
+	}
+	return db
+}
+
+func TestRecordRecoversUpToFeePool(t *testing.T) {
+	db := setupMarket(t)
+	econ, load := modelstesting.UseStandardTestEconomics(t)
+	svc := NewService(db, Config{RecoverFromFees: true}, load, clock.New())
+
+	pool := models.CreditsToMicro(2 * econ.Economics.Betting.BetFees.InitialBetFee)
+	cost, err := svc.Record(RecordInput{MarketID: 1, Source: models.ResolutionCostSourceOracle, Amount: pool + 500, RecordedBy: "admin"})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if cost.Recovered != pool {
+		t.Fatalf("recovered = %d, want %d", cost.Recovered, pool)
+	}
+
+	// The pool is exhausted, so nothing more is recovered
+	second, err := svc.Record(RecordInput{MarketID: 1, Source: models.ResolutionCostSourceStaff, Amount: 1000, RecordedBy: "admin"})
+	if err != nil || second.Recovered != 0 {
+		t.Fatalf("second record: %v, recovered %d", err, second.Recovered)
+	}
+
+	var entries []models.LedgerEntry
+	db.Where("reference_type = ? AND reference_id = ?", referenceType, 1).Order("id").Find(&entries)
+	if len(entries) != 3 || entries[0].Amount != -(pool+500) || entries[1].Amount != pool || entries[2].Amount != -1000 {
+		t.Fatalf("unexpected ledger entries: %+v", entries)
+	}
+	for _, e := range entries {
+		if e.UserID != models.PlatformUserID {
+			t.Fatalf("expected platform ledger entry, got %+v", e)
+		}
+	}
+
+	report, err := svc.Profitability(1)
+	if err != nil {
+		t.Fatalf("profitability: %v", err)
+	}
+	subsidy := models.CreditsToMicro(econ.Economics.MarketCreation.InitialMarketSubsidization)
+	if report.Volume != models.CreditsToMicro(170) || report.Fees != pool || report.Subsidy != subsidy ||
+		report.ResolutionCost != pool+1500 || report.RecoveredCost != pool || report.UnrecoveredCost != 1500 ||
+		report.Net != pool-subsidy-(pool+1500) || len(report.Costs) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestRecordValidatesInput(t *testing.T) {
+	db := setupMarket(t)
+	_, load := modelstesting.UseStandardTestEconomics(t)
+	svc := NewService(db, Config{}, load, clock.New())
+
+	if _, err := svc.Record(RecordInput{MarketID: 1, Source: models.ResolutionCostSourceOracle}); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount, got %v", err)
+	}
+	if _, err := svc.Record(RecordInput{MarketID: 1, Source: "BRIBE", Amount: 1}); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("expected ErrInvalidSource, got %v", err)
+	}
+	if _, err := svc.Record(RecordInput{MarketID: 99, Source: models.ResolutionCostSourceOracle, Amount: 1}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+	if cost, err := svc.ChargeResolutionFee(1, "creator"); cost != nil || err != nil {
+		t.Errorf("expected no fee when unconfigured, got %+v %v", cost, err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package restrictions lets admins freeze parts of a single user's account:
+// withdrawals, trading, or deposits, which are then held for review rather
+// than credited. Each restriction carries a reason and an optional expiry.
+package restrictions
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions
+const (
+	ActionRestrictionSet    = "USER_RESTRICTION_SET"
+	ActionRestrictionLifted = "USER_RESTRICTION_LIFTED"
+)
+
+var (
+	ErrUnknownKind    = errors.New("unknown restriction; use withdrawals_frozen, trading_frozen or deposits_blocked")
+	ErrReasonRequired = errors.New("a reason is required to restrict an account")
+	ErrInvalidExpiry  = errors.New("a restriction must expire in the future")
+	ErrNotFound       = errors.New("restriction not found")
+)
+
+// RestrictedError is returned when a restriction on the user's account
+// refuses what they are doing
+type RestrictedError struct {
+	Restriction models.UserRestriction
+}
+
+func (e *RestrictedError) Error() string {
+	var what string
+	switch e.Restriction.Kind {
+	case models.RestrictionWithdrawalsFrozen:
+		what = "Withdrawals are frozen on this account"
+	case models.RestrictionTradingFrozen:
+		what = "Trading is frozen on this account"
+	default:
+		what = "Deposits are blocked on this account"
+	}
+	if e.Restriction.ExpiresAt != nil {
+		what += " until " + e.Restriction.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return what + ": " + e.Restriction.Reason
+}
+
+// Active returns the restrictions in force on the user's account at now
+func Active(db *gorm.DB, userID int64, now time.Time) ([]models.UserRestriction, error) {
+	restrictions := []models.UserRestriction{}
+	err := db.Where("user_id = ? AND (expires_at IS NULL OR expires_at > ?)", userID, now).
+		Order("kind").Find(&restrictions).Error
+	return restrictions, err
+}
+
+// Check returns a *RestrictedError if a restriction of kind is in force on
+// the user's account at now
+func Check(db *gorm.DB, userID int64, kind string, now time.Time) error {
+	var restriction models.UserRestriction
+	if err := db.Where("user_id = ? AND kind = ?", userID, kind).Limit(1).Find(&restriction).Error; err != nil {
+		return fmt.Errorf("failed to load account restrictions: %w", err)
+	}
+	if restriction.ID == 0 || !restriction.ActiveAt(now) {
+		return nil
+	}
+	return &RestrictedError{Restriction: restriction}
+}
+
+// Set places or replaces a restriction of kind on the user's account, until
+// expiresAt or, when nil, until lifted, and audits it
+func Set(db *gorm.DB, userID int64, kind, reason string, expiresAt *time.Time, actor string, now time.Time) (*models.UserRestriction, error) {
+	if !slices.Contains(models.RestrictionKinds, kind) {
+		return nil, ErrUnknownKind
+	}
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, ErrInvalidExpiry
+	}
+
+	restriction := models.UserRestriction{UserID: userID, Kind: kind, Reason: reason, ExpiresAt: expiresAt, UpdatedBy: actor}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "kind"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason", "expires_at", "updated_by", "updated_at"}),
+		}).Create(&restriction).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND kind = ?", userID, kind).First(&restriction).Error; err != nil {
+			return err
+		}
+		until := "lifted"
+		if expiresAt != nil {
+			until = expiresAt.UTC().Format(time.RFC3339)
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionRestrictionSet,
+			TargetType: "user",
+			TargetID:   uint(userID),
+			Details:    fmt.Sprintf("kind=%s until=%s reason=%s", kind, until, reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// Lift removes a restriction of kind from the user's account and audits it
+func Lift(db *gorm.DB, userID int64, kind, actor string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("user_id = ? AND kind = ?", userID, kind).Delete(&models.UserRestriction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionRestrictionLifted,
+			TargetType: "user",
+			TargetID:   uint(userID),
+			Details:    fmt.Sprintf("kind=%s", kind),
+		})
+	})
+}
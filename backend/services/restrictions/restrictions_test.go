@@ -0,0 +1,68 @@
+package restrictions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestRestrictionsExpireAndLift(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Now()
+	past, later := now.Add(-time.Minute), now.Add(time.Hour)
+
+	if _, err := Set(db, 7, "everything_frozen", "fraud", nil, "admin", now); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("unknown kind = %v, want ErrUnknownKind", err)
+	}
+	if _, err := Set(db, 7, models.RestrictionTradingFrozen, "", nil, "admin", now); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("no reason = %v, want ErrReasonRequired", err)
+	}
+	if _, err := Set(db, 7, models.RestrictionTradingFrozen, "fraud", &past, "admin", now); !errors.Is(err, ErrInvalidExpiry) {
+		t.Errorf("past expiry = %v, want ErrInvalidExpiry", err)
+	}
+
+	if _, err := Set(db, 7, models.RestrictionTradingFrozen, "chargeback", &later, "admin", now); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	var restrictedErr *RestrictedError
+	if err := Check(db, 7, models.RestrictionTradingFrozen, now); !errors.As(err, &restrictedErr) || restrictedErr.Restriction.Reason != "chargeback" {
+		t.Fatalf("Check = %v, want the trading freeze", err)
+	}
+	if err := Check(db, 7, models.RestrictionWithdrawalsFrozen, now); err != nil {
+		t.Errorf("withdrawals Check = %v, want only trading frozen", err)
+	}
+	if err := Check(db, 8, models.RestrictionTradingFrozen, now); err != nil {
+		t.Errorf("other user Check = %v, want no restriction", err)
+	}
+	if err := Check(db, 7, models.RestrictionTradingFrozen, later); err != nil {
+		t.Errorf("Check at expiry = %v, want the freeze over", err)
+	}
+
+	// Setting it again replaces the reason and expiry
+	if _, err := Set(db, 7, models.RestrictionTradingFrozen, "chargeback confirmed", nil, "admin", now); err != nil {
+		t.Fatalf("Set again: %v", err)
+	}
+	active, err := Active(db, 7, later.Add(time.Hour))
+	if err != nil || len(active) != 1 || active[0].ExpiresAt != nil || active[0].Reason != "chargeback confirmed" {
+		t.Fatalf("Active = %+v, %v; want one open-ended freeze", active, err)
+	}
+
+	if err := Lift(db, 7, models.RestrictionTradingFrozen, "admin"); err != nil {
+		t.Fatalf("Lift: %v", err)
+	}
+	if err := Lift(db, 7, models.RestrictionTradingFrozen, "admin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lift again = %v, want ErrNotFound", err)
+	}
+	if err := Check(db, 7, models.RestrictionTradingFrozen, now); err != nil {
+		t.Errorf("Check after lift = %v", err)
+	}
+
+	var audited int64
+	db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ?", "user", 7).Count(&audited)
+	if audited != 3 {
+		t.Errorf("audited %d changes, want 3", audited)
+	}
+}
@@ -0,0 +1,136 @@
+// Package risk scores withdrawal requests so admins can review the riskiest first.
+package risk
+
+import (
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/addresslabels"
+
+	"gorm.io/gorm"
+)
+
+// Risk reason codes stored on WithdrawalRequest.RiskReasons
+const (
+	ReasonNewDestination      = "NEW_DESTINATION"
+	ReasonDepositThenWithdraw = "DEPOSIT_THEN_WITHDRAW"
+	ReasonVelocity            = "VELOCITY"
+	ReasonUnusualAmount       = "UNUSUAL_AMOUNT"
+	ReasonWashTrading         = "WASH_TRADING"
+	ReasonMixerDestination    = "MIXER_DESTINATION"
+)
+
+// Scoring weights and thresholds
+const (
+	weightNewDestination      = 25
+	weightDepositThenWithdraw = 30
+	weightVelocity            = 20
+	weightUnusualAmount       = 25
+	weightWashTrading         = 30
+	weightMixerDestination    = 60
+
+	depositWindow      = time.Hour           // Withdrawal this soon after a deposit is suspicious
+	velocityWindow     = 24 * time.Hour      // Window for counting recent withdrawals
+	velocityThreshold  = 3                   // Recent withdrawals (excluding this one) that trigger the velocity rule
+	amountMultiplier   = 5                   // Multiple of the user's average withdrawal considered unusual
+	firstWithdrawalCap = 1000                // Credits; first withdrawals above this are unusual
+	washTradingWindow  = 30 * 24 * time.Hour // Wash trade flags this recent count against the user
+	maxScore           = 100
+)
+
+// Assessment is the result of scoring a withdrawal request
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+// Scorer computes risk scores from a user's wallet history
+type Scorer struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewScorer creates a risk scorer
+func NewScorer(db *gorm.DB, c clock.Clock) *Scorer {
+	return &Scorer{db: db, clock: c}
+}
+
+// Score evaluates a withdrawal request that has not been saved yet
+func (s *Scorer) Score(req *models.WithdrawalRequest) Assessment {
+	now := s.clock.Now()
+	var a Assessment
+
+	var sameDestination int64
+	s.db.Model(&models.WithdrawalRequest{}).
+		Where("user_id = ? AND LOWER(to_address) = ?", req.UserID, strings.ToLower(req.ToAddress)).
+		Count(&sameDestination)
+	if sameDestination == 0 {
+		a.add(ReasonNewDestination, weightNewDestination)
+	}
+
+	var recentDeposits int64
+	s.db.Model(&models.CryptoTransaction{}).
+		Where("user_id = ? AND type = ? AND created_at >= ?", req.UserID, models.TxTypeDeposit, now.Add(-depositWindow)).
+		Count(&recentDeposits)
+	if recentDeposits > 0 {
+		a.add(ReasonDepositThenWithdraw, weightDepositThenWithdraw)
+	}
+
+	var recentWithdrawals int64
+	s.db.Model(&models.WithdrawalRequest{}).
+		Where("user_id = ? AND created_at >= ?", req.UserID, now.Add(-velocityWindow)).
+		Count(&recentWithdrawals)
+	if recentWithdrawals >= velocityThreshold {
+		a.add(ReasonVelocity, weightVelocity)
+	}
+
+	var history struct {
+		Count int64
+		Total int64
+	}
+	s.db.Model(&models.WithdrawalRequest{}).
+		Where("user_id = ? AND status <> ?", req.UserID, models.TxStatusRejected).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total").
+		Scan(&history)
+	if history.Count == 0 {
+		if req.Amount > models.CreditsToMicro(firstWithdrawalCap) {
+			a.add(ReasonUnusualAmount, weightUnusualAmount)
+		}
+	} else if req.Amount > amountMultiplier*(history.Total/history.Count) {
+		a.add(ReasonUnusualAmount, weightUnusualAmount)
+	}
+
+	var washFlags int64
+	s.db.Model(&models.WashTradeFlag{}).
+		Joins("JOIN users ON users.username = wash_trade_flags.username OR users.username = wash_trade_flags.counterparty").
+		Where("users.id = ? AND wash_trade_flags.detected_at >= ?", req.UserID, now.Add(-washTradingWindow)).
+		Count(&washFlags)
+	if washFlags > 0 {
+		a.add(ReasonWashTrading, weightWashTrading)
+	}
+
+	// Funds sent straight to a known mixer
+	if label, err := addresslabels.Lookup(s.db, req.ChainName, req.ToAddress); err == nil && label != nil && label.Kind == models.AddressKindMixer {
+		a.add(ReasonMixerDestination, weightMixerDestination)
+	}
+
+	if a.Score > maxScore {
+		a.Score = maxScore
+	}
+	return a
+}
+
+// Apply scores the request and stores the result on it
+func (s *Scorer) Apply(req *models.WithdrawalRequest) Assessment {
+	a := s.Score(req)
+	req.RiskScore = a.Score
+	req.RiskReasons = strings.Join(a.Reasons, ",")
+	return a
+}
+
+func (a *Assessment) add(reason string, weight int) {
+	a.Score += weight
+	a.Reasons = append(a.Reasons, reason)
+}
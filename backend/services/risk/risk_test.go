@@ -0,0 +1,74 @@
+package risk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/addresslabels"
+)
+
+func TestScoreFirstWithdrawalToNewAddress(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	scorer := NewScorer(db, clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	req := &models.WithdrawalRequest{UserID: 1, ToAddress: "0xAbC", Amount: models.CreditsToMicro(50)}
+	a := scorer.Apply(req)
+
+	if a.Score != weightNewDestination || !reflect.DeepEqual(a.Reasons, []string{ReasonNewDestination}) {
+		t.Fatalf("unexpected assessment: %+v", a)
+	}
+	if req.RiskScore != weightNewDestination || req.RiskReasons != ReasonNewDestination {
+		t.Fatalf("assessment not applied: %d %q", req.RiskScore, req.RiskReasons)
+	}
+}
+
+func TestScoreAccumulatesReasons(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	scorer := NewScorer(db, clock.NewFake(now))
+
+	deposit := models.CryptoTransaction{UserID: 1, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted, ChainName: "base", TokenSymbol: "USDC", TxHash: "0xdep"}
+	deposit.CreatedAt = now.Add(-10 * time.Minute)
+	if err := db.Create(&deposit).Error; err != nil {
+		t.Fatalf("create deposit: %v", err)
+	}
+	for i := 0; i < velocityThreshold; i++ {
+		prior := models.WithdrawalRequest{UserID: 1, ChainID: 8453, ChainName: "base", TokenSymbol: "USDC", Amount: models.CreditsToMicro(10), ToAddress: "0xold", Status: models.TxStatusCompleted}
+		prior.CreatedAt = now.Add(-time.Duration(i+1) * time.Hour)
+		if err := db.Create(&prior).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+
+	a := scorer.Score(&models.WithdrawalRequest{UserID: 1, ToAddress: "0xnew", Amount: models.CreditsToMicro(100)})
+	want := []string{ReasonNewDestination, ReasonDepositThenWithdraw, ReasonVelocity, ReasonUnusualAmount}
+	if !reflect.DeepEqual(a.Reasons, want) || a.Score != maxScore {
+		t.Fatalf("unexpected assessment: %+v", a)
+	}
+
+	known := scorer.Score(&models.WithdrawalRequest{UserID: 1, ToAddress: "0xOLD", Amount: models.CreditsToMicro(10)})
+	for _, reason := range known.Reasons {
+		if reason == ReasonNewDestination || reason == ReasonUnusualAmount {
+			t.Fatalf("unexpected reason %s for a known address and usual amount", reason)
+		}
+	}
+}
+
+func TestScoreFlagsMixerDestination(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	scorer := NewScorer(db, clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+	mixer := "0xd90e2f925DA726b50C4Ed8D0Fb90Ad053324F31b"
+	if _, err := addresslabels.Set(db, addresslabels.Input{Address: mixer, Kind: models.AddressKindMixer, Name: "Tornado Cash"}, models.AddressLabelManual, "root"); err != nil {
+		t.Fatalf("label mixer: %v", err)
+	}
+
+	a := scorer.Score(&models.WithdrawalRequest{UserID: 1, ChainName: "ethereum", ToAddress: mixer, Amount: models.CreditsToMicro(50)})
+	want := []string{ReasonNewDestination, ReasonMixerDestination}
+	if !reflect.DeepEqual(a.Reasons, want) || a.Score != weightNewDestination+weightMixerDestination {
+		t.Fatalf("unexpected assessment: %+v", a)
+	}
+}
@@ -0,0 +1,160 @@
+// Package roles grants admins permissions through named roles, so that, for
+// example, support staff can look into withdrawals while only finance admins
+// approve them and only superadmins change chain configuration.
+package roles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionRolesUpdated is the audit action for a change to an admin's roles
+const ActionRolesUpdated = "ADMIN_ROLES_UPDATED"
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrNotAdmin       = errors.New("roles can only be given to admins")
+	ErrUnknownRole    = errors.New("unknown role")
+	ErrLastSuperAdmin = errors.New("the last superadmin cannot give up the role")
+)
+
+// HasPermission reports whether any of the user's roles grants permission
+func HasPermission(db *gorm.DB, userID int64, permission string) (bool, error) {
+	held, err := ForUser(db, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range held {
+		if role.Grants(permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// List returns every role, by name
+func List(db *gorm.DB) ([]models.Role, error) {
+	roles := []models.Role{}
+	err := db.Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// ForUser returns the roles held by a user, by name
+func ForUser(db *gorm.DB, userID int64) ([]models.Role, error) {
+	roles := []models.Role{}
+	err := db.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").Find(&roles).Error
+	return roles, err
+}
+
+// Assign gives a user a role they may already hold
+func Assign(tx *gorm.DB, userID int64, name, grantedBy string) error {
+	var role models.Role
+	if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownRole
+		}
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UserRole{UserID: userID, RoleID: role.ID, GrantedBy: grantedBy}).Error
+}
+
+// Set replaces an admin's roles with the named ones and audits the change.
+// The platform always keeps at least one superadmin.
+func Set(db *gorm.DB, username string, names []string, actor string) ([]models.Role, error) {
+	var result []models.Role
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("username = ?", username).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if user.UserType != "ADMIN" {
+			return ErrNotAdmin
+		}
+
+		wanted := []models.Role{}
+		if len(names) > 0 {
+			if err := tx.Where("name IN ?", names).Order("name").Find(&wanted).Error; err != nil {
+				return err
+			}
+		}
+		if len(wanted) != len(unique(names)) {
+			return ErrUnknownRole
+		}
+
+		previous, err := ForUser(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if holds(previous, models.RoleSuperAdmin) && !holds(wanted, models.RoleSuperAdmin) {
+			var superadmins int64
+			if err := tx.Model(&models.UserRole{}).
+				Joins("JOIN roles ON roles.id = user_roles.role_id").
+				Where("roles.name = ?", models.RoleSuperAdmin).
+				Count(&superadmins).Error; err != nil {
+				return err
+			}
+			if superadmins <= 1 {
+				return ErrLastSuperAdmin
+			}
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, role := range wanted {
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: role.ID, GrantedBy: actor}).Error; err != nil {
+				return err
+			}
+		}
+		result = wanted
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionRolesUpdated,
+			TargetType: "user",
+			TargetID:   uint(user.ID),
+			Details:    fmt.Sprintf("roles [%s] -> [%s]", joinNames(previous), joinNames(wanted)),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func holds(roles []models.Role, name string) bool {
+	for _, role := range roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func unique(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func joinNames(roles []models.Role) string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,77 @@
+package roles
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestRolesGrantPermissionsAndKeepASuperadmin(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	root := modelstesting.GenerateUser("root", 0)
+	root.UserType = "ADMIN"
+	staff := modelstesting.GenerateUser("staff", 0)
+	staff.UserType = "ADMIN"
+	user := modelstesting.GenerateUser("user", 0)
+	db.Create(&root)
+	db.Create(&staff)
+	db.Create(&user)
+	if err := Assign(db, root.ID, models.RoleSuperAdmin, "test"); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+
+	// An admin without roles holds no permissions
+	if ok, _ := HasPermission(db, staff.ID, models.PermWithdrawalsView); ok {
+		t.Fatalf("staff can view withdrawals without a role")
+	}
+
+	if _, err := Set(db, "staff", []string{models.RoleSupport}, "root"); err != nil {
+		t.Fatalf("set support: %v", err)
+	}
+	if ok, _ := HasPermission(db, staff.ID, models.PermWithdrawalsView); !ok {
+		t.Fatalf("support cannot view withdrawals")
+	}
+	if ok, _ := HasPermission(db, staff.ID, models.PermWithdrawalsApprove); ok {
+		t.Fatalf("support can approve withdrawals")
+	}
+	if ok, _ := HasPermission(db, root.ID, models.PermChainsManage); !ok {
+		t.Fatalf("superadmin cannot manage chains")
+	}
+
+	held, err := Set(db, "staff", []string{models.RoleFinance, models.RoleSupport}, "root")
+	if err != nil || len(held) != 2 {
+		t.Fatalf("set finance = %+v, %v", held, err)
+	}
+	if ok, _ := HasPermission(db, staff.ID, models.PermWithdrawalsApprove); !ok {
+		t.Fatalf("finance cannot approve withdrawals")
+	}
+	if ok, _ := HasPermission(db, staff.ID, models.PermChainsManage); ok {
+		t.Fatalf("finance can manage chains")
+	}
+
+	if _, err := Set(db, "user", []string{models.RoleSupport}, "root"); !errors.Is(err, ErrNotAdmin) {
+		t.Fatalf("non-admin err = %v", err)
+	}
+	if _, err := Set(db, "staff", []string{"janitor"}, "root"); !errors.Is(err, ErrUnknownRole) {
+		t.Fatalf("unknown role err = %v", err)
+	}
+	if _, err := Set(db, "root", nil, "root"); !errors.Is(err, ErrLastSuperAdmin) {
+		t.Fatalf("last superadmin err = %v", err)
+	}
+
+	// With a second superadmin the first may step down
+	if _, err := Set(db, "staff", []string{models.RoleSuperAdmin}, "root"); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if _, err := Set(db, "root", []string{models.RoleFinance}, "staff"); err != nil {
+		t.Fatalf("step down: %v", err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ?", ActionRolesUpdated).Count(&audits)
+	if audits != 4 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
@@ -0,0 +1,297 @@
+// Package saga coordinates multi-step money flows. Each flow is a list of
+// steps with optional compensations; step state is persisted per instance so a
+// failure part-way through is undone, or retried, the same way every time.
+package saga
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownSaga   = errors.New("unknown saga")
+	ErrAlreadyActive = errors.New("saga already in progress for this reference")
+	ErrNotWaiting    = errors.New("saga is not waiting for an external event")
+	ErrNotRetryable  = errors.New("saga is not in a retryable state")
+
+	// ErrInDoubt is wrapped by a step error when the step's external work may
+	// have happened even though the step failed. The saga is not compensated:
+	// it stops FAILED on that step, after the step's InDoubt hook, for a retry.
+	ErrInDoubt = errors.New("step outcome in doubt")
+)
+
+// Step is one unit of work in a saga. Run and Compensate each execute in their
+// own database transaction.
+type Step struct {
+	Name       string
+	Run        func(tx *gorm.DB, s *models.SagaInstance) error
+	Compensate func(tx *gorm.DB, s *models.SagaInstance) error // Undoes Run; nil if there is nothing to undo
+	Await      bool                                            // Run starts external work; the step finishes on Advance or Fail
+	Pivot      bool                                            // Once done the saga can only move forward; later failures are retried, not compensated
+	InDoubt    func(tx *gorm.DB, s *models.SagaInstance) error // Flags a Run that failed with ErrInDoubt; nil if there is nothing to flag
+}
+
+// Definition declares a saga's steps in order
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// Coordinator runs saga instances against registered definitions
+type Coordinator struct {
+	db    *gorm.DB
+	clock clock.Clock
+	mu    sync.RWMutex
+	defs  map[string]*Definition
+}
+
+// NewCoordinator creates a coordinator with no definitions
+func NewCoordinator(db *gorm.DB, c clock.Clock) *Coordinator {
+	return &Coordinator{db: db, clock: c, defs: map[string]*Definition{}}
+}
+
+// Register adds a saga definition
+func (c *Coordinator) Register(def *Definition) {
+	c.mu.Lock()
+	c.defs[def.Name] = def
+	c.mu.Unlock()
+}
+
+// Start creates an instance for the reference and runs it until it finishes
+// or waits. A step error is returned after any compensation has run.
+func (c *Coordinator) Start(name string, referenceID uint, data map[string]string) (*models.SagaInstance, error) {
+	def, err := c.definition(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var active int64
+	c.db.Model(&models.SagaInstance{}).
+		Where("name = ? AND reference_id = ? AND status IN ?", name, referenceID,
+			[]string{models.SagaStatusRunning, models.SagaStatusWaiting, models.SagaStatusCompensating, models.SagaStatusFailed}).
+		Count(&active)
+	if active > 0 {
+		return nil, ErrAlreadyActive
+	}
+
+	if data == nil {
+		data = map[string]string{}
+	}
+	inst := &models.SagaInstance{Name: name, ReferenceID: referenceID, Status: models.SagaStatusRunning, Data: data}
+	if err := c.db.Create(inst).Error; err != nil {
+		return nil, err
+	}
+	return inst, c.run(def, inst)
+}
+
+// Find returns the most recent instance of a saga for a reference
+func (c *Coordinator) Find(name string, referenceID uint) (*models.SagaInstance, error) {
+	var inst models.SagaInstance
+	err := c.db.Where("name = ? AND reference_id = ?", name, referenceID).Order("id DESC").First(&inst).Error
+	if err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// Advance finishes the awaited step with the given extra data and continues the saga
+func (c *Coordinator) Advance(name string, referenceID uint, data map[string]string) (*models.SagaInstance, error) {
+	def, inst, err := c.waiting(name, referenceID)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range data {
+		inst.Data[k] = v
+	}
+	if err := c.logStep(inst, def.Steps[inst.CurrentStep].Name, models.SagaStepDone, ""); err != nil {
+		return nil, err
+	}
+	inst.CurrentStep++
+	inst.Status = models.SagaStatusRunning
+	return inst, c.run(def, inst)
+}
+
+// Fail reports that the awaited step's external work failed and compensates
+// it together with every earlier step
+func (c *Coordinator) Fail(name string, referenceID uint, reason string) (*models.SagaInstance, error) {
+	def, inst, err := c.waiting(name, referenceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.logStep(inst, def.Steps[inst.CurrentStep].Name, models.SagaStepFailed, reason); err != nil {
+		return nil, err
+	}
+	inst.LastError = reason
+	if c.pastPivot(def, inst.CurrentStep) {
+		inst.Status = models.SagaStatusFailed
+		return inst, c.db.Save(inst).Error
+	}
+	return inst, c.compensate(def, inst, inst.CurrentStep)
+}
+
+// Retry resumes a saga that stopped on an error: forward from the failed step
+// after a pivot, or backward if a compensation failed
+func (c *Coordinator) Retry(id uint) (*models.SagaInstance, error) {
+	var inst models.SagaInstance
+	if err := c.db.First(&inst, id).Error; err != nil {
+		return nil, err
+	}
+	def, err := c.definition(inst.Name)
+	if err != nil {
+		return nil, err
+	}
+	if inst.Data == nil {
+		inst.Data = map[string]string{}
+	}
+
+	switch inst.Status {
+	case models.SagaStatusFailed:
+		inst.Status = models.SagaStatusRunning
+		return &inst, c.run(def, &inst)
+	case models.SagaStatusCompensating:
+		return &inst, c.compensate(def, &inst, inst.CurrentStep)
+	default:
+		return nil, ErrNotRetryable
+	}
+}
+
+// run executes steps from inst.CurrentStep until the saga finishes, waits or fails
+func (c *Coordinator) run(def *Definition, inst *models.SagaInstance) error {
+	for inst.CurrentStep < len(def.Steps) {
+		step := def.Steps[inst.CurrentStep]
+		if err := c.db.Transaction(func(tx *gorm.DB) error { return step.Run(tx, inst) }); err != nil {
+			log.Printf("Saga: %s #%d step %s failed: %v", inst.Name, inst.ID, step.Name, err)
+			if logErr := c.logStep(inst, step.Name, models.SagaStepFailed, err.Error()); logErr != nil {
+				return logErr
+			}
+			inst.LastError = err.Error()
+			if errors.Is(err, ErrInDoubt) {
+				return c.stopInDoubt(step, inst, err)
+			}
+			if c.pastPivot(def, inst.CurrentStep) {
+				inst.Status = models.SagaStatusFailed
+				if saveErr := c.db.Save(inst).Error; saveErr != nil {
+					return saveErr
+				}
+				return err
+			}
+			if compErr := c.compensate(def, inst, inst.CurrentStep-1); compErr != nil {
+				return fmt.Errorf("%w (compensation also failed: %v)", err, compErr)
+			}
+			return err
+		}
+
+		if step.Await {
+			inst.Status = models.SagaStatusWaiting
+			return c.db.Save(inst).Error
+		}
+		if err := c.logStep(inst, step.Name, models.SagaStepDone, ""); err != nil {
+			return err
+		}
+		inst.CurrentStep++
+		if err := c.db.Save(inst).Error; err != nil {
+			return err
+		}
+	}
+
+	now := c.clock.Now()
+	inst.Status = models.SagaStatusCompleted
+	inst.LastError = ""
+	inst.FinishedAt = &now
+	return c.db.Save(inst).Error
+}
+
+// compensate undoes steps from index from down to the first, in reverse order
+func (c *Coordinator) compensate(def *Definition, inst *models.SagaInstance, from int) error {
+	inst.Status = models.SagaStatusCompensating
+	for i := from; i >= 0; i-- {
+		inst.CurrentStep = i
+		if err := c.db.Save(inst).Error; err != nil {
+			return err
+		}
+		step := def.Steps[i]
+		if step.Compensate == nil {
+			continue
+		}
+		if err := c.db.Transaction(func(tx *gorm.DB) error { return step.Compensate(tx, inst) }); err != nil {
+			log.Printf("Saga: %s #%d compensation of %s failed: %v", inst.Name, inst.ID, step.Name, err)
+			inst.LastError = fmt.Sprintf("compensating %s: %v", step.Name, err)
+			if saveErr := c.db.Save(inst).Error; saveErr != nil {
+				return saveErr
+			}
+			return err
+		}
+		if err := c.logStep(inst, step.Name, models.SagaStepCompensated, ""); err != nil {
+			return err
+		}
+	}
+
+	now := c.clock.Now()
+	inst.Status = models.SagaStatusCompensated
+	inst.FinishedAt = &now
+	return c.db.Save(inst).Error
+}
+
+// stopInDoubt leaves the saga FAILED on a step whose external work may have
+// happened, so a retry finishes the step rather than compensating it
+func (c *Coordinator) stopInDoubt(step Step, inst *models.SagaInstance, err error) error {
+	if step.InDoubt != nil {
+		if flagErr := c.db.Transaction(func(tx *gorm.DB) error { return step.InDoubt(tx, inst) }); flagErr != nil {
+			log.Printf("Saga: %s #%d could not flag in-doubt step %s: %v", inst.Name, inst.ID, step.Name, flagErr)
+		}
+	}
+	inst.Status = models.SagaStatusFailed
+	if saveErr := c.db.Save(inst).Error; saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// pastPivot reports whether a pivot step before index has completed
+func (c *Coordinator) pastPivot(def *Definition, index int) bool {
+	for i := 0; i < index; i++ {
+		if def.Steps[i].Pivot {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Coordinator) waiting(name string, referenceID uint) (*Definition, *models.SagaInstance, error) {
+	def, err := c.definition(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	inst, err := c.Find(name, referenceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inst.Status != models.SagaStatusWaiting {
+		return nil, nil, ErrNotWaiting
+	}
+	if inst.Data == nil {
+		inst.Data = map[string]string{}
+	}
+	return def, inst, nil
+}
+
+func (c *Coordinator) definition(name string) (*Definition, error) {
+	c.mu.RLock()
+	def, ok := c.defs[name]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownSaga
+	}
+	return def, nil
+}
+
+func (c *Coordinator) logStep(inst *models.SagaInstance, name, status, errMsg string) error {
+	return c.db.Create(&models.SagaStepLog{SagaID: inst.ID, Step: inst.CurrentStep, Name: name, Status: status, Error: errMsg}).Error
+}
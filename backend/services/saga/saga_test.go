@@ -0,0 +1,246 @@
+package saga
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+// recorder builds steps that append their name to a shared trail
+type recorder struct {
+	trail []string
+	fail  map[string]error
+}
+
+func (rec *recorder) run(name string) func(*gorm.DB, *models.SagaInstance) error {
+	return func(_ *gorm.DB, s *models.SagaInstance) error {
+		if err := rec.fail[name]; err != nil {
+			return err
+		}
+		rec.trail = append(rec.trail, name)
+		s.Data[name] = "done"
+		return nil
+	}
+}
+
+func (rec *recorder) undo(name string) func(*gorm.DB, *models.SagaInstance) error {
+	return func(*gorm.DB, *models.SagaInstance) error {
+		if err := rec.fail["undo "+name]; err != nil {
+			return err
+		}
+		rec.trail = append(rec.trail, "undo "+name)
+		return nil
+	}
+}
+
+func newFlow(t *testing.T, rec *recorder, steps ...Step) *Coordinator {
+	t.Helper()
+	coord := NewCoordinator(modelstesting.NewFakeDB(t), clock.NewFake(time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)))
+	coord.Register(&Definition{Name: "test", Steps: steps})
+	return coord
+}
+
+func statuses(t *testing.T, coord *Coordinator, id uint) string {
+	t.Helper()
+	var logs []models.SagaStepLog
+	coord.db.Where("saga_id = ?", id).Order("id").Find(&logs)
+	var parts []string
+	for _, l := range logs {
+		parts = append(parts, l.Name+":"+l.Status)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestAwaitAndAdvanceCompletes(t *testing.T) {
+	rec := &recorder{}
+	coord := newFlow(t, rec,
+		Step{Name: "reserve", Run: rec.run("reserve"), Compensate: rec.undo("reserve")},
+		Step{Name: "transfer", Run: rec.run("transfer"), Compensate: rec.undo("transfer"), Await: true, Pivot: true},
+		Step{Name: "notify", Run: rec.run("notify")},
+	)
+
+	inst, err := coord.Start("test", 7, map[string]string{"actor": "admin"})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if inst.Status != models.SagaStatusWaiting || inst.CurrentStep != 1 {
+		t.Fatalf("after start: status %s step %d, want WAITING at 1", inst.Status, inst.CurrentStep)
+	}
+	if _, err := coord.Start("test", 7, nil); !errors.Is(err, ErrAlreadyActive) {
+		t.Fatalf("second Start: got %v, want ErrAlreadyActive", err)
+	}
+
+	inst, err = coord.Advance("test", 7, map[string]string{"txHash": "0xabc"})
+	if err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if inst.Status != models.SagaStatusCompleted || inst.FinishedAt == nil {
+		t.Fatalf("status = %s, want COMPLETED", inst.Status)
+	}
+
+	stored, _ := coord.Find("test", 7)
+	if stored.Data["actor"] != "admin" || stored.Data["txHash"] != "0xabc" || stored.Data["notify"] != "done" {
+		t.Errorf("data not persisted: %v", stored.Data)
+	}
+	if got := strings.Join(rec.trail, ","); got != "reserve,transfer,notify" {
+		t.Errorf("trail = %s", got)
+	}
+	if got := statuses(t, coord, inst.ID); got != "reserve:DONE,transfer:DONE,notify:DONE" {
+		t.Errorf("step log = %s", got)
+	}
+	if _, err := coord.Advance("test", 7, nil); !errors.Is(err, ErrNotWaiting) {
+		t.Errorf("Advance after completion: got %v, want ErrNotWaiting", err)
+	}
+}
+
+func TestStepFailureCompensatesInReverse(t *testing.T) {
+	boom := errors.New("boom")
+	rec := &recorder{fail: map[string]error{"charge": boom}}
+	coord := newFlow(t, rec,
+		Step{Name: "reserve", Run: rec.run("reserve"), Compensate: rec.undo("reserve")},
+		Step{Name: "lock", Run: rec.run("lock")},
+		Step{Name: "hold", Run: rec.run("hold"), Compensate: rec.undo("hold")},
+		Step{Name: "charge", Run: rec.run("charge"), Compensate: rec.undo("charge")},
+	)
+
+	inst, err := coord.Start("test", 1, nil)
+	if !errors.Is(err, boom) {
+		t.Fatalf("Start: got %v, want step error", err)
+	}
+	if inst.Status != models.SagaStatusCompensated || inst.LastError != "boom" {
+		t.Fatalf("status %s lastError %q, want COMPENSATED boom", inst.Status, inst.LastError)
+	}
+	if got := strings.Join(rec.trail, ","); got != "reserve,lock,hold,undo hold,undo reserve" {
+		t.Errorf("trail = %s", got)
+	}
+	if got := statuses(t, coord, inst.ID); got != "reserve:DONE,lock:DONE,hold:DONE,charge:FAILED,hold:COMPENSATED,reserve:COMPENSATED" {
+		t.Errorf("step log = %s", got)
+	}
+
+	// A compensated saga does not block a fresh attempt
+	rec.fail = nil
+	if _, err := coord.Start("test", 1, nil); err != nil {
+		t.Errorf("restart after compensation: %v", err)
+	}
+}
+
+func TestFailOnAwaitedStepCompensatesIt(t *testing.T) {
+	rec := &recorder{}
+	coord := newFlow(t, rec,
+		Step{Name: "transfer", Run: rec.run("transfer"), Compensate: rec.undo("transfer"), Await: true, Pivot: true},
+		Step{Name: "complete", Run: rec.run("complete")},
+	)
+
+	if _, err := coord.Start("test", 3, nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	inst, err := coord.Fail("test", 3, "transfer rejected")
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	if inst.Status != models.SagaStatusCompensated || inst.LastError != "transfer rejected" {
+		t.Fatalf("status %s lastError %q", inst.Status, inst.LastError)
+	}
+	if got := strings.Join(rec.trail, ","); got != "transfer,undo transfer" {
+		t.Errorf("trail = %s", got)
+	}
+}
+
+func TestFailureAfterPivotIsRetried(t *testing.T) {
+	rec := &recorder{fail: map[string]error{"complete": errors.New("db down")}}
+	coord := newFlow(t, rec,
+		Step{Name: "transfer", Run: rec.run("transfer"), Compensate: rec.undo("transfer"), Await: true, Pivot: true},
+		Step{Name: "complete", Run: rec.run("complete")},
+	)
+
+	if _, err := coord.Start("test", 4, nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	inst, err := coord.Advance("test", 4, nil)
+	if err == nil || inst.Status != models.SagaStatusFailed {
+		t.Fatalf("Advance: status %s err %v, want FAILED", inst.Status, err)
+	}
+	for _, step := range rec.trail {
+		if strings.HasPrefix(step, "undo") {
+			t.Fatalf("pivot step was compensated: %v", rec.trail)
+		}
+	}
+	if _, err := coord.Start("test", 4, nil); !errors.Is(err, ErrAlreadyActive) {
+		t.Errorf("Start over failed saga: got %v, want ErrAlreadyActive", err)
+	}
+
+	rec.fail = nil
+	inst, err = coord.Retry(inst.ID)
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if inst.Status != models.SagaStatusCompleted {
+		t.Errorf("status after retry = %s", inst.Status)
+	}
+	if _, err := coord.Retry(inst.ID); !errors.Is(err, ErrNotRetryable) {
+		t.Errorf("Retry completed saga: got %v, want ErrNotRetryable", err)
+	}
+}
+
+func TestFailedCompensationResumesOnRetry(t *testing.T) {
+	rec := &recorder{fail: map[string]error{"charge": errors.New("boom"), "undo reserve": errors.New("ledger locked")}}
+	coord := newFlow(t, rec,
+		Step{Name: "reserve", Run: rec.run("reserve"), Compensate: rec.undo("reserve")},
+		Step{Name: "charge", Run: rec.run("charge")},
+	)
+
+	inst, _ := coord.Start("test", 5, nil)
+	if inst.Status != models.SagaStatusCompensating {
+		t.Fatalf("status = %s, want COMPENSATING", inst.Status)
+	}
+
+	rec.fail = nil
+	inst, err := coord.Retry(inst.ID)
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if inst.Status != models.SagaStatusCompensated {
+		t.Errorf("status after retry = %s, want COMPENSATED", inst.Status)
+	}
+	if got := strings.Join(rec.trail, ","); got != "reserve,undo reserve" {
+		t.Errorf("trail = %s", got)
+	}
+}
+
+func TestInDoubtStepIsFlaggedNotCompensated(t *testing.T) {
+	rec := &recorder{fail: map[string]error{"transfer": fmt.Errorf("%w: record failed", ErrInDoubt)}}
+	coord := newFlow(t, rec,
+		Step{Name: "reserve", Run: rec.run("reserve"), Compensate: rec.undo("reserve")},
+		Step{Name: "transfer", Run: rec.run("transfer"), Compensate: rec.undo("transfer"), Await: true, Pivot: true,
+			InDoubt: func(_ *gorm.DB, s *models.SagaInstance) error {
+				rec.trail = append(rec.trail, "flag transfer")
+				return nil
+			}},
+	)
+
+	inst, err := coord.Start("test", 6, nil)
+	if !errors.Is(err, ErrInDoubt) {
+		t.Fatalf("Start: got %v, want ErrInDoubt", err)
+	}
+	if inst.Status != models.SagaStatusFailed || inst.CurrentStep != 1 {
+		t.Fatalf("status %s step %d, want FAILED at 1", inst.Status, inst.CurrentStep)
+	}
+	if got := strings.Join(rec.trail, ","); got != "reserve,flag transfer" {
+		t.Errorf("trail = %s", got)
+	}
+
+	// A retry finishes the step instead of undoing it
+	rec.fail = nil
+	inst, err = coord.Retry(inst.ID)
+	if err != nil || inst.Status != models.SagaStatusWaiting {
+		t.Fatalf("Retry: status %s err %v, want WAITING", inst.Status, err)
+	}
+}
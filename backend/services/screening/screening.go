@@ -0,0 +1,149 @@
+// Package screening checks blockchain addresses against sanctions lists.
+package screening
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of screening an address
+type Result struct {
+	Flagged  bool
+	Provider string
+	Reason   string
+}
+
+// Screener checks whether an address is sanctioned
+type Screener interface {
+	Screen(chainName, address string) (Result, error)
+}
+
+// KnownSanctioned lists publicly sanctioned addresses (lowercased), used by
+// the static screener and for flagging historical activity
+var KnownSanctioned = map[string]string{
+	"0xd90e2f925da726b50c4ed8d0fb90ad053324f31b": "Tornado Cash Router",
+	"0x12d66f87a04a9e220743712ce6d9bb1b5616b8fc": "Tornado Cash 0.1 ETH",
+	"0x47ce0c6ed5b0ce3d3a51fdb1c52dc66a7c3c2936": "Tornado Cash 1 ETH",
+	"0x910cbd523d972eb0a6f4cae4618ad62622b39dbf": "Tornado Cash 10 ETH",
+}
+
+// StaticList screens addresses against a fixed list
+type StaticList struct {
+	addresses map[string]string
+}
+
+// NewStaticList creates a screener from a map of address to description.
+// Addresses are matched case-insensitively.
+func NewStaticList(addresses map[string]string) *StaticList {
+	normalized := make(map[string]string, len(addresses))
+	for addr, name := range addresses {
+		normalized[strings.ToLower(addr)] = name
+	}
+	return &StaticList{addresses: normalized}
+}
+
+// Screen implements Screener
+func (s *StaticList) Screen(chainName, address string) (Result, error) {
+	if name, ok := s.addresses[strings.ToLower(address)]; ok {
+		return Result{Flagged: true, Provider: "static", Reason: "Sanctioned address: " + name}, nil
+	}
+	return Result{Provider: "static"}, nil
+}
+
+// HTTPScreener queries a Chainalysis/TRM-style sanctions API:
+// GET {baseURL}/{address} with an X-API-Key header, answering
+// {"identifications":[{"category":"sanctions","name":"..."}]}
+type HTTPScreener struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewHTTPScreener creates an HTTP screener
+func NewHTTPScreener(baseURL, apiKey string) *HTTPScreener {
+	return &HTTPScreener{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type identificationsResponse struct {
+	Identifications []struct {
+		Category string `json:"category"`
+		Name     string `json:"name"`
+	} `json:"identifications"`
+}
+
+// Screen implements Screener
+func (s *HTTPScreener) Screen(chainName, address string) (Result, error) {
+	req, err := http.NewRequest(http.MethodGet, s.baseURL+"/"+url.PathEscape(address), nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create screening request: %w", err)
+	}
+	req.Header.Set("X-API-Key", s.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("screening request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("screening API returned status %d", resp.StatusCode)
+	}
+
+	var body identificationsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Result{}, fmt.Errorf("failed to parse screening response: %w", err)
+	}
+
+	result := Result{Provider: "api"}
+	if len(body.Identifications) > 0 {
+		id := body.Identifications[0]
+		result.Flagged = true
+		result.Reason = fmt.Sprintf("Sanctions match (%s): %s", id.Category, id.Name)
+	}
+	return result, nil
+}
+
+// Fallback screens with the primary screener and falls back to the secondary
+// when the primary errors
+type Fallback struct {
+	primary   Screener
+	secondary Screener
+}
+
+// NewFallback creates a fallback screener
+func NewFallback(primary, secondary Screener) *Fallback {
+	return &Fallback{primary: primary, secondary: secondary}
+}
+
+// Screen implements Screener
+func (f *Fallback) Screen(chainName, address string) (Result, error) {
+	result, err := f.primary.Screen(chainName, address)
+	if err == nil {
+		return result, nil
+	}
+	log.Printf("Screening: primary screener failed, using fallback: %v", err)
+	return f.secondary.Screen(chainName, address)
+}
+
+// NewFromEnv builds the configured screener. SCREENING_API_URL and
+// SCREENING_API_KEY enable the HTTP screener with the static list as fallback;
+// otherwise only the static list is used.
+func NewFromEnv() Screener {
+	static := NewStaticList(KnownSanctioned)
+	apiURL := os.Getenv("SCREENING_API_URL")
+	if apiURL == "" {
+		return static
+	}
+	return NewFallback(NewHTTPScreener(apiURL, os.Getenv("SCREENING_API_KEY")), static)
+}
@@ -0,0 +1,61 @@
+package screening
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type failingScreener struct{}
+
+func (failingScreener) Screen(chainName, address string) (Result, error) {
+	return Result{}, errors.New("provider down")
+}
+
+func TestStaticListMatchesCaseInsensitively(t *testing.T) {
+	s := NewStaticList(KnownSanctioned)
+
+	result, err := s.Screen("ethereum", "0xD90E2F925DA726B50C4ED8D0FB90AD053324F31B")
+	if err != nil || !result.Flagged {
+		t.Fatalf("expected sanctioned address to be flagged, got %+v, %v", result, err)
+	}
+	if result, _ := s.Screen("ethereum", "0x1111111111111111111111111111111111111111"); result.Flagged {
+		t.Fatal("clean address should not be flagged")
+	}
+}
+
+func TestHTTPScreener(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/0xbad" {
+			w.Write([]byte(`{"identifications":[{"category":"sanctions","name":"SANCTIONS: OFAC SDN"}]}`))
+			return
+		}
+		w.Write([]byte(`{"identifications":[]}`))
+	}))
+	defer server.Close()
+
+	s := NewHTTPScreener(server.URL+"/", "secret")
+	if result, err := s.Screen("base", "0xbad"); err != nil || !result.Flagged || result.Provider != "api" {
+		t.Fatalf("expected flagged api result, got %+v, %v", result, err)
+	}
+	if result, err := s.Screen("base", "0xgood"); err != nil || result.Flagged {
+		t.Fatalf("expected clean result, got %+v, %v", result, err)
+	}
+	if _, err := NewHTTPScreener(server.URL, "wrong").Screen("base", "0xgood"); err == nil {
+		t.Fatal("expected error for rejected API key")
+	}
+}
+
+func TestFallbackUsesSecondaryOnError(t *testing.T) {
+	s := NewFallback(failingScreener{}, NewStaticList(map[string]string{"0xABC": "test entry"}))
+
+	result, err := s.Screen("base", "0xabc")
+	if err != nil || !result.Flagged || result.Provider != "static" {
+		t.Fatalf("expected static fallback to flag address, got %+v, %v", result, err)
+	}
+}
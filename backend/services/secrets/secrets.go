@@ -0,0 +1,193 @@
+// Package secrets reads secrets such as signing keys from a secrets manager,
+// so they are held in memory only and never written to disk.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ErrNotFound is returned when a secret or its field does not exist
+var ErrNotFound = errors.New("secret not found")
+
+// Store reads a secret by name
+type Store interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Vault reads secrets from a HashiCorp Vault KV version 2 engine. A name is
+// "path#field"; without a field the "value" field is read.
+type Vault struct {
+	addr       string
+	token      string
+	mount      string
+	httpClient *http.Client
+}
+
+// NewVault creates a Vault store for the KV engine mounted at mount
+func NewVault(addr, token, mount string) *Vault {
+	if mount == "" {
+		mount = "secret"
+	}
+	return &Vault{
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		mount:      strings.Trim(mount, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Get implements Store
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	path, field, _ := strings.Cut(name, "#")
+	if field == "" {
+		field = "value"
+	}
+	endpoint := v.addr + "/v1/" + v.mount + "/data/" + strings.Trim(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d for %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	value, ok := body.Data.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s#%s", ErrNotFound, path, field)
+	}
+	return value, nil
+}
+
+// AWSSecretsManager reads the string value of secrets from AWS Secrets
+// Manager. A name is the secret's name or ARN.
+type AWSSecretsManager struct {
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	httpClient  *http.Client
+}
+
+// NewAWSSecretsManager creates a store that signs requests to the regional
+// Secrets Manager endpoint, or endpoint if set, with the given credentials
+func NewAWSSecretsManager(endpoint, region string, credentials aws.CredentialsProvider) *AWSSecretsManager {
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	return &AWSSecretsManager{
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		region:      region,
+		credentials: credentials,
+		signer:      v4.NewSigner(),
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Get implements Store
+func (a *AWSSecretsManager) Get(ctx context.Context, name string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": name})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create secrets manager request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	creds, err := a.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(payload)
+	if err := a.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "secretsmanager", a.region, time.Now()); err != nil {
+		return "", fmt.Errorf("failed to sign secrets manager request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse secrets manager response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if strings.HasSuffix(body.Type, "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", fmt.Errorf("secrets manager returned status %d: %s %s", resp.StatusCode, body.Type, body.Message)
+	}
+	if body.SecretString == "" {
+		return "", fmt.Errorf("%w: %s has no string value", ErrNotFound, name)
+	}
+	return body.SecretString, nil
+}
+
+// NewFromEnv builds the store selected by SECRETS_BACKEND, or nil when it is
+// unset. "vault" reads VAULT_ADDR, VAULT_TOKEN and VAULT_KV_MOUNT (default
+// "secret"); "aws" uses the default AWS credential chain and region, with
+// AWS_SECRETS_MANAGER_ENDPOINT overriding the endpoint.
+func NewFromEnv(ctx context.Context) (Store, error) {
+	switch backend := os.Getenv("SECRETS_BACKEND"); backend {
+	case "":
+		return nil, nil
+	case "vault":
+		addr := os.Getenv("VAULT_ADDR")
+		if _, err := url.ParseRequestURI(addr); err != nil {
+			return nil, fmt.Errorf("invalid VAULT_ADDR %q", addr)
+		}
+		return NewVault(addr, os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_KV_MOUNT")), nil
+	case "aws":
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		if cfg.Region == "" {
+			return nil, errors.New("AWS region not configured")
+		}
+		return NewAWSSecretsManager(os.Getenv("AWS_SECRETS_MANAGER_ENDPOINT"), cfg.Region, cfg.Credentials), nil
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestVaultReadsKVField(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vt" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/kv/data/dfns/primary" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"private_key":"PEM"}}}`))
+	}))
+	defer srv.Close()
+
+	store := NewVault(srv.URL, "vt", "kv")
+	if got, err := store.Get(context.Background(), "dfns/primary#private_key"); err != nil || got != "PEM" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := store.Get(context.Background(), "dfns/primary#other"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing field err = %v", err)
+	}
+	if _, err := store.Get(context.Background(), "dfns/backup"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing path err = %v", err)
+	}
+}
+
+func TestAWSSecretsManagerSignsGetSecretValue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, `{"__type":"AccessDeniedException"}`, http.StatusBadRequest)
+			return
+		}
+		var req struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.SecretId != "dfns/primary" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.secretsmanager#ResourceNotFoundException","message":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"SecretString":"PEM"}`))
+	}))
+	defer srv.Close()
+
+	store := NewAWSSecretsManager(srv.URL, "eu-west-1", credentials.NewStaticCredentialsProvider("AKID", "secret", ""))
+	if got, err := store.Get(context.Background(), "dfns/primary"); err != nil || got != "PEM" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := store.Get(context.Background(), "dfns/backup"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing secret err = %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+// Package selfexclusion holds the responsible gambling controls users set on
+// themselves. A cooling-off period or self-exclusion keeps them from betting
+// and holds their deposits until it ends, and cannot be cut short. A daily
+// deposit limit caps what they can deposit in any 24 hours: lowering it
+// applies at once, raising or removing it only after a day, so the decision
+// is not made in the heat of the moment.
+package selfexclusion
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Periods users may choose, in days
+const (
+	MinCoolingOffDays    = 1
+	MaxCoolingOffDays    = 42
+	MinSelfExclusionDays = 180
+	MaxSelfExclusionDays = 5 * 365
+)
+
+// IncreaseDelay is how long a raised or removed deposit limit waits before it
+// applies
+const IncreaseDelay = 24 * time.Hour
+
+// Audit actions
+const (
+	ActionCoolingOff      = "USER_COOLING_OFF"
+	ActionSelfExcluded    = "USER_SELF_EXCLUDED"
+	ActionDepositLimitSet = "USER_DEPOSIT_LIMIT_SET"
+)
+
+var (
+	ErrInvalidPeriod = errors.New("invalid exclusion period")
+	ErrInvalidLimit  = errors.New("a deposit limit cannot be negative")
+)
+
+// ExcludedError is returned when the user is cooling off or self-excluded
+type ExcludedError struct {
+	Exclusion models.SelfExclusion
+}
+
+func (e *ExcludedError) Error() string {
+	what := "You are self-excluded"
+	if e.Exclusion.Kind == models.ExclusionCoolingOff {
+		what = "You are taking a break"
+	}
+	return what + " until " + e.Exclusion.EndsAt.UTC().Format(time.RFC3339)
+}
+
+// Status is the user's responsible gambling settings at a point in time
+type Status struct {
+	Exclusion           *models.SelfExclusion `json:"exclusion"`             // The active exclusion ending last, if any
+	DailyDepositLimit   int64                 `json:"dailyDepositLimit"`     // Micro-credits; 0 for none
+	PendingDepositLimit *int64                `json:"pendingDepositLimit"`   // Micro-credits; applies from PendingFrom
+	PendingFrom         *time.Time            `json:"pendingFrom,omitempty"` // When the pending limit applies
+	DepositedToday      int64                 `json:"depositedToday"`        // Micro-credits in the last 24 hours
+	DepositRemaining    *int64                `json:"depositRemaining"`      // Micro-credits; nil without a limit
+}
+
+// ExcludedAccount is one excluded account in the admin report
+type ExcludedAccount struct {
+	UserID   int64     `json:"userId"`
+	Username string    `json:"username"`
+	Kind     string    `json:"kind"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+}
+
+// Report summarises the accounts excluded at a point in time
+type Report struct {
+	CoolingOff    int               `json:"coolingOff"`
+	SelfExcluded  int               `json:"selfExcluded"`
+	DepositLimits int               `json:"depositLimits"` // Accounts with a daily deposit limit in force
+	Accounts      []ExcludedAccount `json:"accounts"`
+}
+
+// Service applies users' responsible gambling settings
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService creates a self-exclusion service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+// Status returns user's exclusion and deposit limit
+func (s *Service) Status(userID int64) (*Status, error) {
+	now := s.clock.Now()
+	exclusion, err := activeExclusion(s.db, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := loadLimit(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	deposited, err := depositedSince(s.db, userID, now.Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Exclusion: exclusion, DailyDepositLimit: limit.EffectiveAt(now), DepositedToday: deposited}
+	if limit.PendingFrom != nil && now.Before(*limit.PendingFrom) {
+		status.PendingDepositLimit, status.PendingFrom = limit.PendingLimit, limit.PendingFrom
+	}
+	if status.DailyDepositLimit > 0 {
+		remaining := max(status.DailyDepositLimit-deposited, 0)
+		status.DepositRemaining = &remaining
+	}
+	return status, nil
+}
+
+// Exclude keeps user from betting and depositing for days. kind is
+// models.ExclusionCoolingOff or models.ExclusionSelfExclusion, each with its
+// own range of days. A new exclusion can only add to an existing one.
+func (s *Service) Exclude(user *models.User, kind string, days int) (*models.SelfExclusion, error) {
+	action := ActionCoolingOff
+	minDays, maxDays := MinCoolingOffDays, MaxCoolingOffDays
+	if kind == models.ExclusionSelfExclusion {
+		action = ActionSelfExcluded
+		minDays, maxDays = MinSelfExclusionDays, MaxSelfExclusionDays
+	} else if kind != models.ExclusionCoolingOff {
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidPeriod, kind)
+	}
+	if days < minDays || days > maxDays {
+		return nil, fmt.Errorf("%w: choose between %d and %d days", ErrInvalidPeriod, minDays, maxDays)
+	}
+
+	now := s.clock.Now()
+	exclusion := models.SelfExclusion{UserID: user.ID, Kind: kind, StartsAt: now, EndsAt: now.AddDate(0, 0, days)}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&exclusion).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      user.Username,
+			Action:     action,
+			TargetType: "user",
+			TargetID:   uint(user.ID),
+			Details:    fmt.Sprintf("days=%d until=%s", days, exclusion.EndsAt.UTC().Format(time.RFC3339)),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &exclusion, nil
+}
+
+// SetDepositLimit sets user's daily deposit limit to limit micro-credits, or
+// removes it when 0. A lower limit applies at once; a higher one, or
+// removing it, after IncreaseDelay.
+func (s *Service) SetDepositLimit(user *models.User, limit int64) (*Status, error) {
+	if limit < 0 {
+		return nil, ErrInvalidLimit
+	}
+
+	now := s.clock.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		current, err := loadLimit(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		effective := current.EffectiveAt(now)
+
+		row := models.DepositLimit{UserID: user.ID, DailyLimit: effective}
+		details := fmt.Sprintf("limit=%s applied", models.FormatMicroCredits(limit))
+		if limit != 0 && (effective == 0 || limit < effective) || limit == effective {
+			row.DailyLimit = limit
+		} else {
+			from := now.Add(IncreaseDelay)
+			row.PendingLimit, row.PendingFrom = &limit, &from
+			details = fmt.Sprintf("limit=%s from=%s", models.FormatMicroCredits(limit), from.UTC().Format(time.RFC3339))
+		}
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"daily_limit", "pending_limit", "pending_from", "updated_at"}),
+		}).Create(&row).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      user.Username,
+			Action:     ActionDepositLimitSet,
+			TargetType: "user",
+			TargetID:   uint(user.ID),
+			Details:    details,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Status(user.ID)
+}
+
+// Report returns the accounts cooling off or self-excluded at the time,
+// ending soonest first
+func (s *Service) Report() (*Report, error) {
+	now := s.clock.Now()
+	var exclusions []models.SelfExclusion
+	if err := s.db.Where("starts_at <= ? AND ends_at > ?", now, now).Order("ends_at DESC").Find(&exclusions).Error; err != nil {
+		return nil, err
+	}
+
+	// Overlapping exclusions report once, as the one ending last
+	latest := map[int64]models.SelfExclusion{}
+	userIDs := []int64{}
+	for _, e := range exclusions {
+		if _, seen := latest[e.UserID]; !seen {
+			latest[e.UserID] = e
+			userIDs = append(userIDs, e.UserID)
+		}
+	}
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := s.db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	usernames := make(map[int64]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	report := &Report{Accounts: []ExcludedAccount{}}
+	for i := len(userIDs) - 1; i >= 0; i-- {
+		e := latest[userIDs[i]]
+		report.Accounts = append(report.Accounts, ExcludedAccount{
+			UserID: e.UserID, Username: usernames[e.UserID], Kind: e.Kind, StartsAt: e.StartsAt, EndsAt: e.EndsAt,
+		})
+		if e.Kind == models.ExclusionSelfExclusion {
+			report.SelfExcluded++
+		} else {
+			report.CoolingOff++
+		}
+	}
+
+	var limits []models.DepositLimit
+	if err := s.db.Find(&limits).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range limits {
+		if l.EffectiveAt(now) > 0 {
+			report.DepositLimits++
+		}
+	}
+	return report, nil
+}
+
+// Check returns an *ExcludedError if the user is cooling off or
+// self-excluded at now
+func Check(db *gorm.DB, userID int64, now time.Time) error {
+	exclusion, err := activeExclusion(db, userID, now)
+	if err != nil {
+		return fmt.Errorf("failed to load self-exclusions: %w", err)
+	}
+	if exclusion == nil {
+		return nil
+	}
+	return &ExcludedError{Exclusion: *exclusion}
+}
+
+// DepositOverLimit reports whether depositing amount micro-credits at now
+// would take the user past their daily deposit limit. Deposits held for
+// review do not count towards it.
+func DepositOverLimit(db *gorm.DB, userID, amount int64, now time.Time) (bool, int64, error) {
+	limit, err := loadLimit(db, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to load deposit limit: %w", err)
+	}
+	daily := limit.EffectiveAt(now)
+	if daily == 0 {
+		return false, 0, nil
+	}
+	deposited, err := depositedSince(db, userID, now.Add(-24*time.Hour))
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to total recent deposits: %w", err)
+	}
+	return deposited+amount > daily, daily, nil
+}
+
+func activeExclusion(db *gorm.DB, userID int64, now time.Time) (*models.SelfExclusion, error) {
+	var exclusion models.SelfExclusion
+	err := db.Where("user_id = ? AND starts_at <= ? AND ends_at > ?", userID, now, now).
+		Order("ends_at DESC").Limit(1).Find(&exclusion).Error
+	if err != nil || exclusion.ID == 0 {
+		return nil, err
+	}
+	return &exclusion, nil
+}
+
+func loadLimit(db *gorm.DB, userID int64) (models.DepositLimit, error) {
+	limit := models.DepositLimit{UserID: userID}
+	err := db.Where("user_id = ?", userID).Limit(1).Find(&limit).Error
+	return limit, err
+}
+
+func depositedSince(db *gorm.DB, userID int64, since time.Time) (int64, error) {
+	var total int64
+	err := db.Model(&models.CryptoTransaction{}).
+		Where("user_id = ? AND type = ? AND status IN ? AND created_at > ?", userID, models.TxTypeDeposit,
+			[]string{models.TxStatusCompleted, models.TxStatusPending}, since).
+		Select("COALESCE(SUM(amount_credits), 0)").Scan(&total).Error
+	return total, err
+}
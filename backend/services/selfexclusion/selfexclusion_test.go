@@ -0,0 +1,96 @@
+package selfexclusion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestExclusionBlocksUntilItEnds(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	user := modelstesting.GenerateUser("gambler", 0)
+	db.Create(&user)
+
+	if _, err := svc.Exclude(&user, models.ExclusionCoolingOff, 0); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("0 days = %v, want ErrInvalidPeriod", err)
+	}
+	if _, err := svc.Exclude(&user, models.ExclusionSelfExclusion, 30); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("30-day self-exclusion = %v, want ErrInvalidPeriod", err)
+	}
+
+	if _, err := svc.Exclude(&user, models.ExclusionCoolingOff, 7); err != nil {
+		t.Fatalf("Exclude: %v", err)
+	}
+	var excludedErr *ExcludedError
+	if err := Check(db, user.ID, fake.Now()); !errors.As(err, &excludedErr) || excludedErr.Exclusion.Kind != models.ExclusionCoolingOff {
+		t.Fatalf("Check = %v, want cooling off", err)
+	}
+
+	report, err := svc.Report()
+	if err != nil || report.CoolingOff != 1 || len(report.Accounts) != 1 || report.Accounts[0].Username != "gambler" {
+		t.Fatalf("Report = %+v, %v; want gambler cooling off", report, err)
+	}
+
+	fake.Advance(7 * 24 * time.Hour)
+	if err := Check(db, user.ID, fake.Now()); err != nil {
+		t.Errorf("Check after the break = %v, want none", err)
+	}
+	if report, _ := svc.Report(); len(report.Accounts) != 0 {
+		t.Errorf("Report after the break = %+v, want no accounts", report.Accounts)
+	}
+}
+
+func TestDepositLimitIncreasesWaitADay(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, fake)
+	user := modelstesting.GenerateUser("gambler", 0)
+	db.Create(&user)
+
+	if _, err := svc.SetDepositLimit(&user, -1); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("negative limit = %v, want ErrInvalidLimit", err)
+	}
+
+	// A first limit is a decrease from none and applies at once
+	status, err := svc.SetDepositLimit(&user, models.CreditsToMicro(100))
+	if err != nil || status.DailyDepositLimit != models.CreditsToMicro(100) || status.PendingDepositLimit != nil {
+		t.Fatalf("first limit = %+v, %v; want 100 at once", status, err)
+	}
+
+	deposit := models.CryptoTransaction{UserID: user.ID, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+		AmountCredits: models.CreditsToMicro(60), TxHash: "0x1"}
+	deposit.CreatedAt = fake.Now()
+	db.Create(&deposit)
+	if over, _, _ := DepositOverLimit(db, user.ID, models.CreditsToMicro(40), fake.Now()); over {
+		t.Error("40 more is within the limit")
+	}
+	if over, _, _ := DepositOverLimit(db, user.ID, models.CreditsToMicro(41), fake.Now()); !over {
+		t.Error("41 more is over the limit")
+	}
+
+	// Raising it waits a day
+	status, err = svc.SetDepositLimit(&user, models.CreditsToMicro(500))
+	if err != nil || status.DailyDepositLimit != models.CreditsToMicro(100) || status.PendingDepositLimit == nil ||
+		*status.PendingDepositLimit != models.CreditsToMicro(500) || *status.DepositRemaining != models.CreditsToMicro(40) {
+		t.Fatalf("raised limit = %+v, %v; want 100 with 500 pending and 40 left", status, err)
+	}
+	fake.Advance(IncreaseDelay)
+	if over, limit, _ := DepositOverLimit(db, user.ID, models.CreditsToMicro(41), fake.Now()); over || limit != models.CreditsToMicro(500) {
+		t.Errorf("after a day: over %v at limit %d, want 500 in force", over, limit)
+	}
+
+	// Removing it waits too, and lowering it again cancels that
+	if status, _ := svc.SetDepositLimit(&user, 0); status.DailyDepositLimit != models.CreditsToMicro(500) {
+		t.Errorf("removed limit in force = %d, want 500 until the delay passes", status.DailyDepositLimit)
+	}
+	status, err = svc.SetDepositLimit(&user, models.CreditsToMicro(50))
+	if err != nil || status.DailyDepositLimit != models.CreditsToMicro(50) || status.PendingDepositLimit != nil {
+		t.Fatalf("lowered limit = %+v, %v; want 50 at once with nothing pending", status, err)
+	}
+}
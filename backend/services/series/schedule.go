@@ -0,0 +1,133 @@
+package series
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned for a schedule that is not a valid cron expression
+var ErrInvalidSchedule = errors.New(`schedule must be a cron expression "minute hour day-of-month month day-of-week", or @hourly, @daily, @weekly or @monthly`)
+
+// maxSearch bounds how far ahead Next looks for a matching time
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var shorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Schedule is a parsed five-field cron expression, evaluated in UTC. Fields
+// accept *, numbers, ranges (1-5), steps (*/15, 1-31/2) and lists (1,15).
+// As in cron, when both day fields are restricted a day matching either runs.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // Bit sets of allowed values
+	domAny, dowAny                bool
+}
+
+// ParseSchedule parses a cron expression
+func ParseSchedule(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if full, ok := shorthands[expr]; ok {
+		expr = full
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, ErrInvalidSchedule
+	}
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is also Sunday
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return &s, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%w: bad step in %q", ErrInvalidSchedule, part)
+			}
+			rangePart, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("%w: bad value %q", ErrInvalidSchedule, part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("%w: bad value %q", ErrInvalidSchedule, part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%w: %q out of range %d-%d", ErrInvalidSchedule, part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t that the schedule matches, or the
+// zero time if there is none within five years
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,349 @@
+// Package series runs recurring markets. A series is a template and a cron
+// schedule; its generator keeps one instance of it open, creating the next
+// as soon as the previous one closes. Instances take the series' wording
+// and settings, carry over the previous instance's oracle, and are linked
+// to the series in order. Users can subscribe to hear about each new one.
+package series
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/notify"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	dateLayout        = "2006-01-02"
+	datePlaceholder   = "{date}"
+	maxNameLength     = 100
+	maxTitleLength    = 160
+	maxDescriptionLen = 2000
+)
+
+var (
+	ErrSeriesNotFound     = errors.New("series not found")
+	ErrInvalidName        = fmt.Errorf("name must be 1 to %d characters", maxNameLength)
+	ErrInvalidTemplate    = fmt.Errorf("question template must be 1 to %d characters once {date} is filled in", maxTitleLength)
+	ErrInvalidDescription = fmt.Errorf("description must be at most %d characters", maxDescriptionLen)
+	ErrInvalidProbability = errors.New("initial probability must be between 0 and 1")
+	ErrNoUpcomingClose    = errors.New("schedule has no close time in the next five years")
+)
+
+// Service manages series, their subscriptions and their instances
+type Service struct {
+	db    *gorm.DB
+	econ  setup.EconConfigLoader
+	clock clock.Clock
+}
+
+// NewService creates a series service
+func NewService(db *gorm.DB, econ setup.EconConfigLoader, c clock.Clock) *Service {
+	return &Service{db: db, econ: econ, clock: c}
+}
+
+// CreateInput describes a new series
+type CreateInput struct {
+	Name               string  `json:"name"`
+	QuestionTemplate   string  `json:"questionTemplate"`
+	Description        string  `json:"description"`
+	InitialProbability float64 `json:"initialProbability"`
+	YesLabel           string  `json:"yesLabel"`
+	NoLabel            string  `json:"noLabel"`
+	Schedule           string  `json:"schedule"`
+	TenantID           uint    `json:"-"` // The community whose hostname the series was created on
+}
+
+// Detail is a series with its instances, newest first
+type Detail struct {
+	models.MarketSeries
+	Subscribers int64           `json:"subscribers"`
+	Markets     []models.Market `json:"markets"`
+}
+
+func title(template string, closesAt time.Time) string {
+	return strings.ReplaceAll(template, datePlaceholder, closesAt.UTC().Format(dateLayout))
+}
+
+// Create starts a series owned by creator and opens its first instance
+func (s *Service) Create(creator *models.User, in CreateInput) (*models.MarketSeries, *models.Market, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	in.QuestionTemplate = strings.TrimSpace(in.QuestionTemplate)
+	if in.Name == "" || len(in.Name) > maxNameLength {
+		return nil, nil, ErrInvalidName
+	}
+	if sample := title(in.QuestionTemplate, s.clock.Now()); sample == "" || len(sample) > maxTitleLength {
+		return nil, nil, ErrInvalidTemplate
+	}
+	if len(in.Description) > maxDescriptionLen {
+		return nil, nil, ErrInvalidDescription
+	}
+	if in.InitialProbability == 0 {
+		in.InitialProbability = s.econ().Economics.MarketCreation.InitialMarketProbability
+	}
+	if in.InitialProbability <= 0 || in.InitialProbability >= 1 {
+		return nil, nil, ErrInvalidProbability
+	}
+	if _, err := ParseSchedule(in.Schedule); err != nil {
+		return nil, nil, err
+	}
+
+	series := models.MarketSeries{
+		Name:               in.Name,
+		QuestionTemplate:   in.QuestionTemplate,
+		Description:        in.Description,
+		InitialProbability: in.InitialProbability,
+		YesLabel:           in.YesLabel,
+		NoLabel:            in.NoLabel,
+		Schedule:           strings.TrimSpace(in.Schedule),
+		CreatorUsername:    creator.Username,
+		IsActive:           true,
+		TenantID:           in.TenantID,
+	}
+	var market *models.Market
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&series).Error; err != nil {
+			return err
+		}
+		var err error
+		market, err = s.next(tx, &series, nil)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &series, market, nil
+}
+
+// SetActive pauses or resumes a series. A paused series opens no new
+// instances; its open instance runs its course.
+func (s *Service) SetActive(id uint, active bool) (*models.MarketSeries, error) {
+	series, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(series).Update("is_active", active).Error; err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+func (s *Service) get(id uint) (*models.MarketSeries, error) {
+	var series models.MarketSeries
+	if err := s.db.First(&series, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+	return &series, nil
+}
+
+// List returns the series, active ones only unless all is set
+func (s *Service) List(all bool) ([]models.MarketSeries, error) {
+	query := s.db.Order("id")
+	if !all {
+		query = query.Where("is_active = ?", true)
+	}
+	var list []models.MarketSeries
+	err := query.Find(&list).Error
+	return list, err
+}
+
+// Get returns a series with its instances
+func (s *Service) Get(id uint) (*Detail, error) {
+	series, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	detail := Detail{MarketSeries: *series}
+	if err := s.db.Where("series_id = ?", id).Order("series_index DESC").Find(&detail.Markets).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.MarketSeriesSubscription{}).Where("series_id = ?", id).Count(&detail.Subscribers).Error; err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// Subscribe asks for a notification when the series opens an instance.
+// Subscribing again is a no-op.
+func (s *Service) Subscribe(userID int64, id uint) error {
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.MarketSeriesSubscription{SeriesID: id, UserID: userID}).Error
+}
+
+// Unsubscribe stops the notifications. Unsubscribing again is a no-op.
+func (s *Service) Unsubscribe(userID int64, id uint) error {
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+	return s.db.Where("series_id = ? AND user_id = ?", id, userID).Delete(&models.MarketSeriesSubscription{}).Error
+}
+
+// Subscriptions returns the series the user is subscribed to
+func (s *Service) Subscriptions(userID int64) ([]models.MarketSeries, error) {
+	var list []models.MarketSeries
+	err := s.db.Where("id IN (?)", s.db.Model(&models.MarketSeriesSubscription{}).Select("series_id").Where("user_id = ?", userID)).
+		Order("id").Find(&list).Error
+	return list, err
+}
+
+// Generate opens the next instance of every active series whose last
+// instance has closed, and returns how many it opened
+func (s *Service) Generate() (int, error) {
+	var active []models.MarketSeries
+	if err := s.db.Where("is_active = ?", true).Order("id").Find(&active).Error; err != nil {
+		return 0, err
+	}
+	now := s.clock.Now()
+	opened := 0
+	for i := range active {
+		series := &active[i]
+		var previous *models.Market
+		if series.LastMarketID != nil {
+			var last models.Market
+			err := s.db.First(&last, *series.LastMarketID).Error
+			if err == nil {
+				if last.ResolutionDateTime.After(now) && last.ClosedAt == nil && !last.IsResolved {
+					continue // Still open
+				}
+				previous = &last
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return opened, err
+			}
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			_, err := s.next(tx, series, previous)
+			return err
+		})
+		if err != nil {
+			log.Printf("Series: opening the next instance of series %d failed: %v", series.ID, err)
+			s.db.Model(series).Update("last_error", err.Error())
+			continue
+		}
+		opened++
+	}
+	return opened, nil
+}
+
+// next opens the series' next instance, closing at its next scheduled time
+// far enough ahead to be allowed, and tells its subscribers
+func (s *Service) next(tx *gorm.DB, series *models.MarketSeries, previous *models.Market) (*models.Market, error) {
+	schedule, err := ParseSchedule(series.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	earliest := now.Add(time.Duration(s.econ().Economics.MarketCreation.MinimumFutureHours * float64(time.Hour)))
+	closesAt := schedule.Next(now)
+	for !closesAt.IsZero() && !closesAt.After(earliest) {
+		closesAt = schedule.Next(closesAt)
+	}
+	if closesAt.IsZero() {
+		return nil, ErrNoUpcomingClose
+	}
+
+	seriesID := series.ID
+	market := models.Market{
+		QuestionTitle:      title(series.QuestionTemplate, closesAt),
+		Description:        series.Description,
+		OutcomeType:        models.OutcomeTypeBinary,
+		ResolutionDateTime: closesAt,
+		InitialProbability: series.InitialProbability,
+		YesLabel:           series.YesLabel,
+		NoLabel:            series.NoLabel,
+		CreatorUsername:    series.CreatorUsername,
+		SeriesID:           &seriesID,
+		SeriesIndex:        series.Instances + 1,
+		TenantID:           series.TenantID,
+	}
+	if market.YesLabel == "" {
+		market.YesLabel = "YES"
+	}
+	if market.NoLabel == "" {
+		market.NoLabel = "NO"
+	}
+	if err := tx.Create(&market).Error; err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		if err := carryOverOracle(tx, previous, &market); err != nil {
+			return nil, err
+		}
+	}
+
+	series.Instances++
+	series.LastMarketID = &market.ID
+	series.LastGeneratedAt = &now
+	series.LastError = ""
+	if err := tx.Model(series).Updates(map[string]interface{}{
+		"instances":         series.Instances,
+		"last_market_id":    market.ID,
+		"last_generated_at": now,
+		"last_error":        "",
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	var subscribers []int64
+	if err := tx.Model(&models.MarketSeriesSubscription{}).Where("series_id = ?", series.ID).Pluck("user_id", &subscribers).Error; err != nil {
+		return nil, err
+	}
+	for _, userID := range subscribers {
+		if err := notify.Send(tx, userID, notify.TypeSeriesMarket, "New market in "+series.Name,
+			fmt.Sprintf("Market #%d \"%s\" is open for bets until %s.", market.ID, market.QuestionTitle, closesAt.Format(time.RFC1123))); err != nil {
+			return nil, err
+		}
+	}
+	return &market, nil
+}
+
+// carryOverOracle gives the new instance the previous instance's oracle
+func carryOverOracle(tx *gorm.DB, previous, market *models.Market) error {
+	var cfg models.MarketOracleConfig
+	err := tx.Where("market_id = ?", previous.ID).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.MarketOracleConfig{
+		MarketID:     market.ID,
+		Source:       cfg.Source,
+		URL:          cfg.URL,
+		Field:        cfg.Field,
+		Comparator:   cfg.Comparator,
+		Target:       cfg.Target,
+		Status:       models.OracleStatusPending,
+		ConfiguredBy: cfg.ConfiguredBy,
+	}).Error
+}
+
+// Run opens due instances every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.Generate()
+		if err != nil {
+			log.Printf("Series: generation failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Series: Opened %d markets", n)
+		}
+	}
+}
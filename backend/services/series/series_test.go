@@ -0,0 +1,112 @@
+package series
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/notify"
+	"socialpredict/setup"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2026, 5, 13, 10, 30, 0, 0, time.UTC) // A Wednesday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 16 * * 5", time.Date(2026, 5, 15, 16, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 5, 14, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 5, 13, 10, 45, 0, 0, time.UTC)},
+		{"0 9 1 * *", time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 12 1,20 * 1", time.Date(2026, 5, 18, 12, 0, 0, 0, time.UTC)}, // The 1st, the 20th or a Monday
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := ParseSchedule(c.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", c.expr, err)
+		}
+		if got := schedule.Next(from); !got.Equal(c.want) {
+			t.Errorf("%q: next = %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@yearly"} {
+		if _, err := ParseSchedule(bad); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%q: err = %v, want ErrInvalidSchedule", bad, err)
+		}
+	}
+}
+
+func TestGenerateOpensNextInstance(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("admin", 0)
+	fan := modelstesting.GenerateUser("fan", 0)
+	db.Create(&admin)
+	db.Create(&fan)
+	fake := clock.NewFake(time.Date(2026, 5, 13, 10, 30, 0, 0, time.UTC))
+	svc := NewService(db, func() *setup.EconomicConfig { return modelstesting.GenerateEconomicConfig() }, fake)
+
+	series, first, err := svc.Create(&admin, CreateInput{
+		Name:             "Weekly BTC",
+		QuestionTemplate: "Will BTC close above $100k on {date}?",
+		Schedule:         "0 16 * * 5",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if first.QuestionTitle != "Will BTC close above $100k on 2026-05-15?" || first.SeriesIndex != 1 {
+		t.Fatalf("first instance = %+v", first)
+	}
+	db.Create(&models.MarketOracleConfig{MarketID: first.ID, Source: models.OracleSourcePrice, URL: "https://example.com/btc",
+		Field: "price", Comparator: models.OracleCompareGT, Target: "100000", Status: models.OracleStatusPending, ConfiguredBy: "admin"})
+	if err := svc.Subscribe(fan.ID, series.ID); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := svc.Subscribe(fan.ID, series.ID); err != nil {
+		t.Fatalf("subscribe again: %v", err)
+	}
+
+	// Nothing opens while the instance is open
+	if n, err := svc.Generate(); err != nil || n != 0 {
+		t.Fatalf("generate while open: %d, %v", n, err)
+	}
+
+	fake.Set(first.ResolutionDateTime.Add(time.Minute))
+	if n, err := svc.Generate(); err != nil || n != 1 {
+		t.Fatalf("generate after close: %d, %v", n, err)
+	}
+	detail, err := svc.Get(series.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(detail.Markets) != 2 || detail.Subscribers != 1 {
+		t.Fatalf("detail = %d markets, %d subscribers", len(detail.Markets), detail.Subscribers)
+	}
+	second := detail.Markets[0]
+	if second.SeriesIndex != 2 || !second.ResolutionDateTime.Equal(time.Date(2026, 5, 22, 16, 0, 0, 0, time.UTC)) {
+		t.Fatalf("second instance = %+v", second)
+	}
+	var oracle models.MarketOracleConfig
+	if err := db.Where("market_id = ?", second.ID).First(&oracle).Error; err != nil || oracle.Target != "100000" {
+		t.Fatalf("oracle not carried over: %+v, %v", oracle, err)
+	}
+	var notified int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", fan.ID, notify.TypeSeriesMarket).Count(&notified)
+	if notified != 1 {
+		t.Fatalf("subscriber notifications = %d", notified)
+	}
+
+	// Paused series open nothing
+	if _, err := svc.SetActive(series.ID, false); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	fake.Set(second.ResolutionDateTime.Add(time.Minute))
+	if n, err := svc.Generate(); err != nil || n != 0 {
+		t.Fatalf("generate while paused: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,100 @@
+package settings
+
+import (
+	"fmt"
+	"strconv"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionCreatorFeesUpdated is the audit action for a creator fee settings change
+const ActionCreatorFeesUpdated = "CREATOR_FEE_SETTINGS_UPDATED"
+
+// MaxCreatorFeeBps caps the creator fee at 10% of trade volume
+const MaxCreatorFeeBps = 1000
+
+var ErrInvalidCreatorFees = fmt.Errorf("creator fee must be 0 to %d basis points and the platform share 0 to 10000", MaxCreatorFeeBps)
+
+// CreatorFees control the fee charged on trades for the market's creator
+type CreatorFees struct {
+	FeeBps           int64 // Charged on each trade, in basis points of its volume
+	PlatformShareBps int64 // Part of each fee the platform keeps, in basis points
+}
+
+// Validate checks both rates are in range
+func (c CreatorFees) Validate() error {
+	if c.FeeBps < 0 || c.FeeBps > MaxCreatorFeeBps || c.PlatformShareBps < 0 || c.PlatformShareBps > 10000 {
+		return ErrInvalidCreatorFees
+	}
+	return nil
+}
+
+// Split returns the fee on a trade of volume micro-credits and the part of it
+// that goes to the platform
+func (c CreatorFees) Split(volume int64) (fee, platform int64) {
+	fee = volume * c.FeeBps / 10000
+	platform = fee * c.PlatformShareBps / 10000
+	return fee, platform
+}
+
+// CreatorFees returns the current creator fee settings. Creator fees are off
+// until an admin sets them.
+func (s *Store) CreatorFees(db *gorm.DB) (CreatorFees, error) {
+	values, err := s.load(db)
+	if err != nil {
+		return CreatorFees{}, err
+	}
+	return CreatorFees{
+		FeeBps:           intSetting(values, models.SettingCreatorFeeBps),
+		PlatformShareBps: intSetting(values, models.SettingCreatorFeePlatformBps),
+	}, nil
+}
+
+// SetCreatorFees stores new creator fee settings and audits the change
+func (s *Store) SetCreatorFees(db *gorm.DB, fees CreatorFees, actor string) error {
+	if err := fees.Validate(); err != nil {
+		return err
+	}
+	previous, err := s.CreatorFees(db)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for key, value := range map[string]int64{
+			models.SettingCreatorFeeBps:         fees.FeeBps,
+			models.SettingCreatorFeePlatformBps: fees.PlatformShareBps,
+		} {
+			setting := models.PlatformSetting{Key: key, Value: strconv.FormatInt(value, 10), UpdatedBy: actor}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}).Create(&setting).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionCreatorFeesUpdated,
+			TargetType: "platform_settings",
+			Details: fmt.Sprintf("creator fees bps=%d->%d platformShareBps=%d->%d",
+				previous.FeeBps, fees.FeeBps, previous.PlatformShareBps, fees.PlatformShareBps),
+		})
+	})
+	s.Invalidate()
+	return err
+}
+
+// intSetting parses a plain integer setting, defaulting to zero
+func intSetting(values map[string]string, key string) int64 {
+	if v, ok := values[key]; ok {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return parsed
+		}
+	}
+	return 0
+}
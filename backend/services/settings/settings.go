@@ -0,0 +1,187 @@
+// Package settings reads and updates runtime platform settings, such as
+// withdrawal limits, with a short-lived in-memory cache.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionUpdated is the audit action for a settings change
+const ActionUpdated = "PLATFORM_SETTINGS_UPDATED"
+
+// cacheTTL bounds how stale a cached value can be on other instances
+const cacheTTL = 30 * time.Second
+
+// Default withdrawal limits in whole credits, used until an admin changes them
+const (
+	DefaultMinWithdrawal          = 10
+	DefaultMaxWithdrawal          = 10000
+	DefaultDailyWithdrawalLimit   = 50000
+	DefaultWeeklyWithdrawalLimit  = 150000
+	DefaultMonthlyWithdrawalLimit = 300000
+)
+
+var ErrInvalidLimits = errors.New("limits must be positive with minimum <= maximum <= daily <= weekly <= monthly limit; optional limits may be 0")
+
+// WithdrawalLimits are the per-request and rolling-window withdrawal limits in
+// micro-credits. A zero weekly, monthly or global limit is not enforced.
+type WithdrawalLimits struct {
+	Min     int64
+	Max     int64
+	Daily   int64
+	Weekly  int64
+	Monthly int64
+
+	GlobalDaily   int64 // Platform-wide caps across all users
+	GlobalWeekly  int64
+	GlobalMonthly int64
+}
+
+// Validate checks the limits are positive and consistently ordered
+func (l WithdrawalLimits) Validate() error {
+	if l.Min <= 0 || l.Min > l.Max || l.Max > l.Daily {
+		return ErrInvalidLimits
+	}
+	if l.Weekly < 0 || l.Monthly < 0 || l.GlobalDaily < 0 || l.GlobalWeekly < 0 || l.GlobalMonthly < 0 {
+		return ErrInvalidLimits
+	}
+	if (l.Weekly > 0 && l.Weekly < l.Daily) || (l.Monthly > 0 && l.Monthly < max(l.Daily, l.Weekly)) {
+		return ErrInvalidLimits
+	}
+	return nil
+}
+
+// Store caches settings read from the platform_settings table
+type Store struct {
+	clock    clock.Clock
+	mu       sync.RWMutex
+	values   map[string]string
+	loadedAt time.Time
+}
+
+// Shared is the process-wide settings store
+var Shared = NewStore(clock.New())
+
+// NewStore creates an empty settings store
+func NewStore(c clock.Clock) *Store {
+	return &Store{clock: c}
+}
+
+// Invalidate drops cached values so the next read goes to the database
+func (s *Store) Invalidate() {
+	s.mu.Lock()
+	s.values = nil
+	s.mu.Unlock()
+}
+
+// WithdrawalLimits returns the current withdrawal limits
+func (s *Store) WithdrawalLimits(db *gorm.DB) (WithdrawalLimits, error) {
+	values, err := s.load(db)
+	if err != nil {
+		return WithdrawalLimits{}, err
+	}
+	return WithdrawalLimits{
+		Min:   microSetting(values, models.SettingWithdrawalMin, DefaultMinWithdrawal),
+		Max:   microSetting(values, models.SettingWithdrawalMax, DefaultMaxWithdrawal),
+		Daily: microSetting(values, models.SettingWithdrawalDaily, DefaultDailyWithdrawalLimit),
+
+		Weekly:  microSetting(values, models.SettingWithdrawalWeekly, DefaultWeeklyWithdrawalLimit),
+		Monthly: microSetting(values, models.SettingWithdrawalMonthly, DefaultMonthlyWithdrawalLimit),
+
+		GlobalDaily:   microSetting(values, models.SettingGlobalWithdrawalDaily, 0),
+		GlobalWeekly:  microSetting(values, models.SettingGlobalWithdrawalWeekly, 0),
+		GlobalMonthly: microSetting(values, models.SettingGlobalWithdrawalMonthly, 0),
+	}, nil
+}
+
+// SetWithdrawalLimits stores new withdrawal limits and audits the change
+func (s *Store) SetWithdrawalLimits(db *gorm.DB, limits WithdrawalLimits, actor string) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	previous, err := s.WithdrawalLimits(db)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for key, value := range map[string]int64{
+			models.SettingWithdrawalMin:           limits.Min,
+			models.SettingWithdrawalMax:           limits.Max,
+			models.SettingWithdrawalDaily:         limits.Daily,
+			models.SettingWithdrawalWeekly:        limits.Weekly,
+			models.SettingWithdrawalMonthly:       limits.Monthly,
+			models.SettingGlobalWithdrawalDaily:   limits.GlobalDaily,
+			models.SettingGlobalWithdrawalWeekly:  limits.GlobalWeekly,
+			models.SettingGlobalWithdrawalMonthly: limits.GlobalMonthly,
+		} {
+			setting := models.PlatformSetting{Key: key, Value: strconv.FormatInt(value, 10), UpdatedBy: actor}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}).Create(&setting).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionUpdated,
+			TargetType: "platform_settings",
+			Details: fmt.Sprintf("withdrawal limits min=%s->%s max=%s->%s daily=%s->%s weekly=%s->%s monthly=%s->%s global=%s/%s/%s->%s/%s/%s",
+				models.FormatMicroCredits(previous.Min), models.FormatMicroCredits(limits.Min),
+				models.FormatMicroCredits(previous.Max), models.FormatMicroCredits(limits.Max),
+				models.FormatMicroCredits(previous.Daily), models.FormatMicroCredits(limits.Daily),
+				models.FormatMicroCredits(previous.Weekly), models.FormatMicroCredits(limits.Weekly),
+				models.FormatMicroCredits(previous.Monthly), models.FormatMicroCredits(limits.Monthly),
+				models.FormatMicroCredits(previous.GlobalDaily), models.FormatMicroCredits(previous.GlobalWeekly), models.FormatMicroCredits(previous.GlobalMonthly),
+				models.FormatMicroCredits(limits.GlobalDaily), models.FormatMicroCredits(limits.GlobalWeekly), models.FormatMicroCredits(limits.GlobalMonthly)),
+		})
+	})
+	s.Invalidate()
+	return err
+}
+
+// load returns cached settings, reloading them once the cache expires
+func (s *Store) load(db *gorm.DB) (map[string]string, error) {
+	s.mu.RLock()
+	values, loadedAt := s.values, s.loadedAt
+	s.mu.RUnlock()
+	if values != nil && clock.Since(s.clock, loadedAt) < cacheTTL {
+		return values, nil
+	}
+
+	var rows []models.PlatformSetting
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	values = make(map[string]string, len(rows))
+	for _, row := range rows {
+		values[row.Key] = row.Value
+	}
+
+	s.mu.Lock()
+	s.values, s.loadedAt = values, s.clock.Now()
+	s.mu.Unlock()
+	return values, nil
+}
+
+// microSetting parses a micro-credit setting, falling back to a whole-credit default
+func microSetting(values map[string]string, key string, defaultCredits int64) int64 {
+	if v, ok := values[key]; ok {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return parsed
+		}
+	}
+	return models.CreditsToMicro(defaultCredits)
+}
@@ -0,0 +1,113 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestWithdrawalLimitsDefaultsAndUpdate(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	store := NewStore(clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	limits, err := store.WithdrawalLimits(db)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := WithdrawalLimits{
+		Min:   models.CreditsToMicro(DefaultMinWithdrawal),
+		Max:   models.CreditsToMicro(DefaultMaxWithdrawal),
+		Daily: models.CreditsToMicro(DefaultDailyWithdrawalLimit),
+
+		Weekly:  models.CreditsToMicro(DefaultWeeklyWithdrawalLimit),
+		Monthly: models.CreditsToMicro(DefaultMonthlyWithdrawalLimit),
+	}
+	if limits != want {
+		t.Fatalf("defaults = %+v, want %+v", limits, want)
+	}
+
+	updated := WithdrawalLimits{
+		Min: models.CreditsToMicro(5), Max: models.CreditsToMicro(500), Daily: models.CreditsToMicro(1000),
+		Weekly: models.CreditsToMicro(3000), GlobalDaily: models.CreditsToMicro(100000),
+	}
+	if err := store.SetWithdrawalLimits(db, updated, "admin"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if limits, _ := store.WithdrawalLimits(db); limits != updated {
+		t.Fatalf("after update = %+v, want %+v", limits, updated)
+	}
+
+	// Updating again overwrites the same rows rather than adding new ones
+	updated.Max = models.CreditsToMicro(600)
+	if err := store.SetWithdrawalLimits(db, updated, "admin"); err != nil {
+		t.Fatalf("second set: %v", err)
+	}
+	var count int64
+	db.Model(&models.PlatformSetting{}).Count(&count)
+	if count != 8 {
+		t.Fatalf("expected 8 setting rows, got %d", count)
+	}
+
+	var audits []models.AuditLog
+	db.Where("action = ?", ActionUpdated).Find(&audits)
+	if len(audits) != 2 || audits[0].Actor != "admin" {
+		t.Fatalf("unexpected audit entries: %+v", audits)
+	}
+}
+
+func TestCacheExpiresAndValidation(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	store := NewStore(fake)
+
+	if _, err := store.WithdrawalLimits(db); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// Another instance changes the setting directly in the database
+	db.Create(&models.PlatformSetting{Key: models.SettingWithdrawalMin, Value: "1000000"})
+
+	if limits, _ := store.WithdrawalLimits(db); limits.Min != models.CreditsToMicro(DefaultMinWithdrawal) {
+		t.Fatalf("expected cached minimum, got %d", limits.Min)
+	}
+	fake.Advance(cacheTTL)
+	if limits, _ := store.WithdrawalLimits(db); limits.Min != models.CreditsToMicro(1) {
+		t.Fatalf("expected refreshed minimum, got %d", limits.Min)
+	}
+
+	for _, invalid := range []WithdrawalLimits{
+		{Min: 10, Max: 5, Daily: 20},
+		{Min: 5, Max: 10, Daily: 20, Weekly: 15},
+		{Min: 5, Max: 10, Daily: 20, Monthly: 15},
+		{Min: 5, Max: 10, Daily: 20, GlobalDaily: -1},
+	} {
+		if err := store.SetWithdrawalLimits(db, invalid, "admin"); !errors.Is(err, ErrInvalidLimits) {
+			t.Errorf("%+v: expected ErrInvalidLimits, got %v", invalid, err)
+		}
+	}
+}
+
+func TestMakerCheckerKeepsAdminsOffTheirOwnAccount(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	store := NewStore(clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	if policy, _ := store.MakerChecker(db); !policy.BlockOwnAccount || policy.SecondApproval {
+		t.Fatalf("defaults = %+v", policy)
+	}
+	if err := store.CheckNotOwnAccount(db, 7, 7); !errors.Is(err, ErrOwnAccount) {
+		t.Fatalf("own account: got %v, want ErrOwnAccount", err)
+	}
+	if err := store.CheckNotOwnAccount(db, 7, 8); err != nil {
+		t.Fatalf("other account: %v", err)
+	}
+
+	if err := store.SetMakerChecker(db, MakerChecker{BlockOwnAccount: false}, "admin"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := store.CheckNotOwnAccount(db, 7, 7); err != nil {
+		t.Fatalf("own account with the block off: %v", err)
+	}
+}
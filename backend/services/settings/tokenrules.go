@@ -0,0 +1,96 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionTokenRuleUpdated is the audit action for a per-token withdrawal rule change
+const ActionTokenRuleUpdated = "TOKEN_WITHDRAWAL_RULE_UPDATED"
+
+var (
+	ErrInvalidTokenRule    = errors.New("token rule limits must be 0 or positive with minimum <= maximum")
+	ErrWithdrawalsDisabled = errors.New("withdrawals of this token are disabled on this chain")
+)
+
+// TokenWithdrawalLimits returns the platform withdrawal limits with any
+// per-(chain, token) rule applied. ErrWithdrawalsDisabled is returned when the
+// rule switches withdrawals off.
+func (s *Store) TokenWithdrawalLimits(db *gorm.DB, chainName, tokenSymbol string) (WithdrawalLimits, error) {
+	limits, err := s.WithdrawalLimits(db)
+	if err != nil {
+		return WithdrawalLimits{}, err
+	}
+
+	var rule models.TokenWithdrawalRule
+	err = db.Where("chain_name = ? AND token_symbol = ?", chainName, tokenSymbol).Limit(1).Find(&rule).Error
+	if err != nil {
+		return WithdrawalLimits{}, err
+	}
+	if rule.ID == 0 {
+		return limits, nil
+	}
+	if rule.Disabled {
+		return limits, ErrWithdrawalsDisabled
+	}
+	return ApplyTokenRule(limits, rule), nil
+}
+
+// ApplyTokenRule overrides the per-request limits with a rule. An override can raise the
+// minimum or lower the maximum but never exceed the daily limit.
+func ApplyTokenRule(limits WithdrawalLimits, rule models.TokenWithdrawalRule) WithdrawalLimits {
+	if rule.MinWithdrawal > 0 {
+		limits.Min = rule.MinWithdrawal
+	}
+	if rule.MaxWithdrawal > 0 {
+		limits.Max = min(rule.MaxWithdrawal, limits.Daily)
+	}
+	return limits
+}
+
+// TokenWithdrawalRules lists every per-token rule
+func TokenWithdrawalRules(db *gorm.DB) ([]models.TokenWithdrawalRule, error) {
+	var rules []models.TokenWithdrawalRule
+	err := db.Order("chain_name, token_symbol").Find(&rules).Error
+	return rules, err
+}
+
+// SetTokenWithdrawalRule creates or replaces the rule for rule's chain and token
+// and audits the change
+func SetTokenWithdrawalRule(db *gorm.DB, rule models.TokenWithdrawalRule, actor string) (*models.TokenWithdrawalRule, error) {
+	if rule.MinWithdrawal < 0 || rule.MaxWithdrawal < 0 ||
+		(rule.MinWithdrawal > 0 && rule.MaxWithdrawal > 0 && rule.MinWithdrawal > rule.MaxWithdrawal) {
+		return nil, ErrInvalidTokenRule
+	}
+	rule.UpdatedBy = actor
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_name"}, {Name: "token_symbol"}},
+			DoUpdates: clause.AssignmentColumns([]string{"min_withdrawal", "max_withdrawal", "disabled", "note", "updated_by", "updated_at"}),
+		}).Create(&rule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("chain_name = ? AND token_symbol = ?", rule.ChainName, rule.TokenSymbol).First(&rule).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionTokenRuleUpdated,
+			TargetType: "token_withdrawal_rule",
+			TargetID:   rule.ID,
+			Details: fmt.Sprintf("%s/%s min=%s max=%s disabled=%t", rule.ChainName, rule.TokenSymbol,
+				models.FormatMicroCredits(rule.MinWithdrawal), models.FormatMicroCredits(rule.MaxWithdrawal), rule.Disabled),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
@@ -0,0 +1,60 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestTokenWithdrawalLimits(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	store := NewStore(clock.NewFake(time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)))
+
+	// Ethereum mainnet stablecoins are seeded with a higher minimum
+	limits, err := store.TokenWithdrawalLimits(db, "ethereum", "USDC")
+	if err != nil {
+		t.Fatalf("ethereum limits: %v", err)
+	}
+	if limits.Min != models.CreditsToMicro(50) || limits.Max != models.CreditsToMicro(DefaultMaxWithdrawal) {
+		t.Fatalf("ethereum USDC limits = %+v, want min 50 and platform max", limits)
+	}
+	if limits, _ := store.TokenWithdrawalLimits(db, "tron", "USDT"); limits.Min != models.CreditsToMicro(DefaultMinWithdrawal) {
+		t.Fatalf("tron USDT min = %d, want platform default", limits.Min)
+	}
+
+	// A maximum above the daily limit is capped at it
+	rule, err := SetTokenWithdrawalRule(db, models.TokenWithdrawalRule{
+		ChainName: "tron", TokenSymbol: "USDT", MinWithdrawal: models.CreditsToMicro(2), MaxWithdrawal: models.CreditsToMicro(1_000_000),
+	}, "admin")
+	if err != nil {
+		t.Fatalf("set rule: %v", err)
+	}
+	if rule.UpdatedBy != "admin" || rule.ID == 0 {
+		t.Errorf("saved rule = %+v", rule)
+	}
+	limits, _ = store.TokenWithdrawalLimits(db, "tron", "USDT")
+	if limits.Min != models.CreditsToMicro(2) || limits.Max != models.CreditsToMicro(DefaultDailyWithdrawalLimit) {
+		t.Errorf("tron USDT limits = %+v, want min 2 and max capped at the daily limit", limits)
+	}
+
+	// Replacing the rule updates the same row and can switch withdrawals off
+	if _, err := SetTokenWithdrawalRule(db, models.TokenWithdrawalRule{ChainName: "tron", TokenSymbol: "USDT", Disabled: true}, "admin"); err != nil {
+		t.Fatalf("disable rule: %v", err)
+	}
+	if _, err := store.TokenWithdrawalLimits(db, "tron", "USDT"); !errors.Is(err, ErrWithdrawalsDisabled) {
+		t.Errorf("expected ErrWithdrawalsDisabled, got %v", err)
+	}
+	rules, _ := TokenWithdrawalRules(db)
+	if len(rules) != 3 {
+		t.Errorf("expected 3 rules, got %d", len(rules))
+	}
+
+	_, err = SetTokenWithdrawalRule(db, models.TokenWithdrawalRule{ChainName: "tron", TokenSymbol: "USDC", MinWithdrawal: 10, MaxWithdrawal: 5}, "admin")
+	if !errors.Is(err, ErrInvalidTokenRule) {
+		t.Errorf("expected ErrInvalidTokenRule, got %v", err)
+	}
+}
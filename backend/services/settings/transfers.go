@@ -0,0 +1,83 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionTransferSettingsUpdated is the audit action for a transfer settings change
+const ActionTransferSettingsUpdated = "TRANSFER_SETTINGS_UPDATED"
+
+// DefaultTransferDailyLimit is how many whole credits a user may send to other
+// users over a rolling 24 hours until an admin changes it
+const DefaultTransferDailyLimit = 1000
+
+var ErrInvalidTransferSettings = errors.New("transfer daily limit must be positive")
+
+// TransferSettings control user-to-user credit transfers
+type TransferSettings struct {
+	Enabled    bool
+	DailyLimit int64 // Micro-credits a user may send over a rolling 24 hours
+}
+
+// Transfers returns the current transfer settings. Transfers are enabled
+// until an admin switches them off.
+func (s *Store) Transfers(db *gorm.DB) (TransferSettings, error) {
+	values, err := s.load(db)
+	if err != nil {
+		return TransferSettings{}, err
+	}
+	enabled := true
+	if v, ok := values[models.SettingTransfersEnabled]; ok {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			enabled = parsed
+		}
+	}
+	return TransferSettings{
+		Enabled:    enabled,
+		DailyLimit: microSetting(values, models.SettingTransferDailyLimit, DefaultTransferDailyLimit),
+	}, nil
+}
+
+// SetTransfers stores new transfer settings and audits the change
+func (s *Store) SetTransfers(db *gorm.DB, transfers TransferSettings, actor string) error {
+	if transfers.DailyLimit <= 0 {
+		return ErrInvalidTransferSettings
+	}
+	previous, err := s.Transfers(db)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for key, value := range map[string]string{
+			models.SettingTransfersEnabled:   strconv.FormatBool(transfers.Enabled),
+			models.SettingTransferDailyLimit: strconv.FormatInt(transfers.DailyLimit, 10),
+		} {
+			setting := models.PlatformSetting{Key: key, Value: value, UpdatedBy: actor}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}).Create(&setting).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionTransferSettingsUpdated,
+			TargetType: "platform_settings",
+			Details: fmt.Sprintf("transfers enabled=%t->%t daily=%s->%s",
+				previous.Enabled, transfers.Enabled,
+				models.FormatMicroCredits(previous.DailyLimit), models.FormatMicroCredits(transfers.DailyLimit)),
+		})
+	})
+	s.Invalidate()
+	return err
+}
@@ -0,0 +1,368 @@
+// Package settlement holds market winnings for a settlement delay before
+// they can be withdrawn, so a market cannot be resolved in someone's favour
+// and the winnings cashed out before anyone can object. Held winnings count
+// towards the balance and can be bet, but not withdrawn or transferred; see
+// models.User.PendingBalance.
+//
+// While winnings are held, anyone who bet in the market, or an admin, can
+// open a dispute. That freezes the market's holds until an admin either
+// releases them or reverses them through the ledger.
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/ledger"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	ActionDisputeReleased = "MARKET_DISPUTE_RELEASED"
+	ActionDisputeUpheld   = "MARKET_DISPUTE_UPHELD"
+)
+
+const (
+	referenceType       = "market_dispute"
+	defaultDelayHours   = 24
+	releaseBatchSize    = 500
+	maxDisputeReasonLen = 1000
+)
+
+var (
+	ErrMarketNotFound  = errors.New("market not found")
+	ErrReasonRequired  = errors.New("reason is required")
+	ErrNotEligible     = errors.New("only admins and users who bet in the market can dispute it")
+	ErrNothingHeld     = errors.New("the market has no winnings still held")
+	ErrAlreadyDisputed = errors.New("the market already has an open dispute")
+	ErrDisputeNotFound = errors.New("dispute not found")
+	ErrDisputeClosed   = errors.New("dispute is already closed")
+)
+
+// Config controls the settlement delay
+type Config struct {
+	Delay time.Duration // How long winnings are held; zero pays them out withdrawable
+}
+
+// LoadConfigFromEnv reads SETTLEMENT_DELAY_HOURS
+func LoadConfigFromEnv() Config {
+	hours := float64(defaultDelayHours)
+	if v := os.Getenv("SETTLEMENT_DELAY_HOURS"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed >= 0 {
+			hours = parsed
+		}
+	}
+	return Config{Delay: time.Duration(hours * float64(time.Hour))}
+}
+
+// Hold keeps amount micro-credits just paid to the user out of their
+// withdrawable balance until the delay has passed
+func Hold(tx *gorm.DB, user *models.User, marketID int64, amount int64, config Config, now time.Time) error {
+	if config.Delay <= 0 || amount <= 0 {
+		return nil
+	}
+	if err := tx.Create(&models.PayoutHold{
+		UserID:    user.ID,
+		MarketID:  marketID,
+		Amount:    amount,
+		Status:    models.PayoutHoldHeld,
+		ReleaseAt: now.Add(config.Delay),
+	}).Error; err != nil {
+		return err
+	}
+	user.PendingBalance += amount
+	return tx.Model(&models.User{}).Where("id = ?", user.ID).
+		Update("pending_balance", gorm.Expr("pending_balance + ?", amount)).Error
+}
+
+// Service releases held winnings and handles disputes
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService creates a settlement service
+func NewService(db *gorm.DB, c clock.Clock) *Service {
+	return &Service{db: db, clock: c}
+}
+
+// Pending sums the user's held winnings, disputed ones included, and
+// returns when the next undisputed hold is released, if any
+func (s *Service) Pending(userID int64) (int64, *time.Time, error) {
+	var holds []models.PayoutHold
+	if err := s.db.Where("user_id = ? AND status IN ?", userID, []string{models.PayoutHoldHeld, models.PayoutHoldDisputed}).
+		Order("release_at").Find(&holds).Error; err != nil {
+		return 0, nil, err
+	}
+	var total int64
+	var next *time.Time
+	for i := range holds {
+		total += holds[i].Amount
+		if next == nil && holds[i].Status == models.PayoutHoldHeld {
+			next = &holds[i].ReleaseAt
+		}
+	}
+	return total, next, nil
+}
+
+// ReleaseDue releases every undisputed hold whose delay has passed and
+// returns how many it released
+func (s *Service) ReleaseDue() (int, error) {
+	var due []models.PayoutHold
+	if err := s.db.Where("status = ? AND release_at <= ?", models.PayoutHoldHeld, s.clock.Now()).
+		Order("release_at").Limit(releaseBatchSize).Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for i := range due {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.release(tx, &due[i], models.PayoutHoldHeld)
+		})
+		if err != nil {
+			return released, fmt.Errorf("hold %d: %w", due[i].ID, err)
+		}
+		released++
+	}
+	return released, nil
+}
+
+// release makes a hold withdrawable if it still has status from
+func (s *Service) release(tx *gorm.DB, hold *models.PayoutHold, from string) error {
+	now := s.clock.Now()
+	result := tx.Model(hold).Where("status = ?", from).
+		Updates(map[string]interface{}{"status": models.PayoutHoldReleased, "released_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return reducePending(tx, hold)
+}
+
+// reducePending takes a released or reversed hold off the user's pending
+// balance, never below zero
+func reducePending(tx *gorm.DB, hold *models.PayoutHold) error {
+	result := tx.Model(&models.User{}).Where("id = ?", hold.UserID).
+		Update("pending_balance", gorm.Expr("CASE WHEN pending_balance > ? THEN pending_balance - ? ELSE 0 END", hold.Amount, hold.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// OpenDispute freezes a market's held winnings until an admin reviews its
+// resolution. Only admins and users who bet in the market can open one.
+func (s *Service) OpenDispute(marketID int64, user *models.User, reason string) (*models.MarketDispute, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if len(reason) > maxDisputeReasonLen {
+		reason = reason[:maxDisputeReasonLen]
+	}
+
+	var dispute models.MarketDispute
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var market models.Market
+		if err := tx.First(&market, marketID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMarketNotFound
+			}
+			return err
+		}
+		if user.UserType != "ADMIN" {
+			var bets int64
+			if err := tx.Model(&models.Bet{}).Where("market_id = ? AND username = ?", marketID, user.Username).
+				Count(&bets).Error; err != nil {
+				return err
+			}
+			if bets == 0 {
+				return ErrNotEligible
+			}
+		}
+
+		var open int64
+		if err := tx.Model(&models.MarketDispute{}).Where("market_id = ? AND status = ?", marketID, models.DisputeStatusOpen).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrAlreadyDisputed
+		}
+
+		var holds []models.PayoutHold
+		if err := tx.Where("market_id = ? AND status = ?", marketID, models.PayoutHoldHeld).Find(&holds).Error; err != nil {
+			return err
+		}
+		if len(holds) == 0 {
+			return ErrNothingHeld
+		}
+		if err := tx.Model(&models.PayoutHold{}).Where("market_id = ? AND status = ?", marketID, models.PayoutHoldHeld).
+			Update("status", models.PayoutHoldDisputed).Error; err != nil {
+			return err
+		}
+
+		dispute = models.MarketDispute{MarketID: marketID, OpenedBy: user.Username, Reason: reason, Status: models.DisputeStatusOpen}
+		dispute.CreatedAt = s.clock.Now()
+		if err := tx.Create(&dispute).Error; err != nil {
+			return err
+		}
+		return notifyHolders(tx, holds, notify.TypeDisputeOpened, "Winnings frozen",
+			fmt.Sprintf("Your winnings from market #%d are frozen while a dispute of its resolution is reviewed.", marketID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// ListDisputes returns disputes, optionally with the given status, newest first
+func (s *Service) ListDisputes(status string) ([]models.MarketDispute, error) {
+	query := s.db.Model(&models.MarketDispute{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	list := []models.MarketDispute{}
+	err := query.Order("created_at DESC").Limit(100).Find(&list).Error
+	return list, err
+}
+
+// ReleaseDispute dismisses a dispute and releases the market's frozen
+// winnings at once
+func (s *Service) ReleaseDispute(id uint, admin, note string) (*models.MarketDispute, error) {
+	return s.closeDispute(id, admin, note, models.DisputeStatusReleased)
+}
+
+// UpholdDispute reverses the market's frozen winnings through the ledger.
+// Winnings already spent leave the user's balance negative.
+func (s *Service) UpholdDispute(id uint, admin, note string) (*models.MarketDispute, error) {
+	return s.closeDispute(id, admin, note, models.DisputeStatusUpheld)
+}
+
+func (s *Service) closeDispute(id uint, admin, note, status string) (*models.MarketDispute, error) {
+	var dispute models.MarketDispute
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&dispute, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDisputeNotFound
+			}
+			return err
+		}
+		now := s.clock.Now()
+		result := tx.Model(&dispute).Where("status = ?", models.DisputeStatusOpen).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": admin,
+			"review_note": note,
+			"closed_at":   now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDisputeClosed
+		}
+
+		var holds []models.PayoutHold
+		if err := tx.Where("market_id = ? AND status = ?", dispute.MarketID, models.PayoutHoldDisputed).
+			Order("id").Find(&holds).Error; err != nil {
+			return err
+		}
+		for i := range holds {
+			var err error
+			if status == models.DisputeStatusReleased {
+				err = s.release(tx, &holds[i], models.PayoutHoldDisputed)
+			} else {
+				err = reverse(tx, &holds[i], dispute.ID)
+			}
+			if err != nil {
+				return fmt.Errorf("hold %d: %w", holds[i].ID, err)
+			}
+		}
+
+		message := fmt.Sprintf("The dispute of market #%d was dismissed and your winnings released.", dispute.MarketID)
+		action := ActionDisputeReleased
+		if status == models.DisputeStatusUpheld {
+			message = fmt.Sprintf("The dispute of market #%d was upheld and your winnings from it reversed.", dispute.MarketID)
+			action = ActionDisputeUpheld
+		}
+		if note != "" {
+			message += " Note: " + note
+		}
+		if err := notifyHolders(tx, holds, notify.TypeDisputeClosed, "Dispute closed", message); err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin,
+			Action:     action,
+			TargetType: referenceType,
+			TargetID:   dispute.ID,
+			Details:    fmt.Sprintf("market=%d holds=%d note=%q", dispute.MarketID, len(holds), note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dispute, s.db.First(&dispute, dispute.ID).Error
+}
+
+// reverse takes a frozen hold back out of the user's balance
+func reverse(tx *gorm.DB, hold *models.PayoutHold, disputeID uint) error {
+	if err := tx.Model(hold).Update("status", models.PayoutHoldReversed).Error; err != nil {
+		return err
+	}
+	if err := reducePending(tx, hold); err != nil {
+		return err
+	}
+	var user models.User
+	if err := tx.First(&user, hold.UserID).Error; err != nil {
+		return fmt.Errorf("user: %w", err)
+	}
+	_, err := ledger.Apply(tx, &user, ledger.Posting{
+		Type:          models.LedgerTypePayoutReversal,
+		Amount:        -hold.Amount,
+		ReferenceType: referenceType,
+		ReferenceID:   disputeID,
+		MarketID:      &hold.MarketID,
+		Description:   fmt.Sprintf("Winnings from market #%d reversed after dispute #%d was upheld", hold.MarketID, disputeID),
+	})
+	return err
+}
+
+// notifyHolders sends one notification to each user with a hold
+func notifyHolders(tx *gorm.DB, holds []models.PayoutHold, notificationType, title, message string) error {
+	seen := make(map[int64]bool)
+	for _, hold := range holds {
+		if seen[hold.UserID] {
+			continue
+		}
+		seen[hold.UserID] = true
+		if err := notify.Send(tx, hold.UserID, notificationType, title, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run releases due winnings every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.ReleaseDue(); err != nil {
+			log.Printf("Settlement: releasing held winnings failed: %v", err)
+		}
+	}
+}
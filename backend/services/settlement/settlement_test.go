@@ -0,0 +1,144 @@
+package settlement
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/ledger"
+
+	"gorm.io/gorm"
+)
+
+// payOut credits a winner and holds the winnings, as resolution does
+func payOut(t *testing.T, db *gorm.DB, user *models.User, marketID, amount int64, now time.Time) {
+	t.Helper()
+	if _, err := ledger.Apply(db, user, ledger.Posting{Type: models.LedgerTypeMarketPayout, Amount: amount, MarketID: &marketID}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if err := Hold(db, user, marketID, amount, Config{Delay: 24 * time.Hour}, now); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+}
+
+func reload(t *testing.T, db *gorm.DB, user *models.User) *models.User {
+	t.Helper()
+	var fresh models.User
+	if err := db.First(&fresh, user.ID).Error; err != nil {
+		t.Fatalf("reload user: %v", err)
+	}
+	return &fresh
+}
+
+func TestHeldWinningsReleaseAfterDelay(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	svc := NewService(db, fake)
+
+	winner := modelstesting.GenerateUser("winner", 10)
+	db.Create(&winner)
+	payOut(t, db, &winner, 1, models.CreditsToMicro(90), fake.Now())
+
+	held := reload(t, db, &winner)
+	if held.PendingBalance != models.CreditsToMicro(90) || held.WithdrawableMicroCredits() != models.CreditsToMicro(10) {
+		t.Fatalf("pending %d, withdrawable %d; want 90 held and 10 withdrawable", held.PendingBalance, held.WithdrawableMicroCredits())
+	}
+	if total, next, _ := svc.Pending(winner.ID); total != models.CreditsToMicro(90) || next == nil {
+		t.Errorf("Pending = %d, %v", total, next)
+	}
+
+	if n, _ := svc.ReleaseDue(); n != 0 {
+		t.Fatal("released winnings before the delay passed")
+	}
+	fake.Advance(24 * time.Hour)
+	if n, err := svc.ReleaseDue(); err != nil || n != 1 {
+		t.Fatalf("ReleaseDue = %d, %v; want 1", n, err)
+	}
+	if released := reload(t, db, &winner); released.PendingBalance != 0 || released.WithdrawableMicroCredits() != models.CreditsToMicro(100) {
+		t.Errorf("pending %d, withdrawable %d after release", released.PendingBalance, released.WithdrawableMicroCredits())
+	}
+}
+
+func TestDisputeFreezesAndReversesWinnings(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	svc := NewService(db, fake)
+
+	winner := modelstesting.GenerateUser("winner", 0)
+	loser := modelstesting.GenerateUser("loser", 0)
+	outsider := modelstesting.GenerateUser("outsider", 0)
+	for _, u := range []*models.User{&winner, &loser, &outsider} {
+		db.Create(u)
+	}
+	market := modelstesting.GenerateMarket(1, "winner")
+	db.Create(&market)
+	bet := modelstesting.GenerateBet(20, "NO", "loser", uint(market.ID), 0)
+	db.Create(&bet)
+	payOut(t, db, &winner, market.ID, models.CreditsToMicro(50), fake.Now())
+
+	if _, err := svc.OpenDispute(market.ID, &outsider, "resolved early"); !errors.Is(err, ErrNotEligible) {
+		t.Errorf("outsider dispute = %v, want ErrNotEligible", err)
+	}
+	dispute, err := svc.OpenDispute(market.ID, &loser, "resolved before the event happened")
+	if err != nil {
+		t.Fatalf("OpenDispute: %v", err)
+	}
+	if _, err := svc.OpenDispute(market.ID, &loser, "again"); !errors.Is(err, ErrAlreadyDisputed) {
+		t.Errorf("second dispute = %v, want ErrAlreadyDisputed", err)
+	}
+
+	// Frozen winnings are not released when the delay passes
+	fake.Advance(48 * time.Hour)
+	if n, _ := svc.ReleaseDue(); n != 0 {
+		t.Fatal("released disputed winnings")
+	}
+
+	if _, err := svc.UpholdDispute(dispute.ID, "admin", "confirmed"); err != nil {
+		t.Fatalf("UpholdDispute: %v", err)
+	}
+	reversed := reload(t, db, &winner)
+	if reversed.PendingBalance != 0 || reversed.BalanceMicroCredits() != 0 {
+		t.Errorf("pending %d, balance %d after reversal; want both 0", reversed.PendingBalance, reversed.BalanceMicroCredits())
+	}
+	var hold models.PayoutHold
+	db.First(&hold, "market_id = ?", market.ID)
+	if hold.Status != models.PayoutHoldReversed {
+		t.Errorf("hold status = %s, want REVERSED", hold.Status)
+	}
+	if _, err := svc.ReleaseDispute(dispute.ID, "admin", ""); !errors.Is(err, ErrDisputeClosed) {
+		t.Errorf("closing twice = %v, want ErrDisputeClosed", err)
+	}
+}
+
+func TestHoldsFromStaleCopiesAllCount(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	svc := NewService(db, fake)
+
+	winner := modelstesting.GenerateUser("winner", 0)
+	db.Create(&winner)
+
+	// Two markets resolve at once, each with its own copy of the winner
+	first, second := *reload(t, db, &winner), *reload(t, db, &winner)
+	config := Config{Delay: 24 * time.Hour}
+	if err := Hold(db, &first, 1, models.CreditsToMicro(30), config, fake.Now()); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if err := Hold(db, &second, 2, models.CreditsToMicro(50), config, fake.Now()); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if held := reload(t, db, &winner); held.PendingBalance != models.CreditsToMicro(80) {
+		t.Fatalf("pending = %d, want both holds", held.PendingBalance)
+	}
+
+	fake.Advance(24 * time.Hour)
+	if n, err := svc.ReleaseDue(); err != nil || n != 2 {
+		t.Fatalf("ReleaseDue = %d, %v; want 2", n, err)
+	}
+	if released := reload(t, db, &winner); released.PendingBalance != 0 {
+		t.Errorf("pending after release = %d, want 0", released.PendingBalance)
+	}
+}
@@ -0,0 +1,163 @@
+// Package stream fans market events out to Server-Sent Events clients.
+//
+// A Hub keeps the subscribers of each market. Publishing never blocks: each
+// subscriber has a small buffer, and one that falls behind is disconnected
+// rather than holding up trading. SSE clients reconnect on their own.
+package stream
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"socialpredict/models"
+)
+
+// Event types
+const (
+	EventTrade       = "trade"
+	EventProbability = "probability"
+	EventComment     = "comment"
+)
+
+const (
+	defaultBuffer           = 64
+	maxSubscribersPerMarket = 500
+)
+
+var ErrTooManySubscribers = errors.New("too many subscribers for this market")
+
+// Event is one message pushed to a market's subscribers
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	MarketID int64       `json:"marketId"`
+	Data     interface{} `json:"data"`
+	At       time.Time   `json:"at"`
+}
+
+// Trade is the data of a trade event
+type Trade struct {
+	BetID    uint      `json:"betId"`
+	Username string    `json:"username"`
+	Outcome  string    `json:"outcome"`
+	Amount   int64     `json:"amount"`             // Credits bet, or shares sold when negative
+	Proceeds int64     `json:"proceeds,omitempty"` // Credits paid out for a sale
+	PlacedAt time.Time `json:"placedAt"`
+}
+
+// Subscription receives a market's events until it is closed, either by the
+// subscriber or by the hub when the subscriber falls behind
+type Subscription struct {
+	marketID int64
+	events   chan Event
+	once     sync.Once
+}
+
+// Events returns the subscription's channel, closed when it ends
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.events) })
+}
+
+// Hub routes events to the subscribers of each market
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[int64]map[*Subscription]struct{}
+	buffer int
+	nextID uint64
+	now    func() time.Time
+}
+
+// NewHub returns a hub whose subscribers buffer up to buffer events
+func NewHub(buffer int) *Hub {
+	if buffer < 1 {
+		buffer = defaultBuffer
+	}
+	return &Hub{subs: make(map[int64]map[*Subscription]struct{}), buffer: buffer, now: time.Now}
+}
+
+// Default is the hub trades are published to
+var Default = NewHub(defaultBuffer)
+
+// Subscribe starts receiving the market's events
+func (h *Hub) Subscribe(marketID int64) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs[marketID]) >= maxSubscribersPerMarket {
+		return nil, ErrTooManySubscribers
+	}
+	sub := &Subscription{marketID: marketID, events: make(chan Event, h.buffer)}
+	if h.subs[marketID] == nil {
+		h.subs[marketID] = make(map[*Subscription]struct{})
+	}
+	h.subs[marketID][sub] = struct{}{}
+	return sub, nil
+}
+
+// Unsubscribe stops the subscription and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if subs := h.subs[sub.marketID]; subs != nil {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subs, sub.marketID)
+		}
+	}
+	sub.close()
+}
+
+// Subscribers returns how many clients follow the market
+func (h *Hub) Subscribers(marketID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[marketID])
+}
+
+// Publish sends an event to the market's subscribers without waiting on
+// any of them. Subscribers whose buffer is full are dropped.
+func (h *Hub) Publish(marketID int64, eventType string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs[marketID]) == 0 {
+		return
+	}
+	h.nextID++
+	event := Event{ID: h.nextID, Type: eventType, MarketID: marketID, Data: data, At: h.now()}
+	for sub := range h.subs[marketID] {
+		select {
+		case sub.events <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// PublishTrade sends a trade and the probabilities it left the market at
+func (h *Hub) PublishTrade(bet models.Bet, points []models.MarketPricePoint) {
+	marketID := int64(bet.MarketID)
+	h.Publish(marketID, EventTrade, Trade{
+		BetID:    bet.ID,
+		Username: bet.Username,
+		Outcome:  bet.Outcome,
+		Amount:   bet.Amount,
+		Proceeds: bet.Proceeds,
+		PlacedAt: bet.PlacedAt,
+	})
+	if len(points) == 0 {
+		return
+	}
+	probabilities := make(map[string]float64, len(points))
+	for _, p := range points {
+		probabilities[p.Outcome] = p.Probability
+	}
+	h.Publish(marketID, EventProbability, probabilities)
+}
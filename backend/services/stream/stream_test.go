@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models"
+)
+
+func TestPublishReachesMarketSubscribers(t *testing.T) {
+	hub := NewHub(4)
+	sub, err := hub.Subscribe(1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	other, _ := hub.Subscribe(2)
+
+	hub.PublishTrade(models.Bet{ID: 7, MarketID: 1, Username: "alice", Outcome: "YES", Amount: 10},
+		[]models.MarketPricePoint{{Outcome: "YES", Probability: 0.6}})
+
+	trade := <-sub.Events()
+	if trade.Type != EventTrade || trade.Data.(Trade).BetID != 7 {
+		t.Fatalf("first event = %+v, want the trade", trade)
+	}
+	probability := <-sub.Events()
+	if probability.Type != EventProbability || probability.Data.(map[string]float64)["YES"] != 0.6 || probability.ID <= trade.ID {
+		t.Fatalf("second event = %+v, want the new probability", probability)
+	}
+	select {
+	case event := <-other.Events():
+		t.Fatalf("market 2 subscriber got %+v", event)
+	default:
+	}
+
+	hub.Unsubscribe(sub)
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("channel still open after Unsubscribe")
+	}
+	if hub.Subscribers(1) != 0 {
+		t.Fatalf("market 1 still has %d subscribers", hub.Subscribers(1))
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(2)
+	slow, _ := hub.Subscribe(1)
+	for i := 0; i < 3; i++ {
+		hub.Publish(1, EventComment, i)
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != 2 || hub.Subscribers(1) != 0 {
+		t.Fatalf("slow subscriber got %d events and %d remain subscribed, want 2 buffered then dropped", received, hub.Subscribers(1))
+	}
+	// Unsubscribing after being dropped is harmless
+	hub.Unsubscribe(slow)
+}
+
+func TestSubscriberLimit(t *testing.T) {
+	hub := NewHub(1)
+	for i := 0; i < maxSubscribersPerMarket; i++ {
+		if _, err := hub.Subscribe(1); err != nil {
+			t.Fatalf("Subscribe %d: %v", i, err)
+		}
+	}
+	if _, err := hub.Subscribe(1); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("err = %v, want ErrTooManySubscribers", err)
+	}
+	if _, err := hub.Subscribe(2); err != nil {
+		t.Fatalf("other market: %v", err)
+	}
+}
@@ -0,0 +1,434 @@
+// Package tenants lets one deployment host several branded prediction-market
+// communities. Each tenant has its own hostnames, branding, credit name, fee
+// schedule and deposit chains; its markets and leaderboards are kept apart
+// from other communities'. Users, wallets and the treasury are shared.
+//
+// Requests are matched to a tenant by hostname in Middleware. Hostnames no
+// active tenant claims are the default community, which keeps the platform's
+// own settings. Active tenants are cached briefly; every change drops the
+// cache.
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/setup"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions for tenant changes
+const (
+	ActionCreated = "TENANT_CREATED"
+	ActionUpdated = "TENANT_UPDATED"
+)
+
+const (
+	targetType    = "tenant"
+	cacheTTL      = 30 * time.Second
+	maxName       = 100
+	maxCreditName = 30
+	maxHostnames  = 10
+)
+
+var (
+	ErrNotFound        = errors.New("tenant not found")
+	ErrInvalidSlug     = errors.New("slug must be 2 to 40 lower case letters, digits or hyphens")
+	ErrInvalidName     = fmt.Errorf("name is required and at most %d characters", maxName)
+	ErrInvalidHostname = fmt.Errorf("give 1 to %d valid hostnames, without scheme or port", maxHostnames)
+	ErrHostnameTaken   = errors.New("hostname is already used by another tenant")
+	ErrSlugTaken       = errors.New("slug is already used by another tenant")
+	ErrInvalidBranding = fmt.Errorf("logo URL must be http or https, colour a hex code such as #1f6feb, and credit name at most %d characters", maxCreditName)
+	ErrInvalidFee      = errors.New("fees and market creation cost cannot be negative")
+	ErrUnknownChain    = errors.New("enabled chains must be chains the platform supports")
+)
+
+var (
+	slugPattern     = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,38}[a-z0-9]$`)
+	hostnamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+	colourPattern   = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Default is the community served on hostnames no tenant claims
+var Default = models.Tenant{Slug: "default", IsActive: true}
+
+// defaultTenant returns a copy of Default, so callers cannot change it
+func defaultTenant() *models.Tenant {
+	tenant := Default
+	return &tenant
+}
+
+// Input is the admin-editable part of a tenant
+type Input struct {
+	Slug             string   `json:"slug"` // Fixed once the tenant exists
+	Name             string   `json:"name"`
+	Hostnames        []string `json:"hostnames"`
+	LogoURL          string   `json:"logoUrl"`
+	PrimaryColor     string   `json:"primaryColor"`
+	CreditName       string   `json:"creditName"`
+	InitialBetFee    *int64   `json:"initialBetFee"`
+	BuySharesFee     *int64   `json:"buySharesFee"`
+	SellSharesFee    *int64   `json:"sellSharesFee"`
+	CreateMarketCost *int64   `json:"createMarketCost"`
+	EnabledChains    []string `json:"enabledChains"`
+	IsActive         bool     `json:"isActive"`
+}
+
+// validate checks the input and normalises hostnames and chains
+func (in *Input) validate(db *gorm.DB) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > maxName {
+		return ErrInvalidName
+	}
+	if len(in.Hostnames) == 0 || len(in.Hostnames) > maxHostnames {
+		return ErrInvalidHostname
+	}
+	for i, host := range in.Hostnames {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if len(host) > 253 || !hostnamePattern.MatchString(host) {
+			return ErrInvalidHostname
+		}
+		in.Hostnames[i] = host
+	}
+	slices.Sort(in.Hostnames)
+	in.Hostnames = slices.Compact(in.Hostnames)
+
+	in.CreditName = strings.TrimSpace(in.CreditName)
+	if !validURL(in.LogoURL) || (in.PrimaryColor != "" && !colourPattern.MatchString(in.PrimaryColor)) || len(in.CreditName) > maxCreditName {
+		return ErrInvalidBranding
+	}
+	for _, fee := range []*int64{in.InitialBetFee, in.BuySharesFee, in.SellSharesFee, in.CreateMarketCost} {
+		if fee != nil && *fee < 0 {
+			return ErrInvalidFee
+		}
+	}
+
+	for i, chain := range in.EnabledChains {
+		in.EnabledChains[i] = strings.ToLower(strings.TrimSpace(chain))
+	}
+	slices.Sort(in.EnabledChains)
+	in.EnabledChains = slices.Compact(in.EnabledChains)
+	if len(in.EnabledChains) > 0 {
+		var known int64
+		if err := db.Model(&models.SupportedChain{}).Where("name IN ?", in.EnabledChains).Count(&known).Error; err != nil {
+			return err
+		}
+		if int(known) != len(in.EnabledChains) {
+			return ErrUnknownChain
+		}
+	}
+	if in.EnabledChains == nil {
+		in.EnabledChains = []string{}
+	}
+	return nil
+}
+
+// validURL accepts an empty URL or an absolute http(s) one
+func validURL(raw string) bool {
+	if raw == "" {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Store caches the active tenants by hostname and ID
+type Store struct {
+	clock    clock.Clock
+	mu       sync.RWMutex
+	cached   *snapshot
+	loadedAt time.Time
+}
+
+type snapshot struct {
+	byHost map[string]*models.Tenant
+	byID   map[uint]*models.Tenant
+}
+
+// Shared is the process-wide tenant store
+var Shared = NewStore(clock.New())
+
+// NewStore creates an empty tenant store
+func NewStore(c clock.Clock) *Store {
+	return &Store{clock: c}
+}
+
+// Invalidate drops the cached tenants so the next lookup goes to the database
+func (s *Store) Invalidate() {
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+}
+
+// ForHost returns the active tenant claiming host, or Default
+func (s *Store) ForHost(db *gorm.DB, host string) (*models.Tenant, error) {
+	snap, err := s.load(db)
+	if err != nil {
+		return nil, err
+	}
+	if tenant, ok := snap.byHost[normaliseHost(host)]; ok {
+		return tenant, nil
+	}
+	return defaultTenant(), nil
+}
+
+// ForID returns the active tenant with id, or Default for 0 or a tenant that
+// has been switched off
+func (s *Store) ForID(db *gorm.DB, id uint) (*models.Tenant, error) {
+	if id == 0 {
+		return defaultTenant(), nil
+	}
+	snap, err := s.load(db)
+	if err != nil {
+		return nil, err
+	}
+	if tenant, ok := snap.byID[id]; ok {
+		return tenant, nil
+	}
+	return defaultTenant(), nil
+}
+
+// ForMarket returns the tenant a market belongs to
+func (s *Store) ForMarket(db *gorm.DB, marketID uint) (*models.Tenant, error) {
+	var market models.Market
+	if err := db.Select("id", "tenant_id").First(&market, marketID).Error; err != nil {
+		return nil, err
+	}
+	return s.ForID(db, market.TenantID)
+}
+
+// MarketInTenant reports whether a market exists in the tenant's community.
+// Markets of other communities are treated as missing.
+func MarketInTenant(db *gorm.DB, marketID uint, tenant *models.Tenant) (bool, error) {
+	var count int64
+	err := db.Model(&models.Market{}).Where("id = ?", marketID).Scopes(models.InTenant(tenant.ID)).Count(&count).Error
+	return count > 0, err
+}
+
+func (s *Store) load(db *gorm.DB) (*snapshot, error) {
+	s.mu.RLock()
+	snap, loadedAt := s.cached, s.loadedAt
+	s.mu.RUnlock()
+	if snap != nil && clock.Since(s.clock, loadedAt) < cacheTTL {
+		return snap, nil
+	}
+
+	var tenants []models.Tenant
+	if err := db.Where("is_active = ?", true).Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	snap = &snapshot{byHost: make(map[string]*models.Tenant), byID: make(map[uint]*models.Tenant, len(tenants))}
+	for i := range tenants {
+		tenant := &tenants[i]
+		snap.byID[tenant.ID] = tenant
+		for _, host := range tenant.Hostnames {
+			snap.byHost[host] = tenant
+		}
+	}
+
+	s.mu.Lock()
+	s.cached, s.loadedAt = snap, s.clock.Now()
+	s.mu.Unlock()
+	return snap, nil
+}
+
+// List returns every tenant, active or not
+func List(db *gorm.DB) ([]models.Tenant, error) {
+	tenants := []models.Tenant{}
+	err := db.Order("slug").Find(&tenants).Error
+	return tenants, err
+}
+
+// Create adds a tenant and audits it
+func (s *Store) Create(db *gorm.DB, in Input, actor string) (*models.Tenant, error) {
+	slug := strings.ToLower(strings.TrimSpace(in.Slug))
+	if !slugPattern.MatchString(slug) || slug == Default.Slug {
+		return nil, ErrInvalidSlug
+	}
+	if err := in.validate(db); err != nil {
+		return nil, err
+	}
+
+	tenant := &models.Tenant{Slug: slug}
+	apply(tenant, in)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Unscoped().Model(&models.Tenant{}).Where("slug = ?", slug).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrSlugTaken
+		}
+		if err := checkHostnames(tx, 0, in.Hostnames); err != nil {
+			return err
+		}
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+		return record(tx, actor, ActionCreated, tenant, fmt.Sprintf("tenant %s hosts %s", slug, strings.Join(tenant.Hostnames, ",")))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return tenant, nil
+}
+
+// Update replaces a tenant's settings and audits the change. The slug cannot
+// change. Switching a tenant off sends its hostnames to the default
+// community; its markets are kept.
+func (s *Store) Update(db *gorm.DB, id uint, in Input, actor string) (*models.Tenant, error) {
+	if err := in.validate(db); err != nil {
+		return nil, err
+	}
+
+	var tenant models.Tenant
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&tenant, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if in.Slug != "" && strings.ToLower(strings.TrimSpace(in.Slug)) != tenant.Slug {
+			return ErrInvalidSlug
+		}
+		if err := checkHostnames(tx, tenant.ID, in.Hostnames); err != nil {
+			return err
+		}
+		previous := tenant
+		apply(&tenant, in)
+		if err := tx.Save(&tenant).Error; err != nil {
+			return err
+		}
+		return record(tx, actor, ActionUpdated, &tenant, fmt.Sprintf("tenant %s hosts %s->%s active %t->%t",
+			tenant.Slug, strings.Join(previous.Hostnames, ","), strings.Join(tenant.Hostnames, ","), previous.IsActive, tenant.IsActive))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Invalidate()
+	return &tenant, nil
+}
+
+func apply(tenant *models.Tenant, in Input) {
+	tenant.Name = in.Name
+	tenant.Hostnames = in.Hostnames
+	tenant.LogoURL = in.LogoURL
+	tenant.PrimaryColor = in.PrimaryColor
+	tenant.CreditName = in.CreditName
+	tenant.InitialBetFee = in.InitialBetFee
+	tenant.BuySharesFee = in.BuySharesFee
+	tenant.SellSharesFee = in.SellSharesFee
+	tenant.CreateMarketCost = in.CreateMarketCost
+	tenant.EnabledChains = in.EnabledChains
+	tenant.IsActive = in.IsActive
+}
+
+// checkHostnames refuses hostnames another tenant, active or not, claims
+func checkHostnames(tx *gorm.DB, id uint, hostnames []string) error {
+	var others []models.Tenant
+	if err := tx.Select("id", "hostnames").Where("id <> ?", id).Find(&others).Error; err != nil {
+		return err
+	}
+	for _, other := range others {
+		for _, host := range other.Hostnames {
+			if slices.Contains(hostnames, host) {
+				return fmt.Errorf("%w: %s", ErrHostnameTaken, host)
+			}
+		}
+	}
+	return nil
+}
+
+func record(tx *gorm.DB, actor, action string, tenant *models.Tenant, details string) error {
+	return audit.Record(tx, models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   tenant.ID,
+		Details:    details,
+	})
+}
+
+// normaliseHost strips the port from a Host header and lower-cases it
+func normaliseHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+type tenantContextKey struct{}
+
+// Middleware selects the tenant for each request by its hostname. Requests
+// fall back to the default community when tenants cannot be loaded, so the
+// platform stays up.
+func Middleware(db *gorm.DB, store *Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := store.ForHost(db, r.Host)
+		if err != nil {
+			tenant = defaultTenant()
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, tenant)))
+	})
+}
+
+// FromRequest returns the tenant Middleware selected for r, or Default
+func FromRequest(r *http.Request) *models.Tenant {
+	if tenant, ok := r.Context().Value(tenantContextKey{}).(*models.Tenant); ok {
+		return tenant
+	}
+	return defaultTenant()
+}
+
+// BetFees returns the tenant's fee schedule, falling back to defaults for
+// fees it does not set
+func BetFees(tenant *models.Tenant, defaults setup.BetFees) setup.BetFees {
+	fees := defaults
+	if tenant.InitialBetFee != nil {
+		fees.InitialBetFee = *tenant.InitialBetFee
+	}
+	if tenant.BuySharesFee != nil {
+		fees.BuySharesFee = *tenant.BuySharesFee
+	}
+	if tenant.SellSharesFee != nil {
+		fees.SellSharesFee = *tenant.SellSharesFee
+	}
+	return fees
+}
+
+// CreateMarketCost returns what creating a market costs in the tenant
+func CreateMarketCost(tenant *models.Tenant, defaultCost int64) int64 {
+	if tenant.CreateMarketCost != nil {
+		return *tenant.CreateMarketCost
+	}
+	return defaultCost
+}
+
+// Economics returns the platform economics with the tenant's fee schedule
+func Economics(tenant *models.Tenant, economics setup.Economics) setup.Economics {
+	economics.Betting.BetFees = BetFees(tenant, economics.Betting.BetFees)
+	economics.MarketIncentives.CreateMarketCost = CreateMarketCost(tenant, economics.MarketIncentives.CreateMarketCost)
+	return economics
+}
+
+// ChainEnabled reports whether the tenant offers deposits on chain. Tenants
+// that enable no chains offer every active one.
+func ChainEnabled(tenant *models.Tenant, chain string) bool {
+	return len(tenant.EnabledChains) == 0 || slices.Contains(tenant.EnabledChains, chain)
+}
@@ -0,0 +1,132 @@
+package tenants
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/setup"
+)
+
+func fee(credits int64) *int64 {
+	return &credits
+}
+
+func TestTenantSelectedByHostname(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	store := NewStore(clock.NewFake(time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)))
+
+	if _, err := store.Create(db, Input{Slug: "Default", Name: "Nope", Hostnames: []string{"nope.example"}, IsActive: true}, "admin"); !errors.Is(err, ErrInvalidSlug) {
+		t.Errorf("default slug = %v, want ErrInvalidSlug", err)
+	}
+	if _, err := store.Create(db, Input{Slug: "club", Name: "Club", Hostnames: []string{"https://club.example"}, IsActive: true}, "admin"); !errors.Is(err, ErrInvalidHostname) {
+		t.Errorf("hostname with scheme = %v, want ErrInvalidHostname", err)
+	}
+
+	club, err := store.Create(db, Input{Slug: "club", Name: "Club", Hostnames: []string{"Club.Example", "bets.club.example"},
+		CreditName: "chips", IsActive: true}, "admin")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := store.Create(db, Input{Slug: "rival", Name: "Rival", Hostnames: []string{"club.example"}, IsActive: true}, "admin"); !errors.Is(err, ErrHostnameTaken) {
+		t.Errorf("claimed hostname = %v, want ErrHostnameTaken", err)
+	}
+	if _, err := store.Create(db, Input{Slug: "club", Name: "Club again", Hostnames: []string{"other.example"}, IsActive: true}, "admin"); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("reused slug = %v, want ErrSlugTaken", err)
+	}
+
+	if tenant, err := store.ForHost(db, "club.example:443"); err != nil || tenant.ID != club.ID || tenant.CreditName != "chips" {
+		t.Fatalf("ForHost(club.example:443) = %+v, %v; want club", tenant, err)
+	}
+	if tenant, _ := store.ForHost(db, "localhost:8080"); tenant.ID != 0 || tenant.Slug != Default.Slug {
+		t.Errorf("ForHost(localhost) = %+v, want the default community", tenant)
+	}
+
+	// Switching the tenant off sends its hostnames to the default community
+	if _, err := store.Update(db, club.ID, Input{Name: "Club", Hostnames: club.Hostnames, IsActive: false}, "admin"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if tenant, _ := store.ForHost(db, "club.example"); tenant.ID != 0 {
+		t.Errorf("ForHost after switching off = %d, want the default community", tenant.ID)
+	}
+	if _, err := store.Update(db, club.ID+1, Input{Name: "Missing", Hostnames: []string{"missing.example"}}, "admin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update missing = %v, want ErrNotFound", err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ?", targetType, club.ID).Count(&audits)
+	if audits != 2 {
+		t.Errorf("audit entries = %d, want 2", audits)
+	}
+}
+
+func TestTenantFeesAndChains(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	store := NewStore(clock.NewFake(time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)))
+
+	if _, err := store.Create(db, Input{Slug: "club", Name: "Club", Hostnames: []string{"club.example"}, BuySharesFee: fee(-1), IsActive: true}, "admin"); !errors.Is(err, ErrInvalidFee) {
+		t.Errorf("negative fee = %v, want ErrInvalidFee", err)
+	}
+	if _, err := store.Create(db, Input{Slug: "club", Name: "Club", Hostnames: []string{"club.example"}, EnabledChains: []string{"nochain"}, IsActive: true}, "admin"); !errors.Is(err, ErrUnknownChain) {
+		t.Errorf("unknown chain = %v, want ErrUnknownChain", err)
+	}
+
+	db.Create(&models.SupportedChain{ChainID: 8453, Name: "base", DisplayName: "Base", IsActive: true})
+	club, err := store.Create(db, Input{Slug: "club", Name: "Club", Hostnames: []string{"club.example"},
+		InitialBetFee: fee(0), CreateMarketCost: fee(25), EnabledChains: []string{"Base"}, IsActive: true}, "admin")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	defaults := setup.BetFees{InitialBetFee: 1, BuySharesFee: 2, SellSharesFee: 3}
+	if fees := BetFees(club, defaults); fees != (setup.BetFees{InitialBetFee: 0, BuySharesFee: 2, SellSharesFee: 3}) {
+		t.Errorf("BetFees = %+v, want no initial fee and the platform's trading fees", fees)
+	}
+	if cost := CreateMarketCost(club, 10); cost != 25 {
+		t.Errorf("CreateMarketCost = %d, want 25", cost)
+	}
+	if !ChainEnabled(club, "base") || ChainEnabled(club, "ethereum") {
+		t.Errorf("ChainEnabled = %v, want base only", club.EnabledChains)
+	}
+	if !ChainEnabled(defaultTenant(), "ethereum") {
+		t.Error("the default community offers every chain")
+	}
+
+	market := modelstesting.GenerateMarket(1, "creator")
+	market.TenantID = club.ID
+	db.Create(&market)
+	if tenant, err := store.ForMarket(db, uint(market.ID)); err != nil || tenant.ID != club.ID {
+		t.Errorf("ForMarket = %+v, %v; want club", tenant, err)
+	}
+	if ok, _ := MarketInTenant(db, uint(market.ID), defaultTenant()); ok {
+		t.Error("the club's market is not in the default community")
+	}
+}
+
+func TestMiddlewareStoresTenant(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	store := NewStore(clock.New())
+	club, err := store.Create(db, Input{Slug: "club", Name: "Club", Hostnames: []string{"club.example"}, IsActive: true}, "admin")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var got *models.Tenant
+	handler := Middleware(db, store, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromRequest(r)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "http://club.example/v0/markets", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got == nil || got.ID != club.ID {
+		t.Errorf("tenant = %+v, want club", got)
+	}
+
+	if tenant := FromRequest(httptest.NewRequest(http.MethodGet, "/v0/markets", nil)); tenant.ID != 0 {
+		t.Errorf("tenant without middleware = %d, want the default community", tenant.ID)
+	}
+}
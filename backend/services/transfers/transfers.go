@@ -0,0 +1,190 @@
+// Package transfers sends credits between users, for tips and community prize
+// distribution. Admins can switch transfers off and cap how much each user
+// sends per day.
+package transfers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/notify"
+	"socialpredict/services/restrictions"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+// MaxMemoLength is the longest memo a transfer may carry, in characters
+const MaxMemoLength = 280
+
+const (
+	referenceType = "credit_transfer"
+	dailyWindow   = 24 * time.Hour
+)
+
+var (
+	ErrDisabled             = errors.New("credit transfers are currently disabled")
+	ErrInvalidAmount        = errors.New("amount must be positive")
+	ErrSelfTransfer         = errors.New("cannot transfer credits to yourself")
+	ErrRecipientNotFound    = errors.New("recipient not found")
+	ErrInsufficientBalance  = errors.New("insufficient balance")
+	ErrMemoTooLong          = fmt.Errorf("memo must be at most %d characters", MaxMemoLength)
+	ErrDailyLimitExceeded   = errors.New("daily transfer limit exceeded")
+	ErrBonusNotTransferable = errors.New("promotional bonus credits cannot be transferred")
+)
+
+// Service sends and lists credit transfers
+type Service struct {
+	db       *gorm.DB
+	settings *settings.Store
+	clock    clock.Clock
+}
+
+// NewService creates a transfer service
+func NewService(db *gorm.DB, store *settings.Store, c clock.Clock) *Service {
+	return &Service{db: db, settings: store, clock: c}
+}
+
+// Send moves amount micro-credits from the sender to the user named
+// toUsername, writing a ledger entry on both sides and notifying the
+// recipient. A failed daily limit check wraps ErrDailyLimitExceeded.
+func (s *Service) Send(fromUserID int64, toUsername string, amount int64, memo string) (*models.CreditTransfer, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if utf8.RuneCountInString(memo) > MaxMemoLength {
+		return nil, ErrMemoTooLong
+	}
+	config, err := s.settings.Transfers(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return nil, ErrDisabled
+	}
+
+	var transfer models.CreditTransfer
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var recipient models.User
+		if err := tx.Select("id").Where("username = ?", toUsername).First(&recipient).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecipientNotFound
+			}
+			return err
+		}
+		if recipient.ID == fromUserID {
+			return ErrSelfTransfer
+		}
+		// Both balances are locked before the checks, so concurrent transfers
+		// from the sender see each other's debits and none can overdraw
+		from, to, err := ledger.LockUsers(tx, fromUserID, recipient.ID)
+		if err != nil {
+			return fmt.Errorf("sender: %w", err)
+		}
+		if from.BalanceMicroCredits() < amount {
+			return ErrInsufficientBalance
+		}
+		if from.WithdrawableMicroCredits() < amount {
+			return ErrBonusNotTransferable
+		}
+		// Credits sent on could be withdrawn by the recipient
+		if err := restrictions.Check(tx, from.ID, models.RestrictionWithdrawalsFrozen, s.clock.Now()); err != nil {
+			return err
+		}
+
+		var sentToday int64
+		if err := tx.Model(&models.CreditTransfer{}).
+			Where("from_user_id = ? AND created_at > ?", from.ID, s.clock.Now().Add(-dailyWindow)).
+			Select("COALESCE(SUM(amount), 0)").Scan(&sentToday).Error; err != nil {
+			return err
+		}
+		if sentToday+amount > config.DailyLimit {
+			return fmt.Errorf("%w: %s of %s credits left today", ErrDailyLimitExceeded,
+				models.FormatMicroCredits(max(config.DailyLimit-sentToday, 0)), models.FormatMicroCredits(config.DailyLimit))
+		}
+
+		transfer = models.CreditTransfer{FromUserID: from.ID, ToUserID: to.ID, Amount: amount, Memo: memo}
+		transfer.CreatedAt = s.clock.Now()
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+
+		if _, err := ledger.Apply(tx, from, ledger.Posting{
+			Type:          models.LedgerTypeTransferOut,
+			Amount:        -amount,
+			ReferenceType: referenceType,
+			ReferenceID:   transfer.ID,
+			Description:   fmt.Sprintf("Transfer #%d to %s", transfer.ID, to.Username),
+		}); err != nil {
+			return err
+		}
+		if _, err := ledger.Apply(tx, to, ledger.Posting{
+			Type:          models.LedgerTypeTransferIn,
+			Amount:        amount,
+			ReferenceType: referenceType,
+			ReferenceID:   transfer.ID,
+			Description:   fmt.Sprintf("Transfer #%d from %s", transfer.ID, from.Username),
+		}); err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("%s sent you %s credits.", from.Username, models.FormatMicroCredits(amount))
+		if memo != "" {
+			message += " Memo: " + memo
+		}
+		return notify.Send(tx, to.ID, notify.TypeCreditTransfer, "Credits received", message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// TransferView is a transfer as seen by one of its parties
+type TransferView struct {
+	ID           uint      `json:"id"`
+	Direction    string    `json:"direction"` // "sent" or "received"
+	Counterparty string    `json:"counterparty"`
+	Amount       float64   `json:"amount"`      // Credits, rounded for display
+	AmountMicro  int64     `json:"amountMicro"` // Exact amount in micro-credits
+	Memo         string    `json:"memo,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// List returns the user's most recent sent and received transfers
+func (s *Service) List(userID int64, limit int) ([]TransferView, error) {
+	var transfers []models.CreditTransfer
+	if err := s.db.Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+		Order("created_at DESC").Limit(limit).Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, 0, len(transfers))
+	for _, t := range transfers {
+		userIDs = append(userIDs, t.FromUserID, t.ToUserID)
+	}
+	var users []models.User
+	if err := s.db.Select("id, username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[int64]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	views := make([]TransferView, len(transfers))
+	for i, t := range transfers {
+		view := TransferView{ID: t.ID, Direction: "sent", Counterparty: usernames[t.ToUserID],
+			Amount: models.DisplayCredits(t.Amount), AmountMicro: t.Amount, Memo: t.Memo, CreatedAt: t.CreatedAt}
+		if t.ToUserID == userID {
+			view.Direction, view.Counterparty = "received", usernames[t.FromUserID]
+		}
+		views[i] = view
+	}
+	return views, nil
+}
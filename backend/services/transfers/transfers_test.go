@@ -0,0 +1,155 @@
+package transfers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/settings"
+
+	"gorm.io/gorm"
+)
+
+func setup(t *testing.T) (*gorm.DB, *clock.Fake, *settings.Store, *Service, *models.User, *models.User) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 4, 2, 9, 0, 0, 0, time.UTC))
+	store := settings.NewStore(fake)
+	svc := NewService(db, store, fake)
+
+	alice := modelstesting.GenerateUser("alice", 500)
+	bob := modelstesting.GenerateUser("bob", 0)
+	for _, u := range []*models.User{&alice, &bob} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	return db, fake, store, svc, &alice, &bob
+}
+
+func TestSendMovesCreditsAndWritesLedger(t *testing.T) {
+	db, _, _, svc, alice, bob := setup(t)
+
+	transfer, err := svc.Send(alice.ID, "bob", models.CreditsToMicro(25)+500_000, "prize")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var gotAlice, gotBob models.User
+	db.First(&gotAlice, alice.ID)
+	db.First(&gotBob, bob.ID)
+	if gotAlice.BalanceMicroCredits() != models.CreditsToMicro(474)+500_000 || gotBob.BalanceMicroCredits() != models.CreditsToMicro(25)+500_000 {
+		t.Errorf("balances = %d / %d", gotAlice.BalanceMicroCredits(), gotBob.BalanceMicroCredits())
+	}
+
+	var entries []models.LedgerEntry
+	db.Where("reference_type = ? AND reference_id = ?", referenceType, transfer.ID).Order("amount").Find(&entries)
+	if len(entries) != 2 || entries[0].Type != models.LedgerTypeTransferOut || entries[1].Type != models.LedgerTypeTransferIn ||
+		entries[0].Amount != -transfer.Amount || entries[1].BalanceAfter != gotBob.BalanceMicroCredits() {
+		t.Errorf("ledger entries = %+v", entries)
+	}
+
+	var note models.Notification
+	if err := db.Where("user_id = ? AND type = ?", bob.ID, "CREDIT_TRANSFER").First(&note).Error; err != nil {
+		t.Errorf("recipient not notified: %v", err)
+	}
+
+	views, err := svc.List(bob.ID, 10)
+	if err != nil || len(views) != 1 || views[0].Direction != "received" || views[0].Counterparty != "alice" {
+		t.Errorf("List = %+v, %v", views, err)
+	}
+}
+
+func TestSendRejectsInvalidTransfers(t *testing.T) {
+	_, _, _, svc, alice, bob := setup(t)
+
+	for name, tc := range map[string]struct {
+		from   int64
+		to     string
+		amount int64
+		want   error
+	}{
+		"zero amount":  {alice.ID, "bob", 0, ErrInvalidAmount},
+		"self":         {alice.ID, "alice", models.CreditsToMicro(1), ErrSelfTransfer},
+		"no recipient": {alice.ID, "carol", models.CreditsToMicro(1), ErrRecipientNotFound},
+		"overdraw":     {bob.ID, "alice", models.CreditsToMicro(1), ErrInsufficientBalance},
+	} {
+		if _, err := svc.Send(tc.from, tc.to, tc.amount, ""); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestDailyLimitAndToggle(t *testing.T) {
+	db, fake, store, svc, alice, _ := setup(t)
+	if err := store.SetTransfers(db, settings.TransferSettings{Enabled: true, DailyLimit: models.CreditsToMicro(100)}, "admin"); err != nil {
+		t.Fatalf("SetTransfers: %v", err)
+	}
+
+	if _, err := svc.Send(alice.ID, "bob", models.CreditsToMicro(80), ""); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if _, err := svc.Send(alice.ID, "bob", models.CreditsToMicro(30), ""); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("over limit = %v, want ErrDailyLimitExceeded", err)
+	}
+	fake.Advance(25 * time.Hour)
+	if _, err := svc.Send(alice.ID, "bob", models.CreditsToMicro(30), ""); err != nil {
+		t.Errorf("send after window: %v", err)
+	}
+
+	if err := store.SetTransfers(db, settings.TransferSettings{Enabled: false, DailyLimit: models.CreditsToMicro(100)}, "admin"); err != nil {
+		t.Fatalf("SetTransfers: %v", err)
+	}
+	if _, err := svc.Send(alice.ID, "bob", models.CreditsToMicro(1), ""); !errors.Is(err, ErrDisabled) {
+		t.Errorf("disabled = %v, want ErrDisabled", err)
+	}
+}
+
+func TestConcurrentSendsCannotOverdraw(t *testing.T) {
+	db, _, _, svc, alice, bob := setup(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	// Alice has 500 credits; only one of two 300 credit transfers fits
+	errs := make(chan error, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Send(alice.ID, "bob", models.CreditsToMicro(300), "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var sent, refused int
+	for err := range errs {
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, ErrInsufficientBalance):
+			refused++
+		default:
+			t.Errorf("Send: %v", err)
+		}
+	}
+	if sent != 1 || refused != 1 {
+		t.Errorf("sent %d, refused %d; want one of each", sent, refused)
+	}
+
+	var gotAlice, gotBob models.User
+	db.First(&gotAlice, alice.ID)
+	db.First(&gotBob, bob.ID)
+	if gotAlice.AccountBalance != models.CreditsToMicro(200) || gotBob.AccountBalance != models.CreditsToMicro(300) {
+		t.Errorf("balances = %d / %d, want 200 / 300", gotAlice.AccountBalance, gotBob.AccountBalance)
+	}
+}
@@ -0,0 +1,237 @@
+// Package travelrule collects travel-rule style beneficiary information for
+// large withdrawals. At or above a threshold the user attests whether the
+// destination is a wallet they control or an account at an exchange, and who
+// the beneficiary is. The attestation is kept on the withdrawal request and
+// exported for compliance.
+package travelrule
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultThreshold    = 1000 * models.MicroCreditsPerCredit
+	defaultExportWindow = 30 * 24 * time.Hour
+	maxNameLength       = 140
+)
+
+var (
+	ErrRequired     = errors.New("withdrawals of this size need beneficiary information: a beneficiary type and name")
+	ErrInvalid      = errors.New("beneficiary type must be SELF_CUSTODY or EXCHANGE, with a name of at most 140 characters and, for EXCHANGE, the exchange's name")
+	ErrInvalidRange = errors.New("from and to must be YYYY-MM-DD with from on or before to")
+)
+
+// Config holds travel-rule settings
+type Config struct {
+	ThresholdMicro int64 // Withdrawals of at least this many micro-credits need a beneficiary attestation
+}
+
+// LoadConfigFromEnv reads TRAVEL_RULE_THRESHOLD (credits; 0 requires an
+// attestation for every withdrawal)
+func LoadConfigFromEnv() Config {
+	config := Config{ThresholdMicro: defaultThreshold}
+	if v := os.Getenv("TRAVEL_RULE_THRESHOLD"); v != "" {
+		if micro, err := models.ParseCredits(v); err == nil && micro >= 0 {
+			config.ThresholdMicro = micro
+		}
+	}
+	return config
+}
+
+// Beneficiary is what the user attests about a withdrawal's destination
+type Beneficiary struct {
+	Type     string `json:"type"` // SELF_CUSTODY or EXCHANGE
+	Name     string `json:"name"` // Who holds the destination; the user's own name for SELF_CUSTODY
+	Exchange string `json:"exchange,omitempty"`
+}
+
+// Policy decides which withdrawals need a beneficiary attestation
+type Policy struct {
+	config Config
+}
+
+// NewPolicy creates a travel-rule policy
+func NewPolicy(config Config) *Policy {
+	return &Policy{config: config}
+}
+
+// Threshold returns the smallest withdrawal, in micro-credits, that needs an attestation
+func (p *Policy) Threshold() int64 {
+	return p.config.ThresholdMicro
+}
+
+// Required reports whether a withdrawal of amountMicro needs a beneficiary
+// attestation. A nil Policy requires none.
+func (p *Policy) Required(amountMicro int64) bool {
+	return p != nil && amountMicro >= p.config.ThresholdMicro
+}
+
+// Check validates the beneficiary sent with a withdrawal of amountMicro and
+// returns it cleaned up, or nil when none was sent and none is needed. An
+// attestation sent below the threshold is kept too.
+func (p *Policy) Check(amountMicro int64, b *Beneficiary) (*Beneficiary, error) {
+	if b == nil || (b.Type == "" && b.Name == "" && b.Exchange == "") {
+		if p.Required(amountMicro) {
+			return nil, ErrRequired
+		}
+		return nil, nil
+	}
+	clean := Beneficiary{
+		Type:     strings.ToUpper(strings.TrimSpace(b.Type)),
+		Name:     strings.TrimSpace(b.Name),
+		Exchange: strings.TrimSpace(b.Exchange),
+	}
+	if clean.Name == "" || len(clean.Name) > maxNameLength || len(clean.Exchange) > maxNameLength {
+		return nil, ErrInvalid
+	}
+	switch clean.Type {
+	case models.BeneficiarySelfCustody:
+		clean.Exchange = ""
+	case models.BeneficiaryExchange:
+		if clean.Exchange == "" {
+			return nil, ErrInvalid
+		}
+	default:
+		return nil, ErrInvalid
+	}
+	return &clean, nil
+}
+
+// Apply records the attestation on a withdrawal request, made at attestedAt
+func (b *Beneficiary) Apply(wr *models.WithdrawalRequest, attestedAt time.Time) {
+	if b == nil {
+		return
+	}
+	wr.BeneficiaryType = b.Type
+	wr.BeneficiaryName = b.Name
+	wr.BeneficiaryExchange = b.Exchange
+	wr.BeneficiaryAttestedAt = &attestedAt
+}
+
+// Record is one withdrawal in a compliance export. Amount is in micro-credits.
+type Record struct {
+	WithdrawalID        uint       `json:"withdrawalId"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UserID              int64      `json:"userId"`
+	Username            string     `json:"username"`
+	Status              string     `json:"status"`
+	ChainName           string     `json:"chainName"`
+	TokenSymbol         string     `json:"tokenSymbol"`
+	Amount              int64      `json:"amount"`
+	ToAddress           string     `json:"toAddress"`
+	BeneficiaryType     string     `json:"beneficiaryType"` // Empty for a withdrawal over the threshold made without an attestation
+	BeneficiaryName     string     `json:"beneficiaryName"`
+	BeneficiaryExchange string     `json:"beneficiaryExchange,omitempty"`
+	AttestedAt          *time.Time `json:"attestedAt"`
+}
+
+// ParseRange returns the export window for YYYY-MM-DD dates, to inclusive.
+// An empty from is 30 days before now and an empty to is now.
+func ParseRange(fromDate, toDate string, now time.Time) (from, to time.Time, err error) {
+	from, to = now.Add(-defaultExportWindow), now
+	if fromDate != "" {
+		if from, err = time.Parse("2006-01-02", fromDate); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidRange
+		}
+	}
+	if toDate != "" {
+		if to, err = time.Parse("2006-01-02", toDate); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidRange
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+	return from, to, nil
+}
+
+// Export returns the withdrawals requested in [from, to) that carry an
+// attestation or are at or above the threshold, oldest first. Those over the
+// threshold without one, made before the rule applied, are included so gaps
+// show up in the export.
+func (p *Policy) Export(db *gorm.DB, from, to time.Time) ([]Record, error) {
+	var withdrawals []models.WithdrawalRequest
+	if err := db.Where("created_at >= ? AND created_at < ?", from, to).
+		Where("beneficiary_type <> '' OR amount >= ?", p.Threshold()).
+		Order("created_at, id").
+		Find(&withdrawals).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, 0, len(withdrawals))
+	for _, wr := range withdrawals {
+		userIDs = append(userIDs, wr.UserID)
+	}
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	usernames := make(map[int64]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	records := make([]Record, 0, len(withdrawals))
+	for _, wr := range withdrawals {
+		records = append(records, Record{
+			WithdrawalID:        wr.ID,
+			CreatedAt:           wr.CreatedAt,
+			UserID:              wr.UserID,
+			Username:            usernames[wr.UserID],
+			Status:              wr.Status,
+			ChainName:           wr.ChainName,
+			TokenSymbol:         wr.TokenSymbol,
+			Amount:              wr.Amount,
+			ToAddress:           wr.ToAddress,
+			BeneficiaryType:     wr.BeneficiaryType,
+			BeneficiaryName:     wr.BeneficiaryName,
+			BeneficiaryExchange: wr.BeneficiaryExchange,
+			AttestedAt:          wr.BeneficiaryAttestedAt,
+		})
+	}
+	return records, nil
+}
+
+// WriteCSV writes an export as CSV with a header row
+func WriteCSV(w io.Writer, records []Record) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"withdrawal_id", "created_at", "user_id", "username", "status", "chain", "token",
+		"amount_micro", "amount_credits", "to_address", "beneficiary_type", "beneficiary_name", "beneficiary_exchange", "attested_at"})
+	for _, r := range records {
+		attestedAt := ""
+		if r.AttestedAt != nil {
+			attestedAt = r.AttestedAt.UTC().Format(time.RFC3339)
+		}
+		out.Write([]string{
+			strconv.FormatUint(uint64(r.WithdrawalID), 10),
+			r.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(r.UserID, 10),
+			r.Username,
+			r.Status,
+			r.ChainName,
+			r.TokenSymbol,
+			strconv.FormatInt(r.Amount, 10),
+			models.FormatMicroCredits(r.Amount),
+			r.ToAddress,
+			r.BeneficiaryType,
+			r.BeneficiaryName,
+			r.BeneficiaryExchange,
+			attestedAt,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,81 @@
+package travelrule
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestCheckRequiresBeneficiaryAtThreshold(t *testing.T) {
+	policy := NewPolicy(Config{ThresholdMicro: 1000 * models.MicroCreditsPerCredit})
+	large := 1000 * models.MicroCreditsPerCredit
+
+	if _, err := policy.Check(large, nil); !errors.Is(err, ErrRequired) {
+		t.Fatalf("missing beneficiary err = %v", err)
+	}
+	if b, err := policy.Check(large-1, nil); err != nil || b != nil {
+		t.Fatalf("below threshold = %+v, %v", b, err)
+	}
+	if _, err := policy.Check(large, &Beneficiary{Type: "exchange", Name: "Alice"}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("exchange without its name err = %v", err)
+	}
+	b, err := policy.Check(large, &Beneficiary{Type: " self_custody ", Name: " Alice ", Exchange: "Kraken"})
+	if err != nil || b.Type != models.BeneficiarySelfCustody || b.Name != "Alice" || b.Exchange != "" {
+		t.Fatalf("self-custody = %+v, %v", b, err)
+	}
+	if b, err := (*Policy)(nil).Check(large, nil); err != nil || b != nil {
+		t.Fatalf("nil policy = %+v, %v", b, err)
+	}
+}
+
+func TestExportIncludesAttestedAndUnattestedLargeWithdrawals(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	user := modelstesting.GenerateUser("alice", 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	policy := NewPolicy(Config{ThresholdMicro: 1000 * models.MicroCreditsPerCredit})
+	now := time.Now().UTC()
+
+	attested := models.WithdrawalRequest{UserID: user.ID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+		Amount: 2000 * models.MicroCreditsPerCredit, ToAddress: "0xabc", Status: models.TxStatusPending}
+	(&Beneficiary{Type: models.BeneficiaryExchange, Name: "Alice", Exchange: "Kraken"}).Apply(&attested, now)
+	unattested := attested
+	unattested.BeneficiaryType, unattested.BeneficiaryName, unattested.BeneficiaryExchange, unattested.BeneficiaryAttestedAt = "", "", "", nil
+	small := unattested
+	small.Amount = 10 * models.MicroCreditsPerCredit
+	for _, wr := range []*models.WithdrawalRequest{&attested, &unattested, &small} {
+		if err := db.Create(wr).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+
+	from, to, err := ParseRange("", "", now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("range: %v", err)
+	}
+	records, err := policy.Export(db, from, to)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("export = %+v, %v", records, err)
+	}
+	if records[0].Username != "alice" || records[0].BeneficiaryExchange != "Kraken" || records[1].BeneficiaryType != "" {
+		t.Fatalf("records = %+v", records)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.Contains(lines[1], "EXCHANGE,Alice,Kraken") {
+		t.Fatalf("csv = %q", buf.String())
+	}
+
+	if _, _, err := ParseRange("2026-06-10", "2026-06-01", now); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("reversed range err = %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+// Package treasury manages platform-owned wallets. Hot wallets pay out
+// withdrawals; admins sweep surplus from them to cold storage, and hot
+// balances above a configured ceiling raise an alert.
+package treasury
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/notify"
+
+	"gorm.io/gorm"
+)
+
+// defaultAlertCooldown is how long a hot wallet stays quiet after a ceiling alert
+const defaultAlertCooldown = 6 * time.Hour
+
+// Audit actions
+const (
+	ActionWalletAdded     = "TREASURY_WALLET_ADDED"
+	ActionWalletUpdated   = "TREASURY_WALLET_UPDATED"
+	ActionColdTransfer    = "TREASURY_COLD_TRANSFER_INITIATED"
+	ActionCeilingExceeded = "TREASURY_HOT_CEILING_EXCEEDED"
+)
+
+const (
+	auditTargetWallet   = "treasury_wallet"
+	auditTargetTransfer = "treasury_transfer"
+)
+
+var (
+	ErrInvalidWallet       = errors.New("wallet needs a name, a valid chain and address, and a DFNS wallet ID if hot")
+	ErrWalletNotFound      = errors.New("treasury wallet not found")
+	ErrNoHotWallet         = errors.New("no active hot wallet for this chain")
+	ErrInvalidTransfer     = errors.New("transfers go from an active hot wallet to an active cold wallet on the same chain")
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrTokenUnavailable    = errors.New("token not available on this chain")
+	ErrProviderUnavailable = errors.New("wallet provider unavailable")
+	ErrTransferFailed      = errors.New("failed to initiate blockchain transfer")
+)
+
+// Provider moves funds and reads balances for DFNS wallets. *dfns.Client satisfies it.
+type Provider interface {
+	InitiateTransfer(ctx context.Context, walletID string, req dfns.TransferRequest) (*dfns.TransferResponse, error)
+	GetWalletBalance(ctx context.Context, walletID string) (*dfns.WalletBalanceResponse, error)
+}
+
+// Providers returns the provider for a DFNS org, or nil if it is unavailable
+type Providers func(org string) Provider
+
+// OrgProviders serves providers from the configured DFNS organizations
+func OrgProviders(orgs *dfns.Orgs) Providers {
+	return func(org string) Provider {
+		if client := orgs.Client(org); client != nil {
+			return client
+		}
+		return nil
+	}
+}
+
+// Config holds treasury settings
+type Config struct {
+	AlertCooldown time.Duration // Minimum time between ceiling alerts for one wallet
+}
+
+// LoadConfigFromEnv reads TREASURY_ALERT_COOLDOWN
+func LoadConfigFromEnv() Config {
+	config := Config{AlertCooldown: defaultAlertCooldown}
+	if d, err := time.ParseDuration(os.Getenv("TREASURY_ALERT_COOLDOWN")); err == nil && d > 0 {
+		config.AlertCooldown = d
+	}
+	return config
+}
+
+// Service manages treasury wallets and transfers between them
+type Service struct {
+	db        *gorm.DB
+	providers Providers
+	config    Config
+	clock     clock.Clock
+}
+
+// NewService creates a treasury service
+func NewService(db *gorm.DB, providers Providers, config Config, c clock.Clock) *Service {
+	return &Service{db: db, providers: providers, config: config, clock: c}
+}
+
+// HotWallet returns the active hot wallet for a chain, for paying out withdrawals
+func HotWallet(db *gorm.DB, chainName string) (*models.TreasuryWallet, error) {
+	var wallet models.TreasuryWallet
+	err := db.Where("kind = ? AND chain_name = ? AND is_active = ?", models.TreasuryWalletHot, chainName, true).
+		Order("id").First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoHotWallet
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Wallets returns every treasury wallet, hot first
+func (s *Service) Wallets() ([]models.TreasuryWallet, error) {
+	var wallets []models.TreasuryWallet
+	err := s.db.Order("kind DESC, chain_name, id").Find(&wallets).Error
+	return wallets, err
+}
+
+// AddWallet registers a hot or cold treasury wallet
+func (s *Service) AddWallet(wallet models.TreasuryWallet, actor string) (*models.TreasuryWallet, error) {
+	wallet.Name = strings.TrimSpace(wallet.Name)
+	if wallet.Name == "" || !dfns.IsValidChainName(wallet.ChainName) || !dfns.IsValidAddress(wallet.Address, wallet.ChainName) ||
+		wallet.BalanceCeiling < 0 {
+		return nil, ErrInvalidWallet
+	}
+	switch wallet.Kind {
+	case models.TreasuryWalletHot:
+		if wallet.DfnsWalletID == "" {
+			return nil, ErrInvalidWallet
+		}
+	case models.TreasuryWalletCold:
+		// Cold storage is not signed for by the platform
+		wallet.DfnsOrg, wallet.DfnsWalletID, wallet.BalanceCeiling = "", "", 0
+	default:
+		return nil, ErrInvalidWallet
+	}
+	wallet.ID = 0
+	wallet.ChainID = models.GetChainID(wallet.ChainName)
+	wallet.IsActive = true
+	wallet.CreatedBy = actor
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&wallet).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionWalletAdded,
+			TargetType: auditTargetWallet,
+			TargetID:   wallet.ID,
+			Details:    fmt.Sprintf("%s wallet %q on %s at %s", wallet.Kind, wallet.Name, wallet.ChainName, wallet.Address),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// UpdateWallet changes a wallet's hot balance ceiling and whether it is active
+func (s *Service) UpdateWallet(id uint, ceiling int64, active bool, actor string) (*models.TreasuryWallet, error) {
+	if ceiling < 0 {
+		return nil, ErrInvalidWallet
+	}
+	var wallet models.TreasuryWallet
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&wallet, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWalletNotFound
+			}
+			return err
+		}
+		if wallet.Kind != models.TreasuryWalletHot {
+			ceiling = 0
+		}
+		details := fmt.Sprintf("ceiling=%s->%s active=%t->%t",
+			models.FormatMicroCredits(wallet.BalanceCeiling), models.FormatMicroCredits(ceiling), wallet.IsActive, active)
+		if err := tx.Model(&wallet).Updates(map[string]interface{}{"balance_ceiling": ceiling, "is_active": active}).Error; err != nil {
+			return err
+		}
+		wallet.BalanceCeiling, wallet.IsActive = ceiling, active
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionWalletUpdated,
+			TargetType: auditTargetWallet,
+			TargetID:   wallet.ID,
+			Details:    details,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Transfers returns the most recent treasury transfers
+func (s *Service) Transfers(limit int) ([]models.TreasuryTransfer, error) {
+	var transfers []models.TreasuryTransfer
+	err := s.db.Order("id DESC").Limit(limit).Find(&transfers).Error
+	return transfers, err
+}
+
+// MoveInput describes a sweep from a hot wallet to cold storage
+type MoveInput struct {
+	FromWalletID uint
+	ToWalletID   uint
+	TokenSymbol  string
+	Amount       int64 // Micro-credits
+	Note         string
+	InitiatedBy  string // Admin username
+}
+
+// MoveToCold starts a DFNS transfer from a hot wallet to a cold wallet. The
+// transfer is recorded before DFNS is called so every attempt leaves a record;
+// it completes or fails when the DFNS webhook arrives.
+func (s *Service) MoveToCold(ctx context.Context, in MoveInput) (*models.TreasuryTransfer, error) {
+	if in.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	var from, to models.TreasuryWallet
+	if err := s.db.First(&from, in.FromWalletID).Error; err != nil {
+		return nil, ErrWalletNotFound
+	}
+	if err := s.db.First(&to, in.ToWalletID).Error; err != nil {
+		return nil, ErrWalletNotFound
+	}
+	if from.Kind != models.TreasuryWalletHot || to.Kind != models.TreasuryWalletCold ||
+		!from.IsActive || !to.IsActive || from.ChainName != to.ChainName {
+		return nil, ErrInvalidTransfer
+	}
+
+	token, err := chains.ActiveToken(s.db, from.ChainName, in.TokenSymbol)
+	if err != nil {
+		return nil, ErrTokenUnavailable
+	}
+
+	transfer := models.TreasuryTransfer{
+		FromWalletID: from.ID,
+		ToWalletID:   to.ID,
+		ChainName:    from.ChainName,
+		TokenSymbol:  in.TokenSymbol,
+		TokenAddress: token.ContractAddress,
+		Amount:       in.Amount,
+		TokenAmount:  dfns.MicroCreditsToTokenAmount(in.Amount, token.Decimals),
+		FromAddress:  from.Address,
+		ToAddress:    to.Address,
+		Status:       models.TreasuryTransferPending,
+		InitiatedBy:  in.InitiatedBy,
+		Note:         in.Note,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      in.InitiatedBy,
+			Action:     ActionColdTransfer,
+			TargetType: auditTargetTransfer,
+			TargetID:   transfer.ID,
+			Details: fmt.Sprintf("%s %s from %q to %q on %s: %s", models.FormatMicroCredits(in.Amount), in.TokenSymbol,
+				from.Name, to.Name, from.ChainName, in.Note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider := s.providers(from.DfnsOrg)
+	if provider == nil {
+		return &transfer, s.fail(&transfer, ErrProviderUnavailable)
+	}
+	// DFNS may accept the transfer even if the admin's request goes away, so
+	// only the client's per-call timeout may cut the call short
+	resp, err := provider.InitiateTransfer(context.WithoutCancel(ctx), from.DfnsWalletID,
+		dfns.NewTokenTransfer(from.ChainName, to.Address, token.ContractAddress, transfer.TokenAmount, ""))
+	if err != nil {
+		log.Printf("Treasury: Failed to initiate cold transfer %d: %v", transfer.ID, err)
+		return &transfer, s.fail(&transfer, ErrTransferFailed)
+	}
+
+	transfer.Status = models.TreasuryTransferSubmitted
+	transfer.DfnsTransferID = resp.ID
+	if err := s.db.Save(&transfer).Error; err != nil {
+		return &transfer, err
+	}
+	return &transfer, nil
+}
+
+// fail marks a transfer FAILED and returns reason
+func (s *Service) fail(transfer *models.TreasuryTransfer, reason error) error {
+	now := s.clock.Now()
+	transfer.Status = models.TreasuryTransferFailed
+	transfer.ErrorMessage = reason.Error()
+	transfer.CompletedAt = &now
+	if err := s.db.Save(transfer).Error; err != nil {
+		return err
+	}
+	return reason
+}
+
+// CompleteTransfer settles the treasury transfer for a DFNS transfer ID and
+// reports whether one was found
+func CompleteTransfer(db *gorm.DB, dfnsTransferID, txHash string, now time.Time) (bool, error) {
+	return settle(db, dfnsTransferID, map[string]interface{}{
+		"status": models.TreasuryTransferCompleted, "tx_hash": txHash, "completed_at": now,
+	})
+}
+
+// FailTransfer marks the treasury transfer for a DFNS transfer ID failed and
+// reports whether one was found
+func FailTransfer(db *gorm.DB, dfnsTransferID, reason string, now time.Time) (bool, error) {
+	return settle(db, dfnsTransferID, map[string]interface{}{
+		"status": models.TreasuryTransferFailed, "error_message": reason, "completed_at": now,
+	})
+}
+
+func settle(db *gorm.DB, dfnsTransferID string, updates map[string]interface{}) (bool, error) {
+	if dfnsTransferID == "" {
+		return false, nil
+	}
+	var transfer models.TreasuryTransfer
+	err := db.Where("dfns_transfer_id = ?", dfnsTransferID).First(&transfer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if transfer.Status != models.TreasuryTransferSubmitted {
+		return true, nil
+	}
+	return true, db.Model(&transfer).Updates(updates).Error
+}
+
+// CheckCeilings refreshes hot wallet balances and alerts admins about any
+// above their ceiling. It returns how many alerts were raised.
+func (s *Service) CheckCeilings(ctx context.Context) (int, error) {
+	var wallets []models.TreasuryWallet
+	if err := s.db.Where("kind = ? AND is_active = ?", models.TreasuryWalletHot, true).Find(&wallets).Error; err != nil {
+		return 0, err
+	}
+
+	alerts := 0
+	for i := range wallets {
+		wallet := &wallets[i]
+		balance, err := s.balance(ctx, wallet)
+		if err != nil {
+			log.Printf("Treasury: Failed to read balance of hot wallet %d: %v", wallet.ID, err)
+			continue
+		}
+		now := s.clock.Now()
+		updates := map[string]interface{}{"last_balance": balance, "last_checked_at": now}
+
+		exceeded := wallet.BalanceCeiling > 0 && balance > wallet.BalanceCeiling
+		coolingDown := wallet.LastAlertAt != nil && clock.Since(s.clock, *wallet.LastAlertAt) < s.config.AlertCooldown
+		if exceeded && !coolingDown {
+			if err := s.alert(wallet, balance); err != nil {
+				return alerts, err
+			}
+			updates["last_alert_at"] = now
+			alerts++
+		}
+		if err := s.db.Model(wallet).Updates(updates).Error; err != nil {
+			return alerts, err
+		}
+	}
+	return alerts, nil
+}
+
+// balance returns a hot wallet's holdings of the tokens accepted on its chain, in micro-credits
+func (s *Service) balance(ctx context.Context, wallet *models.TreasuryWallet) (int64, error) {
+	provider := s.providers(wallet.DfnsOrg)
+	if provider == nil {
+		return 0, ErrProviderUnavailable
+	}
+	assets, err := provider.GetWalletBalance(ctx, wallet.DfnsWalletID)
+	if err != nil {
+		return 0, err
+	}
+	var chain models.SupportedChain
+	if err := s.db.Where("name = ?", wallet.ChainName).First(&chain).Error; err != nil {
+		return 0, err
+	}
+	tokens, err := chains.Tokens(s.db, chain.ID)
+	if err != nil {
+		return 0, err
+	}
+	accepted := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		accepted[token.Token.Symbol] = true
+	}
+	var total int64
+	for _, asset := range assets.Items {
+		if !accepted[asset.Symbol] {
+			continue
+		}
+		balance, err := dfns.ConvertToMicroCredits(asset.Balance, asset.Decimals)
+		if err != nil && !errors.Is(err, dfns.ErrTokenAmountNotPositive) {
+			return 0, fmt.Errorf("%s balance: %w", asset.Symbol, err)
+		}
+		total += balance
+	}
+	return total, nil
+}
+
+func (s *Service) alert(wallet *models.TreasuryWallet, balance int64) error {
+	excess := balance - wallet.BalanceCeiling
+	message := fmt.Sprintf("Hot wallet %q on %s holds %s, above its ceiling of %s. Consider moving %s to cold storage.",
+		wallet.Name, wallet.ChainName, models.FormatMicroCredits(balance),
+		models.FormatMicroCredits(wallet.BalanceCeiling), models.FormatMicroCredits(excess))
+	log.Printf("Treasury: %s", message)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := notify.Admins(tx, notify.TypeTreasuryAlert, "Hot wallet above ceiling", message); err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      "system",
+			Action:     ActionCeilingExceeded,
+			TargetType: auditTargetWallet,
+			TargetID:   wallet.ID,
+			Details:    message,
+		})
+	})
+}
+
+// Run checks hot wallet ceilings every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.CheckCeilings(context.Background()); err != nil {
+			log.Printf("Treasury: Ceiling check failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package treasury
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+)
+
+const (
+	hotAddress  = "0x1111111111111111111111111111111111111111"
+	coldAddress = "0x2222222222222222222222222222222222222222"
+)
+
+type fakeProvider struct {
+	balance   string
+	transfers []dfns.TransferRequest
+	err       error
+}
+
+func (f *fakeProvider) InitiateTransfer(_ context.Context, walletID string, req dfns.TransferRequest) (*dfns.TransferResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.transfers = append(f.transfers, req)
+	return &dfns.TransferResponse{ID: "xfer-1", WalletID: walletID, Status: "Pending"}, nil
+}
+
+func (f *fakeProvider) GetWalletBalance(context.Context, string) (*dfns.WalletBalanceResponse, error) {
+	return &dfns.WalletBalanceResponse{Items: []dfns.WalletAsset{
+		{Symbol: "USDC", Balance: f.balance, Decimals: 6},
+		{Symbol: "ETH", Balance: "5000000000000000000", Decimals: 18},
+	}}, nil
+}
+
+func newTestService(t *testing.T) (*Service, *gorm.DB, *fakeProvider, *clock.Fake) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	provider := &fakeProvider{}
+	fake := clock.NewFake(time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, func(string) Provider { return provider }, Config{AlertCooldown: 6 * time.Hour}, fake)
+	return svc, db, provider, fake
+}
+
+func addWallets(t *testing.T, svc *Service, ceiling int64) (*models.TreasuryWallet, *models.TreasuryWallet) {
+	t.Helper()
+	hot, err := svc.AddWallet(models.TreasuryWallet{Name: "eth hot", Kind: models.TreasuryWalletHot, ChainName: "ethereum",
+		Address: hotAddress, DfnsWalletID: "wa-hot", BalanceCeiling: ceiling}, "admin")
+	if err != nil {
+		t.Fatalf("add hot wallet: %v", err)
+	}
+	cold, err := svc.AddWallet(models.TreasuryWallet{Name: "eth cold", Kind: models.TreasuryWalletCold, ChainName: "ethereum",
+		Address: coldAddress}, "admin")
+	if err != nil {
+		t.Fatalf("add cold wallet: %v", err)
+	}
+	return hot, cold
+}
+
+func TestMoveToColdRecordsAndSettlesTransfer(t *testing.T) {
+	svc, db, provider, fake := newTestService(t)
+	hot, cold := addWallets(t, svc, 0)
+
+	transfer, err := svc.MoveToCold(context.Background(), MoveInput{FromWalletID: hot.ID, ToWalletID: cold.ID, TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(2500), Note: "weekly sweep", InitiatedBy: "admin"})
+	if err != nil {
+		t.Fatalf("move to cold: %v", err)
+	}
+	if transfer.Status != models.TreasuryTransferSubmitted || transfer.DfnsTransferID != "xfer-1" {
+		t.Fatalf("unexpected transfer: %+v", transfer)
+	}
+	if len(provider.transfers) != 1 || provider.transfers[0].To != coldAddress || provider.transfers[0].Amount != "2500000000" {
+		t.Fatalf("unexpected DFNS transfer: %+v", provider.transfers)
+	}
+
+	found, err := CompleteTransfer(db, "xfer-1", "0xhash", fake.Now())
+	if !found || err != nil {
+		t.Fatalf("complete transfer: found=%v err=%v", found, err)
+	}
+	var stored models.TreasuryTransfer
+	db.First(&stored, transfer.ID)
+	if stored.Status != models.TreasuryTransferCompleted || stored.TxHash != "0xhash" {
+		t.Errorf("transfer not completed: %+v", stored)
+	}
+	if found, _ := CompleteTransfer(db, "user-withdrawal", "0x", fake.Now()); found {
+		t.Errorf("unrelated DFNS transfer matched a treasury transfer")
+	}
+}
+
+func TestMoveToColdValidatesDirection(t *testing.T) {
+	svc, db, provider, _ := newTestService(t)
+	hot, cold := addWallets(t, svc, 0)
+
+	if _, err := svc.MoveToCold(context.Background(), MoveInput{FromWalletID: cold.ID, ToWalletID: hot.ID, TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(1), InitiatedBy: "admin"}); !errors.Is(err, ErrInvalidTransfer) {
+		t.Errorf("expected ErrInvalidTransfer, got %v", err)
+	}
+
+	// A rejected DFNS call still leaves a failed record
+	provider.err = errors.New("boom")
+	transfer, err := svc.MoveToCold(context.Background(), MoveInput{FromWalletID: hot.ID, ToWalletID: cold.ID, TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(1), InitiatedBy: "admin"})
+	if !errors.Is(err, ErrTransferFailed) {
+		t.Fatalf("expected ErrTransferFailed, got %v", err)
+	}
+	var stored models.TreasuryTransfer
+	db.First(&stored, transfer.ID)
+	if stored.Status != models.TreasuryTransferFailed {
+		t.Errorf("expected FAILED record, got %s", stored.Status)
+	}
+}
+
+func TestCheckCeilingsAlertsAdminsWithCooldown(t *testing.T) {
+	svc, db, provider, fake := newTestService(t)
+	addWallets(t, svc, models.CreditsToMicro(10000))
+	admin := modelstesting.GenerateUser("treasuryadmin", 0)
+	admin.UserType = "ADMIN"
+	db.Create(&admin)
+
+	provider.balance = "9000000000" // 9,000 USDC
+	if alerts, err := svc.CheckCeilings(context.Background()); err != nil || alerts != 0 {
+		t.Fatalf("expected no alert below ceiling, got %d (%v)", alerts, err)
+	}
+
+	provider.balance = "12000000000"
+	if alerts, _ := svc.CheckCeilings(context.Background()); alerts != 1 {
+		t.Fatalf("expected one alert, got %d", alerts)
+	}
+	if alerts, _ := svc.CheckCeilings(context.Background()); alerts != 0 {
+		t.Fatalf("expected cooldown to suppress repeat alert, got %d", alerts)
+	}
+	fake.Advance(7 * time.Hour)
+	if alerts, _ := svc.CheckCeilings(context.Background()); alerts != 1 {
+		t.Fatalf("expected alert after cooldown, got %d", alerts)
+	}
+
+	var notifications int64
+	db.Model(&models.Notification{}).Where("user_id = ?", admin.ID).Count(&notifications)
+	if notifications != 2 {
+		t.Errorf("expected 2 admin notifications, got %d", notifications)
+	}
+	hot, _ := HotWallet(db, "ethereum")
+	if hot.LastBalance != models.CreditsToMicro(12000) {
+		t.Errorf("last balance = %d", hot.LastBalance)
+	}
+}
@@ -0,0 +1,85 @@
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults, which every authenticator app supports)
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // Steps either side of now accepted for clock drift
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random 160-bit base32 TOTP secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(buf), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI authenticator apps read from a QR code
+func ProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Code returns the TOTP code for secret at time t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return codeAt(key, step(t)), nil
+}
+
+// validate checks code against secret at t, allowing totpSkew steps of drift.
+// It returns the matching time step, which must be later than lastStep.
+func validate(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	now := step(t)
+	for s := now - totpSkew; s <= now+totpSkew; s++ {
+		if s <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(codeAt(key, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+func codeAt(key []byte, s int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(s))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
@@ -0,0 +1,296 @@
+// Package twofactor provides a second factor for sensitive actions such as
+// withdrawals: a TOTP code from an authenticator app the user has enrolled,
+// or a one-time code sent to their email address.
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/mailer"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWithdrawalThreshold = 1000 * models.MicroCreditsPerCredit
+	defaultEmailTokenTTL       = 10 * time.Minute
+	defaultIssuer              = "SocialPredict"
+	emailTokenDigits           = 8
+)
+
+var (
+	ErrRequired       = errors.New("a two-factor code or email confirmation code is required")
+	ErrInvalidCode    = errors.New("invalid or expired two-factor code")
+	ErrInvalidToken   = errors.New("invalid or expired email confirmation code")
+	ErrNotEnrolled    = errors.New("two-factor authentication is not set up")
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrUnknownPurpose = errors.New("unknown confirmation purpose")
+	ErrEmailNotSent   = errors.New("failed to send confirmation email")
+)
+
+// Purposes are the actions an email token can confirm, with the subject line of its email
+var Purposes = map[string]string{
+	models.EmailTokenPurposeWithdrawal:       "Confirm your withdrawal",
+	models.EmailTokenPurposeSecuritySettings: "Confirm your security settings change",
+}
+
+// Config holds second-factor settings
+type Config struct {
+	WithdrawalThresholdMicro int64         // Withdrawals of at least this many micro-credits need a second factor
+	EmailTokenTTL            time.Duration // How long an emailed code stays valid
+	Issuer                   string        // Name shown in authenticator apps
+}
+
+// LoadConfigFromEnv reads WITHDRAWAL_2FA_THRESHOLD (credits; 0 requires a
+// second factor for every withdrawal), EMAIL_TOKEN_TTL and TOTP_ISSUER
+func LoadConfigFromEnv() Config {
+	config := Config{
+		WithdrawalThresholdMicro: defaultWithdrawalThreshold,
+		EmailTokenTTL:            defaultEmailTokenTTL,
+		Issuer:                   defaultIssuer,
+	}
+	if v := os.Getenv("WITHDRAWAL_2FA_THRESHOLD"); v != "" {
+		if micro, err := models.ParseCredits(v); err == nil && micro >= 0 {
+			config.WithdrawalThresholdMicro = micro
+		}
+	}
+	if d, err := time.ParseDuration(os.Getenv("EMAIL_TOKEN_TTL")); err == nil && d > 0 {
+		config.EmailTokenTTL = d
+	}
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		config.Issuer = v
+	}
+	return config
+}
+
+// Enrollment is a new TOTP secret for the user to add to their authenticator app
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUrl"`
+}
+
+// Service manages TOTP enrollment and checks second factors
+type Service struct {
+	db     *gorm.DB
+	mail   mailer.Mailer
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a two-factor service
+func NewService(db *gorm.DB, mail mailer.Mailer, config Config, c clock.Clock) *Service {
+	return &Service{db: db, mail: mail, config: config, clock: c}
+}
+
+// WithdrawalThreshold returns the smallest withdrawal, in micro-credits, that needs a second factor
+func (s *Service) WithdrawalThreshold() int64 {
+	return s.config.WithdrawalThresholdMicro
+}
+
+// RequiredForWithdrawal reports whether a withdrawal of amountMicro needs a
+// second factor. A nil Service requires none.
+func (s *Service) RequiredForWithdrawal(amountMicro int64) bool {
+	return s != nil && amountMicro >= s.config.WithdrawalThresholdMicro
+}
+
+// Enabled reports whether the user has confirmed a TOTP enrollment
+func (s *Service) Enabled(userID int64) (bool, error) {
+	var tf models.UserTwoFactor
+	err := s.db.Where("user_id = ?", userID).First(&tf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled, nil
+}
+
+// Enroll creates a new TOTP secret for user, replacing any unconfirmed one.
+// It takes effect once confirmed with Activate.
+func (s *Service) Enroll(user *models.User) (*Enrollment, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var tf models.UserTwoFactor
+		err := tx.Where("user_id = ?", user.ID).First(&tf).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.UserTwoFactor{UserID: user.ID, Secret: secret}).Error
+		}
+		if err != nil {
+			return err
+		}
+		if tf.Enabled {
+			return ErrAlreadyEnabled
+		}
+		return tx.Model(&tf).Updates(map[string]interface{}{"secret": secret, "last_used_step": 0}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Enrollment{Secret: secret, URI: ProvisioningURI(s.config.Issuer, user.Username, secret)}, nil
+}
+
+// Activate enables TOTP once the user proves their app produces valid codes
+func (s *Service) Activate(userID int64, code string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		tf, err := s.checkTOTP(tx, userID, code)
+		if err != nil {
+			return err
+		}
+		if tf.Enabled {
+			return ErrAlreadyEnabled
+		}
+		now := s.clock.Now()
+		return tx.Model(tf).Updates(map[string]interface{}{"enabled": true, "enabled_at": now}).Error
+	})
+}
+
+// Disable removes TOTP after checking a current code
+func (s *Service) Disable(userID int64, code string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		tf, err := s.checkTOTP(tx, userID, code)
+		if err != nil {
+			return err
+		}
+		if !tf.Enabled {
+			return ErrNotEnrolled
+		}
+		return tx.Delete(tf).Error
+	})
+}
+
+// SendEmailToken emails user a one-time code for purpose and returns when it expires
+func (s *Service) SendEmailToken(user *models.User, purpose string) (time.Time, error) {
+	subject, ok := Purposes[purpose]
+	if !ok {
+		return time.Time{}, ErrUnknownPurpose
+	}
+	code, err := randomDigits(emailTokenDigits)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiresAt := s.clock.Now().Add(s.config.EmailTokenTTL)
+	token := models.EmailToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(user.ID, code),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.Create(&token).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	body := fmt.Sprintf("Your confirmation code is %s. It expires in %s.\n\nIf you did not request this, change your password now.", code, s.config.EmailTokenTTL)
+	if err := s.mail.Send(user.Email, subject, body); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrEmailNotSent, err)
+	}
+	return expiresAt, nil
+}
+
+// Verify checks a second factor for purpose: a TOTP code if the user has
+// enabled TOTP, or else an emailed code. Either is consumed, so it cannot be
+// used again.
+func (s *Service) Verify(user *models.User, purpose, code, emailToken string) error {
+	switch {
+	case code != "":
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			tf, err := s.checkTOTP(tx, user.ID, code)
+			if err != nil {
+				return err
+			}
+			if !tf.Enabled {
+				return ErrNotEnrolled
+			}
+			return nil
+		})
+	case emailToken != "":
+		return s.consumeEmailToken(user.ID, purpose, emailToken)
+	default:
+		return ErrRequired
+	}
+}
+
+// checkTOTP validates code against the user's secret and records its time
+// step so the same code is not accepted twice
+func (s *Service) checkTOTP(tx *gorm.DB, userID int64, code string) (*models.UserTwoFactor, error) {
+	var tf models.UserTwoFactor
+	err := tx.Where("user_id = ?", userID).First(&tf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matched, ok := validate(tf.Secret, code, s.clock.Now(), tf.LastUsedStep)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	// Guard against a concurrent request accepting the same step
+	result := tx.Model(&models.UserTwoFactor{}).
+		Where("id = ? AND last_used_step < ?", tf.ID, matched).
+		Update("last_used_step", matched)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidCode
+	}
+	tf.LastUsedStep = matched
+	return &tf, nil
+}
+
+func (s *Service) consumeEmailToken(userID int64, purpose, code string) error {
+	now := s.clock.Now()
+	var tokens []models.EmailToken
+	if err := s.db.Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userID, purpose, now).
+		Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	want := hashToken(userID, code)
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(want)) != 1 {
+			continue
+		}
+		result := s.db.Model(&models.EmailToken{}).Where("id = ? AND used_at IS NULL", t.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+	return ErrInvalidToken
+}
+
+// hashToken binds an emailed code to its user, since short codes repeat across users
+func hashToken(userID int64, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", userID, code)))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomDigits(n int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v.Int64()), nil
+}
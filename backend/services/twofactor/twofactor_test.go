@@ -0,0 +1,133 @@
+package twofactor
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+type fakeMailer struct {
+	to, subject, body string
+}
+
+func (f *fakeMailer) Send(to, subject, body string) error {
+	f.to, f.subject, f.body = to, subject, body
+	return nil
+}
+
+func TestCodeMatchesRFC6238Vector(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, secret "12345678901234567890", truncated to 6 digits
+	secret := secretEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 2000000000: "279037"} {
+		got, err := Code(secret, time.Unix(unix, 0))
+		if err != nil || got != want {
+			t.Errorf("Code at %d = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestEnrollActivateAndVerify(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Date(2026, 3, 25, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	svc := NewService(db, &fakeMailer{}, Config{WithdrawalThresholdMicro: 100 * models.MicroCreditsPerCredit, Issuer: "Test"}, fake)
+
+	user := modelstesting.GenerateUser("alice", 0)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	enrollment, err := svc.Enroll(&user)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if enabled, _ := svc.Enabled(user.ID); enabled {
+		t.Fatal("enabled before activation")
+	}
+	code, _ := Code(enrollment.Secret, now)
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, code, ""); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Verify before activation = %v, want ErrNotEnrolled", err)
+	}
+	if err := svc.Activate(user.ID, "000000"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Activate with wrong code = %v, want ErrInvalidCode", err)
+	}
+	if err := svc.Activate(user.ID, code); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if _, err := svc.Enroll(&user); !errors.Is(err, ErrAlreadyEnabled) {
+		t.Errorf("Enroll when enabled = %v, want ErrAlreadyEnabled", err)
+	}
+
+	// The code used to activate cannot be replayed
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, code, ""); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("replayed code = %v, want ErrInvalidCode", err)
+	}
+	fake.Advance(30 * time.Second)
+	next, _ := Code(enrollment.Secret, fake.Now())
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, next, ""); err != nil {
+		t.Errorf("Verify with next code: %v", err)
+	}
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, "", ""); !errors.Is(err, ErrRequired) {
+		t.Errorf("Verify without a factor = %v, want ErrRequired", err)
+	}
+
+	if !svc.RequiredForWithdrawal(100*models.MicroCreditsPerCredit) || svc.RequiredForWithdrawal(99*models.MicroCreditsPerCredit) {
+		t.Error("threshold not applied")
+	}
+	var none *Service
+	if none.RequiredForWithdrawal(1 << 40) {
+		t.Error("nil service should require nothing")
+	}
+}
+
+func TestEmailTokenIsSingleUseAndExpires(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Date(2026, 3, 25, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	mail := &fakeMailer{}
+	svc := NewService(db, mail, Config{EmailTokenTTL: 10 * time.Minute}, fake)
+
+	user := modelstesting.GenerateUser("bob", 0)
+	other := modelstesting.GenerateUser("carol", 0)
+	for _, u := range []*models.User{&user, &other} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	if _, err := svc.SendEmailToken(&user, "NOPE"); !errors.Is(err, ErrUnknownPurpose) {
+		t.Errorf("unknown purpose = %v", err)
+	}
+	if _, err := svc.SendEmailToken(&user, models.EmailTokenPurposeWithdrawal); err != nil {
+		t.Fatalf("SendEmailToken: %v", err)
+	}
+	if mail.to != user.Email {
+		t.Errorf("mailed %q, want %q", mail.to, user.Email)
+	}
+	code := regexp.MustCompile(`\d{8}`).FindString(mail.body)
+	if code == "" {
+		t.Fatalf("no code in email body %q", mail.body)
+	}
+
+	if err := svc.Verify(&other, models.EmailTokenPurposeWithdrawal, "", code); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("another user's code = %v, want ErrInvalidToken", err)
+	}
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, "", code); err != nil {
+		t.Fatalf("Verify email token: %v", err)
+	}
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, "", code); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reused code = %v, want ErrInvalidToken", err)
+	}
+
+	svc.SendEmailToken(&user, models.EmailTokenPurposeWithdrawal)
+	code = regexp.MustCompile(`\d{8}`).FindString(mail.body)
+	fake.Advance(11 * time.Minute)
+	if err := svc.Verify(&user, models.EmailTokenPurposeWithdrawal, "", code); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired code = %v, want ErrInvalidToken", err)
+	}
+}
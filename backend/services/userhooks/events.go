@@ -0,0 +1,303 @@
+package userhooks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sources of account events, each scanned from its own cursor
+const (
+	sourceBets        = "bets"
+	sourceResolutions = "resolutions"
+	sourceDeposits    = "deposits"
+	sourceWithdrawals = "withdrawals"
+)
+
+// scanOverlap is how far before its cursor each scan starts reading again,
+// so rows committed late with an earlier timestamp are not missed. Events
+// are keyed by what they are about, so rereading never queues one twice.
+const scanOverlap = time.Minute
+
+// BetFill is the data of a bet.filled event. Amounts are in credits.
+type BetFill struct {
+	BetID       uint      `json:"betId"`
+	MarketID    uint      `json:"marketId"`
+	Side        string    `json:"side"` // buy or sell
+	Outcome     string    `json:"outcome"`
+	Amount      int64     `json:"amount"`             // Credits bet, or shares sold
+	Proceeds    int64     `json:"proceeds,omitempty"` // Credits received for a sale
+	Probability float64   `json:"probability,omitempty"`
+	PlacedAt    time.Time `json:"placedAt"`
+}
+
+// MarketResolution is the data of a market.resolved event
+type MarketResolution struct {
+	MarketID      int64     `json:"marketId"`
+	QuestionTitle string    `json:"questionTitle"`
+	Resolution    string    `json:"resolution"`
+	ResolvedAt    time.Time `json:"resolvedAt"`
+}
+
+// DepositCredit is the data of a deposit.credited event
+type DepositCredit struct {
+	TransactionID uint    `json:"transactionId"`
+	ChainName     string  `json:"chainName"`
+	TokenSymbol   string  `json:"tokenSymbol"`
+	Amount        string  `json:"amount"`  // Raw token amount
+	Credits       float64 `json:"credits"` // Credits added to the balance
+	TxHash        string  `json:"txHash"`
+}
+
+// WithdrawalStatus is the data of a withdrawal.status_changed event
+type WithdrawalStatus struct {
+	WithdrawalID uint    `json:"withdrawalId"`
+	Status       string  `json:"status"`
+	Credits      float64 `json:"credits"`
+	ChainName    string  `json:"chainName"`
+	TokenSymbol  string  `json:"tokenSymbol"`
+	ToAddress    string  `json:"toAddress"`
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+}
+
+// subscribers returns the active webhooks subscribed to event, by user
+func (s *Service) subscribers(event string) (map[int64][]*models.UserWebhook, error) {
+	var hooks []models.UserWebhook
+	if err := s.db.Where("is_active = ?", true).Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	byUser := make(map[int64][]*models.UserWebhook)
+	for i := range hooks {
+		if subscribed(&hooks[i], event) {
+			byUser[hooks[i].UserID] = append(byUser[hooks[i].UserID], &hooks[i])
+		}
+	}
+	return byUser, nil
+}
+
+// scanSource reads a source from its cursor up to now. The first scan of a
+// source only sets its cursor: events from before scanning began are not sent.
+func (s *Service) scanSource(source string, scan func(since, until time.Time) (int, error)) (int, error) {
+	now := s.clock.Now()
+	var cursor models.UserWebhookCursor
+	err := s.db.Where("source = ?", source).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, s.db.Create(&models.UserWebhookCursor{Source: source, Position: now, CreatedAt: now}).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+	since := cursor.Position.Add(-scanOverlap)
+	if since.Before(cursor.CreatedAt) {
+		since = cursor.CreatedAt
+	}
+	queued, err := scan(since, now)
+	if err != nil {
+		return queued, fmt.Errorf("%s: %w", source, err)
+	}
+	return queued, s.db.Model(&cursor).Update("position", now).Error
+}
+
+// enqueueOnce queues an event for every one of the user's webhooks that has
+// not already had the event with this reference
+func (s *Service) enqueueOnce(hooks []*models.UserWebhook, eventType, reference string, data interface{}) (int, error) {
+	queued := 0
+	for _, hook := range hooks {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			ok, err := s.enqueue(tx, hook, eventType, &reference, data)
+			if ok {
+				queued++
+			}
+			return err
+		})
+		if err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
+
+// ScanEvents queues bet.filled, market.resolved, deposit.credited and
+// withdrawal.status_changed events for what happened to subscribed users'
+// accounts since the last scan
+func (s *Service) ScanEvents() (int, error) {
+	scans := []struct {
+		source string
+		scan   func(since, until time.Time) (int, error)
+	}{
+		{sourceBets, s.scanBets},
+		{sourceResolutions, s.scanResolutions},
+		{sourceDeposits, s.scanDeposits},
+		{sourceWithdrawals, s.scanWithdrawals},
+	}
+	total := 0
+	for _, sc := range scans {
+		queued, err := s.scanSource(sc.source, sc.scan)
+		total += queued
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *Service) scanBets(since, until time.Time) (int, error) {
+	hooks, err := s.subscribers(models.WebhookEventBetFilled)
+	if err != nil || len(hooks) == 0 {
+		return 0, err
+	}
+	var users []models.User
+	if err := s.db.Where("id IN ?", keys(hooks)).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	userIDs := make(map[string]int64, len(users))
+	usernames := make([]string, 0, len(users))
+	for _, u := range users {
+		userIDs[u.Username] = u.ID
+		usernames = append(usernames, u.Username)
+	}
+
+	var bets []models.Bet
+	if err := s.db.Where("username IN ? AND created_at > ? AND created_at <= ?", usernames, since, until).
+		Order("id").Find(&bets).Error; err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, bet := range bets {
+		fill := BetFill{
+			BetID:       bet.ID,
+			MarketID:    bet.MarketID,
+			Side:        "buy",
+			Outcome:     bet.Outcome,
+			Amount:      bet.Amount,
+			Probability: bet.Probability,
+			PlacedAt:    bet.PlacedAt,
+		}
+		if bet.Amount < 0 {
+			fill.Side = "sell"
+			fill.Amount = -bet.Amount
+			fill.Proceeds = bet.Proceeds
+		}
+		n, err := s.enqueueOnce(hooks[userIDs[bet.Username]], models.WebhookEventBetFilled, fmt.Sprintf("bet:%d", bet.ID), fill)
+		queued += n
+		if err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
+
+func (s *Service) scanResolutions(since, until time.Time) (int, error) {
+	hooks, err := s.subscribers(models.WebhookEventMarketResolved)
+	if err != nil || len(hooks) == 0 {
+		return 0, err
+	}
+	var markets []models.Market
+	if err := s.db.Where("is_resolved = ? AND updated_at > ? AND updated_at <= ?", true, since, until).
+		Order("id").Find(&markets).Error; err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, market := range markets {
+		var userIDs []int64
+		if err := s.db.Model(&models.User{}).
+			Where("id IN ? AND username IN (?)", keys(hooks), s.db.Model(&models.Bet{}).Select("username").Where("market_id = ?", market.ID)).
+			Pluck("id", &userIDs).Error; err != nil {
+			return queued, err
+		}
+		resolution := MarketResolution{
+			MarketID:      market.ID,
+			QuestionTitle: market.QuestionTitle,
+			Resolution:    market.ResolutionResult,
+			ResolvedAt:    market.FinalResolutionDateTime,
+		}
+		for _, userID := range userIDs {
+			n, err := s.enqueueOnce(hooks[userID], models.WebhookEventMarketResolved, fmt.Sprintf("market:%d", market.ID), resolution)
+			queued += n
+			if err != nil {
+				return queued, err
+			}
+		}
+	}
+	return queued, nil
+}
+
+func (s *Service) scanDeposits(since, until time.Time) (int, error) {
+	hooks, err := s.subscribers(models.WebhookEventDepositCredited)
+	if err != nil || len(hooks) == 0 {
+		return 0, err
+	}
+	var deposits []models.CryptoTransaction
+	if err := s.db.Where("user_id IN ? AND type = ? AND status = ? AND updated_at > ? AND updated_at <= ?",
+		keys(hooks), models.TxTypeDeposit, models.TxStatusCompleted, since, until).
+		Order("id").Find(&deposits).Error; err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, d := range deposits {
+		n, err := s.enqueueOnce(hooks[d.UserID], models.WebhookEventDepositCredited, fmt.Sprintf("deposit:%d", d.ID), DepositCredit{
+			TransactionID: d.ID,
+			ChainName:     d.ChainName,
+			TokenSymbol:   d.TokenSymbol,
+			Amount:        d.Amount,
+			Credits:       models.DisplayCredits(d.AmountCredits),
+			TxHash:        d.TxHash,
+		})
+		queued += n
+		if err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
+
+func (s *Service) scanWithdrawals(since, until time.Time) (int, error) {
+	hooks, err := s.subscribers(models.WebhookEventWithdrawalChanged)
+	if err != nil || len(hooks) == 0 {
+		return 0, err
+	}
+	var withdrawals []models.WithdrawalRequest
+	if err := s.db.Where("user_id IN ? AND updated_at > ? AND updated_at <= ?", keys(hooks), since, until).
+		Order("id").Find(&withdrawals).Error; err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, w := range withdrawals {
+		// One event per status a withdrawal reaches
+		reference := fmt.Sprintf("withdrawal:%d:%s", w.ID, w.Status)
+		n, err := s.enqueueOnce(hooks[w.UserID], models.WebhookEventWithdrawalChanged, reference, WithdrawalStatus{
+			WithdrawalID: w.ID,
+			Status:       w.Status,
+			Credits:      models.DisplayCredits(w.Amount),
+			ChainName:    w.ChainName,
+			TokenSymbol:  w.TokenSymbol,
+			ToAddress:    w.ToAddress,
+			ErrorMessage: w.ErrorMessage,
+		})
+		queued += n
+		if err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
+
+func keys(hooks map[int64][]*models.UserWebhook) []int64 {
+	ids := make([]int64, 0, len(hooks))
+	for id := range hooks {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// insertOnce creates the delivery unless the webhook already has one with
+// the same reference, and reports whether it did
+func insertOnce(tx *gorm.DB, delivery *models.UserWebhookDelivery) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,139 @@
+package userhooks
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestScanEventsQueuesAccountEventsOnce(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	svc := NewService(db, Config{AllowLocal: true}, clock.New())
+	user := createUser(t, db)
+	other := modelstesting.GenerateUser("bystander", 0)
+	db.Create(&other)
+
+	hook, _, err := svc.Register(user, "http://127.0.0.1:9/hook", []string{
+		models.WebhookEventBetFilled,
+		models.WebhookEventMarketResolved,
+		models.WebhookEventDepositCredited,
+		models.WebhookEventWithdrawalChanged,
+	}, 0, "")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	// Activity from before the first scan is not reported
+	market := modelstesting.GenerateMarket(1, "bystander")
+	db.Create(&market)
+	early := modelstesting.GenerateBet(10, "YES", user.Username, 1, -time.Hour)
+	db.Create(&early)
+	if queued, err := svc.ScanEvents(); err != nil || queued != 0 {
+		t.Fatalf("first scan: queued %d, err %v", queued, err)
+	}
+
+	bet := modelstesting.GenerateBet(25, "NO", user.Username, 1, 0)
+	db.Create(&bet)
+	othersBet := modelstesting.GenerateBet(25, "YES", "bystander", 1, 0)
+	db.Create(&othersBet)
+	market.IsResolved = true
+	market.ResolutionResult = "NO"
+	market.FinalResolutionDateTime = time.Now()
+	db.Save(&market)
+	deposit := models.CryptoTransaction{UserID: user.ID, Type: models.TxTypeDeposit, Status: models.TxStatusCompleted,
+		ChainName: "Base", TokenSymbol: "USDC", Amount: "5000000", AmountCredits: models.CreditsToMicro(5)}
+	db.Create(&deposit)
+	withdrawal := models.WithdrawalRequest{UserID: user.ID, ChainID: 8453, ChainName: "Base", TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(3), ToAddress: "0xabc", Status: models.TxStatusPending}
+	db.Create(&withdrawal)
+
+	queued, err := svc.ScanEvents()
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if queued != 4 {
+		t.Fatalf("expected fill, resolution, deposit and withdrawal events, got %d", queued)
+	}
+
+	// Rescanning the overlap queues nothing new
+	if queued, err := svc.ScanEvents(); err != nil || queued != 0 {
+		t.Fatalf("rescan: queued %d, err %v", queued, err)
+	}
+
+	// Each status a withdrawal reaches is its own event
+	db.Model(&withdrawal).Update("status", models.TxStatusCompleted)
+	if queued, err := svc.ScanEvents(); err != nil || queued != 1 {
+		t.Fatalf("status change: queued %d, err %v", queued, err)
+	}
+
+	deliveries, err := svc.Deliveries(user.ID, hook.ID, models.WebhookDeliveryPending, 0)
+	if err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	events := make(map[string]int)
+	for _, d := range deliveries {
+		events[d.Event]++
+		if d.Event == models.WebhookEventBetFilled {
+			var event struct {
+				Data BetFill `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(d.Payload), &event); err != nil {
+				t.Fatalf("payload: %v", err)
+			}
+			if event.Data.BetID != bet.ID || event.Data.Side != "buy" || event.Data.Amount != 25 {
+				t.Errorf("fill = %+v", event.Data)
+			}
+		}
+	}
+	if events[models.WebhookEventBetFilled] != 1 || events[models.WebhookEventMarketResolved] != 1 ||
+		events[models.WebhookEventDepositCredited] != 1 || events[models.WebhookEventWithdrawalChanged] != 2 {
+		t.Fatalf("events = %v", events)
+	}
+}
+
+func TestRetryRequeuesFailedDelivery(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, Config{AllowLocal: true}, fake)
+	user := createUser(t, db)
+	intruder := modelstesting.GenerateUser("intruder", 0)
+	db.Create(&intruder)
+
+	hook, _, err := svc.Register(user, "http://127.0.0.1:9/hook", nil, 0, "")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svc.Enqueue(db, hook, models.WebhookEventBalanceChanged, BalanceChange{}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	var delivery models.UserWebhookDelivery
+	db.First(&delivery)
+	db.Model(&delivery).Updates(map[string]interface{}{"status": models.WebhookDeliveryFailed, "attempts": maxAttempts, "next_attempt_at": nil})
+
+	if _, err := svc.Retry(intruder.ID, hook.ID, delivery.ID); err != ErrNotFound {
+		t.Fatalf("another user's webhook: err = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.Retry(user.ID, hook.ID, delivery.ID+1); err != ErrNoDelivery {
+		t.Fatalf("unknown delivery: err = %v, want ErrNoDelivery", err)
+	}
+
+	retried, err := svc.Retry(user.ID, hook.ID, delivery.ID)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retried.Status != models.WebhookDeliveryPending || retried.Attempts != 0 || retried.NextAttemptAt == nil {
+		t.Fatalf("retried delivery = %+v", retried)
+	}
+
+	if _, err := svc.Deliveries(user.ID, hook.ID, "BOGUS", 0); err != ErrInvalidStatus {
+		t.Fatalf("bad status filter: err = %v", err)
+	}
+	failed, err := svc.Deliveries(user.ID, hook.ID, models.WebhookDeliveryFailed, 0)
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("failed deliveries after retry = %d, %v", len(failed), err)
+	}
+}
@@ -0,0 +1,491 @@
+// Package userhooks delivers signed account events, such as balance changes,
+// to webhook URLs registered by users. Events are queued in the database and
+// retried with backoff until delivered or given up on.
+package userhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+)
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-SocialPredict-Event"
+	HeaderDelivery  = "X-SocialPredict-Delivery"
+	HeaderSignature = "X-SocialPredict-Signature"
+)
+
+const (
+	maxWebhooksPerUser = 5
+	maxDeliveryPage    = 200
+	maxAttempts        = 8
+	baseRetryDelay     = time.Minute
+	deliveryTimeout    = 10 * time.Second
+	deliveryBatchSize  = 100
+)
+
+var (
+	ErrInvalidURL      = errors.New("webhook URL must be an https URL on a public host")
+	ErrTooManyWebhooks = fmt.Errorf("at most %d webhooks per user", maxWebhooksPerUser)
+	ErrInvalidEvent    = errors.New("unsupported webhook event")
+	ErrNotFound        = errors.New("webhook not found")
+	ErrNoDelivery      = errors.New("delivery not found")
+	ErrInvalidStatus   = errors.New("status must be PENDING, DELIVERED or FAILED")
+)
+
+// SupportedEvents are the event types users can subscribe to
+var SupportedEvents = []string{
+	models.WebhookEventBalanceChanged,
+	models.WebhookEventBetFilled,
+	models.WebhookEventMarketResolved,
+	models.WebhookEventDepositCredited,
+	models.WebhookEventWithdrawalChanged,
+}
+
+// Config controls delivery
+type Config struct {
+	// AllowLocal permits http URLs and private, loopback and link-local
+	// addresses. Only for development.
+	AllowLocal bool
+}
+
+// LoadConfigFromEnv reads USER_WEBHOOKS_ALLOW_LOCAL
+func LoadConfigFromEnv() Config {
+	var config Config
+	config.AllowLocal, _ = strconv.ParseBool(os.Getenv("USER_WEBHOOKS_ALLOW_LOCAL"))
+	return config
+}
+
+// Event is the JSON body POSTed to a webhook
+type Event struct {
+	ID        uint        `json:"id"` // Delivery ID; stable across retries
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// BalanceChange is the data of a balance.changed event. Amounts are in credits.
+type BalanceChange struct {
+	PreviousBalance float64 `json:"previousBalance"`
+	Balance         float64 `json:"balance"`
+	Change          float64 `json:"change"`
+}
+
+// Service manages user webhooks and delivers their events
+type Service struct {
+	db     *gorm.DB
+	config Config
+	clock  clock.Clock
+	client *http.Client
+}
+
+// NewService creates a webhook service. Unless config.AllowLocal is set, its
+// HTTP client refuses to connect to non-public addresses, whatever the URL's
+// host resolves to.
+func NewService(db *gorm.DB, config Config, c clock.Clock) *Service {
+	dialer := &net.Dialer{Timeout: deliveryTimeout}
+	if !config.AllowLocal {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrInvalidURL
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	return &Service{
+		db:     db,
+		config: config,
+		clock:  c,
+		client: &http.Client{
+			Timeout:   deliveryTimeout,
+			Transport: transport,
+			// Redirects could point a delivery somewhere the URL check never saw
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Register adds a webhook for the user and returns it with its signing
+// secret, which is only shown this once
+func (s *Service) Register(user *models.User, rawURL string, events []string, minChange int64, description string) (*models.UserWebhook, string, error) {
+	if err := s.validateURL(rawURL); err != nil {
+		return nil, "", err
+	}
+	if len(events) == 0 {
+		events = SupportedEvents
+	}
+	for _, event := range events {
+		if !isSupportedEvent(event) {
+			return nil, "", ErrInvalidEvent
+		}
+	}
+	if minChange < 0 {
+		minChange = 0
+	}
+
+	var count int64
+	s.db.Model(&models.UserWebhook{}).Where("user_id = ?", user.ID).Count(&count)
+	if count >= maxWebhooksPerUser {
+		return nil, "", ErrTooManyWebhooks
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	hook := models.UserWebhook{
+		UserID:      user.ID,
+		URL:         rawURL,
+		Secret:      secret,
+		Events:      strings.Join(events, ","),
+		MinChange:   minChange,
+		LastBalance: user.BalanceMicroCredits(),
+		Description: description,
+		IsActive:    true,
+	}
+	if err := s.db.Create(&hook).Error; err != nil {
+		return nil, "", err
+	}
+	return &hook, secret, nil
+}
+
+// List returns the user's webhooks
+func (s *Service) List(userID int64) ([]models.UserWebhook, error) {
+	var hooks []models.UserWebhook
+	err := s.db.Where("user_id = ?", userID).Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+// Delete removes one of the user's webhooks and drops its undelivered events
+func (s *Service) Delete(userID int64, id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&models.UserWebhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("webhook_id = ? AND status = ?", id, models.WebhookDeliveryPending).
+			Delete(&models.UserWebhookDelivery{}).Error
+	})
+}
+
+// Deliveries returns the newest deliveries of one of the user's webhooks,
+// optionally only those with status
+func (s *Service) Deliveries(userID int64, webhookID uint, status string, limit int) ([]models.UserWebhookDelivery, error) {
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	if limit <= 0 || limit > maxDeliveryPage {
+		limit = maxDeliveryPage
+	}
+	if err := s.db.Where("id = ? AND user_id = ?", webhookID, userID).First(&models.UserWebhook{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	query := s.db.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	deliveries := []models.UserWebhookDelivery{}
+	err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Retry queues one of a webhook's deliveries to be sent again at the next
+// delivery run, with a fresh set of attempts. Delivered events can be
+// redelivered too; receivers should use the delivery ID to ignore repeats.
+func (s *Service) Retry(userID int64, webhookID, deliveryID uint) (*models.UserWebhookDelivery, error) {
+	if err := s.db.Where("id = ? AND user_id = ?", webhookID, userID).First(&models.UserWebhook{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var delivery models.UserWebhookDelivery
+	if err := s.db.Where("id = ? AND webhook_id = ?", deliveryID, webhookID).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoDelivery
+		}
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	delivery.LastError = ""
+	if err := s.db.Save(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ScanBalances queues a balance.changed event for every active webhook whose
+// user's balance has moved by at least its threshold since the last event
+func (s *Service) ScanBalances() (int, error) {
+	var hooks []models.UserWebhook
+	if err := s.db.Where("is_active = ?", true).Find(&hooks).Error; err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for i := range hooks {
+		hook := &hooks[i]
+		if !subscribed(hook, models.WebhookEventBalanceChanged) {
+			continue
+		}
+		var user models.User
+		if err := s.db.First(&user, hook.UserID).Error; err != nil {
+			continue
+		}
+		balance := user.BalanceMicroCredits()
+		change := balance - hook.LastBalance
+		if change == 0 || abs(change) < hook.MinChange {
+			continue
+		}
+
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := s.Enqueue(tx, hook, models.WebhookEventBalanceChanged, BalanceChange{
+				PreviousBalance: models.DisplayCredits(hook.LastBalance),
+				Balance:         models.DisplayCredits(balance),
+				Change:          models.DisplayCredits(change),
+			}); err != nil {
+				return err
+			}
+			return tx.Model(hook).Update("last_balance", balance).Error
+		})
+		if err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// Enqueue queues an event for delivery to a webhook
+func (s *Service) Enqueue(tx *gorm.DB, hook *models.UserWebhook, eventType string, data interface{}) error {
+	_, err := s.enqueue(tx, hook, eventType, nil, data)
+	return err
+}
+
+// enqueue queues an event and reports whether it did. An event with a
+// reference is skipped if the webhook already had one with the same reference.
+func (s *Service) enqueue(tx *gorm.DB, hook *models.UserWebhook, eventType string, reference *string, data interface{}) (bool, error) {
+	now := s.clock.Now()
+	delivery := models.UserWebhookDelivery{
+		WebhookID:     hook.ID,
+		UserID:        hook.UserID,
+		Event:         eventType,
+		ReferenceKey:  reference,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	created, err := insertOnce(tx, &delivery)
+	if err != nil || !created {
+		return false, err
+	}
+	payload, err := json.Marshal(Event{ID: delivery.ID, Type: eventType, CreatedAt: now, Data: data})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Model(&delivery).Update("payload", string(payload)).Error
+}
+
+// DeliverPending sends every queued event that is due and returns how many
+// were delivered
+func (s *Service) DeliverPending() (int, error) {
+	var deliveries []models.UserWebhookDelivery
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, s.clock.Now()).
+		Order("id").Limit(deliveryBatchSize).Find(&deliveries).Error; err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range deliveries {
+		d := &deliveries[i]
+		var hook models.UserWebhook
+		if err := s.db.First(&hook, d.WebhookID).Error; err != nil {
+			s.db.Model(d).Updates(map[string]interface{}{"status": models.WebhookDeliveryFailed, "last_error": "webhook removed"})
+			continue
+		}
+		if s.attempt(&hook, d) {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attempt POSTs one delivery and records the outcome
+func (s *Service) attempt(hook *models.UserWebhook, d *models.UserWebhookDelivery) bool {
+	now := s.clock.Now()
+	d.Attempts++
+
+	code, err := s.post(hook, d, now)
+	d.ResponseCode = code
+	if err == nil {
+		d.Status = models.WebhookDeliveryDelivered
+		d.DeliveredAt = &now
+		d.NextAttemptAt = nil
+		d.LastError = ""
+	} else {
+		d.LastError = err.Error()
+		if d.Attempts >= maxAttempts {
+			d.Status = models.WebhookDeliveryFailed
+			d.NextAttemptAt = nil
+		} else {
+			next := now.Add(baseRetryDelay << (d.Attempts - 1))
+			d.NextAttemptAt = &next
+		}
+	}
+	if saveErr := s.db.Save(d).Error; saveErr != nil {
+		log.Printf("User webhooks: failed to record delivery %d: %v", d.ID, saveErr)
+	}
+	return err == nil
+}
+
+func (s *Service) post(hook *models.UserWebhook, d *models.UserWebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, now, []byte(d.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Run scans balances and account events and delivers due events every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.ScanBalances(); err != nil {
+			log.Printf("User webhooks: balance scan failed: %v", err)
+		}
+		if _, err := s.ScanEvents(); err != nil {
+			log.Printf("User webhooks: event scan failed: %v", err)
+		}
+		if _, err := s.DeliverPending(); err != nil {
+			log.Printf("User webhooks: delivery failed: %v", err)
+		}
+	}
+}
+
+// Sign returns the signature header value for a payload: the unix timestamp
+// and an HMAC-SHA256 of "timestamp.payload" keyed with the webhook secret.
+// Receivers recompute the HMAC and should reject stale timestamps.
+func Sign(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if s.config.AllowLocal {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return ErrInvalidURL
+		}
+		return nil
+	}
+	if u.Scheme != "https" || strings.EqualFold(u.Hostname(), "localhost") {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast())
+}
+
+func isSupportedEvent(event string) bool {
+	for _, e := range SupportedEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func subscribed(hook *models.UserWebhook, event string) bool {
+	for _, e := range strings.Split(hook.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
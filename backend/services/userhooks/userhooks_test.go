@@ -0,0 +1,157 @@
+package userhooks
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+func createUser(t *testing.T, db *gorm.DB) *models.User {
+	t.Helper()
+	user := modelstesting.GenerateUser("hookuser", 0)
+	user.AddMicroCredits(models.CreditsToMicro(1000))
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+func changeBalance(t *testing.T, db *gorm.DB, user *models.User, credits int64) {
+	t.Helper()
+	user.AddMicroCredits(models.CreditsToMicro(credits))
+	if err := db.Save(user).Error; err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+}
+
+func TestBalanceChangeIsSignedAndDelivered(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, Config{AllowLocal: true}, fake)
+	user := createUser(t, db)
+
+	var gotBody []byte
+	var gotSignature, gotEvent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(HeaderSignature)
+		gotEvent = r.Header.Get(HeaderEvent)
+	}))
+	defer server.Close()
+
+	hook, secret, err := svc.Register(user, server.URL, nil, models.CreditsToMicro(50), "")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	// Below the threshold nothing is queued
+	changeBalance(t, db, user, 20)
+	if queued, err := svc.ScanBalances(); err != nil || queued != 0 {
+		t.Fatalf("expected no events below threshold, got %d (%v)", queued, err)
+	}
+
+	// The change is measured from the last event, so small moves accumulate
+	changeBalance(t, db, user, 40)
+	if queued, err := svc.ScanBalances(); err != nil || queued != 1 {
+		t.Fatalf("expected one event, got %d (%v)", queued, err)
+	}
+	if delivered, err := svc.DeliverPending(); err != nil || delivered != 1 {
+		t.Fatalf("expected one delivery, got %d (%v)", delivered, err)
+	}
+
+	if gotEvent != models.WebhookEventBalanceChanged {
+		t.Errorf("event header = %q", gotEvent)
+	}
+	if want := Sign(secret, fake.Now(), gotBody); gotSignature != want {
+		t.Errorf("signature = %q, want %q", gotSignature, want)
+	}
+
+	var stored models.UserWebhook
+	db.First(&stored, hook.ID)
+	if stored.LastBalance != models.CreditsToMicro(1060) {
+		t.Errorf("last balance = %d", stored.LastBalance)
+	}
+
+	// Nothing new to report on the next scan
+	if queued, _ := svc.ScanBalances(); queued != 0 {
+		t.Errorf("expected no further events, got %d", queued)
+	}
+}
+
+func TestFailedDeliveryIsRetriedWithBackoff(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC))
+	svc := NewService(db, Config{AllowLocal: true}, fake)
+	user := createUser(t, db)
+
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	if _, _, err := svc.Register(user, server.URL, nil, 0, ""); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	changeBalance(t, db, user, -5)
+	svc.ScanBalances()
+
+	if delivered, _ := svc.DeliverPending(); delivered != 0 {
+		t.Fatalf("expected failed delivery")
+	}
+	var d models.UserWebhookDelivery
+	db.First(&d)
+	if d.Status != models.WebhookDeliveryPending || d.Attempts != 1 || d.ResponseCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected delivery state: %+v", d)
+	}
+
+	// Not due again until the backoff has passed
+	fail = false
+	if delivered, _ := svc.DeliverPending(); delivered != 0 {
+		t.Fatalf("retried before backoff")
+	}
+	fake.Advance(baseRetryDelay)
+	if delivered, _ := svc.DeliverPending(); delivered != 1 {
+		t.Fatalf("expected retry to succeed")
+	}
+	db.First(&d, d.ID)
+	if d.Status != models.WebhookDeliveryDelivered || d.Attempts != 2 {
+		t.Errorf("unexpected delivery state after retry: %+v", d)
+	}
+}
+
+func TestRegisterRejectsUnsafeURLs(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	svc := NewService(db, Config{}, clock.New())
+	user := createUser(t, db)
+
+	for _, u := range []string{
+		"http://example.com/hook",
+		"https://localhost/hook",
+		"https://127.0.0.1/hook",
+		"https://10.0.0.5/hook",
+		"https://169.254.169.254/latest",
+		"not a url",
+	} {
+		if _, _, err := svc.Register(user, u, nil, 0, ""); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("%s: expected ErrInvalidURL, got %v", u, err)
+		}
+	}
+	if _, _, err := svc.Register(user, "https://example.com/hook", []string{"market.created"}, 0, ""); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent, got %v", err)
+	}
+	if _, _, err := svc.Register(user, "https://example.com/hook", nil, 0, ""); err != nil {
+		t.Errorf("expected public https URL to be accepted: %v", err)
+	}
+}
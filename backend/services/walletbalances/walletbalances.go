@@ -0,0 +1,165 @@
+// Package walletbalances keeps snapshots of users' deposit wallet balances as
+// DFNS reports them, so users can check where a deposit landed. Snapshots are
+// refreshed on read once they are older than the refresh interval, and the
+// last snapshot is served when DFNS is unavailable.
+package walletbalances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/logger"
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultMaxAge is how long a snapshot is served before DFNS is asked again
+const defaultMaxAge = 5 * time.Minute
+
+// ErrReaderUnavailable means the wallet's DFNS org is not configured
+var ErrReaderUnavailable = errors.New("DFNS organization unavailable")
+
+// Reader reads a wallet's assets. *dfns.Client satisfies it.
+type Reader interface {
+	GetWalletBalance(ctx context.Context, walletID string) (*dfns.WalletBalanceResponse, error)
+}
+
+// Readers returns the reader for a DFNS org, or nil if it is unavailable
+type Readers func(org string) Reader
+
+// OrgReaders serves readers from the configured DFNS organizations
+func OrgReaders(orgs *dfns.Orgs) Readers {
+	return func(org string) Reader {
+		if client := orgs.Client(org); client != nil {
+			return client
+		}
+		return nil
+	}
+}
+
+// Config holds balance snapshot settings
+type Config struct {
+	MaxAge time.Duration // How long a snapshot is served before it is refreshed
+}
+
+// LoadConfigFromEnv reads WALLET_BALANCE_MAX_AGE
+func LoadConfigFromEnv() Config {
+	config := Config{MaxAge: defaultMaxAge}
+	if d, err := time.ParseDuration(os.Getenv("WALLET_BALANCE_MAX_AGE")); err == nil && d > 0 {
+		config.MaxAge = d
+	}
+	return config
+}
+
+// Service serves deposit wallet balance snapshots
+type Service struct {
+	db      *gorm.DB
+	readers Readers
+	config  Config
+	clock   clock.Clock
+}
+
+// NewService creates a balance snapshot service
+func NewService(db *gorm.DB, readers Readers, config Config, c clock.Clock) *Service {
+	return &Service{db: db, readers: readers, config: config, clock: c}
+}
+
+// Balances returns the wallet's balance of each token on its chain, by
+// symbol, refreshing the snapshots from DFNS if they are stale. If DFNS
+// cannot be reached the stale snapshots are returned; their FetchedAt says
+// how old they are.
+func (s *Service) Balances(ctx context.Context, wallet *models.Wallet) ([]models.WalletBalanceSnapshot, error) {
+	snapshots, err := s.stored(wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+	if s.fresh(snapshots) {
+		return snapshots, nil
+	}
+
+	if err := s.refresh(ctx, wallet); err != nil {
+		logger.FromContext(ctx).Warn("failed to refresh deposit wallet balance, serving last snapshot",
+			"wallet_id", wallet.ID, "chain", wallet.ChainName, "error", err)
+		return snapshots, nil
+	}
+	return s.stored(wallet.ID)
+}
+
+func (s *Service) stored(walletID uint) ([]models.WalletBalanceSnapshot, error) {
+	snapshots := []models.WalletBalanceSnapshot{}
+	err := s.db.Where("wallet_id = ?", walletID).Order("token_symbol").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// fresh reports whether there are snapshots and none is older than MaxAge
+func (s *Service) fresh(snapshots []models.WalletBalanceSnapshot) bool {
+	if len(snapshots) == 0 {
+		return false
+	}
+	for _, snapshot := range snapshots {
+		if clock.Since(s.clock, snapshot.FetchedAt) >= s.config.MaxAge {
+			return false
+		}
+	}
+	return true
+}
+
+// refresh stores the wallet's current balance of every token on its chain,
+// zero for tokens DFNS does not report
+func (s *Service) refresh(ctx context.Context, wallet *models.Wallet) error {
+	reader := s.readers(wallet.DfnsOrg)
+	if reader == nil {
+		return ErrReaderUnavailable
+	}
+	assets, err := reader.GetWalletBalance(ctx, wallet.DfnsWalletID)
+	if err != nil {
+		return err
+	}
+
+	var chain models.SupportedChain
+	if err := s.db.Where("chain_id = ?", wallet.ChainID).First(&chain).Error; err != nil {
+		return err
+	}
+	tokens, err := chains.Tokens(s.db, chain.ID)
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	snapshots := make([]models.WalletBalanceSnapshot, 0, len(tokens))
+	for _, token := range tokens {
+		snapshot := models.WalletBalanceSnapshot{
+			WalletID:        wallet.ID,
+			TokenSymbol:     token.Token.Symbol,
+			ContractAddress: token.ContractAddress,
+			FetchedAt:       now,
+		}
+		for _, asset := range assets.Items {
+			if !dfns.SameAddress(chain.Name, asset.Contract, token.ContractAddress) {
+				continue
+			}
+			// An empty balance is recorded as zero
+			balance, err := dfns.ConvertToMicroCredits(asset.Balance, token.Decimals)
+			if err != nil && !errors.Is(err, dfns.ErrTokenAmountNotPositive) {
+				return fmt.Errorf("%s balance of wallet %d: %w", token.Token.Symbol, wallet.ID, err)
+			}
+			snapshot.Balance = balance
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "wallet_id"}, {Name: "token_symbol"}},
+		DoUpdates: clause.AssignmentColumns([]string{"contract_address", "balance", "fetched_at"}),
+	}).Create(&snapshots).Error
+}
@@ -0,0 +1,68 @@
+package walletbalances
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+)
+
+type fakeReader struct {
+	assets []dfns.WalletAsset
+	calls  int
+	err    error
+}
+
+func (f *fakeReader) GetWalletBalance(context.Context, string) (*dfns.WalletBalanceResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &dfns.WalletBalanceResponse{Items: f.assets}, nil
+}
+
+func TestBalancesAreSnapshotAndRefreshedWhenStale(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	usdc, err := chains.TokenOnChain(db, "ethereum", "USDC")
+	if err != nil {
+		t.Fatalf("load seeded token: %v", err)
+	}
+	wallet := models.Wallet{UserID: 1, DfnsWalletID: "wa-1", DfnsOrg: dfns.PrimaryOrg, ChainID: 1, ChainName: "ethereum", Address: "0xwallet", IsActive: true}
+	if err := db.Create(&wallet).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+
+	reader := &fakeReader{assets: []dfns.WalletAsset{{Symbol: "USDC", Balance: "12500000", Decimals: 6, Contract: usdc.ContractAddress}}}
+	fake := clock.NewFake(time.Now())
+	svc := NewService(db, func(string) Reader { return reader }, Config{MaxAge: time.Minute}, fake)
+
+	got, err := svc.Balances(context.Background(), &wallet)
+	if err != nil || len(got) != 2 || got[0].TokenSymbol != "USDC" || got[0].Balance != 12_500_000 || got[1].Balance != 0 {
+		t.Fatalf("balances = %+v, %v; want 12.5 USDC and no USDT", got, err)
+	}
+
+	// Within MaxAge the snapshot is served without asking DFNS
+	reader.assets[0].Balance = "20000000"
+	if got, _ := svc.Balances(context.Background(), &wallet); reader.calls != 1 || got[0].Balance != 12_500_000 {
+		t.Fatalf("calls = %d, balance %d; want the cached snapshot", reader.calls, got[0].Balance)
+	}
+
+	fake.Advance(2 * time.Minute)
+	if got, _ := svc.Balances(context.Background(), &wallet); reader.calls != 2 || got[0].Balance != 20_000_000 {
+		t.Fatalf("calls = %d, balance %d; want a refreshed snapshot", reader.calls, got[0].Balance)
+	}
+
+	// An unreachable DFNS serves the last snapshot
+	fake.Advance(2 * time.Minute)
+	reader.err = errors.New("dfns down")
+	got, err = svc.Balances(context.Background(), &wallet)
+	if err != nil || len(got) != 2 || got[0].Balance != 20_000_000 {
+		t.Fatalf("balances = %+v, %v; want the stale snapshot", got, err)
+	}
+}
@@ -0,0 +1,159 @@
+// Package walletrotation replaces a user's deposit wallet when the DFNS wallet
+// is archived or compromised. The old wallet is deactivated with a reason and
+// keeps crediting deposits for a grace period, so senders who still have the
+// old address are not lost.
+package walletrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/logger"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+)
+
+// defaultGracePeriod is how long a retired address keeps crediting deposits
+const defaultGracePeriod = 30 * 24 * time.Hour
+
+// ActionWalletRotated is the audit action for a rotation
+const ActionWalletRotated = "USER_WALLET_ROTATED"
+
+const auditTargetWallet = "wallet"
+
+var (
+	ErrWalletNotFound     = errors.New("wallet not found")
+	ErrWalletInactive     = errors.New("wallet is already inactive")
+	ErrReasonRequired     = errors.New("a reason is required")
+	ErrProvisioningFailed = errors.New("failed to provision replacement wallet")
+)
+
+// Provisioner creates DFNS wallets. *dfns.Orgs satisfies it.
+type Provisioner interface {
+	CreateWallet(ctx context.Context, req dfns.CreateWalletRequest) (*dfns.WalletResponse, string, error)
+}
+
+// Config holds wallet rotation settings
+type Config struct {
+	GracePeriod time.Duration // How long the old address keeps crediting deposits
+}
+
+// LoadConfigFromEnv reads WALLET_ROTATION_GRACE_PERIOD
+func LoadConfigFromEnv() Config {
+	config := Config{GracePeriod: defaultGracePeriod}
+	if d, err := time.ParseDuration(os.Getenv("WALLET_ROTATION_GRACE_PERIOD")); err == nil && d >= 0 {
+		config.GracePeriod = d
+	}
+	return config
+}
+
+// Service rotates user deposit wallets
+type Service struct {
+	db          *gorm.DB
+	provisioner Provisioner
+	config      Config
+	clock       clock.Clock
+}
+
+// NewService creates a wallet rotation service
+func NewService(db *gorm.DB, provisioner Provisioner, config Config, c clock.Clock) *Service {
+	return &Service{db: db, provisioner: provisioner, config: config, clock: c}
+}
+
+// Result is the outcome of a rotation
+type Result struct {
+	Old models.Wallet `json:"old"`
+	New models.Wallet `json:"new"`
+}
+
+// Rotate provisions a replacement for an active wallet and retires the old
+// one. The replacement is created in DFNS first; if saving the switch fails
+// the new DFNS wallet is left unused and the old wallet stays active.
+func (s *Service) Rotate(ctx context.Context, walletID uint, reason, actor string) (*Result, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var old models.Wallet
+	if err := s.db.First(&old, walletID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+	if !old.IsActive {
+		return nil, ErrWalletInactive
+	}
+
+	// The name differs from the original so DFNS does not hand back the old wallet
+	created, org, err := s.provisioner.CreateWallet(ctx, dfns.CreateWalletRequest{
+		Network:    dfns.GetDFNSNetwork(old.ChainName),
+		Name:       fmt.Sprintf("user-%d-%s-r%d", old.UserID, old.ChainName, old.ID),
+		ExternalID: fmt.Sprintf("%d", old.UserID),
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to provision replacement wallet", "wallet_id", old.ID, "error", err)
+		return nil, ErrProvisioningFailed
+	}
+
+	now := s.clock.Now()
+	graceUntil := now.Add(s.config.GracePeriod)
+	replacement := models.Wallet{
+		UserID:       old.UserID,
+		DfnsWalletID: created.ID,
+		DfnsOrg:      org,
+		ChainID:      old.ChainID,
+		ChainName:    old.ChainName,
+		Address:      created.Address,
+		IsActive:     true,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Only one rotation of a wallet may win
+		retire := tx.Model(&models.Wallet{}).Where("id = ? AND is_active = ?", old.ID, true).Update("is_active", false)
+		if retire.Error != nil {
+			return retire.Error
+		}
+		if retire.RowsAffected == 0 {
+			return ErrWalletInactive
+		}
+		if err := tx.Create(&replacement).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Wallet{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+			"deactivated_at":      now,
+			"deactivation_reason": reason,
+			"grace_until":         graceUntil,
+			"replaced_by_id":      replacement.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionWalletRotated,
+			TargetType: auditTargetWallet,
+			TargetID:   old.ID,
+			Details: fmt.Sprintf("user %d %s wallet %s replaced by %s (wallet %d), grace until %s: %s",
+				old.UserID, old.ChainName, old.Address, replacement.Address, replacement.ID, graceUntil.Format(time.RFC3339), reason),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(&old, old.ID).Error; err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("rotated deposit wallet", "user_id", old.UserID, "chain", old.ChainName,
+		"old_wallet_id", old.ID, "new_wallet_id", replacement.ID, "dfns_org", org, "grace_until", graceUntil)
+	return &Result{Old: old, New: replacement}, nil
+}
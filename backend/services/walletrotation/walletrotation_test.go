@@ -0,0 +1,94 @@
+package walletrotation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/dfns"
+)
+
+type fakeProvisioner struct {
+	requests []dfns.CreateWalletRequest
+	err      error
+}
+
+func (f *fakeProvisioner) CreateWallet(_ context.Context, req dfns.CreateWalletRequest) (*dfns.WalletResponse, string, error) {
+	if f.err != nil {
+		return nil, "", f.err
+	}
+	f.requests = append(f.requests, req)
+	return &dfns.WalletResponse{ID: "wa-new", Network: req.Network, Address: "0x2222222222222222222222222222222222222222"}, dfns.PrimaryOrg, nil
+}
+
+func TestRotateReplacesWalletWithGracePeriod(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Date(2026, 3, 17, 12, 0, 0, 0, time.UTC)
+	provisioner := &fakeProvisioner{}
+	svc := NewService(db, provisioner, Config{GracePeriod: 7 * 24 * time.Hour}, clock.NewFake(now))
+
+	old := models.Wallet{UserID: 7, DfnsWalletID: "wa-old", DfnsOrg: dfns.PrimaryOrg, ChainID: 1, ChainName: "ethereum", Address: "0x1111111111111111111111111111111111111111", IsActive: true}
+	if err := db.Create(&old).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+
+	if _, err := svc.Rotate(context.Background(), old.ID, " ", "admin"); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("Rotate without reason = %v, want ErrReasonRequired", err)
+	}
+
+	result, err := svc.Rotate(context.Background(), old.ID, "key compromise", "admin")
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if len(provisioner.requests) != 1 || provisioner.requests[0].Network != "EthereumMainnet" || provisioner.requests[0].ExternalID != "7" {
+		t.Errorf("provisioning requests = %+v", provisioner.requests)
+	}
+	if !result.New.IsActive || result.New.UserID != 7 || result.New.DfnsWalletID != "wa-new" {
+		t.Errorf("replacement = %+v", result.New)
+	}
+	if result.Old.IsActive || result.Old.DeactivationReason != "key compromise" ||
+		result.Old.ReplacedByID == nil || *result.Old.ReplacedByID != result.New.ID {
+		t.Errorf("retired wallet = %+v", result.Old)
+	}
+	if result.Old.GraceUntil == nil || !result.Old.GraceUntil.Equal(now.Add(7*24*time.Hour)) {
+		t.Errorf("grace until = %v", result.Old.GraceUntil)
+	}
+	if !result.Old.AcceptsDeposits(now.Add(24*time.Hour)) || result.Old.AcceptsDeposits(now.Add(8*24*time.Hour)) {
+		t.Error("retired wallet should accept deposits only during the grace period")
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ? AND target_id = ?", ActionWalletRotated, old.ID).Count(&audits)
+	if audits != 1 {
+		t.Errorf("audit entries = %d, want 1", audits)
+	}
+
+	if _, err := svc.Rotate(context.Background(), old.ID, "again", "admin"); !errors.Is(err, ErrWalletInactive) {
+		t.Errorf("second Rotate = %v, want ErrWalletInactive", err)
+	}
+}
+
+func TestRotateKeepsWalletWhenProvisioningFails(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	svc := NewService(db, &fakeProvisioner{err: errors.New("dfns down")}, Config{GracePeriod: time.Hour}, clock.NewFake(time.Now()))
+
+	old := models.Wallet{UserID: 7, DfnsWalletID: "wa-old", ChainID: 1, ChainName: "ethereum", Address: "0x1111111111111111111111111111111111111111", IsActive: true}
+	if err := db.Create(&old).Error; err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+
+	if _, err := svc.Rotate(context.Background(), old.ID, "archived", "admin"); !errors.Is(err, ErrProvisioningFailed) {
+		t.Fatalf("Rotate = %v, want ErrProvisioningFailed", err)
+	}
+	db.First(&old, old.ID)
+	if !old.IsActive || old.DeactivatedAt != nil {
+		t.Errorf("wallet changed after failed rotation: %+v", old)
+	}
+	if _, err := svc.Rotate(context.Background(), 999, "archived", "admin"); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("Rotate unknown wallet = %v, want ErrWalletNotFound", err)
+	}
+}
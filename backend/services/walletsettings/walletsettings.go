@@ -0,0 +1,230 @@
+// Package walletsettings keeps each user's wallet defaults: the preferred
+// chain and token, saved withdrawal addresses and notification preferences.
+// The withdrawal form is prefilled from them, and withdrawals that leave out
+// the chain, token or destination get the defaults server-side.
+package walletsettings
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSavedAddresses = 20
+	maxLabelLength    = 50
+)
+
+var (
+	ErrInvalidChain   = errors.New("preferred chain is not an active chain")
+	ErrInvalidToken   = errors.New("preferred token needs a preferred chain that supports it")
+	ErrInvalidAddress = errors.New("saved addresses need an active chain, an address valid for it and a label of at most 50 characters, with at most one default per chain")
+	ErrTooMany        = fmt.Errorf("at most %d saved addresses", maxSavedAddresses)
+)
+
+// Settings is a user's wallet settings as shown to them
+type Settings struct {
+	PreferredChain            string                `json:"preferredChain"`
+	PreferredToken            string                `json:"preferredToken"`
+	SavedAddresses            []models.SavedAddress `json:"savedAddresses"`
+	NotifyWithdrawalCompleted bool                  `json:"notifyWithdrawalCompleted"`
+}
+
+// Patch changes some settings. Nil fields are left alone; an empty
+// PreferredChain or PreferredToken clears it, and SavedAddresses replaces
+// the whole list.
+type Patch struct {
+	PreferredChain            *string         `json:"preferredChain"`
+	PreferredToken            *string         `json:"preferredToken"`
+	SavedAddresses            *[]AddressInput `json:"savedAddresses"`
+	NotifyWithdrawalCompleted *bool           `json:"notifyWithdrawalCompleted"`
+}
+
+// AddressInput is a saved address as the user sends it
+type AddressInput struct {
+	ChainName string `json:"chainName"`
+	Address   string `json:"address"`
+	Label     string `json:"label"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+// Get returns a user's settings
+func Get(db *gorm.DB, userID int64) (*Settings, error) {
+	row, err := load(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	addresses := []models.SavedAddress{}
+	if err := db.Where("user_id = ?", userID).Order("chain_name, id").Find(&addresses).Error; err != nil {
+		return nil, err
+	}
+	return &Settings{
+		PreferredChain:            row.PreferredChain,
+		PreferredToken:            row.PreferredToken,
+		SavedAddresses:            addresses,
+		NotifyWithdrawalCompleted: !row.MuteWithdrawalCompleted,
+	}, nil
+}
+
+// Update applies a patch to a user's settings and returns the result
+func Update(db *gorm.DB, userID int64, patch Patch) (*Settings, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		row, err := load(tx, userID)
+		if err != nil {
+			return err
+		}
+		if patch.PreferredChain != nil {
+			row.PreferredChain = strings.TrimSpace(*patch.PreferredChain)
+			if row.PreferredChain == "" {
+				row.PreferredToken = ""
+			}
+		}
+		if patch.PreferredToken != nil {
+			row.PreferredToken = strings.ToUpper(strings.TrimSpace(*patch.PreferredToken))
+		}
+		if patch.NotifyWithdrawalCompleted != nil {
+			row.MuteWithdrawalCompleted = !*patch.NotifyWithdrawalCompleted
+		}
+		if err := validatePreferences(tx, row); err != nil {
+			return err
+		}
+		row.UserID = userID
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"preferred_chain", "preferred_token", "mute_withdrawal_completed", "updated_at"}),
+		}).Create(row).Error; err != nil {
+			return err
+		}
+
+		if patch.SavedAddresses != nil {
+			return replaceAddresses(tx, userID, *patch.SavedAddresses)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Get(db, userID)
+}
+
+// Defaults fills in a withdrawal's missing chain, token and destination from
+// the user's settings: the preferred chain and token, and the chain's default
+// saved address. Values the user gave are kept.
+func Defaults(db *gorm.DB, userID int64, chainName, tokenSymbol, toAddress *string) error {
+	if *chainName != "" && *tokenSymbol != "" && *toAddress != "" {
+		return nil
+	}
+	row, err := load(db, userID)
+	if err != nil {
+		return err
+	}
+	if *chainName == "" {
+		*chainName = row.PreferredChain
+	}
+	if *tokenSymbol == "" && *chainName == row.PreferredChain {
+		*tokenSymbol = row.PreferredToken
+	}
+	if *toAddress == "" && *chainName != "" {
+		var saved []models.SavedAddress
+		if err := db.Where("user_id = ? AND chain_name = ? AND is_default = ?", userID, *chainName, true).
+			Limit(1).Find(&saved).Error; err != nil {
+			return err
+		}
+		if len(saved) > 0 {
+			*toAddress = saved[0].Address
+		}
+	}
+	return nil
+}
+
+// NotifyWithdrawalCompleted reports whether the user wants a notification
+// when a withdrawal completes
+func NotifyWithdrawalCompleted(db *gorm.DB, userID int64) (bool, error) {
+	row, err := load(db, userID)
+	if err != nil {
+		return true, err
+	}
+	return !row.MuteWithdrawalCompleted, nil
+}
+
+// load returns the user's settings row, or an unsaved one with the defaults
+func load(db *gorm.DB, userID int64) (*models.WalletSettings, error) {
+	var rows []models.WalletSettings
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return &models.WalletSettings{UserID: userID}, nil
+	}
+	return &rows[0], nil
+}
+
+func validatePreferences(tx *gorm.DB, row *models.WalletSettings) error {
+	if row.PreferredChain != "" {
+		active, err := chains.Shared.IsActive(tx, row.PreferredChain)
+		if err != nil {
+			return err
+		}
+		if !active {
+			return ErrInvalidChain
+		}
+	}
+	if row.PreferredToken != "" {
+		if row.PreferredChain == "" {
+			return ErrInvalidToken
+		}
+		if _, err := chains.Shared.Token(tx, row.PreferredChain, row.PreferredToken); errors.Is(err, chains.ErrTokenNotFound) {
+			return ErrInvalidToken
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replaceAddresses(tx *gorm.DB, userID int64, inputs []AddressInput) error {
+	if len(inputs) > maxSavedAddresses {
+		return ErrTooMany
+	}
+	addresses := make([]models.SavedAddress, 0, len(inputs))
+	defaults := map[string]bool{}
+	seen := map[string]bool{}
+	for _, in := range inputs {
+		saved := models.SavedAddress{
+			UserID:    userID,
+			ChainName: strings.TrimSpace(in.ChainName),
+			Address:   strings.TrimSpace(in.Address),
+			Label:     strings.TrimSpace(in.Label),
+			IsDefault: in.IsDefault,
+		}
+		key := saved.ChainName + "/" + saved.Address
+		if len(saved.Label) > maxLabelLength || seen[key] || (saved.IsDefault && defaults[saved.ChainName]) {
+			return ErrInvalidAddress
+		}
+		active, err := chains.Shared.IsActive(tx, saved.ChainName)
+		if err != nil {
+			return err
+		}
+		if !active || !dfns.IsValidAddress(saved.Address, saved.ChainName) {
+			return ErrInvalidAddress
+		}
+		seen[key] = true
+		defaults[saved.ChainName] = defaults[saved.ChainName] || saved.IsDefault
+		addresses = append(addresses, saved)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.SavedAddress{}).Error; err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	return tx.Create(&addresses).Error
+}
@@ -0,0 +1,92 @@
+package walletsettings
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/chains"
+)
+
+const (
+	ethAddress  = "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+	ethAddress2 = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+)
+
+func TestUpdateStoresDefaultsAndFillsWithdrawals(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t.Cleanup(chains.Shared.Invalidate)
+
+	settings, err := Get(db, 1)
+	if err != nil || settings.PreferredChain != "" || len(settings.SavedAddresses) != 0 || !settings.NotifyWithdrawalCompleted {
+		t.Fatalf("defaults = %+v, %v", settings, err)
+	}
+
+	chain, token, off := "ethereum", "usdc", false
+	addresses := []AddressInput{
+		{ChainName: "ethereum", Address: ethAddress, Label: "Ledger", IsDefault: true},
+		{ChainName: "ethereum", Address: ethAddress2, Label: "Exchange"},
+	}
+	settings, err = Update(db, 1, Patch{PreferredChain: &chain, PreferredToken: &token, SavedAddresses: &addresses, NotifyWithdrawalCompleted: &off})
+	if err != nil || settings.PreferredToken != "USDC" || len(settings.SavedAddresses) != 2 || settings.NotifyWithdrawalCompleted {
+		t.Fatalf("updated = %+v, %v", settings, err)
+	}
+	if notify, err := NotifyWithdrawalCompleted(db, 1); err != nil || notify {
+		t.Fatalf("notify = %t, %v", notify, err)
+	}
+
+	var chainName, tokenSymbol, toAddress string
+	if err := Defaults(db, 1, &chainName, &tokenSymbol, &toAddress); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if chainName != "ethereum" || tokenSymbol != "USDC" || toAddress != ethAddress {
+		t.Fatalf("filled = %s %s %s", chainName, tokenSymbol, toAddress)
+	}
+	toAddress = ethAddress2
+	tokenSymbol = ""
+	if err := Defaults(db, 1, &chainName, &tokenSymbol, &toAddress); err != nil || toAddress != ethAddress2 || tokenSymbol != "USDC" {
+		t.Fatalf("given address kept = %s %s, %v", tokenSymbol, toAddress, err)
+	}
+
+	// A partial patch leaves the rest alone
+	on := true
+	settings, err = Update(db, 1, Patch{NotifyWithdrawalCompleted: &on})
+	if err != nil || settings.PreferredChain != "ethereum" || len(settings.SavedAddresses) != 2 || !settings.NotifyWithdrawalCompleted {
+		t.Fatalf("partial = %+v, %v", settings, err)
+	}
+}
+
+func TestUpdateRejectsInvalidSettings(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	t.Cleanup(chains.Shared.Invalidate)
+
+	unknown, dai, empty := "dogecoin", "DAI", ""
+	if _, err := Update(db, 1, Patch{PreferredChain: &unknown}); !errors.Is(err, ErrInvalidChain) {
+		t.Fatalf("unknown chain err = %v", err)
+	}
+	if _, err := Update(db, 1, Patch{PreferredToken: &dai}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token without chain err = %v", err)
+	}
+	chain := "ethereum"
+	if _, err := Update(db, 1, Patch{PreferredChain: &chain, PreferredToken: &dai}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token not on chain err = %v", err)
+	}
+	if _, err := Update(db, 1, Patch{PreferredChain: &empty}); err != nil {
+		t.Fatalf("clearing chain: %v", err)
+	}
+
+	for name, addresses := range map[string][]AddressInput{
+		"bad address":   {{ChainName: "ethereum", Address: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"}},
+		"two defaults":  {{ChainName: "ethereum", Address: ethAddress, IsDefault: true}, {ChainName: "ethereum", Address: ethAddress2, IsDefault: true}},
+		"duplicate":     {{ChainName: "ethereum", Address: ethAddress}, {ChainName: "ethereum", Address: ethAddress}},
+		"unknown chain": {{ChainName: "dogecoin", Address: ethAddress}},
+	} {
+		if _, err := Update(db, 1, Patch{SavedAddresses: &addresses}); !errors.Is(err, ErrInvalidAddress) {
+			t.Fatalf("%s err = %v", name, err)
+		}
+	}
+	settings, err := Get(db, 1)
+	if err != nil || len(settings.SavedAddresses) != 0 {
+		t.Fatalf("after rejected updates = %+v, %v", settings, err)
+	}
+}
@@ -0,0 +1,361 @@
+// Package walletsync periodically pulls every wallet from each DFNS org and
+// reconciles it with the local records. User wallets take DFNS's name, tags
+// and status; wallets DFNS holds that nothing local references, local wallets
+// DFNS no longer lists, and active wallets DFNS has archived are raised as
+// issues for admin review. The sync never changes which wallet is active:
+// admins rotate or retire wallets themselves.
+package walletsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/audit"
+	"socialpredict/services/dfns"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionIssueResolved is the audit action for an admin resolving an issue
+const ActionIssueResolved = "WALLET_SYNC_ISSUE_RESOLVED"
+
+// resolvedBySync marks issues closed because the mismatch went away
+const resolvedBySync = "sync"
+
+const (
+	auditTargetIssue = "wallet_sync_issue"
+	statusArchived   = "Archived"
+)
+
+var (
+	ErrIssueNotFound = errors.New("wallet sync issue not found")
+	ErrIssueResolved = errors.New("wallet sync issue is already resolved")
+)
+
+// Lister lists an org's DFNS wallets. *dfns.Client satisfies it.
+type Lister interface {
+	ListAllWallets(ctx context.Context) ([]dfns.WalletResponse, error)
+}
+
+// Listers returns the lister for a DFNS org, or nil if it is unavailable
+type Listers func(org string) Lister
+
+// OrgListers serves listers from the configured DFNS organizations
+func OrgListers(orgs *dfns.Orgs) Listers {
+	return func(org string) Lister {
+		if client := orgs.Client(org); client != nil {
+			return client
+		}
+		return nil
+	}
+}
+
+// Config holds wallet sync settings
+type Config struct {
+	// DFNS wallets used by the platform outside the wallet tables, such as
+	// the attestation wallet, which are not orphans
+	PlatformWalletIDs []string
+}
+
+// LoadConfigFromEnv reads ATTESTATION_DFNS_WALLET_ID
+func LoadConfigFromEnv() Config {
+	config := Config{}
+	if id := strings.TrimSpace(os.Getenv("ATTESTATION_DFNS_WALLET_ID")); id != "" {
+		config.PlatformWalletIDs = append(config.PlatformWalletIDs, id)
+	}
+	return config
+}
+
+// Service syncs wallet metadata from DFNS
+type Service struct {
+	db      *gorm.DB
+	orgs    []string
+	listers Listers
+	config  Config
+	clock   clock.Clock
+}
+
+// NewService creates a wallet sync service over the named DFNS orgs
+func NewService(db *gorm.DB, orgs []string, listers Listers, config Config, c clock.Clock) *Service {
+	return &Service{db: db, orgs: orgs, listers: listers, config: config, clock: c}
+}
+
+// Result summarises one sync
+type Result struct {
+	Orgs       int      `json:"orgs"`       // Orgs listed successfully
+	Wallets    int      `json:"wallets"`    // DFNS wallets seen
+	Updated    int      `json:"updated"`    // Local wallets whose DFNS name, tags or status changed
+	Raised     int      `json:"raised"`     // New issues
+	Cleared    int      `json:"cleared"`    // Open issues whose mismatch went away
+	FailedOrgs []string `json:"failedOrgs"` // Orgs that could not be listed; their wallets were left alone
+	OpenIssues int64    `json:"openIssues"` // Issues awaiting review after the sync
+}
+
+// localWallet is a wallet the platform knows, keyed by its DFNS ID
+type localWallet struct {
+	user     *models.Wallet
+	treasury *models.TreasuryWallet
+}
+
+// Run syncs every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if result, err := s.Sync(context.Background()); err != nil {
+			log.Printf("WalletSync: Sync failed: %v", err)
+		} else if result.Raised > 0 {
+			log.Printf("WalletSync: %d new wallet issues, %d open", result.Raised, result.OpenIssues)
+		}
+	}
+}
+
+// Sync reconciles each org's DFNS wallets with the local records. An org
+// that cannot be listed is skipped, so its wallets are never reported missing
+// because of an outage.
+func (s *Service) Sync(ctx context.Context) (*Result, error) {
+	result := &Result{FailedOrgs: []string{}}
+	for _, org := range s.orgs {
+		lister := s.listers(org)
+		if lister == nil {
+			result.FailedOrgs = append(result.FailedOrgs, org)
+			continue
+		}
+		remote, err := lister.ListAllWallets(ctx)
+		if err != nil {
+			log.Printf("WalletSync: Failed to list wallets in DFNS org %s: %v", org, err)
+			result.FailedOrgs = append(result.FailedOrgs, org)
+			continue
+		}
+		if err := s.syncOrg(org, remote, result); err != nil {
+			return nil, fmt.Errorf("sync DFNS org %s: %w", org, err)
+		}
+		result.Orgs++
+		result.Wallets += len(remote)
+	}
+	if err := s.db.Model(&models.WalletSyncIssue{}).Where("resolved_at IS NULL").Count(&result.OpenIssues).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Service) syncOrg(org string, remote []dfns.WalletResponse, result *Result) error {
+	now := s.clock.Now()
+	local, err := s.localWallets(org)
+	if err != nil {
+		return err
+	}
+	platform := make(map[string]bool, len(s.config.PlatformWalletIDs))
+	for _, id := range s.config.PlatformWalletIDs {
+		platform[id] = true
+	}
+
+	// Every issue this sync still sees; open ones not seen are cleared below
+	seen := map[string]bool{}
+	flag := func(issue models.WalletSyncIssue) error {
+		seen[issueKey(issue.Kind, issue.DfnsWalletID)] = true
+		raised, err := s.raise(issue, now)
+		if raised {
+			result.Raised++
+		}
+		return err
+	}
+
+	listed := make(map[string]bool, len(remote))
+	for _, dw := range remote {
+		listed[dw.ID] = true
+		known, ok := local[dw.ID]
+		switch {
+		case !ok && !platform[dw.ID]:
+			if err := flag(models.WalletSyncIssue{
+				Kind: models.WalletSyncOrphaned, DfnsOrg: org, DfnsWalletID: dw.ID, Network: dw.Network, Address: dw.Address,
+				Details: fmt.Sprintf("DFNS wallet %q (%s) has no local record", dw.Name, dw.Status),
+			}); err != nil {
+				return err
+			}
+		case ok && known.user != nil:
+			updated, err := s.updateUserWallet(known.user, dw, now)
+			if err != nil {
+				return err
+			}
+			if updated {
+				result.Updated++
+			}
+			if known.user.IsActive && dw.Status == statusArchived {
+				if err := flag(models.WalletSyncIssue{
+					Kind: models.WalletSyncArchived, DfnsOrg: org, DfnsWalletID: dw.ID, WalletID: &known.user.ID,
+					Network: dw.Network, Address: dw.Address,
+					Details: fmt.Sprintf("Active deposit wallet of user %d is archived in DFNS; rotate it", known.user.UserID),
+				}); err != nil {
+					return err
+				}
+			}
+		case ok && known.treasury != nil && known.treasury.IsActive && dw.Status == statusArchived:
+			if err := flag(models.WalletSyncIssue{
+				Kind: models.WalletSyncArchived, DfnsOrg: org, DfnsWalletID: dw.ID, TreasuryID: &known.treasury.ID,
+				Network: dw.Network, Address: dw.Address,
+				Details: fmt.Sprintf("Active treasury wallet %q is archived in DFNS", known.treasury.Name),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for id, known := range local {
+		if listed[id] {
+			continue
+		}
+		issue := models.WalletSyncIssue{Kind: models.WalletSyncMissing, DfnsOrg: org, DfnsWalletID: id}
+		if known.user != nil {
+			issue.WalletID, issue.Address = &known.user.ID, known.user.Address
+			issue.Details = fmt.Sprintf("Deposit wallet of user %d on %s is not listed by DFNS", known.user.UserID, known.user.ChainName)
+		} else {
+			issue.TreasuryID, issue.Address = &known.treasury.ID, known.treasury.Address
+			issue.Details = fmt.Sprintf("Treasury wallet %q on %s is not listed by DFNS", known.treasury.Name, known.treasury.ChainName)
+		}
+		if err := flag(issue); err != nil {
+			return err
+		}
+	}
+
+	cleared, err := s.clear(org, seen, now)
+	result.Cleared += cleared
+	return err
+}
+
+// localWallets returns the org's user and hot treasury wallets by DFNS ID
+func (s *Service) localWallets(org string) (map[string]localWallet, error) {
+	var users []models.Wallet
+	if err := s.db.Where("dfns_org = ? AND dfns_wallet_id <> ''", org).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	var treasury []models.TreasuryWallet
+	if err := s.db.Where("kind = ? AND dfns_org = ? AND dfns_wallet_id <> ''", models.TreasuryWalletHot, org).Find(&treasury).Error; err != nil {
+		return nil, err
+	}
+	local := make(map[string]localWallet, len(users)+len(treasury))
+	for i := range users {
+		local[users[i].DfnsWalletID] = localWallet{user: &users[i]}
+	}
+	for i := range treasury {
+		local[treasury[i].DfnsWalletID] = localWallet{treasury: &treasury[i]}
+	}
+	return local, nil
+}
+
+// updateUserWallet copies DFNS's name, tags and status onto the wallet and
+// reports whether any of them changed
+func (s *Service) updateUserWallet(wallet *models.Wallet, dw dfns.WalletResponse, now time.Time) (bool, error) {
+	changed := wallet.DfnsName != dw.Name || wallet.DfnsStatus != dw.Status || !sameTags(wallet.DfnsTags, dw.Tags)
+	wallet.DfnsName, wallet.DfnsStatus, wallet.DfnsTags, wallet.DfnsSyncedAt = dw.Name, dw.Status, dw.Tags, &now
+	err := s.db.Model(wallet).Select("dfns_name", "dfns_status", "dfns_tags", "dfns_synced_at").Updates(wallet).Error
+	return changed, err
+}
+
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// raise records an issue, or refreshes LastSeenAt on an existing one, and
+// reports whether it is new. Resolved issues stay resolved.
+func (s *Service) raise(issue models.WalletSyncIssue, now time.Time) (bool, error) {
+	issue.DetectedAt, issue.LastSeenAt = now, now
+	res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&issue)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		log.Printf("WalletSync: %s wallet %s in DFNS org %s: %s", issue.Kind, issue.DfnsWalletID, issue.DfnsOrg, issue.Details)
+		return true, nil
+	}
+	return false, s.db.Model(&models.WalletSyncIssue{}).
+		Where("kind = ? AND dfns_org = ? AND dfns_wallet_id = ? AND resolved_at IS NULL", issue.Kind, issue.DfnsOrg, issue.DfnsWalletID).
+		Update("last_seen_at", now).Error
+}
+
+// clear resolves the org's open issues the sync no longer sees
+func (s *Service) clear(org string, seen map[string]bool, now time.Time) (int, error) {
+	var open []models.WalletSyncIssue
+	if err := s.db.Where("dfns_org = ? AND resolved_at IS NULL", org).Find(&open).Error; err != nil {
+		return 0, err
+	}
+	cleared := 0
+	for _, issue := range open {
+		if seen[issueKey(issue.Kind, issue.DfnsWalletID)] {
+			continue
+		}
+		if err := s.db.Model(&issue).Updates(map[string]interface{}{
+			"resolved_at": now,
+			"resolved_by": resolvedBySync,
+			"note":        "Mismatch no longer seen",
+		}).Error; err != nil {
+			return cleared, err
+		}
+		cleared++
+	}
+	return cleared, nil
+}
+
+func issueKey(kind, walletID string) string {
+	return kind + "/" + walletID
+}
+
+// Issues returns wallet sync issues, newest first; only open ones unless all is set
+func Issues(db *gorm.DB, all bool) ([]models.WalletSyncIssue, error) {
+	issues := []models.WalletSyncIssue{}
+	query := db.Order("detected_at DESC, id DESC")
+	if !all {
+		query = query.Where("resolved_at IS NULL")
+	}
+	err := query.Find(&issues).Error
+	return issues, err
+}
+
+// Resolve marks an open issue reviewed and audits it. The same mismatch is
+// not raised again.
+func (s *Service) Resolve(id uint, note, actor string) (*models.WalletSyncIssue, error) {
+	var issue models.WalletSyncIssue
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&issue, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrIssueNotFound
+			}
+			return err
+		}
+		if issue.ResolvedAt != nil {
+			return ErrIssueResolved
+		}
+		now := s.clock.Now()
+		issue.ResolvedAt, issue.ResolvedBy, issue.Note = &now, actor, strings.TrimSpace(note)
+		if err := tx.Model(&issue).Select("resolved_at", "resolved_by", "note").Updates(&issue).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      actor,
+			Action:     ActionIssueResolved,
+			TargetType: auditTargetIssue,
+			TargetID:   issue.ID,
+			Details:    fmt.Sprintf("%s wallet %s in DFNS org %s: %s", issue.Kind, issue.DfnsWalletID, issue.DfnsOrg, issue.Note),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
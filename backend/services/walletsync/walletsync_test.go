@@ -0,0 +1,90 @@
+package walletsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/dfns"
+)
+
+type fakeLister struct {
+	wallets []dfns.WalletResponse
+	err     error
+}
+
+func (f *fakeLister) ListAllWallets(context.Context) ([]dfns.WalletResponse, error) {
+	return f.wallets, f.err
+}
+
+func TestSyncUpdatesWalletsAndFlagsMismatches(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Now())
+	deposit := models.Wallet{UserID: 7, DfnsWalletID: "wa-user", DfnsOrg: dfns.PrimaryOrg, ChainID: 1, ChainName: "ethereum", Address: "0xuser", IsActive: true}
+	gone := models.Wallet{UserID: 8, DfnsWalletID: "wa-gone", DfnsOrg: dfns.PrimaryOrg, ChainID: 1, ChainName: "ethereum", Address: "0xgone", IsActive: true}
+	hot := models.TreasuryWallet{Name: "hot", Kind: models.TreasuryWalletHot, ChainID: 1, ChainName: "ethereum", Address: "0xhot", DfnsOrg: dfns.PrimaryOrg, DfnsWalletID: "wa-hot", IsActive: true}
+	for _, record := range []interface{}{&deposit, &gone, &hot} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	lister := &fakeLister{wallets: []dfns.WalletResponse{
+		{ID: "wa-user", Name: "user-7", Status: "Archived", Tags: []string{"deposit", "user:7"}},
+		{ID: "wa-hot", Status: "Active"},
+		{ID: "wa-attest", Status: "Active"},
+		{ID: "wa-stray", Name: "test", Status: "Active", Network: "EthereumMainnet", Address: "0xstray"},
+	}}
+	svc := NewService(db, []string{dfns.PrimaryOrg, "secondary"}, func(org string) Lister {
+		if org == dfns.PrimaryOrg {
+			return lister
+		}
+		return &fakeLister{err: errors.New("unreachable")}
+	}, Config{PlatformWalletIDs: []string{"wa-attest"}}, fake)
+
+	result, err := svc.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Orgs != 1 || result.Updated != 1 || result.Raised != 3 || result.OpenIssues != 3 || len(result.FailedOrgs) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	db.First(&deposit, deposit.ID)
+	if deposit.DfnsName != "user-7" || deposit.DfnsStatus != "Archived" || len(deposit.DfnsTags) != 2 || deposit.DfnsSyncedAt == nil || !deposit.IsActive {
+		t.Fatalf("wallet after sync = %+v; want DFNS metadata copied and the wallet left active", deposit)
+	}
+	kinds := map[string]string{}
+	issues, _ := Issues(db, false)
+	for _, issue := range issues {
+		kinds[issue.DfnsWalletID] = issue.Kind
+	}
+	if kinds["wa-user"] != models.WalletSyncArchived || kinds["wa-gone"] != models.WalletSyncMissing || kinds["wa-stray"] != models.WalletSyncOrphaned {
+		t.Fatalf("issues = %v", kinds)
+	}
+
+	// A resolved issue stays resolved; a mismatch that goes away clears itself
+	var stray models.WalletSyncIssue
+	db.Where("dfns_wallet_id = ?", "wa-stray").First(&stray)
+	if _, err := svc.Resolve(stray.ID, "test wallet, archived in DFNS", "root"); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if _, err := svc.Resolve(stray.ID, "", "root"); !errors.Is(err, ErrIssueResolved) {
+		t.Fatalf("resolve again err = %v", err)
+	}
+	lister.wallets = append(lister.wallets, dfns.WalletResponse{ID: "wa-gone", Status: "Active"})
+	fake.Advance(time.Hour)
+	result, err = svc.Sync(context.Background())
+	if err != nil || result.Raised != 0 || result.Cleared != 1 || result.OpenIssues != 1 || result.Updated != 1 {
+		t.Fatalf("second sync = %+v, %v", result, err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ? AND target_id = ?", ActionIssueResolved, stray.ID).Count(&audits)
+	if audits != 1 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
@@ -0,0 +1,267 @@
+// Package washtrading flags users, or accounts linked through shared external
+// addresses, that repeatedly trade against themselves in a market.
+package washtrading
+
+import (
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Detection thresholds
+const (
+	matchWindow = 10 * time.Minute // Opposite trades this close together are treated as matched
+	minRepeats  = 2                // Matched pairs per account pair before anything is flagged
+	maxScore    = 100
+)
+
+// MarketReport summarises the wash trading found in a market
+type MarketReport struct {
+	MarketID       uint                   `json:"marketId"`
+	IntegrityScore int                    `json:"integrityScore"` // 100 means no flagged volume
+	TotalVolume    int64                  `json:"totalVolume"`
+	FlaggedVolume  int64                  `json:"flaggedVolume"`
+	FlaggedUsers   []string               `json:"flaggedUsers"`
+	Flags          []models.WashTradeFlag `json:"flags"`
+}
+
+// Detector scans market bets for self-matching patterns
+type Detector struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewDetector creates a wash trading detector
+func NewDetector(db *gorm.DB, c clock.Clock) *Detector {
+	return &Detector{db: db, clock: c}
+}
+
+type pairKey struct{ user, counterparty string }
+
+// ScanMarket detects and stores wash trades in a market and returns its report
+func (d *Detector) ScanMarket(marketID uint) (*MarketReport, error) {
+	var bets []models.Bet
+	if err := d.db.Where("market_id = ?", marketID).Order("placed_at ASC, id ASC").Find(&bets).Error; err != nil {
+		return nil, err
+	}
+
+	usernames := make([]string, 0)
+	seen := map[string]bool{}
+	var totalVolume int64
+	for _, bet := range bets {
+		totalVolume += abs(bet.Amount)
+		if !seen[bet.Username] {
+			seen[bet.Username] = true
+			usernames = append(usernames, bet.Username)
+		}
+	}
+	links, err := d.linkedAccounts(usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	// Greedily pair each bet with the first later opposite bet by the same or a
+	// linked account, so no bet counts towards more than one flag
+	candidates := map[pairKey][]models.WashTradeFlag{}
+	matched := map[uint]bool{}
+	now := d.clock.Now()
+	for i, first := range bets {
+		if matched[first.ID] {
+			continue
+		}
+		for _, second := range bets[i+1:] {
+			if second.PlacedAt.Sub(first.PlacedAt) > matchWindow {
+				break
+			}
+			if matched[second.ID] || direction(first)*direction(second) >= 0 {
+				continue
+			}
+
+			pattern := models.WashPatternSelfRoundTrip
+			if first.Username != second.Username {
+				if !links[first.Username][second.Username] {
+					continue
+				}
+				pattern = models.WashPatternLinkedOpposite
+			}
+
+			matched[first.ID], matched[second.ID] = true, true
+			key := pairKey{first.Username, second.Username}
+			if key.counterparty < key.user {
+				key = pairKey{key.counterparty, key.user}
+			}
+			candidates[key] = append(candidates[key], models.WashTradeFlag{
+				MarketID:     marketID,
+				Username:     first.Username,
+				Counterparty: second.Username,
+				Pattern:      pattern,
+				BetID:        first.ID,
+				CounterBetID: second.ID,
+				Volume:       abs(first.Amount) + abs(second.Amount),
+				DetectedAt:   now,
+			})
+			break
+		}
+	}
+
+	var flags []models.WashTradeFlag
+	for _, pairs := range candidates {
+		if len(pairs) >= minRepeats {
+			flags = append(flags, pairs...)
+		}
+	}
+	if len(flags) > 0 {
+		if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&flags).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return d.report(marketID, totalVolume)
+}
+
+// ScanRecent scans every market that has bets placed since the given time
+func (d *Detector) ScanRecent(since time.Time) (int, error) {
+	var marketIDs []uint
+	if err := d.db.Model(&models.Bet{}).Where("placed_at >= ?", since).Distinct().Pluck("market_id", &marketIDs).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range marketIDs {
+		if _, err := d.ScanMarket(id); err != nil {
+			return 0, err
+		}
+	}
+	return len(marketIDs), nil
+}
+
+// Run rescans recently active markets every interval. It never returns.
+func (d *Detector) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		// Overlap by the match window so pairs spanning two runs are still found
+		if _, err := d.ScanRecent(d.clock.Now().Add(-interval - matchWindow)); err != nil {
+			log.Printf("Wash trading: scan failed: %v", err)
+		}
+	}
+}
+
+// report builds a market report from the stored flags
+func (d *Detector) report(marketID uint, totalVolume int64) (*MarketReport, error) {
+	var flags []models.WashTradeFlag
+	if err := d.db.Where("market_id = ?", marketID).Order("detected_at ASC, id ASC").Find(&flags).Error; err != nil {
+		return nil, err
+	}
+
+	report := &MarketReport{
+		MarketID:       marketID,
+		IntegrityScore: maxScore,
+		TotalVolume:    totalVolume,
+		FlaggedUsers:   []string{},
+		Flags:          flags,
+	}
+	flagged := map[string]bool{}
+	for _, f := range flags {
+		report.FlaggedVolume += f.Volume
+		for _, name := range []string{f.Username, f.Counterparty} {
+			if !flagged[name] {
+				flagged[name] = true
+				report.FlaggedUsers = append(report.FlaggedUsers, name)
+			}
+		}
+	}
+	if totalVolume > 0 {
+		ratio := math.Min(1, float64(report.FlaggedVolume)/float64(totalVolume))
+		report.IntegrityScore = maxScore - int(math.Round(ratio*maxScore))
+	}
+	return report, nil
+}
+
+// linkedAccounts links users that share a withdrawal destination or deposit source address
+func (d *Detector) linkedAccounts(usernames []string) (map[string]map[string]bool, error) {
+	links := map[string]map[string]bool{}
+	if len(usernames) < 2 {
+		return links, nil
+	}
+
+	var users []models.User
+	if err := d.db.Select("id", "username").Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	nameByID := make(map[int64]string, len(users))
+	ids := make([]int64, 0, len(users))
+	for _, u := range users {
+		nameByID[u.ID] = u.Username
+		ids = append(ids, u.ID)
+	}
+
+	var rows []struct {
+		UserID  int64
+		Address string
+	}
+	if err := d.db.Model(&models.WithdrawalRequest{}).Select("user_id, to_address AS address").
+		Where("user_id IN ?", ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	var deposits []struct {
+		UserID  int64
+		Address string
+	}
+	if err := d.db.Model(&models.CryptoTransaction{}).Select("user_id, from_address AS address").
+		Where("user_id IN ? AND type = ? AND from_address <> ''", ids, models.TxTypeDeposit).Scan(&deposits).Error; err != nil {
+		return nil, err
+	}
+	rows = append(rows, deposits...)
+
+	owners := map[string]map[string]bool{}
+	for _, row := range rows {
+		addr := strings.ToLower(row.Address)
+		if addr == "" {
+			continue
+		}
+		if owners[addr] == nil {
+			owners[addr] = map[string]bool{}
+		}
+		owners[addr][nameByID[row.UserID]] = true
+	}
+	for _, names := range owners {
+		for a := range names {
+			for b := range names {
+				if a == b {
+					continue
+				}
+				if links[a] == nil {
+					links[a] = map[string]bool{}
+				}
+				links[a][b] = true
+			}
+		}
+	}
+	return links, nil
+}
+
+// direction is +1 for a bet that gains exposure to YES (buying YES or selling
+// NO) and -1 for one that gains exposure to NO
+func direction(bet models.Bet) int {
+	dir := 1
+	if bet.Outcome == "NO" {
+		dir = -1
+	}
+	if bet.Amount < 0 {
+		dir = -dir
+	}
+	return dir
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package washtrading
+
+import (
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/risk"
+
+	"gorm.io/gorm"
+)
+
+func createBets(t *testing.T, db *gorm.DB, bets ...models.Bet) {
+	t.Helper()
+	for i := range bets {
+		if err := db.Create(&bets[i]).Error; err != nil {
+			t.Fatalf("create bet: %v", err)
+		}
+	}
+}
+
+func TestScanMarketFlagsSelfRoundTrips(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	detector := NewDetector(db, clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	createBets(t, db,
+		// alice buys and immediately sells twice, farming volume
+		modelstesting.GenerateBet(100, "YES", "alice", 1, -50*time.Minute),
+		modelstesting.GenerateBet(-100, "YES", "alice", 1, -49*time.Minute),
+		modelstesting.GenerateBet(100, "NO", "alice", 1, -30*time.Minute),
+		modelstesting.GenerateBet(100, "YES", "alice", 1, -29*time.Minute),
+		// bob trades normally
+		modelstesting.GenerateBet(200, "YES", "bob", 1, -20*time.Minute),
+	)
+
+	report, err := detector.ScanMarket(1)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(report.Flags) != 2 {
+		t.Fatalf("expected 2 flags, got %+v", report.Flags)
+	}
+	for _, f := range report.Flags {
+		if f.Pattern != models.WashPatternSelfRoundTrip || f.Username != "alice" {
+			t.Errorf("unexpected flag %+v", f)
+		}
+	}
+	if report.TotalVolume != 600 || report.FlaggedVolume != 400 || report.IntegrityScore != 33 {
+		t.Errorf("unexpected report totals: %+v", report)
+	}
+
+	// Rescanning is idempotent
+	again, err := detector.ScanMarket(1)
+	if err != nil || len(again.Flags) != 2 {
+		t.Fatalf("rescan: %v, %d flags", err, len(again.Flags))
+	}
+}
+
+func TestScanMarketIgnoresSingleOrSlowTrades(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	detector := NewDetector(db, clock.NewFake(time.Now()))
+
+	createBets(t, db,
+		modelstesting.GenerateBet(100, "YES", "alice", 1, -5*time.Hour),
+		modelstesting.GenerateBet(-100, "YES", "alice", 1, -4*time.Hour),
+		modelstesting.GenerateBet(50, "YES", "alice", 1, -2*time.Hour),
+		modelstesting.GenerateBet(50, "NO", "alice", 1, -119*time.Minute),
+	)
+
+	report, err := detector.ScanMarket(1)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(report.Flags) != 0 || report.IntegrityScore != 100 {
+		t.Fatalf("expected clean market, got %+v", report)
+	}
+}
+
+func TestLinkedAccountsFeedRiskScore(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	detector := NewDetector(db, clock.NewFake(now))
+
+	alice := modelstesting.GenerateUser("alice", 0)
+	carol := modelstesting.GenerateUser("carol", 0)
+	for _, u := range []*models.User{&alice, &carol} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	// Both accounts withdraw to the same external address
+	for _, u := range []models.User{alice, carol} {
+		wr := models.WithdrawalRequest{UserID: u.ID, ChainID: 8453, ChainName: "base", TokenSymbol: "USDC",
+			Amount: models.CreditsToMicro(10), ToAddress: "0xShared", Status: models.TxStatusCompleted}
+		if err := db.Create(&wr).Error; err != nil {
+			t.Fatalf("create withdrawal: %v", err)
+		}
+	}
+
+	createBets(t, db,
+		modelstesting.GenerateBet(100, "YES", "alice", 1, -50*time.Minute),
+		modelstesting.GenerateBet(100, "NO", "carol", 1, -49*time.Minute),
+		modelstesting.GenerateBet(100, "NO", "alice", 1, -30*time.Minute),
+		modelstesting.GenerateBet(100, "YES", "carol", 1, -29*time.Minute),
+	)
+
+	report, err := detector.ScanMarket(1)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(report.Flags) != 2 || report.Flags[0].Pattern != models.WashPatternLinkedOpposite || report.IntegrityScore != 0 {
+		t.Fatalf("expected linked flags, got %+v", report)
+	}
+
+	req := &models.WithdrawalRequest{UserID: carol.ID, ToAddress: "0xShared", Amount: models.CreditsToMicro(10)}
+	a := risk.NewScorer(db, clock.NewFake(now)).Score(req)
+	found := false
+	for _, reason := range a.Reasons {
+		found = found || reason == risk.ReasonWashTrading
+	}
+	if !found {
+		t.Fatalf("expected wash trading risk reason, got %+v", a)
+	}
+}
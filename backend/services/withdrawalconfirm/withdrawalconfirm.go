@@ -0,0 +1,235 @@
+// Package withdrawalconfirm implements the optional email confirmation step
+// for withdrawals. A user who turns it on has each withdrawal held in
+// AWAITING_USER_CONFIRMATION until they open a signed one-time link sent to
+// their email address, so a hijacked session alone cannot move funds out.
+// Requests left unconfirmed past the link's lifetime expire and are refunded.
+package withdrawalconfirm
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/services/ledger"
+	"socialpredict/services/mailer"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLinkTTL = 30 * time.Minute
+	defaultLinkURL = "http://localhost/wallet/confirm-withdrawal"
+)
+
+var (
+	ErrInvalidToken    = errors.New("invalid confirmation link")
+	ErrExpired         = errors.New("confirmation link has expired")
+	ErrNotAwaiting     = errors.New("withdrawal is not awaiting confirmation")
+	ErrEmailNotSent    = errors.New("failed to send confirmation email")
+	ErrRequestNotFound = errors.New("withdrawal request not found")
+)
+
+// Config holds email confirmation settings
+type Config struct {
+	LinkTTL time.Duration // How long a link stays valid before the request expires
+	LinkURL string        // Page the link opens; the token is added as ?token=
+	Secret  []byte        // HMAC key that signs links
+}
+
+// LoadConfigFromEnv reads WITHDRAWAL_CONFIRM_TTL, WITHDRAWAL_CONFIRM_URL and
+// WITHDRAWAL_CONFIRM_SECRET. Without a secret a random one is used, so links
+// stop working when the server restarts.
+func LoadConfigFromEnv() Config {
+	config := Config{LinkTTL: defaultLinkTTL, LinkURL: defaultLinkURL}
+	if d, err := time.ParseDuration(os.Getenv("WITHDRAWAL_CONFIRM_TTL")); err == nil && d > 0 {
+		config.LinkTTL = d
+	}
+	if v := os.Getenv("WITHDRAWAL_CONFIRM_URL"); v != "" {
+		config.LinkURL = v
+	}
+	if v := os.Getenv("WITHDRAWAL_CONFIRM_SECRET"); v != "" {
+		config.Secret = []byte(v)
+	} else {
+		log.Printf("WithdrawalConfirm: WITHDRAWAL_CONFIRM_SECRET not set, using a random key")
+		config.Secret = make([]byte, 32)
+		rand.Read(config.Secret)
+	}
+	return config
+}
+
+// Service sends and checks withdrawal confirmation links
+type Service struct {
+	db     *gorm.DB
+	mail   mailer.Mailer
+	config Config
+	clock  clock.Clock
+}
+
+// NewService creates a withdrawal confirmation service
+func NewService(db *gorm.DB, mail mailer.Mailer, config Config, c clock.Clock) *Service {
+	return &Service{db: db, mail: mail, config: config, clock: c}
+}
+
+// SendLink emails user a link confirming req, which must be awaiting confirmation
+func (s *Service) SendLink(user *models.User, req *models.WithdrawalRequest) error {
+	expiresAt := req.CreatedAt.Add(s.config.LinkTTL)
+	link := s.config.LinkURL + "?token=" + url.QueryEscape(s.token(req.ID, expiresAt))
+
+	body := fmt.Sprintf("A withdrawal of %s %s to %s on %s was requested from your account.\n\n"+
+		"To confirm it, open this link within %s:\n%s\n\n"+
+		"If you did not request this, do not open the link and change your password now. "+
+		"The withdrawal will be cancelled and refunded automatically.",
+		models.FormatMicroCredits(req.Amount), req.TokenSymbol, req.ToAddress, req.ChainName, s.config.LinkTTL, link)
+	if err := s.mail.Send(user.Email, "Confirm your withdrawal", body); err != nil {
+		return fmt.Errorf("%w: %v", ErrEmailNotSent, err)
+	}
+	return nil
+}
+
+// Confirm checks a link token and moves its request on to admin review:
+// PENDING, or ON_HOLD if screening flagged it when it was submitted. A link
+// works once, since only a request still awaiting confirmation can move on.
+func (s *Service) Confirm(token string) (*models.WithdrawalRequest, error) {
+	id, expiresAt, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if !s.clock.Now().Before(expiresAt) {
+		return nil, ErrExpired
+	}
+
+	var req models.WithdrawalRequest
+	if err := s.db.First(&req, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRequestNotFound
+		}
+		return nil, err
+	}
+
+	next := models.TxStatusPending
+	if req.HoldReason != "" {
+		next = models.TxStatusOnHold
+	}
+	result := s.db.Model(&models.WithdrawalRequest{}).
+		Where("id = ? AND status = ?", req.ID, models.TxStatusAwaitingConfirmation).
+		Update("status", next)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotAwaiting
+	}
+	req.Status = next
+	return &req, nil
+}
+
+// ExpireStale expires and refunds requests not confirmed within the link
+// lifetime, returning how many were expired
+func (s *Service) ExpireStale() (int, error) {
+	cutoff := s.clock.Now().Add(-s.config.LinkTTL)
+	var stale []models.WithdrawalRequest
+	if err := s.db.Where("status = ? AND created_at <= ?", models.TxStatusAwaitingConfirmation, cutoff).
+		Find(&stale).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range stale {
+		req := &stale[i]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			now := s.clock.Now()
+			result := tx.Model(&models.WithdrawalRequest{}).
+				Where("id = ? AND status = ?", req.ID, models.TxStatusAwaitingConfirmation).
+				Updates(map[string]interface{}{
+					"status":        models.TxStatusExpired,
+					"error_message": "Not confirmed by email in time",
+					"processed_at":  now,
+				})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			if _, err := ledger.Apply(tx, &models.User{ID: req.UserID}, ledger.Posting{
+				Type:          models.LedgerTypeWithdrawalRefund,
+				Amount:        req.Amount,
+				ReferenceType: ledger.ReferenceTypeWithdrawalRequest,
+				ReferenceID:   req.ID,
+				Description:   "Refund of withdrawal not confirmed by email in time",
+			}); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, fmt.Errorf("withdrawal %d: %w", req.ID, err)
+		}
+	}
+	return expired, nil
+}
+
+// Run expires unconfirmed requests every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.ExpireStale()
+		if err != nil {
+			log.Printf("WithdrawalConfirm: Expiry failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("WithdrawalConfirm: Expired and refunded %d unconfirmed withdrawals", n)
+		}
+	}
+}
+
+// token signs "<request id>.<expiry unix>"
+func (s *Service) token(requestID uint, expiresAt time.Time) string {
+	payload := strconv.FormatUint(uint64(requestID), 10) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + s.sign(payload)
+}
+
+func (s *Service) parse(token string) (uint, time.Time, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	idPart, expPart, ok := strings.Cut(payload, ".")
+	if !ok {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	id, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	exp, err := strconv.ParseInt(expPart, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	return uint(id), time.Unix(exp, 0), nil
+}
+
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte("withdrawal-confirm:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
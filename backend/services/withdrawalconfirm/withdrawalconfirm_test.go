@@ -0,0 +1,137 @@
+package withdrawalconfirm
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	to, body string
+}
+
+func (f *fakeMailer) Send(to, subject, body string) error {
+	f.to, f.body = to, body
+	return nil
+}
+
+func setup(t *testing.T) (*gorm.DB, *clock.Fake, *fakeMailer, *Service, *models.User) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	fake := clock.NewFake(time.Date(2026, 3, 27, 9, 0, 0, 0, time.UTC))
+	mail := &fakeMailer{}
+	svc := NewService(db, mail, Config{LinkTTL: 30 * time.Minute, LinkURL: "https://example.com/confirm", Secret: []byte("secret")}, fake)
+
+	user := modelstesting.GenerateUser("alice", 100)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, fake, mail, svc, &user
+}
+
+func createRequest(t *testing.T, db *gorm.DB, fake *clock.Fake, userID int64, holdReason string) *models.WithdrawalRequest {
+	t.Helper()
+	req := models.WithdrawalRequest{UserID: userID, ChainID: 8453, ChainName: "base", TokenSymbol: "USDC",
+		Amount: 40 * models.MicroCreditsPerCredit, ToAddress: "0x1111111111111111111111111111111111111111",
+		Status: models.TxStatusAwaitingConfirmation, HoldReason: holdReason}
+	req.CreatedAt = fake.Now()
+	if err := db.Create(&req).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+	return &req
+}
+
+func linkToken(t *testing.T, body string) string {
+	t.Helper()
+	link := regexp.MustCompile(`https://example\.com/confirm\?token=\S+`).FindString(body)
+	if link == "" {
+		t.Fatalf("no link in email body %q", body)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	return u.Query().Get("token")
+}
+
+func TestConfirmMovesRequestToReviewOnce(t *testing.T) {
+	db, fake, mail, svc, user := setup(t)
+	req := createRequest(t, db, fake, user.ID, "")
+	held := createRequest(t, db, fake, user.ID, "Sanctions list match")
+
+	if err := svc.SendLink(user, req); err != nil {
+		t.Fatalf("SendLink: %v", err)
+	}
+	if mail.to != user.Email {
+		t.Errorf("mailed %q, want %q", mail.to, user.Email)
+	}
+	token := linkToken(t, mail.body)
+
+	tampered := strings.Replace(token, token[:2], "AA", 1)
+	if _, err := svc.Confirm(tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered token = %v, want ErrInvalidToken", err)
+	}
+
+	confirmed, err := svc.Confirm(token)
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if confirmed.ID != req.ID || confirmed.Status != models.TxStatusPending {
+		t.Errorf("confirmed = %+v", confirmed)
+	}
+	if _, err := svc.Confirm(token); !errors.Is(err, ErrNotAwaiting) {
+		t.Errorf("second confirm = %v, want ErrNotAwaiting", err)
+	}
+
+	// A request flagged by screening goes on hold once confirmed
+	svc.SendLink(user, held)
+	confirmed, err = svc.Confirm(linkToken(t, mail.body))
+	if err != nil || confirmed.Status != models.TxStatusOnHold {
+		t.Errorf("held request confirm = %+v, %v", confirmed, err)
+	}
+}
+
+func TestUnconfirmedRequestsExpireAndRefund(t *testing.T) {
+	db, fake, mail, svc, user := setup(t)
+	req := createRequest(t, db, fake, user.ID, "")
+	svc.SendLink(user, req)
+	token := linkToken(t, mail.body)
+
+	fake.Advance(10 * time.Minute)
+	if n, err := svc.ExpireStale(); err != nil || n != 0 {
+		t.Fatalf("ExpireStale before TTL = %d, %v", n, err)
+	}
+
+	fake.Advance(25 * time.Minute)
+	if _, err := svc.Confirm(token); !errors.Is(err, ErrExpired) {
+		t.Errorf("late confirm = %v, want ErrExpired", err)
+	}
+	if n, err := svc.ExpireStale(); err != nil || n != 1 {
+		t.Fatalf("ExpireStale = %d, %v", n, err)
+	}
+
+	var got models.WithdrawalRequest
+	db.First(&got, req.ID)
+	if got.Status != models.TxStatusExpired || got.ProcessedAt == nil {
+		t.Errorf("expired request = %+v", got)
+	}
+	var refunded models.User
+	db.First(&refunded, user.ID)
+	if refunded.AccountBalance != models.CreditsToMicro(140) {
+		t.Errorf("balance after refund = %d, want 140", refunded.AccountBalance)
+	}
+
+	// Expiring again does not refund twice
+	if n, _ := svc.ExpireStale(); n != 0 {
+		t.Errorf("second expiry = %d", n)
+	}
+}
@@ -0,0 +1,62 @@
+package withdrawalflow
+
+import (
+	"log"
+	"time"
+
+	"socialpredict/models"
+	"socialpredict/services/saga"
+
+	"gorm.io/gorm"
+)
+
+// Reconciler records withdrawal transfers that DFNS started but that could
+// not be recorded at approval, by retrying their sagas
+type Reconciler struct {
+	db          *gorm.DB
+	coordinator *saga.Coordinator
+}
+
+// NewReconciler creates a reconciler for the coordinator's withdrawal sagas
+func NewReconciler(db *gorm.DB, coordinator *saga.Coordinator) *Reconciler {
+	return &Reconciler{db: db, coordinator: coordinator}
+}
+
+// Reconcile retries every TRANSFER_UNRECORDED withdrawal and returns how many
+// were recorded
+func (r *Reconciler) Reconcile() (int, error) {
+	var unrecorded []models.WithdrawalRequest
+	if err := r.db.Where("status = ?", models.TxStatusUnrecorded).Order("id").Find(&unrecorded).Error; err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, withdrawalReq := range unrecorded {
+		inst, err := r.coordinator.Find(Name, withdrawalReq.ID)
+		if err != nil || inst.Status != models.SagaStatusFailed {
+			log.Printf("WithdrawalFlow: No failed saga to reconcile withdrawal %d", withdrawalReq.ID)
+			continue
+		}
+		if _, err := r.coordinator.Retry(inst.ID); err != nil {
+			log.Printf("WithdrawalFlow: Reconciling withdrawal %d failed: %v", withdrawalReq.ID, err)
+			continue
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+// Run reconciles unrecorded transfers every interval
+func (r *Reconciler) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := r.Reconcile()
+		if err != nil {
+			log.Printf("WithdrawalFlow: Reconciliation failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("WithdrawalFlow: Recorded %d withdrawal transfers", n)
+		}
+	}
+}
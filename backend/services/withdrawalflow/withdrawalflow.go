@@ -0,0 +1,395 @@
+// Package withdrawalflow defines the withdrawal saga: mark the request
+// APPROVING, start the DFNS transfer from the chain's hot wallet and record
+// it, wait for the webhook, mark the withdrawal completed and notify the user.
+// A failed transfer is compensated by refunding the user. A transfer that
+// started but could not be recorded is flagged TRANSFER_UNRECORDED and left
+// for the Reconciler rather than undone.
+package withdrawalflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"socialpredict/clock"
+	"socialpredict/logger"
+	"socialpredict/models"
+	"socialpredict/services/chains"
+	"socialpredict/services/dfns"
+	"socialpredict/services/ledger"
+	"socialpredict/services/notify"
+	"socialpredict/services/saga"
+	"socialpredict/services/treasury"
+	"socialpredict/services/walletsettings"
+	"socialpredict/services/withdrawalnotes"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Name identifies withdrawal saga instances; the reference is the withdrawal request ID
+const Name = "withdrawal"
+
+// Saga data keys
+const (
+	DataAdminID        = "adminId"
+	DataNote           = "note"
+	DataApprovedAmount = "approvedAmount" // Micro-credits, set when approving less than requested
+	DataAdjustment     = "adjustmentReason"
+	DataTransactionID  = "transactionId"
+	DataDfnsTransferID = "dfnsTransferId"
+	DataFromAddress    = "fromAddress"
+	DataWalletID       = "walletId" // Set when the transfer is paid from the user's own wallet
+	DataTxHash         = "txHash"
+)
+
+var (
+	ErrNotApprovable       = errors.New("withdrawal cannot be approved in its current status")
+	ErrInvalidAdjustment   = errors.New("approved amount must be positive and less than the requested amount")
+	ErrWalletNotFound      = errors.New("user wallet not found for this chain")
+	ErrTokenUnavailable    = errors.New("token not available on this chain")
+	ErrProviderUnavailable = errors.New("wallet provider unavailable")
+	ErrTransferFailed      = errors.New("failed to initiate blockchain transfer")
+)
+
+// Register adds the withdrawal saga to a coordinator. The transfer step is the
+// pivot: once DFNS reports it complete the user is never refunded, and later
+// failures are left FAILED for an admin to retry.
+func Register(coordinator *saga.Coordinator, dfnsOrgs *dfns.Orgs, c clock.Clock) {
+	f := &flow{dfnsOrgs: dfnsOrgs, clock: c}
+	coordinator.Register(&saga.Definition{
+		Name: Name,
+		Steps: []saga.Step{
+			{Name: "reserve_withdrawal", Run: f.reserve, Compensate: f.release},
+			{Name: "initiate_transfer", Run: f.initiateTransfer, Compensate: f.refund, InDoubt: f.flagUnrecorded, Await: true, Pivot: true},
+			{Name: "complete_withdrawal", Run: f.complete},
+			{Name: "notify_user", Run: f.notifyCompleted},
+		},
+	})
+}
+
+// Approve starts the saga for a withdrawal request on behalf of an admin
+func Approve(coordinator *saga.Coordinator, withdrawalID uint, adminID int64, note string) (*models.SagaInstance, error) {
+	return coordinator.Start(Name, withdrawalID, map[string]string{
+		DataAdminID: strconv.FormatInt(adminID, 10),
+		DataNote:    note,
+	})
+}
+
+// ApproveAdjusted starts the saga for a withdrawal approved for less than was
+// requested. The difference is refunded to the user when the request is
+// reserved, and only amountMicro is transferred.
+func ApproveAdjusted(coordinator *saga.Coordinator, withdrawalID uint, adminID int64, note string, amountMicro int64, reason string) (*models.SagaInstance, error) {
+	return coordinator.Start(Name, withdrawalID, map[string]string{
+		DataAdminID:        strconv.FormatInt(adminID, 10),
+		DataNote:           note,
+		DataApprovedAmount: strconv.FormatInt(amountMicro, 10),
+		DataAdjustment:     reason,
+	})
+}
+
+type flow struct {
+	dfnsOrgs *dfns.Orgs
+	clock    clock.Clock
+}
+
+// reserve moves the request to APPROVING before the transfer starts, so it
+// can no longer be rejected, cancelled or approved again while DFNS is called
+func (f *flow) reserve(tx *gorm.DB, s *models.SagaInstance) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+	if !withdrawalReq.CanBeApproved() {
+		return ErrNotApprovable
+	}
+	withdrawalReq.Status = models.TxStatusApproving
+	if adminID, err := strconv.ParseInt(s.Data[DataAdminID], 10, 64); err == nil {
+		withdrawalReq.AdminID = &adminID
+	}
+	if s.Data[DataApprovedAmount] != "" {
+		if err := adjust(tx, &withdrawalReq, s.Data[DataApprovedAmount], s.Data[DataAdjustment]); err != nil {
+			return err
+		}
+	}
+	if err := tx.Save(&withdrawalReq).Error; err != nil {
+		return err
+	}
+	if note := s.Data[DataNote]; note != "" {
+		return withdrawalnotes.Add(tx, models.WithdrawalNote{WithdrawalID: withdrawalReq.ID, AdminID: withdrawalReq.AdminID, Body: "Approved: " + note})
+	}
+	return nil
+}
+
+// adjust reduces a request to the approved amount and refunds the difference.
+// The adjustment stands if the approval is later undone: the user already has
+// the difference back.
+func adjust(tx *gorm.DB, withdrawalReq *models.WithdrawalRequest, approved, reason string) error {
+	amount, err := strconv.ParseInt(approved, 10, 64)
+	if err != nil || amount <= 0 || amount >= withdrawalReq.Amount {
+		return ErrInvalidAdjustment
+	}
+	refund := withdrawalReq.Amount - amount
+
+	if _, err := ledger.Apply(tx, &models.User{ID: withdrawalReq.UserID}, ledger.Posting{
+		Type:          models.LedgerTypeWithdrawalRefund,
+		Amount:        refund,
+		ReferenceType: ledger.ReferenceTypeWithdrawalRequest,
+		ReferenceID:   withdrawalReq.ID,
+		Description:   "Withdrawal approved for a reduced amount: " + reason,
+	}); err != nil {
+		return err
+	}
+
+	if withdrawalReq.OriginalAmount == 0 {
+		withdrawalReq.OriginalAmount = withdrawalReq.Amount
+	}
+	withdrawalReq.Amount = amount
+	withdrawalReq.AdjustmentReason = reason
+
+	logger.WithTrace(withdrawalReq.TraceID).Info("withdrawal approved for a reduced amount", "withdrawal_id", withdrawalReq.ID,
+		"requested", models.FormatMicroCredits(withdrawalReq.OriginalAmount), "approved", models.FormatMicroCredits(amount), "refunded", models.FormatMicroCredits(refund))
+	if err := withdrawalnotes.Add(tx, models.WithdrawalNote{WithdrawalID: withdrawalReq.ID, AdminID: withdrawalReq.AdminID,
+		Body: fmt.Sprintf("Adjusted from %s to %s credits: %s", models.FormatMicroCredits(withdrawalReq.OriginalAmount), models.FormatMicroCredits(amount), reason)}); err != nil {
+		return err
+	}
+	return notify.Send(tx, withdrawalReq.UserID, notify.TypeWithdrawalAdjusted, "Withdrawal adjusted",
+		fmt.Sprintf("Your withdrawal of %s credits was approved for %s credits (%s). The remaining %s credits were returned to your balance.",
+			models.FormatMicroCredits(withdrawalReq.OriginalAmount), models.FormatMicroCredits(amount), reason, models.FormatMicroCredits(refund)))
+}
+
+// release returns a request to PENDING when its transfer never started
+func (f *flow) release(tx *gorm.DB, s *models.SagaInstance) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+	// A refunded transfer has already settled the request
+	if withdrawalReq.Status != models.TxStatusApproving {
+		return nil
+	}
+	withdrawalReq.Status = models.TxStatusPending
+	withdrawalReq.AdminID = nil
+	if err := tx.Save(&withdrawalReq).Error; err != nil {
+		return err
+	}
+	return withdrawalnotes.Add(tx, models.WithdrawalNote{WithdrawalID: withdrawalReq.ID, Body: "Approval undone: the transfer never started"})
+}
+
+// initiateTransfer starts the DFNS transfer and records the outbound
+// transaction. On a retry after the records failed, the transfer already
+// started is recorded instead of starting another.
+func (f *flow) initiateTransfer(tx *gorm.DB, s *models.SagaInstance) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+	if withdrawalReq.Status != models.TxStatusApproving && withdrawalReq.Status != models.TxStatusUnrecorded {
+		return ErrNotApprovable
+	}
+	log := logger.WithTrace(withdrawalReq.TraceID).With("withdrawal_id", withdrawalReq.ID)
+
+	token, err := chains.ActiveToken(tx, withdrawalReq.ChainName, withdrawalReq.TokenSymbol)
+	if errors.Is(err, chains.ErrTokenNotFound) {
+		return ErrTokenUnavailable
+	}
+	if err != nil {
+		return fmt.Errorf("token configuration not found: %w", err)
+	}
+	tokenContract := token.ContractAddress
+	tokenAmount := dfns.MicroCreditsToTokenAmount(withdrawalReq.Amount, token.Decimals)
+
+	if s.Data[DataDfnsTransferID] == "" {
+		if err := f.startTransfer(tx, s, &withdrawalReq, tokenContract, tokenAmount); err != nil {
+			return err
+		}
+	}
+	transferID := s.Data[DataDfnsTransferID]
+
+	// From here on the transfer is under way; a failure to record it must
+	// not release or refund the request
+	var walletID *uint
+	if id, err := strconv.ParseUint(s.Data[DataWalletID], 10, 32); err == nil {
+		wid := uint(id)
+		walletID = &wid
+	}
+	cryptoTx := models.CryptoTransaction{
+		UserID:        withdrawalReq.UserID,
+		WalletID:      walletID,
+		Type:          models.TxTypeWithdrawal,
+		Status:        models.TxStatusApproved,
+		ChainID:       withdrawalReq.ChainID,
+		ChainName:     withdrawalReq.ChainName,
+		TokenSymbol:   withdrawalReq.TokenSymbol,
+		TokenAddress:  tokenContract,
+		Amount:        tokenAmount,
+		AmountCredits: withdrawalReq.Amount,
+		FromAddress:   s.Data[DataFromAddress],
+		ToAddress:     withdrawalReq.ToAddress,
+		DfnsTxID:      transferID,
+	}
+	if err := tx.Create(&cryptoTx).Error; err != nil {
+		return fmt.Errorf("%w: transfer %s not recorded: %v", saga.ErrInDoubt, transferID, err)
+	}
+
+	now := f.clock.Now()
+	withdrawalReq.Status = models.TxStatusApproved
+	withdrawalReq.TransactionID = &cryptoTx.ID
+	withdrawalReq.TransferID = ""
+	withdrawalReq.ErrorMessage = ""
+	withdrawalReq.ProcessedAt = &now
+	if err := tx.Save(&withdrawalReq).Error; err != nil {
+		return fmt.Errorf("%w: transfer %s not recorded: %v", saga.ErrInDoubt, transferID, err)
+	}
+
+	log.Info("withdrawal transfer recorded", "dfns_transfer_id", transferID, "tx_id", cryptoTx.ID)
+	s.Data[DataTransactionID] = strconv.FormatUint(uint64(cryptoTx.ID), 10)
+	return nil
+}
+
+// startTransfer asks DFNS to send the withdrawal and keeps the transfer in the
+// saga's data, which is saved even if the transaction that follows fails
+func (f *flow) startTransfer(tx *gorm.DB, s *models.SagaInstance, withdrawalReq *models.WithdrawalRequest, tokenContract, tokenAmount string) error {
+	log := logger.WithTrace(withdrawalReq.TraceID).With("withdrawal_id", withdrawalReq.ID)
+
+	// Pay out from the chain's treasury hot wallet, or from the user's own
+	// deposit wallet where no hot wallet is configured
+	var source struct {
+		walletID     *uint
+		org          string
+		dfnsWalletID string
+		address      string
+	}
+	if hot, err := treasury.HotWallet(tx, withdrawalReq.ChainName); err == nil {
+		source.org, source.dfnsWalletID, source.address = hot.DfnsOrg, hot.DfnsWalletID, hot.Address
+	} else if errors.Is(err, treasury.ErrNoHotWallet) {
+		var wallet models.Wallet
+		if err := tx.Where("user_id = ? AND chain_id = ? AND is_active = ?",
+			withdrawalReq.UserID, withdrawalReq.ChainID, true).First(&wallet).Error; err != nil {
+			return ErrWalletNotFound
+		}
+		source.walletID, source.org, source.dfnsWalletID, source.address = &wallet.ID, wallet.DfnsOrg, wallet.DfnsWalletID, wallet.Address
+	} else {
+		return err
+	}
+
+	// Transfers must be signed by the org that holds the wallet
+	dfnsClient := f.dfnsOrgs.Client(source.org)
+	if dfnsClient == nil {
+		log.Error("DFNS org unavailable for withdrawal", "dfns_org", source.org)
+		return ErrProviderUnavailable
+	}
+	// Saga steps outlive the request that started them, so the transfer is
+	// bounded by the client's per-call timeout rather than a request context
+	ctx := logger.WithTraceID(context.Background(), withdrawalReq.TraceID)
+	dfnsTransfer, err := dfnsClient.InitiateTransfer(ctx, source.dfnsWalletID,
+		dfns.NewTokenTransfer(withdrawalReq.ChainName, withdrawalReq.ToAddress, tokenContract, tokenAmount, withdrawalReq.TraceID))
+	if err != nil {
+		log.Error("failed to initiate DFNS transfer", "error", err)
+		return ErrTransferFailed
+	}
+	log.Info("withdrawal transfer started", "dfns_org", source.org, "dfns_transfer_id", dfnsTransfer.ID)
+
+	s.Data[DataDfnsTransferID] = dfnsTransfer.ID
+	s.Data[DataFromAddress] = source.address
+	if source.walletID != nil {
+		s.Data[DataWalletID] = strconv.FormatUint(uint64(*source.walletID), 10)
+	}
+	return nil
+}
+
+// flagUnrecorded marks a request whose transfer started but could not be
+// recorded, so it is neither approved again nor refunded before the
+// Reconciler records it
+func (f *flow) flagUnrecorded(tx *gorm.DB, s *models.SagaInstance) error {
+	transferID := s.Data[DataDfnsTransferID]
+	if transferID == "" {
+		return nil
+	}
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+	logger.WithTrace(withdrawalReq.TraceID).Error("withdrawal transfer initiated but not recorded",
+		"withdrawal_id", withdrawalReq.ID, "dfns_transfer_id", transferID, "error", s.LastError)
+
+	withdrawalReq.Status = models.TxStatusUnrecorded
+	withdrawalReq.TransferID = transferID
+	withdrawalReq.ErrorMessage = fmt.Sprintf("Transfer %s initiated but not recorded", transferID)
+	return tx.Save(&withdrawalReq).Error
+}
+
+// refund returns the withdrawn credits after a failed transfer
+func (f *flow) refund(tx *gorm.DB, s *models.SagaInstance) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+
+	now := f.clock.Now()
+	reason := s.LastError
+	if reason == "" {
+		reason = "Transfer failed on blockchain"
+	}
+	if _, err := ledger.Apply(tx, &models.User{ID: withdrawalReq.UserID}, ledger.Posting{
+		Type:          models.LedgerTypeWithdrawalRefund,
+		Amount:        withdrawalReq.Amount,
+		ReferenceType: ledger.ReferenceTypeWithdrawalRequest,
+		ReferenceID:   withdrawalReq.ID,
+		Description:   "Refund of failed withdrawal: " + reason,
+	}); err != nil {
+		return err
+	}
+	if withdrawalReq.TransactionID != nil {
+		if err := tx.Model(&models.CryptoTransaction{}).Where("id = ?", *withdrawalReq.TransactionID).
+			Updates(map[string]interface{}{"status": models.TxStatusFailed, "error_message": "Transfer failed", "processed_at": now}).Error; err != nil {
+			return err
+		}
+	}
+	withdrawalReq.Status = models.TxStatusFailed
+	withdrawalReq.ErrorMessage = reason
+	withdrawalReq.ProcessedAt = &now
+	if err := tx.Save(&withdrawalReq).Error; err != nil {
+		return err
+	}
+
+	logger.WithTrace(withdrawalReq.TraceID).Info("refunded failed withdrawal", "withdrawal_id", withdrawalReq.ID,
+		"user_id", withdrawalReq.UserID, "credits", models.FormatMicroCredits(withdrawalReq.Amount))
+	return notify.Send(tx, withdrawalReq.UserID, notify.TypeWithdrawalFailed, "Withdrawal failed",
+		fmt.Sprintf("Your withdrawal of %s credits failed and the credits were returned to your balance.", models.FormatMicroCredits(withdrawalReq.Amount)))
+}
+
+// complete marks the transaction and withdrawal completed once the transfer is final
+func (f *flow) complete(tx *gorm.DB, s *models.SagaInstance) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+
+	now := f.clock.Now()
+	if withdrawalReq.TransactionID != nil {
+		if err := tx.Model(&models.CryptoTransaction{}).Where("id = ?", *withdrawalReq.TransactionID).
+			Updates(map[string]interface{}{"status": models.TxStatusCompleted, "tx_hash": s.Data[DataTxHash], "processed_at": now}).Error; err != nil {
+			return err
+		}
+	}
+	withdrawalReq.Status = models.TxStatusCompleted
+	withdrawalReq.ProcessedAt = &now
+	return tx.Save(&withdrawalReq).Error
+}
+
+// notifyCompleted tells the user their withdrawal arrived, unless they turned
+// that notification off in their wallet settings
+func (f *flow) notifyCompleted(tx *gorm.DB, s *models.SagaInstance) error {
+	var withdrawalReq models.WithdrawalRequest
+	if err := tx.First(&withdrawalReq, s.ReferenceID).Error; err != nil {
+		return err
+	}
+	if wanted, err := walletsettings.NotifyWithdrawalCompleted(tx, withdrawalReq.UserID); err != nil || !wanted {
+		return err
+	}
+	return notify.Send(tx, withdrawalReq.UserID, notify.TypeWithdrawalCompleted, "Withdrawal completed",
+		fmt.Sprintf("Your withdrawal of %s %s to %s has completed.",
+			models.FormatMicroCredits(withdrawalReq.Amount), withdrawalReq.TokenSymbol, withdrawalReq.ToAddress))
+}
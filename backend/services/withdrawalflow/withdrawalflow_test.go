@@ -0,0 +1,135 @@
+package withdrawalflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"socialpredict/clock"
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+	"socialpredict/services/dfns"
+	"socialpredict/services/saga"
+
+	"gorm.io/gorm"
+)
+
+// newApproval sets up a pending withdrawal paid from the user's own sandbox
+// wallet. Transfers never settle during the test.
+func newApproval(t *testing.T, org string) (*gorm.DB, *saga.Coordinator, *dfns.Client, *models.Wallet, *models.WithdrawalRequest) {
+	t.Helper()
+	db := modelstesting.NewFakeDB(t)
+	sandbox := dfns.NewSandbox(dfns.SandboxConfig{ConfirmDelay: time.Hour})
+	orgs := sandbox.Orgs()
+	client := orgs.Primary()
+
+	user := modelstesting.GenerateUser("alice", 0)
+	db.Create(&user)
+	dfnsWallet, err := client.CreateWallet(context.Background(), dfns.CreateWalletRequest{Network: "Ethereum", Name: "alice-ethereum"})
+	if err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	wallet := models.Wallet{UserID: user.ID, DfnsOrg: org, DfnsWalletID: dfnsWallet.ID, ChainID: 1, ChainName: "ethereum", Address: dfnsWallet.Address, IsActive: true}
+	db.Create(&wallet)
+	withdrawalReq := models.WithdrawalRequest{UserID: user.ID, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC",
+		Amount: models.CreditsToMicro(25), ToAddress: "0x00000000000000000000000000000000000000aa", Status: models.TxStatusPending}
+	db.Create(&withdrawalReq)
+
+	coord := saga.NewCoordinator(db, clock.NewFake(time.Date(2026, 5, 24, 9, 0, 0, 0, time.UTC)))
+	Register(coord, orgs, clock.NewFake(time.Date(2026, 5, 24, 9, 0, 0, 0, time.UTC)))
+	return db, coord, client, &wallet, &withdrawalReq
+}
+
+func TestUnrecordedTransferIsFlaggedAndReconciled(t *testing.T) {
+	db, coord, client, wallet, withdrawalReq := newApproval(t, dfns.PrimaryOrg)
+
+	// DFNS takes the transfer but the database then refuses the records
+	failRecords := true
+	db.Callback().Create().Before("gorm:create").Register("test:fail_records", func(tx *gorm.DB) {
+		if failRecords && tx.Statement.Table == "crypto_transactions" {
+			tx.AddError(errors.New("disk full"))
+		}
+	})
+
+	if _, err := Approve(coord, withdrawalReq.ID, 1, "ok"); !errors.Is(err, saga.ErrInDoubt) {
+		t.Fatalf("approve err = %v, want ErrInDoubt", err)
+	}
+	db.First(withdrawalReq, withdrawalReq.ID)
+	if withdrawalReq.Status != models.TxStatusUnrecorded || withdrawalReq.TransferID == "" || withdrawalReq.TransactionID != nil {
+		t.Fatalf("withdrawal = %s transfer %q tx %v, want flagged unrecorded", withdrawalReq.Status, withdrawalReq.TransferID, withdrawalReq.TransactionID)
+	}
+	if _, err := Approve(coord, withdrawalReq.ID, 1, "again"); !errors.Is(err, saga.ErrAlreadyActive) {
+		t.Fatalf("second approve err = %v, want ErrAlreadyActive", err)
+	}
+
+	failRecords = false
+	if n, err := NewReconciler(db, coord).Reconcile(); err != nil || n != 1 {
+		t.Fatalf("reconcile = %d, %v", n, err)
+	}
+	transferID := withdrawalReq.TransferID
+	db.First(withdrawalReq, withdrawalReq.ID)
+	if withdrawalReq.Status != models.TxStatusApproved || withdrawalReq.TransactionID == nil || withdrawalReq.TransferID != "" {
+		t.Fatalf("withdrawal after reconcile = %+v", withdrawalReq)
+	}
+	var cryptoTx models.CryptoTransaction
+	db.First(&cryptoTx, *withdrawalReq.TransactionID)
+	if cryptoTx.DfnsTxID != transferID || cryptoTx.FromAddress != wallet.Address || cryptoTx.WalletID == nil || *cryptoTx.WalletID != wallet.ID {
+		t.Fatalf("recorded transaction = %+v", cryptoTx)
+	}
+
+	// Only the one transfer was sent, and the saga now waits on it
+	transfers, err := client.ListTransfers(context.Background(), wallet.DfnsWalletID)
+	if err != nil || len(transfers.Items) != 1 {
+		t.Fatalf("transfers = %+v, %v", transfers, err)
+	}
+	if inst, _ := coord.Find(Name, withdrawalReq.ID); inst.Status != models.SagaStatusWaiting {
+		t.Fatalf("saga status = %s, want WAITING", inst.Status)
+	}
+}
+
+func TestTransferNotStartedReleasesWithdrawal(t *testing.T) {
+	db, coord, _, _, withdrawalReq := newApproval(t, "missing")
+
+	if _, err := Approve(coord, withdrawalReq.ID, 1, "ok"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("approve err = %v, want ErrProviderUnavailable", err)
+	}
+	db.First(withdrawalReq, withdrawalReq.ID)
+	if withdrawalReq.Status != models.TxStatusPending || withdrawalReq.AdminID != nil {
+		t.Fatalf("withdrawal = %s admin %v, want released to PENDING", withdrawalReq.Status, withdrawalReq.AdminID)
+	}
+	if inst, _ := coord.Find(Name, withdrawalReq.ID); inst.Status != models.SagaStatusCompensated {
+		t.Fatalf("saga status = %s, want COMPENSATED", inst.Status)
+	}
+}
+
+func TestAdjustedApprovalRefundsDifference(t *testing.T) {
+	db, coord, _, _, withdrawalReq := newApproval(t, dfns.PrimaryOrg)
+
+	if _, err := ApproveAdjusted(coord, withdrawalReq.ID, 1, "", models.CreditsToMicro(30), "more than asked"); !errors.Is(err, ErrInvalidAdjustment) {
+		t.Fatalf("approve above the request err = %v, want ErrInvalidAdjustment", err)
+	}
+	if _, err := ApproveAdjusted(coord, withdrawalReq.ID, 1, "", models.CreditsToMicro(20), "clawback of referral bonus"); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+
+	db.First(withdrawalReq, withdrawalReq.ID)
+	if withdrawalReq.Amount != models.CreditsToMicro(20) || withdrawalReq.OriginalAmount != models.CreditsToMicro(25) || withdrawalReq.AdjustmentReason != "clawback of referral bonus" {
+		t.Fatalf("withdrawal = amount %d original %d reason %q", withdrawalReq.Amount, withdrawalReq.OriginalAmount, withdrawalReq.AdjustmentReason)
+	}
+	var user models.User
+	db.First(&user, withdrawalReq.UserID)
+	if user.BalanceMicroCredits() != models.CreditsToMicro(5) {
+		t.Fatalf("balance = %d, want the 5 credit difference refunded", user.BalanceMicroCredits())
+	}
+	var cryptoTx models.CryptoTransaction
+	db.First(&cryptoTx, *withdrawalReq.TransactionID)
+	if cryptoTx.AmountCredits != models.CreditsToMicro(20) {
+		t.Fatalf("transfer recorded for %d, want the approved amount", cryptoTx.AmountCredits)
+	}
+	var notes int64
+	db.Model(&models.WithdrawalNote{}).Where("withdrawal_id = ?", withdrawalReq.ID).Count(&notes)
+	if notes != 1 {
+		t.Fatalf("notes = %d, want the adjustment noted", notes)
+	}
+}
@@ -0,0 +1,120 @@
+// Package withdrawalnotes keeps the thread of reviewer notes on withdrawal
+// requests. Admins append notes, optionally flagging the request for other
+// reviewers, and approvals, rejections and hold releases add theirs.
+package withdrawalnotes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"socialpredict/models"
+	"socialpredict/services/audit"
+
+	"gorm.io/gorm"
+)
+
+// ActionNoteAdded is the audit action for a note added through the notes endpoint
+const ActionNoteAdded = "WITHDRAWAL_NOTE_ADDED"
+
+// SystemAuthor signs notes the platform adds itself
+const SystemAuthor = "system"
+
+const maxBodyLength = 2000
+
+var (
+	ErrNotFound    = errors.New("withdrawal request not found")
+	ErrInvalidNote = errors.New("a note needs text of at most 2000 characters")
+)
+
+// Add appends a note to a withdrawal request's thread within tx. An empty
+// Author is filled in from AdminID's username. Blank notes are skipped, so
+// an approval without a note adds nothing.
+func Add(tx *gorm.DB, note models.WithdrawalNote) error {
+	note.Body = strings.TrimSpace(note.Body)
+	if note.Body == "" {
+		return nil
+	}
+	if note.Author == "" && note.AdminID != nil {
+		var usernames []string
+		if err := tx.Model(&models.User{}).Where("id = ?", *note.AdminID).Pluck("username", &usernames).Error; err != nil {
+			return err
+		}
+		if len(usernames) > 0 {
+			note.Author = usernames[0]
+		}
+	}
+	if note.Author == "" {
+		note.Author = SystemAuthor
+	}
+	return tx.Create(&note).Error
+}
+
+// Append adds an admin's note to a withdrawal request and audits it
+func Append(db *gorm.DB, withdrawalID uint, admin *models.User, body string, flag bool) (*models.WithdrawalNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || len(body) > maxBodyLength {
+		return nil, ErrInvalidNote
+	}
+	note := models.WithdrawalNote{WithdrawalID: withdrawalID, AdminID: &admin.ID, Author: admin.Username, Body: body, Flag: flag}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.WithdrawalRequest{}).Where("id = ?", withdrawalID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditLog{
+			Actor:      admin.Username,
+			Action:     ActionNoteAdded,
+			TargetType: "withdrawal_request",
+			TargetID:   withdrawalID,
+			Details:    fmt.Sprintf("note=%d flag=%t", note.ID, flag),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// List returns a withdrawal request's notes, oldest first
+func List(db *gorm.DB, withdrawalID uint) ([]models.WithdrawalNote, error) {
+	notes := []models.WithdrawalNote{}
+	err := db.Where("withdrawal_id = ?", withdrawalID).Order("created_at, id").Find(&notes).Error
+	return notes, err
+}
+
+// Summary is how many notes a request has and whether any flags it
+type Summary struct {
+	Count   int
+	Flagged bool
+}
+
+// Summarize returns the note summary of each request that has notes
+func Summarize(db *gorm.DB, withdrawalIDs []uint) (map[uint]Summary, error) {
+	summaries := map[uint]Summary{}
+	if len(withdrawalIDs) == 0 {
+		return summaries, nil
+	}
+	var rows []struct {
+		WithdrawalID uint
+		Notes        int
+		Flags        int
+	}
+	if err := db.Model(&models.WithdrawalNote{}).
+		Select("withdrawal_id, COUNT(*) AS notes, SUM(CASE WHEN flag THEN 1 ELSE 0 END) AS flags").
+		Where("withdrawal_id IN ?", withdrawalIDs).
+		Group("withdrawal_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		summaries[row.WithdrawalID] = Summary{Count: row.Notes, Flagged: row.Flags > 0}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,56 @@
+package withdrawalnotes
+
+import (
+	"errors"
+	"testing"
+
+	"socialpredict/models"
+	"socialpredict/models/modelstesting"
+)
+
+func TestNotesThreadOnWithdrawal(t *testing.T) {
+	db := modelstesting.NewFakeDB(t)
+	admin := modelstesting.GenerateUser("reviewer", 0)
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	wr := models.WithdrawalRequest{UserID: 99, ChainID: 1, ChainName: "ethereum", TokenSymbol: "USDC", Amount: 1, ToAddress: "0xabc", Status: models.TxStatusPending}
+	if err := db.Create(&wr).Error; err != nil {
+		t.Fatalf("create withdrawal: %v", err)
+	}
+
+	if err := Add(db, models.WithdrawalNote{WithdrawalID: wr.ID, AdminID: &admin.ID, Body: "Approved: looks fine"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := Add(db, models.WithdrawalNote{WithdrawalID: wr.ID, Body: "  "}); err != nil {
+		t.Fatalf("add blank: %v", err)
+	}
+	if _, err := Append(db, wr.ID, &admin, "Same address as a chargeback last week", true); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if _, err := Append(db, wr.ID, &admin, " ", false); !errors.Is(err, ErrInvalidNote) {
+		t.Fatalf("blank append err = %v", err)
+	}
+	if _, err := Append(db, wr.ID+1, &admin, "hello", false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown withdrawal err = %v", err)
+	}
+
+	notes, err := List(db, wr.ID)
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("notes = %+v, %v", notes, err)
+	}
+	if notes[0].Author != "reviewer" || notes[0].Flag || !notes[1].Flag {
+		t.Fatalf("notes = %+v", notes)
+	}
+
+	summaries, err := Summarize(db, []uint{wr.ID, wr.ID + 1})
+	if err != nil || summaries[wr.ID] != (Summary{Count: 2, Flagged: true}) || summaries[wr.ID+1].Count != 0 {
+		t.Fatalf("summaries = %+v, %v", summaries, err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ?", ActionNoteAdded).Count(&audits)
+	if audits != 1 {
+		t.Fatalf("audit entries = %d", audits)
+	}
+}
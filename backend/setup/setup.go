@@ -17,6 +17,7 @@ type MarketCreation struct {
 	InitialMarketYes           int64   `yaml:"initialMarketYes"`
 	InitialMarketNo            int64   `yaml:"initialMarketNo"`
 	MinimumFutureHours         float64 `yaml:"minimumFutureHours"`
+	DefaultLiquidityParameter  float64 `yaml:"defaultLiquidityParameter"` // LMSR liquidity for markets created without one
 }
 
 type MarketIncentives struct {
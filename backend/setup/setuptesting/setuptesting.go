@@ -30,6 +30,7 @@ func MockEconomicConfig() *setup.EconomicConfig {
 				InitialMarketSubsidization: 10,
 				InitialMarketYes:           0,
 				InitialMarketNo:            0,
+				DefaultLiquidityParameter:  100,
 			},
 			MarketIncentives: setup.MarketIncentives{
 				CreateMarketCost: 10,